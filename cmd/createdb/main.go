@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/feutils"
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+	"github.com/spf13/cobra"
+)
+
+// ----------------------------------------------------------------
+// データベースの作成 (bin/scripts/createdb.c 相当)
+// ----------------------------------------------------------------
+// 管理用のデータベースに接続して CREATE DATABASE を送り、説明を指定していれば COMMENT ON
+// DATABASE で付ける。データベースの名前を省略すると、PGDATABASE、PGUSER、実行しているユーザーの
+// 名前の順に選ぶ。
+
+// createdbOptions はコマンドラインで指定した設定
+type createdbOptions struct {
+	cparams        feutils.ConnParams
+	maintenanceDB  string
+	echo           bool
+	owner          string
+	tablespace     string
+	template       string
+	encoding       string
+	strategy       string
+	locale         string
+	lcCollate      string
+	lcCtype        string
+	localeProvider string
+	builtinLocale  string
+	icuLocale      string
+	icuRules       string
+}
+
+func main() {
+	progname := filepath.Base(os.Args[0])
+
+	var opts createdbOptions
+	var rootCmd = &cobra.Command{
+		Use:   "createdb [OPTION]... [DBNAME] [DESCRIPTION]",
+		Short: "createdb creates a PostgreSQL database.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 2 {
+				return fmt.Errorf("too many command-line arguments (first is \"%s\")", args[2])
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var dbname, comment string
+			if len(args) > 0 {
+				dbname = args[0]
+			}
+			if len(args) > 1 {
+				comment = args[1]
+			}
+			if err := createdb(&opts, dbname, comment); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+				os.Exit(1)
+			}
+			return nil
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	// -h は接続先のホストに使うため、ヘルプは -? にする
+	rootCmd.Flags().BoolP("help", "?", false, "show this help, then exit")
+	rootCmd.Flags().StringVarP(&opts.tablespace, "tablespace", "D", "", "default tablespace for the database")
+	rootCmd.Flags().BoolVarP(&opts.echo, "echo", "e", false, "show the commands being sent to the server")
+	rootCmd.Flags().StringVarP(&opts.encoding, "encoding", "E", "", "encoding for the database")
+	rootCmd.Flags().StringVarP(&opts.locale, "locale", "l", "", "locale settings for the database")
+	rootCmd.Flags().StringVar(&opts.lcCollate, "lc-collate", "", "LC_COLLATE setting for the database")
+	rootCmd.Flags().StringVar(&opts.lcCtype, "lc-ctype", "", "LC_CTYPE setting for the database")
+	rootCmd.Flags().StringVar(&opts.builtinLocale, "builtin-locale", "", "builtin locale setting for the database")
+	rootCmd.Flags().StringVar(&opts.icuLocale, "icu-locale", "", "ICU locale setting for the database")
+	rootCmd.Flags().StringVar(&opts.icuRules, "icu-rules", "", "ICU rules setting for the database")
+	rootCmd.Flags().StringVar(&opts.localeProvider, "locale-provider", "", "locale provider for the database's default collation")
+	rootCmd.Flags().StringVarP(&opts.owner, "owner", "O", "", "database user to own the new database")
+	rootCmd.Flags().StringVarP(&opts.strategy, "strategy", "S", "", "database creation strategy wal_log or file_copy")
+	rootCmd.Flags().StringVarP(&opts.template, "template", "T", "", "template database to copy")
+	rootCmd.Flags().StringVarP(&opts.cparams.Host, "host", "h", "", "database server host or socket directory")
+	rootCmd.Flags().StringVarP(&opts.cparams.Port, "port", "p", "", "database server port")
+	rootCmd.Flags().StringVarP(&opts.cparams.Username, "username", "U", "", "user name to connect as")
+	rootCmd.Flags().StringVar(&opts.maintenanceDB, "maintenance-db", "", "alternate maintenance database")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+		fmt.Fprintf(os.Stderr, "Try \"%s --help\" for more information.\n", progname)
+		os.Exit(1)
+	}
+}
+
+// createdb は dbname を作り、comment が空でなければ説明を付ける
+func createdb(opts *createdbOptions, dbname, comment string) error {
+	if opts.locale != "" {
+		if opts.lcCtype != "" {
+			return errors.New("only one of --locale and --lc-ctype can be specified")
+		}
+		if opts.lcCollate != "" {
+			return errors.New("only one of --locale and --lc-collate can be specified")
+		}
+	}
+	if dbname == "" {
+		dbname = os.Getenv("PGDATABASE")
+	}
+	if dbname == "" {
+		name, err := feutils.DefaultUserName()
+		if err != nil {
+			return err
+		}
+		dbname = name
+	}
+
+	// postgres を作るときに postgres へは接続できない
+	maintenanceDB := opts.maintenanceDB
+	if maintenanceDB == "" && dbname == "postgres" {
+		maintenanceDB = "template1"
+	}
+	conn, err := feutils.ConnectMaintenanceDatabase(opts.cparams, maintenanceDB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	sql := buildCreateDatabase(opts, dbname)
+	if err := feutils.ExecuteCommand(conn, sql, opts.echo); err != nil {
+		return fmt.Errorf("database creation failed: %s", err.Error())
+	}
+	if comment != "" {
+		sql := fmt.Sprintf("COMMENT ON DATABASE %s IS %s;", libpq.EscapeIdentifier(dbname), libpq.EscapeLiteral(comment))
+		if err := feutils.ExecuteCommand(conn, sql, opts.echo); err != nil {
+			return fmt.Errorf("comment creation failed (database was created): %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// buildCreateDatabase は dbname を作る CREATE DATABASE の文を作る
+func buildCreateDatabase(opts *createdbOptions, dbname string) string {
+	var b strings.Builder
+	b.WriteString("CREATE DATABASE " + libpq.EscapeIdentifier(dbname))
+	for _, o := range []struct {
+		keyword string
+		value   string
+		ident   bool
+	}{
+		{"OWNER", opts.owner, true},
+		{"TABLESPACE", opts.tablespace, true},
+		{"ENCODING", opts.encoding, false},
+		{"STRATEGY", opts.strategy, true},
+		{"TEMPLATE", opts.template, true},
+		{"LOCALE", opts.locale, false},
+		{"LC_COLLATE", opts.lcCollate, false},
+		{"LC_CTYPE", opts.lcCtype, false},
+	} {
+		if o.value == "" {
+			continue
+		}
+		if o.ident {
+			fmt.Fprintf(&b, " %s %s", o.keyword, libpq.EscapeIdentifier(o.value))
+		} else {
+			fmt.Fprintf(&b, " %s %s", o.keyword, libpq.EscapeLiteral(o.value))
+		}
+	}
+	// ロケールの提供元はキーワードとしてそのまま書く
+	if opts.localeProvider != "" {
+		b.WriteString(" LOCALE_PROVIDER " + opts.localeProvider)
+	}
+	for _, o := range [][2]string{{"BUILTIN_LOCALE", opts.builtinLocale}, {"ICU_LOCALE", opts.icuLocale}, {"ICU_RULES", opts.icuRules}} {
+		if o[1] != "" {
+			fmt.Fprintf(&b, " %s %s", o[0], libpq.EscapeLiteral(o[1]))
+		}
+	}
+	b.WriteString(";")
+	return b.String()
+}
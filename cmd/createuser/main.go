@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/feutils"
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+	"github.com/spf13/cobra"
+)
+
+// ----------------------------------------------------------------
+// ロールの作成 (bin/scripts/createuser.c 相当)
+// ----------------------------------------------------------------
+// 管理用のデータベースに接続して CREATE ROLE を送る。-P で入力したパスワードは、平文で送らない
+// よう、クライアントでサーバーの password_encryption の形式にしてから送る。
+//
+// 属性は -s と -S のように付けるか付けないかを対にして指定し、後に指定した方が勝つ。スーパー
+// ユーザー、データベースの作成、ロールの作成を指定しなかった場合、--interactive なら尋ね、
+// そうでなければ付けない。スーパーユーザーにするなら、データベースとロールの作成も許す。
+
+// trivalue は指定されなかったことを区別する真偽値 (enum trivalue 相当)
+type trivalue int
+
+const (
+	triDefault trivalue = iota
+	triNo
+	triYes
+)
+
+// triFlag は対のフラグの片方で、指定されると value を *target に書く
+type triFlag struct {
+	target *trivalue
+	value  trivalue
+}
+
+func (f triFlag) String() string { return "" }
+func (f triFlag) Type() string   { return "bool" }
+
+func (f triFlag) Set(string) error {
+	*f.target = f.value
+	return nil
+}
+
+// createuserOptions はコマンドラインで指定した設定
+type createuserOptions struct {
+	cparams     feutils.ConnParams
+	echo        bool
+	interactive bool
+	pwprompt    bool
+	connLimit   string
+	pwexpiry    string
+	roles       []string
+	members     []string
+	admins      []string
+	superuser   trivalue
+	createdb    trivalue
+	createrole  trivalue
+	inherit     trivalue
+	login       trivalue
+	replication trivalue
+	bypassrls   trivalue
+}
+
+func main() {
+	progname := filepath.Base(os.Args[0])
+
+	var opts createuserOptions
+	var rootCmd = &cobra.Command{
+		Use:   "createuser [OPTION]... [ROLENAME]",
+		Short: "createuser creates a new PostgreSQL role.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("too many command-line arguments (first is \"%s\")", args[1])
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var newuser string
+			if len(args) > 0 {
+				newuser = args[0]
+			}
+			if err := createuser(&opts, newuser); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+				os.Exit(1)
+			}
+			return nil
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	flags := rootCmd.Flags()
+	triVar := func(target *trivalue, name, shorthand, usage string, value trivalue) {
+		flags.VarPF(triFlag{target, value}, name, shorthand, usage).NoOptDefVal = "true"
+	}
+	// -h は接続先のホストに使うため、ヘルプは -? にする
+	flags.BoolP("help", "?", false, "show this help, then exit")
+	flags.StringArrayVarP(&opts.admins, "with-admin", "a", nil, "ROLE will be a member of new role with admin option")
+	flags.StringVarP(&opts.connLimit, "connection-limit", "c", "", "connection limit for role (default: no limit)")
+	triVar(&opts.createdb, "createdb", "d", "role can create new databases", triYes)
+	triVar(&opts.createdb, "no-createdb", "D", "role cannot create databases (default)", triNo)
+	flags.BoolVarP(&opts.echo, "echo", "e", false, "show the commands being sent to the server")
+	flags.StringArrayVarP(&opts.roles, "member-of", "g", nil, "new role will be a member of ROLE")
+	flags.StringArrayVar(&opts.roles, "role", nil, "new role will be a member of ROLE")
+	flags.MarkHidden("role")
+	triVar(&opts.inherit, "inherit", "i", "role inherits privileges of roles it is a member of (default)", triYes)
+	triVar(&opts.inherit, "no-inherit", "I", "role does not inherit privileges", triNo)
+	triVar(&opts.login, "login", "l", "role can login (default)", triYes)
+	triVar(&opts.login, "no-login", "L", "role cannot login", triNo)
+	flags.StringArrayVarP(&opts.members, "with-member", "m", nil, "ROLE will be a member of new role")
+	flags.BoolVarP(&opts.pwprompt, "pwprompt", "P", false, "assign a password to new role")
+	triVar(&opts.createrole, "createrole", "r", "role can create new roles", triYes)
+	triVar(&opts.createrole, "no-createrole", "R", "role cannot create roles (default)", triNo)
+	triVar(&opts.superuser, "superuser", "s", "role will be superuser", triYes)
+	triVar(&opts.superuser, "no-superuser", "S", "role will not be superuser (default)", triNo)
+	flags.StringVarP(&opts.pwexpiry, "valid-until", "v", "", "password expiration date and time for role")
+	flags.BoolVar(&opts.interactive, "interactive", false, "prompt for missing role name and attributes rather than using defaults")
+	triVar(&opts.bypassrls, "bypassrls", "", "role can bypass row-level security (RLS) policy", triYes)
+	triVar(&opts.bypassrls, "no-bypassrls", "", "role cannot bypass row-level security (RLS) policy (default)", triNo)
+	triVar(&opts.replication, "replication", "", "role can initiate replication", triYes)
+	triVar(&opts.replication, "no-replication", "", "role cannot initiate replication (default)", triNo)
+	flags.StringVarP(&opts.cparams.Host, "host", "h", "", "database server host or socket directory")
+	flags.StringVarP(&opts.cparams.Port, "port", "p", "", "database server port")
+	flags.StringVarP(&opts.cparams.Username, "username", "U", "", "user name to connect as (not the one to create)")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+		fmt.Fprintf(os.Stderr, "Try \"%s --help\" for more information.\n", progname)
+		os.Exit(1)
+	}
+}
+
+// createuser は newuser を作る。省略されたロールの名前と属性は既定値にするか尋ねる
+func createuser(opts *createuserOptions, newuser string) error {
+	connLimit := -2
+	if opts.connLimit != "" {
+		n, err := strconv.ParseInt(strings.TrimSpace(opts.connLimit), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value \"%s\" for option %s", opts.connLimit, "-c/--connection-limit")
+		}
+		if n < -1 || n > math.MaxInt32 {
+			return fmt.Errorf("%s must be in range %d..%d", "-c/--connection-limit", -1, math.MaxInt32)
+		}
+		connLimit = int(n)
+	}
+
+	if newuser == "" {
+		if opts.interactive {
+			newuser = feutils.SimplePrompt("Enter name of role to add: ", true)
+		} else {
+			name, err := feutils.DefaultUserName()
+			if err != nil {
+				return err
+			}
+			newuser = name
+		}
+	}
+
+	var newpassword string
+	if opts.pwprompt {
+		newpassword = feutils.SimplePrompt("Enter password for new role: ", false)
+		if feutils.SimplePrompt("Enter it again: ", false) != newpassword {
+			fmt.Fprintf(os.Stderr, "Passwords didn't match.\n")
+			os.Exit(1)
+		}
+	}
+
+	ask := func(v *trivalue, question string) {
+		if *v != triDefault {
+			return
+		}
+		*v = triNo
+		if opts.interactive && feutils.YesNoPrompt(question) {
+			*v = triYes
+		}
+	}
+	ask(&opts.superuser, "Shall the new role be a superuser?")
+	if opts.superuser == triYes {
+		// スーパーユーザーは何でもできる
+		opts.createdb = triYes
+		opts.createrole = triYes
+	}
+	ask(&opts.createdb, "Shall the new role be allowed to create databases?")
+	ask(&opts.createrole, "Shall the new role be allowed to create more new roles?")
+	if opts.inherit == triDefault {
+		opts.inherit = triYes
+	}
+	if opts.login == triDefault {
+		opts.login = triYes
+	}
+
+	conn, err := feutils.ConnectMaintenanceDatabase(opts.cparams, "")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var b strings.Builder
+	b.WriteString("CREATE ROLE " + libpq.EscapeIdentifier(newuser))
+	if newpassword != "" {
+		encrypted, err := conn.EncryptPasswordConn(newpassword, newuser, "")
+		if err != nil {
+			return fmt.Errorf("password encryption failed: %s", err.Error())
+		}
+		b.WriteString(" PASSWORD " + libpq.EscapeLiteral(encrypted))
+	}
+	for _, attr := range []struct {
+		keyword string
+		value   trivalue
+	}{
+		{"SUPERUSER", opts.superuser},
+		{"CREATEDB", opts.createdb},
+		{"CREATEROLE", opts.createrole},
+		{"INHERIT", opts.inherit},
+		{"LOGIN", opts.login},
+		{"REPLICATION", opts.replication},
+		{"BYPASSRLS", opts.bypassrls},
+	} {
+		switch attr.value {
+		case triYes:
+			b.WriteString(" " + attr.keyword)
+		case triNo:
+			b.WriteString(" NO" + attr.keyword)
+		}
+	}
+	if connLimit >= -1 {
+		fmt.Fprintf(&b, " CONNECTION LIMIT %d", connLimit)
+	}
+	if opts.pwexpiry != "" {
+		b.WriteString(" VALID UNTIL " + libpq.EscapeLiteral(opts.pwexpiry))
+	}
+	for _, list := range []struct {
+		keyword string
+		roles   []string
+	}{
+		{"IN ROLE", opts.roles},
+		{"ROLE", opts.members},
+		{"ADMIN", opts.admins},
+	} {
+		if len(list.roles) == 0 {
+			continue
+		}
+		quoted := make([]string, len(list.roles))
+		for i, r := range list.roles {
+			quoted[i] = libpq.EscapeIdentifier(r)
+		}
+		b.WriteString(" " + list.keyword + " " + strings.Join(quoted, ", "))
+	}
+	b.WriteString(";")
+
+	if err := feutils.ExecuteCommand(conn, b.String(), opts.echo); err != nil {
+		return fmt.Errorf("creation of new role failed: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/feutils"
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+	"github.com/spf13/cobra"
+)
+
+// ----------------------------------------------------------------
+// データベースの削除 (bin/scripts/dropdb.c 相当)
+// ----------------------------------------------------------------
+// 管理用のデータベースに接続して DROP DATABASE を送る。-i を指定すると、送る前に本当に消すかを
+// 尋ねる。
+
+// dropdbOptions はコマンドラインで指定した設定
+type dropdbOptions struct {
+	cparams       feutils.ConnParams
+	maintenanceDB string
+	echo          bool
+	interactive   bool
+	ifExists      bool
+	force         bool
+}
+
+func main() {
+	progname := filepath.Base(os.Args[0])
+
+	var opts dropdbOptions
+	var rootCmd = &cobra.Command{
+		Use:   "dropdb [OPTION]... DBNAME",
+		Short: "dropdb removes a PostgreSQL database.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case len(args) == 0:
+				return errors.New("missing required argument database name")
+			case len(args) > 1:
+				return fmt.Errorf("too many command-line arguments (first is \"%s\")", args[1])
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := dropdb(&opts, args[0]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+				os.Exit(1)
+			}
+			return nil
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	// -h は接続先のホストに使うため、ヘルプは -? にする
+	rootCmd.Flags().BoolP("help", "?", false, "show this help, then exit")
+	rootCmd.Flags().BoolVarP(&opts.echo, "echo", "e", false, "show the commands being sent to the server")
+	rootCmd.Flags().BoolVarP(&opts.force, "force", "f", false, "try to terminate other connections before dropping")
+	rootCmd.Flags().BoolVarP(&opts.interactive, "interactive", "i", false, "prompt before deleting anything")
+	rootCmd.Flags().BoolVar(&opts.ifExists, "if-exists", false, "don't report error if database doesn't exist")
+	rootCmd.Flags().StringVarP(&opts.cparams.Host, "host", "h", "", "database server host or socket directory")
+	rootCmd.Flags().StringVarP(&opts.cparams.Port, "port", "p", "", "database server port")
+	rootCmd.Flags().StringVarP(&opts.cparams.Username, "username", "U", "", "user name to connect as")
+	rootCmd.Flags().StringVar(&opts.maintenanceDB, "maintenance-db", "", "alternate maintenance database")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+		fmt.Fprintf(os.Stderr, "Try \"%s --help\" for more information.\n", progname)
+		os.Exit(1)
+	}
+}
+
+// dropdb は dbname を消す。-i で消さないと答えたなら何もしない
+func dropdb(opts *dropdbOptions, dbname string) error {
+	if opts.interactive {
+		fmt.Printf("Database \"%s\" will be permanently removed.\n", dbname)
+		if !feutils.YesNoPrompt("Are you sure?") {
+			return nil
+		}
+	}
+
+	// 消すデータベースに接続したままでは消せない
+	maintenanceDB := opts.maintenanceDB
+	if maintenanceDB == "" && dbname == "postgres" {
+		maintenanceDB = "template1"
+	}
+	conn, err := feutils.ConnectMaintenanceDatabase(opts.cparams, maintenanceDB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	sql := "DROP DATABASE "
+	if opts.ifExists {
+		sql += "IF EXISTS "
+	}
+	sql += libpq.EscapeIdentifier(dbname)
+	if opts.force {
+		sql += " WITH (FORCE)"
+	}
+	if err := feutils.ExecuteCommand(conn, sql+";", opts.echo); err != nil {
+		return fmt.Errorf("database removal failed: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/feutils"
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+	"github.com/spf13/cobra"
+)
+
+// ----------------------------------------------------------------
+// ロールの削除 (bin/scripts/dropuser.c 相当)
+// ----------------------------------------------------------------
+// 管理用のデータベースに接続して DROP ROLE を送る。ロールの名前を省略した場合は、-i を指定して
+// いれば尋ね、いなければエラーにする。
+
+// dropuserOptions はコマンドラインで指定した設定
+type dropuserOptions struct {
+	cparams     feutils.ConnParams
+	echo        bool
+	interactive bool
+	ifExists    bool
+}
+
+func main() {
+	progname := filepath.Base(os.Args[0])
+
+	var opts dropuserOptions
+	var rootCmd = &cobra.Command{
+		Use:   "dropuser [OPTION]... [ROLENAME]",
+		Short: "dropuser removes a PostgreSQL role.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("too many command-line arguments (first is \"%s\")", args[1])
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var rolename string
+			if len(args) > 0 {
+				rolename = args[0]
+			} else if opts.interactive {
+				rolename = feutils.SimplePrompt("Enter name of role to drop: ", true)
+			}
+			if rolename == "" {
+				return errors.New("missing required argument role name")
+			}
+			if err := dropuser(&opts, rolename); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+				os.Exit(1)
+			}
+			return nil
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	// -h は接続先のホストに使うため、ヘルプは -? にする
+	rootCmd.Flags().BoolP("help", "?", false, "show this help, then exit")
+	rootCmd.Flags().BoolVarP(&opts.echo, "echo", "e", false, "show the commands being sent to the server")
+	rootCmd.Flags().BoolVarP(&opts.interactive, "interactive", "i", false, "prompt before deleting anything, and prompt for role name if not specified")
+	rootCmd.Flags().BoolVar(&opts.ifExists, "if-exists", false, "don't report error if user doesn't exist")
+	rootCmd.Flags().StringVarP(&opts.cparams.Host, "host", "h", "", "database server host or socket directory")
+	rootCmd.Flags().StringVarP(&opts.cparams.Port, "port", "p", "", "database server port")
+	rootCmd.Flags().StringVarP(&opts.cparams.Username, "username", "U", "", "user name to connect as (not the one to drop)")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+		fmt.Fprintf(os.Stderr, "Try \"%s --help\" for more information.\n", progname)
+		os.Exit(1)
+	}
+}
+
+// dropuser は rolename を消す。-i で消さないと答えたなら何もしない
+func dropuser(opts *dropuserOptions, rolename string) error {
+	if opts.interactive {
+		fmt.Printf("Role \"%s\" will be permanently removed.\n", rolename)
+		if !feutils.YesNoPrompt("Are you sure?") {
+			return nil
+		}
+	}
+
+	conn, err := feutils.ConnectMaintenanceDatabase(opts.cparams, "")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	sql := "DROP ROLE "
+	if opts.ifExists {
+		sql += "IF EXISTS "
+	}
+	sql += libpq.EscapeIdentifier(rolename) + ";"
+	if err := feutils.ExecuteCommand(conn, sql, opts.echo); err != nil {
+		return fmt.Errorf("removal of role \"%s\" failed: %s", rolename, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,649 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/crypto"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+)
+
+// pgDirMode と pgFileMode は作るディレクトリとファイルのパーミッション
+// (pg_dir_create_mode, pg_file_create_mode 相当)
+const (
+	pgDirMode  fs.FileMode = 0700
+	pgFileMode fs.FileMode = 0600
+)
+
+// 認証方式として指定できる名前 (auth_methods_host, auth_methods_local 相当)
+var (
+	authMethodsHost  = []string{"trust", "reject", "scram-sha-256", "md5", "password", "ident", "radius", "pam", "ldap", "cert"}
+	authMethodsLocal = []string{"trust", "reject", "scram-sha-256", "md5", "password", "peer", "radius", "pam", "ldap"}
+)
+
+// initdbError はヒントを付けられるエラー
+type initdbError struct {
+	msg  string
+	hint string
+}
+
+func (e *initdbError) Error() string { return e.msg }
+
+func errorWithHint(hint, format string, args ...any) error {
+	return &initdbError{msg: fmt.Sprintf(format, args...), hint: hint}
+}
+
+// reportError はエラーを書く (pg_log_error, pg_log_error_hint 相当)
+func reportError(progname string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+	var ie *initdbError
+	if errors.As(err, &ie) && ie.hint != "" {
+		fmt.Fprintf(os.Stderr, "%s: hint: %s\n", progname, ie.hint)
+	}
+}
+
+// initdb は実行中の状態
+type initdb struct {
+	progname string
+	opts     *initdbOptions
+	// effectiveUser はデータディレクトリを所有する OS の利用者で、username はブートストラップ
+	// スーパーユーザーの名前 (effective_user, username 相当)
+	effectiveUser string
+	username      string
+
+	// madeNewPgdata と foundExistingPgdata は失敗したときに片付ける範囲
+	// (made_new_pgdata, found_existing_pgdata 相当)
+	madeNewPgdata       bool
+	foundExistingPgdata bool
+}
+
+// run はクラスタを作る (main 相当)。失敗した場合、呼び出し側が cleanup で作ったものを削除する。
+func (d *initdb) run() error {
+	opts := d.opts
+	for _, opt := range opts.extraOptions {
+		if !strings.Contains(opt, "=") {
+			return fmt.Errorf("-c %s requires a value", opt)
+		}
+	}
+	if err := d.checkAuthMethods(); err != nil {
+		return err
+	}
+	if err := d.setupPgdata(); err != nil {
+		return err
+	}
+	u, err := user.Current()
+	if err != nil {
+		return fmt.Errorf("could not look up effective user ID %d: %w", os.Geteuid(), err)
+	}
+	d.effectiveUser = u.Username
+	d.username = opts.username
+	if d.username == "" {
+		d.username = d.effectiveUser
+	}
+	if strings.HasPrefix(d.username, "pg_") {
+		return fmt.Errorf("superuser name \"%s\" is disallowed; role names cannot begin with \"pg_\"", d.username)
+	}
+
+	fmt.Printf("The files belonging to this database system will be owned by user \"%s\".\n", d.effectiveUser)
+	fmt.Printf("This user must also own the server process.\n\n")
+	if opts.dataChecksums {
+		fmt.Printf("Data page checksums are enabled.\n")
+	} else {
+		fmt.Printf("Data page checksums are disabled.\n")
+	}
+	if opts.clusterKeyFile != "" {
+		fmt.Printf("Data file encryption is enabled.\n\n")
+	} else {
+		fmt.Printf("Data file encryption is disabled.\n\n")
+	}
+
+	if err := d.createDataDirectory(); err != nil {
+		return err
+	}
+	if err := d.createSubdirs(); err != nil {
+		return err
+	}
+	if err := writeVersionFile(opts.pgdata); err != nil {
+		return err
+	}
+	if err := d.setupConfig(); err != nil {
+		return err
+	}
+	if err := d.setupFileEncryption(); err != nil {
+		return err
+	}
+	if err := d.bootstrapTemplate1(); err != nil {
+		return err
+	}
+	if err := d.postBootstrap(); err != nil {
+		return err
+	}
+
+	if opts.doSync {
+		fmt.Printf("syncing data to disk ... ")
+		if err := syncPgdata(opts.pgdata); err != nil {
+			return err
+		}
+		fmt.Printf("ok\n")
+	} else {
+		fmt.Printf("\nSync to disk skipped.\nThe data directory might become corrupt if the operating system crashes.\n")
+	}
+
+	if opts.authLocal == "trust" || opts.authHost == "trust" {
+		fmt.Fprintf(os.Stderr, "%s: warning: enabling \"trust\" authentication for local connections\n", d.progname)
+		fmt.Fprintf(os.Stderr, "%s: hint: You can change this by editing pg_hba.conf or using the option -A, or --auth-local and --auth-host, the next time you run initdb.\n", d.progname)
+	}
+	if !opts.noInstruct {
+		fmt.Printf("\nSuccess. You can now start the database server using:\n\n")
+		fmt.Printf("    postgres -D %s\n\n", shellQuote(opts.pgdata))
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// 引数の確認
+// ----------------------------------------------------------------
+
+// checkAuthMethods は認証方式を確かめ、指定がなければ trust にする (check_authmethod_valid,
+// check_need_password 相当)
+func (d *initdb) checkAuthMethods() error {
+	opts := d.opts
+	if opts.authMethod != "" {
+		if opts.authLocal == "" {
+			opts.authLocal = opts.authMethod
+		}
+		if opts.authHost == "" {
+			opts.authHost = opts.authMethod
+		}
+	}
+	if opts.authLocal == "" {
+		opts.authLocal = "trust"
+	}
+	if opts.authHost == "" {
+		opts.authHost = "trust"
+	}
+	for _, c := range []struct {
+		method  string
+		valid   []string
+		conntyp string
+	}{
+		{opts.authLocal, authMethodsLocal, "local"},
+		{opts.authHost, authMethodsHost, "host"},
+	} {
+		if !slices.Contains(c.valid, c.method) {
+			return fmt.Errorf("invalid authentication method \"%s\" for \"%s\" connections", c.method, c.conntyp)
+		}
+	}
+	// スーパーユーザーのパスワードをまだ設定できないため、パスワードを使う認証方式では接続できなくなる
+	for _, m := range []string{opts.authLocal, opts.authHost} {
+		if m == "md5" || m == "password" || m == "scram-sha-256" {
+			return fmt.Errorf("must specify a password for the superuser to enable password authentication")
+		}
+	}
+	return nil
+}
+
+// setupPgdata はデータディレクトリを決める (setup_pgdata 相当)。指定がなければ PGDATA を使う。
+func (d *initdb) setupPgdata() error {
+	if d.opts.pgdata == "" {
+		d.opts.pgdata = os.Getenv("PGDATA")
+	}
+	if d.opts.pgdata == "" {
+		return errorWithHint("You must identify the directory where the data for this database system will reside.  Do this with either the invocation option -D or the environment variable PGDATA.",
+			"no data directory specified")
+	}
+	abs, err := filepath.Abs(d.opts.pgdata)
+	if err != nil {
+		return err
+	}
+	d.opts.pgdata = abs
+	// サーバーはデータディレクトリで起動するとは限らないため、鍵ファイルも絶対パスにする
+	if d.opts.clusterKeyFile != "" {
+		if d.opts.clusterKeyFile, err = filepath.Abs(d.opts.clusterKeyFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// ディレクトリとファイルの作成
+// ----------------------------------------------------------------
+
+// createDataDirectory はデータディレクトリを作る (create_data_directory 相当)。空のディレクトリが
+// 既にあればパーミッションを直して使い、空でなければエラーにする。
+func (d *initdb) createDataDirectory() error {
+	pgdata := d.opts.pgdata
+	entries, err := os.ReadDir(pgdata)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		fmt.Printf("creating directory %s ... ", pgdata)
+		if err := os.MkdirAll(pgdata, pgDirMode); err != nil {
+			return fmt.Errorf("could not create directory \"%s\": %s", pgdata, platform.OSErrorMessage(err))
+		}
+		d.madeNewPgdata = true
+		// MkdirAll は umask の影響を受けるため、パーミッションを設定し直す
+		if err := os.Chmod(pgdata, pgDirMode); err != nil {
+			return fmt.Errorf("could not change permissions of directory \"%s\": %s", pgdata, platform.OSErrorMessage(err))
+		}
+		fmt.Printf("ok\n")
+	case err != nil:
+		return fmt.Errorf("could not access directory \"%s\": %s", pgdata, platform.OSErrorMessage(err))
+	case len(entries) == 0:
+		fmt.Printf("fixing permissions on existing directory %s ... ", pgdata)
+		if err := os.Chmod(pgdata, pgDirMode); err != nil {
+			return fmt.Errorf("could not change permissions of directory \"%s\": %s", pgdata, platform.OSErrorMessage(err))
+		}
+		d.foundExistingPgdata = true
+		fmt.Printf("ok\n")
+	case len(entries) == 1 && entries[0].Name() == "lost+found":
+		return errorWithHint("Using a mount point directly as the data directory is not recommended.\nCreate a subdirectory under the mount point.",
+			"directory \"%s\" exists but is not empty\nIt contains a lost+found directory, perhaps due to it being a mount point.", pgdata)
+	default:
+		return errorWithHint(fmt.Sprintf("If you want to create a new database system, either remove or empty the directory \"%s\" or run %s with an argument other than \"%s\".", pgdata, d.progname, pgdata),
+			"directory \"%s\" exists but is not empty", pgdata)
+	}
+	return nil
+}
+
+// createSubdirs はデータディレクトリの中のディレクトリを作る
+func (d *initdb) createSubdirs() error {
+	fmt.Printf("creating subdirectories ... ")
+	for _, dir := range miscadmin.DataDirSubdirs {
+		path := filepath.Join(d.opts.pgdata, dir)
+		if err := os.MkdirAll(path, pgDirMode); err != nil {
+			return fmt.Errorf("could not create directory \"%s\": %s", path, platform.OSErrorMessage(err))
+		}
+	}
+	fmt.Printf("ok\n")
+	return nil
+}
+
+// writeVersionFile はディレクトリに PG_VERSION を書く (write_version_file 相当)
+func writeVersionFile(dir string) error {
+	return writeFile(filepath.Join(dir, "PG_VERSION"), []byte(pgconfig.PgMajorVersion+"\n"))
+}
+
+// writeFile はファイルを作って data を書く。既にあればエラーにする (writefile 相当)
+func writeFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, pgFileMode)
+	if err != nil {
+		return fmt.Errorf("could not open file \"%s\" for writing: %s", path, platform.OSErrorMessage(err))
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write file \"%s\": %s", path, platform.OSErrorMessage(err))
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not close file \"%s\": %s", path, platform.OSErrorMessage(err))
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// 設定ファイル (setup_config 相当)
+// ----------------------------------------------------------------
+
+// setupConfig は postgresql.conf、postgresql.auto.conf、pg_hba.conf、pg_ident.conf を書く
+func (d *initdb) setupConfig() error {
+	fmt.Printf("selecting default \"max_connections\" ... %d\n", guc.MaxConnections.BootVal)
+	fmt.Printf("creating configuration files ... ")
+
+	var sample bytes.Buffer
+	if err := guc.WriteSampleConfig(&sample); err != nil {
+		return err
+	}
+	lines := strings.SplitAfter(sample.String(), "\n")
+	lines = replaceGUCValue(lines, "max_connections", strconv.Itoa(guc.MaxConnections.BootVal))
+	if d.opts.clusterKeyFile != "" {
+		lines = replaceGUCValue(lines, "cluster_key_file", d.opts.clusterKeyFile)
+	}
+	for _, opt := range d.opts.extraOptions {
+		name, value, _ := strings.Cut(opt, "=")
+		lines = replaceGUCValue(lines, strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if err := writeFile(filepath.Join(d.opts.pgdata, "postgresql.conf"), []byte(strings.Join(lines, ""))); err != nil {
+		return err
+	}
+
+	if err := writeFile(filepath.Join(d.opts.pgdata, "postgresql.auto.conf"),
+		[]byte("# Do not edit this file manually!\n# It will be overwritten by the ALTER SYSTEM command.\n")); err != nil {
+		return err
+	}
+
+	hba := strings.NewReplacer("@authmethodlocal@", d.opts.authLocal, "@authmethodhost@", d.opts.authHost).Replace(hbaSample)
+	if err := writeFile(filepath.Join(d.opts.pgdata, "pg_hba.conf"), []byte(hba)); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(d.opts.pgdata, "pg_ident.conf"), []byte(identSample)); err != nil {
+		return err
+	}
+	fmt.Printf("ok\n")
+	return nil
+}
+
+// setupFileEncryption は --cluster-key-file を指定した場合に、データ暗号化鍵を作って鍵暗号化鍵で
+// 暗号化し、データディレクトリに保存する (bootstrap_template1 の前の BootStrapKmgr 相当)
+func (d *initdb) setupFileEncryption() error {
+	if d.opts.clusterKeyFile == "" {
+		return nil
+	}
+	fmt.Printf("setting up data encryption keys ... ")
+	if err := crypto.BootstrapFileEncryptionKeys(d.opts.pgdata, d.opts.clusterKeyFile); err != nil {
+		return err
+	}
+	fmt.Printf("ok\n")
+	return nil
+}
+
+// simpleValue は引用符で囲まずに設定ファイルに書ける値 (数値、単位付きの数値、識別子)
+var simpleValue = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+
+// replaceGUCValue は設定ファイルの name の行を "name = value" に置き換える (replace_guc_value 相当)。
+// コメントアウトした行も置き換え、行末のコメントは残す。該当する行がなければ最後に加える。
+func replaceGUCValue(lines []string, name, value string) []string {
+	if !simpleValue.MatchString(value) {
+		value = guc.QuoteConfigValue(value)
+	}
+	newline := name + " = " + value
+	for i, line := range lines {
+		s := strings.TrimLeft(line, " \t#")
+		if len(s) <= len(name) || !strings.EqualFold(s[:len(name)], name) {
+			continue
+		}
+		rest := strings.TrimLeft(s[len(name):], " \t")
+		if !strings.HasPrefix(rest, "=") {
+			continue
+		}
+		if j := strings.Index(rest, "\t#"); j >= 0 {
+			for j > 0 && rest[j-1] == '\t' {
+				j--
+			}
+			newline += strings.TrimSuffix(rest[j:], "\n")
+		}
+		lines[i] = newline + "\n"
+		return lines
+	}
+	return append(lines, newline+"\n")
+}
+
+// hbaSample は pg_hba.conf の雛形 (pg_hba.conf.sample 相当)
+const hbaSample = `# PostgreSQL Client Authentication Configuration File
+# ===================================================
+#
+# Refer to the "Client Authentication" section in the PostgreSQL
+# documentation for a complete description of this file.  A short
+# synopsis follows.
+#
+# This file controls: which hosts are allowed to connect, how clients
+# are authenticated, which PostgreSQL user names they can use, which
+# databases they can access.  Records take one of these forms:
+#
+# local         DATABASE  USER  METHOD  [OPTIONS]
+# host          DATABASE  USER  ADDRESS  METHOD  [OPTIONS]
+# hostssl       DATABASE  USER  ADDRESS  METHOD  [OPTIONS]
+# hostnossl     DATABASE  USER  ADDRESS  METHOD  [OPTIONS]
+#
+# (The uppercase items must be replaced by actual values.)
+#
+# This file is read on server startup and when the server receives a
+# SIGHUP signal.  If you edit the file on a running system, you have to
+# SIGHUP the server for the changes to take effect, or execute
+# "SELECT pg_reload_conf()".
+
+# TYPE  DATABASE        USER            ADDRESS                 METHOD
+
+# "local" is for Unix domain socket connections only
+local   all             all                                     @authmethodlocal@
+# IPv4 local connections:
+host    all             all             127.0.0.1/32            @authmethodhost@
+# IPv6 local connections:
+host    all             all             ::1/128                 @authmethodhost@
+# Allow replication connections from localhost, by a user with the
+# replication privilege.
+local   replication     all                                     @authmethodlocal@
+host    replication     all             127.0.0.1/32            @authmethodhost@
+host    replication     all             ::1/128                 @authmethodhost@
+`
+
+// identSample は pg_ident.conf の雛形 (pg_ident.conf.sample 相当)
+const identSample = `# PostgreSQL User Name Maps
+# =========================
+#
+# Refer to the PostgreSQL documentation, chapter "Client
+# Authentication" for a complete description.  A short synopsis
+# follows.
+#
+# This file controls PostgreSQL user name mapping.  It maps external
+# user names to their corresponding PostgreSQL user names.  Records
+# are of the form:
+#
+# MAPNAME  SYSTEM-USERNAME  DATABASE-USERNAME
+#
+# (The uppercase quantities must be replaced by actual values.)
+#
+# This file is read on server startup and when the server receives a
+# SIGHUP signal.  If you edit the file on a running system, you have to
+# SIGHUP the server for the changes to take effect, or execute
+# "SELECT pg_reload_conf()".
+
+# MAPNAME       SYSTEM-USERNAME         DATABASE-USERNAME
+`
+
+// ----------------------------------------------------------------
+// ブートストラップ
+// ----------------------------------------------------------------
+
+// bootstrapTemplate1 は制御ファイルを書き、template1 を作る (bootstrap_template1 と
+// BootStrapXLOG 相当)
+func (d *initdb) bootstrapTemplate1() error {
+	fmt.Printf("running bootstrap script ... ")
+	bki := catalog.PostgresBKI
+	headerLine := "# PostgreSQL " + pgconfig.PgMajorVersion + "\n"
+	if !strings.HasPrefix(bki, headerLine) {
+		return errorWithHint("Check your installation.", "input file does not belong to PostgreSQL %s", pgconfig.PgVersion)
+	}
+	// ブートストラップスーパーユーザーの名前を埋め込む (replace_token 相当)
+	lines := strings.SplitAfter(bki, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, bootstrapSuperuserToken) {
+			continue
+		}
+		fields := strings.Split(line, " ")
+		for j, f := range fields {
+			if f == bootstrapSuperuserToken {
+				fields[j] = escapeQuotesBKI(d.username)
+			}
+		}
+		lines[i] = strings.Join(fields, " ")
+	}
+
+	backend, err := findBackendExec(d.progname)
+	if err != nil {
+		return err
+	}
+	args := []string{"boot", "-D", d.opts.pgdata}
+	if d.opts.dataChecksums {
+		args = append(args, "-k")
+	}
+	cmd := exec.Command(backend, args...)
+	cmd.Stdin = strings.NewReader(strings.Join(lines, ""))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && ee.Exited() {
+			return fmt.Errorf("child process exited with exit code %d", ee.ExitCode())
+		}
+		return fmt.Errorf("could not execute command \"%s\": %v", backend, err)
+	}
+
+	if err := writeVersionFile(databasePath(d.opts.pgdata, catalog.Template1DbOid)); err != nil {
+		return err
+	}
+	fmt.Printf("ok\n")
+	return nil
+}
+
+// bootstrapSuperuserToken は BKI の中でブートストラップスーパーユーザーの名前に置き換える語
+const bootstrapSuperuserToken = "POSTGRES"
+
+// escapeQuotesBKI は値を BKI の単一引用符で囲んだ形にする (escape_quotes_bki 相当)
+func escapeQuotesBKI(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// findBackendExec は initdb と同じディレクトリにある postgres を探す (find_other_exec 相当)
+func findBackendExec(progname string) (string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("could not locate my own executable path: %v", err)
+	}
+	path := filepath.Join(filepath.Dir(self), "postgres")
+	if fi, err := os.Stat(path); err != nil || fi.IsDir() || fi.Mode()&0111 == 0 {
+		return "", errorWithHint("Check your installation.",
+			"program \"postgres\" is needed by %s but was not found in the same directory as \"%s\"", progname, self)
+	}
+	return path, nil
+}
+
+// postBootstrap は template1 を写して template0 と postgres を作る (make_template0,
+// make_postgres 相当)
+func (d *initdb) postBootstrap() error {
+	fmt.Printf("performing post-bootstrap initialization ... ")
+	src := databasePath(d.opts.pgdata, catalog.Template1DbOid)
+	for _, oid := range []catalog.Oid{catalog.Template0DbOid, catalog.PostgresDbOid} {
+		if err := copyDir(src, databasePath(d.opts.pgdata, oid)); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("ok\n")
+	return nil
+}
+
+// databasePath はデータベースのディレクトリを返す (GetDatabasePath 相当)
+func databasePath(pgdata string, dbOid catalog.Oid) string {
+	return filepath.Join(pgdata, "base", strconv.FormatUint(uint64(dbOid), 10))
+}
+
+// copyDir はディレクトリの中身を写す (copydir 相当)。サブディレクトリも写す。
+func copyDir(src, dst string) error {
+	if err := os.Mkdir(dst, pgDirMode); err != nil {
+		return fmt.Errorf("could not create directory \"%s\": %s", dst, platform.OSErrorMessage(err))
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("could not open directory \"%s\": %s", src, platform.OSErrorMessage(err))
+	}
+	for _, e := range entries {
+		from, to := filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())
+		if e.IsDir() {
+			if err := copyDir(from, to); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := copyFile(from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile はファイルを写す (copy_file 相当)
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return fmt.Errorf("could not open file \"%s\": %s", from, platform.OSErrorMessage(err))
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, pgFileMode)
+	if err != nil {
+		return fmt.Errorf("could not create file \"%s\": %s", to, platform.OSErrorMessage(err))
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("could not write to file \"%s\": %s", to, platform.OSErrorMessage(err))
+	}
+	return out.Close()
+}
+
+// syncPgdata はデータディレクトリの全てのファイルとディレクトリをディスクに同期する
+// (sync_pgdata 相当)
+func syncPgdata(pgdata string) error {
+	return filepath.WalkDir(pgdata, func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("could not open file \"%s\": %s", path, platform.OSErrorMessage(err))
+		}
+		defer f.Close()
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("could not fsync file \"%s\": %s", path, platform.OSErrorMessage(err))
+		}
+		return nil
+	})
+}
+
+// ----------------------------------------------------------------
+// 後始末
+// ----------------------------------------------------------------
+
+// cleanup は失敗したときに作ったものを削除する (cleanup_directories_atexit 相当)
+func (d *initdb) cleanup() {
+	pgdata := d.opts.pgdata
+	if !d.madeNewPgdata && !d.foundExistingPgdata {
+		return
+	}
+	if d.opts.noClean {
+		fmt.Fprintf(os.Stderr, "%s: data directory \"%s\" not removed at user's request\n", d.progname, pgdata)
+		return
+	}
+	if d.madeNewPgdata {
+		fmt.Fprintf(os.Stderr, "%s: removing data directory \"%s\"\n", d.progname, pgdata)
+		if err := os.RemoveAll(pgdata); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: error: failed to remove data directory\n", d.progname)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: removing contents of data directory \"%s\"\n", d.progname, pgdata)
+	entries, _ := os.ReadDir(pgdata)
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(pgdata, e.Name())); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: error: failed to remove contents of data directory\n", d.progname)
+			return
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// 補助
+// ----------------------------------------------------------------
+
+// shellSafe はシェルで引用符を付けずに書ける文字列
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:=+-]+$`)
+
+// shellQuote は案内に表示するパスを、必要ならシェルの単一引用符で囲む (appendShellString 相当)
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// ----------------------------------------------------------------
+// データベースクラスタの作成 (bin/initdb/initdb.c 相当)
+// ----------------------------------------------------------------
+// データディレクトリを作り、サーバーが起動できるようにする。
+//
+//  1. ディレクトリと、その中の base/、global/、pg_wal/、pg_xact/ などを作る
+//  2. PG_VERSION と、設定ファイル (postgresql.conf、pg_hba.conf、pg_ident.conf) を書く
+//  3. --cluster-key-file を指定した場合は、ファイル暗号化のデータ暗号化鍵を作る
+//  4. ブートストラップとして制御ファイルとシステムカタログを書き、template1 を作る
+//  5. template1 を写して template0 と postgres を作る
+//
+// ブートストラップでは、initdb と同じディレクトリにある postgres を "postgres boot" として起動し、
+// 組み込みの BKI を渡して制御ファイルとシステムカタログの最初の内容を書かせる。
+// ブートストラップスーパーユーザーの名前は -U で指定し、省略すると initdb を実行した利用者の
+// 名前にする。パスワードはまだ設定できないため、-W と --pwfile は扱わない。
+
+// initdbOptions はコマンドラインで指定した設定
+type initdbOptions struct {
+	pgdata        string
+	username      string
+	authMethod    string
+	authLocal     string
+	authHost      string
+	dataChecksums bool
+	// clusterKeyFile はファイル暗号化の鍵暗号化鍵を置いたファイル。空ならファイル暗号化を使わない
+	clusterKeyFile string
+	noClean        bool
+	doSync         bool
+	noInstruct     bool
+	// extraOptions は -c で指定した "名前=値" の並び
+	extraOptions []string
+}
+
+func main() {
+	progname := filepath.Base(os.Args[0])
+
+	var opts initdbOptions
+	var noSync bool
+	var rootCmd = &cobra.Command{
+		Use:   "initdb [OPTION]... [DATADIR]",
+		Short: "initdb initializes a PostgreSQL database cluster.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && opts.pgdata == "" {
+				opts.pgdata = args[0]
+			}
+			opts.doSync = !noSync
+			if os.Geteuid() == 0 {
+				fmt.Fprintf(os.Stderr, "%s: error: cannot be run as root\n", progname)
+				fmt.Fprintf(os.Stderr, "%s: hint: Please log in (using, e.g., \"su\") as the (unprivileged) user that will own the server process.\n", progname)
+				os.Exit(1)
+			}
+			d := &initdb{progname: progname, opts: &opts}
+			if err := d.run(); err != nil {
+				reportError(progname, err)
+				d.cleanup()
+				os.Exit(1)
+			}
+			return nil
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	rootCmd.Flags().BoolP("help", "?", false, "show this help, then exit")
+	rootCmd.Flags().StringVarP(&opts.pgdata, "pgdata", "D", "", "location for this database cluster")
+	rootCmd.Flags().StringVarP(&opts.username, "username", "U", "", "database superuser name")
+	rootCmd.Flags().StringVarP(&opts.authMethod, "auth", "A", "", "default authentication method for local connections")
+	rootCmd.Flags().StringVar(&opts.authHost, "auth-host", "", "default authentication method for local TCP/IP connections")
+	rootCmd.Flags().StringVar(&opts.authLocal, "auth-local", "", "default authentication method for local-socket connections")
+	rootCmd.Flags().BoolVarP(&opts.dataChecksums, "data-checksums", "k", false, "use data page checksums")
+	rootCmd.Flags().StringVarP(&opts.clusterKeyFile, "cluster-key-file", "K", "", "enable data file encryption using the cluster key in FILE")
+	rootCmd.Flags().StringArrayVarP(&opts.extraOptions, "set", "c", nil, "override default setting for server parameter")
+	rootCmd.Flags().BoolVarP(&opts.noClean, "no-clean", "n", false, "do not clean up after errors")
+	rootCmd.Flags().BoolVarP(&noSync, "no-sync", "N", false, "do not wait for changes to be written safely to disk")
+	rootCmd.Flags().BoolVar(&opts.noInstruct, "no-instructions", false, "do not print instructions for next steps")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+		fmt.Fprintf(os.Stderr, "Try \"%s --help\" for more information.\n", progname)
+		os.Exit(1)
+	}
+}
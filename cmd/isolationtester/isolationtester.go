@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+	pqcomm "github.com/Tsubasa-2005/go-postgres/internal/libpq"
+)
+
+// ----------------------------------------------------------------
+// 隔離性のテストの実行 (isolation/isolationtester.c 相当)
+// ----------------------------------------------------------------
+// セッションごとに接続を1つ開き、permutation の順にステップを実行して結果を標準出力に書く。
+// 期待される出力との比較は pg_regress 側の仕事で、このプログラムは出力するだけ。
+//
+// C言語版では、ステップがロック待ちになったかを制御用の接続から
+// pg_isolation_test_session_is_blocked() で調べ、待っている間に他のセッションの
+// ステップを進める。ロックマネージャがまだないため、ステップは常に完了するまで待つ。
+
+// tester は実行中のテストの状態
+type tester struct {
+	spec     *TestSpec
+	testname string
+	// conns[0] は全体の setup, teardown 用の制御用の接続、conns[i+1] は i 番目のセッションの接続
+	conns []*libpq.Conn
+}
+
+// connect はセッションごとの接続を開く。テストの出力を安定させるため、
+// NOTICE などはセッション名を付けて標準出力に書く (isolation_notice_processor 相当)。
+func (t *tester) connect(conninfo string) error {
+	names := []string{"control connection"}
+	for _, sess := range t.spec.sessions {
+		names = append(names, sess.name)
+	}
+	for i, name := range names {
+		appname := fmt.Sprintf("isolation/%s/%s", t.testname, name)
+		conn, err := libpq.Connect(conninfo + " application_name='" + escapeConnValue(appname) + "'")
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			conn.SetNoticeReceiver(func(r *libpq.Result) {
+				fmt.Printf("%s: %s\n", name, r.Err().Error())
+			})
+		}
+		t.conns = append(t.conns, conn)
+	}
+	return nil
+}
+
+func escapeConnValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+func (t *tester) close() {
+	for _, c := range t.conns {
+		c.Close()
+	}
+}
+
+// runTesting は全ての permutation を実行する (run_testspec 相当)
+func (t *tester) runTesting() error {
+	for _, sess := range t.spec.sessions {
+		for _, st := range sess.steps {
+			if len(t.spec.permutations) > 0 && !st.used {
+				fmt.Fprintf(os.Stderr, "unused step name: %s\n", st.name)
+			}
+		}
+	}
+
+	if len(t.spec.permutations) > 0 {
+		for _, p := range t.spec.permutations {
+			if err := t.runPermutation(p.steps); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return t.runAllPermutations()
+}
+
+// runAllPermutations は各セッション内の順序を保った全てのステップの並べ方を実行する
+// (run_all_permutations 相当)
+func (t *tester) runAllPermutations() error {
+	next := make([]int, len(t.spec.sessions))
+	var steps []*Step
+	var recurse func() error
+	recurse = func() error {
+		done := true
+		for i, sess := range t.spec.sessions {
+			if next[i] >= len(sess.steps) {
+				continue
+			}
+			done = false
+			steps = append(steps, sess.steps[next[i]])
+			next[i]++
+			if err := recurse(); err != nil {
+				return err
+			}
+			next[i]--
+			steps = steps[:len(steps)-1]
+		}
+		if done {
+			return t.runPermutation(steps)
+		}
+		return nil
+	}
+	return recurse()
+}
+
+// runPermutation はステップの並び1つを、setup から teardown まで実行する (run_permutation 相当)
+func (t *tester) runPermutation(steps []*Step) error {
+	fmt.Printf("\nstarting permutation:")
+	for _, st := range steps {
+		fmt.Printf(" %s", st.name)
+	}
+	fmt.Printf("\n")
+
+	for _, sql := range t.spec.setupSQLs {
+		res, err := t.conns[0].Exec(sql)
+		if err != nil {
+			return err
+		}
+		if res.Status == libpq.FatalError {
+			return fmt.Errorf("setup failed: %s", res.Err().Message())
+		}
+		printResult(res)
+	}
+	for i, sess := range t.spec.sessions {
+		if sess.setupSQL == "" {
+			continue
+		}
+		res, err := t.conns[i+1].Exec(sess.setupSQL)
+		if err != nil {
+			return err
+		}
+		if res.Status == libpq.FatalError {
+			return fmt.Errorf("setup of session %s failed: %s", sess.name, res.Err().Message())
+		}
+		printResult(res)
+	}
+
+	for _, st := range steps {
+		fmt.Printf("step %s: %s\n", st.name, st.sql)
+		res, err := t.conns[st.session+1].Exec(st.sql)
+		if err != nil {
+			return err
+		}
+		printResult(res)
+	}
+
+	// teardown の失敗は報告するだけで、次の permutation に進む
+	for i, sess := range t.spec.sessions {
+		if sess.teardownSQL == "" {
+			continue
+		}
+		res, err := t.conns[i+1].Exec(sess.teardownSQL)
+		if err != nil {
+			return err
+		}
+		if res.Status == libpq.FatalError {
+			fmt.Printf("teardown of session %s failed: %s\n", sess.name, res.Err().Message())
+		}
+		printResult(res)
+	}
+	if t.spec.teardownSQL != "" {
+		res, err := t.conns[0].Exec(t.spec.teardownSQL)
+		if err != nil {
+			return err
+		}
+		if res.Status == libpq.FatalError {
+			fmt.Printf("teardown failed: %s\n", res.Err().Message())
+		}
+		printResult(res)
+	}
+	return nil
+}
+
+// printResult はステップの結果を書く。行を返す文の結果は表にし、エラーは重大度とメッセージを書く
+// (printResultSet, report_error_message 相当)
+func printResult(res *libpq.Result) {
+	switch res.Status {
+	case libpq.TuplesOK:
+		libpq.Print(os.Stdout, res, libpq.PrintOpt{Header: true, Align: true, FieldSep: "|"})
+	case libpq.FatalError:
+		e := res.Err()
+		fmt.Printf("%s:  %s\n", e.Severity(), e.Message())
+		if detail := e.Field(pqcomm.PgDiagMessageDetail); detail != "" {
+			fmt.Printf("DETAIL:  %s\n", detail)
+		}
+	}
+}
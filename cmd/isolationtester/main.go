@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	progname := filepath.Base(os.Args[0])
+
+	var testname string
+	var rootCmd = &cobra.Command{
+		Use:   "isolationtester [CONNINFO] < SPECFILE",
+		Short: "run an isolation test spec read from standard input",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conninfo := "dbname=postgres"
+			if len(args) > 0 {
+				conninfo = args[0]
+			}
+
+			src, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			spec, err := parseSpec(string(src))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Parsed test spec with %d sessions\n", len(spec.sessions))
+
+			t := &tester{spec: spec, testname: testname}
+			defer t.close()
+			if err := t.connect(conninfo); err != nil {
+				return err
+			}
+			return t.runTesting()
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	rootCmd.Flags().StringVar(&testname, "test-name", "", "name of the test, used in application_name")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", progname, strings.TrimSpace(err.Error()))
+		os.Exit(1)
+	}
+}
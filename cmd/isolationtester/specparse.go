@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ----------------------------------------------------------------
+// テスト仕様ファイルの解析 (isolation/specparse.y, specscanner.l 相当)
+// ----------------------------------------------------------------
+// 仕様ファイルの書式は次の通り。"#" から行末まではコメント。
+//
+//	setup { SQL }            (複数書ける)
+//	teardown { SQL }
+//	session 名前
+//	    setup { SQL }
+//	    step 名前 { SQL }    (1つ以上)
+//	    teardown { SQL }
+//	permutation ステップ名 ...
+//
+// permutation を省略した場合は、全てのステップの並べ方 (各セッション内の順序は保つ) を試す。
+// ステップ名の後の "(...)" はロック待ちの順序の指定で、ロックの待ちを検出できるように
+// なるまでは読み飛ばす。
+
+// TestSpec は仕様ファイル1つ分 (TestSpec 相当)
+type TestSpec struct {
+	setupSQLs    []string
+	teardownSQL  string
+	sessions     []*Session
+	permutations []*Permutation
+}
+
+// Session はセッション1つ分 (Session 相当)
+type Session struct {
+	name        string
+	setupSQL    string
+	steps       []*Step
+	teardownSQL string
+}
+
+// Step はステップ1つ分 (Step 相当)
+type Step struct {
+	name    string
+	sql     string
+	session int // sessions の添字
+	used    bool
+}
+
+// Permutation は実行するステップの並び (Permutation 相当)
+type Permutation struct {
+	steps []*Step
+}
+
+// specToken は字句の種類
+type specToken int
+
+const (
+	tokEOF specToken = iota
+	tokIdent
+	tokSQL
+	tokInteger
+	tokLParen
+	tokRParen
+	tokComma
+	tokAsterisk
+)
+
+// specScanner は仕様ファイルの字句解析器
+type specScanner struct {
+	src  string
+	pos  int
+	line int
+
+	tok    specToken
+	text   string
+	quoted bool // 識別子が引用符付きだった
+}
+
+func (s *specScanner) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s at line %d", fmt.Sprintf(format, args...), s.line)
+}
+
+// next は次の字句を読む。
+func (s *specScanner) next() error {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '\n':
+			s.line++
+			s.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			s.pos++
+		case c == '#':
+			for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+				s.pos++
+			}
+		default:
+			return s.scanToken()
+		}
+	}
+	s.tok, s.text = tokEOF, ""
+	return nil
+}
+
+func (s *specScanner) scanToken() error {
+	c := s.src[s.pos]
+	start := s.pos
+	s.quoted = false
+	switch {
+	case c == '{':
+		// SQL は対応する "}" まで。入れ子の "{}" を含めてよい
+		depth := 0
+		for ; s.pos < len(s.src); s.pos++ {
+			switch s.src[s.pos] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			case '\n':
+				s.line++
+			}
+			if depth == 0 {
+				break
+			}
+		}
+		if s.pos >= len(s.src) {
+			return s.errorf("unterminated sql block")
+		}
+		s.tok, s.text = tokSQL, strings.TrimSpace(s.src[start+1:s.pos])
+		s.pos++
+	case c == '"':
+		// 引用符付きの識別子。"" は " 1文字を表す
+		var b strings.Builder
+		for s.pos++; ; s.pos++ {
+			if s.pos >= len(s.src) {
+				return s.errorf("unterminated quoted identifier")
+			}
+			if s.src[s.pos] == '"' {
+				if s.pos+1 < len(s.src) && s.src[s.pos+1] == '"' {
+					b.WriteByte('"')
+					s.pos++
+					continue
+				}
+				break
+			}
+			b.WriteByte(s.src[s.pos])
+		}
+		s.pos++
+		s.tok, s.text, s.quoted = tokIdent, b.String(), true
+	case c >= '0' && c <= '9':
+		for s.pos < len(s.src) && s.src[s.pos] >= '0' && s.src[s.pos] <= '9' {
+			s.pos++
+		}
+		s.tok, s.text = tokInteger, s.src[start:s.pos]
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for s.pos < len(s.src) && (s.src[s.pos] == '_' || unicode.IsLetter(rune(s.src[s.pos])) ||
+			unicode.IsDigit(rune(s.src[s.pos]))) {
+			s.pos++
+		}
+		s.tok, s.text = tokIdent, s.src[start:s.pos]
+	case c == '(':
+		s.pos++
+		s.tok, s.text = tokLParen, "("
+	case c == ')':
+		s.pos++
+		s.tok, s.text = tokRParen, ")"
+	case c == ',':
+		s.pos++
+		s.tok, s.text = tokComma, ","
+	case c == '*':
+		s.pos++
+		s.tok, s.text = tokAsterisk, "*"
+	default:
+		return s.errorf("syntax error at \"%c\"", c)
+	}
+	return nil
+}
+
+// isKeyword は現在の字句がキーワード kw かを返す。キーワードは引用符なしの識別子として現れる。
+func (s *specScanner) isKeyword(kw string) bool {
+	return s.tok == tokIdent && s.text == kw && !s.quoted
+}
+
+// expect は現在の字句が tok であることを確かめて、その文字列を返す。
+func (s *specScanner) expect(tok specToken, what string) (string, error) {
+	if s.tok != tok {
+		if s.tok == tokEOF {
+			return "", s.errorf("syntax error at end of file, expected %s", what)
+		}
+		return "", s.errorf("syntax error at \"%s\", expected %s", s.text, what)
+	}
+	text := s.text
+	return text, s.next()
+}
+
+// parseSpec は仕様ファイルを解析する。
+func parseSpec(src string) (*TestSpec, error) {
+	s := &specScanner{src: src, line: 1}
+	if err := s.next(); err != nil {
+		return nil, err
+	}
+
+	spec := &TestSpec{}
+	// setup は先頭にいくつでも書ける
+	for s.isKeyword("setup") {
+		if err := s.next(); err != nil {
+			return nil, err
+		}
+		sql, err := s.expect(tokSQL, "sql block")
+		if err != nil {
+			return nil, err
+		}
+		spec.setupSQLs = append(spec.setupSQLs, sql)
+	}
+	if s.isKeyword("teardown") {
+		if err := s.next(); err != nil {
+			return nil, err
+		}
+		sql, err := s.expect(tokSQL, "sql block")
+		if err != nil {
+			return nil, err
+		}
+		spec.teardownSQL = sql
+	}
+
+	for s.isKeyword("session") {
+		sess, err := parseSession(s, len(spec.sessions))
+		if err != nil {
+			return nil, err
+		}
+		spec.sessions = append(spec.sessions, sess)
+	}
+	if len(spec.sessions) == 0 {
+		return nil, s.errorf("syntax error, expected session")
+	}
+
+	var names [][]string
+	for s.isKeyword("permutation") {
+		if err := s.next(); err != nil {
+			return nil, err
+		}
+		var perm []string
+		for s.tok == tokIdent && !s.isKeyword("permutation") {
+			perm = append(perm, s.text)
+			if err := s.next(); err != nil {
+				return nil, err
+			}
+			if s.tok == tokLParen {
+				if err := skipBlockers(s); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if len(perm) == 0 {
+			return nil, s.errorf("syntax error, expected step name")
+		}
+		names = append(names, perm)
+	}
+	if s.tok != tokEOF {
+		return nil, s.errorf("syntax error at \"%s\"", s.text)
+	}
+
+	if err := spec.resolve(names); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func parseSession(s *specScanner, index int) (*Session, error) {
+	if err := s.next(); err != nil {
+		return nil, err
+	}
+	name, err := s.expect(tokIdent, "session name")
+	if err != nil {
+		return nil, err
+	}
+	sess := &Session{name: name}
+
+	if s.isKeyword("setup") {
+		if err := s.next(); err != nil {
+			return nil, err
+		}
+		if sess.setupSQL, err = s.expect(tokSQL, "sql block"); err != nil {
+			return nil, err
+		}
+	}
+	for s.isKeyword("step") {
+		if err := s.next(); err != nil {
+			return nil, err
+		}
+		stepName, err := s.expect(tokIdent, "step name")
+		if err != nil {
+			return nil, err
+		}
+		sql, err := s.expect(tokSQL, "sql block")
+		if err != nil {
+			return nil, err
+		}
+		sess.steps = append(sess.steps, &Step{name: stepName, sql: sql, session: index})
+	}
+	if len(sess.steps) == 0 {
+		return nil, s.errorf("syntax error, expected step")
+	}
+	if s.isKeyword("teardown") {
+		if err := s.next(); err != nil {
+			return nil, err
+		}
+		if sess.teardownSQL, err = s.expect(tokSQL, "sql block"); err != nil {
+			return nil, err
+		}
+	}
+	return sess, nil
+}
+
+// skipBlockers はステップ名の後の "(...)" を読み飛ばす。
+func skipBlockers(s *specScanner) error {
+	for s.tok != tokRParen {
+		if s.tok == tokEOF {
+			return s.errorf("syntax error at end of file, expected \")\"")
+		}
+		if err := s.next(); err != nil {
+			return err
+		}
+	}
+	return s.next()
+}
+
+// resolve はステップ名の重複を確かめ、permutation のステップ名を Step に対応付ける。
+func (spec *TestSpec) resolve(perms [][]string) error {
+	steps := make(map[string]*Step)
+	for _, sess := range spec.sessions {
+		for _, st := range sess.steps {
+			if _, dup := steps[st.name]; dup {
+				return fmt.Errorf("duplicate step name: %s", st.name)
+			}
+			steps[st.name] = st
+		}
+	}
+	for _, names := range perms {
+		p := &Permutation{}
+		for _, name := range names {
+			st, ok := steps[name]
+			if !ok {
+				return fmt.Errorf("undefined step \"%s\" specified in permutation", name)
+			}
+			st.used = true
+			p.steps = append(p.steps, st)
+		}
+		spec.permutations = append(spec.permutations, p)
+	}
+	return nil
+}
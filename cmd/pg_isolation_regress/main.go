@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/regress"
+)
+
+// ----------------------------------------------------------------
+// 隔離性の回帰テスト (test/isolation/isolation_main.c 相当)
+// ----------------------------------------------------------------
+// 各テストは inputdir/specs/テスト名.spec を isolationtester で実行し、その出力を
+// outputdir/results/テスト名.out に書く。期待される出力は expecteddir/expected/テスト名.out。
+
+// isolationStartTest は isolationtester でテストの仕様ファイルを実行する (isolation_start_test 相当)
+func isolationStartTest(opts *regress.Options, testname string) (*regress.TestProcess, error) {
+	infile := filepath.Join(opts.InputDir, "specs", testname+".spec")
+	outfile := filepath.Join(opts.OutputDir, "results", testname+".out")
+	expectfile := filepath.Join(opts.ExpectDir, "expected", testname+".out")
+
+	// 接続先は pg_regress が設定した環境変数 (PGHOST, PGPORT など) で決まる
+	cmd := exec.Command(regress.FindProgram(opts, "isolationtester"),
+		"--test-name", testname, fmt.Sprintf("dbname='%s'", opts.DBName))
+	if err := regress.StartWithIO(cmd, infile, outfile); err != nil {
+		return nil, fmt.Errorf("could not start process for test %s: %w", testname, err)
+	}
+	return &regress.TestProcess{
+		Cmd:         cmd,
+		ResultFiles: []string{outfile},
+		ExpectFiles: []string{expectfile},
+	}, nil
+}
+
+func main() {
+	os.Exit(regress.RegressionMain(filepath.Base(os.Args[0]), isolationStartTest))
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/regress"
+)
+
+// ----------------------------------------------------------------
+// SQL の回帰テスト (test/regress/pg_regress_main.c 相当)
+// ----------------------------------------------------------------
+// 各テストは inputdir/sql/テスト名.sql を psql -a で実行し、その出力を
+// outputdir/results/テスト名.out に書く。期待される出力は expecteddir/expected/テスト名.out。
+
+// psqlStartTest は psql でテストの SQL ファイルを実行する (psql_start_test 相当)
+func psqlStartTest(opts *regress.Options, testname string) (*regress.TestProcess, error) {
+	infile := filepath.Join(opts.InputDir, "sql", testname+".sql")
+	outfile := filepath.Join(opts.OutputDir, "results", testname+".out")
+	expectfile := filepath.Join(opts.ExpectDir, "expected", testname+".out")
+
+	cmd := exec.Command(regress.FindProgram(opts, "psql"), "-X", "-a", "-q", "-d", opts.DBName)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PGAPPNAME=pg_regress/%s", testname))
+	if err := regress.StartWithIO(cmd, infile, outfile); err != nil {
+		return nil, fmt.Errorf("could not start process for test %s: %w", testname, err)
+	}
+	return &regress.TestProcess{
+		Cmd:         cmd,
+		ResultFiles: []string{outfile},
+		ExpectFiles: []string{expectfile},
+	}, nil
+}
+
+func main() {
+	os.Exit(regress.RegressionMain(filepath.Base(os.Args[0]), psqlStartTest))
+}
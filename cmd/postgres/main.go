@@ -1,13 +1,17 @@
 package main
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/Tsubasa-2005/go-postgres/internal/backend"
+	"github.com/Tsubasa-2005/go-postgres/internal/bootstrap"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
 	"github.com/Tsubasa-2005/go-postgres/internal/platform"
 	"github.com/Tsubasa-2005/go-postgres/internal/postmaster"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func main() {
@@ -149,50 +153,120 @@ func main() {
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return postmaster.PostmasterMain(args)
+			// 起動した後のエラーでは使い方を表示しない
+			cmd.SilenceUsage = true
+			return postmaster.PostmasterMain()
 		},
 	}
+	// -h は listen_addresses に使うため、ヘルプは --help と -? にする
+	rootCmd.Flags().BoolP("help", "?", false, "show this help, then exit")
+	addGucFlags(rootCmd.Flags(), gucShorthands)
 
 	// DISPATCH_CHECK
+	// データディレクトリと設定を確かめて終わる。問題があれば 0 以外で終了する
 	var checkCmd = &cobra.Command{
 		Use:    "check",
 		Hidden: true,
-		Run: func(cmd *cobra.Command, args []string) {
-			panic("DISPATCH_CHECK: Not implemented yet")
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return bootstrap.CheckerModeMain()
 		},
 	}
+	checkCmd.Flags().BoolP("help", "?", false, "show this help, then exit")
+	addGucFlags(checkCmd.Flags(), gucShorthands)
 	rootCmd.AddCommand(checkCmd)
 
 	// DISPATCH_BOOT
+	// initdb が起動し、標準入力から BKI を読んでシステムカタログを作る
+	var dataChecksums bool
 	var bootCmd = &cobra.Command{
 		Use:    "boot",
 		Hidden: true,
-		Run: func(cmd *cobra.Command, args []string) {
-			panic("DISPATCH_BOOT: Not implemented yet")
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return bootstrap.BootstrapModeMain(dataChecksums, os.Stdin)
 		},
 	}
+	bootCmd.Flags().BoolVarP(&dataChecksums, "data-checksums", "k", false, "use data page checksums")
+	addGucFlags(bootCmd.Flags(), map[string]string{"data_directory": "D"})
 	rootCmd.AddCommand(bootCmd)
 
 	var describeConfigCmd = &cobra.Command{
 		Use:   "describe-config",
 		Short: "Describe configuration parameters",
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("GucInfoMain: Listing all configuration parameters...")
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return guc.GucInfoMain(os.Stdout)
 		},
 	}
 	rootCmd.AddCommand(describeConfigCmd)
 
 	// DISPATCH_SINGLE
+	// postmaster を起動せずに、標準入力から読んだ問い合わせを実行する
+	var echoQuery, useSemiNewlineNewline bool
 	var singleCmd = &cobra.Command{
-		Use:   "single",
+		Use:   "single [DBNAME]",
 		Short: "Single user mode",
-		Run: func(cmd *cobra.Command, args []string) {
-			panic("DISPATCH_SINGLE: Not implemented yet")
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			dbname := ""
+			if len(args) > 0 {
+				dbname = args[0]
+			}
+			return backend.PostgresSingleUserMain(dbname, echoQuery, useSemiNewlineNewline, os.Stdin, os.Stdout)
 		},
 	}
+	singleCmd.Flags().BoolVarP(&echoQuery, "echo", "E", false, "echo statement before execution")
+	singleCmd.Flags().BoolVarP(&useSemiNewlineNewline, "semicolon-newline", "j", false, "do not use newline as interactive query delimiter")
+	addGucFlags(singleCmd.Flags(), map[string]string{"data_directory": "D"})
 	rootCmd.AddCommand(singleCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
 }
+
+// addGucFlags は設定パラメータのオプションを加える。設定パラメータは C言語版の --name=value と
+// 同じく、パラメータ名の "_" を "-" にしたオプションで指定する。よく使うものには shorthands で
+// C言語版と同じ1文字のオプションも付ける。
+func addGucFlags(flags *pflag.FlagSet, shorthands map[string]string) {
+	for _, g := range guc.Variables() {
+		if g.Context == guc.PGCInternal {
+			continue
+		}
+		name := strings.ReplaceAll(strings.ToLower(g.Name), "_", "-")
+		flag := flags.VarPF(gucFlag{g}, name, shorthands[g.Name], g.ShortDesc)
+		if g.VarType() == guc.PGCBool {
+			flag.NoOptDefVal = "on"
+		}
+	}
+}
+
+// gucShorthands は設定パラメータの1文字のオプション (PostmasterMain の getopt の処理相当)
+var gucShorthands = map[string]string{
+	"data_directory":          "D",
+	"listen_addresses":        "h",
+	"port":                    "p",
+	"unix_socket_directories": "k",
+	"max_connections":         "N",
+	"ssl":                     "l",
+}
+
+// gucFlag はコマンドラインで指定された値を、設定パラメータのサーバー全体の値にする
+// (SetConfigOption(name, value, PGC_POSTMASTER, PGC_S_ARGV) 相当)
+type gucFlag struct {
+	g *guc.ConfigGeneric
+}
+
+func (f gucFlag) String() string {
+	value, _ := guc.GetConfigOption(f.g.Name)
+	return value
+}
+
+func (f gucFlag) Set(value string) error {
+	return guc.SetConfigOption(f.g.Name, value, guc.PGCPostmaster, guc.PGCSArgv)
+}
+
+func (f gucFlag) Type() string { return f.g.VarType().String() }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/feutils"
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+)
+
+// sendQuery は問い合わせをサーバーに送り、結果を書き出す (SendQuery 相当)。
+// エラーになった文があれば false を返す。
+func sendQuery(query string) bool {
+	results, err := pset.db.ExecAll(query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		fmt.Fprintf(os.Stderr, "connection to server was lost\n")
+		os.Exit(exitBadConn)
+	}
+
+	ok := true
+	for _, res := range results {
+		switch res.Status {
+		case libpq.TuplesOK:
+			feutils.PrintQuery(os.Stdout, res, feutils.PrintQueryOpt{})
+		case libpq.CommandOK:
+			if !pset.quiet {
+				fmt.Println(res.CmdStatus())
+			}
+		case libpq.FatalError:
+			fmt.Fprint(os.Stderr, res.Err().BuildMessage(query))
+			ok = false
+		}
+	}
+	return ok
+}
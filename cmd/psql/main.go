@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+	"github.com/spf13/cobra"
+)
+
+// ----------------------------------------------------------------
+// 対話型ターミナル (bin/psql/startup.c 相当)
+// ----------------------------------------------------------------
+// pg_regress がテストの SQL を流し込むための最小限の psql。
+// 標準入力または -f で指定したファイルから文を読み、結果を psql と同じ形式で書き出す。
+// バックスラッシュコマンドは \echo、\password、\q だけを扱う。
+
+// Exit codes (EXIT_SUCCESS などの相当)
+const (
+	exitSuccess = 0
+	exitFailure = 1
+	exitBadConn = 2
+	exitUser    = 3 // ON_ERROR_STOP で止まった
+)
+
+// psqlSettings は実行中の設定 (PsqlSettings 相当)
+type psqlSettings struct {
+	db          *libpq.Conn
+	echoAll     bool // -a: 読んだ行をそのまま書き出す
+	quiet       bool // -q: コマンドタグを書き出さない
+	onErrorStop bool
+}
+
+var pset psqlSettings
+
+func main() {
+	progname := filepath.Base(os.Args[0])
+
+	var (
+		commands []string
+		file     string
+		dbname   string
+		host     string
+		port     string
+		username string
+		vars     []string
+	)
+	var rootCmd = &cobra.Command{
+		Use:   "psql [OPTION]... [DBNAME [USERNAME]]",
+		Short: "psql is the PostgreSQL interactive terminal.",
+		Args:  cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && dbname == "" {
+				dbname = args[0]
+			}
+			if len(args) > 1 && username == "" {
+				username = args[1]
+			}
+			for _, v := range vars {
+				name, value, _ := strings.Cut(v, "=")
+				if name == "ON_ERROR_STOP" {
+					pset.onErrorStop = value != "0" && !strings.EqualFold(value, "off")
+				}
+			}
+
+			var conninfo []string
+			for _, kv := range [][2]string{{"dbname", dbname}, {"host", host}, {"port", port}, {"user", username}} {
+				if kv[1] != "" {
+					conninfo = append(conninfo, kv[0]+"='"+strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(kv[1])+"'")
+				}
+			}
+			conn, err := libpq.Connect(strings.Join(conninfo, " "))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+				os.Exit(exitBadConn)
+			}
+			defer conn.Close()
+			conn.SetNoticeReceiver(func(r *libpq.Result) {
+				fmt.Fprint(os.Stderr, r.Err().BuildMessage(""))
+			})
+			pset.db = conn
+
+			if len(commands) > 0 {
+				for _, c := range commands {
+					if pset.echoAll {
+						fmt.Println(c)
+					}
+					if !sendQuery(c) && pset.onErrorStop {
+						os.Exit(exitUser)
+					}
+				}
+				return nil
+			}
+
+			in := os.Stdin
+			if file != "" && file != "-" {
+				f, err := os.Open(file)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: error: %s: %s\n", progname, file, err.Error())
+					os.Exit(exitFailure)
+				}
+				defer f.Close()
+				in = f
+			}
+			os.Exit(mainLoop(in))
+			return nil
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	// -h は接続先のホストに使うため、ヘルプは -? にする
+	rootCmd.Flags().BoolP("help", "?", false, "show this help, then exit")
+	rootCmd.Flags().StringArrayVarP(&commands, "command", "c", nil, "run only single command (SQL or internal) and exit")
+	rootCmd.Flags().StringVarP(&file, "file", "f", "", "execute commands from file, then exit")
+	rootCmd.Flags().StringVarP(&dbname, "dbname", "d", "", "database name to connect to")
+	rootCmd.Flags().StringVarP(&host, "host", "h", "", "database server host or socket directory")
+	rootCmd.Flags().StringVarP(&port, "port", "p", "", "database server port")
+	rootCmd.Flags().StringVarP(&username, "username", "U", "", "database user name")
+	rootCmd.Flags().StringArrayVarP(&vars, "set", "v", nil, "set psql variable NAME to VALUE")
+	rootCmd.Flags().BoolVarP(&pset.echoAll, "echo-all", "a", false, "echo all input from script")
+	rootCmd.Flags().BoolVarP(&pset.quiet, "quiet", "q", false, "run quietly (no messages, only query output)")
+	rootCmd.Flags().BoolP("no-psqlrc", "X", false, "do not read startup file (~/.psqlrc)")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+		os.Exit(exitFailure)
+	}
+}
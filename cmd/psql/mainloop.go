@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/feutils"
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+)
+
+// mainLoop は入力を1行ずつ読み、文が完成するたびに実行する (MainLoop 相当)。
+// 入力の終わりに残った文も実行する。戻り値は終了コード。
+func mainLoop(in io.Reader) int {
+	var scan feutils.PsqlScanState
+	successResult := exitSuccess
+
+	r := bufio.NewReader(in)
+	for {
+		line, err := r.ReadString('\n')
+		if line == "" && err != nil {
+			break
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if pset.echoAll {
+			fmt.Println(line)
+		}
+
+		for _, item := range scan.Scan(line) {
+			switch item.Kind {
+			case feutils.ScanStatement:
+				if !sendQuery(item.Text) {
+					successResult = exitFailure
+					if pset.onErrorStop {
+						return exitUser
+					}
+				}
+			case feutils.ScanBackslash:
+				quit, ok := handleSlashCmd(item.Text)
+				if quit {
+					return successResult
+				}
+				if !ok && pset.onErrorStop {
+					return exitUser
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if q := scan.Pending(); strings.TrimSpace(q) != "" {
+		if !sendQuery(q) {
+			successResult = exitFailure
+		}
+	}
+	return successResult
+}
+
+// handleSlashCmd はバックスラッシュコマンドを実行する (HandleSlashCmds 相当)。
+// quit が true なら入力の処理をやめる。
+func handleSlashCmd(text string) (quit, ok bool) {
+	cmd, args, _ := strings.Cut(text, " ")
+	switch cmd {
+	case "q", "quit":
+		return true, true
+	case "echo":
+		fmt.Println(strings.TrimSpace(args))
+		return false, true
+	case "password":
+		return false, execPassword(strings.TrimSpace(args))
+	}
+	fmt.Fprintf(os.Stderr, "invalid command \\%s\n", cmd)
+	fmt.Fprintf(os.Stderr, "Try \\? for help.\n")
+	return false, false
+}
+
+// execPassword は \password [USERNAME] を実行する (exec_command_password 相当)。
+// 新しいパスワードを2回入力させ、クライアントで暗号化してからサーバーに送る。
+func execPassword(user string) bool {
+	if user == "" {
+		user = pset.db.User()
+	}
+	pw1 := feutils.SimplePrompt(fmt.Sprintf("Enter new password for user \"%s\": ", user), false)
+	pw2 := feutils.SimplePrompt("Enter it again: ", false)
+	if pw1 != pw2 {
+		fmt.Fprintf(os.Stderr, "Passwords didn't match.\n")
+		return false
+	}
+
+	res, err := pset.db.ChangePassword(user, pw1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return false
+	}
+	if res.Status == libpq.FatalError {
+		fmt.Fprint(os.Stderr, res.Err().BuildMessage(""))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/feutils"
+)
+
+// ----------------------------------------------------------------
+// データベースの VACUUM と ANALYZE (bin/scripts/vacuumdb.c 相当)
+// ----------------------------------------------------------------
+// サーバーに接続し、VACUUM か ANALYZE の文を送ってデータベースを掃除する。-a で接続できる
+// 全てのデータベースを、-t で指定したテーブルだけを、-n と -N でスキーマを選んで処理する。
+// -j を指定すると、その数の接続を開き、テーブルごとの文を空いた接続に順に送って並列に処理する。
+// どれかの文が失敗すれば、新しい文は送らずに待っている文の終わるのを待って終了する。
+
+// vacuumingOptions は VACUUM と ANALYZE に付けるオプション (vacuumingOptions 相当)
+type vacuumingOptions struct {
+	analyzeOnly bool
+	verbose     bool
+	andAnalyze  bool
+	full        bool
+	freeze      bool
+	skipLocked  bool
+	// disablePageSkipping は可視性マップで飛ばせるページも読む
+	disablePageSkipping bool
+}
+
+// vacuumdbOptions はコマンドラインで指定した設定
+type vacuumdbOptions struct {
+	vacopts       vacuumingOptions
+	cparams       feutils.ConnParams
+	maintenanceDB string
+	all           bool
+	tables        []string
+	schemas       []string
+	excludeSchema []string
+	jobs          int
+	echo          bool
+	quiet         bool
+}
+
+func main() {
+	progname := filepath.Base(os.Args[0])
+
+	var opts vacuumdbOptions
+	var rootCmd = &cobra.Command{
+		Use:   "vacuumdb [OPTION]... [DBNAME]",
+		Short: "vacuumdb cleans and analyzes a PostgreSQL database.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && opts.cparams.DBName == "" {
+				opts.cparams.DBName = args[0]
+			}
+			v := &vacuumdb{progname: progname, opts: &opts}
+			if err := v.checkOptions(); err != nil {
+				v.logError("%s", err.Error())
+				os.Exit(1)
+			}
+			if !v.run() {
+				os.Exit(1)
+			}
+			return nil
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	// -h は接続先のホストに使うため、ヘルプは -? にする
+	rootCmd.Flags().BoolP("help", "?", false, "show this help, then exit")
+	rootCmd.Flags().BoolVarP(&opts.all, "all", "a", false, "vacuum all databases")
+	rootCmd.Flags().StringVarP(&opts.cparams.DBName, "dbname", "d", "", "database to vacuum")
+	rootCmd.Flags().BoolVar(&opts.vacopts.disablePageSkipping, "disable-page-skipping", false, "disable all page-skipping behavior")
+	rootCmd.Flags().BoolVarP(&opts.echo, "echo", "e", false, "show the commands being sent to the server")
+	rootCmd.Flags().BoolVarP(&opts.vacopts.full, "full", "f", false, "do full vacuuming")
+	rootCmd.Flags().BoolVarP(&opts.vacopts.freeze, "freeze", "F", false, "freeze row transaction information")
+	rootCmd.Flags().IntVarP(&opts.jobs, "jobs", "j", 1, "use this many concurrent connections to vacuum")
+	rootCmd.Flags().StringArrayVarP(&opts.schemas, "schema", "n", nil, "vacuum tables in the specified schema(s) only")
+	rootCmd.Flags().StringArrayVarP(&opts.excludeSchema, "exclude-schema", "N", nil, "do not vacuum tables in the specified schema(s)")
+	rootCmd.Flags().BoolVarP(&opts.quiet, "quiet", "q", false, "don't write any messages")
+	rootCmd.Flags().BoolVar(&opts.vacopts.skipLocked, "skip-locked", false, "skip relations that cannot be immediately locked")
+	rootCmd.Flags().StringArrayVarP(&opts.tables, "table", "t", nil, "vacuum specific table(s) only")
+	rootCmd.Flags().BoolVarP(&opts.vacopts.verbose, "verbose", "v", false, "write a lot of output")
+	rootCmd.Flags().BoolVarP(&opts.vacopts.andAnalyze, "analyze", "z", false, "update optimizer statistics")
+	rootCmd.Flags().BoolVarP(&opts.vacopts.analyzeOnly, "analyze-only", "Z", false, "only update optimizer statistics; no vacuum")
+	rootCmd.Flags().StringVarP(&opts.cparams.Host, "host", "h", "", "database server host or socket directory")
+	rootCmd.Flags().StringVarP(&opts.cparams.Port, "port", "p", "", "database server port")
+	rootCmd.Flags().StringVarP(&opts.cparams.Username, "username", "U", "", "user name to connect as")
+	rootCmd.Flags().StringVar(&opts.maintenanceDB, "maintenance-db", "", "alternate maintenance database")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: error: %s\n", progname, err.Error())
+		fmt.Fprintf(os.Stderr, "Try \"%s --help\" for more information.\n", progname)
+		os.Exit(1)
+	}
+}
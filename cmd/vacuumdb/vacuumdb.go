@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/feutils"
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+)
+
+// vacuumdb は1回の実行の状態
+type vacuumdb struct {
+	progname string
+	opts     *vacuumdbOptions
+}
+
+// checkOptions は同時に指定できないオプションを確かめる (main と check_objfilter の検査相当)
+func (v *vacuumdb) checkOptions() error {
+	o := v.opts
+	if o.jobs < 1 {
+		return fmt.Errorf("-j/--jobs must be in range 1..%d", int32(^uint32(0)>>1))
+	}
+	if o.vacopts.analyzeOnly {
+		for _, opt := range []struct {
+			name string
+			set  bool
+		}{
+			{"full", o.vacopts.full},
+			{"freeze", o.vacopts.freeze},
+			{"disable-page-skipping", o.vacopts.disablePageSkipping},
+		} {
+			if opt.set {
+				return fmt.Errorf("cannot use the \"%s\" option when performing only analyze", opt.name)
+			}
+		}
+	}
+	switch {
+	case o.all && o.cparams.DBName != "":
+		return errors.New("cannot vacuum all databases and a specific one at the same time")
+	case o.all && len(o.tables) > 0:
+		return errors.New("cannot vacuum specific table(s) in all databases")
+	case o.all && len(o.schemas) > 0:
+		return errors.New("cannot vacuum specific schema(s) in all databases")
+	case o.all && len(o.excludeSchema) > 0:
+		return errors.New("cannot exclude specific schema(s) in all databases")
+	case len(o.tables) > 0 && len(o.schemas) > 0:
+		return errors.New("cannot vacuum all tables in schema(s) and specific table(s) at the same time")
+	case len(o.tables) > 0 && len(o.excludeSchema) > 0:
+		return errors.New("cannot vacuum specific table(s) and exclude schema(s) at the same time")
+	case len(o.schemas) > 0 && len(o.excludeSchema) > 0:
+		return errors.New("cannot vacuum all tables in schema(s) and exclude schema(s) at the same time")
+	}
+	return nil
+}
+
+// logError はエラーを書く (pg_log_error 相当)
+func (v *vacuumdb) logError(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "%s: error: %s\n", v.progname, fmt.Sprintf(format, args...))
+}
+
+// run は指定されたデータベースを処理し、全て成功したかを返す
+func (v *vacuumdb) run() bool {
+	if v.opts.all {
+		return v.vacuumAllDatabases()
+	}
+	return v.vacuumOneDatabase(v.opts.cparams.DBName)
+}
+
+// connectDatabase は dbname に接続する。dbname が空なら接続先の既定のデータベースに接続する。
+func (v *vacuumdb) connectDatabase(dbname string) (*libpq.Conn, error) {
+	cparams := v.opts.cparams
+	cparams.DBName = dbname
+	return feutils.ConnectDatabase(cparams)
+}
+
+// vacuumAllDatabases は接続を許している全てのデータベースを順に処理する
+// (vacuum_all_databases 相当)
+func (v *vacuumdb) vacuumAllDatabases() bool {
+	conn, err := feutils.ConnectMaintenanceDatabase(v.opts.cparams, v.opts.maintenanceDB)
+	if err != nil {
+		v.logError("%s", err.Error())
+		return false
+	}
+	dbs, ok := v.executeQuery(conn, "SELECT datname FROM pg_database WHERE datallowconn AND datconnlimit <> -2 ORDER BY 1;")
+	conn.Close()
+	if !ok {
+		return false
+	}
+	for _, row := range dbs {
+		if !v.vacuumOneDatabase(row[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// executeQuery は行を返す問い合わせを実行する (executeQuery 相当)。失敗すればエラーを書いて
+// 偽を返す。
+func (v *vacuumdb) executeQuery(conn *libpq.Conn, query string) ([][]string, bool) {
+	if v.opts.echo {
+		fmt.Println(query)
+	}
+	res, err := conn.Exec(query)
+	if err != nil {
+		v.logError("query failed: %s", err.Error())
+		return nil, false
+	}
+	if res.Status != libpq.TuplesOK {
+		if e := res.Err(); e != nil {
+			v.logError("query failed: %s", e.Error())
+		}
+		v.logError("Query was: %s", query)
+		return nil, false
+	}
+	rows := make([][]string, res.NTuples())
+	for i := range rows {
+		rows[i] = make([]string, res.NFields())
+		for j := range rows[i] {
+			rows[i][j] = res.GetValue(i, j)
+		}
+	}
+	return rows, true
+}
+
+// vacuumOneDatabase は1つのデータベースを処理する (vacuum_one_database 相当)。テーブルを
+// 指定していれば各テーブルに、スキーマで選ぶか並列に処理するならカタログから読んだテーブルに
+// 文を送る。どちらでもなければ、データベース全体に1つの文を送る。
+func (v *vacuumdb) vacuumOneDatabase(dbname string) bool {
+	conn, err := v.connectDatabase(dbname)
+	if err != nil {
+		v.logError("%s", err.Error())
+		return false
+	}
+	if dbname == "" {
+		dbname = conn.User()
+	}
+	if !v.opts.quiet {
+		fmt.Printf("%s: vacuuming database \"%s\"\n", v.progname, dbname)
+	}
+
+	var tables []string
+	switch {
+	case len(v.opts.tables) > 0:
+		tables = v.opts.tables
+	case len(v.opts.schemas) > 0 || len(v.opts.excludeSchema) > 0 || v.opts.jobs > 1:
+		rows, ok := v.executeQuery(conn, v.tableListQuery())
+		if !ok {
+			conn.Close()
+			return false
+		}
+		if len(rows) == 0 {
+			conn.Close()
+			return true
+		}
+		for _, row := range rows {
+			tables = append(tables, libpq.EscapeIdentifier(row[1])+"."+libpq.EscapeIdentifier(row[0]))
+		}
+	}
+
+	if len(tables) == 0 {
+		defer conn.Close()
+		return v.runVacuumCommand(conn, dbname, v.prepareVacuumCommand(""), "")
+	}
+	return v.runParallel(conn, dbname, tables)
+}
+
+// tableListQuery は処理するテーブルを大きい順に読む問い合わせを返す (vacuum_one_database の
+// カタログの問い合わせ相当)。ANALYZE だけならパーティションテーブルも含める。
+func (v *vacuumdb) tableListQuery() string {
+	var b strings.Builder
+	b.WriteString("SELECT c.relname, ns.nspname FROM pg_catalog.pg_class c\n")
+	b.WriteString(" JOIN pg_catalog.pg_namespace ns ON c.relnamespace OPERATOR(pg_catalog.=) ns.oid\n")
+	if v.opts.vacopts.analyzeOnly {
+		b.WriteString(" WHERE c.relkind OPERATOR(pg_catalog.=) ANY (array['r', 'm', 'p'])\n")
+	} else {
+		b.WriteString(" WHERE c.relkind OPERATOR(pg_catalog.=) ANY (array['r', 'm'])\n")
+	}
+	schemaList := func(names []string) string {
+		quoted := make([]string, len(names))
+		for i, n := range names {
+			quoted[i] = libpq.EscapeLiteral(n)
+		}
+		return "array[" + strings.Join(quoted, ", ") + "]::pg_catalog.name[]"
+	}
+	if len(v.opts.schemas) > 0 {
+		fmt.Fprintf(&b, " AND ns.nspname OPERATOR(pg_catalog.=) ANY (%s)\n", schemaList(v.opts.schemas))
+	}
+	if len(v.opts.excludeSchema) > 0 {
+		fmt.Fprintf(&b, " AND ns.nspname OPERATOR(pg_catalog.<>) ALL (%s)\n", schemaList(v.opts.excludeSchema))
+	}
+	b.WriteString(" ORDER BY c.relpages DESC;")
+	return b.String()
+}
+
+// prepareVacuumCommand は table を処理する文を作る (prepare_vacuum_command 相当)。table が
+// 空ならデータベース全体を処理する。
+func (v *vacuumdb) prepareVacuumCommand(table string) string {
+	o := v.opts.vacopts
+	var b strings.Builder
+	var flags []string
+	if o.analyzeOnly {
+		b.WriteString("ANALYZE")
+		if o.skipLocked {
+			flags = append(flags, "SKIP_LOCKED")
+		}
+		if o.verbose {
+			flags = append(flags, "VERBOSE")
+		}
+	} else {
+		b.WriteString("VACUUM")
+		for _, f := range []struct {
+			name string
+			set  bool
+		}{
+			{"DISABLE_PAGE_SKIPPING", o.disablePageSkipping},
+			{"SKIP_LOCKED", o.skipLocked},
+			{"FULL", o.full},
+			{"FREEZE", o.freeze},
+			{"VERBOSE", o.verbose},
+			{"ANALYZE", o.andAnalyze},
+		} {
+			if f.set {
+				flags = append(flags, f.name)
+			}
+		}
+	}
+	if len(flags) > 0 {
+		b.WriteString(" (" + strings.Join(flags, ", ") + ")")
+	}
+	if table != "" {
+		b.WriteString(" " + table)
+	}
+	b.WriteString(";")
+	return b.String()
+}
+
+// runVacuumCommand は文を送って終わるのを待つ (run_vacuum_command 相当)。失敗すればエラーを
+// 書いて偽を返す。
+func (v *vacuumdb) runVacuumCommand(conn *libpq.Conn, dbname, sql, table string) bool {
+	if v.opts.echo {
+		fmt.Println(sql)
+	}
+	res, err := conn.Exec(sql)
+	var msg string
+	switch {
+	case err != nil:
+		msg = err.Error()
+	case res.Status == libpq.FatalError:
+		msg = res.Err().Error()
+	default:
+		return true
+	}
+	if table != "" {
+		v.logError("vacuuming of table \"%s\" in database \"%s\" failed: %s", table, dbname, msg)
+	} else {
+		v.logError("vacuuming of database \"%s\" failed: %s", dbname, msg)
+	}
+	return false
+}
+
+// runParallel はテーブルごとの文を、-j の数まで開いた接続の空いたものに順に送る
+// (vacuum_one_database の ParallelSlot による処理相当)。conn は最初の接続として使う。
+// どれかが失敗すれば、残りのテーブルは処理しない。
+func (v *vacuumdb) runParallel(conn *libpq.Conn, dbname string, tables []string) bool {
+	conns := []*libpq.Conn{conn}
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+	for len(conns) < min(v.opts.jobs, len(tables)) {
+		c, err := v.connectDatabase(dbname)
+		if err != nil {
+			v.logError("%s", err.Error())
+			return false
+		}
+		conns = append(conns, c)
+	}
+
+	var failed atomic.Bool
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for table := range work {
+				if !v.runVacuumCommand(c, dbname, v.prepareVacuumCommand(table), table) {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	for _, table := range tables {
+		if failed.Load() {
+			break
+		}
+		work <- table
+	}
+	close(work)
+	wg.Wait()
+	return !failed.Load()
+}
@@ -0,0 +1,372 @@
+package gist
+
+import (
+	"math"
+	"sort"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// 幾何型の GiST 演算子クラスのサポート関数 (access/gist/gistproc.c 相当)
+// ----------------------------------------------------------------
+// box_ops, poly_ops, circle_ops, point_ops の索引キーは全て矩形 (Box)。
+// polygon と circle は外接矩形を、point は縦横の幅が 0 の矩形をキーにする。
+// GiST アクセスメソッド本体はまだ存在しないため、サポート関数
+// (consistent, union, penalty, picksplit, distance) のみを提供する。
+//
+// point_ops の演算子クラスとその演算子は pg_opclass.dat などに登録してあり、サポート関数は
+// fmgr パッケージが Entry を internal の引数として受け取る関数として登録する。
+
+// StrategyNumber は R-tree 系演算子のストラテジ番号 (access/stratnum.h 相当)。
+type StrategyNumber int
+
+const (
+	RTLeftStrategyNumber           StrategyNumber = 1  // <<
+	RTOverLeftStrategyNumber       StrategyNumber = 2  // &<
+	RTOverlapStrategyNumber        StrategyNumber = 3  // &&
+	RTOverRightStrategyNumber      StrategyNumber = 4  // &>
+	RTRightStrategyNumber          StrategyNumber = 5  // >>
+	RTSameStrategyNumber           StrategyNumber = 6  // ~=
+	RTContainsStrategyNumber       StrategyNumber = 7  // @>
+	RTContainedByStrategyNumber    StrategyNumber = 8  // <@
+	RTOverBelowStrategyNumber      StrategyNumber = 9  // &<|
+	RTBelowStrategyNumber          StrategyNumber = 10 // <<|
+	RTAboveStrategyNumber          StrategyNumber = 11 // |>>
+	RTOverAboveStrategyNumber      StrategyNumber = 12 // |&>
+	RTOldContainsStrategyNumber    StrategyNumber = 13 // 旧 ~
+	RTOldContainedByStrategyNumber StrategyNumber = 14 // 旧 @
+	RTKNNSearchStrategyNumber      StrategyNumber = 15 // <->
+)
+
+// point_ops では、右辺の型ごとにストラテジ番号を GeoStrategyNumberOffset ずつずらす。
+const (
+	GeoStrategyNumberOffset = 20
+
+	PointStrategyNumberGroup   = 0
+	BoxStrategyNumberGroup     = 1
+	PolygonStrategyNumberGroup = 2
+	CircleStrategyNumberGroup  = 3
+)
+
+// Entry はサポート関数に渡す索引キー1つ分 (GISTENTRY 相当)。Key は圧縮した索引キー (*adt.Box) か、
+// 圧縮する前の葉の値
+type Entry struct {
+	Key  adt.Datum
+	Leaf bool
+}
+
+// ----------------------------------------------------------------
+// box_ops
+// ----------------------------------------------------------------
+
+// BoxConsistent は索引キーが条件を満たし得るかを判定する (gist_box_consistent 相当)。
+// 矩形の比較は正確なので再評価は不要。
+func BoxConsistent(key, query *adt.Box, strategy StrategyNumber, isLeaf bool) (match bool, recheck bool) {
+	if isLeaf {
+		return boxLeafConsistent(key, query, strategy), false
+	}
+	return rtreeInternalConsistent(key, query, strategy), false
+}
+
+// boxLeafConsistent は葉のキー (索引付けした値そのもの) を判定する (gist_box_leaf_consistent 相当)
+func boxLeafConsistent(key, query *adt.Box, strategy StrategyNumber) bool {
+	switch strategy {
+	case RTLeftStrategyNumber:
+		return adt.BoxLeft(key, query)
+	case RTOverLeftStrategyNumber:
+		return adt.BoxOverLeft(key, query)
+	case RTOverlapStrategyNumber:
+		return adt.BoxOverlap(key, query)
+	case RTOverRightStrategyNumber:
+		return adt.BoxOverRight(key, query)
+	case RTRightStrategyNumber:
+		return adt.BoxRight(key, query)
+	case RTSameStrategyNumber:
+		return adt.BoxSame(key, query)
+	case RTContainsStrategyNumber, RTOldContainsStrategyNumber:
+		return adt.BoxContain(key, query)
+	case RTContainedByStrategyNumber, RTOldContainedByStrategyNumber:
+		return adt.BoxContain(query, key)
+	case RTOverBelowStrategyNumber:
+		return adt.BoxOverBelow(key, query)
+	case RTBelowStrategyNumber:
+		return adt.BoxBelow(key, query)
+	case RTAboveStrategyNumber:
+		return adt.BoxAbove(key, query)
+	case RTOverAboveStrategyNumber:
+		return adt.BoxOverAbove(key, query)
+	default:
+		return false
+	}
+}
+
+// rtreeInternalConsistent は内部ノードのキー (子のキーの和) を判定する (rtree_internal_consistent 相当)。
+// 子のいずれかが条件を満たし得る場合に true を返す。
+func rtreeInternalConsistent(key, query *adt.Box, strategy StrategyNumber) bool {
+	switch strategy {
+	case RTLeftStrategyNumber:
+		return !adt.BoxOverRight(key, query)
+	case RTOverLeftStrategyNumber:
+		return !adt.BoxRight(key, query)
+	case RTOverlapStrategyNumber:
+		return adt.BoxOverlap(key, query)
+	case RTOverRightStrategyNumber:
+		return !adt.BoxLeft(key, query)
+	case RTRightStrategyNumber:
+		return !adt.BoxOverLeft(key, query)
+	case RTSameStrategyNumber, RTContainsStrategyNumber, RTOldContainsStrategyNumber:
+		return adt.BoxContain(key, query)
+	case RTContainedByStrategyNumber, RTOldContainedByStrategyNumber:
+		return adt.BoxOverlap(key, query)
+	case RTOverBelowStrategyNumber:
+		return !adt.BoxAbove(key, query)
+	case RTBelowStrategyNumber:
+		return !adt.BoxOverAbove(key, query)
+	case RTAboveStrategyNumber:
+		return !adt.BoxOverBelow(key, query)
+	case RTOverAboveStrategyNumber:
+		return !adt.BoxBelow(key, query)
+	default:
+		return false
+	}
+}
+
+// BoxUnion は全てのキーを囲む矩形を返す (gist_box_union 相当)
+func BoxUnion(keys []*adt.Box) *adt.Box {
+	u := *keys[0]
+	for _, b := range keys[1:] {
+		adjustBox(&u, b)
+	}
+	return &u
+}
+
+// adjustBox は b を含むように u を広げる (adjustBox 相当)
+func adjustBox(u, b *adt.Box) {
+	u.High.X = math.Max(u.High.X, b.High.X)
+	u.High.Y = math.Max(u.High.Y, b.High.Y)
+	u.Low.X = math.Min(u.Low.X, b.Low.X)
+	u.Low.Y = math.Min(u.Low.Y, b.Low.Y)
+}
+
+// sizeBox は矩形の面積を返す (size_box 相当)。幅または高さが負なら 0、NaN を含めば無限大とする。
+func sizeBox(b *adt.Box) float64 {
+	if b.High.X <= b.Low.X || b.High.Y <= b.Low.Y {
+		return 0
+	}
+	if math.IsNaN(b.High.X) || math.IsNaN(b.High.Y) || math.IsNaN(b.Low.X) || math.IsNaN(b.Low.Y) {
+		return math.Inf(1)
+	}
+	return (b.High.X - b.Low.X) * (b.High.Y - b.Low.Y)
+}
+
+// BoxPenalty は orig に add を加えたときの面積の増分を返す (gist_box_penalty 相当)
+func BoxPenalty(orig, add *adt.Box) float64 {
+	u := *orig
+	adjustBox(&u, add)
+	return sizeBox(&u) - sizeBox(orig)
+}
+
+// BoxSame は2つのキーが同じかを返す (gist_box_same 相当)。正確に比較する。
+func BoxSame(b1, b2 *adt.Box) bool {
+	return *b1 == *b2
+}
+
+// Split はページ分割の結果 (GIST_SPLITVEC 相当)。Left と Right はキーの添字。
+type Split struct {
+	Left, Right           []int
+	LeftUnion, RightUnion *adt.Box
+}
+
+// limitRatio は分割後の一方のページに入るキーの最小の割合 (LIMIT_RATIO 相当)
+const limitRatio = 0.3
+
+// BoxPicksplit はページのキーを2つに分ける (gist_box_picksplit 相当)。
+// C言語版の double sorting split を簡略化したもので、X 軸と Y 軸のそれぞれについて
+// キーを下限で並べ、分割位置を動かしながら2つのグループの重なりが最小になる分割を選ぶ。
+// 重なりが同じ場合は面積の和が小さい方を選ぶ。
+func BoxPicksplit(keys []*adt.Box) *Split {
+	n := len(keys)
+	minCount := int(math.Ceil(limitRatio * float64(n)))
+	if minCount < 1 {
+		minCount = 1
+	}
+
+	var best *Split
+	bestOverlap, bestSize := math.Inf(1), math.Inf(1)
+	for _, axis := range []func(*adt.Box) (lo, hi float64){
+		func(b *adt.Box) (float64, float64) { return b.Low.X, b.High.X },
+		func(b *adt.Box) (float64, float64) { return b.Low.Y, b.High.Y },
+	} {
+		order := make([]int, n)
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			li, hi := axis(keys[order[i]])
+			lj, hj := axis(keys[order[j]])
+			return li < lj || li == lj && hi < hj
+		})
+
+		for k := minCount; k <= n-minCount; k++ {
+			left := unionOf(keys, order[:k])
+			right := unionOf(keys, order[k:])
+			_, lhi := axis(left)
+			rlo, _ := axis(right)
+			overlap := math.Max(0, lhi-rlo)
+			size := sizeBox(left) + sizeBox(right)
+			if overlap < bestOverlap || overlap == bestOverlap && size < bestSize {
+				bestOverlap, bestSize = overlap, size
+				best = &Split{
+					Left:       append([]int(nil), order[:k]...),
+					Right:      append([]int(nil), order[k:]...),
+					LeftUnion:  left,
+					RightUnion: right,
+				}
+			}
+		}
+	}
+	if best == nil {
+		// キーが少なすぎて最小数を満たせない場合は半分に分ける (fallbackSplit 相当)
+		order := make([]int, n)
+		for i := range order {
+			order[i] = i
+		}
+		half := (n + 1) / 2
+		best = &Split{
+			Left:       order[:half],
+			Right:      order[half:],
+			LeftUnion:  unionOf(keys, order[:half]),
+			RightUnion: unionOf(keys, order[half:]),
+		}
+	}
+	return best
+}
+
+func unionOf(keys []*adt.Box, idx []int) *adt.Box {
+	if len(idx) == 0 {
+		return nil
+	}
+	u := *keys[idx[0]]
+	for _, i := range idx[1:] {
+		adjustBox(&u, keys[i])
+	}
+	return &u
+}
+
+// computeDistance は点から索引キーまでの距離を返す (computeDistance 相当)。
+// 葉のキーが点 (幅 0 の矩形) の場合は点どうしの距離になる。
+func computeDistance(isLeaf bool, key *adt.Box, p *adt.Point) float64 {
+	if isLeaf && key.High == key.Low {
+		return adt.PointDistance(&key.High, p)
+	}
+	return adt.DistPb(p, key)
+}
+
+// BoxDistance は KNN 検索で使う、点から矩形のキーまでの距離 (gist_box_distance 相当)
+func BoxDistance(key *adt.Box, query *adt.Point) float64 {
+	return computeDistance(false, key, query)
+}
+
+// ----------------------------------------------------------------
+// poly_ops, circle_ops
+// ----------------------------------------------------------------
+// キーは外接矩形なので、判定結果は常に元の値での再評価が必要になる。
+
+// PolyCompress は多角形の索引キーを作る (gist_poly_compress 相当)
+func PolyCompress(poly *adt.Polygon) *adt.Box {
+	b := poly.BoundBox
+	return &b
+}
+
+// PolyConsistent は gist_poly_consistent 相当。
+func PolyConsistent(key *adt.Box, query *adt.Polygon, strategy StrategyNumber) (match bool, recheck bool) {
+	return rtreeInternalConsistent(key, &query.BoundBox, strategy), true
+}
+
+// CircleCompress は円の索引キーを作る (gist_circle_compress 相当)
+func CircleCompress(c *adt.Circle) *adt.Box {
+	return adt.CircleBox(c)
+}
+
+// CircleConsistent は gist_circle_consistent 相当。
+func CircleConsistent(key *adt.Box, query *adt.Circle, strategy StrategyNumber) (match bool, recheck bool) {
+	return rtreeInternalConsistent(key, adt.CircleBox(query), strategy), true
+}
+
+// PolyDistance は gist_poly_distance 相当。外接矩形までの距離なので再評価が必要。
+func PolyDistance(key *adt.Box, query *adt.Point) (dist float64, recheck bool) {
+	return computeDistance(false, key, query), true
+}
+
+// CircleDistance は gist_circle_distance 相当。外接矩形までの距離なので再評価が必要。
+func CircleDistance(key *adt.Box, query *adt.Point) (dist float64, recheck bool) {
+	return computeDistance(false, key, query), true
+}
+
+// ----------------------------------------------------------------
+// point_ops
+// ----------------------------------------------------------------
+
+// PointCompress は点の索引キーを作る (gist_point_compress 相当)
+func PointCompress(p *adt.Point) *adt.Box {
+	return &adt.Box{High: *p, Low: *p}
+}
+
+// PointConsistent は gist_point_consistent 相当。query の型は、ストラテジ番号の
+// グループに応じて *adt.Point, *adt.Box, *adt.Polygon, *adt.Circle のいずれか。
+func PointConsistent(key *adt.Box, query any, strategy StrategyNumber, isLeaf bool) (match bool, recheck bool) {
+	switch strategy / GeoStrategyNumberOffset {
+	case PointStrategyNumberGroup:
+		return pointConsistentInternal(key, query.(*adt.Point), strategy%GeoStrategyNumberOffset, isLeaf), false
+	case BoxStrategyNumberGroup:
+		// point <@ box のみ。on_pb は正確な比較を使うため、ここでも正確に判定する。
+		// 葉のキーは High == Low なので、内部ノードと同じ判定で済む。
+		q := query.(*adt.Box)
+		return q.High.X >= key.Low.X && q.Low.X <= key.High.X &&
+			q.High.Y >= key.Low.Y && q.Low.Y <= key.High.Y, false
+	case PolygonStrategyNumberGroup:
+		q := query.(*adt.Polygon)
+		match = rtreeInternalConsistent(key, &q.BoundBox, RTOverlapStrategyNumber)
+		if isLeaf && match {
+			return adt.PolyContainPt(q, &key.High), false
+		}
+		return match, true
+	case CircleStrategyNumberGroup:
+		q := query.(*adt.Circle)
+		match = rtreeInternalConsistent(key, adt.CircleBox(q), RTOverlapStrategyNumber)
+		if isLeaf && match {
+			return adt.CircleContainPt(q, &key.High), false
+		}
+		return match, true
+	default:
+		return false, false
+	}
+}
+
+// pointConsistentInternal は点どうしの演算子を判定する (gist_point_consistent_internal 相当)
+func pointConsistentInternal(key *adt.Box, q *adt.Point, strategy StrategyNumber, isLeaf bool) bool {
+	switch strategy {
+	case RTLeftStrategyNumber:
+		return adt.FPlt(key.Low.X, q.X)
+	case RTRightStrategyNumber:
+		return adt.FPgt(key.High.X, q.X)
+	case RTAboveStrategyNumber:
+		return adt.FPgt(key.High.Y, q.Y)
+	case RTBelowStrategyNumber:
+		return adt.FPlt(key.Low.Y, q.Y)
+	case RTSameStrategyNumber:
+		if isLeaf {
+			return adt.FPeq(key.Low.X, q.X) && adt.FPeq(key.Low.Y, q.Y)
+		}
+		return adt.FPle(q.X, key.High.X) && adt.FPge(q.X, key.Low.X) &&
+			adt.FPle(q.Y, key.High.Y) && adt.FPge(q.Y, key.Low.Y)
+	default:
+		return false
+	}
+}
+
+// PointDistance は KNN 検索で使う、点から索引キーまでの距離 (gist_point_distance 相当)
+func PointDistance(key *adt.Box, query *adt.Point, isLeaf bool) float64 {
+	return computeDistance(isLeaf, key, query)
+}
@@ -0,0 +1,105 @@
+package gist
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+func box(lx, ly, hx, hy float64) *adt.Box {
+	return &adt.Box{Low: adt.Point{X: lx, Y: ly}, High: adt.Point{X: hx, Y: hy}}
+}
+
+func TestPointConsistentLeaf(t *testing.T) {
+	key := PointCompress(&adt.Point{X: 1, Y: 2})
+	tests := []struct {
+		strategy StrategyNumber
+		query    any
+		want     bool
+	}{
+		{RTLeftStrategyNumber, &adt.Point{X: 3, Y: 0}, true},
+		{RTLeftStrategyNumber, &adt.Point{X: 1, Y: 0}, false},
+		{RTRightStrategyNumber, &adt.Point{X: 0, Y: 0}, true},
+		{RTAboveStrategyNumber, &adt.Point{X: 0, Y: 1}, true},
+		{RTBelowStrategyNumber, &adt.Point{X: 0, Y: 1}, false},
+		{RTSameStrategyNumber, &adt.Point{X: 1, Y: 2}, true},
+		{RTSameStrategyNumber, &adt.Point{X: 1, Y: 2.5}, false},
+		// point <@ box
+		{BoxStrategyNumberGroup*GeoStrategyNumberOffset + RTContainedByStrategyNumber, box(0, 0, 2, 2), true},
+		{BoxStrategyNumberGroup*GeoStrategyNumberOffset + RTContainedByStrategyNumber, box(2, 2, 3, 3), false},
+	}
+	for _, tt := range tests {
+		match, recheck := PointConsistent(key, tt.query, tt.strategy, true)
+		if match != tt.want || recheck {
+			t.Errorf("PointConsistent(leaf, %v, %d) = %t, %t; want %t, false", tt.query, tt.strategy, match, recheck, tt.want)
+		}
+	}
+}
+
+func TestPointConsistentInternal(t *testing.T) {
+	// 内部ノードのキーは子の点を囲む矩形。子に条件を満たす点があり得れば true を返す
+	key := box(0, 0, 10, 10)
+	tests := []struct {
+		strategy StrategyNumber
+		query    *adt.Point
+		want     bool
+	}{
+		{RTSameStrategyNumber, &adt.Point{X: 5, Y: 5}, true},
+		{RTSameStrategyNumber, &adt.Point{X: 11, Y: 5}, false},
+		{RTLeftStrategyNumber, &adt.Point{X: 0, Y: 5}, false},
+		{RTLeftStrategyNumber, &adt.Point{X: 0.5, Y: 5}, true},
+		{RTAboveStrategyNumber, &adt.Point{X: 5, Y: 10}, false},
+	}
+	for _, tt := range tests {
+		if match, _ := PointConsistent(key, tt.query, tt.strategy, false); match != tt.want {
+			t.Errorf("PointConsistent(%v, %v, %d) = %t, want %t", key, tt.query, tt.strategy, match, tt.want)
+		}
+	}
+}
+
+func TestPointDistance(t *testing.T) {
+	q := &adt.Point{X: 0, Y: 0}
+	if got := PointDistance(PointCompress(&adt.Point{X: 3, Y: 4}), q, true); got != 5 {
+		t.Errorf("leaf distance = %v, want 5", got)
+	}
+	// 内部ノードでは矩形までの最短距離。矩形の中の点なら 0
+	if got := PointDistance(box(3, -1, 5, 1), q, false); got != 3 {
+		t.Errorf("internal distance = %v, want 3", got)
+	}
+	if got := PointDistance(box(-1, -1, 1, 1), q, false); got != 0 {
+		t.Errorf("distance to enclosing box = %v, want 0", got)
+	}
+	if got, want := PointDistance(box(1, 1, 2, 2), q, false), math.Sqrt2; math.Abs(got-want) > 1e-12 {
+		t.Errorf("distance to box corner = %v, want %v", got, want)
+	}
+}
+
+func TestBoxPicksplitKeepsEveryKey(t *testing.T) {
+	var keys []*adt.Box
+	for i := range 10 {
+		x := float64(i)
+		if i >= 5 {
+			x += 100
+		}
+		keys = append(keys, PointCompress(&adt.Point{X: x, Y: 0}))
+	}
+	split := BoxPicksplit(keys)
+	if len(split.Left)+len(split.Right) != len(keys) || len(split.Left) == 0 || len(split.Right) == 0 {
+		t.Fatalf("split = %v / %v", split.Left, split.Right)
+	}
+	// 離れた2つの群に分かれ、それぞれの和の矩形は全てのキーを囲む
+	for _, side := range []struct {
+		idx   []int
+		union *adt.Box
+	}{{split.Left, split.LeftUnion}, {split.Right, split.RightUnion}} {
+		for _, i := range side.idx {
+			if !BoxSame(BoxUnion([]*adt.Box{side.union, keys[i]}), side.union) {
+				t.Errorf("key %d %v is outside its union %v", i, keys[i], side.union)
+			}
+		}
+	}
+	if split.LeftUnion.High.X >= 100 && split.LeftUnion.Low.X < 100 {
+		t.Errorf("left union %v spans both groups", split.LeftUnion)
+	}
+}
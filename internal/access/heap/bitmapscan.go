@@ -0,0 +1,220 @@
+package heap
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/nodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/buffer"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/page"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/smgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/snapmgr"
+)
+
+// ----------------------------------------------------------------
+// ビットマップヒープスキャン (executor/nodeBitmapHeapscan.c と access/heap/heapam_handler.c の
+// heapam_scan_bitmap_next_block 相当)
+// ----------------------------------------------------------------
+// インデックスが集めたタプルの位置の集合 (nodes.TIDBitmap) を、ブロック番号の順に辿って
+// ヒープのページを読む。ページはとびとびになり、OS の順読みの先読みが効かないため、これから
+// 読むページの読み込みを PrefetchBuffer で先に始めさせる。
+//
+// 先読みには本体とは別のイテレーターを使い、本体より最大 prefetchTarget ページ先を読ませる。
+// prefetchTarget は -1 から始め、ページを1つ読むたびに 0、1、2、4 と増やして
+// effective_io_concurrency に達したら止める。数行だけ読んで終わるスキャンで、使わないページを
+// 読ませないためである。先読みの要求は、本体が今のページを読み終えてから出す。本体の読み込みと
+// 競わないためである。
+//
+// 条件の確かめ直しは呼び出し元が行う。インデックスのアクセスメソッドはまだないため、集合は
+// 呼び出し元が作る。
+
+// BitmapScan はビットマップヒープスキャン (BitmapHeapScanState と HeapScanDescData のうち
+// ビットマップのスキャンで使う部分相当)
+type BitmapScan struct {
+	rel *Relation
+	// snapshot は行を選ぶスナップショットで、curxid はスキャンするトランザクション。snapshot が
+	// nil なら全ての版を返す
+	snapshot *snapmgr.Snapshot
+	curxid   adt.TransactionId
+	// nblocks はスキャンを始めたときのページ数。これより後ろのページは読まない (rs_nblocks 相当)
+	nblocks storage.BlockNumber
+
+	iterator *nodes.TBMIterator
+	// recheck は今のページのタプルの条件を確かめ直す必要があるか
+	recheck bool
+	// tuples は今のページの見えるタプルの写しで、next が次に返す位置 (rs_vistuples 相当)
+	tuples []*HeapTuple
+	next   int
+	// onPage は今のページのタプルを返している途中であることを表す (tbmres が NULL でないこと相当)
+	onPage bool
+
+	// prefetchIterator は先読みするページを辿るイテレーター。先読みしないか、全て先読みしたら nil
+	prefetchIterator *nodes.TBMIterator
+	// prefetchPages は先読みのイテレーターが本体より先にいるページの数
+	prefetchPages int
+	// prefetchTarget は先読みのイテレーターを本体より先に進めるページの数。-1 から始める
+	prefetchTarget int
+	// prefetchMaximum は prefetchTarget の上限 (effective_io_concurrency)
+	prefetchMaximum int
+}
+
+// BeginBitmapScan は tbm のタプルを読むビットマップヒープスキャンを始める (ExecInitBitmapHeapScan と
+// heap_beginscan 相当)。トランザクション curxid の snapshot から見える版だけを返す。
+func (rel *Relation) BeginBitmapScan(snapshot *snapmgr.Snapshot, curxid adt.TransactionId, tbm *nodes.TIDBitmap) (*BitmapScan, error) {
+	nblocks, err := smgr.Nblocks(rel.Locator, storage.MainForkNum)
+	if err != nil {
+		return nil, err
+	}
+	scan := &BitmapScan{
+		rel:             rel,
+		snapshot:        snapshot,
+		curxid:          curxid,
+		nblocks:         nblocks,
+		iterator:        tbm.BeginIterate(),
+		prefetchTarget:  -1,
+		prefetchMaximum: guc.EffectiveIoConcurrency.Get(),
+	}
+	if scan.prefetchMaximum > 0 {
+		scan.prefetchIterator = tbm.BeginIterate()
+	}
+	return scan, nil
+}
+
+// Next は次のタプルの写しを返す (BitmapHeapNext 相当)。recheck が真なら、呼び出し元はタプルが
+// 条件を満たすかを確かめ直す。終わりに達したら nil を返す。
+func (scan *BitmapScan) Next() (tup *HeapTuple, recheck bool, err error) {
+	for {
+		if !scan.onPage {
+			res, ok := scan.iterator.Next()
+			if !ok {
+				return nil, false, nil
+			}
+			if err := scan.adjustPrefetchIterator(res); err != nil {
+				return nil, false, err
+			}
+			if res.Blockno >= scan.nblocks {
+				// スキャンを始めた後にリレーションが切り詰められたか、集合が古い
+				continue
+			}
+			if err := scan.getPage(res); err != nil {
+				return nil, false, err
+			}
+			scan.onPage = true
+			scan.adjustPrefetchTarget()
+		} else if scan.prefetchTarget < scan.prefetchMaximum {
+			// 1ページ目を読み終える前でも、いくらか先読みを始めておく
+			scan.prefetchTarget++
+		}
+
+		// 今のページを読み終えてから先読みの要求を出す
+		if err := scan.prefetch(); err != nil {
+			return nil, false, err
+		}
+
+		if scan.next < len(scan.tuples) {
+			tup := scan.tuples[scan.next]
+			scan.next++
+			return tup, scan.recheck, nil
+		}
+		scan.onPage = false
+	}
+}
+
+// adjustPrefetchIterator は本体のイテレーターが res に進んだことを先読みの状態に反映する
+// (BitmapAdjustPrefetchIterator 相当)。先読みのイテレーターが本体より後ろにならないようにする。
+func (scan *BitmapScan) adjustPrefetchIterator(res nodes.TBMIterateResult) error {
+	if scan.prefetchPages > 0 {
+		// 本体が先読みの位置に1ページ近づいた
+		scan.prefetchPages--
+		return nil
+	}
+	if scan.prefetchIterator != nil {
+		pre, ok := scan.prefetchIterator.Next()
+		if !ok || pre.Blockno != res.Blockno {
+			return errutil.Elog(errutil.Error, "prefetch and main iterators are out of sync")
+		}
+	}
+	return nil
+}
+
+// adjustPrefetchTarget は先読みするページの数を増やす (BitmapAdjustPrefetchTarget 相当)
+func (scan *BitmapScan) adjustPrefetchTarget() {
+	switch {
+	case scan.prefetchTarget >= scan.prefetchMaximum:
+		// これ以上は増やさない
+	case scan.prefetchTarget >= scan.prefetchMaximum/2:
+		scan.prefetchTarget = scan.prefetchMaximum
+	case scan.prefetchTarget > 0:
+		scan.prefetchTarget *= 2
+	default:
+		scan.prefetchTarget++
+	}
+}
+
+// prefetch は先読みのイテレーターを本体より prefetchTarget ページ先まで進め、進めたページの
+// 読み込みを始めさせる (BitmapPrefetch 相当)
+func (scan *BitmapScan) prefetch() error {
+	if scan.prefetchIterator == nil {
+		return nil
+	}
+	for scan.prefetchPages < scan.prefetchTarget {
+		pre, ok := scan.prefetchIterator.Next()
+		if !ok {
+			// 先読みするページはもうない
+			scan.prefetchIterator = nil
+			return nil
+		}
+		scan.prefetchPages++
+		if pre.Blockno >= scan.nblocks {
+			continue
+		}
+		if _, err := scan.rel.buffers.PrefetchBuffer(scan.rel.Locator, storage.MainForkNum, pre.Blockno); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getPage はページ res.Blockno の集合が指す見えるタプルを写して scan.tuples に置く
+// (heapam_scan_bitmap_next_block 相当)。不正確なページは全てのタプルを調べる。HOT はまだないため、
+// 集合が指す行ポインタのタプルだけを調べる。
+func (scan *BitmapScan) getPage(res nodes.TBMIterateResult) error {
+	b := scan.rel.buffers
+	buf, err := b.ReadBuffer(scan.rel.Locator, res.Blockno)
+	if err != nil {
+		return err
+	}
+	b.LockBuffer(buf, buffer.BufferLockShare)
+	pg := b.BufferGetPage(buf)
+	scan.tuples = scan.tuples[:0]
+	scan.next = 0
+	scan.recheck = res.Recheck
+	visit := func(off storage.OffsetNumber) error {
+		tup, ok := scan.rel.getTuple(pg, storage.ItemPointer{Block: res.Blockno, Offset: off})
+		if !ok {
+			return nil
+		}
+		if scan.snapshot != nil {
+			visible, err := scan.rel.satisfiesMVCC(tup, scan.snapshot, scan.curxid, buf)
+			if err != nil || !visible {
+				return err
+			}
+		}
+		scan.tuples = append(scan.tuples, tup.Copy())
+		return nil
+	}
+	if res.Lossy {
+		for off := storage.FirstOffsetNumber; err == nil && off <= page.MaxOffsetNumber(pg); off++ {
+			err = visit(off)
+		}
+	} else {
+		for _, off := range res.Offsets {
+			if err = visit(off); err != nil {
+				break
+			}
+		}
+	}
+	b.UnlockReleaseBuffer(buf)
+	return err
+}
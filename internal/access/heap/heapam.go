@@ -0,0 +1,463 @@
+package heap
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/buffer"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/page"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/smgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/snapmgr"
+)
+
+// ----------------------------------------------------------------
+// ヒープのアクセスメソッド (access/heap/heapam.c 相当)
+// ----------------------------------------------------------------
+// ヒープのリレーションに行を挿入、削除、更新し、ページを先頭から順に読む。ページは共有
+// バッファを通して読み書きし、変更は内容のロックを排他のモードで取って行う。
+//
+// 削除と更新は行を消さずに、古い版の t_xmax に変更したトランザクションを書く。更新は新しい版を
+// 別の行として置き、古い版の t_ctid から新しい版を指す。HOT はまだないため、新しい版が同じ
+// ページに入っても、常にインデックスから指される普通の行として置く。
+//
+// トランザクションのロックはまだないため、実行中の他のトランザクションが変更した行を変更
+// しようとした場合は、待たずに TMBeingModified を返す。待って確かめ直すのは呼び出し元が行う。
+// WAL もまだないため、変更を記録しない。
+//
+// 大量の行は MultiInsert でまとめて挿入する。BulkInsertState を渡すと、共有バッファを
+// 追い出さないようリングのバッファを使い、最後に使ったページのピンを持ち続ける。
+//
+// スキャンと Fetch はスナップショットから見える版だけを返す。スナップショットに nil を渡すと、
+// 行ポインタが指す全ての版を返す (SnapshotAny 相当)。
+
+// Relation はバックエンドが開いたヒープのリレーション (RelationData のうちヒープのアクセスで
+// 使う部分相当)。バックエンドごとに Open で作り、そのバックエンドのゴルーチンだけが使う。
+type Relation struct {
+	// Oid はリレーションの OID。タプルの t_tableOid と拡張のロックに使う
+	Oid catalog.Oid
+	// Locator はリレーションのファイル
+	Locator storage.RelFileLocator
+	// Desc はタプルの列の並び
+	Desc TupleDesc
+
+	buffers *buffer.Backend
+	proc    *lmgr.Proc
+	// targetBlock は最後に挿入したページ (smgr_targblock 相当)。分からなければ InvalidBlockNumber
+	targetBlock storage.BlockNumber
+}
+
+// Open はリレーションを開く (relation_open のうちヒープのアクセスの準備相当)。ページは
+// buffers のピンとして読み、拡張のロックは proc で取る。リレーションのロックは呼び出し元が取る。
+func Open(buffers *buffer.Backend, proc *lmgr.Proc, oid catalog.Oid, locator storage.RelFileLocator, desc TupleDesc) *Relation {
+	return &Relation{Oid: oid, Locator: locator, Desc: desc, buffers: buffers, proc: proc, targetBlock: storage.InvalidBlockNumber}
+}
+
+// prepareInsert は挿入するタプルのヘッダのトランザクションの欄を書く (heap_prepare_insert 相当)
+func (rel *Relation) prepareInsert(tup *HeapTuple, cid adt.CommandId, xid adt.TransactionId) {
+	t := tup.Data
+	t.SetInfomask(t.Infomask()&^HeapXactMask | HeapXmaxInvalid)
+	t.SetInfomask2(t.Infomask2() &^ Heap2XactMask)
+	t.SetXmin(xid)
+	t.SetCmin(cid)
+	t.SetXmax(transam.InvalidTransactionId)
+	tup.TableOid = rel.Oid
+}
+
+// Insert はトランザクション xid のコマンド cid としてタプルを挿入する (heap_insert 相当)。
+// 置いた位置を tup.Self に書く。大量に挿入する場合は bistate を渡す。nil でもよい。
+func (rel *Relation) Insert(tup *HeapTuple, cid adt.CommandId, xid adt.TransactionId, bistate *BulkInsertState) error {
+	rel.prepareInsert(tup, cid, xid)
+	buf, err := rel.getBufferForTuple(tup.Len(), buffer.InvalidBuffer, bistate, 1)
+	if err != nil {
+		return err
+	}
+	if err := rel.putHeapTuple(buf, tup); err != nil {
+		return err
+	}
+	// 可視性マップはまだないため、ページの印だけを下ろす
+	page.ClearFlags(rel.buffers.BufferGetPage(buf), page.PdAllVisible)
+	rel.buffers.MarkBufferDirty(buf)
+	rel.buffers.UnlockReleaseBuffer(buf)
+	return nil
+}
+
+// multiInsertPages は tuples を全て置くのに要る空のページの数を見積もる (heap_multi_insert_pages 相当)
+func multiInsertPages(tuples []*HeapTuple) int {
+	emptyPageAvail := pgconfig.BlckSz - page.SizeOfPageHeaderData - page.ReservedPageSize
+	npages := 1
+	pageAvail := emptyPageAvail
+	for _, tup := range tuples {
+		need := page.MaxAlign(tup.Len()) + storage.SizeOfItemID
+		if need > pageAvail {
+			npages++
+			pageAvail = emptyPageAvail
+		}
+		pageAvail -= need
+	}
+	return npages
+}
+
+// MultiInsert はトランザクション xid のコマンド cid として tuples を挿入する (heap_multi_insert 相当)。
+// 1つずつ Insert するのと結果は同じだが、ページを1つロックするたびに入るだけのタプルを置き、
+// ページのロックと汚れた印をページごとに1回で済ませる。拡張する場合は、残りのタプルが要る
+// ページをまとめて足す。COPY のように大量の行を入れるために使う。
+//
+// C言語版はページごとに1つの WAL のレコードを書くが、WAL はまだないため書かない。
+func (rel *Relation) MultiInsert(tuples []*HeapTuple, cid adt.CommandId, xid adt.TransactionId, bistate *BulkInsertState) error {
+	for _, tup := range tuples {
+		rel.prepareInsert(tup, cid, xid)
+	}
+	for ndone := 0; ndone < len(tuples); {
+		// 最初のタプルは必ず置けるページを選ぶ
+		buf, err := rel.getBufferForTuple(tuples[ndone].Len(), buffer.InvalidBuffer, bistate, multiInsertPages(tuples[ndone:]))
+		if err != nil {
+			return err
+		}
+		pg := rel.buffers.BufferGetPage(buf)
+		nthispage := 0
+		for _, tup := range tuples[ndone:] {
+			if nthispage > 0 && page.GetHeapFreeSpace(pg) < page.MaxAlign(tup.Len()) {
+				break
+			}
+			if err := rel.putHeapTuple(buf, tup); err != nil {
+				return err
+			}
+			nthispage++
+		}
+		page.ClearFlags(pg, page.PdAllVisible)
+		rel.buffers.MarkBufferDirty(buf)
+		rel.buffers.UnlockReleaseBuffer(buf)
+		ndone += nthispage
+	}
+	return nil
+}
+
+// lockTupleBuffer は tid のタプルのバッファを読み、内容のロックを排他のモードで取る。tid が
+// 使っている行ポインタを指していなければ ok に false を返す。返すタプルはページの中を指す。
+func (rel *Relation) lockTupleBuffer(tid storage.ItemPointer) (buf buffer.Buffer, tup *HeapTuple, ok bool, err error) {
+	buf, err = rel.buffers.ReadBuffer(rel.Locator, tid.Block)
+	if err != nil {
+		return buffer.InvalidBuffer, nil, false, err
+	}
+	rel.buffers.LockBuffer(buf, buffer.BufferLockExclusive)
+	tup, ok = rel.getTuple(rel.buffers.BufferGetPage(buf), tid)
+	return buf, tup, ok, nil
+}
+
+// getTuple はページの tid のタプルを返す。tid が項目を指していなければ ok に false を返す。
+// 返すタプルはページの中を指す。
+func (rel *Relation) getTuple(pg []byte, tid storage.ItemPointer) (*HeapTuple, bool) {
+	if tid.Offset < storage.FirstOffsetNumber || tid.Offset > page.MaxOffsetNumber(pg) {
+		return nil, false
+	}
+	id := page.GetItemID(pg, tid.Offset)
+	if !id.IsNormal() {
+		return nil, false
+	}
+	return &HeapTuple{Self: tid, TableOid: rel.Oid, Data: HeapTupleHeader(page.GetItem(pg, id))}, true
+}
+
+// failureData は変更できなかったタプルの情報を返す
+func failureData(tup *HeapTuple, result TMResult) TMFailureData {
+	tmfd := TMFailureData{Ctid: tup.Data.Ctid(), Xmax: tup.Data.Xmax(), Cmax: adt.InvalidCommandId}
+	if result == TMSelfModified {
+		tmfd.Cmax = tup.Data.RawCommandId()
+	}
+	return tmfd
+}
+
+// setXmax は古い版に削除か更新をしたトランザクションとコマンドを書く
+func setXmax(t HeapTupleHeader, cid adt.CommandId, xid adt.TransactionId) {
+	t.SetInfomask(t.Infomask() &^ (HeapXmaxCommitted | HeapXmaxInvalid | HeapXmaxIsMulti | HeapLockMask | HeapXmaxLockOnly | HeapMoved))
+	t.SetInfomask2(t.Infomask2() &^ HeapHotUpdated)
+	t.SetXmax(xid)
+	t.SetCmax(cid)
+}
+
+// Delete はトランザクション xid のコマンド cid として tid の行を削除する (heap_delete 相当)。
+// TMOk 以外の結果ではタプルを変更せず、tmfd に理由の行の情報を返す。コマンドから見えない行は
+// エラーにする。
+func (rel *Relation) Delete(tid storage.ItemPointer, cid adt.CommandId, xid adt.TransactionId) (result TMResult, tmfd TMFailureData, err error) {
+	buf, tup, ok, err := rel.lockTupleBuffer(tid)
+	if err != nil {
+		return TMInvisible, tmfd, err
+	}
+	result = TMInvisible
+	if ok {
+		if result, err = rel.satisfiesUpdate(tup, cid, xid, buf); err != nil {
+			rel.buffers.UnlockReleaseBuffer(buf)
+			return result, tmfd, err
+		}
+	}
+	switch result {
+	case TMOk:
+	case TMInvisible:
+		rel.buffers.UnlockReleaseBuffer(buf)
+		return result, tmfd, errutil.New(errutil.Error, errcodes.ObjectNotInPrerequisiteState, "attempted to delete invisible tuple")
+	default:
+		tmfd = failureData(tup, result)
+		rel.buffers.UnlockReleaseBuffer(buf)
+		return result, tmfd, nil
+	}
+
+	setXmax(tup.Data, cid, xid)
+	tup.Data.SetInfomask2(tup.Data.Infomask2() | HeapKeysUpdated)
+	// 削除した行の t_ctid は自分自身を指したままにする
+	tup.Data.SetCtid(tup.Self)
+	rel.buffers.MarkBufferDirty(buf)
+	rel.buffers.UnlockReleaseBuffer(buf)
+	return TMOk, tmfd, nil
+}
+
+// Update はトランザクション xid のコマンド cid として otid の行を newtup に更新する
+// (heap_update 相当)。新しい版を置いた位置を newtup.Self に書く。TMOk 以外の結果の扱いは
+// Delete と同じ。
+func (rel *Relation) Update(otid storage.ItemPointer, newtup *HeapTuple, cid adt.CommandId, xid adt.TransactionId) (result TMResult, tmfd TMFailureData, err error) {
+	buf, oldtup, ok, err := rel.lockTupleBuffer(otid)
+	if err != nil {
+		return TMInvisible, tmfd, err
+	}
+	result = TMInvisible
+	if ok {
+		if result, err = rel.satisfiesUpdate(oldtup, cid, xid, buf); err != nil {
+			rel.buffers.UnlockReleaseBuffer(buf)
+			return result, tmfd, err
+		}
+	}
+	switch result {
+	case TMOk:
+	case TMInvisible:
+		rel.buffers.UnlockReleaseBuffer(buf)
+		return result, tmfd, errutil.New(errutil.Error, errcodes.ObjectNotInPrerequisiteState, "attempted to update invisible tuple")
+	default:
+		tmfd = failureData(oldtup, result)
+		rel.buffers.UnlockReleaseBuffer(buf)
+		return result, tmfd, nil
+	}
+
+	rel.prepareInsert(newtup, cid, xid)
+	newtup.Data.SetInfomask(newtup.Data.Infomask() | HeapUpdated)
+
+	newbuf := buf
+	if page.MaxAlign(newtup.Len()) > page.GetHeapFreeSpace(rel.buffers.BufferGetPage(buf)) {
+		// 新しい版を置くページを探す間は古い版のページのロックを放す。その間に他の
+		// トランザクションが古い版を変更しないよう、行のロックの印を付けておく。トランザクションが
+		// アボートすれば印は無効になる
+		t := oldtup.Data
+		t.SetInfomask(t.Infomask()&^(HeapXmaxCommitted|HeapXmaxInvalid|HeapXmaxIsMulti|HeapLockMask) | HeapXmaxExclLock | HeapXmaxLockOnly)
+		t.SetXmax(xid)
+		rel.buffers.MarkBufferDirty(buf)
+		rel.buffers.LockBuffer(buf, buffer.BufferLockUnlock)
+		if newbuf, err = rel.getBufferForTuple(newtup.Len(), buf, nil, 1); err != nil {
+			rel.buffers.ReleaseBuffer(buf)
+			return TMInvisible, tmfd, err
+		}
+	}
+	if err := rel.putHeapTuple(newbuf, newtup); err != nil {
+		return TMInvisible, tmfd, err
+	}
+	setXmax(oldtup.Data, cid, xid)
+	oldtup.Data.SetCtid(newtup.Self)
+
+	page.ClearFlags(rel.buffers.BufferGetPage(buf), page.PdAllVisible)
+	rel.buffers.MarkBufferDirty(buf)
+	if newbuf != buf {
+		page.ClearFlags(rel.buffers.BufferGetPage(newbuf), page.PdAllVisible)
+		rel.buffers.MarkBufferDirty(newbuf)
+		rel.buffers.UnlockReleaseBuffer(newbuf)
+	}
+	rel.buffers.UnlockReleaseBuffer(buf)
+	return TMOk, tmfd, nil
+}
+
+// Fetch は tid のタプルがトランザクション curxid の snapshot から見えれば、その写しを返す
+// (heap_fetch 相当)。tid が行を指していないか、行が見えなければ ok に false を返す。
+func (rel *Relation) Fetch(tid storage.ItemPointer, snapshot *snapmgr.Snapshot, curxid adt.TransactionId) (tup *HeapTuple, ok bool, err error) {
+	tup, visible, err := rel.fetch(tid, snapshot, curxid)
+	if err != nil || !visible {
+		return nil, false, err
+	}
+	return tup, true, nil
+}
+
+// fetch は tid のタプルの写しと、curxid の snapshot から見えるかを返す。tid が行を指して
+// いなければ nil を返す。
+func (rel *Relation) fetch(tid storage.ItemPointer, snapshot *snapmgr.Snapshot, curxid adt.TransactionId) (tup *HeapTuple, visible bool, err error) {
+	nblocks, err := smgr.Nblocks(rel.Locator, storage.MainForkNum)
+	if err != nil || tid.Block >= nblocks {
+		return nil, false, err
+	}
+	buf, err := rel.buffers.ReadBuffer(rel.Locator, tid.Block)
+	if err != nil {
+		return nil, false, err
+	}
+	rel.buffers.LockBuffer(buf, buffer.BufferLockShare)
+	defer rel.buffers.UnlockReleaseBuffer(buf)
+	tup, ok := rel.getTuple(rel.buffers.BufferGetPage(buf), tid)
+	if !ok {
+		return nil, false, nil
+	}
+	visible = true
+	if snapshot != nil {
+		if visible, err = rel.satisfiesMVCC(tup, snapshot, curxid, buf); err != nil {
+			return nil, false, err
+		}
+	}
+	return tup.Copy(), visible, nil
+}
+
+// GetLatestTid は tid の行から t_ctid を辿り、トランザクション curxid の snapshot から見える
+// 最も新しい版の位置を返す (heap_get_latest_tid 相当)。見える版がなければ tid をそのまま返す。
+// 次の版の t_xmin が前の版の t_xmax と異なれば、VACUUM が消した後に別の行が置かれたものとして
+// 辿るのを止める。
+func (rel *Relation) GetLatestTid(tid storage.ItemPointer, snapshot *snapmgr.Snapshot, curxid adt.TransactionId) (storage.ItemPointer, error) {
+	latest := tid
+	priorXmax := transam.InvalidTransactionId
+	for {
+		tup, visible, err := rel.fetch(tid, snapshot, curxid)
+		if err != nil {
+			return storage.InvalidItemPointer, err
+		}
+		if tup == nil || transam.TransactionIdIsValid(priorXmax) && tup.Data.Xmin() != priorXmax {
+			return latest, nil
+		}
+		if visible {
+			latest = tid
+		}
+		t := tup.Data
+		if t.Infomask()&HeapXmaxInvalid != 0 || t.XmaxIsLockedOnly() || t.Ctid() == tid {
+			return latest, nil
+		}
+		priorXmax = t.Xmax()
+		tid = t.Ctid()
+	}
+}
+
+// Scan はリレーションのシーケンシャルスキャン (HeapScanDescData 相当)
+type Scan struct {
+	rel *Relation
+	// nblocks はスキャンを始めたときのページ数。後から足したページは読まない (rs_nblocks 相当)
+	nblocks storage.BlockNumber
+	// startBlock は最初に読むページ (rs_startblock 相当)
+	startBlock storage.BlockNumber
+	// block は読んでいるページ。まだ読んでいなければ InvalidBlockNumber (rs_cblock 相当)
+	block    storage.BlockNumber
+	strategy *buffer.BufferAccessStrategy
+	// sync は他のスキャンと読む位置を合わせるか
+	sync bool
+	// snapshot は行を選ぶスナップショットで、curxid はスキャンするトランザクション。snapshot が
+	// nil なら全ての版を返す
+	snapshot *snapmgr.Snapshot
+	curxid   adt.TransactionId
+	// tuples は読んでいるページのタプルの写しで、next が次に返す位置 (rs_vistuples 相当)
+	tuples []*HeapTuple
+	next   int
+	done   bool
+}
+
+// BeginScan はシーケンシャルスキャンを始める (heap_beginscan と initscan 相当)。共有バッファの
+// 1/4 より大きいリレーションは、共有バッファを追い出さないようリングのバッファで読む。
+// allowSync が真なら、大きいリレーションは他のスキャンが読んでいる位置から読み始める。
+// synchronize_seqscans を確かめるのは呼び出し元が行う。トランザクション curxid の snapshot から
+// 見える版だけを返す。
+func (rel *Relation) BeginScan(snapshot *snapmgr.Snapshot, curxid adt.TransactionId, allowSync bool) (*Scan, error) {
+	nblocks, err := smgr.Nblocks(rel.Locator, storage.MainForkNum)
+	if err != nil {
+		return nil, err
+	}
+	scan := &Scan{rel: rel, nblocks: nblocks, block: storage.InvalidBlockNumber, snapshot: snapshot, curxid: curxid}
+	if int(nblocks) > guc.SharedBuffers.Get()/4 {
+		scan.strategy = buffer.GetAccessStrategy(buffer.BASBulkRead)
+		if allowSync {
+			scan.sync = true
+			scan.startBlock = GetLocation(rel.Locator, nblocks)
+		}
+	}
+	return scan, nil
+}
+
+// Next は次のタプルの写しを返す (heap_getnext 相当)。終わりに達したら nil を返す。
+func (scan *Scan) Next() (*HeapTuple, error) {
+	for {
+		if scan.next < len(scan.tuples) {
+			tup := scan.tuples[scan.next]
+			scan.next++
+			return tup, nil
+		}
+		if scan.done {
+			return nil, nil
+		}
+		blk, ok := scan.nextBlock()
+		if !ok {
+			scan.done = true
+			scan.tuples = nil
+			return nil, nil
+		}
+		if err := scan.getPage(blk); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// nextBlock は次に読むページを返す (heapgettup_initial_block と heapgettup_advance_block 相当)。
+// 末尾まで読んだら先頭に戻り、最初に読んだページに戻ったら ok に false を返す。
+func (scan *Scan) nextBlock() (storage.BlockNumber, bool) {
+	if scan.block == storage.InvalidBlockNumber {
+		if scan.nblocks == 0 {
+			return storage.InvalidBlockNumber, false
+		}
+		return scan.startBlock, true
+	}
+	blk := scan.block + 1
+	if blk >= scan.nblocks {
+		blk = 0
+	}
+	if scan.sync {
+		ReportLocation(scan.rel.Locator, blk)
+	}
+	if blk == scan.startBlock {
+		return storage.InvalidBlockNumber, false
+	}
+	return blk, true
+}
+
+// getPage はページ blk の見えるタプルを写して scan.tuples に置く (heap_prepare_pagescan と
+// page_collect_tuples 相当)。C言語版はピンを付けたままのページの中を指すが、他のバックエンドが
+// ヘッダを書き換えている間に読まないよう、内容のロックを持つ間に写してピンを外す。
+func (scan *Scan) getPage(blk storage.BlockNumber) error {
+	b := scan.rel.buffers
+	buf, err := b.ReadBufferExtended(scan.rel.Locator, storage.MainForkNum, blk, buffer.RBMNormal, scan.strategy)
+	if err != nil {
+		return err
+	}
+	b.LockBuffer(buf, buffer.BufferLockShare)
+	pg := b.BufferGetPage(buf)
+	scan.tuples = scan.tuples[:0]
+	scan.next = 0
+	for off := storage.FirstOffsetNumber; off <= page.MaxOffsetNumber(pg); off++ {
+		tup, ok := scan.rel.getTuple(pg, storage.ItemPointer{Block: blk, Offset: off})
+		if !ok {
+			continue
+		}
+		if scan.snapshot != nil {
+			visible, err := scan.rel.satisfiesMVCC(tup, scan.snapshot, scan.curxid, buf)
+			if err != nil {
+				b.UnlockReleaseBuffer(buf)
+				return err
+			}
+			if !visible {
+				continue
+			}
+		}
+		scan.tuples = append(scan.tuples, tup.Copy())
+	}
+	b.UnlockReleaseBuffer(buf)
+	scan.block = blk
+	return nil
+}
@@ -0,0 +1,221 @@
+package heap
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/buffer"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/procarray"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/snapmgr"
+)
+
+// ----------------------------------------------------------------
+// 行の可視性の判定 (access/heap/heapam_visibility.c 相当)
+// ----------------------------------------------------------------
+// 行の t_xmin と t_xmax のトランザクションの状態から、行がスナップショットから見えるか
+// (HeapTupleSatisfiesMVCC)、削除と更新をするコマンドが変更できるか (HeapTupleSatisfiesUpdate) を
+// 判定する。読み取りはスナップショットだけで判定するため、実行中のトランザクションが変更した
+// 行も待たずに読め、読み取りが書き込みを妨げることもない。
+//
+// トランザクションの状態を transam で引いて、コミットかアボートかが分かったら t_infomask に
+// ヒントビットを書き、次からは引かずに済ませる。ヒントビットは失っても引き直せばよいため、
+// 内容のロックを共有のモードで持つ間に書き、MarkBufferDirtyHint で汚れた印を付ける。
+// 実行中のトランザクションはヒントビットを書かない。
+//
+// コンボコマンド ID はまだないため、同じトランザクションが挿入して削除した行の t_cid は cmax
+// だけを持つ。そのような行は、cmin がスナップショットのコマンドより前だったものとして扱う。
+//
+// マルチトランザクション ID とサブトランザクションはまだないため、t_xmax は常に1つの
+// トップレベルのトランザクションを表す。
+
+// TMResult は行を変更しようとした結果 (TM_Result 相当)
+type TMResult int
+
+const (
+	// TMOk は行を変更できる (変更した) ことを表す
+	TMOk TMResult = iota
+	// TMInvisible は行がコマンドから見えないことを表す
+	TMInvisible
+	// TMSelfModified は同じトランザクションの現在のコマンドか後のコマンドが既に変更したことを表す
+	TMSelfModified
+	// TMUpdated は他のトランザクションが更新してコミットしたことを表す
+	TMUpdated
+	// TMDeleted は他のトランザクションが削除してコミットしたことを表す
+	TMDeleted
+	// TMBeingModified は実行中の他のトランザクションが変更したかロックしたことを表す
+	TMBeingModified
+)
+
+// TMFailureData は変更できなかった行の情報 (TM_FailureData 相当)
+type TMFailureData struct {
+	// Ctid は更新した場合の新しい版の位置。削除した場合は行自身の位置
+	Ctid storage.ItemPointer
+	// Xmax は行を変更したトランザクション
+	Xmax adt.TransactionId
+	// Cmax は TMSelfModified の場合に、行を変更したコマンド。それ以外は InvalidCommandId
+	Cmax adt.CommandId
+}
+
+// setHintBits はタプルのヘッダにヒントビット infomask を書く (SetHintBits 相当)。タプルは
+// ページ buf の中を指し、内容のロックを共有か排他のモードで持って呼ぶ。非同期コミットはまだ
+// ないため、コミットの WAL が書き出されたかは確かめない。
+func (rel *Relation) setHintBits(t HeapTupleHeader, buf buffer.Buffer, infomask uint16) {
+	t.SetInfomask(t.Infomask() | infomask)
+	rel.buffers.MarkBufferDirtyHint(buf)
+}
+
+// satisfiesMVCC はトランザクション curxid が snapshot で行を見られるかを返す
+// (HeapTupleSatisfiesMVCC 相当)。transam で状態を引いたらヒントビットを書く。状態を引けなければ
+// エラーを返す。
+func (rel *Relation) satisfiesMVCC(tup *HeapTuple, snapshot *snapmgr.Snapshot, curxid adt.TransactionId, buf buffer.Buffer) (bool, error) {
+	t := tup.Data
+	if !t.XminCommitted() {
+		if t.XminInvalid() {
+			return false, nil
+		}
+		switch xmin := t.Xmin(); {
+		case xmin == curxid:
+			if t.Infomask()&HeapXmaxInvalid != 0 || t.XmaxIsLockedOnly() {
+				// t_cid は cmin を持つ
+				return t.RawCommandId() < snapshot.Curcid, nil
+			}
+			if t.Xmax() != curxid {
+				// 削除したサブトランザクションはアボートした
+				rel.setHintBits(t, buf, HeapXmaxInvalid)
+				return true, nil
+			}
+			// t_cid は cmax を持つ。スナップショットより後のコマンドが削除したなら見える
+			return t.RawCommandId() >= snapshot.Curcid, nil
+		case snapmgr.XidInMVCCSnapshot(xmin, snapshot):
+			return false, nil
+		default:
+			committed, err := transam.TransactionIdDidCommit(xmin)
+			if err != nil {
+				return false, err
+			}
+			if !committed {
+				// アボートしたか、実行中に異常終了した
+				rel.setHintBits(t, buf, HeapXminInvalid)
+				return false, nil
+			}
+			rel.setHintBits(t, buf, HeapXminCommitted)
+		}
+	} else if !t.XminFrozen() && snapmgr.XidInMVCCSnapshot(t.Xmin(), snapshot) {
+		// コミットしたが、スナップショットを取ったときには実行中だった
+		return false, nil
+	}
+
+	// 挿入はスナップショットから見てコミットしている
+	if t.Infomask()&HeapXmaxInvalid != 0 || t.XmaxIsLockedOnly() {
+		return true, nil
+	}
+	xmax := t.Xmax()
+	if t.Infomask()&HeapXmaxCommitted != 0 {
+		return snapmgr.XidInMVCCSnapshot(xmax, snapshot), nil
+	}
+	switch {
+	case xmax == curxid:
+		return t.RawCommandId() >= snapshot.Curcid, nil
+	case snapmgr.XidInMVCCSnapshot(xmax, snapshot):
+		return true, nil
+	}
+	committed, err := transam.TransactionIdDidCommit(xmax)
+	if err != nil {
+		return false, err
+	}
+	if !committed {
+		// 削除したトランザクションはアボートしたか、実行中に異常終了した
+		rel.setHintBits(t, buf, HeapXmaxInvalid)
+		return true, nil
+	}
+	rel.setHintBits(t, buf, HeapXmaxCommitted)
+	return false, nil
+}
+
+// satisfiesUpdate はトランザクション curxid のコマンド curcid が行を変更できるかを返す
+// (HeapTupleSatisfiesUpdate 相当)。transam で状態を引いたらヒントビットを書く。C言語版は自分の
+// トランザクションがロックしただけの行に TM_BeingModified を返すが、行のロックはまだ Update が
+// 新しい版を置く場所を探す間にしか使わないため、TMOk として扱う。状態を引けなければエラーを返す。
+func (rel *Relation) satisfiesUpdate(tup *HeapTuple, curcid adt.CommandId, curxid adt.TransactionId, buf buffer.Buffer) (TMResult, error) {
+	t := tup.Data
+	if !t.XminCommitted() {
+		if t.XminInvalid() {
+			return TMInvisible, nil
+		}
+		switch xmin := t.Xmin(); {
+		case xmin == curxid:
+			if t.Infomask()&HeapXmaxInvalid != 0 || t.XmaxIsLockedOnly() {
+				if t.RawCommandId() >= curcid {
+					// 現在のコマンドか後のコマンドが挿入した
+					return TMInvisible, nil
+				}
+				return TMOk, nil
+			}
+			if t.Xmax() != curxid {
+				// 削除したサブトランザクションはアボートした
+				rel.setHintBits(t, buf, HeapXmaxInvalid)
+				return TMOk, nil
+			}
+			if t.RawCommandId() >= curcid {
+				return TMSelfModified, nil
+			}
+			return TMInvisible, nil
+		case procarray.TransactionIdIsInProgress(xmin):
+			return TMInvisible, nil
+		default:
+			committed, err := transam.TransactionIdDidCommit(xmin)
+			if err != nil {
+				return TMInvisible, err
+			}
+			if !committed {
+				// アボートしたか、実行中に異常終了した
+				rel.setHintBits(t, buf, HeapXminInvalid)
+				return TMInvisible, nil
+			}
+			rel.setHintBits(t, buf, HeapXminCommitted)
+		}
+	}
+
+	// 挿入はコミットしている
+	if t.Infomask()&HeapXmaxInvalid != 0 {
+		return TMOk, nil
+	}
+	if t.Infomask()&HeapXmaxCommitted != 0 {
+		if t.XmaxIsLockedOnly() {
+			return TMOk, nil
+		}
+		return updatedOrDeleted(tup), nil
+	}
+	xmax := t.Xmax()
+	switch {
+	case xmax == curxid:
+		if t.XmaxIsLockedOnly() {
+			return TMOk, nil
+		}
+		if t.RawCommandId() >= curcid {
+			return TMSelfModified, nil
+		}
+		return TMInvisible, nil
+	case procarray.TransactionIdIsInProgress(xmax):
+		return TMBeingModified, nil
+	}
+	committed, err := transam.TransactionIdDidCommit(xmax)
+	if err != nil {
+		return TMInvisible, err
+	}
+	if !committed || t.XmaxIsLockedOnly() {
+		// 削除したトランザクションはアボートしたか、ロックしたトランザクションは終わった
+		rel.setHintBits(t, buf, HeapXmaxInvalid)
+		return TMOk, nil
+	}
+	rel.setHintBits(t, buf, HeapXmaxCommitted)
+	return updatedOrDeleted(tup), nil
+}
+
+// updatedOrDeleted は他のトランザクションがコミットした変更が更新か削除かを返す
+func updatedOrDeleted(tup *HeapTuple) TMResult {
+	if tup.Data.Ctid() == tup.Self {
+		return TMDeleted
+	}
+	return TMUpdated
+}
@@ -0,0 +1,225 @@
+package heap
+
+import (
+	"encoding/binary"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// タプルの組み立てと分解 (access/common/heaptuple.c 相当)
+// ----------------------------------------------------------------
+// 列の値をタプルの形式に並べ、タプルから列の値を取り出す。値は各列の型の内部表現のバイト列で
+// 渡す。固定長の列は長さちょうどのバイト列、可変長 (varlena) の列は長さのヘッダを除いた中身、
+// NUL 終端の文字列の列は NUL を除いた中身を渡す。
+//
+// 各列は型の境界 (attalign) に揃えて置く。可変長の列は、中身が 126 バイトまでなら1バイトの
+// ヘッダを付けて揃えずに置き、それより長ければ4バイトのヘッダを付けて揃える。ヘッダは
+// リトルエンディアンの並びと同じく、1バイトのヘッダは最下位のビットを立てて長さを1ビット、
+// 4バイトのヘッダは長さを2ビットずらして持つ。TOAST はまだないため、圧縮と外部化はしない。
+
+// MaxTupleAttributeNumber はタプルの列の数の上限 (MaxTupleAttributeNumber 相当)
+const MaxTupleAttributeNumber = 1664
+
+// Attribute は列1つの格納のしかた (FormData_pg_attribute の attlen と attalign 相当)
+type Attribute struct {
+	// Len は固定長の列の長さ。可変長なら -1、NUL 終端の文字列なら -2
+	Len int16
+	// Align は列を揃える境界。'c' (1バイト)、's' (2バイト)、'i' (4バイト)、'd' (8バイト)
+	Align byte
+}
+
+// TupleDesc はタプルの列の並び (TupleDescData 相当)
+type TupleDesc []Attribute
+
+// 可変長の列のヘッダ (varatt.h 相当)
+const (
+	varHdrSz       = 4    // 4バイトのヘッダの大きさ (VARHDRSZ 相当)
+	varHdrSzShort  = 1    // 1バイトのヘッダの大きさ (VARHDRSZ_SHORT 相当)
+	varattShortMax = 0x7F // 1バイトのヘッダで表せる長さの上限 (VARATT_SHORT_MAX 相当)
+)
+
+// alignOffset は off を列の境界に切り上げる (att_align_nominal 相当)
+func alignOffset(off int, align byte) int {
+	var n int
+	switch align {
+	case 's':
+		n = 2
+	case 'i':
+		n = 4
+	case 'd':
+		n = 8
+	default:
+		return off
+	}
+	return (off + n - 1) &^ (n - 1)
+}
+
+// canMakeShort は可変長の値を1バイトのヘッダで置けるかを返す (VARATT_CAN_MAKE_SHORT 相当)
+func canMakeShort(v []byte) bool {
+	return len(v)+varHdrSzShort <= varattShortMax
+}
+
+// computeDataSize は値を並べた部分の大きさを返す (heap_compute_data_size 相当)
+func computeDataSize(desc TupleDesc, values [][]byte, isnull []bool) int {
+	size := 0
+	for i, att := range desc {
+		if isnull[i] {
+			continue
+		}
+		switch {
+		case att.Len == -1 && canMakeShort(values[i]):
+			size += varHdrSzShort + len(values[i])
+		case att.Len == -1:
+			size = alignOffset(size, att.Align) + varHdrSz + len(values[i])
+		case att.Len == -2:
+			size = alignOffset(size, att.Align) + len(values[i]) + 1
+		default:
+			size = alignOffset(size, att.Align) + int(att.Len)
+		}
+	}
+	return size
+}
+
+// FormTuple は列の値からタプルを組み立てる (heap_form_tuple 相当)。isnull[i] が真の列は
+// NULL とし、values[i] を見ない。固定長の列の値の長さが列の長さと異なればエラーにする。
+func FormTuple(desc TupleDesc, values [][]byte, isnull []bool) (*HeapTuple, error) {
+	if len(desc) > MaxTupleAttributeNumber {
+		return nil, errutil.New(errutil.Error, errcodes.TooManyColumns,
+			"number of columns (%d) exceeds limit (%d)", len(desc), MaxTupleAttributeNumber)
+	}
+	hasnull := false
+	for i, att := range desc {
+		if isnull[i] {
+			hasnull = true
+		} else if att.Len > 0 && len(values[i]) != int(att.Len) {
+			return nil, errutil.Elog(errutil.Error, "wrong length %d for attribute %d of length %d", len(values[i]), i+1, att.Len)
+		}
+	}
+
+	hoff := SizeofHeapTupleHeader
+	if hasnull {
+		hoff += (len(desc) + 7) / 8
+	}
+	hoff = alignOffset(hoff, 'd')
+	data := make(HeapTupleHeader, hoff+computeDataSize(desc, values, isnull))
+	data[tHoffOffset] = byte(hoff)
+	data.SetNatts(len(desc))
+	data.SetCtid(storage.InvalidItemPointer)
+	data.SetInfomask(fillTuple(desc, values, isnull, data[hoff:], data[tBitsOffset:hoff], hasnull))
+	return &HeapTuple{Self: storage.InvalidItemPointer, Data: data}, nil
+}
+
+// fillTuple は値を buf に並べ、NULL のビットマップを bits に書き、t_infomask のビットを返す
+// (heap_fill_tuple 相当)。hasnull が偽なら bits を書かない。
+func fillTuple(desc TupleDesc, values [][]byte, isnull []bool, buf, bits []byte, hasnull bool) uint16 {
+	var infomask uint16
+	if hasnull {
+		infomask |= HeapHasNull
+	}
+	off := 0
+	for i, att := range desc {
+		if isnull[i] {
+			continue
+		}
+		if hasnull {
+			bits[i/8] |= 1 << (i % 8)
+		}
+		v := values[i]
+		switch {
+		case att.Len == -1 && canMakeShort(v):
+			infomask |= HeapHasVarWidth
+			buf[off] = byte((varHdrSzShort+len(v))<<1 | 0x01)
+			off += varHdrSzShort + copy(buf[off+varHdrSzShort:], v)
+		case att.Len == -1:
+			infomask |= HeapHasVarWidth
+			off = alignOffset(off, att.Align)
+			binary.LittleEndian.PutUint32(buf[off:], uint32(varHdrSz+len(v))<<2)
+			off += varHdrSz + copy(buf[off+varHdrSz:], v)
+		case att.Len == -2:
+			infomask |= HeapHasVarWidth
+			off = alignOffset(off, att.Align)
+			off += copy(buf[off:], v) + 1
+		default:
+			off = alignOffset(off, att.Align)
+			off += copy(buf[off:], v)
+		}
+	}
+	return infomask
+}
+
+// DeformTuple はタプルから列の値を取り出す (heap_deform_tuple 相当)。返す値はタプルの内容を
+// 指す。タプルの列が desc より少なければ、足りない列は NULL とする (ALTER TABLE ADD COLUMN で
+// 後から加えた列に当たる)。
+func DeformTuple(desc TupleDesc, tup *HeapTuple) (values [][]byte, isnull []bool, err error) {
+	t := tup.Data
+	values = make([][]byte, len(desc))
+	isnull = make([]bool, len(desc))
+	natts := min(t.Natts(), len(desc))
+	hasnull := t.HasNulls()
+	var bits []byte
+	if hasnull {
+		bits = t.Bits()
+	}
+	data := t[t.Hoff():]
+	off := 0
+	for i := range desc {
+		if i >= natts || hasnull && bits[i/8]&(1<<(i%8)) == 0 {
+			isnull[i] = true
+			continue
+		}
+		att := desc[i]
+		var start, end int
+		switch {
+		case att.Len == -1:
+			// 揃えの詰め物は 0 のため、0 でないバイトは1バイトのヘッダの始まりになる
+			// (att_align_pointer 相当)
+			if off < len(data) && data[off] == 0 {
+				off = alignOffset(off, att.Align)
+			}
+			if off >= len(data) {
+				return nil, nil, errCorruptedTuple(tup)
+			}
+			if data[off]&0x01 != 0 {
+				if data[off] == 0x01 {
+					return nil, nil, errutil.Elog(errutil.Error, "unexpected external TOAST pointer in tuple %s", tup.Self)
+				}
+				start, end = off+varHdrSzShort, off+int(data[off]>>1)
+			} else {
+				if off+varHdrSz > len(data) {
+					return nil, nil, errCorruptedTuple(tup)
+				}
+				start, end = off+varHdrSz, off+int(binary.LittleEndian.Uint32(data[off:])>>2)
+			}
+			if end < start || end > len(data) {
+				return nil, nil, errCorruptedTuple(tup)
+			}
+			off = end
+		case att.Len == -2:
+			off = alignOffset(off, att.Align)
+			start = off
+			for end = start; end < len(data) && data[end] != 0; end++ {
+			}
+			if end >= len(data) {
+				return nil, nil, errCorruptedTuple(tup)
+			}
+			off = end + 1
+		default:
+			off = alignOffset(off, att.Align)
+			start, end = off, off+int(att.Len)
+			if end > len(data) {
+				return nil, nil, errCorruptedTuple(tup)
+			}
+			off = end
+		}
+		values[i] = data[start:end:end]
+	}
+	return values, isnull, nil
+}
+
+// errCorruptedTuple は列の値がタプルの終わりを超えていることを表す
+func errCorruptedTuple(tup *HeapTuple) error {
+	return errutil.New(errutil.Error, errcodes.DataCorrupted, "invalid attribute length in tuple %s", tup.Self)
+}
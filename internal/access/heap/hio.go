@@ -0,0 +1,246 @@
+package heap
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/buffer"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/page"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/smgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// タプルを置くページの選択 (access/heap/hio.c 相当)
+// ----------------------------------------------------------------
+// 空き領域マップ (FSM) はまだないため、最後に挿入したページか、リレーションの最後のページだけを
+// 試す。空きが足りなければ、リレーションの拡張のロックを取ってページを1つ足す。拡張は先に
+// ファイルを 0 のページで伸ばしてから、共有バッファに 0 で埋めたページを読まずに割り当てる。
+//
+// 更新では古い版のページ (otherBuffer) と新しい版のページの両方をロックする。2つの
+// バックエンドが逆の順にロックして互いに待たないよう、ブロック番号の小さい方から取る。
+//
+// 大量の挿入 (BulkInsertState) では、共有バッファを追い出さないようリングのバッファで読み書きし、
+// 最後に使ったページのピンを持ち続けて読み直さずに済ませる。拡張も、残りのタプルが要るだけの
+// ページをまとめて足し、足したページを順に使う。FSM がないため、まとめて足したページは
+// BulkInsertState を閉じた後は最後のページしか使われない。
+
+// extensionLockTag はリレーションの拡張のロックの対象 (SET_LOCKTAG_RELATION_EXTEND 相当)
+func (rel *Relation) extensionLockTag() lmgr.LockTag {
+	return lmgr.LockTag{Field1: uint32(rel.Locator.DbOid), Field2: uint32(rel.Oid), Type: lmgr.LockTagRelationExtend}
+}
+
+// putHeapTuple はロックしたバッファのページにタプルを置き、tup.Self と t_ctid に位置を書く
+// (RelationPutHeapTuple 相当)。空きがあることは呼び出し元が確かめておく。
+func (rel *Relation) putHeapTuple(buf buffer.Buffer, tup *HeapTuple) error {
+	pg := rel.buffers.BufferGetPage(buf)
+	off, err := page.AddItem(pg, tup.Data, storage.InvalidOffsetNumber, page.AddItemIsHeap)
+	if err != nil {
+		return err
+	}
+	if off == storage.InvalidOffsetNumber {
+		return errutil.Elog(errutil.Panic, "failed to add tuple to page")
+	}
+	tup.Self = storage.ItemPointer{Block: rel.buffers.BufferGetBlockNumber(buf), Offset: off}
+	tup.Data.SetCtid(tup.Self)
+	HeapTupleHeader(page.GetItem(pg, page.GetItemID(pg, off))).SetCtid(tup.Self)
+	return nil
+}
+
+// maxBuffersToExtendBy は一度に足すページの数の上限 (MAX_BUFFERS_TO_EXTEND_BY 相当)
+const maxBuffersToExtendBy = 64
+
+// BulkInsertState は大量の挿入の状態 (BulkInsertStateData 相当)。GetBulkInsertState で作り、
+// 終えたら Free で放す。
+type BulkInsertState struct {
+	buffers  *buffer.Backend
+	strategy *buffer.BufferAccessStrategy
+	// current は最後に使ったページのバッファで、このためのピンを持つ (current_buf 相当)
+	current buffer.Buffer
+	// nextFree と lastFree はまとめて足したページのうち、まだ使っていないものの範囲
+	// (next_free、last_free 相当)
+	nextFree, lastFree storage.BlockNumber
+}
+
+// GetBulkInsertState は大量の挿入の状態を作る (GetBulkInsertState 相当)
+func (rel *Relation) GetBulkInsertState() *BulkInsertState {
+	return &BulkInsertState{
+		buffers:  rel.buffers,
+		strategy: buffer.GetAccessStrategy(buffer.BASBulkWrite),
+		current:  buffer.InvalidBuffer,
+		nextFree: storage.InvalidBlockNumber,
+		lastFree: storage.InvalidBlockNumber,
+	}
+}
+
+// ReleasePin は持っているページのピンを外す (ReleaseBulkInsertStatePin 相当)。同じ状態で
+// 別のリレーションに挿入する前に呼ぶ。
+func (bistate *BulkInsertState) ReleasePin() {
+	if bistate.current != buffer.InvalidBuffer {
+		bistate.buffers.ReleaseBuffer(bistate.current)
+		bistate.current = buffer.InvalidBuffer
+	}
+	// 次に別のリレーションに挿入するとき、このリレーションに足したページの範囲を使わないよう、
+	// まとめて足したページの範囲も忘れる
+	bistate.nextFree = storage.InvalidBlockNumber
+	bistate.lastFree = storage.InvalidBlockNumber
+}
+
+// Free は大量の挿入の状態を放す (FreeBulkInsertState 相当)
+func (bistate *BulkInsertState) Free() {
+	bistate.ReleasePin()
+}
+
+// readBufferBI はブロック blk のバッファを読む (ReadBufferBI 相当)。bistate があれば
+// リングのバッファで読み、持っているピンのページならそれを使う。
+func (rel *Relation) readBufferBI(blk storage.BlockNumber, mode buffer.ReadBufferMode, bistate *BulkInsertState) (buffer.Buffer, error) {
+	if bistate == nil {
+		return rel.buffers.ReadBufferExtended(rel.Locator, storage.MainForkNum, blk, mode, nil)
+	}
+	if bistate.current != buffer.InvalidBuffer {
+		if rel.buffers.BufferGetBlockNumber(bistate.current) == blk {
+			rel.buffers.IncrBufferRefCount(bistate.current)
+			return bistate.current, nil
+		}
+		rel.buffers.ReleaseBuffer(bistate.current)
+		bistate.current = buffer.InvalidBuffer
+	}
+	buf, err := rel.buffers.ReadBufferExtended(rel.Locator, storage.MainForkNum, blk, mode, bistate.strategy)
+	if err != nil {
+		return buffer.InvalidBuffer, err
+	}
+	rel.buffers.IncrBufferRefCount(buf)
+	bistate.current = buf
+	return buf, nil
+}
+
+// getBufferForTuple は length バイトのタプルを置けるページのバッファを、内容のロックを排他の
+// モードで取って返す (RelationGetBufferForTuple 相当)。otherBuffer が InvalidBuffer でなければ、
+// そのバッファもロックして返す。返したバッファが otherBuffer と同じなら、ロックは1つだけ取る。
+// 拡張する場合は、bistate があれば numPages までのページをまとめて足す。
+func (rel *Relation) getBufferForTuple(length int, otherBuffer buffer.Buffer, bistate *BulkInsertState, numPages int) (buffer.Buffer, error) {
+	length = page.MaxAlign(length)
+	if maxSize := MaxHeapTupleSize - page.ReservedPageSize; length > maxSize {
+		return buffer.InvalidBuffer, errutil.New(errutil.Error, errcodes.ProgramLimitExceeded,
+			"row is too big: size %d, maximum size %d", length, maxSize)
+	}
+
+	target := rel.targetBlock
+	if bistate != nil && bistate.current != buffer.InvalidBuffer {
+		target = rel.buffers.BufferGetBlockNumber(bistate.current)
+	}
+	if target == storage.InvalidBlockNumber {
+		nblocks, err := smgr.Nblocks(rel.Locator, storage.MainForkNum)
+		if err != nil {
+			return buffer.InvalidBuffer, err
+		}
+		if nblocks > 0 {
+			target = nblocks - 1
+		}
+	}
+	for target != storage.InvalidBlockNumber {
+		buf, err := rel.lockBuffersForTuple(target, otherBuffer, bistate)
+		if err != nil {
+			return buffer.InvalidBuffer, err
+		}
+		if length <= page.GetHeapFreeSpace(rel.buffers.BufferGetPage(buf)) {
+			rel.targetBlock = target
+			return buf, nil
+		}
+		// 空きが足りない。次のページを試す間や拡張のロックを待つ間はバッファのロックを持たない
+		if otherBuffer != buffer.InvalidBuffer && otherBuffer != buf {
+			rel.buffers.LockBuffer(otherBuffer, buffer.BufferLockUnlock)
+		}
+		if buf == otherBuffer {
+			rel.buffers.LockBuffer(buf, buffer.BufferLockUnlock)
+		} else {
+			rel.buffers.UnlockReleaseBuffer(buf)
+		}
+
+		// まとめて足したページが残っていれば、次はそれを試す
+		target = storage.InvalidBlockNumber
+		if bistate != nil && bistate.nextFree != storage.InvalidBlockNumber {
+			target = bistate.nextFree
+			if bistate.nextFree++; bistate.nextFree > bistate.lastFree {
+				bistate.nextFree = storage.InvalidBlockNumber
+				bistate.lastFree = storage.InvalidBlockNumber
+			}
+		}
+	}
+	return rel.extend(length, otherBuffer, bistate, numPages)
+}
+
+// lockBuffersForTuple はブロック target のバッファと otherBuffer をブロック番号の順にロックし、
+// target のバッファを返す。まだ初期化していないページは初期化する。
+func (rel *Relation) lockBuffersForTuple(target storage.BlockNumber, otherBuffer buffer.Buffer, bistate *BulkInsertState) (buffer.Buffer, error) {
+	b := rel.buffers
+	var buf buffer.Buffer
+	if otherBuffer != buffer.InvalidBuffer && b.BufferGetBlockNumber(otherBuffer) == target {
+		buf = otherBuffer
+		b.LockBuffer(buf, buffer.BufferLockExclusive)
+	} else {
+		var err error
+		if buf, err = rel.readBufferBI(target, buffer.RBMNormal, bistate); err != nil {
+			return buffer.InvalidBuffer, err
+		}
+		if otherBuffer != buffer.InvalidBuffer && b.BufferGetBlockNumber(otherBuffer) < target {
+			b.LockBuffer(otherBuffer, buffer.BufferLockExclusive)
+			b.LockBuffer(buf, buffer.BufferLockExclusive)
+		} else {
+			b.LockBuffer(buf, buffer.BufferLockExclusive)
+			if otherBuffer != buffer.InvalidBuffer {
+				b.LockBuffer(otherBuffer, buffer.BufferLockExclusive)
+			}
+		}
+	}
+	// まとめて足したページと、拡張した後の初期化の前に異常終了したページは 0 のまま残る
+	if pg := b.BufferGetPage(buf); page.IsNew(pg) {
+		page.Init(pg, 0)
+		b.MarkBufferDirty(buf)
+	}
+	return buf, nil
+}
+
+// extend はリレーションにページを足し、最初のページのバッファを内容のロックを排他のモードで
+// 取って返す (RelationAddBlocks 相当)。otherBuffer もロックする。足すページは otherBuffer より
+// 後ろにあるため、otherBuffer を先にロックする。bistate があれば numPages までのページを
+// まとめて足し、残りのページを bistate.nextFree から使う。
+func (rel *Relation) extend(length int, otherBuffer buffer.Buffer, bistate *BulkInsertState, numPages int) (buffer.Buffer, error) {
+	extendBy := 1
+	if bistate != nil {
+		extendBy = min(max(numPages, 1), maxBuffersToExtendBy)
+	}
+
+	tag := rel.extensionLockTag()
+	if _, err := rel.proc.LockAcquire(tag, lmgr.ExclusiveLock, false, false); err != nil {
+		return buffer.InvalidBuffer, err
+	}
+	defer rel.proc.LockRelease(tag, lmgr.ExclusiveLock, false)
+
+	nblocks, err := smgr.Nblocks(rel.Locator, storage.MainForkNum)
+	if err != nil {
+		return buffer.InvalidBuffer, err
+	}
+	if err := smgr.ZeroExtend(rel.Locator, storage.MainForkNum, nblocks, extendBy); err != nil {
+		return buffer.InvalidBuffer, err
+	}
+	if otherBuffer != buffer.InvalidBuffer {
+		rel.buffers.LockBuffer(otherBuffer, buffer.BufferLockExclusive)
+	}
+	buf, err := rel.readBufferBI(nblocks, buffer.RBMZeroAndLock, bistate)
+	if err != nil {
+		return buffer.InvalidBuffer, err
+	}
+	pg := rel.buffers.BufferGetPage(buf)
+	page.Init(pg, 0)
+	rel.buffers.MarkBufferDirty(buf)
+	if length > page.GetHeapFreeSpace(pg) {
+		return buffer.InvalidBuffer, errutil.Elog(errutil.Error, "tuple is too big: size %d", length)
+	}
+	if extendBy > 1 {
+		bistate.nextFree = nblocks + 1
+		bistate.lastFree = nblocks + storage.BlockNumber(extendBy) - 1
+	}
+	rel.targetBlock = nblocks
+	return buf, nil
+}
@@ -0,0 +1,230 @@
+package heap
+
+import (
+	"encoding/binary"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/page"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// ヒープタプルの形式 (access/htup.h, access/htup_details.h 相当)
+// ----------------------------------------------------------------
+// ヒープのページの項目はタプルヘッダ (HeapTupleHeaderData) で始まり、NULL のビットマップと
+// 揃えのための詰め物の後ろに列の値が続く。値は t_hoff から始まる。
+//
+//	+--------+--------+-------+--------+-------------+------------+--------+---------+------+
+//	| t_xmin | t_xmax | t_cid | t_ctid | t_infomask2 | t_infomask | t_hoff | t_bits  | 値.. |
+//	| 4      | 4      | 4     | 6      | 2           | 2          | 1      | 可変長  |      |
+//	+--------+--------+-------+--------+-------------+------------+--------+---------+------+
+//
+// t_xmin は行を挿入したトランザクション、t_xmax は削除または更新したトランザクションで、
+// t_cid はそのトランザクションの中のコマンドの番号を持つ。t_ctid は自分自身か、更新した場合は
+// 新しい版を指し、更新を重ねた版を辿れる。
+//
+// 各欄はページヘッダと同じくリトルエンディアンで持つ。コンボコマンド ID はまだないため、
+// 同じトランザクションが挿入した行を削除すると、t_cid の cmin を cmax で上書きする。
+
+// タプルヘッダの各欄の位置 (HeapTupleHeaderData 相当)
+const (
+	tXminOffset      = 0
+	tXmaxOffset      = 4
+	tCidOffset       = 8
+	tCtidOffset      = 12
+	tInfomask2Offset = 18
+	tInfomaskOffset  = 20
+	tHoffOffset      = 22
+	tBitsOffset      = 23
+
+	// SizeofHeapTupleHeader は NULL のビットマップを除いたタプルヘッダの大きさ
+	// (SizeofHeapTupleHeader 相当)
+	SizeofHeapTupleHeader = 23
+)
+
+// t_infomask のビット (HEAP_* 相当)
+const (
+	HeapHasNull        = 0x0001 // NULL の列がある
+	HeapHasVarWidth    = 0x0002 // 可変長の列がある
+	HeapHasExternal    = 0x0004 // TOAST した列がある
+	HeapXmaxKeyshrLock = 0x0010 // t_xmax は FOR KEY SHARE のロック
+	HeapComboCid       = 0x0020 // t_cid はコンボコマンド ID
+	HeapXmaxExclLock   = 0x0040 // t_xmax は排他のロック
+	HeapXmaxLockOnly   = 0x0080 // t_xmax は行のロックだけで、削除も更新もしていない
+	HeapXminCommitted  = 0x0100 // t_xmin はコミットした
+	HeapXminInvalid    = 0x0200 // t_xmin は無効かアボートした
+	HeapXmaxCommitted  = 0x0400 // t_xmax はコミットした
+	HeapXmaxInvalid    = 0x0800 // t_xmax は無効かアボートした
+	HeapXmaxIsMulti    = 0x1000 // t_xmax はマルチトランザクション ID
+	HeapUpdated        = 0x2000 // 行を更新して作った新しい版
+	HeapMovedOff       = 0x4000 // 9.0 より前の VACUUM FULL が動かした元の版
+	HeapMovedIn        = 0x8000 // 9.0 より前の VACUUM FULL が動かした先の版
+
+	HeapXminFrozen = HeapXminCommitted | HeapXminInvalid
+	HeapLockMask   = HeapXmaxExclLock | HeapXmaxKeyshrLock
+	HeapMoved      = HeapMovedOff | HeapMovedIn
+	// HeapXactMask はトランザクションの状態に関するビット (HEAP_XACT_MASK 相当)
+	HeapXactMask = 0xFFF0
+)
+
+// t_infomask2 のビット (HEAP_* 相当)
+const (
+	// HeapNattsMask は列の数を持つビット (HEAP_NATTS_MASK 相当)
+	HeapNattsMask = 0x07FF
+	// HeapKeysUpdated はキーの列を変更する更新か削除をしたことを表す
+	HeapKeysUpdated = 0x2000
+	// HeapHotUpdated はインデックスを更新しない更新 (HOT) をしたことを表す
+	HeapHotUpdated = 0x4000
+	// HeapOnlyTuple は HOT で作った版で、インデックスから直接は指されないことを表す
+	HeapOnlyTuple = 0x8000
+
+	// Heap2XactMask はトランザクションの状態に関するビット (HEAP2_XACT_MASK 相当)
+	Heap2XactMask = 0xE000
+)
+
+// MaxHeapTupleSize はヒープのページに置けるタプルの大きさの上限 (MaxHeapTupleSize 相当)。
+// 空のページの行ポインタ1つ分の後ろの空き領域に等しい。ファイル暗号化が有効なクラスタでは、
+// ページの末尾に空ける page.ReservedPageSize だけ小さくなる。
+const MaxHeapTupleSize = pgconfig.BlckSz - (page.SizeOfPageHeaderData+storage.SizeOfItemID+page.MaximumAlignof-1)&^(page.MaximumAlignof-1)
+
+// HeapTupleHeader はタプルヘッダで始まるタプルの内容 (HeapTupleHeaderData 相当)
+type HeapTupleHeader []byte
+
+// Xmin は行を挿入したトランザクションを返す (HeapTupleHeaderGetRawXmin 相当)
+func (t HeapTupleHeader) Xmin() adt.TransactionId {
+	return adt.TransactionId(binary.LittleEndian.Uint32(t[tXminOffset:]))
+}
+
+// SetXmin は行を挿入したトランザクションを書く (HeapTupleHeaderSetXmin 相当)
+func (t HeapTupleHeader) SetXmin(xid adt.TransactionId) {
+	binary.LittleEndian.PutUint32(t[tXminOffset:], uint32(xid))
+}
+
+// Xmax は行を削除、更新、ロックしたトランザクションを返す (HeapTupleHeaderGetRawXmax 相当)
+func (t HeapTupleHeader) Xmax() adt.TransactionId {
+	return adt.TransactionId(binary.LittleEndian.Uint32(t[tXmaxOffset:]))
+}
+
+// SetXmax は行を削除、更新、ロックしたトランザクションを書く (HeapTupleHeaderSetXmax 相当)
+func (t HeapTupleHeader) SetXmax(xid adt.TransactionId) {
+	binary.LittleEndian.PutUint32(t[tXmaxOffset:], uint32(xid))
+}
+
+// RawCommandId は t_cid をそのまま返す (HeapTupleHeaderGetRawCommandId 相当)。xmax を
+// 書いていなければ cmin、書いていれば cmax になる。
+func (t HeapTupleHeader) RawCommandId() adt.CommandId {
+	return adt.CommandId(binary.LittleEndian.Uint32(t[tCidOffset:]))
+}
+
+// SetCmin は行を挿入したコマンドを書く (HeapTupleHeaderSetCmin 相当)
+func (t HeapTupleHeader) SetCmin(cid adt.CommandId) {
+	binary.LittleEndian.PutUint32(t[tCidOffset:], uint32(cid))
+	t.SetInfomask(t.Infomask() &^ HeapComboCid)
+}
+
+// SetCmax は行を削除、更新したコマンドを書く (HeapTupleHeaderSetCmax 相当)
+func (t HeapTupleHeader) SetCmax(cid adt.CommandId) {
+	binary.LittleEndian.PutUint32(t[tCidOffset:], uint32(cid))
+	t.SetInfomask(t.Infomask() &^ HeapComboCid)
+}
+
+// Ctid は行の新しい版か、新しい版がなければ自分自身の位置を返す
+func (t HeapTupleHeader) Ctid() storage.ItemPointer {
+	return storage.GetItemPointer(t[tCtidOffset:])
+}
+
+// SetCtid は t_ctid を書く
+func (t HeapTupleHeader) SetCtid(tid storage.ItemPointer) {
+	storage.PutItemPointer(t[tCtidOffset:], tid)
+}
+
+// Infomask は t_infomask を返す
+func (t HeapTupleHeader) Infomask() uint16 {
+	return binary.LittleEndian.Uint16(t[tInfomaskOffset:])
+}
+
+// SetInfomask は t_infomask を書く
+func (t HeapTupleHeader) SetInfomask(mask uint16) {
+	binary.LittleEndian.PutUint16(t[tInfomaskOffset:], mask)
+}
+
+// Infomask2 は t_infomask2 を返す
+func (t HeapTupleHeader) Infomask2() uint16 {
+	return binary.LittleEndian.Uint16(t[tInfomask2Offset:])
+}
+
+// SetInfomask2 は t_infomask2 を書く
+func (t HeapTupleHeader) SetInfomask2(mask uint16) {
+	binary.LittleEndian.PutUint16(t[tInfomask2Offset:], mask)
+}
+
+// Natts は列の数を返す (HeapTupleHeaderGetNatts 相当)
+func (t HeapTupleHeader) Natts() int {
+	return int(t.Infomask2() & HeapNattsMask)
+}
+
+// SetNatts は列の数を書く (HeapTupleHeaderSetNatts 相当)
+func (t HeapTupleHeader) SetNatts(natts int) {
+	t.SetInfomask2(t.Infomask2()&^HeapNattsMask | uint16(natts)&HeapNattsMask)
+}
+
+// Hoff は値の始まる位置を返す
+func (t HeapTupleHeader) Hoff() int {
+	return int(t[tHoffOffset])
+}
+
+// HasNulls は NULL の列があるかを返す (HeapTupleHasNulls 相当)
+func (t HeapTupleHeader) HasNulls() bool {
+	return t.Infomask()&HeapHasNull != 0
+}
+
+// Bits は NULL のビットマップを返す。ビットが立っている列は NULL でない
+func (t HeapTupleHeader) Bits() []byte {
+	return t[tBitsOffset:t.Hoff()]
+}
+
+// XminCommitted は t_xmin がコミットしたことをヒントビットが示すかを返す
+// (HeapTupleHeaderXminCommitted 相当)
+func (t HeapTupleHeader) XminCommitted() bool {
+	return t.Infomask()&HeapXminCommitted != 0
+}
+
+// XminInvalid は t_xmin が無効かアボートしたことをヒントビットが示すかを返す。凍結した行は
+// 両方のビットが立つため除く (HeapTupleHeaderXminInvalid 相当)
+func (t HeapTupleHeader) XminInvalid() bool {
+	return t.Infomask()&HeapXminFrozen == HeapXminInvalid
+}
+
+// XminFrozen は行を凍結し、t_xmin が全てのトランザクションから見てコミットしたものとして
+// 扱えるかを返す (HeapTupleHeaderXminFrozen 相当)
+func (t HeapTupleHeader) XminFrozen() bool {
+	return t.Infomask()&HeapXminFrozen == HeapXminFrozen
+}
+
+// XmaxIsLockedOnly は t_xmax が行のロックだけを表すかを返す (HEAP_XMAX_IS_LOCKED_ONLY 相当)
+func (t HeapTupleHeader) XmaxIsLockedOnly() bool {
+	mask := t.Infomask()
+	return mask&HeapXmaxLockOnly != 0 || mask&(HeapXmaxIsMulti|HeapLockMask) == HeapXmaxExclLock
+}
+
+// HeapTuple はメモリの中のタプル (HeapTupleData 相当)
+type HeapTuple struct {
+	// Self はタプルのページの中の位置 (t_self 相当)。まだページに置いていなければ無効
+	Self storage.ItemPointer
+	// TableOid はタプルを置いたリレーション (t_tableOid 相当)
+	TableOid catalog.Oid
+	// Data はタプルヘッダで始まる内容。長さが t_len に当たる
+	Data HeapTupleHeader
+}
+
+// Len はタプルの長さを返す (t_len 相当)
+func (tup *HeapTuple) Len() int {
+	return len(tup.Data)
+}
+
+// Copy はタプルを写す (heap_copytuple 相当)
+func (tup *HeapTuple) Copy() *HeapTuple {
+	return &HeapTuple{Self: tup.Self, TableOid: tup.TableOid, Data: append(HeapTupleHeader(nil), tup.Data...)}
+}
@@ -0,0 +1,94 @@
+package heap
+
+import (
+	"sync"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+)
+
+// ----------------------------------------------------------------
+// シーケンシャルスキャンの同期 (access/common/syncscan.c 相当)
+// ----------------------------------------------------------------
+// 大きなテーブルを複数のバックエンドが同時にシーケンシャルスキャンする場合、各スキャンが
+// 先頭から読むと、同じページを別々にディスクから読むことになる。そこで、スキャンは
+// 読んでいる位置を共有の表に報告し、後から始めるスキャンはその位置から読み始める。
+// 後のスキャンは先のスキャンが共有バッファに読んだページを使い、末尾まで読んだら先頭に戻って
+// 始めた位置まで読む。
+//
+// 表は最近使ったリレーションから順に SyncScanNElem 個だけ持ち、溢れたら最も長く使われていない
+// 項目を捨てる。位置は SyncScanReportInterval ページごとにだけ報告し、表のロックを取れなければ
+// 報告を諦める。位置は手がかりに過ぎず、多少古くても結果は変わらないためである。
+//
+// 読み始める位置が変わると行を返す順序も変わる。順序に依存するテストのために、
+// synchronize_seqscans を off にすると同期しない。
+
+// SyncScanNElem は表に持つリレーションの数 (SYNC_SCAN_NELEM 相当)
+const SyncScanNElem = 20
+
+// SyncScanReportInterval は位置を報告する間隔のページ数 (SYNC_SCAN_REPORT_INTERVAL 相当)。
+// 128kB ごとに報告する。
+const SyncScanReportInterval = 128 * 1024 / pgconfig.BlckSz
+
+// scanLocation はリレーションのスキャンの位置 (ss_scan_location_t 相当)
+type scanLocation struct {
+	locator  storage.RelFileLocator
+	location storage.BlockNumber
+}
+
+// scanLocations は共有の表 (ss_scan_locations_t と SyncScanLock 相当)。items は最近使った
+// 順に並べる。C言語版は双方向リストで並べ替えるが、項目が少ないため配列をずらして並べ替える。
+// 空の項目は InvalidOid のリレーションを指し、どのリレーションにも一致しない。
+var scanLocations struct {
+	sync.Mutex
+	items [SyncScanNElem]scanLocation
+}
+
+// search はリレーションの項目を探して表の先頭に移す (ss_search 相当)。set が true なら
+// 項目の位置を location にし、false なら項目の位置を返す。項目がなければ最も長く
+// 使われていない項目を location のリレーションの項目に置き換える。scanLocations のロックを
+// 持って呼ぶ。
+func search(locator storage.RelFileLocator, location storage.BlockNumber, set bool) storage.BlockNumber {
+	items := &scanLocations.items
+	i := 0
+	for ; i < len(items)-1; i++ {
+		if items[i].locator == locator {
+			break
+		}
+	}
+	item := items[i]
+	if item.locator != locator {
+		item = scanLocation{locator: locator, location: location}
+	} else if set {
+		item.location = location
+	}
+	copy(items[1:i+1], items[:i])
+	items[0] = item
+	return item.location
+}
+
+// GetLocation はリレーションのスキャンを始める位置を返す (ss_get_location 相当)。
+// relnblocks はリレーションのページ数で、報告された位置がそれを超えていれば先頭から読む。
+func GetLocation(locator storage.RelFileLocator, relnblocks storage.BlockNumber) storage.BlockNumber {
+	scanLocations.Lock()
+	startloc := search(locator, 0, false)
+	scanLocations.Unlock()
+	// テーブルを切り詰めた後は、以前の位置がテーブルの外にある
+	if startloc >= relnblocks {
+		startloc = 0
+	}
+	return startloc
+}
+
+// ReportLocation はスキャンが location のページを読んでいることを報告する (ss_report_location 相当)
+func ReportLocation(locator storage.RelFileLocator, location storage.BlockNumber) {
+	if location%SyncScanReportInterval != 0 {
+		return
+	}
+	// 他のバックエンドが表を使っていれば待たずに諦める (LWLockConditionalAcquire 相当)
+	if !scanLocations.TryLock() {
+		return
+	}
+	search(locator, location, true)
+	scanLocations.Unlock()
+}
@@ -0,0 +1,201 @@
+package transam
+
+import (
+	"path/filepath"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// トランザクションの状態 (access/transam/clog.c 相当)
+// ----------------------------------------------------------------
+// C言語版と同じく、トランザクション1つにつき2ビットで状態を持ち、データディレクトリの pg_xact に
+// slru のページとして置く。ページ1つに clogXactsPerPage 個のトランザクションの状態が入り、
+// トランザクション ID からページと位置が決まる。
+//
+// 状態は clogXactsPerLSNGroup 個ずつのグループにまとめ、グループごとに状態を記録したときの WAL の
+// 位置を持つ。非同期コミットのトランザクションの状態が、コミットの WAL より先にディスクに
+// 届かないようにするためである。WAL はまだないため、記録する位置は今は常に InvalidXLogRecPtr になる。
+//
+// 汚れたページはチェックポイントで書き出す。次に割り当てる ID も制御ファイルに残すため、起動し
+// 直した後もそれまでの ID の状態を引ける。周回に備えて古いセグメントを消す処理 (TruncateCLOG) は
+// まだない。データディレクトリを使わずに起動した場合は、ページをメモリにだけ持つ。
+
+// XidStatus はトランザクションの状態 (XidStatus 相当)
+type XidStatus uint8
+
+const (
+	TransactionStatusInProgress   XidStatus = 0x00
+	TransactionStatusCommitted    XidStatus = 0x01
+	TransactionStatusAborted      XidStatus = 0x02
+	TransactionStatusSubCommitted XidStatus = 0x03
+)
+
+const (
+	// clogBitsPerXact はトランザクション1つの状態のビット数 (CLOG_BITS_PER_XACT 相当)
+	clogBitsPerXact = 2
+	// clogXactsPerByte は1バイトに入るトランザクションの数 (CLOG_XACTS_PER_BYTE 相当)
+	clogXactsPerByte = 4
+	// clogXactsPerPage は1ページに入るトランザクションの数 (CLOG_XACTS_PER_PAGE 相当)
+	clogXactsPerPage = pgconfig.BlckSz * clogXactsPerByte
+	// clogXactBitmask は1つの状態を取り出すマスク (CLOG_XACT_BITMASK 相当)
+	clogXactBitmask = 1<<clogBitsPerXact - 1
+
+	// clogXactsPerLSNGroup はグループ1つのトランザクションの数 (CLOG_XACTS_PER_LSN_GROUP 相当)
+	clogXactsPerLSNGroup = 32
+	// clogLSNsPerPage はページ1つのグループの数 (CLOG_LSNS_PER_PAGE 相当)
+	clogLSNsPerPage = clogXactsPerPage / clogXactsPerLSNGroup
+)
+
+// xactCtl は pg_xact のページの置き場 (XactCtl 相当)。BootStrapCLOG か StartupCLOG で
+// データディレクトリのものにするまでは、ファイルを使わない
+var xactCtl = newSLRU("", 0, clogLSNsPerPage)
+
+// transactionIdToPage は xid の状態を置くページの番号を返す (TransactionIdToPage 相当)
+func transactionIdToPage(xid adt.TransactionId) int64 {
+	return int64(xid / clogXactsPerPage)
+}
+
+// transactionIdToPgIndex は xid の状態のページの中の番号を返す (TransactionIdToPgIndex 相当)
+func transactionIdToPgIndex(xid adt.TransactionId) int {
+	return int(xid % clogXactsPerPage)
+}
+
+// transactionIdToByte は xid の状態を置くページの中のバイトの位置を返す (TransactionIdToByte 相当)
+func transactionIdToByte(xid adt.TransactionId) int {
+	return transactionIdToPgIndex(xid) / clogXactsPerByte
+}
+
+// transactionIdToBShift は xid の状態のバイトの中のビットの位置を返す (TransactionIdToBIndex 相当)
+func transactionIdToBShift(xid adt.TransactionId) uint {
+	return uint(xid%clogXactsPerByte) * clogBitsPerXact
+}
+
+// getLSNIndex は xid の状態のグループのページの中の番号を返す (GetLSNIndex 相当)
+func getLSNIndex(xid adt.TransactionId) int {
+	return transactionIdToPgIndex(xid) / clogXactsPerLSNGroup
+}
+
+// clogShmemBuffers は pg_xact のバッファの数を shared_buffers から決める (CLOGShmemBuffers 相当)
+func clogShmemBuffers() int {
+	return min(128, max(4, guc.SharedBuffers.Get()/512))
+}
+
+// clogDir はデータディレクトリ dataDir の pg_xact のパスを返す
+func clogDir(dataDir string) string {
+	return filepath.Join(dataDir, "pg_xact")
+}
+
+// TransactionIdSetStatus はトランザクションの状態を記録する (TransactionIdSetTreeStatus 相当)。
+// lsn は状態を記録した WAL の位置で、ページを書き出す前にその位置まで WAL を同期させる。
+// 同期を待たせる WAL がなければ InvalidXLogRecPtr を渡す。ページを読めなければエラーを返す。
+func TransactionIdSetStatus(xid adt.TransactionId, status XidStatus, lsn XLogRecPtr) error {
+	xactCtl.Lock()
+	defer xactCtl.Unlock()
+	b, err := xactCtl.readPage(transactionIdToPage(xid), xid)
+	if err != nil {
+		return err
+	}
+	i, shift := transactionIdToByte(xid), transactionIdToBShift(xid)
+	b.page[i] = b.page[i]&^(clogXactBitmask<<shift) | byte(status)<<shift
+	if lsn != InvalidXLogRecPtr {
+		g := getLSNIndex(xid)
+		b.groupLSN[g] = max(b.groupLSN[g], lsn)
+	}
+	b.dirty = true
+	return nil
+}
+
+// TransactionIdGetStatus はトランザクションの状態を返す (TransactionIdGetStatus 相当)。
+// 呼び出し側は xid が oldestClogXid 以降で、割り当て済みであることを確かめておく。ページを
+// 読めなければエラーを返す。
+func TransactionIdGetStatus(xid adt.TransactionId) (XidStatus, error) {
+	xactCtl.Lock()
+	defer xactCtl.Unlock()
+	b, err := xactCtl.readPage(transactionIdToPage(xid), xid)
+	if err != nil {
+		return TransactionStatusInProgress, err
+	}
+	return XidStatus(b.page[transactionIdToByte(xid)]>>transactionIdToBShift(xid)) & clogXactBitmask, nil
+}
+
+// BootStrapCLOG はブートストラップで pg_xact の最初のページを作る (BootStrapCLOG 相当)
+func BootStrapCLOG(dataDir string) error {
+	ctl := newSLRU(clogDir(dataDir), clogShmemBuffers(), clogLSNsPerPage)
+	ctl.Lock()
+	defer ctl.Unlock()
+	b, err := ctl.zeroPage(0)
+	if err != nil {
+		return err
+	}
+	if err := ctl.writePage(b); err != nil {
+		return err
+	}
+	xactCtl = ctl
+	return nil
+}
+
+// StartupCLOG は起動時に pg_xact をデータディレクトリ dataDir のものにし、次に割り当てる ID の
+// ページの残りを0で埋める (StartupCLOG と TrimCLOG 相当)。残りは前回のサーバーが異常終了する
+// 前に割り当てた ID の状態でありうるためである。ページがまだファイルになければ作る。
+// transamVariables の nextXid を復元してから、バックエンドを起動する前に呼ぶ。
+func StartupCLOG(dataDir string) error {
+	ctl := newSLRU(clogDir(dataDir), clogShmemBuffers(), clogLSNsPerPage)
+	xid := ReadNextTransactionId()
+	pageno := transactionIdToPage(xid)
+
+	ctl.Lock()
+	defer ctl.Unlock()
+	ctl.latestPageNumber = pageno
+	exists, err := ctl.pageExists(pageno)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		_, err = ctl.zeroPage(pageno)
+	} else if transactionIdToPgIndex(xid) != 0 {
+		var b *slruBuffer
+		if b, err = ctl.readPage(pageno, xid); err == nil {
+			i, shift := transactionIdToByte(xid), transactionIdToBShift(xid)
+			// xid のバイトのうち xid より前の状態は残し、後ろのバイトは全て0にする
+			b.page[i] &= 1<<shift - 1
+			clear(b.page[i+1:])
+			b.dirty = true
+		}
+	}
+	if err != nil {
+		return err
+	}
+	xactCtl = ctl
+	return nil
+}
+
+// CheckPointCLOG は pg_xact の汚れたページを全て書き出す (CheckPointCLOG 相当)。
+// 書き出したセグメントは、同じチェックポイントの ProcessSyncRequests が同期する。
+func CheckPointCLOG() error {
+	xactCtl.Lock()
+	defer xactCtl.Unlock()
+	return xactCtl.writeAll()
+}
+
+// clogNeedsCheckpoint は pg_xact に書き出していないページがあるかを返す
+func clogNeedsCheckpoint() bool {
+	xactCtl.Lock()
+	defer xactCtl.Unlock()
+	return xactCtl.anyDirty()
+}
+
+// extendCLOG は新しく割り当てる ID newestXact の状態を置くページを用意する (ExtendCLOG 相当)。
+// ページの最初の ID を割り当てるときにだけ、ページを0で埋める。周回した直後は、ページ 0 の
+// 最初の ID は FirstNormalTransactionId になる。transamVariables のロックを持って呼ぶ。
+func extendCLOG(newestXact adt.TransactionId) error {
+	if transactionIdToPgIndex(newestXact) != 0 && newestXact != FirstNormalTransactionId {
+		return nil
+	}
+	xactCtl.Lock()
+	defer xactCtl.Unlock()
+	_, err := xactCtl.zeroPage(transactionIdToPage(newestXact))
+	return err
+}
@@ -0,0 +1,28 @@
+package transam
+
+import "github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+
+// ----------------------------------------------------------------
+// マルチトランザクション ID (access/transam/multixact.c の一部相当)
+// ----------------------------------------------------------------
+// 行を複数のトランザクションでロックした時に作る ID。行ロックがまだないため、ID を作ることはなく、
+// 次に割り当てる ID は常に FirstMultiXactId である。mxid_age のために番号の体系だけを持つ。
+
+// MultiXactId はマルチトランザクション ID (MultiXactId 相当)。xid と同じく32ビットで周回する
+type MultiXactId = adt.TransactionId
+
+// 予約したマルチトランザクション ID (InvalidMultiXactId、FirstMultiXactId 相当)
+const (
+	InvalidMultiXactId MultiXactId = 0
+	FirstMultiXactId   MultiXactId = 1
+)
+
+// MultiXactIdIsValid は ID が InvalidMultiXactId でないかを返す (MultiXactIdIsValid 相当)
+func MultiXactIdIsValid(multi MultiXactId) bool {
+	return multi != InvalidMultiXactId
+}
+
+// ReadNextMultiXactId は次に割り当てるマルチトランザクション ID を返す (ReadNextMultiXactId 相当)
+func ReadNextMultiXactId() MultiXactId {
+	return FirstMultiXactId
+}
@@ -0,0 +1,275 @@
+package transam
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/file"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/fsync"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// ページ単位の状態の置き場 (access/transam/slru.c 相当)
+// ----------------------------------------------------------------
+// pg_xact のように ID から位置の決まる小さな状態を BLCKSZ のページに並べてファイルに置き、
+// 使うページだけを決まった数のバッファに持つ (Simple LRU)。ファイルは slruPagesPerSegment
+// ページごとのセグメントに分け、セグメントの番号を16進数4桁で名前にする。バッファが埋まったら
+// 最も長く使っていないページを追い出し、汚れていれば書き出してから使う。
+//
+// ページの中の状態はいくつかずつのグループに分け、グループごとに状態を記録した WAL の位置
+// (グループの LSN) を持つ。ページを書き出す前に、グループの LSN のうち最も後ろのものまで WAL を
+// 同期する。書き出したセグメントは fsync.RegisterSyncRequest で次のチェックポイントに同期させる。
+//
+// C言語版はバッファごとに入出力のロックを持ち、読み書きの間は制御のロックを放すが、ここでは
+// 制御のロックを持ったまま読み書きする。ディレクトリを指定しなければファイルを使わず、使う
+// 全てのページをメモリに持つ。データディレクトリを使わずに起動した場合である。
+
+// slruPagesPerSegment はセグメントファイル1つのページの数 (SLRU_PAGES_PER_SEGMENT 相当)
+const slruPagesPerSegment = 32
+
+// slruBuffer はページ1つ分のバッファ (SlruSharedData の page_* と group_lsn のバッファ1つ分相当)
+type slruBuffer struct {
+	// valid はバッファがページ pageno を持つことを表す (SLRU_PAGE_VALID 相当)
+	valid  bool
+	pageno int64
+	dirty  bool
+	// lruCount は最後に使ったときの slruCtl.curLRUCount。小さいほど長く使っていない
+	lruCount uint64
+	page     []byte
+	// groupLSN はグループごとの、状態を記録した WAL のうち最も後ろの位置
+	groupLSN []XLogRecPtr
+}
+
+// slruCtl はページの置き場1つ (SlruCtlData と SlruSharedData 相当)。ロックを持って操作する。
+type slruCtl struct {
+	sync.Mutex
+	// dir はセグメントファイルを置くディレクトリ。空ならファイルを使わない
+	dir string
+	// nslots はバッファの数。dir が空なら上限はなく、使うページの分だけ増やす
+	nslots int
+	// lsnGroupsPerPage はページごとのグループの数
+	lsnGroupsPerPage int
+	buffers          []*slruBuffer
+	// curLRUCount はバッファを使うたびに増やす数 (cur_lru_count 相当)
+	curLRUCount uint64
+	// latestPageNumber は最後に0で埋めたページ。追い出さない (latest_page_number 相当)
+	latestPageNumber int64
+}
+
+// newSLRU はディレクトリ dir のページの置き場を作る (SimpleLruInit 相当)
+func newSLRU(dir string, nslots, lsnGroupsPerPage int) *slruCtl {
+	return &slruCtl{dir: dir, nslots: nslots, lsnGroupsPerPage: lsnGroupsPerPage}
+}
+
+// segmentFileName はセグメント segno のファイルのパスを返す (SlruFileName 相当)
+func (ctl *slruCtl) segmentFileName(segno int64) string {
+	return filepath.Join(ctl.dir, fmt.Sprintf("%04X", segno))
+}
+
+// recordPageUsage はバッファを使ったことを記録する (SlruRecentlyUsed 相当)
+func (ctl *slruCtl) recordPageUsage(b *slruBuffer) {
+	ctl.curLRUCount++
+	b.lruCount = ctl.curLRUCount
+}
+
+// zeroPage はページ pageno を0で埋めたバッファを返す (SimpleLruZeroPage 相当)。ページは
+// 汚れた印を付け、ファイルには追い出すかチェックポイントで書き出す。
+func (ctl *slruCtl) zeroPage(pageno int64) (*slruBuffer, error) {
+	b, err := ctl.selectLRUPage(pageno)
+	if err != nil {
+		return nil, err
+	}
+	b.valid, b.pageno, b.dirty = true, pageno, true
+	clear(b.page)
+	clear(b.groupLSN)
+	ctl.recordPageUsage(b)
+	ctl.latestPageNumber = pageno
+	return b, nil
+}
+
+// readPage はページ pageno を持つバッファを返す。なければファイルから読む (SimpleLruReadPage 相当)。
+// xid は読めなかったときのメッセージに出すトランザクション。
+func (ctl *slruCtl) readPage(pageno int64, xid adt.TransactionId) (*slruBuffer, error) {
+	b, err := ctl.selectLRUPage(pageno)
+	if err != nil {
+		return nil, err
+	}
+	if !b.valid || b.pageno != pageno {
+		b.valid = false
+		if err := ctl.physicalReadPage(pageno, b.page, xid); err != nil {
+			return nil, err
+		}
+		b.valid, b.pageno, b.dirty = true, pageno, false
+		clear(b.groupLSN)
+	}
+	ctl.recordPageUsage(b)
+	return b, nil
+}
+
+// writePage はバッファが汚れていれば書き出す (SlruInternalWritePage 相当)。先にグループの LSN の
+// うち最も後ろのものまで WAL を同期する。
+func (ctl *slruCtl) writePage(b *slruBuffer) error {
+	if !b.dirty {
+		return nil
+	}
+	maxLSN := InvalidXLogRecPtr
+	for _, lsn := range b.groupLSN {
+		maxLSN = max(maxLSN, lsn)
+	}
+	if maxLSN != InvalidXLogRecPtr {
+		if err := XLogFlush(maxLSN); err != nil {
+			return err
+		}
+	}
+	if err := ctl.physicalWritePage(b.pageno, b.page); err != nil {
+		return err
+	}
+	b.dirty = false
+	return nil
+}
+
+// writeAll は汚れた全てのバッファを書き出す (SimpleLruWriteAll 相当)。ファイルを使わない場合は
+// 何もしない。
+func (ctl *slruCtl) writeAll() error {
+	if ctl.dir == "" {
+		return nil
+	}
+	for _, b := range ctl.buffers {
+		if b.valid {
+			if err := ctl.writePage(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// anyDirty は書き出していないバッファがあるかを返す。ファイルを使わない場合は常に false を返す。
+func (ctl *slruCtl) anyDirty() bool {
+	if ctl.dir == "" {
+		return false
+	}
+	for _, b := range ctl.buffers {
+		if b.valid && b.dirty {
+			return true
+		}
+	}
+	return false
+}
+
+// selectLRUPage はページ pageno を置くバッファを返す (SlruSelectLRUPage 相当)。既にページを
+// 持つバッファがあればそれを返す。なければ空いたバッファか、最も長く使っていないバッファを
+// 返す。追い出すバッファが汚れていれば書き出す。
+func (ctl *slruCtl) selectLRUPage(pageno int64) (*slruBuffer, error) {
+	for _, b := range ctl.buffers {
+		if b.valid && b.pageno == pageno {
+			return b, nil
+		}
+	}
+	for _, b := range ctl.buffers {
+		if !b.valid {
+			return b, nil
+		}
+	}
+	if ctl.dir == "" || len(ctl.buffers) < ctl.nslots {
+		b := &slruBuffer{page: make([]byte, pgconfig.BlckSz), groupLSN: make([]XLogRecPtr, ctl.lsnGroupsPerPage)}
+		ctl.buffers = append(ctl.buffers, b)
+		return b, nil
+	}
+
+	// 最後に0で埋めたページは、すぐにまた使うため追い出さない
+	var victim *slruBuffer
+	for _, b := range ctl.buffers {
+		if b.pageno != ctl.latestPageNumber && (victim == nil || b.lruCount < victim.lruCount) {
+			victim = b
+		}
+	}
+	if err := ctl.writePage(victim); err != nil {
+		return nil, err
+	}
+	victim.valid = false
+	return victim, nil
+}
+
+// pageExists はページ pageno がファイルにあるかを返す (SimpleLruDoesPhysicalPageExist 相当)。
+// ファイルを使わない場合は、バッファにあるかを返す。
+func (ctl *slruCtl) pageExists(pageno int64) (bool, error) {
+	if ctl.dir == "" {
+		for _, b := range ctl.buffers {
+			if b.valid && b.pageno == pageno {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	path := ctl.segmentFileName(pageno / slruPagesPerSegment)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, slruIOError(err, InvalidTransactionId, "Could not open file \"%s\": %s.", path, platform.OSErrorMessage(err))
+	}
+	offset := pageno % slruPagesPerSegment * pgconfig.BlckSz
+	return info.Size() >= offset+pgconfig.BlckSz, nil
+}
+
+// physicalReadPage はページ pageno をファイルから buf に読む (SlruPhysicalReadPage 相当)
+func (ctl *slruCtl) physicalReadPage(pageno int64, buf []byte, xid adt.TransactionId) error {
+	if ctl.dir == "" {
+		// ファイルを使わない場合、0で埋めていないページはどこにもない
+		return errutil.Elog(errutil.Error, "could not access status of transaction %d", xid).
+			WithDetail("Page %d is not in memory.", pageno)
+	}
+	path := ctl.segmentFileName(pageno / slruPagesPerSegment)
+	offset := pageno % slruPagesPerSegment * pgconfig.BlckSz
+	f, err := file.PathNameOpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return slruIOError(err, xid, "Could not open file \"%s\": %s.", path, platform.OSErrorMessage(err))
+	}
+	defer f.Close()
+	n, err := f.ReadAt(buf, offset)
+	if n == len(buf) {
+		return nil
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		return slruIOError(nil, xid, "Could not read from file \"%s\" at offset %d: read too few bytes.", path, offset)
+	}
+	return slruIOError(err, xid, "Could not read from file \"%s\" at offset %d: %s.", path, offset, platform.OSErrorMessage(err))
+}
+
+// physicalWritePage は buf をページ pageno としてファイルに書く (SlruPhysicalWritePage 相当)。
+// セグメントのファイルがなければ作る。
+func (ctl *slruCtl) physicalWritePage(pageno int64, buf []byte) error {
+	path := ctl.segmentFileName(pageno / slruPagesPerSegment)
+	offset := pageno % slruPagesPerSegment * pgconfig.BlckSz
+	f, err := file.PathNameOpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return slruIOError(err, InvalidTransactionId, "Could not open file \"%s\": %s.", path, platform.OSErrorMessage(err))
+	}
+	defer f.Close()
+	if n, err := f.WriteAt(buf, offset); n != len(buf) {
+		if err == nil {
+			return slruIOError(nil, InvalidTransactionId, "Could not write to file \"%s\" at offset %d: wrote too few bytes.", path, offset)
+		}
+		return slruIOError(err, InvalidTransactionId, "Could not write to file \"%s\" at offset %d: %s.", path, offset, platform.OSErrorMessage(err))
+	}
+	fsync.RegisterSyncRequest(path)
+	return nil
+}
+
+// slruIOError はページを読み書きできなかったことを表すエラーを作る (SlruReportIOError 相当)。
+// err は OS のエラーで、SQLSTATE を決める。書き出しでは xid は InvalidTransactionId になる。
+func slruIOError(err error, xid adt.TransactionId, detail string, args ...any) *errutil.ErrorData {
+	return errutil.New(errutil.Error, file.ErrcodeForFileAccess(err), "could not access status of transaction %d", xid).
+		WithDetail(detail, args...)
+}
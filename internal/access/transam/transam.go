@@ -0,0 +1,82 @@
+package transam
+
+import "github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+
+// ----------------------------------------------------------------
+// トランザクション ID の比較 (access/transam/transam.c、access/transam.h 相当)
+// ----------------------------------------------------------------
+// トランザクション ID は 32 ビットで周回するため、通常の ID どうしは差の符号で前後を決める。
+// ある ID から見て 2^31 個前までが過去、2^31 個後までが未来になる。予約した特別な ID は
+// 周回せず、どの通常の ID よりも前にある。
+
+// 予約したトランザクション ID (InvalidTransactionId などの定数相当)
+const (
+	InvalidTransactionId     adt.TransactionId = 0
+	BootstrapTransactionId   adt.TransactionId = 1
+	FrozenTransactionId      adt.TransactionId = 2
+	FirstNormalTransactionId adt.TransactionId = 3
+)
+
+// TransactionIdIsValid は ID が InvalidTransactionId でないかを返す (TransactionIdIsValid 相当)
+func TransactionIdIsValid(xid adt.TransactionId) bool {
+	return xid != InvalidTransactionId
+}
+
+// TransactionIdIsNormal は ID が予約したものでないかを返す (TransactionIdIsNormal 相当)
+func TransactionIdIsNormal(xid adt.TransactionId) bool {
+	return xid >= FirstNormalTransactionId
+}
+
+// TransactionIdPrecedes は id1 が id2 より前かを返す (TransactionIdPrecedes 相当)
+func TransactionIdPrecedes(id1, id2 adt.TransactionId) bool {
+	if !TransactionIdIsNormal(id1) || !TransactionIdIsNormal(id2) {
+		return id1 < id2
+	}
+	return int32(id1-id2) < 0
+}
+
+// TransactionIdPrecedesOrEquals は id1 が id2 より前か等しいかを返す (TransactionIdPrecedesOrEquals 相当)
+func TransactionIdPrecedesOrEquals(id1, id2 adt.TransactionId) bool {
+	if !TransactionIdIsNormal(id1) || !TransactionIdIsNormal(id2) {
+		return id1 <= id2
+	}
+	return int32(id1-id2) <= 0
+}
+
+// TransactionIdFollows は id1 が id2 より後かを返す (TransactionIdFollows 相当)
+func TransactionIdFollows(id1, id2 adt.TransactionId) bool {
+	return TransactionIdPrecedes(id2, id1)
+}
+
+// TransactionIdFollowsOrEquals は id1 が id2 より後か等しいかを返す (TransactionIdFollowsOrEquals 相当)
+func TransactionIdFollowsOrEquals(id1, id2 adt.TransactionId) bool {
+	return TransactionIdPrecedesOrEquals(id2, id1)
+}
+
+// transactionLogFetch はトランザクションの状態を返す (TransactionLogFetch 相当)。
+// 予約した ID のうち BootstrapTransactionId と FrozenTransactionId は常にコミット済み、
+// InvalidTransactionId はアボート済みとして扱う。
+func transactionLogFetch(xid adt.TransactionId) (XidStatus, error) {
+	if !TransactionIdIsNormal(xid) {
+		if xid == BootstrapTransactionId || xid == FrozenTransactionId {
+			return TransactionStatusCommitted, nil
+		}
+		return TransactionStatusAborted, nil
+	}
+	return TransactionIdGetStatus(xid)
+}
+
+// TransactionIdDidCommit はトランザクションがコミットしたかを返す (TransactionIdDidCommit 相当)。
+// pg_xact のページを読めなければエラーを返す。
+func TransactionIdDidCommit(xid adt.TransactionId) (bool, error) {
+	status, err := transactionLogFetch(xid)
+	return status == TransactionStatusCommitted, err
+}
+
+// TransactionIdDidAbort はトランザクションがアボートしたかを返す (TransactionIdDidAbort 相当)。
+// 実行中のまま異常終了したトランザクションは false になるため、呼び出し側は実行中でないことを
+// 別に確かめる。
+func TransactionIdDidAbort(xid adt.TransactionId) (bool, error) {
+	status, err := transactionLogFetch(xid)
+	return status == TransactionStatusAborted, err
+}
@@ -0,0 +1,111 @@
+package transam
+
+import (
+	"sync"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// トランザクション ID の割り当て (access/transam/varsup.c 相当)
+// ----------------------------------------------------------------
+// 次に割り当てるトランザクション ID を epoch を含む64ビットで持つ。32ビットの ID が周回すると
+// epoch が1つ増え、予約した ID (0 から 2) は飛ばす。
+//
+// WAL がないため、割り当てた ID を異常終了の後に知る方法がない。そこで WAL を持つ前の C言語版
+// (VAR_XID_PREFETCH) と同じく、xidPrefetch 個ずつ先に ID を予約し、予約の終わりを制御ファイルに
+// 書いて同期してから、その範囲の ID を割り当てる。起動時は StartupXLOG が制御ファイルの値から
+// 割り当てを始めるため、異常終了した後でも割り当てた ID を割り当て直さない。代わりに、予約して
+// 使わなかった ID は飛ばす。シャットダウンのチェックポイントは、予約を次に割り当てる ID まで戻す。
+// 制御ファイルを読まずに起動した場合は、epoch 0 の FirstNormalTransactionId から割り当て、
+// 予約を書かない。
+
+// transamVariables は次に割り当てる ID (TransamVariablesData の一部相当)
+var transamVariables struct {
+	sync.Mutex
+	// nextXid は次に割り当てるトランザクション ID (nextXid 相当)
+	nextXid adt.FullTransactionId
+	// reservedXid は制御ファイルに書いた予約の終わり。これより前の ID だけを割り当てる
+	// (xidCount 相当)
+	reservedXid adt.FullTransactionId
+	// oldestClogXid は状態を引ける最も古いトランザクション ID (oldestClogXid 相当)。
+	// pg_xact の古いセグメントはまだ消さないため、FirstNormalTransactionId のまま変わらない
+	oldestClogXid adt.TransactionId
+}
+
+// xidPrefetch は一度に予約する ID の数 (VAR_XID_PREFETCH 相当)
+const xidPrefetch = 1024
+
+func init() {
+	transamVariables.nextXid = FullTransactionIdFromEpochAndXid(0, FirstNormalTransactionId)
+	transamVariables.oldestClogXid = FirstNormalTransactionId
+}
+
+// FullTransactionIdFromEpochAndXid は epoch と32ビットの ID から64ビットの ID を作る
+// (FullTransactionIdFromEpochAndXid 相当)
+func FullTransactionIdFromEpochAndXid(epoch uint32, xid adt.TransactionId) adt.FullTransactionId {
+	return adt.FullTransactionId(uint64(epoch)<<32 | uint64(xid))
+}
+
+// EpochFromFullTransactionId は64ビットの ID の epoch を返す (EpochFromFullTransactionId 相当)
+func EpochFromFullTransactionId(fxid adt.FullTransactionId) uint32 {
+	return uint32(fxid >> 32)
+}
+
+// XidFromFullTransactionId は64ビットの ID の下位32ビットを返す (XidFromFullTransactionId 相当)
+func XidFromFullTransactionId(fxid adt.FullTransactionId) adt.TransactionId {
+	return adt.TransactionId(fxid)
+}
+
+// FullTransactionIdIsValid は ID が InvalidTransactionId でないかを返す (FullTransactionIdIsValid 相当)
+func FullTransactionIdIsValid(fxid adt.FullTransactionId) bool {
+	return XidFromFullTransactionId(fxid) != InvalidTransactionId
+}
+
+// GetNewTransactionId は新しいトランザクション ID を割り当てる (GetNewTransactionId 相当)。
+// 割り当てた ID の状態は、トランザクションが終わるまで実行中になる。状態を置く pg_xact の
+// ページを用意できないか、予約を制御ファイルに書けなければ、ID を割り当てずにエラーを返す。
+func GetNewTransactionId() (adt.FullTransactionId, error) {
+	transamVariables.Lock()
+	defer transamVariables.Unlock()
+	fxid := transamVariables.nextXid
+	// 状態を置く場所を用意してから次の ID を進める。失敗しても同じ ID を割り当て直せばよい
+	if err := extendCLOG(XidFromFullTransactionId(fxid)); err != nil {
+		return 0, err
+	}
+	if fxid >= transamVariables.reservedXid {
+		// 予約を使い切ったため、次の分を予約する
+		reserved := fxid + xidPrefetch
+		if err := updateControlFileNextXid(reserved); err != nil {
+			return 0, err
+		}
+		transamVariables.reservedXid = reserved
+	}
+	transamVariables.nextXid++
+	// 周回した後は予約した ID を飛ばす (FullTransactionIdAdvance 相当)
+	for !TransactionIdIsNormal(XidFromFullTransactionId(transamVariables.nextXid)) {
+		transamVariables.nextXid++
+	}
+	return fxid, nil
+}
+
+// ReadNextFullTransactionId は次に割り当てるトランザクション ID を返す (ReadNextFullTransactionId 相当)
+func ReadNextFullTransactionId() adt.FullTransactionId {
+	transamVariables.Lock()
+	defer transamVariables.Unlock()
+	return transamVariables.nextXid
+}
+
+// ReadNextTransactionId は次に割り当てるトランザクション ID の下位32ビットを返す
+// (ReadNextTransactionId 相当)
+func ReadNextTransactionId() adt.TransactionId {
+	return XidFromFullTransactionId(ReadNextFullTransactionId())
+}
+
+// OldestClogXid は状態を問い合わせられる最も古いトランザクション ID を返す
+// (TransamVariables->oldestClogXid 相当)
+func OldestClogXid() adt.TransactionId {
+	transamVariables.Lock()
+	defer transamVariables.Unlock()
+	return transamVariables.oldestClogXid
+}
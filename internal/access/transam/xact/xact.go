@@ -0,0 +1,550 @@
+package xact
+
+import (
+	"errors"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/procarray"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// トランザクションの状態遷移 (access/transam/xact.c 相当)
+// ----------------------------------------------------------------
+// トランザクションの状態を2段で持つ。下の段 (TransState) は、トランザクションを開始しているか、
+// コミットやアボートの途中かを表す。上の段 (TBlockState) は、BEGIN で始めたトランザクション
+// ブロックの中にいるかと、ブロックの中でエラーになったかを表す。
+//
+// バックエンドはコマンドごとに StartTransactionCommand と CommitTransactionCommand を呼び、
+// エラーになれば AbortCurrentTransaction を呼ぶ。BEGIN、COMMIT、ROLLBACK は上の段を変える
+// だけで、トランザクションの開始と終了は次の CommitTransactionCommand が行う。ブロックの中で
+// エラーになると、トランザクションはアボートするがブロックは終わらず、COMMIT か ROLLBACK が
+// 届くまで他の文を実行しない。
+//
+// トランザクション ID は必要になった時に初めて割り当てるため、読むだけのトランザクションは
+// ID を使わない。コマンド ID はトランザクションの中の文を区別し、前の文が変更した行が後の文から
+// 見えるようにする。行を変更しなかった文の後では進めない。
+//
+// 資源の準備と後始末は Callbacks を通してバックエンドが行う。サブトランザクション、2相
+// コミットと並列処理はまだないため、それらの状態は持たない。
+//
+// 想定していない状態で呼ばれた場合は、状態が壊れているため FATAL のエラーを返し、セッションを
+// 終えさせる。C言語版の elog(FATAL) に当たり、トランザクションの開始と終了の確認は C言語版では
+// WARNING だが、続けても状態を正しく戻せないため同じく FATAL にする。
+
+// TransState はトランザクションの下の段の状態 (TransState 相当)
+type TransState int
+
+const (
+	// TransDefault はトランザクションの外
+	TransDefault TransState = iota
+	// TransStart は開始の途中
+	TransStart
+	// TransInprogress は実行中
+	TransInprogress
+	// TransCommit はコミットの途中
+	TransCommit
+	// TransAbort はアボートの途中か、アボートして後始末を待っている
+	TransAbort
+)
+
+// String は状態の名前を返す (TransStateAsString 相当)
+func (st TransState) String() string {
+	switch st {
+	case TransDefault:
+		return "DEFAULT"
+	case TransStart:
+		return "START"
+	case TransInprogress:
+		return "INPROGRESS"
+	case TransCommit:
+		return "COMMIT"
+	case TransAbort:
+		return "ABORT"
+	}
+	return "UNRECOGNIZED"
+}
+
+// TBlockState はトランザクションブロックの状態 (TBlockState 相当)
+type TBlockState int
+
+const (
+	// TBlockDefault はトランザクションの外
+	TBlockDefault TBlockState = iota
+	// TBlockStarted はブロックの外の1つのコマンドのトランザクションを実行中
+	TBlockStarted
+	// TBlockBegin は BEGIN を実行した
+	TBlockBegin
+	// TBlockInprogress はブロックの中
+	TBlockInprogress
+	// TBlockImplicitInprogress は複数の文からなる単純問い合わせの暗黙のブロックの中
+	TBlockImplicitInprogress
+	// TBlockEnd は COMMIT を実行した
+	TBlockEnd
+	// TBlockAbort はブロックの中でエラーになり、ROLLBACK を待っている
+	TBlockAbort
+	// TBlockAbortEnd はエラーになったブロックで ROLLBACK を実行した
+	TBlockAbortEnd
+	// TBlockAbortPending は実行中のブロックで ROLLBACK を実行した
+	TBlockAbortPending
+)
+
+// String は状態の名前を返す (BlockStateAsString 相当)
+func (st TBlockState) String() string {
+	switch st {
+	case TBlockDefault:
+		return "DEFAULT"
+	case TBlockStarted:
+		return "STARTED"
+	case TBlockBegin:
+		return "BEGIN"
+	case TBlockInprogress:
+		return "INPROGRESS"
+	case TBlockImplicitInprogress:
+		return "IMPLICIT_INPROGRESS"
+	case TBlockEnd:
+		return "END"
+	case TBlockAbort:
+		return "ABORT"
+	case TBlockAbortEnd:
+		return "ABORT_END"
+	case TBlockAbortPending:
+		return "ABORT_PENDING"
+	}
+	return "UNRECOGNIZED"
+}
+
+// Callbacks はトランザクションの開始と終了でバックエンドが行う処理 (StartTransaction、
+// CommitTransaction、AbortTransaction のうち、他のモジュールの AtStart_* や AtEOXact_* を呼ぶ部分相当)
+type Callbacks interface {
+	// AtStartTransaction はトランザクションの開始時に、トランザクションの特性などを準備する
+	AtStartTransaction()
+	// AtCommitTransaction はコミットを記録した後に、トランザクションの資源を放す
+	AtCommitTransaction()
+	// AtAbortTransaction はアボートを記録した後に、エラーで途中になった処理が持っていたものも
+	// 含めてトランザクションの資源を放す
+	AtAbortTransaction()
+}
+
+// TransactionState はバックエンド1つのトランザクションの状態 (TransactionStateData と
+// CurrentTransactionState 相当)
+type TransactionState struct {
+	// pid はバックエンドの PID。実行中のトランザクションの一覧に使う
+	pid       int32
+	callbacks Callbacks
+
+	state      TransState
+	blockState TBlockState
+	// fullXid は割り当てたトランザクション ID。割り当てていなければ 0
+	fullXid adt.FullTransactionId
+	// stableLatestXid は GetStableLatestTransactionId がトランザクションの中で返す ID
+	stableLatestXid adt.TransactionId
+	// localXid はローカルなトランザクション ID (MyProc->vxid.lxid 相当)。PID と組にして仮想
+	// トランザクション ID にする。トランザクションの外では 0
+	localXid     uint32
+	nextLocalXid uint32
+	// currentCommandId は実行中のコマンドの ID で、currentCommandIdUsed はそのコマンドが ID を
+	// 使って行を変更したこと (currentCommandIdUsed 相当)
+	currentCommandId     adt.CommandId
+	currentCommandIdUsed bool
+}
+
+// New は PID が pid のバックエンドのトランザクションの状態を作る。トランザクションの外から始める。
+func New(pid int32, callbacks Callbacks) *TransactionState {
+	return &TransactionState{pid: pid, callbacks: callbacks}
+}
+
+// IsTransactionState はトランザクションを実行中かを返す (IsTransactionState 相当)。ブロックの
+// 中でエラーになった後は偽を返す。
+func (s *TransactionState) IsTransactionState() bool {
+	return s.state == TransInprogress
+}
+
+// IsAbortedTransactionBlockState はエラーになったブロックの中にいるかを返す
+// (IsAbortedTransactionBlockState 相当)。COMMIT と ROLLBACK のほかは実行できない。
+func (s *TransactionState) IsAbortedTransactionBlockState() bool {
+	return s.blockState == TBlockAbort
+}
+
+// IsTransactionBlock はトランザクションブロックの中にいるかを返す (IsTransactionBlock 相当)。
+// 暗黙のブロックもブロックとみなす。
+func (s *TransactionState) IsTransactionBlock() bool {
+	return s.blockState != TBlockDefault && s.blockState != TBlockStarted
+}
+
+// IsTransactionOrTransactionBlock はトランザクションかブロックの中にいるかを返す
+// (IsTransactionOrTransactionBlock 相当)
+func (s *TransactionState) IsTransactionOrTransactionBlock() bool {
+	return s.blockState != TBlockDefault
+}
+
+// TransactionBlockStatusCode は ReadyForQuery で通知するトランザクションの状態を返す
+// (TransactionBlockStatusCode 相当)。'I' はトランザクションの外、'T' はブロックの中、'E' は
+// エラーになったブロックの中を表す。
+func (s *TransactionState) TransactionBlockStatusCode() (byte, error) {
+	switch s.blockState {
+	case TBlockDefault, TBlockStarted:
+		return 'I', nil
+	case TBlockBegin, TBlockInprogress, TBlockImplicitInprogress, TBlockEnd, TBlockAbortPending:
+		return 'T', nil
+	case TBlockAbort, TBlockAbortEnd:
+		return 'E', nil
+	}
+	return 0, errutil.Elog(errutil.Fatal, "invalid transaction block state: %s", s.blockState)
+}
+
+// ----------------------------------------------------------------
+// トランザクション ID とコマンド ID
+// ----------------------------------------------------------------
+
+// GetTopFullTransactionId はトランザクション ID を返す。まだ割り当てていなければ割り当てる
+// (GetTopFullTransactionId と AssignTransactionId 相当)。割り当てられなければエラーを返す。
+func (s *TransactionState) GetTopFullTransactionId() (adt.FullTransactionId, error) {
+	if !transam.FullTransactionIdIsValid(s.fullXid) {
+		fxid, err := procarray.AssignTransactionId(s.pid)
+		if err != nil {
+			return 0, err
+		}
+		s.fullXid = fxid
+	}
+	return s.fullXid, nil
+}
+
+// GetTopFullTransactionIdIfAny はトランザクション ID を返す。割り当てていなければ 0 を返す
+// (GetTopFullTransactionIdIfAny 相当)。
+func (s *TransactionState) GetTopFullTransactionIdIfAny() adt.FullTransactionId {
+	return s.fullXid
+}
+
+// GetStableLatestTransactionId はトランザクションの中で変わらない「最新の」トランザクション ID を
+// 返す (GetStableLatestTransactionId 相当)。割り当てていればその ID、割り当てていなければ
+// トランザクションで最初に呼んだ時の次の ID。
+func (s *TransactionState) GetStableLatestTransactionId() adt.TransactionId {
+	if s.stableLatestXid == transam.InvalidTransactionId {
+		if transam.FullTransactionIdIsValid(s.fullXid) {
+			s.stableLatestXid = transam.XidFromFullTransactionId(s.fullXid)
+		} else {
+			s.stableLatestXid = transam.ReadNextTransactionId()
+		}
+	}
+	return s.stableLatestXid
+}
+
+// LocalTransactionId はローカルなトランザクション ID を返す。トランザクションの外では 0
+func (s *TransactionState) LocalTransactionId() uint32 {
+	return s.localXid
+}
+
+// GetCurrentCommandId は実行中のコマンドの ID を返す (GetCurrentCommandId 相当)。コマンドが
+// その ID で行を変更するなら used を真にする。次の CommandCounterIncrement で ID が進む。
+func (s *TransactionState) GetCurrentCommandId(used bool) adt.CommandId {
+	if used {
+		s.currentCommandIdUsed = true
+	}
+	return s.currentCommandId
+}
+
+// CommandCounterIncrement はコマンド ID を進め、実行中のコマンドが変更した行を次のコマンドから
+// 見えるようにする (CommandCounterIncrement 相当)。コマンドが ID を使っていなければ進めない。
+func (s *TransactionState) CommandCounterIncrement() error {
+	if !s.currentCommandIdUsed {
+		return nil
+	}
+	if s.currentCommandId+1 == adt.InvalidCommandId {
+		return errutil.New(errutil.Error, errcodes.ProgramLimitExceeded, "cannot have more than 2^32-2 commands in a transaction")
+	}
+	s.currentCommandId++
+	s.currentCommandIdUsed = false
+	return nil
+}
+
+// ----------------------------------------------------------------
+// トランザクションの開始と終了
+// ----------------------------------------------------------------
+
+// startTransaction はトランザクションを開始する (StartTransaction 相当)
+func (s *TransactionState) startTransaction() error {
+	if s.state != TransDefault {
+		return errutil.Elog(errutil.Fatal, "StartTransaction while in %s state", s.state)
+	}
+	s.state = TransStart
+	s.nextLocalXid++
+	s.localXid = s.nextLocalXid
+	s.fullXid = 0
+	s.stableLatestXid = transam.InvalidTransactionId
+	s.currentCommandId = adt.FirstCommandId
+	s.currentCommandIdUsed = false
+	s.callbacks.AtStartTransaction()
+	s.state = TransInprogress
+	return nil
+}
+
+// commitTransaction はトランザクションをコミットする (CommitTransaction 相当)。コミットを
+// 記録できなければ PANIC のエラーを返す。
+func (s *TransactionState) commitTransaction() error {
+	if s.state != TransInprogress {
+		return errutil.Elog(errutil.Fatal, "CommitTransaction while in %s state", s.state)
+	}
+	s.state = TransCommit
+	if err := s.endTransactionId(transam.TransactionStatusCommitted); err != nil {
+		return err
+	}
+	s.callbacks.AtCommitTransaction()
+	s.state = TransDefault
+	return nil
+}
+
+// abortTransaction はトランザクションをアボートする (AbortTransaction 相当)。トランザクションの
+// 外に出るのは cleanupTransaction を呼んだ後である。アボートを記録できなくても資源は放し、
+// PANIC のエラーを返す。
+func (s *TransactionState) abortTransaction() error {
+	if s.state != TransInprogress && s.state != TransStart {
+		return errutil.Elog(errutil.Fatal, "AbortTransaction while in %s state", s.state)
+	}
+	s.state = TransAbort
+	err := s.endTransactionId(transam.TransactionStatusAborted)
+	s.callbacks.AtAbortTransaction()
+	return err
+}
+
+// cleanupTransaction はアボートしたトランザクションを終える (CleanupTransaction 相当)
+func (s *TransactionState) cleanupTransaction() error {
+	if s.state != TransAbort {
+		return errutil.Elog(errutil.Fatal, "CleanupTransaction: unexpected state %s", s.state)
+	}
+	s.state = TransDefault
+	return nil
+}
+
+// abortAndCleanup はトランザクションをアボートして終える。アボートを記録できなくても終え、
+// PANIC のエラーを返す。
+func (s *TransactionState) abortAndCleanup() error {
+	err := s.abortTransaction()
+	if s.state != TransAbort {
+		// 想定していない状態で、アボートしていない
+		return err
+	}
+	if cerr := s.cleanupTransaction(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// endTransactionId は割り当てたトランザクション ID の状態を記録し、実行中の一覧から外す
+// (RecordTransactionCommit、RecordTransactionAbort と ProcArrayEndTransaction 相当)。
+// 他のバックエンドが実行中でも記録済みでもない状態を見ないよう、記録してから外す。ID を
+// 割り当てていなくても、スナップショットの xmin は外す。
+//
+// C言語版はクリティカルセクションの中で記録するため、記録できなければ PANIC になる。ここでも
+// pg_xact に記録できなかったエラーを PANIC にして返す。一覧から外さないため、他のバックエンドは
+// サーバーが初期化し直すまで実行中として扱う。
+func (s *TransactionState) endTransactionId(status transam.XidStatus) error {
+	if transam.FullTransactionIdIsValid(s.fullXid) {
+		// WAL はまだないため、ページを書き出す前に同期を待たせる位置はない
+		if err := transam.TransactionIdSetStatus(transam.XidFromFullTransactionId(s.fullXid), status, transam.InvalidXLogRecPtr); err != nil {
+			var edata *errutil.ErrorData
+			if errors.As(err, &edata) {
+				edata.Level = errutil.Panic
+			}
+			return err
+		}
+	}
+	procarray.EndTransaction(s.pid)
+	s.fullXid = 0
+	s.stableLatestXid = transam.InvalidTransactionId
+	s.localXid = 0
+	return nil
+}
+
+// StartTransactionCommand はコマンドを始める (StartTransactionCommand 相当)。トランザクションの
+// 外であればトランザクションを開始する。
+func (s *TransactionState) StartTransactionCommand() error {
+	switch s.blockState {
+	case TBlockDefault:
+		if err := s.startTransaction(); err != nil {
+			return err
+		}
+		s.blockState = TBlockStarted
+	case TBlockInprogress, TBlockImplicitInprogress, TBlockAbort:
+		// ブロックの中。エラーになったブロックでは、コマンドを実行させないのは呼び出し側の役目
+	default:
+		return errutil.Elog(errutil.Fatal, "StartTransactionCommand: unexpected state %s", s.blockState)
+	}
+	return nil
+}
+
+// CommitTransactionCommand はコマンドを終える (CommitTransactionCommand 相当)。ブロックの外の
+// コマンドと、COMMIT か ROLLBACK を実行したブロックはトランザクションを終え、ブロックの中では
+// コマンド ID を進める。
+func (s *TransactionState) CommitTransactionCommand() error {
+	switch s.blockState {
+	case TBlockStarted, TBlockEnd:
+		if err := s.commitTransaction(); err != nil {
+			return err
+		}
+		s.blockState = TBlockDefault
+	case TBlockBegin:
+		// BEGIN を終えたので、以降のコマンドはブロックの中で実行する
+		s.blockState = TBlockInprogress
+	case TBlockInprogress, TBlockImplicitInprogress:
+		return s.CommandCounterIncrement()
+	case TBlockAbort:
+		// エラーになったブロックで、ROLLBACK を待っている
+	case TBlockAbortEnd:
+		if err := s.cleanupTransaction(); err != nil {
+			return err
+		}
+		s.blockState = TBlockDefault
+	case TBlockAbortPending:
+		err := s.abortAndCleanup()
+		s.blockState = TBlockDefault
+		return err
+	default:
+		return errutil.Elog(errutil.Fatal, "CommitTransactionCommand: unexpected state %s", s.blockState)
+	}
+	return nil
+}
+
+// AbortCurrentTransaction はコマンドがエラーになった時にトランザクションをアボートする
+// (AbortCurrentTransaction 相当)。ブロックの中であれば、ブロックは ROLLBACK を待つ状態にする。
+// アボートを記録できなければ PANIC のエラーを返す。
+func (s *TransactionState) AbortCurrentTransaction() error {
+	var err error
+	switch s.blockState {
+	case TBlockDefault:
+		// トランザクションの開始の途中でエラーになった場合だけ、後始末がいる
+		if s.state == TransDefault {
+			return nil
+		}
+		if s.state == TransAbort {
+			return s.cleanupTransaction()
+		}
+		err = s.abortAndCleanup()
+	case TBlockStarted, TBlockBegin, TBlockImplicitInprogress, TBlockEnd, TBlockAbortPending:
+		// COMMIT か ROLLBACK を実行した後のエラーも、ブロックを終える
+		err = s.abortAndCleanup()
+		s.blockState = TBlockDefault
+	case TBlockInprogress:
+		err = s.abortTransaction()
+		s.blockState = TBlockAbort
+	case TBlockAbort:
+		// アボート済み
+	case TBlockAbortEnd:
+		err = s.cleanupTransaction()
+		s.blockState = TBlockDefault
+	default:
+		err = errutil.Elog(errutil.Fatal, "AbortCurrentTransaction: unexpected state %s", s.blockState)
+	}
+	return err
+}
+
+// AbortOutOfAnyTransaction はブロックの中でも外でもトランザクションを終える
+// (AbortOutOfAnyTransaction 相当)。セッションの終了時に使う。アボートを記録できなければ
+// PANIC のエラーを返す。
+func (s *TransactionState) AbortOutOfAnyTransaction() error {
+	var err error
+	switch s.state {
+	case TransDefault:
+	case TransCommit:
+		// コミットを記録できずに PANIC になった。サーバー全体を初期化し直すため、アボートしない
+	case TransAbort:
+		err = s.cleanupTransaction()
+	default:
+		err = s.abortAndCleanup()
+	}
+	s.blockState = TBlockDefault
+	return err
+}
+
+// ----------------------------------------------------------------
+// トランザクションブロック
+// ----------------------------------------------------------------
+
+// BeginTransactionBlock は BEGIN を実行する (BeginTransactionBlock 相当)。既にブロックの中で
+// あれば、何もせずに WARNING を返す。
+func (s *TransactionState) BeginTransactionBlock() (warning *errutil.ErrorData, err error) {
+	switch s.blockState {
+	case TBlockStarted, TBlockImplicitInprogress:
+		// 暗黙のブロックの中の BEGIN は、それまでの文も含む通常のブロックを始める
+		s.blockState = TBlockBegin
+	case TBlockInprogress, TBlockAbort:
+		return errutil.New(errutil.Warning, errcodes.ActiveSQLTransaction, "there is already a transaction in progress"), nil
+	default:
+		return nil, errutil.Elog(errutil.Fatal, "BeginTransactionBlock: unexpected state %s", s.blockState)
+	}
+	return nil, nil
+}
+
+// EndTransactionBlock は COMMIT を実行する (EndTransactionBlock 相当)。エラーになったブロック
+// ではロールバックし、committed に偽を返す。ブロックの外であれば WARNING を返す。
+func (s *TransactionState) EndTransactionBlock() (committed bool, warning *errutil.ErrorData, err error) {
+	switch s.blockState {
+	case TBlockInprogress:
+		s.blockState = TBlockEnd
+		return true, nil, nil
+	case TBlockImplicitInprogress:
+		// BEGIN がないため警告するが、暗黙のブロックのそれまでの文はコミットする
+		s.blockState = TBlockEnd
+		return true, errutil.New(errutil.Warning, errcodes.NoActiveSQLTransaction, "there is no transaction in progress"), nil
+	case TBlockAbort:
+		s.blockState = TBlockAbortEnd
+		return false, nil, nil
+	case TBlockStarted:
+		return true, errutil.New(errutil.Warning, errcodes.NoActiveSQLTransaction, "there is no transaction in progress"), nil
+	}
+	return false, nil, errutil.Elog(errutil.Fatal, "EndTransactionBlock: unexpected state %s", s.blockState)
+}
+
+// UserAbortTransactionBlock は ROLLBACK を実行する (UserAbortTransactionBlock 相当)。ブロックの
+// 外であれば、そのコマンドのトランザクションをアボートして WARNING を返す。
+func (s *TransactionState) UserAbortTransactionBlock() (warning *errutil.ErrorData, err error) {
+	switch s.blockState {
+	case TBlockInprogress:
+		s.blockState = TBlockAbortPending
+	case TBlockAbort:
+		s.blockState = TBlockAbortEnd
+	case TBlockStarted, TBlockImplicitInprogress:
+		// 暗黙のブロックの中では、それまでの文の変更も取り消す
+		s.blockState = TBlockAbortPending
+		return errutil.New(errutil.Warning, errcodes.NoActiveSQLTransaction, "there is no transaction in progress"), nil
+	default:
+		return nil, errutil.Elog(errutil.Fatal, "UserAbortTransactionBlock: unexpected state %s", s.blockState)
+	}
+	return nil, nil
+}
+
+// BeginImplicitTransactionBlock は複数の文からなる単純問い合わせの文を、1つのトランザクションで実行する
+// 暗黙のブロックを始める (BeginImplicitTransactionBlock 相当)。ブロックの中では何もしない。
+func (s *TransactionState) BeginImplicitTransactionBlock() {
+	if s.blockState == TBlockStarted {
+		s.blockState = TBlockImplicitInprogress
+	}
+}
+
+// EndImplicitTransactionBlock は暗黙のブロックを終え、次の CommitTransactionCommand で
+// コミットさせる (EndImplicitTransactionBlock 相当)
+func (s *TransactionState) EndImplicitTransactionBlock() {
+	if s.blockState == TBlockImplicitInprogress {
+		s.blockState = TBlockStarted
+	}
+}
+
+// PreventInTransactionBlock はブロックの中であればエラーを返す (PreventInTransactionBlock 相当)。
+// stmtType はエラーメッセージに使う文の種類。
+func (s *TransactionState) PreventInTransactionBlock(stmtType string) error {
+	if s.IsTransactionBlock() {
+		return errutil.New(errutil.Error, errcodes.ActiveSQLTransaction, "%s cannot run inside a transaction block", stmtType)
+	}
+	return nil
+}
+
+// WarnNoTransactionBlock はブロックの外であれば、効果がないことを知らせる WARNING を返す
+// (WarnNoTransactionBlock 相当)。stmtType はメッセージに使う文の種類。
+func (s *TransactionState) WarnNoTransactionBlock(stmtType string) (warning *errutil.ErrorData) {
+	if !s.IsTransactionBlock() {
+		return errutil.New(errutil.Warning, errcodes.NoActiveSQLTransaction, "%s can only be used in transaction blocks", stmtType)
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+package transam
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/common/controldata"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/buffer"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/fsync"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/page"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/injection"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/pgstat"
+)
+
+// ----------------------------------------------------------------
+// 制御ファイル (access/transam/xlog.c の ReadControlFile、BootStrapXLOG、StartupXLOG、
+// CreateCheckPoint 相当)
+// ----------------------------------------------------------------
+// WAL がまだ存在しないため、xlog.c のうち制御ファイルを作る部分と読む部分、起動の処理と
+// チェックポイントの処理だけを持つ。制御ファイルはブートストラップで作る。data_directory を
+// 指定して起動した場合、postmaster がバックエンドを起動する前に読み、このサーバーで扱える
+// データディレクトリかを確かめてから、制御ファイルに書いたトランザクション ID の予約の終わりから
+// 割り当てを始める。
+
+var control struct {
+	sync.Mutex
+	file *catalog.ControlFileData
+	// mockNonce は制御ファイルを読まずに起動した場合の MockAuthNonce
+	mockNonce []byte
+}
+
+// BootStrapXLOG はブートストラップで制御ファイルと pg_xact の最初のページを作る (BootStrapXLOG 相当)。
+// dataChecksumVersion はデータページのチェックサムの版で、使わない場合は 0 にする。
+func BootStrapXLOG(dataDir string, dataChecksumVersion uint32) error {
+	now := time.Now()
+	cf := &catalog.ControlFileData{
+		// 作成した時刻とプロセス ID から、クラスタごとに異なる値にする
+		SystemIdentifier: uint64(now.Unix())<<32 | uint64(now.Nanosecond()/1000)<<12 | uint64(os.Getpid()&0xFFF),
+		PgControlVersion: catalog.PgControlVersion,
+		CatalogVersionNo: catalog.CatalogVersionNo,
+		State:            catalog.DBShutdowned,
+		Time:             now.Unix(),
+		NextXid:          uint64(FullTransactionIdFromEpochAndXid(0, FirstNormalTransactionId)),
+
+		MaxConnections:     int32(guc.MaxConnections.Get()),
+		MaxWorkerProcesses: int32(guc.MaxWorkerProcesses.Get()),
+
+		BlckSz:              pgconfig.BlckSz,
+		NameDataLen:         adt.NameDataLen,
+		DataChecksumVersion: dataChecksumVersion,
+	}
+	if _, err := rand.Read(cf.MockAuthNonce[:]); err != nil {
+		return fmt.Errorf("could not generate secret authorization token")
+	}
+	if err := controldata.UpdateControlFile(dataDir, cf, true); err != nil {
+		return err
+	}
+	if err := BootStrapCLOG(dataDir); err != nil {
+		return err
+	}
+	setDataChecksumState(dataChecksumVersion)
+
+	control.Lock()
+	defer control.Unlock()
+	control.file = cf
+	return nil
+}
+
+// StartupXLOG は ReadControlFile で読んだ制御ファイルの予約の終わりを次に割り当てるトランザクション
+// ID とし、pg_xact を使えるようにする (StartupXLOG 相当)。WAL がないため、リカバリはしない。
+// 前回のサーバーが異常終了していても、予約の終わりより前の ID しか割り当てていないため、同じ ID を
+// 割り当て直さない。postmaster がバックエンドを起動する前に呼ぶ。
+func StartupXLOG(dataDir string) error {
+	control.Lock()
+	nextXid := adt.FullTransactionId(control.file.NextXid)
+	control.Unlock()
+
+	transamVariables.Lock()
+	transamVariables.nextXid = nextXid
+	transamVariables.reservedXid = nextXid
+	transamVariables.Unlock()
+	return StartupCLOG(dataDir)
+}
+
+// ReadControlFile はデータディレクトリの制御ファイルを読み、このサーバーと互換性があるかを
+// 確かめる (ReadControlFile 相当)
+func ReadControlFile(dataDir string) error {
+	cf, crcOK, err := controldata.GetControlFile(dataDir)
+	if err != nil {
+		return err
+	}
+
+	// CRC より先に版を確かめる。他の版の制御ファイルでは CRC の位置が異なりうるため
+	if cf.PgControlVersion != catalog.PgControlVersion && cf.PgControlVersion%65536 == 0 && cf.PgControlVersion/65536 != 0 {
+		return fmt.Errorf("database files are incompatible with server\n"+
+			"DETAIL:  The database cluster was initialized with PG_CONTROL_VERSION %d (0x%08x), but the server was compiled with PG_CONTROL_VERSION %d (0x%08x).\n"+
+			"HINT:  This could be a problem of mismatched byte ordering.  It looks like you need to initdb.",
+			cf.PgControlVersion, cf.PgControlVersion, catalog.PgControlVersion, catalog.PgControlVersion)
+	}
+	if cf.PgControlVersion != catalog.PgControlVersion {
+		return incompatible("PG_CONTROL_VERSION", int64(cf.PgControlVersion), catalog.PgControlVersion, "It looks like you need to initdb.")
+	}
+	if !crcOK {
+		return fmt.Errorf("incorrect checksum in control file")
+	}
+	if cf.CatalogVersionNo != catalog.CatalogVersionNo {
+		return incompatible("CATALOG_VERSION_NO", int64(cf.CatalogVersionNo), catalog.CatalogVersionNo, "It looks like you need to initdb.")
+	}
+	if cf.BlckSz != pgconfig.BlckSz {
+		return incompatible("BLCKSZ", int64(cf.BlckSz), pgconfig.BlckSz, "It looks like you need to recompile or initdb.")
+	}
+	if cf.NameDataLen != adt.NameDataLen {
+		return incompatible("NAMEDATALEN", int64(cf.NameDataLen), adt.NameDataLen, "It looks like you need to recompile or initdb.")
+	}
+
+	setDataChecksumState(cf.DataChecksumVersion)
+	control.Lock()
+	defer control.Unlock()
+	control.file = cf
+	return nil
+}
+
+// setDataChecksumState は制御ファイルのチェックサムの版からデータチェックサムの状態を決める
+func setDataChecksumState(version uint32) {
+	state := page.DataChecksumsOff
+	if version != 0 {
+		state = page.DataChecksumsOn
+	}
+	page.SetDataChecksumState(state)
+	guc.SetConfigOption("data_checksums", state.String(), guc.PGCInternal, guc.PGCSOverride)
+}
+
+// SetDataChecksumState はデータチェックサムの状態を変え、data_checksums に表示する
+// (SetDataChecksumsOn、SetDataChecksumsOff など相当)。on と off にするときは制御ファイルの
+// data_checksum_version にも記録する。途中の状態は記録しないため、有効化の途中でサーバーが
+// 止まると、再起動した後は off に戻る。
+func SetDataChecksumState(state page.DataChecksumState) error {
+	if state == page.DataChecksumsOn || state == page.DataChecksumsOff {
+		control.Lock()
+		if control.file != nil {
+			version := uint32(0)
+			if state == page.DataChecksumsOn {
+				version = catalog.PgDataChecksumVersion
+			}
+			control.file.DataChecksumVersion = version
+			if err := controldata.UpdateControlFile(guc.DataDirectory.Get(), control.file, true); err != nil {
+				control.Unlock()
+				return err
+			}
+		}
+		control.Unlock()
+	}
+	page.SetDataChecksumState(state)
+	return guc.SetConfigOption("data_checksums", state.String(), guc.PGCInternal, guc.PGCSOverride)
+}
+
+// incompatible は制御ファイルの値がサーバーのビルド時の値と異なることを表すエラーを返す
+func incompatible(name string, got, want int64, hint string) error {
+	return fmt.Errorf("database files are incompatible with server\n"+
+		"DETAIL:  The database cluster was initialized with %s %d, but the server was compiled with %s %d.\n"+
+		"HINT:  %s", name, got, name, want, hint)
+}
+
+// GetMockAuthenticationNonce は存在しないロールへの認証で使う乱数を返す
+// (GetMockAuthenticationNonce 相当)。制御ファイルを読まずに起動した場合は、
+// 起動ごとに作った乱数を返す。
+func GetMockAuthenticationNonce() []byte {
+	control.Lock()
+	defer control.Unlock()
+	if control.file != nil {
+		return control.file.MockAuthNonce[:]
+	}
+	if control.mockNonce == nil {
+		control.mockNonce = make([]byte, catalog.MockAuthNonceLen)
+		rand.Read(control.mockNonce)
+	}
+	return control.mockNonce
+}
+
+// XLogFlush は WAL を record の位置まで書き出して同期する (XLogFlush 相当)。データのページを
+// 書き出す前に、ページを変更した WAL の位置まで呼ぶ。WAL はまだ書かないため、同期を待つものは
+// なく、何もしない。
+func XLogFlush(record XLogRecPtr) error {
+	return nil
+}
+
+// チェックポイントの種類と契機 (CHECKPOINT_* 相当)。RequestCheckpoint と CreateCheckPoint に渡す。
+const (
+	// CheckpointIsShutdown はシャットダウンのチェックポイント
+	CheckpointIsShutdown = 1 << iota
+	// CheckpointImmediate は checkpoint_completion_target で進み具合を調整せずに急いで行う
+	CheckpointImmediate
+	// CheckpointForce は前回のチェックポイントから何も書いていなくても行う
+	CheckpointForce
+	// CheckpointWait はチェックポイントが終わるまで待つ (RequestCheckpoint だけが使う)
+	CheckpointWait
+	// CheckpointRequested はバックエンドが要求したチェックポイント
+	CheckpointRequested
+	// CheckpointCauseTime は checkpoint_timeout の経過によるチェックポイント
+	CheckpointCauseTime
+)
+
+// CreateCheckPoint はチェックポイントを行う (CreateCheckPoint 相当)。pg_xact と共有バッファの
+// 汚れたページを全て書き出してから、前回のチェックポイントの後に登録された同期の要求を全て処理し
+// (CheckPointGuts 相当)、制御ファイルに終えた時刻と状態を書く。シャットダウンのチェックポイント
+// では状態を shut down にし、トランザクション ID の予約を次に割り当てる ID まで戻す。それ以外では
+// 状態を in production にし、予約の終わりは変えない。書き出したバッファの数と、書き出しと
+// 同期にかけた時間は checkpointer の統計に加える。
+//
+// writeDelay が nil でなければ、バッファを1つ書き出すたびに終えた割合を渡して呼び、checkpointer が
+// checkpoint_completion_target に合わせて休む。
+//
+// シャットダウンか CheckpointForce でなく、書き出すものも同期するものもなければ、何もせずに終える。
+func CreateCheckPoint(flags int, writeDelay func(progress float64)) error {
+	shutdown := flags&CheckpointIsShutdown != 0
+	if !shutdown && flags&CheckpointForce == 0 && fsync.PendingSyncRequests() == 0 && !buffer.DirtyBufferExists() && !clogNeedsCheckpoint() {
+		return nil
+	}
+
+	start := time.Now()
+	if guc.LogCheckpoints.Get() {
+		logCheckpointStart(flags)
+	}
+	if shutdown {
+		if err := updateControlFile(catalog.DBShutdowning); err != nil {
+			return err
+		}
+	}
+
+	writeStart := time.Now()
+	if err := CheckPointCLOG(); err != nil {
+		return err
+	}
+	bufsWritten, err := buffer.BufferSync(CheckpointerProc, writeDelay)
+	if err != nil {
+		return err
+	}
+	syncStart := time.Now()
+	stats, err := fsync.ProcessSyncRequests(nil)
+	if err != nil {
+		return err
+	}
+	syncEnd := time.Now()
+
+	// ページを書き終え、制御ファイルを書き換える前に異常終了したときの検証に使う
+	if err := injection.Run("checkpoint-before-control-file"); err != nil {
+		return err
+	}
+	if shutdown {
+		err = shutdownControlFile()
+	} else {
+		err = updateControlFile(catalog.DBInProduction)
+	}
+	if err != nil {
+		return err
+	}
+	pgstat.ReportCheckpointer(pgstat.CheckpointerStats{
+		WriteTime:      syncStart.Sub(writeStart),
+		SyncTime:       syncEnd.Sub(syncStart),
+		BuffersWritten: int64(bufsWritten),
+	})
+	if guc.LogCheckpoints.Get() {
+		logCheckpointEnd(bufsWritten, stats, syncStart.Sub(writeStart), syncEnd.Sub(syncStart), time.Since(start))
+	}
+	return nil
+}
+
+// updateControlFile は制御ファイルの状態と更新した時刻を書き換え、ディスクに同期する
+// (CreateCheckPoint の UpdateControlFile 相当)。制御ファイルを読まずに起動した場合は何もしない。
+func updateControlFile(state catalog.DBState) error {
+	control.Lock()
+	defer control.Unlock()
+	if control.file == nil {
+		return nil
+	}
+	control.file.State = state
+	control.file.Time = time.Now().Unix()
+	return controldata.UpdateControlFile(guc.DataDirectory.Get(), control.file, true)
+}
+
+// updateControlFileNextXid は制御ファイルのトランザクション ID の予約の終わりを nextXid にし、
+// ディスクに同期する。制御ファイルを読まずに起動した場合は何もしない。transamVariables の
+// ロックを持って呼ぶ。
+func updateControlFileNextXid(nextXid adt.FullTransactionId) error {
+	control.Lock()
+	defer control.Unlock()
+	if control.file == nil {
+		return nil
+	}
+	control.file.NextXid = uint64(nextXid)
+	return controldata.UpdateControlFile(guc.DataDirectory.Get(), control.file, true)
+}
+
+// shutdownControlFile はシャットダウンのチェックポイントの終わりに、制御ファイルの状態を
+// shut down にし、トランザクション ID の予約を次に割り当てる ID まで戻す。次に起動したときに
+// 予約して使わなかった ID を飛ばさないためである。
+func shutdownControlFile() error {
+	transamVariables.Lock()
+	defer transamVariables.Unlock()
+	control.Lock()
+	defer control.Unlock()
+	if control.file == nil {
+		return nil
+	}
+	nextXid := transamVariables.nextXid
+	control.file.State = catalog.DBShutdowned
+	control.file.NextXid = uint64(nextXid)
+	control.file.Time = time.Now().Unix()
+	if err := controldata.UpdateControlFile(guc.DataDirectory.Get(), control.file, true); err != nil {
+		return err
+	}
+	transamVariables.reservedXid = nextXid
+	return nil
+}
+
+// CheckpointerProc はサーバーログに出す checkpointer の情報。チェックポイントのログは
+// checkpointer のメッセージとして出す
+var CheckpointerProc = errutil.NewAuxProcInfo("checkpointer")
+
+// logCheckpointStart はチェックポイントを始めたことをログに出す (LogCheckpointStart 相当)
+func logCheckpointStart(flags int) {
+	var b strings.Builder
+	for _, f := range []struct {
+		flag int
+		name string
+	}{
+		{CheckpointIsShutdown, " shutdown"},
+		{CheckpointImmediate, " immediate"},
+		{CheckpointForce, " force"},
+		{CheckpointWait, " wait"},
+		{CheckpointCauseTime, " time"},
+	} {
+		if flags&f.flag != 0 {
+			b.WriteString(f.name)
+		}
+	}
+	errutil.Report(CheckpointerProc, errutil.Elog(errutil.Log, "checkpoint starting:%s", b.String()))
+}
+
+// logCheckpointEnd はチェックポイントを終えたことと、かかった時間をログに出す (LogCheckpointEnd 相当)。
+// WAL がないため、書き出したバッファと同期したファイルのことだけを出す。
+func logCheckpointEnd(bufsWritten int, stats fsync.SyncStats, write, sync, total time.Duration) {
+	var average time.Duration
+	if stats.Files > 0 {
+		average = stats.Total / time.Duration(stats.Files)
+	}
+	var percent float64
+	if n := guc.SharedBuffers.Get(); n > 0 {
+		percent = float64(bufsWritten) * 100 / float64(n)
+	}
+	errutil.Report(CheckpointerProc, errutil.Elog(errutil.Log, "checkpoint complete: wrote %d buffers (%.1f%%); write=%s s, sync=%s s, total=%s s; sync files=%d, longest=%s s, average=%s s",
+		bufsWritten, percent, formatSeconds(write), formatSeconds(sync), formatSeconds(total), stats.Files, formatSeconds(stats.Longest), formatSeconds(average)))
+}
+
+// formatSeconds は時間を秒とミリ秒の "%ld.%03d" の形にする
+func formatSeconds(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%d.%03d", ms/1000, ms%1000)
+}
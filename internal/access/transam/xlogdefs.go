@@ -0,0 +1,11 @@
+package transam
+
+// ----------------------------------------------------------------
+// WAL の位置 (access/xlogdefs.h 相当)
+// ----------------------------------------------------------------
+
+// XLogRecPtr は WAL の中の位置。WAL の先頭からのバイト数で表す (XLogRecPtr 相当)
+type XLogRecPtr uint64
+
+// InvalidXLogRecPtr は位置がないことを表す (InvalidXLogRecPtr 相当)
+const InvalidXLogRecPtr XLogRecPtr = 0
@@ -0,0 +1,681 @@
+package hba
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// クライアント認証の設定 (libpq/hba.c, libpq/hba.h 相当)
+// ----------------------------------------------------------------
+// pg_hba.conf の各行は「接続の種類、データベース、ユーザー、接続元のアドレス、
+// 認証方式、認証のオプション」からなる。接続のたびに先頭の行から順に調べ、
+// 最初に一致した行の認証方式を使う。一致する行がなければ接続を拒否する。
+//
+// C言語版では postmaster が読み込んだ結果を fork で子プロセスに引き継ぐ。Go言語版では
+// 読み込んだ結果を差し替えて公開し、バックエンドは接続の時点のものを参照する。
+// SIGHUP による再読み込みで誤りが見つかった場合は、以前の設定を使い続ける。
+
+// ConnType は接続の種類 (ConnType 相当)
+type ConnType int
+
+const (
+	CtLocal ConnType = iota
+	CtHost
+	CtHostSSL
+	CtHostNoSSL
+	CtHostGSS
+	CtHostNoGSS
+)
+
+// UserAuth は認証方式 (UserAuth 相当)
+type UserAuth int
+
+const (
+	UaReject UserAuth = iota
+	UaImplicitReject
+	UaTrust
+	UaIdent
+	UaPassword
+	UaMD5
+	UaSCRAM
+	UaGSS
+	UaSSPI
+	UaPAM
+	UaBSD
+	UaLDAP
+	UaCert
+	UaRADIUS
+	UaPeer
+)
+
+// userAuthNames は認証方式の名前 (UserAuthName 相当)
+var userAuthNames = [...]string{
+	UaReject:         "reject",
+	UaImplicitReject: "implicit reject",
+	UaTrust:          "trust",
+	UaIdent:          "ident",
+	UaPassword:       "password",
+	UaMD5:            "md5",
+	UaSCRAM:          "scram-sha-256",
+	UaGSS:            "gss",
+	UaSSPI:           "sspi",
+	UaPAM:            "pam",
+	UaBSD:            "bsd",
+	UaLDAP:           "ldap",
+	UaCert:           "cert",
+	UaRADIUS:         "radius",
+	UaPeer:           "peer",
+}
+
+func (m UserAuth) String() string {
+	return userAuthNames[m]
+}
+
+// ipCompareMethod は接続元のアドレスの比較方法 (IPCompareMethod 相当)
+type ipCompareMethod int
+
+const (
+	ipCmpMask ipCompareMethod = iota
+	ipCmpSameHost
+	ipCmpSameNet
+	ipCmpAll
+)
+
+// ClientCertMode は clientcert オプションの値 (ClientCertMode 相当)
+type ClientCertMode int
+
+const (
+	ClientCertOff ClientCertMode = iota
+	ClientCertCA
+	ClientCertFull
+)
+
+// ClientCertName は clientname オプションの値。クライアント証明書のどの部分を
+// ユーザー名と比べるかを表す (ClientCertName 相当)
+type ClientCertName int
+
+const (
+	ClientCertCN ClientCertName = iota
+	ClientCertDN
+)
+
+// HbaLine は pg_hba.conf の1行 (HbaLine 相当)
+type HbaLine struct {
+	SourceFile string
+	LineNum    int
+	RawLine    string
+
+	ConnType  ConnType
+	Databases []AuthToken
+	Roles     []AuthToken
+
+	ipCmp    ipCompareMethod
+	addr     net.IPNet
+	hostname string
+
+	AuthMethod     UserAuth
+	UserMap        string
+	ClientCert     ClientCertMode
+	ClientCertName ClientCertName
+}
+
+// parsedHbaLines は読み込み済みの設定
+var parsedHbaLines atomic.Pointer[[]*HbaLine]
+
+// defaultHbaConf は hba_file を指定しない場合の設定。全ての接続を信頼する。
+const defaultHbaConf = "local all all trust\nhost all all all trust"
+
+// ErrNotLoaded は設定を読み込めなかったことを表す。誤りの内容は読み込み時にログに出力する。
+var ErrNotLoaded = errors.New("could not load pg_hba.conf")
+
+// Load は pg_hba.conf を読み込む (load_hba 相当)。filename が空の場合は defaultHbaConf を使う。
+// 1行でも誤りがあれば、それまでの設定を変更せずに ErrNotLoaded を返す。
+func Load(filename string) error {
+	var tokLines []TokenizedLine
+	var err error
+	if filename == "" {
+		filename = "(default)"
+		tokLines, err = tokenize(strings.NewReader(defaultHbaConf))
+	} else {
+		tokLines, err = tokenizeFile(filename)
+	}
+	if err != nil {
+		errutil.Report(nil, errutil.New(errutil.Log, errcodes.ConfigFileError, "could not open configuration file \"%s\": %s", filename, platform.OSErrorMessage(err)))
+		return ErrNotLoaded
+	}
+
+	ok := true
+	var lines []*HbaLine
+	for _, tl := range tokLines {
+		if tl.Err != "" {
+			logParseError(filename, tl.LineNum, tl.Err, "")
+			ok = false
+			continue
+		}
+		line, err := parseHbaLine(filename, tl)
+		if err != nil {
+			var pe *parseError
+			if errors.As(err, &pe) {
+				logParseError(filename, tl.LineNum, pe.msg, pe.hint)
+			}
+			ok = false
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if !ok {
+		return ErrNotLoaded
+	}
+	// 行が1つもない場合は全ての接続を拒否することになる。C言語版と同様に読み込みは成功とする。
+	parsedHbaLines.Store(&lines)
+	return nil
+}
+
+// parseError は1行の解析の誤り
+type parseError struct {
+	msg  string
+	hint string
+}
+
+func (e *parseError) Error() string { return e.msg }
+
+func errorf(format string, args ...any) error {
+	return &parseError{msg: fmt.Sprintf(format, args...)}
+}
+
+func errorHint(hint, format string, args ...any) error {
+	return &parseError{msg: fmt.Sprintf(format, args...), hint: hint}
+}
+
+// logParseError は解析の誤りを、ファイル名と行番号とともにログに出力する
+func logParseError(filename string, lineno int, msg, hint string) {
+	edata := errutil.New(errutil.Log, errcodes.ConfigFileError, "%s", msg)
+	edata.Hint = hint
+	edata.Context = fmt.Sprintf("line %d of configuration file \"%s\"", lineno, filename)
+	errutil.Report(nil, edata)
+}
+
+// ----------------------------------------------------------------
+// 1行の解析 (parse_hba_line 相当)
+// ----------------------------------------------------------------
+
+func parseHbaLine(filename string, tl TokenizedLine) (*HbaLine, error) {
+	line := &HbaLine{SourceFile: filename, LineNum: tl.LineNum, RawLine: tl.RawLine}
+	fields := tl.Fields
+
+	// 接続の種類
+	if len(fields[0]) > 1 {
+		return nil, errorHint("Specify exactly one connection type per line.", "multiple values specified for connection type")
+	}
+	switch ct := fields[0][0].String; ct {
+	case "local":
+		line.ConnType = CtLocal
+	case "host":
+		line.ConnType = CtHost
+	case "hostssl":
+		line.ConnType = CtHostSSL
+		if !libpq.SSLLoaded() {
+			logParseError(filename, tl.LineNum, "hostssl record cannot match because SSL is disabled", "Set \"ssl = on\" in postgresql.conf.")
+		}
+	case "hostnossl":
+		line.ConnType = CtHostNoSSL
+	case "hostgssenc":
+		line.ConnType = CtHostGSS
+		logParseError(filename, tl.LineNum, "hostgssenc record cannot match because GSSAPI is not supported by this build", "")
+	case "hostnogssenc":
+		line.ConnType = CtHostNoGSS
+	default:
+		return nil, errorf("invalid connection type \"%s\"", ct)
+	}
+	fields = fields[1:]
+
+	// データベース
+	if len(fields) == 0 {
+		return nil, errorf("end-of-line before database specification")
+	}
+	dbs, err := compileTokens(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	line.Databases = dbs
+	fields = fields[1:]
+
+	// ユーザー
+	if len(fields) == 0 {
+		return nil, errorf("end-of-line before role specification")
+	}
+	roles, err := compileTokens(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	line.Roles = roles
+	fields = fields[1:]
+
+	// 接続元のアドレス (local 以外)
+	if line.ConnType != CtLocal {
+		if len(fields) == 0 {
+			return nil, errorf("end-of-line before IP address specification")
+		}
+		if fields, err = line.parseAddress(fields); err != nil {
+			return nil, err
+		}
+	}
+
+	// 認証方式
+	if len(fields) == 0 {
+		return nil, errorf("end-of-line before authentication method")
+	}
+	if len(fields[0]) > 1 {
+		return nil, errorHint("Specify exactly one authentication type per line.", "multiple values specified for authentication type")
+	}
+	if err := line.parseAuthMethod(fields[0][0].String); err != nil {
+		return nil, err
+	}
+	fields = fields[1:]
+
+	// 認証のオプション
+	for _, field := range fields {
+		for _, tok := range field {
+			name, value, ok := strings.Cut(tok.String, "=")
+			if !ok {
+				return nil, errorf("authentication option not in name=value format: %s", tok.String)
+			}
+			if err := line.parseOption(name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// cert 認証は常にクライアント証明書を検証する
+	if line.AuthMethod == UaCert {
+		line.ClientCert = ClientCertFull
+	}
+	return line, nil
+}
+
+// compileTokens は "/" で始まるトークンを正規表現として検査する
+func compileTokens(toks []AuthToken) ([]AuthToken, error) {
+	out := make([]AuthToken, len(toks))
+	for i, t := range toks {
+		if t.isRegexp() {
+			re, err := regexp.Compile(t.String[1:])
+			if err != nil {
+				return nil, errorf("invalid regular expression \"%s\": %s", t.String[1:], err.Error())
+			}
+			t.regexp = re
+		}
+		out[i] = t
+	}
+	return out, nil
+}
+
+// parseAddress は接続元のアドレスと、必要であれば続くネットマスクを解析し、残りのフィールドを返す
+func (line *HbaLine) parseAddress(fields [][]AuthToken) ([][]AuthToken, error) {
+	if len(fields[0]) > 1 {
+		return nil, errorHint("Specify one address range per line.", "multiple values specified for host address")
+	}
+	tok := fields[0][0]
+	fields = fields[1:]
+
+	switch {
+	case tok.isKeyword("all"):
+		line.ipCmp = ipCmpAll
+		return fields, nil
+	case tok.isKeyword("samehost"):
+		line.ipCmp = ipCmpSameHost
+		return fields, nil
+	case tok.isKeyword("samenet"):
+		line.ipCmp = ipCmpSameNet
+		return fields, nil
+	}
+
+	line.ipCmp = ipCmpMask
+	str, cidr, hasCIDR := strings.Cut(tok.String, "/")
+	ip := parseIP(str)
+	if ip == nil {
+		// IP アドレスでなければホスト名とみなす
+		if hasCIDR {
+			return nil, errorf("specifying both host name and CIDR mask is invalid: \"%s\"", tok.String)
+		}
+		line.hostname = tok.String
+		return fields, nil
+	}
+	line.addr.IP = ip
+
+	if hasCIDR {
+		bits, err := strconv.Atoi(cidr)
+		if err != nil || bits < 0 || bits > len(ip)*8 {
+			return nil, errorf("invalid CIDR mask in address \"%s\"", tok.String)
+		}
+		line.addr.Mask = net.CIDRMask(bits, len(ip)*8)
+	} else {
+		// CIDR 表記でなければ、次のフィールドがネットマスク
+		if len(fields) == 0 {
+			return nil, errorHint("Specify an address range in CIDR notation, or provide a separate netmask.",
+				"end-of-line before netmask specification")
+		}
+		if len(fields[0]) > 1 {
+			return nil, errorf("multiple values specified for netmask")
+		}
+		mask := parseIP(fields[0][0].String)
+		if mask == nil {
+			return nil, errorf("invalid IP mask \"%s\": %s", fields[0][0].String, "Name or service not known")
+		}
+		if len(mask) != len(ip) {
+			return nil, errorf("IP address and mask do not match")
+		}
+		line.addr.Mask = net.IPMask(mask)
+		fields = fields[1:]
+	}
+	return fields, nil
+}
+
+// parseIP は IP アドレスを解析する。IPv4 アドレスは4バイトで返す。
+func parseIP(s string) net.IP {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil
+	}
+	if !strings.Contains(s, ":") {
+		return ip.To4()
+	}
+	return ip
+}
+
+// parseAuthMethod は認証方式を解析する
+func (line *HbaLine) parseAuthMethod(method string) error {
+	unsupported := false
+	switch method {
+	case "trust":
+		line.AuthMethod = UaTrust
+	case "reject":
+		line.AuthMethod = UaReject
+	case "ident":
+		line.AuthMethod = UaIdent
+	case "peer":
+		line.AuthMethod = UaPeer
+	case "password":
+		line.AuthMethod = UaPassword
+	case "md5":
+		line.AuthMethod = UaMD5
+	case "scram-sha-256":
+		line.AuthMethod = UaSCRAM
+	case "cert":
+		line.AuthMethod = UaCert
+	case "gss":
+		line.AuthMethod, unsupported = UaGSS, true
+	case "sspi":
+		line.AuthMethod, unsupported = UaSSPI, true
+	case "pam":
+		line.AuthMethod, unsupported = UaPAM, true
+	case "bsd":
+		line.AuthMethod, unsupported = UaBSD, true
+	case "ldap":
+		line.AuthMethod, unsupported = UaLDAP, true
+	case "radius":
+		line.AuthMethod, unsupported = UaRADIUS, true
+	default:
+		return errorf("invalid authentication method \"%s\"", method)
+	}
+	if unsupported {
+		return errorf("invalid authentication method \"%s\": not supported by this build", method)
+	}
+
+	switch {
+	case line.ConnType == CtLocal && line.AuthMethod == UaIdent:
+		// Unix ドメインソケットでの ident は peer として扱う
+		line.AuthMethod = UaPeer
+	case line.ConnType != CtLocal && line.AuthMethod == UaPeer:
+		return errorf("peer authentication is only supported on local sockets")
+	case line.ConnType != CtHostSSL && line.AuthMethod == UaCert:
+		return errorf("cert authentication is only supported on hostssl connections")
+	}
+	return nil
+}
+
+// parseOption は認証のオプション "名前=値" を解析する (parse_hba_auth_opt 相当)
+func (line *HbaLine) parseOption(name, value string) error {
+	switch name {
+	case "map":
+		switch line.AuthMethod {
+		case UaIdent, UaPeer, UaCert:
+		default:
+			return errorf("authentication option \"%s\" is only valid for authentication methods %s", name, "ident, peer, gssapi, sspi, and cert")
+		}
+		line.UserMap = value
+	case "clientcert":
+		if line.ConnType != CtHostSSL {
+			return errorf("clientcert can only be configured for \"hostssl\" rows")
+		}
+		switch value {
+		case "verify-full":
+			line.ClientCert = ClientCertFull
+		case "verify-ca":
+			if line.AuthMethod == UaCert {
+				return errorf("clientcert only accepts \"verify-full\" when using \"cert\" authentication")
+			}
+			line.ClientCert = ClientCertCA
+		default:
+			return errorf("invalid value for clientcert: \"%s\"", value)
+		}
+	case "clientname":
+		if line.ConnType != CtHostSSL {
+			return errorf("clientname can only be configured for \"hostssl\" rows")
+		}
+		switch value {
+		case "CN":
+			line.ClientCertName = ClientCertCN
+		case "DN":
+			line.ClientCertName = ClientCertDN
+		default:
+			return errorf("invalid value for clientname: \"%s\"", value)
+		}
+	default:
+		return errorf("unrecognized authentication option name: \"%s\"", name)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// 接続に一致する行の検索 (check_hba, hba_getauthmethod 相当)
+// ----------------------------------------------------------------
+
+// CheckHba は接続に一致する最初の行を返す (check_hba 相当)。
+// 一致する行がなければ、認証方式が UaImplicitReject の行を返す。proc はログに出す
+// バックエンドの情報。
+func CheckHba(proc *errutil.ProcInfo, port *libpq.Port) *HbaLine {
+	lines := parsedHbaLines.Load()
+	if lines != nil {
+		clientIP := parseIP(port.RemoteHost)
+		var remoteHostname *string
+		for _, line := range *lines {
+			// local の行は Unix ドメインソケットの接続にだけ、それ以外の行は TCP/IP の接続にだけ一致する
+			if port.IsUnixSocket() {
+				if line.ConnType != CtLocal {
+					continue
+				}
+			} else {
+				if line.ConnType == CtLocal || !line.matchConnType(port) {
+					continue
+				}
+				if !line.matchAddress(proc, clientIP, &remoteHostname) {
+					continue
+				}
+			}
+			if !checkDB(port.DatabaseName, port.UserName, line.Databases) {
+				continue
+			}
+			if !checkRole(port.UserName, line.Roles) {
+				continue
+			}
+			return line
+		}
+	}
+	return &HbaLine{AuthMethod: UaImplicitReject}
+}
+
+// matchConnType は接続の暗号化の有無が行の種類に合うかを返す
+func (line *HbaLine) matchConnType(port *libpq.Port) bool {
+	switch line.ConnType {
+	case CtHostSSL:
+		return port.SSLInUse
+	case CtHostNoSSL:
+		return !port.SSLInUse
+	case CtHostGSS:
+		// GSSAPI による暗号化は扱わない
+		return false
+	}
+	return true
+}
+
+// matchAddress は接続元のアドレスが行に一致するかを返す。
+// ホスト名の逆引きの結果は remoteHostname に保存し、同じ接続で繰り返し引かない。
+func (line *HbaLine) matchAddress(proc *errutil.ProcInfo, clientIP net.IP, remoteHostname **string) bool {
+	if clientIP == nil {
+		return false
+	}
+	switch line.ipCmp {
+	case ipCmpAll:
+		return true
+	case ipCmpSameHost, ipCmpSameNet:
+		return checkSameHostOrNet(proc, clientIP, line.ipCmp)
+	}
+	if line.hostname != "" {
+		return checkHostname(clientIP, line.hostname, remoteHostname)
+	}
+	return len(clientIP) == len(line.addr.IP) && line.addr.Contains(clientIP)
+}
+
+// checkSameHostOrNet は接続元がサーバー自身のアドレス、またはサーバーが直接つながっている
+// ネットワークのアドレスかを返す (check_same_host_or_net 相当)
+func checkSameHostOrNet(proc *errutil.ProcInfo, clientIP net.IP, method ipCompareMethod) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		errutil.Report(proc, errutil.Elog(errutil.Log, "error enumerating network interfaces: %s", err.Error()))
+		return false
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipnet.IP
+		if ip4 := ip.To4(); ip4 != nil && len(ipnet.Mask) == net.IPv4len {
+			ip = ip4
+		}
+		if len(ip) != len(clientIP) {
+			continue
+		}
+		if method == ipCmpSameHost {
+			if ip.Equal(clientIP) {
+				return true
+			}
+		} else if (&net.IPNet{IP: ip.Mask(ipnet.Mask), Mask: ipnet.Mask}).Contains(clientIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHostname は接続元のホスト名が行のホスト名に一致するかを返す (check_hostname 相当)。
+// 接続元のアドレスを逆引きした名前を正引きし、接続元のアドレスに戻ることも確かめる。
+// "." で始まる名前は、その後ろの部分が一致すればよい。
+func checkHostname(clientIP net.IP, hostname string, remoteHostname **string) bool {
+	if *remoteHostname == nil {
+		name := ""
+		if names, err := net.LookupAddr(clientIP.String()); err == nil && len(names) > 0 {
+			name = strings.TrimSuffix(names[0], ".")
+		}
+		*remoteHostname = &name
+	}
+	name := **remoteHostname
+	if name == "" {
+		return false
+	}
+
+	if strings.HasPrefix(hostname, ".") {
+		if !strings.HasSuffix(strings.ToLower(name), strings.ToLower(hostname)) {
+			return false
+		}
+	} else if !strings.EqualFold(name, hostname) {
+		return false
+	}
+
+	ips, err := net.LookupIP(name)
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if ip.Equal(clientIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDB はデータベース名が行のデータベースの指定に一致するかを返す (check_db 相当)
+func checkDB(dbname, role string, tokens []AuthToken) bool {
+	for _, tok := range tokens {
+		switch {
+		case tok.isKeyword("all"):
+			return true
+		case tok.isKeyword("sameuser"):
+			if dbname == role {
+				return true
+			}
+		case tok.isKeyword("samegroup"), tok.isKeyword("samerole"):
+			if isMember(role, dbname) {
+				return true
+			}
+		case tok.isKeyword("replication"):
+			// レプリケーション接続はまだ受け付けない
+			continue
+		case tok.regexp != nil:
+			if tok.regexp.MatchString(dbname) {
+				return true
+			}
+		case tok.String == dbname:
+			return true
+		}
+	}
+	return false
+}
+
+// checkRole はユーザー名が行のユーザーの指定に一致するかを返す (check_role 相当)
+func checkRole(role string, tokens []AuthToken) bool {
+	for _, tok := range tokens {
+		switch {
+		case tok.isKeyword("all"):
+			return true
+		case tok.isMemberCheck():
+			if isMember(role, tok.String[1:]) {
+				return true
+			}
+		case tok.regexp != nil:
+			if tok.regexp.MatchString(role) {
+				return true
+			}
+		case tok.String == role:
+			return true
+		}
+	}
+	return false
+}
+
+// isMember は role が group のメンバーかを返す (is_member 相当)。
+// スーパーユーザーであっても、メンバーでなければ一致しない。
+func isMember(role, group string) bool {
+	return catalog.IsMemberOfRoleNosuper(role, group)
+}
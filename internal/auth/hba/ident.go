@@ -0,0 +1,189 @@
+package hba
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// ユーザー名の対応付け (hba.c の load_ident, check_usermap 相当)
+// ----------------------------------------------------------------
+// pg_ident.conf の各行は「対応付けの名前、システムのユーザー名、データベースのユーザー名」からなる。
+// pg_hba.conf の行の map オプションで名前を指定すると、peer や cert などの認証方式で
+// 確かめたシステムのユーザー名 (OS のユーザー名や証明書の CN) が、接続しようとしている
+// データベースのユーザー名に対応付けられているかを調べる。
+//
+// システムのユーザー名が "/" で始まる場合は正規表現とし、データベースのユーザー名に含まれる
+// "\1" を正規表現の最初の括弧に一致した部分で置き換える。データベースのユーザー名には
+// "all"、"+ロール名"、"/正規表現" も指定できる。
+
+// IdentLine は pg_ident.conf の1行 (IdentLine 相当)
+type IdentLine struct {
+	LineNum int
+	RawLine string
+
+	Usermap    string
+	SystemUser AuthToken
+	PgUser     AuthToken
+}
+
+// parsedIdentLines は読み込み済みの対応付け
+var parsedIdentLines atomic.Pointer[[]*IdentLine]
+
+// ErrIdentNotLoaded は対応付けを読み込めなかったことを表す。誤りの内容は読み込み時にログに出力する。
+var ErrIdentNotLoaded = errors.New("could not load pg_ident.conf")
+
+// LoadIdent は pg_ident.conf を読み込む (load_ident 相当)。filename が空の場合は対応付けを持たない。
+// 1行でも誤りがあれば、それまでの対応付けを変更せずに ErrIdentNotLoaded を返す。
+func LoadIdent(filename string) error {
+	var tokLines []TokenizedLine
+	if filename != "" {
+		var err error
+		if tokLines, err = tokenizeFile(filename); err != nil {
+			errutil.Report(nil, errutil.New(errutil.Log, errcodes.ConfigFileError, "could not open usermap file \"%s\": %s", filename, platform.OSErrorMessage(err)))
+			return ErrIdentNotLoaded
+		}
+	}
+
+	ok := true
+	var lines []*IdentLine
+	for _, tl := range tokLines {
+		if tl.Err != "" {
+			logParseError(filename, tl.LineNum, tl.Err, "")
+			ok = false
+			continue
+		}
+		line, err := parseIdentLine(tl)
+		if err != nil {
+			var pe *parseError
+			if errors.As(err, &pe) {
+				logParseError(filename, tl.LineNum, pe.msg, pe.hint)
+			}
+			ok = false
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if !ok {
+		return ErrIdentNotLoaded
+	}
+	parsedIdentLines.Store(&lines)
+	return nil
+}
+
+// parseIdentLine は1行を解析する (parse_ident_line 相当)
+func parseIdentLine(tl TokenizedLine) (*IdentLine, error) {
+	line := &IdentLine{LineNum: tl.LineNum, RawLine: tl.RawLine}
+	fields := tl.Fields
+	if len(fields) < 3 {
+		return nil, errorf("missing entry at end of line")
+	}
+	for _, f := range fields[:3] {
+		if len(f) > 1 {
+			return nil, errorf("multiple values in ident field")
+		}
+	}
+	line.Usermap = fields[0][0].String
+
+	sysUser, err := compileTokens(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	line.SystemUser = sysUser[0]
+
+	// \1 を含む場合は、置き換えた後で正規表現をコンパイルする
+	line.PgUser = fields[2][0]
+	if !strings.Contains(line.PgUser.String, `\1`) {
+		pgUser, err := compileTokens(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		line.PgUser = pgUser[0]
+	}
+	return line, nil
+}
+
+// CheckUsermap はシステムのユーザー名 systemUser が、対応付け usermapName でデータベースの
+// ユーザー名 pgRole に対応付けられているかを返す (check_usermap 相当)。
+// usermapName が空の場合は2つの名前が一致するかを調べる。一致しない理由は proc のバックエンドの
+// ログとして出力する。
+func CheckUsermap(proc *errutil.ProcInfo, usermapName, pgRole, systemUser string) bool {
+	if usermapName == "" {
+		if pgRole == systemUser {
+			return true
+		}
+		errutil.Report(proc, errutil.Elog(errutil.Log, "provided user name (%s) and authenticated user name (%s) do not match",
+			pgRole, systemUser))
+		return false
+	}
+
+	if lines := parsedIdentLines.Load(); lines != nil {
+		for _, line := range *lines {
+			if line.Usermap != usermapName {
+				continue
+			}
+			found, failed := line.check(proc, pgRole, systemUser)
+			if failed {
+				// 正規表現の誤りがあれば、後続の行は調べずに失敗とする
+				return false
+			}
+			if found {
+				return true
+			}
+		}
+	}
+	errutil.Report(proc, errutil.Elog(errutil.Log, "no match in usermap \"%s\" for user \"%s\" authenticated as \"%s\"",
+		usermapName, pgRole, systemUser))
+	return false
+}
+
+// check は1行が名前の組に一致するかを返す (check_ident_usermap 相当)。
+// 行の指定が誤っていた場合は failed に true を返す。
+func (line *IdentLine) check(proc *errutil.ProcInfo, pgRole, systemUser string) (found, failed bool) {
+	pgUser := line.PgUser
+	if re := line.SystemUser.regexp; re != nil {
+		m := re.FindStringSubmatch(systemUser)
+		if m == nil {
+			return false, false
+		}
+		// データベースのユーザー名の \1 を、最初の括弧に一致した部分で置き換える
+		if strings.Contains(pgUser.String, `\1`) {
+			if len(m) < 2 {
+				errutil.Report(proc, errutil.New(errutil.Log, errcodes.InvalidRegularExpression,
+					"regular expression \"%s\" has no subexpressions as requested by backreference in \"%s\"",
+					line.SystemUser.String[1:], pgUser.String))
+				return false, true
+			}
+			pgUser.String = strings.Replace(pgUser.String, `\1`, m[1], 1)
+			if pgUser.isRegexp() {
+				// 置き換えた後の文字列を正規表現としてコンパイルする
+				res, err := compileTokens([]AuthToken{pgUser})
+				if err != nil {
+					var pe *parseError
+					if errors.As(err, &pe) {
+						errutil.Report(proc, errutil.New(errutil.Log, errcodes.InvalidRegularExpression, "%s", pe.msg))
+					}
+					return false, true
+				}
+				pgUser = res[0]
+			}
+		}
+	} else if line.SystemUser.String != systemUser {
+		return false, false
+	}
+
+	switch {
+	case pgUser.isKeyword("all"):
+		return true, false
+	case pgUser.isMemberCheck():
+		return isMember(pgRole, pgUser.String[1:]), false
+	case pgUser.regexp != nil:
+		return pgUser.regexp.MatchString(pgRole), false
+	}
+	return pgUser.String == pgRole, false
+}
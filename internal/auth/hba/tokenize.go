@@ -0,0 +1,164 @@
+package hba
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// 設定ファイルの字句解析 (hba.c の next_token, tokenize_auth_file 相当)
+// ----------------------------------------------------------------
+// 各行は空白で区切ったフィールドの並びで、1つのフィールドはカンマで区切った
+// 複数のトークンからなる。"#" から行末まではコメント、行末の "\" は次の行に続くことを表す。
+// 二重引用符で囲んだトークンは空白やカンマを含められ、キーワード ("all" など) としては扱わない。
+
+// AuthToken は1つのトークン (AuthToken 相当)
+type AuthToken struct {
+	String string
+	Quoted bool
+	// regexp は "/" で始まるトークンを正規表現としてコンパイルしたもの
+	regexp *regexp.Regexp
+}
+
+// isKeyword はトークンがキーワード kw (引用符なし) かを返す (token_is_keyword 相当)
+func (t AuthToken) isKeyword(kw string) bool {
+	return !t.Quoted && t.String == kw
+}
+
+// isMemberCheck はトークンが "+ロール名" の形 (ロールのメンバーかを調べる) かを返す
+func (t AuthToken) isMemberCheck() bool {
+	return !t.Quoted && strings.HasPrefix(t.String, "+") && len(t.String) > 1
+}
+
+// isRegexp はトークンが "/正規表現" の形かを返す
+func (t AuthToken) isRegexp() bool {
+	return !t.Quoted && strings.HasPrefix(t.String, "/") && len(t.String) > 1
+}
+
+// TokenizedLine は字句解析した1行 (TokenizedAuthLine 相当)
+type TokenizedLine struct {
+	Fields  [][]AuthToken
+	LineNum int
+	RawLine string
+	Err     string // 字句解析の誤り。空でなければ Fields は使わない
+}
+
+// tokenizeFile は設定ファイルを行ごとに字句解析する (tokenize_auth_file 相当)
+func tokenizeFile(filename string) ([]TokenizedLine, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file \"%s\": %w", filename, err)
+	}
+	defer f.Close()
+	return tokenize(f)
+}
+
+func tokenize(r io.Reader) ([]TokenizedLine, error) {
+	var lines []TokenizedLine
+	sc := bufio.NewScanner(r)
+	lineno := 0
+	for sc.Scan() {
+		lineno++
+		startLine := lineno
+		raw := sc.Text()
+		// 行末の "\" は継続行
+		for strings.HasSuffix(raw, `\`) && sc.Scan() {
+			lineno++
+			raw = raw[:len(raw)-1] + sc.Text()
+		}
+
+		tl := TokenizedLine{LineNum: startLine, RawLine: raw}
+		rest := raw
+		for {
+			field, r, errmsg := nextField(rest)
+			if errmsg != "" {
+				tl.Err = errmsg
+				break
+			}
+			if field == nil {
+				break
+			}
+			tl.Fields = append(tl.Fields, field)
+			rest = r
+		}
+		if len(tl.Fields) > 0 || tl.Err != "" {
+			lines = append(lines, tl)
+		}
+	}
+	return lines, sc.Err()
+}
+
+// nextField は s の先頭から1つのフィールド (カンマで区切ったトークンの並び) を取り出す
+// (next_field_expand 相当)。フィールドがなければ nil を返す。
+func nextField(s string) (field []AuthToken, rest string, errmsg string) {
+	for {
+		tok, r, sawComma, ok, errmsg := nextToken(s)
+		if errmsg != "" {
+			return nil, "", errmsg
+		}
+		if !ok {
+			return field, r, ""
+		}
+		field = append(field, tok)
+		s = r
+		if !sawComma {
+			return field, s, ""
+		}
+	}
+}
+
+// nextToken は s の先頭から1つのトークンを取り出す (next_token 相当)。
+// sawComma はトークンの直後にカンマがあり、同じフィールドにトークンが続くことを表す。
+func nextToken(s string) (tok AuthToken, rest string, sawComma, ok bool, errmsg string) {
+	i := 0
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\r') {
+		i++
+	}
+	if i >= len(s) || s[i] == '#' {
+		return AuthToken{}, "", false, false, ""
+	}
+
+	var b strings.Builder
+	inQuote, wasQuote := false, false
+	for ; i < len(s); i++ {
+		c := s[i]
+		if !inQuote && (c == ' ' || c == '\t' || c == '\r' || c == '#') {
+			break
+		}
+		if !inQuote && c == ',' {
+			sawComma = true
+			i++
+			break
+		}
+		if c == '"' {
+			// 引用符の中の "" は " 1文字
+			if inQuote && i+1 < len(s) && s[i+1] == '"' {
+				b.WriteByte('"')
+				i++
+				continue
+			}
+			inQuote = !inQuote
+			wasQuote = true
+			continue
+		}
+		b.WriteByte(c)
+	}
+	if inQuote {
+		return AuthToken{}, "", false, false, "unterminated quoted string"
+	}
+	if sawComma {
+		// カンマの後に何もなければ、次のトークンがない
+		j := i
+		for j < len(s) && (s[j] == ' ' || s[j] == '\t' || s[j] == '\r') {
+			j++
+		}
+		if j >= len(s) || s[j] == '#' {
+			return AuthToken{}, "", false, false, "missing entry at end of line"
+		}
+	}
+	return AuthToken{String: b.String(), Quoted: wasQuote}, s[i:], sawComma, true, ""
+}
@@ -0,0 +1,348 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+)
+
+// ----------------------------------------------------------------
+// 既定のアクセス権限の変更 (catalog/aclchk.c の ExecAlterDefaultPrivilegesStmt 相当)
+// ----------------------------------------------------------------
+// ALTER DEFAULT PRIVILEGES は、FOR ROLE のロール (省略すれば現在のユーザー) がこれから作る
+// オブジェクトに付ける権限を pg_default_acl に設定する。IN SCHEMA を省略した設定は種類ごとの
+// 既定の権限の代わりになり、権限を取り除くこともできる。IN SCHEMA を指定した設定は、その
+// スキーマに作るオブジェクトに権限を加えるだけである。
+//
+// 他のロールの既定の権限を変えるには、そのロールの権限を持たなければならない。オブジェクトを
+// 作るときの grantor は、オブジェクトを作るロールとする。
+//
+// 設定はテーブル、シーケンス、関数、型、スキーマについてできるが、今のところ作れるオブジェクトは
+// ドメインだけで、ドメインを作るときに型の既定の権限を付ける。
+
+func init() {
+	fmgr.RegisterSetReturning("pg_get_default_acl", pgGetDefaultAcl)
+}
+
+// internalDefaultACL は1つのロールとスキーマへの変更 (InternalDefaultACL 相当)
+type internalDefaultACL struct {
+	roleid catalog.Oid
+	// nspid は IN SCHEMA のスキーマ。指定しなければ InvalidOid
+	nspid      catalog.Oid
+	isGrant    bool
+	objtype    byte
+	allPrivs   bool
+	privileges catalog.AclMode
+	grantees   []catalog.Oid
+	grantOpt   bool
+}
+
+// stringToPrivilege は権限の名前を権限のビットにする (string_to_privilege 相当)。RULE は以前の版の
+// 権限で、何も表さない。
+func stringToPrivilege(privname string) (catalog.AclMode, error) {
+	switch privname {
+	case "insert":
+		return catalog.AclInsert, nil
+	case "select":
+		return catalog.AclSelect, nil
+	case "update":
+		return catalog.AclUpdate, nil
+	case "delete":
+		return catalog.AclDelete, nil
+	case "truncate":
+		return catalog.AclTruncate, nil
+	case "references":
+		return catalog.AclReferences, nil
+	case "trigger":
+		return catalog.AclTrigger, nil
+	case "execute":
+		return catalog.AclExecute, nil
+	case "usage":
+		return catalog.AclUsage, nil
+	case "create":
+		return catalog.AclCreate, nil
+	case "temporary", "temp":
+		return catalog.AclCreateTemp, nil
+	case "connect":
+		return catalog.AclConnect, nil
+	case "set":
+		return catalog.AclSet, nil
+	case "alter system":
+		return catalog.AclAlterSys, nil
+	case "maintain":
+		return catalog.AclMaintain, nil
+	case "rule":
+		return catalog.AclNoRights, nil
+	}
+	return 0, newError(errcodes.SyntaxError, "unrecognized privilege type \"%s\"", privname)
+}
+
+// privilegeToString は権限のビットを名前にする (privilege_to_string 相当)
+func privilegeToString(privilege catalog.AclMode) string {
+	switch privilege {
+	case catalog.AclInsert:
+		return "INSERT"
+	case catalog.AclSelect:
+		return "SELECT"
+	case catalog.AclUpdate:
+		return "UPDATE"
+	case catalog.AclDelete:
+		return "DELETE"
+	case catalog.AclTruncate:
+		return "TRUNCATE"
+	case catalog.AclReferences:
+		return "REFERENCES"
+	case catalog.AclTrigger:
+		return "TRIGGER"
+	case catalog.AclExecute:
+		return "EXECUTE"
+	case catalog.AclUsage:
+		return "USAGE"
+	case catalog.AclCreate:
+		return "CREATE"
+	case catalog.AclCreateTemp:
+		return "TEMP"
+	case catalog.AclConnect:
+		return "CONNECT"
+	case catalog.AclSet:
+		return "SET"
+	case catalog.AclAlterSys:
+		return "ALTER SYSTEM"
+	case catalog.AclMaintain:
+		return "MAINTAIN"
+	}
+	return "???"
+}
+
+// execAlterDefaultPrivileges は ALTER DEFAULT PRIVILEGES 文を実行する (ExecAlterDefaultPrivilegesStmt 相当)
+func (s *session) execAlterDefaultPrivileges(stmt *parser.AlterDefaultPrivilegesStmt) error {
+	var drolespecs, dnspnames *parser.DefElem
+	for _, defel := range stmt.Options {
+		dst := &dnspnames
+		if defel.Defname == "roles" {
+			dst = &drolespecs
+		}
+		if *dst != nil {
+			return newError(errcodes.SyntaxError, "conflicting or redundant options")
+		}
+		*dst = defel
+	}
+
+	action := stmt.Action
+	iacls := internalDefaultACL{isGrant: action.IsGrant, grantOpt: action.GrantOption}
+	for _, grantee := range action.Grantees {
+		if grantee.RoleType == parser.RoleSpecPublic {
+			iacls.grantees = append(iacls.grantees, catalog.ACLIDPublic)
+			continue
+		}
+		role, err := s.getRoleSpec(grantee)
+		if err != nil {
+			return err
+		}
+		iacls.grantees = append(iacls.grantees, role.Oid)
+	}
+
+	var allPrivileges catalog.AclMode
+	var errormsg string
+	switch action.Objtype {
+	case parser.GrantTables:
+		iacls.objtype = catalog.DefaclObjRelation
+		allPrivileges, errormsg = catalog.AclAllRightsRelation, "invalid privilege type %s for relation"
+	case parser.GrantSequences:
+		iacls.objtype = catalog.DefaclObjSequence
+		allPrivileges, errormsg = catalog.AclAllRightsSequence, "invalid privilege type %s for sequence"
+	case parser.GrantFunctions:
+		iacls.objtype = catalog.DefaclObjFunction
+		allPrivileges, errormsg = catalog.AclAllRightsFunction, "invalid privilege type %s for function"
+	case parser.GrantRoutines:
+		iacls.objtype = catalog.DefaclObjFunction
+		allPrivileges, errormsg = catalog.AclAllRightsFunction, "invalid privilege type %s for routine"
+	case parser.GrantTypes:
+		iacls.objtype = catalog.DefaclObjType
+		allPrivileges, errormsg = catalog.AclAllRightsType, "invalid privilege type %s for type"
+	case parser.GrantSchemas:
+		iacls.objtype = catalog.DefaclObjNamespace
+		allPrivileges, errormsg = catalog.AclAllRightsNamespace, "invalid privilege type %s for schema"
+	default:
+		return fmt.Errorf("unrecognized GrantStmt.objtype: %d", action.Objtype)
+	}
+
+	if action.Privileges == nil {
+		iacls.allPrivs = true
+	}
+	for _, privnode := range action.Privileges {
+		if privnode.Cols != nil {
+			return newError(errcodes.InvalidGrantOperation, "default privileges cannot be set for columns")
+		}
+		if privnode.PrivName == "" {
+			return fmt.Errorf("AccessPriv node must specify privilege")
+		}
+		priv, err := stringToPrivilege(privnode.PrivName)
+		if err != nil {
+			return err
+		}
+		if priv&^allPrivileges != 0 {
+			return newError(errcodes.InvalidGrantOperation, errormsg, privilegeToString(priv))
+		}
+		iacls.privileges |= priv
+	}
+
+	var nspnames []string
+	if dnspnames != nil {
+		nspnames = dnspnames.Arg.([]string)
+	}
+	if drolespecs == nil {
+		role, _ := catalog.SearchRole(s.userName)
+		iacls.roleid = role.Oid
+		return setDefaultACLsInSchemas(&iacls, nspnames)
+	}
+	for _, spec := range drolespecs.Arg.([]*parser.RoleSpec) {
+		role, err := s.getRoleSpec(spec)
+		if err != nil {
+			return err
+		}
+		// 既定の権限を変えるロールの権限を持たなければならない
+		if !catalog.HasPrivsOfRole(s.userName, role.Rolname) {
+			return newError(errcodes.InsufficientPrivilege, "permission denied to change default privileges")
+		}
+		iacls.roleid = role.Oid
+		if err := setDefaultACLsInSchemas(&iacls, nspnames); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setDefaultACLsInSchemas は iacls を nspnames の各スキーマに、nspnames が空なら全てのスキーマへの
+// 設定として適用する (SetDefaultACLsInSchemas 相当)
+func setDefaultACLsInSchemas(iacls *internalDefaultACL, nspnames []string) error {
+	if len(nspnames) == 0 {
+		iacls.nspid = catalog.InvalidOid
+		return setDefaultACL(iacls)
+	}
+	for _, nspname := range nspnames {
+		nspid, ok := catalog.NamespaceGetOid(nspname)
+		if !ok {
+			return newError(errcodes.UndefinedSchema, "schema \"%s\" does not exist", nspname)
+		}
+		iacls.nspid = nspid
+		if err := setDefaultACL(iacls); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setDefaultACL は1つのロールとスキーマの既定の権限を変える (SetDefaultACL 相当)。行がなければ、
+// 全てのスキーマへの設定は種類の既定の ACL から、スキーマへの設定は空の ACL から始める。結果が
+// 初めの ACL と同じになれば行を削除する。
+func setDefaultACL(iacls *internalDefaultACL) error {
+	privileges := iacls.privileges
+	if iacls.allPrivs {
+		switch iacls.objtype {
+		case catalog.DefaclObjRelation:
+			privileges = catalog.AclAllRightsRelation
+		case catalog.DefaclObjSequence:
+			privileges = catalog.AclAllRightsSequence
+		case catalog.DefaclObjFunction:
+			privileges = catalog.AclAllRightsFunction
+		case catalog.DefaclObjType:
+			privileges = catalog.AclAllRightsType
+		case catalog.DefaclObjNamespace:
+			privileges = catalog.AclAllRightsNamespace
+		}
+	}
+	if iacls.objtype == catalog.DefaclObjNamespace && iacls.nspid != catalog.InvalidOid {
+		return newError(errcodes.InvalidGrantOperation, "cannot use IN SCHEMA clause when using GRANT/REVOKE ON SCHEMAS")
+	}
+	if iacls.isGrant && iacls.grantOpt {
+		for _, grantee := range iacls.grantees {
+			if grantee == catalog.ACLIDPublic {
+				return newError(errcodes.InvalidGrantOperation, "grant options can only be granted to roles")
+			}
+		}
+	}
+
+	defAcl := catalog.Acl{}
+	if iacls.nspid == catalog.InvalidOid {
+		defAcl = catalog.AclDefault(iacls.objtype, iacls.roleid)
+	}
+	defAcl = catalog.AclItemSort(defAcl)
+	catalog.AlterDefaultAcl(iacls.roleid, iacls.nspid, iacls.objtype, func(oldAcl catalog.Acl, exists bool) catalog.Acl {
+		if !exists {
+			oldAcl = defAcl
+		}
+		newAcl := mergeAclWithGrant(oldAcl, iacls.isGrant, iacls.grantOpt, iacls.grantees, privileges, iacls.roleid)
+		newAcl = catalog.AclItemSort(newAcl)
+		// 初めの ACL と同じであれば行は要らない
+		if catalog.AclEqual(newAcl, defAcl) {
+			return nil
+		}
+		if newAcl == nil {
+			newAcl = catalog.Acl{}
+		}
+		return newAcl
+	})
+	return nil
+}
+
+// mergeAclWithGrant は oldAcl の各 grantee の権限を grantorID から受けたものとして加えるか、
+// 取り除く (merge_acl_with_grant 相当)。REVOKE GRANT OPTION FOR は GRANT OPTION だけを取り除く。
+func mergeAclWithGrant(oldAcl catalog.Acl, isGrant, grantOption bool, grantees []catalog.Oid, privileges catalog.AclMode, grantorID catalog.Oid) catalog.Acl {
+	newAcl := oldAcl
+	for _, grantee := range grantees {
+		var privs catalog.AclMode
+		if isGrant || !grantOption {
+			privs |= privileges
+		}
+		if !isGrant || grantOption {
+			privs |= catalog.GrantOptionFor(privileges)
+		}
+		newAcl = catalog.AclChange(newAcl, catalog.AclItem{Grantee: grantee, Grantor: grantorID, Privs: privs}, isGrant)
+	}
+	return newAcl
+}
+
+// defaultACLDescription は既定の権限の説明を返す (getObjectDescription の OCLASS_DEFACL 相当)
+func defaultACLDescription(d catalog.FormPgDefaultAcl) string {
+	role, _ := catalog.SearchRoleByOid(d.Defaclrole)
+	var kind string
+	switch d.Defaclobjtype {
+	case catalog.DefaclObjRelation:
+		kind = "relations"
+	case catalog.DefaclObjSequence:
+		kind = "sequences"
+	case catalog.DefaclObjFunction:
+		kind = "functions"
+	case catalog.DefaclObjType:
+		kind = "types"
+	case catalog.DefaclObjNamespace:
+		kind = "schemas"
+	}
+	desc := fmt.Sprintf("default privileges on new %s belonging to role %s", kind, role.Rolname)
+	if d.Defaclnamespace != catalog.InvalidOid {
+		desc += " in schema " + catalog.NamespaceGetName(d.Defaclnamespace)
+	}
+	return desc
+}
+
+// pgGetDefaultAcl は pg_default_acl の行を返す。aclitem 型がまだないため、ACL は aclitemout の
+// 形の text[] で返す。
+func pgGetDefaultAcl(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	acls := catalog.ListDefaultAcls()
+	rows := make([][]adt.Datum, 0, len(acls))
+	for _, d := range acls {
+		rows = append(rows, []adt.Datum{
+			d.Oid,
+			d.Defaclrole,
+			d.Defaclnamespace,
+			d.Defaclobjtype,
+			adt.TextArray(d.Defaclacl.Strings()),
+		})
+	}
+	return rows, nil
+}
@@ -0,0 +1,248 @@
+package backend
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os/user"
+	"strconv"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/auth/hba"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// クライアント認証 (libpq/auth.c 相当)
+// ----------------------------------------------------------------
+// pg_hba.conf で接続に一致した行の認証方式でクライアントを認証する。
+// 失敗した場合は、どの行に一致したかをサーバーログにだけ出力する。
+
+// clientAuthentication はクライアントを認証する (ClientAuthentication 相当)。proc はログに出す
+// バックエンドの情報。
+func clientAuthentication(proc *errutil.ProcInfo, port *libpq.Port) error {
+	line := hba.CheckHba(proc, port)
+
+	// clientcert が指定されていれば、認証方式によらずクライアント証明書を要求する
+	if line.ClientCert != hba.ClientCertOff && !port.PeerCertValid {
+		return newError(errcodes.InvalidAuthorizationSpecification, "connection requires a valid client certificate")
+	}
+
+	switch line.AuthMethod {
+	case hba.UaReject:
+		return newError(errcodes.InvalidAuthorizationSpecification, "pg_hba.conf rejects connection for host \"%s\", user \"%s\", database \"%s\", %s",
+			port.RemoteHost, port.UserName, port.DatabaseName, encryptionName(port))
+	case hba.UaImplicitReject:
+		return newError(errcodes.InvalidAuthorizationSpecification, "no pg_hba.conf entry for host \"%s\", user \"%s\", database \"%s\", %s",
+			port.RemoteHost, port.UserName, port.DatabaseName, encryptionName(port))
+	case hba.UaTrust:
+	case hba.UaCert:
+		// verify-full の検査で証明書のユーザー名を確かめる
+	case hba.UaMD5, hba.UaSCRAM:
+		if err := checkPWChallengeAuth(line, port); err != nil {
+			return err
+		}
+	case hba.UaPassword:
+		if err := checkPasswordAuth(line, port); err != nil {
+			return err
+		}
+	case hba.UaPeer:
+		if err := checkPeerAuth(proc, line, port); err != nil {
+			return err
+		}
+	default:
+		return authFailed(line, port, fmt.Sprintf("%s authentication is not implemented.", line.AuthMethod))
+	}
+
+	if line.ClientCert == hba.ClientCertFull {
+		if err := checkCertAuth(proc, line, port); err != nil {
+			return err
+		}
+	}
+	return sendAuthenticationOk(port)
+}
+
+// checkPWChallengeAuth はチャレンジレスポンス方式のパスワード認証を行う (CheckPWChallengeAuth 相当)。
+// 保存されたパスワードが SCRAM の秘密情報であれば、md5 が指定されていても SCRAM で認証する。
+func checkPWChallengeAuth(line *hba.HbaLine, port *libpq.Port) error {
+	secret, logdetail := getRolePassword(port.UserName)
+	var ok bool
+	var detail string
+	var err error
+	if line.AuthMethod == hba.UaMD5 && secret != "" && getPasswordType(secret) == passwordTypeMD5 {
+		ok, detail, err = checkMD5Auth(port, secret)
+	} else {
+		ok, detail, err = checkSASLAuth(port, secret)
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if detail == "" {
+			detail = logdetail
+		}
+		return authFailed(line, port, detail)
+	}
+	return nil
+}
+
+// checkPasswordAuth は平文のパスワードで認証する (CheckPasswordAuth 相当)
+func checkPasswordAuth(line *hba.HbaLine, port *libpq.Port) error {
+	buf := libpq.BeginMessage(libpq.PqMsgAuthenticationRequest)
+	buf.SendInt32(libpq.AuthReqPassword)
+	if err := buf.EndMessage(port); err != nil {
+		return err
+	}
+	passwd, err := recvPasswordPacket(port)
+	if err != nil {
+		return err
+	}
+
+	secret, logdetail := getRolePassword(port.UserName)
+	if secret == "" {
+		return authFailed(line, port, logdetail)
+	}
+	if logdetail := plainCryptVerify(port.UserName, secret, passwd); logdetail != "" {
+		return authFailed(line, port, logdetail)
+	}
+	return nil
+}
+
+// checkMD5Auth は MD5 のチャレンジレスポンスで認証する (CheckMD5Auth 相当)
+func checkMD5Auth(port *libpq.Port, secret string) (ok bool, logdetail string, err error) {
+	var salt [4]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		return false, "", newError(errcodes.InternalError, "could not generate random MD5 salt")
+	}
+	buf := libpq.BeginMessage(libpq.PqMsgAuthenticationRequest)
+	buf.SendInt32(libpq.AuthReqMD5)
+	buf.SendBytes(salt[:])
+	if err := buf.EndMessage(port); err != nil {
+		return false, "", err
+	}
+	passwd, err := recvPasswordPacket(port)
+	if err != nil {
+		return false, "", err
+	}
+	if logdetail := md5CryptVerify(port.UserName, secret, passwd, salt[:]); logdetail != "" {
+		return false, logdetail, nil
+	}
+	return true, "", nil
+}
+
+// checkPeerAuth は Unix ドメインソケットの接続相手のオペレーティングシステムのユーザー名が、
+// ユーザー名と一致するか、map オプションで指定した対応付けでユーザー名に対応付けられているかを
+// 確かめる (auth_peer 相当)
+func checkPeerAuth(proc *errutil.ProcInfo, line *hba.HbaLine, port *libpq.Port) error {
+	uid, _, err := platform.GetPeerEid(port.Conn())
+	if err != nil {
+		if errors.Is(err, platform.ErrPeerCredNotSupported) {
+			errutil.Report(proc, errutil.New(errutil.Log, errcodes.FeatureNotSupported, "peer authentication is not supported on this platform"))
+		} else {
+			errutil.Report(proc, errutil.Elog(errutil.Log, "could not get peer credentials: %s", err.Error()))
+		}
+		return authFailed(line, port, "")
+	}
+	u, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		errutil.Report(proc, errutil.Elog(errutil.Log, "could not look up local user ID %d: %s", uid, err.Error()))
+		return authFailed(line, port, "")
+	}
+	if !hba.CheckUsermap(proc, line.UserMap, port.UserName, u.Username) {
+		return authFailed(line, port, "")
+	}
+	return nil
+}
+
+// recvPasswordPacket はパスワードのメッセージを受け取る (recv_password_packet 相当)
+func recvPasswordPacket(port *libpq.Port) (string, error) {
+	if err := port.Flush(); err != nil {
+		return "", err
+	}
+	mtype, err := port.GetByte()
+	if err != nil {
+		return "", libpq.ErrConnectionClosed
+	}
+	if mtype != libpq.PqMsgPasswordMessage {
+		return "", newError(errcodes.ProtocolViolation, "expected password response, got message type %d", mtype)
+	}
+	body, err := port.GetMessage(maxAuthTokenLength)
+	if err != nil {
+		return "", err
+	}
+	msg := libpq.NewMessage(body)
+	passwd, err := msg.GetMsgString()
+	if err != nil || msg.Remaining() != 0 {
+		return "", newError(errcodes.ProtocolViolation, "invalid password packet size")
+	}
+	if passwd == "" {
+		return "", newError(errcodes.InvalidPassword, "empty password returned by client")
+	}
+	return passwd, nil
+}
+
+// encryptionName は接続の暗号化の有無をエラーメッセージ用に返す
+func encryptionName(port *libpq.Port) string {
+	if port.SSLInUse {
+		return "SSL encryption"
+	}
+	return "no encryption"
+}
+
+// checkCertAuth はクライアント証明書の CN (clientname=DN の場合は DN) が、ユーザー名と一致するか、
+// map オプションで指定した対応付けでユーザー名に対応付けられているかを確かめる (CheckCertAuth 相当)
+func checkCertAuth(proc *errutil.ProcInfo, line *hba.HbaLine, port *libpq.Port) error {
+	name, field := port.PeerCN, "CN"
+	if line.ClientCertName == hba.ClientCertDN {
+		name, field = port.PeerDN, "DN"
+	}
+	if name == "" {
+		return authFailed(line, port, "Client certificate contains no user name.")
+	}
+	if !hba.CheckUsermap(proc, line.UserMap, port.UserName, name) {
+		if line.AuthMethod != hba.UaCert {
+			return authFailed(line, port, fmt.Sprintf("certificate validation (clientcert=verify-full) failed for user \"%s\": %s mismatch",
+				port.UserName, field))
+		}
+		return authFailed(line, port, "")
+	}
+	return nil
+}
+
+// logAuthenticationFailure は認証に失敗した接続元を1行でサーバーログに出力する。
+// fail2ban のように、ログから接続元を取り出して遮断する仕組みで使えるようにする。
+func logAuthenticationFailure(proc *errutil.ProcInfo, port *libpq.Port) {
+	host := "host=" + port.RemoteHost
+	if port.RemotePort != "" {
+		host += " port=" + port.RemotePort
+	}
+	errutil.Report(proc, errutil.Elog(errutil.Log, "authentication failure: %s user=%s database=%s",
+		host, port.UserName, port.DatabaseName))
+}
+
+// authFailed は認証の失敗を表すエラーを作る (auth_failed 相当)。
+// 失敗の理由と一致した行は、クライアントには知らせずサーバーログにだけ出力する。
+func authFailed(line *hba.HbaLine, port *libpq.Port, detail string) error {
+	code, format := errcodes.InvalidAuthorizationSpecification, ""
+	switch line.AuthMethod {
+	case hba.UaReject, hba.UaImplicitReject, hba.UaTrust:
+		format = "authentication failed for user \"%s\": host rejected"
+	case hba.UaIdent:
+		format = "Ident authentication failed for user \"%s\""
+	case hba.UaPeer:
+		format = "Peer authentication failed for user \"%s\""
+	case hba.UaPassword, hba.UaMD5, hba.UaSCRAM:
+		code, format = errcodes.InvalidPassword, "password authentication failed for user \"%s\""
+	case hba.UaCert:
+		format = "certificate authentication failed for user \"%s\""
+	default:
+		format = "authentication failed for user \"%s\": invalid authentication method"
+	}
+	cdetail := fmt.Sprintf("Connection matched file \"%s\" line %d: \"%s\"", line.SourceFile, line.LineNum, line.RawLine)
+	if detail != "" {
+		cdetail = detail + "\n" + cdetail
+	}
+	return errutil.New(errutil.Error, code, format, port.UserName).WithDetailLog("%s", cdetail)
+}
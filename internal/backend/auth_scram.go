@@ -0,0 +1,502 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/common/scram"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// SASL による認証 (libpq/auth-sasl.c 相当)
+// ----------------------------------------------------------------
+// AuthenticationSASL で使える認証機構を知らせ、クライアントの SASLInitialResponse と
+// SASLResponse を、認証機構の処理が成功か失敗を返すまで交換する。
+
+// maxAuthTokenLength は認証のメッセージの長さの上限 (PG_MAX_AUTH_TOKEN_LENGTH 相当)
+const maxAuthTokenLength = 65535
+
+// saslStatus は認証機構の1回の処理の結果 (PG_SASL_EXCHANGE_* 相当)
+type saslStatus int
+
+const (
+	saslContinue saslStatus = iota
+	saslSuccess
+	saslFailure
+)
+
+// checkSASLAuth は SASL で認証する (CheckSASLAuth 相当)。
+// secret が空の場合も交換は最後まで行い、ユーザーが存在するかを推測されないようにする。
+// 認証に失敗した場合は、サーバーログにだけ出力する理由を logdetail に返す。
+func checkSASLAuth(port *libpq.Port, secret string) (ok bool, logdetail string, err error) {
+	// チャネルバインディングは SSL 接続でだけ提供する
+	buf := libpq.BeginMessage(libpq.PqMsgAuthenticationRequest)
+	buf.SendInt32(libpq.AuthReqSASL)
+	if port.SSLInUse {
+		buf.SendString(scram.MechanismSHA256Plus)
+	}
+	buf.SendString(scram.MechanismSHA256)
+	buf.SendByte(0)
+	if err := buf.EndMessage(port); err != nil {
+		return false, "", err
+	}
+	if err := port.Flush(); err != nil {
+		return false, "", err
+	}
+
+	var state *scramState
+	for {
+		mtype, err := port.GetByte()
+		if err != nil {
+			return false, "", libpq.ErrConnectionClosed
+		}
+		if mtype != libpq.PqMsgSASLResponse {
+			return false, "", newError(errcodes.ProtocolViolation, "expected SASL response, got message type %d", mtype)
+		}
+		body, err := port.GetMessage(maxAuthTokenLength)
+		if err != nil {
+			return false, "", err
+		}
+		msg := libpq.NewMessage(body)
+
+		var input []byte
+		if state == nil {
+			// 最初のメッセージ (SASLInitialResponse) は選んだ認証機構の名前を含む
+			mech, err := msg.GetMsgString()
+			if err != nil {
+				return false, "", newError(errcodes.ProtocolViolation, "%s", err.Error())
+			}
+			if state, err = scramInit(port, mech, secret); err != nil {
+				return false, "", err
+			}
+			inputlen, err := msg.GetMsgInt32()
+			if err != nil {
+				return false, "", newError(errcodes.ProtocolViolation, "%s", err.Error())
+			}
+			if inputlen >= 0 {
+				if input, err = msg.GetMsgBytes(int(inputlen)); err != nil {
+					return false, "", newError(errcodes.ProtocolViolation, "%s", err.Error())
+				}
+			}
+		} else {
+			input, _ = msg.GetMsgBytes(msg.Remaining())
+		}
+		if err := msg.GetMsgEnd(); err != nil {
+			return false, "", newError(errcodes.ProtocolViolation, "%s", err.Error())
+		}
+
+		status, output, err := state.exchange(input)
+		if err != nil {
+			return false, "", err
+		}
+		switch status {
+		case saslFailure:
+			return false, state.logdetail, nil
+		case saslSuccess:
+			buf := libpq.BeginMessage(libpq.PqMsgAuthenticationRequest)
+			buf.SendInt32(libpq.AuthReqSASLFin)
+			buf.SendBytes(output)
+			return true, "", buf.EndMessage(port)
+		}
+		buf := libpq.BeginMessage(libpq.PqMsgAuthenticationRequest)
+		buf.SendInt32(libpq.AuthReqSASLCont)
+		buf.SendBytes(output)
+		if err := buf.EndMessage(port); err != nil {
+			return false, "", err
+		}
+		if err := port.Flush(); err != nil {
+			return false, "", err
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// SCRAM-SHA-256 の交換 (libpq/auth-scram.c 相当)
+// ----------------------------------------------------------------
+// メッセージの流れは次の通り。
+//
+//	クライアント → サーバー: client-first-message  (n,,n=,r=<client nonce>)
+//	サーバー → クライアント: server-first-message  (r=<nonce>,s=<salt>,i=<iterations>)
+//	クライアント → サーバー: client-final-message  (c=<cbind>,r=<nonce>,p=<proof>)
+//	サーバー → クライアント: server-final-message  (v=<server signature>)
+//
+// ユーザー名はスタートアップパケットで受け取ったものを使い、client-first-message の
+// n= の値は無視する。
+
+type scramExchState int
+
+const (
+	scramAuthInit scramExchState = iota
+	scramAuthSaltSent
+	scramAuthFinished
+)
+
+// scramState は1回の SCRAM の交換の状態 (scram_state 相当)
+type scramState struct {
+	state scramExchState
+	port  *libpq.Port
+
+	// channelBinding はクライアントが -PLUS の認証機構を選んだことを表す
+	channelBinding bool
+	cbindFlag      byte
+
+	secret scram.Secret
+
+	clientFirstMessageBare string
+	clientNonce            string
+	serverFirstMessage     string
+	serverNonce            string
+
+	clientFinalMessageWithoutProof string
+	clientFinalNonce               string
+	clientProof                    []byte
+
+	// doomed は認証を必ず失敗させることを表す。秘密情報がない場合も、
+	// 失敗の時点を遅らせてユーザーの有無を推測されないようにする
+	doomed    bool
+	logdetail string
+}
+
+// scramInit は交換の状態を初期化する (scram_init 相当)
+func scramInit(port *libpq.Port, mech, secret string) (*scramState, error) {
+	st := &scramState{port: port}
+	switch {
+	case mech == scram.MechanismSHA256Plus && port.SSLInUse:
+		st.channelBinding = true
+	case mech == scram.MechanismSHA256:
+	default:
+		return nil, newError(errcodes.ProtocolViolation, "client selected an invalid SASL authentication mechanism")
+	}
+
+	if secret != "" {
+		if s, ok := scram.ParseSecret(secret); ok {
+			st.secret = s
+		} else {
+			st.logdetail = fmt.Sprintf("User \"%s\" does not have a valid SCRAM secret.", port.UserName)
+			st.doomed = true
+		}
+	} else {
+		st.doomed = true
+	}
+	if st.doomed {
+		st.secret = mockScramSecret(port.UserName)
+	}
+	return st, nil
+}
+
+// mockScramSecret は存在しないユーザーのための偽の秘密情報を作る (mock_scram_secret 相当)。
+// salt は同じユーザー名に対して常に同じ値になる。
+func mockScramSecret(username string) scram.Secret {
+	h := sha256.New()
+	h.Write([]byte(username))
+	// ユーザーごとの偽の salt を作るため、サーバー固有の値を混ぜる (mock_authentication_nonce 相当)
+	h.Write(transam.GetMockAuthenticationNonce())
+	return scram.Secret{
+		Iterations: scram.DefaultIterations,
+		Salt:       h.Sum(nil)[:scram.DefaultSaltLen],
+		StoredKey:  make([]byte, scram.KeyLen),
+		ServerKey:  make([]byte, scram.KeyLen),
+	}
+}
+
+// malformed はメッセージの形式の誤りを表すエラーを作る
+func malformed(format string, args ...any) error {
+	return withDetail(newError(errcodes.ProtocolViolation, "malformed SCRAM message"), format, args...)
+}
+
+// exchange はクライアントのメッセージを1つ処理し、返すメッセージを作る (scram_exchange 相当)
+func (st *scramState) exchange(input []byte) (saslStatus, []byte, error) {
+	if st.state == scramAuthInit && len(input) == 0 {
+		// 最初のメッセージを省略したクライアントには、空のメッセージを返して促す
+		return saslContinue, nil, nil
+	}
+	if len(input) == 0 {
+		return saslFailure, nil, malformed("The message is empty.")
+	}
+	if bytes.IndexByte(input, 0) >= 0 {
+		return saslFailure, nil, malformed("Message length does not match input length.")
+	}
+
+	switch st.state {
+	case scramAuthInit:
+		if err := st.readClientFirstMessage(string(input)); err != nil {
+			return saslFailure, nil, err
+		}
+		out := st.buildServerFirstMessage()
+		st.state = scramAuthSaltSent
+		return saslContinue, []byte(out), nil
+
+	case scramAuthSaltSent:
+		if err := st.readClientFinalMessage(string(input)); err != nil {
+			return saslFailure, nil, err
+		}
+		if !st.verifyFinalNonce() {
+			return saslFailure, nil, withDetail(newError(errcodes.ProtocolViolation, "invalid SCRAM response"), "Nonce does not match.")
+		}
+		st.state = scramAuthFinished
+		if st.doomed || !st.verifyClientProof() {
+			return saslFailure, nil, nil
+		}
+		return saslSuccess, []byte(st.buildServerFinalMessage()), nil
+	}
+	return saslFailure, nil, newError(errcodes.InternalError, "invalid SCRAM exchange state")
+}
+
+// readAttributeValue は "attr=value" を読み、値と残りを返す (read_attribute_value 相当)
+func readAttributeValue(input string, attr byte) (value, rest string, err error) {
+	if len(input) == 0 || input[0] != attr {
+		got := ""
+		if len(input) > 0 {
+			got = input[:1]
+		}
+		return "", "", malformed("Expected attribute \"%c\" but found \"%s\".", attr, got)
+	}
+	if len(input) < 2 || input[1] != '=' {
+		return "", "", malformed("Expected character \"=\" for attribute \"%c\".", attr)
+	}
+	value, rest, _ = strings.Cut(input[2:], ",")
+	return value, rest, nil
+}
+
+// readAnyAttr は拡張の属性を1つ読み飛ばし、属性名と残りを返す (read_any_attr 相当)
+func readAnyAttr(input string) (attr byte, value, rest string, err error) {
+	if len(input) == 0 || !('a' <= input[0] && input[0] <= 'z' || 'A' <= input[0] && input[0] <= 'Z') {
+		got := ""
+		if len(input) > 0 {
+			got = input[:1]
+		}
+		return 0, "", "", malformed("Attribute expected, but found invalid character \"%s\".", got)
+	}
+	attr = input[0]
+	if len(input) < 2 || input[1] != '=' {
+		return 0, "", "", malformed("Expected character \"=\" for attribute \"%c\".", attr)
+	}
+	value, rest, _ = strings.Cut(input[2:], ",")
+	return attr, value, rest, nil
+}
+
+// isPrintableNonce はノンスが ',' 以外の印字可能な ASCII 文字だけからなるかを返す
+func isPrintableNonce(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x21 || s[i] > 0x7e || s[i] == ',' {
+			return false
+		}
+	}
+	return true
+}
+
+// readClientFirstMessage は client-first-message を解析する (read_client_first_message 相当)
+func (st *scramState) readClientFirstMessage(input string) error {
+	// gs2-cbind-flag
+	switch {
+	case strings.HasPrefix(input, "n"):
+		if st.channelBinding {
+			return malformed("The client selected SCRAM-SHA-256-PLUS, but the SCRAM message does not include channel binding data.")
+		}
+		st.cbindFlag = 'n'
+		input = input[1:]
+	case strings.HasPrefix(input, "y"):
+		// クライアントはチャネルバインディングに対応しているが、サーバーが対応していないと考えている
+		if st.port.SSLInUse {
+			return withDetail(newError(errcodes.ProtocolViolation, "SCRAM channel binding negotiation error"),
+				"The client supports SCRAM channel binding but thinks the server does not.  However, this server does support channel binding.")
+		}
+		if st.channelBinding {
+			return malformed("The client selected SCRAM-SHA-256-PLUS, but the SCRAM message does not include channel binding data.")
+		}
+		st.cbindFlag = 'y'
+		input = input[1:]
+	case strings.HasPrefix(input, "p"):
+		if !st.channelBinding {
+			return malformed("The client selected SCRAM-SHA-256 without channel binding, but the SCRAM message includes channel binding data.")
+		}
+		cbname, rest, err := readAttributeValue(input, 'p')
+		if err != nil {
+			return err
+		}
+		if cbname != scram.ChannelBindingType {
+			return newError(errcodes.ProtocolViolation, "unsupported SCRAM channel-binding type \"%s\"", cbname)
+		}
+		st.cbindFlag = 'p'
+		// readAttributeValue が ',' を読み飛ばしたので、続く authzid の判定のために戻す
+		input = "," + rest
+	default:
+		got := ""
+		if len(input) > 0 {
+			got = input[:1]
+		}
+		return malformed("Unexpected channel-binding flag \"%s\".", got)
+	}
+
+	if !strings.HasPrefix(input, ",") {
+		got := ""
+		if len(input) > 0 {
+			got = input[:1]
+		}
+		return malformed("Comma expected, but found character \"%s\".", got)
+	}
+	input = input[1:]
+
+	// authzid には対応しない
+	if strings.HasPrefix(input, "a") {
+		return newError(errcodes.FeatureNotSupported, "client uses authorization identity, but it is not supported")
+	}
+	if !strings.HasPrefix(input, ",") {
+		got := ""
+		if len(input) > 0 {
+			got = input[:1]
+		}
+		return malformed("Unexpected attribute \"%s\" in client-first-message.", got)
+	}
+	input = input[1:]
+	st.clientFirstMessageBare = input
+
+	// 必須の拡張には対応しない
+	if strings.HasPrefix(input, "m") {
+		return newError(errcodes.FeatureNotSupported, "client requires an unsupported SCRAM extension")
+	}
+
+	// ユーザー名はスタートアップパケットのものを使う
+	_, input, err := readAttributeValue(input, 'n')
+	if err != nil {
+		return err
+	}
+	nonce, input, err := readAttributeValue(input, 'r')
+	if err != nil {
+		return err
+	}
+	if !isPrintableNonce(nonce) {
+		return newError(errcodes.ProtocolViolation, "non-printable characters in SCRAM nonce")
+	}
+	st.clientNonce = nonce
+
+	// 残りの拡張は読み飛ばす
+	for input != "" {
+		if _, _, input, err = readAnyAttr(input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildServerFirstMessage は server-first-message を作る (build_server_first_message 相当)
+func (st *scramState) buildServerFirstMessage() string {
+	raw := make([]byte, scram.RawNonceLen)
+	rand.Read(raw)
+	st.serverNonce = base64.StdEncoding.EncodeToString(raw)
+	st.serverFirstMessage = fmt.Sprintf("r=%s%s,s=%s,i=%d", st.clientNonce, st.serverNonce,
+		base64.StdEncoding.EncodeToString(st.secret.Salt), st.secret.Iterations)
+	return st.serverFirstMessage
+}
+
+// readClientFinalMessage は client-final-message を解析する (read_client_final_message 相当)
+func (st *scramState) readClientFinalMessage(input string) error {
+	begin := input
+
+	// チャネルバインディング
+	cbind, input, err := readAttributeValue(input, 'c')
+	if err != nil {
+		return err
+	}
+	if st.cbindFlag == 'p' {
+		hash, err := st.port.CertificateHash()
+		if err != nil {
+			return newError(errcodes.InternalError, "could not get server certificate hash: %s", err.Error())
+		}
+		expected := "p=" + scram.ChannelBindingType + ",," + string(hash)
+		got, err := base64.StdEncoding.DecodeString(cbind)
+		if err != nil || string(got) != expected {
+			return newError(errcodes.ProtocolViolation, "SCRAM channel binding check failed")
+		}
+	} else {
+		// チャネルバインディングを使わない場合は gs2-header をそのまま送り返してくる
+		expected := base64.StdEncoding.EncodeToString([]byte(string(st.cbindFlag) + ",,"))
+		if cbind != expected {
+			return newError(errcodes.ProtocolViolation, "unexpected SCRAM channel-binding attribute in client-final-message")
+		}
+	}
+
+	nonce, input, err := readAttributeValue(input, 'r')
+	if err != nil {
+		return err
+	}
+	st.clientFinalNonce = nonce
+
+	// proof の前の拡張を読み飛ばす
+	for {
+		proofStart := len(begin) - len(input)
+		attr, value, rest, err := readAnyAttr(input)
+		if err != nil {
+			return err
+		}
+		if attr != 'p' {
+			input = rest
+			continue
+		}
+		st.clientFinalMessageWithoutProof = begin[:proofStart-1]
+		proof, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(proof) != scram.KeyLen {
+			return malformed("Malformed proof in client-final-message.")
+		}
+		st.clientProof = proof
+		if rest != "" || strings.HasSuffix(input, ",") {
+			return malformed("Garbage found at the end of client-final-message.")
+		}
+		return nil
+	}
+}
+
+// verifyFinalNonce は client-final-message のノンスが2つのノンスを連結したものかを返す (verify_final_nonce 相当)
+func (st *scramState) verifyFinalNonce() bool {
+	return st.clientFinalNonce == st.clientNonce+st.serverNonce
+}
+
+// authMessage は署名の対象となるメッセージを返す
+func (st *scramState) authMessage() string {
+	return st.clientFirstMessageBare + "," + st.serverFirstMessage + "," + st.clientFinalMessageWithoutProof
+}
+
+// verifyClientProof はクライアントの proof を検証する (verify_client_proof 相当)。
+// ClientKey = ClientProof XOR ClientSignature として、H(ClientKey) が StoredKey と一致するかを調べる。
+func (st *scramState) verifyClientProof() bool {
+	signature := scram.HMAC(st.secret.StoredKey, st.authMessage())
+	clientKey := make([]byte, scram.KeyLen)
+	for i := range clientKey {
+		clientKey[i] = st.clientProof[i] ^ signature[i]
+	}
+	return hmac.Equal(scram.H(clientKey), st.secret.StoredKey)
+}
+
+// buildServerFinalMessage は server-final-message を作る (build_server_final_message 相当)
+func (st *scramState) buildServerFinalMessage() string {
+	signature := scram.HMAC(st.secret.ServerKey, st.authMessage())
+	return "v=" + base64.StdEncoding.EncodeToString(signature)
+}
+
+// scramBuildSecret は平文のパスワードから、乱数の salt を使って保存用の秘密情報を作る
+// (pg_be_scram_build_secret 相当)
+func scramBuildSecret(password string, iterations int) string {
+	salt := make([]byte, scram.DefaultSaltLen)
+	rand.Read(salt)
+	return scram.BuildSecret(password, salt, iterations)
+}
+
+// scramVerifyPlainPassword は平文のパスワードを SCRAM の秘密情報と照合する
+// (scram_verify_plain_password 相当)。password 認証で SCRAM の秘密情報を持つロールを認証するときに使う。
+func scramVerifyPlainPassword(password, secret string) bool {
+	s, ok := scram.ParseSecret(secret)
+	if !ok {
+		// get_password_type で判定済みなので起こらない
+		return false
+	}
+	salted := scram.SaltedPassword(scram.SASLprep(password), s.Salt, s.Iterations)
+	return hmac.Equal(scram.ServerKey(salted), s.ServerKey)
+}
@@ -0,0 +1,338 @@
+package backend
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// バックエンドの起動 (backend_startup.c の BackendMain / BackendInitialize 相当)
+// ----------------------------------------------------------------
+// C言語版では postmaster が接続ごとに fork() した子プロセスがバックエンドになるが、
+// Go言語版では接続ごとにゴルーチンを起動し、その中でバックエンドの処理を行う。
+
+// authTimeout は認証にかかる時間を監視する (STARTUP_PACKET_TIMEOUT と、認証中の
+// STATEMENT_TIMEOUT 相当)。時間を過ぎるか、認証の途中で終了を要求されると、接続の期限を
+// 過去にして送受信を中断させる。期限は TLS に切り替える前の接続に設定するため、
+// TLS の送受信も中断される。
+type authTimeout struct {
+	conn  net.Conn
+	timer *time.Timer
+
+	mu sync.Mutex
+	// done は認証を終えて監視をやめたことを表す
+	done bool
+	// err は送受信を中断させた理由。中断していなければ nil
+	err error
+}
+
+// startingBackends は認証を終えていないバックエンドの監視の一覧。
+// 認証の途中で終了を要求するために使う
+var startingBackends = struct {
+	sync.Mutex
+	m map[*authTimeout]struct{}
+}{m: make(map[*authTimeout]struct{})}
+
+// enableAuthTimeout は認証にかかる時間の監視を始める
+func enableAuthTimeout(conn net.Conn) *authTimeout {
+	t := &authTimeout{conn: conn}
+	startingBackends.Lock()
+	startingBackends.m[t] = struct{}{}
+	startingBackends.Unlock()
+	t.timer = time.AfterFunc(time.Duration(guc.AuthenticationTimeout.Get())*time.Second, func() { t.abort(errAuthTimeout) })
+	return t
+}
+
+// abort は監視中であれば、err を理由に送受信を中断させる
+func (t *authTimeout) abort(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done || t.err != nil {
+		return
+	}
+	t.err = err
+	t.conn.SetDeadline(time.Now())
+}
+
+// aborted は送受信を中断させた理由を返す。中断していなければ nil を返す。
+func (t *authTimeout) aborted() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// disable は監視をやめる。既に中断させていた場合は、その理由を返す。
+func (t *authTimeout) disable() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return t.err
+	}
+	if !t.done {
+		t.done = true
+		t.timer.Stop()
+		t.conn.SetDeadline(time.Time{})
+	}
+	t.release()
+	return nil
+}
+
+// release は監視の一覧から除く。バックエンドの終了時にも呼ぶ。
+func (t *authTimeout) release() {
+	t.timer.Stop()
+	startingBackends.Lock()
+	delete(startingBackends.m, t)
+	startingBackends.Unlock()
+}
+
+// errAuthTimeout は認証が authentication_timeout までに終わらなかったことを表す
+var errAuthTimeout = newError(errcodes.QueryCanceled, "canceling authentication due to timeout")
+
+// errNoStartup はクライアントがスタートアップパケットを送らずに切断したこと、
+// または CancelRequest のように応答不要なパケットを処理し終えたことを表す。
+var errNoStartup = errors.New("no startup packet")
+
+// CACState は postmaster が接続を受け付けられるかを判定した結果 (CAC_state 相当)
+type CACState int
+
+const (
+	CACOk       CACState = iota
+	CACTooMany           // 接続数が上限に達している
+	CACShutdown          // 停止の処理中
+	CACRecovery          // 他のバックエンドの異常終了の後、初期化し直している
+)
+
+// ChildCrash はバックエンドの異常終了の内容 (C言語版の子プロセスがシグナルで終了した場合相当)。
+// 処理の途中で panic したバックエンドは、共有する状態を壊しているかもしれない。
+type ChildCrash struct {
+	// PID はバックエンドの PID。PID を割り当てる前に異常終了した場合は 0
+	PID int32
+	// Reason は panic に渡された値
+	Reason any
+	// Activity は異常終了したときに実行していた問い合わせ
+	Activity string
+	// Stack は panic したときのスタックトレース
+	Stack []byte
+}
+
+// BackendMain は1つのクライアント接続を処理する。接続が終わるまで戻らない。
+// cac が CACOk 以外の場合は、スタートアップパケットを処理した後にエラーを返して接続を閉じる
+// (dead-end バックエンド)。先にスタートアップパケットを読むのは、クライアントが
+// SSL の交渉やプロトコルのバージョンに応じた正しい形式でエラーを受け取れるようにするため。
+//
+// 処理の途中で panic した場合は、postmaster 全体を道連れにせずに異常終了の内容を返す。
+// ただし、バックエンドが起動した別のゴルーチンでの panic と、ランタイムの致命的なエラー
+// (マップへの同時書き込みなど) は回復できず、プロセスごと終了する。
+func BackendMain(conn net.Conn, cac CACState) (crash *ChildCrash) {
+	var s *session
+	defer func() {
+		if r := recover(); r != nil {
+			crash = &ChildCrash{Reason: r, Stack: debug.Stack()}
+			if s != nil {
+				crash.PID, crash.Activity = s.pid, s.activity
+			}
+		}
+	}()
+
+	startTime := time.Now()
+	port := libpq.NewPort(conn)
+	defer port.Close()
+
+	// セッションを作る前に出すメッセージにも接続元を付ける
+	logProc := newLogProcInfo(port, startTime)
+
+	timeout := enableAuthTimeout(conn)
+	defer timeout.release()
+	if guc.LogConnections.Get() {
+		if port.RemotePort != "" {
+			errutil.Report(logProc, errutil.Elog(errutil.Log, "connection received: host=%s port=%s", port.RemoteHost, port.RemotePort))
+		} else {
+			errutil.Report(logProc, errutil.Elog(errutil.Log, "connection received: host=%s", port.RemoteHost))
+		}
+	}
+
+	if err := processStartupPacket(logProc, port, false, false); err != nil {
+		// スタートアップパケットが期限までに届かない場合は、何も報告せずに閉じる
+		if !errors.Is(err, errNoStartup) && timeout.aborted() == nil {
+			reportFatal(logProc, port, err)
+		}
+		return
+	}
+
+	switch cac {
+	case CACTooMany:
+		timeout.disable()
+		reportFatal(logProc, port, newError(errcodes.TooManyConnections, "sorry, too many clients already"))
+		return nil
+	case CACShutdown:
+		timeout.disable()
+		reportFatal(logProc, port, newError(errcodes.CannotConnectNow, "the database system is shutting down"))
+		return nil
+	case CACRecovery:
+		timeout.disable()
+		reportFatal(logProc, port, newError(errcodes.CannotConnectNow, "the database system is in recovery mode"))
+		return nil
+	}
+
+	s = newSession(port)
+	s.startTime = startTime
+	logProc.Status = s.logStatus
+	s.logProc = logProc
+	s.authTimeout = timeout
+	defer s.procExit()
+	if err := s.initPostgres(); err != nil {
+		if aborted := timeout.aborted(); aborted != nil {
+			// 中断した接続には送れないため、サーバーログにだけ出力する。
+			// 他のバックエンドの異常終了による中断は、postmaster が報告する
+			if aborted != errCrashShutdown {
+				_ = errutil.EmitErrorReport(s.logProc, nil, nil, makeErrorData(errutil.Fatal, aborted, ""), "")
+			}
+			return nil
+		}
+		// 認証の途中でクライアントが切断した場合 (パスワードを尋ねるために接続し直す psql など) は
+		// 何も報告しない
+		if !errors.Is(err, libpq.ErrConnectionClosed) {
+			reportFatal(logProc, port, err)
+		}
+		return nil
+	}
+
+	postgresMain(s)
+	return nil
+}
+
+// processStartupPacket はスタートアップパケットを読み取り、Port に接続パラメータを設定する
+// (ProcessStartupPacket 相当)。SSLRequest には SSL が有効なら接続を TLS に切り替え、
+// GSSENCRequest には暗号化非対応 ('N') と応答し、続けて送られてくる本来の
+// スタートアップパケットを処理する。
+func processStartupPacket(proc *errutil.ProcInfo, port *libpq.Port, sslDone, gssDone bool) error {
+	buf, err := port.GetStartupPacket()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			// 何も送らずに切断するのはポートスキャンやヘルスチェックによくあるので、ログは出さない
+			return errNoStartup
+		}
+		return newError(errcodes.ProtocolViolation, "%s", err.Error())
+	}
+
+	proto := libpq.ProtocolVersion(binary.BigEndian.Uint32(buf[:4]))
+
+	switch {
+	case proto == libpq.CancelRequestCode:
+		// PID と秘密鍵が続く。応答は返さずに接続を閉じる。
+		if len(buf) == 12 {
+			processCancelRequest(proc, int32(binary.BigEndian.Uint32(buf[4:8])),
+				int32(binary.BigEndian.Uint32(buf[8:12])))
+		}
+		return errNoStartup
+	case proto == libpq.NegotiateSSLCode && !sslDone:
+		// SSL が有効なら 'S' と応答して TLS のハンドシェイクを行い、
+		// 暗号化した接続でもう一度スタートアップパケットを受け取る。
+		// Unix ドメインソケットの接続は暗号化しない
+		useSSL := libpq.SSLLoaded() && !port.IsUnixSocket()
+		if err := respondNegotiation(port, useSSL); err != nil {
+			return errNoStartup
+		}
+		if useSSL {
+			if err := port.SecureOpenServer(); err != nil {
+				if errors.Is(err, libpq.ErrUnencryptedDataAfterSSLRequest) {
+					return newError(errcodes.ProtocolViolation, "%s", err.Error())
+				}
+				// ハンドシェイクに失敗した接続には ErrorResponse を送れない
+				errutil.Report(proc, errutil.New(errutil.Log, errcodes.ProtocolViolation, "%s", err.Error()))
+				return errNoStartup
+			}
+		}
+		return processStartupPacket(proc, port, true, gssDone)
+	case proto == libpq.NegotiateGSSCode && !gssDone:
+		// GSSAPI による暗号化は未対応
+		if err := respondNegotiation(port, false); err != nil {
+			return errNoStartup
+		}
+		return processStartupPacket(proc, port, sslDone, true)
+	}
+
+	if proto.Major() < libpq.PgProtocolEarliest.Major() || proto.Major() > libpq.PgProtocolLatest.Major() {
+		return newError(errcodes.FeatureNotSupported, "unsupported frontend protocol %d.%d: server supports %d.0 to %d.%d",
+			proto.Major(), proto.Minor(), libpq.PgProtocolEarliest.Major(),
+			libpq.PgProtocolLatest.Major(), libpq.PgProtocolLatest.Minor())
+	}
+	port.Proto = proto
+
+	// 残りは "名前\0値\0" の並びで、空の名前で終わる
+	var unrecognized []string
+	fields := strings.Split(string(buf[4:]), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		name, value := fields[i], fields[i+1]
+		if name == "" {
+			break
+		}
+		switch {
+		case name == "database":
+			port.DatabaseName = value
+		case name == "user":
+			port.UserName = value
+		case name == "options":
+			port.CmdlineOptions = value
+		case name == "replication":
+			return newError(errcodes.FeatureNotSupported, "replication connections are not supported")
+		case strings.HasPrefix(name, "_pq_."):
+			// プロトコル拡張オプションは未対応として NegotiateProtocolVersion で通知する
+			unrecognized = append(unrecognized, name)
+		default:
+			if name == "application_name" {
+				port.ApplicationName = value
+			}
+			port.GUCOptions = append(port.GUCOptions, [2]string{name, value})
+		}
+	}
+
+	if port.UserName == "" {
+		return newError(errcodes.InvalidAuthorizationSpecification, "no PostgreSQL user name specified in startup packet")
+	}
+	if port.DatabaseName == "" {
+		port.DatabaseName = port.UserName
+	}
+
+	if proto.Minor() > libpq.PgProtocolLatest.Minor() || len(unrecognized) > 0 {
+		if err := sendNegotiateProtocolVersion(port, unrecognized); err != nil {
+			return errNoStartup
+		}
+	}
+	return nil
+}
+
+// respondNegotiation は SSLRequest / GSSENCRequest に1バイトで応答する。
+// accept が true なら 'S' (暗号化する)、false なら 'N' (暗号化しない)。
+func respondNegotiation(port *libpq.Port, accept bool) error {
+	reply := byte('N')
+	if accept {
+		reply = 'S'
+	}
+	if err := port.PutRaw([]byte{reply}); err != nil {
+		return err
+	}
+	return port.Flush()
+}
+
+// sendNegotiateProtocolVersion は NegotiateProtocolVersion メッセージを送る (SendNegotiateProtocolVersion 相当)
+func sendNegotiateProtocolVersion(port *libpq.Port, unrecognized []string) error {
+	buf := libpq.BeginMessage(libpq.PqMsgNegotiateProtocolVersion)
+	buf.SendInt32(int32(libpq.PgProtocolLatest))
+	buf.SendInt32(int32(len(unrecognized)))
+	for _, name := range unrecognized {
+		buf.SendString(name)
+	}
+	return buf.EndMessage(port)
+}
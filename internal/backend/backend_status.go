@@ -0,0 +1,172 @@
+package backend
+
+import (
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+)
+
+// ----------------------------------------------------------------
+// バックエンドの活動の記録 (utils/activity/backend_status.c 相当)
+// ----------------------------------------------------------------
+// 各バックエンドは、実行中の問い合わせ、セッションの状態、トランザクションと問い合わせを
+// 始めた時刻をバックエンドの一覧に記録する。待っているものは一覧の待機イベントの Slot に記録する。
+// pg_stat_activity は他のバックエンドの記録を参照する。
+//
+// track_activities が off のセッションは問い合わせと状態を記録せず、状態は disabled になる。
+// 問い合わせの文字列は track_activity_query_size - 1 バイトまでを記録する。
+
+// backendState はセッションの状態 (BackendState 相当)
+type backendState int
+
+const (
+	stateUndefined backendState = iota
+	stateIdle
+	stateRunning
+	stateIdleInTransaction
+	stateFastpath
+	stateIdleInTransactionAborted
+	stateDisabled
+)
+
+// backendStateNames は pg_stat_activity の state 列に表示する名前
+var backendStateNames = [...]string{
+	stateIdle:                     "idle",
+	stateRunning:                  "active",
+	stateIdleInTransaction:        "idle in transaction",
+	stateFastpath:                 "fastpath function call",
+	stateIdleInTransactionAborted: "idle in transaction (aborted)",
+	stateDisabled:                 "disabled",
+}
+
+// backendStatus はバックエンドの活動 (PgBackendStatus 相当)
+type backendStatus struct {
+	// backendType は pg_stat_activity の backend_type に表示する種類
+	backendType  string
+	databaseName string
+	appname      string
+	// clientAddr と clientPort は接続元のアドレスとポート番号。Unix ドメインソケットの接続では
+	// clientAddr が空で clientPort が -1、クライアントのないバックグラウンドワーカーでは clientPort が 0
+	clientAddr string
+	clientPort int32
+	// procStart はバックエンドを起動した時刻、xactStart は実行中のトランザクションを始めた時刻、
+	// activityStart は activity の問い合わせを始めた時刻、stateStart は state になった時刻
+	procStart     time.Time
+	xactStart     time.Time
+	activityStart time.Time
+	stateStart    time.Time
+	state         backendState
+	activity      string
+	// queryID は実行中または最後に実行した文の問い合わせ ID。分からなければ 0
+	queryID int64
+}
+
+// updateBackendStatus は pid のバックエンドの活動を update で更新する
+func updateBackendStatus(pid int32, update func(st *backendStatus)) {
+	backendList.Lock()
+	defer backendList.Unlock()
+	if entry, ok := backendList.entries[pid]; ok {
+		update(&entry.status)
+	}
+}
+
+// pgstatBestart は認証を終えたセッションの活動の記録を始める (pgstat_bestart 相当)
+func (s *session) pgstatBestart() {
+	st := backendStatus{
+		backendType:  "client backend",
+		databaseName: s.databaseName,
+		appname:      s.gucs.GetString(guc.ApplicationName),
+		clientPort:   -1,
+		procStart:    s.startTime,
+	}
+	if s.port != nil && !s.port.IsUnixSocket() {
+		st.clientAddr = s.port.RemoteHost
+		if port, err := strconv.Atoi(s.port.RemotePort); err == nil {
+			st.clientPort = int32(port)
+		}
+	}
+	updateBackendStatus(s.pid, func(entry *backendStatus) { *entry = st })
+}
+
+// reportActivity はセッションの状態と、実行を始めた問い合わせを記録する (pgstat_report_activity 相当)。
+// query が空の場合は、前の問い合わせを残す。
+func (s *session) reportActivity(state backendState, query string) {
+	trackActivities := s.gucs.GetBool(guc.TrackActivities)
+	appname := s.gucs.GetString(guc.ApplicationName)
+	now := time.Now()
+	if query != "" {
+		query = clipQuery(query, guc.TrackActivityQuerySize.Get()-1)
+	}
+	updateBackendStatus(s.pid, func(st *backendStatus) {
+		// application_name には値を変えたときに記録する仕組みがないため、状態と共に写す
+		st.appname = appname
+		if !trackActivities {
+			// off にしたときに、それまでの記録を消す
+			if st.state != stateDisabled {
+				st.state = stateDisabled
+				st.stateStart = time.Time{}
+				st.activity = ""
+				st.activityStart = time.Time{}
+				st.queryID = 0
+			}
+			return
+		}
+		// 新しい文の問い合わせ ID は解析した後に分かるため、前の文の ID を消しておく
+		if state == stateRunning {
+			st.queryID = 0
+		}
+		st.state = state
+		st.stateStart = now
+		if query != "" {
+			st.activity = query
+			st.activityStart = now
+		}
+	})
+}
+
+// reportQueryId は実行を始めた文の問い合わせ ID を記録する (pgstat_report_query_id 相当)。
+// force が false の場合は、既に ID を記録していれば上書きしない。関数の中から実行した文ではなく、
+// クライアントが送った文の ID を残すためである。
+func (s *session) reportQueryId(queryID int64, force bool) {
+	if !s.gucs.GetBool(guc.TrackActivities) {
+		return
+	}
+	updateBackendStatus(s.pid, func(st *backendStatus) {
+		if st.queryID != 0 && !force {
+			return
+		}
+		st.queryID = queryID
+	})
+}
+
+// getMyQueryId は記録した実行中の文の問い合わせ ID を返す (pgstat_get_my_query_id 相当)
+func (s *session) getMyQueryId() int64 {
+	backendList.Lock()
+	defer backendList.Unlock()
+	if entry, ok := backendList.entries[s.pid]; ok {
+		return entry.status.queryID
+	}
+	return 0
+}
+
+// reportXactTimestamp はトランザクションを始めた時刻を記録する (pgstat_report_xact_timestamp 相当)。
+// トランザクションを終えたときはゼロの時刻を渡す。
+func (s *session) reportXactTimestamp(t time.Time) {
+	if !s.gucs.GetBool(guc.TrackActivities) {
+		return
+	}
+	updateBackendStatus(s.pid, func(st *backendStatus) { st.xactStart = t })
+}
+
+// clipQuery は問い合わせを文字の途中で切らずに limit バイトまでに切り詰める (pg_mbcliplen 相当)
+func clipQuery(query string, limit int) string {
+	if len(query) <= limit {
+		return query
+	}
+	for limit > 0 && !utf8.RuneStart(query[limit]) {
+		limit--
+	}
+	return query[:limit]
+}
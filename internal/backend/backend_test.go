@@ -0,0 +1,75 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+)
+
+// fakeConn は受信するバイト列を先に決めておく接続。送信したバイト列は読み捨てる。
+type fakeConn struct {
+	r *bytes.Reader
+}
+
+func newFakePort(data []byte) *libpq.Port {
+	return libpq.NewPort(&fakeConn{r: bytes.NewReader(data)})
+}
+
+func (c *fakeConn) Read(p []byte) (int, error)  { return c.r.Read(p) }
+func (c *fakeConn) Write(p []byte) (int, error) { return len(p), nil }
+func (c *fakeConn) Close() error                { return nil }
+func (c *fakeConn) LocalAddr() net.Addr         { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5432} }
+func (c *fakeConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000}
+}
+func (c *fakeConn) SetDeadline(time.Time) error      { return nil }
+func (c *fakeConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *fakeConn) SetWriteDeadline(time.Time) error { return nil }
+
+// startupPacket は長さを先頭に付けたスタートアップパケットを作る。
+func startupPacket(proto libpq.ProtocolVersion, body string) []byte {
+	p := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	p = binary.BigEndian.AppendUint32(p, uint32(proto))
+	return append(p, body...)
+}
+
+// FuzzStartupPacket は任意のスタートアップパケットを processStartupPacket に渡しても panic せず、
+// 接続を受け付けるか、クライアントに返せるエラーか、黙って切断するかのどれかになることを確かめる。
+func FuzzStartupPacket(f *testing.F) {
+	f.Add(startupPacket(libpq.PgProtocolLatest, "user\x00alice\x00database\x00postgres\x00\x00"))
+	f.Add(startupPacket(libpq.PgProtocolLatest, "user\x00alice\x00_pq_.foo\x00bar\x00\x00"))
+	f.Add(startupPacket(libpq.PgProtocolLatest, "user\x00"))
+	f.Add(startupPacket(libpq.PgProtocolLatest, "\x00"))
+	f.Add(startupPacket(libpq.ProtocolVersion(2<<16), "user\x00alice\x00\x00"))
+	f.Add(startupPacket(libpq.CancelRequestCode, "\x00\x00\x00\x01\x00\x00\x00\x02"))
+	f.Add(append(startupPacket(libpq.NegotiateSSLCode, ""),
+		startupPacket(libpq.PgProtocolLatest, "user\x00alice\x00\x00")...))
+	f.Add(append(startupPacket(libpq.NegotiateGSSCode, ""), startupPacket(libpq.NegotiateGSSCode, "")...))
+	f.Add([]byte{0, 0, 0, 8})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0, 3, 0, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		port := newFakePort(data)
+		err := processStartupPacket(nil, port, false, false)
+		var edata *errutil.ErrorData
+		switch {
+		case err == nil:
+			if port.UserName == "" || port.DatabaseName == "" {
+				t.Fatalf("accepted a startup packet without user or database: %+v", port)
+			}
+		case errors.Is(err, errNoStartup):
+		case errors.As(err, &edata):
+			if edata.Level != errutil.Error && edata.Level != errutil.Fatal {
+				t.Fatalf("unexpected error level %v: %s", edata.Level, edata.Message)
+			}
+		default:
+			t.Fatalf("unexpected error type %T: %v", err, err)
+		}
+	})
+}
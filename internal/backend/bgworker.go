@@ -0,0 +1,100 @@
+package backend
+
+import (
+	"errors"
+	"runtime/debug"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/postmaster/bgworker"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// バックグラウンドワーカーの実行 (postmaster/bgworker.c の BackgroundWorkerMain 相当)
+// ----------------------------------------------------------------
+// postmaster が起動したワーカーをバックエンドの一覧に登録し、max_worker_processes の枠を使う。
+// 登録したワーカーは pg_stat_activity に backend_type をワーカーの種類として表示され、
+// シャットダウンや異常終了の後の終了の要求もクライアントのバックエンドと同じく受け取る。
+//
+// データベースに接続したワーカーも、SQL を実行する仕組み (SPI) がまだないため、
+// データベースとユーザーを記録するだけである。
+
+// bgworkerContext は動作中のワーカー1つ (bgworker.Context)
+type bgworkerContext struct {
+	worker     *bgworker.Worker
+	pid        int32
+	interrupts *miscadmin.Interrupts
+	waitEvent  *waitevent.Slot
+	connected  bool
+	// logProc はサーバーログに出すワーカーの情報
+	logProc *errutil.ProcInfo
+}
+
+func (c *bgworkerContext) Worker() *bgworker.Worker          { return c.worker }
+func (c *bgworkerContext) PID() int32                        { return c.pid }
+func (c *bgworkerContext) Interrupts() *miscadmin.Interrupts { return c.interrupts }
+func (c *bgworkerContext) WaitEvent() *waitevent.Slot        { return c.waitEvent }
+
+// InitializeConnection はワーカーを dbname のデータベースに username として接続させる
+// (BackgroundWorkerInitializeConnection 相当)
+func (c *bgworkerContext) InitializeConnection(dbname, username string) error {
+	if c.worker.Flags&bgworker.BackendDatabaseConnection == 0 {
+		return newError(errcodes.ProgramLimitExceeded, "database connection requirement not indicated during registration")
+	}
+	if c.connected {
+		return newError(errcodes.ObjectNotInPrerequisiteState, "background worker \"%s\" is already connected to a database", c.worker.Name)
+	}
+	if catalog.GetDatabaseOid(dbname) == catalog.InvalidOid {
+		return newError(errcodes.UndefinedDatabase, "database \"%s\" does not exist", dbname)
+	}
+	if _, ok := catalog.SearchRole(username); !ok {
+		return newError(errcodes.InvalidAuthorizationSpecification, "role \"%s\" does not exist", username)
+	}
+	setBackendRole(c.pid, username)
+	updateBackendStatus(c.pid, func(st *backendStatus) { st.databaseName = dbname })
+	c.logProc.UserName, c.logProc.DatabaseName = username, dbname
+	c.connected = true
+	return nil
+}
+
+// BackgroundWorkerMain は postmaster が起動したワーカーを実行し、終わるまで戻らない
+// (BackgroundWorkerMain 相当)。ワーカーの本体が返したエラーを exitErr に返す。panic した場合は
+// postmaster 全体を道連れにせずに異常終了の内容を返す。
+func BackgroundWorkerMain(rw *bgworker.RegisteredWorker) (crash *ChildCrash, exitErr error) {
+	w := rw.Worker()
+	ctx := &bgworkerContext{worker: w, interrupts: &miscadmin.Interrupts{}, waitEvent: &waitevent.Slot{}}
+	defer func() {
+		if r := recover(); r != nil {
+			crash = &ChildCrash{PID: ctx.pid, Reason: r, Stack: debug.Stack()}
+		}
+	}()
+
+	pid, _, err := registerBackend(procBgworker, ctx.interrupts, ctx.waitEvent)
+	if err != nil {
+		errutil.Report(nil, makeErrorData(errutil.Fatal, err, ""))
+		return nil, err
+	}
+	defer unregisterBackend(pid)
+	ctx.pid = pid
+	now := time.Now()
+	ctx.logProc = &errutil.ProcInfo{Pid: pid, BackendType: w.Type, StartTime: now}
+	updateBackendStatus(pid, func(st *backendStatus) {
+		*st = backendStatus{backendType: w.Type, procStart: now}
+	})
+	bgworker.ReportWorkerStarted(rw, pid, ctx.interrupts)
+
+	err = w.Main(ctx)
+	switch {
+	case err == nil:
+	case errors.Is(err, miscadmin.ErrProcDie):
+		errutil.Report(ctx.logProc, errutil.New(errutil.Fatal, errcodes.AdminShutdown,
+			"terminating background worker \"%s\" due to administrator command", w.Name))
+	default:
+		errutil.Report(ctx.logProc, makeErrorData(errutil.Error, err, ""))
+	}
+	return nil, err
+}
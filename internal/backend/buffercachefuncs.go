@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/buffer"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+)
+
+// ----------------------------------------------------------------
+// 共有バッファの内容の一覧 (contrib/pg_buffercache/pg_buffercache_pages.c 相当)
+// ----------------------------------------------------------------
+// pg_buffercache は共有バッファごとに1行を返し、持っているページのリレーションとブロック番号、
+// 汚れているか、使用回数、ピンの数を示す。ページを持っていないバッファは bufferid 以外を NULL に
+// する。C言語版では拡張だが、Go言語版は拡張を読み込めないため pg_catalog に置く。pg_monitor の
+// 権限を持つロールだけが参照できる。
+
+// テーブル空間の OID (DEFAULTTABLESPACE_OID, GLOBALTABLESPACE_OID 相当)。テーブル空間はまだ
+// ないため、共有リレーションなら pg_global、そうでなければ pg_default とする。
+const (
+	defaultTablespaceOid catalog.Oid = 1663
+	globalTablespaceOid  catalog.Oid = 1664
+)
+
+func init() {
+	fmgr.RegisterSetReturning("pg_buffercache_pages", pgBuffercachePages)
+}
+
+// pgBuffercachePages は pg_buffercache の行を返す (pg_buffercache_pages 相当)
+func pgBuffercachePages(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	if !catalog.HasPrivsOfRole(ctx.UserName(), catalog.RolePgMonitor) {
+		return nil, newError(errcodes.InsufficientPrivilege, "permission denied for view pg_buffercache")
+	}
+	entries := buffer.GetBufferCacheEntries()
+	rows := make([][]adt.Datum, 0, len(entries))
+	for _, e := range entries {
+		row := make([]adt.Datum, 9)
+		row[0] = int32(e.Buffer)
+		if e.Valid {
+			tablespace := defaultTablespaceOid
+			if e.Tag.RLocator.DbOid == catalog.InvalidOid {
+				tablespace = globalTablespaceOid
+			}
+			row[1] = e.Tag.RLocator.RelNumber
+			row[2] = tablespace
+			row[3] = e.Tag.RLocator.DbOid
+			row[4] = int16(e.Tag.ForkNum)
+			row[5] = int64(e.Tag.BlockNum)
+			row[6] = e.IsDirty
+			row[7] = int16(e.UsageCount)
+			row[8] = int32(e.PinningBackends)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
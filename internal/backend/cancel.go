@@ -0,0 +1,251 @@
+package backend
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/procarray"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// 問い合わせのキャンセル (postmaster の BackendList, processCancelRequest 相当)
+// ----------------------------------------------------------------
+// 各バックエンドには起動時に PID と秘密鍵を割り当て、BackendKeyData でクライアントに通知する。
+// クライアントは別の接続で CancelRequest としてこの2つを送り、一致したバックエンドの
+// 実行中の文を取り消させる。
+//
+// C言語版では postmaster が子プロセスの一覧を持ち、該当するプロセスに SIGINT を送る。
+// Go言語版ではバックエンドはゴルーチンで PID を持たないため、起動時に番号を採番して
+// PID の代わりとし、シグナルの代わりにバックエンドの割り込みフラグを立てる。
+// CancelRequest はスタートアップパケットとして届くため、一覧はバックエンド側に置く。
+// 一覧は接続数の上限の確認 (C言語版の PGPROC の空きの確認) にも使う。枠は種類ごとに分け、
+// クライアントの接続は max_connections の枠だけを使う。
+
+// procKind はバックエンドが使う枠の種類 (InitProcGlobal の freeProcs, autovacFreeProcs,
+// bgworkerFreeProcs 相当)
+type procKind int
+
+const (
+	// procClient はクライアントの接続。max_connections の枠を使う
+	procClient procKind = iota
+	// procAutovacuum は autovacuum のランチャーとワーカー。autovacuum_max_workers + 1 の枠を使う
+	procAutovacuum
+	// procBgworker はバックグラウンドワーカー。max_worker_processes の枠を使う
+	procBgworker
+)
+
+// slots は種類ごとの枠の数を返す。全ての種類の合計は miscadmin.MaxBackends になる
+func (k procKind) slots() int {
+	switch k {
+	case procAutovacuum:
+		return guc.AutovacuumMaxWorkers.Get() + 1
+	case procBgworker:
+		return guc.MaxWorkerProcesses.Get()
+	}
+	return guc.MaxConnections.Get()
+}
+
+// backendEntry は動作中のバックエンド1つ分 (Backend 相当)
+type backendEntry struct {
+	kind       procKind
+	cancelKey  int32
+	interrupts *miscadmin.Interrupts
+	// role は認証を終えたセッションのユーザー名 (PGPROC の roleId 相当)。認証の前は空
+	role string
+	// ssl は接続の SSL の状態 (PgBackendStatus の st_sslstatus 相当)。SSL を使わない接続では nil
+	ssl *backendSSLStatus
+	// status はセッションの活動。認証を終えると記録を始める
+	status backendStatus
+	// waitEvent はバックエンドが待っているもの (PGPROC の wait_event_info 相当)
+	waitEvent *waitevent.Slot
+}
+
+var backendList = struct {
+	sync.Mutex
+	entries map[int32]*backendEntry
+	lastPid int32
+}{entries: make(map[int32]*backendEntry)}
+
+// registerBackend はバックエンドを一覧に登録し、PID と秘密鍵を割り当てる (InitProcess 相当)。
+// kind の種類の枠が全て使われている場合は登録せずにエラーを返す。
+// 予約された枠の確認は、ロールが決まった後に checkReservedConnections で行う。
+func registerBackend(kind procKind, interrupts *miscadmin.Interrupts, waitEvent *waitevent.Slot) (pid, cancelKey int32, err error) {
+	var key [4]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return 0, 0, fmt.Errorf("could not generate random cancel key: %w", err)
+	}
+	cancelKey = int32(binary.BigEndian.Uint32(key[:]))
+
+	backendList.Lock()
+	defer backendList.Unlock()
+	if countProcs(kind) >= kind.slots() {
+		return 0, 0, newError(errcodes.TooManyConnections, "sorry, too many clients already")
+	}
+	for {
+		backendList.lastPid++
+		if backendList.lastPid <= 0 {
+			backendList.lastPid = 1
+		}
+		if _, used := backendList.entries[backendList.lastPid]; !used {
+			break
+		}
+	}
+	pid = backendList.lastPid
+	backendList.entries[pid] = &backendEntry{kind: kind, cancelKey: cancelKey, interrupts: interrupts, waitEvent: waitEvent}
+	return pid, cancelKey, nil
+}
+
+// countProcs は kind の種類の枠を使っているバックエンドの数を返す。backendList のロックを持って呼ぶ。
+func countProcs(kind procKind) int {
+	n := 0
+	for _, entry := range backendList.entries {
+		if entry.kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// checkReservedConnections は、登録済みのバックエンドが予約された枠を使ってよいかを確かめる
+// (InitPostgres の HaveNFreeProcs による確認相当)。残りの枠が superuser_reserved_connections と
+// reserved_connections の合計に満たない場合、スーパーユーザーでなければ superuser_reserved_connections
+// の枠を、さらに pg_use_reserved_connections の権限がなければ reserved_connections の枠を使えない。
+func checkReservedConnections(role string) error {
+	if catalog.IsSuperuser(role) {
+		return nil
+	}
+	reserved := guc.SuperuserReservedConnections.Get() + guc.ReservedConnections.Get()
+	if reserved <= 0 {
+		return nil
+	}
+	backendList.Lock()
+	free := procClient.slots() - countProcs(procClient)
+	backendList.Unlock()
+	if free >= reserved {
+		return nil
+	}
+	if free < guc.SuperuserReservedConnections.Get() {
+		return newError(errcodes.TooManyConnections, "remaining connection slots are reserved for roles with the %s attribute",
+			"SUPERUSER")
+	}
+	if !catalog.HasPrivsOfRole(role, catalog.RolePgUseReservedConnections) {
+		return newError(errcodes.TooManyConnections, "remaining connection slots are reserved for roles with privileges of the \"%s\" role",
+			catalog.RolePgUseReservedConnections)
+	}
+	return nil
+}
+
+// setBackendRole は認証を終えたセッションのユーザー名を一覧に記録する
+func setBackendRole(pid int32, role string) {
+	backendList.Lock()
+	defer backendList.Unlock()
+	if entry, ok := backendList.entries[pid]; ok {
+		entry.role = role
+	}
+}
+
+// countUserBackends は role のユーザーで認証を終えたバックエンドの数を返す (CountUserBackends 相当)
+func countUserBackends(role string) int {
+	backendList.Lock()
+	defer backendList.Unlock()
+	n := 0
+	for _, entry := range backendList.entries {
+		if entry.kind == procClient && entry.role == role {
+			n++
+		}
+	}
+	return n
+}
+
+// unregisterBackend はバックエンドを一覧と実行中のトランザクションの一覧から除く
+// (CleanupBackend と ProcArrayRemove 相当)
+func unregisterBackend(pid int32) {
+	backendList.Lock()
+	defer backendList.Unlock()
+	delete(backendList.entries, pid)
+	procarray.Remove(pid)
+}
+
+// TerminateBackends は全てのバックエンドにセッションの終了を要求する
+// (fast シャットダウンでの SignalChildren(SIGTERM) 相当)。
+// 認証を終えていないバックエンドは、クライアントに何も送らずに接続を閉じる。
+func TerminateBackends() {
+	signalAllBackends(miscadmin.ErrProcDie, (*miscadmin.Interrupts).SetProcDiePending)
+}
+
+// QuitBackends は他のバックエンドの異常終了を受けて、全てのバックエンドに直ちに終了するよう
+// 要求する (HandleChildCrash での SignalChildren(SIGQUIT) 相当)
+func QuitBackends() {
+	signalAllBackends(errCrashShutdown, (*miscadmin.Interrupts).SetQuickDiePending)
+}
+
+// signalAllBackends は認証を終えていないバックエンドの送受信を reason で中断させ、
+// 一覧の全てのバックエンドに set で割り込みを要求する
+func signalAllBackends(reason error, set func(*miscadmin.Interrupts)) {
+	startingBackends.Lock()
+	starting := make([]*authTimeout, 0, len(startingBackends.m))
+	for t := range startingBackends.m {
+		starting = append(starting, t)
+	}
+	startingBackends.Unlock()
+	// authTimeout の disable は自身のロックを持ったまま一覧のロックを取るため、一覧のロックを放してから中断させる
+	for _, t := range starting {
+		t.abort(reason)
+	}
+
+	backendList.Lock()
+	defer backendList.Unlock()
+	for _, entry := range backendList.entries {
+		set(entry.interrupts)
+	}
+}
+
+// ResetSharedState は異常終了の後、全てのバックエンドが終わった時点でバックエンド間で共有する
+// 一覧を空にする (再初期化での CreateSharedMemoryAndSemaphores 相当)。終了したバックエンドは
+// 通常は自身を一覧から除くが、異常終了したバックエンドの後始末は当てにしない。
+// PID は振り直さない。終了したバックエンドの PID を指定した合図が新しいバックエンドに届かないようにするため。
+// ロールの一覧はC言語版ではディスク上のカタログにあるため、初期化しない。
+func ResetSharedState() {
+	startingBackends.Lock()
+	startingBackends.m = make(map[*authTimeout]struct{})
+	startingBackends.Unlock()
+
+	backendList.Lock()
+	backendList.entries = make(map[int32]*backendEntry)
+	backendList.Unlock()
+}
+
+// processCancelRequest は CancelRequest の PID と秘密鍵が一致するバックエンドに
+// 取り消しを要求する (processCancelRequest 相当)。一致しなければ何もしない。
+// 要求元に応答は返さない。
+func processCancelRequest(proc *errutil.ProcInfo, pid, cancelKey int32) {
+	backendList.Lock()
+	entry, ok := backendList.entries[pid]
+	backendList.Unlock()
+
+	switch {
+	case !ok:
+		errutil.Report(proc, errutil.Elog(errutil.Log, "PID %d in cancel request did not match any process", pid))
+	case entry.cancelKey != cancelKey:
+		errutil.Report(proc, errutil.Elog(errutil.Log, "wrong key in cancel request for process %d", pid))
+	default:
+		entry.interrupts.SetQueryCancelPending()
+	}
+}
+
+// sendBackendKeyData は BackendKeyData メッセージを送る。
+func sendBackendKeyData(port *libpq.Port, pid, cancelKey int32) error {
+	buf := libpq.BeginMessage(libpq.PqMsgBackendKeyData)
+	buf.SendInt32(pid)
+	buf.SendInt32(cancelKey)
+	return buf.EndMessage(port)
+}
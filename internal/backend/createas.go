@@ -0,0 +1,187 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/heap"
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/executor"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/smgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// CREATE TABLE AS と SELECT INTO (commands/createas.c 相当)
+// ----------------------------------------------------------------
+// 問い合わせの出力列と同じ列のテーブルを作り、問い合わせを実行器で実行して、結果の行を
+// intorelReceiver で新しいテーブルに書く。行は COPY FROM と同じく、ある程度の数をためてから
+// heap.Relation の MultiInsert でページごとにまとめて置き、BulkInsertState のリングのバッファを
+// 使って共有バッファを追い出さないようにする。
+//
+// C言語版は wal_level が minimal なら行を WAL に書かずに、ページを書き出してファイルを同期する。
+// WAL はまだないため、どの wal_level でも minimal と同じく行ごとの WAL を書かず、文の終わりに
+// 新しいテーブルのページを書き出してファイルを同期することで行を永続化する。
+//
+// 一時テーブルはまだ作れない。
+
+// 行をためる数と大きさの上限 (copyfrom.c の MAX_BUFFERED_TUPLES と MAX_BUFFERED_BYTES 相当)
+const (
+	maxBufferedTuples = 1000
+	maxBufferedBytes  = 65535
+)
+
+// execCreateTableAs は CREATE TABLE AS と SELECT INTO を実行する (ExecCreateTableAs 相当)
+func (s *session) execCreateTableAs(stmt *parser.CreateTableAsStmt) (*executor.Result, error) {
+	into := stmt.Into
+	if into.Temp {
+		return nil, newError(errcodes.FeatureNotSupported, "temporary tables are not supported")
+	}
+	nspid, err := rangeVarGetCreationNamespace(into.Rel)
+	if err != nil {
+		return nil, err
+	}
+	// 既にあるテーブルは IF NOT EXISTS なら作らずに済ませる (CreateTableAsRelExists 相当)
+	if _, exists := catalog.RelnameGetRelation(catalog.NamespaceGetName(nspid), into.Rel.Relname); exists {
+		if !stmt.IfNotExists {
+			return nil, newError(errcodes.DuplicateTable, "relation \"%s\" already exists", into.Rel.Relname)
+		}
+		if err := s.reportNotice(errutil.New(errutil.Notice, errcodes.DuplicateTable, "relation \"%s\" already exists, skipping", into.Rel.Relname)); err != nil {
+			return nil, err
+		}
+		return &executor.Result{CommandTag: createCommandTag(stmt)}, nil
+	}
+
+	query := stmt.Analyzed
+	attrs, err := intoRelationAttrs(into, query.TargetList)
+	if err != nil {
+		return nil, err
+	}
+	// 作ったテーブルの定義とファイルは、アボートしたら atEOXactRelations と smgrDoPendingDeletes が消す
+	rel, err := s.heapCreateWithCatalog(into.Rel.Relname, nspid, attrs, nil)
+	if err != nil {
+		return nil, err
+	}
+	if into.SkipData {
+		// WITH NO DATA は問い合わせを実行しない (create_ctas_nodata 相当)
+		return &executor.Result{CommandTag: createCommandTag(stmt)}, nil
+	}
+
+	fxid, err := s.xact.GetTopFullTransactionId()
+	if err != nil {
+		return nil, err
+	}
+	dest := newIntorelReceiver(s, rel, s.xact.GetCurrentCommandId(true), transam.XidFromFullTransactionId(fxid))
+	defer dest.bistate.Free()
+	qd := s.newQueryDesc(query, nil)
+	qd.Dest = dest
+	if err := executor.ExecutorStart(qd); err != nil {
+		return nil, err
+	}
+	if err := executor.ExecutorRun(qd, 0); err != nil {
+		return nil, err
+	}
+	if err := dest.flush(); err != nil {
+		return nil, err
+	}
+	dest.bistate.Free()
+	// WAL に書かなかった行を永続化する (heap_sync 相当)
+	locator := executor.RelationLocator(rel)
+	if err := s.buffers.FlushRelationBuffers(locator); err != nil {
+		return nil, err
+	}
+	if err := smgr.Immedsync(locator, storage.MainForkNum); err != nil {
+		return nil, err
+	}
+	return &executor.Result{CommandTag: fmt.Sprintf("SELECT %d", qd.Processed())}, nil
+}
+
+// rangeVarGetCreationNamespace は新しいリレーションを作るスキーマを返す
+// (RangeVarGetCreationNamespace 相当)。スキーマを書かなければ public に作る。
+func rangeVarGetCreationNamespace(rv *parser.RangeVar) (catalog.Oid, error) {
+	if rv.Schemaname == "" {
+		return catalog.PgPublicNamespace, nil
+	}
+	nspid, ok := catalog.NamespaceGetOid(rv.Schemaname)
+	if !ok {
+		return catalog.InvalidOid, newError(errcodes.InvalidSchemaName, "schema \"%s\" does not exist", rv.Schemaname)
+	}
+	if nspid == catalog.PgCatalogNamespace {
+		// allow_system_table_mods はまだないため、常に拒む
+		return catalog.InvalidOid, withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to create \"%s.%s\"", rv.Schemaname, rv.Relname),
+			"System catalog modifications are currently disallowed.")
+	}
+	return nspid, nil
+}
+
+// intoRelationAttrs は問い合わせの出力列から新しいテーブルの列を作る (intorel_startup と
+// CheckAttributeNamesTypes 相当)。列名を指定していれば、前から順に出力列の名前の代わりに使う。
+// 列とドメインの依存はまだ記録できないため、ドメインの列はその基の型の列にする。
+func intoRelationAttrs(into *parser.IntoClause, tlist []*parser.TargetEntry) ([]catalog.FormPgAttribute, error) {
+	attrs := make([]catalog.FormPgAttribute, len(tlist))
+	for i, te := range tlist {
+		attr := catalog.FormPgAttribute{Attname: te.ResName, Atttypid: catalog.GetBaseType(te.Expr.ExprType())}
+		if i < len(into.ColNames) {
+			attr.Attname = into.ColNames[i]
+		}
+		for _, prev := range attrs[:i] {
+			if prev.Attname == attr.Attname {
+				return nil, newError(errcodes.DuplicateColumn, "column \"%s\" specified more than once", attr.Attname)
+			}
+		}
+		if err := checkAttributeType(attr.Attname, attr.Atttypid); err != nil {
+			return nil, err
+		}
+		attrs[i] = attr
+	}
+	return attrs, nil
+}
+
+// intorelReceiver は問い合わせの結果の行を新しいテーブルに書く DestReceiver (DR_intorel 相当)
+type intorelReceiver struct {
+	relation *catalog.FormPgClass
+	rel      *heap.Relation
+	cid      adt.CommandId
+	xid      adt.TransactionId
+	bistate  *heap.BulkInsertState
+	// buffered はまだ挿入していないタプルで、bufferedBytes はその大きさの合計
+	buffered      []*heap.HeapTuple
+	bufferedBytes int
+}
+
+// newIntorelReceiver はトランザクション xid のコマンド cid として rel に行を書く intorelReceiver を
+// 作る (CreateIntoRelDestReceiver と intorel_startup 相当)
+func newIntorelReceiver(s *session, rel *catalog.FormPgClass, cid adt.CommandId, xid adt.TransactionId) *intorelReceiver {
+	hrel := heap.Open(s.buffers, s.lockProc, rel.Oid, executor.RelationLocator(rel), executor.RelationTupleDesc(rel))
+	return &intorelReceiver{relation: rel, rel: hrel, cid: cid, xid: xid, bistate: hrel.GetBulkInsertState()}
+}
+
+// ReceiveSlot は1行をタプルにしてためる (intorel_receive 相当)。上限に達したらまとめて挿入する。
+func (r *intorelReceiver) ReceiveSlot(row []adt.Datum) error {
+	tup, err := executor.FormHeapTuple(r.relation, r.rel.Desc, row)
+	if err != nil {
+		return err
+	}
+	r.buffered = append(r.buffered, tup)
+	r.bufferedBytes += tup.Len()
+	if len(r.buffered) >= maxBufferedTuples || r.bufferedBytes >= maxBufferedBytes {
+		return r.flush()
+	}
+	return nil
+}
+
+// flush はためたタプルをまとめて挿入する (CopyMultiInsertBufferFlush 相当)
+func (r *intorelReceiver) flush() error {
+	if len(r.buffered) == 0 {
+		return nil
+	}
+	if err := r.rel.MultiInsert(r.buffered, r.cid, r.xid, r.bistate); err != nil {
+		return err
+	}
+	r.buffered, r.bufferedBytes = nil, 0
+	return nil
+}
@@ -0,0 +1,115 @@
+package backend
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/common/md5"
+	"github.com/Tsubasa-2005/go-postgres/internal/common/scram"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// 保存されたパスワードの参照 (libpq/crypt.c 相当)
+// ----------------------------------------------------------------
+
+// passwordType は保存されたパスワードの形式 (PasswordType 相当)
+type passwordType int
+
+const (
+	passwordTypePlaintext passwordType = iota
+	passwordTypeMD5
+	passwordTypeSCRAMSHA256
+)
+
+// getPasswordType は保存されたパスワードの形式を判定する (get_password_type 相当)
+func getPasswordType(secret string) passwordType {
+	if len(secret) == md5.PasswdLen && strings.HasPrefix(secret, "md5") && isHex(secret[3:]) {
+		return passwordTypeMD5
+	}
+	if _, ok := scram.ParseSecret(secret); ok {
+		return passwordTypeSCRAMSHA256
+	}
+	return passwordTypePlaintext
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// getRolePassword はロールの保存されたパスワードを返す (get_role_password 相当)。
+// ロールがないか、パスワードがないか有効期限を過ぎていれば、空文字列と、
+// サーバーログにだけ出力する理由を返す。
+func getRolePassword(role string) (secret, logdetail string) {
+	r, ok := catalog.SearchRole(role)
+	if !ok {
+		return "", fmt.Sprintf("Role \"%s\" does not exist.", role)
+	}
+	if r.Rolpassword == "" {
+		return "", fmt.Sprintf("User \"%s\" has no password assigned.", role)
+	}
+	if r.HasValidUntil && adt.TimestampTz(r.Rolvaliduntil) < adt.GetCurrentTimestamp() {
+		return "", fmt.Sprintf("User \"%s\" has an expired password.", role)
+	}
+	return r.Rolpassword, ""
+}
+
+// encryptPassword は保存するパスワードを target の形式で作る (encrypt_password 相当)。
+// 既に MD5 のハッシュか SCRAM の秘密情報であれば、そのまま保存する。iterations は
+// SCRAM の秘密情報を作るときの反復回数 (scram_iterations)。
+func encryptPassword(target passwordType, role, password string, iterations int) (string, error) {
+	if getPasswordType(password) != passwordTypePlaintext {
+		return password, nil
+	}
+	switch target {
+	case passwordTypeMD5:
+		return md5.Encrypt(password, role), nil
+	case passwordTypeSCRAMSHA256:
+		return scramBuildSecret(password, iterations), nil
+	}
+	return "", fmt.Errorf("cannot encrypt password to requested type")
+}
+
+// md5CryptVerify は MD5 のチャレンジへのクライアントの応答を検証する (md5_crypt_verify 相当)。
+// 一致しなければ、サーバーログにだけ出力する理由を返す。
+func md5CryptVerify(role, secret, clientPass string, salt []byte) string {
+	if getPasswordType(secret) != passwordTypeMD5 {
+		// 保存されたパスワードが MD5 でなければ、MD5 の応答は検証できない
+		return fmt.Sprintf("User \"%s\" has a password that cannot be used with MD5 authentication.", role)
+	}
+	expected := md5.Encrypt(secret[3:], string(salt))
+	if subtle.ConstantTimeCompare([]byte(clientPass), []byte(expected)) != 1 {
+		return fmt.Sprintf("Password does not match for user \"%s\".", role)
+	}
+	return ""
+}
+
+// plainCryptVerify は平文で受け取ったパスワードを、保存された形式に応じて検証する
+// (plain_crypt_verify 相当)。一致しなければ、サーバーログにだけ出力する理由を返す。
+func plainCryptVerify(role, secret, clientPass string) string {
+	switch getPasswordType(secret) {
+	case passwordTypeSCRAMSHA256:
+		if scramVerifyPlainPassword(clientPass, secret) {
+			return ""
+		}
+	case passwordTypeMD5:
+		expected := md5.Encrypt(clientPass, role)
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) == 1 {
+			return ""
+		}
+	default:
+		// 平文のパスワードはもう保存しないが、以前の形式として受け付ける
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(clientPass)) == 1 {
+			return ""
+		}
+	}
+	return fmt.Sprintf("Password does not match for user \"%s\".", role)
+}
@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/postmaster/datachecksumsworker"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+)
+
+// ----------------------------------------------------------------
+// データチェックサムを切り替える関数 (datachecksumsworker.c の SQL 関数相当)
+// ----------------------------------------------------------------
+// 既定の引数がまだないため、pg_enable_data_checksums(cost_delay int DEFAULT 0, cost_limit int
+// DEFAULT 100) は引数の数の異なる3つの関数として定義する。スーパーユーザーだけが実行できる。
+
+func init() {
+	fmgr.Register("enable_data_checksums", enableDataChecksums)
+	fmgr.Register("disable_data_checksums", disableDataChecksums)
+}
+
+// enableDataChecksums はデータチェックサムの有効化を始める (enable_data_checksums 相当)。
+// 有効化を終えるのを待たずに戻る。
+func enableDataChecksums(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	if !catalog.IsSuperuser(ctx.UserName()) {
+		return nil, newError(errcodes.InsufficientPrivilege, "must be superuser to change data checksum state")
+	}
+	costDelay, costLimit := int32(0), int32(100)
+	if len(fcinfo.Args) > 0 {
+		costDelay = fcinfo.Args[0].(int32)
+	}
+	if len(fcinfo.Args) > 1 {
+		costLimit = fcinfo.Args[1].(int32)
+	}
+	return adt.Void{}, datachecksumsworker.EnableDataChecksums(time.Duration(costDelay)*time.Millisecond, int(costLimit))
+}
+
+// disableDataChecksums はデータチェックサムを無効にする (disable_data_checksums 相当)
+func disableDataChecksums(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	if !catalog.IsSuperuser(ctx.UserName()) {
+		return nil, newError(errcodes.InsufficientPrivilege, "must be superuser to change data checksum state")
+	}
+	return adt.Void{}, datachecksumsworker.DisableDataChecksums()
+}
@@ -0,0 +1,98 @@
+package backend
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 定義のオプションの値の取り出し (commands/define.c 相当)
+// ----------------------------------------------------------------
+// CREATE OPERATOR や ALTER OPERATOR ... SET のような、"名前 = 値" の並びで定義する文の値を
+// 目的の形で取り出す。文法は値を型名、名前の並び、文字列、数値のいずれかとして解析するため、
+// 取り出す側で期待する形に読み替える。
+
+// defGetString は値を文字列として返す (defGetString 相当)
+func defGetString(def *parser.DefElem) (string, error) {
+	switch v := def.Arg.(type) {
+	case nil:
+		return "", newError(errcodes.SyntaxError, "%s requires a parameter", def.Defname)
+	case *parser.Integer:
+		return strconv.Itoa(int(v.Ival)), nil
+	case *parser.Float:
+		return v.Fval, nil
+	case *parser.Boolean:
+		if v.Boolval {
+			return "true", nil
+		}
+		return "false", nil
+	case *parser.String:
+		return v.Sval, nil
+	case *parser.TypeName:
+		return strings.Join(v.Names, "."), nil
+	case []string:
+		return strings.Join(v, "."), nil
+	}
+	return "", newError(errcodes.InternalError, "unrecognized node type: %T", def.Arg)
+}
+
+// defGetBoolean は値を真偽値として返す (defGetBoolean 相当)。値を書かなければ真とみなす。
+func defGetBoolean(def *parser.DefElem) (bool, error) {
+	switch v := def.Arg.(type) {
+	case nil:
+		return true, nil
+	case *parser.Integer:
+		switch v.Ival {
+		case 0:
+			return false, nil
+		case 1:
+			return true, nil
+		}
+	case *parser.Boolean:
+		return v.Boolval, nil
+	default:
+		s, err := defGetString(def)
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(s) {
+		case "true", "on":
+			return true, nil
+		case "false", "off":
+			return false, nil
+		}
+	}
+	return false, newError(errcodes.SyntaxError, "%s requires a Boolean value", def.Defname)
+}
+
+// defGetQualifiedName は値を修飾された名前として返す (defGetQualifiedName 相当)
+func defGetQualifiedName(def *parser.DefElem) ([]string, error) {
+	switch v := def.Arg.(type) {
+	case nil:
+		return nil, newError(errcodes.SyntaxError, "%s requires a parameter", def.Defname)
+	case *parser.TypeName:
+		return v.Names, nil
+	case []string:
+		return v, nil
+	case *parser.String:
+		// 予約語は文法が文字列として渡す
+		return []string{v.Sval}, nil
+	}
+	return nil, newError(errcodes.SyntaxError, "argument of %s must be a name", def.Defname)
+}
+
+// defGetTypeName は値を型名として返す (defGetTypeName 相当)
+func defGetTypeName(def *parser.DefElem) (*parser.TypeName, error) {
+	switch v := def.Arg.(type) {
+	case nil:
+		return nil, newError(errcodes.SyntaxError, "%s requires a parameter", def.Defname)
+	case *parser.TypeName:
+		return v, nil
+	case *parser.String:
+		return &parser.TypeName{Names: []string{v.Sval}, Location: def.Location}, nil
+	}
+	return nil, newError(errcodes.SyntaxError, "argument of %s must be a type name", def.Defname)
+}
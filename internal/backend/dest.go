@@ -0,0 +1,102 @@
+package backend
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/executor"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 結果の送り先 (tcop/dest.c 相当)
+// ----------------------------------------------------------------
+// 問い合わせの結果は、postmaster から起動したバックエンドではクライアントにメッセージとして
+// 送り、単一ユーザーモードでは標準出力に人が読める形で書く。単純問い合わせの処理は
+// 送り先を意識せず、ここの関数を通して結果を出力する。
+
+// commandDest は結果の送り先 (CommandDest 相当)
+type commandDest int
+
+const (
+	// destRemote はクライアントに送る (DestRemote 相当)
+	destRemote commandDest = iota
+	// destDebug は単一ユーザーモードの標準出力に書く (DestDebug 相当)
+	destDebug
+)
+
+// beginCommand は結果行の前に列の情報を出力する (BeginCommand と receiver の rStartup 相当)。
+// 結果行を返さない文では desc が nil で、何も出力しない。
+func (s *session) beginCommand(desc executor.TupleDesc) error {
+	if desc == nil {
+		return nil
+	}
+	if s.whereToSendOutput == destDebug {
+		debugStartup(s.out, desc)
+		return nil
+	}
+	return sendRowDescription(s.port, desc, nil)
+}
+
+// createDestReceiver は結果行の送り先を作る (CreateDestReceiver 相当)。limit は結果の行数と
+// 大きさの制限で、nil なら制限しない。
+func (s *session) createDestReceiver(desc executor.TupleDesc, limit *resultLimit) executor.DestReceiver {
+	if s.whereToSendOutput == destDebug {
+		return &debugtupReceiver{out: s.out, desc: desc, limit: limit}
+	}
+	return newPrinttup(s.port, desc, nil, limit)
+}
+
+// resultLimit は1つの文がクライアントに返す結果の行数と大きさの制限。statement_row_limit と
+// statement_result_size_limit の値を文の実行を始めたときに読み、ポータルが持つ。
+// 拡張問い合わせで行数を区切って何度も Execute しても、合計で制限する。
+type resultLimit struct {
+	// maxRows と maxKB は制限の値。-1 なら制限しない
+	maxRows int
+	maxKB   int
+	rows    int64
+	bytes   int64
+}
+
+// newResultLimit はセッションの設定から結果の制限を作る。どちらも制限しないなら nil を返す。
+func (s *session) newResultLimit() *resultLimit {
+	maxRows, maxKB := s.gucs.GetInt(guc.StatementRowLimit), s.gucs.GetInt(guc.StatementResultSizeLimit)
+	if maxRows < 0 && maxKB < 0 {
+		return nil
+	}
+	return &resultLimit{maxRows: maxRows, maxKB: maxKB}
+}
+
+// add は size バイトの結果行を1行送る前に呼び、制限を超えるならエラーを返す
+func (l *resultLimit) add(size int) error {
+	if l == nil {
+		return nil
+	}
+	if l.maxRows >= 0 && l.rows+1 > int64(l.maxRows) {
+		return newError(errcodes.ConfigurationLimitExceeded,
+			"number of result rows exceeds statement_row_limit (%d)", l.maxRows)
+	}
+	if l.maxKB >= 0 && l.bytes+int64(size) > int64(l.maxKB)*1024 {
+		return newError(errcodes.ConfigurationLimitExceeded,
+			"result size exceeds statement_result_size_limit (%dkB)", l.maxKB)
+	}
+	l.rows++
+	l.bytes += int64(size)
+	return nil
+}
+
+// endCommand は文の実行が終わったことを通知する (EndCommand 相当)。
+// 単一ユーザーモードではコマンドタグを出力しない。
+func (s *session) endCommand(tag string) error {
+	if s.whereToSendOutput == destDebug {
+		return nil
+	}
+	return sendCommandComplete(s.port, tag)
+}
+
+// nullCommand は空の問い合わせを受け取ったことを通知する (NullCommand 相当)
+func (s *session) nullCommand() error {
+	if s.whereToSendOutput == destDebug {
+		return nil
+	}
+	return s.port.PutMessage(libpq.PqMsgEmptyQueryResponse, nil)
+}
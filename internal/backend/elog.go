@@ -0,0 +1,130 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/executor"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/file"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/snapmgr"
+)
+
+// ----------------------------------------------------------------
+// エラーの報告 (utils/error/elog.c の一部相当)
+// ----------------------------------------------------------------
+// 各パッケージのエラーを errutil.ErrorData に変換し、セッションの log_min_messages と
+// client_min_messages に従ってサーバーログとクライアントに報告する。サーバーログの行には
+// セッションの logProc の情報を付ける。
+
+// newError は SQLSTATE 付きの ERROR を作る。
+func newError(code, format string, args ...any) error {
+	return errutil.New(errutil.Error, code, format, args...)
+}
+
+// withDetail は newError で作ったエラーに、クライアントにも送る詳細を付ける (errdetail 相当)
+func withDetail(err error, format string, args ...any) error {
+	if ed, ok := err.(*errutil.ErrorData); ok {
+		ed.WithDetail(format, args...)
+	}
+	return err
+}
+
+// withHint は newError で作ったエラーに、対処の方法を付ける (errhint 相当)
+func withHint(err error, format string, args ...any) error {
+	if ed, ok := err.(*errutil.ErrorData); ok {
+		ed.WithHint(format, args...)
+	}
+	return err
+}
+
+// newNotice は NOTICE を作る
+func newNotice(format string, args ...any) *errutil.ErrorData {
+	return errutil.Elog(errutil.Notice, format, args...)
+}
+
+// makeErrorData はエラーから level の水準で報告する内容を取り出す。position は SyntaxError の
+// 場合だけ、query 中の文字数で設定する。
+func makeErrorData(level errutil.Level, err error, query string) *errutil.ErrorData {
+	edata := &errutil.ErrorData{Level: level, Code: errcodes.InternalError, Message: err.Error()}
+	var ed *errutil.ErrorData
+	var se *parser.SyntaxError
+	var ge *guc.Error
+	var ee *executor.Error
+	var ae *adt.Error
+	var fe *file.Error
+	var le *lmgr.Error
+	switch {
+	case errors.As(err, &ed):
+		*edata = *ed
+		edata.Level = level
+	case errors.As(err, &ge):
+		edata.Code, edata.Message, edata.Detail, edata.Hint = ge.Code, ge.Message, ge.Detail, ge.Hint
+	case errors.As(err, &ee):
+		edata.Code, edata.Message = ee.Code, ee.Message
+	case errors.As(err, &ae):
+		edata.Code, edata.Message = ae.Code, ae.Message
+	case errors.As(err, &fe):
+		edata.Code, edata.Message = fe.Code, fe.Message
+	case errors.As(err, &le):
+		edata.Code, edata.Message = le.Code, le.Message
+	case errors.Is(err, miscadmin.ErrQueryCanceled):
+		edata.Code = errcodes.QueryCanceled
+	case errors.Is(err, miscadmin.ErrProcDie):
+		edata.Code = errcodes.AdminShutdown
+	case errors.Is(err, snapmgr.ErrSnapshotTooOld):
+		edata.Code = errcodes.SnapshotTooOld
+	case errors.As(err, &se):
+		edata.Code, edata.Message, edata.Hint = errcodes.SyntaxError, se.Message, se.Hint
+		if se.Code != "" {
+			edata.Code = se.Code
+		}
+		if se.Position >= 0 && se.Position <= len(query) {
+			edata.Position = utf8.RuneCountInString(query[:se.Position]) + 1
+		}
+	}
+	edata.Context = errutil.ContextOf(err)
+	return edata
+}
+
+// reportFatal は FATAL を報告する。呼び出し側はこの後セッションを終了する。
+// セッションを始める前にも使うため、サーバー全体の設定で報告する。
+func reportFatal(proc *errutil.ProcInfo, port *libpq.Port, err error) {
+	if errutil.EmitErrorReport(proc, nil, port, makeErrorData(errutil.Fatal, err, ""), "") == nil && port != nil {
+		_ = port.Flush()
+	}
+}
+
+// logStatus はサーバーログに出すセッションの状態を返す (errutil.ProcInfo.Status)
+func (s *session) logStatus() errutil.ProcStatus {
+	st := errutil.ProcStatus{
+		CommandTag: s.commandTag,
+		QueryID:    s.getMyQueryId(),
+	}
+	// トランザクションの状態は、バックエンドの一覧に登録して PID が決まってから作る
+	if s.xact != nil {
+		st.Xid = uint32(transam.XidFromFullTransactionId(s.xact.GetTopFullTransactionIdIfAny()))
+		st.Vxid = fmt.Sprintf("%d/%d", s.pid, s.xact.LocalTransactionId())
+	}
+	return st
+}
+
+// reportWarning は WARNING を報告する。クライアントには NoticeResponse として送る。
+func (s *session) reportWarning(code, msg string) error {
+	return s.reportNotice(errutil.New(errutil.Warning, code, "%s", msg))
+}
+
+// reportNotice は NOTICE や WARNING を報告する。クライアントには NoticeResponse として送る。
+// 単一ユーザーモードではクライアントがいないため、サーバーログにだけ出力する。
+func (s *session) reportNotice(edata *errutil.ErrorData) error {
+	return errutil.EmitErrorReport(s.logProc, s.gucs, s.port, edata, "")
+}
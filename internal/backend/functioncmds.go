@@ -0,0 +1,308 @@
+package backend
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 関数の属性の変更と型変換の作成と削除 (commands/functioncmds.c の AlterFunction、CreateCast、
+// DropCast 相当)
+// ----------------------------------------------------------------
+// ALTER FUNCTION で関数の SECURITY DEFINER と SET 句を変える。SECURITY DEFINER の関数は所有者の
+// 権限で実行し、SET 句のパラメータは関数を実行する間だけ設定する。どちらも実行器が関数を呼ぶときに
+// 適用する。SECURITY DEFINER の関数は、呼び出したユーザーが作ったオブジェクトを search_path で
+// 拾わないように、SET 句で search_path を固定するべきである。固定していなければ警告する。
+//
+// CREATE CAST で型変換を pg_cast に加える。変換の方法は3つある。
+//
+//   - WITH FUNCTION は関数を呼んで変換する。関数の1つ目の引数は変換元の型から、結果は変換先の型へ
+//     値をそのまま使えなければならない。2つ目と3つ目の引数を取る場合は、型修飾子と明示的な変換で
+//     あるかを受け取る
+//   - WITHOUT FUNCTION は値をそのまま使う。誤って使うとサーバーが異常終了するため、スーパーユーザー
+//     だけが作れる。内部表現の長さが異なる型の間では作れない
+//   - WITH INOUT は変換元の出力関数と変換先の入力関数で変換する
+//
+// 変換を作る、削除するには、変換元か変換先の型の所有者の権限が必要である。関数を作る文
+// (CREATE FUNCTION) がまだないため、WITH FUNCTION に使えるのは組み込み関数だけである。
+
+// castDescription は変換の説明を返す (getObjectDescription の OCLASS_CAST 相当)
+func castDescription(cast catalog.FormPgCast) string {
+	return fmt.Sprintf("cast from %s to %s", catalog.FormatType(cast.Castsource), catalog.FormatType(cast.Casttarget))
+}
+
+// lookupFuncWithArgs は名前と引数の型から関数を探す (LookupFuncWithArgs 相当)。引数の型を
+// 書かなかった場合は、その名前の関数が1つだけでなければエラーにする。
+func lookupFuncWithArgs(owa *parser.ObjectWithArgs) (*catalog.FormPgProc, error) {
+	name := owa.Objname[len(owa.Objname)-1]
+	if owa.ArgsUnspecified {
+		cands := catalog.FuncnameGetCandidates(name, -1)
+		switch len(cands) {
+		case 0:
+			return nil, newError(errcodes.UndefinedFunction, "could not find a function named \"%s\"", name)
+		case 1:
+			return cands[0], nil
+		}
+		return nil, withHint(newError(errcodes.AmbiguousFunction, "function name \"%s\" is not unique", name),
+			"Specify the argument list to select the function unambiguously.")
+	}
+
+	argtypes := make([]catalog.Oid, len(owa.Objargs))
+	argnames := make([]string, len(owa.Objargs))
+	for i, tn := range owa.Objargs {
+		typid, err := parser.TypenameTypeID(tn)
+		if err != nil {
+			return nil, newError(errcodes.UndefinedObject, "%s", err.Error())
+		}
+		argtypes[i] = typid
+		argnames[i] = catalog.FormatType(typid)
+	}
+	for _, cand := range catalog.FuncnameGetCandidates(name, len(argtypes)) {
+		match := true
+		for i, t := range argtypes {
+			if cand.Proargtypes[i] != t {
+				match = false
+				break
+			}
+		}
+		if match {
+			return cand, nil
+		}
+	}
+	return nil, newError(errcodes.UndefinedFunction, "function %s(%s) does not exist", name, strings.Join(argnames, ", "))
+}
+
+// funcSignatureString は関数を "名前(引数の型, ...)" の形で返す (format_procedure 相当)
+func funcSignatureString(proc *catalog.FormPgProc) string {
+	argnames := make([]string, len(proc.Proargtypes))
+	for i, t := range proc.Proargtypes {
+		argnames[i] = catalog.FormatType(t)
+	}
+	return fmt.Sprintf("%s(%s)", proc.Proname, strings.Join(argnames, ", "))
+}
+
+// alterFunction は ALTER FUNCTION、ALTER PROCEDURE、ALTER ROUTINE 文を実行する (AlterFunction 相当)。
+// 関数の所有者の権限が必要である。組み込み関数の所有者はブートストラップスーパーユーザーである。
+func (s *session) alterFunction(stmt *parser.AlterFunctionStmt) error {
+	proc, err := lookupFuncWithArgs(stmt.Func)
+	if err != nil {
+		return err
+	}
+	// 手続き (prokind = 'p') はまだないため、全て関数である
+	if stmt.Objtype == parser.ObjectProcedure {
+		return newError(errcodes.WrongObjectType, "%s is not a procedure", funcSignatureString(proc))
+	}
+	owner, _ := catalog.SearchRoleByOid(catalog.BootstrapSuperuserID)
+	if !catalog.HasPrivsOfRole(s.userName, owner.Rolname) {
+		return newError(errcodes.InsufficientPrivilege, "must be owner of function %s", proc.Proname)
+	}
+
+	var securityDefElem *parser.DefElem
+	var setItems []*parser.VariableSetStmt
+	for _, action := range stmt.Actions {
+		switch action.Defname {
+		case "security":
+			if securityDefElem != nil {
+				return newError(errcodes.SyntaxError, "conflicting or redundant options")
+			}
+			securityDefElem = action
+		case "set":
+			setItems = append(setItems, action.Arg.(*parser.VariableSetStmt))
+		default:
+			return fmt.Errorf("option \"%s\" not recognized", action.Defname)
+		}
+	}
+
+	prosecdef := proc.Prosecdef
+	if securityDefElem != nil {
+		prosecdef = securityDefElem.Arg.(*parser.Boolean).Boolval
+	}
+	proconfig := proc.Proconfig
+	context := s.gucContext()
+	for _, set := range setItems {
+		if proconfig, err = s.updateProconfig(proconfig, set, context); err != nil {
+			return err
+		}
+	}
+	catalog.UpdateProcAttrs(proc.Oid, func(p *catalog.FormPgProc) {
+		p.Prosecdef, p.Proconfig = prosecdef, proconfig
+	})
+
+	if prosecdef && !slices.ContainsFunc(proconfig, func(item string) bool {
+		return strings.HasPrefix(item, "search_path=")
+	}) {
+		return s.reportNotice(errutil.New(errutil.Warning, errcodes.Warning, "SECURITY DEFINER function %s does not set search_path", funcSignatureString(proc)).
+			WithHint("Attach \"SET search_path\" to the function so that it cannot be subverted by objects the caller creates."))
+	}
+	return nil
+}
+
+// updateProconfig は SET 句と RESET 句を関数の設定の並びに反映した並びを返す (AlterFunction の
+// proconfig の更新相当)。値は SET と同じ権限で確かめる。
+func (s *session) updateProconfig(proconfig []string, set *parser.VariableSetStmt, context guc.Context) ([]string, error) {
+	if set.Kind == parser.VarResetAll {
+		return guc.GUCArrayReset(proconfig, context), nil
+	}
+	var value *string
+	switch set.Kind {
+	case parser.VarSetValue:
+		v := flattenSetVariableArgs(set.Args)
+		value = &v
+	case parser.VarSetCurrent:
+		// SET var FROM CURRENT は実行した時点のセッションの値を使う (ExtractSetVariableArgs 相当)
+		v, err := s.gucs.GetConfigOption(set.Name)
+		if err != nil {
+			return nil, err
+		}
+		value = &v
+	}
+	name, err := guc.ValidateOptionArrayItem(set.Name, value, context)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return guc.GUCArrayDelete(proconfig, name), nil
+	}
+	return guc.GUCArrayAdd(proconfig, name, *value), nil
+}
+
+// createCast は CREATE CAST 文を実行する (CreateCast 相当)
+func (s *session) createCast(stmt *parser.CreateCastStmt) error {
+	sourcetypeid, err := parser.TypenameTypeID(stmt.Sourcetype)
+	if err != nil {
+		return newError(errcodes.UndefinedObject, "%s", err.Error())
+	}
+	targettypeid, err := parser.TypenameTypeID(stmt.Targettype)
+	if err != nil {
+		return newError(errcodes.UndefinedObject, "%s", err.Error())
+	}
+	if sourcetypeid == catalog.UNKNOWNOID {
+		return newError(errcodes.WrongObjectType, "source data type %s is a pseudo-type", catalog.FormatType(sourcetypeid))
+	}
+	if targettypeid == catalog.UNKNOWNOID {
+		return newError(errcodes.WrongObjectType, "target data type %s is a pseudo-type", catalog.FormatType(targettypeid))
+	}
+	if !s.typeOwnercheck(sourcetypeid) && !s.typeOwnercheck(targettypeid) {
+		return newError(errcodes.InsufficientPrivilege, "must be owner of type %s or type %s",
+			catalog.FormatType(sourcetypeid), catalog.FormatType(targettypeid))
+	}
+
+	// ドメインへの変換とドメインからの変換は基の型の変換として探すため、作っても使われない
+	if catalog.TypeIsDomain(sourcetypeid) {
+		if err := s.reportWarning(errcodes.Warning, "cast will be ignored because the source data type is a domain"); err != nil {
+			return err
+		}
+	} else if catalog.TypeIsDomain(targettypeid) {
+		if err := s.reportWarning(errcodes.Warning, "cast will be ignored because the target data type is a domain"); err != nil {
+			return err
+		}
+	}
+
+	cast := catalog.FormPgCast{Castsource: sourcetypeid, Casttarget: targettypeid}
+	switch {
+	case stmt.Func != nil:
+		cast.Castmethod = catalog.CoercionMethodFunction
+	case stmt.Inout:
+		cast.Castmethod = catalog.CoercionMethodInOut
+	default:
+		cast.Castmethod = catalog.CoercionMethodBinary
+	}
+
+	nargs := 0
+	if stmt.Func != nil {
+		proc, err := lookupFuncWithArgs(stmt.Func)
+		if err != nil {
+			return err
+		}
+		nargs = len(proc.Proargtypes)
+		switch {
+		case nargs < 1 || nargs > 3:
+			return newError(errcodes.InvalidFunctionDefinition, "cast function must take one to three arguments")
+		case !parser.IsBinaryCoercible(sourcetypeid, proc.Proargtypes[0]):
+			return newError(errcodes.InvalidFunctionDefinition, "argument of cast function must match or be binary-coercible from source data type")
+		case nargs > 1 && proc.Proargtypes[1] != catalog.INT4OID:
+			return newError(errcodes.InvalidFunctionDefinition, "second argument of cast function must be type %s", "integer")
+		case nargs > 2 && proc.Proargtypes[2] != catalog.BOOLOID:
+			return newError(errcodes.InvalidFunctionDefinition, "third argument of cast function must be type %s", "boolean")
+		case !parser.IsBinaryCoercible(proc.Prorettype, targettypeid):
+			return newError(errcodes.InvalidFunctionDefinition, "return data type of cast function must match or be binary-coercible to target data type")
+		}
+		cast.Castfunc = proc.Oid
+	}
+
+	if cast.Castmethod == catalog.CoercionMethodBinary {
+		if !catalog.IsSuperuser(s.userName) {
+			return newError(errcodes.InsufficientPrivilege, "must be superuser to create a cast WITHOUT FUNCTION")
+		}
+		// 値をそのまま使うため、内部表現の長さが同じでなければならない
+		if catalog.TypeLen(sourcetypeid) != catalog.TypeLen(targettypeid) {
+			return newError(errcodes.InvalidFunctionDefinition, "source and target data types are not physically compatible")
+		}
+		if catalog.GetElementType(sourcetypeid) != catalog.InvalidOid || catalog.GetElementType(targettypeid) != catalog.InvalidOid {
+			return newError(errcodes.InvalidFunctionDefinition, "array data types are not binary-compatible")
+		}
+		if catalog.TypeIsDomain(sourcetypeid) || catalog.TypeIsDomain(targettypeid) {
+			return newError(errcodes.InvalidFunctionDefinition, "domain data types must not be marked binary-compatible")
+		}
+	}
+
+	// 同じ型の間の変換は、型修飾子を受け取る長さの変換の関数だけが作れる
+	if sourcetypeid == targettypeid && nargs < 2 {
+		return newError(errcodes.InvalidFunctionDefinition, "source data type and target data type are the same")
+	}
+
+	switch stmt.Context {
+	case parser.CoercionImplicit:
+		cast.Castcontext = catalog.CoercionCodeImplicit
+	case parser.CoercionAssignment:
+		cast.Castcontext = catalog.CoercionCodeAssignment
+	default:
+		cast.Castcontext = catalog.CoercionCodeExplicit
+	}
+	if _, ok := catalog.CreateCast(cast); !ok {
+		return newError(errcodes.DuplicateObject, "cast from type %s to type %s already exists",
+			catalog.FormatType(sourcetypeid), catalog.FormatType(targettypeid))
+	}
+	return nil
+}
+
+// dropCast は DROP CAST 文を実行する (RemoveObjects の OBJECT_CAST と DropCastById 相当)
+func (s *session) dropCast(stmt *parser.DropStmt) error {
+	types := stmt.Objects[0].([]*parser.TypeName)
+	var typids [2]catalog.Oid
+	for i, tn := range types {
+		typid, err := parser.TypenameTypeID(tn)
+		if err != nil {
+			if !stmt.MissingOk {
+				return newError(errcodes.UndefinedObject, "%s", err.Error())
+			}
+			return s.reportNotice(newNotice("type \"%s\" does not exist, skipping", tn.Names[len(tn.Names)-1]))
+		}
+		typids[i] = typid
+	}
+	source, target := catalog.FormatType(typids[0]), catalog.FormatType(typids[1])
+
+	cast, ok := catalog.SearchCast(typids[0], typids[1])
+	if !ok {
+		if !stmt.MissingOk {
+			return newError(errcodes.UndefinedObject, "cast from type %s to type %s does not exist", source, target)
+		}
+		return s.reportNotice(newNotice("cast from type %s to type %s does not exist, skipping", source, target))
+	}
+	if !s.typeOwnercheck(typids[0]) && !s.typeOwnercheck(typids[1]) {
+		return newError(errcodes.InsufficientPrivilege, "must be owner of type %s or type %s", source, target)
+	}
+	if cast.Oid < catalog.FirstNormalObjectID {
+		// 組み込みの変換 (IsPinnedObject 相当)
+		return newError(errcodes.DependentObjectsStillExist, "cannot drop %s because it is required by the database system", castDescription(cast))
+	}
+	catalog.DropCast(cast.Oid)
+	return nil
+}
@@ -0,0 +1,266 @@
+package backend
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/executor"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+)
+
+// ----------------------------------------------------------------
+// SET, RESET, SHOW の実行 (utils/misc/guc_funcs.c 相当)
+// ----------------------------------------------------------------
+// SET の効果は、トランザクションをコミットすればセッションの終わりまで続き、アボートすれば
+// 取り消される。SET LOCAL の効果はトランザクションの終わりまでで、トランザクションブロックの
+// 外では同じ問い合わせ文字列の後の文にだけ効く。
+// 値が変わった GUC_REPORT のパラメータは、ParameterStatus でクライアントに通知する。
+//
+// SHOW ALL と pg_settings は全てのパラメータの値を返す。GUC_SUPERUSER_ONLY のパラメータは
+// pg_read_all_settings の権限を持つロールにだけ表示する。
+
+func init() {
+	fmgr.RegisterSetReturning("show_all_settings", showAllSettings)
+}
+
+// execSetVariableStmt は SET 文と RESET 文を実行する (ExecSetVariableStmt 相当)
+func (s *session) execSetVariableStmt(stmt *parser.VariableSetStmt) error {
+	action := guc.GucActionSet
+	if stmt.IsLocal {
+		if warning := s.xact.WarnNoTransactionBlock("SET LOCAL"); warning != nil {
+			if err := s.reportNotice(warning); err != nil {
+				return err
+			}
+		}
+		action = guc.GucActionLocal
+	}
+	var err error
+	switch stmt.Kind {
+	case parser.VarSetValue:
+		err = s.gucs.SetConfigOption(stmt.Name, flattenSetVariableArgs(stmt.Args), s.gucContext(), guc.PGCSSession, action)
+	case parser.VarSetDefault, parser.VarReset:
+		err = s.gucs.ResetConfigOption(stmt.Name, s.gucContext(), action)
+	case parser.VarResetAll:
+		s.gucs.ResetAllOptions()
+	case parser.VarSetMulti:
+		err = s.setTransactionCharacteristics(stmt)
+	}
+	if err != nil {
+		return err
+	}
+	return s.reportChangedGUCOptions()
+}
+
+// alterSystem は ALTER SYSTEM を実行する (AlterSystemSetConfigFile 相当)。値は postgresql.auto.conf に
+// 書くだけで、設定ファイルを読み直すまで反映しない。パラメータごとの権限の仕組みがないため、
+// スーパーユーザーだけが実行できる。
+func (s *session) alterSystem(stmt *parser.AlterSystemStmt) error {
+	set := stmt.Setstmt
+	if !catalog.IsSuperuser(s.userName) {
+		if set.Kind == parser.VarResetAll {
+			return newError(errcodes.InsufficientPrivilege, "permission denied to perform ALTER SYSTEM RESET ALL")
+		}
+		return newError(errcodes.InsufficientPrivilege, "permission denied to set parameter \"%s\"", set.Name)
+	}
+	switch set.Kind {
+	case parser.VarSetValue:
+		value := flattenSetVariableArgs(set.Args)
+		return guc.AlterSystemSetConfigFile(set.Name, &value, s.waitEvent)
+	case parser.VarSetDefault, parser.VarReset:
+		return guc.AlterSystemSetConfigFile(set.Name, nil, s.waitEvent)
+	}
+	return guc.AlterSystemSetConfigFile("", nil, s.waitEvent)
+}
+
+// alterSetting はデータベースとロールの組み合わせへの設定を変更する (AlterSetting 相当)。
+// 値は SET と同じ権限で確かめ、接続の開始時にスーパーユーザーの権限で設定する。
+func (s *session) alterSetting(database string, role catalog.Oid, set *parser.VariableSetStmt) error {
+	context := s.gucContext()
+	if set.Kind == parser.VarResetAll {
+		catalog.AlterDbRoleSetting(database, role, func(setconfig []string) []string {
+			return guc.GUCArrayReset(setconfig, context)
+		})
+		return nil
+	}
+	var value *string
+	if set.Kind == parser.VarSetValue {
+		v := flattenSetVariableArgs(set.Args)
+		value = &v
+	}
+	name, err := guc.ValidateOptionArrayItem(set.Name, value, context)
+	if err != nil {
+		return err
+	}
+	catalog.AlterDbRoleSetting(database, role, func(setconfig []string) []string {
+		if value == nil {
+			return guc.GUCArrayDelete(setconfig, name)
+		}
+		return guc.GUCArrayAdd(setconfig, name, *value)
+	})
+	return nil
+}
+
+// alterDatabaseSet は ALTER DATABASE SET を実行する (AlterDatabaseSet 相当)。データベースの
+// カタログがないため、データベースの存在は確かめない。データベースの所有者もないため、
+// スーパーユーザーだけが実行できる。
+func (s *session) alterDatabaseSet(stmt *parser.AlterDatabaseSetStmt) error {
+	if !catalog.IsSuperuser(s.userName) {
+		return newError(errcodes.InsufficientPrivilege, "must be owner of database %s", stmt.Dbname)
+	}
+	return s.alterSetting(stmt.Dbname, catalog.InvalidOid, stmt.Setstmt)
+}
+
+// processSettings は接続の開始時に、データベースとロールごとの設定をセッションの値にする
+// (process_settings 相当)。優先するものから順に設定し、設定できない値は警告にする。
+func (s *session) processSettings(role catalog.Oid) error {
+	for _, setting := range []struct {
+		database string
+		role     catalog.Oid
+		source   guc.Source
+	}{
+		{s.databaseName, role, guc.PGCSDatabaseUser},
+		{"", role, guc.PGCSUser},
+		{s.databaseName, catalog.InvalidOid, guc.PGCSDatabase},
+		{"", catalog.InvalidOid, guc.PGCSGlobal},
+	} {
+		setconfig := catalog.GetDbRoleSetting(setting.database, setting.role)
+		for _, err := range s.gucs.ProcessGUCArray(setconfig, guc.PGCSuset, setting.source, guc.GucActionSet) {
+			if err := s.reportNotice(makeErrorData(errutil.Warning, err, "")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// gucContext は SET と RESET でパラメータを変更する時の状況を返す。
+// スーパーユーザーであれば PGCSuset、それ以外は PGCUserset。
+func (s *session) gucContext() guc.Context {
+	if catalog.IsSuperuser(s.userName) {
+		return guc.PGCSuset
+	}
+	return guc.PGCUserset
+}
+
+// flattenSetVariableArgs は SET の値の並びを1つの文字列にする (flatten_set_variable_args 相当)
+func flattenSetVariableArgs(args []*parser.AConst) string {
+	vals := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.Val.(type) {
+		case *parser.String:
+			vals[i] = v.Sval
+		case *parser.Integer:
+			vals[i] = strconv.Itoa(int(v.Ival))
+		case *parser.Float:
+			vals[i] = v.Fval
+		}
+	}
+	return strings.Join(vals, ", ")
+}
+
+// getPGVariableResultDesc は SHOW の結果の列定義を返す (GetPGVariableResultDesc 相当)。
+// 列名はパラメータの正式な綴りにする。SHOW ALL は名前、値、説明の3列を返す。
+func getPGVariableResultDesc(name string) executor.TupleDesc {
+	if strings.EqualFold(name, "all") {
+		return executor.TupleDesc{
+			{Name: "name", TypeID: catalog.TEXTOID, TypMod: -1},
+			{Name: "setting", TypeID: catalog.TEXTOID, TypMod: -1},
+			{Name: "description", TypeID: catalog.TEXTOID, TypMod: -1},
+		}
+	}
+	if g := guc.Lookup(name); g != nil {
+		name = g.Name
+	}
+	return executor.TupleDesc{{Name: name, TypeID: catalog.TEXTOID, TypMod: -1}}
+}
+
+// getPGVariable は SHOW name と SHOW ALL を実行する (GetPGVariable / ShowGUCConfigOption 相当)
+func (s *session) getPGVariable(name string) (*executor.Result, error) {
+	if strings.EqualFold(name, "all") {
+		return s.showAllGUCConfig(), nil
+	}
+	if g := guc.Lookup(name); g != nil && g.Flags&guc.GucSuperuserOnly != 0 &&
+		!catalog.HasPrivsOfRole(s.userName, catalog.RolePgReadAllSettings) {
+		return nil, withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to examine \"%s\"", g.Name),
+			"Only roles with privileges of the \"%s\" role may examine this parameter.", catalog.RolePgReadAllSettings)
+	}
+	value, err := s.gucs.GetConfigOption(name)
+	if err != nil {
+		return nil, err
+	}
+	return &executor.Result{
+		Desc:       getPGVariableResultDesc(name),
+		Rows:       [][]adt.Datum{{value}},
+		CommandTag: "SHOW",
+	}, nil
+}
+
+// configOptionIsVisible は SHOW ALL と pg_settings にパラメータを表示するかを返す
+// (ConfigOptionIsVisible 相当)
+func (s *session) configOptionIsVisible(g *guc.ConfigGeneric) bool {
+	if g.Flags&guc.GucNoShowAll != 0 {
+		return false
+	}
+	return g.Flags&guc.GucSuperuserOnly == 0 || catalog.HasPrivsOfRole(s.userName, catalog.RolePgReadAllSettings)
+}
+
+// showAllGUCConfig は SHOW ALL を実行する (ShowAllGUCConfig 相当)
+func (s *session) showAllGUCConfig() *executor.Result {
+	var rows [][]adt.Datum
+	for _, g := range guc.Variables() {
+		if !s.configOptionIsVisible(g) {
+			continue
+		}
+		// 表示できるパラメータの値は必ず得られる
+		value, _ := s.gucs.GetConfigOption(g.Name)
+		rows = append(rows, []adt.Datum{g.Name, value, nullIfEmpty(g.ShortDesc)})
+	}
+	return &executor.Result{Desc: getPGVariableResultDesc("all"), Rows: rows, CommandTag: "SHOW"}
+}
+
+// showAllSettings は pg_settings の行を名前の順に返す (show_all_settings 相当)。設定ファイルの
+// 場所は pg_read_all_settings の権限を持つロールにだけ表示する。
+func showAllSettings(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	readAll := catalog.HasPrivsOfRole(s.userName, catalog.RolePgReadAllSettings)
+	var rows [][]adt.Datum
+	for _, g := range guc.Variables() {
+		if !s.configOptionIsVisible(g) {
+			continue
+		}
+		v := s.gucs.GetConfigOptionValues(g)
+		row := make([]adt.Datum, 17)
+		row[0] = g.Name
+		row[1] = v.Setting
+		row[2] = nullIfEmpty(v.Unit)
+		row[3] = g.Group.String()
+		row[4] = nullIfEmpty(g.ShortDesc)
+		row[5] = nullIfEmpty(g.LongDesc)
+		row[6] = g.Context.String()
+		row[7] = g.VarType().String()
+		row[8] = v.Source.String()
+		if v.MinVal != nil {
+			row[9], row[10] = *v.MinVal, *v.MaxVal
+		}
+		if v.EnumVals != nil {
+			row[11] = adt.TextArray(v.EnumVals)
+		}
+		row[12] = v.BootVal
+		row[13] = v.ResetVal
+		if readAll && v.SourceFile != "" {
+			row[14], row[15] = v.SourceFile, int32(v.SourceLine)
+		}
+		row[16] = v.PendingRestart
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,102 @@
+package backend
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/executor"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/smgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// テーブルの定義の作成、変更、削除 (catalog/heap.c 相当)
+// ----------------------------------------------------------------
+// リレーションのカタログはトランザクションに従わないため、定義を変えるたびに前の定義を
+// relationUndo に記録し、トランザクションがアボートしたら逆の順に戻す。C言語版ではカタログの
+// 行の可視性がこれを行う。
+
+// relationUndo はアボートしたときに戻すリレーションの定義。old が nil なら、作った定義を消す
+type relationUndo struct {
+	relid catalog.Oid
+	old   *catalog.FormPgClass
+}
+
+// heapCreateWithCatalog は nspid に relname のテーブルの定義とファイルを作る
+// (heap_create_with_catalog 相当)。作ったテーブルには AccessExclusiveLock を取り、他のセッションが
+// コミットの前に読もうとしたら待たせる。
+func (s *session) heapCreateWithCatalog(relname string, nspid catalog.Oid, attrs []catalog.FormPgAttribute, parents []catalog.Oid) (*catalog.FormPgClass, error) {
+	owner, _ := catalog.SearchRole(s.userName)
+	rel := &catalog.FormPgClass{
+		Relname:        relname,
+		Relnamespace:   nspid,
+		Relowner:       owner.Oid,
+		Reldatabase:    catalog.GetDatabaseOid(s.databaseName),
+		Relkind:        catalog.RelkindRelation,
+		Relpersistence: catalog.RelpersistencePermanent,
+		Attrs:          attrs,
+		Inhparents:     parents,
+	}
+	rel.Oid = getNewRelFileNumber(rel.Reldatabase)
+	rel.Relfilenode = rel.Oid
+	if !catalog.CreateRelation(*rel) {
+		return nil, newError(errcodes.DuplicateTable, "relation \"%s\" already exists", rel.Relname)
+	}
+	s.relationUndo = append(s.relationUndo, relationUndo{relid: rel.Oid})
+	if _, err := s.lockProc.LockAcquire(executor.RelationLockTag(rel), lmgr.AccessExclusiveLock, false, false); err != nil {
+		return nil, err
+	}
+	if err := s.relationCreateStorage(executor.RelationLocator(rel)); err != nil {
+		return nil, err
+	}
+	return rel, nil
+}
+
+// getNewRelFileNumber は新しいリレーションの OID を返す (GetNewRelFileNumber 相当)。カタログは
+// サーバーを再起動すると消えるが、前に作ったテーブルのファイルは残るため、ファイルと重ならない
+// OID を選び、ファイルの番号にも使う。
+func getNewRelFileNumber(dboid catalog.Oid) catalog.Oid {
+	for {
+		oid := catalog.GetNewObjectID()
+		if !smgr.Exists(storage.RelFileLocator{DbOid: dboid, RelNumber: oid}, storage.MainForkNum) {
+			return oid
+		}
+	}
+}
+
+// updateRelation はテーブルの定義を置き換える (CatalogTupleUpdate 相当)
+func (s *session) updateRelation(rel *catalog.FormPgClass) {
+	if old, ok := catalog.SearchRelation(rel.Oid); ok && catalog.UpdateRelation(*rel) {
+		s.relationUndo = append(s.relationUndo, relationUndo{relid: rel.Oid, old: old})
+	}
+}
+
+// heapDropWithCatalog はテーブルの定義を削除し、ファイルをコミットしたら消すよう予約する
+// (heap_drop_with_catalog 相当)。定義がもうなければ何もしない。
+func (s *session) heapDropWithCatalog(rel *catalog.FormPgClass) {
+	old, ok := catalog.SearchRelation(rel.Oid)
+	if !ok || !catalog.DropRelation(rel.Oid) {
+		return
+	}
+	s.relationUndo = append(s.relationUndo, relationUndo{relid: rel.Oid, old: old})
+	s.relationDropStorage(executor.RelationLocator(old))
+}
+
+// atEOXactRelations はトランザクションの終わりにリレーションの定義の変更を確定する。アボート
+// したら、記録した変更を逆の順に戻す。
+func (s *session) atEOXactRelations(isCommit bool) {
+	undo := s.relationUndo
+	s.relationUndo = nil
+	if isCommit {
+		return
+	}
+	for i := len(undo) - 1; i >= 0; i-- {
+		u := &undo[i]
+		switch {
+		case u.old == nil:
+			catalog.DropRelation(u.relid)
+		case !catalog.UpdateRelation(*u.old):
+			catalog.CreateRelation(*u.old)
+		}
+	}
+}
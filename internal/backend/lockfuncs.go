@@ -0,0 +1,95 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+)
+
+// ----------------------------------------------------------------
+// 勧告的ロックの関数 (utils/adt/lockfuncs.c の pg_advisory_* 相当)
+// ----------------------------------------------------------------
+// アプリケーションが決めた 64 ビットの鍵に、同じデータベースのセッションどうしで排他と共有の
+// ロックを取る。pg_advisory_lock はセッションが終わるか pg_advisory_unlock で放すまで持ち、
+// pg_advisory_xact_lock はトランザクションの終わりに放す。取れるまで待つ間は、他のロックと
+// 同じく lock_timeout と log_lock_waits の対象になる。
+
+func init() {
+	fmgr.Register("pg_advisory_lock_int8", pgAdvisoryLock(lmgr.ExclusiveLock, true))
+	fmgr.Register("pg_advisory_xact_lock_int8", pgAdvisoryLock(lmgr.ExclusiveLock, false))
+	fmgr.Register("pg_advisory_lock_shared_int8", pgAdvisoryLock(lmgr.ShareLock, true))
+	fmgr.Register("pg_advisory_xact_lock_shared_int8", pgAdvisoryLock(lmgr.ShareLock, false))
+	fmgr.Register("pg_try_advisory_lock_int8", pgTryAdvisoryLock(lmgr.ExclusiveLock, true))
+	fmgr.Register("pg_try_advisory_xact_lock_int8", pgTryAdvisoryLock(lmgr.ExclusiveLock, false))
+	fmgr.Register("pg_try_advisory_lock_shared_int8", pgTryAdvisoryLock(lmgr.ShareLock, true))
+	fmgr.Register("pg_try_advisory_xact_lock_shared_int8", pgTryAdvisoryLock(lmgr.ShareLock, false))
+	fmgr.Register("pg_advisory_unlock_int8", pgAdvisoryUnlock(lmgr.ExclusiveLock))
+	fmgr.Register("pg_advisory_unlock_shared_int8", pgAdvisoryUnlock(lmgr.ShareLock))
+	fmgr.Register("pg_advisory_unlock_all", pgAdvisoryUnlockAll)
+}
+
+// advisoryTag は 64 ビットの鍵の勧告的ロックの対象を作る (SET_LOCKTAG_INT64 相当)
+func (s *session) advisoryTag(key int64) lmgr.LockTag {
+	return lmgr.LockTag{
+		Field1: uint32(catalog.GetDatabaseOid(s.databaseName)),
+		Field2: uint32(uint64(key) >> 32),
+		Field3: uint32(key),
+		Field4: 1,
+		Type:   lmgr.LockTagAdvisory,
+	}
+}
+
+// pgAdvisoryLock は取れるまで待って勧告的ロックを取る関数を返す (pg_advisory_lock_int8 など相当)
+func pgAdvisoryLock(mode lmgr.LockMode, sessionLock bool) fmgr.PGFunction {
+	return func(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+		s, err := callerSession(fcinfo)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.lockProc.LockAcquire(s.advisoryTag(fcinfo.Args[0].(int64)), mode, sessionLock, false); err != nil {
+			return nil, err
+		}
+		return adt.Void{}, nil
+	}
+}
+
+// pgTryAdvisoryLock は待たずに勧告的ロックを取る関数を返す (pg_try_advisory_lock_int8 など相当)。
+// 取れたかどうかを返す。
+func pgTryAdvisoryLock(mode lmgr.LockMode, sessionLock bool) fmgr.PGFunction {
+	return func(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+		s, err := callerSession(fcinfo)
+		if err != nil {
+			return nil, err
+		}
+		return s.lockProc.LockAcquire(s.advisoryTag(fcinfo.Args[0].(int64)), mode, sessionLock, true)
+	}
+}
+
+// pgAdvisoryUnlock はセッションの勧告的ロックを1つ放す関数を返す (pg_advisory_unlock_int8 など相当)。
+// 持っていなければ WARNING を報告して false を返す。
+func pgAdvisoryUnlock(mode lmgr.LockMode) fmgr.PGFunction {
+	return func(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+		s, err := callerSession(fcinfo)
+		if err != nil {
+			return nil, err
+		}
+		if !s.lockProc.LockRelease(s.advisoryTag(fcinfo.Args[0].(int64)), mode, true) {
+			s.Warning(fmt.Sprintf("you don't own a lock of type %s", mode))
+			return false, nil
+		}
+		return true, nil
+	}
+}
+
+// pgAdvisoryUnlockAll はセッションの勧告的ロックを全て放す (pg_advisory_unlock_all 相当)
+func pgAdvisoryUnlockAll(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	s.lockProc.ReleaseSessionLocks(lmgr.LockTagAdvisory)
+	return adt.Void{}, nil
+}
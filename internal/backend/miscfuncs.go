@@ -0,0 +1,228 @@
+package backend
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// セッションの情報を返す関数と pg_sleep (utils/adt/misc.c, utils/adt/name.c,
+// utils/adt/network.c, utils/adt/version.c 相当)
+// ----------------------------------------------------------------
+// 接続先のデータベースやユーザー、接続元のアドレス、サーバーのバージョンを返す。
+// CURRENT_USER のような括弧のない SQL 標準の構文は、構文解析でこれらの関数の呼び出しにする。
+//
+// pg_sleep は C言語版ではラッチを待つ。ラッチがないため、割り込みを確かめながら
+// 100ms ずつ休む (ロックの待機と同じ)。
+
+func init() {
+	fmgr.Register("pg_sleep", pgSleep)
+	fmgr.Register("pg_sleep_for", pgSleepFor)
+	fmgr.Register("pg_sleep_until", pgSleepUntil)
+	fmgr.Register("pgsql_version", pgsqlVersion)
+	fmgr.Register("current_database", currentDatabase)
+	fmgr.Register("current_user", currentUser)
+	fmgr.Register("session_user", sessionUser)
+	fmgr.Register("current_schema", currentSchema)
+	fmgr.Register("inet_client_addr", inetClientAddr)
+	fmgr.Register("inet_client_port", inetClientPort)
+	fmgr.Register("inet_server_addr", inetServerAddr)
+	fmgr.Register("inet_server_port", inetServerPort)
+}
+
+// sleepPollInterval は pg_sleep で割り込みを確かめる間隔
+const sleepPollInterval = 100 * time.Millisecond
+
+// pgSleep は指定した秒数だけ休む (pg_sleep 相当)。負の値と NaN はすぐに戻る。
+func pgSleep(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	return adt.Void{}, s.sleep(fcinfo.Args[0].(float64))
+}
+
+// pgSleepFor は interval の長さだけ休む (pg_sleep_for 相当)。月は30日として数える。
+func pgSleepFor(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	iv := fcinfo.Args[0].(*adt.Interval)
+	days := float64(iv.Month)*30 + float64(iv.Day)
+	return adt.Void{}, s.sleep(days*86400 + float64(iv.Time)/1e6)
+}
+
+// pgSleepUntil は指定した時刻まで休む (pg_sleep_until 相当)
+func pgSleepUntil(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	until := fcinfo.Args[0].(adt.TimestampTz)
+	return adt.Void{}, s.sleep(float64(until-adt.GetCurrentTimestamp()) / 1e6)
+}
+
+// sleep は secs 秒だけ休む。休んでいる間も問い合わせの取り消しとセッションの終了に応じる。
+func (s *session) sleep(secs float64) error {
+	if math.IsNaN(secs) || secs <= 0 {
+		return s.interrupts.CheckForInterrupts()
+	}
+	// time.Duration に収まらない長さは上限で切る
+	d := time.Duration(math.MaxInt64)
+	if secs < d.Seconds() {
+		d = time.Duration(secs * float64(time.Second))
+	}
+	end := sim.Now().Add(d)
+	s.waitEvent.Start(waitevent.TimeoutPgSleep)
+	defer s.waitEvent.End()
+	for {
+		if err := s.interrupts.CheckForInterrupts(); err != nil {
+			return err
+		}
+		delay := end.Sub(sim.Now())
+		if delay <= 0 {
+			return nil
+		}
+		sim.Sleep(min(delay, sleepPollInterval))
+	}
+}
+
+// pgsqlVersion はサーバーのバージョンを表す文字列を返す (version(), PG_VERSION_STR 相当)
+func pgsqlVersion(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	return fmt.Sprintf("PostgreSQL %s on %s-%s, compiled by %s, %d-bit",
+		pgconfig.PgVersion, runtime.GOARCH, runtime.GOOS, runtime.Version(), strconv.IntSize), nil
+}
+
+// currentDatabase は接続先のデータベースの名前を返す (current_database 相当)
+func currentDatabase(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	return adt.NameIn(s.databaseName), nil
+}
+
+// currentUser は現在のユーザーの名前を返す (current_user 相当)。SECURITY DEFINER の関数の中では
+// 関数の所有者になる。
+func currentUser(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	return adt.NameIn(ctx.UserName()), nil
+}
+
+// sessionUser はセッションを始めたユーザーの名前を返す (session_user 相当)
+func sessionUser(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	return adt.NameIn(s.sessionUserName), nil
+}
+
+// currentSchema は search_path のうち存在する最初のスキーマの名前を返す (current_schema 相当)。
+// 存在するスキーマがなければ NULL を返す。"$user" は現在のユーザーの名前のスキーマを表す。
+func currentSchema(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	list, _ := adt.SplitGUCList(s.gucs.GetString(guc.SearchPath), ',')
+	for _, nspname := range list {
+		if nspname == "$user" {
+			nspname = s.userName
+		}
+		if _, ok := catalog.NamespaceGetOid(nspname); ok {
+			return adt.NameIn(nspname), nil
+		}
+	}
+	return nil, nil
+}
+
+// C言語版の inet_client_addr と inet_server_addr は inet 型を返す。inet 型はまだないため、
+// アドレスは text で返す。Unix ドメインソケットの接続では、どれも NULL を返す。
+
+// inetClientAddr は接続元のアドレスを返す (inet_client_addr 相当)
+func inetClientAddr(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	if s.port == nil || s.port.IsUnixSocket() {
+		return nil, nil
+	}
+	return s.port.RemoteHost, nil
+}
+
+// inetClientPort は接続元のポート番号を返す (inet_client_port 相当)
+func inetClientPort(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	if s.port == nil || s.port.IsUnixSocket() {
+		return nil, nil
+	}
+	port, err := strconv.Atoi(s.port.RemotePort)
+	if err != nil {
+		return nil, nil
+	}
+	return int32(port), nil
+}
+
+// serverHostPort は接続を受け付けたサーバー側のアドレスとポート番号を返す。
+// Unix ドメインソケットの接続では ok に false を返す。
+func (s *session) serverHostPort() (host, port string, ok bool) {
+	if s.port == nil || s.port.IsUnixSocket() {
+		return "", "", false
+	}
+	host, port, err := net.SplitHostPort(s.port.Conn().LocalAddr().String())
+	if err != nil {
+		return "", "", false
+	}
+	return host, port, true
+}
+
+// inetServerAddr は接続を受け付けたサーバーのアドレスを返す (inet_server_addr 相当)
+func inetServerAddr(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	host, _, ok := s.serverHostPort()
+	if !ok {
+		return nil, nil
+	}
+	return host, nil
+}
+
+// inetServerPort は接続を受け付けたサーバーのポート番号を返す (inet_server_port 相当)
+func inetServerPort(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	_, port, ok := s.serverHostPort()
+	if !ok {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, nil
+	}
+	return int32(n), nil
+}
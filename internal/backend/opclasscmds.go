@@ -0,0 +1,704 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 演算子クラスと演算子族の作成、変更、削除 (commands/opclasscmds.c 相当)
+// ----------------------------------------------------------------
+// CREATE OPERATOR CLASS は演算子クラスを作り、その演算子とサポート関数を演算子族に加える。
+// FAMILY を書かなければ、演算子クラスと同じ名前の演算子族を使い、なければ作る。
+// ALTER OPERATOR FAMILY ... ADD と DROP は、演算子クラスに属さない演算子とサポート関数
+// (型をまたぐ比較など) を演算子族に加え、除く。
+//
+// 誤った演算子クラスはインデックスを壊すため、作成と ALTER OPERATOR FAMILY には
+// スーパーユーザーの権限が必要である。名前と所有者の変更と削除は所有者ができる。
+//
+// 演算子クラスの定義で加えた演算子とサポート関数は演算子クラスに属し、演算子クラスと共に
+// 削除される。演算子クラスは演算子族に属し、演算子族と共に削除される。
+
+// opFamilyMember は演算子族に加える、または除く演算子かサポート関数 (OpFamilyMember 相当)
+type opFamilyMember struct {
+	isFunc     bool
+	object     catalog.Oid
+	number     int
+	lefttype   catalog.Oid
+	righttype  catalog.Oid
+	sortfamily catalog.Oid
+}
+
+// opclassDescription は演算子クラスの説明を返す (getObjectDescription の OCLASS_OPCLASS 相当)
+func opclassDescription(opclass catalog.FormPgOpclass) string {
+	am, _ := catalog.SearchAm(opclass.Opcmethod)
+	return fmt.Sprintf("operator class %s for access method %s", opclass.Opcname, am.Amname)
+}
+
+// opfamilyDescription は演算子族の説明を返す (getOpFamilyDescription 相当)
+func opfamilyDescription(opfamily catalog.FormPgOpfamily) string {
+	am, _ := catalog.SearchAm(opfamily.Opfmethod)
+	return fmt.Sprintf("operator family %s for access method %s", opfamily.Opfname, am.Amname)
+}
+
+// amopDescription は演算子族の演算子の説明を返す (getObjectDescription の OCLASS_AMOP 相当)
+func amopDescription(amop catalog.FormPgAmop) string {
+	opfamily, _ := catalog.SearchOpfamilyByOid(amop.Amopfamily)
+	op, _ := catalog.SearchOperator(amop.Amopopr)
+	return fmt.Sprintf("operator %d (%s, %s) of %s: %s", amop.Amopstrategy, catalog.FormatType(amop.Amoplefttype),
+		catalog.FormatType(amop.Amoprighttype), opfamilyDescription(opfamily), catalog.FormatOperator(op))
+}
+
+// formatProcedure は関数を name(argtype,...) の形で返す (format_procedure 相当)
+func formatProcedure(proc *catalog.FormPgProc) string {
+	args := make([]string, len(proc.Proargtypes))
+	for i, t := range proc.Proargtypes {
+		args[i] = catalog.FormatType(t)
+	}
+	return proc.Proname + "(" + strings.Join(args, ",") + ")"
+}
+
+// getIndexAm は名前からインデックスのアクセスメソッドを返す (get_index_am_oid 相当)
+func getIndexAm(amname string) (*catalog.FormPgAm, error) {
+	am, ok := catalog.SearchAmByName(amname)
+	if !ok {
+		return nil, newError(errcodes.UndefinedObject, "access method \"%s\" does not exist", amname)
+	}
+	if am.Amtype != catalog.AmtypeIndex {
+		return nil, newError(errcodes.WrongObjectType, "access method \"%s\" is not of type %s", amname, "INDEX")
+	}
+	return am, nil
+}
+
+// getOpfamily はアクセスメソッドと名前から演算子族を返す (get_opfamily_oid 相当)
+func getOpfamily(am *catalog.FormPgAm, names []string) (catalog.FormPgOpfamily, error) {
+	name := names[len(names)-1]
+	opfamily, ok := catalog.SearchOpfamily(am.Oid, name)
+	if !ok {
+		return opfamily, newError(errcodes.UndefinedObject, "operator family \"%s\" does not exist for access method \"%s\"",
+			strings.Join(names, "."), am.Amname)
+	}
+	return opfamily, nil
+}
+
+// getOpclass はアクセスメソッドと名前から演算子クラスを返す (get_opclass_oid 相当)
+func getOpclass(am *catalog.FormPgAm, names []string) (catalog.FormPgOpclass, error) {
+	name := names[len(names)-1]
+	opclass, ok := catalog.SearchOpclass(am.Oid, name)
+	if !ok {
+		return opclass, newError(errcodes.UndefinedObject, "operator class \"%s\" does not exist for access method \"%s\"",
+			strings.Join(names, "."), am.Amname)
+	}
+	return opclass, nil
+}
+
+// maxOpNumber は演算子のストラテジ番号の上限を返す。ストラテジ番号の意味を決めていない
+// アクセスメソッドは、int16 に収まる番号を受け付ける。
+func maxOpNumber(am *catalog.FormPgAm) int {
+	if am.Amstrategies == 0 {
+		return 1<<15 - 1
+	}
+	return int(am.Amstrategies)
+}
+
+// processTypesSpec は OPERATOR と FUNCTION の後に括弧で書いた左右の型を返す (processTypesSpec 相当)。
+// 1つだけ書いた場合は左右とも同じ型とする。
+func processTypesSpec(args []*parser.TypeName) (lefttype, righttype catalog.Oid, err error) {
+	if len(args) > 2 {
+		return catalog.InvalidOid, catalog.InvalidOid, newError(errcodes.SyntaxError, "one or two argument types must be specified")
+	}
+	if lefttype, err = parser.TypenameTypeID(args[0]); err != nil {
+		return catalog.InvalidOid, catalog.InvalidOid, newError(errcodes.UndefinedObject, "%s", err.Error())
+	}
+	righttype = lefttype
+	if len(args) > 1 {
+		if righttype, err = parser.TypenameTypeID(args[1]); err != nil {
+			return catalog.InvalidOid, catalog.InvalidOid, newError(errcodes.UndefinedObject, "%s", err.Error())
+		}
+	}
+	return lefttype, righttype, nil
+}
+
+// assignOperTypes は演算子族の演算子を確かめ、左右の型を決める (assignOperTypes 相当)。
+// 型を指定しなければ、演算子の被演算子の型とする。
+func assignOperTypes(member *opFamilyMember, am *catalog.FormPgAm) error {
+	op, ok := catalog.SearchOperator(member.object)
+	if !ok {
+		return fmt.Errorf("cache lookup failed for operator %d", member.object)
+	}
+	if op.Oprkind != catalog.OprkindBinary {
+		return newError(errcodes.InvalidObjectDefinition, "index operators must be binary")
+	}
+	if member.sortfamily != catalog.InvalidOid {
+		if !am.Amcanorderbyop {
+			return newError(errcodes.InvalidObjectDefinition, "access method \"%s\" does not support ordering operators", am.Amname)
+		}
+	} else if op.Oprresult != catalog.BOOLOID {
+		return newError(errcodes.InvalidObjectDefinition, "index search operators must return boolean")
+	}
+	if member.lefttype == catalog.InvalidOid {
+		member.lefttype = op.Oprleft
+	}
+	if member.righttype == catalog.InvalidOid {
+		member.righttype = op.Oprright
+	}
+	return nil
+}
+
+// assignProcTypes はサポート関数を確かめ、左右の型を決める (assignProcTypes 相当)。btree と hash は
+// 各番号の関数の引数と結果の型を確かめる。型を指定しなければ、btree と hash では関数の引数の型、
+// それ以外では演算子クラスの型 (typeoid) とする。ALTER OPERATOR FAMILY では typeoid が InvalidOid で、
+// 型を決められなければエラーにする。
+func assignProcTypes(member *opFamilyMember, am *catalog.FormPgAm, typeoid catalog.Oid) error {
+	proc, ok := catalog.SearchProc(member.object)
+	if !ok {
+		return fmt.Errorf("cache lookup failed for function %d", member.object)
+	}
+	nargs := len(proc.Proargtypes)
+	invalid := func(msg string) error { return newError(errcodes.InvalidObjectDefinition, "%s", msg) }
+
+	switch {
+	case member.number == int(am.Amoptsprocnum):
+		// オプションを解析する関数は internal を受け取るが、internal 型がまだないため作れない
+		return withHint(invalid("invalid operator class options parsing function"),
+			"Valid signature of operator class options parsing function is %s.", "(internal) RETURNS void")
+	case am.Oid == catalog.BtreeAmOid:
+		switch member.number {
+		case 1: // BTORDER_PROC
+			if nargs != 2 {
+				return invalid("btree comparison functions must have two arguments")
+			}
+			if proc.Prorettype != catalog.INT4OID {
+				return invalid("btree comparison functions must return integer")
+			}
+			if member.lefttype == catalog.InvalidOid {
+				member.lefttype = proc.Proargtypes[0]
+			}
+			if member.righttype == catalog.InvalidOid {
+				member.righttype = proc.Proargtypes[1]
+			}
+		case 2: // BTSORTSUPPORT_PROC
+			// internal 型がまだないため、条件を満たす関数はない
+			return invalid("btree sort support functions must accept type \"internal\"")
+		case 3: // BTINRANGE_PROC
+			if nargs != 5 {
+				return invalid("btree in_range functions must have five arguments")
+			}
+			if proc.Prorettype != catalog.BOOLOID {
+				return invalid("btree in_range functions must return boolean")
+			}
+			if member.lefttype == catalog.InvalidOid {
+				member.lefttype = proc.Proargtypes[0]
+			}
+			if member.righttype == catalog.InvalidOid {
+				member.righttype = proc.Proargtypes[2]
+			}
+		case 4: // BTEQUALIMAGE_PROC
+			if nargs != 1 {
+				return invalid("btree equal image functions must have one argument")
+			}
+			if proc.Prorettype != catalog.BOOLOID {
+				return invalid("btree equal image functions must return boolean")
+			}
+			// CREATE INDEX のときに列の型で呼ぶため、型をまたぐ関数は意味がない
+			if member.lefttype != member.righttype {
+				return invalid("btree equal image functions must not be cross-type")
+			}
+		}
+	case am.Oid == catalog.HashAmOid:
+		switch member.number {
+		case 1: // HASHSTANDARD_PROC
+			if nargs != 1 {
+				return invalid("hash function 1 must have one argument")
+			}
+			if proc.Prorettype != catalog.INT4OID {
+				return invalid("hash function 1 must return integer")
+			}
+		case 2: // HASHEXTENDED_PROC
+			if nargs != 2 {
+				return invalid("hash function 2 must have two arguments")
+			}
+			if proc.Prorettype != catalog.INT8OID {
+				return invalid("hash function 2 must return bigint")
+			}
+		}
+		if member.lefttype == catalog.InvalidOid {
+			member.lefttype = proc.Proargtypes[0]
+		}
+		if member.righttype == catalog.InvalidOid {
+			member.righttype = proc.Proargtypes[0]
+		}
+	}
+
+	if member.lefttype == catalog.InvalidOid {
+		member.lefttype = typeoid
+	}
+	if member.righttype == catalog.InvalidOid {
+		member.righttype = typeoid
+	}
+	if member.lefttype == catalog.InvalidOid || member.righttype == catalog.InvalidOid {
+		return invalid("associated data types must be specified for index support function")
+	}
+	return nil
+}
+
+// addFamilyMember は演算子かサポート関数を一覧に加える (addFamilyMember 相当)。同じ番号と型の組は
+// 一度しか書けない。
+func addFamilyMember(list []*opFamilyMember, member *opFamilyMember) ([]*opFamilyMember, error) {
+	for _, old := range list {
+		if old.number == member.number && old.lefttype == member.lefttype && old.righttype == member.righttype {
+			kind := "operator"
+			if member.isFunc {
+				kind = "function"
+			}
+			return nil, newError(errcodes.InvalidObjectDefinition, "%s number %d for (%s,%s) appears more than once",
+				kind, member.number, catalog.FormatType(member.lefttype), catalog.FormatType(member.righttype))
+		}
+	}
+	return append(list, member), nil
+}
+
+// parseOpclassItems は CREATE OPERATOR CLASS と ALTER OPERATOR FAMILY ... ADD の要素を演算子と
+// サポート関数に分けて確かめる (DefineOpClass と AlterOpFamilyAdd の要素の処理相当)。typeoid は
+// 演算子クラスの型で、ALTER OPERATOR FAMILY では InvalidOid。storage は STORAGE で指定した型。
+func parseOpclassItems(items []*parser.CreateOpClassItem, am *catalog.FormPgAm, typeoid catalog.Oid) (operators, procedures []*opFamilyMember, storage catalog.Oid, err error) {
+	isAlter := typeoid == catalog.InvalidOid
+	for _, item := range items {
+		switch item.Itemtype {
+		case parser.OpclassItemOperator:
+			if item.Number <= 0 || item.Number > maxOpNumber(am) {
+				return nil, nil, 0, newError(errcodes.InvalidParameterValue, "invalid operator number %d, must be between 1 and %d",
+					item.Number, maxOpNumber(am))
+			}
+			var op catalog.FormPgOperator
+			if !item.Name.ArgsUnspecified {
+				if op, err = lookupOperWithArgs(item.Name); err != nil {
+					return nil, nil, 0, err
+				}
+			} else if isAlter {
+				return nil, nil, 0, newError(errcodes.SyntaxError, "operator argument types must be specified in ALTER OPERATOR FAMILY")
+			} else {
+				// 型を書かなければ、演算子クラスの型の二項演算子とする
+				name := item.Name.Objname[len(item.Name.Objname)-1]
+				var ok bool
+				if op, ok = catalog.OperatorLookup(name, typeoid, typeoid); !ok {
+					return nil, nil, 0, newError(errcodes.UndefinedFunction, "operator does not exist: %s", opSignatureString(name, typeoid, typeoid))
+				}
+			}
+			member := &opFamilyMember{object: op.Oid, number: item.Number}
+			if item.OrderFamily != nil {
+				btree, _ := catalog.SearchAm(catalog.BtreeAmOid)
+				sortfamily, err := getOpfamily(btree, item.OrderFamily)
+				if err != nil {
+					return nil, nil, 0, err
+				}
+				member.sortfamily = sortfamily.Oid
+			}
+			if err := assignOperTypes(member, am); err != nil {
+				return nil, nil, 0, err
+			}
+			if operators, err = addFamilyMember(operators, member); err != nil {
+				return nil, nil, 0, err
+			}
+		case parser.OpclassItemFunction:
+			if item.Number <= 0 || item.Number > int(am.Amsupport) {
+				return nil, nil, 0, newError(errcodes.InvalidParameterValue, "invalid function number %d, must be between 1 and %d",
+					item.Number, am.Amsupport)
+			}
+			proc, err := lookupFuncWithArgs(item.Name)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			member := &opFamilyMember{isFunc: true, object: proc.Oid, number: item.Number}
+			if item.ClassArgs != nil {
+				if member.lefttype, member.righttype, err = processTypesSpec(item.ClassArgs); err != nil {
+					return nil, nil, 0, err
+				}
+			}
+			if err := assignProcTypes(member, am, typeoid); err != nil {
+				return nil, nil, 0, err
+			}
+			if procedures, err = addFamilyMember(procedures, member); err != nil {
+				return nil, nil, 0, err
+			}
+		case parser.OpclassItemStorageType:
+			if isAlter {
+				return nil, nil, 0, newError(errcodes.SyntaxError, "STORAGE cannot be specified in ALTER OPERATOR FAMILY")
+			}
+			if storage != catalog.InvalidOid {
+				return nil, nil, 0, newError(errcodes.SyntaxError, "storage type specified more than once")
+			}
+			if storage, err = parser.TypenameTypeID(item.Storedtype); err != nil {
+				return nil, nil, 0, newError(errcodes.UndefinedObject, "%s", err.Error())
+			}
+		}
+	}
+	return operators, procedures, storage, nil
+}
+
+// checkFamilyMembers は演算子族に同じ番号と型の組の演算子とサポート関数がまだないことを確かめる
+// (storeOperators と storeProcedures の重複の確認相当)。何も加えないうちに全てを確かめる。
+func checkFamilyMembers(opfamily catalog.FormPgOpfamily, operators, procedures []*opFamilyMember) error {
+	for _, m := range operators {
+		if _, exists := catalog.SearchAmop(opfamily.Oid, m.lefttype, m.righttype, int16(m.number)); exists {
+			return newError(errcodes.DuplicateObject, "operator %d(%s,%s) already exists in operator family \"%s\"",
+				m.number, catalog.FormatType(m.lefttype), catalog.FormatType(m.righttype), opfamily.Opfname)
+		}
+	}
+	for _, m := range procedures {
+		if _, exists := catalog.SearchAmproc(opfamily.Oid, m.lefttype, m.righttype, int16(m.number)); exists {
+			return newError(errcodes.DuplicateObject, "function %d(%s,%s) already exists in operator family \"%s\"",
+				m.number, catalog.FormatType(m.lefttype), catalog.FormatType(m.righttype), opfamily.Opfname)
+		}
+	}
+	return nil
+}
+
+// storeFamilyMembers は演算子とサポート関数を演算子族に加える (storeOperators と storeProcedures 相当)。
+// opclassoid は演算子クラスの定義で加える場合の演算子クラスで、ALTER OPERATOR FAMILY では InvalidOid。
+func storeFamilyMembers(opfamily catalog.FormPgOpfamily, opclassoid catalog.Oid, operators, procedures []*opFamilyMember) {
+	for _, m := range operators {
+		purpose := catalog.AmopSearch
+		if m.sortfamily != catalog.InvalidOid {
+			purpose = catalog.AmopOrder
+		}
+		catalog.AddAmop(catalog.FormPgAmop{Amopfamily: opfamily.Oid, Amoplefttype: m.lefttype, Amoprighttype: m.righttype,
+			Amopstrategy: int16(m.number), Amoppurpose: purpose, Amopopr: m.object, Amopmethod: opfamily.Opfmethod,
+			Amopsortfamily: m.sortfamily, Opclass: opclassoid})
+	}
+	for _, m := range procedures {
+		catalog.AddAmproc(catalog.FormPgAmproc{Amprocfamily: opfamily.Oid, Amproclefttype: m.lefttype,
+			Amprocrighttype: m.righttype, Amprocnum: int16(m.number), Amproc: m.object, Opclass: opclassoid})
+	}
+}
+
+// defineOpClass は CREATE OPERATOR CLASS 文を実行する (DefineOpClass 相当)
+func (s *session) defineOpClass(stmt *parser.CreateOpClassStmt) error {
+	opcname := stmt.Opclassname[len(stmt.Opclassname)-1]
+	am, err := getIndexAm(stmt.Amname)
+	if err != nil {
+		return err
+	}
+	typeoid, err := parser.TypenameTypeID(stmt.Datatype)
+	if err != nil {
+		return newError(errcodes.UndefinedObject, "%s", err.Error())
+	}
+	if !catalog.IsSuperuser(s.userName) {
+		return newError(errcodes.InsufficientPrivilege, "must be superuser to create an operator class")
+	}
+
+	// FAMILY を書かなければ、同じ名前の演算子族を使うか作る
+	var opfamily catalog.FormPgOpfamily
+	familyExists := true
+	if stmt.Opfamilyname != nil {
+		if opfamily, err = getOpfamily(am, stmt.Opfamilyname); err != nil {
+			return err
+		}
+	} else if opfamily, familyExists = catalog.SearchOpfamily(am.Oid, opcname); !familyExists {
+		opfamily = catalog.FormPgOpfamily{Opfmethod: am.Oid, Opfname: opcname}
+	}
+
+	operators, procedures, storage, err := parseOpclassItems(stmt.Items, am, typeoid)
+	if err != nil {
+		return err
+	}
+	if storage != catalog.InvalidOid {
+		if storage == typeoid {
+			// 列の型と同じであれば指定しなかったものとする
+			storage = catalog.InvalidOid
+		} else if !am.Amstorage {
+			return newError(errcodes.InvalidObjectDefinition, "storage type cannot be different from data type for access method \"%s\"", stmt.Amname)
+		}
+	}
+
+	if _, exists := catalog.SearchOpclass(am.Oid, opcname); exists {
+		return newError(errcodes.DuplicateObject, "operator class \"%s\" for access method \"%s\" already exists", opcname, stmt.Amname)
+	}
+	if stmt.IsDefault {
+		if def, exists := catalog.GetDefaultOpclass(am.Oid, typeoid); exists {
+			return withDetail(newError(errcodes.DuplicateObject, "could not make operator class \"%s\" be default for type %s",
+				opcname, strings.Join(stmt.Datatype.Names, ".")),
+				"Operator class \"%s\" already is the default.", def.Opcname)
+		}
+	}
+	if familyExists {
+		if err := checkFamilyMembers(opfamily, operators, procedures); err != nil {
+			return err
+		}
+	}
+
+	role, _ := catalog.SearchRole(s.userName)
+	if !familyExists {
+		opfamily.Opfowner = role.Oid
+		opfamily.Oid, _ = catalog.CreateOpfamily(opfamily)
+	}
+	opclassoid, _ := catalog.CreateOpclass(catalog.FormPgOpclass{Opcmethod: am.Oid, Opcname: opcname, Opcowner: role.Oid,
+		Opcfamily: opfamily.Oid, Opcintype: typeoid, Opcdefault: stmt.IsDefault, Opckeytype: storage})
+	storeFamilyMembers(opfamily, opclassoid, operators, procedures)
+	return nil
+}
+
+// defineOpFamily は CREATE OPERATOR FAMILY 文を実行する (DefineOpFamily 相当)
+func (s *session) defineOpFamily(stmt *parser.CreateOpFamilyStmt) error {
+	opfname := stmt.Opfamilyname[len(stmt.Opfamilyname)-1]
+	am, err := getIndexAm(stmt.Amname)
+	if err != nil {
+		return err
+	}
+	if !catalog.IsSuperuser(s.userName) {
+		return newError(errcodes.InsufficientPrivilege, "must be superuser to create an operator family")
+	}
+	role, _ := catalog.SearchRole(s.userName)
+	if _, ok := catalog.CreateOpfamily(catalog.FormPgOpfamily{Opfmethod: am.Oid, Opfname: opfname, Opfowner: role.Oid}); !ok {
+		return newError(errcodes.DuplicateObject, "operator family \"%s\" for access method \"%s\" already exists", opfname, stmt.Amname)
+	}
+	return nil
+}
+
+// alterOpFamily は ALTER OPERATOR FAMILY ... ADD と DROP を実行する (AlterOpFamily 相当)
+func (s *session) alterOpFamily(stmt *parser.AlterOpFamilyStmt) error {
+	am, err := getIndexAm(stmt.Amname)
+	if err != nil {
+		return err
+	}
+	opfamily, err := getOpfamily(am, stmt.Opfamilyname)
+	if err != nil {
+		return err
+	}
+	if !catalog.IsSuperuser(s.userName) {
+		return newError(errcodes.InsufficientPrivilege, "must be superuser to alter an operator family")
+	}
+	if stmt.IsDrop {
+		return alterOpFamilyDrop(opfamily, am, stmt.Items)
+	}
+	operators, procedures, _, err := parseOpclassItems(stmt.Items, am, catalog.InvalidOid)
+	if err != nil {
+		return err
+	}
+	if err := checkFamilyMembers(opfamily, operators, procedures); err != nil {
+		return err
+	}
+	storeFamilyMembers(opfamily, catalog.InvalidOid, operators, procedures)
+	return nil
+}
+
+// alterOpFamilyDrop は ALTER OPERATOR FAMILY ... DROP を実行する (AlterOpFamilyDrop、dropOperators、
+// dropProcedures 相当)。演算子クラスの定義で加えたものは、演算子クラスを削除しなければ除けない。
+func alterOpFamilyDrop(opfamily catalog.FormPgOpfamily, am *catalog.FormPgAm, items []*parser.CreateOpClassItem) error {
+	var amops []catalog.FormPgAmop
+	var amprocs []catalog.FormPgAmproc
+	for _, item := range items {
+		kind, limit := "operator", maxOpNumber(am)
+		if item.Itemtype == parser.OpclassItemFunction {
+			kind, limit = "function", int(am.Amsupport)
+		}
+		if item.Number <= 0 || item.Number > limit {
+			return newError(errcodes.InvalidParameterValue, "invalid %s number %d, must be between 1 and %d", kind, item.Number, limit)
+		}
+		lefttype, righttype, err := processTypesSpec(item.ClassArgs)
+		if err != nil {
+			return err
+		}
+		var memberoid, opclassoid catalog.Oid
+		var desc string
+		if item.Itemtype == parser.OpclassItemOperator {
+			amop, ok := catalog.SearchAmop(opfamily.Oid, lefttype, righttype, int16(item.Number))
+			if !ok {
+				return newError(errcodes.UndefinedObject, "operator %d(%s,%s) does not exist in operator family \"%s\"",
+					item.Number, catalog.FormatType(lefttype), catalog.FormatType(righttype), opfamily.Opfname)
+			}
+			amops = append(amops, amop)
+			memberoid, opclassoid, desc = amop.Oid, amop.Opclass, amopDescription(amop)
+		} else {
+			amproc, ok := catalog.SearchAmproc(opfamily.Oid, lefttype, righttype, int16(item.Number))
+			if !ok {
+				return newError(errcodes.UndefinedObject, "function %d(%s,%s) does not exist in operator family \"%s\"",
+					item.Number, catalog.FormatType(lefttype), catalog.FormatType(righttype), opfamily.Opfname)
+			}
+			amprocs = append(amprocs, amproc)
+			proc, _ := catalog.SearchProc(amproc.Amproc)
+			memberoid, opclassoid = amproc.Oid, amproc.Opclass
+			desc = fmt.Sprintf("function %d (%s, %s) of %s: %s", amproc.Amprocnum, catalog.FormatType(lefttype),
+				catalog.FormatType(righttype), opfamilyDescription(opfamily), formatProcedure(proc))
+		}
+		if memberoid < catalog.FirstNormalObjectID {
+			// 組み込みの演算子族の演算子とサポート関数 (IsPinnedObject 相当)
+			return newError(errcodes.DependentObjectsStillExist, "cannot drop %s because it is required by the database system", desc)
+		}
+		if opclassoid != catalog.InvalidOid {
+			opclass, _ := catalog.SearchOpclassByOid(opclassoid)
+			return withHint(newError(errcodes.DependentObjectsStillExist, "cannot drop %s because %s requires it",
+				desc, opclassDescription(opclass)), "You can drop %s instead.", opclassDescription(opclass))
+		}
+	}
+	for _, amop := range amops {
+		catalog.DropAmop(amop.Oid)
+	}
+	for _, amproc := range amprocs {
+		catalog.DropAmproc(amproc.Oid)
+	}
+	return nil
+}
+
+// lookupOpclassObject は DropStmt などの形 (アクセスメソッドの名前に続けた名前) の演算子クラスを返す
+func lookupOpclassObject(object []string) (*catalog.FormPgAm, catalog.FormPgOpclass, error) {
+	am, err := getIndexAm(object[0])
+	if err != nil {
+		return nil, catalog.FormPgOpclass{}, err
+	}
+	opclass, err := getOpclass(am, object[1:])
+	return am, opclass, err
+}
+
+// lookupOpfamilyObject は DropStmt などの形 (アクセスメソッドの名前に続けた名前) の演算子族を返す
+func lookupOpfamilyObject(object []string) (*catalog.FormPgAm, catalog.FormPgOpfamily, error) {
+	am, err := getIndexAm(object[0])
+	if err != nil {
+		return nil, catalog.FormPgOpfamily{}, err
+	}
+	opfamily, err := getOpfamily(am, object[1:])
+	return am, opfamily, err
+}
+
+// renameOpClassOrFamily は ALTER OPERATOR CLASS と ALTER OPERATOR FAMILY の RENAME TO を実行する
+// (AlterObjectRename_internal 相当)
+func (s *session) renameOpClassOrFamily(stmt *parser.RenameStmt) error {
+	if stmt.RenameType == parser.ObjectOpclass {
+		am, opclass, err := lookupOpclassObject(stmt.Object)
+		if err != nil {
+			return err
+		}
+		if !s.ownercheck(opclass.Opcowner) {
+			return newError(errcodes.InsufficientPrivilege, "must be owner of operator class %s", opclass.Opcname)
+		}
+		if _, exists := catalog.SearchOpclass(am.Oid, stmt.Newname); exists {
+			return newError(errcodes.DuplicateObject, "operator class \"%s\" for access method \"%s\" already exists", stmt.Newname, am.Amname)
+		}
+		catalog.UpdateOpclass(opclass.Oid, func(c *catalog.FormPgOpclass) { c.Opcname = stmt.Newname })
+		return nil
+	}
+	am, opfamily, err := lookupOpfamilyObject(stmt.Object)
+	if err != nil {
+		return err
+	}
+	if !s.ownercheck(opfamily.Opfowner) {
+		return newError(errcodes.InsufficientPrivilege, "must be owner of operator family %s", opfamily.Opfname)
+	}
+	if _, exists := catalog.SearchOpfamily(am.Oid, stmt.Newname); exists {
+		return newError(errcodes.DuplicateObject, "operator family \"%s\" for access method \"%s\" already exists", stmt.Newname, am.Amname)
+	}
+	catalog.UpdateOpfamily(opfamily.Oid, func(f *catalog.FormPgOpfamily) { f.Opfname = stmt.Newname })
+	return nil
+}
+
+// alterOpClassOrFamilyOwner は ALTER OPERATOR CLASS と ALTER OPERATOR FAMILY の OWNER TO を実行する
+// (AlterObjectOwner_internal 相当)
+func (s *session) alterOpClassOrFamilyOwner(stmt *parser.AlterOwnerStmt) error {
+	object := stmt.Object.([]string)
+	newOwner, err := s.getRoleSpec(stmt.NewOwner)
+	if err != nil {
+		return err
+	}
+	if stmt.ObjectType == parser.ObjectOpclass {
+		_, opclass, err := lookupOpclassObject(object)
+		if err != nil {
+			return err
+		}
+		if opclass.Opcowner == newOwner.Oid {
+			return nil
+		}
+		if !catalog.IsSuperuser(s.userName) && !s.ownercheck(opclass.Opcowner) {
+			return newError(errcodes.InsufficientPrivilege, "must be owner of operator class %s", opclass.Opcname)
+		}
+		if err := s.checkNewOwner(newOwner); err != nil {
+			return err
+		}
+		catalog.UpdateOpclass(opclass.Oid, func(c *catalog.FormPgOpclass) { c.Opcowner = newOwner.Oid })
+		return nil
+	}
+	_, opfamily, err := lookupOpfamilyObject(object)
+	if err != nil {
+		return err
+	}
+	if opfamily.Opfowner == newOwner.Oid {
+		return nil
+	}
+	if !catalog.IsSuperuser(s.userName) && !s.ownercheck(opfamily.Opfowner) {
+		return newError(errcodes.InsufficientPrivilege, "must be owner of operator family %s", opfamily.Opfname)
+	}
+	if err := s.checkNewOwner(newOwner); err != nil {
+		return err
+	}
+	catalog.UpdateOpfamily(opfamily.Oid, func(f *catalog.FormPgOpfamily) { f.Opfowner = newOwner.Oid })
+	return nil
+}
+
+// removeOpClassesOrFamilies は DROP OPERATOR CLASS と DROP OPERATOR FAMILY を実行する (RemoveObjects と
+// performMultipleDeletions 相当)。演算子族を削除すると、それに属する演算子クラスも削除する。
+// これらに依存するインデックスはまだないため、CASCADE と RESTRICT は結果に影響しない。
+func (s *session) removeOpClassesOrFamilies(stmt *parser.DropStmt) error {
+	kind := "class"
+	if stmt.RemoveType == parser.ObjectOpfamily {
+		kind = "family"
+	}
+	var opclasses []catalog.FormPgOpclass
+	var opfamilies []catalog.FormPgOpfamily
+	for _, obj := range stmt.Objects {
+		object := obj.([]string)
+		am, err := getIndexAm(object[0])
+		if err != nil {
+			return err
+		}
+		name := object[len(object)-1]
+		if stmt.RemoveType == parser.ObjectOpclass {
+			opclass, ok := catalog.SearchOpclass(am.Oid, name)
+			if ok && !s.ownercheck(opclass.Opcowner) {
+				return newError(errcodes.InsufficientPrivilege, "must be owner of operator class %s", opclass.Opcname)
+			}
+			if ok && opclass.Oid < catalog.FirstNormalObjectID {
+				// 組み込みの演算子クラス (IsPinnedObject 相当)
+				return newError(errcodes.DependentObjectsStillExist, "cannot drop %s because it is required by the database system",
+					opclassDescription(opclass))
+			}
+			if ok {
+				opclasses = append(opclasses, opclass)
+				continue
+			}
+		} else {
+			opfamily, ok := catalog.SearchOpfamily(am.Oid, name)
+			if ok && !s.ownercheck(opfamily.Opfowner) {
+				return newError(errcodes.InsufficientPrivilege, "must be owner of operator family %s", opfamily.Opfname)
+			}
+			if ok && opfamily.Oid < catalog.FirstNormalObjectID {
+				return newError(errcodes.DependentObjectsStillExist, "cannot drop %s because it is required by the database system",
+					opfamilyDescription(opfamily))
+			}
+			if ok {
+				opfamilies = append(opfamilies, opfamily)
+				continue
+			}
+		}
+		qualified := strings.Join(object[1:], ".")
+		if !stmt.MissingOk {
+			return newError(errcodes.UndefinedObject, "operator %s \"%s\" does not exist for access method \"%s\"", kind, qualified, am.Amname)
+		}
+		if err := s.reportNotice(newNotice("operator %s \"%s\" does not exist for access method \"%s\", skipping", kind, qualified, am.Amname)); err != nil {
+			return err
+		}
+	}
+
+	for _, opclass := range opclasses {
+		catalog.DropOpclass(opclass.Oid)
+	}
+	for _, opfamily := range opfamilies {
+		for _, opclass := range catalog.OpclassesInFamily(opfamily.Oid) {
+			catalog.DropOpclass(opclass.Oid)
+		}
+		catalog.DropOpfamily(opfamily.Oid)
+	}
+	return nil
+}
@@ -0,0 +1,559 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 演算子の作成、変更、削除 (commands/operatorcmds.c、catalog/pg_operator.c 相当)
+// ----------------------------------------------------------------
+// CREATE OPERATOR で演算子を pg_operator に加える。演算子は関数の呼び出しとして実行し、結果の型は
+// 関数の結果の型になる。交換演算子と否定演算子にまだない演算子を指定した場合は殻の演算子を作り、
+// 後でその演算子を定義したときに相手を指すように埋める。
+//
+// 制約と結合の選択率の推定 (RESTRICT と JOIN) はプランナーがまだないため使わないが、
+// 組み込みの推定関数の名前だけを受け付けて記録する。
+//
+// 演算子を変更、削除するには、その所有者の権限が必要である。演算子族の演算子として使われている
+// 演算子は、CASCADE を指定しない限り削除できない。
+
+// restrictEstimators と joinEstimators は RESTRICT と JOIN に指定できる組み込みの推定関数
+var (
+	restrictEstimators = map[string]bool{
+		"eqsel": true, "neqsel": true, "scalarltsel": true, "scalarlesel": true, "scalargtsel": true,
+		"scalargesel": true, "matchingsel": true, "areasel": true, "positionsel": true, "contsel": true,
+	}
+	joinEstimators = map[string]bool{
+		"eqjoinsel": true, "neqjoinsel": true, "scalarltjoinsel": true, "scalarlejoinsel": true,
+		"scalargtjoinsel": true, "scalargejoinsel": true, "matchingjoinsel": true, "areajoinsel": true,
+		"positionjoinsel": true, "contjoinsel": true,
+	}
+)
+
+// validOperatorName は演算子の名前として使えるかを返す (validOperatorName 相当)
+func validOperatorName(name string) bool {
+	if name == "" || len(name) >= adt.NameDataLen {
+		return false
+	}
+	if strings.Trim(name, "+-*/<>=~!@#%^&|`?") != "" {
+		return false
+	}
+	// コメントの始まりを含む名前は字句解析で演算子にならない
+	if strings.Contains(name, "/*") || strings.Contains(name, "--") {
+		return false
+	}
+	// + か - で終わる2文字以上の名前は、他の文字を含まなければ字句解析で分かれてしまう
+	if len(name) > 1 && (name[len(name)-1] == '+' || name[len(name)-1] == '-') {
+		return strings.ContainsAny(name, "~!@#%^&|`?")
+	}
+	return true
+}
+
+// ownercheck は現在のユーザーが ownerID の所有するオブジェクトの所有者の権限を持つかを返す
+// (object_ownercheck 相当)
+func (s *session) ownercheck(ownerID catalog.Oid) bool {
+	owner, _ := catalog.SearchRoleByOid(ownerID)
+	return catalog.HasPrivsOfRole(s.userName, owner.Rolname)
+}
+
+// checkNewOwner は現在のユーザーがオブジェクトの所有者を newOwner に変えられるかを確かめる
+// (AlterObjectOwner_internal の check_can_set_role 相当)。スーパーユーザーでなければ、新しい所有者の
+// メンバーでなければならない。
+func (s *session) checkNewOwner(newOwner catalog.FormPgAuthid) error {
+	if !catalog.IsSuperuser(s.userName) && !catalog.IsMemberOfRoleNosuper(s.userName, newOwner.Rolname) {
+		return newError(errcodes.InsufficientPrivilege, "must be able to SET ROLE \"%s\"", newOwner.Rolname)
+	}
+	return nil
+}
+
+// operArgTypes は演算子の被演算子の型を返す。NONE と書いた左の型は InvalidOid になる。
+func operArgTypes(owa *parser.ObjectWithArgs) (left, right catalog.Oid, err error) {
+	if owa.Objargs[1] == nil {
+		return catalog.InvalidOid, catalog.InvalidOid, newError(errcodes.SyntaxError, "postfix operators are not supported")
+	}
+	if owa.Objargs[0] != nil {
+		if left, err = parser.TypenameTypeID(owa.Objargs[0]); err != nil {
+			return catalog.InvalidOid, catalog.InvalidOid, newError(errcodes.UndefinedObject, "%s", err.Error())
+		}
+	}
+	if right, err = parser.TypenameTypeID(owa.Objargs[1]); err != nil {
+		return catalog.InvalidOid, catalog.InvalidOid, newError(errcodes.UndefinedObject, "%s", err.Error())
+	}
+	return left, right, nil
+}
+
+// opSignatureString はエラーメッセージ用に演算子と被演算子の型を並べる (op_signature_string 相当)
+func opSignatureString(name string, left, right catalog.Oid) string {
+	s := name + " " + catalog.FormatType(right)
+	if left != catalog.InvalidOid {
+		s = catalog.FormatType(left) + " " + s
+	}
+	return s
+}
+
+// lookupOperWithArgs は名前と被演算子の型から演算子を探す (LookupOperWithArgs 相当)。殻の演算子も返す。
+func lookupOperWithArgs(owa *parser.ObjectWithArgs) (catalog.FormPgOperator, error) {
+	left, right, err := operArgTypes(owa)
+	if err != nil {
+		return catalog.FormPgOperator{}, err
+	}
+	name := owa.Objname[len(owa.Objname)-1]
+	op, ok := catalog.OperatorLookup(name, left, right)
+	if !ok {
+		return catalog.FormPgOperator{}, newError(errcodes.UndefinedFunction, "operator does not exist: %s", opSignatureString(name, left, right))
+	}
+	return op, nil
+}
+
+// lookupEstimator は選択率の推定関数の名前を確かめる (ValidateRestrictionEstimator、
+// ValidateJoinEstimator 相当)。argtypes はエラーメッセージに出す推定関数の引数の型。
+func lookupEstimator(names []string, known map[string]bool, argtypes string) (string, error) {
+	name := names[len(names)-1]
+	if !known[name] {
+		return "", newError(errcodes.UndefinedFunction, "function %s(%s) does not exist", strings.Join(names, "."), argtypes)
+	}
+	return name, nil
+}
+
+// getOtherOperator は交換演算子か否定演算子を返す (get_other_operator 相当)。なければ殻の演算子を作る。
+// 定義している演算子自身を指す交換演算子では InvalidOid を返し、呼び出し側が後で埋める。
+func (s *session) getOtherOperator(names []string, otherLeft, otherRight catalog.Oid, opname string, left, right catalog.Oid, isCommutator bool) (catalog.Oid, error) {
+	name := names[len(names)-1]
+	if other, ok := catalog.OperatorLookup(name, otherLeft, otherRight); ok {
+		if !s.ownercheck(other.Oprowner) {
+			return catalog.InvalidOid, newError(errcodes.InsufficientPrivilege, "must be owner of operator %s", other.Oprname)
+		}
+		return other.Oid, nil
+	}
+	if name == opname && otherLeft == left && otherRight == right {
+		if !isCommutator {
+			return catalog.InvalidOid, newError(errcodes.InvalidFunctionDefinition, "operator cannot be its own negator")
+		}
+		return catalog.InvalidOid, nil
+	}
+	return s.operatorShellMake(name, otherLeft, otherRight)
+}
+
+// operatorShellMake は関数を持たない殻の演算子を作る (OperatorShellMake 相当)
+func (s *session) operatorShellMake(name string, left, right catalog.Oid) (catalog.Oid, error) {
+	if !validOperatorName(name) {
+		return catalog.InvalidOid, newError(errcodes.InvalidName, "\"%s\" is not a valid operator name", name)
+	}
+	role, _ := catalog.SearchRole(s.userName)
+	kind := catalog.OprkindBinary
+	if left == catalog.InvalidOid {
+		kind = catalog.OprkindPrefix
+	}
+	return catalog.CreateOperator(catalog.FormPgOperator{Oprname: name, Oprowner: role.Oid, Oprkind: kind,
+		Oprleft: left, Oprright: right}), nil
+}
+
+// defineOperator は CREATE OPERATOR 文を実行する (DefineOperator と OperatorCreate 相当)
+func (s *session) defineOperator(stmt *parser.DefineStmt) error {
+	opname := stmt.Defnames[len(stmt.Defnames)-1]
+	var (
+		typeName1, typeName2                      *parser.TypeName
+		functionName, commutatorName, negatorName []string
+		restrictionName, joinName                 []string
+		canMerge, canHash                         bool
+		err                                       error
+	)
+	for _, def := range stmt.Definition {
+		switch def.Defname {
+		case "leftarg":
+			typeName1, err = defGetTypeName(def)
+		case "rightarg":
+			typeName2, err = defGetTypeName(def)
+		case "function", "procedure":
+			functionName, err = defGetQualifiedName(def)
+		case "commutator":
+			commutatorName, err = defGetQualifiedName(def)
+		case "negator":
+			negatorName, err = defGetQualifiedName(def)
+		case "restrict":
+			restrictionName, err = defGetQualifiedName(def)
+		case "join":
+			joinName, err = defGetQualifiedName(def)
+		case "hashes":
+			canHash, err = defGetBoolean(def)
+		case "merges":
+			canMerge, err = defGetBoolean(def)
+		case "sort1", "sort2", "ltcmp", "gtcmp":
+			// 古い版の書き方で、マージ結合に使えることを表す
+			canMerge = true
+		default:
+			err = s.reportWarning(errcodes.SyntaxError, fmt.Sprintf("operator attribute \"%s\" not recognized", def.Defname))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if functionName == nil {
+		return newError(errcodes.InvalidFunctionDefinition, "operator function must be specified")
+	}
+	var left, right catalog.Oid
+	if typeName1 != nil {
+		if left, err = parser.TypenameTypeID(typeName1); err != nil {
+			return newError(errcodes.UndefinedObject, "%s", err.Error())
+		}
+	}
+	if typeName2 != nil {
+		if right, err = parser.TypenameTypeID(typeName2); err != nil {
+			return newError(errcodes.UndefinedObject, "%s", err.Error())
+		}
+	}
+	if left == catalog.InvalidOid && right == catalog.InvalidOid {
+		return newError(errcodes.InvalidFunctionDefinition, "operator argument types must be specified")
+	}
+	if right == catalog.InvalidOid {
+		return withDetail(newError(errcodes.InvalidFunctionDefinition, "operator right argument type must be specified"),
+			"Postfix operators are not supported.")
+	}
+
+	// 被演算子の型を引数に取る関数を探す
+	owa := &parser.ObjectWithArgs{Objname: functionName, Objargs: []*parser.TypeName{typeName2}}
+	if typeName1 != nil {
+		owa.Objargs = []*parser.TypeName{typeName1, typeName2}
+	}
+	proc, err := lookupFuncWithArgs(owa)
+	if err != nil {
+		return err
+	}
+
+	var restrict, join string
+	if restrictionName != nil {
+		if restrict, err = lookupEstimator(restrictionName, restrictEstimators, "internal, oid, internal, integer"); err != nil {
+			return err
+		}
+	}
+	if joinName != nil {
+		if join, err = lookupEstimator(joinName, joinEstimators, "internal, oid, internal, smallint, internal"); err != nil {
+			return err
+		}
+	}
+
+	if !validOperatorName(opname) {
+		return newError(errcodes.InvalidName, "\"%s\" is not a valid operator name", opname)
+	}
+	if left == catalog.InvalidOid {
+		switch {
+		case commutatorName != nil:
+			return newError(errcodes.InvalidFunctionDefinition, "only binary operators can have commutators")
+		case join != "":
+			return newError(errcodes.InvalidFunctionDefinition, "only binary operators can have join selectivity")
+		case canMerge:
+			return newError(errcodes.InvalidFunctionDefinition, "only binary operators can merge join")
+		case canHash:
+			return newError(errcodes.InvalidFunctionDefinition, "only binary operators can hash")
+		}
+	}
+	if proc.Prorettype != catalog.BOOLOID {
+		switch {
+		case negatorName != nil:
+			return newError(errcodes.InvalidFunctionDefinition, "only boolean operators can have negators")
+		case restrict != "":
+			return newError(errcodes.InvalidFunctionDefinition, "only boolean operators can have restriction selectivity")
+		case join != "":
+			return newError(errcodes.InvalidFunctionDefinition, "only boolean operators can have join selectivity")
+		case canMerge:
+			return newError(errcodes.InvalidFunctionDefinition, "only boolean operators can merge join")
+		case canHash:
+			return newError(errcodes.InvalidFunctionDefinition, "only boolean operators can hash")
+		}
+	}
+
+	// 殻の演算子があれば、その所有者だけが埋められる
+	shell, exists := catalog.OperatorLookup(opname, left, right)
+	if exists && shell.Oprcode != catalog.InvalidOid {
+		return newError(errcodes.DuplicateFunction, "operator %s already exists", opname)
+	}
+	if exists && !s.ownercheck(shell.Oprowner) {
+		return newError(errcodes.InsufficientPrivilege, "must be owner of operator %s", opname)
+	}
+
+	var commutator, negator catalog.Oid
+	selfCommutator := false
+	if commutatorName != nil {
+		// 交換演算子は左右の型を入れ替えた演算子
+		if commutator, err = s.getOtherOperator(commutatorName, right, left, opname, left, right, true); err != nil {
+			return err
+		}
+		selfCommutator = commutator == catalog.InvalidOid
+	}
+	if negatorName != nil {
+		if negator, err = s.getOtherOperator(negatorName, left, right, opname, left, right, false); err != nil {
+			return err
+		}
+	}
+
+	role, _ := catalog.SearchRole(s.userName)
+	kind := catalog.OprkindBinary
+	if left == catalog.InvalidOid {
+		kind = catalog.OprkindPrefix
+	}
+	fill := func(op *catalog.FormPgOperator) {
+		op.Oprowner = role.Oid
+		op.Oprkind = kind
+		op.Oprcanmerge = canMerge
+		op.Oprcanhash = canHash
+		op.Oprresult = proc.Prorettype
+		op.Oprcom = commutator
+		op.Oprnegate = negator
+		op.Oprcode = proc.Oid
+		op.Oprrest = restrict
+		op.Oprjoin = join
+	}
+	oproid := shell.Oid
+	if exists {
+		catalog.UpdateOperator(oproid, fill)
+	} else {
+		op := catalog.FormPgOperator{Oprname: opname, Oprleft: left, Oprright: right}
+		fill(&op)
+		oproid = catalog.CreateOperator(op)
+	}
+	if selfCommutator {
+		catalog.UpdateOperator(oproid, func(op *catalog.FormPgOperator) { op.Oprcom = oproid })
+	}
+	operatorUpd(oproid, commutator, negator)
+	return nil
+}
+
+// operatorUpd は交換演算子と否定演算子が、まだ相手を持たなければ定義した演算子を指すようにする
+// (OperatorUpd 相当)
+func operatorUpd(baseID, commID, negID catalog.Oid) {
+	if commID != catalog.InvalidOid && commID != baseID {
+		catalog.UpdateOperator(commID, func(op *catalog.FormPgOperator) {
+			if op.Oprcom == catalog.InvalidOid {
+				op.Oprcom = baseID
+			}
+		})
+	}
+	if negID != catalog.InvalidOid {
+		catalog.UpdateOperator(negID, func(op *catalog.FormPgOperator) {
+			if op.Oprnegate == catalog.InvalidOid {
+				op.Oprnegate = baseID
+			}
+		})
+	}
+}
+
+// alterOperator は ALTER OPERATOR ... SET 文を実行する (AlterOperator 相当)。変えられるのは
+// 選択率の推定関数だけで、NONE を指定すると推定関数を外す。
+func (s *session) alterOperator(stmt *parser.AlterOperatorStmt) error {
+	op, err := lookupOperWithArgs(stmt.Opername)
+	if err != nil {
+		return err
+	}
+	var restrictionName, joinName []string
+	updateRestriction, updateJoin := false, false
+	for _, def := range stmt.Options {
+		var param []string
+		if def.Arg != nil {
+			if param, err = defGetQualifiedName(def); err != nil {
+				return err
+			}
+		}
+		switch def.Defname {
+		case "restrict":
+			restrictionName, updateRestriction = param, true
+		case "join":
+			joinName, updateJoin = param, true
+		case "leftarg", "rightarg", "function", "procedure", "commutator", "negator", "hashes", "merges":
+			// CREATE OPERATOR で指定できるが変えられないもの
+			return newError(errcodes.SyntaxError, "operator attribute \"%s\" cannot be changed", def.Defname)
+		default:
+			return newError(errcodes.SyntaxError, "operator attribute \"%s\" not recognized", def.Defname)
+		}
+	}
+	if !s.ownercheck(op.Oprowner) {
+		return newError(errcodes.InsufficientPrivilege, "must be owner of operator %s", op.Oprname)
+	}
+
+	var restrict, join string
+	if restrictionName != nil {
+		if restrict, err = lookupEstimator(restrictionName, restrictEstimators, "internal, oid, internal, integer"); err != nil {
+			return err
+		}
+	}
+	if joinName != nil {
+		if join, err = lookupEstimator(joinName, joinEstimators, "internal, oid, internal, smallint, internal"); err != nil {
+			return err
+		}
+	}
+	if op.Oprleft == catalog.InvalidOid && join != "" {
+		return newError(errcodes.InvalidFunctionDefinition, "only binary operators can have join selectivity")
+	}
+	if op.Oprresult != catalog.BOOLOID {
+		if restrict != "" {
+			return newError(errcodes.InvalidFunctionDefinition, "only boolean operators can have restriction selectivity")
+		}
+		if join != "" {
+			return newError(errcodes.InvalidFunctionDefinition, "only boolean operators can have join selectivity")
+		}
+	}
+	catalog.UpdateOperator(op.Oid, func(o *catalog.FormPgOperator) {
+		if updateRestriction {
+			o.Oprrest = restrict
+		}
+		if updateJoin {
+			o.Oprjoin = join
+		}
+	})
+	return nil
+}
+
+// alterOperatorOwner は ALTER OPERATOR の OWNER TO を実行する (AlterObjectOwner_internal 相当)
+func (s *session) alterOperatorOwner(stmt *parser.AlterOwnerStmt) error {
+	op, err := lookupOperWithArgs(stmt.Object.(*parser.ObjectWithArgs))
+	if err != nil {
+		return err
+	}
+	newOwner, err := s.getRoleSpec(stmt.NewOwner)
+	if err != nil {
+		return err
+	}
+	if op.Oprowner == newOwner.Oid {
+		return nil
+	}
+	if !catalog.IsSuperuser(s.userName) && !s.ownercheck(op.Oprowner) {
+		return newError(errcodes.InsufficientPrivilege, "must be owner of operator %s", op.Oprname)
+	}
+	if err := s.checkNewOwner(newOwner); err != nil {
+		return err
+	}
+	catalog.UpdateOperator(op.Oid, func(o *catalog.FormPgOperator) { o.Oprowner = newOwner.Oid })
+	return nil
+}
+
+// removeOperators は DROP OPERATOR 文を実行する (RemoveObjects の OBJECT_OPERATOR と
+// performMultipleDeletions 相当)。全ての対象を確かめてから削除する。
+func (s *session) removeOperators(stmt *parser.DropStmt) error {
+	d := newOperatorDeletion(make(map[catalog.Oid]bool))
+	var first string
+	for _, obj := range stmt.Objects {
+		owa := obj.(*parser.ObjectWithArgs)
+		name := owa.Objname[len(owa.Objname)-1]
+		if stmt.MissingOk {
+			skipped, err := s.operatorDoesNotExistSkipping(owa, name)
+			if err != nil {
+				return err
+			}
+			if skipped {
+				continue
+			}
+		}
+		op, err := lookupOperWithArgs(owa)
+		if err != nil {
+			return err
+		}
+		if !s.ownercheck(op.Oprowner) {
+			return newError(errcodes.InsufficientPrivilege, "must be owner of operator %s", op.Oprname)
+		}
+		if op.Oid < catalog.FirstNormalObjectID {
+			// 組み込みの演算子 (IsPinnedObject 相当)
+			return newError(errcodes.DependentObjectsStillExist, "cannot drop operator %s because it is required by the database system",
+				catalog.FormatOperator(op))
+		}
+		if first == "" {
+			first = "operator " + catalog.FormatOperator(op)
+		}
+		d.add(op, "")
+	}
+	if err := s.reportDependentObjects(d.dependents, stmt.Behavior, first); err != nil {
+		return err
+	}
+	d.drop()
+	return nil
+}
+
+// operatorDoesNotExistSkipping は DROP OPERATOR IF EXISTS の対象がなければ NOTICE を送り、true を返す
+// (does_not_exist_skipping の OBJECT_OPERATOR 相当)
+func (s *session) operatorDoesNotExistSkipping(owa *parser.ObjectWithArgs, name string) (bool, error) {
+	var msg string
+	for _, tn := range owa.Objargs {
+		if tn != nil {
+			if _, err := parser.TypenameTypeID(tn); err != nil {
+				msg = fmt.Sprintf("type \"%s\" does not exist, skipping", strings.Join(tn.Names, "."))
+				break
+			}
+		}
+	}
+	if msg == "" {
+		left, right, err := operArgTypes(owa)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := catalog.OperatorLookup(name, left, right); ok {
+			return false, nil
+		}
+		msg = fmt.Sprintf("operator %s does not exist, skipping", strings.Join(owa.Objname, "."))
+	}
+	return true, s.reportNotice(newNotice("%s", msg))
+}
+
+// operatorDeletion は削除する演算子と、それに依存するオブジェクトを集める (findDependentObjects の
+// うち演算子に依存するもの相当)。演算子を使う演算子族の演算子を一緒に削除し、それが演算子クラスの
+// 定義で加えたものであれば演算子クラスごと削除する。
+type operatorDeletion struct {
+	// dropping は既に集めたオブジェクトの OID
+	dropping   map[catalog.Oid]bool
+	operators  []catalog.FormPgOperator
+	amops      []catalog.FormPgAmop
+	opclasses  []catalog.FormPgOpclass
+	dependents []dependentObject
+}
+
+func newOperatorDeletion(dropping map[catalog.Oid]bool) *operatorDeletion {
+	return &operatorDeletion{dropping: dropping}
+}
+
+// add は削除する演算子を加える。dependsOn は演算子が依存するオブジェクトの説明で、
+// 削除を指定した演算子では空にする。
+func (d *operatorDeletion) add(op catalog.FormPgOperator, dependsOn string) {
+	if d.dropping[op.Oid] {
+		return
+	}
+	d.dropping[op.Oid] = true
+	d.operators = append(d.operators, op)
+	desc := "operator " + catalog.FormatOperator(op)
+	if dependsOn != "" {
+		d.dependents = append(d.dependents, dependentObject{desc, dependsOn})
+	}
+	for _, amop := range catalog.AmopsUsingOperator(op.Oid) {
+		if amop.Opclass != catalog.InvalidOid {
+			if d.dropping[amop.Opclass] {
+				continue
+			}
+			d.dropping[amop.Opclass] = true
+			opclass, _ := catalog.SearchOpclassByOid(amop.Opclass)
+			d.opclasses = append(d.opclasses, opclass)
+			d.dependents = append(d.dependents, dependentObject{opclassDescription(opclass), desc})
+			continue
+		}
+		if !d.dropping[amop.Oid] {
+			d.dropping[amop.Oid] = true
+			d.amops = append(d.amops, amop)
+			d.dependents = append(d.dependents, dependentObject{amopDescription(amop), desc})
+		}
+	}
+}
+
+// drop は集めたオブジェクトを、依存するものから削除する
+func (d *operatorDeletion) drop() {
+	for _, opclass := range d.opclasses {
+		catalog.DropOpclass(opclass.Oid)
+	}
+	for _, amop := range d.amops {
+		catalog.DropAmop(amop.Oid)
+	}
+	for _, op := range d.operators {
+		catalog.DropOperator(op.Oid)
+	}
+}
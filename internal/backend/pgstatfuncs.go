@@ -0,0 +1,493 @@
+package backend
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/procarray"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/pgstat"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// セッションの状態を返す関数 (utils/adt/pgstatfuncs.c, utils/activity/backend_status.c 相当)
+// ----------------------------------------------------------------
+// 認証を終えたバックエンドは、接続の SSL の状態をバックエンドの一覧に記録する。
+// pg_stat_ssl はそれを1つのバックエンドにつき1行として返す。
+//
+// 他のロールのセッションの SSL の状態は、そのロールの権限を持つか pg_read_all_stats の
+// 権限を持つロールだけが参照できる。権限がなければ pid 以外の列を NULL にする。
+// pg_stat_activity も同様に、権限がなければ問い合わせを <insufficient privilege> とし、
+// セッションを識別する列以外を NULL にする。
+//
+// pg_stat_user_tables と pg_stat_user_indexes は、共有の統計のうち接続先のデータベースの
+// システムカタログ以外のリレーションを1つにつき1行として返す。pg_stat_user_functions は
+// 接続先のデータベースで track_functions が数えた関数を1つにつき1行として返す。
+// pg_stat_database は datid が 0 の行と、データベースを1つにつき1行として返す。pg_stat_reset は
+// 接続先のデータベースの統計を 0 に戻す。
+// pg_stat_archiver、pg_stat_bgwriter、pg_stat_checkpointer はアーカイバ、バックグラウンドライター、
+// checkpointer の統計をそれぞれ1行として返す。pg_stat_reset_shared はこれらを 0 に戻す。
+
+func init() {
+	fmgr.RegisterSetReturning("pg_stat_get_ssl", pgStatGetSSL)
+	fmgr.RegisterSetReturning("pg_stat_get_activity", pgStatGetActivity)
+	fmgr.RegisterSetReturning("pg_stat_get_user_tables", pgStatGetUserTables)
+	fmgr.RegisterSetReturning("pg_stat_get_user_indexes", pgStatGetUserIndexes)
+	fmgr.RegisterSetReturning("pg_stat_get_user_functions", pgStatGetUserFunctions)
+	fmgr.RegisterSetReturning("pg_stat_get_database", pgStatGetDatabase)
+	fmgr.RegisterSetReturning("pg_stat_get_archiver", pgStatGetArchiver)
+	fmgr.RegisterSetReturning("pg_stat_get_bgwriter", pgStatGetBgWriter)
+	fmgr.RegisterSetReturning("pg_stat_get_checkpointer", pgStatGetCheckpointer)
+	fmgr.Register("pg_stat_get_buf_written_backend", pgStatGetBufWrittenBackend)
+	fmgr.Register("pg_stat_reset", pgStatReset)
+	fmgr.Register("pg_stat_reset_shared", pgStatResetShared)
+}
+
+// backendSSLStatus は接続の SSL の状態 (PgBackendSSLStatus 相当)
+type backendSSLStatus struct {
+	version      string
+	cipher       string
+	bits         int32
+	clientDN     string
+	clientSerial string
+	issuerDN     string
+}
+
+// setBackendSSLStatus は接続の SSL の状態を一覧に記録する (pgstat_bestart の SSL の部分相当)
+func setBackendSSLStatus(pid int32, port *libpq.Port) {
+	if !port.SSLInUse {
+		return
+	}
+	status := &backendSSLStatus{
+		version:      port.SSLVersion(),
+		cipher:       port.SSLCipher(),
+		bits:         int32(port.SSLBits()),
+		clientDN:     port.PeerDN,
+		clientSerial: port.SSLPeerSerial(),
+		issuerDN:     port.SSLIssuerDN(),
+	}
+	backendList.Lock()
+	defer backendList.Unlock()
+	if entry, ok := backendList.entries[pid]; ok {
+		entry.ssl = status
+	}
+}
+
+// hasPgstatPermissions は user が role のセッションの状態を参照できるかを返す (HAS_PGSTAT_PERMISSIONS 相当)
+func hasPgstatPermissions(user, role string) bool {
+	return catalog.HasPrivsOfRole(user, catalog.RolePgReadAllStats) || catalog.HasPrivsOfRole(user, role)
+}
+
+// pgStatGetSSL は pg_stat_ssl の行を PID の順に返す (pg_stat_get_activity の SSL の列相当)
+func pgStatGetSSL(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	user := ctx.UserName()
+
+	type backend struct {
+		pid  int32
+		role string
+		ssl  *backendSSLStatus
+	}
+	var backends []backend
+	backendList.Lock()
+	for pid, entry := range backendList.entries {
+		// 認証の前のバックエンドはまだ状態を記録していない
+		if entry.role != "" {
+			backends = append(backends, backend{pid, entry.role, entry.ssl})
+		}
+	}
+	backendList.Unlock()
+	sort.Slice(backends, func(i, j int) bool { return backends[i].pid < backends[j].pid })
+
+	rows := make([][]adt.Datum, 0, len(backends))
+	for _, b := range backends {
+		row := make([]adt.Datum, 8)
+		row[0] = b.pid
+		if hasPgstatPermissions(user, b.role) {
+			if b.ssl == nil {
+				row[1] = false
+			} else {
+				row[1] = true
+				row[2] = b.ssl.version
+				row[3] = b.ssl.cipher
+				row[4] = b.ssl.bits
+				row[5] = nullIfEmpty(b.ssl.clientDN)
+				row[6] = nullIfEmpty(b.ssl.clientSerial)
+				row[7] = nullIfEmpty(b.ssl.issuerDN)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// nullIfEmpty は空文字列を NULL にする。クライアント証明書がない場合の列に使う。
+func nullIfEmpty(s string) adt.Datum {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// pgStatGetActivity は pg_stat_activity の行を PID の順に返す (pg_stat_get_activity 相当)
+func pgStatGetActivity(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	user := ctx.UserName()
+
+	type backend struct {
+		pid       int32
+		role      string
+		status    backendStatus
+		waitEvent waitevent.Info
+	}
+	var backends []backend
+	backendList.Lock()
+	for pid, entry := range backendList.entries {
+		if entry.role != "" || entry.kind == procBgworker {
+			backends = append(backends, backend{pid, entry.role, entry.status, entry.waitEvent.Load()})
+		}
+	}
+	backendList.Unlock()
+	sort.Slice(backends, func(i, j int) bool { return backends[i].pid < backends[j].pid })
+
+	rows := make([][]adt.Datum, 0, len(backends))
+	for _, b := range backends {
+		st := &b.status
+		row := make([]adt.Datum, 22)
+		if datid := catalog.GetDatabaseOid(st.databaseName); datid != catalog.InvalidOid {
+			row[0] = datid
+		}
+		row[1] = nullIfEmpty(st.databaseName)
+		row[2] = b.pid
+		if role, ok := catalog.SearchRole(b.role); ok {
+			row[4] = role.Oid
+		}
+		row[5] = nullIfEmpty(b.role)
+		row[6] = st.appname
+		if !hasPgstatPermissions(user, b.role) {
+			row[20] = "<insufficient privilege>"
+			rows = append(rows, row)
+			continue
+		}
+		// 並列実行はまだないため、leader_pid は常に NULL
+		row[7] = nullIfEmpty(st.clientAddr)
+		if st.clientPort != 0 {
+			row[9] = st.clientPort
+		}
+		row[10] = timestamptzOrNull(st.procStart)
+		row[11] = timestamptzOrNull(st.xactStart)
+		row[12] = timestamptzOrNull(st.activityStart)
+		row[13] = timestamptzOrNull(st.stateStart)
+		row[14] = nullIfEmpty(waitevent.GetWaitEventType(b.waitEvent))
+		row[15] = nullIfEmpty(waitevent.GetWaitEventIdentifier(b.waitEvent))
+		row[16] = nullIfEmpty(backendStateNames[st.state])
+		xid, xmin := procarray.GetTransactionIds(b.pid)
+		if xid != transam.InvalidTransactionId {
+			row[17] = xid
+		}
+		if xmin != transam.InvalidTransactionId {
+			row[18] = xmin
+		}
+		if st.queryID != 0 {
+			row[19] = st.queryID
+		}
+		row[20] = st.activity
+		row[21] = st.backendType
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// timestamptzOrNull は時刻を timestamptz にする。ゼロの時刻は NULL にする。
+func timestamptzOrNull(t time.Time) adt.Datum {
+	if t.IsZero() {
+		return nil
+	}
+	return adt.TimestamptzFromTime(t)
+}
+
+// isUserRelation はユーザーのリレーションかを返す (pg_stat_user_tables の schemaname の条件相当)
+func isUserRelation(rel *pgstat.Relation) bool {
+	return rel.Nspname != "pg_catalog" && rel.Nspname != "information_schema" && !strings.HasPrefix(rel.Nspname, "pg_toast")
+}
+
+// callerDatabaseStats は関数を呼び出したセッションの接続先のデータベースの統計を返す
+func callerDatabaseStats(fcinfo *fmgr.FunctionCallInfo) ([]pgstat.StatTabEntry, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	return pgstat.FetchStatTabEntries(catalog.GetDatabaseOid(s.databaseName)), nil
+}
+
+// pgStatGetUserTables は pg_stat_user_tables の行を OID の順に返す (pg_stat_all_tables の
+// 定義と pg_stat_get_numscans などの関数相当)。idx_scan と idx_tup_fetch はテーブルの
+// インデックスの回数を合計する。
+func pgStatGetUserTables(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	entries, err := callerDatabaseStats(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	type indexTotals struct {
+		scans    int64
+		lastScan time.Time
+		fetched  int64
+	}
+	indexes := make(map[catalog.Oid]*indexTotals)
+	for i := range entries {
+		e := &entries[i]
+		if e.Indrelid == catalog.InvalidOid {
+			continue
+		}
+		t, ok := indexes[e.Indrelid]
+		if !ok {
+			t = &indexTotals{}
+			indexes[e.Indrelid] = t
+		}
+		t.scans += e.NumScans
+		t.fetched += e.TuplesFetched
+		if e.LastScan.After(t.lastScan) {
+			t.lastScan = e.LastScan
+		}
+	}
+
+	var rows [][]adt.Datum
+	for i := range entries {
+		e := &entries[i]
+		if e.Indrelid != catalog.InvalidOid || !isUserRelation(&e.Relation) {
+			continue
+		}
+		row := make([]adt.Datum, 26)
+		row[0] = e.Relid
+		row[1] = e.Nspname
+		row[2] = e.Relname
+		row[3] = e.NumScans
+		row[4] = timestamptzOrNull(e.LastScan)
+		row[5] = e.TuplesReturned
+		// インデックスのないテーブルの idx_scan と idx_tup_fetch は NULL
+		if t, ok := indexes[e.Relid]; ok {
+			row[6] = t.scans
+			row[7] = timestamptzOrNull(t.lastScan)
+			row[8] = t.fetched + e.TuplesFetched
+		}
+		row[9] = e.TuplesInserted
+		row[10] = e.TuplesUpdated
+		row[11] = e.TuplesDeleted
+		row[12] = e.TuplesHotUpdated
+		row[13] = e.TuplesNewpageUpdated
+		row[14] = e.LiveTuples
+		row[15] = e.DeadTuples
+		row[16] = e.ModSinceAnalyze
+		row[17] = e.InsSinceVacuum
+		row[18] = timestamptzOrNull(e.LastVacuumTime)
+		row[19] = timestamptzOrNull(e.LastAutovacuumTime)
+		row[20] = timestamptzOrNull(e.LastAnalyzeTime)
+		row[21] = timestamptzOrNull(e.LastAutoanalyzeTime)
+		row[22] = e.VacuumCount
+		row[23] = e.AutovacuumCount
+		row[24] = e.AnalyzeCount
+		row[25] = e.AutoanalyzeCount
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// pgStatGetUserIndexes は pg_stat_user_indexes の行を OID の順に返す (pg_stat_all_indexes の定義相当)
+func pgStatGetUserIndexes(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	entries, err := callerDatabaseStats(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	relnames := make(map[catalog.Oid]string)
+	for i := range entries {
+		relnames[entries[i].Relid] = entries[i].Relname
+	}
+
+	var rows [][]adt.Datum
+	for i := range entries {
+		e := &entries[i]
+		if e.Indrelid == catalog.InvalidOid || !isUserRelation(&e.Relation) {
+			continue
+		}
+		row := make([]adt.Datum, 9)
+		row[0] = e.Indrelid
+		row[1] = e.Relid
+		row[2] = e.Nspname
+		// テーブルの統計がまだなければ、テーブルの名前は分からない
+		row[3] = nullIfEmpty(relnames[e.Indrelid])
+		row[4] = e.Relname
+		row[5] = e.NumScans
+		row[6] = timestamptzOrNull(e.LastScan)
+		row[7] = e.TuplesReturned
+		row[8] = e.TuplesFetched
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// pgStatGetUserFunctions は pg_stat_user_functions の行を OID の順に返す (pg_stat_user_functions の
+// 定義と pg_stat_get_function_calls などの関数相当)。時間はミリ秒で返す。
+func pgStatGetUserFunctions(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]adt.Datum
+	for _, e := range pgstat.FetchStatFuncEntries(catalog.GetDatabaseOid(s.databaseName)) {
+		proc, ok := catalog.SearchProc(e.Funcid)
+		if !ok {
+			// 統計を数えた後に削除された関数
+			continue
+		}
+		rows = append(rows, []adt.Datum{
+			e.Funcid,
+			"pg_catalog",
+			proc.Proname,
+			e.NumCalls,
+			float64(e.TotalTime) / float64(time.Millisecond),
+			float64(e.SelfTime) / float64(time.Millisecond),
+		})
+	}
+	return rows, nil
+}
+
+// pgStatGetDatabase は pg_stat_database の行を OID の順に返す (pg_stat_database の定義と
+// pg_stat_get_db_numbackends などの関数相当)。最初の行は datid が 0 で、データベースに接続しない
+// バックエンドの分を数える。
+func pgStatGetDatabase(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	numbackends := make(map[catalog.Oid]int32)
+	backendList.Lock()
+	for _, entry := range backendList.entries {
+		if entry.role != "" || entry.kind == procBgworker {
+			numbackends[catalog.GetDatabaseOid(entry.status.databaseName)]++
+		}
+	}
+	backendList.Unlock()
+
+	row := func(datid catalog.Oid, datname adt.Datum) []adt.Datum {
+		st := pgstat.FetchStatDBEntry(datid)
+		return []adt.Datum{
+			datid,
+			datname,
+			numbackends[datid],
+			st.XactCommit,
+			st.XactRollback,
+			st.TempFiles,
+			st.TempBytes,
+			float64(st.BlkReadTime) / float64(time.Millisecond),
+			float64(st.BlkWriteTime) / float64(time.Millisecond),
+			timestamptzOrNull(st.StatResetTimestamp),
+		}
+	}
+	rows := [][]adt.Datum{row(catalog.InvalidOid, nil)}
+	for _, datname := range catalog.ListDatabases() {
+		rows = append(rows, row(catalog.GetDatabaseOid(datname), datname))
+	}
+	return rows, nil
+}
+
+// pgStatGetArchiver は pg_stat_archiver の行を返す (pg_stat_get_archiver 相当)
+func pgStatGetArchiver(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	st := pgstat.FetchStatArchiver()
+	return [][]adt.Datum{{
+		st.ArchivedCount,
+		nullIfEmpty(st.LastArchivedWal),
+		timestamptzOrNull(st.LastArchivedTimestamp),
+		st.FailedCount,
+		nullIfEmpty(st.LastFailedWal),
+		timestamptzOrNull(st.LastFailedTimestamp),
+		timestamptzOrNull(st.StatResetTimestamp),
+	}}, nil
+}
+
+// pgStatGetBgWriter は pg_stat_bgwriter の行を返す (pg_stat_bgwriter の定義と
+// pg_stat_get_bgwriter_buf_written_clean などの関数相当)
+func pgStatGetBgWriter(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	st := pgstat.FetchStatBgWriter()
+	return [][]adt.Datum{{
+		st.BuffersClean,
+		st.MaxwrittenClean,
+		st.BuffersAlloc,
+		timestamptzOrNull(st.StatResetTimestamp),
+	}}, nil
+}
+
+// pgStatGetCheckpointer は pg_stat_checkpointer の行を返す (pg_stat_checkpointer の定義と
+// pg_stat_get_checkpointer_num_timed などの関数相当)。時間はミリ秒で返す。
+func pgStatGetCheckpointer(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	st := pgstat.FetchStatCheckpointer()
+	return [][]adt.Datum{{
+		st.NumTimed,
+		st.NumRequested,
+		st.RestartpointsTimed,
+		st.RestartpointsRequested,
+		st.RestartpointsPerformed,
+		float64(st.WriteTime) / float64(time.Millisecond),
+		float64(st.SyncTime) / float64(time.Millisecond),
+		st.BuffersWritten,
+		timestamptzOrNull(st.StatResetTimestamp),
+	}}, nil
+}
+
+// pgStatGetBufWrittenBackend はバックエンドが自分で書き出したバッファの数を返す
+// (pg_stat_get_buf_written_backend 相当)
+func pgStatGetBufWrittenBackend(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	return pgstat.FetchStatBgWriter().BuffersBackend, nil
+}
+
+// pgStatReset は接続先のデータベースの統計を 0 に戻す (pg_stat_reset 相当)。pg_stat_reset_shared と
+// 同じく、スーパーユーザーだけが実行できる。
+func pgStatReset(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	if !catalog.IsSuperuser(s.userName) {
+		return nil, newError(errcodes.InsufficientPrivilege, "permission denied for function pg_stat_reset")
+	}
+	pgstat.ResetCounters(catalog.GetDatabaseOid(s.databaseName))
+	return adt.Void{}, nil
+}
+
+// pgStatResetShared は共有の統計を 0 に戻す (pg_stat_reset_shared 相当)。引数が NULL なら全てを
+// 戻す。pg_reload_conf と同じく、スーパーユーザーだけが実行できる。
+func pgStatResetShared(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	if !catalog.IsSuperuser(ctx.UserName()) {
+		return nil, newError(errcodes.InsufficientPrivilege, "permission denied for function pg_stat_reset_shared")
+	}
+	if fcinfo.Args[0] == nil {
+		pgstat.ResetArchiver()
+		pgstat.ResetBgWriter()
+		pgstat.ResetCheckpointer()
+		return adt.Void{}, nil
+	}
+	switch target := fcinfo.Args[0].(string); target {
+	case "archiver":
+		pgstat.ResetArchiver()
+	case "bgwriter":
+		pgstat.ResetBgWriter()
+	case "checkpointer":
+		pgstat.ResetCheckpointer()
+	default:
+		return nil, withHint(newError(errcodes.InvalidParameterValue, "unrecognized reset target: \"%s\"", target),
+			"Target must be \"archiver\", \"bgwriter\", or \"checkpointer\".")
+	}
+	return adt.Void{}, nil
+}
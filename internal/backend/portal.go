@@ -0,0 +1,123 @@
+package backend
+
+import (
+	"sort"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/executor"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+)
+
+// ----------------------------------------------------------------
+// ポータル (utils/mmgr/portalmem.c, tcop/pquery.c 相当)
+// ----------------------------------------------------------------
+// ポータルは、パラメータを束縛して実行可能になった文と、その実行状態を表す。
+// Execute メッセージで行数の上限が指定された場合、残りの行は次の Execute で返す。
+// SELECT は実行器の状態をポータルに持ち、必要な行だけを作って送るため、
+// 結果の全体をメモリに持つことはない。
+//
+// pg_cursors は、C言語版と同じく SQL のカーソルとして作ったポータルだけを返し、
+// Bind メッセージで作ったポータルは含めない。DECLARE CURSOR はまだないため、現時点では
+// 常に空になる。
+
+func init() {
+	fmgr.RegisterSetReturning("pg_cursor", pgCursor)
+}
+
+// portalStatus はポータルの状態 (PortalStatus 相当)
+type portalStatus int
+
+const (
+	// portalReady は実行できる状態 (PORTAL_READY)。行を返す文は、全ての行を返した後もこの状態のままになる
+	portalReady portalStatus = iota
+	// portalDone は行を返さない文を実行し終えた状態 (PORTAL_DONE)
+	portalDone
+	// portalFailed は実行中にエラーになった状態 (PORTAL_FAILED)
+	portalFailed
+)
+
+// portal は実行中の文 (PortalData 相当)
+type portal struct {
+	name   string
+	stmt   *preparedStatement
+	params executor.ParamListInfo
+	// formats は結果の各列の書式コード (0: テキスト, 1: バイナリ)
+	formats []int16
+	status  portalStatus
+	// visible は pg_cursors に表示するかどうか。Bind で作ったポータルは表示しない
+	visible bool
+	// creationTime はポータルを作った時刻
+	creationTime adt.TimestampTz
+
+	// queryDesc は SELECT の実行状態。Execute のたびに続きの行を作って送る
+	queryDesc *executor.QueryDesc
+	// holdStore はユーティリティ文の実行結果 (holdStore 相当)。pos はまだ返していない最初の行
+	holdStore *executor.Result
+	pos       int
+	// limit は文が返す結果の制限。文の実行を始めたときに作る
+	limit *resultLimit
+}
+
+// started は文の実行を始めたかどうかを返す
+func (p *portal) started() bool {
+	return p.queryDesc != nil || p.holdStore != nil
+}
+
+// tupDesc は結果行の列定義を返す (PortalGetTupleDesc 相当)。行を返さない文では nil を返す。
+func (p *portal) tupDesc() executor.TupleDesc {
+	if p.holdStore != nil {
+		return p.holdStore.Desc
+	}
+	return executor.ExecTypeFromTL(p.stmt.query.TargetList)
+}
+
+// createPortal はポータルを作る (CreatePortal 相当)。無名のポータルは置き換える。
+func (s *session) createPortal(p *portal) error {
+	if p.name != "" {
+		if _, ok := s.portals[p.name]; ok {
+			return newError(errcodes.DuplicateCursor, "portal \"%s\" already exists", p.name)
+		}
+	}
+	p.creationTime = adt.GetCurrentTimestamp()
+	s.portals[p.name] = p
+	return nil
+}
+
+// getPortalByName は名前でポータルを探す (GetPortalByName 相当)
+func (s *session) getPortalByName(name string) (*portal, error) {
+	p, ok := s.portals[name]
+	if !ok {
+		return nil, newError(errcodes.UndefinedCursor, "portal \"%s\" does not exist", name)
+	}
+	return p, nil
+}
+
+// dropPortal はポータルを削除する (PortalDrop 相当)。存在しなくてもエラーにしない。
+func (s *session) dropPortal(name string) {
+	delete(s.portals, name)
+}
+
+// atEOXactPortals はトランザクション終了時に全てのポータルを削除する (AtCleanup_Portals 相当)。
+// WITH HOLD カーソルはまだないため、全てのポータルが対象になる。
+func (s *session) atEOXactPortals() {
+	clear(s.portals)
+}
+
+// pgCursor は pg_cursors の行を名前の順に返す (pg_cursor 相当)。WITH HOLD、BINARY、
+// SCROLL のカーソルはまだないため、それらの列は false になる。
+func pgCursor(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]adt.Datum
+	for name, p := range s.portals {
+		if !p.visible {
+			continue
+		}
+		rows = append(rows, []adt.Datum{name, p.stmt.queryString, false, false, false, p.creationTime})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0].(string) < rows[j][0].(string) })
+	return rows, nil
+}
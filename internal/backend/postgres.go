@@ -0,0 +1,1123 @@
+package backend
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"os/user"
+	"syscall"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam/xact"
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/crypto"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/executor"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/buffer"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/file"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/ipc"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/smgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/pgstat"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/snapmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// バックエンドのメインループ (tcop/postgres.c の PostgresMain 相当)
+// ----------------------------------------------------------------
+
+// session は1つの接続に属するバックエンドの状態。
+type session struct {
+	port *libpq.Port
+	// pid と cancelKey は BackendKeyData で通知する PID と秘密鍵 (MyProcPid, MyCancelKey 相当)
+	pid       int32
+	cancelKey int32
+	// activity は実行中または最後に実行した問い合わせ (PgBackendStatus の st_activity 相当)
+	activity string
+	// commandTag は実行中のコマンドのタグ。アイドルの間は idle などの状態 (ps の表示相当)
+	commandTag string
+	// logProc はサーバーログに出すこのセッションの情報
+	logProc *errutil.ProcInfo
+	// databaseName と userName は接続先のデータベースとユーザー。userName は SECURITY DEFINER の
+	// 関数の中では関数の所有者に変わる (CurrentUserId 相当)
+	databaseName string
+	userName     string
+	// sessionUserName はセッションを始めたユーザー (SessionUserId 相当)。関数の中でも変わらない
+	sessionUserName string
+	// gucs はセッションで設定したパラメータの値
+	gucs *guc.Session
+	// reportedGUCs は GUC_REPORT のパラメータについて、最後にクライアントに通知した値
+	reportedGUCs map[string]string
+	// startTime は接続を受け付けた時刻 (MyStartTimestamp 相当)
+	startTime time.Time
+	// authTimeout は認証にかかる時間の監視。認証を終えたら止める
+	authTimeout *authTimeout
+	// exitCallbacks はセッションの終了時に実行する後始末
+	exitCallbacks []func()
+
+	preparedStatements map[string]*preparedStatement
+	portals            map[string]*portal
+
+	// doingExtendedQuery は拡張問い合わせプロトコルのメッセージを処理中であることを表す
+	doingExtendedQuery bool
+	// ignoreTillSync が true の間は、Sync 以外のメッセージを読み捨てる。
+	// 拡張問い合わせプロトコルでエラーが起きた後、クライアントと同期を取り直すために使う。
+	ignoreTillSync bool
+	// xactStarted はトランザクションのコマンドを始めていることを表す (xact_started 相当)
+	xactStarted bool
+	// xact はセッションのトランザクションの状態。バックエンドの一覧に登録して PID が決まってから作る
+	xact *xact.TransactionState
+	// xactSnapshot は REPEATABLE READ 以上のトランザクションで最初に取ったスナップショット
+	// (CurrentSnapshot の FirstSnapshotSet 以降の値相当)。取っていなければ nil
+	xactSnapshot *snapmgr.Snapshot
+
+	// interrupts はこのバックエンドへの割り込み要求 (キャンセル要求など)
+	interrupts *miscadmin.Interrupts
+	// pgstat はまだ共有の統計に加えていないテーブルとインデックスの操作の回数
+	pgstat *pgstat.Pending
+	// waitEvent はこのバックエンドが待っているものの記録
+	waitEvent *waitevent.Slot
+	// tempFiles はこのバックエンドが開いている一時ファイル。バックエンドの一覧に登録して
+	// PID が決まってから作る
+	tempFiles *file.TempFiles
+	// lockProc はこのバックエンドが取った重いロック。tempFiles と同じく PID が決まってから作る
+	lockProc *lmgr.Proc
+	// buffers はこのバックエンドが付けている共有バッファのピン。lockProc で内容のロックを取る
+	buffers *buffer.Backend
+	// pendingDeletes は実行中のトランザクションの終わりに消すリレーションのファイル (pendingDeletes 相当)
+	pendingDeletes []pendingRelDelete
+	// relationUndo は実行中のトランザクションがアボートしたら戻すリレーションの定義
+	relationUndo []relationUndo
+
+	// whereToSendOutput は結果の送り先 (whereToSendOutput 相当)。単一ユーザーモードでは
+	// port が nil で、結果を out に書く
+	whereToSendOutput commandDest
+	out               io.Writer
+}
+
+// newSession はセッションを作る。port が nil の場合は単一ユーザーモードのセッションで、
+// 呼び出し側が結果の送り先を設定する。
+func newSession(port *libpq.Port) *session {
+	waitEvent := &waitevent.Slot{}
+	s := &session{
+		port:               port,
+		gucs:               guc.NewSession(),
+		reportedGUCs:       make(map[string]string),
+		preparedStatements: make(map[string]*preparedStatement),
+		portals:            make(map[string]*portal),
+		interrupts:         &miscadmin.Interrupts{},
+		waitEvent:          waitEvent,
+	}
+	s.logProc = newLogProcInfo(port, time.Now())
+	s.logProc.Status = s.logStatus
+	s.pgstat = pgstat.NewPending(waitEvent, func() pgstat.TrackFunc {
+		return pgstat.TrackFunctionsLevel(s.gucs.GetEnum(guc.TrackFunctions))
+	}, func() bool { return s.gucs.GetBool(guc.TrackIoTiming) })
+	// コマンドを待っている間や、結果を読まないクライアントへの送信で止まっている間に
+	// 終了を要求されたら、送受信を中断させる
+	if port != nil {
+		port.WaitEvent = waitEvent
+		s.interrupts.SetWakeup(func() { port.Conn().SetDeadline(time.Now()) })
+	}
+	return s
+}
+
+// newLogProcInfo はサーバーログに出すクライアントのバックエンドの情報を作る。PID は
+// バックエンドを一覧に登録したときに設定する。
+func newLogProcInfo(port *libpq.Port, startTime time.Time) *errutil.ProcInfo {
+	proc := &errutil.ProcInfo{BackendType: "client backend", StartTime: startTime}
+	if port != nil {
+		proc.RemoteHost, proc.RemotePort = port.RemoteHost, port.RemotePort
+	}
+	return proc
+}
+
+// UserName は現在のユーザー名を返す (fmgr.CallContext)
+func (s *session) UserName() string { return s.userName }
+
+// BackendPid はバックエンドの PID を返す (fmgr.CallContext)
+func (s *session) BackendPid() int32 { return s.pid }
+
+// Warning は WARNING を報告する (fmgr.CallContext)。送信の失敗は次の送信で検出する。
+func (s *session) Warning(msg string) {
+	_ = s.reportWarning(errcodes.Warning, msg)
+}
+
+// EnterFunction は現在のユーザーを owner に切り替え、関数の SET 句を適用する (fmgr.CallContext)。
+// SET 句はユーザーを切り替えた後の権限で設定する。
+func (s *session) EnterFunction(owner string, config []string) (func(), error) {
+	saveUserName := s.userName
+	if owner != "" {
+		s.userName = owner
+	}
+	nestLevel := s.gucs.NewGUCNestLevel()
+	exit := func() {
+		s.gucs.AtEOFunctionGUC(nestLevel)
+		s.userName = saveUserName
+	}
+	if errs := s.gucs.ProcessGUCArray(config, s.gucContext(), guc.PGCSSession, guc.GucActionSave); len(errs) > 0 {
+		exit()
+		return nil, errs[0]
+	}
+	return exit, nil
+}
+
+// postgresMain はクライアントからのメッセージを読み取って処理する。
+// クライアントが Terminate を送るか、接続が切れるか、セッションの終了を要求されるまで戻らない。
+func postgresMain(s *session) {
+	if s.gucs.GetBool(guc.LogDisconnections) {
+		s.onExit(s.logDisconnections)
+	}
+
+	sendReady := true
+	for {
+		if s.interrupts.ProcDiePending() {
+			s.reportProcDie()
+			return
+		}
+		// 単純問い合わせと Sync の処理が終わるたびに ReadyForQuery を送る
+		if sendReady {
+			// アイドルになる前に、統計を共有の統計に加え、セッションの状態を記録する
+			s.pgstat.ReportStat()
+			if s.xact.IsAbortedTransactionBlockState() {
+				s.reportActivity(stateIdleInTransactionAborted, "")
+				s.commandTag = "idle in transaction (aborted)"
+			} else if s.xact.IsTransactionOrTransactionBlock() {
+				s.reportActivity(stateIdleInTransaction, "")
+				s.commandTag = "idle in transaction"
+			} else {
+				s.reportActivity(stateIdle, "")
+				s.commandTag = "idle"
+			}
+			// トランザクションの終わりに元に戻った値も通知する
+			if err := s.reportChangedGUCOptions(); err != nil {
+				return
+			}
+			status, err := s.xact.TransactionBlockStatusCode()
+			if err != nil {
+				reportFatal(s.logProc, s.port, err)
+				return
+			}
+			if err := sendReadyForQuery(s.port, status); err != nil {
+				return
+			}
+			if err := s.port.Flush(); err != nil {
+				return
+			}
+			sendReady = false
+		}
+
+		s.waitEvent.Start(waitevent.ClientRead)
+		firstchar, msg, err := readCommand(s.port)
+		s.waitEvent.End()
+		if err != nil {
+			if s.interrupts.ProcDiePending() {
+				// 終了の要求で受信が中断された
+				s.reportProcDie()
+			} else if !errors.Is(err, libpq.ErrConnectionClosed) {
+				reportFatal(s.logProc, s.port, err)
+			}
+			return
+		}
+		// コマンドを待っている間に届いたキャンセル要求は無視する
+		s.interrupts.ClearQueryCancelPending()
+
+		if s.ignoreTillSync && firstchar != libpq.PqMsgSync && firstchar != libpq.PqMsgTerminate {
+			continue
+		}
+
+		switch firstchar {
+		case libpq.PqMsgQuery:
+			err = s.processQuery(msg)
+			sendReady = true
+		case libpq.PqMsgParse:
+			err = s.processParse(msg)
+		case libpq.PqMsgBind:
+			err = s.processBind(msg)
+		case libpq.PqMsgExecute:
+			err = s.processExecute(msg)
+		case libpq.PqMsgDescribe:
+			err = s.processDescribe(msg)
+		case libpq.PqMsgClose:
+			err = s.processClose(msg)
+		case libpq.PqMsgFlush:
+			if err = msg.GetMsgEnd(); err == nil {
+				err = s.port.Flush()
+			}
+		case libpq.PqMsgSync:
+			if err = msg.GetMsgEnd(); err == nil {
+				if ferr := s.finishXactCommand(); ferr != nil {
+					err = s.reportError("", ferr)
+				}
+				s.doingExtendedQuery = false
+				s.ignoreTillSync = false
+				sendReady = true
+			}
+		case libpq.PqMsgTerminate:
+			return
+		default:
+			reportFatal(s.logProc, s.port, newError(errcodes.ProtocolViolation, "invalid frontend message type %d", firstchar))
+			return
+		}
+
+		if err != nil {
+			switch {
+			case errors.Is(err, libpq.ErrInvalidMessageFormat):
+				// メッセージの形式が壊れている場合は同期を取り直せないため、接続を切る
+				reportFatal(s.logProc, s.port, newError(errcodes.ProtocolViolation, "%s", err.Error()))
+			case errors.Is(err, miscadmin.ErrProcDie), s.interrupts.ProcDiePending():
+				// 結果を読まないクライアントへの送信が終了の要求で中断された場合も含む
+				s.reportProcDie()
+			case errutil.LevelOf(err) == errutil.Fatal:
+				reportFatal(s.logProc, s.port, err)
+			case errutil.LevelOf(err) == errutil.Panic:
+				// サーバー全体を異常終了させる。postmaster が panic を異常終了として扱い、
+				// 他のバックエンドを終了させてから初期化し直す
+				edata := makeErrorData(errutil.Panic, err, "")
+				_ = errutil.EmitErrorReport(s.logProc, s.gucs, s.port, edata, "")
+				_ = s.port.Flush()
+				panic(edata)
+			}
+			// それ以外は送信に失敗した (接続が切れた) ことを表す
+			return
+		}
+	}
+}
+
+// errCrashShutdown は他のバックエンドの異常終了を受けてセッションを終了することを表す
+var errCrashShutdown = withHint(withDetail(newError(errcodes.CrashShutdown, "terminating connection because of crash of another server process"),
+	"The postmaster has commanded this server process to roll back the current transaction and exit, "+
+		"because another server process exited abnormally and possibly corrupted shared memory."),
+	"In a moment you should be able to reconnect to the database and repeat your command.")
+
+// reportProcDie は終了の要求を受けてセッションを終えることを報告する (die, quickdie 相当)。
+// 他のバックエンドの異常終了による要求は、サーバーログには出力せず、クライアントにだけ
+// WARNING として送る (WARNING_CLIENT_ONLY 相当)。
+func (s *session) reportProcDie() {
+	// 待ちを中断させるために過去にした期限を外す。結果の送信の途中で中断された場合は、
+	// 送信バッファが失敗を覚えていて何も送らないため、読まないクライアントを待つことはない
+	s.port.Conn().SetWriteDeadline(time.Time{})
+	if s.interrupts.QuickDiePending() {
+		_ = errutil.EmitErrorReport(s.logProc, s.gucs, s.port, makeErrorData(errutil.WarningClientOnly, errCrashShutdown, ""), "")
+		_ = s.port.Flush()
+		return
+	}
+	reportFatal(s.logProc, s.port, miscadmin.ErrProcDie)
+}
+
+// logDisconnections はセッションの終了をサーバーログに出力する (log_disconnections 相当)。
+// PostgreSQL の出力に加えて、送受信したバイト数も出力する。
+func (s *session) logDisconnections() {
+	elapsed := time.Since(s.startTime)
+	msecs := elapsed.Milliseconds()
+	hostPort := s.port.RemoteHost
+	if s.port.RemotePort != "" {
+		hostPort += " port=" + s.port.RemotePort
+	}
+	errutil.Report(s.logProc, errutil.Elog(errutil.Log, "disconnection: session time: %d:%02d:%02d.%03d user=%s database=%s host=%s bytes_sent=%d bytes_received=%d",
+		msecs/3600000, msecs/60000%60, msecs/1000%60, msecs%1000,
+		s.port.UserName, s.port.DatabaseName, hostPort, s.port.BytesSent, s.port.BytesReceived))
+}
+
+// readCommand は次のメッセージを読み取る (SocketBackend 相当)
+func readCommand(port *libpq.Port) (byte, *libpq.Message, error) {
+	firstchar, err := port.GetByte()
+	if err != nil {
+		return 0, nil, libpq.ErrConnectionClosed
+	}
+
+	maxlen := libpq.PqSmallMessageLimit
+	switch firstchar {
+	case libpq.PqMsgQuery, libpq.PqMsgParse, libpq.PqMsgBind:
+		maxlen = libpq.PqLargeMessageLimit
+	}
+	body, err := port.GetMessage(maxlen)
+	if err != nil {
+		return 0, nil, err
+	}
+	return firstchar, libpq.NewMessage(body), nil
+}
+
+// sendReadyForQuery は ReadyForQuery メッセージを送る (ReadyForQuery 相当)。
+// status はトランザクションの状態 ('I': トランザクション外, 'T': トランザクション中, 'E': 失敗したトランザクション中)。
+func sendReadyForQuery(port *libpq.Port, status byte) error {
+	buf := libpq.BeginMessage(libpq.PqMsgReadyForQuery)
+	buf.SendByte(status)
+	return buf.EndMessage(port)
+}
+
+// startXactCommand はコマンドを始める。トランザクションの外であればトランザクションを開始する
+// (start_xact_command 相当)
+func (s *session) startXactCommand() error {
+	if !s.xactStarted {
+		if err := s.xact.StartTransactionCommand(); err != nil {
+			return err
+		}
+		s.xactStarted = true
+	}
+	return nil
+}
+
+// finishXactCommand はコマンドを終える (finish_xact_command 相当)。トランザクションブロックの外の
+// コマンドであればトランザクションをコミットする。
+func (s *session) finishXactCommand() error {
+	if !s.xactStarted {
+		return nil
+	}
+	s.xactStarted = false
+	return s.xact.CommitTransactionCommand()
+}
+
+// abortCurrentTransaction はトランザクションをアボートする (AbortCurrentTransaction 相当)。文の
+// 実行がエラーになった時に呼ぶ。トランザクションブロックの中であれば、ブロックは ROLLBACK を
+// 待つ状態になる。アボートを記録できなければ PANIC のエラーを返す。
+func (s *session) abortCurrentTransaction() error {
+	s.xactStarted = false
+	return s.xact.AbortCurrentTransaction()
+}
+
+// checkAbortedTransactionBlock はエラーになったトランザクションブロックの中で、実行する文が
+// ブロックを終える文 (exitStmt) でなければエラーを返す (exec_simple_query などの
+// IsAbortedTransactionBlockState の確認相当)
+func (s *session) checkAbortedTransactionBlock(exitStmt bool) error {
+	if s.xact.IsAbortedTransactionBlockState() && !exitStmt {
+		return newError(errcodes.InFailedSQLTransaction, "current transaction is aborted, commands ignored until end of transaction block")
+	}
+	return nil
+}
+
+// isTransactionStmt は stmt がトランザクションを制御する文かを返す (exec_execute_message の
+// is_xact_command の判定相当)
+func isTransactionStmt(stmt parser.Node) bool {
+	_, ok := stmt.(*parser.TransactionStmt)
+	return ok
+}
+
+// isTransactionExitStmt は stmt がエラーになったトランザクションブロックでも実行できる、
+// ブロックを終えるかエラーの前に戻す文かを返す (IsTransactionExitStmt 相当)
+func isTransactionExitStmt(stmt parser.Node) bool {
+	if n, ok := stmt.(*parser.TransactionStmt); ok {
+		switch n.Kind {
+		case parser.TransStmtCommit, parser.TransStmtRollback, parser.TransStmtRollbackTo, parser.TransStmtPrepare:
+			return true
+		}
+	}
+	return false
+}
+
+// reportError は文の処理中に起きたエラーを報告する。拡張問い合わせプロトコルの処理中であれば、
+// 以降は Sync までのメッセージを読み捨てる。
+func (s *session) reportError(query string, err error) error {
+	if errors.Is(err, miscadmin.ErrProcDie) {
+		// セッションの終了は ERROR ではなく FATAL として、呼び出し元が報告する
+		return err
+	}
+	var sf *sendFailure
+	if errors.As(err, &sf) {
+		// 結果行を送れなかった接続には ErrorResponse も送れない
+		return err
+	}
+	switch errutil.LevelOf(err) {
+	case errutil.Panic:
+		// 呼び出し元がサーバー全体を異常終了させる。トランザクションの状態は途中でありうるため、
+		// アボートしない
+		return err
+	case errutil.Fatal:
+		// トランザクションを中止した後、呼び出し元がセッションを終えて報告する
+		if aerr := s.abortCurrentTransaction(); aerr != nil {
+			return aerr
+		}
+		return err
+	}
+	if s.doingExtendedQuery {
+		s.ignoreTillSync = true
+	}
+	// トランザクションの情報 (log_line_prefix の %v など) が残っているうちに報告してからアボートする
+	rerr := errutil.EmitErrorReport(s.logProc, s.gucs, s.port, makeErrorData(errutil.Error, err, query), query)
+	if aerr := s.abortCurrentTransaction(); aerr != nil {
+		// 呼び出し元がサーバー全体を異常終了させる
+		return aerr
+	}
+	return rerr
+}
+
+// processQuery は Query メッセージを処理する。
+func (s *session) processQuery(msg *libpq.Message) error {
+	query, err := msg.GetMsgString()
+	if err != nil {
+		return err
+	}
+	if err := msg.GetMsgEnd(); err != nil {
+		return err
+	}
+	s.doingExtendedQuery = false
+	return s.execSimpleQuery(query)
+}
+
+// execSimpleQuery は Query メッセージで送られた問い合わせを実行する (exec_simple_query 相当)。
+// 文の実行エラーはクライアントに ErrorResponse として報告し、残りの文は実行しない。
+// 複数の文は、間にトランザクションを制御する文がなければ暗黙のトランザクションブロックで
+// まとめて実行する。戻り値のエラーは送信に失敗した (接続が切れた) ことを表す。
+func (s *session) execSimpleQuery(query string) error {
+	s.activity = query
+	s.reportActivity(stateRunning, query)
+	// 単純問い合わせは無名の文とポータルを破棄する (drop_unnamed_stmt 相当)
+	s.dropPreparedStatement("")
+	if err := s.startXactCommand(); err != nil {
+		return s.reportError(query, err)
+	}
+
+	stmts, err := parser.RawParser(query)
+	if err != nil {
+		return s.reportError(query, err)
+	}
+
+	useImplicitBlock := len(stmts) > 1
+	for i, raw := range stmts {
+		// 解析のエラーも実行中のコマンドのものとして報告する
+		s.commandTag = createCommandTag(raw.Stmt)
+		if err := s.startXactCommand(); err != nil {
+			return s.reportError(query, err)
+		}
+		if err := s.checkAbortedTransactionBlock(isTransactionExitStmt(raw.Stmt)); err != nil {
+			return s.reportError(query, err)
+		}
+		if useImplicitBlock {
+			s.xact.BeginImplicitTransactionBlock()
+		}
+		q, err := parser.ParseAnalyzeFixedparams(raw, nil)
+		if err != nil {
+			return s.reportError(query, err)
+		}
+		s.jumbleQuery(q, query)
+		// 単純問い合わせでは、無名のポータルを置き換えずに一時的なポータルで実行する
+		p := &portal{stmt: &preparedStatement{queryString: query, query: q}}
+		if err := s.portalStart(p); err != nil {
+			return s.reportError(query, err)
+		}
+		desc := p.tupDesc()
+		if err := s.beginCommand(desc); err != nil {
+			return err
+		}
+		tag, err := s.portalRun(p, 0, s.createDestReceiver(desc, p.limit))
+		if err != nil {
+			return s.reportError(query, err)
+		}
+
+		// 最後の文とトランザクションを制御する文の後ではコマンドを終え、コミットの失敗を
+		// CommandComplete より先に報告する。それ以外の文の後ではコマンド ID を進める
+		if i == len(stmts)-1 {
+			if useImplicitBlock {
+				s.xact.EndImplicitTransactionBlock()
+			}
+			err = s.finishXactCommand()
+		} else if isTransactionStmt(raw.Stmt) {
+			err = s.finishXactCommand()
+		} else {
+			err = s.xact.CommandCounterIncrement()
+		}
+		if err != nil {
+			return s.reportError(query, err)
+		}
+		if err := s.endCommand(tag); err != nil {
+			return err
+		}
+	}
+
+	// 文がなくても、始めたコマンドを終える
+	if err := s.finishXactCommand(); err != nil {
+		return s.reportError(query, err)
+	}
+	if len(stmts) == 0 {
+		return s.nullCommand()
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// 拡張問い合わせプロトコル
+// ----------------------------------------------------------------
+// Parse で文を解析してプリペアド文を作り、Bind でパラメータを束縛してポータルを作り、
+// Execute でポータルを実行する。エラーが起きた場合は Sync まで後続のメッセージを読み捨てる。
+
+// processParse は Parse メッセージを処理する (exec_parse_message 相当)
+func (s *session) processParse(msg *libpq.Message) error {
+	s.doingExtendedQuery = true
+	s.commandTag = "PARSE"
+	if err := s.startXactCommand(); err != nil {
+		return s.reportError("", err)
+	}
+	stmtName, query, paramTypes, err := readParseMessage(msg)
+	if err != nil {
+		return err
+	}
+	s.reportActivity(stateRunning, query)
+
+	// 無名の文は新しい文で置き換える
+	if stmtName == "" {
+		s.dropPreparedStatement("")
+	}
+
+	ps, err := s.parsePreparedStatement(stmtName, query, paramTypes)
+	if err == nil {
+		if ps.query != nil {
+			s.jumbleQuery(ps.query, query)
+		}
+		err = s.storePreparedStatement(ps)
+	}
+	if err != nil {
+		return s.reportError(query, err)
+	}
+	return s.port.PutMessage(libpq.PqMsgParseComplete, nil)
+}
+
+// readParseMessage は Parse メッセージから文の名前、問い合わせ文字列、パラメータの型を読み取る。
+// 形式が壊れていれば ErrInvalidMessageFormat に当たるエラーを返す。
+func readParseMessage(msg *libpq.Message) (stmtName, query string, paramTypes []catalog.Oid, err error) {
+	if stmtName, err = msg.GetMsgString(); err != nil {
+		return "", "", nil, err
+	}
+	if query, err = msg.GetMsgString(); err != nil {
+		return "", "", nil, err
+	}
+	// 個数は符号なしの16ビット整数
+	numParams, err := msg.GetMsgInt16()
+	if err != nil {
+		return "", "", nil, err
+	}
+	paramTypes = make([]catalog.Oid, uint16(numParams))
+	for i := range paramTypes {
+		oid, err := msg.GetMsgInt32()
+		if err != nil {
+			return "", "", nil, err
+		}
+		paramTypes[i] = catalog.Oid(oid)
+	}
+	if err := msg.GetMsgEnd(); err != nil {
+		return "", "", nil, err
+	}
+	return stmtName, query, paramTypes, nil
+}
+
+// jumbleQuery は compute_query_id が問い合わせ ID の計算を求めていれば、解析済みの文の
+// 問い合わせ ID を計算する (parse_analyze_* の JumbleQuery の呼び出し相当)
+func (s *session) jumbleQuery(q *parser.Query, queryString string) {
+	if parser.IsQueryIdEnabled(s.gucs.GetEnum(guc.ComputeQueryId)) {
+		parser.JumbleQuery(q, queryString)
+	}
+}
+
+// parsePreparedStatement は問い合わせ文字列を解析してプリペアド文を作る。エラーになった
+// トランザクションブロックでは、ブロックを終える文のほかは解析しない。
+func (s *session) parsePreparedStatement(name, query string, paramTypes []catalog.Oid) (*preparedStatement, error) {
+	stmts, err := parser.RawParser(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(stmts) > 1 {
+		return nil, newError(errcodes.SyntaxError, "cannot insert multiple commands into a prepared statement")
+	}
+
+	ps := &preparedStatement{name: name, queryString: query, paramTypes: paramTypes}
+	if len(stmts) == 0 {
+		return ps, nil
+	}
+
+	if err := s.checkAbortedTransactionBlock(isTransactionExitStmt(stmts[0].Stmt)); err != nil {
+		return nil, err
+	}
+	q, types, err := parser.ParseAnalyzeVarparams(stmts[0], paramTypes)
+	if err != nil {
+		return nil, err
+	}
+	ps.query = q
+	ps.paramTypes = types
+	if executor.QueryReturnsTuples(q) {
+		ps.resultDesc = executor.ExecTypeFromTL(q.TargetList)
+	} else if q.CommandType == parser.CmdUtility {
+		ps.resultDesc = utilityTupleDescriptor(q.UtilityStmt)
+	}
+	return ps, nil
+}
+
+// processBind は Bind メッセージを処理する (exec_bind_message 相当)
+func (s *session) processBind(msg *libpq.Message) error {
+	s.doingExtendedQuery = true
+	s.commandTag = "BIND"
+	if err := s.startXactCommand(); err != nil {
+		return s.reportError("", err)
+	}
+	b, err := readBindMessage(msg)
+	if err != nil {
+		return err
+	}
+	ps, err := s.fetchPreparedStatement(b.stmtName)
+	if err != nil {
+		return s.reportError("", err)
+	}
+	// エラーになったトランザクションブロックでは、パラメータの値も変換しない
+	if err := s.checkAbortedTransactionBlock(ps.isTransactionExit() && len(b.values) == 0); err != nil {
+		return s.reportError(ps.queryString, err)
+	}
+
+	p, err := bindPortal(b.portalName, ps, b.paramFormats, b.values, b.resultFormats)
+	if err == nil {
+		ps.genericPlans++
+		// 無名のポータルは新しいポータルで置き換える
+		if b.portalName == "" {
+			s.dropPortal("")
+		}
+		err = s.createPortal(p)
+	}
+	if err != nil {
+		return s.reportError(ps.queryString, err)
+	}
+	return s.port.PutMessage(libpq.PqMsgBindComplete, nil)
+}
+
+// bindMessage は Bind メッセージの内容
+type bindMessage struct {
+	portalName    string
+	stmtName      string
+	paramFormats  []int16
+	values        [][]byte // NULL のパラメータは nil
+	resultFormats []int16
+}
+
+// readBindMessage は Bind メッセージを読み取る。形式が壊れていれば ErrInvalidMessageFormat に
+// 当たるエラーを返す。
+func readBindMessage(msg *libpq.Message) (*bindMessage, error) {
+	b := &bindMessage{}
+	var err error
+	if b.portalName, err = msg.GetMsgString(); err != nil {
+		return nil, err
+	}
+	if b.stmtName, err = msg.GetMsgString(); err != nil {
+		return nil, err
+	}
+	if b.paramFormats, err = getFormatCodes(msg); err != nil {
+		return nil, err
+	}
+	// 個数は符号なしの16ビット整数
+	numParams, err := msg.GetMsgInt16()
+	if err != nil {
+		return nil, err
+	}
+	b.values = make([][]byte, uint16(numParams))
+	for i := range b.values {
+		n, err := msg.GetMsgInt32()
+		if err != nil {
+			return nil, err
+		}
+		if n == -1 {
+			continue
+		}
+		if n < 0 {
+			return nil, libpq.ErrInvalidMessageFormat
+		}
+		if b.values[i], err = msg.GetMsgBytes(int(n)); err != nil {
+			return nil, err
+		}
+	}
+	if b.resultFormats, err = getFormatCodes(msg); err != nil {
+		return nil, err
+	}
+	if err := msg.GetMsgEnd(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// getFormatCodes は書式コードの個数とその並びを読み取る。
+func getFormatCodes(msg *libpq.Message) ([]int16, error) {
+	n, err := msg.GetMsgInt16()
+	if err != nil {
+		return nil, err
+	}
+	formats := make([]int16, uint16(n))
+	for i := range formats {
+		if formats[i], err = msg.GetMsgInt16(); err != nil {
+			return nil, err
+		}
+	}
+	return formats, nil
+}
+
+// expandFormatCodes は書式コードの並びを n 個分に展開する。書式コードは
+// 0 個 (全てテキスト)、1 個 (全てに同じ書式を使う)、n 個 のいずれかで指定される。
+// 個数が合わない場合は nil を返す。
+func expandFormatCodes(formats []int16, n int) []int16 {
+	out := make([]int16, n)
+	switch len(formats) {
+	case 0:
+	case 1:
+		for i := range out {
+			out[i] = formats[0]
+		}
+	case n:
+		copy(out, formats)
+	default:
+		return nil
+	}
+	return out
+}
+
+// bindPortal はパラメータの値を変換し、ポータルを作る。
+func bindPortal(name string, ps *preparedStatement, paramFormats []int16, values [][]byte,
+	resultFormats []int16) (*portal, error) {
+	if len(values) != len(ps.paramTypes) {
+		return nil, newError(errcodes.ProtocolViolation, "bind message supplies %d parameters, but prepared statement \"%s\" requires %d",
+			len(values), ps.name, len(ps.paramTypes))
+	}
+	pformats := expandFormatCodes(paramFormats, len(values))
+	if pformats == nil {
+		return nil, newError(errcodes.ProtocolViolation, "bind message has %d parameter formats but %d parameters",
+			len(paramFormats), len(values))
+	}
+
+	params := make(executor.ParamListInfo, len(values))
+	for i, v := range values {
+		typid := ps.paramTypes[i]
+		switch {
+		case v == nil:
+		case pformats[i] == 0:
+			d, err := adt.InputFunctionCall(typid, string(v))
+			if err != nil {
+				return nil, bindParamErrorContext(err, name, i)
+			}
+			params[i] = d
+		case pformats[i] == 1:
+			d, err := adt.ReceiveFunctionCall(typid, v)
+			if err != nil {
+				return nil, bindParamErrorContext(newError(errcodes.InvalidBinaryRepresentation,
+					"incorrect binary data format in bind parameter %d", i+1), name, i)
+			}
+			params[i] = d
+		default:
+			return nil, newError(errcodes.FeatureNotSupported, "unsupported format code: %d", pformats[i])
+		}
+		// ドメインの値は制約を確かめる (domain_in、domain_recv 相当)。NULL も NOT NULL を確かめる
+		if catalog.TypeIsDomain(typid) {
+			if err := executor.DomainCheck(params[i], typid, &executor.ExprContext{}); err != nil {
+				return nil, bindParamErrorContext(err, name, i)
+			}
+		}
+	}
+
+	rformats := expandFormatCodes(resultFormats, len(ps.resultDesc))
+	if rformats == nil {
+		return nil, newError(errcodes.ProtocolViolation, "bind message has %d result formats but query has %d columns",
+			len(resultFormats), len(ps.resultDesc))
+	}
+	for _, f := range rformats {
+		if f != 0 && f != 1 {
+			return nil, newError(errcodes.FeatureNotSupported, "unsupported format code: %d", f)
+		}
+	}
+
+	return &portal{name: name, stmt: ps, params: params, formats: rformats}, nil
+}
+
+// bindParamErrorContext は i 番目のパラメータの値を変換できなかったエラーに、どのパラメータかを
+// 表す CONTEXT を付ける (bind_param_error_callback 相当)。log_parameter_max_length_on_error が
+// ないため、値は含めない。
+func bindParamErrorContext(err error, portalName string, i int) error {
+	if portalName != "" {
+		return errutil.AddContext(err, "portal \"%s\" parameter $%d", portalName, i+1)
+	}
+	return errutil.AddContext(err, "unnamed portal parameter $%d", i+1)
+}
+
+// processExecute は Execute メッセージを処理する (exec_execute_message 相当)。
+// maxRows が正の場合は最大 maxRows 行を返し、行が残っていれば PortalSuspended を送る。
+func (s *session) processExecute(msg *libpq.Message) error {
+	s.doingExtendedQuery = true
+	if err := s.startXactCommand(); err != nil {
+		return s.reportError("", err)
+	}
+	portalName, err := msg.GetMsgString()
+	if err != nil {
+		return err
+	}
+	maxRows, err := msg.GetMsgInt32()
+	if err != nil {
+		return err
+	}
+	if err := msg.GetMsgEnd(); err != nil {
+		return err
+	}
+
+	p, err := s.getPortalByName(portalName)
+	if err != nil {
+		return s.reportError("", err)
+	}
+	if p.stmt.query == nil {
+		return s.port.PutMessage(libpq.PqMsgEmptyQueryResponse, nil)
+	}
+	s.activity = p.stmt.queryString
+	s.reportActivity(stateRunning, p.stmt.queryString)
+	s.commandTag = queryCommandTag(p.stmt.query)
+	isXactCommand := p.stmt.query.CommandType == parser.CmdUtility && isTransactionStmt(p.stmt.query.UtilityStmt)
+	if err := s.checkAbortedTransactionBlock(p.stmt.isTransactionExit()); err != nil {
+		return s.reportError(p.stmt.queryString, err)
+	}
+
+	// 最初の Execute で文の実行を始める
+	if !p.started() && p.status == portalReady {
+		if err := s.portalStart(p); err != nil {
+			return s.reportError(p.stmt.queryString, err)
+		}
+	}
+
+	count := uint64(0)
+	if maxRows > 0 {
+		count = uint64(maxRows)
+	}
+	tag, err := s.portalRun(p, count, newPrinttup(s.port, p.tupDesc(), p.formats, p.limit))
+	if err != nil {
+		return s.reportError(p.stmt.queryString, err)
+	}
+	if tag == "" {
+		return s.port.PutMessage(libpq.PqMsgPortalSuspended, nil)
+	}
+	// トランザクションを制御する文はその場でコマンドを終え、Sync を待たずに効かせる。それ以外の
+	// 文の後ではコマンド ID を進める
+	if isXactCommand {
+		err = s.finishXactCommand()
+	} else {
+		err = s.xact.CommandCounterIncrement()
+	}
+	if err != nil {
+		return s.reportError(p.stmt.queryString, err)
+	}
+	return sendCommandComplete(s.port, tag)
+}
+
+// processDescribe は Describe メッセージを処理する
+// (exec_describe_statement_message / exec_describe_portal_message 相当)
+func (s *session) processDescribe(msg *libpq.Message) error {
+	s.doingExtendedQuery = true
+	if err := s.startXactCommand(); err != nil {
+		return s.reportError("", err)
+	}
+	kind, err := msg.GetMsgByte()
+	if err != nil {
+		return err
+	}
+	name, err := msg.GetMsgString()
+	if err != nil {
+		return err
+	}
+	if err := msg.GetMsgEnd(); err != nil {
+		return err
+	}
+
+	switch kind {
+	case 'S':
+		ps, err := s.fetchPreparedStatement(name)
+		if err != nil {
+			return s.reportError("", err)
+		}
+		buf := libpq.BeginMessage(libpq.PqMsgParameterDescription)
+		buf.SendInt16(int16(len(ps.paramTypes)))
+		for _, t := range ps.paramTypes {
+			buf.SendInt32(int32(t))
+		}
+		if err := buf.EndMessage(s.port); err != nil {
+			return err
+		}
+		if ps.resultDesc == nil {
+			return s.port.PutMessage(libpq.PqMsgNoData, nil)
+		}
+		// 文の段階では結果の書式が決まっていないため、テキストとして記述する
+		return sendRowDescription(s.port, ps.resultDesc, nil)
+	case 'P':
+		p, err := s.getPortalByName(name)
+		if err != nil {
+			return s.reportError("", err)
+		}
+		if p.stmt.resultDesc == nil {
+			return s.port.PutMessage(libpq.PqMsgNoData, nil)
+		}
+		return sendRowDescription(s.port, p.stmt.resultDesc, p.formats)
+	}
+	return s.reportError("", newError(errcodes.ProtocolViolation, "invalid DESCRIBE message subtype %d", kind))
+}
+
+// processClose は Close メッセージを処理する。存在しない文やポータルを閉じてもエラーにしない。
+func (s *session) processClose(msg *libpq.Message) error {
+	s.doingExtendedQuery = true
+	kind, err := msg.GetMsgByte()
+	if err != nil {
+		return err
+	}
+	name, err := msg.GetMsgString()
+	if err != nil {
+		return err
+	}
+	if err := msg.GetMsgEnd(); err != nil {
+		return err
+	}
+
+	switch kind {
+	case 'S':
+		s.dropPreparedStatement(name)
+	case 'P':
+		s.dropPortal(name)
+	default:
+		return s.reportError("", newError(errcodes.ProtocolViolation, "invalid CLOSE message subtype %d", kind))
+	}
+	return s.port.PutMessage(libpq.PqMsgCloseComplete, nil)
+}
+
+// ----------------------------------------------------------------
+// 単一ユーザーモード (tcop/postgres.c の PostgresSingleUserMain、InteractiveBackend 相当)
+// ----------------------------------------------------------------
+// postmaster を起動せずに、1つのバックエンドがデータディレクトリを直接開き、標準入力から
+// 問い合わせを読んで実行する。結果は標準出力に、エラーは標準エラー出力に書く。
+// 他の接続を受け付けないため、復旧の作業などに使う。
+//
+// 問い合わせは改行で終わる。行末に "\" を書くと次の行に続く。useSemiNewlineNewline が
+// true の場合は、";" の後の空行で終わる (-j オプション相当)。
+
+// PostgresSingleUserMain は単一ユーザーモードのバックエンドを実行する (PostgresSingleUserMain 相当)。
+// データディレクトリは data_directory で指定する。dbname が空の場合は、起動した利用者の名前を
+// データベース名とする。echoQuery が true なら、実行する問い合わせを出力する (-E オプション相当)。
+// 入力の終わりまで戻らない。
+func PostgresSingleUserMain(dbname string, echoQuery, useSemiNewlineNewline bool, in io.Reader, out io.Writer) error {
+	if err := guc.SelectConfigFiles(); err != nil {
+		return err
+	}
+	dataDir := guc.DataDirectory.Get()
+	if dataDir == "" {
+		return fmt.Errorf("no data directory specified\nHINT:  Specify the data directory with -D.")
+	}
+	if err := miscadmin.CheckDataDir(dataDir); err != nil {
+		return err
+	}
+	startTime := time.Now()
+	if err := miscadmin.CreateDataDirLockFile(dataDir, false, startTime, guc.Port.Get()); err != nil {
+		return err
+	}
+	defer miscadmin.RemoveDataDirLockFile(dataDir)
+	if err := transam.ReadControlFile(dataDir); err != nil {
+		return err
+	}
+	// ファイル暗号化が有効なクラスタなら、リレーションのページを読み書きする前に鍵を読み込む
+	if err := crypto.InitializeFileEncryption(dataDir, guc.ClusterKeyFile.Get()); err != nil {
+		return err
+	}
+	if err := transam.StartupXLOG(dataDir); err != nil {
+		return err
+	}
+	if err := file.SetMaxSafeFds(); err != nil {
+		return err
+	}
+	// postmaster と同じく、このプロセスだけが使うセグメントを作る
+	if err := ipc.CreateSharedMemoryAndSemaphores(ipc.ShmemKey(dataDir, guc.Port.Get()), dataDir); err != nil {
+		return err
+	}
+	defer ipc.DestroySharedMemory()
+	// 共有バッファがリレーションのファイルを読み書きできるようにする (BaseInit の smgrinit 相当)
+	smgr.Init()
+	defer smgr.CloseAll()
+	if err := LoadBootstrapSuperuser(dataDir); err != nil {
+		return err
+	}
+	if dbname == "" {
+		u, err := user.Current()
+		if err != nil {
+			return fmt.Errorf("no database nor user name specified")
+		}
+		dbname = u.Username
+	}
+
+	s := newSession(nil)
+	s.whereToSendOutput = destDebug
+	s.out = out
+	s.startTime = startTime
+	defer s.procExit()
+	if err := s.initStandalone(dbname); err != nil {
+		return err
+	}
+
+	// SIGINT で実行中の文を取り消し、SIGTERM でセッションを終える
+	// (StatementCancelHandler、die 相当)
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+	go func() {
+		for sig := range sigc {
+			if sig == syscall.SIGTERM {
+				s.interrupts.SetProcDiePending()
+			} else {
+				s.interrupts.SetQueryCancelPending()
+			}
+		}
+	}()
+
+	fmt.Fprintf(out, "\nPostgreSQL stand-alone backend %s\n", pgconfig.PgVersion)
+	r := bufio.NewReader(in)
+	for {
+		if s.interrupts.ProcDiePending() {
+			return miscadmin.ErrProcDie
+		}
+		fmt.Fprintf(out, "backend> ")
+		query, ok := interactiveBackend(r, useSemiNewlineNewline)
+		if !ok {
+			// 入力の終わりで、端末の行を改め、シャットダウンのチェックポイントを行って終わる
+			// (ShutdownXLOG 相当)
+			fmt.Fprintf(out, "\n")
+			return transam.CreateCheckPoint(transam.CheckpointIsShutdown|transam.CheckpointImmediate, nil)
+		}
+		if echoQuery {
+			fmt.Fprintf(out, "statement: %s\n", query)
+		}
+		// 入力を待っている間に届いたキャンセル要求は無視する
+		s.interrupts.ClearQueryCancelPending()
+		if err := s.execSimpleQuery(query); err != nil {
+			return err
+		}
+	}
+}
+
+// interactiveBackend は標準入力から問い合わせを1つ読む (InteractiveBackend 相当)。
+// 何も読まずに入力が終わった場合は false を返す。
+func interactiveBackend(r *bufio.Reader, useSemiNewlineNewline bool) (string, bool) {
+	var buf []byte
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return string(buf), len(buf) > 0
+		}
+		if c == '\n' {
+			if useSemiNewlineNewline {
+				// ";" の後の空行で問い合わせが終わる。2つ目の改行は捨てる
+				if len(buf) > 1 && buf[len(buf)-1] == '\n' && buf[len(buf)-2] == ';' {
+					return string(buf), true
+				}
+			} else {
+				// 行末の "\" は次の行に続くことを表す。"\" と改行は捨てる
+				if len(buf) > 0 && buf[len(buf)-1] == '\\' {
+					buf = buf[:len(buf)-1]
+					continue
+				}
+				buf = append(buf, '\n')
+				return string(buf), true
+			}
+		}
+		buf = append(buf, c)
+	}
+}
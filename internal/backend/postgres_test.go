@@ -0,0 +1,117 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+)
+
+// frontendMessage は種別バイトと長さを先頭に付けたメッセージを作る。
+func frontendMessage(msgtype byte, body []byte) []byte {
+	p := append([]byte{msgtype}, binary.BigEndian.AppendUint32(nil, uint32(4+len(body)))...)
+	return append(p, body...)
+}
+
+// FuzzFrontendMessage は任意のバイト列を readCommand でメッセージに分け、Parse と Bind の
+// メッセージを読み取っても panic しないことを確かめる。読み取りに失敗するのは形式が壊れている
+// 場合だけで、読み取れたメッセージは組み立て直すと元の本体に戻る。
+func FuzzFrontendMessage(f *testing.F) {
+	parse := libpq.BeginMessage(libpq.PqMsgParse)
+	parse.SendString("s1")
+	parse.SendString("SELECT $1")
+	parse.SendInt16(1)
+	parse.SendInt32(23)
+	f.Add(frontendMessage(libpq.PqMsgParse, parse.Bytes()))
+
+	bind := libpq.BeginMessage(libpq.PqMsgBind)
+	bind.SendString("")
+	bind.SendString("s1")
+	bind.SendInt16(1)
+	bind.SendInt16(1)
+	bind.SendInt16(2)
+	bind.SendCountedText([]byte("42"))
+	bind.SendInt32(-1)
+	bind.SendInt16(0)
+	f.Add(frontendMessage(libpq.PqMsgBind, bind.Bytes()))
+
+	// 個数 0xFFFF を符号付きで読むと make の長さが負になって panic していた (e65f541)
+	f.Add(frontendMessage(libpq.PqMsgParse, []byte("\x00SELECT 1\x00\xff\xff")))
+	f.Add(frontendMessage(libpq.PqMsgBind, []byte("\x00\x00\xff\xff")))
+	f.Add(frontendMessage(libpq.PqMsgBind, []byte("\x00\x00\x00\x00\xff\xff")))
+	f.Add(frontendMessage(libpq.PqMsgBind, []byte("\x00\x00\x00\x00\x00\x01\xff\xff\xff\xfe\x00\x00")))
+	f.Add(append(frontendMessage(libpq.PqMsgSync, nil), 'P', 0xff, 0xff, 0xff, 0xff))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		port := newFakePort(data)
+		for off := 0; ; {
+			firstchar, msg, err := readCommand(port)
+			if err != nil {
+				return
+			}
+			// 読み取れたメッセージの本体は data の中にそのまま並んでいる
+			n := int(binary.BigEndian.Uint32(data[off+1:]))
+			body := data[off+5 : off+1+n]
+			off += 1 + n
+			switch firstchar {
+			case libpq.PqMsgParse:
+				stmtName, query, paramTypes, err := readParseMessage(msg)
+				if err != nil {
+					checkMessageFormatError(t, err)
+					continue
+				}
+				buf := libpq.BeginMessage(libpq.PqMsgParse)
+				buf.SendString(stmtName)
+				buf.SendString(query)
+				buf.SendInt16(int16(len(paramTypes)))
+				for _, oid := range paramTypes {
+					buf.SendInt32(int32(oid))
+				}
+				checkRoundTrip(t, body, buf.Bytes())
+			case libpq.PqMsgBind:
+				b, err := readBindMessage(msg)
+				if err != nil {
+					checkMessageFormatError(t, err)
+					continue
+				}
+				buf := libpq.BeginMessage(libpq.PqMsgBind)
+				buf.SendString(b.portalName)
+				buf.SendString(b.stmtName)
+				sendFormatCodes(buf, b.paramFormats)
+				buf.SendInt16(int16(len(b.values)))
+				for _, v := range b.values {
+					if v == nil {
+						buf.SendInt32(-1)
+					} else {
+						buf.SendCountedText(v)
+					}
+				}
+				sendFormatCodes(buf, b.resultFormats)
+				checkRoundTrip(t, body, buf.Bytes())
+			}
+		}
+	})
+}
+
+func sendFormatCodes(buf *libpq.Buffer, formats []int16) {
+	buf.SendInt16(int16(len(formats)))
+	for _, f := range formats {
+		buf.SendInt16(f)
+	}
+}
+
+func checkMessageFormatError(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, libpq.ErrInvalidMessageFormat) {
+		t.Fatalf("malformed message: got %v, want invalid message format", err)
+	}
+}
+
+func checkRoundTrip(t *testing.T, body, rebuilt []byte) {
+	t.Helper()
+	if !bytes.Equal(body, rebuilt) {
+		t.Fatalf("message did not round-trip:\n got  %x\n want %x", rebuilt, body)
+	}
+}
@@ -0,0 +1,338 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/common/relpath"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/buffer"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/file"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// セッションの初期化と終了 (utils/init/postinit.c の InitPostgres, storage/ipc/ipc.c の proc_exit 相当)
+// ----------------------------------------------------------------
+// スタートアップパケットを処理した後、バックエンドを一覧に登録し、接続先のデータベースと
+// ユーザーを決め、セッションの実行時パラメータを設定してからメインループに入る。
+// 終了時には登録した後始末を逆順に実行する。FATAL で終わる場合も、クライアントが
+// 切断した場合も同じ経路を通る。
+
+// initPostgres はセッションを初期化し、クライアントにクエリを受け付けられることを通知する
+// (InitPostgres 相当)。エラーはクライアントに FATAL として報告する。
+func (s *session) initPostgres() error {
+	pid, cancelKey, err := registerBackend(procClient, s.interrupts, s.waitEvent)
+	if err != nil {
+		return err
+	}
+	s.pid, s.cancelKey = pid, cancelKey
+	s.logProc.Pid = pid
+	s.onExit(func() { unregisterBackend(pid) })
+	s.initTempFiles()
+	s.initLockProc()
+	s.initXact()
+
+	// データベースのカタログはまだ存在しないため、データベースの存在は確認できない。
+	// スタートアップパケットで指定されたものをそのまま使う。
+	s.databaseName = s.port.DatabaseName
+	s.userName = s.port.UserName
+	s.sessionUserName = s.userName
+	s.logProc.DatabaseName, s.logProc.UserName = s.databaseName, s.userName
+
+	if err := clientAuthentication(s.logProc, s.port); err != nil {
+		if !errors.Is(err, libpq.ErrConnectionClosed) && s.authTimeout.aborted() == nil {
+			logAuthenticationFailure(s.logProc, s.port)
+		}
+		return err
+	}
+	if err := s.authTimeout.disable(); err != nil {
+		return err
+	}
+	if guc.LogConnections.Get() {
+		logConnectionAuthorized(s.logProc, s.port)
+	}
+	// ロールがログインできるかを確かめる (InitializeSessionUserId 相当)
+	role, ok := catalog.SearchRole(s.userName)
+	if !ok {
+		return newError(errcodes.InvalidAuthorizationSpecification, "role \"%s\" does not exist", s.userName)
+	}
+	if !role.Rolcanlogin {
+		return newError(errcodes.InvalidAuthorizationSpecification, "role \"%s\" is not permitted to log in", s.userName)
+	}
+	if err := checkReservedConnections(s.userName); err != nil {
+		return err
+	}
+	setBackendRole(pid, s.userName)
+	// 自分自身も数に含めて、ロールごとの接続数の上限を確かめる
+	if role.Rolconnlimit >= 0 && !role.Rolsuper && countUserBackends(s.userName) > int(role.Rolconnlimit) {
+		return newError(errcodes.TooManyConnections, "too many connections for role \"%s\"", s.userName)
+	}
+	setBackendSSLStatus(pid, s.port)
+	s.pgstatBestart()
+	s.pgstat.SetDatabase(catalog.GetDatabaseOid(s.databaseName))
+	// セッションの終了時に、まだ共有の統計に加えていない回数を加える (pgstat_shutdown_hook 相当)
+	s.onExit(s.pgstat.ReportStat)
+
+	// サーバーが決めるパラメータを設定する
+	superuser := catalog.IsSuperuser(s.userName)
+	if err := s.gucs.SetConfigOption("session_authorization", s.userName, guc.PGCInternal, guc.PGCSOverride, guc.GucActionSet); err != nil {
+		return err
+	}
+	if err := s.gucs.SetConfigOption("is_superuser", boolString(superuser), guc.PGCInternal, guc.PGCSOverride, guc.GucActionSet); err != nil {
+		return err
+	}
+
+	// データベースとロールごとの設定は、スタートアップパケットで指定されたものより優先しない
+	if err := s.processSettings(role.Oid); err != nil {
+		return err
+	}
+
+	// options で指定されたものより、スタートアップパケットで個別に指定されたものを優先する
+	// (process_startup_options 相当)
+	opts, err := pgSplitOpts(s.port.CmdlineOptions)
+	if err != nil {
+		return err
+	}
+	gucctx := guc.PGCBackend
+	if superuser {
+		gucctx = guc.PGCSuBackend
+	}
+	for _, o := range append(opts, s.port.GUCOptions...) {
+		if err := s.gucs.SetConfigOption(o[0], o[1], gucctx, guc.PGCSClient, guc.GucActionSet); err != nil {
+			return err
+		}
+	}
+
+	if err := s.reportChangedGUCOptions(); err != nil {
+		return err
+	}
+	return sendBackendKeyData(s.port, s.pid, s.cancelKey)
+}
+
+// initStandalone は単一ユーザーモードのセッションを初期化する (InitPostgres の !IsUnderPostmaster の
+// 処理相当)。認証はせず、セッションのユーザーはブートストラップスーパーユーザーにする
+// (InitializeSessionUserIdStandalone 相当)。
+func (s *session) initStandalone(dbname string) error {
+	pid, cancelKey, err := registerBackend(procClient, s.interrupts, s.waitEvent)
+	if err != nil {
+		return err
+	}
+	s.pid, s.cancelKey = pid, cancelKey
+	s.logProc.Pid = pid
+	s.onExit(func() { unregisterBackend(pid) })
+	s.initTempFiles()
+	s.initLockProc()
+	s.initXact()
+
+	role, ok := catalog.SearchRoleByOid(catalog.BootstrapSuperuserID)
+	if !ok {
+		return newError(errcodes.InvalidAuthorizationSpecification, "role with OID %d does not exist", catalog.BootstrapSuperuserID)
+	}
+	s.databaseName = dbname
+	s.userName = role.Rolname
+	s.sessionUserName = s.userName
+	s.logProc.DatabaseName, s.logProc.UserName = s.databaseName, s.userName
+	setBackendRole(pid, s.userName)
+	s.pgstatBestart()
+	s.pgstat.SetDatabase(catalog.GetDatabaseOid(s.databaseName))
+	s.onExit(s.pgstat.ReportStat)
+
+	if err := s.gucs.SetConfigOption("session_authorization", s.userName, guc.PGCInternal, guc.PGCSOverride, guc.GucActionSet); err != nil {
+		return err
+	}
+	if err := s.gucs.SetConfigOption("is_superuser", "on", guc.PGCInternal, guc.PGCSOverride, guc.GucActionSet); err != nil {
+		return err
+	}
+	return s.processSettings(role.Oid)
+}
+
+// LoadBootstrapSuperuser は pg_authid からブートストラップスーパーユーザーの名前を読み、
+// ロールとして登録する
+func LoadBootstrapSuperuser(dataDir string) error {
+	path := filepath.Join(dataDir, relpath.GetRelationPath(catalog.InvalidOid, catalog.AuthIDRelationID))
+	tuples, err := catalog.ReadCatalogFile(path, catalog.NattsPgAuthid)
+	if err != nil {
+		return err
+	}
+	want := strconv.FormatUint(uint64(catalog.BootstrapSuperuserID), 10)
+	for _, tup := range tuples {
+		oid, rolname := tup[catalog.AnumPgAuthidOid-1], tup[catalog.AnumPgAuthidRolname-1]
+		if oid != nil && *oid == want && rolname != nil {
+			catalog.SetBootstrapSuperuser(*rolname)
+			return nil
+		}
+	}
+	return fmt.Errorf("bootstrap superuser with OID %d not found in \"%s\"", catalog.BootstrapSuperuserID, path)
+}
+
+// pgSplitOpts はスタートアップパケットの options を "-c 名前=値" と "--名前=値" の並びとして
+// 解析する (pg_split_opts, process_postgres_switches 相当)。空白はバックスラッシュで
+// エスケープできる。
+func pgSplitOpts(optstr string) ([][2]string, error) {
+	var words []string
+	var cur strings.Builder
+	inWord, escaped := false, false
+	for _, c := range optstr {
+		switch {
+		case escaped:
+			cur.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			inWord, escaped = true, true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+
+	var opts [][2]string
+	for i := 0; i < len(words); i++ {
+		var setting string
+		switch w := words[i]; {
+		case w == "-c" && i+1 < len(words):
+			i++
+			setting = words[i]
+		case strings.HasPrefix(w, "-c") && len(w) > 2:
+			setting = w[2:]
+		case strings.HasPrefix(w, "--") && len(w) > 2:
+			setting = w[2:]
+		default:
+			return nil, newError(errcodes.SyntaxError, "invalid command-line argument for server process: %s", w)
+		}
+		name, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			if strings.HasPrefix(words[i], "--") {
+				return nil, newError(errcodes.SyntaxError, "--%s requires a value", setting)
+			}
+			return nil, newError(errcodes.SyntaxError, "-c %s requires a value", setting)
+		}
+		// 名前の中の "-" は "_" と同じ扱い (ParseLongOption 相当)
+		opts = append(opts, [2]string{strings.ReplaceAll(name, "-", "_"), value})
+	}
+	return opts, nil
+}
+
+// onExit はセッションの終了時に実行する後始末を登録する (on_proc_exit 相当)
+func (s *session) onExit(fn func()) {
+	s.exitCallbacks = append(s.exitCallbacks, fn)
+}
+
+// initTempFiles はセッションの一時ファイルの管理を始め、終了時に残った一時ファイルを削除する
+// 後始末を登録する (InitFileAccess と AtProcExit_Files の登録相当)
+func (s *session) initTempFiles() {
+	s.tempFiles = file.NewTempFiles(s.pid, func() int { return s.gucs.GetInt(guc.TempFileLimit) }, s.reportTempFileUsage)
+	s.onExit(s.tempFiles.CleanupAll)
+}
+
+// reportTempFileUsage は閉じた一時ファイルを統計に数え、大きさが log_temp_files 以上ならログに
+// 出す (ReportTemporaryFileUsage 相当)。実行中の問い合わせを STATEMENT として添える。
+func (s *session) reportTempFileUsage(path string, size int64) {
+	if s.gucs.GetBool(guc.TrackCounts) {
+		s.pgstat.ReportTempFile(size)
+	}
+	if threshold := s.gucs.GetInt(guc.LogTempFiles); threshold >= 0 && size/1024 >= int64(threshold) {
+		edata := errutil.Elog(errutil.Log, "temporary file: path \"%s\", size %d", path, size)
+		_ = errutil.EmitErrorReport(s.logProc, s.gucs, s.port, edata, s.activity)
+	}
+}
+
+// initLockProc はセッションが重いロックと共有バッファを使えるようにし、終了時に残ったロックと
+// バッファのピンを全て放す後始末を登録する (InitProcess, InitBufferPoolAccess と、ProcKill の
+// LockReleaseAll、AtProcExit_Buffers 相当)
+func (s *session) initLockProc() {
+	s.lockProc = lmgr.NewProc(s.pid, s.interrupts, s.waitEvent, s.gucs, s.logProc)
+	s.buffers = buffer.NewBackend(s.lockProc, s.waitEvent, s.pgstat)
+	s.onExit(func() {
+		s.lockProc.LWLockReleaseAll()
+		s.buffers.ReleaseAll()
+		s.lockProc.LockReleaseAll(true)
+	})
+}
+
+// procExit は登録した後始末を登録と逆の順に実行する (proc_exit 相当)
+func (s *session) procExit() {
+	for i := len(s.exitCallbacks) - 1; i >= 0; i-- {
+		s.exitCallbacks[i]()
+	}
+	s.exitCallbacks = nil
+}
+
+// logConnectionAuthorized は認証に成功した接続をサーバーログに出力する
+// (PerformAuthentication の log_connections の処理相当)
+func logConnectionAuthorized(proc *errutil.ProcInfo, port *libpq.Port) {
+	msg := fmt.Sprintf("connection authorized: user=%s database=%s", port.UserName, port.DatabaseName)
+	if port.ApplicationName != "" {
+		msg += fmt.Sprintf(" application_name=%s", port.ApplicationName)
+	}
+	if port.SSLInUse {
+		msg += fmt.Sprintf(" SSL enabled (protocol=%s, cipher=%s, bits=%d)",
+			port.SSLVersion(), port.SSLCipher(), port.SSLBits())
+	}
+	errutil.Report(proc, errutil.Elog(errutil.Log, "%s", msg))
+}
+
+// boolString は真偽値をパラメータの値の綴りにする
+func boolString(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// sendAuthenticationOk は認証の成功を通知する
+func sendAuthenticationOk(port *libpq.Port) error {
+	buf := libpq.BeginMessage(libpq.PqMsgAuthenticationRequest)
+	buf.SendInt32(libpq.AuthReqOk)
+	return buf.EndMessage(port)
+}
+
+// reportChangedGUCOptions は、最後に通知してから値が変わった GUC_REPORT のパラメータを
+// ParameterStatus でクライアントに通知する (ReportChangedGUCOptions 相当)。
+// 接続の開始時には全てを通知する。単一ユーザーモードでは通知する相手がいない (reporting_enabled 相当)。
+func (s *session) reportChangedGUCOptions() error {
+	if s.whereToSendOutput != destRemote {
+		return nil
+	}
+	for _, g := range guc.Variables() {
+		if g.Flags&guc.GucReport == 0 {
+			continue
+		}
+		value, err := s.gucs.GetConfigOption(g.Name)
+		if err != nil {
+			return err
+		}
+		if old, ok := s.reportedGUCs[g.Name]; ok && old == value {
+			continue
+		}
+		s.reportedGUCs[g.Name] = value
+		if err := sendParameterStatus(s.port, g.Name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendParameterStatus は ParameterStatus メッセージを送る (ReportGUCOption 相当)
+func sendParameterStatus(port *libpq.Port, name, value string) error {
+	buf := libpq.BeginMessage(libpq.PqMsgParameterStatus)
+	buf.SendString(name)
+	buf.SendString(value)
+	return buf.EndMessage(port)
+}
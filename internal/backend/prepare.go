@@ -0,0 +1,122 @@
+package backend
+
+import (
+	"sort"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/executor"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+)
+
+// ----------------------------------------------------------------
+// プリペアド文 (commands/prepare.c, utils/cache/plancache.c 相当)
+// ----------------------------------------------------------------
+// Parse メッセージで作られた文を、名前を付けてセッション内に保持する。
+// 名前が空文字列の文は「無名の文」で、次の Parse や単純問い合わせで置き換えられる。
+//
+// pg_prepared_statements は名前の付いた文を1行ずつ返す。無名の文は含めない。
+// SQL の PREPARE はまだないため、from_sql は常に false になる。
+
+func init() {
+	fmgr.RegisterSetReturning("pg_prepared_statement", pgPreparedStatement)
+}
+
+// preparedStatement は解析済みの文 (PreparedStatement / CachedPlanSource 相当)
+type preparedStatement struct {
+	name        string
+	queryString string
+	// query が nil の場合は空の問い合わせを表す
+	query      *parser.Query
+	paramTypes []catalog.Oid
+	// resultDesc は結果行の列定義。行を返さない文の場合は nil。
+	resultDesc executor.TupleDesc
+	// prepareTime は文を登録した時刻
+	prepareTime adt.TimestampTz
+	// genericPlans は Bind で文を実行可能にした回数 (num_generic_plans 相当)。プランナーを
+	// 持たず、パラメータの値によらず同じ解析結果を使うため、全て汎用計画として数える。
+	genericPlans int64
+}
+
+// isTransactionExit は文がトランザクションブロックを終える文かを返す (IsTransactionExitStmt 相当)。
+// 空の問い合わせは終える文ではない。
+func (ps *preparedStatement) isTransactionExit() bool {
+	return ps.query != nil && ps.query.CommandType == parser.CmdUtility && isTransactionExitStmt(ps.query.UtilityStmt)
+}
+
+// storePreparedStatement は文を登録する (StorePreparedStatement 相当)
+func (s *session) storePreparedStatement(ps *preparedStatement) error {
+	if ps.name != "" {
+		if _, ok := s.preparedStatements[ps.name]; ok {
+			return newError(errcodes.DuplicatePstatement, "prepared statement \"%s\" already exists", ps.name)
+		}
+	}
+	ps.prepareTime = adt.GetCurrentTimestamp()
+	s.preparedStatements[ps.name] = ps
+	return nil
+}
+
+// fetchPreparedStatement は名前で文を探す (FetchPreparedStatement 相当)
+func (s *session) fetchPreparedStatement(name string) (*preparedStatement, error) {
+	ps, ok := s.preparedStatements[name]
+	if !ok {
+		if name == "" {
+			return nil, newError(errcodes.UndefinedPstatement, "unnamed prepared statement does not exist")
+		}
+		return nil, newError(errcodes.UndefinedPstatement, "prepared statement \"%s\" does not exist", name)
+	}
+	return ps, nil
+}
+
+// dropPreparedStatement は文を削除する (DropPreparedStatement 相当)。存在しなくてもエラーにしない。
+func (s *session) dropPreparedStatement(name string) {
+	delete(s.preparedStatements, name)
+}
+
+// pgPreparedStatement は pg_prepared_statements の行を名前の順に返す (pg_prepared_statement 相当)
+func pgPreparedStatement(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]adt.Datum
+	for name, ps := range s.preparedStatements {
+		if name == "" {
+			continue
+		}
+		row := make([]adt.Datum, 8)
+		row[0] = name
+		row[1] = ps.queryString
+		row[2] = ps.prepareTime
+		row[3] = regtypeArray(ps.paramTypes)
+		if ps.resultDesc != nil {
+			types := make([]catalog.Oid, len(ps.resultDesc))
+			for i, attr := range ps.resultDesc {
+				types[i] = attr.TypeID
+			}
+			row[4] = regtypeArray(types)
+		}
+		row[5] = false
+		row[6] = ps.genericPlans
+		row[7] = int64(0)
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0].(string) < rows[j][0].(string) })
+	return rows, nil
+}
+
+// regtypeArray は型の OID の並びを regtype[] の値にする (build_regtype_array 相当)
+func regtypeArray(types []catalog.Oid) *adt.Array {
+	arr := &adt.Array{ElemType: catalog.REGTYPEOID}
+	if len(types) == 0 {
+		return arr
+	}
+	arr.Dims = []int{len(types)}
+	arr.LBounds = []int{1}
+	for _, t := range types {
+		arr.Elems = append(arr.Elems, adt.RegType(t))
+	}
+	return arr
+}
@@ -0,0 +1,165 @@
+package backend
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/executor"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// 結果行のクライアントへの送信 (access/common/printtup.c 相当)
+// ----------------------------------------------------------------
+// 列ごとの書式コードは 0 がテキスト形式、1 がバイナリ形式を表す。
+// 書式コードの並びが nil の場合は全ての列をテキスト形式で送る。
+
+// sendRowDescription は RowDescription メッセージを送る (SendRowDescriptionMessage 相当)
+func sendRowDescription(port *libpq.Port, desc executor.TupleDesc, formats []int16) error {
+	buf := libpq.BeginMessage(libpq.PqMsgRowDescription)
+	buf.SendInt16(int16(len(desc)))
+	for i, att := range desc {
+		buf.SendString(att.Name)
+		buf.SendInt32(0) // テーブルの OID
+		buf.SendInt16(0) // 列番号
+		buf.SendInt32(int32(att.TypeID))
+		buf.SendInt16(catalog.TypeLen(att.TypeID))
+		buf.SendInt32(att.TypMod)
+		buf.SendInt16(formatCode(formats, i))
+	}
+	return buf.EndMessage(port)
+}
+
+// printtupReceiver は結果行を DataRow メッセージとしてクライアントに送る (DR_printtup 相当)。
+// メッセージを組み立てるバッファは行ごとに使い回す。送信バッファが一杯になると
+// クライアントが読むまで送信で待つため、遅いクライアントに対して行が溜まり続けることはない。
+type printtupReceiver struct {
+	port    *libpq.Port
+	desc    executor.TupleDesc
+	formats []int16
+	limit   *resultLimit
+	buf     *libpq.Buffer
+}
+
+// newPrinttup は結果行をクライアントに送る DestReceiver を作る (printtup_create_DR 相当)。
+// limit は結果の制限で、nil なら制限しない。
+func newPrinttup(port *libpq.Port, desc executor.TupleDesc, formats []int16, limit *resultLimit) *printtupReceiver {
+	return &printtupReceiver{port: port, desc: desc, formats: formats, limit: limit, buf: libpq.BeginMessage(libpq.PqMsgDataRow)}
+}
+
+// ReceiveSlot は1行分の DataRow メッセージを送る (printtup 相当)
+func (r *printtupReceiver) ReceiveSlot(row []adt.Datum) error {
+	buf := r.buf
+	buf.Reset(libpq.PqMsgDataRow)
+	buf.SendInt16(int16(len(row)))
+	for i, d := range row {
+		if d == nil {
+			buf.SendInt32(-1)
+			continue
+		}
+		if formatCode(r.formats, i) == 1 {
+			b, err := adt.SendFunctionCall(r.desc[i].TypeID, d)
+			if err != nil {
+				return err
+			}
+			buf.SendCountedText(b)
+			continue
+		}
+		buf.SendCountedText([]byte(adt.OutputFunctionCall(r.desc[i].TypeID, d)))
+	}
+	// 制限を超える行は送らずに文をエラーにする
+	if err := r.limit.add(len(buf.Bytes())); err != nil {
+		return err
+	}
+	if err := buf.EndMessage(r.port); err != nil {
+		return &sendFailure{err: err}
+	}
+	return nil
+}
+
+// sendFailure は結果行の送信に失敗したことを表す。文のエラーとしては報告せず、接続を切る。
+type sendFailure struct {
+	err error
+}
+
+func (e *sendFailure) Error() string { return e.err.Error() }
+func (e *sendFailure) Unwrap() error { return e.err }
+
+// formatCode は i 番目の列の書式コードを返す。
+func formatCode(formats []int16, i int) int16 {
+	if formats == nil {
+		return 0
+	}
+	return formats[i]
+}
+
+// sendCommandComplete は CommandComplete メッセージを送る (EndCommand 相当)
+func sendCommandComplete(port *libpq.Port, tag string) error {
+	buf := libpq.BeginMessage(libpq.PqMsgCommandComplete)
+	buf.SendString(tag)
+	return buf.EndMessage(port)
+}
+
+// ----------------------------------------------------------------
+// 単一ユーザーモードでの結果行の出力 (printtup.c の debugStartup、debugtup 相当)
+// ----------------------------------------------------------------
+// 列ごとに1行ずつ、列番号と列名、値、型の情報を書き、行の終わりに "----" を書く。
+// NULL の列は書かない。
+
+// debugStartup は列の情報を書く (debugStartup 相当)
+func debugStartup(out io.Writer, desc executor.TupleDesc) {
+	for i, att := range desc {
+		printatt(out, i+1, att, nil)
+	}
+	fmt.Fprintf(out, "\t----\n")
+}
+
+// debugtupReceiver は結果行を標準出力に書く DestReceiver (debugtup 相当)
+type debugtupReceiver struct {
+	out   io.Writer
+	desc  executor.TupleDesc
+	limit *resultLimit
+}
+
+// ReceiveSlot は1行分の値を書く (debugtup 相当)。結果の大きさは値のテキスト表現の長さで数える。
+func (r *debugtupReceiver) ReceiveSlot(row []adt.Datum) error {
+	values := make([]*string, len(row))
+	size := 0
+	for i, d := range row {
+		if d == nil {
+			continue
+		}
+		value := adt.OutputFunctionCall(r.desc[i].TypeID, d)
+		values[i] = &value
+		size += len(value)
+	}
+	if err := r.limit.add(size); err != nil {
+		return err
+	}
+	for i, value := range values {
+		if value != nil {
+			printatt(r.out, i+1, r.desc[i], value)
+		}
+	}
+	fmt.Fprintf(r.out, "\t----\n")
+	return nil
+}
+
+// printatt は列1つ分を書く (printatt 相当)。value が nil なら値を書かない。
+func printatt(out io.Writer, attributeID int, att executor.Attribute, value *string) {
+	shown := ""
+	if value != nil {
+		shown = fmt.Sprintf(" = \"%s\"", *value)
+	}
+	typlen := catalog.TypeLen(att.TypeID)
+	// 値渡しの型は長さが 1、2、4、8 バイトの固定長の型 (pg_type.typbyval 相当)
+	byval := 'f'
+	switch typlen {
+	case 1, 2, 4, 8:
+		byval = 't'
+	}
+	fmt.Fprintf(out, "\t%2d: %s%s\t(typeid = %d, len = %d, typmod = %d, byval = %c)\n",
+		attributeID, att.Name, shown, att.TypeID, typlen, att.TypMod, byval)
+}
@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/ipc"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+)
+
+// ----------------------------------------------------------------
+// 共有メモリの割り当ての一覧 (storage/ipc/shmem.c の pg_get_shmem_allocations 相当)
+// ----------------------------------------------------------------
+// pg_shmem_allocations は postmaster が作った共有メモリのセグメントの割り当てを、名前を付けた
+// 割り当てごとに1行、名前のない割り当ての合計を <anonymous> の1行、まだ割り当てていない部分を
+// name が NULL の1行として返す。pg_read_all_stats の権限を持つロールだけが参照できる。
+
+func init() {
+	fmgr.RegisterSetReturning("pg_get_shmem_allocations", pgGetShmemAllocations)
+}
+
+// pgGetShmemAllocations は pg_shmem_allocations の行を返す (pg_get_shmem_allocations 相当)
+func pgGetShmemAllocations(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	if !catalog.HasPrivsOfRole(ctx.UserName(), catalog.RolePgReadAllStats) {
+		return nil, newError(errcodes.InsufficientPrivilege, "permission denied for view pg_shmem_allocations")
+	}
+	allocs, err := ipc.GetShmemAllocations()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]adt.Datum, 0, len(allocs))
+	for _, a := range allocs {
+		row := []adt.Datum{nil, nil, a.Size, a.AllocatedSize}
+		if !a.Free {
+			row[0] = a.Name
+		}
+		if a.Off >= 0 {
+			row[1] = a.Off
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,168 @@
+package backend
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/postmaster/syslogger"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+)
+
+// ----------------------------------------------------------------
+// バックエンドへの合図を送る関数 (storage/ipc/signalfuncs.c, utils/adt/misc.c 相当)
+// ----------------------------------------------------------------
+// 他のバックエンドの問い合わせを取り消し、セッションを終了させる。
+// 対象と同じロールの権限を持つか、pg_signal_backend の権限を持つロールが実行できる。
+// スーパーユーザーのバックエンドには、スーパーユーザーしか合図を送れない。
+
+func init() {
+	fmgr.Register("pg_backend_pid", pgBackendPid)
+	fmgr.Register("pg_cancel_backend", pgCancelBackend)
+	fmgr.Register("pg_terminate_backend", pgTerminateBackend)
+	fmgr.Register("pg_reload_conf", pgReloadConf)
+	fmgr.Register("pg_rotate_logfile", pgRotateLogfile)
+}
+
+// signalResult は pgSignalBackend の結果 (SIGNAL_BACKEND_* 相当)
+type signalResult int
+
+const (
+	signalBackendSuccess signalResult = iota
+	signalBackendError
+	signalBackendNoPermission
+	signalBackendNoSuperuser
+)
+
+// pgSignalBackend は pid のバックエンドに合図を送ってよいかを確かめ、送る (pg_signal_backend 相当)
+func pgSignalBackend(ctx fmgr.CallContext, pid int32, terminate bool) signalResult {
+	backendList.Lock()
+	entry, ok := backendList.entries[pid]
+	backendList.Unlock()
+	if !ok {
+		ctx.Warning(fmt.Sprintf("PID %d is not a PostgreSQL backend process", pid))
+		return signalBackendError
+	}
+
+	// 認証の前のバックエンドと、スーパーユーザーのバックエンドにはスーパーユーザーしか送れない
+	user := ctx.UserName()
+	if (entry.role == "" || catalog.IsSuperuser(entry.role)) && !catalog.IsSuperuser(user) {
+		return signalBackendNoSuperuser
+	}
+	if !catalog.HasPrivsOfRole(user, entry.role) && !catalog.HasPrivsOfRole(user, catalog.RolePgSignalBackend) {
+		return signalBackendNoPermission
+	}
+
+	if terminate {
+		entry.interrupts.SetProcDiePending()
+	} else {
+		entry.interrupts.SetQueryCancelPending()
+	}
+	return signalBackendSuccess
+}
+
+func callerContext(fcinfo *fmgr.FunctionCallInfo) (fmgr.CallContext, error) {
+	if fcinfo.Context == nil {
+		return nil, newError(errcodes.FeatureNotSupported, "function cannot be called outside a session")
+	}
+	return fcinfo.Context, nil
+}
+
+// callerSession は関数を呼び出したセッションを返す。セッションの状態 (プリペアド文など) を
+// 返す関数で使う。
+func callerSession(fcinfo *fmgr.FunctionCallInfo) (*session, error) {
+	s, ok := fcinfo.Context.(*session)
+	if !ok {
+		return nil, newError(errcodes.FeatureNotSupported, "function cannot be called outside a session")
+	}
+	return s, nil
+}
+
+// pgBackendPid は現在のバックエンドの PID を返す (pg_backend_pid 相当)
+func pgBackendPid(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.BackendPid(), nil
+}
+
+// pgCancelBackend は pid のバックエンドで実行中の問い合わせを取り消す (pg_cancel_backend 相当)
+func pgCancelBackend(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	switch pgSignalBackend(ctx, fcinfo.Args[0].(int32), false) {
+	case signalBackendNoSuperuser:
+		return nil, withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to cancel query"),
+			"Only roles with the %s attribute may cancel queries of roles with the %s attribute.", "SUPERUSER", "SUPERUSER")
+	case signalBackendNoPermission:
+		return nil, withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to cancel query"),
+			"Only roles with privileges of the role whose query is being canceled or with privileges of the \"%s\" role may cancel this query.",
+			catalog.RolePgSignalBackend)
+	case signalBackendError:
+		return false, nil
+	}
+	return true, nil
+}
+
+// pgTerminateBackend は pid のバックエンドのセッションを終了させる (pg_terminate_backend 相当)。
+// 終了を待たずに戻る。
+func pgTerminateBackend(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	switch pgSignalBackend(ctx, fcinfo.Args[0].(int32), true) {
+	case signalBackendNoSuperuser:
+		return nil, withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to terminate process"),
+			"Only roles with the %s attribute may terminate processes of roles with the %s attribute.", "SUPERUSER", "SUPERUSER")
+	case signalBackendNoPermission:
+		return nil, withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to terminate process"),
+			"Only roles with privileges of the role whose process is being terminated or with privileges of the \"%s\" role may terminate this process.",
+			catalog.RolePgSignalBackend)
+	case signalBackendError:
+		return false, nil
+	}
+	return true, nil
+}
+
+// pgReloadConf は postmaster に SIGHUP を送り、設定ファイルを読み直させる (pg_reload_conf 相当)。
+// 読み直しを待たずに戻る。C言語版では EXECUTE の権限を PUBLIC から取り消してあるため、
+// 権限の仕組みがない今はスーパーユーザーだけが実行できる。
+func pgReloadConf(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	if !catalog.IsSuperuser(ctx.UserName()) {
+		return nil, newError(errcodes.InsufficientPrivilege, "permission denied for function pg_reload_conf")
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		ctx.Warning(fmt.Sprintf("failed to send signal to postmaster: %s", err))
+		return false, nil
+	}
+	return true, nil
+}
+
+// pgRotateLogfile はログを収集しているゴルーチンにログファイルの切り替えを要求する
+// (pg_rotate_logfile 相当)。切り替えを待たずに戻る。pg_reload_conf と同じくスーパーユーザーだけが
+// 実行できる。
+func pgRotateLogfile(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	ctx, err := callerContext(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	if !catalog.IsSuperuser(ctx.UserName()) {
+		return nil, newError(errcodes.InsufficientPrivilege, "permission denied for function pg_rotate_logfile")
+	}
+	if !syslogger.RequestRotation() {
+		ctx.Warning("rotation not possible because log collection not active")
+		return false, nil
+	}
+	return true, nil
+}
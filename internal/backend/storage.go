@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/smgr"
+)
+
+// ----------------------------------------------------------------
+// リレーションのファイルの作成と削除 (catalog/storage.c 相当)
+// ----------------------------------------------------------------
+// テーブルのファイルはすぐに作るが、消すのはトランザクションの終わりまで待つ。作った
+// トランザクションがアボートしたらファイルを消し、削除したトランザクションがコミットしたら
+// ファイルを消す。
+
+// pendingRelDelete はトランザクションの終わりに消すリレーションのファイル (PendingRelDelete 相当)
+type pendingRelDelete struct {
+	rlocator storage.RelFileLocator
+	// atCommit が真ならコミットしたときに、偽ならアボートしたときに消す
+	atCommit bool
+}
+
+// relationCreateStorage はリレーションの空のファイルを作り、アボートしたら消すよう予約する
+// (RelationCreateStorage 相当)
+func (s *session) relationCreateStorage(rlocator storage.RelFileLocator) error {
+	if err := smgr.Create(rlocator, storage.MainForkNum, false); err != nil {
+		return err
+	}
+	s.pendingDeletes = append(s.pendingDeletes, pendingRelDelete{rlocator: rlocator, atCommit: false})
+	return nil
+}
+
+// relationDropStorage はリレーションのファイルを、コミットしたら消すよう予約する
+// (RelationDropStorage 相当)
+func (s *session) relationDropStorage(rlocator storage.RelFileLocator) {
+	s.pendingDeletes = append(s.pendingDeletes, pendingRelDelete{rlocator: rlocator, atCommit: true})
+}
+
+// smgrDoPendingDeletes はトランザクションの終わりに、予約したリレーションのファイルを消す
+// (smgrDoPendingDeletes 相当)。ファイルを消せなくてもトランザクションの結果は変わらないため、
+// WARNING を報告して続ける。
+func (s *session) smgrDoPendingDeletes(isCommit bool) {
+	pending := s.pendingDeletes
+	s.pendingDeletes = nil
+	for i := len(pending) - 1; i >= 0; i-- {
+		p := &pending[i]
+		if p.atCommit != isCommit {
+			continue
+		}
+		s.buffers.DropRelationBuffers(p.rlocator)
+		if err := smgr.Unlink(p.rlocator, storage.InvalidForkNumber); err != nil {
+			s.Warning(err.Error())
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package backend
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/executor"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// テーブルの作成、変更、削除 (commands/tablecmds.c 相当)
+// ----------------------------------------------------------------
+// CREATE TABLE は列の定義と INHERITS 句の親から列を決めてテーブルを作る。ALTER TABLE は INHERIT と
+// NO INHERIT で継承を変えられる。子のテーブルは親の全ての列を同じ名前と型で持ち、親を読むときは
+// 子の行も読む。列の制約はまだないため、制約で子を読み飛ばすこと (constraint exclusion) はしない。
+//
+// 子のテーブルは親に依存するため、親を削除するには CASCADE を指定して子も一緒に削除する。
+
+// rangeVarGetRelid は nspname の relname のテーブルを探し、mode のロックを取って定義を返す
+// (RangeVarGetRelidExtended 相当)。テーブルがなければ nil を返す。check はロックを取る前に
+// テーブルを確かめ、エラーを返せばロックを取らない。nspname が空なら public から探す。
+func (s *session) rangeVarGetRelid(nspname, relname string, mode lmgr.LockMode, check func(*catalog.FormPgClass) error) (*catalog.FormPgClass, error) {
+	if nspname != "" {
+		if _, ok := catalog.NamespaceGetOid(nspname); !ok {
+			return nil, newError(errcodes.InvalidSchemaName, "schema \"%s\" does not exist", nspname)
+		}
+	}
+	for {
+		rel, ok := catalog.RelnameGetRelation(nspname, relname)
+		if !ok {
+			return nil, nil
+		}
+		if check != nil {
+			if err := check(rel); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := s.lockProc.LockAcquire(executor.RelationLockTag(rel), mode, false, false); err != nil {
+			return nil, err
+		}
+		// ロックを待つ間に削除されたか作り直されたテーブルは、名前から探し直す
+		if cur, ok := catalog.SearchRelation(rel.Oid); ok && cur.Relname == rel.Relname && cur.Relnamespace == rel.Relnamespace {
+			return cur, nil
+		}
+	}
+}
+
+// checkRelationOwner は現在のユーザーがテーブルの所有者の権限を持つかを確かめる (aclcheck_error 相当)
+func (s *session) checkRelationOwner(rel *catalog.FormPgClass) error {
+	if s.relationOwnercheck(rel) {
+		return nil
+	}
+	return newError(errcodes.InsufficientPrivilege, "must be owner of table %s", rel.Relname)
+}
+
+// relationOwnercheck は現在のユーザーがテーブルの所有者の権限を持つかを返す (object_ownercheck 相当)
+func (s *session) relationOwnercheck(rel *catalog.FormPgClass) bool {
+	if catalog.IsSuperuser(s.userName) {
+		return true
+	}
+	owner, ok := catalog.SearchRoleByOid(rel.Relowner)
+	return ok && catalog.HasPrivsOfRole(s.userName, owner.Rolname)
+}
+
+// checkAttributeType は列を型 atttypid で作れるかを確かめる (CheckAttributeType 相当)。疑似型の
+// 列は作れない。
+func checkAttributeType(attname string, atttypid catalog.Oid) error {
+	switch atttypid {
+	case catalog.VOIDOID, catalog.INTERNALOID:
+		return newError(errcodes.InvalidTableDefinition, "column \"%s\" has pseudo-type %s", attname, catalog.FormatType(atttypid))
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// CREATE TABLE
+// ----------------------------------------------------------------
+
+// defineRelation は CREATE TABLE 文を実行する (DefineRelation 相当)
+func (s *session) defineRelation(stmt *parser.CreateStmt) error {
+	if stmt.Temp {
+		return newError(errcodes.FeatureNotSupported, "temporary tables are not supported")
+	}
+	nspid, err := rangeVarGetCreationNamespace(stmt.Relation)
+	if err != nil {
+		return err
+	}
+	relname := stmt.Relation.Relname
+	if _, exists := catalog.RelnameGetRelation(catalog.NamespaceGetName(nspid), relname); exists {
+		if !stmt.IfNotExists {
+			return newError(errcodes.DuplicateTable, "relation \"%s\" already exists", relname)
+		}
+		return s.reportNotice(errutil.New(errutil.Notice, errcodes.DuplicateTable, "relation \"%s\" already exists, skipping", relname))
+	}
+	attrs, parents, err := s.mergeAttributes(stmt)
+	if err != nil {
+		return err
+	}
+	_, err = s.heapCreateWithCatalog(relname, nspid, attrs, parents)
+	return err
+}
+
+// mergeAttributes は親のテーブルの列と、文に書いた列を合わせて新しいテーブルの列を決める
+// (MergeAttributes 相当)。親の列を親の順に並べ、続けて文の列を並べる。同じ名前の列は、型が
+// 同じなら1つにまとめる。親には ShareUpdateExclusiveLock を取り、子を作る間に変わらないようにする。
+func (s *session) mergeAttributes(stmt *parser.CreateStmt) ([]catalog.FormPgAttribute, []catalog.Oid, error) {
+	var attrs []catalog.FormPgAttribute
+	var parents []catalog.Oid
+	find := func(name string) int {
+		return slices.IndexFunc(attrs, func(a catalog.FormPgAttribute) bool { return a.Attname == name })
+	}
+
+	for _, rv := range stmt.InhRelations {
+		parent, err := s.rangeVarGetRelid(rv.Schemaname, rv.Relname, lmgr.ShareUpdateExclusiveLock, s.checkRelationOwner)
+		if err != nil {
+			return nil, nil, err
+		}
+		if parent == nil {
+			if _, isView := catalog.RelnameGetSystemView(rv.Schemaname, rv.Relname); isView {
+				return nil, nil, newError(errcodes.WrongObjectType, "inherited relation \"%s\" is not a table or foreign table", rv.Relname)
+			}
+			return nil, nil, newError(errcodes.UndefinedTable, "relation \"%s\" does not exist", rv.Relname)
+		}
+		if slices.Contains(parents, parent.Oid) {
+			return nil, nil, newError(errcodes.DuplicateTable, "relation \"%s\" would be inherited from more than once", parent.Relname)
+		}
+		parents = append(parents, parent.Oid)
+		for _, attr := range parent.Attrs {
+			i := find(attr.Attname)
+			if i < 0 {
+				attrs = append(attrs, attr)
+				continue
+			}
+			if attrs[i].Atttypid != attr.Atttypid {
+				return nil, nil, withDetail(newError(errcodes.DatatypeMismatch, "inherited column \"%s\" has a type conflict", attr.Attname),
+					"%s versus %s", catalog.FormatType(attrs[i].Atttypid), catalog.FormatType(attr.Atttypid))
+			}
+			if err := s.reportNotice(newNotice("merging multiple inherited definitions of column \"%s\"", attr.Attname)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	ninherited := len(attrs)
+	for i, col := range stmt.TableElts {
+		for _, prev := range stmt.TableElts[:i] {
+			if prev.Colname == col.Colname {
+				return nil, nil, newError(errcodes.DuplicateColumn, "column \"%s\" specified more than once", col.Colname)
+			}
+		}
+		typid, err := parser.TypenameTypeID(col.TypeName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := s.typeAclcheck(typid, catalog.AclUsage); err != nil {
+			return nil, nil, err
+		}
+		// CREATE TABLE AS と同じく、ドメインの列は基の型の列にする
+		typid = catalog.GetBaseType(typid)
+		if err := checkAttributeType(col.Colname, typid); err != nil {
+			return nil, nil, err
+		}
+		if j := find(col.Colname); j >= 0 && j < ninherited {
+			if err := s.reportNotice(newNotice("merging column \"%s\" with inherited definition", col.Colname)); err != nil {
+				return nil, nil, err
+			}
+			if attrs[j].Atttypid != typid {
+				return nil, nil, withDetail(newError(errcodes.DatatypeMismatch, "column \"%s\" has a type conflict", col.Colname),
+					"%s versus %s", catalog.FormatType(attrs[j].Atttypid), catalog.FormatType(typid))
+			}
+			continue
+		}
+		attrs = append(attrs, catalog.FormPgAttribute{Attname: col.Colname, Atttypid: typid})
+	}
+	return attrs, parents, nil
+}
+
+// ----------------------------------------------------------------
+// ALTER TABLE
+// ----------------------------------------------------------------
+
+// alterTable は ALTER TABLE 文を実行する (AlterTable 相当)。テーブルには AccessExclusiveLock を取る。
+func (s *session) alterTable(stmt *parser.AlterTableStmt) error {
+	rv := stmt.Relation
+	rel, err := s.rangeVarGetRelid(rv.Schemaname, rv.Relname, lmgr.AccessExclusiveLock, s.checkRelationOwner)
+	if err != nil {
+		return err
+	}
+	if rel == nil {
+		if _, isView := catalog.RelnameGetSystemView(rv.Schemaname, rv.Relname); isView {
+			return alterTableNotSupportedOnView(stmt.Cmds[0], rv.Relname)
+		}
+		if stmt.MissingOk {
+			return s.reportNotice(newNotice("relation \"%s\" does not exist, skipping", rv.Relname))
+		}
+		return newError(errcodes.UndefinedTable, "relation \"%s\" does not exist", rv.Relname)
+	}
+	for _, cmd := range stmt.Cmds {
+		switch cmd.Subtype {
+		case parser.ATAddInherit:
+			err = s.atExecAddInherit(rel, cmd)
+		case parser.ATDropInherit:
+			err = atExecDropInherit(rel, cmd)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.updateRelation(rel)
+	return nil
+}
+
+// alterTableNotSupportedOnView はビューにできない ALTER TABLE の動作のエラーを返す
+// (ATSimplePermissions 相当)
+func alterTableNotSupportedOnView(cmd *parser.AlterTableCmd, relname string) error {
+	action := "INHERIT"
+	if cmd.Subtype == parser.ATDropInherit {
+		action = "NO INHERIT"
+	}
+	return withDetail(newError(errcodes.WrongObjectType, "ALTER action %s cannot be performed on relation \"%s\"", action, relname),
+		"This operation is not supported for views.")
+}
+
+// atExecAddInherit は INHERIT parent で child を parent の子にする (ATExecAddInherit と
+// MergeAttributesIntoExisting 相当)。child は parent の全ての列を同じ型で持っていなければならない。
+func (s *session) atExecAddInherit(child *catalog.FormPgClass, cmd *parser.AlterTableCmd) error {
+	rv := cmd.Parent
+	parent, err := s.rangeVarGetRelid(rv.Schemaname, rv.Relname, lmgr.ShareUpdateExclusiveLock, s.checkRelationOwner)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		if _, isView := catalog.RelnameGetSystemView(rv.Schemaname, rv.Relname); isView {
+			return alterTableNotSupportedOnView(cmd, rv.Relname)
+		}
+		return newError(errcodes.UndefinedTable, "relation \"%s\" does not exist", rv.Relname)
+	}
+	// 子孫を親にすると継承が循環する
+	if slices.Contains(catalog.FindAllInheritors(child.Oid), parent.Oid) {
+		return withDetail(newError(errcodes.DuplicateTable, "circular inheritance not allowed"),
+			"\"%s\" is already a child of \"%s\".", parent.Relname, child.Relname)
+	}
+	if slices.Contains(child.Inhparents, parent.Oid) {
+		return newError(errcodes.DuplicateTable, "relation \"%s\" would be inherited from more than once", parent.Relname)
+	}
+	for _, attr := range parent.Attrs {
+		i := slices.IndexFunc(child.Attrs, func(a catalog.FormPgAttribute) bool { return a.Attname == attr.Attname })
+		if i < 0 {
+			return newError(errcodes.DatatypeMismatch, "child table is missing column \"%s\"", attr.Attname)
+		}
+		if child.Attrs[i].Atttypid != attr.Atttypid {
+			return newError(errcodes.DatatypeMismatch, "child table \"%s\" has different type for column \"%s\"", child.Relname, attr.Attname)
+		}
+	}
+	child.Inhparents = append(child.Inhparents, parent.Oid)
+	return nil
+}
+
+// atExecDropInherit は NO INHERIT parent で child と parent の継承をやめる (ATExecDropInherit 相当)。
+// 子は親から受け継いだ列をそのまま持ち続ける。
+func atExecDropInherit(child *catalog.FormPgClass, cmd *parser.AlterTableCmd) error {
+	rv := cmd.Parent
+	parent, ok := catalog.RelnameGetRelation(rv.Schemaname, rv.Relname)
+	if !ok {
+		return newError(errcodes.UndefinedTable, "relation \"%s\" does not exist", rv.Relname)
+	}
+	i := slices.Index(child.Inhparents, parent.Oid)
+	if i < 0 {
+		return newError(errcodes.UndefinedTable, "relation \"%s\" is not a parent of relation \"%s\"", parent.Relname, child.Relname)
+	}
+	child.Inhparents = slices.Delete(child.Inhparents, i, i+1)
+	return nil
+}
+
+// ----------------------------------------------------------------
+// DROP TABLE
+// ----------------------------------------------------------------
+
+// removeRelations は DROP TABLE 文を実行する (RemoveRelations と performMultipleDeletions 相当)。
+// 全ての対象に AccessExclusiveLock を取って確かめてから削除する。CASCADE では、対象を継承した
+// 子のテーブルも削除する。
+func (s *session) removeRelations(stmt *parser.DropStmt) error {
+	var targets []*catalog.FormPgClass
+	for _, obj := range stmt.Objects {
+		rel, err := s.lookupTableForDrop(obj.([]string), stmt.MissingOk)
+		if err != nil {
+			return err
+		}
+		if rel != nil {
+			targets = append(targets, rel)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	// 子のテーブルを集める (findDependentObjects 相当)。子も削除するためロックを取る
+	dropping := make(map[catalog.Oid]bool)
+	var order []*catalog.FormPgClass
+	var dependents []dependentObject
+	var collect func(rel *catalog.FormPgClass, dependsOn string) error
+	collect = func(rel *catalog.FormPgClass, dependsOn string) error {
+		if dropping[rel.Oid] {
+			return nil
+		}
+		dropping[rel.Oid] = true
+		if dependsOn != "" {
+			dependents = append(dependents, dependentObject{"table " + rel.Relname, dependsOn})
+		}
+		for _, childOid := range catalog.FindInheritanceChildren(rel.Oid) {
+			if dropping[childOid] {
+				continue
+			}
+			child, ok := catalog.SearchRelation(childOid)
+			if !ok {
+				continue
+			}
+			if _, err := s.lockProc.LockAcquire(executor.RelationLockTag(child), lmgr.AccessExclusiveLock, false, false); err != nil {
+				return err
+			}
+			// ロックを待つ間に削除されたか、継承をやめた子は削除しない
+			if child, ok = catalog.SearchRelation(childOid); !ok || !slices.Contains(child.Inhparents, rel.Oid) {
+				continue
+			}
+			if err := collect(child, "table "+rel.Relname); err != nil {
+				return err
+			}
+		}
+		order = append(order, rel)
+		return nil
+	}
+	for _, rel := range targets {
+		if err := collect(rel, ""); err != nil {
+			return err
+		}
+	}
+	if err := s.reportDependentObjects(dependents, stmt.Behavior, "table "+targets[0].Relname); err != nil {
+		return err
+	}
+	for _, rel := range order {
+		s.heapDropWithCatalog(rel)
+	}
+	return nil
+}
+
+// lookupTableForDrop は削除するテーブルを探してロックを取る (RangeVarGetRelidExtended と
+// RangeVarCallbackForDropRelation 相当)。missingOk でテーブルがなければ NOTICE を報告して nil を返す。
+func (s *session) lookupTableForDrop(names []string, missingOk bool) (*catalog.FormPgClass, error) {
+	if len(names) > 2 {
+		return nil, newError(errcodes.SyntaxError, "improper qualified name (too many dotted names): %s", strings.Join(names, "."))
+	}
+	nspname, relname := "", names[len(names)-1]
+	if len(names) == 2 {
+		nspname = names[0]
+		if _, ok := catalog.NamespaceGetOid(nspname); !ok && missingOk {
+			return nil, s.reportNotice(newNotice("schema \"%s\" does not exist, skipping", nspname))
+		}
+	}
+	rel, err := s.rangeVarGetRelid(nspname, relname, lmgr.AccessExclusiveLock, s.checkRelationOwner)
+	if err != nil || rel != nil {
+		return rel, err
+	}
+	if _, isView := catalog.RelnameGetSystemView(nspname, relname); isView {
+		return nil, withHint(newError(errcodes.WrongObjectType, "\"%s\" is not a table", relname),
+			"Use DROP VIEW to remove a view.")
+	}
+	if missingOk {
+		return nil, s.reportNotice(newNotice("table \"%s\" does not exist, skipping", relname))
+	}
+	return nil, newError(errcodes.UndefinedTable, "table \"%s\" does not exist", relname)
+}
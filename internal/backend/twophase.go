@@ -0,0 +1,23 @@
+package backend
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+)
+
+// ----------------------------------------------------------------
+// 2相コミット (access/transam/twophase.c の一部相当)
+// ----------------------------------------------------------------
+// PREPARE TRANSACTION で準備したトランザクションは、pg_prepared_xacts で一覧できる。
+// トランザクションブロックと PREPARE TRANSACTION はまだないため、準備済みの
+// トランザクションは存在せず、pg_prepared_xacts は常に空になる。監視ツールが
+// ビューを参照してもエラーにならないよう、ビューだけを用意する。
+
+func init() {
+	fmgr.RegisterSetReturning("pg_prepared_xact", pgPreparedXact)
+}
+
+// pgPreparedXact は準備済みのトランザクションを返す (pg_prepared_xact 相当)
+func pgPreparedXact(fcinfo *fmgr.FunctionCallInfo) ([][]adt.Datum, error) {
+	return nil, nil
+}
@@ -0,0 +1,418 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// ドメインの作成、変更、削除 (commands/typecmds.c、commands/dropcmds.c のうちドメイン相当)
+// ----------------------------------------------------------------
+// CREATE DOMAIN、ALTER DOMAIN、DROP DOMAIN でドメイン (pg_type) とその制約 (pg_constraint) を
+// 操作する。制約は値をドメインに変換するとき (CoerceToDomain) と、ドメインの型のパラメータを
+// Bind で受け取るときに実行器が確かめる。
+//
+// CREATE TABLE AS はドメインの列を基の型の列として作るため、ドメインの値を格納した列はない。
+// C言語版が SET NOT NULL、ADD CONSTRAINT、VALIDATE CONSTRAINT で行う既存の値の確認
+// (validateDomainConstraint) は確かめる値がなく、常に成功する。
+//
+// ドメインを変更、削除するには、その所有者の権限が必要である。ドメインを基にした別のドメインが
+// あれば、CASCADE を指定しない限り削除できない。
+
+// lookupDomain は名前のドメインを返す。ドメインでない型であればエラーにする
+// (typenameTypeId と checkDomainOwner 相当)。
+func (s *session) lookupDomain(names []string) (*catalog.FormPgType, error) {
+	name := names[len(names)-1]
+	typid, ok := catalog.TypenameTypeID(name)
+	if !ok {
+		return nil, newError(errcodes.UndefinedObject, "type \"%s\" does not exist", name)
+	}
+	typ, _ := catalog.SearchType(typid)
+	if typ.Typbasetype == catalog.InvalidOid {
+		return nil, newError(errcodes.WrongObjectType, "%s is not a domain", catalog.FormatType(typid))
+	}
+	if err := s.checkDomainOwner(typ); err != nil {
+		return nil, err
+	}
+	return typ, nil
+}
+
+// checkDomainOwner は現在のユーザーがドメインの所有者の権限を持つかを確かめる (aclcheck_error_type 相当)
+func (s *session) checkDomainOwner(typ *catalog.FormPgType) error {
+	if s.typeOwnercheck(typ.Oid) {
+		return nil
+	}
+	return newError(errcodes.InsufficientPrivilege, "must be owner of type %s", typ.Typname)
+}
+
+// typeOwnercheck は現在のユーザーが型の所有者の権限を持つかを返す (object_ownercheck 相当)。
+// 組み込み型はブートストラップスーパーユーザーが所有する。
+func (s *session) typeOwnercheck(typid catalog.Oid) bool {
+	ownerID := catalog.BootstrapSuperuserID
+	if typ, ok := catalog.SearchType(typid); ok && typ.Typowner != catalog.InvalidOid {
+		ownerID = typ.Typowner
+	}
+	owner, _ := catalog.SearchRoleByOid(ownerID)
+	return catalog.HasPrivsOfRole(s.userName, owner.Rolname)
+}
+
+// typeAclcheck は現在のユーザーが型に mode の権限を持つかを確かめる (object_aclcheck と
+// aclcheck_error_type 相当)。配列型は要素の型の権限を見る。組み込み型は既定の権限を持つ。
+func (s *session) typeAclcheck(typid catalog.Oid, mode catalog.AclMode) error {
+	if elem := catalog.GetElementType(typid); elem != catalog.InvalidOid {
+		typid = elem
+	}
+	typ, _ := catalog.SearchType(typid)
+	acl := typ.Typacl
+	if acl == nil {
+		ownerID := typ.Typowner
+		if ownerID == catalog.InvalidOid {
+			ownerID = catalog.BootstrapSuperuserID
+		}
+		acl = catalog.AclDefault(catalog.DefaclObjType, ownerID)
+	}
+	if catalog.AclMask(acl, s.userName, mode) != mode {
+		return newError(errcodes.InsufficientPrivilege, "permission denied for type %s", catalog.FormatType(typid))
+	}
+	return nil
+}
+
+// createDomain は CREATE DOMAIN 文を実行する (DefineDomain 相当)
+func (s *session) createDomain(stmt *parser.CreateDomainStmt) error {
+	domainName := stmt.Domainname[len(stmt.Domainname)-1]
+	if _, ok := catalog.TypenameTypeID(domainName); ok {
+		return newError(errcodes.DuplicateObject, "type \"%s\" already exists", domainName)
+	}
+
+	basetypeoid, err := parser.TypenameTypeID(stmt.TypeName)
+	if err != nil {
+		return newError(errcodes.UndefinedObject, "%s", err.Error())
+	}
+	// 基の型は組み込みの基本型か別のドメインでなければならない。疑似型は使えない
+	if basetypeoid == catalog.UNKNOWNOID {
+		return newError(errcodes.DatatypeMismatch, "\"%s\" is not a valid base type for a domain", catalog.FormatType(basetypeoid))
+	}
+	if err := s.typeAclcheck(basetypeoid, catalog.AclUsage); err != nil {
+		return err
+	}
+	baseType, _ := catalog.SearchType(basetypeoid)
+	owner, _ := catalog.SearchRole(s.userName)
+
+	var (
+		defaultExpr parser.Expr
+		sawDefault  bool
+		nullDefined bool
+		typNotNull  bool
+		checks      []*parser.Constraint
+		checkExprs  []parser.Expr
+	)
+	for _, constr := range stmt.Constraints {
+		switch constr.Contype {
+		case parser.ConstrDefault:
+			if sawDefault {
+				return newError(errcodes.SyntaxError, "multiple default expressions")
+			}
+			sawDefault = true
+			e, err := parser.TransformDomainDefault(constr.RawExpr, domainName, basetypeoid, constr.Location)
+			if err != nil {
+				return err
+			}
+			defaultExpr = e
+		case parser.ConstrNotNull:
+			if nullDefined && !typNotNull {
+				return newError(errcodes.SyntaxError, "conflicting NULL/NOT NULL constraints")
+			}
+			nullDefined, typNotNull = true, true
+		case parser.ConstrNull:
+			if nullDefined && typNotNull {
+				return newError(errcodes.SyntaxError, "conflicting NULL/NOT NULL constraints")
+			}
+			nullDefined = true
+		case parser.ConstrCheck:
+			// CHECK 制約はドメインを作ってから加える
+			e, err := parser.TransformDomainCheck(constr.RawExpr, basetypeoid, constr.Location)
+			if err != nil {
+				return err
+			}
+			checks = append(checks, constr)
+			checkExprs = append(checkExprs, e)
+		}
+	}
+
+	typid, ok := catalog.CreateDomainType(catalog.FormPgType{
+		Typname:       domainName,
+		Typlen:        baseType.Typlen,
+		Typdelim:      baseType.Typdelim,
+		Typowner:      owner.Oid,
+		Typacl:        catalog.GetUserDefaultAcl(catalog.DefaclObjType, owner.Oid, catalog.PgPublicNamespace),
+		Typbasetype:   basetypeoid,
+		Typnotnull:    typNotNull,
+		Typdefaultbin: defaultExpr,
+	})
+	if !ok {
+		return newError(errcodes.DuplicateObject, "type \"%s\" already exists", domainName)
+	}
+	for i, constr := range checks {
+		if err := s.domainAddCheckConstraint(typid, domainName, constr, checkExprs[i]); err != nil {
+			catalog.DropDomainType(typid)
+			return err
+		}
+	}
+	return nil
+}
+
+// domainAddCheckConstraint はドメインに解析済みの CHECK 制約を加える (domainAddCheckConstraint 相当)。
+// 名前がなければ "ドメイン名_check" とし、既にあれば数字を付けて重ならない名前にする
+// (ChooseConstraintName 相当)。
+func (s *session) domainAddCheckConstraint(typid catalog.Oid, domainName string, constr *parser.Constraint, expr parser.Expr) error {
+	con := catalog.FormPgConstraint{
+		Conname:      constr.Conname,
+		Contype:      catalog.ConstraintCheck,
+		Convalidated: !constr.SkipValidation,
+		Contypid:     typid,
+		Conbin:       expr,
+	}
+	if con.Conname != "" {
+		if _, ok := catalog.CreateConstraint(con); !ok {
+			return newError(errcodes.DuplicateObject, "constraint \"%s\" for domain \"%s\" already exists", con.Conname, domainName)
+		}
+		return nil
+	}
+	for pass := 0; ; pass++ {
+		con.Conname = domainName + "_check"
+		if pass > 0 {
+			con.Conname += fmt.Sprint(pass)
+		}
+		if _, ok := catalog.CreateConstraint(con); ok {
+			return nil
+		}
+	}
+}
+
+// findDomainConstraint はドメインの名前の制約を返す
+func findDomainConstraint(typid catalog.Oid, conname string) (catalog.FormPgConstraint, bool) {
+	for _, con := range catalog.TypeConstraints(typid) {
+		if con.Conname == conname {
+			return con, true
+		}
+	}
+	return catalog.FormPgConstraint{}, false
+}
+
+// alterDomain は ALTER DOMAIN 文のうち、既定値と制約を変更するものを実行する
+// (AlterDomainDefault、AlterDomainNotNull、AlterDomainAddConstraint、AlterDomainDropConstraint、
+// AlterDomainValidateConstraint 相当)
+func (s *session) alterDomain(stmt *parser.AlterDomainStmt) error {
+	typ, err := s.lookupDomain(stmt.TypeName)
+	if err != nil {
+		return err
+	}
+
+	switch stmt.Subtype {
+	case parser.AlterDomainDefault:
+		var defaultExpr parser.Expr
+		if stmt.Def != nil {
+			e, err := parser.TransformDomainDefault(stmt.Def, typ.Typname, typ.Typbasetype, -1)
+			if err != nil {
+				return err
+			}
+			defaultExpr = e
+		}
+		catalog.UpdateDomainType(typ.Oid, func(t *catalog.FormPgType) { t.Typdefaultbin = defaultExpr })
+	case parser.AlterDomainDropNotNull:
+		catalog.UpdateDomainType(typ.Oid, func(t *catalog.FormPgType) { t.Typnotnull = false })
+	case parser.AlterDomainSetNotNull:
+		catalog.UpdateDomainType(typ.Oid, func(t *catalog.FormPgType) { t.Typnotnull = true })
+	case parser.AlterDomainAddConstraint:
+		constr := stmt.Def.(*parser.Constraint)
+		if constr.Contype == parser.ConstrNotNull {
+			catalog.UpdateDomainType(typ.Oid, func(t *catalog.FormPgType) { t.Typnotnull = true })
+			return nil
+		}
+		expr, err := parser.TransformDomainCheck(constr.RawExpr, typ.Typbasetype, constr.Location)
+		if err != nil {
+			return err
+		}
+		return s.domainAddCheckConstraint(typ.Oid, typ.Typname, constr, expr)
+	case parser.AlterDomainDropConstraint:
+		con, ok := findDomainConstraint(typ.Oid, stmt.Name)
+		if !ok {
+			if !stmt.MissingOk {
+				return newError(errcodes.UndefinedObject, "constraint \"%s\" of domain \"%s\" does not exist", stmt.Name, typ.Typname)
+			}
+			return s.reportNotice(newNotice("constraint \"%s\" of domain \"%s\" does not exist, skipping", stmt.Name, typ.Typname))
+		}
+		catalog.DropConstraint(con.Oid)
+	case parser.AlterDomainValidateConstraint:
+		con, ok := findDomainConstraint(typ.Oid, stmt.Name)
+		if !ok {
+			return newError(errcodes.UndefinedObject, "constraint \"%s\" of domain \"%s\" does not exist", stmt.Name, typ.Typname)
+		}
+		if con.Contype != catalog.ConstraintCheck {
+			return newError(errcodes.WrongObjectType, "constraint \"%s\" of domain \"%s\" is not a check constraint", stmt.Name, typ.Typname)
+		}
+		catalog.UpdateConstraint(con.Oid, func(c *catalog.FormPgConstraint) { c.Convalidated = true })
+	}
+	return nil
+}
+
+// renameDomain は ALTER DOMAIN の RENAME TO と RENAME CONSTRAINT を実行する
+// (RenameType、RenameConstraint 相当)
+func (s *session) renameDomain(stmt *parser.RenameStmt) error {
+	typ, err := s.lookupDomain(stmt.Object)
+	if err != nil {
+		return err
+	}
+	if stmt.RenameType == parser.ObjectDomconstraint {
+		con, ok := findDomainConstraint(typ.Oid, stmt.Subname)
+		if !ok {
+			return newError(errcodes.UndefinedObject, "constraint \"%s\" for domain %s does not exist", stmt.Subname, typ.Typname)
+		}
+		if _, exists := findDomainConstraint(typ.Oid, stmt.Newname); exists {
+			return newError(errcodes.DuplicateObject, "constraint \"%s\" for domain %s already exists", stmt.Newname, typ.Typname)
+		}
+		catalog.UpdateConstraint(con.Oid, func(c *catalog.FormPgConstraint) { c.Conname = stmt.Newname })
+		return nil
+	}
+	if !catalog.RenameDomainType(typ.Oid, stmt.Newname) {
+		return newError(errcodes.DuplicateObject, "type \"%s\" already exists", stmt.Newname)
+	}
+	return nil
+}
+
+// alterDomainOwner は ALTER DOMAIN の OWNER TO を実行する (AlterTypeOwner 相当)。スーパーユーザー
+// でなければ、新しい所有者のメンバーでなければならない。
+func (s *session) alterDomainOwner(stmt *parser.AlterOwnerStmt) error {
+	typ, err := s.lookupDomain(stmt.Object.([]string))
+	if err != nil {
+		return err
+	}
+	newOwner, err := s.getRoleSpec(stmt.NewOwner)
+	if err != nil {
+		return err
+	}
+	if typ.Typowner == newOwner.Oid {
+		return nil
+	}
+	if !catalog.IsSuperuser(s.userName) && !catalog.IsMemberOfRoleNosuper(s.userName, newOwner.Rolname) {
+		return newError(errcodes.InsufficientPrivilege, "must be able to SET ROLE \"%s\"", newOwner.Rolname)
+	}
+	catalog.UpdateDomainType(typ.Oid, func(t *catalog.FormPgType) {
+		if t.Typacl != nil {
+			t.Typacl = catalog.AclNewOwner(t.Typacl, t.Typowner, newOwner.Oid)
+		}
+		t.Typowner = newOwner.Oid
+	})
+	return nil
+}
+
+// dropDomain は DROP DOMAIN 文を実行する (RemoveObjects と performMultipleDeletions 相当)。
+// 全ての対象を確かめてから削除する。CASCADE では、対象を基にしたドメインと、対象を変換元か
+// 変換先とする変換と、対象を被演算子か結果の型とする演算子も削除する。
+func (s *session) dropDomain(stmt *parser.DropStmt) error {
+	var targets []*catalog.FormPgType
+	for _, obj := range stmt.Objects {
+		names := obj.([]string)
+		name := names[len(names)-1]
+		if _, ok := catalog.TypenameTypeID(name); !ok && stmt.MissingOk {
+			if err := s.reportNotice(newNotice("type \"%s\" does not exist, skipping", name)); err != nil {
+				return err
+			}
+			continue
+		}
+		typ, err := s.lookupDomain(names)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, typ)
+	}
+
+	if len(targets) == 0 {
+		return nil
+	}
+
+	// 依存するドメインと変換を集める (findDependentObjects 相当)
+	dropping := make(map[catalog.Oid]bool)
+	var order []catalog.FormPgType
+	var casts []catalog.FormPgCast
+	var dependents []dependentObject
+	ops := newOperatorDeletion(dropping)
+	var collect func(typ catalog.FormPgType, dependent bool)
+	collect = func(typ catalog.FormPgType, dependent bool) {
+		if dropping[typ.Oid] {
+			return
+		}
+		dropping[typ.Oid] = true
+		if dependent {
+			dependents = append(dependents, dependentObject{"type " + typ.Typname, "type " + catalog.FormatType(typ.Typbasetype)})
+		}
+		for _, cast := range catalog.CastsOnType(typ.Oid) {
+			if !dropping[cast.Oid] {
+				dropping[cast.Oid] = true
+				casts = append(casts, cast)
+				dependents = append(dependents, dependentObject{castDescription(cast), "type " + typ.Typname})
+			}
+		}
+		for _, op := range catalog.OperatorsOnType(typ.Oid) {
+			ops.add(op, "type "+typ.Typname)
+			dependents = append(dependents, ops.dependents...)
+			ops.dependents = nil
+		}
+		for _, dep := range catalog.DomainsOnType(typ.Oid) {
+			collect(dep, true)
+		}
+		order = append(order, typ)
+	}
+	for _, typ := range targets {
+		collect(*typ, false)
+	}
+	if err := s.reportDependentObjects(dependents, stmt.Behavior, "type "+targets[0].Typname); err != nil {
+		return err
+	}
+
+	// 変換と演算子と、依存するドメインから先に削除する
+	for _, cast := range casts {
+		catalog.DropCast(cast.Oid)
+	}
+	ops.drop()
+	for _, typ := range order {
+		catalog.DropDomainType(typ.Oid)
+	}
+	return nil
+}
+
+// dependentObject は削除するオブジェクトに依存するオブジェクトと、その依存先の説明
+type dependentObject struct {
+	desc, dependsOn string
+}
+
+// reportDependentObjects は削除するオブジェクトに依存するオブジェクトを報告する
+// (reportDependentObjects 相当)。RESTRICT ではエラーにし、CASCADE では一緒に削除するものを
+// NOTICE で知らせる。object はエラーメッセージに出す、削除するオブジェクトの説明。
+func (s *session) reportDependentObjects(dependents []dependentObject, behavior parser.DropBehavior, object string) error {
+	if len(dependents) == 0 {
+		return nil
+	}
+	if behavior != parser.DropCascade {
+		var detail []string
+		for _, dep := range dependents {
+			detail = append(detail, dep.desc+" depends on "+dep.dependsOn)
+		}
+		return withHint(withDetail(newError(errcodes.DependentObjectsStillExist, "cannot drop %s because other objects depend on it", object),
+			"%s", strings.Join(detail, "\n")),
+			"Use DROP ... CASCADE to drop the dependent objects too.")
+	}
+	if len(dependents) == 1 {
+		return s.reportNotice(newNotice("drop cascades to %s", dependents[0].desc))
+	}
+	var detail []string
+	for _, dep := range dependents {
+		detail = append(detail, "drop cascades to "+dep.desc)
+	}
+	return s.reportNotice(newNotice("drop cascades to %d other objects", len(dependents)).
+		WithDetail("%s", strings.Join(detail, "\n")))
+}
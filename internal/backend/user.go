@@ -0,0 +1,510 @@
+package backend
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// ロールの作成、変更、削除 (commands/user.c 相当)
+// ----------------------------------------------------------------
+// CREATE ROLE、ALTER ROLE、DROP ROLE でロールの一覧 (pg_authid) を操作する。
+//
+// パスワードは password_encryption の形式で保存する。クライアントが \password のように
+// 暗号化した値を送ってきた場合は、その形式のまま保存する。
+//
+// ロールを作るには CREATEROLE の属性が必要で、自分の持たない属性 (SUPERUSER など) は
+// 与えられない。作ったロールには、作ったロールの ADMIN OPTION が与えられる。
+// 既存のロールを変更、削除するには、CREATEROLE の属性とそのロールの ADMIN OPTION が必要である。
+// ただし、自分自身のパスワードは誰でも変更できる。スーパーユーザーのロールは
+// スーパーユーザーしか変更、削除できない。
+
+// roleOptions は CREATE ROLE と ALTER ROLE のオプションを種類ごとに分けたもの
+type roleOptions struct {
+	password, issuper, inherit, createrole, createdb, canlogin, isreplication,
+	connlimit, addroleto, rolemembers, adminmembers, validUntil, bypassRLS *parser.DefElem
+}
+
+// parseRoleOptions はオプションを種類ごとに分ける。同じ種類のオプションは一度しか書けない。
+func (s *session) parseRoleOptions(options []*parser.DefElem) (*roleOptions, error) {
+	var o roleOptions
+	for _, opt := range options {
+		var dst **parser.DefElem
+		switch opt.Defname {
+		case "password":
+			dst = &o.password
+		case "sysid":
+			if err := s.reportNotice(newNotice("SYSID can no longer be specified")); err != nil {
+				return nil, err
+			}
+			continue
+		case "superuser":
+			dst = &o.issuper
+		case "inherit":
+			dst = &o.inherit
+		case "createrole":
+			dst = &o.createrole
+		case "createdb":
+			dst = &o.createdb
+		case "canlogin":
+			dst = &o.canlogin
+		case "isreplication":
+			dst = &o.isreplication
+		case "connectionlimit":
+			dst = &o.connlimit
+		case "addroleto":
+			dst = &o.addroleto
+		case "rolemembers":
+			dst = &o.rolemembers
+		case "adminmembers":
+			dst = &o.adminmembers
+		case "validUntil":
+			dst = &o.validUntil
+		case "bypassrls":
+			dst = &o.bypassRLS
+		default:
+			return nil, newError(errcodes.SyntaxError, "option \"%s\" not recognized", opt.Defname)
+		}
+		if *dst != nil {
+			return nil, newError(errcodes.SyntaxError, "conflicting or redundant options")
+		}
+		*dst = opt
+	}
+
+	if o.connlimit != nil {
+		if n := intOption(o.connlimit); n < -1 {
+			return nil, newError(errcodes.InvalidParameterValue, "invalid connection limit: %d", n)
+		}
+	}
+	return &o, nil
+}
+
+func boolOption(d *parser.DefElem) bool {
+	return d.Arg.(*parser.Boolean).Boolval
+}
+
+func intOption(d *parser.DefElem) int32 {
+	return d.Arg.(*parser.Integer).Ival
+}
+
+// getRoleName はロールの指定からロール名を返す (get_rolespec_name 相当)
+func (s *session) getRoleName(spec *parser.RoleSpec) string {
+	if spec.RoleType == parser.RoleSpecCString {
+		return spec.Rolename
+	}
+	// SET ROLE がないため、CURRENT_ROLE、CURRENT_USER、SESSION_USER はいずれも接続したユーザー
+	return s.userName
+}
+
+// getRoleSpec はロールの指定から既存のロールを返す (get_rolespec_tuple 相当)
+func (s *session) getRoleSpec(spec *parser.RoleSpec) (catalog.FormPgAuthid, error) {
+	name := s.getRoleName(spec)
+	role, ok := catalog.SearchRole(name)
+	if !ok {
+		return role, newError(errcodes.UndefinedObject, "role \"%s\" does not exist", name)
+	}
+	return role, nil
+}
+
+// haveCreateRolePrivilege はロールが CREATEROLE の属性を持つかを返す (have_createrole_privilege 相当)
+func haveCreateRolePrivilege(rolname string) bool {
+	role, ok := catalog.SearchRole(rolname)
+	return ok && (role.Rolsuper || role.Rolcreaterole)
+}
+
+// haveCreatedbPrivilege はロールが CREATEDB の属性を持つかを返す (have_createdb_privilege 相当)
+func haveCreatedbPrivilege(rolname string) bool {
+	role, ok := catalog.SearchRole(rolname)
+	return ok && (role.Rolsuper || role.Rolcreatedb)
+}
+
+// isReservedName はロール名が予約されているかを返す (IsReservedName 相当)
+func isReservedName(name string) bool {
+	return strings.HasPrefix(name, "pg_")
+}
+
+// createRole は CREATE ROLE 文を実行する (CreateRole 相当)
+func (s *session) createRole(stmt *parser.CreateRoleStmt) error {
+	o, err := s.parseRoleOptions(stmt.Options)
+	if err != nil {
+		return err
+	}
+	role := catalog.FormPgAuthid{
+		Rolname:    stmt.Role,
+		Rolinherit: true,
+		// CREATE USER だけは、既定でログインできるロールを作る
+		Rolcanlogin:  stmt.StmtType == parser.RoleStmtUser,
+		Rolconnlimit: -1,
+	}
+	if o.issuper != nil {
+		role.Rolsuper = boolOption(o.issuper)
+	}
+	if o.inherit != nil {
+		role.Rolinherit = boolOption(o.inherit)
+	}
+	if o.createrole != nil {
+		role.Rolcreaterole = boolOption(o.createrole)
+	}
+	if o.createdb != nil {
+		role.Rolcreatedb = boolOption(o.createdb)
+	}
+	if o.canlogin != nil {
+		role.Rolcanlogin = boolOption(o.canlogin)
+	}
+	if o.isreplication != nil {
+		role.Rolreplication = boolOption(o.isreplication)
+	}
+	if o.bypassRLS != nil {
+		role.Rolbypassrls = boolOption(o.bypassRLS)
+	}
+	if o.connlimit != nil {
+		role.Rolconnlimit = intOption(o.connlimit)
+	}
+
+	// 自分の持たない属性は与えられない
+	current := s.userName
+	if !haveCreateRolePrivilege(current) {
+		return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to create role"),
+			"Only roles with the %s attribute may create roles.", "CREATEROLE")
+	}
+	for _, attr := range []struct {
+		requested bool
+		have      func() bool
+		name      string
+	}{
+		{role.Rolsuper, func() bool { return catalog.IsSuperuser(current) }, "SUPERUSER"},
+		{role.Rolcreatedb, func() bool { return haveCreatedbPrivilege(current) }, "CREATEDB"},
+		{role.Rolreplication, func() bool { return catalog.IsSuperuser(current) }, "REPLICATION"},
+		{role.Rolbypassrls, func() bool { return catalog.IsSuperuser(current) }, "BYPASSRLS"},
+	} {
+		if attr.requested && !attr.have() {
+			return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to create role"),
+				"Only roles with the %s attribute may create roles with the %s attribute.", attr.name, attr.name)
+		}
+	}
+
+	if isReservedName(stmt.Role) {
+		return withDetail(newError(errcodes.ReservedName, "role name \"%s\" is reserved", stmt.Role),
+			"Role names starting with \"pg_\" are reserved.")
+	}
+	if _, exists := catalog.SearchRole(stmt.Role); exists {
+		return newError(errcodes.DuplicateObject, "role \"%s\" already exists", stmt.Role)
+	}
+
+	// メンバーシップを加えるロールが存在し、与えてよいかを先に確かめる
+	addroleto, err := s.roleSpecsToRoles(o.addroleto)
+	if err != nil {
+		return err
+	}
+	for _, r := range addroleto {
+		if !catalog.IsAdminOfRole(current, r.Rolname) {
+			return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to grant role \"%s\"", r.Rolname),
+				"Only roles with the %s option on role \"%s\" may grant this role.", "ADMIN", r.Rolname)
+		}
+	}
+	rolemembers, err := s.roleSpecsToRoles(o.rolemembers)
+	if err != nil {
+		return err
+	}
+	adminmembers, err := s.roleSpecsToRoles(o.adminmembers)
+	if err != nil {
+		return err
+	}
+
+	if o.password != nil {
+		if role.Rolpassword, err = s.roleSecret(stmt.Role, o.password); err != nil {
+			return err
+		}
+	}
+	if o.validUntil != nil {
+		validUntil, err := adt.TimestamptzIn(o.validUntil.Arg.(*parser.String).Sval)
+		if err != nil {
+			return err
+		}
+		role.Rolvaliduntil, role.HasValidUntil = int64(validUntil), true
+	}
+
+	if _, ok := catalog.CreateRole(role); !ok {
+		return newError(errcodes.DuplicateObject, "role \"%s\" already exists", stmt.Role)
+	}
+
+	// スーパーユーザーでなければ、作ったロールを管理できるよう ADMIN OPTION を与える。
+	// 権限は引き継がない (createrole_self_grant の既定値相当)
+	if !catalog.IsSuperuser(current) {
+		catalog.GrantRole(current, stmt.Role, catalog.GrantOptions{Admin: true})
+	}
+	for _, r := range addroleto {
+		catalog.GrantRole(stmt.Role, r.Rolname, catalog.GrantOptions{Inherit: role.Rolinherit})
+	}
+	for _, r := range rolemembers {
+		catalog.GrantRole(r.Rolname, stmt.Role, catalog.GrantOptions{Inherit: r.Rolinherit})
+	}
+	for _, r := range adminmembers {
+		catalog.GrantRole(r.Rolname, stmt.Role, catalog.GrantOptions{Admin: true, Inherit: r.Rolinherit})
+	}
+	return nil
+}
+
+// roleSpecsToRoles はロールの並びのオプションから既存のロールを返す (roleSpecsToIds 相当)
+func (s *session) roleSpecsToRoles(d *parser.DefElem) ([]catalog.FormPgAuthid, error) {
+	if d == nil {
+		return nil, nil
+	}
+	var roles []catalog.FormPgAuthid
+	for _, spec := range d.Arg.([]*parser.RoleSpec) {
+		role, err := s.getRoleSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// roleSecret は PASSWORD オプションから保存するパスワードを作る。
+// PASSWORD NULL と空のパスワードは、パスワードを持たないことを表す空文字列にする。
+func (s *session) roleSecret(rolename string, d *parser.DefElem) (string, error) {
+	str, ok := d.Arg.(*parser.String)
+	if !ok {
+		return "", nil
+	}
+	// 空のパスワードと、空のパスワードを暗号化したものは保存しない
+	if str.Sval == "" || plainCryptVerify(rolename, str.Sval, "") == "" {
+		return "", s.reportNotice(newNotice("empty string is not a valid password, clearing password"))
+	}
+	return encryptPassword(s.passwordEncryption(), rolename, str.Sval, s.scramIterations())
+}
+
+// alterRole は ALTER ROLE 文を実行する (AlterRole 相当)
+func (s *session) alterRole(stmt *parser.AlterRoleStmt) error {
+	o, err := s.parseRoleOptions(stmt.Options)
+	if err != nil {
+		return err
+	}
+	role, err := s.getRoleSpec(stmt.Role)
+	if err != nil {
+		return err
+	}
+	rolename := role.Rolname
+
+	current := s.userName
+	super := catalog.IsSuperuser(current)
+	if role.Rolsuper && !super {
+		return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to alter role"),
+			"Only roles with the %s attribute may alter roles with the %s attribute.", "SUPERUSER", "SUPERUSER")
+	}
+	if o.issuper != nil && !super {
+		return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to alter role"),
+			"Only roles with the %s attribute may change the %s attribute.", "SUPERUSER", "SUPERUSER")
+	}
+	if o.issuper != nil && !boolOption(o.issuper) && role.Oid == catalog.BootstrapSuperuserID {
+		return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to alter role"),
+			"The bootstrap user must have the %s attribute.", "SUPERUSER")
+	}
+
+	if !haveCreateRolePrivilege(current) || !catalog.IsAdminOfRole(current, rolename) {
+		// 管理できないロールについては、自分自身のパスワードしか変更できない
+		if o.inherit != nil || o.createrole != nil || o.createdb != nil || o.canlogin != nil ||
+			o.connlimit != nil || o.validUntil != nil || o.isreplication != nil || o.bypassRLS != nil {
+			return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to alter role"),
+				"Only roles with the %s attribute and the %s option on role \"%s\" may alter this role.",
+				"CREATEROLE", "ADMIN", rolename)
+		}
+		if o.password != nil && rolename != current {
+			return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to alter role"),
+				"To change another role's password, the current user must have the %s attribute and the %s option on the role.",
+				"CREATEROLE", "ADMIN")
+		}
+	} else if !super {
+		// 管理できるロールにも、自分の持たない属性は与えられない
+		for _, attr := range []struct {
+			requested *parser.DefElem
+			have      bool
+			name      string
+		}{
+			{o.createdb, haveCreatedbPrivilege(current), "CREATEDB"},
+			{o.isreplication, false, "REPLICATION"},
+			{o.bypassRLS, false, "BYPASSRLS"},
+		} {
+			if attr.requested != nil && !attr.have {
+				return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to alter role"),
+					"Only roles with the %s attribute may change the %s attribute.", attr.name, attr.name)
+			}
+		}
+	}
+
+	var secret string
+	if o.password != nil {
+		if secret, err = s.roleSecret(rolename, o.password); err != nil {
+			return err
+		}
+	}
+	var validUntil adt.TimestampTz
+	if o.validUntil != nil {
+		if validUntil, err = adt.TimestamptzIn(o.validUntil.Arg.(*parser.String).Sval); err != nil {
+			return err
+		}
+	}
+
+	catalog.UpdateRole(rolename, func(r *catalog.FormPgAuthid) {
+		if o.issuper != nil {
+			r.Rolsuper = boolOption(o.issuper)
+		}
+		if o.inherit != nil {
+			r.Rolinherit = boolOption(o.inherit)
+		}
+		if o.createrole != nil {
+			r.Rolcreaterole = boolOption(o.createrole)
+		}
+		if o.createdb != nil {
+			r.Rolcreatedb = boolOption(o.createdb)
+		}
+		if o.canlogin != nil {
+			r.Rolcanlogin = boolOption(o.canlogin)
+		}
+		if o.isreplication != nil {
+			r.Rolreplication = boolOption(o.isreplication)
+		}
+		if o.bypassRLS != nil {
+			r.Rolbypassrls = boolOption(o.bypassRLS)
+		}
+		if o.connlimit != nil {
+			r.Rolconnlimit = intOption(o.connlimit)
+		}
+		if o.password != nil {
+			r.Rolpassword = secret
+		}
+		if o.validUntil != nil {
+			r.Rolvaliduntil, r.HasValidUntil = int64(validUntil), true
+		}
+	})
+	return nil
+}
+
+// alterRoleSet は ALTER ROLE の SET と RESET を実行する (AlterRoleSet 相当)。自分自身への設定は
+// 誰でも変更できる。全てのロールへの設定 (ALTER ROLE ALL) はスーパーユーザーだけが変更できる。
+func (s *session) alterRoleSet(stmt *parser.AlterRoleSetStmt) error {
+	current := s.userName
+	roleid := catalog.InvalidOid
+	if stmt.Role != nil {
+		role, err := s.getRoleSpec(stmt.Role)
+		if err != nil {
+			return err
+		}
+		if role.Rolsuper {
+			if !catalog.IsSuperuser(current) {
+				return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to alter role"),
+					"Only roles with the %s attribute may alter roles with the %s attribute.", "SUPERUSER", "SUPERUSER")
+			}
+		} else if (!haveCreateRolePrivilege(current) || !catalog.IsAdminOfRole(current, role.Rolname)) &&
+			role.Rolname != current {
+			return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to alter role"),
+				"Only roles with the %s attribute and the %s option on role \"%s\" may alter this role.",
+				"CREATEROLE", "ADMIN", role.Rolname)
+		}
+		roleid = role.Oid
+	} else if !catalog.IsSuperuser(current) {
+		return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to alter setting"),
+			"Only roles with the %s attribute may alter settings globally.", "SUPERUSER")
+	}
+	return s.alterSetting(stmt.Database, roleid, stmt.Setstmt)
+}
+
+// dropRole は DROP ROLE 文を実行する (DropRole 相当)。
+// トランザクションがないため、全てのロールを確かめてから削除する。
+func (s *session) dropRole(stmt *parser.DropRoleStmt) error {
+	current := s.userName
+	if !haveCreateRolePrivilege(current) {
+		return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to drop role"),
+			"Only roles with the %s attribute and the %s option on the target roles may drop roles.",
+			"CREATEROLE", "ADMIN")
+	}
+
+	var targets []string
+	for _, spec := range stmt.Roles {
+		if spec.RoleType != parser.RoleSpecCString {
+			return newError(errcodes.ReservedName, "cannot use special role specifier in DROP ROLE")
+		}
+		role, ok := catalog.SearchRole(spec.Rolename)
+		if !ok {
+			if !stmt.MissingOk {
+				return newError(errcodes.UndefinedObject, "role \"%s\" does not exist", spec.Rolename)
+			}
+			if err := s.reportNotice(newNotice("role \"%s\" does not exist, skipping", spec.Rolename)); err != nil {
+				return err
+			}
+			continue
+		}
+		switch {
+		case role.Rolname == current:
+			return newError(errcodes.ObjectInUse, "current user cannot be dropped")
+		case role.Oid < catalog.FirstNormalObjectID:
+			// ブートストラップスーパーユーザーと定義済みロール (IsPinnedObject 相当)
+			return newError(errcodes.DependentObjectsStillExist, "cannot drop role %s because it is required by the database system", role.Rolname)
+		case role.Rolsuper && !catalog.IsSuperuser(current):
+			return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to drop role"),
+				"Only roles with the %s attribute may drop roles with the %s attribute.", "SUPERUSER", "SUPERUSER")
+		case !catalog.IsAdminOfRole(current, role.Rolname):
+			return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to drop role"),
+				"Only roles with the %s attribute and the %s option on role \"%s\" may drop this role.",
+				"CREATEROLE", "ADMIN", role.Rolname)
+		}
+		// ロールが所有するオブジェクトがあれば削除できない (checkSharedDependencies 相当)
+		var detail []string
+		for _, rel := range catalog.RelationsOwnedBy(role.Oid) {
+			detail = append(detail, "owner of table "+rel.Relname)
+		}
+		for _, typ := range catalog.DomainsOwnedBy(role.Oid) {
+			detail = append(detail, "owner of type "+typ.Typname)
+		}
+		for _, op := range catalog.OperatorsOwnedBy(role.Oid) {
+			detail = append(detail, "owner of operator "+catalog.FormatOperator(op))
+		}
+		for _, opfamily := range catalog.OpfamiliesOwnedBy(role.Oid) {
+			detail = append(detail, "owner of "+opfamilyDescription(opfamily))
+		}
+		for _, opclass := range catalog.OpclassesOwnedBy(role.Oid) {
+			detail = append(detail, "owner of "+opclassDescription(opclass))
+		}
+		for _, d := range catalog.ListDefaultAcls() {
+			switch {
+			case d.Defaclrole == role.Oid:
+				detail = append(detail, "owner of "+defaultACLDescription(d))
+			case slices.Contains(catalog.AclRoles(d.Defaclacl), role.Oid):
+				detail = append(detail, "privileges for "+defaultACLDescription(d))
+			}
+		}
+		for _, typ := range catalog.DomainsGrantedTo(role.Oid) {
+			detail = append(detail, "privileges for type "+typ.Typname)
+		}
+		if len(detail) > 0 {
+			return withDetail(newError(errcodes.DependentObjectsStillExist, "role \"%s\" cannot be dropped because some objects depend on it", role.Rolname),
+				"%s", strings.Join(detail, "\n"))
+		}
+		targets = append(targets, role.Rolname)
+	}
+
+	for _, name := range targets {
+		catalog.DropRole(name)
+	}
+	return nil
+}
+
+// passwordEncryption は password_encryption で指定されたパスワードの形式を返す
+func (s *session) passwordEncryption() passwordType {
+	if s.gucs.GetEnum(guc.PasswordEncryption) == "md5" {
+		return passwordTypeMD5
+	}
+	return passwordTypeSCRAMSHA256
+}
+
+// scramIterations は scram_iterations で指定された反復回数を返す
+func (s *session) scramIterations() int {
+	return s.gucs.GetInt(guc.ScramIterations)
+}
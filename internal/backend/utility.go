@@ -0,0 +1,368 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/executor"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/postmaster/checkpointer"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// ユーティリティ文の実行 (tcop/utility.c, tcop/pquery.c 相当)
+// ----------------------------------------------------------------
+// SELECT 以外の文は計画も実行器も通さず、文の種類ごとの処理を直接呼ぶ。
+
+// portalStart はポータルの文の実行を始める (PortalStart 相当)。SELECT は実行器を準備し、
+// ユーティリティ文はその場で processUtility で実行して、結果をポータルに保持する。
+func (s *session) portalStart(p *portal) error {
+	query := p.stmt.query
+	s.reportQueryId(query.QueryId, false)
+	p.limit = s.newResultLimit()
+	if query.CommandType == parser.CmdUtility {
+		res, err := s.processUtility(query.UtilityStmt)
+		if err != nil {
+			p.status = portalFailed
+			return err
+		}
+		p.holdStore = res
+		return nil
+	}
+	qd := s.newQueryDesc(query, p.params)
+	if err := executor.ExecutorStart(qd); err != nil {
+		p.status = portalFailed
+		return err
+	}
+	p.queryDesc = qd
+	return nil
+}
+
+// portalRun はポータルの結果行を最大 count 行 dest に送る (PortalRun 相当)。count が 0 の場合は
+// 全ての行を送る。行を送り終えたら CommandComplete で送るコマンドタグを返し、count 行を送って
+// 中断した場合は空文字列を返す。PostgreSQL と同じく、ちょうど count 行で終わる場合も中断とみなす。
+// 行を返す文は最後の行を返した後も続けて実行でき、その場合は 0 行を返す。
+func (s *session) portalRun(p *portal, count uint64, dest executor.DestReceiver) (string, error) {
+	// 実行し終えた文やエラーになった文は、もう一度実行できない (MarkPortalActive 相当)
+	if p.status != portalReady {
+		return "", newError(errcodes.ObjectNotInPrerequisiteState, "portal \"%s\" cannot be run", p.name)
+	}
+
+	var nprocessed uint64
+	if p.holdStore != nil {
+		rows := p.holdStore.Rows
+		for ; p.pos < len(rows) && (count == 0 || nprocessed < count); p.pos++ {
+			if err := dest.ReceiveSlot(rows[p.pos]); err != nil {
+				p.status = portalFailed
+				return "", err
+			}
+			nprocessed++
+		}
+	} else {
+		qd := p.queryDesc
+		qd.Dest = dest
+		before := qd.Processed()
+		if err := executor.ExecutorRun(qd, count); err != nil {
+			p.status = portalFailed
+			return "", err
+		}
+		nprocessed = qd.Processed() - before
+	}
+
+	if count > 0 && nprocessed == count {
+		return "", nil
+	}
+	if p.holdStore != nil {
+		if p.holdStore.Desc == nil {
+			p.status = portalDone
+		}
+		return p.holdStore.CommandTag, nil
+	}
+	return fmt.Sprintf("SELECT %d", nprocessed), nil
+}
+
+// newQueryDesc は文をこのセッションで実行する QueryDesc を作る (CreateQueryDesc 相当)。
+// 結果の送り先は呼び出し元が設定する。
+func (s *session) newQueryDesc(query *parser.Query, params executor.ParamListInfo) *executor.QueryDesc {
+	return &executor.QueryDesc{
+		Query:      query,
+		Params:     params,
+		Interrupts: s.interrupts,
+		Caller:     s,
+		FuncStats:  s.pgstat,
+		Snapshot:   s.getTransactionSnapshot(),
+		CurrentXid: transam.XidFromFullTransactionId(s.xact.GetTopFullTransactionIdIfAny()),
+		Buffers:    s.buffers,
+		LockProc:   s.lockProc,
+		SyncScan:   s.gucs.GetBool(guc.SynchronizeSeqscans),
+	}
+}
+
+// processUtility はユーティリティ文を実行する (ProcessUtility / standard_ProcessUtility 相当)
+func (s *session) processUtility(stmt parser.Node) (*executor.Result, error) {
+	if classifyUtilityCommandAsReadOnly(stmt)&commandOKInReadOnlyTxn == 0 {
+		if err := s.preventCommandIfReadOnly(createCommandTag(stmt)); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	switch n := stmt.(type) {
+	case *parser.TransactionStmt:
+		return s.execTransactionStmt(n)
+	case *parser.CreateRoleStmt:
+		err = s.createRole(n)
+	case *parser.AlterRoleStmt:
+		err = s.alterRole(n)
+	case *parser.AlterRoleSetStmt:
+		err = s.alterRoleSet(n)
+	case *parser.DropRoleStmt:
+		err = s.dropRole(n)
+	case *parser.VariableSetStmt:
+		err = s.execSetVariableStmt(n)
+	case *parser.AlterSystemStmt:
+		// 設定ファイルの書き換えはロールバックできない
+		if err = s.xact.PreventInTransactionBlock("ALTER SYSTEM"); err == nil {
+			err = s.alterSystem(n)
+		}
+	case *parser.AlterDatabaseSetStmt:
+		err = s.alterDatabaseSet(n)
+	case *parser.CreateStmt:
+		err = s.defineRelation(n)
+	case *parser.AlterTableStmt:
+		err = s.alterTable(n)
+	case *parser.CreateTableAsStmt:
+		return s.execCreateTableAs(n)
+	case *parser.CreateDomainStmt:
+		err = s.createDomain(n)
+	case *parser.AlterDomainStmt:
+		err = s.alterDomain(n)
+	case *parser.RenameStmt:
+		if n.RenameType == parser.ObjectOpclass || n.RenameType == parser.ObjectOpfamily {
+			err = s.renameOpClassOrFamily(n)
+		} else {
+			err = s.renameDomain(n)
+		}
+	case *parser.AlterOwnerStmt:
+		switch n.ObjectType {
+		case parser.ObjectOperator:
+			err = s.alterOperatorOwner(n)
+		case parser.ObjectOpclass, parser.ObjectOpfamily:
+			err = s.alterOpClassOrFamilyOwner(n)
+		default:
+			err = s.alterDomainOwner(n)
+		}
+	case *parser.CreateCastStmt:
+		err = s.createCast(n)
+	case *parser.DefineStmt:
+		err = s.defineOperator(n)
+	case *parser.AlterOperatorStmt:
+		err = s.alterOperator(n)
+	case *parser.CreateOpClassStmt:
+		err = s.defineOpClass(n)
+	case *parser.CreateOpFamilyStmt:
+		err = s.defineOpFamily(n)
+	case *parser.AlterOpFamilyStmt:
+		err = s.alterOpFamily(n)
+	case *parser.DropStmt:
+		switch n.RemoveType {
+		case parser.ObjectCast:
+			err = s.dropCast(n)
+		case parser.ObjectOperator:
+			err = s.removeOperators(n)
+		case parser.ObjectOpclass, parser.ObjectOpfamily:
+			err = s.removeOpClassesOrFamilies(n)
+		case parser.ObjectTable:
+			err = s.removeRelations(n)
+		default:
+			err = s.dropDomain(n)
+		}
+	case *parser.AlterDefaultPrivilegesStmt:
+		err = s.execAlterDefaultPrivileges(n)
+	case *parser.AlterFunctionStmt:
+		err = s.alterFunction(n)
+	case *parser.CheckPointStmt:
+		err = s.execCheckPoint()
+	case *parser.VariableShowStmt:
+		return s.getPGVariable(n.Name)
+	default:
+		return nil, fmt.Errorf("unrecognized node type: %T", stmt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &executor.Result{CommandTag: createCommandTag(stmt)}, nil
+}
+
+// queryCommandTag は解析済みの文のコマンドタグを返す (CreateCommandTag 相当)
+func queryCommandTag(query *parser.Query) string {
+	if query.CommandType == parser.CmdUtility {
+		return createCommandTag(query.UtilityStmt)
+	}
+	return "SELECT"
+}
+
+// createCommandTag は解析する前の文のコマンドタグを返す (CreateCommandTag 相当)
+func createCommandTag(stmt parser.Node) string {
+	switch n := stmt.(type) {
+	case *parser.TransactionStmt:
+		switch n.Kind {
+		case parser.TransStmtBegin:
+			return "BEGIN"
+		case parser.TransStmtStart:
+			return "START TRANSACTION"
+		case parser.TransStmtCommit:
+			return "COMMIT"
+		case parser.TransStmtSavepoint:
+			return "SAVEPOINT"
+		case parser.TransStmtRelease:
+			return "RELEASE"
+		case parser.TransStmtPrepare:
+			return "PREPARE TRANSACTION"
+		case parser.TransStmtCommitPrepared:
+			return "COMMIT PREPARED"
+		case parser.TransStmtRollbackPrepared:
+			return "ROLLBACK PREPARED"
+		}
+		return "ROLLBACK"
+	case *parser.CreateRoleStmt:
+		return "CREATE ROLE"
+	case *parser.AlterRoleStmt, *parser.AlterRoleSetStmt:
+		return "ALTER ROLE"
+	case *parser.DropRoleStmt:
+		return "DROP ROLE"
+	case *parser.VariableSetStmt:
+		if n.Kind == parser.VarReset || n.Kind == parser.VarResetAll {
+			return "RESET"
+		}
+		return "SET"
+	case *parser.AlterSystemStmt:
+		return "ALTER SYSTEM"
+	case *parser.AlterDatabaseSetStmt:
+		return "ALTER DATABASE"
+	case *parser.CheckPointStmt:
+		return "CHECKPOINT"
+	case *parser.AlterDefaultPrivilegesStmt:
+		return "ALTER DEFAULT PRIVILEGES"
+	case *parser.AlterFunctionStmt:
+		return "ALTER " + objectTypeName(n.Objtype)
+	case *parser.CreateDomainStmt:
+		return "CREATE DOMAIN"
+	case *parser.AlterDomainStmt:
+		return "ALTER DOMAIN"
+	case *parser.RenameStmt:
+		return "ALTER " + objectTypeName(n.RenameType)
+	case *parser.AlterOwnerStmt:
+		return "ALTER " + objectTypeName(n.ObjectType)
+	case *parser.CreateCastStmt:
+		return "CREATE CAST"
+	case *parser.DefineStmt:
+		return "CREATE OPERATOR"
+	case *parser.AlterOperatorStmt:
+		return "ALTER OPERATOR"
+	case *parser.CreateOpClassStmt:
+		return "CREATE OPERATOR CLASS"
+	case *parser.CreateOpFamilyStmt:
+		return "CREATE OPERATOR FAMILY"
+	case *parser.AlterOpFamilyStmt:
+		return "ALTER OPERATOR FAMILY"
+	case *parser.DropStmt:
+		return "DROP " + objectTypeName(n.RemoveType)
+	case *parser.CreateStmt:
+		return "CREATE TABLE"
+	case *parser.AlterTableStmt:
+		return "ALTER TABLE"
+	case *parser.CreateTableAsStmt:
+		if n.IsSelectInto {
+			return "SELECT INTO"
+		}
+		return "CREATE TABLE AS"
+	case *parser.VariableShowStmt:
+		return "SHOW"
+	case *parser.SelectStmt:
+		return "SELECT"
+	}
+	return "???"
+}
+
+// objectTypeName はコマンドタグに使うオブジェクトの種類の名前を返す (AlterObjectTypeCommandTag 相当)
+func objectTypeName(objtype parser.ObjectType) string {
+	switch objtype {
+	case parser.ObjectCast:
+		return "CAST"
+	case parser.ObjectFunction:
+		return "FUNCTION"
+	case parser.ObjectProcedure:
+		return "PROCEDURE"
+	case parser.ObjectRoutine:
+		return "ROUTINE"
+	case parser.ObjectOperator:
+		return "OPERATOR"
+	case parser.ObjectOpclass:
+		return "OPERATOR CLASS"
+	case parser.ObjectOpfamily:
+		return "OPERATOR FAMILY"
+	case parser.ObjectTable:
+		return "TABLE"
+	}
+	// ドメインの制約もドメインの変更として扱う
+	return "DOMAIN"
+}
+
+// 文をどの状況で実行できるかを表すフラグ (COMMAND_OK_IN_* 相当)
+const (
+	// commandOKInReadOnlyTxn は読み取り専用のトランザクションで実行できる
+	commandOKInReadOnlyTxn = 1 << iota
+	// commandOKInParallelMode は並列処理の実行中に実行できる
+	commandOKInParallelMode
+	// commandOKInRecovery はリカバリ中 (ホットスタンバイ) に実行できる
+	commandOKInRecovery
+
+	commandIsStrictlyReadOnly = commandOKInReadOnlyTxn | commandOKInParallelMode | commandOKInRecovery
+	commandIsNotReadOnly      = 0
+)
+
+// classifyUtilityCommandAsReadOnly はユーティリティ文を実行できる状況を返す
+// (ClassifyUtilityCommandAsReadOnly 相当)。カタログを変更する文は読み取り専用ではない。
+// ALTER SYSTEM はファイルを書くが、データベースの内容を変えないため読み取り専用とみなす。
+func classifyUtilityCommandAsReadOnly(stmt parser.Node) int {
+	switch stmt.(type) {
+	case *parser.CreateRoleStmt, *parser.AlterRoleStmt, *parser.AlterRoleSetStmt,
+		*parser.DropRoleStmt, *parser.AlterDatabaseSetStmt, *parser.CreateStmt, *parser.AlterTableStmt,
+		*parser.CreateTableAsStmt, *parser.CreateDomainStmt, *parser.AlterDomainStmt, *parser.RenameStmt,
+		*parser.AlterOwnerStmt, *parser.CreateCastStmt, *parser.DropStmt, *parser.DefineStmt,
+		*parser.AlterOperatorStmt, *parser.CreateOpClassStmt, *parser.CreateOpFamilyStmt,
+		*parser.AlterOpFamilyStmt, *parser.AlterDefaultPrivilegesStmt, *parser.AlterFunctionStmt:
+		return commandIsNotReadOnly
+	case *parser.TransactionStmt, *parser.CheckPointStmt:
+		return commandOKInReadOnlyTxn | commandOKInRecovery
+	}
+	return commandIsStrictlyReadOnly
+}
+
+// utilityTupleDescriptor はユーティリティ文が返す行の列定義を返す (UtilityTupleDescriptor 相当)。
+// 行を返さない文では nil を返す。
+func utilityTupleDescriptor(stmt parser.Node) executor.TupleDesc {
+	switch n := stmt.(type) {
+	case *parser.VariableShowStmt:
+		return getPGVariableResultDesc(n.Name)
+	}
+	return nil
+}
+
+// execCheckPoint は CHECKPOINT を実行する (standard_ProcessUtility の T_CheckPointStmt の処理相当)。
+// checkpointer に急ぐチェックポイントを要求し、終わるまで待つ。
+func (s *session) execCheckPoint() error {
+	if !catalog.HasPrivsOfRole(s.userName, catalog.RolePgCheckpoint) {
+		return withDetail(newError(errcodes.InsufficientPrivilege, "permission denied to execute %s command", "CHECKPOINT"),
+			"Only roles with privileges of the \"%s\" role may execute this command.", catalog.RolePgCheckpoint)
+	}
+	err := checkpointer.RequestCheckpoint(transam.CheckpointImmediate|transam.CheckpointWait|transam.CheckpointForce, s.interrupts, s.waitEvent)
+	if errors.Is(err, checkpointer.ErrCheckpointFailed) {
+		return withHint(newError(errcodes.InternalError, "%s", err), "Consult recent messages in the server log for details.")
+	}
+	return err
+}
@@ -0,0 +1,222 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam/xact"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/executor"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/procarray"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/snapmgr"
+)
+
+// ----------------------------------------------------------------
+// トランザクションの特性と資源 (access/transam/xact.c の一部相当)
+// ----------------------------------------------------------------
+// トランザクションの状態は xact.TransactionState が持ち、セッションは xact.Callbacks として
+// トランザクションの開始と終了で資源を準備し、後始末する。トランザクションの開始時に分離レベルと
+// 読み取り専用かどうかを default_transaction_* の値にし、SET TRANSACTION でそのトランザクションの
+// 間だけ変更できる。
+//
+// トランザクションの中で SET したパラメータは、トランザクションがエラーで終わると元の値に戻す。
+//
+// 文はスナップショットで行を選ぶ。READ COMMITTED では文の開始ごとに、REPEATABLE READ 以上では
+// トランザクションで最初の文の開始時に取る。スナップショットには実行中のコマンドの ID を入れ、
+// 同じトランザクションの前の文が変更した行を見えるようにする。
+//
+// 読み取り専用のトランザクションでは、データを変更する文を実行できない。ロールの作成などの
+// カタログを変更するユーティリティ文もこれに含まれる。
+
+// initXact はセッションがトランザクションを使えるようにし、終了時に実行中のトランザクションを
+// アボートする後始末を登録する (ShutdownPostgres の AbortOutOfAnyTransaction 相当)。終了時に
+// アボートを記録できなければ、サーバー全体を異常終了させる。
+func (s *session) initXact() {
+	s.xact = xact.New(s.pid, s)
+	s.onExit(func() {
+		if err := s.xact.AbortOutOfAnyTransaction(); err != nil {
+			edata := makeErrorData(errutil.Panic, err, "")
+			errutil.Report(s.logProc, edata)
+			panic(edata)
+		}
+	})
+}
+
+// AtStartTransaction はトランザクションの特性を既定値にする (StartTransaction の
+// XactIsoLevel などの設定と AtStart_GUC 相当、xact.Callbacks)
+func (s *session) AtStartTransaction() {
+	for _, c := range []struct {
+		name  string
+		value string
+	}{
+		{guc.TransactionIsolation.Name, s.gucs.GetEnum(guc.DefaultTransactionIsolation)},
+		{guc.TransactionReadOnly.Name, boolString(s.gucs.GetBool(guc.DefaultTransactionReadOnly))},
+		{guc.TransactionDeferrable.Name, boolString(s.gucs.GetBool(guc.DefaultTransactionDeferrable))},
+	} {
+		// 既定値は妥当な値であることが確かめてあるため、失敗しない
+		_ = s.gucs.SetConfigOption(c.name, c.value, guc.PGCSuset, guc.PGCSSession, guc.GucActionSet)
+	}
+	s.gucs.AtStartGUC()
+	s.reportXactTimestamp(time.Now())
+}
+
+// AtCommitTransaction はコミットしたトランザクションの資源を放す (CommitTransaction の
+// AtEOXact_* の呼び出し相当、xact.Callbacks)
+func (s *session) AtCommitTransaction() {
+	s.xactSnapshot = nil
+	s.pgstat.AtEOXact(true)
+	s.reportXactTimestamp(time.Time{})
+	s.gucs.AtEOXactGUC(true)
+	s.atEOXactPortals()
+	s.atEOXactRelations(true)
+	s.smgrDoPendingDeletes(true)
+	s.tempFiles.AtEOXact()
+	s.lockProc.LockReleaseAll(false)
+}
+
+// AtAbortTransaction はアボートしたトランザクションの資源を放す (AbortTransaction の
+// AtAbort_* と AtEOXact_* の呼び出し相当、xact.Callbacks)。トランザクションの中でしたパラメータの
+// 変更を取り消す。エラーで途中になった処理が持っていた軽量ロックを最初に放し、残った共有バッファの
+// ピンを外す。
+func (s *session) AtAbortTransaction() {
+	s.lockProc.LWLockReleaseAll()
+	s.buffers.ReleaseAll()
+	s.xactSnapshot = nil
+	s.pgstat.AtEOXact(false)
+	s.reportXactTimestamp(time.Time{})
+	s.gucs.AtEOXactGUC(false)
+	s.atEOXactPortals()
+	s.atEOXactRelations(false)
+	s.smgrDoPendingDeletes(false)
+	s.tempFiles.AtEOXact()
+	s.lockProc.LockReleaseAll(false)
+}
+
+// getTransactionSnapshot は文が使うスナップショットを返す (GetTransactionSnapshot 相当)。
+// READ COMMITTED では文ごとに取り直し、REPEATABLE READ と SERIALIZABLE ではトランザクションで
+// 最初に取ったものを使い続ける。SERIALIZABLE の述語ロックはまだないため、REPEATABLE READ と
+// 同じに扱う。使い続けるスナップショットも、コマンド ID は実行中のコマンドのものにする
+// (SnapshotSetCommandId 相当)。
+func (s *session) getTransactionSnapshot() *snapmgr.Snapshot {
+	curcid := s.xact.GetCurrentCommandId(false)
+	if s.xactSnapshot != nil {
+		// 前の文のポータルが持つスナップショットは変えない
+		snapshot := *s.xactSnapshot
+		snapshot.Curcid = curcid
+		return &snapshot
+	}
+	snapshot := procarray.GetSnapshotData(s.pid, curcid)
+	if s.isolationUsesXactSnapshot() {
+		s.xactSnapshot = snapshot
+	}
+	return snapshot
+}
+
+// isolationUsesXactSnapshot はトランザクションの分離レベルがトランザクションの間同じ
+// スナップショットを使うものかを返す (IsolationUsesXactSnapshot 相当)
+func (s *session) isolationUsesXactSnapshot() bool {
+	switch s.gucs.GetEnum(guc.TransactionIsolation) {
+	case "repeatable read", "serializable":
+		return true
+	}
+	return false
+}
+
+// execTransactionStmt はトランザクションを制御する文を実行する (standard_ProcessUtility の
+// T_TransactionStmt の処理相当)。トランザクションの開始と終了は、文の後の finishXactCommand が
+// 行う。エラーになったトランザクションブロックの COMMIT はロールバックし、コマンドタグを
+// ROLLBACK にする。
+//
+// サブトランザクションと2相コミットはまだないため、セーブポイント、AND CHAIN と PREPARE
+// TRANSACTION などは状態を変えずにエラーにする。
+func (s *session) execTransactionStmt(stmt *parser.TransactionStmt) (*executor.Result, error) {
+	if err := checkTransactionStmtSupported(stmt); err != nil {
+		return nil, err
+	}
+	tag := createCommandTag(stmt)
+	var warning *errutil.ErrorData
+	var err error
+	switch stmt.Kind {
+	case parser.TransStmtBegin, parser.TransStmtStart:
+		if warning, err = s.xact.BeginTransactionBlock(); warning == nil && err == nil {
+			// BEGIN に書いたトランザクションの特性は SET TRANSACTION と同じに設定する
+			for _, mode := range stmt.Options {
+				if err := s.setTransactionMode(mode, ""); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case parser.TransStmtCommit:
+		var committed bool
+		if committed, warning, err = s.xact.EndTransactionBlock(); err == nil && !committed {
+			tag = "ROLLBACK"
+		}
+	case parser.TransStmtRollback:
+		warning, err = s.xact.UserAbortTransactionBlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if warning != nil {
+		if err := s.reportNotice(warning); err != nil {
+			return nil, err
+		}
+	}
+	return &executor.Result{CommandTag: tag}, nil
+}
+
+// checkTransactionStmtSupported は stmt がまだ実行できない文であればエラーを返す
+func checkTransactionStmtSupported(stmt *parser.TransactionStmt) error {
+	switch stmt.Kind {
+	case parser.TransStmtSavepoint, parser.TransStmtRelease, parser.TransStmtRollbackTo:
+		return withDetail(newError(errcodes.FeatureNotSupported, "savepoints are not supported"),
+			"Subtransactions are not implemented.")
+	case parser.TransStmtPrepare, parser.TransStmtCommitPrepared, parser.TransStmtRollbackPrepared:
+		return withDetail(newError(errcodes.FeatureNotSupported, "%s is not supported", createCommandTag(stmt)),
+			"Two-phase commit is not implemented.")
+	}
+	if stmt.Chain {
+		return newError(errcodes.FeatureNotSupported, "%s AND CHAIN is not supported", createCommandTag(stmt))
+	}
+	return nil
+}
+
+// setTransactionCharacteristics は SET TRANSACTION と SET SESSION CHARACTERISTICS AS TRANSACTION を
+// 実行する (ExecSetVariableStmt の VAR_SET_MULTI の処理相当)。SET TRANSACTION は実行中の
+// トランザクションにだけ効くため、トランザクションブロックの外では警告を出す。
+func (s *session) setTransactionCharacteristics(stmt *parser.VariableSetStmt) error {
+	prefix := ""
+	if stmt.Name == "TRANSACTION" {
+		if warning := s.xact.WarnNoTransactionBlock("SET TRANSACTION"); warning != nil {
+			if err := s.reportNotice(warning); err != nil {
+				return err
+			}
+		}
+	} else {
+		prefix = "default_"
+	}
+	for _, mode := range stmt.Options {
+		if err := s.setTransactionMode(mode, prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTransactionMode はトランザクションの特性を1つ設定する。prefix が "default_" なら
+// セッションの既定値を設定する。
+func (s *session) setTransactionMode(mode *parser.DefElem, prefix string) error {
+	value := flattenSetVariableArgs([]*parser.AConst{mode.Arg.(*parser.AConst)})
+	return s.gucs.SetConfigOption(prefix+mode.Defname, value, s.gucContext(), guc.PGCSSession, guc.GucActionSet)
+}
+
+// preventCommandIfReadOnly は読み取り専用のトランザクションであればエラーを返す
+// (PreventCommandIfReadOnly 相当)。cmdname はコマンドタグ。
+func (s *session) preventCommandIfReadOnly(cmdname string) error {
+	if s.gucs.GetBool(guc.TransactionReadOnly) {
+		return newError(errcodes.ReadOnlySQLTransaction, "cannot execute %s in a read-only transaction", cmdname)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package backend
+
+import (
+	"math"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/procarray"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+)
+
+// ----------------------------------------------------------------
+// トランザクション ID を調べる関数 (utils/adt/xid8funcs.c、xid.c の xid_age、mxid_age 相当)
+// ----------------------------------------------------------------
+// 実行中のトランザクションの ID と、過去のトランザクションの状態を返す。txid_ で始まる関数は
+// xid8 の代わりに bigint を使う以前の版の名前である。age と mxid_age は ID が周回するまでの
+// 余裕を監視するために使う。
+
+func init() {
+	fmgr.Register("xid_age", xidAge)
+	fmgr.Register("mxid_age", mxidAge)
+	fmgr.Register("xid8toxid", xid8ToXid)
+	fmgr.Register("pg_current_xact_id", pgCurrentXactID)
+	fmgr.Register("pg_current_xact_id_if_assigned", pgCurrentXactIDIfAssigned)
+	fmgr.Register("pg_xact_status", pgXactStatus)
+	fmgr.Register("txid_current", txidCurrent)
+	fmgr.Register("txid_current_if_assigned", txidCurrentIfAssigned)
+	fmgr.Register("txid_status", txidStatus)
+}
+
+// xidAge は現在のトランザクションから見た xid の古さを返す (xid_age 相当)。
+// 予約した ID は常に最も古いものとして扱う。
+func xidAge(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	xid := fcinfo.Args[0].(adt.TransactionId)
+	if !transam.TransactionIdIsNormal(xid) {
+		return int32(math.MaxInt32), nil
+	}
+	return int32(s.xact.GetStableLatestTransactionId() - xid), nil
+}
+
+// mxidAge は次に割り当てるマルチトランザクション ID から見た古さを返す (mxid_age 相当)
+func mxidAge(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	multi := fcinfo.Args[0].(adt.TransactionId)
+	if !transam.MultiXactIdIsValid(multi) {
+		return int32(math.MaxInt32), nil
+	}
+	return int32(transam.ReadNextMultiXactId() - multi), nil
+}
+
+// xid8ToXid は xid8 の下位32ビットを返す (xid8toxid 相当)
+func xid8ToXid(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	return transam.XidFromFullTransactionId(fcinfo.Args[0].(adt.FullTransactionId)), nil
+}
+
+// pgCurrentXactID は実行中のトランザクションの ID を返す。まだ割り当てていなければ割り当てる
+// (pg_current_xact_id 相当)。
+func pgCurrentXactID(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	return s.xact.GetTopFullTransactionId()
+}
+
+// pgCurrentXactIDIfAssigned は実行中のトランザクションの ID を返す。割り当てていなければ NULL
+// (pg_current_xact_id_if_assigned 相当)。
+func pgCurrentXactIDIfAssigned(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	fxid := s.xact.GetTopFullTransactionIdIfAny()
+	if !transam.FullTransactionIdIsValid(fxid) {
+		return nil, nil
+	}
+	return fxid, nil
+}
+
+// pgXactStatus はトランザクションの状態を "in progress"、"committed"、"aborted" のいずれかで返す
+// (pg_xact_status 相当)。状態が分からないほど古い ID では NULL を返す。
+func pgXactStatus(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	return s.xactStatus(fcinfo.Args[0].(adt.FullTransactionId))
+}
+
+// txidCurrent は pg_current_xact_id を bigint で返す (txid_current 相当)
+func txidCurrent(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	fxid, err := s.xact.GetTopFullTransactionId()
+	if err != nil {
+		return nil, err
+	}
+	return int64(fxid), nil
+}
+
+// txidCurrentIfAssigned は pg_current_xact_id_if_assigned を bigint で返す
+// (txid_current_if_assigned 相当)
+func txidCurrentIfAssigned(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	fxid := s.xact.GetTopFullTransactionIdIfAny()
+	if !transam.FullTransactionIdIsValid(fxid) {
+		return nil, nil
+	}
+	return int64(fxid), nil
+}
+
+// txidStatus は bigint で指定した ID の pg_xact_status を返す (txid_status 相当)
+func txidStatus(fcinfo *fmgr.FunctionCallInfo) (adt.Datum, error) {
+	s, err := callerSession(fcinfo)
+	if err != nil {
+		return nil, err
+	}
+	return s.xactStatus(adt.FullTransactionId(fcinfo.Args[0].(int64)))
+}
+
+// xactStatus は pg_xact_status と txid_status の処理の本体
+func (s *session) xactStatus(fxid adt.FullTransactionId) (adt.Datum, error) {
+	xid, ok, err := transactionIdInRecentPast(fxid)
+	if err != nil || !ok {
+		return nil, err
+	}
+	// 実行中の一覧を状態の記録より先に見る。終わるトランザクションは記録してから一覧から外れる
+	if s.xact.GetTopFullTransactionIdIfAny() == fxid || procarray.TransactionIdIsInProgress(xid) {
+		return "in progress", nil
+	}
+	committed, err := transam.TransactionIdDidCommit(xid)
+	if err != nil {
+		return nil, err
+	}
+	if committed {
+		return "committed", nil
+	}
+	// アボートした、または実行中のままセッションが終わった
+	return "aborted", nil
+}
+
+// transactionIdInRecentPast は fxid の状態をまだ調べられるかを返す (TransactionIdInRecentPast 相当)。
+// 未来の ID はエラーにする。予約した ID は epoch に関係なく調べられる。
+func transactionIdInRecentPast(fxid adt.FullTransactionId) (adt.TransactionId, bool, error) {
+	xid := transam.XidFromFullTransactionId(fxid)
+	if !transam.TransactionIdIsValid(xid) {
+		return xid, false, nil
+	}
+	if !transam.TransactionIdIsNormal(xid) {
+		return xid, true, nil
+	}
+	now := transam.ReadNextFullTransactionId()
+	if fxid >= now {
+		return xid, false, newError(errcodes.InvalidParameterValue, "transaction ID %d is in the future", uint64(fxid))
+	}
+
+	// 状態を覚えている最も古い ID を、now から 2^31 以内にある epoch で64ビットにして比べる
+	oldestXid := transam.OldestClogXid()
+	epoch := transam.EpochFromFullTransactionId(now)
+	if oldestXid > transam.XidFromFullTransactionId(now) {
+		epoch--
+	}
+	return xid, fxid >= transam.FullTransactionIdFromEpochAndXid(epoch, oldestXid), nil
+}
@@ -0,0 +1,253 @@
+package bootstrap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// BKI の字句解析と構文解析 (bootstrap/bootscanner.l、bootparse.y 相当)
+// ----------------------------------------------------------------
+// BKI は1行に1つの命令を書く。扱う命令は次のとおり。# で始まる行は注釈として読み飛ばす。
+//
+//	create <カタログ名> <OID> [shared_relation] ( <列名> = <型名> , ... )
+//	open <カタログ名>
+//	insert ( <値> ... )
+//	close <カタログ名>
+//
+// create の列の定義は複数の行に分けてよい。値は英数字と "_"、"-" だけならそのまま書き、
+// それ以外は単一引用符で囲んで "\" でエスケープする。_null_ は NULL を表す。
+
+// token は BKI の字句
+type token struct {
+	text string
+	// quoted は単一引用符で囲んだ値であることを表す
+	quoted bool
+	line   int
+}
+
+// scan は BKI を字句に分ける (boot_yylex 相当)
+func scan(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '(' || c == ')' || c == ',' || c == '=':
+			tokens = append(tokens, token{text: string(c), line: line})
+			i++
+		case c == '\'':
+			var b strings.Builder
+			start := line
+			for i++; ; i++ {
+				if i >= len(src) {
+					return nil, fmt.Errorf("syntax error at line %d: unterminated quoted string", start)
+				}
+				if src[i] == '\'' {
+					i++
+					break
+				}
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				if src[i] == '\n' {
+					line++
+				}
+				b.WriteByte(src[i])
+			}
+			tokens = append(tokens, token{text: b.String(), quoted: true, line: start})
+		case isIdentChar(c):
+			start := i
+			for i < len(src) && isIdentChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{text: src[start:i], line: line})
+		default:
+			return nil, fmt.Errorf("syntax error at line %d: unexpected character %q", line, c)
+		}
+	}
+	return tokens, nil
+}
+
+func isIdentChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-' || c == '.'
+}
+
+// parser は字句を読み、命令を state に対して実行する (boot_yyparse 相当)
+type parser struct {
+	tokens []token
+	pos    int
+	st     *state
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) next() (token, error) {
+	tok, ok := p.peek()
+	if !ok {
+		line := 1
+		if len(p.tokens) > 0 {
+			line = p.tokens[len(p.tokens)-1].line
+		}
+		return token{}, fmt.Errorf("syntax error at line %d: unexpected end of input", line)
+	}
+	p.pos++
+	return tok, nil
+}
+
+// expect は次の字句が記号 s であることを確かめる
+func (p *parser) expect(s string) error {
+	tok, err := p.next()
+	if err != nil {
+		return err
+	}
+	if tok.quoted || tok.text != s {
+		return fmt.Errorf("syntax error at line %d: expected \"%s\" but found \"%s\"", tok.line, s, tok.text)
+	}
+	return nil
+}
+
+// ident は名前の字句を読む
+func (p *parser) ident() (token, error) {
+	tok, err := p.next()
+	if err != nil {
+		return tok, err
+	}
+	if tok.quoted || !isIdentChar(tok.text[0]) {
+		return tok, fmt.Errorf("syntax error at line %d: expected a name but found \"%s\"", tok.line, tok.text)
+	}
+	return tok, nil
+}
+
+func (p *parser) parse() error {
+	for {
+		tok, ok := p.peek()
+		if !ok {
+			return nil
+		}
+		p.pos++
+		var err error
+		switch {
+		case tok.quoted:
+			err = fmt.Errorf("syntax error at line %d: unexpected \"%s\"", tok.line, tok.text)
+		case tok.text == "create":
+			err = p.parseCreate(tok.line)
+		case tok.text == "open":
+			err = p.parseOpen(tok.line)
+		case tok.text == "insert":
+			err = p.parseInsert(tok.line)
+		case tok.text == "close":
+			err = p.parseClose(tok.line)
+		default:
+			err = fmt.Errorf("syntax error at line %d: unrecognized command \"%s\"", tok.line, tok.text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseCreate は create を読む (Boot_CreateStmt 相当)
+func (p *parser) parseCreate(line int) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	oidTok, err := p.ident()
+	if err != nil {
+		return err
+	}
+	shared := false
+	if tok, ok := p.peek(); ok && !tok.quoted && tok.text == "shared_relation" {
+		shared = true
+		p.pos++
+	}
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	var cols []columnDef
+	for {
+		colName, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if err := p.expect("="); err != nil {
+			return err
+		}
+		typName, err := p.ident()
+		if err != nil {
+			return err
+		}
+		cols = append(cols, columnDef{name: colName.text, typname: typName.text, line: typName.line})
+		tok, err := p.next()
+		if err != nil {
+			return err
+		}
+		if tok.text == ")" && !tok.quoted {
+			break
+		}
+		if tok.text != "," || tok.quoted {
+			return fmt.Errorf("syntax error at line %d: expected \",\" or \")\" but found \"%s\"", tok.line, tok.text)
+		}
+	}
+	return p.st.createRel(line, name.text, oidTok.text, shared, cols)
+}
+
+// parseOpen は open を読む (Boot_OpenStmt 相当)
+func (p *parser) parseOpen(line int) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	return p.st.openRel(line, name.text)
+}
+
+// parseClose は close を読む (Boot_CloseStmt 相当)
+func (p *parser) parseClose(line int) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	return p.st.closeRel(line, name.text)
+}
+
+// parseInsert は insert を読む (Boot_InsertStmt 相当)
+func (p *parser) parseInsert(line int) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	var values []*string
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return err
+		}
+		if tok.text == ")" && !tok.quoted {
+			break
+		}
+		switch {
+		case !tok.quoted && tok.text == nullValue:
+			values = append(values, nil)
+		case tok.quoted || isIdentChar(tok.text[0]):
+			v := tok.text
+			values = append(values, &v)
+		default:
+			return fmt.Errorf("syntax error at line %d: unexpected \"%s\"", tok.line, tok.text)
+		}
+	}
+	return p.st.insertOneTuple(line, values)
+}
@@ -0,0 +1,243 @@
+package bootstrap
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/backend"
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/common/relpath"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// ブートストラップ (bootstrap/bootstrap.c 相当)
+// ----------------------------------------------------------------
+// initdb が "postgres boot" として起動し、標準入力から BKI (postgres.bki) を読んで、
+// システムカタログの最初の内容をデータディレクトリに書く。
+//
+//  1. データディレクトリを確かめ、制御ファイルを作る
+//  2. BKI の命令を順に実行し、カタログの行を集める
+//  3. 共有カタログを global/ に、それ以外のカタログを template1 (base/1) に書く
+//
+// 値は列の型の入力関数で確かめてから、出力関数のテキスト表現にして書く。途中でエラーに
+// なった場合は何も書かずに終わり、initdb がデータディレクトリを片付ける。
+
+// nullValue は BKI で NULL を表す値
+const nullValue = "_null_"
+
+// columnDef はカタログの列の定義 (attrtypes の要素相当)
+type columnDef struct {
+	name    string
+	typname string
+	typid   catalog.Oid
+	line    int
+}
+
+// relation はブートストラップで作るカタログ
+type relation struct {
+	name   string
+	relid  catalog.Oid
+	shared bool
+	cols   []columnDef
+	tuples []catalog.CatalogTuple
+}
+
+// state はブートストラップの処理の状態
+type state struct {
+	rels  map[string]*relation
+	order []*relation
+	// boot はいま開いているカタログ (boot_reldesc 相当)
+	boot *relation
+}
+
+// BootstrapModeMain はブートストラップを実行する (BootstrapModeMain 相当)。データディレクトリは
+// data_directory で指定する。dataChecksums が true ならデータページのチェックサムを使う
+// クラスタにする。
+func BootstrapModeMain(dataChecksums bool, in io.Reader) error {
+	if err := guc.SelectConfigFiles(); err != nil {
+		return err
+	}
+	dataDir := guc.DataDirectory.Get()
+	if dataDir == "" {
+		return fmt.Errorf("no data directory specified\nHINT:  Specify the data directory with -D.")
+	}
+	if err := miscadmin.CheckDataDir(dataDir); err != nil {
+		return err
+	}
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return fmt.Errorf("could not read bootstrap input: %w", err)
+	}
+
+	var checksumVersion uint32
+	if dataChecksums {
+		checksumVersion = catalog.PgDataChecksumVersion
+	}
+	if err := transam.BootStrapXLOG(dataDir, checksumVersion); err != nil {
+		return err
+	}
+
+	tokens, err := scan(string(src))
+	if err != nil {
+		return err
+	}
+	st := &state{rels: make(map[string]*relation)}
+	p := &parser{tokens: tokens, st: st}
+	if err := p.parse(); err != nil {
+		return err
+	}
+	// 閉じていないカタログは入力の終わりで閉じる (cleanup 相当)
+	st.boot = nil
+
+	return st.writeRelations(dataDir)
+}
+
+// createRel はカタログを作って開く (Boot_CreateStmt の処理相当)
+func (st *state) createRel(line int, name, oidText string, shared bool, cols []columnDef) error {
+	if st.boot != nil {
+		return fmt.Errorf("line %d: cannot create relation \"%s\" while relation \"%s\" is open", line, name, st.boot.name)
+	}
+	if _, ok := st.rels[name]; ok {
+		return fmt.Errorf("line %d: relation \"%s\" already exists", line, name)
+	}
+	relid, err := strconv.ParseUint(oidText, 10, 32)
+	if err != nil || relid == 0 {
+		return fmt.Errorf("line %d: invalid OID \"%s\" for relation \"%s\"", line, oidText, name)
+	}
+	for _, r := range st.order {
+		if r.relid == catalog.Oid(relid) {
+			return fmt.Errorf("line %d: OID %d of relation \"%s\" is already used by relation \"%s\"", line, relid, name, r.name)
+		}
+	}
+	seen := make(map[string]bool, len(cols))
+	for i := range cols {
+		c := &cols[i]
+		if seen[c.name] {
+			return fmt.Errorf("line %d: column \"%s\" specified more than once", c.line, c.name)
+		}
+		seen[c.name] = true
+		typid, ok := catalog.TypenameTypeID(c.typname)
+		if !ok {
+			return fmt.Errorf("line %d: unrecognized type \"%s\"", c.line, c.typname)
+		}
+		c.typid = typid
+	}
+
+	rel := &relation{name: name, relid: catalog.Oid(relid), shared: shared, cols: cols}
+	st.rels[name] = rel
+	st.order = append(st.order, rel)
+	st.boot = rel
+	return nil
+}
+
+// openRel は作ったカタログを開く (boot_openrel 相当)
+func (st *state) openRel(line int, name string) error {
+	rel, ok := st.rels[name]
+	if !ok {
+		return fmt.Errorf("line %d: relation \"%s\" does not exist", line, name)
+	}
+	st.boot = rel
+	return nil
+}
+
+// closeRel は開いているカタログを閉じる (closerel 相当)
+func (st *state) closeRel(line int, name string) error {
+	if st.boot == nil {
+		return fmt.Errorf("line %d: no open relation to close", line)
+	}
+	if st.boot.name != name {
+		return fmt.Errorf("line %d: close of %s when %s was expected", line, name, st.boot.name)
+	}
+	st.boot = nil
+	return nil
+}
+
+// insertOneTuple は開いているカタログに行を加える (InsertOneTuple、InsertOneValue 相当)
+func (st *state) insertOneTuple(line int, values []*string) error {
+	rel := st.boot
+	if rel == nil {
+		return fmt.Errorf("line %d: no open relation to insert into", line)
+	}
+	if len(values) != len(rel.cols) {
+		return fmt.Errorf("line %d: incorrect number of columns in row (expected %d, got %d)", line, len(rel.cols), len(values))
+	}
+	tup := make(catalog.CatalogTuple, len(values))
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		col := rel.cols[i]
+		d, err := adt.InputFunctionCall(col.typid, *v)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid value for column \"%s\" of relation \"%s\": %v", line, col.name, rel.name, err)
+		}
+		out := adt.OutputFunctionCall(col.typid, d)
+		tup[i] = &out
+	}
+	rel.tuples = append(rel.tuples, tup)
+	return nil
+}
+
+// writeRelations は集めた行をカタログのファイルに書く。共有カタログは global/ に、
+// それ以外は template1 のディレクトリに書く。
+func (st *state) writeRelations(dataDir string) error {
+	for _, rel := range st.order {
+		dbOid := catalog.Template1DbOid
+		if rel.shared {
+			dbOid = catalog.InvalidOid
+		}
+		dir := filepath.Join(dataDir, relpath.GetDatabasePath(dbOid))
+		if err := os.MkdirAll(dir, miscadmin.PgDirModeOwner); err != nil {
+			return fmt.Errorf("could not create directory \"%s\": %v", dir, err)
+		}
+		path := filepath.Join(dataDir, relpath.GetRelationPath(dbOid, rel.relid))
+		if err := catalog.WriteCatalogFile(path, rel.tuples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckerModeMain はデータディレクトリと設定がサーバーの起動に使えるかを確かめる (CheckerModeMain 相当)。
+// C言語版は設定を読んで共有メモリを確保できるかだけを確かめるが、ここでは起動の前に
+// データディレクトリで確かめられることを全て確かめ、最初に見つかった問題をエラーとして返す。
+//
+//  1. 設定ファイルとコマンドラインの設定を読む
+//  2. データディレクトリの所有者とパーミッション、PG_VERSION を確かめる
+//  3. initdb が作るディレクトリが揃っていることを確かめる
+//  4. 制御ファイルの版と CRC を確かめる
+//  5. ロックファイルが動いている postmaster のものでないことを確かめる
+//  6. 接続数の設定でバックエンドの一覧の大きさが上限を超えないことを確かめる
+//  7. pg_authid からブートストラップスーパーユーザーを読めることを確かめる
+func CheckerModeMain() error {
+	if err := guc.SelectConfigFiles(); err != nil {
+		return err
+	}
+	dataDir := guc.DataDirectory.Get()
+	if dataDir == "" {
+		return fmt.Errorf("no data directory specified\nHINT:  Specify the data directory with -D.")
+	}
+	if err := miscadmin.CheckDataDir(dataDir); err != nil {
+		return err
+	}
+	if err := miscadmin.ValidateDataDirStructure(dataDir); err != nil {
+		return err
+	}
+	if err := transam.ReadControlFile(dataDir); err != nil {
+		return err
+	}
+	if err := miscadmin.CheckDataDirLockFile(dataDir); err != nil {
+		return err
+	}
+	if err := miscadmin.CheckMaxBackends(); err != nil {
+		return err
+	}
+	return backend.LoadBootstrapSuperuser(dataDir)
+}
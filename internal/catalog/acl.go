@@ -0,0 +1,272 @@
+package catalog
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// アクセス権限の一覧 (utils/acl.h, utils/adt/acl.c のうち aclitem 相当)
+// ----------------------------------------------------------------
+// オブジェクトのアクセス権限は、誰が (grantee) 誰から (grantor) どの権限を受けたかを表す
+// aclitem の並び (ACL) として持つ。権限ごとに、それを他のロールに与え直せる権限 (GRANT OPTION)
+// のビットを上位の32ビットに持つ。grantee が ACLIDPublic の項目は PUBLIC (全てのロール) への
+// 権限を表す。
+//
+// オブジェクトの ACL が nil の場合は、オブジェクトの種類ごとの既定の権限 (acldefault) を持つと
+// みなす。既定では所有者が全ての権限を持ち、関数と型は PUBLIC も EXECUTE と USAGE を持つ。
+
+// AclMode は権限のビットの集まり (AclMode 相当)
+type AclMode uint64
+
+// 権限のビット (ACL_* 相当)
+const (
+	AclInsert     AclMode = 1 << 0
+	AclSelect     AclMode = 1 << 1
+	AclUpdate     AclMode = 1 << 2
+	AclDelete     AclMode = 1 << 3
+	AclTruncate   AclMode = 1 << 4
+	AclReferences AclMode = 1 << 5
+	AclTrigger    AclMode = 1 << 6
+	AclExecute    AclMode = 1 << 7
+	AclUsage      AclMode = 1 << 8
+	AclCreate     AclMode = 1 << 9
+	AclCreateTemp AclMode = 1 << 10
+	AclConnect    AclMode = 1 << 11
+	AclSet        AclMode = 1 << 12
+	AclAlterSys   AclMode = 1 << 13
+	AclMaintain   AclMode = 1 << 14
+	numAclRights          = 15
+
+	AclNoRights AclMode = 0
+)
+
+// オブジェクトの種類ごとに与えられる権限 (ACL_ALL_RIGHTS_* 相当)
+const (
+	AclAllRightsRelation  = AclInsert | AclSelect | AclUpdate | AclDelete | AclTruncate | AclReferences | AclTrigger | AclMaintain
+	AclAllRightsSequence  = AclUsage | AclSelect | AclUpdate
+	AclAllRightsFunction  = AclExecute
+	AclAllRightsType      = AclUsage
+	AclAllRightsNamespace = AclUsage | AclCreate
+)
+
+// aclAllRightsStr は権限のビットを下位から順に表す文字 (ACL_ALL_RIGHTS_STR 相当)
+const aclAllRightsStr = "arwdDxtXUCTcsAm"
+
+// ACLIDPublic は PUBLIC を表す grantee (ACL_ID_PUBLIC 相当)
+const ACLIDPublic Oid = 0
+
+// GrantOptionFor は権限 privs の GRANT OPTION のビットを返す (ACL_GRANT_OPTION_FOR 相当)
+func GrantOptionFor(privs AclMode) AclMode { return privs << 32 }
+
+// AclItem は ACL の1つの項目 (AclItem 相当)。Privs は下位の32ビットに権限を、上位の32ビットに
+// GRANT OPTION を持つ。
+type AclItem struct {
+	Grantee Oid
+	Grantor Oid
+	Privs   AclMode
+}
+
+// Rights は項目の権限を返す (ACLITEM_GET_RIGHTS 相当)
+func (item AclItem) Rights() AclMode { return item.Privs & 0xFFFFFFFF }
+
+// Goptions は項目の GRANT OPTION を権限の位置に戻して返す (ACLITEM_GET_GOPTIONS 相当)
+func (item AclItem) Goptions() AclMode { return item.Privs >> 32 }
+
+// Acl は aclitem の並び (Acl 相当)
+type Acl []AclItem
+
+// ACL の対象のオブジェクトの種類。pg_default_acl の defaclobjtype の値を使う (DEFACLOBJ_* 相当)
+const (
+	DefaclObjRelation  byte = 'r'
+	DefaclObjSequence  byte = 'S'
+	DefaclObjFunction  byte = 'f'
+	DefaclObjType      byte = 'T'
+	DefaclObjNamespace byte = 'n'
+)
+
+// AclDefault はオブジェクトの種類 objtype の既定の ACL を返す (acldefault 相当)。所有者 owner は
+// 全ての権限を持ち、関数と型は PUBLIC も権限を持つ。
+func AclDefault(objtype byte, owner Oid) Acl {
+	var worldDefault, ownerDefault AclMode
+	switch objtype {
+	case DefaclObjRelation:
+		ownerDefault = AclAllRightsRelation
+	case DefaclObjSequence:
+		ownerDefault = AclAllRightsSequence
+	case DefaclObjFunction:
+		worldDefault = AclExecute
+		ownerDefault = AclAllRightsFunction
+	case DefaclObjType:
+		worldDefault = AclUsage
+		ownerDefault = AclAllRightsType
+	case DefaclObjNamespace:
+		ownerDefault = AclAllRightsNamespace
+	default:
+		panic(fmt.Sprintf("unrecognized object type: %c", objtype))
+	}
+	var acl Acl
+	if worldDefault != AclNoRights {
+		acl = append(acl, AclItem{Grantee: ACLIDPublic, Grantor: owner, Privs: worldDefault})
+	}
+	return append(acl, AclItem{Grantee: owner, Grantor: owner, Privs: ownerDefault})
+}
+
+// AclChange は acl の mod と同じ grantee と grantor の項目に mod の権限を加えるか (isGrant)、
+// 取り除いた ACL を返す (aclupdate の ACL_MODECHG_ADD と ACL_MODECHG_DEL 相当)。権限が
+// なくなった項目は取り除く。acl そのものは変えない。
+func AclChange(acl Acl, mod AclItem, isGrant bool) Acl {
+	out := slices.Clone(acl)
+	i := slices.IndexFunc(out, func(item AclItem) bool {
+		return item.Grantee == mod.Grantee && item.Grantor == mod.Grantor
+	})
+	if i < 0 {
+		if !isGrant {
+			return out
+		}
+		out = append(out, AclItem{Grantee: mod.Grantee, Grantor: mod.Grantor})
+		i = len(out) - 1
+	}
+	if isGrant {
+		out[i].Privs |= mod.Privs
+	} else {
+		out[i].Privs &^= mod.Privs
+	}
+	if out[i].Rights() == AclNoRights {
+		out = slices.Delete(out, i, i+1)
+	}
+	return out
+}
+
+// AclMerge は leftAcl に rightAcl の全ての項目の権限を加えた ACL を返す (aclmerge 相当)
+func AclMerge(leftAcl, rightAcl Acl) Acl {
+	out := slices.Clone(leftAcl)
+	for _, item := range rightAcl {
+		out = AclChange(out, item, true)
+	}
+	return out
+}
+
+// AclItemSort は ACL の項目を grantee、grantor、権限の順に並べた写しを返す (aclitemsort 相当)
+func AclItemSort(acl Acl) Acl {
+	out := slices.Clone(acl)
+	slices.SortFunc(out, func(a, b AclItem) int {
+		if c := cmp.Compare(a.Grantee, b.Grantee); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Grantor, b.Grantor); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Privs, b.Privs)
+	})
+	return out
+}
+
+// AclEqual は2つの ACL が同じ項目を同じ順に持つかを返す (aclequal 相当)
+func AclEqual(left, right Acl) bool {
+	return slices.Equal(left, right)
+}
+
+// AclNewOwner は ACL の oldOwner を newOwner に置き換えた写しを返す (aclnewowner 相当)。
+// 置き換えて grantee と grantor が同じになった項目はまとめる。
+func AclNewOwner(acl Acl, oldOwner, newOwner Oid) Acl {
+	var out Acl
+	for _, item := range acl {
+		if item.Grantee == oldOwner {
+			item.Grantee = newOwner
+		}
+		if item.Grantor == oldOwner {
+			item.Grantor = newOwner
+		}
+		out = AclChange(out, item, true)
+	}
+	return out
+}
+
+// AclMask は rolname が acl で持つ権限のうち mask に含まれるものを返す (aclmask 相当)。
+// スーパーユーザーは全ての権限を持つ。PUBLIC への権限と、権限を受け継ぐロールへの権限も数える。
+func AclMask(acl Acl, rolname string, mask AclMode) AclMode {
+	if IsSuperuser(rolname) {
+		return mask
+	}
+	var result AclMode
+	for _, item := range acl {
+		if item.Grantee != ACLIDPublic {
+			grantee, ok := SearchRoleByOid(item.Grantee)
+			if !ok || !HasPrivsOfRole(rolname, grantee.Rolname) {
+				continue
+			}
+		}
+		result |= item.Privs & mask
+		if result == mask {
+			break
+		}
+	}
+	return result
+}
+
+// AclRoles は ACL の grantee と grantor に現れるロールを返す。PUBLIC は含めない
+// (updateAclDependencies が記録するロール相当)
+func AclRoles(acl Acl) []Oid {
+	var roles []Oid
+	for _, item := range acl {
+		for _, r := range []Oid{item.Grantee, item.Grantor} {
+			if r != ACLIDPublic && !slices.Contains(roles, r) {
+				roles = append(roles, r)
+			}
+		}
+	}
+	return roles
+}
+
+// String は項目を "grantee=権限/grantor" の形で返す (aclitemout 相当)。PUBLIC の grantee は
+// 空にし、GRANT OPTION のある権限の文字には "*" を付ける。
+func (item AclItem) String() string {
+	var b strings.Builder
+	if item.Grantee != ACLIDPublic {
+		putRoleID(&b, item.Grantee)
+	}
+	b.WriteByte('=')
+	for i := 0; i < numAclRights; i++ {
+		bit := AclMode(1) << i
+		if item.Rights()&bit != 0 {
+			b.WriteByte(aclAllRightsStr[i])
+			if item.Goptions()&bit != 0 {
+				b.WriteByte('*')
+			}
+		}
+	}
+	b.WriteByte('/')
+	putRoleID(&b, item.Grantor)
+	return b.String()
+}
+
+// putRoleID はロール名を書く (putid 相当)。英数字と "_" 以外を含む名前は二重引用符で囲む。
+// ロールがなければ OID を書く。
+func putRoleID(b *strings.Builder, roleid Oid) {
+	role, ok := SearchRoleByOid(roleid)
+	if !ok {
+		fmt.Fprintf(b, "%d", roleid)
+		return
+	}
+	name := role.Rolname
+	safe := name != "" && strings.IndexFunc(name, func(r rune) bool {
+		return !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	}) < 0
+	if safe {
+		b.WriteString(name)
+		return
+	}
+	b.WriteString(`"` + strings.ReplaceAll(name, `"`, `""`) + `"`)
+}
+
+// Strings は ACL の各項目を aclitemout の形にした並びを返す
+func (acl Acl) Strings() []string {
+	out := make([]string, len(acl))
+	for i, item := range acl {
+		out[i] = item.String()
+	}
+	return out
+}
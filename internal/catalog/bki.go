@@ -0,0 +1,9 @@
+package catalog
+
+import _ "embed"
+
+// PostgresBKI はブートストラップで読む BKI (share/postgres.bki 相当)。genbki が .dat ファイルから
+// 生成し、initdb が "postgres boot" の標準入力に渡す。
+//
+//go:embed postgres.bki
+var PostgresBKI string
@@ -0,0 +1,6 @@
+package catalog
+
+// CatalogVersionNo はシステムカタログの形式の版 (CATALOG_VERSION_NO 相当)。
+// 制御ファイルに記録し、異なる版の initdb で作ったデータディレクトリでは起動しない。
+// C言語版と同じく、カタログの形式を変えたら変更した日付と連番 (yyyymmddN) にする。
+const CatalogVersionNo = 202610143
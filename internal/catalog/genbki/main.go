@@ -0,0 +1,1110 @@
+// genbki はシステムカタログの初期データ (*.dat) から、組み込みオブジェクトの OID の定数と
+// カタログの行を定義する Go のソースを生成する (backend/catalog/genbki.pl 相当)。
+//
+// 組み込みの型と関数の OID は、PostgreSQL 本体と同じ値を .dat ファイルに明示的に書く。
+// バイナリ形式を扱うクライアントドライバは既知の OID (23=int4, 25=text など) で型を判別するため、
+// OID はビルドや実装の変更で変わってはならない。これらのカタログで OID がない、範囲外、
+// または重複している行があれば何も生成せずに失敗する (genbki.pl と duplicate_oids の検査相当)。
+// クライアントが OID を知る必要のない pg_cast, pg_opclass, pg_amop, pg_amproc の行には
+// OID を書かず、genbki が firstGenbkiObjectID から順に割り当てる。
+//
+// internal/catalog で go generate を実行すると、次のファイルを生成する。
+//
+//	pg_type.dat     → pg_type_d.go     (型の OID の定数と pg_type の行)
+//	pg_proc.dat     → pg_proc_d.go     (pg_proc の行)
+//	pg_authid.dat   → pg_authid_d.go   (定義済みロール)
+//	pg_database.dat → pg_database_d.go (データベースの OID の定数)
+//	pg_cast.dat     → pg_cast_d.go     (pg_cast の行)
+//	pg_am.dat       → pg_am_d.go       (アクセスメソッドの OID の定数)
+//	pg_operator.dat → pg_operator_d.go (pg_operator の行)
+//	pg_opfamily.dat → pg_opfamily_d.go (pg_opfamily の行)
+//	pg_opclass.dat  → pg_opclass_d.go  (pg_opclass の行)
+//	pg_amop.dat     → pg_amop_d.go     (pg_amop の行)
+//	pg_amproc.dat   → pg_amproc_d.go   (pg_amproc の行)
+//	全ての .dat     → postgres.bki     (ブートストラップで読むカタログの定義と初期データ)
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+)
+
+// firstGenbkiObjectID は genbki が自動で割り当てる OID の先頭 (FirstGenbkiObjectId 相当)。
+// .dat ファイルに手で書く OID はこれより小さくなければならない。
+const firstGenbkiObjectID = 10000
+
+// row は .dat ファイルの1行分 (1つのハッシュ) のデータ
+type row struct {
+	values map[string]string
+	// pos はエラーメッセージに使う "ファイル名:行番号"
+	pos string
+}
+
+// catalog は1つの .dat ファイルの内容
+type catalog struct {
+	name string
+	rows []*row
+}
+
+func main() {
+	if err := run("."); err != nil {
+		fmt.Fprintf(os.Stderr, "genbki: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string) error {
+	catalogs := make(map[string]*catalog)
+	for _, def := range catalogDefs {
+		c, err := readCatalog(filepath.Join(dir, def.name+".dat"), def.name)
+		if err != nil {
+			return err
+		}
+		catalogs[def.name] = c
+	}
+	types, procs, authid, database := catalogs["pg_type"], catalogs["pg_proc"], catalogs["pg_authid"], catalogs["pg_database"]
+	casts, ams, opers := catalogs["pg_cast"], catalogs["pg_am"], catalogs["pg_operator"]
+	opfamilies, opclasses := catalogs["pg_opfamily"], catalogs["pg_opclass"]
+	amops, amprocs := catalogs["pg_amop"], catalogs["pg_amproc"]
+
+	if err := checkRequired(types, "typname", "typlen"); err != nil {
+		return err
+	}
+	if err := checkRequired(procs, "proname", "prorettype", "prosrc"); err != nil {
+		return err
+	}
+	if err := checkRequired(authid, "rolname"); err != nil {
+		return err
+	}
+	if err := checkRequired(database, "datname", "datdba", "encoding"); err != nil {
+		return err
+	}
+	if err := checkKeys(casts, "castsource", "casttarget", "castcontext", "castmethod"); err != nil {
+		return err
+	}
+	if err := checkRequired(ams, "amname", "amtype"); err != nil {
+		return err
+	}
+	if err := checkRequired(opers, "oprname", "oprright", "oprresult", "oprcode"); err != nil {
+		return err
+	}
+	if err := checkRequired(opfamilies, "opfmethod", "opfname"); err != nil {
+		return err
+	}
+	if err := checkKeys(opclasses, "opcmethod", "opcname", "opcfamily", "opcintype"); err != nil {
+		return err
+	}
+	if err := checkKeys(amops, "amopfamily", "amoplefttype", "amoprighttype", "amopstrategy", "amopopr", "amopmethod"); err != nil {
+		return err
+	}
+	if err := checkKeys(amprocs, "amprocfamily", "amproclefttype", "amprocrighttype", "amprocnum", "amproc"); err != nil {
+		return err
+	}
+	typeRows, err := expandArrayTypes(types)
+	if err != nil {
+		return err
+	}
+	types.rows = typeRows
+	if err := checkOids(typeRows, procs.rows, authid.rows, database.rows, ams.rows, opers.rows, opfamilies.rows); err != nil {
+		return err
+	}
+	if err := sortByOid(procs.rows); err != nil {
+		return err
+	}
+	assignOids(casts.rows, opclasses.rows, amops.rows, amprocs.rows)
+
+	typeSrc, err := genPgType(typeRows)
+	if err != nil {
+		return err
+	}
+	procSrc, err := genPgProc(procs.rows, typeRows)
+	if err != nil {
+		return err
+	}
+	castSrc, err := genPgCast(casts.rows, typeRows, procs.rows)
+	if err != nil {
+		return err
+	}
+	bki, err := genBKI(catalogs)
+	if err != nil {
+		return err
+	}
+	sources := map[string][]byte{
+		"pg_type_d.go":     typeSrc,
+		"pg_proc_d.go":     procSrc,
+		"pg_authid_d.go":   genPgAuthid(authid.rows),
+		"pg_database_d.go": genPgDatabase(database.rows),
+		"pg_cast_d.go":     castSrc,
+		"pg_am_d.go":       genPgAm(ams.rows),
+	}
+	refs := goRefs(catalogs)
+	for _, g := range rowCatalogs {
+		src, err := genRows(findCatalogDef(g.name), catalogs[g.name].rows, refs, g.variable, g.descr)
+		if err != nil {
+			return err
+		}
+		sources[g.name+"_d.go"] = src
+	}
+
+	for name, src := range sources {
+		formatted, err := format.Source(src)
+		if err != nil {
+			return fmt.Errorf("could not format %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), formatted, 0644); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, "postgres.bki"), bki, 0644)
+}
+
+// ----------------------------------------------------------------
+// カタログの定義 (catalog/pg_*.h の CATALOG と列の宣言相当)
+// ----------------------------------------------------------------
+// ブートストラップで作るカタログと、その列。.dat ファイルの行に列の値がなければ def を使う
+// (BKI_DEFAULT 相当)。lookup を指定した列には、そのカタログの行の名前を書き、OID に変換する
+// (BKI_LOOKUP 相当)。
+
+type columnDef struct {
+	name string
+	typ  string
+	def  string
+	// lookup は名前を OID に変換するカタログ。_oid の列では空白で区切った名前の並びを変換する
+	lookup string
+}
+
+type catalogDef struct {
+	name string
+	// relid はカタログの OID で、symbol はその定数名 (TypeRelationId など相当)
+	relid  uint32
+	symbol string
+	// shared はクラスタ全体で共有するカタログ (BKI_SHARED_RELATION 相当)
+	shared  bool
+	columns []columnDef
+}
+
+// bkiNull は BKI で NULL を表す値 (BKI_DEFAULT(_null_) 相当)
+const bkiNull = "_null_"
+
+var catalogDefs = []catalogDef{
+	{name: "pg_proc", relid: 1255, symbol: "ProcedureRelationID", columns: []columnDef{
+		{name: "oid", typ: "oid"},
+		{name: "proname", typ: "name"},
+		{name: "proargtypes", typ: "_oid", lookup: "pg_type"},
+		{name: "prorettype", typ: "oid", lookup: "pg_type"},
+		{name: "proisstrict", typ: "bool", def: "t"},
+		{name: "prosrc", typ: "text"},
+	}},
+	{name: "pg_type", relid: 1247, symbol: "TypeRelationID", columns: []columnDef{
+		{name: "oid", typ: "oid"},
+		{name: "typname", typ: "name"},
+		{name: "typlen", typ: "int2"},
+		{name: "typdelim", typ: "char", def: ","},
+		{name: "typelem", typ: "oid", def: "0", lookup: "pg_type"},
+		{name: "typarray", typ: "oid", def: "0", lookup: "pg_type"},
+	}},
+	{name: "pg_authid", relid: 1260, symbol: "AuthIDRelationID", shared: true, columns: []columnDef{
+		{name: "oid", typ: "oid"},
+		{name: "rolname", typ: "name"},
+		{name: "rolsuper", typ: "bool", def: "f"},
+		{name: "rolinherit", typ: "bool", def: "t"},
+		{name: "rolcreaterole", typ: "bool", def: "f"},
+		{name: "rolcreatedb", typ: "bool", def: "f"},
+		{name: "rolcanlogin", typ: "bool", def: "f"},
+		{name: "rolreplication", typ: "bool", def: "f"},
+		{name: "rolbypassrls", typ: "bool", def: "f"},
+		{name: "rolconnlimit", typ: "int4", def: "-1"},
+		{name: "rolpassword", typ: "text", def: bkiNull},
+		{name: "rolvaliduntil", typ: "timestamptz", def: bkiNull},
+	}},
+	{name: "pg_database", relid: 1262, symbol: "DatabaseRelationID", shared: true, columns: []columnDef{
+		{name: "oid", typ: "oid"},
+		{name: "datname", typ: "name"},
+		{name: "datdba", typ: "oid", lookup: "pg_authid"},
+		{name: "encoding", typ: "int4"},
+		{name: "datistemplate", typ: "bool", def: "f"},
+		{name: "datallowconn", typ: "bool", def: "t"},
+		{name: "datconnlimit", typ: "int4", def: "-1"},
+	}},
+	{name: "pg_cast", relid: 2605, symbol: "CastRelationID", columns: []columnDef{
+		{name: "oid", typ: "oid"},
+		{name: "castsource", typ: "oid", lookup: "pg_type"},
+		{name: "casttarget", typ: "oid", lookup: "pg_type"},
+		{name: "castfunc", typ: "oid", def: "0", lookup: "pg_proc"},
+		{name: "castcontext", typ: "char"},
+		{name: "castmethod", typ: "char"},
+	}},
+	{name: "pg_am", relid: 2601, symbol: "AccessMethodRelationID", columns: []columnDef{
+		{name: "oid", typ: "oid"},
+		{name: "amname", typ: "name"},
+		{name: "amtype", typ: "char"},
+	}},
+	{name: "pg_operator", relid: 2617, symbol: "OperatorRelationID", columns: []columnDef{
+		{name: "oid", typ: "oid"},
+		{name: "oprname", typ: "name"},
+		{name: "oprowner", typ: "oid", def: bootstrapSuperuserName, lookup: "pg_authid"},
+		{name: "oprkind", typ: "char", def: "b"},
+		{name: "oprcanmerge", typ: "bool", def: "f"},
+		{name: "oprcanhash", typ: "bool", def: "f"},
+		{name: "oprleft", typ: "oid", def: "0", lookup: "pg_type"},
+		{name: "oprright", typ: "oid", lookup: "pg_type"},
+		{name: "oprresult", typ: "oid", lookup: "pg_type"},
+		{name: "oprcom", typ: "oid", def: "0", lookup: "pg_operator"},
+		{name: "oprnegate", typ: "oid", def: "0", lookup: "pg_operator"},
+		{name: "oprcode", typ: "oid", lookup: "pg_proc"},
+	}},
+	{name: "pg_opfamily", relid: 2753, symbol: "OperatorFamilyRelationID", columns: []columnDef{
+		{name: "oid", typ: "oid"},
+		{name: "opfmethod", typ: "oid", lookup: "pg_am"},
+		{name: "opfname", typ: "name"},
+		{name: "opfowner", typ: "oid", def: bootstrapSuperuserName, lookup: "pg_authid"},
+	}},
+	{name: "pg_opclass", relid: 2616, symbol: "OperatorClassRelationID", columns: []columnDef{
+		{name: "oid", typ: "oid"},
+		{name: "opcmethod", typ: "oid", lookup: "pg_am"},
+		{name: "opcname", typ: "name"},
+		{name: "opcowner", typ: "oid", def: bootstrapSuperuserName, lookup: "pg_authid"},
+		{name: "opcfamily", typ: "oid", lookup: "pg_opfamily"},
+		{name: "opcintype", typ: "oid", lookup: "pg_type"},
+		{name: "opcdefault", typ: "bool", def: "t"},
+		{name: "opckeytype", typ: "oid", def: "0", lookup: "pg_type"},
+	}},
+	{name: "pg_amop", relid: 2602, symbol: "AccessMethodOperatorRelationID", columns: []columnDef{
+		{name: "oid", typ: "oid"},
+		{name: "amopfamily", typ: "oid", lookup: "pg_opfamily"},
+		{name: "amoplefttype", typ: "oid", lookup: "pg_type"},
+		{name: "amoprighttype", typ: "oid", lookup: "pg_type"},
+		{name: "amopstrategy", typ: "int2"},
+		{name: "amoppurpose", typ: "char", def: "s"},
+		{name: "amopopr", typ: "oid", lookup: "pg_operator"},
+		{name: "amopmethod", typ: "oid", lookup: "pg_am"},
+		{name: "amopsortfamily", typ: "oid", def: "0", lookup: "pg_opfamily"},
+	}},
+	{name: "pg_amproc", relid: 2603, symbol: "AccessMethodProcedureRelationID", columns: []columnDef{
+		{name: "oid", typ: "oid"},
+		{name: "amprocfamily", typ: "oid", lookup: "pg_opfamily"},
+		{name: "amproclefttype", typ: "oid", lookup: "pg_type"},
+		{name: "amprocrighttype", typ: "oid", lookup: "pg_type"},
+		{name: "amprocnum", typ: "int2"},
+		{name: "amproc", typ: "oid", lookup: "pg_proc"},
+	}},
+}
+
+func findCatalogDef(name string) *catalogDef {
+	for i := range catalogDefs {
+		if catalogDefs[i].name == name {
+			return &catalogDefs[i]
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// .dat ファイルの読み込み (Catalog::ParseData 相当)
+// ----------------------------------------------------------------
+// .dat ファイルは Perl のハッシュの配列として書く。
+//
+//	[
+//	{ oid => '16', typname => 'bool' },
+//	]
+//
+// 値は単一引用符で囲み、値の中の単一引用符と \ は \ でエスケープする。
+// # から行末まではコメントになる。
+
+// readCatalog は .dat ファイルを読み込む
+func readCatalog(path, name string) (*catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &lexer{src: data, file: filepath.Base(path), line: 1}
+	c := &catalog{name: name}
+
+	if err := l.expect("["); err != nil {
+		return nil, err
+	}
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if tok == "]" {
+			break
+		}
+		if tok != "{" {
+			return nil, l.errorf("expected \"{\" or \"]\" but found %q", tok)
+		}
+		r := &row{values: make(map[string]string), pos: l.pos()}
+		for {
+			key, err := l.next()
+			if err != nil {
+				return nil, err
+			}
+			if key == "}" {
+				break
+			}
+			if err := l.expect("=>"); err != nil {
+				return nil, err
+			}
+			value, err := l.next()
+			if err != nil {
+				return nil, err
+			}
+			if !l.quoted {
+				return nil, l.errorf("value of %q must be quoted", key)
+			}
+			if _, ok := r.values[key]; ok {
+				return nil, l.errorf("duplicate key %q", key)
+			}
+			r.values[key] = value
+
+			sep, err := l.next()
+			if err != nil {
+				return nil, err
+			}
+			if sep == "}" {
+				break
+			}
+			if sep != "," {
+				return nil, l.errorf("expected \",\" or \"}\" but found %q", sep)
+			}
+		}
+		c.rows = append(c.rows, r)
+
+		// 行の後の "," は省略できる
+		if l.peek() == ',' {
+			if _, err := l.next(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if tok, err := l.next(); err == nil && tok != "" {
+		return nil, l.errorf("unexpected %q after \"]\"", tok)
+	}
+	return c, nil
+}
+
+// lexer は .dat ファイルの字句解析器
+type lexer struct {
+	src  []byte
+	i    int
+	file string
+	line int
+	// quoted は直前に読んだトークンが引用符で囲んだ値だったかどうか
+	quoted bool
+}
+
+func (l *lexer) pos() string { return fmt.Sprintf("%s:%d", l.file, l.line) }
+
+func (l *lexer) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s: %s", l.pos(), fmt.Sprintf(format, args...))
+}
+
+// skipSpace は空白とコメントを読み飛ばす
+func (l *lexer) skipSpace() {
+	for l.i < len(l.src) {
+		switch c := l.src[l.i]; {
+		case c == '\n':
+			l.line++
+			l.i++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.i++
+		case c == '#':
+			for l.i < len(l.src) && l.src[l.i] != '\n' {
+				l.i++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// peek は次のトークンの最初の文字を返す。入力の終わりでは 0 を返す。
+func (l *lexer) peek() byte {
+	l.skipSpace()
+	if l.i >= len(l.src) {
+		return 0
+	}
+	return l.src[l.i]
+}
+
+// next は次のトークンを返す。入力の終わりでは空文字列を返す。
+func (l *lexer) next() (string, error) {
+	l.skipSpace()
+	l.quoted = false
+	if l.i >= len(l.src) {
+		return "", nil
+	}
+	c := l.src[l.i]
+	switch {
+	case c == '[' || c == ']' || c == '{' || c == '}' || c == ',':
+		l.i++
+		return string(c), nil
+	case c == '=' && l.i+1 < len(l.src) && l.src[l.i+1] == '>':
+		l.i += 2
+		return "=>", nil
+	case c == '\'':
+		var buf strings.Builder
+		l.i++
+		for {
+			if l.i >= len(l.src) {
+				return "", l.errorf("unterminated quoted string")
+			}
+			c := l.src[l.i]
+			if c == '\\' && l.i+1 < len(l.src) {
+				buf.WriteByte(l.src[l.i+1])
+				l.i += 2
+				continue
+			}
+			if c == '\'' {
+				l.i++
+				break
+			}
+			if c == '\n' {
+				l.line++
+			}
+			buf.WriteByte(c)
+			l.i++
+		}
+		l.quoted = true
+		return buf.String(), nil
+	case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		start := l.i
+		for l.i < len(l.src) {
+			c := l.src[l.i]
+			if c != '_' && !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+				break
+			}
+			l.i++
+		}
+		return string(l.src[start:l.i]), nil
+	}
+	return "", l.errorf("unexpected character %q", c)
+}
+
+// expect は次のトークンが want であることを確かめる
+func (l *lexer) expect(want string) error {
+	tok, err := l.next()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return l.errorf("expected %q but found %q", want, tok)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// 検査
+// ----------------------------------------------------------------
+
+// checkRequired は全ての行に oid と keys の値があることを確かめる
+func checkRequired(c *catalog, keys ...string) error {
+	return checkKeys(c, append([]string{"oid"}, keys...)...)
+}
+
+// checkKeys は全ての行に keys の値があることを確かめる。OID を genbki が割り当てるカタログに使う
+func checkKeys(c *catalog, keys ...string) error {
+	for _, r := range c.rows {
+		for _, key := range keys {
+			if _, ok := r.values[key]; !ok {
+				return fmt.Errorf("%s: %s row is missing %q", r.pos, c.name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// assignOids は OID のない行に firstGenbkiObjectID から順に OID を割り当てる
+// (genbki.pl の GenbkiNextOid 相当)。OID はカタログをまたいで重複しないよう、引数の順に割り当てる。
+func assignOids(catalogs ...[]*row) {
+	next := firstGenbkiObjectID
+	for _, rows := range catalogs {
+		for _, r := range rows {
+			if _, ok := r.values["oid"]; !ok {
+				r.values["oid"] = strconv.Itoa(next)
+				next++
+			}
+		}
+	}
+}
+
+// oidOf は行の OID を返す。手で割り当てる範囲 (1 .. FirstGenbkiObjectId-1) でなければエラーにする。
+func oidOf(r *row, key string) (uint32, error) {
+	n, err := strconv.ParseUint(r.values[key], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid %s %q", r.pos, key, r.values[key])
+	}
+	if n == 0 || n >= firstGenbkiObjectID {
+		return 0, fmt.Errorf("%s: %s %d is outside the range for manually assigned OIDs (1 .. %d)",
+			r.pos, key, n, firstGenbkiObjectID-1)
+	}
+	return uint32(n), nil
+}
+
+// checkOids は OID がカタログ全体で重複していないことを確かめる (duplicate_oids 相当)
+func checkOids(catalogs ...[]*row) error {
+	seen := make(map[uint32]string)
+	for _, rows := range catalogs {
+		for _, r := range rows {
+			oid, err := oidOf(r, "oid")
+			if err != nil {
+				return err
+			}
+			if prev, ok := seen[oid]; ok {
+				return fmt.Errorf("%s: OID %d is already used at %s", r.pos, oid, prev)
+			}
+			seen[oid] = r.pos
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// pg_type
+// ----------------------------------------------------------------
+
+// expandArrayTypes は array_type_oid を持つ型の配列型の行を作り、全ての型の行を OID 順に返す。
+// 配列型の名前は要素の型の名前に "_" を付けたもので、区切り文字は要素の型と同じにする
+// (genbki.pl の array_type_oid の処理相当)。
+func expandArrayTypes(c *catalog) ([]*row, error) {
+	rows := append([]*row(nil), c.rows...)
+	for _, r := range c.rows {
+		arrayOid, ok := r.values["array_type_oid"]
+		if !ok {
+			continue
+		}
+		array := &row{
+			values: map[string]string{
+				"oid":     arrayOid,
+				"typname": "_" + r.values["typname"],
+				"typlen":  "-1",
+				"typelem": r.values["typname"],
+			},
+			pos: r.pos,
+		}
+		if delim, ok := r.values["typdelim"]; ok {
+			array.values["typdelim"] = delim
+		}
+		if _, err := oidOf(array, "oid"); err != nil {
+			return nil, err
+		}
+		r.values["typarray"] = array.values["typname"]
+		rows = append(rows, array)
+	}
+	if err := sortByOid(rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// sortByOid は行を OID の順に並べる
+func sortByOid(rows []*row) error {
+	oids := make(map[*row]uint32, len(rows))
+	for _, r := range rows {
+		oid, err := oidOf(r, "oid")
+		if err != nil {
+			return err
+		}
+		oids[r] = oid
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return oids[rows[i]] < oids[rows[j]] })
+	return nil
+}
+
+// typeSymbol は型の OID の定数名を返す (form_pg_type_symbol 相当)。
+// 配列型 "_int4" は INT4ARRAYOID になる。
+func typeSymbol(typname string) string {
+	if elem, ok := strings.CutPrefix(typname, "_"); ok {
+		return strings.ToUpper(elem) + "ARRAYOID"
+	}
+	return strings.ToUpper(typname) + "OID"
+}
+
+func genPgType(rows []*row) ([]byte, error) {
+	var buf bytes.Buffer
+	writeHeader(&buf, "pg_type.dat")
+	writeRelationID(&buf, findCatalogDef("pg_type"))
+
+	buf.WriteString("// 組み込み型の OID (pg_type_d.h 相当)\nconst (\n")
+	for _, r := range rows {
+		fmt.Fprintf(&buf, "%s Oid = %s\n", typeSymbol(r.values["typname"]), r.values["oid"])
+	}
+	buf.WriteString(")\n\n")
+
+	buf.WriteString("// builtinTypes は組み込み型の行 (pg_type.dat 相当)\nvar builtinTypes = []FormPgType{\n")
+	for _, r := range rows {
+		typname := r.values["typname"]
+		typlen, err := strconv.ParseInt(r.values["typlen"], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid typlen %q", r.pos, r.values["typlen"])
+		}
+		delim := r.values["typdelim"]
+		if delim == "" {
+			delim = ","
+		}
+		if len(delim) != 1 {
+			return nil, fmt.Errorf("%s: typdelim must be a single byte", r.pos)
+		}
+		fmt.Fprintf(&buf, "{Oid: %s, Typname: %q, Typlen: %d, Typdelim: %q", typeSymbol(typname), typname, typlen, delim[0])
+		if elem, ok := r.values["typelem"]; ok {
+			fmt.Fprintf(&buf, ", Typelem: %s", typeSymbol(elem))
+		}
+		if array, ok := r.values["typarray"]; ok {
+			fmt.Fprintf(&buf, ", Typarray: %s", typeSymbol(array))
+		}
+		buf.WriteString("},\n")
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// ----------------------------------------------------------------
+// pg_proc
+// ----------------------------------------------------------------
+
+// genPgProc は pg_proc の行を生成する。引数と結果の型の名前は pg_type の行から OID に変換する
+// (genbki.pl の regtype の参照の解決相当)。
+func genPgProc(rows []*row, types []*row) ([]byte, error) {
+	typeOids := make(map[string]string, len(types))
+	for _, t := range types {
+		typeOids[t.values["typname"]] = typeSymbol(t.values["typname"])
+	}
+	lookupType := func(r *row, name string) (string, error) {
+		sym, ok := typeOids[name]
+		if !ok {
+			return "", fmt.Errorf("%s: unresolved OID reference %q in pg_proc", r.pos, name)
+		}
+		return sym, nil
+	}
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "pg_proc.dat")
+	writeRelationID(&buf, findCatalogDef("pg_proc"))
+	buf.WriteString("// builtinProcs は組み込み関数の行 (pg_proc.dat 相当)\nvar builtinProcs = []FormPgProc{\n")
+	for _, r := range rows {
+		rettype, err := lookupType(r, r.values["prorettype"])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "{Oid: %s, Proname: %q", r.values["oid"], r.values["proname"])
+		if args := strings.Fields(r.values["proargtypes"]); len(args) > 0 {
+			syms := make([]string, len(args))
+			for i, a := range args {
+				if syms[i], err = lookupType(r, a); err != nil {
+					return nil, err
+				}
+			}
+			fmt.Fprintf(&buf, ", Proargtypes: []Oid{%s}", strings.Join(syms, ", "))
+		}
+		// proisstrict の既定値は pg_proc.h と同じく true
+		strict := r.values["proisstrict"] != "f"
+		fmt.Fprintf(&buf, ", Prorettype: %s, Proisstrict: %t, Prosrc: %q},\n", rettype, strict, r.values["prosrc"])
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// ----------------------------------------------------------------
+// pg_cast
+// ----------------------------------------------------------------
+
+// genPgCast は pg_cast の行を生成する。castfunc は "proname(argtypes)" の形で書き、
+// pg_proc の行から OID に変換する (regprocedure の参照の解決相当)。
+func genPgCast(rows []*row, types []*row, procs []*row) ([]byte, error) {
+	typeOids := make(map[string]string, len(types))
+	for _, t := range types {
+		typeOids[t.values["typname"]] = typeSymbol(t.values["typname"])
+	}
+	procOids := procSignatures(procs)
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "pg_cast.dat")
+	writeRelationID(&buf, findCatalogDef("pg_cast"))
+	buf.WriteString("// builtinCasts は組み込みの型変換の行 (pg_cast.dat 相当)\nvar builtinCasts = []FormPgCast{\n")
+	for _, r := range rows {
+		var syms [2]string
+		for i, key := range []string{"castsource", "casttarget"} {
+			sym, ok := typeOids[r.values[key]]
+			if !ok {
+				return nil, fmt.Errorf("%s: unresolved OID reference %q in pg_cast", r.pos, r.values[key])
+			}
+			syms[i] = sym
+		}
+		fmt.Fprintf(&buf, "{Oid: %s, Castsource: %s, Casttarget: %s", r.values["oid"], syms[0], syms[1])
+		if fn, ok := r.values["castfunc"]; ok {
+			oid, ok := procOids[signatureKey(fn)]
+			if !ok {
+				return nil, fmt.Errorf("%s: unresolved OID reference %q in pg_cast", r.pos, fn)
+			}
+			fmt.Fprintf(&buf, ", Castfunc: %s", oid)
+		}
+		for _, key := range []string{"castcontext", "castmethod"} {
+			if len(r.values[key]) != 1 {
+				return nil, fmt.Errorf("%s: %s must be a single byte", r.pos, key)
+			}
+		}
+		fmt.Fprintf(&buf, ", Castcontext: %q, Castmethod: %q},\n", r.values["castcontext"][0], r.values["castmethod"][0])
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// procSignatures は "proname(argtypes)" から関数の OID への対応を作る
+func procSignatures(procs []*row) map[string]string {
+	m := make(map[string]string, len(procs))
+	for _, p := range procs {
+		sig := p.values["proname"] + "(" + strings.Join(strings.Fields(p.values["proargtypes"]), ",") + ")"
+		m[sig] = p.values["oid"]
+	}
+	return m
+}
+
+// signatureKey は "proname(argtypes)" から空白を除き、procSignatures の対応を引く形にする
+func signatureKey(sig string) string {
+	return strings.Join(strings.Fields(sig), "")
+}
+
+// operatorSignatures は "oprname(lefttype,righttype)" から演算子の OID への対応を作る
+func operatorSignatures(opers []*row) map[string]string {
+	m := make(map[string]string, len(opers))
+	for _, o := range opers {
+		left := o.values["oprleft"]
+		if left == "" {
+			left = "0"
+		}
+		m[o.values["oprname"]+"("+left+","+o.values["oprright"]+")"] = o.values["oid"]
+	}
+	return m
+}
+
+// opfamilyNames は "amname/opfname" から演算子族の OID への対応を作る
+func opfamilyNames(opfamilies []*row) map[string]string {
+	m := make(map[string]string, len(opfamilies))
+	for _, f := range opfamilies {
+		m[f.values["opfmethod"]+"/"+f.values["opfname"]] = f.values["oid"]
+	}
+	return m
+}
+
+// ----------------------------------------------------------------
+// pg_am
+// ----------------------------------------------------------------
+
+// genPgAm はアクセスメソッドの OID の定数を生成する。アクセスメソッドの性質は pg_am.go に書く
+func genPgAm(rows []*row) []byte {
+	var buf bytes.Buffer
+	writeHeader(&buf, "pg_am.dat")
+	writeRelationID(&buf, findCatalogDef("pg_am"))
+	writeOidSymbols(&buf, "pg_am", rows)
+	return buf.Bytes()
+}
+
+// ----------------------------------------------------------------
+// pg_operator, pg_opfamily, pg_opclass, pg_amop, pg_amproc
+// ----------------------------------------------------------------
+// これらのカタログの行は、列と同じ名前のフィールドを持つ Form 構造体 (FormPgOperator など) の
+// スライスとして生成する。
+
+// rowCatalogs は genRows で行を生成するカタログと、行のスライスの変数名とその説明
+var rowCatalogs = []struct{ name, variable, descr string }{
+	{"pg_operator", "builtinOperators", "組み込みの演算子"},
+	{"pg_opfamily", "builtinOpfamilies", "組み込みの演算子族"},
+	{"pg_opclass", "builtinOpclasses", "組み込みの演算子クラス"},
+	{"pg_amop", "builtinAmops", "組み込みの演算子族の演算子"},
+	{"pg_amproc", "builtinAmprocs", "組み込みの演算子族のサポート関数"},
+}
+
+// goRefs は lookup の名前から、生成する Go のソースに書く OID の式への対応を作る。型と
+// アクセスメソッドは定数名、ブートストラップスーパーユーザーは BootstrapSuperuserID、
+// それ以外は OID の値を書く。
+func goRefs(catalogs map[string]*catalog) map[string]map[string]string {
+	types := make(map[string]string)
+	for _, t := range catalogs["pg_type"].rows {
+		types[t.values["typname"]] = typeSymbol(t.values["typname"])
+	}
+	ams := make(map[string]string)
+	for _, a := range catalogs["pg_am"].rows {
+		ams[a.values["amname"]] = a.values["oid_symbol"]
+	}
+	roles := nameToOid(catalogs["pg_authid"].rows, "rolname")
+	roles[bootstrapSuperuserName] = "BootstrapSuperuserID"
+	return map[string]map[string]string{
+		"pg_type":     types,
+		"pg_am":       ams,
+		"pg_authid":   roles,
+		"pg_proc":     procSignatures(catalogs["pg_proc"].rows),
+		"pg_operator": operatorSignatures(catalogs["pg_operator"].rows),
+		"pg_opfamily": opfamilyNames(catalogs["pg_opfamily"].rows),
+	}
+}
+
+// genRows はカタログの行を Form 構造体のスライスとして生成する。値が既定値のゼロ値
+// (0, f, 空文字列) の列は書かない。
+func genRows(def *catalogDef, rows []*row, refs map[string]map[string]string, variable, descr string) ([]byte, error) {
+	var buf bytes.Buffer
+	writeHeader(&buf, def.name+".dat")
+	writeRelationID(&buf, def)
+	fmt.Fprintf(&buf, "// %s は%sの行 (%s.dat 相当)\nvar %s = []Form%s{\n", variable, descr, def.name, variable, goName(def.name))
+	for _, r := range rows {
+		var fields []string
+		for _, col := range def.columns {
+			v, ok := r.values[col.name]
+			if !ok {
+				v = col.def
+			}
+			var expr string
+			switch {
+			case col.lookup != "" && v != "0":
+				key := v
+				if col.lookup == "pg_proc" || col.lookup == "pg_operator" {
+					key = signatureKey(v)
+				}
+				sym, ok := refs[col.lookup][key]
+				if !ok {
+					return nil, fmt.Errorf("%s: unresolved OID reference %q in %s", r.pos, v, col.name)
+				}
+				expr = sym
+			case col.typ == "oid" || col.typ == "int2":
+				if _, err := strconv.ParseInt(v, 10, 32); err != nil {
+					return nil, fmt.Errorf("%s: invalid %s %q", r.pos, col.name, v)
+				}
+				if v != "0" {
+					expr = v
+				}
+			case col.typ == "bool":
+				if v != "t" && v != "f" {
+					return nil, fmt.Errorf("%s: %s must be t or f", r.pos, col.name)
+				}
+				if v == "t" {
+					expr = "true"
+				}
+			case col.typ == "char":
+				if len(v) != 1 {
+					return nil, fmt.Errorf("%s: %s must be a single byte", r.pos, col.name)
+				}
+				expr = fmt.Sprintf("%q", v[0])
+			default:
+				if v != "" {
+					expr = strconv.Quote(v)
+				}
+			}
+			if expr != "" {
+				fields = append(fields, goName(col.name)+": "+expr)
+			}
+		}
+		fmt.Fprintf(&buf, "{%s},\n", strings.Join(fields, ", "))
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// ----------------------------------------------------------------
+// pg_authid
+// ----------------------------------------------------------------
+
+// genPgAuthid は定義済みロールの一覧を生成する。ブートストラップスーパーユーザーの名前は
+// initdb が決めるため、一覧には含めない
+func genPgAuthid(rows []*row) []byte {
+	var buf bytes.Buffer
+	writeHeader(&buf, "pg_authid.dat")
+	writeRelationID(&buf, findCatalogDef("pg_authid"))
+	writeOidSymbols(&buf, "pg_authid", rows)
+	buf.WriteString("// PredefinedRoles は定義済みロールの一覧 (pg_authid.dat 相当)\nvar PredefinedRoles = []PredefinedRole{\n")
+	for _, r := range rows {
+		if r.values["rolname"] == bootstrapSuperuserName {
+			continue
+		}
+		fmt.Fprintf(&buf, "{Oid: %s, Rolname: %q},\n", r.values["oid"], r.values["rolname"])
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// ----------------------------------------------------------------
+// pg_database
+// ----------------------------------------------------------------
+
+func genPgDatabase(rows []*row) []byte {
+	var buf bytes.Buffer
+	writeHeader(&buf, "pg_database.dat")
+	writeRelationID(&buf, findCatalogDef("pg_database"))
+	writeOidSymbols(&buf, "pg_database", rows)
+	return buf.Bytes()
+}
+
+// ----------------------------------------------------------------
+// postgres.bki (genbki.pl の BKI の出力相当)
+// ----------------------------------------------------------------
+// カタログごとに create で列を定義し、insert で行を書き、close で閉じる。
+//
+//	create pg_type 1247
+//	 (
+//	 oid = oid ,
+//	 typname = name ,
+//	 ...
+//	 )
+//	insert ( 16 bool 1 ',' 0 1000 )
+//	close pg_type
+//
+// 値は空白で区切る。空白や記号を含む値は単一引用符で囲み、NULL は _null_ と書く。
+
+// bootstrapSuperuserName は initdb がブートストラップスーパーユーザーの名前に置き換える語
+const bootstrapSuperuserName = "POSTGRES"
+
+func genBKI(catalogs map[string]*catalog) ([]byte, error) {
+	// lookup に使う、名前から OID への対応
+	names := map[string]map[string]string{
+		"pg_type":     nameToOid(catalogs["pg_type"].rows, "typname"),
+		"pg_authid":   nameToOid(catalogs["pg_authid"].rows, "rolname"),
+		"pg_proc":     procSignatures(catalogs["pg_proc"].rows),
+		"pg_am":       nameToOid(catalogs["pg_am"].rows, "amname"),
+		"pg_operator": operatorSignatures(catalogs["pg_operator"].rows),
+		"pg_opfamily": opfamilyNames(catalogs["pg_opfamily"].rows),
+	}
+	resolve := func(r *row, col columnDef, name string) (string, error) {
+		if col.lookup == "pg_proc" || col.lookup == "pg_operator" {
+			name = signatureKey(name)
+		}
+		oid, ok := names[col.lookup][name]
+		if !ok {
+			return "", fmt.Errorf("%s: unresolved OID reference %q in %s", r.pos, name, col.name)
+		}
+		return oid, nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# PostgreSQL %s\n", pgconfig.PgMajorVersion)
+	for _, def := range catalogDefs {
+		fmt.Fprintf(&buf, "create %s %d", def.name, def.relid)
+		if def.shared {
+			buf.WriteString(" shared_relation")
+		}
+		buf.WriteString("\n (\n")
+		for i, col := range def.columns {
+			sep := " ,"
+			if i == len(def.columns)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(&buf, " %s = %s%s\n", col.name, col.typ, sep)
+		}
+		buf.WriteString(" )\n")
+
+		known := make(map[string]bool, len(def.columns))
+		for _, col := range def.columns {
+			known[col.name] = true
+		}
+		for _, r := range catalogs[def.name].rows {
+			for key := range r.values {
+				if !known[key] && !metadataKeys[key] {
+					return nil, fmt.Errorf("%s: unknown column %q in %s", r.pos, key, def.name)
+				}
+			}
+			values := make([]string, len(def.columns))
+			for i, col := range def.columns {
+				v, ok := r.values[col.name]
+				if !ok {
+					v = col.def
+				}
+				switch {
+				case v == bkiNull:
+					values[i] = bkiNull
+					continue
+				case col.lookup != "" && col.typ == "_oid":
+					var oids []string
+					for _, name := range strings.Fields(v) {
+						oid, err := resolve(r, col, name)
+						if err != nil {
+							return nil, err
+						}
+						oids = append(oids, oid)
+					}
+					v = "{" + strings.Join(oids, ",") + "}"
+				case col.lookup != "" && v != "0":
+					oid, err := resolve(r, col, v)
+					if err != nil {
+						return nil, err
+					}
+					v = oid
+				}
+				values[i] = bkiValue(v)
+			}
+			fmt.Fprintf(&buf, "insert ( %s )\n", strings.Join(values, " "))
+		}
+		fmt.Fprintf(&buf, "close %s\n", def.name)
+	}
+	return buf.Bytes(), nil
+}
+
+// metadataKeys は .dat ファイルの行に書ける、列ではないキー
+var metadataKeys = map[string]bool{"oid_symbol": true, "array_type_oid": true, "descr": true}
+
+// nameToOid は行の名前の列から OID への対応を作る
+func nameToOid(rows []*row, key string) map[string]string {
+	m := make(map[string]string, len(rows))
+	for _, r := range rows {
+		m[r.values[key]] = r.values["oid"]
+	}
+	return m
+}
+
+// bkiValue は値を BKI に書く形にする。英数字だけの値以外は単一引用符で囲む。
+func bkiValue(v string) string {
+	plain := v != ""
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-') {
+			plain = false
+			break
+		}
+	}
+	if plain && v != bkiNull {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// writeRelationID はカタログの OID と列の番号の定数を書く (*_d.h の RelationId と Anum_* 相当)
+func writeRelationID(buf *bytes.Buffer, def *catalogDef) {
+	fmt.Fprintf(buf, "// %s は %s の OID\nconst %s Oid = %d\n\n", def.symbol, def.name, def.symbol, def.relid)
+	rel := goName(def.name)
+	fmt.Fprintf(buf, "// %s の列の番号 (Anum_%s_* と Natts_%s 相当)\nconst (\n", def.name, def.name, def.name)
+	for i, col := range def.columns {
+		fmt.Fprintf(buf, "Anum%s%s = %d\n", rel, goName(col.name), i+1)
+	}
+	fmt.Fprintf(buf, "Natts%s = %d\n)\n\n", rel, len(def.columns))
+}
+
+// goName は pg_type のような名前を PgType のような Go の名前にする
+func goName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+// writeOidSymbols は oid_symbol を指定した行の OID の定数を書く (*_d.h の #define 相当)
+func writeOidSymbols(buf *bytes.Buffer, name string, rows []*row) {
+	fmt.Fprintf(buf, "// %s の初期データの OID (%s_d.h 相当)\nconst (\n", name, name)
+	for _, r := range rows {
+		if sym, ok := r.values["oid_symbol"]; ok {
+			fmt.Fprintf(buf, "%s Oid = %s\n", sym, r.values["oid"])
+		}
+	}
+	buf.WriteString(")\n\n")
+}
+
+func writeHeader(buf *bytes.Buffer, source string) {
+	fmt.Fprintf(buf, "// Code generated by genbki from %s; DO NOT EDIT.\n\npackage catalog\n\n", source)
+}
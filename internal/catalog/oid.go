@@ -0,0 +1,23 @@
+package catalog
+
+import "sync"
+
+// ----------------------------------------------------------------
+// OID の割り当て (access/transam/varsup.c の GetNewObjectId 相当)
+// ----------------------------------------------------------------
+// CREATE ROLE や CREATE DOMAIN で作るオブジェクトには、種類によらず重ならない OID を割り当てる。
+// カタログはメモリ上にしかないため、サーバーを再起動するとカウンタも FirstNormalObjectID に戻る。
+
+var oidGen = struct {
+	sync.Mutex
+	next Oid
+}{next: FirstNormalObjectID}
+
+// GetNewObjectID は新しいオブジェクトの OID を返す (GetNewObjectId 相当)
+func GetNewObjectID() Oid {
+	oidGen.Lock()
+	defer oidGen.Unlock()
+	oid := oidGen.next
+	oidGen.next++
+	return oid
+}
@@ -0,0 +1,67 @@
+package catalog
+
+// ----------------------------------------------------------------
+// アクセスメソッド (pg_am 相当)
+// ----------------------------------------------------------------
+// テーブルとインデックスのアクセスメソッドの一覧。C言語版はインデックスのアクセスメソッドの
+// 性質をハンドラ関数が返す IndexAmRoutine に持つが、Go言語版はハンドラ関数がないため、
+// 演算子クラスの定義に使う性質を行に直接持つ。CREATE ACCESS METHOD はまだないため、
+// 組み込みのアクセスメソッドだけがある。その OID の定数 (GistAmOid など) は pg_am.dat から
+// genbki で pg_am_d.go に生成する。
+//
+// インデックスのアクセスメソッドの実装はまだない。演算子クラスと演算子族は定義できるが、
+// それを使うインデックスはまだ作れない。
+
+// アクセスメソッドの種類 (AMTYPE_* 相当)
+const (
+	AmtypeIndex byte = 'i'
+	AmtypeTable byte = 't'
+)
+
+// FormPgAm はアクセスメソッド1つ分 (FormData_pg_am と IndexAmRoutine の一部相当)
+type FormPgAm struct {
+	Oid    Oid
+	Amname string
+	Amtype byte
+	// Amstrategies は演算子のストラテジ番号の数。0 はストラテジ番号の意味を決めていないことを表す
+	Amstrategies uint16
+	// Amsupport はサポート関数の番号の数
+	Amsupport uint16
+	// Amoptsprocnum はオプションを解析するサポート関数の番号。なければ 0
+	Amoptsprocnum uint16
+	// Amcanorderbyop は ORDER BY の演算子 (距離の演算子など) で走査できることを表す
+	Amcanorderbyop bool
+	// Amstorage はインデックスに格納する型が列の型と異なってもよいことを表す
+	Amstorage bool
+}
+
+// builtinAms は組み込みのアクセスメソッド (pg_am.dat と各アクセスメソッドのハンドラ相当)
+var builtinAms = []FormPgAm{
+	{Oid: HeapTableAmOid, Amname: "heap", Amtype: AmtypeTable},
+	{Oid: BtreeAmOid, Amname: "btree", Amtype: AmtypeIndex, Amstrategies: 5, Amsupport: 5, Amoptsprocnum: 5},
+	{Oid: HashAmOid, Amname: "hash", Amtype: AmtypeIndex, Amstrategies: 1, Amsupport: 3, Amoptsprocnum: 3},
+	{Oid: GistAmOid, Amname: "gist", Amtype: AmtypeIndex, Amsupport: 11, Amoptsprocnum: 10, Amcanorderbyop: true, Amstorage: true},
+	{Oid: GinAmOid, Amname: "gin", Amtype: AmtypeIndex, Amsupport: 7, Amoptsprocnum: 7, Amstorage: true},
+	{Oid: SpgistAmOid, Amname: "spgist", Amtype: AmtypeIndex, Amsupport: 6, Amoptsprocnum: 6, Amcanorderbyop: true, Amstorage: true},
+	{Oid: BrinAmOid, Amname: "brin", Amtype: AmtypeIndex, Amsupport: 15, Amoptsprocnum: 5, Amstorage: true},
+}
+
+// SearchAmByName は名前からアクセスメソッドを返す (SearchSysCache(AMNAME) 相当)
+func SearchAmByName(amname string) (*FormPgAm, bool) {
+	for i := range builtinAms {
+		if builtinAms[i].Amname == amname {
+			return &builtinAms[i], true
+		}
+	}
+	return nil, false
+}
+
+// SearchAm は OID からアクセスメソッドを返す (SearchSysCache(AMOID) 相当)
+func SearchAm(amoid Oid) (*FormPgAm, bool) {
+	for i := range builtinAms {
+		if builtinAms[i].Oid == amoid {
+			return &builtinAms[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,134 @@
+package catalog
+
+import "sync"
+
+// ----------------------------------------------------------------
+// ロールのメンバーシップ (pg_auth_members, utils/adt/acl.c 相当)
+// ----------------------------------------------------------------
+// ロールをほかのロールのメンバーにすると、メンバーはそのロールの権限を引き継ぐ。
+// メンバーシップは推移的で、メンバーのメンバーも権限を引き継ぐ。
+// GRANT がまだ存在しないため、CREATE ROLE の IN ROLE、ROLE、ADMIN か GrantRole で登録する。
+// 定義済みロールどうしのメンバーシップ (pg_monitor など) は最初から登録しておく。
+//
+// メンバーシップごとに、ADMIN OPTION (そのロールをほかのロールに与えられるか) と
+// INHERIT OPTION (そのロールの権限を引き継ぐか) を持つ。INHERIT OPTION のない
+// メンバーシップは、権限の確認 (HasPrivsOfRole) ではたどらない。
+
+// GrantOptions はメンバーシップの属性 (pg_auth_members の admin_option, inherit_option 相当)
+type GrantOptions struct {
+	Admin   bool
+	Inherit bool
+}
+
+var authMembers struct {
+	sync.RWMutex
+	// m はメンバーから、そのメンバーが直接属するロールとメンバーシップの属性への対応
+	m map[string]map[string]GrantOptions
+}
+
+func init() {
+	for _, m := range predefinedRoleMembers {
+		GrantRole(m.member, m.role, GrantOptions{Inherit: true})
+	}
+}
+
+// GrantRole は member を role のメンバーにする。既にメンバーであれば属性を置き換える。
+func GrantRole(member, role string, opts GrantOptions) {
+	authMembers.Lock()
+	defer authMembers.Unlock()
+	if authMembers.m == nil {
+		authMembers.m = make(map[string]map[string]GrantOptions)
+	}
+	if authMembers.m[member] == nil {
+		authMembers.m[member] = make(map[string]GrantOptions)
+	}
+	authMembers.m[member][role] = opts
+}
+
+// RevokeRole は member を role のメンバーから外す
+func RevokeRole(member, role string) {
+	authMembers.Lock()
+	defer authMembers.Unlock()
+	delete(authMembers.m[member], role)
+}
+
+// dropRoleMembers は role がメンバーであるもの、role のメンバーであるものを全て削除する
+func dropRoleMembers(role string) {
+	authMembers.Lock()
+	defer authMembers.Unlock()
+	delete(authMembers.m, role)
+	for _, roles := range authMembers.m {
+		delete(roles, role)
+	}
+}
+
+// HasPrivsOfRole は member が role の権限を持つかどうかを返す (has_privs_of_role 相当)。
+// 同じロールである場合、スーパーユーザーである場合、INHERIT OPTION のあるメンバーシップを
+// 直接または間接にたどれる場合に真となる。
+func HasPrivsOfRole(member, role string) bool {
+	if IsSuperuser(member) {
+		return true
+	}
+	return rolesIsMemberOf(member, role, true)
+}
+
+// IsMemberOfRoleNosuper は member が role と同じロールであるか、直接または間接にメンバーであるかを
+// 返す (is_member_of_role_nosuper 相当)。スーパーユーザーであっても特別扱いしない。
+func IsMemberOfRoleNosuper(member, role string) bool {
+	return rolesIsMemberOf(member, role, false)
+}
+
+// IsAdminOfRole は member が role を管理できるか (role の ADMIN OPTION を持つか) を返す
+// (is_admin_of_role 相当)。member 自身か、member が属するロールのいずれかが role の
+// ADMIN OPTION を持てば真となる。ロールは自分自身の ADMIN OPTION を持たない。
+func IsAdminOfRole(member, role string) bool {
+	if IsSuperuser(member) {
+		return true
+	}
+	if member == role {
+		return false
+	}
+	authMembers.RLock()
+	defer authMembers.RUnlock()
+	for _, m := range memberClosure(member, false) {
+		if authMembers.m[m][role].Admin {
+			return true
+		}
+	}
+	return false
+}
+
+// rolesIsMemberOf は member から role までメンバーシップをたどれるかを返す (roles_is_member_of 相当)。
+// inheritOnly が true の場合は INHERIT OPTION のあるメンバーシップだけをたどる (ROLERECURSE_PRIVS)。
+func rolesIsMemberOf(member, role string, inheritOnly bool) bool {
+	if member == role {
+		return true
+	}
+	authMembers.RLock()
+	defer authMembers.RUnlock()
+	for _, m := range memberClosure(member, inheritOnly) {
+		if m == role {
+			return true
+		}
+	}
+	return false
+}
+
+// memberClosure は member 自身と、member が直接または間接に属する全てのロールを返す。
+// 呼び出し元が authMembers の読み取りロックを持つこと。循環していても同じロールは一度しか見ない。
+func memberClosure(member string, inheritOnly bool) []string {
+	seen := map[string]bool{member: true}
+	closure := []string{member}
+	for i := 0; i < len(closure); i++ {
+		for r, opts := range authMembers.m[closure[i]] {
+			if inheritOnly && !opts.Inherit {
+				continue
+			}
+			if !seen[r] {
+				seen[r] = true
+				closure = append(closure, r)
+			}
+		}
+	}
+	return closure
+}
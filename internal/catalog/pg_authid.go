@@ -0,0 +1,162 @@
+package catalog
+
+import (
+	"sort"
+	"sync"
+)
+
+// ----------------------------------------------------------------
+// ロール (pg_authid 相当)
+// ----------------------------------------------------------------
+// 認証と権限の確認で参照するロールの一覧。システムカタログがまだ存在しないため、
+// サーバーのメモリ上にだけ持ち、サーバーを再起動すると CREATE ROLE で作ったロールは失われる。
+//
+// 起動時に存在するのは、ブートストラップスーパーユーザーと定義済みロールだけである。
+// ブートストラップスーパーユーザーの名前は、initdb がブートストラップで global/ の pg_authid に
+// 書いたものを postmaster が読む。ロールは CREATE ROLE で作り、ALTER ROLE で
+// 属性を変更し、DROP ROLE で削除する。
+//
+// パスワードには SCRAM-SHA-256 の秘密情報、MD5 のハッシュ、または平文のパスワードの
+// いずれかを保存する。パスワードの有効期限 (rolvaliduntil) を過ぎると、パスワードによる認証では
+// ログインできなくなる。
+
+// FirstNormalObjectID は CREATE で作るオブジェクトに割り当てる最初の OID (FirstNormalObjectId 相当)
+const FirstNormalObjectID Oid = 16384
+
+// FormPgAuthid はロール1つ分 (FormData_pg_authid 相当)
+type FormPgAuthid struct {
+	Oid            Oid
+	Rolname        string
+	Rolsuper       bool
+	Rolinherit     bool
+	Rolcreaterole  bool
+	Rolcreatedb    bool
+	Rolcanlogin    bool
+	Rolreplication bool
+	Rolbypassrls   bool
+	// Rolconnlimit は同時に接続できるセッションの数。-1 は制限しない
+	Rolconnlimit int32
+	// Rolpassword はパスワードの秘密情報。空の場合はパスワードを持たない (NULL)
+	Rolpassword string
+	// Rolvaliduntil はパスワードの有効期限 (timestamp with time zone)。
+	// HasValidUntil が false の場合は期限を持たない (NULL)
+	Rolvaliduntil int64
+	HasValidUntil bool
+}
+
+var authid struct {
+	sync.RWMutex
+	m map[string]*FormPgAuthid
+}
+
+func init() {
+	authid.m = make(map[string]*FormPgAuthid)
+	for _, r := range PredefinedRoles {
+		authid.m[r.Rolname] = &FormPgAuthid{Oid: r.Oid, Rolname: r.Rolname, Rolinherit: true, Rolconnlimit: -1}
+	}
+}
+
+// SetBootstrapSuperuser はブートストラップスーパーユーザーを作る (initdb の bootstrap 相当)。
+// 全ての属性を持ち、OID は BootstrapSuperuserID とする。同じ名前のロールがあれば置き換える。
+func SetBootstrapSuperuser(rolname string) {
+	authid.Lock()
+	defer authid.Unlock()
+	for name, r := range authid.m {
+		if r.Oid == BootstrapSuperuserID {
+			delete(authid.m, name)
+		}
+	}
+	authid.m[rolname] = &FormPgAuthid{
+		Oid:            BootstrapSuperuserID,
+		Rolname:        rolname,
+		Rolsuper:       true,
+		Rolinherit:     true,
+		Rolcreaterole:  true,
+		Rolcreatedb:    true,
+		Rolcanlogin:    true,
+		Rolreplication: true,
+		Rolbypassrls:   true,
+		Rolconnlimit:   -1,
+	}
+}
+
+// SearchRole は名前からロールを返す (SearchSysCache(AUTHNAME) 相当)。
+// 返す値は写しで、変更しても一覧には反映されない。
+func SearchRole(rolname string) (FormPgAuthid, bool) {
+	authid.RLock()
+	defer authid.RUnlock()
+	r, ok := authid.m[rolname]
+	if !ok {
+		return FormPgAuthid{}, false
+	}
+	return *r, true
+}
+
+// SearchRoleByOid は OID からロールを返す (SearchSysCache(AUTHOID) 相当)
+func SearchRoleByOid(roleid Oid) (FormPgAuthid, bool) {
+	authid.RLock()
+	defer authid.RUnlock()
+	for _, r := range authid.m {
+		if r.Oid == roleid {
+			return *r, true
+		}
+	}
+	return FormPgAuthid{}, false
+}
+
+// ListRoles は全てのロールを OID の順に返す
+func ListRoles() []FormPgAuthid {
+	authid.RLock()
+	defer authid.RUnlock()
+	roles := make([]FormPgAuthid, 0, len(authid.m))
+	for _, r := range authid.m {
+		roles = append(roles, *r)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Oid < roles[j].Oid })
+	return roles
+}
+
+// CreateRole はロールを追加し、割り当てた OID を返す (CatalogTupleInsert 相当)。
+// 同じ名前のロールが既にあれば追加せずに ok に false を返す。
+func CreateRole(role FormPgAuthid) (oid Oid, ok bool) {
+	authid.Lock()
+	defer authid.Unlock()
+	if _, exists := authid.m[role.Rolname]; exists {
+		return InvalidOid, false
+	}
+	role.Oid = GetNewObjectID()
+	authid.m[role.Rolname] = &role
+	return role.Oid, true
+}
+
+// UpdateRole はロールを update で変更する (CatalogTupleUpdate 相当)。ロールがなければ false を返す。
+func UpdateRole(rolname string, update func(role *FormPgAuthid)) bool {
+	authid.Lock()
+	defer authid.Unlock()
+	r, ok := authid.m[rolname]
+	if !ok {
+		return false
+	}
+	update(r)
+	return true
+}
+
+// DropRole はロールと、そのロールに関するメンバーシップを削除する (CatalogTupleDelete 相当)。
+// ロールがなければ false を返す。
+func DropRole(rolname string) bool {
+	authid.Lock()
+	r, ok := authid.m[rolname]
+	delete(authid.m, rolname)
+	authid.Unlock()
+	if ok {
+		dropRoleMembers(rolname)
+		dropRoleSettings(r.Oid)
+	}
+	return ok
+}
+
+// IsSuperuser はロールがスーパーユーザーかどうかを返す (superuser_arg 相当)
+func IsSuperuser(rolname string) bool {
+	r, ok := SearchRole(rolname)
+	return ok && r.Rolsuper
+}
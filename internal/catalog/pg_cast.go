@@ -0,0 +1,106 @@
+package catalog
+
+import (
+	"sort"
+	"sync"
+)
+
+// ----------------------------------------------------------------
+// 型変換 (pg_cast 相当)
+// ----------------------------------------------------------------
+// 型から型への変換の方法と、変換を暗黙に行える場面を持つ。組み込みの変換の行 (builtinCasts) は
+// pg_cast.dat から genbki で pg_cast_d.go に生成する。CREATE CAST で作る変換は、ドメインと
+// 同じくサーバーのメモリ上にだけ持つ。
+//
+// 組み込みの変換の OID は FirstNormalObjectID より小さく、削除できない。
+
+// 変換を暗黙に行える場面 (COERCION_CODE_* 相当)
+const (
+	// CoercionCodeImplicit は関数の引数や演算子の被演算子として暗黙に変換できる
+	CoercionCodeImplicit byte = 'i'
+	// CoercionCodeAssignment は列への代入で暗黙に変換できる
+	CoercionCodeAssignment byte = 'a'
+	// CoercionCodeExplicit は CAST か :: で明示したときだけ変換できる
+	CoercionCodeExplicit byte = 'e'
+)
+
+// 変換の方法 (COERCION_METHOD_* 相当)
+const (
+	// CoercionMethodFunction は castfunc の関数を呼んで変換する
+	CoercionMethodFunction byte = 'f'
+	// CoercionMethodBinary は内部表現が同じで、値をそのまま使う
+	CoercionMethodBinary byte = 'b'
+	// CoercionMethodInOut は変換元の出力関数と変換先の入力関数で変換する
+	CoercionMethodInOut byte = 'i'
+)
+
+// FormPgCast は型変換1つ分 (FormData_pg_cast 相当)
+type FormPgCast struct {
+	Oid        Oid
+	Castsource Oid
+	Casttarget Oid
+	// Castfunc は変換の関数。Castmethod が CoercionMethodFunction でなければ InvalidOid
+	Castfunc    Oid
+	Castcontext byte
+	Castmethod  byte
+}
+
+var userCasts struct {
+	sync.RWMutex
+	byOid map[Oid]*FormPgCast
+}
+
+// SearchCast は source から target への変換を返す (SearchSysCache(CASTSOURCETARGET) 相当)
+func SearchCast(source, target Oid) (FormPgCast, bool) {
+	for _, c := range builtinCasts {
+		if c.Castsource == source && c.Casttarget == target {
+			return c, true
+		}
+	}
+	userCasts.RLock()
+	defer userCasts.RUnlock()
+	for _, c := range userCasts.byOid {
+		if c.Castsource == source && c.Casttarget == target {
+			return *c, true
+		}
+	}
+	return FormPgCast{}, false
+}
+
+// CreateCast は変換を追加し、割り当てた OID を返す (CastCreate 相当)。同じ型の組の変換が
+// 既にあれば追加せずに ok に false を返す。
+func CreateCast(cast FormPgCast) (oid Oid, ok bool) {
+	if _, exists := SearchCast(cast.Castsource, cast.Casttarget); exists {
+		return InvalidOid, false
+	}
+	userCasts.Lock()
+	defer userCasts.Unlock()
+	if userCasts.byOid == nil {
+		userCasts.byOid = make(map[Oid]*FormPgCast)
+	}
+	cast.Oid = GetNewObjectID()
+	userCasts.byOid[cast.Oid] = &cast
+	return cast.Oid, true
+}
+
+// DropCast は CREATE CAST で作った変換を削除する (DropCastById 相当)
+func DropCast(castoid Oid) {
+	userCasts.Lock()
+	defer userCasts.Unlock()
+	delete(userCasts.byOid, castoid)
+}
+
+// CastsOnType は型を変換元か変換先とする CREATE CAST の変換を OID の順に返す。
+// 型を削除するときに、依存する変換を探すために使う。
+func CastsOnType(typid Oid) []FormPgCast {
+	userCasts.RLock()
+	defer userCasts.RUnlock()
+	var out []FormPgCast
+	for _, c := range userCasts.byOid {
+		if c.Castsource == typid || c.Casttarget == typid {
+			out = append(out, *c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Oid < out[j].Oid })
+	return out
+}
@@ -0,0 +1,159 @@
+package catalog
+
+import (
+	"sort"
+	"sync"
+)
+
+// ----------------------------------------------------------------
+// リレーション (pg_class と pg_attribute 相当)
+// ----------------------------------------------------------------
+// CREATE TABLE と CREATE TABLE AS で作るテーブルの定義を持つ。システムビューは system_views.go の表にあり、
+// ここには入らない。行は共有バッファを通してヒープのファイルに書くが、定義はドメインと同じく
+// サーバーのメモリ上にだけ持つため、サーバーを再起動すると消える。ファイルは残るため、
+// 新しいテーブルのファイルの番号は、既にあるファイルと重ならないものを選ぶ
+// (GetNewRelFileNumber 相当、呼び出し元が行う)。
+//
+// 定義の変更はトランザクションに従わない。変更したトランザクションがアボートしたら、呼び出し元が
+// 前の定義に戻す。他のセッションからは作った時点で定義が見えるが、行はスナップショット
+// からコミットが見えるまで見えない。
+
+// リレーションの種類 (relkind 相当)
+const (
+	RelkindRelation byte = 'r'
+)
+
+// リレーションの永続性 (relpersistence 相当)
+const (
+	RelpersistencePermanent byte = 'p'
+)
+
+// FormPgAttribute はリレーションの列1つ分 (FormData_pg_attribute の一部相当)
+type FormPgAttribute struct {
+	Attname  string
+	Atttypid Oid
+}
+
+// FormPgClass はリレーション1つ分 (FormData_pg_class の一部と、その列の定義相当)
+type FormPgClass struct {
+	Oid          Oid
+	Relname      string
+	Relnamespace Oid
+	Relowner     Oid
+	// Reldatabase はリレーションを作ったデータベース。カタログはデータベースごとに分かれて
+	// いないため、ファイルの場所を決めるために持つ
+	Reldatabase Oid
+	// Relfilenode はリレーションのファイルの番号
+	Relfilenode    Oid
+	Relkind        byte
+	Relpersistence byte
+	// Attrs は列の並び。attnum の順に並べる
+	Attrs []FormPgAttribute
+	// Inhparents は親のテーブル (pg_inherits の inhparent を inhseqno の順に並べたもの相当)
+	Inhparents []Oid
+}
+
+var relations struct {
+	sync.RWMutex
+	byOid map[Oid]*FormPgClass
+}
+
+// copyRelation は定義の写しを返す。列と親の並びも写す
+func copyRelation(rel *FormPgClass) *FormPgClass {
+	c := *rel
+	c.Attrs = append([]FormPgAttribute(nil), rel.Attrs...)
+	c.Inhparents = append([]Oid(nil), rel.Inhparents...)
+	return &c
+}
+
+// relnameGetRelationLocked は名前からリレーションを探す。relations のロックを持って呼ぶ
+func relnameGetRelationLocked(nspid Oid, relname string) (*FormPgClass, bool) {
+	for _, rel := range relations.byOid {
+		if rel.Relnamespace == nspid && rel.Relname == relname {
+			return rel, true
+		}
+	}
+	return nil, false
+}
+
+// RelnameGetRelation はスキーマ名とリレーションの名前からリレーションを探し、定義の写しを返す
+// (RangeVarGetRelid 相当)。nspname が空の場合は search_path の既定の作成先である public から探す。
+func RelnameGetRelation(nspname, relname string) (*FormPgClass, bool) {
+	nspid := PgPublicNamespace
+	if nspname != "" {
+		var ok bool
+		if nspid, ok = NamespaceGetOid(nspname); !ok {
+			return nil, false
+		}
+	}
+	relations.RLock()
+	defer relations.RUnlock()
+	if rel, ok := relnameGetRelationLocked(nspid, relname); ok {
+		return copyRelation(rel), true
+	}
+	return nil, false
+}
+
+// SearchRelation は OID からリレーションの定義の写しを返す (SearchSysCache(RELOID) 相当)
+func SearchRelation(relid Oid) (*FormPgClass, bool) {
+	relations.RLock()
+	defer relations.RUnlock()
+	if rel, ok := relations.byOid[relid]; ok {
+		return copyRelation(rel), true
+	}
+	return nil, false
+}
+
+// CreateRelation はリレーションの定義を追加する (heap_create_with_catalog のカタログの更新相当)。
+// OID とファイルの番号は呼び出し元が決めておく。同じスキーマに同じ名前のリレーションが既に
+// あれば追加せずに false を返す。
+func CreateRelation(rel FormPgClass) bool {
+	relations.Lock()
+	defer relations.Unlock()
+	if _, exists := relnameGetRelationLocked(rel.Relnamespace, rel.Relname); exists {
+		return false
+	}
+	if relations.byOid == nil {
+		relations.byOid = make(map[Oid]*FormPgClass)
+	}
+	relations.byOid[rel.Oid] = copyRelation(&rel)
+	return true
+}
+
+// UpdateRelation は OID が同じリレーションの定義を置き換える (CatalogTupleUpdate 相当)。
+// 定義がなければ false を返す。
+func UpdateRelation(rel FormPgClass) bool {
+	relations.Lock()
+	defer relations.Unlock()
+	if _, ok := relations.byOid[rel.Oid]; !ok {
+		return false
+	}
+	relations.byOid[rel.Oid] = copyRelation(&rel)
+	return true
+}
+
+// DropRelation はリレーションの定義を削除する (heap_drop_with_catalog のカタログの更新相当)。
+// 定義がなければ false を返す。
+func DropRelation(relid Oid) bool {
+	relations.Lock()
+	defer relations.Unlock()
+	if _, ok := relations.byOid[relid]; !ok {
+		return false
+	}
+	delete(relations.byOid, relid)
+	return true
+}
+
+// RelationsOwnedBy は roleid が所有するリレーションを OID の順に返す (pg_shdepend を roleid で引く処理相当)
+func RelationsOwnedBy(roleid Oid) []FormPgClass {
+	relations.RLock()
+	defer relations.RUnlock()
+	var out []FormPgClass
+	for _, rel := range relations.byOid {
+		if rel.Relowner == roleid {
+			out = append(out, *copyRelation(rel))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Oid < out[j].Oid })
+	return out
+}
@@ -0,0 +1,98 @@
+package catalog
+
+import (
+	"sort"
+	"sync"
+)
+
+// ----------------------------------------------------------------
+// 制約 (pg_constraint 相当)
+// ----------------------------------------------------------------
+// テーブルは CREATE TABLE AS で作るものだけで制約を持たないため、持つのはドメインの CHECK 制約
+// だけである。ドメインの NOT NULL は pg_type の typnotnull で表す。
+// ドメインを削除すると、その制約も削除する。
+
+// 制約の種類 (CONSTRAINT_* 相当)
+const (
+	ConstraintCheck byte = 'c'
+)
+
+// FormPgConstraint は制約1つ分 (FormData_pg_constraint の一部相当)
+type FormPgConstraint struct {
+	Oid     Oid
+	Conname string
+	Contype byte
+	// Convalidated は既存の値を確かめ終えていることを表す。NOT VALID で加えた制約は false
+	Convalidated bool
+	// Contypid は制約を持つドメイン
+	Contypid Oid
+	// Conbin は CHECK 制約の式 (parser.Expr)。ドメインの値は CoerceToDomainValue で参照する
+	Conbin any
+}
+
+var constraints struct {
+	sync.RWMutex
+	byOid map[Oid]*FormPgConstraint
+}
+
+// TypeConstraints はドメインの制約を OID の順、つまり加えた順に返す
+func TypeConstraints(typid Oid) []FormPgConstraint {
+	constraints.RLock()
+	defer constraints.RUnlock()
+	var out []FormPgConstraint
+	for _, c := range constraints.byOid {
+		if c.Contypid == typid {
+			out = append(out, *c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Oid < out[j].Oid })
+	return out
+}
+
+// CreateConstraint は制約を追加し、割り当てた OID を返す (CreateConstraintEntry 相当)。
+// 同じドメインに同じ名前の制約が既にあれば追加せずに ok に false を返す。
+func CreateConstraint(con FormPgConstraint) (oid Oid, ok bool) {
+	constraints.Lock()
+	defer constraints.Unlock()
+	for _, c := range constraints.byOid {
+		if c.Contypid == con.Contypid && c.Conname == con.Conname {
+			return InvalidOid, false
+		}
+	}
+	if constraints.byOid == nil {
+		constraints.byOid = make(map[Oid]*FormPgConstraint)
+	}
+	con.Oid = GetNewObjectID()
+	constraints.byOid[con.Oid] = &con
+	return con.Oid, true
+}
+
+// UpdateConstraint は制約を update で変更する (CatalogTupleUpdate 相当)。制約がなければ false を返す。
+func UpdateConstraint(conoid Oid, update func(con *FormPgConstraint)) bool {
+	constraints.Lock()
+	defer constraints.Unlock()
+	c, ok := constraints.byOid[conoid]
+	if !ok {
+		return false
+	}
+	update(c)
+	return true
+}
+
+// DropConstraint は制約を削除する (RemoveConstraintById 相当)
+func DropConstraint(conoid Oid) {
+	constraints.Lock()
+	defer constraints.Unlock()
+	delete(constraints.byOid, conoid)
+}
+
+// dropTypeConstraints はドメインの全ての制約を削除する
+func dropTypeConstraints(typid Oid) {
+	constraints.Lock()
+	defer constraints.Unlock()
+	for oid, c := range constraints.byOid {
+		if c.Contypid == typid {
+			delete(constraints.byOid, oid)
+		}
+	}
+}
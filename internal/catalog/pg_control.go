@@ -0,0 +1,92 @@
+package catalog
+
+// ----------------------------------------------------------------
+// 制御ファイル (catalog/pg_control.h 相当)
+// ----------------------------------------------------------------
+// データディレクトリの global/pg_control に置く、クラスタ全体の状態。initdb が作り、
+// サーバーは起動時に読んで、データディレクトリがこのサーバーで扱えるものかを確かめる。
+//
+// WAL がまだ存在しないため、C言語版の項目のうち、チェックポイントの位置とリカバリの状態に
+// 関するものは持たない。チェックポイントの内容 (checkPointCopy) は次の ID だけを持つ。ファイルは C言語版と同じく
+// PgControlFileSize バイトに 0 で埋め、最後の項目に CRC-32C を書く。
+
+// PgControlVersion は制御ファイルの形式の版 (PG_CONTROL_VERSION 相当)
+const PgControlVersion = 1700
+
+// PgControlFileSize は制御ファイルの大きさ (PG_CONTROL_FILE_SIZE 相当)。
+// 内容は PgControlMaxSafeSize バイトに収め、1セクタへの書き込みで壊れないようにする
+const PgControlFileSize = 8192
+
+// PgControlMaxSafeSize は制御ファイルの内容の上限 (PG_CONTROL_MAX_SAFE_SIZE 相当)
+const PgControlMaxSafeSize = 512
+
+// MockAuthNonceLen はモック認証に使う乱数の長さ (MOCK_AUTH_NONCE_LEN 相当)
+const MockAuthNonceLen = 32
+
+// DBState はクラスタの状態 (DBState 相当)
+type DBState uint32
+
+const (
+	DBStartup DBState = iota
+	DBShutdowned
+	DBShutdownedInRecovery
+	DBShutdowning
+	DBInCrashRecovery
+	DBInArchiveRecovery
+	DBInProduction
+)
+
+// dbStateNames は pg_controldata が表示する名前 (dbState 相当)
+var dbStateNames = [...]string{
+	DBStartup:              "starting up",
+	DBShutdowned:           "shut down",
+	DBShutdownedInRecovery: "shut down in recovery",
+	DBShutdowning:          "shutting down",
+	DBInCrashRecovery:      "in crash recovery",
+	DBInArchiveRecovery:    "in archive recovery",
+	DBInProduction:         "in production",
+}
+
+func (s DBState) String() string {
+	if int(s) < len(dbStateNames) {
+		return dbStateNames[s]
+	}
+	return "unrecognized status code"
+}
+
+// ControlFileData は制御ファイルの内容 (ControlFileData 相当)。
+// 全ての項目を固定長にし、この順にリトルエンディアンで書く。
+type ControlFileData struct {
+	// SystemIdentifier は initdb が作ったときに決める、クラスタの一意な識別子
+	SystemIdentifier uint64
+	PgControlVersion uint32
+	CatalogVersionNo uint32
+
+	State DBState
+	// Time は最後に制御ファイルを更新した時刻 (Unix 時刻の秒)
+	Time int64
+	// NextXid は最後のチェックポイントの時点で次に割り当てる、epoch を含むトランザクション ID
+	// (checkPointCopy.nextXid 相当)
+	NextXid uint64
+
+	// 以下はスタンバイがプライマリと同じ値以上を設定する必要があるパラメータの値
+	MaxConnections     int32
+	MaxWorkerProcesses int32
+
+	// 以下はデータディレクトリの形式を決めるビルド時の定数。異なる値で作った
+	// データディレクトリは読めない
+	BlckSz      uint32
+	NameDataLen uint32
+
+	// DataChecksumVersion はデータページのチェックサムの版。0 はチェックサムを使わない
+	DataChecksumVersion uint32
+
+	// MockAuthNonce は存在しないロールへの SCRAM 認証で応答を偽装するための乱数
+	MockAuthNonce [MockAuthNonceLen]byte
+
+	// Crc はここまでの内容の CRC-32C
+	Crc uint32
+}
+
+// PgDataChecksumVersion はデータページのチェックサムの版 (PG_DATA_CHECKSUM_VERSION 相当)
+const PgDataChecksumVersion = 1
@@ -0,0 +1,27 @@
+package catalog
+
+// ----------------------------------------------------------------
+// データベース (pg_database 相当)
+// ----------------------------------------------------------------
+// initdb が作る3つのデータベース (template1、template0、postgres) の行は pg_database.dat に
+// 定義し、ブートストラップで global/ の pg_database に書く。データベースのファイルは
+// base/<OID> に置く。CREATE DATABASE はまだないため、データベースはこの3つだけである。
+
+// GetDatabaseOid はデータベースの名前から OID を返す (get_database_oid 相当)。
+// そのデータベースがなければ InvalidOid を返す。
+func GetDatabaseOid(datname string) Oid {
+	switch datname {
+	case "template1":
+		return Template1DbOid
+	case "template0":
+		return Template0DbOid
+	case "postgres":
+		return PostgresDbOid
+	}
+	return InvalidOid
+}
+
+// ListDatabases はデータベースの名前を OID の順に返す (pg_database の全ての行を読むこと相当)
+func ListDatabases() []string {
+	return []string{"template1", "template0", "postgres"}
+}
@@ -0,0 +1,62 @@
+package catalog
+
+import (
+	"slices"
+	"sync"
+)
+
+// ----------------------------------------------------------------
+// データベースとロールごとの設定 (pg_db_role_setting 相当)
+// ----------------------------------------------------------------
+// ALTER ROLE SET と ALTER DATABASE SET で設定したパラメータの値を、"名前=値" の並び
+// (setconfig) として持つ。接続の開始時に、データベースとロールの組み合わせ、ロール、
+// データベース、全体の順に優先してセッションの値にする。
+//
+// データベースのカタログ (pg_database) がまだ存在しないため、データベースは OID ではなく
+// 名前で区別する。データベース名が空の場合は全てのデータベース、ロールが InvalidOid の場合は
+// 全てのロールへの設定を表す。
+
+type dbRoleSettingKey struct {
+	database string
+	role     Oid
+}
+
+var dbRoleSettings struct {
+	sync.RWMutex
+	m map[dbRoleSettingKey][]string
+}
+
+// GetDbRoleSetting はデータベースとロールの組み合わせへの設定を返す
+func GetDbRoleSetting(database string, role Oid) []string {
+	dbRoleSettings.RLock()
+	defer dbRoleSettings.RUnlock()
+	return slices.Clone(dbRoleSettings.m[dbRoleSettingKey{database, role}])
+}
+
+// AlterDbRoleSetting はデータベースとロールの組み合わせへの設定を update の結果で置き換える
+// (AlterSetting 相当)。結果が空であれば組み合わせごと削除する。
+func AlterDbRoleSetting(database string, role Oid, update func(setconfig []string) []string) {
+	dbRoleSettings.Lock()
+	defer dbRoleSettings.Unlock()
+	key := dbRoleSettingKey{database, role}
+	setconfig := update(slices.Clone(dbRoleSettings.m[key]))
+	if len(setconfig) == 0 {
+		delete(dbRoleSettings.m, key)
+		return
+	}
+	if dbRoleSettings.m == nil {
+		dbRoleSettings.m = make(map[dbRoleSettingKey][]string)
+	}
+	dbRoleSettings.m[key] = setconfig
+}
+
+// dropRoleSettings はロールへの設定を全て削除する (DropSetting 相当)
+func dropRoleSettings(role Oid) {
+	dbRoleSettings.Lock()
+	defer dbRoleSettings.Unlock()
+	for key := range dbRoleSettings.m {
+		if key.role == role {
+			delete(dbRoleSettings.m, key)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package catalog
+
+import (
+	"slices"
+	"sort"
+	"sync"
+)
+
+// ----------------------------------------------------------------
+// 既定のアクセス権限 (pg_default_acl 相当)
+// ----------------------------------------------------------------
+// ALTER DEFAULT PRIVILEGES で設定した、ロールが新しく作るオブジェクトに付ける ACL を、ロール、
+// スキーマ、オブジェクトの種類ごとに持つ。スキーマが InvalidOid の行はどのスキーマにも当てはまり、
+// 種類ごとの既定の ACL (acldefault) の代わりになる。スキーマを指定した行は、それに加える権限を
+// 表す。行の ACL が既定の ACL と同じになれば行を削除する。
+
+// FormPgDefaultAcl は既定の権限1つ分 (FormData_pg_default_acl 相当)
+type FormPgDefaultAcl struct {
+	Oid Oid
+	// Defaclrole はオブジェクトを作るロール
+	Defaclrole Oid
+	// Defaclnamespace は当てはまるスキーマ。全てのスキーマなら InvalidOid
+	Defaclnamespace Oid
+	// Defaclobjtype はオブジェクトの種類 (DefaclObj*)
+	Defaclobjtype byte
+	Defaclacl     Acl
+}
+
+type defaultAclKey struct {
+	role      Oid
+	namespace Oid
+	objtype   byte
+}
+
+var defaultAcls struct {
+	sync.RWMutex
+	m map[defaultAclKey]*FormPgDefaultAcl
+}
+
+// SearchDefaultAcl はロール、スキーマ、種類の既定の権限を返す (DEFACLROLENSPOBJ の syscache 相当)
+func SearchDefaultAcl(role, namespace Oid, objtype byte) (FormPgDefaultAcl, bool) {
+	defaultAcls.RLock()
+	defer defaultAcls.RUnlock()
+	d, ok := defaultAcls.m[defaultAclKey{role, namespace, objtype}]
+	if !ok {
+		return FormPgDefaultAcl{}, false
+	}
+	c := *d
+	c.Defaclacl = slices.Clone(d.Defaclacl)
+	return c, true
+}
+
+// AlterDefaultAcl はロール、スキーマ、種類の既定の権限を update の結果で置き換える (SetDefaultACL
+// のカタログの更新相当)。行がなければ update に nil と false を渡す。結果が nil であれば行を削除する。
+func AlterDefaultAcl(role, namespace Oid, objtype byte, update func(acl Acl, exists bool) Acl) {
+	defaultAcls.Lock()
+	defer defaultAcls.Unlock()
+	key := defaultAclKey{role, namespace, objtype}
+	d, exists := defaultAcls.m[key]
+	var old Acl
+	if exists {
+		old = slices.Clone(d.Defaclacl)
+	}
+	acl := update(old, exists)
+	switch {
+	case acl == nil:
+		delete(defaultAcls.m, key)
+	case exists:
+		d.Defaclacl = acl
+	default:
+		if defaultAcls.m == nil {
+			defaultAcls.m = make(map[defaultAclKey]*FormPgDefaultAcl)
+		}
+		defaultAcls.m[key] = &FormPgDefaultAcl{
+			Oid:             GetNewObjectID(),
+			Defaclrole:      role,
+			Defaclnamespace: namespace,
+			Defaclobjtype:   objtype,
+			Defaclacl:       acl,
+		}
+	}
+}
+
+// ListDefaultAcls は全ての既定の権限を OID の順に返す
+func ListDefaultAcls() []FormPgDefaultAcl {
+	defaultAcls.RLock()
+	defer defaultAcls.RUnlock()
+	out := make([]FormPgDefaultAcl, 0, len(defaultAcls.m))
+	for _, d := range defaultAcls.m {
+		c := *d
+		c.Defaclacl = slices.Clone(d.Defaclacl)
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Oid < out[j].Oid })
+	return out
+}
+
+// GetUserDefaultAcl は owner がスキーマ namespace に作る objtype のオブジェクトに付ける ACL を返す
+// (get_user_default_acl 相当)。全てのスキーマへの既定の権限 (なければ acldefault) に、スキーマへの
+// 既定の権限を加える。結果が acldefault と同じであれば、既定の ACL を表す nil を返す。
+func GetUserDefaultAcl(objtype byte, owner, namespace Oid) Acl {
+	globAcl, globFound := SearchDefaultAcl(owner, InvalidOid, objtype)
+	schemaAcl, schemaFound := SearchDefaultAcl(owner, namespace, objtype)
+	if !globFound && !schemaFound {
+		return nil
+	}
+	defAcl := AclDefault(objtype, owner)
+	glob := globAcl.Defaclacl
+	if !globFound {
+		glob = defAcl
+	}
+	result := AclItemSort(AclMerge(glob, schemaAcl.Defaclacl))
+	if AclEqual(result, AclItemSort(defAcl)) {
+		return nil
+	}
+	return result
+}
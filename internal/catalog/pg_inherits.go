@@ -0,0 +1,42 @@
+package catalog
+
+import "sort"
+
+// ----------------------------------------------------------------
+// テーブルの継承 (pg_inherits と catalog/pg_inherits.c 相当)
+// ----------------------------------------------------------------
+// 子のテーブルの定義が親の OID を Inhparents に持つ。親から子を探すときは全ての定義を調べる。
+
+// FindInheritanceChildren は relid を直接の親に持つテーブルの OID を返す
+// (find_inheritance_children 相当)。OID の順に並べる。
+func FindInheritanceChildren(relid Oid) []Oid {
+	relations.RLock()
+	defer relations.RUnlock()
+	var children []Oid
+	for _, rel := range relations.byOid {
+		for _, parent := range rel.Inhparents {
+			if parent == relid {
+				children = append(children, rel.Oid)
+				break
+			}
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i] < children[j] })
+	return children
+}
+
+// FindAllInheritors は relid と、その子孫の全てのテーブルの OID を返す (find_all_inheritors 相当)。
+// relid を先頭にして、親に近い順に並べる。複数の親を持つテーブルは1回だけ含める。
+func FindAllInheritors(relid Oid) []Oid {
+	rels := []Oid{relid}
+	seen := map[Oid]bool{relid: true}
+	for i := 0; i < len(rels); i++ {
+		for _, child := range FindInheritanceChildren(rels[i]) {
+			if !seen[child] {
+				seen[child] = true
+				rels = append(rels, child)
+			}
+		}
+	}
+	return rels
+}
@@ -0,0 +1,45 @@
+package catalog
+
+// ----------------------------------------------------------------
+// スキーマ (pg_namespace 相当)
+// ----------------------------------------------------------------
+// CREATE SCHEMA がまだないため、initdb が作る pg_catalog と public だけがある。ドメインなどの
+// オブジェクトの名前はまだスキーマで修飾しない。組み込みのオブジェクトは pg_catalog に、
+// ユーザーが作ったオブジェクトは search_path の既定の作成先である public にあるとみなす。
+
+// 組み込みのスキーマの OID (PostgreSQL 本体と同じ値)
+const (
+	PgCatalogNamespace Oid = 11
+	PgPublicNamespace  Oid = 2200
+)
+
+// FormPgNamespace はスキーマ1つ分の定義 (FormData_pg_namespace の一部相当)
+type FormPgNamespace struct {
+	Oid     Oid
+	Nspname string
+}
+
+var builtinNamespaces = []FormPgNamespace{
+	{Oid: PgCatalogNamespace, Nspname: "pg_catalog"},
+	{Oid: PgPublicNamespace, Nspname: "public"},
+}
+
+// NamespaceGetOid はスキーマの名前から OID を返す (get_namespace_oid 相当)
+func NamespaceGetOid(nspname string) (Oid, bool) {
+	for _, n := range builtinNamespaces {
+		if n.Nspname == nspname {
+			return n.Oid, true
+		}
+	}
+	return InvalidOid, false
+}
+
+// NamespaceGetName はスキーマの OID から名前を返す (get_namespace_name 相当)。なければ空文字列
+func NamespaceGetName(nspid Oid) string {
+	for _, n := range builtinNamespaces {
+		if n.Oid == nspid {
+			return n.Nspname
+		}
+	}
+	return ""
+}
@@ -0,0 +1,164 @@
+package catalog
+
+import (
+	"sort"
+	"sync"
+)
+
+// ----------------------------------------------------------------
+// 演算子 (pg_operator 相当)
+// ----------------------------------------------------------------
+// CREATE OPERATOR で作る演算子を持つ。演算子は名前と左右の被演算子の型で識別し、前置演算子は
+// 左の型を InvalidOid にする。後置演算子は PostgreSQL 14 で廃止されたため作れない。
+//
+// 組み込みの演算子の行 (builtinOperators) は pg_operator.dat から genbki で pg_operator_d.go に
+// 生成する。組み込みの比較演算子と算術演算子はまだ行を持たず、式の解析と実行器が直接扱う。
+// CREATE OPERATOR で作る行は、ドメインと同じくサーバーのメモリ上にだけ持つ。組み込みの演算子の
+// OID は FirstNormalObjectID より小さく、削除できない。
+//
+// 交換演算子や否定演算子に、まだない演算子を指定した場合は、関数を持たない「殻」の演算子を
+// 作っておく (OperatorShellMake 相当)。後でその演算子を定義すると殻を埋める。
+
+// 演算子の種類 (oprkind 相当)
+const (
+	OprkindBinary byte = 'b'
+	OprkindPrefix byte = 'l'
+)
+
+// FormPgOperator は演算子1つ分 (FormData_pg_operator 相当)
+type FormPgOperator struct {
+	Oid      Oid
+	Oprname  string
+	Oprowner Oid
+	Oprkind  byte
+	// Oprcanmerge と Oprcanhash は演算子がマージ結合とハッシュ結合に使えることを表す
+	Oprcanmerge bool
+	Oprcanhash  bool
+	// Oprleft は左の被演算子の型で、前置演算子では InvalidOid
+	Oprleft   Oid
+	Oprright  Oid
+	Oprresult Oid
+	// Oprcom は交換演算子 (x op y = y com x となる演算子)、Oprnegate は否定演算子。なければ InvalidOid
+	Oprcom    Oid
+	Oprnegate Oid
+	// Oprcode は演算子を実装する関数。殻の演算子では InvalidOid
+	Oprcode Oid
+	// Oprrest と Oprjoin は制約と結合の選択率を推定する関数の名前。なければ空。
+	// 推定を使うプランナーがまだないため、関数の OID ではなく名前を持つ
+	Oprrest string
+	Oprjoin string
+}
+
+var operators struct {
+	sync.RWMutex
+	byOid map[Oid]*FormPgOperator
+}
+
+func init() {
+	operators.byOid = make(map[Oid]*FormPgOperator)
+	for i := range builtinOperators {
+		operators.byOid[builtinOperators[i].Oid] = &builtinOperators[i]
+	}
+}
+
+// OperatorLookup は名前と被演算子の型から演算子を返す (OpernameGetOprid 相当)。
+// 前置演算子は left に InvalidOid を渡す。
+func OperatorLookup(name string, left, right Oid) (FormPgOperator, bool) {
+	operators.RLock()
+	defer operators.RUnlock()
+	for _, op := range operators.byOid {
+		if op.Oprname == name && op.Oprleft == left && op.Oprright == right {
+			return *op, true
+		}
+	}
+	return FormPgOperator{}, false
+}
+
+// SearchOperator は OID から演算子を返す (SearchSysCache(OPEROID) 相当)
+func SearchOperator(oproid Oid) (FormPgOperator, bool) {
+	operators.RLock()
+	defer operators.RUnlock()
+	if op, ok := operators.byOid[oproid]; ok {
+		return *op, true
+	}
+	return FormPgOperator{}, false
+}
+
+// OpernameGetCandidates は名前と種類が一致する演算子を OID の順に返す (OpernameGetCandidates 相当)
+func OpernameGetCandidates(name string, kind byte) []FormPgOperator {
+	return listOperators(func(op *FormPgOperator) bool { return op.Oprname == name && op.Oprkind == kind })
+}
+
+// CreateOperator は演算子を追加し、割り当てた OID を返す。同じ名前と被演算子の型の演算子が
+// 既にあるかは呼び出し側で確かめる。
+func CreateOperator(op FormPgOperator) Oid {
+	operators.Lock()
+	defer operators.Unlock()
+	op.Oid = GetNewObjectID()
+	operators.byOid[op.Oid] = &op
+	return op.Oid
+}
+
+// UpdateOperator は演算子を update で変更する (CatalogTupleUpdate 相当)。演算子がなければ false を返す。
+func UpdateOperator(oproid Oid, update func(op *FormPgOperator)) bool {
+	operators.Lock()
+	defer operators.Unlock()
+	op, ok := operators.byOid[oproid]
+	if !ok {
+		return false
+	}
+	update(op)
+	return true
+}
+
+// DropOperator は演算子を削除する (RemoveOperatorById 相当)。他の演算子の交換演算子と否定演算子が
+// この演算子を指していれば、その指定を消す。
+func DropOperator(oproid Oid) {
+	operators.Lock()
+	defer operators.Unlock()
+	delete(operators.byOid, oproid)
+	for _, op := range operators.byOid {
+		if op.Oprcom == oproid {
+			op.Oprcom = InvalidOid
+		}
+		if op.Oprnegate == oproid {
+			op.Oprnegate = InvalidOid
+		}
+	}
+}
+
+// OperatorsOnType は被演算子か結果の型が typid の演算子を OID の順に返す。型を削除するときに、
+// 依存する演算子を探すために使う。
+func OperatorsOnType(typid Oid) []FormPgOperator {
+	return listOperators(func(op *FormPgOperator) bool {
+		return op.Oprleft == typid || op.Oprright == typid || op.Oprresult == typid
+	})
+}
+
+// OperatorsOwnedBy はロールが所有する演算子を OID の順に返す
+func OperatorsOwnedBy(roleid Oid) []FormPgOperator {
+	return listOperators(func(op *FormPgOperator) bool { return op.Oprowner == roleid })
+}
+
+func listOperators(match func(op *FormPgOperator) bool) []FormPgOperator {
+	operators.RLock()
+	defer operators.RUnlock()
+	var out []FormPgOperator
+	for _, op := range operators.byOid {
+		if match(op) {
+			out = append(out, *op)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Oid < out[j].Oid })
+	return out
+}
+
+// FormatOperator は演算子を name(lefttype,righttype) の形で返す (format_operator 相当)。
+// 前置演算子の左の型は NONE と書く。
+func FormatOperator(op FormPgOperator) string {
+	left := "NONE"
+	if op.Oprleft != InvalidOid {
+		left = FormatType(op.Oprleft)
+	}
+	return op.Oprname + "(" + left + "," + FormatType(op.Oprright) + ")"
+}
@@ -0,0 +1,362 @@
+package catalog
+
+import (
+	"sort"
+	"sync"
+)
+
+// ----------------------------------------------------------------
+// 演算子族と演算子クラス (pg_opfamily, pg_opclass, pg_amop, pg_amproc 相当)
+// ----------------------------------------------------------------
+// 演算子族はインデックスのアクセスメソッドが互いに比べられるとみなす演算子とサポート関数の集まりで、
+// 演算子クラスは演算子族のうち、1つの型の列にインデックスを作るときに使うものを選ぶ。
+// 演算子族の演算子 (pg_amop) はストラテジ番号で、サポート関数 (pg_amproc) はサポート関数の
+// 番号で役割を表す。どちらも左右の型の組ごとに持つ。
+//
+// 組み込みの行は pg_opfamily.dat, pg_opclass.dat, pg_amop.dat, pg_amproc.dat から genbki で
+// *_d.go に生成する。CREATE OPERATOR CLASS などで作る行は、ドメインと同じくサーバーのメモリ上に
+// だけ持つ。組み込みの行の OID は FirstNormalObjectID より小さく、削除できない。
+
+// 演算子族の演算子の用途 (AMOP_* 相当)
+const (
+	// AmopSearch は WHERE 句の条件でインデックスを検索する演算子
+	AmopSearch byte = 's'
+	// AmopOrder は ORDER BY で順序を決める演算子
+	AmopOrder byte = 'o'
+)
+
+// FormPgOpfamily は演算子族1つ分 (FormData_pg_opfamily 相当)
+type FormPgOpfamily struct {
+	Oid       Oid
+	Opfmethod Oid
+	Opfname   string
+	Opfowner  Oid
+}
+
+// FormPgOpclass は演算子クラス1つ分 (FormData_pg_opclass 相当)
+type FormPgOpclass struct {
+	Oid       Oid
+	Opcmethod Oid
+	Opcname   string
+	Opcowner  Oid
+	Opcfamily Oid
+	// Opcintype はインデックスを作れる列の型
+	Opcintype Oid
+	// Opcdefault は Opcintype の列の既定の演算子クラスであることを表す
+	Opcdefault bool
+	// Opckeytype はインデックスに格納する型。列の型と同じであれば InvalidOid
+	Opckeytype Oid
+}
+
+// FormPgAmop は演算子族の演算子1つ分 (FormData_pg_amop 相当)
+type FormPgAmop struct {
+	Oid           Oid
+	Amopfamily    Oid
+	Amoplefttype  Oid
+	Amoprighttype Oid
+	Amopstrategy  int16
+	Amoppurpose   byte
+	Amopopr       Oid
+	Amopmethod    Oid
+	// Amopsortfamily は AmopOrder の演算子の結果を並べる btree の演算子族。AmopSearch では InvalidOid
+	Amopsortfamily Oid
+	// Opclass は CREATE OPERATOR CLASS で加えた行の演算子クラス (pg_depend の DEPENDENCY_INTERNAL 相当)。
+	// ALTER OPERATOR FAMILY で加えた行は InvalidOid
+	Opclass Oid
+}
+
+// FormPgAmproc は演算子族のサポート関数1つ分 (FormData_pg_amproc 相当)
+type FormPgAmproc struct {
+	Oid             Oid
+	Amprocfamily    Oid
+	Amproclefttype  Oid
+	Amprocrighttype Oid
+	Amprocnum       int16
+	Amproc          Oid
+	// Opclass は FormPgAmop.Opclass と同じ
+	Opclass Oid
+}
+
+// opfamilies は4つのカタログ。演算子族の削除で全てを変更するため、1つのロックで守る
+var opfamilies struct {
+	sync.RWMutex
+	families map[Oid]*FormPgOpfamily
+	classes  map[Oid]*FormPgOpclass
+	amops    map[Oid]*FormPgAmop
+	amprocs  map[Oid]*FormPgAmproc
+}
+
+// init は組み込みの行を4つのカタログに入れる
+func init() {
+	opfamilies.families = make(map[Oid]*FormPgOpfamily)
+	opfamilies.classes = make(map[Oid]*FormPgOpclass)
+	opfamilies.amops = make(map[Oid]*FormPgAmop)
+	opfamilies.amprocs = make(map[Oid]*FormPgAmproc)
+	for i := range builtinOpfamilies {
+		opfamilies.families[builtinOpfamilies[i].Oid] = &builtinOpfamilies[i]
+	}
+	for i := range builtinOpclasses {
+		opfamilies.classes[builtinOpclasses[i].Oid] = &builtinOpclasses[i]
+	}
+	for i := range builtinAmops {
+		opfamilies.amops[builtinAmops[i].Oid] = &builtinAmops[i]
+	}
+	for i := range builtinAmprocs {
+		opfamilies.amprocs[builtinAmprocs[i].Oid] = &builtinAmprocs[i]
+	}
+}
+
+// SearchOpfamily はアクセスメソッドと名前から演算子族を返す (SearchSysCache(OPFAMILYAMNAMENSP) 相当)
+func SearchOpfamily(amoid Oid, name string) (FormPgOpfamily, bool) {
+	opfamilies.RLock()
+	defer opfamilies.RUnlock()
+	for _, f := range opfamilies.families {
+		if f.Opfmethod == amoid && f.Opfname == name {
+			return *f, true
+		}
+	}
+	return FormPgOpfamily{}, false
+}
+
+// SearchOpfamilyByOid は OID から演算子族を返す (SearchSysCache(OPFAMILYOID) 相当)
+func SearchOpfamilyByOid(opfoid Oid) (FormPgOpfamily, bool) {
+	opfamilies.RLock()
+	defer opfamilies.RUnlock()
+	if f, ok := opfamilies.families[opfoid]; ok {
+		return *f, true
+	}
+	return FormPgOpfamily{}, false
+}
+
+// CreateOpfamily は演算子族を追加し、割り当てた OID を返す (CreateOpFamily 相当)。同じアクセス
+// メソッドに同じ名前の演算子族が既にあれば追加せずに ok に false を返す。
+func CreateOpfamily(f FormPgOpfamily) (oid Oid, ok bool) {
+	if _, exists := SearchOpfamily(f.Opfmethod, f.Opfname); exists {
+		return InvalidOid, false
+	}
+	opfamilies.Lock()
+	defer opfamilies.Unlock()
+	f.Oid = GetNewObjectID()
+	opfamilies.families[f.Oid] = &f
+	return f.Oid, true
+}
+
+// UpdateOpfamily は演算子族を update で変更する。演算子族がなければ false を返す。
+func UpdateOpfamily(opfoid Oid, update func(f *FormPgOpfamily)) bool {
+	opfamilies.Lock()
+	defer opfamilies.Unlock()
+	f, ok := opfamilies.families[opfoid]
+	if !ok {
+		return false
+	}
+	update(f)
+	return true
+}
+
+// DropOpfamily は演算子族と、その演算子とサポート関数を削除する (RemoveOpFamilyById 相当)。
+// 演算子族に属する演算子クラスは呼び出し側で先に削除する。
+func DropOpfamily(opfoid Oid) {
+	opfamilies.Lock()
+	defer opfamilies.Unlock()
+	delete(opfamilies.families, opfoid)
+	for oid, amop := range opfamilies.amops {
+		if amop.Amopfamily == opfoid {
+			delete(opfamilies.amops, oid)
+		}
+	}
+	for oid, amproc := range opfamilies.amprocs {
+		if amproc.Amprocfamily == opfoid {
+			delete(opfamilies.amprocs, oid)
+		}
+	}
+}
+
+// SearchOpclass はアクセスメソッドと名前から演算子クラスを返す (SearchSysCache(CLAAMNAMENSP) 相当)
+func SearchOpclass(amoid Oid, name string) (FormPgOpclass, bool) {
+	opfamilies.RLock()
+	defer opfamilies.RUnlock()
+	for _, c := range opfamilies.classes {
+		if c.Opcmethod == amoid && c.Opcname == name {
+			return *c, true
+		}
+	}
+	return FormPgOpclass{}, false
+}
+
+// SearchOpclassByOid は OID から演算子クラスを返す (SearchSysCache(CLAOID) 相当)
+func SearchOpclassByOid(opcoid Oid) (FormPgOpclass, bool) {
+	opfamilies.RLock()
+	defer opfamilies.RUnlock()
+	if c, ok := opfamilies.classes[opcoid]; ok {
+		return *c, true
+	}
+	return FormPgOpclass{}, false
+}
+
+// GetDefaultOpclass はアクセスメソッドで typid の列に使う既定の演算子クラスを返す
+// (GetDefaultOpClass の一部相当)
+func GetDefaultOpclass(amoid, typid Oid) (FormPgOpclass, bool) {
+	opfamilies.RLock()
+	defer opfamilies.RUnlock()
+	for _, c := range opfamilies.classes {
+		if c.Opcmethod == amoid && c.Opcintype == typid && c.Opcdefault {
+			return *c, true
+		}
+	}
+	return FormPgOpclass{}, false
+}
+
+// CreateOpclass は演算子クラスを追加し、割り当てた OID を返す。同じアクセスメソッドに同じ名前の
+// 演算子クラスが既にあれば追加せずに ok に false を返す。
+func CreateOpclass(c FormPgOpclass) (oid Oid, ok bool) {
+	if _, exists := SearchOpclass(c.Opcmethod, c.Opcname); exists {
+		return InvalidOid, false
+	}
+	opfamilies.Lock()
+	defer opfamilies.Unlock()
+	c.Oid = GetNewObjectID()
+	opfamilies.classes[c.Oid] = &c
+	return c.Oid, true
+}
+
+// UpdateOpclass は演算子クラスを update で変更する。演算子クラスがなければ false を返す。
+func UpdateOpclass(opcoid Oid, update func(c *FormPgOpclass)) bool {
+	opfamilies.Lock()
+	defer opfamilies.Unlock()
+	c, ok := opfamilies.classes[opcoid]
+	if !ok {
+		return false
+	}
+	update(c)
+	return true
+}
+
+// DropOpclass は演算子クラスと、その定義で演算子族に加えた演算子とサポート関数を削除する
+// (RemoveOpClassById 相当)。ALTER OPERATOR FAMILY で加えたものは演算子族に残す。
+func DropOpclass(opcoid Oid) {
+	opfamilies.Lock()
+	defer opfamilies.Unlock()
+	delete(opfamilies.classes, opcoid)
+	for oid, amop := range opfamilies.amops {
+		if amop.Opclass == opcoid {
+			delete(opfamilies.amops, oid)
+		}
+	}
+	for oid, amproc := range opfamilies.amprocs {
+		if amproc.Opclass == opcoid {
+			delete(opfamilies.amprocs, oid)
+		}
+	}
+}
+
+// OpclassesInFamily は演算子族に属する演算子クラスを OID の順に返す
+func OpclassesInFamily(opfoid Oid) []FormPgOpclass {
+	return listOpclasses(func(c *FormPgOpclass) bool { return c.Opcfamily == opfoid })
+}
+
+// OpclassesOwnedBy はロールが所有する演算子クラスを OID の順に返す
+func OpclassesOwnedBy(roleid Oid) []FormPgOpclass {
+	return listOpclasses(func(c *FormPgOpclass) bool { return c.Opcowner == roleid })
+}
+
+func listOpclasses(match func(c *FormPgOpclass) bool) []FormPgOpclass {
+	opfamilies.RLock()
+	defer opfamilies.RUnlock()
+	var out []FormPgOpclass
+	for _, c := range opfamilies.classes {
+		if match(c) {
+			out = append(out, *c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Oid < out[j].Oid })
+	return out
+}
+
+// OpfamiliesOwnedBy はロールが所有する演算子族を OID の順に返す
+func OpfamiliesOwnedBy(roleid Oid) []FormPgOpfamily {
+	opfamilies.RLock()
+	defer opfamilies.RUnlock()
+	var out []FormPgOpfamily
+	for _, f := range opfamilies.families {
+		if f.Opfowner == roleid {
+			out = append(out, *f)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Oid < out[j].Oid })
+	return out
+}
+
+// SearchAmop は演算子族の、左右の型とストラテジ番号が一致する演算子を返す
+// (SearchSysCache(AMOPSTRATEGY) 相当)
+func SearchAmop(opfoid, lefttype, righttype Oid, strategy int16) (FormPgAmop, bool) {
+	opfamilies.RLock()
+	defer opfamilies.RUnlock()
+	for _, amop := range opfamilies.amops {
+		if amop.Amopfamily == opfoid && amop.Amoplefttype == lefttype && amop.Amoprighttype == righttype &&
+			amop.Amopstrategy == strategy {
+			return *amop, true
+		}
+	}
+	return FormPgAmop{}, false
+}
+
+// AddAmop は演算子族に演算子を加え、割り当てた OID を返す (storeOperators の一部相当)
+func AddAmop(amop FormPgAmop) Oid {
+	opfamilies.Lock()
+	defer opfamilies.Unlock()
+	amop.Oid = GetNewObjectID()
+	opfamilies.amops[amop.Oid] = &amop
+	return amop.Oid
+}
+
+// DropAmop は演算子族から演算子を除く (RemoveAmOpEntryById 相当)
+func DropAmop(amopoid Oid) {
+	opfamilies.Lock()
+	defer opfamilies.Unlock()
+	delete(opfamilies.amops, amopoid)
+}
+
+// AmopsUsingOperator は演算子を使う演算子族の演算子を OID の順に返す。演算子を削除するときに、
+// 依存する演算子族の演算子を探すために使う。
+func AmopsUsingOperator(oproid Oid) []FormPgAmop {
+	opfamilies.RLock()
+	defer opfamilies.RUnlock()
+	var out []FormPgAmop
+	for _, amop := range opfamilies.amops {
+		if amop.Amopopr == oproid {
+			out = append(out, *amop)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Oid < out[j].Oid })
+	return out
+}
+
+// SearchAmproc は演算子族の、左右の型と番号が一致するサポート関数を返す
+// (SearchSysCache(AMPROCNUM) 相当)
+func SearchAmproc(opfoid, lefttype, righttype Oid, procnum int16) (FormPgAmproc, bool) {
+	opfamilies.RLock()
+	defer opfamilies.RUnlock()
+	for _, amproc := range opfamilies.amprocs {
+		if amproc.Amprocfamily == opfoid && amproc.Amproclefttype == lefttype && amproc.Amprocrighttype == righttype &&
+			amproc.Amprocnum == procnum {
+			return *amproc, true
+		}
+	}
+	return FormPgAmproc{}, false
+}
+
+// AddAmproc は演算子族にサポート関数を加え、割り当てた OID を返す (storeProcedures の一部相当)
+func AddAmproc(amproc FormPgAmproc) Oid {
+	opfamilies.Lock()
+	defer opfamilies.Unlock()
+	amproc.Oid = GetNewObjectID()
+	opfamilies.amprocs[amproc.Oid] = &amproc
+	return amproc.Oid
+}
+
+// DropAmproc は演算子族からサポート関数を除く (RemoveAmProcEntryById 相当)
+func DropAmproc(amprocoid Oid) {
+	opfamilies.Lock()
+	defer opfamilies.Unlock()
+	delete(opfamilies.amprocs, amprocoid)
+}
@@ -0,0 +1,67 @@
+package catalog
+
+// ----------------------------------------------------------------
+// 定義済みロール (pg_authid.dat, system_functions.sql の GRANT 相当)
+// ----------------------------------------------------------------
+// 特定の操作や情報の参照を、スーパーユーザーでないロールにも許すための組み込みのロール。
+// 権限を与えたいロールを、これらのロールのメンバーにして使う。
+// 定義済みロールの一覧 (PredefinedRoles) は pg_authid.dat から genbki で pg_authid_d.go に
+// 生成する。OID は PostgreSQL 本体と同じ値にする。
+
+const (
+	// RolePgDatabaseOwner は接続先のデータベースの所有者を暗黙のメンバーとするロール
+	RolePgDatabaseOwner = "pg_database_owner"
+	// RolePgReadAllData は全てのテーブルを読めるロール
+	RolePgReadAllData = "pg_read_all_data"
+	// RolePgWriteAllData は全てのテーブルに書き込めるロール
+	RolePgWriteAllData = "pg_write_all_data"
+	// RolePgMonitor は監視のための情報を参照できるロール。
+	// pg_read_all_settings、pg_read_all_stats、pg_stat_scan_tables の権限を持つ
+	RolePgMonitor = "pg_monitor"
+	// RolePgReadAllSettings はスーパーユーザー専用のものを含む全ての設定パラメータを参照できるロール
+	RolePgReadAllSettings = "pg_read_all_settings"
+	// RolePgReadAllStats は全てのセッションの統計情報 (実行中の問い合わせなど) を参照できるロール
+	RolePgReadAllStats = "pg_read_all_stats"
+	// RolePgStatScanTables はテーブルを長時間ロックしうる監視用の関数を実行できるロール
+	RolePgStatScanTables = "pg_stat_scan_tables"
+	// RolePgReadServerFiles はサーバー上のファイルを読めるロール
+	RolePgReadServerFiles = "pg_read_server_files"
+	// RolePgWriteServerFiles はサーバー上のファイルに書き込めるロール
+	RolePgWriteServerFiles = "pg_write_server_files"
+	// RolePgExecuteServerProgram はサーバー上でプログラムを実行できるロール
+	RolePgExecuteServerProgram = "pg_execute_server_program"
+	// RolePgSignalBackend はスーパーユーザー以外のバックエンドの問い合わせを取り消し、
+	// セッションを終了させられるロール
+	RolePgSignalBackend = "pg_signal_backend"
+	// RolePgCheckpoint は CHECKPOINT を実行できるロール
+	RolePgCheckpoint = "pg_checkpoint"
+	// RolePgMaintain は VACUUM、ANALYZE などの保守の操作を全てのテーブルに実行できるロール
+	RolePgMaintain = "pg_maintain"
+	// RolePgUseReservedConnections は reserved_connections で予約した接続を使えるロール
+	RolePgUseReservedConnections = "pg_use_reserved_connections"
+	// RolePgCreateSubscription は CREATE SUBSCRIPTION を実行できるロール
+	RolePgCreateSubscription = "pg_create_subscription"
+)
+
+// PredefinedRole は定義済みロール1つ分
+type PredefinedRole struct {
+	Oid     Oid
+	Rolname string
+}
+
+// predefinedRoleMembers は定義済みロールどうしのメンバーシップ
+var predefinedRoleMembers = []struct{ member, role string }{
+	{RolePgMonitor, RolePgReadAllSettings},
+	{RolePgMonitor, RolePgReadAllStats},
+	{RolePgMonitor, RolePgStatScanTables},
+}
+
+// IsPredefinedRole は rolname が定義済みロールかどうかを返す
+func IsPredefinedRole(rolname string) bool {
+	for _, r := range PredefinedRoles {
+		if r.Rolname == rolname {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package catalog
+
+import (
+	"slices"
+	"sync"
+)
+
+// ----------------------------------------------------------------
+// 組み込み関数 (pg_proc.dat 相当)
+// ----------------------------------------------------------------
+// 関数の名前、引数と結果の型、実装の名前 (prosrc) を持つ。実装そのものは
+// fmgr パッケージに prosrc の名前で登録する。関数の行 (builtinProcs) は pg_proc.dat から
+// genbki で pg_proc_d.go に生成する。OID は PostgreSQL 本体と同じ値にする。
+//
+// 組み込み関数はブートストラップスーパーユーザーが所有する。ALTER FUNCTION で変えられる属性
+// (SECURITY DEFINER と SET 句) は、生成した行を変えずに procAttrs に持ち、関数を引くときに重ねる。
+
+// FormPgProc は関数1つ分の定義 (FormData_pg_proc の一部相当)
+type FormPgProc struct {
+	Oid         Oid
+	Proname     string
+	Proargtypes []Oid
+	Prorettype  Oid
+	// Proisstrict が true の関数は、引数に NULL があれば呼び出さずに NULL を返す
+	Proisstrict bool
+	Prosrc      string
+	// Prosecdef が true の関数は、呼び出したユーザーでなく所有者の権限で実行する (SECURITY DEFINER)
+	Prosecdef bool
+	// Proconfig は関数を実行する間だけ設定するパラメータの "名前=値" の並び (SET 句)
+	Proconfig []string
+}
+
+// procAttrs は ALTER FUNCTION で変えた組み込み関数の属性
+var procAttrs struct {
+	sync.RWMutex
+	byOid map[Oid]FormPgProc
+}
+
+// withProcAttrs は ALTER FUNCTION で変えた属性を重ねた関数の定義を返す
+func withProcAttrs(p *FormPgProc) *FormPgProc {
+	procAttrs.RLock()
+	defer procAttrs.RUnlock()
+	if a, ok := procAttrs.byOid[p.Oid]; ok {
+		c := *p
+		c.Prosecdef, c.Proconfig = a.Prosecdef, a.Proconfig
+		return &c
+	}
+	return p
+}
+
+// UpdateProcAttrs は関数の SECURITY DEFINER と SET 句を update で書き換える (AlterFunction の
+// pg_proc の行の更新相当)。それ以外の列の変更は無視する。
+func UpdateProcAttrs(oid Oid, update func(p *FormPgProc)) {
+	proc, ok := SearchProc(oid)
+	if !ok {
+		return
+	}
+	c := *proc
+	c.Proconfig = slices.Clone(c.Proconfig)
+	update(&c)
+
+	procAttrs.Lock()
+	defer procAttrs.Unlock()
+	if !c.Prosecdef && len(c.Proconfig) == 0 {
+		delete(procAttrs.byOid, oid)
+		return
+	}
+	if procAttrs.byOid == nil {
+		procAttrs.byOid = make(map[Oid]FormPgProc)
+	}
+	procAttrs.byOid[oid] = FormPgProc{Prosecdef: c.Prosecdef, Proconfig: c.Proconfig}
+}
+
+// FuncnameGetCandidates は名前と引数の数が一致する関数を返す (FuncnameGetCandidates 相当)。
+// nargs が -1 の場合は引数の数を問わない。
+func FuncnameGetCandidates(name string, nargs int) []*FormPgProc {
+	var out []*FormPgProc
+	for i := range builtinProcs {
+		p := &builtinProcs[i]
+		if p.Proname == name && (nargs < 0 || len(p.Proargtypes) == nargs) {
+			out = append(out, withProcAttrs(p))
+		}
+	}
+	return out
+}
+
+// SearchProc は OID から関数の定義を返す (SearchSysCache(PROCOID) 相当)
+func SearchProc(oid Oid) (*FormPgProc, bool) {
+	for i := range builtinProcs {
+		if builtinProcs[i].Oid == oid {
+			return withProcAttrs(&builtinProcs[i]), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,272 @@
+package catalog
+
+import (
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Oid はオブジェクト識別子 (postgres_ext.h の Oid 相当)
+type Oid uint32
+
+const InvalidOid Oid = 0
+
+// ----------------------------------------------------------------
+// 組み込み型 (pg_type 相当)
+// ----------------------------------------------------------------
+// 組み込み型の OID の定数 (BOOLOID など) と行 (builtinTypes) は、pg_type.dat から
+// genbki で pg_type_d.go に生成する。クライアントドライバはこれらの既知の OID で
+// 型を判別するため、OID は PostgreSQL 本体と同じ値を pg_type.dat に書き、変えてはならない。
+//
+// CREATE DOMAIN で作るドメインは、ロールと同じくサーバーのメモリ上にだけ持つ。ドメインの
+// CHECK 制約は pg_constraint に持つ。
+
+//go:generate go run ./genbki
+
+// FormPgType は型1つ分の定義 (FormData_pg_type の一部相当)
+type FormPgType struct {
+	Oid     Oid
+	Typname string
+	// Typlen は内部表現の長さ。可変長の場合は -1、NUL 終端の文字列の場合は -2
+	Typlen int16
+	// Typdelim は配列のテキスト表現で要素を区切る文字
+	Typdelim byte
+	// Typelem は配列型の要素の型。配列型でなければ InvalidOid
+	Typelem Oid
+	// Typarray はこの型を要素とする配列型。なければ InvalidOid
+	Typarray Oid
+
+	// 以下はドメインだけが持つ。組み込み型では全て 0 になる
+
+	// Typowner はドメインの所有者
+	Typowner Oid
+	// Typacl はドメインのアクセス権限。nil なら既定の権限 (acldefault) を持つ
+	Typacl Acl
+	// Typbasetype はドメインの基になる型 (別のドメインのこともある)。ドメインでなければ InvalidOid
+	Typbasetype Oid
+	// Typnotnull はドメインが NULL を許さないことを表す
+	Typnotnull bool
+	// Typdefaultbin はドメインの既定値の式 (parser.Expr)。なければ nil。カタログはメモリ上にしか
+	// ないため、C言語版のように nodeToString の文字列にせず、解析済みの式をそのまま持つ
+	Typdefaultbin any
+}
+
+// typeByOid と typeByName は OID と型名から組み込み型の定義を引く表 (TYPEOID, TYPENAMENSP の syscache 相当)
+var typeByOid, typeByName = func() (map[Oid]*FormPgType, map[string]*FormPgType) {
+	byOid := make(map[Oid]*FormPgType, len(builtinTypes))
+	byName := make(map[string]*FormPgType, len(builtinTypes))
+	for i := range builtinTypes {
+		t := &builtinTypes[i]
+		byOid[t.Oid] = t
+		byName[t.Typname] = t
+	}
+	return byOid, byName
+}()
+
+// domainTypes は CREATE DOMAIN で作った型。組み込み型の表は変わらないため、こちらだけをロックで守る
+var domainTypes struct {
+	sync.RWMutex
+	byOid map[Oid]*FormPgType
+}
+
+// lookupType は OID から型の定義を返す。ドメインの定義は写しを返す
+func lookupType(typid Oid) (*FormPgType, bool) {
+	if t, ok := typeByOid[typid]; ok {
+		return t, true
+	}
+	domainTypes.RLock()
+	defer domainTypes.RUnlock()
+	if t, ok := domainTypes.byOid[typid]; ok {
+		c := *t
+		return &c, true
+	}
+	return nil, false
+}
+
+// SearchType は OID から型の定義を返す (SearchSysCache(TYPEOID) 相当)
+func SearchType(typid Oid) (*FormPgType, bool) {
+	return lookupType(typid)
+}
+
+// GetArrayType は要素の型に対応する配列型を返す (get_array_type 相当)。なければ InvalidOid。
+func GetArrayType(typid Oid) Oid {
+	if t, ok := lookupType(typid); ok {
+		return t.Typarray
+	}
+	return InvalidOid
+}
+
+// GetElementType は配列型の要素の型を返す (get_element_type 相当)。配列型でなければ InvalidOid。
+func GetElementType(typid Oid) Oid {
+	if t, ok := lookupType(typid); ok {
+		return t.Typelem
+	}
+	return InvalidOid
+}
+
+// TypeDelim は配列のテキスト表現で要素を区切る文字を返す (pg_type.typdelim 相当)
+func TypeDelim(typid Oid) byte {
+	if t, ok := lookupType(typid); ok {
+		return t.Typdelim
+	}
+	return ','
+}
+
+// TypeLen は型の内部表現の長さを返す (pg_type.typlen 相当)。可変長の場合は -1。
+func TypeLen(typid Oid) int16 {
+	if t, ok := lookupType(typid); ok {
+		return t.Typlen
+	}
+	return -1
+}
+
+// TypeIsDomain は型がドメインかどうかを返す (pg_type.typtype = TYPTYPE_DOMAIN 相当)
+func TypeIsDomain(typid Oid) bool {
+	t, ok := lookupType(typid)
+	return ok && t.Typbasetype != InvalidOid
+}
+
+// GetBaseType はドメインを基の型までたどり、ドメインでない型を返す (getBaseType 相当)。
+// ドメインでなければ typid をそのまま返す。
+func GetBaseType(typid Oid) Oid {
+	for {
+		t, ok := lookupType(typid)
+		if !ok || t.Typbasetype == InvalidOid {
+			return typid
+		}
+		typid = t.Typbasetype
+	}
+}
+
+// CreateDomainType はドメインを追加し、割り当てた OID を返す (TypeCreate 相当)。
+// 同じ名前の型が既にあれば追加せずに ok に false を返す。
+func CreateDomainType(typ FormPgType) (oid Oid, ok bool) {
+	domainTypes.Lock()
+	defer domainTypes.Unlock()
+	if _, exists := typenameTypeIDLocked(typ.Typname); exists {
+		return InvalidOid, false
+	}
+	if domainTypes.byOid == nil {
+		domainTypes.byOid = make(map[Oid]*FormPgType)
+	}
+	typ.Oid = GetNewObjectID()
+	domainTypes.byOid[typ.Oid] = &typ
+	return typ.Oid, true
+}
+
+// UpdateDomainType はドメインを update で変更する (CatalogTupleUpdate 相当)。ドメインがなければ false を返す。
+func UpdateDomainType(typid Oid, update func(typ *FormPgType)) bool {
+	domainTypes.Lock()
+	defer domainTypes.Unlock()
+	t, ok := domainTypes.byOid[typid]
+	if !ok {
+		return false
+	}
+	update(t)
+	return true
+}
+
+// RenameDomainType はドメインの名前を変える (RenameTypeInternal 相当)。同じ名前の型が既にあれば
+// 変えずに false を返す。
+func RenameDomainType(typid Oid, newname string) bool {
+	domainTypes.Lock()
+	defer domainTypes.Unlock()
+	if _, exists := typenameTypeIDLocked(newname); exists {
+		return false
+	}
+	if t, ok := domainTypes.byOid[typid]; ok {
+		t.Typname = newname
+	}
+	return true
+}
+
+// DropDomainType はドメインと、その制約を削除する (RemoveTypeById 相当)
+func DropDomainType(typid Oid) {
+	domainTypes.Lock()
+	delete(domainTypes.byOid, typid)
+	domainTypes.Unlock()
+	dropTypeConstraints(typid)
+}
+
+// DomainsOnType は typid を基にしたドメインを OID の順に返す (pg_depend を typid で引く処理相当)
+func DomainsOnType(typid Oid) []FormPgType {
+	return listDomains(func(t *FormPgType) bool { return t.Typbasetype == typid })
+}
+
+// DomainsOwnedBy は roleid が所有するドメインを OID の順に返す (pg_shdepend を roleid で引く処理相当)
+func DomainsOwnedBy(roleid Oid) []FormPgType {
+	return listDomains(func(t *FormPgType) bool { return t.Typowner == roleid })
+}
+
+// DomainsGrantedTo は所有者でない roleid が ACL に現れるドメインを OID の順に返す
+// (pg_shdepend の SHARED_DEPENDENCY_ACL を roleid で引く処理相当)
+func DomainsGrantedTo(roleid Oid) []FormPgType {
+	return listDomains(func(t *FormPgType) bool {
+		return t.Typowner != roleid && slices.Contains(AclRoles(t.Typacl), roleid)
+	})
+}
+
+func listDomains(match func(t *FormPgType) bool) []FormPgType {
+	domainTypes.RLock()
+	defer domainTypes.RUnlock()
+	var out []FormPgType
+	for _, t := range domainTypes.byOid {
+		if match(t) {
+			out = append(out, *t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Oid < out[j].Oid })
+	return out
+}
+
+// formatTypeNames は format_type が型名の代わりに SQL 標準の名前で表示する型 (format_type_extended 相当)
+var formatTypeNames = map[Oid]string{
+	BOOLOID:        "boolean",
+	CHAROID:        "\"char\"",
+	INT8OID:        "bigint",
+	INT2OID:        "smallint",
+	INT4OID:        "integer",
+	FLOAT4OID:      "real",
+	FLOAT8OID:      "double precision",
+	BPCHAROID:      "character",
+	VARCHAROID:     "character varying",
+	TIMEOID:        "time without time zone",
+	TIMESTAMPOID:   "timestamp without time zone",
+	TIMESTAMPTZOID: "timestamp with time zone",
+}
+
+// TypenameTypeID は型名から型の OID を返す (typenameTypeId 相当)。
+// "_int4" のように先頭に "_" を付けた名前は配列型を表す。SQL 標準の別名 (integer など) は
+// 構文解析で型名に置き換えてから渡す。
+func TypenameTypeID(name string) (Oid, bool) {
+	domainTypes.RLock()
+	defer domainTypes.RUnlock()
+	return typenameTypeIDLocked(name)
+}
+
+// typenameTypeIDLocked は TypenameTypeID の本体。呼び出し側が domainTypes のロックを持つ
+func typenameTypeIDLocked(name string) (Oid, bool) {
+	if t, ok := typeByName[name]; ok {
+		return t.Oid, true
+	}
+	for _, t := range domainTypes.byOid {
+		if t.Typname == name {
+			return t.Oid, true
+		}
+	}
+	return InvalidOid, false
+}
+
+// FormatType は型の表示名を返す (format_type_be 相当)。配列型は要素の型名に "[]" を付ける。
+func FormatType(typid Oid) string {
+	if name, ok := formatTypeNames[typid]; ok {
+		return name
+	}
+	if elem := GetElementType(typid); elem != InvalidOid {
+		return FormatType(elem) + "[]"
+	}
+	if t, ok := lookupType(typid); ok {
+		return t.Typname
+	}
+	return "???"
+}
@@ -0,0 +1,87 @@
+package catalog
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+)
+
+// ----------------------------------------------------------------
+// カタログのファイル
+// ----------------------------------------------------------------
+// ブートストラップで作るカタログの行をファイルに読み書きする。ヒープのページはまだないため、
+// COPY のテキスト形式と同じく1行に1つのタプルを書き、列をタブで区切る。NULL は \N と書き、
+// 値の中のバックスラッシュ、タブ、改行はエスケープする。
+//
+// 値は各列の型の出力関数が返すテキスト表現で、読んだ側が入力関数で内部表現に戻す。
+
+// CatalogTuple はカタログの1行。列の番号 (Anum_*) から 1 を引いた位置に値を持ち、
+// nil は NULL を表す。
+type CatalogTuple []*string
+
+var (
+	copyEscaper   = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+	copyUnescaper = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r")
+)
+
+// WriteCatalogFile はタプルをファイルに書く。ファイルが既にあればエラーにする。
+func WriteCatalogFile(path string, tuples []CatalogTuple) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("could not create file \"%s\": %s", path, platform.OSErrorMessage(err))
+	}
+	w := bufio.NewWriter(f)
+	for _, tup := range tuples {
+		for i, v := range tup {
+			if i > 0 {
+				w.WriteByte('\t')
+			}
+			if v == nil {
+				w.WriteString(`\N`)
+			} else {
+				w.WriteString(copyEscaper.Replace(*v))
+			}
+		}
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write to file \"%s\": %s", path, platform.OSErrorMessage(err))
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not close file \"%s\": %s", path, platform.OSErrorMessage(err))
+	}
+	return nil
+}
+
+// ReadCatalogFile はファイルから全てのタプルを読む。natts はタプルの列の数で、
+// 列の数が異なる行があればエラーにする。
+func ReadCatalogFile(path string, natts int) ([]CatalogTuple, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file \"%s\": %s", path, platform.OSErrorMessage(err))
+	}
+	var tuples []CatalogTuple
+	for i, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if line == "" && len(data) == 0 {
+			break
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != natts {
+			return nil, fmt.Errorf("invalid tuple at line %d of file \"%s\": expected %d columns, found %d", i+1, path, natts, len(fields))
+		}
+		tup := make(CatalogTuple, natts)
+		for j, field := range fields {
+			if field == `\N` {
+				continue
+			}
+			v := copyUnescaper.Replace(field)
+			tup[j] = &v
+		}
+		tuples = append(tuples, tup)
+	}
+	return tuples, nil
+}
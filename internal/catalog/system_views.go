@@ -0,0 +1,307 @@
+package catalog
+
+// ----------------------------------------------------------------
+// システムビュー (system_views.sql 相当)
+// ----------------------------------------------------------------
+// C言語版ではビューを SQL で定義し、その多くは集合を返す関数 (pg_stat_get_activity など) を
+// 呼び出す。Go言語版ではビューの定義を持てないため、ビューの列と、行を返す関数の
+// 実装の名前 (prosrc) を直接持つ。実装は fmgr パッケージに RegisterSetReturning で登録する。
+
+// SystemViewAttr はシステムビューの列1つ分 (FormData_pg_attribute の一部相当)
+type SystemViewAttr struct {
+	Name   string
+	TypeID Oid
+}
+
+// SystemView はシステムビュー1つ分の定義
+type SystemView struct {
+	Nspname string
+	Relname string
+	Attrs   []SystemViewAttr
+	// Prosrc は行を返す関数の実装の名前
+	Prosrc string
+}
+
+var systemViews = []SystemView{
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_settings",
+		Attrs: []SystemViewAttr{
+			{"name", TEXTOID},
+			{"setting", TEXTOID},
+			{"unit", TEXTOID},
+			{"category", TEXTOID},
+			{"short_desc", TEXTOID},
+			{"extra_desc", TEXTOID},
+			{"context", TEXTOID},
+			{"vartype", TEXTOID},
+			{"source", TEXTOID},
+			{"min_val", TEXTOID},
+			{"max_val", TEXTOID},
+			{"enumvals", TEXTARRAYOID},
+			{"boot_val", TEXTOID},
+			{"reset_val", TEXTOID},
+			{"sourcefile", TEXTOID},
+			{"sourceline", INT4OID},
+			{"pending_restart", BOOLOID},
+		},
+		Prosrc: "show_all_settings",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_stat_ssl",
+		Attrs: []SystemViewAttr{
+			{"pid", INT4OID},
+			{"ssl", BOOLOID},
+			{"version", TEXTOID},
+			{"cipher", TEXTOID},
+			{"bits", INT4OID},
+			{"client_dn", TEXTOID},
+			{"client_serial", NUMERICOID},
+			{"issuer_dn", TEXTOID},
+		},
+		Prosrc: "pg_stat_get_ssl",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_stat_activity",
+		// inet 型はまだないため、client_addr は text で返す
+		Attrs: []SystemViewAttr{
+			{"datid", OIDOID},
+			{"datname", NAMEOID},
+			{"pid", INT4OID},
+			{"leader_pid", INT4OID},
+			{"usesysid", OIDOID},
+			{"usename", NAMEOID},
+			{"application_name", TEXTOID},
+			{"client_addr", TEXTOID},
+			{"client_hostname", TEXTOID},
+			{"client_port", INT4OID},
+			{"backend_start", TIMESTAMPTZOID},
+			{"xact_start", TIMESTAMPTZOID},
+			{"query_start", TIMESTAMPTZOID},
+			{"state_change", TIMESTAMPTZOID},
+			{"wait_event_type", TEXTOID},
+			{"wait_event", TEXTOID},
+			{"state", TEXTOID},
+			{"backend_xid", XIDOID},
+			{"backend_xmin", XIDOID},
+			{"query_id", INT8OID},
+			{"query", TEXTOID},
+			{"backend_type", TEXTOID},
+		},
+		Prosrc: "pg_stat_get_activity",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_stat_user_tables",
+		Attrs: []SystemViewAttr{
+			{"relid", OIDOID},
+			{"schemaname", NAMEOID},
+			{"relname", NAMEOID},
+			{"seq_scan", INT8OID},
+			{"last_seq_scan", TIMESTAMPTZOID},
+			{"seq_tup_read", INT8OID},
+			{"idx_scan", INT8OID},
+			{"last_idx_scan", TIMESTAMPTZOID},
+			{"idx_tup_fetch", INT8OID},
+			{"n_tup_ins", INT8OID},
+			{"n_tup_upd", INT8OID},
+			{"n_tup_del", INT8OID},
+			{"n_tup_hot_upd", INT8OID},
+			{"n_tup_newpage_upd", INT8OID},
+			{"n_live_tup", INT8OID},
+			{"n_dead_tup", INT8OID},
+			{"n_mod_since_analyze", INT8OID},
+			{"n_ins_since_vacuum", INT8OID},
+			{"last_vacuum", TIMESTAMPTZOID},
+			{"last_autovacuum", TIMESTAMPTZOID},
+			{"last_analyze", TIMESTAMPTZOID},
+			{"last_autoanalyze", TIMESTAMPTZOID},
+			{"vacuum_count", INT8OID},
+			{"autovacuum_count", INT8OID},
+			{"analyze_count", INT8OID},
+			{"autoanalyze_count", INT8OID},
+		},
+		Prosrc: "pg_stat_get_user_tables",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_stat_user_indexes",
+		Attrs: []SystemViewAttr{
+			{"relid", OIDOID},
+			{"indexrelid", OIDOID},
+			{"schemaname", NAMEOID},
+			{"relname", NAMEOID},
+			{"indexrelname", NAMEOID},
+			{"idx_scan", INT8OID},
+			{"last_idx_scan", TIMESTAMPTZOID},
+			{"idx_tup_read", INT8OID},
+			{"idx_tup_fetch", INT8OID},
+		},
+		Prosrc: "pg_stat_get_user_indexes",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_stat_user_functions",
+		Attrs: []SystemViewAttr{
+			{"funcid", OIDOID},
+			{"schemaname", NAMEOID},
+			{"funcname", NAMEOID},
+			{"calls", INT8OID},
+			{"total_time", FLOAT8OID},
+			{"self_time", FLOAT8OID},
+		},
+		Prosrc: "pg_stat_get_user_functions",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_stat_database",
+		Attrs: []SystemViewAttr{
+			{"datid", OIDOID},
+			{"datname", NAMEOID},
+			{"numbackends", INT4OID},
+			{"xact_commit", INT8OID},
+			{"xact_rollback", INT8OID},
+			{"temp_files", INT8OID},
+			{"temp_bytes", INT8OID},
+			{"blk_read_time", FLOAT8OID},
+			{"blk_write_time", FLOAT8OID},
+			{"stats_reset", TIMESTAMPTZOID},
+		},
+		Prosrc: "pg_stat_get_database",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_stat_archiver",
+		Attrs: []SystemViewAttr{
+			{"archived_count", INT8OID},
+			{"last_archived_wal", TEXTOID},
+			{"last_archived_time", TIMESTAMPTZOID},
+			{"failed_count", INT8OID},
+			{"last_failed_wal", TEXTOID},
+			{"last_failed_time", TIMESTAMPTZOID},
+			{"stats_reset", TIMESTAMPTZOID},
+		},
+		Prosrc: "pg_stat_get_archiver",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_stat_bgwriter",
+		Attrs: []SystemViewAttr{
+			{"buffers_clean", INT8OID},
+			{"maxwritten_clean", INT8OID},
+			{"buffers_alloc", INT8OID},
+			{"stats_reset", TIMESTAMPTZOID},
+		},
+		Prosrc: "pg_stat_get_bgwriter",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_stat_checkpointer",
+		Attrs: []SystemViewAttr{
+			{"num_timed", INT8OID},
+			{"num_requested", INT8OID},
+			{"restartpoints_timed", INT8OID},
+			{"restartpoints_req", INT8OID},
+			{"restartpoints_done", INT8OID},
+			{"write_time", FLOAT8OID},
+			{"sync_time", FLOAT8OID},
+			{"buffers_written", INT8OID},
+			{"stats_reset", TIMESTAMPTZOID},
+		},
+		Prosrc: "pg_stat_get_checkpointer",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_prepared_xacts",
+		Attrs: []SystemViewAttr{
+			{"transaction", XIDOID},
+			{"gid", TEXTOID},
+			{"prepared", TIMESTAMPTZOID},
+			{"owner", NAMEOID},
+			{"database", NAMEOID},
+		},
+		Prosrc: "pg_prepared_xact",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_prepared_statements",
+		Attrs: []SystemViewAttr{
+			{"name", TEXTOID},
+			{"statement", TEXTOID},
+			{"prepare_time", TIMESTAMPTZOID},
+			{"parameter_types", REGTYPEARRAYOID},
+			{"result_types", REGTYPEARRAYOID},
+			{"from_sql", BOOLOID},
+			{"generic_plans", INT8OID},
+			{"custom_plans", INT8OID},
+		},
+		Prosrc: "pg_prepared_statement",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_cursors",
+		Attrs: []SystemViewAttr{
+			{"name", TEXTOID},
+			{"statement", TEXTOID},
+			{"is_holdable", BOOLOID},
+			{"is_binary", BOOLOID},
+			{"is_scrollable", BOOLOID},
+			{"creation_time", TIMESTAMPTZOID},
+		},
+		Prosrc: "pg_cursor",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_shmem_allocations",
+		Attrs: []SystemViewAttr{
+			{"name", TEXTOID},
+			{"off", INT8OID},
+			{"size", INT8OID},
+			{"allocated_size", INT8OID},
+		},
+		Prosrc: "pg_get_shmem_allocations",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_buffercache",
+		Attrs: []SystemViewAttr{
+			{"bufferid", INT4OID},
+			{"relfilenode", OIDOID},
+			{"reltablespace", OIDOID},
+			{"reldatabase", OIDOID},
+			{"relforknumber", INT2OID},
+			{"relblocknumber", INT8OID},
+			{"isdirty", BOOLOID},
+			{"usagecount", INT2OID},
+			{"pinning_backends", INT4OID},
+		},
+		Prosrc: "pg_buffercache_pages",
+	},
+	{
+		Nspname: "pg_catalog",
+		Relname: "pg_default_acl",
+		// aclitem 型はまだないため、defaclacl は text[] で返す
+		Attrs: []SystemViewAttr{
+			{"oid", OIDOID},
+			{"defaclrole", OIDOID},
+			{"defaclnamespace", OIDOID},
+			{"defaclobjtype", CHAROID},
+			{"defaclacl", TEXTARRAYOID},
+		},
+		Prosrc: "pg_get_default_acl",
+	},
+}
+
+// RelnameGetSystemView はスキーマ名とビューの名前からシステムビューを探す
+// (RangeVarGetRelid 相当)。nspname が空の場合は pg_catalog から探す。
+func RelnameGetSystemView(nspname, relname string) (*SystemView, bool) {
+	for i := range systemViews {
+		v := &systemViews[i]
+		if v.Relname == relname && (nspname == "" || v.Nspname == nspname) {
+			return v, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// ----------------------------------------------------------------
+// コストに基づく VACUUM の遅延 (commands/vacuum.c の vacuum_delay_point、VacuumUpdateCosts 相当)
+// ----------------------------------------------------------------
+// VACUUM はページを扱うたびにコストを数え、vacuum_cost_limit に達したら vacuum_cost_delay だけ
+// 休む。共有バッファで見つかったページ、見つからずに読み込んだページ、VACUUM が変更したページの
+// コストは、それぞれ vacuum_cost_page_hit、vacuum_cost_page_miss、vacuum_cost_page_dirty である。
+// vacuum_cost_delay が 0 ならコストを数えない。
+//
+// autovacuum のワーカーは autovacuum_vacuum_cost_delay と autovacuum_vacuum_cost_limit を使い、
+// 同時に動いているワーカーで上限を分け合う (CostBalancer)。ワーカーの数が増えても、
+// autovacuum 全体の I/O の量は1つのワーカーの場合と変わらない。
+//
+// C言語版ではプロセスごとの大域変数だが、バックエンドはゴルーチンのため、VACUUM を実行する
+// バックエンドやワーカーがそれぞれ VacuumCost を持つ。
+
+// CostBalancer は autovacuum のワーカーが使う遅延と上限を決める
+// (autovacuum.c の VacuumUpdateCosts、AutoVacuumUpdateCostLimit の処理相当)
+type CostBalancer interface {
+	// CostDelay は vacuum_cost_delay として使う値を返す
+	CostDelay() float64
+	// CostLimit は vacuum_cost_limit として使う値を返す。他のワーカーと分け合った後の値である
+	CostLimit() int
+}
+
+// VacuumCost は VACUUM 1つ分のコストの状態
+type VacuumCost struct {
+	// Active はコストを数えているかどうか (VacuumCostActive 相当)
+	Active bool
+	// Balance は前回休んでから数えたコスト (VacuumCostBalance 相当)
+	Balance int
+	// Delay と Limit は実際に使う遅延 (ミリ秒) と上限 (vacuum_cost_delay、vacuum_cost_limit 相当)
+	Delay float64
+	Limit int
+	// FailsafeActive は周回の危険があり、遅延をやめたことを表す (VacuumFailsafeActive 相当)
+	FailsafeActive bool
+
+	pageHit, pageMiss, pageDirty int
+
+	// gucs は手動の VACUUM を実行するセッションの設定。nil ならサーバー全体の値を使う
+	gucs *guc.Session
+	// balancer は autovacuum のワーカーの場合に、遅延と上限を決める
+	balancer   CostBalancer
+	interrupts *miscadmin.Interrupts
+}
+
+// NewVacuumCost は VACUUM のコストの状態を作り、設定の値を読む。手動の VACUUM では gucs に
+// セッションの設定を渡し、autovacuum のワーカーでは balancer を渡す。
+func NewVacuumCost(gucs *guc.Session, balancer CostBalancer, interrupts *miscadmin.Interrupts) *VacuumCost {
+	c := &VacuumCost{gucs: gucs, balancer: balancer, interrupts: interrupts}
+	c.UpdateCosts()
+	return c
+}
+
+func (c *VacuumCost) getInt(v *guc.ConfigInt) int {
+	if c.gucs != nil {
+		return c.gucs.GetInt(v)
+	}
+	return v.Get()
+}
+
+func (c *VacuumCost) getReal(v *guc.ConfigReal) float64 {
+	if c.gucs != nil {
+		return c.gucs.GetReal(v)
+	}
+	return v.Get()
+}
+
+// UpdateCosts は設定の値を読み直し、コストを数えるかどうかを決める (VacuumUpdateCosts 相当)
+func (c *VacuumCost) UpdateCosts() {
+	if c.balancer != nil {
+		c.Delay = c.balancer.CostDelay()
+		c.Limit = c.balancer.CostLimit()
+	} else {
+		c.Delay = c.getReal(guc.VacuumCostDelay)
+		c.Limit = c.getInt(guc.VacuumCostLimit)
+	}
+	c.pageHit = c.getInt(guc.VacuumCostPageHit)
+	c.pageMiss = c.getInt(guc.VacuumCostPageMiss)
+	c.pageDirty = c.getInt(guc.VacuumCostPageDirty)
+
+	if c.Delay > 0 && !c.FailsafeActive {
+		c.Active = true
+	} else if c.Active {
+		c.Active = false
+		c.Balance = 0
+	}
+}
+
+// PageHit、PageMiss、PageDirty はページ1つ分のコストを加える (ReadBuffer と MarkBufferDirty の
+// VacuumCostBalance の加算相当)
+func (c *VacuumCost) PageHit() {
+	if c.Active {
+		c.Balance += c.pageHit
+	}
+}
+
+func (c *VacuumCost) PageMiss() {
+	if c.Active {
+		c.Balance += c.pageMiss
+	}
+}
+
+func (c *VacuumCost) PageDirty() {
+	if c.Active {
+		c.Balance += c.pageDirty
+	}
+}
+
+// DelayPoint は割り込みを確かめ、コストが上限に達していれば休む (vacuum_delay_point 相当)。
+// 休む時間はコストに比例させ、vacuum_cost_delay の4倍を超えないようにする。
+func (c *VacuumCost) DelayPoint() error {
+	if err := c.interrupts.CheckForInterrupts(); err != nil {
+		return err
+	}
+	// autovacuum のワーカーでは、設定ファイルの再読み込みと他のワーカーの増減を反映する
+	if c.balancer != nil {
+		c.UpdateCosts()
+	}
+	if !c.Active || c.Balance < c.Limit {
+		return nil
+	}
+
+	msec := c.Delay * float64(c.Balance) / float64(c.Limit)
+	msec = min(msec, c.Delay*4)
+	sim.Sleep(time.Duration(msec * float64(time.Millisecond)))
+	c.Balance = 0
+
+	// 休んでいる間に他のワーカーが増減していれば、上限を配分し直す (AutoVacuumUpdateCostLimit 相当)
+	if c.balancer != nil {
+		c.Limit = c.balancer.CostLimit()
+	}
+	return c.interrupts.CheckForInterrupts()
+}
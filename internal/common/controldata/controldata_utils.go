@@ -0,0 +1,88 @@
+package controldata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+)
+
+// ----------------------------------------------------------------
+// 制御ファイルの読み書き (common/controldata_utils.c 相当)
+// ----------------------------------------------------------------
+// サーバーと initdb などのクライアントプログラムの両方が使う。内容は catalog.ControlFileData の
+// 項目の順にリトルエンディアンで並べ、最後の Crc にそれより前の CRC-32C を書く。
+
+// XlogControlFile はデータディレクトリからの制御ファイルの相対パス (XLOG_CONTROL_FILE 相当)
+const XlogControlFile = "global/pg_control"
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// GetControlFile は制御ファイルを読む (get_controlfile 相当)。内容が壊れていて CRC が
+// 一致しない場合も、読んだ内容を返し crcOK に false を返す。
+func GetControlFile(dataDir string) (cf *catalog.ControlFileData, crcOK bool, err error) {
+	path := filepath.Join(dataDir, XlogControlFile)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not open file \"%s\" for reading: %s", path, platform.OSErrorMessage(err))
+	}
+	defer f.Close()
+
+	size := binary.Size(catalog.ControlFileData{})
+	buf := make([]byte, size)
+	if n, err := io.ReadFull(f, buf); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, false, fmt.Errorf("could not read file \"%s\": read %d of %d", path, n, size)
+		}
+		return nil, false, fmt.Errorf("could not read file \"%s\": %s", path, platform.OSErrorMessage(err))
+	}
+
+	cf = new(catalog.ControlFileData)
+	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, cf); err != nil {
+		return nil, false, fmt.Errorf("could not read file \"%s\": %w", path, err)
+	}
+	return cf, crc32.Checksum(buf[:size-4], crc32cTable) == cf.Crc, nil
+}
+
+// UpdateControlFile は CRC を計算し直して制御ファイルを書く (update_controlfile 相当)。
+// ファイルがなければ作る。doSync が true なら書いた内容をディスクに同期する。
+func UpdateControlFile(dataDir string, cf *catalog.ControlFileData, doSync bool) error {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, cf); err != nil {
+		return err
+	}
+	b := buf.Bytes()
+	cf.Crc = crc32.Checksum(b[:len(b)-4], crc32cTable)
+	binary.LittleEndian.PutUint32(b[len(b)-4:], cf.Crc)
+
+	// 0 で埋めたファイル全体を書き、後で読むときにファイルの終わりで失敗しないようにする
+	page := make([]byte, catalog.PgControlFileSize)
+	copy(page, b)
+
+	path := filepath.Join(dataDir, XlogControlFile)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open file \"%s\": %s", path, platform.OSErrorMessage(err))
+	}
+	if _, err := f.Write(page); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write file \"%s\": %s", path, platform.OSErrorMessage(err))
+	}
+	if doSync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return fmt.Errorf("could not fsync file \"%s\": %s", path, platform.OSErrorMessage(err))
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not close file \"%s\": %s", path, platform.OSErrorMessage(err))
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package md5
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+)
+
+// ----------------------------------------------------------------
+// MD5 によるパスワードの暗号化 (common/md5_common.c 相当)
+// ----------------------------------------------------------------
+// 保存する形式は "md5" に続けて md5(パスワード + ユーザー名) の16進表記を並べたもの。
+// 認証では、サーバーが送る4バイトの salt を使って、クライアントが
+// "md5" + md5(保存する形式の16進表記の部分 + salt) を返す。
+
+// PasswdLen は暗号化したパスワードの長さ ("md5" と32文字の16進表記) (MD5_PASSWD_LEN 相当)
+const PasswdLen = 35
+
+// Encrypt は passwd を salt で暗号化し、"md5" で始まる文字列を返す (pg_md5_encrypt 相当)
+func Encrypt(passwd, salt string) string {
+	sum := md5.Sum([]byte(passwd + salt))
+	return "md5" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,31 @@
+package relpath
+
+import (
+	"fmt"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+)
+
+// ----------------------------------------------------------------
+// リレーションのファイルのパス (common/relpath.c 相当)
+// ----------------------------------------------------------------
+// リレーションのファイルは、データディレクトリからの相対パスで次の場所に置く。
+//
+//	global/<relfilenumber>          共有カタログ
+//	base/<データベースの OID>/<relfilenumber>  それ以外のリレーション
+//
+// テーブル空間はまだないため、pg_tblspc/ の下は使わない。
+
+// GetDatabasePath はデータベースのディレクトリのパスを返す (GetDatabasePath 相当)。
+// dbOid が InvalidOid の場合は共有カタログのディレクトリを返す。
+func GetDatabasePath(dbOid catalog.Oid) string {
+	if dbOid == catalog.InvalidOid {
+		return "global"
+	}
+	return fmt.Sprintf("base/%d", dbOid)
+}
+
+// GetRelationPath はリレーションのファイルのパスを返す (GetRelationPath 相当)
+func GetRelationPath(dbOid, relNumber catalog.Oid) string {
+	return fmt.Sprintf("%s/%d", GetDatabasePath(dbOid), relNumber)
+}
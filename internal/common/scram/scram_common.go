@@ -0,0 +1,144 @@
+package scram
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// SCRAM-SHA-256 の共通処理 (common/scram-common.c 相当)
+// ----------------------------------------------------------------
+// サーバーとクライアントの両方で使う鍵の導出と、サーバーに保存する秘密情報
+// (SCRAM secret) の組み立てと解析を行う。RFC 5802 と RFC 7677 を参照。
+//
+// 保存する秘密情報の形式は次の通り。
+//
+//	SCRAM-SHA-256$<iteration count>:<salt>$<StoredKey>:<ServerKey>
+//
+// salt、StoredKey、ServerKey は base64 で符号化する。
+
+const (
+	// MechanismSHA256 と MechanismSHA256Plus は SASL の認証機構の名前。
+	// -PLUS はチャネルバインディングを使う
+	MechanismSHA256     = "SCRAM-SHA-256"
+	MechanismSHA256Plus = "SCRAM-SHA-256-PLUS"
+
+	// KeyLen は鍵とハッシュの長さ (SCRAM_SHA_256_KEY_LEN 相当)
+	KeyLen = sha256.Size
+	// RawNonceLen は乱数から作るノンスのバイト数 (SCRAM_RAW_NONCE_LEN 相当)
+	RawNonceLen = 18
+	// DefaultSaltLen は新しい秘密情報の salt のバイト数 (SCRAM_DEFAULT_SALT_LEN 相当)
+	DefaultSaltLen = 16
+	// DefaultIterations は新しい秘密情報の反復回数 (SCRAM_SHA_256_DEFAULT_ITERATIONS 相当)
+	DefaultIterations = 4096
+
+	// ChannelBindingType はサポートするチャネルバインディングの種類
+	ChannelBindingType = "tls-server-end-point"
+)
+
+// SaltedPassword はパスワードから SaltedPassword を導出する (scram_SaltedPassword 相当)。
+// Hi() は PBKDF2 と同じ計算である。
+func SaltedPassword(password string, salt []byte, iterations int) []byte {
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, KeyLen)
+	if err != nil {
+		// 長さと反復回数が正しければ失敗しない
+		panic(err)
+	}
+	return key
+}
+
+// HMAC は HMAC-SHA-256 を計算する。msgs は連結してから計算する。
+func HMAC(key []byte, msgs ...string) []byte {
+	h := hmac.New(sha256.New, key)
+	for _, m := range msgs {
+		h.Write([]byte(m))
+	}
+	return h.Sum(nil)
+}
+
+// H は SHA-256 を計算する (scram_H 相当)
+func H(b []byte) []byte {
+	sum := sha256.Sum256(b)
+	return sum[:]
+}
+
+// ClientKey は SaltedPassword から ClientKey を導出する (scram_ClientKey 相当)
+func ClientKey(saltedPassword []byte) []byte {
+	return HMAC(saltedPassword, "Client Key")
+}
+
+// ServerKey は SaltedPassword から ServerKey を導出する (scram_ServerKey 相当)
+func ServerKey(saltedPassword []byte) []byte {
+	return HMAC(saltedPassword, "Server Key")
+}
+
+// SASLprep はパスワードを正規化する (pg_saslprep 相当)。
+// Unicode の正規化表がまだないため、パスワードをそのまま使う。C言語版でも、
+// 正しい UTF-8 でないパスワードや禁止された文字を含むパスワードはそのまま使うので、
+// ASCII の印字可能な文字だけからなるパスワードでは結果は同じになる。
+func SASLprep(password string) string {
+	return password
+}
+
+// BuildSecret はパスワードから保存用の秘密情報を作る (scram_build_secret 相当)
+func BuildSecret(password string, salt []byte, iterations int) string {
+	salted := SaltedPassword(SASLprep(password), salt, iterations)
+	storedKey := H(ClientKey(salted))
+	serverKey := ServerKey(salted)
+	enc := base64.StdEncoding
+	return fmt.Sprintf("SCRAM-SHA-256$%d:%s$%s:%s", iterations,
+		enc.EncodeToString(salt), enc.EncodeToString(storedKey), enc.EncodeToString(serverKey))
+}
+
+// Secret は解析した秘密情報
+type Secret struct {
+	Iterations int
+	Salt       []byte
+	StoredKey  []byte
+	ServerKey  []byte
+}
+
+// ParseSecret は保存用の秘密情報を解析する (parse_scram_secret 相当)。
+// 形式が正しくなければ ok に false を返す。
+func ParseSecret(secret string) (s Secret, ok bool) {
+	method, rest, found := strings.Cut(secret, "$")
+	if !found || method != "SCRAM-SHA-256" {
+		return Secret{}, false
+	}
+	iterSalt, keys, found := strings.Cut(rest, "$")
+	if !found {
+		return Secret{}, false
+	}
+	iterStr, saltStr, found := strings.Cut(iterSalt, ":")
+	if !found {
+		return Secret{}, false
+	}
+	storedStr, serverStr, found := strings.Cut(keys, ":")
+	if !found {
+		return Secret{}, false
+	}
+
+	iterations, err := strconv.Atoi(iterStr)
+	if err != nil || iterations <= 0 {
+		return Secret{}, false
+	}
+	enc := base64.StdEncoding
+	salt, err := enc.DecodeString(saltStr)
+	if err != nil {
+		return Secret{}, false
+	}
+	storedKey, err := enc.DecodeString(storedStr)
+	if err != nil || len(storedKey) != KeyLen {
+		return Secret{}, false
+	}
+	serverKey, err := enc.DecodeString(serverStr)
+	if err != nil || len(serverKey) != KeyLen {
+		return Secret{}, false
+	}
+	return Secret{Iterations: iterations, Salt: salt, StoredKey: storedKey, ServerKey: serverKey}, true
+}
@@ -0,0 +1,234 @@
+package hstore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// hstore 型 (contrib/hstore の hstore_io.c / hstore_op.c 相当)
+// ----------------------------------------------------------------
+// C言語版ではキーと値を1つの可変長バイト列に詰めて格納するが、
+// Go言語版では (キー, 値) の組を整列済みスライスとして保持する。
+// キーの順序は C言語版と同じく「長さ → バイト列」の順 (comparePairs 相当)。
+// 値が NULL の場合は Null フィールドで表す。
+//
+// hstore 型と演算子は pg_type.dat と pg_operator.dat に登録してある。値の入出力は adt パッケージが、
+// 演算子の関数は fmgr パッケージが扱う。
+
+// Pair はキーと値の組。
+type Pair struct {
+	Key  string
+	Val  string
+	Null bool
+}
+
+// Hstore はキーで整列され、キーが重複しない Pair の列。
+type Hstore struct {
+	pairs []Pair
+}
+
+func comparePairs(a, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// New は Pair の列から Hstore を作成する。重複キーは最初のものを残す (hstoreUniquePairs 相当)
+func New(pairs []Pair) *Hstore {
+	ps := append([]Pair(nil), pairs...)
+	sort.SliceStable(ps, func(i, j int) bool { return comparePairs(ps[i].Key, ps[j].Key) < 0 })
+	n := 0
+	for i, p := range ps {
+		if i > 0 && ps[n-1].Key == p.Key {
+			continue
+		}
+		ps[n] = p
+		n++
+	}
+	return &Hstore{pairs: ps[:n]}
+}
+
+// Pairs は整列済みの Pair の列を返す。
+func (h *Hstore) Pairs() []Pair {
+	return h.pairs
+}
+
+// Len はキーの数を返す。
+func (h *Hstore) Len() int {
+	return len(h.pairs)
+}
+
+func (h *Hstore) find(key string) int {
+	i := sort.Search(len(h.pairs), func(i int) bool { return comparePairs(h.pairs[i].Key, key) >= 0 })
+	if i < len(h.pairs) && h.pairs[i].Key == key {
+		return i
+	}
+	return -1
+}
+
+// ----------------------------------------------------------------
+// 入力 (hstore_in 相当)
+// ----------------------------------------------------------------
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) syntaxError() error {
+	if p.pos >= len(p.s) {
+		return errors.New("syntax error in hstore: unexpected end of string")
+	}
+	return fmt.Errorf("syntax error in hstore, near %q at position %d", p.s[p.pos:p.pos+1], p.pos)
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.s) && isSpace(p.s[p.pos]) {
+		p.pos++
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// getVal は引用符付きまたは引用符なしの文字列を1つ読み取る (get_val 相当)。
+// 引用符なしの場合は quoted=false を返し、NULL 判定は呼び出し側で行う。
+func (p *parser) getVal(isKey bool) (val string, quoted bool, err error) {
+	p.skipSpaces()
+	if p.pos >= len(p.s) {
+		return "", false, p.syntaxError()
+	}
+
+	var sb strings.Builder
+	if p.s[p.pos] == '"' {
+		p.pos++
+		for {
+			if p.pos >= len(p.s) {
+				return "", false, p.syntaxError()
+			}
+			c := p.s[p.pos]
+			switch c {
+			case '\\':
+				p.pos++
+				if p.pos >= len(p.s) {
+					return "", false, p.syntaxError()
+				}
+				sb.WriteByte(p.s[p.pos])
+			case '"':
+				p.pos++
+				return sb.String(), true, nil
+			default:
+				sb.WriteByte(c)
+			}
+			p.pos++
+		}
+	}
+
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if isSpace(c) || c == ',' || (isKey && c == '=') {
+			break
+		}
+		if c == '\\' {
+			p.pos++
+			if p.pos >= len(p.s) {
+				return "", false, p.syntaxError()
+			}
+			c = p.s[p.pos]
+		} else if c == '"' || c == '=' || c == '>' {
+			return "", false, p.syntaxError()
+		}
+		sb.WriteByte(c)
+		p.pos++
+	}
+	if sb.Len() == 0 {
+		return "", false, p.syntaxError()
+	}
+	return sb.String(), false, nil
+}
+
+// Parse は hstore のテキスト表現を解析する (hstore_in / parse_hstore 相当)
+func Parse(s string) (*Hstore, error) {
+	p := &parser{s: s}
+	var pairs []Pair
+
+	p.skipSpaces()
+	for p.pos < len(p.s) {
+		key, _, err := p.getVal(true)
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpaces()
+		if !strings.HasPrefix(p.s[p.pos:], "=>") {
+			return nil, p.syntaxError()
+		}
+		p.pos += 2
+
+		val, quoted, err := p.getVal(false)
+		if err != nil {
+			return nil, err
+		}
+		pair := Pair{Key: key, Val: val}
+		if !quoted && strings.EqualFold(val, "null") {
+			pair = Pair{Key: key, Null: true}
+		}
+		pairs = append(pairs, pair)
+
+		p.skipSpaces()
+		if p.pos >= len(p.s) {
+			break
+		}
+		if p.s[p.pos] != ',' {
+			return nil, p.syntaxError()
+		}
+		p.pos++
+		p.skipSpaces()
+		if p.pos >= len(p.s) {
+			return nil, p.syntaxError()
+		}
+	}
+
+	return New(pairs), nil
+}
+
+// ----------------------------------------------------------------
+// 出力 (hstore_out 相当)
+// ----------------------------------------------------------------
+
+func quote(sb *strings.Builder, s string) {
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+	sb.WriteByte('"')
+}
+
+// String は hstore のテキスト表現を返す。
+func (h *Hstore) String() string {
+	var sb strings.Builder
+	for i, p := range h.pairs {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		quote(&sb, p.Key)
+		sb.WriteString("=>")
+		if p.Null {
+			sb.WriteString("NULL")
+		} else {
+			quote(&sb, p.Val)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,82 @@
+package hstore
+
+// ----------------------------------------------------------------
+// GIN 演算子クラスのサポート関数 (hstore_gin.c 相当)
+// ----------------------------------------------------------------
+// gin_hstore_ops のキーは、キーに 'K'、値に 'V' (NULL の場合は 'N') の
+// 接頭辞を付けたテキスト。GIN アクセスメソッド本体はまだ存在しないため、
+// キー抽出と一貫性判定のみを提供する。
+//
+// gin_hstore_ops の演算子クラスは pg_opclass.dat などに登録してあり、サポート関数は
+// fmgr パッケージが登録する。
+
+const (
+	keyFlag  = 'K'
+	valFlag  = 'V'
+	nullFlag = 'N'
+)
+
+// Strategy は演算子クラスのストラテジ番号。
+type Strategy int
+
+const (
+	ContainsStrategyNumber  Strategy = 7  // @>
+	ExistsStrategyNumber    Strategy = 9  // ?
+	ExistsAnyStrategyNumber Strategy = 10 // ?|
+	ExistsAllStrategyNumber Strategy = 11 // ?&
+)
+
+func makeKey(flag byte, s string) string {
+	return string(flag) + s
+}
+
+// GinExtractValue は索引付けする hstore からキーを抽出する (gin_extract_hstore 相当)
+func GinExtractValue(h *Hstore) []string {
+	entries := make([]string, 0, len(h.pairs)*2)
+	for _, p := range h.pairs {
+		entries = append(entries, makeKey(keyFlag, p.Key))
+		if p.Null {
+			entries = append(entries, makeKey(nullFlag, ""))
+		} else {
+			entries = append(entries, makeKey(valFlag, p.Val))
+		}
+	}
+	return entries
+}
+
+// GinExtractQueryContains は @> の検索条件からキーを抽出する (gin_extract_hstore_query 相当)
+func GinExtractQueryContains(query *Hstore) []string {
+	return GinExtractValue(query)
+}
+
+// GinExtractQueryExists は ?, ?|, ?& の検索条件からキーを抽出する (gin_extract_hstore_query 相当)
+func GinExtractQueryExists(keys []string) []string {
+	entries := make([]string, len(keys))
+	for i, k := range keys {
+		entries[i] = makeKey(keyFlag, k)
+	}
+	return entries
+}
+
+// GinConsistent は索引エントリが条件を満たし得るかを判定する (gin_consistent_hstore 相当)
+func GinConsistent(check []bool, strategy Strategy) (match bool, recheck bool) {
+	all, some := true, false
+	for _, c := range check {
+		all = all && c
+		some = some || c
+	}
+
+	switch strategy {
+	case ContainsStrategyNumber:
+		// キーと値の対応は索引からは分からないため再評価が必要
+		return all, true
+	case ExistsStrategyNumber:
+		return some, false
+	case ExistsAnyStrategyNumber:
+		return some, false
+	case ExistsAllStrategyNumber:
+		return all, false
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,99 @@
+package hstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// json / jsonb との相互変換 (hstore_to_json, hstore_to_jsonb 相当)
+// ----------------------------------------------------------------
+// jsonb のオブジェクトはキーを「長さ → バイト列」の順に並べるが、これは hstore の
+// キー順と同じなので、hstore_to_json と hstore_to_jsonb は同じテキスト表現になる。
+// 値は常に文字列 (NULL は null) として出力する。
+
+// escapeJSON は PostgreSQL の escape_json と同じ規則で文字列をエスケープする。
+func escapeJSON(sb *strings.Builder, s string) {
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(sb, `\u%04x`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+}
+
+// ToJSON は hstore_to_json() / hstore_to_jsonb() の実装。
+func (h *Hstore) ToJSON() string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, p := range h.pairs {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		escapeJSON(&sb, p.Key)
+		sb.WriteString(": ")
+		if p.Null {
+			sb.WriteString("null")
+		} else {
+			escapeJSON(&sb, p.Val)
+		}
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// FromJSON は JSON オブジェクトから hstore を作成する。
+// 文字列値はそのまま、null は NULL、それ以外の値 (数値・真偽値・入れ子) は JSON テキストとして格納する。
+func FromJSON(data []byte) (*Hstore, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var obj map[string]json.RawMessage
+	if err := dec.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("invalid input syntax for type json: %w", err)
+	}
+	if obj == nil {
+		return nil, errors.New("cannot convert a non-object JSON value to hstore")
+	}
+
+	pairs := make([]Pair, 0, len(obj))
+	for k, raw := range obj {
+		raw = bytes.TrimSpace(raw)
+		switch {
+		case bytes.Equal(raw, []byte("null")):
+			pairs = append(pairs, Pair{Key: k, Null: true})
+		case len(raw) > 0 && raw[0] == '"':
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, Pair{Key: k, Val: s})
+		default:
+			pairs = append(pairs, Pair{Key: k, Val: string(raw)})
+		}
+	}
+	return New(pairs), nil
+}
@@ -0,0 +1,111 @@
+package hstore
+
+// ----------------------------------------------------------------
+// hstore 演算子 (hstore_op.c 相当)
+// ----------------------------------------------------------------
+
+// FetchVal は -> 演算子の実装 (hstore_fetchval 相当)。
+// キーが存在しないか値が NULL の場合は ok=false を返す。
+func (h *Hstore) FetchVal(key string) (val string, ok bool) {
+	i := h.find(key)
+	if i < 0 || h.pairs[i].Null {
+		return "", false
+	}
+	return h.pairs[i].Val, true
+}
+
+// Exists は ? 演算子の実装 (hstore_exists 相当)
+func (h *Hstore) Exists(key string) bool {
+	return h.find(key) >= 0
+}
+
+// ExistsAny は ?| 演算子の実装 (hstore_exists_any 相当)
+func (h *Hstore) ExistsAny(keys []string) bool {
+	for _, k := range keys {
+		if h.Exists(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExistsAll は ?& 演算子の実装 (hstore_exists_all 相当)
+func (h *Hstore) ExistsAll(keys []string) bool {
+	for _, k := range keys {
+		if !h.Exists(k) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains は @> 演算子の実装 (hstore_contains 相当)。
+// other の全ての組が h に同じ値 (NULL 同士も一致とみなす) で含まれるかを判定する。
+func (h *Hstore) Contains(other *Hstore) bool {
+	for _, p := range other.pairs {
+		i := h.find(p.Key)
+		if i < 0 {
+			return false
+		}
+		q := h.pairs[i]
+		if q.Null != p.Null || (!p.Null && q.Val != p.Val) {
+			return false
+		}
+	}
+	return true
+}
+
+// ContainedBy は <@ 演算子の実装 (hstore_contained 相当)
+func (h *Hstore) ContainedBy(other *Hstore) bool {
+	return other.Contains(h)
+}
+
+// Concat は || 演算子の実装 (hstore_concat 相当)。キーが重複する場合は other の値が優先される。
+func (h *Hstore) Concat(other *Hstore) *Hstore {
+	pairs := make([]Pair, 0, len(h.pairs)+len(other.pairs))
+	pairs = append(pairs, other.pairs...)
+	pairs = append(pairs, h.pairs...)
+	return New(pairs)
+}
+
+// Delete は - 演算子 (text) の実装 (hstore_delete 相当)
+func (h *Hstore) Delete(key string) *Hstore {
+	pairs := make([]Pair, 0, len(h.pairs))
+	for _, p := range h.pairs {
+		if p.Key != key {
+			pairs = append(pairs, p)
+		}
+	}
+	return &Hstore{pairs: pairs}
+}
+
+// Akeys は akeys() 関数の実装 (hstore_akeys 相当)
+func (h *Hstore) Akeys() []string {
+	keys := make([]string, len(h.pairs))
+	for i, p := range h.pairs {
+		keys[i] = p.Key
+	}
+	return keys
+}
+
+// Avals は avals() 関数の実装 (hstore_avals 相当)。NULL の値は nil で表す。
+func (h *Hstore) Avals() []*string {
+	vals := make([]*string, len(h.pairs))
+	for i := range h.pairs {
+		if !h.pairs[i].Null {
+			vals[i] = &h.pairs[i].Val
+		}
+	}
+	return vals
+}
+
+// Slice は slice() 関数の実装 (hstore_slice_to_hstore 相当)
+func (h *Hstore) Slice(keys []string) *Hstore {
+	var pairs []Pair
+	for _, k := range keys {
+		if i := h.find(k); i >= 0 {
+			pairs = append(pairs, h.pairs[i])
+		}
+	}
+	return New(pairs)
+}
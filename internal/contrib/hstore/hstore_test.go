@@ -0,0 +1,122 @@
+package hstore
+
+import (
+	"slices"
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) *Hstore {
+	t.Helper()
+	h, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q) = %v", s, err)
+	}
+	return h
+}
+
+func TestParseAndString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{``, ``},
+		{`a=>1`, `"a"=>"1"`},
+		// キーは長さ、バイト列の順に並び、重複したキーは最初のものを残す
+		{`bb=>2, a=>1, a=>3`, `"a"=>"1", "bb"=>"2"`},
+		{`"k y"=>"v\"q", n=>NULL, s=>"NULL"`, `"n"=>NULL, "s"=>"NULL", "k y"=>"v\"q"`},
+	}
+	for _, tt := range tests {
+		if got := mustParse(t, tt.in).String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`a=>`, "syntax error in hstore: unexpected end of string"},
+		{`a=1`, `syntax error in hstore, near "=" at position 1`},
+		{`a=>1,`, "syntax error in hstore: unexpected end of string"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.in); err == nil || err.Error() != tt.want {
+			t.Errorf("Parse(%q) = %v, want %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	h := mustParse(t, `a=>1, b=>2, c=>NULL`)
+	if v, ok := h.FetchVal("b"); !ok || v != "2" {
+		t.Errorf("FetchVal(b) = %q, %t", v, ok)
+	}
+	if _, ok := h.FetchVal("c"); ok {
+		t.Error("FetchVal returned the NULL value of c")
+	}
+	if !h.Exists("c") || h.Exists("d") || !h.ExistsAny([]string{"d", "a"}) || h.ExistsAll([]string{"a", "d"}) {
+		t.Error("Exists, ExistsAny or ExistsAll is wrong")
+	}
+	if !h.Contains(mustParse(t, `a=>1, c=>NULL`)) || h.Contains(mustParse(t, `a=>2`)) {
+		t.Error("Contains is wrong")
+	}
+	if !mustParse(t, `b=>2`).ContainedBy(h) {
+		t.Error("ContainedBy is wrong")
+	}
+	if got := h.Concat(mustParse(t, `a=>9, d=>4`)).String(); got != `"a"=>"9", "b"=>"2", "c"=>NULL, "d"=>"4"` {
+		t.Errorf("Concat = %s", got)
+	}
+	if got := h.Delete("b").String(); got != `"a"=>"1", "c"=>NULL` {
+		t.Errorf("Delete(b) = %s", got)
+	}
+	if got := h.Slice([]string{"c", "a", "x"}).String(); got != `"a"=>"1", "c"=>NULL` {
+		t.Errorf("Slice = %s", got)
+	}
+	if got := h.Akeys(); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("Akeys = %q", got)
+	}
+	if got := h.Avals(); len(got) != 3 || *got[0] != "1" || got[2] != nil {
+		t.Errorf("Avals = %v", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	h := mustParse(t, `a=>1, "q"=>"x\"y", n=>NULL`)
+	want := `{"a": "1", "n": null, "q": "x\"y"}`
+	if got := h.ToJSON(); got != want {
+		t.Errorf("ToJSON = %s, want %s", got, want)
+	}
+	back, err := FromJSON([]byte(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.String() != h.String() {
+		t.Errorf("FromJSON(ToJSON) = %s, want %s", back, h)
+	}
+}
+
+func TestGinConsistent(t *testing.T) {
+	value := GinExtractValue(mustParse(t, `a=>1, b=>NULL`))
+	if want := []string{"Ka", "V1", "Kb", "N"}; !slices.Equal(value, want) {
+		t.Fatalf("GinExtractValue = %q, want %q", value, want)
+	}
+	check := func(query []string) []bool {
+		var c []bool
+		for _, k := range query {
+			c = append(c, slices.Contains(value, k))
+		}
+		return c
+	}
+	if match, recheck := GinConsistent(check(GinExtractQueryContains(mustParse(t, `a=>1`))), ContainsStrategyNumber); !match || !recheck {
+		t.Errorf("@> a=>1 = %t, %t; want true, true", match, recheck)
+	}
+	if match, _ := GinConsistent(check(GinExtractQueryContains(mustParse(t, `a=>2`))), ContainsStrategyNumber); match {
+		t.Error("@> a=>2 matched")
+	}
+	if match, recheck := GinConsistent(check(GinExtractQueryExists([]string{"x", "b"})), ExistsAnyStrategyNumber); !match || recheck {
+		t.Errorf("?| {x,b} = %t, %t; want true, false", match, recheck)
+	}
+	if match, _ := GinConsistent(check(GinExtractQueryExists([]string{"x", "b"})), ExistsAllStrategyNumber); match {
+		t.Error("?& {x,b} matched")
+	}
+}
@@ -0,0 +1,80 @@
+package pgtrgm
+
+// ----------------------------------------------------------------
+// GIN 演算子クラスのサポート関数 (trgm_gin.c 相当)
+// ----------------------------------------------------------------
+// gin_trgm_ops のキーはトライグラムそのもの。
+// 索引アクセスメソッド (GIN/GiST) 本体はまだ存在しないため、ここでは
+// キー抽出と一貫性判定 (consistent) のみを提供し、AM 側から呼ばれる想定とする。
+//
+// gin_trgm_ops と gist_trgm_ops の演算子クラスは pg_opclass.dat などに登録してあり、
+// サポート関数は fmgr パッケージが登録する。LIKE と ILIKE の演算子はまだないため、
+// 演算子クラスには LikeStrategyNumber と ILikeStrategyNumber の演算子を含めない。
+
+// Strategy は演算子クラスのストラテジ番号。索引を作った列を演算子の左辺に書く。
+type Strategy int
+
+const (
+	SimilarityStrategyNumber           Strategy = 1  // %
+	DistanceStrategyNumber             Strategy = 2  // <->
+	LikeStrategyNumber                 Strategy = 3  // LIKE
+	ILikeStrategyNumber                Strategy = 4  // ILIKE
+	WordSimilarityStrategyNumber       Strategy = 7  // %>
+	WordDistanceStrategyNumber         Strategy = 8  // <->>
+	StrictWordSimilarityStrategyNumber Strategy = 9  // %>>
+	StrictWordDistanceStrategyNumber   Strategy = 10 // <->>>
+)
+
+// GinExtractValue は索引付けする値からキーを抽出する (gin_extract_value_trgm 相当)
+func GinExtractValue(value string) []Trigram {
+	return GenerateTrgm(value)
+}
+
+// GinExtractQuery は検索条件からキーを抽出する (gin_extract_query_trgm 相当)
+func GinExtractQuery(query string, strategy Strategy) []Trigram {
+	switch strategy {
+	case LikeStrategyNumber, ILikeStrategyNumber:
+		return GenerateWildcardTrgm(query)
+	default:
+		return GenerateTrgm(query)
+	}
+}
+
+// GinConsistent は、check[i] が「i 番目の検索キーを含む」ことを表すとき、
+// 索引エントリが条件を満たし得るかを判定する (gin_trgm_consistent 相当)。
+// 戻り値の recheck が true の場合、呼び出し側はヒープ上の値で条件を再評価する必要がある。
+func GinConsistent(check []bool, strategy Strategy) (match bool, recheck bool) {
+	nkeys := len(check)
+	ntrue := 0
+	for _, c := range check {
+		if c {
+			ntrue++
+		}
+	}
+
+	switch strategy {
+	case SimilarityStrategyNumber, WordSimilarityStrategyNumber, StrictWordSimilarityStrategyNumber:
+		if nkeys == 0 {
+			return false, false
+		}
+		// 索引値側のトライグラム数は分からないため、共通数/キー数 を上限として判定する
+		return float64(ntrue)/float64(nkeys) >= strategyThreshold(strategy), true
+	case LikeStrategyNumber, ILikeStrategyNumber:
+		// 全てのキーを含んでいなければ一致し得ない
+		return ntrue == nkeys, true
+	default:
+		return false, false
+	}
+}
+
+// strategyThreshold は類似度の演算子のストラテジ番号から、類似度のしきい値を返す
+func strategyThreshold(strategy Strategy) float64 {
+	switch strategy {
+	case SimilarityStrategyNumber:
+		return SimilarityThreshold
+	case WordSimilarityStrategyNumber:
+		return WordSimilarityThreshold
+	default:
+		return StrictWordSimilarityThreshold
+	}
+}
@@ -0,0 +1,120 @@
+package pgtrgm
+
+// ----------------------------------------------------------------
+// GiST 演算子クラスのサポート関数 (trgm_gist.c 相当)
+// ----------------------------------------------------------------
+// gist_trgm_ops の索引キーはトライグラムの集合 (整列済み・重複なし)。葉のキーは値の
+// トライグラムの集合で、内部ノードのキーは子のキーの和集合とする。
+//
+// C言語版は内部ノードのキーをトライグラムのハッシュのビット列 (シグネチャ) にして
+// 大きさを抑えるが、Go言語版は索引をまだディスクに書かないため、和集合をそのまま持つ。
+// 内部ノードでの判定は、シグネチャの代わりに和集合との共通のトライグラムの数を使う。
+
+// GistSplit はページ分割の結果 (GIST_SPLITVEC 相当)。Left と Right はキーの添字。
+type GistSplit struct {
+	Left, Right           []int
+	LeftUnion, RightUnion []Trigram
+}
+
+// GistCompress は値の索引キーを作る (gtrgm_compress 相当)
+func GistCompress(value string) []Trigram {
+	return GenerateTrgm(value)
+}
+
+// GistConsistent は索引キーが条件を満たし得るかを判定する (gtrgm_consistent 相当)。
+// 類似度の上限は、条件のトライグラムのうちキーにあるものの割合で見積もる。
+// 葉の % だけは値のトライグラムの集合そのものから類似度を正確に求める。
+func GistConsistent(key []Trigram, query string, strategy Strategy, isLeaf bool) (match bool, recheck bool) {
+	switch strategy {
+	case SimilarityStrategyNumber, WordSimilarityStrategyNumber, StrictWordSimilarityStrategyNumber:
+	default:
+		return false, false
+	}
+	qtrg := GenerateTrgm(query)
+	if len(qtrg) == 0 {
+		return false, false
+	}
+	count := countCommon(key, qtrg)
+	threshold := strategyThreshold(strategy)
+	if strategy == SimilarityStrategyNumber && isLeaf {
+		return calcSml(count, len(key), len(qtrg)) >= threshold, false
+	}
+	// word_similarity は値の一部の範囲との類似度のため、葉でも再評価が要る
+	return float64(count)/float64(len(qtrg)) >= threshold, strategy != SimilarityStrategyNumber
+}
+
+// GistDistance は KNN 検索で使う距離 (1 - 類似度) を返す (gtrgm_distance 相当)。
+// 内部ノードでは類似度の上限から求めた、距離の下限を返す。
+func GistDistance(key []Trigram, query string, strategy Strategy, isLeaf bool) (dist float64, recheck bool) {
+	qtrg := GenerateTrgm(query)
+	if len(qtrg) == 0 {
+		return 1, false
+	}
+	count := countCommon(key, qtrg)
+	switch strategy {
+	case DistanceStrategyNumber:
+		if isLeaf {
+			return 1 - calcSml(count, len(key), len(qtrg)), false
+		}
+		return 1 - float64(count)/float64(len(qtrg)), false
+	case WordDistanceStrategyNumber, StrictWordDistanceStrategyNumber:
+		return 1 - float64(count)/float64(len(qtrg)), true
+	default:
+		return 1, false
+	}
+}
+
+// GistUnion は全てのキーの和集合を返す (gtrgm_union 相当)
+func GistUnion(keys [][]Trigram) []Trigram {
+	var all []Trigram
+	for _, k := range keys {
+		all = append(all, k...)
+	}
+	return uniqueSorted(all)
+}
+
+// GistPenalty は orig に add を加えたときに増えるトライグラムの数を返す (gtrgm_penalty 相当)
+func GistPenalty(orig, add []Trigram) float64 {
+	return float64(len(add) - countCommon(orig, add))
+}
+
+// GistSame は2つのキーが同じかを返す (gtrgm_same 相当)
+func GistSame(a, b []Trigram) bool {
+	return len(a) == len(b) && countCommon(a, b) == len(a)
+}
+
+// hemdist は2つのキーの対称差の大きさを返す (hemdist 相当)
+func hemdist(a, b []Trigram) int {
+	return len(a) + len(b) - 2*countCommon(a, b)
+}
+
+// GistPicksplit はページのキーを2つに分ける (gtrgm_picksplit 相当)。最も離れた2つのキーを
+// 種にして、残りのキーを加えたときに増えるトライグラムが少ない方に入れる。
+func GistPicksplit(keys [][]Trigram) *GistSplit {
+	seedL, seedR, worst := 0, len(keys)-1, -1
+	for i := range keys {
+		for j := i + 1; j < len(keys); j++ {
+			if d := hemdist(keys[i], keys[j]); d > worst {
+				seedL, seedR, worst = i, j, d
+			}
+		}
+	}
+	split := &GistSplit{
+		Left: []int{seedL}, Right: []int{seedR},
+		LeftUnion: keys[seedL], RightUnion: keys[seedR],
+	}
+	for i, k := range keys {
+		if i == seedL || i == seedR {
+			continue
+		}
+		costL, costR := GistPenalty(split.LeftUnion, k), GistPenalty(split.RightUnion, k)
+		if costL < costR || costL == costR && len(split.Left) <= len(split.Right) {
+			split.Left = append(split.Left, i)
+			split.LeftUnion = GistUnion([][]Trigram{split.LeftUnion, k})
+		} else {
+			split.Right = append(split.Right, i)
+			split.RightUnion = GistUnion([][]Trigram{split.RightUnion, k})
+		}
+	}
+	return split
+}
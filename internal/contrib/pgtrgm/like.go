@@ -0,0 +1,109 @@
+package pgtrgm
+
+// ----------------------------------------------------------------
+// LIKE パターンからのトライグラム抽出 (generate_wildcard_trgm 相当)
+// ----------------------------------------------------------------
+// LIKE '%foo%' のような検索を索引で絞り込むため、パターン中のワイルドカード
+// (% と _) を含まない部分からトライグラムを抽出する。
+// 抽出したトライグラムは「一致する文字列が必ず含むもの」であり、索引は
+// 候補の絞り込みにのみ使われ、最終的な判定は LIKE 自体の再評価 (recheck) で行う。
+//
+// 単語の前後のパディングは、その単語が本当に単語の境界にある場合のみ付与する。
+// 例えば 'foo%' の "foo" は先頭が文字列の先頭なので左側にパディングを付けるが、
+// ワイルドカードに接している右側には付けない。
+
+const escapeChar = '\\'
+
+func isWildcard(r rune) bool {
+	return r == '%' || r == '_'
+}
+
+// wildcardPart はパターン中の1つの単語とその前後が単語境界かどうかを表す。
+type wildcardPart struct {
+	word         []rune
+	lpad, rpad   bool
+	nextPosition int
+}
+
+// getWildcardPart はパターンの pos 以降から次の単語を取り出す (get_wildcard_part 相当)
+func getWildcardPart(pattern []rune, pos int) (wildcardPart, bool) {
+	inLeadingWildcardMeta := false
+	inEscape := false
+
+	// 単語の開始位置まで読み飛ばす
+	for pos < len(pattern) {
+		r := pattern[pos]
+		if inEscape {
+			if isWordChar(r) {
+				break
+			}
+			inEscape = false
+			inLeadingWildcardMeta = false
+		} else if r == escapeChar {
+			inEscape = true
+		} else if isWildcard(r) {
+			inLeadingWildcardMeta = true
+		} else if isWordChar(r) {
+			break
+		} else {
+			inLeadingWildcardMeta = false
+		}
+		pos++
+	}
+	if pos >= len(pattern) {
+		return wildcardPart{}, false
+	}
+
+	part := wildcardPart{lpad: !inLeadingWildcardMeta}
+
+	// 単語を読み取る
+	inEscape = false
+	for pos < len(pattern) {
+		r := pattern[pos]
+		if inEscape {
+			if !isWordChar(r) {
+				// エスケープされた非単語文字は単語の終わり (右側は境界)
+				part.rpad = true
+				pos++
+				part.nextPosition = pos
+				return part, true
+			}
+			part.word = append(part.word, r)
+			inEscape = false
+		} else if r == escapeChar {
+			inEscape = true
+		} else if isWildcard(r) {
+			part.nextPosition = pos
+			return part, true
+		} else if !isWordChar(r) {
+			part.rpad = true
+			pos++
+			part.nextPosition = pos
+			return part, true
+		} else {
+			part.word = append(part.word, r)
+		}
+		pos++
+	}
+
+	// パターンの末尾に達した場合は右側も境界
+	part.rpad = true
+	part.nextPosition = pos
+	return part, true
+}
+
+// GenerateWildcardTrgm は LIKE パターンから必須トライグラムを抽出する。
+func GenerateWildcardTrgm(pattern string) []Trigram {
+	runes := []rune(pattern)
+	var trgs []Trigram
+	pos := 0
+	for {
+		part, ok := getWildcardPart(runes, pos)
+		if !ok {
+			break
+		}
+		trgs = append(trgs, wordTrigrams(string(part.word), part.lpad, part.rpad)...)
+		pos = part.nextPosition
+	}
+	return uniqueSorted(trgs)
+}
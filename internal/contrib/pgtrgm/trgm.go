@@ -0,0 +1,236 @@
+package pgtrgm
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ----------------------------------------------------------------
+// トライグラム (contrib/pg_trgm の trgm_op.c 相当)
+// ----------------------------------------------------------------
+// PostgreSQL の pg_trgm は、文字列を単語に分割し、各単語の前に空白2つ、
+// 後ろに空白1つを付与 (LPADDING=2, RPADDING=1) して3文字ずつ切り出す。
+// 大文字小文字は区別せず (IGNORECASE)、英数字以外は単語の区切りとみなす (KEEPONLYALNUM)。
+//
+// C言語版ではマルチバイト文字のトライグラムを CRC で3バイトに圧縮しているが、
+// Go言語版では rune 単位で扱い、3文字の string をそのままトライグラムとする。
+
+const (
+	lPadding = 2
+	rPadding = 1
+)
+
+// 類似度しきい値の既定値 (pg_trgm.similarity_threshold 等の既定値相当)
+var (
+	SimilarityThreshold           = 0.3
+	WordSimilarityThreshold       = 0.6
+	StrictWordSimilarityThreshold = 0.5
+)
+
+// Trigram は3文字分の rune 列を表す。
+type Trigram string
+
+// posTrgm は出現位置付きのトライグラム (pos_trgm 相当)
+type posTrgm struct {
+	trg   Trigram
+	index int
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// splitWords は文字列を単語に分割する (find_word 相当)
+func splitWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return !isWordChar(r) })
+}
+
+// wordTrigrams は1単語からパディング付きのトライグラムを生成する (make_trigrams 相当)
+func wordTrigrams(word string, lpad, rpad bool) []Trigram {
+	buf := make([]rune, 0, len(word)+lPadding+rPadding)
+	if lpad {
+		for i := 0; i < lPadding; i++ {
+			buf = append(buf, ' ')
+		}
+	}
+	buf = append(buf, []rune(strings.ToLower(word))...)
+	if rpad {
+		for i := 0; i < rPadding; i++ {
+			buf = append(buf, ' ')
+		}
+	}
+
+	if len(buf) < 3 {
+		return nil
+	}
+	trgs := make([]Trigram, 0, len(buf)-2)
+	for i := 0; i+3 <= len(buf); i++ {
+		trgs = append(trgs, Trigram(buf[i:i+3]))
+	}
+	return trgs
+}
+
+// generateTrgmOnly は出現順のトライグラム列と、各トライグラムが単語の先頭かどうかを返す
+// (generate_trgm_only 相当)。bounds は strict_word_similarity で単語境界の判定に使う。
+func generateTrgmOnly(s string) (trgs []Trigram, bounds []bool) {
+	for _, w := range splitWords(s) {
+		wt := wordTrigrams(w, true, true)
+		for i, t := range wt {
+			trgs = append(trgs, t)
+			bounds = append(bounds, i == 0)
+		}
+	}
+	return trgs, bounds
+}
+
+// uniqueSorted はトライグラム列を整列し重複を除く。
+func uniqueSorted(trgs []Trigram) []Trigram {
+	out := append([]Trigram(nil), trgs...)
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	n := 0
+	for i, t := range out {
+		if i == 0 || t != out[n-1] {
+			out[n] = t
+			n++
+		}
+	}
+	return out[:n]
+}
+
+// GenerateTrgm は文字列のトライグラム集合 (整列済み・重複なし) を返す (generate_trgm 相当)
+func GenerateTrgm(s string) []Trigram {
+	trgs, _ := generateTrgmOnly(s)
+	return uniqueSorted(trgs)
+}
+
+// ShowTrgm は show_trgm() 関数の実装。
+func ShowTrgm(s string) []string {
+	trgs := GenerateTrgm(s)
+	out := make([]string, len(trgs))
+	for i, t := range trgs {
+		out[i] = string(t)
+	}
+	return out
+}
+
+// countCommon は整列済みトライグラム集合の共通要素数を数える (cnt_sml の前半部相当)
+func countCommon(a, b []Trigram) int {
+	count := 0
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			count++
+			i++
+			j++
+		}
+	}
+	return count
+}
+
+// calcSml は DIVUNION 方式の類似度計算 (CALCSML 相当)
+func calcSml(count, len1, len2 int) float64 {
+	if len1+len2-count == 0 {
+		return 0
+	}
+	return float64(count) / float64(len1+len2-count)
+}
+
+// Similarity は similarity() 関数の実装 (cnt_sml 相当)
+func Similarity(a, b string) float64 {
+	t1 := GenerateTrgm(a)
+	t2 := GenerateTrgm(b)
+	if len(t1) == 0 || len(t2) == 0 {
+		return 0
+	}
+	return calcSml(countCommon(t1, t2), len(t1), len(t2))
+}
+
+// SimilarityDist は <-> 演算子の実装 (similarity_dist 相当)
+func SimilarityDist(a, b string) float64 {
+	return 1 - Similarity(a, b)
+}
+
+// SimilarityOp は % 演算子の実装 (similarity_op 相当)
+func SimilarityOp(a, b string) bool {
+	return Similarity(a, b) >= SimilarityThreshold
+}
+
+// calcWordSimilarity は、1つ目の文字列のトライグラム集合と、2つ目の文字列の
+// 順序付きトライグラム列の任意の連続範囲との類似度の最大値を求める
+// (calc_word_similarity 相当)。strict の場合は範囲の両端を単語境界に限定する。
+//
+// C言語版は近似的な反復手続き (iterate_word_similarity) で範囲の下限を調整しているが、
+// ここでは全ての連続範囲を調べる素直な実装とする。
+func calcWordSimilarity(a, b string, strict bool) float64 {
+	set1 := GenerateTrgm(a)
+	if len(set1) == 0 {
+		return 0
+	}
+	in1 := make(map[Trigram]bool, len(set1))
+	for _, t := range set1 {
+		in1[t] = true
+	}
+
+	trg2, bounds := generateTrgmOnly(b)
+	best := 0.0
+	for lower := 0; lower < len(trg2); lower++ {
+		if strict && !bounds[lower] {
+			continue
+		}
+		seen := make(map[Trigram]bool)
+		count := 0
+		for upper := lower; upper < len(trg2); upper++ {
+			t := trg2[upper]
+			if !seen[t] {
+				seen[t] = true
+				if in1[t] {
+					count++
+				}
+			}
+			// strict の場合、範囲の終端は単語の末尾でなければならない
+			if strict && upper+1 < len(trg2) && !bounds[upper+1] {
+				continue
+			}
+			if s := calcSml(count, len(set1), len(seen)); s > best {
+				best = s
+			}
+		}
+	}
+	return best
+}
+
+// WordSimilarity は word_similarity() 関数の実装。
+func WordSimilarity(a, b string) float64 {
+	return calcWordSimilarity(a, b, false)
+}
+
+// StrictWordSimilarity は strict_word_similarity() 関数の実装。
+func StrictWordSimilarity(a, b string) float64 {
+	return calcWordSimilarity(a, b, true)
+}
+
+// WordSimilarityOp は <% 演算子の実装 (word_similarity_op 相当)
+func WordSimilarityOp(a, b string) bool {
+	return WordSimilarity(a, b) >= WordSimilarityThreshold
+}
+
+// StrictWordSimilarityOp は <<% 演算子の実装 (strict_word_similarity_op 相当)
+func StrictWordSimilarityOp(a, b string) bool {
+	return StrictWordSimilarity(a, b) >= StrictWordSimilarityThreshold
+}
+
+// WordSimilarityDist は <<-> 演算子の実装 (word_similarity_dist_op 相当)
+func WordSimilarityDist(a, b string) float64 {
+	return 1 - WordSimilarity(a, b)
+}
+
+// StrictWordSimilarityDist は <<<-> 演算子の実装 (strict_word_similarity_dist_op 相当)
+func StrictWordSimilarityDist(a, b string) float64 {
+	return 1 - StrictWordSimilarity(a, b)
+}
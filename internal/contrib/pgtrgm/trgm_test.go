@@ -0,0 +1,108 @@
+package pgtrgm
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestShowTrgm(t *testing.T) {
+	if got, want := ShowTrgm("Cat"), []string{"  c", " ca", "at ", "cat"}; !slices.Equal(got, want) {
+		t.Errorf("ShowTrgm(Cat) = %q, want %q", got, want)
+	}
+	if got := ShowTrgm("!!"); len(got) != 0 {
+		t.Errorf("ShowTrgm(!!) = %q, want none", got)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	// PostgreSQL の pg_trgm の文書にある例と同じ値になる
+	tests := []struct {
+		name string
+		fn   func(a, b string) float64
+		want float64
+	}{
+		{"similarity", Similarity, 4.0 / 11},
+		{"word_similarity", WordSimilarity, 0.8},
+		{"strict_word_similarity", StrictWordSimilarity, 4.0 / 7},
+	}
+	for _, tt := range tests {
+		if got := tt.fn("word", "two words"); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s(word, two words) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got := Similarity("abc", "xyz"); got != 0 {
+		t.Errorf("Similarity(abc, xyz) = %v, want 0", got)
+	}
+	if !SimilarityOp("word", "words") || SimilarityOp("word", "ward") {
+		t.Error("SimilarityOp does not follow the default threshold 0.3")
+	}
+}
+
+func TestGinConsistent(t *testing.T) {
+	// 問い合わせのキー 4 つのうち 1 つしかなければ、類似度は 0.3 に届き得ない
+	if match, _ := GinConsistent([]bool{true, false, false, false}, SimilarityStrategyNumber); match {
+		t.Error("1 of 4 keys matched the similarity strategy")
+	}
+	match, recheck := GinConsistent([]bool{true, true, false, false}, SimilarityStrategyNumber)
+	if !match || !recheck {
+		t.Errorf("2 of 4 keys = %t, %t; want true, true", match, recheck)
+	}
+}
+
+func TestGistConsistent(t *testing.T) {
+	leaf := GistCompress("word")
+	if match, recheck := GistConsistent(leaf, "words", SimilarityStrategyNumber, true); !match || recheck {
+		t.Errorf("leaf word %% words = %t, %t; want true, false", match, recheck)
+	}
+	if match, _ := GistConsistent(leaf, "ward", SimilarityStrategyNumber, true); match {
+		t.Error("leaf key of word matched ward")
+	}
+	// 内部ノードのキーは子のキーの和集合で、子に一致し得るものがあれば true を返す
+	inner := GistUnion([][]Trigram{leaf, GistCompress("banana")})
+	if match, _ := GistConsistent(inner, "words", SimilarityStrategyNumber, false); !match {
+		t.Error("internal key does not match a child")
+	}
+	if match, _ := GistConsistent(inner, "xylophone", SimilarityStrategyNumber, false); match {
+		t.Error("internal key matched an unrelated query")
+	}
+}
+
+func TestGistDistance(t *testing.T) {
+	leaf := GistCompress("word")
+	if got, recheck := GistDistance(leaf, "two words", DistanceStrategyNumber, true); math.Abs(got-7.0/11) > 1e-12 || recheck {
+		t.Errorf("leaf distance = %v, %t; want %v, false", got, recheck, 7.0/11)
+	}
+	// 内部ノードの距離は、子のどのキーの距離よりも大きくない
+	inner := GistUnion([][]Trigram{leaf, GistCompress("banana")})
+	if got, _ := GistDistance(inner, "two words", DistanceStrategyNumber, false); got > 7.0/11 {
+		t.Errorf("internal distance %v is larger than the leaf distance", got)
+	}
+}
+
+func TestGistPicksplitKeepsEveryKey(t *testing.T) {
+	values := []string{"apple", "apples", "applet", "zebra", "zebras", "zebu"}
+	keys := make([][]Trigram, len(values))
+	for i, v := range values {
+		keys[i] = GistCompress(v)
+	}
+	split := GistPicksplit(keys)
+	if len(split.Left)+len(split.Right) != len(keys) || len(split.Left) == 0 || len(split.Right) == 0 {
+		t.Fatalf("split = %v / %v", split.Left, split.Right)
+	}
+	for _, side := range []struct {
+		idx   []int
+		union []Trigram
+	}{{split.Left, split.LeftUnion}, {split.Right, split.RightUnion}} {
+		for _, i := range side.idx {
+			if !GistSame(GistUnion([][]Trigram{side.union, keys[i]}), side.union) {
+				t.Errorf("key %q is outside its union", values[i])
+			}
+		}
+	}
+	// apple の仲間と zebra の仲間は別のページに分かれる
+	sideOf := func(i int) bool { return slices.Contains(split.Left, i) }
+	if sideOf(0) == sideOf(3) || sideOf(0) != sideOf(1) || sideOf(3) != sideOf(4) {
+		t.Errorf("split = %v / %v, want apples and zebras apart", split.Left, split.Right)
+	}
+}
@@ -0,0 +1,105 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+)
+
+// ----------------------------------------------------------------
+// ページ単位の暗号化 (crypto/bufenc.c 相当)
+// ----------------------------------------------------------------
+// リレーションのページを AES-256-CTR で暗号化する。暗号化してもページの大きさが変わらないよう、
+// 認証タグを持たない CTR モードを使う。改ざんの検出はデータチェックサムに任せる。
+//
+// CTR モードでは同じ鍵と IV で別の平文を暗号化してはならない。C言語版はページの LSN を IV に
+// 含めるが、WAL をまだ書かないため LSN は変わらない。そこで書き出すたびに乱数の nonce を作り、
+// ページの末尾に空けた page.ReservedPageSize バイトに暗号化せずに置く。IV はページを識別する
+// 情報と nonce のハッシュの先頭 14 バイトとし、残りの 2 バイトをページ内のブロックのカウンタに
+// 使う (1 ページは 16 バイトのブロック 512 個なので桁あふれしない)。nonce は 64 ビットのため、
+// 同じページを 2^32 回書き直すまでは IV が重なることはまずない。
+//
+// nonce が全て 0 のページは暗号化していないページとして読む。md の ZeroExtend が書く 0 の
+// ページがこれに当たる。
+//
+// WAL 用の鍵も initdb で作っておくが、WAL をまだ書かないため WAL のページは暗号化しない。
+
+// PageEncryptOffset はリレーションのページで暗号化を始める位置。ページヘッダの pd_lsn と
+// pd_checksum は暗号化しない。チェックサムはバッファマネージャーが暗号化する前のページに対して
+// 計算し、読み込んだときも復号した後に検証する。
+const PageEncryptOffset = 10
+
+// PageNonceSize はページの末尾に置く nonce の大きさ。ファイル暗号化が有効なクラスタでは、
+// この大きさを page.ReservedPageSize にする。
+const PageNonceSize = 8
+
+// PageID はリレーションのページを識別する (BufferTag 相当)
+type PageID struct {
+	RLocator storage.RelFileLocator
+	ForkNum  storage.ForkNumber
+	BlockNum storage.BlockNumber
+}
+
+// pageIV はリレーションのページの IV を nonce から作る。
+func pageIV(id PageID, nonce []byte) []byte {
+	var buf [16]byte
+	binary.BigEndian.PutUint32(buf[0:], uint32(id.RLocator.DbOid))
+	binary.BigEndian.PutUint32(buf[4:], uint32(id.RLocator.RelNumber))
+	binary.BigEndian.PutUint32(buf[8:], uint32(id.ForkNum))
+	binary.BigEndian.PutUint32(buf[12:], uint32(id.BlockNum))
+	h := sha256.New()
+	h.Write([]byte("relation"))
+	h.Write(buf[:])
+	h.Write(nonce)
+	iv := h.Sum(nil)[:16]
+	iv[14], iv[15] = 0, 0
+	return iv
+}
+
+// xorKeyStream は鍵 keyID と iv で buf をその場で暗号化 (または復号) する。
+func xorKeyStream(keyID int, iv, buf []byte) {
+	stream := cipher.NewCTR(fileEncryption.keys[keyID], iv)
+	stream.XORKeyStream(buf, buf)
+}
+
+// pageNonce はページの末尾の nonce の領域を返す。
+func pageNonce(page []byte) []byte {
+	return page[len(page)-PageNonceSize:]
+}
+
+// EncryptPage はページをその場で暗号化する (EncryptPage 相当)。新しい nonce をページの末尾に
+// 書く。ファイル暗号化が無効な場合は何もしない。ページを書き換えるため、smgr は共有バッファの
+// ページの複製に対して、書き出す直前に呼ぶ。
+func EncryptPage(page []byte, id PageID) {
+	if fileEncryption == nil {
+		return
+	}
+	nonce := pageNonce(page)
+	for {
+		rand.Read(nonce)
+		// 全て 0 の nonce は暗号化していないページの印に使う
+		if binary.BigEndian.Uint64(nonce) != 0 {
+			break
+		}
+	}
+	xorKeyStream(KeyIDRelation, pageIV(id, nonce), page[PageEncryptOffset:len(page)-PageNonceSize])
+}
+
+// DecryptPage はページをその場で復号する (DecryptPage 相当)。
+// smgr がページを読み込んだ直後に呼ぶ。nonce が全て 0 のページはそのまま返す。
+func DecryptPage(page []byte, id PageID) {
+	if fileEncryption == nil {
+		return
+	}
+	nonce := pageNonce(page)
+	if binary.BigEndian.Uint64(nonce) == 0 {
+		return
+	}
+	// CTR モードでは暗号化と復号は同じ操作
+	xorKeyStream(KeyIDRelation, pageIV(id, nonce), page[PageEncryptOffset:len(page)-PageNonceSize])
+	// 共有バッファのページでは nonce の領域を 0 にしておく
+	clear(nonce)
+}
@@ -0,0 +1,177 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/page"
+)
+
+// ----------------------------------------------------------------
+// クラスタ単位のファイル暗号化の鍵管理 (crypto/kmgr.c 相当)
+// ----------------------------------------------------------------
+// 鍵は2段階で管理する。
+//
+//   - 鍵暗号化鍵 (KEK): データディレクトリの外に置いた鍵ファイル
+//     (cluster_key_file) に16進数で書かれた 256 ビットの鍵。
+//   - データ暗号化鍵: リレーション用と WAL 用の2つ。initdb の際に乱数で作り、
+//     KEK で AES-GCM により暗号化して $PGDATA/pg_cryptokeys/live/ に保存する。
+//
+// KEK はデータ暗号化鍵の暗号化にしか使わないため、KEK を変更しても
+// データファイルを暗号化し直す必要はない。
+//
+// initdb は --cluster-key-file を指定すると BootstrapFileEncryptionKeys で鍵を作り、
+// postgresql.conf の cluster_key_file に鍵ファイルを書く。サーバーは起動のたびに
+// InitializeFileEncryption で鍵を読み込む。
+
+// KeyDir はデータ暗号化鍵を保存するディレクトリ (データディレクトリからの相対パス)
+const KeyDir = "pg_cryptokeys/live"
+
+// データ暗号化鍵の識別子。KeyDir 内のファイル名にもなる。
+const (
+	KeyIDRelation = 0
+	KeyIDWAL      = 1
+	numKeys       = 2
+)
+
+// KeyLen は鍵の長さ (AES-256)
+const KeyLen = 32
+
+// keyring は復号済みのデータ暗号化鍵を保持する。
+type keyring struct {
+	keys [numKeys]cipher.Block
+}
+
+// fileEncryption はファイル暗号化が有効な場合の鍵。無効な場合は nil。
+var fileEncryption *keyring
+
+// FileEncryptionEnabled はクラスタでファイル暗号化が有効かを返す。
+func FileEncryptionEnabled() bool {
+	return fileEncryption != nil
+}
+
+// readKEK は鍵ファイルから KEK を読む。
+func readKEK(keyFile string) ([]byte, error) {
+	if keyFile == "" {
+		return nil, errors.New("cluster_key_file must be set when file encryption is enabled")
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read cluster key file \"%s\": %w", keyFile, err)
+	}
+	kek, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(kek) != KeyLen {
+		return nil, fmt.Errorf("cluster key file \"%s\" must contain a %d-byte key in hexadecimal", keyFile, KeyLen)
+	}
+	return kek, nil
+}
+
+// wrapKey は鍵を KEK で暗号化する。出力は nonce に暗号文と認証タグを続けたもの。
+func wrapKey(kek, key []byte, keyID int) ([]byte, error) {
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	// 鍵ファイルの入れ替えを検知できるよう、鍵の識別子を追加認証データにする
+	return gcm.Seal(nonce, nonce, key, []byte{byte(keyID)}), nil
+}
+
+// unwrapKey は wrapKey で暗号化した鍵を復号する。
+func unwrapKey(kek, wrapped []byte, keyID int) ([]byte, error) {
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, errors.New("invalid wrapped key")
+	}
+	nonce, ciphertext := wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, []byte{byte(keyID)})
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func keyPath(dataDir string, keyID int) string {
+	return filepath.Join(dataDir, KeyDir, fmt.Sprint(keyID))
+}
+
+// BootstrapFileEncryptionKeys はデータ暗号化鍵を作って保存する (BootStrapKmgr 相当)。
+// initdb でファイル暗号化を有効にした場合に呼ぶ。
+func BootstrapFileEncryptionKeys(dataDir, keyFile string) error {
+	kek, err := readKEK(keyFile)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(dataDir, KeyDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("could not create directory \"%s\": %w", dir, err)
+	}
+
+	for id := 0; id < numKeys; id++ {
+		key := make([]byte, KeyLen)
+		if _, err := rand.Read(key); err != nil {
+			return fmt.Errorf("could not generate encryption key: %w", err)
+		}
+		wrapped, err := wrapKey(kek, key, id)
+		if err != nil {
+			return err
+		}
+		path := keyPath(dataDir, id)
+		if err := os.WriteFile(path, wrapped, 0600); err != nil {
+			return fmt.Errorf("could not write file \"%s\": %w", path, err)
+		}
+	}
+	return nil
+}
+
+// InitializeFileEncryption は保存されたデータ暗号化鍵を読み込む (InitializeKmgr 相当)。
+// 鍵のディレクトリがなければファイル暗号化は無効として何もしない。有効なら、ページの末尾に
+// nonce の領域を空けるよう page.ReservedPageSize を設定する。リレーションのページを読み書き
+// する前に呼ぶ。
+func InitializeFileEncryption(dataDir, keyFile string) error {
+	fileEncryption = nil
+	page.ReservedPageSize = 0
+	if _, err := os.Stat(filepath.Join(dataDir, KeyDir)); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	kek, err := readKEK(keyFile)
+	if err != nil {
+		return err
+	}
+	kr := &keyring{}
+	for id := 0; id < numKeys; id++ {
+		path := keyPath(dataDir, id)
+		wrapped, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read file \"%s\": %w", path, err)
+		}
+		key, err := unwrapKey(kek, wrapped, id)
+		if err != nil {
+			return errors.New("cluster key does not match the keys stored in the data directory")
+		}
+		if kr.keys[id], err = aes.NewCipher(key); err != nil {
+			return err
+		}
+	}
+	fileEncryption = kr
+	page.ReservedPageSize = PageNonceSize
+	return nil
+}
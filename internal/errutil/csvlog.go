@@ -0,0 +1,104 @@
+package errutil
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// CSV 形式のサーバーログ (utils/error/csvlog.c 相当)
+// ----------------------------------------------------------------
+// log_destination が csvlog なら、1つのメッセージを CSV の1行にする。列の並びは PostgreSQL の
+// csvlog と同じで、postgres_log テーブルに COPY で読み込める。文字列の列は二重引用符で囲み、
+// 値がなければ空にする。メッセージの中の改行はそのまま残すため、1行が複数の物理行になることがある。
+//
+// 内部の問い合わせ (internal_query, internal_query_pos) と、エラーを報告したソースの位置 (location)
+// はまだ持たないため、いつも空にする。
+
+// writeCsvlog は r を CSV の1行にする (write_csvlog 相当)
+func writeCsvlog(r *logRecord) []byte {
+	proc := r.proc
+	edata := r.edata
+	var b strings.Builder
+	lineNumber := proc.nextLineNumber(LogDestCsvlog)
+	session := proc.isSession()
+
+	b.WriteString(r.formattedLogTime())
+	b.WriteByte(',')
+	if session {
+		appendCSVLiteral(&b, proc.UserName)
+	}
+	b.WriteByte(',')
+	if session {
+		appendCSVLiteral(&b, proc.DatabaseName)
+	}
+	b.WriteByte(',')
+	b.WriteString(strconv.Itoa(int(proc.Pid)))
+	b.WriteByte(',')
+	// 接続元のホストとポート番号
+	if session {
+		b.WriteByte('"')
+		b.WriteString(proc.RemoteHost)
+		if proc.RemotePort != "" {
+			b.WriteByte(':')
+			b.WriteString(proc.RemotePort)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte(',')
+	b.WriteString(proc.sessionID())
+	b.WriteByte(',')
+	b.WriteString(strconv.FormatInt(lineNumber, 10))
+	b.WriteByte(',')
+	if session {
+		appendCSVLiteral(&b, r.procStatus().CommandTag)
+	}
+	b.WriteByte(',')
+	b.WriteString(r.formattedStartTime())
+	b.WriteByte(',')
+	b.WriteString(r.procStatus().Vxid)
+	b.WriteByte(',')
+	b.WriteString(strconv.FormatUint(uint64(r.procStatus().Xid), 10))
+	b.WriteByte(',')
+	b.WriteString(edata.Level.String())
+	b.WriteByte(',')
+	b.WriteString(edata.Code)
+	b.WriteByte(',')
+	appendCSVLiteral(&b, edata.Message)
+	b.WriteByte(',')
+	appendCSVLiteral(&b, r.detail())
+	b.WriteByte(',')
+	appendCSVLiteral(&b, edata.Hint)
+	// internal_query と internal_query_pos
+	b.WriteString(",,,")
+	appendCSVLiteral(&b, edata.Context)
+	b.WriteByte(',')
+	appendCSVLiteral(&b, r.query)
+	b.WriteByte(',')
+	if r.query != "" && edata.Position > 0 {
+		b.WriteString(strconv.Itoa(edata.Position))
+	}
+	// location
+	b.WriteString(",,")
+	if session {
+		appendCSVLiteral(&b, r.applicationName())
+	}
+	b.WriteByte(',')
+	appendCSVLiteral(&b, proc.BackendType)
+	// leader_pid
+	b.WriteString(",,")
+	b.WriteString(strconv.FormatInt(r.procStatus().QueryID, 10))
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+// appendCSVLiteral は s を二重引用符で囲み、中の二重引用符を重ねて書く (appendCSVLiteral 相当)。
+// s が空なら何も書かない。
+func appendCSVLiteral(b *strings.Builder, s string) {
+	if s == "" {
+		return
+	}
+	b.WriteByte('"')
+	b.WriteString(strings.ReplaceAll(s, `"`, `""`))
+	b.WriteByte('"')
+}
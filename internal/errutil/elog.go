@@ -0,0 +1,662 @@
+package errutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// エラーの報告 (utils/error/elog.c 相当)
+// ----------------------------------------------------------------
+// 報告する内容を ErrorData にまとめ、EmitErrorReport でサーバーログとクライアント
+// (ErrorResponse または NoticeResponse) に送る。サーバーログに出すかは log_min_messages、
+// クライアントに送るかは client_min_messages で決める。
+//
+// サーバーログには log_destination の形式で書く。stderr は各行の先頭に log_line_prefix を付けて
+// 標準エラー出力に、csvlog と jsonlog は1つのメッセージを CSV の1行または JSON の1つの
+// オブジェクトにして、logging_collector が集めるそれぞれのファイルに書く。ログを収集していない
+// ときの csvlog と jsonlog は、stderr の形式で標準エラー出力に書く。プロセスの情報 (log_line_prefix
+// の %p や %u など) は、メッセージを出すプロセスが持つ ProcInfo から取る。
+//
+// guc と libpq はこのパッケージが使うため、このパッケージを呼べない。設定ファイルを読む途中の
+// 誤りや待ち受けるアドレスのメッセージは、init で設定する guc.LogHook と libpq.LogHook を通して
+// postmaster のメッセージとして書く。log_timezone はまだないため、時刻はサーバーの地方時で書く。
+//
+// C言語版の ereport(ERROR) は longjmp で呼び出し元に戻るが、ここでは ERROR 以上の ErrorData を
+// error として返す。バックエンドは ERROR を受け取るとトランザクションを中止してセッションを続け、
+// FATAL を受け取るとセッションを終了し、PANIC を受け取るとサーバー全体を異常終了として扱う。
+//
+// error_context_stack のコールバックの代わりに、エラーを返す途中で AddContext が CONTEXT の
+// 行を付ける。内側で付けたものから順に報告する。
+
+// Level はメッセージの水準 (elevel 相当)。後のものほど重い。
+type Level int
+
+const (
+	Debug5 Level = iota + 10
+	Debug4
+	Debug3
+	Debug2
+	Debug1
+	// Log はサーバーの動作の記録。サーバーログでは ERROR と FATAL の間に位置する
+	Log
+	// LogServerOnly はクライアントには送らない LOG (LOG_SERVER_ONLY 相当)
+	LogServerOnly
+	// Info は client_min_messages によらずクライアントに送る
+	Info
+	Notice
+	Warning
+	// WarningClientOnly はサーバーログには出さない WARNING (WARNING_CLIENT_ONLY 相当)
+	WarningClientOnly
+	// Error は現在のトランザクションを中止させる
+	Error
+	// Fatal はセッションを終了させる
+	Fatal
+	// Panic はサーバー全体を異常終了させる
+	Panic
+)
+
+// String はメッセージに付ける水準の名前を返す (error_severity 相当)
+func (l Level) String() string {
+	switch {
+	case l <= Debug1:
+		return "DEBUG"
+	case l == Log, l == LogServerOnly:
+		return "LOG"
+	case l == Info:
+		return "INFO"
+	case l == Notice:
+		return "NOTICE"
+	case l == Warning, l == WarningClientOnly:
+		return "WARNING"
+	case l == Error:
+		return "ERROR"
+	case l == Fatal:
+		return "FATAL"
+	default:
+		return "PANIC"
+	}
+}
+
+// levelNames は log_min_messages と client_min_messages の値に対応する水準
+var levelNames = map[string]Level{
+	"debug5": Debug5, "debug4": Debug4, "debug3": Debug3, "debug2": Debug2, "debug1": Debug1,
+	"log": Log, "info": Info, "notice": Notice, "warning": Warning,
+	"error": Error, "fatal": Fatal, "panic": Panic,
+}
+
+// defaultCode は SQLSTATE を指定しなかったときの SQLSTATE を返す (errstart 相当)
+func defaultCode(level Level) string {
+	switch {
+	case level >= Error:
+		return errcodes.InternalError
+	case level >= Warning:
+		return errcodes.Warning
+	default:
+		return errcodes.SuccessfulCompletion
+	}
+}
+
+// ErrorData は報告する1つのメッセージの内容 (ErrorData 相当)。Error 以上の水準のものは
+// error として呼び出し元に返す。
+type ErrorData struct {
+	Level   Level
+	Code    string
+	Message string
+	Detail  string
+	// DetailLog はサーバーログにだけ出す詳細 (errdetail_log 相当)。あれば Detail の代わりに出す
+	DetailLog string
+	Hint      string
+	// Context は改行で区切った CONTEXT の行 (errcontext 相当)
+	Context string
+	// Position は問い合わせ中の位置 (1始まりの文字数)。0 の場合は省略する
+	Position int
+}
+
+func (e *ErrorData) Error() string { return e.Message }
+
+// New は SQLSTATE が code のメッセージを作る (ereport と errcode, errmsg 相当)
+func New(level Level, code, format string, args ...any) *ErrorData {
+	return &ErrorData{Level: level, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Elog は SQLSTATE を指定せずにメッセージを作る (elog 相当)。SQLSTATE は水準から決める。
+func Elog(level Level, format string, args ...any) *ErrorData {
+	return New(level, defaultCode(level), format, args...)
+}
+
+// WithDetail はクライアントにも送る詳細を付ける (errdetail 相当)
+func (e *ErrorData) WithDetail(format string, args ...any) *ErrorData {
+	e.Detail = fmt.Sprintf(format, args...)
+	return e
+}
+
+// WithDetailLog はサーバーログにだけ出す詳細を付ける (errdetail_log 相当)
+func (e *ErrorData) WithDetailLog(format string, args ...any) *ErrorData {
+	e.DetailLog = fmt.Sprintf(format, args...)
+	return e
+}
+
+// WithHint は対処の方法を付ける (errhint 相当)
+func (e *ErrorData) WithHint(format string, args ...any) *ErrorData {
+	e.Hint = fmt.Sprintf(format, args...)
+	return e
+}
+
+// contextError は AddContext が付けた CONTEXT の行
+type contextError struct {
+	err     error
+	context string
+}
+
+func (e *contextError) Error() string { return e.err.Error() }
+func (e *contextError) Unwrap() error { return e.err }
+
+// AddContext は err に CONTEXT の行を付ける (errcontext 相当)。err が nil なら nil を返す。
+func AddContext(err error, format string, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	return &contextError{err: err, context: fmt.Sprintf(format, args...)}
+}
+
+// ContextOf は err に付いた CONTEXT の行を内側で付けたものから順に、改行で区切って返す
+func ContextOf(err error) string {
+	var lines []string
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch e := err.(type) {
+		case *contextError:
+			lines = append(lines, e.context)
+		case *ErrorData:
+			if e.Context != "" {
+				lines = append(lines, e.Context)
+			}
+		}
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LevelOf は err を報告する水準を返す。ErrorData でないエラーは ERROR として扱う。
+func LevelOf(err error) Level {
+	var e *ErrorData
+	if errors.As(err, &e) && e.Level > Error {
+		return e.Level
+	}
+	return Error
+}
+
+// ----------------------------------------------------------------
+// サーバーログとクライアントへの出力
+// ----------------------------------------------------------------
+
+// EmitErrorReport は edata をサーバーログとクライアントに送る (EmitErrorReport 相当)。
+// proc はメッセージを出すプロセスで、nil なら postmaster とする。gucs はセッションの設定で、
+// nil ならサーバー全体の値を使う。port が nil ならクライアントには送らない。query は STATEMENT
+// として出す問い合わせで、空なら出さない。戻り値のエラーは送信に失敗した (接続が切れた) ことを表す。
+func EmitErrorReport(proc *ProcInfo, gucs *guc.Session, port *libpq.Port, edata *ErrorData, query string) error {
+	if isLogLevelOutput(edata.Level, settingLevel(gucs, guc.LogMinMessages)) {
+		if query != "" && !isLogLevelOutput(edata.Level, settingLevel(gucs, guc.LogMinErrorStatement)) {
+			query = ""
+		}
+		if proc == nil {
+			proc = postmasterProc
+		}
+		sendMessageToServerLog(&logRecord{edata: edata, proc: proc, gucs: gucs, query: query, time: time.Now()})
+	}
+	if port != nil && shouldOutputToClient(edata.Level, settingLevel(gucs, guc.ClientMinMessages)) {
+		return sendMessageToFrontend(port, edata)
+	}
+	return nil
+}
+
+// Report はクライアントのいないプロセスのメッセージをサーバーログに出す (ereport 相当)。
+// proc が nil なら postmaster のメッセージとする。
+func Report(proc *ProcInfo, edata *ErrorData) {
+	_ = EmitErrorReport(proc, nil, nil, edata, "")
+}
+
+func init() {
+	guc.LogHook = reportHook
+	libpq.LogHook = reportHook
+}
+
+// reportHook は guc と libpq のメッセージを postmaster のメッセージとしてサーバーログに出す
+// (guc.LogHook、libpq.LogHook)。level は log_min_messages の値と同じ水準の名前。
+func reportHook(level, message, detail, hint string) {
+	edata := Elog(levelNames[strings.ToLower(level)], "%s", message)
+	edata.Detail, edata.Hint = detail, hint
+	Report(nil, edata)
+}
+
+// settingLevel はメッセージの水準のパラメータの値を返す
+func settingLevel(gucs *guc.Session, c *guc.ConfigEnum) Level {
+	if gucs != nil {
+		return levelNames[gucs.GetEnum(c)]
+	}
+	return levelNames[c.Get()]
+}
+
+// isLogLevelOutput は level のメッセージを、水準の下限が min のときにサーバーログに出すかを返す
+// (is_log_level_output 相当)。サーバーログでは LOG を ERROR と FATAL の間の水準として扱う。
+func isLogLevelOutput(level, min Level) bool {
+	switch {
+	case level == WarningClientOnly:
+		return false
+	case level == Log, level == LogServerOnly:
+		return min <= Error
+	case min == Log:
+		return level >= Fatal
+	default:
+		return level >= min
+	}
+}
+
+// shouldOutputToClient は level のメッセージを、水準の下限が min のときにクライアントに
+// 送るかを返す (should_output_to_client 相当)。ERROR 以上と INFO はいつも送る。
+func shouldOutputToClient(level, min Level) bool {
+	switch {
+	case level == LogServerOnly:
+		return false
+	case level >= Error, level == Info:
+		return true
+	default:
+		return level >= min
+	}
+}
+
+// sendMessageToServerLog はサーバーログにメッセージを出す (send_message_to_server_log 相当)。
+// csvlog と jsonlog は、ログを収集していなければ代わりに stderr の形式で書く。
+func sendMessageToServerLog(r *logRecord) {
+	dest := LogDestinations()
+	fallbackToStderr := false
+	collector.RLock()
+	defer collector.RUnlock()
+	if dest&LogDestCsvlog != 0 {
+		if collector.write != nil {
+			collector.write(LogDestCsvlog, writeCsvlog(r))
+		} else {
+			fallbackToStderr = true
+		}
+	}
+	if dest&LogDestJsonlog != 0 {
+		if collector.write != nil {
+			collector.write(LogDestJsonlog, writeJsonlog(r))
+		} else {
+			fallbackToStderr = true
+		}
+	}
+	if dest&LogDestStderr != 0 || fallbackToStderr {
+		// 複数の行を1回で書き、他のバックエンドのメッセージと混ざらないようにする
+		os.Stderr.WriteString(writeStderrLog(r))
+	}
+}
+
+// writeStderrLog は stderr の形式のメッセージを作る。DETAIL などの各行にも log_line_prefix を付け、
+// サーバーログにだけ出す詳細があれば、クライアントにも送る詳細の代わりに出す。
+func writeStderrLog(r *logRecord) string {
+	var b strings.Builder
+	line := func(label, text string) {
+		r.logLinePrefix(&b)
+		b.WriteString(label)
+		b.WriteString(":  ")
+		appendWithTabs(&b, text)
+		b.WriteByte('\n')
+	}
+	line(r.edata.Level.String(), r.edata.Message)
+	if detail := r.detail(); detail != "" {
+		line("DETAIL", detail)
+	}
+	if r.edata.Hint != "" {
+		line("HINT", r.edata.Hint)
+	}
+	if r.edata.Context != "" {
+		line("CONTEXT", r.edata.Context)
+	}
+	if r.query != "" {
+		line("STATEMENT", r.query)
+	}
+	return b.String()
+}
+
+// appendWithTabs は各改行の後にタブを入れて s を書く (append_with_tabs 相当)。複数行の
+// メッセージの続きの行を、次のメッセージと区別できるようにする。
+func appendWithTabs(b *strings.Builder, s string) {
+	b.WriteString(strings.ReplaceAll(s, "\n", "\n\t"))
+}
+
+// sendMessageToFrontend は ErrorResponse または NoticeResponse メッセージを送る
+// (send_message_to_frontend 相当)。ERROR 以上は ErrorResponse として送る。
+func sendMessageToFrontend(port *libpq.Port, edata *ErrorData) error {
+	msgtype := byte(libpq.PqMsgNoticeResponse)
+	if edata.Level >= Error {
+		msgtype = libpq.PqMsgErrorResponse
+	}
+	buf := libpq.BeginMessage(msgtype)
+	buf.SendByte(libpq.PgDiagSeverity)
+	buf.SendString(edata.Level.String())
+	buf.SendByte(libpq.PgDiagSeverityNonlocalized)
+	buf.SendString(edata.Level.String())
+	buf.SendByte(libpq.PgDiagSqlstate)
+	buf.SendString(edata.Code)
+	buf.SendByte(libpq.PgDiagMessagePrimary)
+	buf.SendString(edata.Message)
+	if edata.Detail != "" {
+		buf.SendByte(libpq.PgDiagMessageDetail)
+		buf.SendString(edata.Detail)
+	}
+	if edata.Hint != "" {
+		buf.SendByte(libpq.PgDiagMessageHint)
+		buf.SendString(edata.Hint)
+	}
+	if edata.Position > 0 {
+		buf.SendByte(libpq.PgDiagStatementPosition)
+		buf.SendString(fmt.Sprint(edata.Position))
+	}
+	if edata.Context != "" {
+		buf.SendByte(libpq.PgDiagContext)
+		buf.SendString(edata.Context)
+	}
+	buf.SendByte(0)
+	return buf.EndMessage(port)
+}
+
+// ----------------------------------------------------------------
+// サーバーログの出力先とプロセスの情報
+// ----------------------------------------------------------------
+
+// LogDest は log_destination の出力先 (LOG_DESTINATION_* 相当)
+type LogDest int
+
+const (
+	LogDestStderr LogDest = 1 << iota
+	LogDestCsvlog
+	LogDestJsonlog
+)
+
+// LogDestinations は log_destination の出力先を返す。値は設定したときに確かめてある
+func LogDestinations() LogDest {
+	list, _ := adt.SplitGUCList(guc.LogDestination.Get(), ',')
+	var dest LogDest
+	for _, tok := range list {
+		switch strings.ToLower(tok) {
+		case "stderr":
+			dest |= LogDestStderr
+		case "csvlog":
+			dest |= LogDestCsvlog
+		case "jsonlog":
+			dest |= LogDestJsonlog
+		}
+	}
+	return dest
+}
+
+// collector は csvlog と jsonlog の行をログを収集するゴルーチンに渡す関数。nil なら収集していない
+// (redirection_done 相当)
+var collector struct {
+	sync.RWMutex
+	write func(dest LogDest, line []byte)
+}
+
+// SetCollector は csvlog と jsonlog の行の書き込み先を設定する。ログの収集を始めたときと
+// 止めたときに syslogger が呼ぶ。止めたときは nil を渡し、書き込み中の行を渡し終えるまで待つ。
+func SetCollector(write func(dest LogDest, line []byte)) {
+	collector.Lock()
+	defer collector.Unlock()
+	collector.write = write
+}
+
+// ProcInfo はサーバーログに出すプロセスの情報 (MyProcPid, MyBackendType, MyStartTime,
+// MyProcPort 相当)。メッセージを出すプロセスが1つ作って持ち、EmitErrorReport に渡す。
+type ProcInfo struct {
+	Pid int32
+	// BackendType は pg_stat_activity の backend_type と同じプロセスの種類
+	BackendType string
+	StartTime   time.Time
+	// RemoteHost と RemotePort は接続元 (Unix ドメインソケットでは RemoteHost が [local])。
+	// クライアントの接続を処理するプロセスでなければ RemoteHost が空
+	RemoteHost, RemotePort string
+	// UserName と DatabaseName は接続先のユーザーとデータベース。分かったときに設定する
+	UserName, DatabaseName string
+	// Status はメッセージを出す時点のプロセスの状態を返す。nil なら状態を出さない
+	Status func() ProcStatus
+
+	// lineNumbers は出力先ごとの、このプロセスがこれまでに出した行の数 (log_line_number 相当)
+	lineNumbers [3]atomic.Int64
+}
+
+// ProcStatus はメッセージを出す時点のプロセスの状態
+type ProcStatus struct {
+	// CommandTag は実行中のコマンドのタグ。アイドルなら idle (ps の表示相当)
+	CommandTag string
+	// Vxid は仮想トランザクション ID。空なら出さない
+	Vxid string
+	// Xid は割り当てたトランザクション ID。割り当てていなければ 0
+	Xid     uint32
+	QueryID int64
+}
+
+// NewAuxProcInfo はクライアントの接続を処理しないプロセス (checkpointer など) の ProcInfo を作る。
+// ゴルーチンが別の PID を持たないため、サーバーの PID を使う。
+func NewAuxProcInfo(backendType string) *ProcInfo {
+	return &ProcInfo{Pid: int32(os.Getpid()), BackendType: backendType, StartTime: time.Now()}
+}
+
+// postmasterProc は proc を渡さないメッセージのプロセスの情報
+var postmasterProc = NewAuxProcInfo("postmaster")
+
+// isSession はクライアントの接続を処理するプロセスかを返す (MyProcPort != NULL 相当)
+func (p *ProcInfo) isSession() bool { return p.RemoteHost != "" }
+
+// nextLineNumber は dest に出す次の行の番号を返す
+func (p *ProcInfo) nextLineNumber(dest LogDest) int64 {
+	i := 0
+	for ; LogDest(1)<<i != dest; i++ {
+	}
+	return p.lineNumbers[i].Add(1)
+}
+
+// sessionID はセッションの ID を返す。開始時刻と PID の16進数を . で繋ぐ
+func (p *ProcInfo) sessionID() string {
+	return fmt.Sprintf("%x.%x", p.StartTime.Unix(), p.Pid)
+}
+
+// logRecord はサーバーログに出す1つのメッセージと、それを出した時点の情報
+type logRecord struct {
+	edata *ErrorData
+	proc  *ProcInfo
+	gucs  *guc.Session
+	// query は出す問い合わせ。log_min_error_statement で出さない場合は空
+	query  string
+	time   time.Time
+	status *ProcStatus
+}
+
+// detail はサーバーログに出す詳細を返す
+func (r *logRecord) detail() string {
+	if r.edata.DetailLog != "" {
+		return r.edata.DetailLog
+	}
+	return r.edata.Detail
+}
+
+// procStatus はプロセスの状態を返す。1つのメッセージの中では同じ値を使う
+func (r *logRecord) procStatus() *ProcStatus {
+	if r.status == nil {
+		r.status = &ProcStatus{}
+		if r.proc.Status != nil {
+			*r.status = r.proc.Status()
+		}
+	}
+	return r.status
+}
+
+// applicationName は application_name の値を返す
+func (r *logRecord) applicationName() string {
+	if r.gucs != nil {
+		return r.gucs.GetString(guc.ApplicationName)
+	}
+	return guc.ApplicationName.Get()
+}
+
+// formattedLogTime はメッセージを出した時刻をミリ秒まで書いたもの (get_formatted_log_time 相当)。
+// log_timezone はまだなく、地方時で書く。
+func (r *logRecord) formattedLogTime() string {
+	return r.time.Format("2006-01-02 15:04:05.000 MST")
+}
+
+// formattedStartTime はプロセスを始めた時刻 (get_formatted_start_time 相当)
+func (r *logRecord) formattedStartTime() string {
+	return r.proc.StartTime.Format("2006-01-02 15:04:05 MST")
+}
+
+// logLinePrefix は log_line_prefix を展開して b に書く (log_line_prefix, log_status_format 相当)。
+// % の後に幅を書くと、正なら右に、負なら左に寄せて空白で埋める。知らない指定は無視する。
+// %q より後は、クライアントの接続を処理するプロセスでなければ書かない。
+func (r *logRecord) logLinePrefix(b *strings.Builder) {
+	format := guc.LogLinePrefix.Get()
+	proc := r.proc
+	lineNumber := proc.nextLineNumber(LogDestStderr)
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		// % の後の幅を読む (process_log_prefix_padding 相当)
+		i++
+		start := i
+		if i < len(format) && format[i] == '-' {
+			i++
+		}
+		for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+			i++
+		}
+		if i >= len(format) {
+			return
+		}
+		padding, _ := strconv.Atoi(format[start:i])
+		pad := func(s string) { writePadded(b, s, padding) }
+		// 値がないときは幅の分だけ空白を書く
+		empty := func() { writePadded(b, "", padding) }
+		switch format[i] {
+		case 'a':
+			if proc.isSession() {
+				appname := r.applicationName()
+				if appname == "" {
+					appname = "[unknown]"
+				}
+				pad(appname)
+			} else {
+				empty()
+			}
+		case 'b':
+			pad(proc.BackendType)
+		case 'u':
+			if proc.isSession() {
+				pad(unknownIfEmpty(proc.UserName))
+			} else {
+				empty()
+			}
+		case 'd':
+			if proc.isSession() {
+				pad(unknownIfEmpty(proc.DatabaseName))
+			} else {
+				empty()
+			}
+		case 'c':
+			pad(proc.sessionID())
+		case 'p':
+			pad(strconv.Itoa(int(proc.Pid)))
+		case 'P':
+			// 並列実行はまだなく、グループのリーダーになるプロセスはない
+			empty()
+		case 'l':
+			pad(strconv.FormatInt(lineNumber, 10))
+		case 'm':
+			pad(r.formattedLogTime())
+		case 't':
+			pad(r.time.Format("2006-01-02 15:04:05 MST"))
+		case 'n':
+			pad(fmt.Sprintf("%d.%03d", r.time.Unix(), r.time.Nanosecond()/int(time.Millisecond)))
+		case 's':
+			pad(r.formattedStartTime())
+		case 'i':
+			if proc.isSession() {
+				pad(r.procStatus().CommandTag)
+			} else {
+				empty()
+			}
+		case 'r':
+			if proc.isSession() {
+				host := proc.RemoteHost
+				if proc.RemotePort != "" {
+					host += "(" + proc.RemotePort + ")"
+				}
+				pad(host)
+			} else {
+				empty()
+			}
+		case 'h':
+			if proc.isSession() {
+				pad(proc.RemoteHost)
+			} else {
+				empty()
+			}
+		case 'q':
+			if !proc.isSession() {
+				return
+			}
+		case 'v':
+			if vxid := r.procStatus().Vxid; vxid != "" {
+				pad(vxid)
+			} else {
+				empty()
+			}
+		case 'x':
+			pad(strconv.FormatUint(uint64(r.procStatus().Xid), 10))
+		case 'e':
+			pad(r.edata.Code)
+		case 'Q':
+			pad(strconv.FormatInt(r.procStatus().QueryID, 10))
+		case '%':
+			b.WriteByte('%')
+		}
+	}
+}
+
+// writePadded は s を幅 padding に揃えて書く。C言語の "%*s" と同じく、負の幅は左に寄せる
+func writePadded(b *strings.Builder, s string, padding int) {
+	width := padding
+	if width < 0 {
+		width = -width
+	}
+	fill := strings.Repeat(" ", max(width-len(s), 0))
+	if padding > 0 {
+		b.WriteString(fill)
+	}
+	b.WriteString(s)
+	if padding < 0 {
+		b.WriteString(fill)
+	}
+}
+
+// unknownIfEmpty は空の値を [unknown] にする
+func unknownIfEmpty(s string) string {
+	if s == "" {
+		return "[unknown]"
+	}
+	return s
+}
@@ -0,0 +1,113 @@
+package errutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// JSON 形式のサーバーログ (utils/error/jsonlog.c 相当)
+// ----------------------------------------------------------------
+// log_destination が jsonlog なら、1つのメッセージを1行の JSON のオブジェクトにする。キーの
+// 名前と並びは PostgreSQL の jsonlog と同じで、値のないキーは省く。pid や line_num などの数は
+// 数値として、それ以外は文字列として書く。
+
+// writeJsonlog は r を JSON のオブジェクトの1行にする (write_jsonlog 相当)
+func writeJsonlog(r *logRecord) []byte {
+	proc := r.proc
+	edata := r.edata
+	var b strings.Builder
+	lineNumber := proc.nextLineNumber(LogDestJsonlog)
+	session := proc.isSession()
+	st := r.procStatus()
+
+	b.WriteByte('{')
+	// 最初のキーの前にはカンマを付けない
+	b.WriteString(`"timestamp":`)
+	appendJSONString(&b, r.formattedLogTime())
+	if session {
+		appendJSONKeyValue(&b, "user", proc.UserName, true)
+		appendJSONKeyValue(&b, "dbname", proc.DatabaseName, true)
+	}
+	appendJSONKeyValue(&b, "pid", strconv.Itoa(int(proc.Pid)), false)
+	if session {
+		appendJSONKeyValue(&b, "remote_host", proc.RemoteHost, true)
+		appendJSONKeyValue(&b, "remote_port", proc.RemotePort, false)
+	}
+	appendJSONKeyValue(&b, "session_id", proc.sessionID(), true)
+	appendJSONKeyValue(&b, "line_num", strconv.FormatInt(lineNumber, 10), false)
+	if session {
+		appendJSONKeyValue(&b, "ps", st.CommandTag, true)
+	}
+	appendJSONKeyValue(&b, "session_start", r.formattedStartTime(), true)
+	appendJSONKeyValue(&b, "vxid", st.Vxid, true)
+	if st.Xid != 0 {
+		appendJSONKeyValue(&b, "txid", strconv.FormatUint(uint64(st.Xid), 10), false)
+	}
+	appendJSONKeyValue(&b, "error_severity", edata.Level.String(), true)
+	appendJSONKeyValue(&b, "state_code", edata.Code, true)
+	appendJSONKeyValue(&b, "message", edata.Message, true)
+	appendJSONKeyValue(&b, "detail", r.detail(), true)
+	appendJSONKeyValue(&b, "hint", edata.Hint, true)
+	appendJSONKeyValue(&b, "context", edata.Context, true)
+	if r.query != "" {
+		appendJSONKeyValue(&b, "statement", r.query, true)
+		if edata.Position > 0 {
+			appendJSONKeyValue(&b, "cursor_position", strconv.Itoa(edata.Position), false)
+		}
+	}
+	if session {
+		appendJSONKeyValue(&b, "application_name", r.applicationName(), true)
+	}
+	appendJSONKeyValue(&b, "backend_type", proc.BackendType, true)
+	appendJSONKeyValue(&b, "query_id", strconv.FormatInt(st.QueryID, 10), false)
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// appendJSONKeyValue はカンマに続けてキーと値を書く (appendJSONKeyValue 相当)。値が空なら
+// 何も書かない。escape が true なら値を JSON の文字列にし、false なら数値としてそのまま書く。
+func appendJSONKeyValue(b *strings.Builder, key, value string, escape bool) {
+	if value == "" {
+		return
+	}
+	b.WriteByte(',')
+	appendJSONString(b, key)
+	b.WriteByte(':')
+	if escape {
+		appendJSONString(b, value)
+	} else {
+		b.WriteString(value)
+	}
+}
+
+// appendJSONString は s を JSON の文字列にして書く (escape_json 相当)
+func appendJSONString(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for _, c := range s {
+		switch c {
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			if c < ' ' {
+				fmt.Fprintf(b, `\u%04x`, c)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
@@ -0,0 +1,380 @@
+package executor
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/pgstat"
+)
+
+// ----------------------------------------------------------------
+// 式の評価 (execExpr.c / execExprInterp.c 相当)
+// ----------------------------------------------------------------
+// C言語版は式をステップ列にコンパイルしてから解釈実行するが、
+// Go言語版では解析済みの式木を再帰的に評価する。
+
+// ParamListInfo は外部パラメータ ($n) の値 (ParamListInfo 相当)。添字は n-1。
+type ParamListInfo []adt.Datum
+
+// ExprContext は式の評価に使う情報 (ExprContext 相当)
+type ExprContext struct {
+	Params ParamListInfo
+	// Caller は関数を呼び出すセッション。nil の場合はセッションに依存する関数を呼べない
+	Caller fmgr.CallContext
+	// FuncStats は関数の呼び出しを数えるセッションの統計 (track_functions)。nil の場合は数えない
+	FuncStats *pgstat.Pending
+	// ScanTuples は走査中の各リレーションの現在の行 (ecxt_scantuple 相当)。添字は VarNo-1。
+	ScanTuples [][]adt.Datum
+	// DomainValue はドメインの CHECK 制約を確かめている値 (domainValue_datum 相当)
+	DomainValue adt.Datum
+}
+
+// Error は SQLSTATE 付きの実行時のエラー。バックエンドはクライアントにそのまま報告する。
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// ExecEvalExpr は式を評価する。
+func ExecEvalExpr(expr parser.Expr, econtext *ExprContext) (adt.Datum, error) {
+	switch e := expr.(type) {
+	case *parser.Const:
+		return e.ConstValue, nil
+	case *parser.Param:
+		if e.ParamID > len(econtext.Params) {
+			return nil, fmt.Errorf("no value found for parameter %d", e.ParamID)
+		}
+		return econtext.Params[e.ParamID-1], nil
+	case *parser.Var:
+		if e.VarNo > len(econtext.ScanTuples) {
+			return nil, fmt.Errorf("invalid varno %d", e.VarNo)
+		}
+		return econtext.ScanTuples[e.VarNo-1][e.VarAttno-1], nil
+	case *parser.FuncExpr:
+		return execEvalFunc(e, econtext)
+	case *parser.CoerceViaIO:
+		val, err := ExecEvalExpr(e.Arg, econtext)
+		if err != nil {
+			return nil, err
+		}
+		return coerceValue(val, e.Arg.ExprType(), e.ResultType)
+	case *parser.OpExpr:
+		if e.Opfuncid != catalog.InvalidOid {
+			// CREATE OPERATOR で作った演算子は、その関数を呼ぶ
+			return callFunction(e.Opfuncid, e.Args, econtext)
+		}
+		val, err := ExecEvalExpr(e.Args[0], econtext)
+		if err != nil {
+			return nil, err
+		}
+		if e.Opname == "-" && len(e.Args) == 1 {
+			return negate(val, e.ResultType)
+		}
+		if len(e.Args) == 2 {
+			rval, err := ExecEvalExpr(e.Args[1], econtext)
+			if err != nil {
+				return nil, err
+			}
+			return evalComparison(e.Opname, val, rval, e.Args[0].ExprType())
+		}
+		return nil, &Error{Code: errcodes.UndefinedFunction, Message: fmt.Sprintf("operator does not exist: %s", e.Opname)}
+	case *parser.BoolOpExpr:
+		return execEvalBoolExpr(e, econtext)
+	case *parser.RelabelType:
+		return ExecEvalExpr(e.Arg, econtext)
+	case *parser.CoerceToDomain:
+		val, err := ExecEvalExpr(e.Arg, econtext)
+		if err != nil {
+			return nil, err
+		}
+		if err := DomainCheck(val, e.ResultType, econtext); err != nil {
+			return nil, err
+		}
+		return val, nil
+	case *parser.CoerceToDomainValue:
+		return econtext.DomainValue, nil
+	}
+	return nil, fmt.Errorf("unrecognized node type: %T", expr)
+}
+
+// execEvalFunc は関数を呼び出す (ExecInterpExpr の EEOP_FUNCEXPR 相当)
+func execEvalFunc(e *parser.FuncExpr, econtext *ExprContext) (adt.Datum, error) {
+	return callFunction(e.FuncID, e.Args, econtext)
+}
+
+// callFunction は引数を評価して funcid の関数を呼ぶ。STRICT の関数は引数に NULL があれば呼ばずに
+// NULL を返す (EEOP_FUNCEXPR_STRICT 相当)。
+func callFunction(funcid catalog.Oid, args []parser.Expr, econtext *ExprContext) (adt.Datum, error) {
+	proc, ok := catalog.SearchProc(funcid)
+	if !ok {
+		return nil, fmt.Errorf("cache lookup failed for function %d", funcid)
+	}
+	fn, ok := fmgr.Lookup(proc.Prosrc)
+	if !ok {
+		return nil, fmt.Errorf("internal function \"%s\" is not in internal lookup table", proc.Prosrc)
+	}
+	fcinfo := &fmgr.FunctionCallInfo{Args: make([]adt.Datum, len(args)), Context: econtext.Caller}
+	for i, arg := range args {
+		val, err := ExecEvalExpr(arg, econtext)
+		if err != nil {
+			return nil, err
+		}
+		if val == nil && proc.Proisstrict {
+			return nil, nil
+		}
+		fcinfo.Args[i] = val
+	}
+	// 組み込み関数は track_functions によらず数えない (fmgr_info の fn_stats 相当)
+	fcu := econtext.FuncStats.InitFunctionUsage(funcid, pgstat.TrackFuncAll)
+	if (proc.Prosecdef || len(proc.Proconfig) > 0) && econtext.Caller != nil {
+		// 組み込み関数の所有者はブートストラップスーパーユーザー
+		var owner string
+		if proc.Prosecdef {
+			role, ok := catalog.SearchRoleByOid(catalog.BootstrapSuperuserID)
+			if !ok {
+				return nil, fmt.Errorf("cache lookup failed for role %d", catalog.BootstrapSuperuserID)
+			}
+			owner = role.Rolname
+		}
+		exit, err := econtext.Caller.EnterFunction(owner, proc.Proconfig)
+		if err != nil {
+			return nil, err
+		}
+		defer exit()
+	}
+	result, err := fn(fcinfo)
+	fcu.End(true)
+	return result, err
+}
+
+// coerceValue は値を target 型に変換する。専用のキャスト関数はまだないため、
+// 数値から整数への変換 (四捨五入) 以外は文字列表現を経由した I/O 変換で代用する。
+func coerceValue(val adt.Datum, source, target catalog.Oid) (adt.Datum, error) {
+	if val == nil {
+		return nil, nil
+	}
+	if isIntegerType(target) {
+		switch v := val.(type) {
+		case float64:
+			// float8 → 整数は偶数丸め (rint)
+			return adt.InputFunctionCall(target, strconv.FormatFloat(math.RoundToEven(v), 'f', 0, 64))
+		case string:
+			if source == catalog.NUMERICOID {
+				// numeric → 整数は四捨五入
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return nil, err
+				}
+				return adt.InputFunctionCall(target, strconv.FormatFloat(math.Round(f), 'f', 0, 64))
+			}
+		}
+	}
+	return adt.InputFunctionCall(target, adt.OutputFunctionCall(source, val))
+}
+
+func isIntegerType(typid catalog.Oid) bool {
+	return typid == catalog.INT2OID || typid == catalog.INT4OID || typid == catalog.INT8OID
+}
+
+// negate は単項マイナスを評価する (int4um, int8um, float8um, numeric_uminus 相当)
+func negate(val adt.Datum, typid catalog.Oid) (adt.Datum, error) {
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case int16:
+		if v == math.MinInt16 {
+			return nil, &Error{Code: errcodes.NumericValueOutOfRange, Message: "smallint out of range"}
+		}
+		return -v, nil
+	case int32:
+		if v == math.MinInt32 {
+			return nil, &Error{Code: errcodes.NumericValueOutOfRange, Message: "integer out of range"}
+		}
+		return -v, nil
+	case int64:
+		if v == math.MinInt64 {
+			return nil, &Error{Code: errcodes.NumericValueOutOfRange, Message: "bigint out of range"}
+		}
+		return -v, nil
+	case float64:
+		return -v, nil
+	case string:
+		if typid == catalog.NUMERICOID {
+			if len(v) > 0 && v[0] == '-' {
+				return v[1:], nil
+			}
+			return adt.NumericIn("-" + v)
+		}
+	}
+	return nil, &Error{Code: errcodes.UndefinedFunction, Message: fmt.Sprintf("operator does not exist: - %s", catalog.FormatType(typid))}
+}
+
+// execEvalBoolExpr は AND、OR、NOT を3値論理で評価する (EEOP_BOOL_*_STEP 相当)。AND は偽の引数が
+// あれば、OR は真の引数があれば、残りの引数を評価せずに結果を決める。
+func execEvalBoolExpr(e *parser.BoolOpExpr, econtext *ExprContext) (adt.Datum, error) {
+	if e.Op == parser.NotExpr {
+		val, err := ExecEvalExpr(e.Args[0], econtext)
+		if val == nil || err != nil {
+			return nil, err
+		}
+		return !val.(bool), nil
+	}
+	// AND では偽、OR では真が結果を決める
+	decisive := e.Op == parser.OrExpr
+	sawNull := false
+	for _, arg := range e.Args {
+		val, err := ExecEvalExpr(arg, econtext)
+		if err != nil {
+			return nil, err
+		}
+		if val == nil {
+			sawNull = true
+			continue
+		}
+		if val.(bool) == decisive {
+			return decisive, nil
+		}
+	}
+	if sawNull {
+		return nil, nil
+	}
+	return !decisive, nil
+}
+
+// evalComparison は同じ型の2つの値を比較演算子で比べる (int4eq、texteq などの比較関数相当)。
+// いずれかが NULL なら NULL を返す。typid は左辺の型で、同じ Go の型で表す型を区別するために使う。
+func evalComparison(opname string, l, r adt.Datum, typid catalog.Oid) (adt.Datum, error) {
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	c, err := compareDatums(l, r, catalog.GetBaseType(typid))
+	if err != nil {
+		return nil, err
+	}
+	switch opname {
+	case "=":
+		return c == 0, nil
+	case "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case ">":
+		return c > 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return nil, &Error{Code: errcodes.UndefinedFunction, Message: fmt.Sprintf("operator does not exist: %s", opname)}
+}
+
+// compareDatums は2つの値を比べ、l が小さければ負、等しければ 0、大きければ正を返す
+// (btint4cmp、bttextcmp などの比較関数相当)。浮動小数点数の NaN は全ての値より大きい。
+// 文字列はバイト順で比べ、character では末尾の空白を無視する。
+func compareDatums(l, r adt.Datum, typid catalog.Oid) (int, error) {
+	switch a := l.(type) {
+	case bool:
+		b := r.(bool)
+		switch {
+		case a == b:
+			return 0, nil
+		case !a:
+			return -1, nil
+		}
+		return 1, nil
+	case int16:
+		return cmp.Compare(a, r.(int16)), nil
+	case int32:
+		return cmp.Compare(a, r.(int32)), nil
+	case int64:
+		return cmp.Compare(a, r.(int64)), nil
+	case catalog.Oid:
+		return cmp.Compare(a, r.(catalog.Oid)), nil
+	case byte:
+		return cmp.Compare(a, r.(byte)), nil
+	case float32:
+		return cmp.Compare(a, r.(float32)), nil
+	case float64:
+		return cmp.Compare(a, r.(float64)), nil
+	case string:
+		b := r.(string)
+		switch typid {
+		case catalog.NUMERICOID:
+			return adt.NumericCmp(a, b), nil
+		case catalog.BPCHAROID:
+			return strings.Compare(strings.TrimRight(a, " "), strings.TrimRight(b, " ")), nil
+		}
+		return strings.Compare(a, b), nil
+	case []byte:
+		return bytes.Compare(a, r.([]byte)), nil
+	case adt.UUID:
+		b := r.(adt.UUID)
+		return bytes.Compare(a[:], b[:]), nil
+	case adt.DateADT:
+		return cmp.Compare(a, r.(adt.DateADT)), nil
+	case adt.TimeADT:
+		return cmp.Compare(a, r.(adt.TimeADT)), nil
+	case adt.Timestamp:
+		return cmp.Compare(a, r.(adt.Timestamp)), nil
+	case adt.TimestampTz:
+		return cmp.Compare(a, r.(adt.TimestampTz)), nil
+	}
+	return 0, &Error{Code: errcodes.UndefinedFunction, Message: fmt.Sprintf("could not identify a comparison function for type %s", catalog.FormatType(typid))}
+}
+
+// DomainCheck は値がドメインの制約を満たすかを確かめる (ExecEvalConstraintNotNull、
+// ExecEvalConstraintCheck、domain_check 相当)。基になったドメインの制約も確かめる。NOT NULL を
+// 先に確かめ、CHECK 制約は基のドメインのものから、同じドメインの中では名前の順に確かめる。
+// CHECK 制約の式の結果が NULL の場合は満たすとみなす。
+func DomainCheck(val adt.Datum, domainType catalog.Oid, econtext *ExprContext) error {
+	var levels []catalog.Oid
+	notNull := false
+	for typid := domainType; ; {
+		typ, ok := catalog.SearchType(typid)
+		if !ok {
+			return fmt.Errorf("cache lookup failed for type %d", typid)
+		}
+		if typ.Typbasetype == catalog.InvalidOid {
+			break
+		}
+		levels = append(levels, typid)
+		notNull = notNull || typ.Typnotnull
+		typid = typ.Typbasetype
+	}
+	if notNull && val == nil {
+		return &Error{Code: errcodes.NotNullViolation, Message: fmt.Sprintf("domain %s does not allow null values", catalog.FormatType(domainType))}
+	}
+
+	ctx := *econtext
+	ctx.DomainValue = val
+	for i := len(levels) - 1; i >= 0; i-- {
+		cons := catalog.TypeConstraints(levels[i])
+		sort.Slice(cons, func(a, b int) bool { return cons[a].Conname < cons[b].Conname })
+		for _, con := range cons {
+			if con.Contype != catalog.ConstraintCheck {
+				continue
+			}
+			res, err := ExecEvalExpr(con.Conbin.(parser.Expr), &ctx)
+			if err != nil {
+				return err
+			}
+			if res == false {
+				return &Error{Code: errcodes.CheckViolation, Message: fmt.Sprintf("value for domain %s violates check constraint \"%s\"",
+					catalog.FormatType(domainType), con.Conname)}
+			}
+		}
+	}
+	return nil
+}
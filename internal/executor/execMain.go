@@ -0,0 +1,301 @@
+package executor
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/heap"
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/parser"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/buffer"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/fmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/pgstat"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/snapmgr"
+)
+
+// ----------------------------------------------------------------
+// 文の実行 (execMain.c, nodeResult.c 相当)
+// ----------------------------------------------------------------
+// 計画 (planner) の段階はまだ存在しないため、解析済みの Query を直接実行する。
+// 現時点で実行できるのは SELECT のみで、FROM 句にはシステムビューとテーブルを書ける。
+// FROM 句に複数のリレーションがある場合は、入れ子ループでそれらの直積を作る。
+
+// Attribute は結果の列の定義 (FormData_pg_attribute の一部相当)
+type Attribute struct {
+	Name   string
+	TypeID catalog.Oid
+	TypMod int32
+}
+
+// TupleDesc は結果の列の並び (TupleDesc 相当)
+type TupleDesc []Attribute
+
+// Result はユーティリティ文の実行結果。SELECT の結果行は DestReceiver に1行ずつ送る。
+type Result struct {
+	// Desc が nil の場合、その文は行を返さない
+	Desc       TupleDesc
+	Rows       [][]adt.Datum
+	CommandTag string
+}
+
+// ExecTypeFromTL は出力列のリストから結果の列定義を作る (ExecTypeFromTL 相当)
+func ExecTypeFromTL(tlist []*parser.TargetEntry) TupleDesc {
+	desc := make(TupleDesc, len(tlist))
+	for i, te := range tlist {
+		desc[i] = Attribute{Name: te.ResName, TypeID: te.Expr.ExprType(), TypMod: -1}
+	}
+	return desc
+}
+
+// QueryReturnsTuples は文が結果行を返すかどうかを返す。
+func QueryReturnsTuples(query *parser.Query) bool {
+	return query.CommandType == parser.CmdSelect
+}
+
+// DestReceiver は実行器が作った結果行の送り先 (DestReceiver 相当)
+type DestReceiver interface {
+	// ReceiveSlot は1行を受け取る (receiveSlot 相当)。エラーを返すと実行を中断する
+	ReceiveSlot(row []adt.Datum) error
+}
+
+// QueryDesc は実行する文とその実行環境 (QueryDesc 相当)
+type QueryDesc struct {
+	Query  *parser.Query
+	Params ParamListInfo
+	// Interrupts は実行中に確認する割り込み要求。nil の場合は確認しない。
+	Interrupts *miscadmin.Interrupts
+	// Caller は文を実行するセッション。関数の呼び出しに渡す
+	Caller fmgr.CallContext
+	// FuncStats は関数の呼び出しを数えるセッションの統計。nil の場合は数えない
+	FuncStats *pgstat.Pending
+	// Snapshot は文が行を読むスナップショット (snapshot 相当)。テーブルはこれから見える行だけを
+	// 返す。システムビューの行は関数の結果のため、Snapshot によらず全て返す
+	Snapshot *snapmgr.Snapshot
+	// CurrentXid は文を実行するトランザクションの ID。割り当てていなければ InvalidTransactionId
+	CurrentXid adt.TransactionId
+	// Buffers と LockProc はテーブルのページを読むセッションの共有バッファのピンと重いロック
+	Buffers  *buffer.Backend
+	LockProc *lmgr.Proc
+	// SyncScan は synchronize_seqscans の値で、大きいテーブルを他のスキャンと同期して読む
+	SyncScan bool
+	// Dest は結果行の送り先
+	Dest DestReceiver
+
+	// estate は ExecutorStart が作る実行状態
+	estate *executorState
+}
+
+// executorState は実行中の文の状態 (EState と PlanState 相当)。
+// 入れ子ループの各段で次に読む行の位置を持ち、ExecutorRun を呼ぶたびに続きから実行する。
+type executorState struct {
+	econtext  *ExprContext
+	relations [][][]adt.Datum
+	// pos は各リレーションで今読んでいる行の位置
+	pos     []int
+	started bool
+	done    bool
+	// processed はこれまでに送った行の数 (es_processed 相当)
+	processed uint64
+}
+
+// ExecutorStart は文の実行を準備する (ExecutorStart 相当)。
+// システムビューの行は関数の呼び出しで一度に作られるため、ここで全て読んでおく。テーブルも
+// 直積を作るために何度も読み直すため、同じくここでスナップショットから見える行を全て読む。
+func ExecutorStart(qd *QueryDesc) error {
+	query := qd.Query
+	if query.CommandType != parser.CmdSelect {
+		return fmt.Errorf("unrecognized command type: %d", query.CommandType)
+	}
+
+	econtext := &ExprContext{Params: qd.Params, Caller: qd.Caller, FuncStats: qd.FuncStats}
+	relations := make([][][]adt.Datum, len(query.RangeTable))
+	for i, rte := range query.RangeTable {
+		var rows [][]adt.Datum
+		var err error
+		if rte.Relation != nil {
+			rows, err = execRelationScan(qd, rte)
+		} else {
+			rows, err = execSystemViewScan(rte.View, econtext)
+		}
+		if err != nil {
+			return err
+		}
+		relations[i] = rows
+	}
+	econtext.ScanTuples = make([][]adt.Datum, len(relations))
+	qd.estate = &executorState{econtext: econtext, relations: relations, pos: make([]int, len(relations))}
+	return nil
+}
+
+// ExecutorRun は最大 count 行を作って Dest に送る (ExecutorRun 相当)。count が 0 の場合は
+// 全ての行を送る。行は1行ずつ送るため、結果の全体をメモリに持つことはない。
+func ExecutorRun(qd *QueryDesc, count uint64) error {
+	es := qd.estate
+	for n := uint64(0); count == 0 || n < count; n++ {
+		if !es.fetchNext() {
+			return nil
+		}
+		row, err := execProject(qd.Query.TargetList, es.econtext, qd.Interrupts)
+		if err != nil {
+			return err
+		}
+		if err := qd.Dest.ReceiveSlot(row); err != nil {
+			return err
+		}
+		es.processed++
+	}
+	return nil
+}
+
+// Done は全ての行を送り終えたかどうかを返す
+func (qd *QueryDesc) Done() bool {
+	return qd.estate.done
+}
+
+// Processed はこれまでに送った行の数を返す
+func (qd *QueryDesc) Processed() uint64 {
+	return qd.estate.processed
+}
+
+// fetchNext は入れ子ループを次の行の組み合わせに進め、ScanTuples に設定する
+// (ExecNestLoop 相当)。全ての組み合わせを返し終えていれば false を返す。
+func (es *executorState) fetchNext() bool {
+	if es.done {
+		return false
+	}
+	if !es.started {
+		es.started = true
+		for _, rel := range es.relations {
+			if len(rel) == 0 {
+				es.done = true
+				return false
+			}
+		}
+	} else {
+		// 最も内側のループから進め、行を読み終えたら外側のループに繰り上げる
+		level := len(es.relations) - 1
+		for ; level >= 0; level-- {
+			es.pos[level]++
+			if es.pos[level] < len(es.relations[level]) {
+				break
+			}
+			es.pos[level] = 0
+		}
+		if level < 0 {
+			es.done = true
+			return false
+		}
+	}
+	for i, rel := range es.relations {
+		es.econtext.ScanTuples[i] = rel[es.pos[i]]
+	}
+	return true
+}
+
+// execProject は出力列を評価して1行を作る (ExecProject 相当)
+func execProject(tlist []*parser.TargetEntry, econtext *ExprContext, interrupts *miscadmin.Interrupts) ([]adt.Datum, error) {
+	row := make([]adt.Datum, len(tlist))
+	for i, te := range tlist {
+		if err := interrupts.CheckForInterrupts(); err != nil {
+			return nil, err
+		}
+		val, err := ExecEvalExpr(te.Expr, econtext)
+		if err != nil {
+			return nil, err
+		}
+		row[i] = val
+	}
+	return row, nil
+}
+
+// execRelationScan は範囲テーブルのテーブルの行を返す (expand_inherited_rtentry と ExecAppend
+// 相当)。ONLY を書かなければ、継承した子孫のテーブルの行も続けて返す。子の行は、親の列と
+// 同じ名前の列を親の列の順に並べ直す (make_inh_translation_list 相当)。テーブルのロックは
+// AccessShareLock で取ってから読み、トランザクションの終わりまで持つ。
+func execRelationScan(qd *QueryDesc, rte *parser.RangeTblEntry) ([][]adt.Datum, error) {
+	parent := rte.Relation
+	// ロックを待つ間に削除されていれば読めない (relation_open 相当)
+	if _, err := qd.LockProc.LockAcquire(RelationLockTag(parent), lmgr.AccessShareLock, false, false); err != nil {
+		return nil, err
+	}
+	if _, ok := catalog.SearchRelation(parent.Oid); !ok {
+		return nil, fmt.Errorf("could not open relation with OID %d", parent.Oid)
+	}
+	rows, err := execSeqScan(qd, parent)
+	if err != nil || !rte.Inh {
+		return rows, err
+	}
+	for _, childOid := range catalog.FindAllInheritors(parent.Oid)[1:] {
+		child, ok := catalog.SearchRelation(childOid)
+		if !ok {
+			continue
+		}
+		if _, err := qd.LockProc.LockAcquire(RelationLockTag(child), lmgr.AccessShareLock, false, false); err != nil {
+			return nil, err
+		}
+		// ロックを待つ間に削除された子は読まない
+		if child, ok = catalog.SearchRelation(childOid); !ok {
+			continue
+		}
+		colmap := make([]int, len(parent.Attrs))
+		for i, attr := range parent.Attrs {
+			colmap[i] = slices.IndexFunc(child.Attrs, func(a catalog.FormPgAttribute) bool { return a.Attname == attr.Attname })
+			if colmap[i] < 0 {
+				return nil, fmt.Errorf("could not find inherited attribute \"%s\" of relation \"%s\"", attr.Attname, child.Relname)
+			}
+		}
+		childRows, err := execSeqScan(qd, child)
+		if err != nil {
+			return nil, err
+		}
+		for _, childRow := range childRows {
+			row := make([]adt.Datum, len(colmap))
+			for i, j := range colmap {
+				row[i] = childRow[j]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// execSeqScan はテーブルのうちスナップショットから見える全ての行を返す (ExecSeqScan 相当)。
+// テーブルのロックは呼び出し元が取る。
+func execSeqScan(qd *QueryDesc, relation *catalog.FormPgClass) ([][]adt.Datum, error) {
+	desc := RelationTupleDesc(relation)
+	rel := heap.Open(qd.Buffers, qd.LockProc, relation.Oid, RelationLocator(relation), desc)
+	scan, err := rel.BeginScan(qd.Snapshot, qd.CurrentXid, qd.SyncScan)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]adt.Datum
+	for {
+		if err := qd.Interrupts.CheckForInterrupts(); err != nil {
+			return nil, err
+		}
+		tup, err := scan.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tup == nil {
+			return rows, nil
+		}
+		row, err := deformHeapTuple(relation, desc, tup)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// execSystemViewScan はシステムビューの全ての行を返す (ExecFunctionScan 相当)
+func execSystemViewScan(view *catalog.SystemView, econtext *ExprContext) ([][]adt.Datum, error) {
+	fn, ok := fmgr.LookupSetReturning(view.Prosrc)
+	if !ok {
+		return nil, fmt.Errorf("internal function \"%s\" is not in internal lookup table", view.Prosrc)
+	}
+	return fn(&fmgr.FunctionCallInfo{Context: econtext.Caller})
+}
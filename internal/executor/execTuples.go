@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/access/heap"
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// テーブルの行とヒープのタプルの変換 (execTuples.c, heaptuple.c の heap_form_tuple と
+// heap_deform_tuple 相当)
+// ----------------------------------------------------------------
+// 実行器は行を Go の値 (adt.Datum) の並びで扱い、ヒープは列の値をバイト列で持つ。C言語版の
+// Datum は型ごとの内部表現をそのまま持つが、Go言語版の値はポインタを含むため、ページに置くときは
+// 型のバイナリ表現 (送信関数の出力) にする。バイナリ表現の長さは型によって決まらないため、
+// 全ての列を可変長 (attlen = -1) の列として置く。
+
+// RelationLocator はテーブルのファイルを返す (RelationData の rd_locator 相当)
+func RelationLocator(rel *catalog.FormPgClass) storage.RelFileLocator {
+	return storage.RelFileLocator{DbOid: rel.Reldatabase, RelNumber: rel.Relfilenode}
+}
+
+// RelationLockTag はテーブルのロックの対象を返す (SET_LOCKTAG_RELATION 相当)
+func RelationLockTag(rel *catalog.FormPgClass) lmgr.LockTag {
+	return lmgr.LockTag{Field1: uint32(rel.Reldatabase), Field2: uint32(rel.Oid), Type: lmgr.LockTagRelation}
+}
+
+// RelationTupleDesc はテーブルのタプルの列の並びを返す (RelationGetDescr 相当)
+func RelationTupleDesc(rel *catalog.FormPgClass) heap.TupleDesc {
+	desc := make(heap.TupleDesc, len(rel.Attrs))
+	for i := range desc {
+		desc[i] = heap.Attribute{Len: -1, Align: 'i'}
+	}
+	return desc
+}
+
+// FormHeapTuple は行の値からテーブルのタプルを作る (ExecFetchSlotHeapTuple と heap_form_tuple 相当)
+func FormHeapTuple(rel *catalog.FormPgClass, desc heap.TupleDesc, row []adt.Datum) (*heap.HeapTuple, error) {
+	values := make([][]byte, len(row))
+	isnull := make([]bool, len(row))
+	for i, d := range row {
+		if d == nil {
+			isnull[i] = true
+			continue
+		}
+		v, err := adt.SendFunctionCall(rel.Attrs[i].Atttypid, d)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return heap.FormTuple(desc, values, isnull)
+}
+
+// deformHeapTuple はテーブルのタプルを行の値に戻す (slot_getallattrs と heap_deform_tuple 相当)
+func deformHeapTuple(rel *catalog.FormPgClass, desc heap.TupleDesc, tup *heap.HeapTuple) ([]adt.Datum, error) {
+	values, isnull, err := heap.DeformTuple(desc, tup)
+	if err != nil {
+		return nil, err
+	}
+	row := make([]adt.Datum, len(rel.Attrs))
+	for i := range row {
+		// タプルの列が定義より少なければ、残りの列は NULL とみなす (getmissingattr 相当)
+		if i >= len(values) || isnull[i] {
+			continue
+		}
+		if row[i], err = adt.ReceiveFunctionCall(rel.Attrs[i].Atttypid, values[i]); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
@@ -0,0 +1,67 @@
+package feutils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+)
+
+// ----------------------------------------------------------------
+// クライアントのコマンドの接続 (fe_utils/connect_utils.c 相当)
+// ----------------------------------------------------------------
+// createdb や vacuumdb などのコマンドは、-h, -p, -U と接続するデータベースから接続文字列を作って
+// サーバーに接続する。データベースを作ったり消したりするコマンドは、対象とは別の管理用の
+// データベース (--maintenance-db) に接続する。指定がなければ postgres に、接続できなければ
+// template1 に接続する。
+
+// ConnParams は接続先 (ConnParams 相当)
+type ConnParams struct {
+	// DBName は接続するデータベース。空なら接続先の既定のデータベース
+	DBName string
+	Host   string
+	Port   string
+	// Username は接続するユーザー。空なら接続先の既定のユーザー
+	Username string
+}
+
+// ConnectDatabase は cparams の接続先に接続する (connectDatabase 相当)。サーバーからの
+// NOTICE などはそのまま標準エラー出力に書く。
+func ConnectDatabase(cparams ConnParams) (*libpq.Conn, error) {
+	var conninfo []string
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	for _, kv := range [][2]string{{"dbname", cparams.DBName}, {"host", cparams.Host}, {"port", cparams.Port}, {"user", cparams.Username}} {
+		if kv[1] != "" {
+			conninfo = append(conninfo, kv[0]+"='"+quote.Replace(kv[1])+"'")
+		}
+	}
+	conn, err := libpq.Connect(strings.Join(conninfo, " "))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetNoticeReceiver(func(r *libpq.Result) {
+		fmt.Fprint(os.Stderr, r.Err().BuildMessage(""))
+	})
+	return conn, nil
+}
+
+// ConnectMaintenanceDatabase は管理用のデータベースに接続する (connectMaintenanceDatabase
+// 相当)。maintenanceDB が空なら postgres に、接続できなければ template1 に接続する。どちらにも
+// 接続できなければ postgres への接続のエラーを返す。
+func ConnectMaintenanceDatabase(cparams ConnParams, maintenanceDB string) (*libpq.Conn, error) {
+	if maintenanceDB != "" {
+		cparams.DBName = maintenanceDB
+		return ConnectDatabase(cparams)
+	}
+	cparams.DBName = "postgres"
+	conn, err := ConnectDatabase(cparams)
+	if err == nil {
+		return conn, nil
+	}
+	cparams.DBName = "template1"
+	if conn, err1 := ConnectDatabase(cparams); err1 == nil {
+		return conn, nil
+	}
+	return nil, err
+}
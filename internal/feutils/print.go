@@ -0,0 +1,114 @@
+package feutils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+)
+
+// ----------------------------------------------------------------
+// 問い合わせ結果の表示 (fe_utils/print.c 相当)
+// ----------------------------------------------------------------
+// psql の既定の表示形式 (aligned, border 1) だけを実装する。
+//
+//	 a | b
+//	---+-----
+//	 1 | foo
+//	(1 row)
+//
+// 見出しは中央に寄せ、数値型の列は右寄せ、それ以外は左寄せにする。
+// 左寄せの最後の列は行末に空白を足さない。値の中の改行の折り返しはまだ扱わない。
+
+// PrintQueryOpt は表示方法 (printQueryOpt 相当)
+type PrintQueryOpt struct {
+	// NullPrint は NULL の代わりに表示する文字列 (\pset null 相当)
+	NullPrint string
+	// TuplesOnly が true なら見出しと行数を表示しない (\pset tuples_only 相当)
+	TuplesOnly bool
+}
+
+// columnAlignRight は型が右寄せで表示されるかを返す (column_type_alignment 相当)
+func columnAlignRight(typid catalog.Oid) bool {
+	switch typid {
+	case catalog.INT2OID, catalog.INT4OID, catalog.INT8OID, catalog.FLOAT8OID, catalog.NUMERICOID:
+		return true
+	}
+	return false
+}
+
+// PrintQuery は結果を表の形で書き出す (printQuery 相当)
+func PrintQuery(w io.Writer, res *libpq.Result, opt PrintQueryOpt) {
+	ncols := res.NFields()
+	cells := make([][]string, res.NTuples())
+	width := make([]int, ncols)
+	right := make([]bool, ncols)
+	for j, f := range res.Fields {
+		width[j] = utf8.RuneCountInString(f.Name)
+		right[j] = columnAlignRight(f.TypeID)
+	}
+	for i := range cells {
+		cells[i] = make([]string, ncols)
+		for j := range cells[i] {
+			v := res.GetValue(i, j)
+			if res.GetIsNull(i, j) {
+				v = opt.NullPrint
+			}
+			cells[i][j] = v
+			width[j] = max(width[j], utf8.RuneCountInString(v))
+		}
+	}
+
+	var b strings.Builder
+	if !opt.TuplesOnly {
+		// 見出し
+		for j, f := range res.Fields {
+			if j > 0 {
+				b.WriteString("|")
+			}
+			space := width[j] - utf8.RuneCountInString(f.Name)
+			fmt.Fprintf(&b, " %s%s%s ", strings.Repeat(" ", space/2), f.Name, strings.Repeat(" ", (space+1)/2))
+		}
+		b.WriteByte('\n')
+		// 区切り線
+		for j := range width {
+			if j > 0 {
+				b.WriteString("+")
+			}
+			b.WriteString(strings.Repeat("-", width[j]+2))
+		}
+		b.WriteByte('\n')
+	}
+
+	for _, row := range cells {
+		for j, v := range row {
+			if j > 0 {
+				b.WriteString(" |")
+			}
+			b.WriteByte(' ')
+			pad := strings.Repeat(" ", width[j]-utf8.RuneCountInString(v))
+			switch {
+			case right[j]:
+				b.WriteString(pad + v)
+			case j < ncols-1:
+				b.WriteString(v + pad)
+			default:
+				b.WriteString(v)
+			}
+		}
+		b.WriteByte('\n')
+	}
+
+	if !opt.TuplesOnly {
+		if res.NTuples() == 1 {
+			b.WriteString("(1 row)\n")
+		} else {
+			fmt.Fprintf(&b, "(%d rows)\n", res.NTuples())
+		}
+	}
+	b.WriteByte('\n')
+	io.WriteString(w, b.String())
+}
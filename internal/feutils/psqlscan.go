@@ -0,0 +1,201 @@
+package feutils
+
+import "strings"
+
+// ----------------------------------------------------------------
+// psql の入力の字句解析 (fe_utils/psqlscan.l 相当)
+// ----------------------------------------------------------------
+// 入力を行ごとに受け取り、文の終わりの ";" とバックスラッシュコマンドを見つける。
+// 引用符 ('...', "...", $tag$...$tag$) とコメント (--, /* */) の中の
+// ";" や "\" は区切りとみなさない。括弧の中の ";" も文の終わりではない。
+// 文がまだ始まっていない間の空白と "--" コメントは捨てる。
+
+// ScanKind は Scan が見つけたものの種類
+type ScanKind int
+
+const (
+	// ScanStatement は ";" で終わった文
+	ScanStatement ScanKind = iota
+	// ScanBackslash はバックスラッシュコマンド (先頭の "\" を除いた残り)
+	ScanBackslash
+)
+
+// ScanItem は Scan が見つけた文またはバックスラッシュコマンド
+type ScanItem struct {
+	Kind ScanKind
+	Text string
+}
+
+type scanState int
+
+const (
+	stateInitial scanState = iota
+	stateSingleQuote
+	stateExtendedQuote // E'...' (バックスラッシュによるエスケープがある)
+	stateDoubleQuote
+	stateDollarQuote
+	stateBlockComment
+)
+
+// PsqlScanState は行をまたいで保持する字句解析の状態 (PsqlScanStateData 相当)
+type PsqlScanState struct {
+	buf          strings.Builder
+	state        scanState
+	dollarTag    string
+	commentDepth int
+	parenDepth   int
+}
+
+// Scan は1行 (改行を含まない) を読み、完成した文とバックスラッシュコマンドを順に返す。
+// 完成していない文は次の行に持ち越す。
+func (s *PsqlScanState) Scan(line string) []ScanItem {
+	var items []ScanItem
+	emit := func(i int) { s.buf.WriteByte(line[i]) }
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch s.state {
+		case stateSingleQuote, stateExtendedQuote:
+			emit(i)
+			if c == '\\' && s.state == stateExtendedQuote && i+1 < len(line) {
+				i++
+				emit(i)
+			} else if c == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					emit(i)
+				} else {
+					s.state = stateInitial
+				}
+			}
+			continue
+		case stateDoubleQuote:
+			emit(i)
+			if c == '"' {
+				s.state = stateInitial
+			}
+			continue
+		case stateDollarQuote:
+			if strings.HasPrefix(line[i:], s.dollarTag) {
+				s.buf.WriteString(s.dollarTag)
+				i += len(s.dollarTag) - 1
+				s.state = stateInitial
+			} else {
+				emit(i)
+			}
+			continue
+		case stateBlockComment:
+			switch {
+			case strings.HasPrefix(line[i:], "/*"):
+				s.buf.WriteString("/*")
+				s.commentDepth++
+				i++
+			case strings.HasPrefix(line[i:], "*/"):
+				s.buf.WriteString("*/")
+				i++
+				if s.commentDepth--; s.commentDepth == 0 {
+					s.state = stateInitial
+				}
+			default:
+				emit(i)
+			}
+			continue
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			if s.buf.Len() > 0 {
+				emit(i)
+			}
+		case strings.HasPrefix(line[i:], "--"):
+			if s.buf.Len() > 0 {
+				s.buf.WriteString(line[i:])
+			}
+			i = len(line)
+		case strings.HasPrefix(line[i:], "/*"):
+			s.buf.WriteString("/*")
+			s.state = stateBlockComment
+			s.commentDepth = 1
+			i++
+		case c == '\'':
+			// 直前が E (単独の識別子) なら E'...' の文字列
+			if i > 0 && (line[i-1] == 'E' || line[i-1] == 'e') && (i < 2 || !isIdentChar(line[i-2])) {
+				s.state = stateExtendedQuote
+			} else {
+				s.state = stateSingleQuote
+			}
+			emit(i)
+		case c == '"':
+			s.state = stateDoubleQuote
+			emit(i)
+		case c == '$' && (i == 0 || !isIdentChar(line[i-1])):
+			if tag, ok := dollarQuoteTag(line[i:]); ok {
+				s.buf.WriteString(tag)
+				i += len(tag) - 1
+				s.state = stateDollarQuote
+				s.dollarTag = tag
+			} else {
+				emit(i)
+			}
+		case c == '(':
+			s.parenDepth++
+			emit(i)
+		case c == ')':
+			if s.parenDepth > 0 {
+				s.parenDepth--
+			}
+			emit(i)
+		case c == ';':
+			emit(i)
+			if s.parenDepth == 0 {
+				items = append(items, ScanItem{Kind: ScanStatement, Text: s.buf.String()})
+				s.buf.Reset()
+			}
+		case c == '\\':
+			// 行の残りがバックスラッシュコマンド。"\\" で区切って同じ行に文を続けることもできる
+			rest := line[i+1:]
+			if end := strings.Index(rest, `\\`); end >= 0 {
+				items = append(items, ScanItem{Kind: ScanBackslash, Text: strings.TrimSpace(rest[:end])})
+				i += end + 2
+			} else {
+				items = append(items, ScanItem{Kind: ScanBackslash, Text: strings.TrimSpace(rest)})
+				i = len(line)
+			}
+		default:
+			emit(i)
+		}
+	}
+	// 行の終わりは空白として扱う
+	if s.buf.Len() > 0 {
+		s.buf.WriteByte('\n')
+	}
+	return items
+}
+
+// Pending は完成していない文を返す。空白しか残っていなければ空文字列を返す。
+func (s *PsqlScanState) Pending() string {
+	return strings.TrimRight(s.buf.String(), "\n")
+}
+
+// Reset は完成していない文を捨てる (psql_scan_reset 相当)
+func (s *PsqlScanState) Reset() {
+	*s = PsqlScanState{}
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// dollarQuoteTag は s の先頭がドル引用符の開始 ($$ または $tag$) ならその文字列を返す。
+func dollarQuoteTag(s string) (string, bool) {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1], true
+		}
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80 || i > 1 && c >= '0' && c <= '9') {
+			return "", false
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,30 @@
+package feutils
+
+import (
+	"fmt"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+)
+
+// ----------------------------------------------------------------
+// クライアントのコマンドの問い合わせ (fe_utils/query_utils.c 相当)
+// ----------------------------------------------------------------
+
+// ExecuteCommand は行を返さない文 query を送る (executeMaintenanceCommand 相当)。echo が true
+// なら送る前に標準出力に書く。失敗すればサーバーのエラーを返す。
+func ExecuteCommand(conn *libpq.Conn, query string, echo bool) error {
+	if echo {
+		fmt.Println(query)
+	}
+	res, err := conn.Exec(query)
+	if err != nil {
+		return err
+	}
+	if res.Status != libpq.CommandOK {
+		if e := res.Err(); e != nil {
+			return e
+		}
+		return fmt.Errorf("unexpected result status for \"%s\"", query)
+	}
+	return nil
+}
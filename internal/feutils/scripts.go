@@ -0,0 +1,38 @@
+package feutils
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+)
+
+// ----------------------------------------------------------------
+// クライアントのコマンドの共通処理 (bin/scripts/common.c, common/username.c 相当)
+// ----------------------------------------------------------------
+
+// YesNoPrompt は question を表示して y か n の答えを読み、y なら true を返す (yesno_prompt 相当)。
+// どちらでもなければ聞き直す。
+func YesNoPrompt(question string) bool {
+	for {
+		switch SimplePrompt(question+" (y/n) ", true) {
+		case "y":
+			return true
+		case "n":
+			return false
+		}
+		fmt.Printf("Please answer \"%s\" or \"%s\".\n", "y", "n")
+	}
+}
+
+// DefaultUserName は接続するユーザーの既定値を返す。PGUSER があればそれを、なければ
+// 実行しているユーザーの名前を返す (get_user_name 相当)。
+func DefaultUserName() (string, error) {
+	if name := os.Getenv("PGUSER"); name != "" {
+		return name, nil
+	}
+	u, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("could not look up effective user ID %d: %s", os.Geteuid(), err)
+	}
+	return u.Username, nil
+}
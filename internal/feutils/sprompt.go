@@ -0,0 +1,47 @@
+package feutils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+)
+
+// ----------------------------------------------------------------
+// 端末からの入力 (port/sprompt.c 相当)
+// ----------------------------------------------------------------
+
+// SimplePrompt は prompt を表示して1行読み、末尾の改行を除いて返す (simple_prompt 相当)。
+// 端末 (/dev/tty) があればそこから読み、なければ標準入力から読む。echo が false の場合は
+// 入力を画面に表示しない。
+func SimplePrompt(prompt string, echo bool) string {
+	in, out := os.Stdin, os.Stderr
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		in, out = tty, tty
+	}
+
+	fmt.Fprint(out, prompt)
+	if !echo {
+		if restore, err := platform.DisableEcho(int(in.Fd())); err == nil {
+			defer func() {
+				restore()
+				// 入力した改行は表示されないため、代わりに改行する
+				fmt.Fprintln(out)
+			}()
+		}
+	}
+
+	// 標準入力から読む場合に後続の入力を読み過ぎないよう、1バイトずつ読む
+	var line strings.Builder
+	var b [1]byte
+	for {
+		n, err := in.Read(b[:])
+		if n == 0 || err != nil || b[0] == '\n' {
+			break
+		}
+		line.WriteByte(b[0])
+	}
+	return strings.TrimRight(line.String(), "\r")
+}
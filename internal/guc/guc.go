@@ -0,0 +1,1151 @@
+package guc
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 設定パラメータ (utils/misc/guc.c 相当)
+// ----------------------------------------------------------------
+// パラメータの定義は guc_tables.go にまとめる。パラメータには真偽値、整数、実数、文字列、
+// 列挙の5種類があり、それぞれ既定値と値の範囲または選択肢、変更できる時期 (Context) を持つ。
+//
+// C言語版ではバックエンドごとにプロセスが分かれるため、パラメータの値はプロセスの大域変数に
+// 持つ。Go言語版ではバックエンドが同じプロセスのゴルーチンとして動くため、値を2段で持つ。
+// コマンドラインなどで設定したサーバー全体の値は定義の側に持ち、各パッケージは Get で読む。
+// セッションで変更した値は Session に持ち、変更していないパラメータはサーバー全体の値を参照する。
+
+// Context はパラメータを変更できる時期 (GucContext 相当)。後のものほど変更しやすい。
+type Context int
+
+const (
+	// PGCInternal は変更できない。サーバーが内部で値を決める
+	PGCInternal Context = iota
+	// PGCPostmaster はサーバーの起動時にだけ設定できる
+	PGCPostmaster
+	// PGCSighup は起動時と、設定を読み直すときに設定できる
+	PGCSighup
+	// PGCSuBackend は PGCSighup に加え、スーパーユーザーが接続の開始時に設定できる
+	PGCSuBackend
+	// PGCBackend は PGCSighup に加え、接続の開始時に設定できる
+	PGCBackend
+	// PGCSuset はスーパーユーザーがいつでも変更できる
+	PGCSuset
+	// PGCUserset は誰でもいつでも変更できる
+	PGCUserset
+)
+
+// contextNames は pg_settings の context 列に表示する名前 (GucContext_Names 相当)
+var contextNames = [...]string{
+	PGCInternal:   "internal",
+	PGCPostmaster: "postmaster",
+	PGCSighup:     "sighup",
+	PGCSuBackend:  "superuser-backend",
+	PGCBackend:    "backend",
+	PGCSuset:      "superuser",
+	PGCUserset:    "user",
+}
+
+func (c Context) String() string { return contextNames[c] }
+
+// Source は値の出どころ (GucSource 相当)。後のものほど優先し、先のものでは上書きしない。
+type Source int
+
+const (
+	PGCSDefault      Source = iota // 既定値
+	PGCSFile                       // 設定ファイル
+	PGCSArgv                       // postmaster のコマンドライン
+	PGCSGlobal                     // 全てのロールと全てのデータベースへの設定 (ALTER ROLE ALL SET)
+	PGCSDatabase                   // データベースごとの設定 (ALTER DATABASE SET)
+	PGCSUser                       // ロールごとの設定 (ALTER ROLE SET)
+	PGCSDatabaseUser               // データベースの中のロールごとの設定 (ALTER ROLE IN DATABASE SET)
+	PGCSClient                     // スタートアップパケット
+	PGCSOverride                   // サーバーが内部で上書きした値
+	PGCSSession                    // SET
+)
+
+// sourceNames は pg_settings の source 列に表示する名前 (GucSource_Names 相当)
+var sourceNames = [...]string{
+	PGCSDefault:      "default",
+	PGCSFile:         "configuration file",
+	PGCSArgv:         "command line",
+	PGCSGlobal:       "global",
+	PGCSDatabase:     "database",
+	PGCSUser:         "user",
+	PGCSDatabaseUser: "database user",
+	PGCSClient:       "client",
+	PGCSOverride:     "override",
+	PGCSSession:      "session",
+}
+
+func (s Source) String() string { return sourceNames[s] }
+
+// VarType はパラメータの値の種類 (config_type 相当)
+type VarType int
+
+const (
+	PGCBool VarType = iota
+	PGCInt
+	PGCReal
+	PGCString
+	PGCEnum
+)
+
+// typeNames は pg_settings の vartype 列に表示する名前 (config_type_names 相当)
+var typeNames = [...]string{
+	PGCBool:   "bool",
+	PGCInt:    "integer",
+	PGCReal:   "real",
+	PGCString: "string",
+	PGCEnum:   "enum",
+}
+
+func (t VarType) String() string { return typeNames[t] }
+
+// ConfigGroup はパラメータの分類 (config_group 相当)
+type ConfigGroup int
+
+const (
+	Ungrouped ConfigGroup = iota
+	FileLocations
+	ConnAuthSettings
+	ConnAuthTCP
+	ConnAuthAuth
+	ConnAuthSSL
+	ResourcesMem
+	ResourcesDisk
+	ResourcesKernel
+	ResourcesVacuumDelay
+	ResourcesBgWriter
+	ResourcesAsynchronous
+	WalSettings
+	WalCheckpoints
+	WalArchiving
+	ErrorHandlingOptions
+	LoggingWhere
+	LoggingWhen
+	LoggingWhat
+	StatsMonitoring
+	StatsCumulative
+	Autovacuum
+	ClientConnStatement
+	ClientConnLocale
+	LockManagement
+	CompatOptionsPrevious
+	PresetOptions
+)
+
+// configGroupNames は pg_settings の category 列に表示する名前 (config_group_names 相当)
+var configGroupNames = [...]string{
+	Ungrouped:             "Ungrouped",
+	FileLocations:         "File Locations",
+	ConnAuthSettings:      "Connections and Authentication / Connection Settings",
+	ConnAuthTCP:           "Connections and Authentication / TCP Settings",
+	ConnAuthAuth:          "Connections and Authentication / Authentication",
+	ConnAuthSSL:           "Connections and Authentication / SSL",
+	ResourcesMem:          "Resource Usage / Memory",
+	ResourcesDisk:         "Resource Usage / Disk",
+	ResourcesKernel:       "Resource Usage / Kernel Resources",
+	ResourcesVacuumDelay:  "Resource Usage / Cost-Based Vacuum Delay",
+	ResourcesBgWriter:     "Resource Usage / Background Writer",
+	ResourcesAsynchronous: "Resource Usage / Asynchronous Behavior",
+	WalSettings:           "Write-Ahead Log / Settings",
+	WalCheckpoints:        "Write-Ahead Log / Checkpoints",
+	WalArchiving:          "Write-Ahead Log / Archiving",
+	ErrorHandlingOptions:  "Error Handling",
+	LoggingWhere:          "Reporting and Logging / Where to Log",
+	LoggingWhen:           "Reporting and Logging / When to Log",
+	LoggingWhat:           "Reporting and Logging / What to Log",
+	StatsMonitoring:       "Statistics / Monitoring",
+	StatsCumulative:       "Statistics / Cumulative Query and Index Statistics",
+	Autovacuum:            "Autovacuum",
+	ClientConnStatement:   "Client Connection Defaults / Statement Behavior",
+	ClientConnLocale:      "Client Connection Defaults / Locale and Formatting",
+	LockManagement:        "Lock Management",
+	CompatOptionsPrevious: "Version and Platform Compatibility / Previous PostgreSQL Versions",
+	PresetOptions:         "Preset Options",
+}
+
+func (g ConfigGroup) String() string { return configGroupNames[g] }
+
+// パラメータの属性 (GUC_* 相当)
+const (
+	// GucReport は値が変わったら ParameterStatus でクライアントに通知する (GUC_REPORT 相当)
+	GucReport = 1 << iota
+	// GucNoResetAll は RESET ALL の対象にしない (GUC_NO_RESET_ALL 相当)
+	GucNoResetAll
+	// GucNoShowAll は SHOW ALL と pg_settings に表示しない (GUC_NO_SHOW_ALL 相当)
+	GucNoShowAll
+	// GucNotInSample は postgresql.conf の見本に含めない (GUC_NOT_IN_SAMPLE 相当)
+	GucNotInSample
+	// GucDisallowInFile は設定ファイルで設定できない (GUC_DISALLOW_IN_FILE 相当)
+	GucDisallowInFile
+	// GucDisallowInAutoFile は ALTER SYSTEM で設定できない (GUC_DISALLOW_IN_AUTO_FILE 相当)
+	GucDisallowInAutoFile
+	// GucSuperuserOnly は pg_read_all_settings の権限を持つロールだけが値を参照できる (GUC_SUPERUSER_ONLY 相当)
+	GucSuperuserOnly
+	// GucUnitByte などは整数と実数の値の単位
+	// (GUC_UNIT_BYTE, GUC_UNIT_KB, GUC_UNIT_BLOCKS, GUC_UNIT_XBLOCKS, GUC_UNIT_MS, GUC_UNIT_S, GUC_UNIT_MIN 相当)
+	GucUnitByte
+	GucUnitKB
+	GucUnitBlocks
+	GucUnitXBlocks
+	GucUnitMS
+	GucUnitS
+	GucUnitMin
+
+	gucUnitMemory = GucUnitByte | GucUnitKB | GucUnitBlocks | GucUnitXBlocks
+	gucUnitTime   = GucUnitMS | GucUnitS | GucUnitMin
+	gucUnit       = gucUnitMemory | gucUnitTime
+)
+
+// Error は SQLSTATE 付きのエラー。バックエンドはクライアントにそのまま報告する。
+type Error struct {
+	Code    string
+	Message string
+	Detail  string
+	Hint    string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func newError(code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ConfigGeneric は全ての種類のパラメータに共通の定義とサーバー全体の値 (config_generic 相当)
+type ConfigGeneric struct {
+	Name      string
+	Context   Context
+	Group     ConfigGroup
+	ShortDesc string
+	LongDesc  string
+	Flags     int
+
+	typ VarType
+	// value と source はサーバー全体の値と、その出どころ。mu で保護する
+	value  any
+	source Source
+	// sourcefile と sourceline は設定ファイルで設定した値の場所。mu で保護する
+	sourcefile string
+	sourceline int
+	// pendingRestart は設定ファイルの値がサーバーの再起動を待っていることを表す
+	// (GUC_PENDING_RESTART 相当)。mu で保護する
+	pendingRestart bool
+}
+
+// configVar は各種類のパラメータの定義が実装する
+type configVar interface {
+	generic() *ConfigGeneric
+	vartype() VarType
+	bootValue() any
+	// parse は値のテキスト表現を検査し、値にする (parse_and_validate_value 相当)
+	parse(value string) (any, error)
+	// show は値の表示用のテキスト表現を返す (ShowGUCOption 相当)
+	show(v any) string
+}
+
+func (g *ConfigGeneric) generic() *ConfigGeneric { return g }
+
+// VarType は値の種類を返す
+func (g *ConfigGeneric) VarType() VarType { return g.typ }
+
+// get はサーバー全体の値を返す
+func (g *ConfigGeneric) get() any {
+	mu.RLock()
+	defer mu.RUnlock()
+	return g.value
+}
+
+// ConfigBool は真偽値のパラメータ (config_bool 相当)
+type ConfigBool struct {
+	ConfigGeneric
+	BootVal bool
+}
+
+// Get はサーバー全体の値を返す
+func (c *ConfigBool) Get() bool { return c.get().(bool) }
+
+func (c *ConfigBool) vartype() VarType { return PGCBool }
+func (c *ConfigBool) bootValue() any   { return c.BootVal }
+
+func (c *ConfigBool) parse(value string) (any, error) {
+	b, ok := adt.ParseBool(value)
+	if !ok {
+		return nil, newError(errcodes.InvalidParameterValue, "parameter \"%s\" requires a Boolean value", c.Name)
+	}
+	return b, nil
+}
+
+func (c *ConfigBool) show(v any) string {
+	if v.(bool) {
+		return "on"
+	}
+	return "off"
+}
+
+// ConfigInt は整数のパラメータ (config_int 相当)
+type ConfigInt struct {
+	ConfigGeneric
+	BootVal  int
+	Min, Max int
+	// ShowHook は値の表示方法を変える (show_hook 相当)。nil なら単位を付けた10進数で表示する
+	ShowHook func(int) string
+	// CheckHook は範囲の中の値を更に確かめる (check_hook 相当)。妥当でなければ DETAIL にする
+	// 理由を返す。nil なら範囲の中のどの値も受け付ける
+	CheckHook func(value int) (detail string, ok bool)
+}
+
+// Get はサーバー全体の値を返す
+func (c *ConfigInt) Get() int { return c.get().(int) }
+
+func (c *ConfigInt) vartype() VarType { return PGCInt }
+func (c *ConfigInt) bootValue() any   { return c.BootVal }
+
+func (c *ConfigInt) parse(value string) (any, error) {
+	f, hint, ok := parseNumber(value, c.Flags)
+	if ok && (f > math.MaxInt32 || f < math.MinInt32) {
+		hint, ok = "Value exceeds integer range.", false
+	}
+	if !ok {
+		err := newError(errcodes.InvalidParameterValue, "invalid value for parameter \"%s\": \"%s\"", c.Name, value)
+		err.Hint = hint
+		return nil, err
+	}
+	n := int(math.RoundToEven(f))
+	if n < c.Min || n > c.Max {
+		unit := unitSuffix(c.Flags)
+		return nil, newError(errcodes.InvalidParameterValue, "%d%s is outside the valid range for parameter \"%s\" (%d%s .. %d%s)",
+			n, unit, c.Name, c.Min, unit, c.Max, unit)
+	}
+	if c.CheckHook != nil {
+		if detail, ok := c.CheckHook(n); !ok {
+			err := newError(errcodes.InvalidParameterValue, "invalid value for parameter \"%s\": %d", c.Name, n)
+			err.Detail = detail
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func (c *ConfigInt) show(v any) string {
+	n := v.(int)
+	if c.ShowHook != nil {
+		return c.ShowHook(n)
+	}
+	if n > 0 && c.Flags&gucUnit != 0 {
+		for _, u := range unitConversions(c.Flags) {
+			if u.multiplier < 1 {
+				// 基本単位より小さい単位では常に整数になる
+				return strconv.Itoa(int(float64(n)/u.multiplier)) + u.unit
+			}
+			if n%int(u.multiplier) == 0 {
+				return strconv.Itoa(n/int(u.multiplier)) + u.unit
+			}
+		}
+	}
+	return strconv.Itoa(n)
+}
+
+// ConfigReal は実数のパラメータ (config_real 相当)
+type ConfigReal struct {
+	ConfigGeneric
+	BootVal  float64
+	Min, Max float64
+}
+
+// Get はサーバー全体の値を返す
+func (c *ConfigReal) Get() float64 { return c.get().(float64) }
+
+func (c *ConfigReal) vartype() VarType { return PGCReal }
+func (c *ConfigReal) bootValue() any   { return c.BootVal }
+
+func (c *ConfigReal) parse(value string) (any, error) {
+	f, hint, ok := parseNumber(value, c.Flags)
+	if !ok {
+		err := newError(errcodes.InvalidParameterValue, "invalid value for parameter \"%s\": \"%s\"", c.Name, value)
+		err.Hint = hint
+		return nil, err
+	}
+	if f < c.Min || f > c.Max {
+		unit := unitSuffix(c.Flags)
+		return nil, newError(errcodes.InvalidParameterValue, "%s%s is outside the valid range for parameter \"%s\" (%s%s .. %s%s)",
+			formatReal(f), unit, c.Name, formatReal(c.Min), unit, formatReal(c.Max), unit)
+	}
+	return f, nil
+}
+
+func (c *ConfigReal) show(v any) string {
+	f := v.(float64)
+	if f > 0 && c.Flags&gucUnit != 0 {
+		// 単位に直しても整数になる最も大きい単位で表示する
+		for _, u := range unitConversions(c.Flags) {
+			if u.multiplier <= 1 || f/u.multiplier == math.Trunc(f/u.multiplier) {
+				return formatReal(f/math.Max(u.multiplier, 1)) + u.unit
+			}
+		}
+	}
+	return formatReal(f)
+}
+
+// formatReal は実数を C言語の "%g" と同じ形式にする
+func formatReal(f float64) string {
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
+
+// ConfigString は文字列のパラメータ (config_string 相当)
+type ConfigString struct {
+	ConfigGeneric
+	BootVal string
+	// CheckHook は値を確かめる (check_hook 相当)。妥当でなければ DETAIL にする理由を返す。
+	// nil ならどの値も受け付ける
+	CheckHook func(value string) (detail string, ok bool)
+}
+
+// Get はサーバー全体の値を返す
+func (c *ConfigString) Get() string { return c.get().(string) }
+
+func (c *ConfigString) vartype() VarType  { return PGCString }
+func (c *ConfigString) bootValue() any    { return c.BootVal }
+func (c *ConfigString) show(v any) string { return v.(string) }
+
+func (c *ConfigString) parse(value string) (any, error) {
+	if c.CheckHook != nil {
+		if detail, ok := c.CheckHook(value); !ok {
+			err := newError(errcodes.InvalidParameterValue, "invalid value for parameter \"%s\": \"%s\"", c.Name, value)
+			err.Detail = detail
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// ConfigEnum は選択肢から値を選ぶパラメータ (config_enum 相当)
+type ConfigEnum struct {
+	ConfigGeneric
+	BootVal string
+	Options []string
+}
+
+// Get はサーバー全体の値を返す
+func (c *ConfigEnum) Get() string { return c.get().(string) }
+
+func (c *ConfigEnum) vartype() VarType { return PGCEnum }
+func (c *ConfigEnum) bootValue() any   { return c.BootVal }
+
+// parse は大文字と小文字を区別せずに選択肢と照合し、選択肢の綴りにする (config_enum_lookup_by_name 相当)
+func (c *ConfigEnum) parse(value string) (any, error) {
+	for _, o := range c.Options {
+		if strings.EqualFold(o, value) {
+			return o, nil
+		}
+	}
+	err := newError(errcodes.InvalidParameterValue, "invalid value for parameter \"%s\": \"%s\"", c.Name, value)
+	err.Hint = fmt.Sprintf("Available values: %s.", strings.Join(c.Options, ", "))
+	return nil, err
+}
+
+func (c *ConfigEnum) show(v any) string { return v.(string) }
+
+// ----------------------------------------------------------------
+// 単位
+// ----------------------------------------------------------------
+
+// unitConversion は値の単位から基本単位への換算 (unit_conversion 相当)
+type unitConversion struct {
+	unit       string
+	multiplier float64
+}
+
+// blockKB はリレーションのページの大きさ、xblockKB は WAL のブロックの大きさ (kB)
+const (
+	blockKB  = pgconfig.BlckSz / 1024
+	xblockKB = pgconfig.XLogBlckSz / 1024
+)
+
+// 基本単位ごとの換算表。大きい単位から並べる (memory_unit_conversion_table, time_unit_conversion_table 相当)
+var (
+	memoryUnitsByte = []unitConversion{
+		{"TB", 1024 * 1024 * 1024 * 1024}, {"GB", 1024 * 1024 * 1024}, {"MB", 1024 * 1024}, {"kB", 1024}, {"B", 1},
+	}
+	memoryUnitsKB = []unitConversion{
+		{"TB", 1024 * 1024 * 1024}, {"GB", 1024 * 1024}, {"MB", 1024}, {"kB", 1}, {"B", 1.0 / 1024},
+	}
+	// memoryUnitsBlocks はリレーションのページ (BLCKSZ) を基本単位とする
+	memoryUnitsBlocks = []unitConversion{
+		{"TB", 1024 * 1024 * 1024 / blockKB}, {"GB", 1024 * 1024 / blockKB}, {"MB", 1024 / blockKB},
+		{"kB", 1.0 / blockKB}, {"B", 1.0 / (blockKB * 1024)},
+	}
+	// memoryUnitsXBlocks は WAL のブロック (XLOG_BLCKSZ) を基本単位とする
+	memoryUnitsXBlocks = []unitConversion{
+		{"TB", 1024 * 1024 * 1024 / xblockKB}, {"GB", 1024 * 1024 / xblockKB}, {"MB", 1024 / xblockKB},
+		{"kB", 1.0 / xblockKB}, {"B", 1.0 / (xblockKB * 1024)},
+	}
+	timeUnitsMS = []unitConversion{
+		{"d", 24 * 60 * 60 * 1000}, {"h", 60 * 60 * 1000}, {"min", 60 * 1000}, {"s", 1000}, {"ms", 1}, {"us", 1.0 / 1000},
+	}
+	timeUnitsS = []unitConversion{
+		{"d", 24 * 60 * 60}, {"h", 60 * 60}, {"min", 60}, {"s", 1}, {"ms", 1.0 / 1000}, {"us", 1.0 / 1000000},
+	}
+	timeUnitsMin = []unitConversion{
+		{"d", 24 * 60}, {"h", 60}, {"min", 1}, {"s", 1.0 / 60}, {"ms", 1.0 / (60 * 1000)}, {"us", 1.0 / (60 * 1000000)},
+	}
+)
+
+const (
+	memoryUnitsHint = "Valid units for this parameter are \"B\", \"kB\", \"MB\", \"GB\", and \"TB\"."
+	timeUnitsHint   = "Valid units for this parameter are \"us\", \"ms\", \"s\", \"min\", \"h\", and \"d\"."
+)
+
+// unitConversions はパラメータの基本単位の換算表を返す
+func unitConversions(flags int) []unitConversion {
+	switch {
+	case flags&GucUnitByte != 0:
+		return memoryUnitsByte
+	case flags&GucUnitKB != 0:
+		return memoryUnitsKB
+	case flags&GucUnitBlocks != 0:
+		return memoryUnitsBlocks
+	case flags&GucUnitXBlocks != 0:
+		return memoryUnitsXBlocks
+	case flags&GucUnitMS != 0:
+		return timeUnitsMS
+	case flags&GucUnitS != 0:
+		return timeUnitsS
+	case flags&GucUnitMin != 0:
+		return timeUnitsMin
+	}
+	return nil
+}
+
+// unitName は値の基本単位の名前を返す (get_config_unit_name 相当)。単位がなければ空文字列。
+// ページと WAL のブロックは "8kB" のようにブロックの大きさで表す。
+func unitName(flags int) string {
+	if flags&GucUnitBlocks != 0 {
+		return fmt.Sprintf("%dkB", blockKB)
+	}
+	if flags&GucUnitXBlocks != 0 {
+		return fmt.Sprintf("%dkB", xblockKB)
+	}
+	for _, u := range unitConversions(flags) {
+		if u.multiplier == 1 {
+			return u.unit
+		}
+	}
+	return ""
+}
+
+// unitSuffix は範囲外のエラーで値に付ける " 単位" を返す
+func unitSuffix(flags int) string {
+	if unit := unitName(flags); unit != "" {
+		return " " + unit
+	}
+	return ""
+}
+
+var numberPattern = regexp.MustCompile(`^[+-]?(?:0[xX][0-9a-fA-F]+|(?:\d+\.?\d*|\.\d+)(?:[eE][+-]?\d+)?)`)
+
+// parseNumber は単位を付けてもよい数値を解析し、基本単位の値を返す (parse_int, parse_real 相当)。
+// 整数は 0x で始まる16進数と、0 で始まる8進数でも書ける。失敗した場合は、あればヒントを返す。
+func parseNumber(value string, flags int) (f float64, hint string, ok bool) {
+	s := strings.TrimSpace(value)
+	num := numberPattern.FindString(s)
+	if num == "" {
+		return 0, "", false
+	}
+	if n, err := strconv.ParseInt(num, 0, 64); err == nil {
+		f = float64(n)
+	} else if f, err = strconv.ParseFloat(num, 64); err != nil {
+		return 0, "", false
+	}
+
+	unit := strings.TrimSpace(s[len(num):])
+	if unit == "" {
+		return f, "", true
+	}
+	if flags&gucUnit == 0 {
+		return 0, "", false
+	}
+	for _, u := range unitConversions(flags) {
+		if u.unit == unit {
+			return f * u.multiplier, "", true
+		}
+	}
+	if flags&gucUnitMemory != 0 {
+		return 0, memoryUnitsHint, false
+	}
+	return 0, timeUnitsHint, false
+}
+
+// ----------------------------------------------------------------
+// パラメータの一覧
+// ----------------------------------------------------------------
+
+var (
+	// mu はサーバー全体の値を保護する。postmaster が設定し、各バックエンドが読む
+	mu sync.RWMutex
+	// variables は小文字にした名前からパラメータの定義を引く (guc_hashtab 相当)
+	variables = make(map[string]configVar)
+	// sortedVariables は名前順に並べたパラメータの定義
+	sortedVariables []*ConfigGeneric
+)
+
+// init は全てのパラメータを一覧に登録し、既定値にする (InitializeGUCOptions 相当)
+func init() {
+	for _, v := range configureNames {
+		g := v.generic()
+		g.typ = v.vartype()
+		g.value, g.source = v.bootValue(), PGCSDefault
+		variables[strings.ToLower(g.Name)] = v
+		sortedVariables = append(sortedVariables, g)
+	}
+	sort.Slice(sortedVariables, func(i, j int) bool {
+		return strings.ToLower(sortedVariables[i].Name) < strings.ToLower(sortedVariables[j].Name)
+	})
+}
+
+// find は名前 (大文字小文字を区別しない) からパラメータの定義を探す (find_option 相当)
+func find(name string) configVar {
+	return variables[strings.ToLower(name)]
+}
+
+// Lookup は名前 (大文字小文字を区別しない) からパラメータの定義を返す。なければ nil。
+func Lookup(name string) *ConfigGeneric {
+	if v := find(name); v != nil {
+		return v.generic()
+	}
+	return nil
+}
+
+// Variables は全てのパラメータの定義を名前順に返す
+func Variables() []*ConfigGeneric {
+	return sortedVariables
+}
+
+// findOrError はパラメータの定義を探し、なければエラーを返す
+func findOrError(name string) (configVar, error) {
+	v := find(name)
+	if v == nil {
+		return nil, newError(errcodes.UndefinedObject, "unrecognized configuration parameter \"%s\"", name)
+	}
+	return v, nil
+}
+
+// checkContext は context の時点で、source からパラメータを変更できるかを確かめる
+// (set_config_option の前半相当)。context は呼び出し側の状況を表し、SET ではスーパーユーザーなら
+// PGCSuset、それ以外は PGCUserset、接続の開始時には PGCSuBackend か PGCBackend を渡す。
+func checkContext(g *ConfigGeneric, context Context, source Source) error {
+	switch g.Context {
+	case PGCInternal:
+		if context != PGCInternal {
+			return newError(errcodes.CantChangeRuntimeParam, "parameter \"%s\" cannot be changed", g.Name)
+		}
+	case PGCPostmaster:
+		if context != PGCPostmaster {
+			return newError(errcodes.CantChangeRuntimeParam, "parameter \"%s\" cannot be changed without restarting the server", g.Name)
+		}
+	case PGCSighup:
+		if context != PGCSighup && context != PGCPostmaster {
+			return newError(errcodes.CantChangeRuntimeParam, "parameter \"%s\" cannot be changed now", g.Name)
+		}
+	case PGCSuBackend, PGCBackend:
+		if g.Context == PGCSuBackend && context == PGCBackend {
+			return newError(errcodes.InsufficientPrivilege, "permission denied to set parameter \"%s\"", g.Name)
+		}
+		if context != PGCPostmaster && context != PGCSighup && context != PGCSuBackend &&
+			context != PGCBackend && source != PGCSClient {
+			return newError(errcodes.CantChangeRuntimeParam, "parameter \"%s\" cannot be set after connection start", g.Name)
+		}
+	case PGCSuset:
+		if context == PGCUserset || context == PGCBackend {
+			return newError(errcodes.InsufficientPrivilege, "permission denied to set parameter \"%s\"", g.Name)
+		}
+	}
+	return nil
+}
+
+// SetConfigOption はサーバー全体の値を設定する (set_config_option 相当)。postmaster が
+// コマンドラインの値を設定するために使う。値の出どころが今の値より優先しない場合は何もしない。
+func SetConfigOption(name, value string, context Context, source Source) error {
+	v, err := findOrError(name)
+	if err != nil {
+		return err
+	}
+	g := v.generic()
+	if err := checkContext(g, context, source); err != nil {
+		return err
+	}
+	newval, err := v.parse(value)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if source >= g.source {
+		g.value, g.source = newval, source
+	}
+	return nil
+}
+
+// GetConfigOption はサーバー全体の値の表示用のテキスト表現を返す (GetConfigOption 相当)
+func GetConfigOption(name string) (string, error) {
+	v, err := findOrError(name)
+	if err != nil {
+		return "", err
+	}
+	return v.show(v.generic().get()), nil
+}
+
+// ----------------------------------------------------------------
+// セッションの値
+// ----------------------------------------------------------------
+
+// GucAction はセッションの値を変更する操作 (GucAction 相当)
+type GucAction int
+
+const (
+	// GucActionSet は SET。トランザクションをコミットすれば値が残る (GUC_ACTION_SET)
+	GucActionSet GucAction = iota
+	// GucActionLocal は SET LOCAL。トランザクションの終わりで元の値に戻る (GUC_ACTION_LOCAL)
+	GucActionLocal
+	// GucActionSave は関数の SET 句。関数を抜けるときに AtEOFunctionGUC で元の値に戻る
+	// (GUC_ACTION_SAVE)
+	GucActionSave
+)
+
+// Session はセッションで設定したパラメータの値。バックエンドごとに1つ持つ。
+//
+// トランザクションの中で変更したパラメータは、変更前の値をスタック (stack) に退避し、
+// トランザクションをアボートすれば元に戻す。サブトランザクションがないため、トランザクションの
+// 入れ子は1段だけである。
+//
+// SET 句を持つ関数の呼び出しは NewGUCNestLevel で入れ子を1段深くし、その中で GucActionSave で
+// 設定した値の変更前の値を saved に退避する。関数を抜けるときに AtEOFunctionGUC で元に戻す。
+// 関数の中で SET (SET LOCAL でなく) をした場合は、C言語版と同じくその値を関数の後にも残す。
+type Session struct {
+	values map[*ConfigGeneric]*sessionValue
+	// inXact はトランザクションの中であることを表す。外で変更した値は退避しない
+	inXact bool
+	stack  map[*ConfigGeneric]*gucStack
+	// saved は関数の呼び出しの入れ子ごとに、SET 句で変更する前の値
+	saved []map[*ConfigGeneric]stackedValue
+}
+
+// gucStackState はトランザクションの中でした変更の種類 (GucStackState 相当)
+type gucStackState int
+
+const (
+	// gucStackSet は SET をした状態 (GUC_SET)
+	gucStackSet gucStackState = iota
+	// gucStackLocal は SET LOCAL だけをした状態 (GUC_LOCAL)
+	gucStackLocal
+	// gucStackSetLocal は SET の後に SET LOCAL をした状態 (GUC_SET_LOCAL)
+	gucStackSetLocal
+)
+
+// gucStack はトランザクションの中で変更したパラメータの退避した値 (GucStack 相当)
+type gucStack struct {
+	state gucStackState
+	// prior はトランザクションを始めた時の値。masked は SET LOCAL で隠した SET の値
+	prior  stackedValue
+	masked stackedValue
+}
+
+// stackedValue は退避した値。present が false ならサーバー全体の値を参照していたことを表す
+type stackedValue struct {
+	value   any
+	source  Source
+	present bool
+}
+
+// sessionValue はセッションでの1つのパラメータの値
+type sessionValue struct {
+	value  any
+	source Source
+	// resetValue は RESET で戻す値とその出どころ (reset_val, reset_source 相当)。
+	// hasReset が false なら RESET でサーバー全体の値に戻す
+	resetValue  any
+	resetSource Source
+	hasReset    bool
+}
+
+// NewSession は全てのパラメータがサーバー全体の値を参照するセッションの値を作る
+func NewSession() *Session {
+	return &Session{
+		values: make(map[*ConfigGeneric]*sessionValue),
+		stack:  make(map[*ConfigGeneric]*gucStack),
+	}
+}
+
+// value は現在値と、その出どころを返す
+func (s *Session) value(g *ConfigGeneric) (any, Source) {
+	if sv, ok := s.values[g]; ok {
+		return sv.value, sv.source
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	return g.value, g.source
+}
+
+// SetConfigOption はセッションの値を設定する (set_config_option 相当)。source が PGCSOverride
+// 以前の出どころであれば、RESET で戻す値にもする。値の出どころが今の値より優先しない場合は何もしない。
+func (s *Session) SetConfigOption(name, value string, context Context, source Source, action GucAction) error {
+	v, err := findOrError(name)
+	if err != nil {
+		return err
+	}
+	g := v.generic()
+	if err := checkContext(g, context, source); err != nil {
+		return err
+	}
+	newval, err := v.parse(value)
+	if err != nil {
+		return err
+	}
+	if _, cur := s.value(g); source < cur {
+		return nil
+	}
+	s.pushOldValue(g, action)
+	sv, ok := s.values[g]
+	if !ok {
+		sv = &sessionValue{}
+		s.values[g] = sv
+	}
+	sv.value, sv.source = newval, source
+	if source <= PGCSOverride {
+		sv.resetValue, sv.resetSource, sv.hasReset = newval, source, true
+	}
+	return nil
+}
+
+// ResetConfigOption は値を RESET で戻す値にする (RESET name 相当)
+func (s *Session) ResetConfigOption(name string, context Context, action GucAction) error {
+	v, err := findOrError(name)
+	if err != nil {
+		return err
+	}
+	g := v.generic()
+	if err := checkContext(g, context, PGCSSession); err != nil {
+		return err
+	}
+	s.reset(g, action)
+	return nil
+}
+
+func (s *Session) reset(g *ConfigGeneric, action GucAction) {
+	s.pushOldValue(g, action)
+	sv, ok := s.values[g]
+	switch {
+	case !ok:
+	case sv.hasReset:
+		sv.value, sv.source = sv.resetValue, sv.resetSource
+	default:
+		delete(s.values, g)
+	}
+}
+
+// ResetAllOptions はいつでも変更できる全てのパラメータを RESET で戻す値にする (ResetAllOptions 相当)
+func (s *Session) ResetAllOptions() {
+	for _, g := range sortedVariables {
+		if g.Flags&GucNoResetAll != 0 || g.Context != PGCSuset && g.Context != PGCUserset {
+			continue
+		}
+		s.reset(g, GucActionSet)
+	}
+}
+
+// current は退避するための現在の値を返す
+func (s *Session) current(g *ConfigGeneric) stackedValue {
+	if sv, ok := s.values[g]; ok {
+		return stackedValue{sv.value, sv.source, true}
+	}
+	return stackedValue{}
+}
+
+// restore は退避した値に戻す。RESET で戻す値は変えない。
+func (s *Session) restore(g *ConfigGeneric, v stackedValue) {
+	sv, ok := s.values[g]
+	switch {
+	case v.present && ok:
+		sv.value, sv.source = v.value, v.source
+	case v.present:
+		s.values[g] = &sessionValue{value: v.value, source: v.source}
+	case ok && sv.hasReset:
+		sv.value, sv.source = sv.resetValue, sv.resetSource
+	default:
+		delete(s.values, g)
+	}
+}
+
+// pushOldValue は変更する前の値を退避する (push_old_value 相当)。トランザクションの中で
+// 既に変更したパラメータは、最初の値を残したまま変更の種類だけを更新する。
+func (s *Session) pushOldValue(g *ConfigGeneric, action GucAction) {
+	switch action {
+	case GucActionSave:
+		top := s.saved[len(s.saved)-1]
+		if _, ok := top[g]; !ok {
+			top[g] = s.current(g)
+		}
+		return
+	case GucActionSet:
+		// SET の値は関数を抜けても残す
+		for _, level := range s.saved {
+			delete(level, g)
+		}
+	}
+	if !s.inXact {
+		return
+	}
+	if st, ok := s.stack[g]; ok {
+		switch action {
+		case GucActionSet:
+			// SET はそれまでの SET LOCAL を打ち消す
+			st.state, st.masked = gucStackSet, stackedValue{}
+		case GucActionLocal:
+			if st.state == gucStackSet {
+				st.state, st.masked = gucStackSetLocal, s.current(g)
+			}
+		}
+		return
+	}
+	st := &gucStack{state: gucStackSet, prior: s.current(g)}
+	if action == GucActionLocal {
+		st.state = gucStackLocal
+	}
+	s.stack[g] = st
+}
+
+// NewGUCNestLevel は SET 句を持つ関数を呼ぶ前に入れ子を1段深くし、その深さを返す
+// (NewGUCNestLevel 相当)
+func (s *Session) NewGUCNestLevel() int {
+	s.saved = append(s.saved, make(map[*ConfigGeneric]stackedValue))
+	return len(s.saved)
+}
+
+// AtEOFunctionGUC は nestLevel より深い入れ子で GucActionSave で設定した値を元に戻す
+// (AtEOXact_GUC の nestLevel が 1 より大きい場合相当)。関数を抜けるときに、エラーで抜ける
+// 場合も必ず呼ぶ。
+func (s *Session) AtEOFunctionGUC(nestLevel int) {
+	for len(s.saved) >= nestLevel {
+		top := s.saved[len(s.saved)-1]
+		s.saved = s.saved[:len(s.saved)-1]
+		for g, v := range top {
+			s.restore(g, v)
+		}
+	}
+}
+
+// AtStartGUC はトランザクションの開始時に呼ぶ (AtStart_GUC 相当)。以降の変更は退避する。
+func (s *Session) AtStartGUC() {
+	s.inXact = true
+}
+
+// AtEOXactGUC はトランザクションの終了時に、トランザクションの中でした変更を確定するか
+// 元に戻す (AtEOXact_GUC 相当)。コミットでは SET の値を残し、SET LOCAL の値は戻す。
+// アボートでは全ての変更を戻す。
+func (s *Session) AtEOXactGUC(isCommit bool) {
+	for g, st := range s.stack {
+		switch {
+		case !isCommit || st.state == gucStackLocal:
+			s.restore(g, st.prior)
+		case st.state == gucStackSetLocal:
+			s.restore(g, st.masked)
+		}
+	}
+	clear(s.stack)
+	s.inXact = false
+}
+
+// GetConfigOption は現在値の表示用のテキスト表現を返す (GetConfigOption 相当)
+func (s *Session) GetConfigOption(name string) (string, error) {
+	v, err := findOrError(name)
+	if err != nil {
+		return "", err
+	}
+	val, _ := s.value(v.generic())
+	return v.show(val), nil
+}
+
+// ConfigOptionValues は pg_settings の1行に表示するパラメータの状態 (GetConfigOptionValues 相当)。
+// 値は単位を付けずに基本単位で表す。空文字列の Unit と SourceFile、nil の MinVal、MaxVal、
+// EnumVals は、その列が NULL であることを表す。
+type ConfigOptionValues struct {
+	Setting        string
+	Unit           string
+	Source         Source
+	MinVal, MaxVal *string
+	EnumVals       []string
+	BootVal        string
+	ResetVal       string
+	SourceFile     string
+	SourceLine     int
+	PendingRestart bool
+}
+
+// GetConfigOptionValues はパラメータの状態を返す (GetConfigOptionValues 相当)
+func (s *Session) GetConfigOptionValues(g *ConfigGeneric) ConfigOptionValues {
+	v := find(g.Name)
+	val, source := s.value(g)
+
+	mu.RLock()
+	resetVal := g.value
+	sourcefile, sourceline, pendingRestart := g.sourcefile, g.sourceline, g.pendingRestart
+	mu.RUnlock()
+	if sv, ok := s.values[g]; ok && sv.hasReset {
+		resetVal = sv.resetValue
+	}
+
+	values := ConfigOptionValues{
+		Setting:        showRaw(v, val),
+		Unit:           unitName(g.Flags),
+		Source:         source,
+		BootVal:        showRaw(v, v.bootValue()),
+		ResetVal:       showRaw(v, resetVal),
+		PendingRestart: pendingRestart,
+	}
+	switch c := v.(type) {
+	case *ConfigInt:
+		minVal, maxVal := strconv.Itoa(c.Min), strconv.Itoa(c.Max)
+		values.MinVal, values.MaxVal = &minVal, &maxVal
+	case *ConfigReal:
+		minVal, maxVal := formatReal(c.Min), formatReal(c.Max)
+		values.MinVal, values.MaxVal = &minVal, &maxVal
+	case *ConfigEnum:
+		values.EnumVals = c.Options
+	}
+	if source == PGCSFile {
+		values.SourceFile, values.SourceLine = sourcefile, sourceline
+	}
+	return values
+}
+
+// showRaw は値を単位を付けずに表す (ShowGUCOption(record, false) 相当)
+func showRaw(v configVar, val any) string {
+	switch c := v.(type) {
+	case *ConfigInt:
+		if c.ShowHook == nil {
+			return strconv.Itoa(val.(int))
+		}
+	case *ConfigReal:
+		return formatReal(val.(float64))
+	}
+	return v.show(val)
+}
+
+// GetBool などは現在値を返す
+func (s *Session) GetBool(c *ConfigBool) bool {
+	v, _ := s.value(&c.ConfigGeneric)
+	return v.(bool)
+}
+
+func (s *Session) GetInt(c *ConfigInt) int {
+	v, _ := s.value(&c.ConfigGeneric)
+	return v.(int)
+}
+
+func (s *Session) GetReal(c *ConfigReal) float64 {
+	v, _ := s.value(&c.ConfigGeneric)
+	return v.(float64)
+}
+
+func (s *Session) GetString(c *ConfigString) string {
+	v, _ := s.value(&c.ConfigGeneric)
+	return v.(string)
+}
+
+func (s *Session) GetEnum(c *ConfigEnum) string {
+	v, _ := s.value(&c.ConfigGeneric)
+	return v.(string)
+}
+
+// ----------------------------------------------------------------
+// 設定の配列 (GUCArrayAdd, ProcessGUCArray 相当)
+// ----------------------------------------------------------------
+// ALTER ROLE SET と ALTER DATABASE SET の値は "名前=値" の並びとして保存し、
+// 接続の開始時にセッションの値にする。
+
+// ValidateOptionArrayItem は設定の配列の値を変更できるかを確かめ、パラメータの正式な綴りを返す
+// (validate_option_array_item 相当)。context は SET と同じく、スーパーユーザーなら PGCSuset、
+// それ以外は PGCUserset を渡す。value が nil の場合 (値を取り除く場合) は値を確かめない。
+func ValidateOptionArrayItem(name string, value *string, context Context) (string, error) {
+	v, err := findOrError(name)
+	if err != nil {
+		return "", err
+	}
+	g := v.generic()
+	if err := checkContext(g, context, PGCSSession); err != nil {
+		return "", err
+	}
+	if value != nil {
+		if _, err := v.parse(*value); err != nil {
+			return "", err
+		}
+	}
+	return g.Name, nil
+}
+
+// GUCArrayAdd は設定の配列の name の値を value にする (GUCArrayAdd 相当)。
+// 同じ名前の値があれば置き換え、なければ最後に加える。
+func GUCArrayAdd(array []string, name, value string) []string {
+	item := name + "=" + value
+	for i, cur := range array {
+		if n, _, _ := strings.Cut(cur, "="); strings.EqualFold(n, name) {
+			out := slices.Clone(array)
+			out[i] = item
+			return out
+		}
+	}
+	return append(slices.Clone(array), item)
+}
+
+// GUCArrayDelete は設定の配列から name の値を取り除く (GUCArrayDelete 相当)
+func GUCArrayDelete(array []string, name string) []string {
+	return slices.DeleteFunc(slices.Clone(array), func(cur string) bool {
+		n, _, _ := strings.Cut(cur, "=")
+		return strings.EqualFold(n, name)
+	})
+}
+
+// GUCArrayReset は設定の配列から、context で変更できる値を全て取り除く (GUCArrayReset 相当)。
+// スーパーユーザーでなければ、スーパーユーザーだけが設定できる値は残す。
+func GUCArrayReset(array []string, context Context) []string {
+	if context == PGCSuset {
+		return nil
+	}
+	return slices.DeleteFunc(slices.Clone(array), func(cur string) bool {
+		n, _, _ := strings.Cut(cur, "=")
+		v := find(n)
+		return v == nil || checkContext(v.generic(), context, PGCSSession) == nil
+	})
+}
+
+// ProcessGUCArray は設定の配列の値をセッションの値にする (ProcessGUCArray 相当)。
+// 設定できない値があっても残りは設定し、それぞれのエラーを返す。接続の開始時の設定では、呼び出し側は
+// 警告として報告する。
+func (s *Session) ProcessGUCArray(array []string, context Context, source Source, action GucAction) []error {
+	var errs []error
+	for _, item := range array {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			errs = append(errs, newError(errcodes.SyntaxError, "could not parse setting for parameter \"%s\"", name))
+			continue
+		}
+		// 名前の中の "-" は "_" と同じ扱い (ParseLongOption 相当)
+		name = strings.ReplaceAll(name, "-", "_")
+		if err := s.SetConfigOption(name, value, context, source, action); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,626 @@
+package guc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// 設定ファイル (utils/misc/guc-file.l 相当)
+// ----------------------------------------------------------------
+// postgresql.conf の各行は "名前 = 値" の形で書く。"=" は省略でき、# から行末まではコメントになる。
+// 値は単一引用符で囲む文字列か、空白を含まない語 (数値、単位付きの数値、識別子など) で書く。
+// 引用符で囲んだ文字列の中では '' と \ によるエスケープを使える。
+//
+//	include 'ファイル'             ファイルを読み込む
+//	include_if_exists 'ファイル'   ファイルがあれば読み込む
+//	include_dir 'ディレクトリ'     ディレクトリの中の .conf ファイルを名前順に読み込む
+//
+// 相対パスは書かれていたファイルのディレクトリからのパスとする。同じパラメータを
+// 複数回設定した場合は、最後の値を使う。
+//
+// ファイルの値はコマンドラインで指定した値より優先しない。SIGHUP で読み直すと、変更した値を
+// サーバー全体の値に反映する。セッションで SET していないパラメータには、各バックエンドにも
+// すぐに反映される。postmaster の起動時にしか設定できないパラメータの変更は反映せずにログに出す。
+
+// maxIncludeDepth は include の入れ子の深さの上限 (CONF_FILE_MAX_DEPTH 相当)
+const maxIncludeDepth = 10
+
+// configVariable は設定ファイルの1行分の設定 (ConfigVariable 相当)
+type configVariable struct {
+	name       string
+	value      string
+	filename   string
+	sourceline int
+}
+
+// LogHook はメッセージをサーバーログに書く (ereport 相当)。errutil は guc に依存するため、guc から
+// errutil は呼べない。errutil が init で設定する。nil なら標準エラー出力に書く。
+var LogHook func(level, message, detail, hint string)
+
+// logEntry はサーバーログに書く LOG のメッセージ1つ
+type logEntry struct {
+	message, detail, hint string
+}
+
+// report はメッセージをサーバーログに書く
+func (e logEntry) report() {
+	if LogHook != nil {
+		LogHook("LOG", e.message, e.detail, e.hint)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "LOG:  %s\n", e.message)
+	if e.detail != "" {
+		fmt.Fprintf(os.Stderr, "DETAIL:  %s\n", e.detail)
+	}
+	if e.hint != "" {
+		fmt.Fprintf(os.Stderr, "HINT:  %s\n", e.hint)
+	}
+}
+
+// errorEntry はパラメータの値の誤りを詳細とヒントとともにメッセージにする
+func errorEntry(err error) logEntry {
+	e := logEntry{message: err.Error()}
+	var ge *Error
+	if errors.As(err, &ge) {
+		e.detail, e.hint = ge.Detail, ge.Hint
+	}
+	return e
+}
+
+// logf はメッセージをサーバーログに書く
+func logf(format string, args ...any) {
+	logEntry{message: fmt.Sprintf(format, args...)}.report()
+}
+
+// configLog はパラメータのロックを持つ間のメッセージを溜めておき、ロックを放してから書く。
+// LogHook は log_line_prefix などの値を読むため、ロックを持ったままでは呼べない。
+type configLog []logEntry
+
+func (l *configLog) logf(format string, args ...any) {
+	*l = append(*l, logEntry{message: fmt.Sprintf(format, args...)})
+}
+
+func (l *configLog) logError(err error) {
+	*l = append(*l, errorEntry(err))
+}
+
+// flush は溜めたメッセージを書く
+func (l *configLog) flush() {
+	for _, e := range *l {
+		e.report()
+	}
+	*l = nil
+}
+
+// absoluteConfigLocation は include で指定したパスを絶対パスにする (AbsoluteConfigLocation 相当)。
+// 相対パスは callingFile のディレクトリからのパスとする。
+func absoluteConfigLocation(location, callingFile string) string {
+	if filepath.IsAbs(location) {
+		return location
+	}
+	if callingFile != "" {
+		return filepath.Join(filepath.Dir(callingFile), location)
+	}
+	if abs, err := filepath.Abs(location); err == nil {
+		return abs
+	}
+	return location
+}
+
+// parseConfigFile は設定ファイルを読み、include を展開した設定を items に加える (ParseConfigFile 相当)。
+// strict が false の場合は、ファイルがなくても誤りとしない (include_if_exists)。
+// 誤りはログに書き、1つでもあれば false を返す。
+func parseConfigFile(location, callingFile string, strict bool, depth int, items *[]*configVariable) bool {
+	path := absoluteConfigLocation(location, callingFile)
+	if depth > maxIncludeDepth {
+		logf("could not open configuration file \"%s\": maximum nesting depth exceeded", path)
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !strict && errors.Is(err, os.ErrNotExist) {
+			return true
+		}
+		logf("could not open configuration file \"%s\": %s", path, platform.OSErrorMessage(err))
+		return false
+	}
+	return parseConfigFp(string(data), path, depth, items)
+}
+
+// parseConfigDirectory はディレクトリの中の、名前が "." で始まらない .conf ファイルを
+// 名前順に読み込む (ParseConfigDirectory 相当)
+func parseConfigDirectory(location, callingFile string, depth int, items *[]*configVariable) bool {
+	dir := absoluteConfigLocation(location, callingFile)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logf("could not open configuration directory \"%s\": %s", dir, platform.OSErrorMessage(err))
+		return false
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".conf") || e.IsDir() {
+			continue
+		}
+		names = append(names, filepath.Join(dir, name))
+	}
+	sort.Strings(names)
+	ok := true
+	for _, name := range names {
+		if !parseConfigFile(name, "", true, depth+1, items) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// parseConfigFp は設定ファイルの内容を1行ずつ解析する (ParseConfigFp 相当)。
+// 誤りのある行はログに書いて読み飛ばし、残りの行の解析を続ける。
+func parseConfigFp(data, filename string, depth int, items *[]*configVariable) bool {
+	ok := true
+	for i, line := range strings.Split(data, "\n") {
+		lineno := i + 1
+		toks, errTok, lexOK := tokenizeConfigLine(line)
+		if lexOK && len(toks) == 0 {
+			continue
+		}
+		// "名前 [=] 値" の形でなければ構文の誤り
+		if lexOK {
+			switch {
+			case !isConfigName(toks[0].text) || toks[0].quoted:
+				errTok, lexOK = toks[0].text, false
+			case len(toks) >= 2 && toks[1].text == "=" && !toks[1].quoted:
+				toks = append(toks[:1], toks[2:]...)
+			}
+		}
+		switch {
+		case !lexOK:
+		case len(toks) < 2:
+			lexOK = false
+		case toks[1].text == "=" && !toks[1].quoted:
+			errTok, lexOK = "=", false
+		case len(toks) > 2:
+			errTok, lexOK = toks[2].text, false
+		}
+		if !lexOK {
+			if errTok == "" {
+				logf("syntax error in file \"%s\" line %d, near end of line", filename, lineno)
+			} else {
+				logf("syntax error in file \"%s\" line %d, near token \"%s\"", filename, lineno, errTok)
+			}
+			ok = false
+			continue
+		}
+
+		name, value := toks[0].text, toks[1].text
+		switch strings.ToLower(name) {
+		case "include":
+			if !parseConfigFile(value, filename, true, depth+1, items) {
+				ok = false
+			}
+		case "include_if_exists":
+			if !parseConfigFile(value, filename, false, depth+1, items) {
+				ok = false
+			}
+		case "include_dir":
+			if !parseConfigDirectory(value, filename, depth+1, items) {
+				ok = false
+			}
+		default:
+			*items = append(*items, &configVariable{name: name, value: value, filename: filename, sourceline: lineno})
+		}
+	}
+	return ok
+}
+
+// configToken は設定ファイルの1行の中の語
+type configToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeConfigLine は1行を語に分ける。引用符で囲んだ文字列はエスケープを解いて返す
+// (GUC_scanstr 相当)。閉じていない文字列があれば、その語と false を返す。
+func tokenizeConfigLine(line string) (toks []configToken, errTok string, ok bool) {
+	i := 0
+	for i < len(line) {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			i++
+		case c == '#':
+			return toks, "", true
+		case c == '=':
+			toks = append(toks, configToken{text: "="})
+			i++
+		case c == '\'':
+			var buf strings.Builder
+			j := i + 1
+			for {
+				if j >= len(line) {
+					return nil, line[i:], false
+				}
+				if line[j] == '\'' {
+					if j+1 < len(line) && line[j+1] == '\'' {
+						buf.WriteByte('\'')
+						j += 2
+						continue
+					}
+					j++
+					break
+				}
+				if line[j] == '\\' && j+1 < len(line) {
+					j = scanEscape(line, j+1, &buf)
+					continue
+				}
+				buf.WriteByte(line[j])
+				j++
+			}
+			toks = append(toks, configToken{text: buf.String(), quoted: true})
+			i = j
+		default:
+			j := i
+			for j < len(line) && !strings.ContainsRune(" \t\r\f#='", rune(line[j])) {
+				j++
+			}
+			toks = append(toks, configToken{text: line[i:j]})
+			i = j
+		}
+	}
+	return toks, "", true
+}
+
+// scanEscape は \ の後のエスケープを解いて buf に書き、次の位置を返す
+func scanEscape(line string, j int, buf *strings.Builder) int {
+	switch c := line[j]; c {
+	case 'b':
+		buf.WriteByte('\b')
+	case 'f':
+		buf.WriteByte('\f')
+	case 'n':
+		buf.WriteByte('\n')
+	case 'r':
+		buf.WriteByte('\r')
+	case 't':
+		buf.WriteByte('\t')
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		// 3桁までの8進数
+		n := 0
+		k := j
+		for ; k < len(line) && k < j+3 && '0' <= line[k] && line[k] <= '7'; k++ {
+			n = n*8 + int(line[k]-'0')
+		}
+		buf.WriteByte(byte(n))
+		return k
+	default:
+		buf.WriteByte(c)
+	}
+	return j + 1
+}
+
+// isConfigName はパラメータ名として書ける語かどうかを返す (ID と QUALIFIED_ID 相当)
+func isConfigName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		letter := c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+		switch {
+		case letter:
+		case i > 0 && ('0' <= c && c <= '9' || c == '$'):
+		case i > 0 && c == '.' && i+1 < len(s):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ----------------------------------------------------------------
+// 設定ファイルの反映 (ProcessConfigFile 相当)
+// ----------------------------------------------------------------
+
+// SelectConfigFiles はデータディレクトリと設定ファイルの場所を決め、設定ファイルを読む
+// (SelectConfigFiles 相当)。postmaster の起動時に、コマンドラインの値を設定した後で呼ぶ。
+//
+// data_directory は絶対パスにする。config_file、hba_file と ident_file を指定していなければ、
+// データディレクトリの postgresql.conf、pg_hba.conf と pg_ident.conf を使う。
+func SelectConfigFiles() error {
+	if dir := DataDirectory.Get(); dir != "" {
+		if err := setOverride(DataDirectory, absoluteConfigLocation(dir, "")); err != nil {
+			return err
+		}
+		if _, err := os.Stat(DataDirectory.Get()); err != nil {
+			return fmt.Errorf("could not access directory \"%s\": %s\nHINT:  Run initdb to initialize a PostgreSQL data directory.",
+				DataDirectory.Get(), platform.OSErrorMessage(err))
+		}
+		if ConfigFile.Get() == "" {
+			if err := setOverride(ConfigFile, filepath.Join(DataDirectory.Get(), "postgresql.conf")); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := ProcessConfigFile(PGCPostmaster); err != nil {
+		return err
+	}
+
+	// 設定ファイルで data_directory を指定した場合も、ここで既定値を決める
+	dir := DataDirectory.Get()
+	if dir == "" {
+		return nil
+	}
+	if err := setOverride(DataDirectory, absoluteConfigLocation(dir, "")); err != nil {
+		return err
+	}
+	for _, c := range []struct {
+		v    *ConfigString
+		name string
+	}{
+		{HbaFile, "pg_hba.conf"},
+		{IdentFile, "pg_ident.conf"},
+	} {
+		if c.v.Get() == "" {
+			if err := setOverride(c.v, filepath.Join(DataDirectory.Get(), c.name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setOverride はサーバーが決めた値を設定する (SetConfigOption(..., PGC_S_OVERRIDE) 相当)
+func setOverride(c *ConfigString, value string) error {
+	return SetConfigOption(c.Name, value, PGCPostmaster, PGCSOverride)
+}
+
+// ProcessConfigFile は config_file を読み、値をサーバー全体の値に反映する (ProcessConfigFile 相当)。
+// config_file が空の場合は何もしない。
+//
+// 起動時 (context が PGCPostmaster) は、誤りが1つでもあればエラーを返す。SIGHUP による読み直し
+// (PGCSighup) では誤りをログに書く。構文の誤りか不明なパラメータがあれば何も反映せず、
+// 値の誤りであれば、誤りのないパラメータだけを反映する。
+func ProcessConfigFile(context Context) error {
+	filename := ConfigFile.Get()
+	if filename == "" {
+		return nil
+	}
+	filename = absoluteConfigLocation(filename, "")
+
+	// ALTER SYSTEM で設定した値は、postgresql.conf の最後に書いたものとして扱う
+	var items []*configVariable
+	if !parseConfigFile(filename, "", true, 0, &items) ||
+		!parseConfigFile(autoConfFilename(), "", false, 0, &items) {
+		return configFileError(context, filename, "no changes were applied", logf)
+	}
+
+	// 名前を確かめ、同じパラメータの設定が複数あれば最後のものだけを使う
+	last := make(map[*ConfigGeneric]*configVariable)
+	unrecognized := false
+	for _, item := range items {
+		v := find(item.name)
+		if v == nil {
+			logf("unrecognized configuration parameter \"%s\" in file \"%s\" line %d",
+				item.name, item.filename, item.sourceline)
+			unrecognized = true
+			continue
+		}
+		last[v.generic()] = item
+	}
+	if unrecognized {
+		return configFileError(context, filename, "no changes were applied", logf)
+	}
+
+	// 溜めたメッセージは、ロックを放した後に書く
+	var log configLog
+	defer log.flush()
+	mu.Lock()
+	defer mu.Unlock()
+
+	failed := false
+	// 設定ファイルから消えたパラメータは既定値に戻す
+	for _, g := range sortedVariables {
+		if _, ok := last[g]; ok || g.source != PGCSFile {
+			continue
+		}
+		if g.Context == PGCPostmaster {
+			log.logf("parameter \"%s\" cannot be changed without restarting the server", g.Name)
+			g.pendingRestart = true
+			failed = true
+			continue
+		}
+		v := find(g.Name)
+		g.value, g.source = v.bootValue(), PGCSDefault
+		g.sourcefile, g.sourceline = "", 0
+		log.logf("parameter \"%s\" removed from configuration file, reset to default", g.Name)
+	}
+
+	for _, item := range items {
+		v := find(item.name)
+		g := v.generic()
+		if last[g] != item {
+			continue
+		}
+		newval, err := v.parse(item.value)
+		if err != nil {
+			log.logError(err)
+			failed = true
+			continue
+		}
+		// コマンドラインで指定した値は設定ファイルで上書きしない
+		if g.source > PGCSFile {
+			continue
+		}
+		if g.Context == PGCPostmaster && context != PGCPostmaster {
+			g.pendingRestart = newval != g.value
+			if g.pendingRestart {
+				log.logf("parameter \"%s\" cannot be changed without restarting the server", g.Name)
+				failed = true
+			}
+			continue
+		}
+		if err := checkContext(g, context, PGCSFile); err != nil {
+			log.logError(err)
+			failed = true
+			continue
+		}
+		changed := newval != g.value
+		g.value, g.source = newval, PGCSFile
+		g.sourcefile, g.sourceline = item.filename, item.sourceline
+		if changed && context != PGCPostmaster {
+			log.logf("parameter \"%s\" changed to \"%s\"", g.Name, v.show(newval))
+		}
+	}
+
+	if failed {
+		return configFileError(context, filename, "unaffected changes were applied", log.logf)
+	}
+	return nil
+}
+
+// configFileError は設定ファイルの誤りを報告する。起動時はエラーを返し、読み直しのときは logf で
+// ログに書く。
+func configFileError(context Context, filename, applied string, logf func(format string, args ...any)) error {
+	if context == PGCPostmaster {
+		return fmt.Errorf("configuration file \"%s\" contains errors", filename)
+	}
+	logf("configuration file \"%s\" contains errors; %s", filename, applied)
+	return nil
+}
+
+// ----------------------------------------------------------------
+// ALTER SYSTEM (AlterSystemSetConfigFile 相当)
+// ----------------------------------------------------------------
+// ALTER SYSTEM で設定した値は postgresql.auto.conf に書き、設定ファイルを読むときに
+// postgresql.conf の後に読む。postgresql.auto.conf はデータディレクトリに置き、data_directory を
+// 指定していなければ config_file と同じディレクトリに置く。書き込んだ値は、設定ファイルを
+// 読み直すまで反映しない。
+
+// autoConfFileName は ALTER SYSTEM で書き込むファイルの名前 (PG_AUTOCONF_FILENAME 相当)
+const autoConfFileName = "postgresql.auto.conf"
+
+// autoFileMu は postgresql.auto.conf の書き換えを直列にする (AutoFileLock 相当)
+var autoFileMu sync.Mutex
+
+// autoConfFilename は postgresql.auto.conf のパスを返す。data_directory と config_file の
+// どちらも空の場合は空文字列。
+func autoConfFilename() string {
+	if dir := DataDirectory.Get(); dir != "" {
+		return filepath.Join(dir, autoConfFileName)
+	}
+	filename := ConfigFile.Get()
+	if filename == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(absoluteConfigLocation(filename, "")), autoConfFileName)
+}
+
+// AlterSystemSetConfigFile は postgresql.auto.conf の name の設定を value にする (AlterSystemSetConfigFile 相当)。
+// value が nil の場合は name の設定を削除し、name も空の場合は全ての設定を削除する (ALTER SYSTEM RESET ALL)。
+// 権限は呼び出し側で確かめる。他のセッションの書き換えを待つ間は waitEvent に記録する。
+func AlterSystemSetConfigFile(name string, value *string, waitEvent *waitevent.Slot) error {
+	if name != "" {
+		v, err := findOrError(name)
+		if err != nil {
+			return err
+		}
+		g := v.generic()
+		if g.Context == PGCInternal || g.Flags&(GucDisallowInFile|GucDisallowInAutoFile) != 0 {
+			return newError(errcodes.CantChangeRuntimeParam, "parameter \"%s\" cannot be changed", g.Name)
+		}
+		if value != nil {
+			if _, err := v.parse(*value); err != nil {
+				return err
+			}
+			if strings.ContainsAny(*value, "\n") {
+				return newError(errcodes.InvalidParameterValue, "parameter value for ALTER SYSTEM must not contain a newline")
+			}
+		}
+		name = g.Name
+	}
+
+	path := autoConfFilename()
+	if path == "" {
+		return newError(errcodes.ObjectNotInPrerequisiteState, "could not write \"%s\" because \"%s\" is not set", autoConfFileName, ConfigFile.Name)
+	}
+
+	waitEvent.LockMutex(&autoFileMu, waitevent.LWLockAutoFile)
+	defer autoFileMu.Unlock()
+
+	var items []*configVariable
+	if name != "" {
+		if !parseConfigFile(path, "", false, 0, &items) {
+			return newError(errcodes.ConfigFileError, "could not parse contents of file \"%s\"", path)
+		}
+	}
+	items = replaceAutoConfigValue(items, name, value)
+	return writeAutoConfFile(path, items)
+}
+
+// replaceAutoConfigValue は name の設定を value に置き換える (replace_auto_config_value 相当)。
+// 同じ名前の設定は1つにまとめ、なければ最後に加える。value が nil なら削除する。
+func replaceAutoConfigValue(items []*configVariable, name string, value *string) []*configVariable {
+	var out []*configVariable
+	replaced := false
+	for _, item := range items {
+		if !strings.EqualFold(item.name, name) {
+			out = append(out, item)
+			continue
+		}
+		if value != nil && !replaced {
+			out = append(out, &configVariable{name: name, value: *value})
+			replaced = true
+		}
+	}
+	if value != nil && !replaced {
+		out = append(out, &configVariable{name: name, value: *value})
+	}
+	return out
+}
+
+// writeAutoConfFile は設定を一時ファイルに書いてから、postgresql.auto.conf に置き換える
+// (write_auto_conf_file 相当)。書き込みに失敗した場合は元のファイルを残す。
+func writeAutoConfFile(path string, items []*configVariable) error {
+	var buf strings.Builder
+	buf.WriteString("# Do not edit this file manually!\n")
+	buf.WriteString("# It will be overwritten by the ALTER SYSTEM command.\n")
+	for _, item := range items {
+		fmt.Fprintf(&buf, "%s = %s\n", item.name, QuoteConfigValue(item.value))
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open file \"%s\": %s", tmp, platform.OSErrorMessage(err))
+	}
+	if _, err := f.WriteString(buf.String()); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("could not write to file \"%s\": %s", tmp, platform.OSErrorMessage(err))
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("could not fsync file \"%s\": %s", tmp, platform.OSErrorMessage(err))
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("could not close file \"%s\": %s", tmp, platform.OSErrorMessage(err))
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("could not rename file \"%s\" to \"%s\": %s", tmp, path, platform.OSErrorMessage(err))
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package guc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// postgresql.conf の見本 (utils/misc/postgresql.conf.sample 相当)
+// ----------------------------------------------------------------
+// initdb がデータディレクトリの postgresql.conf として書く。C言語版では見本を手で書いた
+// ファイルとして持つが、ここではパラメータの定義から作り、定義と見本が食い違わないようにする。
+// 全ての設定を既定値でコメントアウトして、分類ごとにまとめて並べる。
+
+const sampleHeader = `# -----------------------------
+# PostgreSQL configuration file
+# -----------------------------
+#
+# This file consists of lines of the form:
+#
+#   name = value
+#
+# (The "=" is optional.)  Whitespace may be used.  Comments are introduced with
+# "#" anywhere on a line.  The complete list of parameter names and allowed
+# values can be found in the PostgreSQL documentation.
+#
+# The commented-out settings shown in this file represent the default values.
+# Re-commenting a setting is NOT sufficient to revert it to the default value;
+# you need to reload the server.
+#
+# This file is read on server startup and when the server receives a SIGHUP
+# signal.  If you edit the file on a running system, you have to SIGHUP the
+# server for the changes to take effect, or execute "SELECT pg_reload_conf()".
+# Some parameters, which are marked below, require a server shutdown and
+# restart to take effect.
+#
+# Any parameter can also be given as a command-line option to the server, e.g.,
+# "postgres --log-connections=on".  Some parameters can be changed at run time
+# with the "SET" SQL command.
+#
+# Memory units:  B  = bytes            Time units:  us  = microseconds
+#                kB = kilobytes                     ms  = milliseconds
+#                MB = megabytes                     s   = seconds
+#                GB = gigabytes                     min = minutes
+#                TB = terabytes                     h   = hours
+#                                                   d   = days
+`
+
+const sampleTrailer = `
+
+#------------------------------------------------------------------------------
+# CONFIG FILE INCLUDES
+#------------------------------------------------------------------------------
+
+# These options allow settings to be loaded from files other than the
+# default postgresql.conf.  Note that these are directives, not variable
+# assignments, so they can usefully be given more than once.
+
+#include_dir = '...'			# include files ending in '.conf' from
+					# a directory, e.g., 'conf.d'
+#include_if_exists = '...'		# include file only if it exists
+#include = '...'			# include file
+
+
+#------------------------------------------------------------------------------
+# CUSTOMIZED OPTIONS
+#------------------------------------------------------------------------------
+
+# Add settings for extensions here
+`
+
+// WriteSampleConfig は postgresql.conf の見本を書く
+func WriteSampleConfig(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(sampleHeader)
+
+	// 分類の順に、同じ分類の中では定義の順に並べる
+	byGroup := make(map[ConfigGroup][]configVar)
+	for _, v := range configureNames {
+		g := v.generic()
+		if g.Context == PGCInternal || g.Flags&(GucNotInSample|GucDisallowInFile) != 0 {
+			continue
+		}
+		byGroup[g.Group] = append(byGroup[g.Group], v)
+	}
+	section := ""
+	for group := range ConfigGroup(len(configGroupNames)) {
+		vars := byGroup[group]
+		if len(vars) == 0 {
+			continue
+		}
+		// "分類 / 小分類" の分類を見出しに、小分類を小見出しにする
+		top, sub, _ := strings.Cut(group.String(), " / ")
+		if top != section {
+			section = top
+			fmt.Fprintf(bw, "\n\n#%s\n# %s\n#%s\n", strings.Repeat("-", 78), strings.ToUpper(top), strings.Repeat("-", 78))
+		}
+		if sub != "" {
+			fmt.Fprintf(bw, "\n# - %s -\n", sub)
+		}
+		bw.WriteString("\n")
+		for _, v := range vars {
+			line := "#" + v.generic().Name + " = " + sampleValue(v)
+			if v.generic().Context == PGCPostmaster {
+				line += "\t\t# (change requires restart)"
+			}
+			bw.WriteString(line + "\n")
+		}
+	}
+
+	bw.WriteString(sampleTrailer)
+	return bw.Flush()
+}
+
+// simpleConfigValue は引用符で囲まずに設定ファイルに書ける値 (数値、単位付きの数値、識別子)
+var simpleConfigValue = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+
+// sampleValue は既定値を設定ファイルに書く形にする。文字列と、空白などを含む値は引用符で囲む。
+func sampleValue(v configVar) string {
+	s := v.show(v.bootValue())
+	if v.vartype() == PGCString || !simpleConfigValue.MatchString(s) {
+		return QuoteConfigValue(s)
+	}
+	return s
+}
+
+// QuoteConfigValue は値を設定ファイルの単一引用符で囲んだ文字列にする。値の中の ' と \ は
+// エスケープする (escape_single_quotes_ascii 相当)。
+func QuoteConfigValue(value string) string {
+	return "'" + strings.NewReplacer(`'`, `''`, `\`, `\\`).Replace(value) + "'"
+}
@@ -0,0 +1,773 @@
+package guc
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// 組み込みのパラメータの定義 (utils/misc/guc_tables.c 相当)
+// ----------------------------------------------------------------
+// C言語版と同じく、パラメータごとに値を持つ変数を定義し、configureNames に並べる。
+// 接続の受け付けに使うパラメータ (listen_addresses など) と、connection_attempt_limit と
+// connection_attempt_window は postmaster が、それ以外は主にバックエンドが読む。
+
+// 接続と認証
+var (
+	ListenAddresses = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "listen_addresses", Context: PGCPostmaster, Group: ConnAuthSettings,
+			ShortDesc: "Sets the host name or IP address(es) to listen to."},
+		BootVal: "localhost",
+	}
+	Port = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "port", Context: PGCPostmaster, Group: ConnAuthSettings,
+			ShortDesc: "Sets the TCP port the server listens on."},
+		BootVal: pgconfig.DefPgPort, Min: 1, Max: 65535,
+	}
+	UnixSocketDirectories = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "unix_socket_directories", Context: PGCPostmaster, Group: ConnAuthSettings,
+			ShortDesc: "Sets the directories where Unix-domain sockets will be created."},
+		BootVal: pgconfig.DefaultPgSocketDir,
+	}
+	UnixSocketPermissions = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "unix_socket_permissions", Context: PGCPostmaster, Group: ConnAuthSettings,
+			ShortDesc: "Sets the access permissions of the Unix-domain socket.",
+			LongDesc:  "Unix-domain sockets use the usual Unix file system permission set. The parameter value is expected to be a numeric mode specification in the form accepted by the chmod and umask system calls. (To use the customary octal format the number must start with a 0 (zero).)"},
+		BootVal: 0777, Min: 0, Max: 0777,
+		// 8進数で表示する (show_unix_socket_permissions 相当)
+		ShowHook: func(n int) string { return fmt.Sprintf("%04o", n) },
+	}
+	MaxConnections = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "max_connections", Context: PGCPostmaster, Group: ConnAuthSettings,
+			ShortDesc: "Sets the maximum number of concurrent connections."},
+		BootVal: 100, Min: 1, Max: 262143,
+	}
+	SuperuserReservedConnections = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "superuser_reserved_connections", Context: PGCPostmaster, Group: ConnAuthSettings,
+			ShortDesc: "Sets the number of connection slots reserved for superusers."},
+		BootVal: 3, Min: 0, Max: 262143,
+	}
+	ReservedConnections = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "reserved_connections", Context: PGCPostmaster, Group: ConnAuthSettings,
+			ShortDesc: "Sets the number of connection slots reserved for roles with privileges of pg_use_reserved_connections."},
+		BootVal: 0, Min: 0, Max: 262143,
+	}
+	// TCP のキープアライブは接続を受け付けた時点で設定するため、C言語版と異なりセッションごとには変更できない
+	TCPKeepalivesIdle = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "tcp_keepalives_idle", Context: PGCSighup, Group: ConnAuthTCP, Flags: GucUnitS,
+			ShortDesc: "Time between issuing TCP keepalives.",
+			LongDesc:  "A value of 0 uses the system default."},
+		BootVal: 0, Min: 0, Max: math.MaxInt32,
+	}
+	TCPKeepalivesInterval = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "tcp_keepalives_interval", Context: PGCSighup, Group: ConnAuthTCP, Flags: GucUnitS,
+			ShortDesc: "Time between TCP keepalive retransmits.",
+			LongDesc:  "A value of 0 uses the system default."},
+		BootVal: 0, Min: 0, Max: math.MaxInt32,
+	}
+	TCPKeepalivesCount = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "tcp_keepalives_count", Context: PGCSighup, Group: ConnAuthTCP,
+			ShortDesc: "Maximum number of TCP keepalive retransmits.",
+			LongDesc:  "Number of consecutive keepalive retransmits that can be lost before a connection is considered dead. A value of 0 uses the system default."},
+		BootVal: 0, Min: 0, Max: math.MaxInt32,
+	}
+	AuthenticationTimeout = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "authentication_timeout", Context: PGCSighup, Group: ConnAuthAuth, Flags: GucUnitS,
+			ShortDesc: "Sets the maximum allowed time to complete client authentication."},
+		BootVal: 60, Min: 1, Max: 600,
+	}
+	// ConnectionAttemptLimit と ConnectionAttemptWindow は C言語版にないパラメータ。
+	// 1つの接続元 IP アドレスから window の間に受け付ける接続の数を制限する。0 は制限しない
+	ConnectionAttemptLimit = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "connection_attempt_limit", Context: PGCSighup, Group: ConnAuthAuth,
+			ShortDesc: "Sets the maximum number of connection attempts per client address within the window.",
+			LongDesc:  "A value of 0 disables the limit."},
+		BootVal: 0, Min: 0, Max: math.MaxInt32,
+	}
+	ConnectionAttemptWindow = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "connection_attempt_window", Context: PGCSighup, Group: ConnAuthAuth, Flags: GucUnitS,
+			ShortDesc: "Sets the length of the connection attempt window."},
+		BootVal: 60, Min: 1, Max: 3600,
+	}
+	PasswordEncryption = &ConfigEnum{
+		ConfigGeneric: ConfigGeneric{Name: "password_encryption", Context: PGCUserset, Group: ConnAuthAuth,
+			ShortDesc: "Chooses the algorithm for encrypting passwords."},
+		BootVal: "scram-sha-256", Options: []string{"md5", "scram-sha-256"},
+	}
+	ScramIterations = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "scram_iterations", Context: PGCUserset, Group: ConnAuthAuth, Flags: GucReport,
+			ShortDesc: "Sets the iteration count for SCRAM secret generation."},
+		BootVal: 4096, Min: 1, Max: math.MaxInt32,
+	}
+)
+
+// SSL
+var (
+	EnableSSL = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "ssl", Context: PGCSighup, Group: ConnAuthSSL,
+			ShortDesc: "Enables SSL connections."},
+	}
+	SSLCertFile = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "ssl_cert_file", Context: PGCSighup, Group: ConnAuthSSL,
+			ShortDesc: "Location of the SSL server certificate file."},
+		BootVal: "server.crt",
+	}
+	SSLKeyFile = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "ssl_key_file", Context: PGCSighup, Group: ConnAuthSSL,
+			ShortDesc: "Location of the SSL server private key file."},
+		BootVal: "server.key",
+	}
+	SSLCAFile = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "ssl_ca_file", Context: PGCSighup, Group: ConnAuthSSL,
+			ShortDesc: "Location of the SSL certificate authority file."},
+	}
+)
+
+// ファイルの場所
+var (
+	// DataDirectory を指定すると、ConfigFile、HbaFile と IdentFile の既定値はデータディレクトリの
+	// 中のファイルになる (SelectConfigFiles)。どちらも空の場合は設定ファイルを読まない
+	DataDirectory = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "data_directory", Context: PGCPostmaster, Group: FileLocations, Flags: GucDisallowInAutoFile | GucSuperuserOnly,
+			ShortDesc: "Sets the server's data directory."},
+	}
+	ConfigFile = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "config_file", Context: PGCPostmaster, Group: FileLocations, Flags: GucDisallowInFile | GucSuperuserOnly,
+			ShortDesc: "Sets the server's main configuration file."},
+	}
+	HbaFile = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "hba_file", Context: PGCPostmaster, Group: FileLocations, Flags: GucSuperuserOnly,
+			ShortDesc: "Sets the server's \"hba\" configuration file."},
+	}
+	IdentFile = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "ident_file", Context: PGCPostmaster, Group: FileLocations, Flags: GucSuperuserOnly,
+			ShortDesc: "Sets the server's \"ident\" configuration file."},
+	}
+	// ClusterKeyFile はファイル暗号化の鍵暗号化鍵を置いたファイル。initdb でファイル暗号化を
+	// 有効にしたクラスタでだけ使う (crypto.InitializeFileEncryption)
+	ClusterKeyFile = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "cluster_key_file", Context: PGCPostmaster, Group: FileLocations, Flags: GucSuperuserOnly,
+			ShortDesc: "Location of the cluster key file used to encrypt data files."},
+	}
+)
+
+// コストに基づく VACUUM の遅延。autovacuum_vacuum_cost_* が -1 の場合、autovacuum は
+// vacuum_cost_* の値を使う
+var (
+	VacuumCostDelay = &ConfigReal{
+		ConfigGeneric: ConfigGeneric{Name: "vacuum_cost_delay", Context: PGCUserset, Group: ResourcesVacuumDelay, Flags: GucUnitMS,
+			ShortDesc: "Vacuum cost delay in milliseconds."},
+		BootVal: 0, Min: 0, Max: 100,
+	}
+	VacuumCostPageHit = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "vacuum_cost_page_hit", Context: PGCUserset, Group: ResourcesVacuumDelay,
+			ShortDesc: "Vacuum cost for a page found in the buffer cache."},
+		BootVal: 1, Min: 0, Max: 10000,
+	}
+	VacuumCostPageMiss = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "vacuum_cost_page_miss", Context: PGCUserset, Group: ResourcesVacuumDelay,
+			ShortDesc: "Vacuum cost for a page not found in the buffer cache."},
+		BootVal: 2, Min: 0, Max: 10000,
+	}
+	VacuumCostPageDirty = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "vacuum_cost_page_dirty", Context: PGCUserset, Group: ResourcesVacuumDelay,
+			ShortDesc: "Vacuum cost for a page dirtied by vacuum."},
+		BootVal: 20, Min: 0, Max: 10000,
+	}
+	VacuumCostLimit = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "vacuum_cost_limit", Context: PGCUserset, Group: ResourcesVacuumDelay,
+			ShortDesc: "Vacuum cost amount available before napping."},
+		BootVal: 200, Min: 1, Max: 10000,
+	}
+	AutovacuumVacuumCostDelay = &ConfigReal{
+		ConfigGeneric: ConfigGeneric{Name: "autovacuum_vacuum_cost_delay", Context: PGCSighup, Group: Autovacuum, Flags: GucUnitMS,
+			ShortDesc: "Vacuum cost delay in milliseconds, for autovacuum.", LongDesc: "-1 means use \"vacuum_cost_delay\"."},
+		BootVal: 2, Min: -1, Max: 100,
+	}
+	AutovacuumVacuumCostLimit = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "autovacuum_vacuum_cost_limit", Context: PGCSighup, Group: Autovacuum,
+			ShortDesc: "Vacuum cost amount available before napping, for autovacuum.", LongDesc: "-1 means use \"vacuum_cost_limit\"."},
+		BootVal: -1, Min: -1, Max: 10000,
+	}
+)
+
+// autovacuum。ランチャーが autovacuum_naptime ごとに各データベースにワーカーを起動し、ワーカーは
+// 閾値と係数から決めた数を超えて変更されたテーブルを VACUUM、ANALYZE する
+var (
+	AutovacuumStartDaemon = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "autovacuum", Context: PGCSighup, Group: Autovacuum,
+			ShortDesc: "Starts the autovacuum subprocess."},
+		BootVal: true,
+	}
+	AutovacuumNaptime = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "autovacuum_naptime", Context: PGCSighup, Group: Autovacuum, Flags: GucUnitS,
+			ShortDesc: "Time to sleep between autovacuum runs."},
+		BootVal: 60, Min: 1, Max: math.MaxInt32 / 1000,
+	}
+	AutovacuumVacuumThreshold = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "autovacuum_vacuum_threshold", Context: PGCSighup, Group: Autovacuum,
+			ShortDesc: "Minimum number of tuple updates or deletes prior to vacuum."},
+		BootVal: 50, Min: 0, Max: math.MaxInt32,
+	}
+	AutovacuumVacuumInsertThreshold = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "autovacuum_vacuum_insert_threshold", Context: PGCSighup, Group: Autovacuum,
+			ShortDesc: "Minimum number of tuple inserts prior to vacuum, or -1 to disable insert vacuums."},
+		BootVal: 1000, Min: -1, Max: math.MaxInt32,
+	}
+	AutovacuumAnalyzeThreshold = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "autovacuum_analyze_threshold", Context: PGCSighup, Group: Autovacuum,
+			ShortDesc: "Minimum number of tuple inserts, updates, or deletes prior to analyze."},
+		BootVal: 50, Min: 0, Max: math.MaxInt32,
+	}
+	AutovacuumVacuumScaleFactor = &ConfigReal{
+		ConfigGeneric: ConfigGeneric{Name: "autovacuum_vacuum_scale_factor", Context: PGCSighup, Group: Autovacuum,
+			ShortDesc: "Number of tuple updates or deletes prior to vacuum as a fraction of reltuples."},
+		BootVal: 0.2, Min: 0, Max: 100,
+	}
+	AutovacuumVacuumInsertScaleFactor = &ConfigReal{
+		ConfigGeneric: ConfigGeneric{Name: "autovacuum_vacuum_insert_scale_factor", Context: PGCSighup, Group: Autovacuum,
+			ShortDesc: "Number of tuple inserts prior to vacuum as a fraction of reltuples."},
+		BootVal: 0.2, Min: 0, Max: 100,
+	}
+	AutovacuumAnalyzeScaleFactor = &ConfigReal{
+		ConfigGeneric: ConfigGeneric{Name: "autovacuum_analyze_scale_factor", Context: PGCSighup, Group: Autovacuum,
+			ShortDesc: "Number of tuple inserts, updates, or deletes prior to analyze as a fraction of reltuples."},
+		BootVal: 0.1, Min: 0, Max: 100,
+	}
+)
+
+// サーバー内部のプロセス。接続の枠とは別に、それらのための枠をバックエンドの一覧に確保する
+var (
+	MaxWorkerProcesses = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "max_worker_processes", Context: PGCPostmaster, Group: ResourcesAsynchronous,
+			ShortDesc: "Maximum number of concurrent worker processes."},
+		BootVal: 8, Min: 0, Max: 262143,
+	}
+	AutovacuumMaxWorkers = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "autovacuum_max_workers", Context: PGCPostmaster, Group: Autovacuum,
+			ShortDesc: "Sets the maximum number of simultaneously running autovacuum worker processes."},
+		BootVal: 3, Min: 1, Max: 262143,
+	}
+)
+
+// 長いトランザクションによる肥大化を抑えるため、古いスナップショットから見える行も VACUUM が
+// 削除できるようにする
+var (
+	OldSnapshotThreshold = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "old_snapshot_threshold", Context: PGCPostmaster, Group: ResourcesAsynchronous, Flags: GucUnitMin,
+			ShortDesc: "Time before a snapshot is too old to read pages changed after the snapshot was taken.",
+			LongDesc:  "A value of -1 disables this feature."},
+		BootVal: -1, Min: -1, Max: 60 * 24 * 60,
+	}
+)
+
+// 先読み。ビットマップヒープスキャンは、これから読むページの読み込みを OS に
+// effective_io_concurrency 個まで先に始めさせる。先読みできない環境では 0 だけを受け付ける
+var (
+	EffectiveIoConcurrency = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "effective_io_concurrency", Context: PGCUserset, Group: ResourcesAsynchronous,
+			ShortDesc: "Number of simultaneous requests that can be handled efficiently by the disk subsystem."},
+		BootVal: defaultEffectiveIoConcurrency, Min: 0, Max: MaxIoConcurrency,
+		CheckHook: checkEffectiveIoConcurrency,
+	}
+)
+
+// MaxIoConcurrency は effective_io_concurrency の上限 (MAX_IO_CONCURRENCY 相当)
+const MaxIoConcurrency = 1000
+
+// defaultEffectiveIoConcurrency は effective_io_concurrency の既定値
+// (DEFAULT_EFFECTIVE_IO_CONCURRENCY 相当)
+var defaultEffectiveIoConcurrency = func() int {
+	if platform.PrefetchSupported {
+		return 1
+	}
+	return 0
+}()
+
+// checkEffectiveIoConcurrency は effective_io_concurrency の値を確かめる
+// (check_effective_io_concurrency 相当)
+func checkEffectiveIoConcurrency(value int) (string, bool) {
+	if !platform.PrefetchSupported && value != 0 {
+		return "effective_io_concurrency must be set to 0 on platforms that lack posix_fadvise().", false
+	}
+	return "", true
+}
+
+// バックグラウンドライター。共有バッファの汚れたページを、クロックスイープが再利用する前に書き出す
+var (
+	BgWriterDelay = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "bgwriter_delay", Context: PGCSighup, Group: ResourcesBgWriter, Flags: GucUnitMS,
+			ShortDesc: "Background writer sleep time between rounds."},
+		BootVal: 200, Min: 10, Max: 10000,
+	}
+	BgWriterLRUMaxPages = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "bgwriter_lru_maxpages", Context: PGCSighup, Group: ResourcesBgWriter,
+			ShortDesc: "Background writer maximum number of LRU pages to flush per round.", LongDesc: "0 disables background writing."},
+		BootVal: 100, Min: 0, Max: math.MaxInt32 / 2,
+	}
+	BgWriterLRUMultiplier = &ConfigReal{
+		ConfigGeneric: ConfigGeneric{Name: "bgwriter_lru_multiplier", Context: PGCSighup, Group: ResourcesBgWriter,
+			ShortDesc: "Multiple of the average buffer usage to free per round."},
+		BootVal: 2.0, Min: 0, Max: 10,
+	}
+)
+
+// メモリ。共有バッファの数はサーバーの起動時に決め、共有メモリに確保する
+var (
+	SharedBuffers = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "shared_buffers", Context: PGCPostmaster, Group: ResourcesMem, Flags: GucUnitBlocks,
+			ShortDesc: "Sets the number of shared memory buffers used by the server."},
+		BootVal: 16384, Min: 16, Max: math.MaxInt32 / 2,
+	}
+)
+
+// ディスクの使用量。一時ファイルの大きさの合計をセッションごとに制限する
+var (
+	// 一般のユーザーが自分で制限を外せないよう、スーパーユーザーだけが変更できる
+	TempFileLimit = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "temp_file_limit", Context: PGCSuset, Group: ResourcesDisk, Flags: GucUnitKB,
+			ShortDesc: "Limits the total size of all temporary files used by each process.",
+			LongDesc:  "-1 means no limit."},
+		BootVal: -1, Min: -1, Max: math.MaxInt32,
+	}
+)
+
+// カーネルの資源。仮想ファイル記述子が同時に開いておくファイルの数を制限する
+var (
+	MaxFilesPerProcess = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "max_files_per_process", Context: PGCPostmaster, Group: ResourcesKernel,
+			ShortDesc: "Sets the maximum number of simultaneously open files for each server process."},
+		BootVal: 1000, Min: 64, Max: math.MaxInt32,
+	}
+)
+
+// WAL の書き出し。synchronous_commit が off のトランザクションは WAL の同期を待たずにコミットを
+// 終え、WAL writer が wal_writer_delay ごとに同期する
+var (
+	SynchronousCommit = &ConfigEnum{
+		ConfigGeneric: ConfigGeneric{Name: "synchronous_commit", Context: PGCUserset, Group: WalSettings,
+			ShortDesc: "Sets the current transaction's synchronization level."},
+		BootVal: "on", Options: []string{"local", "remote_write", "remote_apply", "on", "off"},
+	}
+	WalWriterDelay = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "wal_writer_delay", Context: PGCSighup, Group: WalSettings, Flags: GucUnitMS,
+			ShortDesc: "Time between WAL flushes performed in the WAL writer."},
+		BootVal: 200, Min: 1, Max: 10000,
+	}
+	WalWriterFlushAfter = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "wal_writer_flush_after", Context: PGCSighup, Group: WalSettings, Flags: GucUnitXBlocks,
+			ShortDesc: "Amount of WAL written out by WAL writer that triggers a flush."},
+		BootVal: (1024 * 1024) / pgconfig.XLogBlckSz, Min: 0, Max: math.MaxInt32,
+	}
+)
+
+// チェックポイント。WAL がまだないため、checkpoint_timeout の経過と CHECKPOINT だけが
+// チェックポイントの契機になる
+var (
+	CheckPointTimeout = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "checkpoint_timeout", Context: PGCSighup, Group: WalCheckpoints, Flags: GucUnitS,
+			ShortDesc: "Sets the maximum time between automatic WAL checkpoints."},
+		BootVal: 300, Min: 30, Max: 86400,
+	}
+	CheckPointCompletionTarget = &ConfigReal{
+		ConfigGeneric: ConfigGeneric{Name: "checkpoint_completion_target", Context: PGCSighup, Group: WalCheckpoints,
+			ShortDesc: "Time spent flushing dirty buffers during checkpoint, as fraction of checkpoint interval."},
+		BootVal: 0.9, Min: 0, Max: 1,
+	}
+)
+
+// WAL のアーカイブ。archive_mode が off でなければ、アーカイバが pg_wal/archive_status の
+// .ready のファイルが示す WAL セグメントを archive_command でアーカイブする
+var (
+	ArchiveMode = &ConfigEnum{
+		ConfigGeneric: ConfigGeneric{Name: "archive_mode", Context: PGCPostmaster, Group: WalArchiving,
+			ShortDesc: "Allows archiving of WAL files using \"archive_command\"."},
+		BootVal: "off", Options: []string{"always", "on", "off"},
+	}
+	ArchiveCommand = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "archive_command", Context: PGCSighup, Group: WalArchiving, Flags: GucSuperuserOnly,
+			ShortDesc: "Sets the shell command that will be called to archive a WAL file."},
+	}
+)
+
+// ログの出力先。csvlog と jsonlog は logging_collector が on のときだけファイルに書き、off なら
+// stderr と同じ形式で標準エラー出力に書く
+var (
+	LogDestination = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "log_destination", Context: PGCSighup, Group: LoggingWhere,
+			ShortDesc: "Sets the destination for server log output.",
+			LongDesc:  "Valid values are combinations of \"stderr\", \"csvlog\", and \"jsonlog\", depending on the platform."},
+		BootVal:   "stderr",
+		CheckHook: checkLogDestination,
+	}
+)
+
+// LogDestinationKeywords は log_destination に書ける出力先
+var LogDestinationKeywords = []string{"stderr", "csvlog", "jsonlog"}
+
+// checkLogDestination は log_destination の値を確かめる (check_log_destination 相当)
+func checkLogDestination(value string) (string, bool) {
+	list, ok := adt.SplitGUCList(value, ',')
+	if !ok {
+		return "List syntax is invalid.", false
+	}
+	for _, tok := range list {
+		if !slices.ContainsFunc(LogDestinationKeywords, func(k string) bool { return strings.EqualFold(k, tok) }) {
+			return fmt.Sprintf("Unrecognized key word: \"%s\".", tok), false
+		}
+	}
+	return "", true
+}
+
+// ログの収集。logging_collector が on なら、全てのプロセスの標準エラー出力を1つのゴルーチンが
+// 受け取り、log_directory のファイルに書いて log_rotation_age と log_rotation_size で切り替える
+var (
+	LoggingCollector = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "logging_collector", Context: PGCPostmaster, Group: LoggingWhere,
+			ShortDesc: "Start a subprocess to capture stderr output and/or csvlogs into log files."},
+	}
+	LogDirectory = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "log_directory", Context: PGCSighup, Group: LoggingWhere,
+			ShortDesc: "Sets the destination directory for log files.",
+			LongDesc:  "Can be specified as relative to the data directory or as absolute path."},
+		BootVal: "log",
+	}
+	LogFilename = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "log_filename", Context: PGCSighup, Group: LoggingWhere,
+			ShortDesc: "Sets the file name pattern for log files."},
+		BootVal: "postgresql-%Y-%m-%d_%H%M%S.log",
+	}
+	LogFileMode = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "log_file_mode", Context: PGCSighup, Group: LoggingWhere,
+			ShortDesc: "Sets the file permissions for log files.",
+			LongDesc:  "The parameter value is expected to be a numeric mode specification in the form accepted by the chmod and umask system calls. (To use the customary octal format the number must start with a 0 (zero).)"},
+		BootVal: 0600, Min: 0, Max: 0777,
+		// 8進数で表示する (show_log_file_mode 相当)
+		ShowHook: func(n int) string { return fmt.Sprintf("%04o", n) },
+	}
+	LogRotationAge = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "log_rotation_age", Context: PGCSighup, Group: LoggingWhere, Flags: GucUnitMin,
+			ShortDesc: "Sets the amount of time to wait before forcing log file rotation."},
+		BootVal: 24 * 60, Min: 0, Max: math.MaxInt32 / 60,
+	}
+	LogRotationSize = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "log_rotation_size", Context: PGCSighup, Group: LoggingWhere, Flags: GucUnitKB,
+			ShortDesc: "Sets the maximum size a log file can reach before being rotated."},
+		BootVal: 10 * 1024, Min: 0, Max: math.MaxInt32 / 1024,
+	}
+	LogTruncateOnRotation = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "log_truncate_on_rotation", Context: PGCSighup, Group: LoggingWhere,
+			ShortDesc: "Truncate existing log files of same name during log rotation."},
+	}
+)
+
+// ログに出すメッセージの水準。client_min_messages はクライアントに送るメッセージの水準で、
+// ERROR 以上はいつも送る
+var (
+	LogMinMessages = &ConfigEnum{
+		ConfigGeneric: ConfigGeneric{Name: "log_min_messages", Context: PGCSuset, Group: LoggingWhen,
+			ShortDesc: "Sets the message levels that are logged.",
+			LongDesc:  "Each level includes all the levels that follow it. The later the level, the fewer messages are sent."},
+		BootVal: "warning", Options: serverMessageLevelOptions,
+	}
+	LogMinErrorStatement = &ConfigEnum{
+		ConfigGeneric: ConfigGeneric{Name: "log_min_error_statement", Context: PGCSuset, Group: LoggingWhen,
+			ShortDesc: "Causes all statements generating error at or above this level to be logged.",
+			LongDesc:  "Each level includes all the levels that follow it. The later the level, the fewer messages are sent."},
+		BootVal: "error", Options: serverMessageLevelOptions,
+	}
+	ClientMinMessages = &ConfigEnum{
+		ConfigGeneric: ConfigGeneric{Name: "client_min_messages", Context: PGCUserset, Group: ClientConnStatement,
+			ShortDesc: "Sets the message levels that are sent to the client.",
+			LongDesc:  "Each level includes all the levels that follow it. The later the level, the fewer messages are sent."},
+		BootVal: "notice", Options: clientMessageLevelOptions,
+	}
+)
+
+// serverMessageLevelOptions と clientMessageLevelOptions はメッセージの水準の選択肢
+// (server_message_level_options, client_message_level_options 相当)。LOG の位置が異なる
+var (
+	serverMessageLevelOptions = []string{"debug5", "debug4", "debug3", "debug2", "debug1",
+		"info", "notice", "warning", "error", "log", "fatal", "panic"}
+	clientMessageLevelOptions = []string{"debug5", "debug4", "debug3", "debug2", "debug1",
+		"log", "notice", "warning", "error"}
+)
+
+// ログ出力と障害時の動作
+var (
+	LogLinePrefix = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "log_line_prefix", Context: PGCSighup, Group: LoggingWhat,
+			ShortDesc: "Controls information prefixed to each log line.",
+			LongDesc:  "If blank, no prefix is used."},
+		BootVal: "%m [%p] ",
+	}
+	LogCheckpoints = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "log_checkpoints", Context: PGCSighup, Group: LoggingWhat,
+			ShortDesc: "Logs each checkpoint."},
+		BootVal: true,
+	}
+	LogConnections = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "log_connections", Context: PGCSuBackend, Group: LoggingWhat,
+			ShortDesc: "Logs each successful connection."},
+	}
+	LogDisconnections = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "log_disconnections", Context: PGCSuBackend, Group: LoggingWhat,
+			ShortDesc: "Logs end of a session, including duration."},
+	}
+	LogLockWaits = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "log_lock_waits", Context: PGCSuset, Group: LoggingWhat,
+			ShortDesc: "Logs long lock waits."},
+	}
+	// 一般のユーザーが自分で記録を止められないよう、スーパーユーザーだけが変更できる
+	LogTempFiles = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "log_temp_files", Context: PGCSuset, Group: LoggingWhat, Flags: GucUnitKB,
+			ShortDesc: "Log the use of temporary files larger than this number of kilobytes.",
+			LongDesc:  "Zero logs all files. The default is -1 (turning this feature off)."},
+		BootVal: -1, Min: -1, Max: math.MaxInt32,
+	}
+	RestartAfterCrash = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "restart_after_crash", Context: PGCSighup, Group: ErrorHandlingOptions,
+			ShortDesc: "Reinitialize server after backend crash."},
+		BootVal: true,
+	}
+)
+
+// ロックの管理。deadlock_timeout を超えてロックを待つと、log_lock_waits が on ならログに出す
+var (
+	DeadlockTimeout = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "deadlock_timeout", Context: PGCSuset, Group: LockManagement, Flags: GucUnitMS,
+			ShortDesc: "Sets the time to wait on a lock before checking for deadlock."},
+		BootVal: 1000, Min: 1, Max: math.MaxInt32,
+	}
+)
+
+// 問い合わせ ID。auto の場合は、問い合わせ ID を使うモジュールが有効にしたときだけ計算する
+var (
+	ComputeQueryId = &ConfigEnum{
+		ConfigGeneric: ConfigGeneric{Name: "compute_query_id", Context: PGCSuset, Group: StatsMonitoring,
+			ShortDesc: "Enables in-core computation of query identifiers."},
+		BootVal: "auto", Options: []string{"auto", "regress", "on", "off"},
+	}
+)
+
+// 累積統計。バックエンドの活動とテーブルやインデックスの操作の回数、関数の呼び出しを記録し、
+// pg_stat_activity や pg_stat_user_tables、pg_stat_user_functions で参照できるようにする
+var (
+	TrackActivities = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "track_activities", Context: PGCSuset, Group: StatsCumulative,
+			ShortDesc: "Collects information about executing commands.",
+			LongDesc:  "Enables the collection of information on the currently executing command of each session, along with the time at which that command began execution."},
+		BootVal: true,
+	}
+	TrackCounts = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "track_counts", Context: PGCSuset, Group: StatsCumulative,
+			ShortDesc: "Collects statistics on database activity."},
+		BootVal: true,
+	}
+	TrackFunctions = &ConfigEnum{
+		ConfigGeneric: ConfigGeneric{Name: "track_functions", Context: PGCSuset, Group: StatsCumulative,
+			ShortDesc: "Collects function-level statistics on database activity."},
+		BootVal: "none", Options: []string{"none", "pl", "all"},
+	}
+	TrackIoTiming = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "track_io_timing", Context: PGCSuset, Group: StatsCumulative,
+			ShortDesc: "Collects timing statistics for database I/O activity."},
+	}
+	// 問い合わせの文字列を記録する領域はバックエンドの一覧と共に確保するため、起動時にしか変更できない
+	TrackActivityQuerySize = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "track_activity_query_size", Context: PGCPostmaster, Group: ResourcesMem, Flags: GucUnitByte,
+			ShortDesc: "Sets the size reserved for pg_stat_activity.query, in bytes."},
+		BootVal: 1024, Min: 100, Max: 1048576,
+	}
+)
+
+// クライアントの接続の既定値
+var (
+	ApplicationName = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "application_name", Context: PGCUserset, Group: LoggingWhat, Flags: GucReport,
+			ShortDesc: "Sets the application name to be reported in statistics and logs."},
+	}
+	ClientEncoding = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "client_encoding", Context: PGCUserset, Group: ClientConnLocale, Flags: GucReport,
+			ShortDesc: "Sets the client's character set encoding."},
+		BootVal: "UTF8",
+	}
+	DateStyle = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "DateStyle", Context: PGCUserset, Group: ClientConnLocale, Flags: GucReport,
+			ShortDesc: "Sets the display format for date and time values.",
+			LongDesc:  "Also controls interpretation of ambiguous date inputs."},
+		BootVal: "ISO, MDY",
+	}
+	ExtraFloatDigits = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "extra_float_digits", Context: PGCUserset, Group: ClientConnLocale,
+			ShortDesc: "Sets the number of digits displayed for floating-point values.",
+			LongDesc:  "This affects real, double precision, and geometric data types. A zero or negative parameter value is added to the standard number of digits (FLT_DIG or DBL_DIG as appropriate). Any value greater than zero selects precise output mode."},
+		BootVal: 1, Min: -15, Max: 3,
+	}
+	IntervalStyle = &ConfigEnum{
+		ConfigGeneric: ConfigGeneric{Name: "IntervalStyle", Context: PGCUserset, Group: ClientConnLocale, Flags: GucReport,
+			ShortDesc: "Sets the display format for interval values."},
+		BootVal: "postgres", Options: []string{"postgres", "postgres_verbose", "sql_standard", "iso_8601"},
+	}
+	SearchPath = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "search_path", Context: PGCUserset, Group: ClientConnStatement, Flags: GucReport,
+			ShortDesc: "Sets the schema search order for names that are not schema-qualified."},
+		BootVal: "\"$user\", public",
+	}
+	StandardConformingStrings = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "standard_conforming_strings", Context: PGCUserset, Group: CompatOptionsPrevious, Flags: GucReport,
+			ShortDesc: "Causes '...' strings to treat backslashes literally."},
+		BootVal: true,
+	}
+	SynchronizeSeqscans = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "synchronize_seqscans", Context: PGCUserset, Group: CompatOptionsPrevious,
+			ShortDesc: "Enable synchronized sequential scans."},
+		BootVal: true,
+	}
+	TimeZone = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "TimeZone", Context: PGCUserset, Group: ClientConnLocale, Flags: GucReport,
+			ShortDesc: "Sets the time zone for displaying and interpreting time stamps."},
+		BootVal: "UTC",
+	}
+	StatementTimeout = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "statement_timeout", Context: PGCUserset, Group: ClientConnStatement, Flags: GucUnitMS,
+			ShortDesc: "Sets the maximum allowed duration of any statement.",
+			LongDesc:  "A value of 0 turns off the timeout."},
+		BootVal: 0, Min: 0, Max: math.MaxInt32,
+	}
+	LockTimeout = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "lock_timeout", Context: PGCUserset, Group: ClientConnStatement, Flags: GucUnitMS,
+			ShortDesc: "Sets the maximum allowed duration of any wait for a lock.",
+			LongDesc:  "A value of 0 turns off the timeout."},
+		BootVal: 0, Min: 0, Max: math.MaxInt32,
+	}
+	IdleInTransactionSessionTimeout = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "idle_in_transaction_session_timeout", Context: PGCUserset, Group: ClientConnStatement, Flags: GucUnitMS,
+			ShortDesc: "Sets the maximum allowed idle time between queries, when in a transaction.",
+			LongDesc:  "A value of 0 turns off the timeout."},
+		BootVal: 0, Min: 0, Max: math.MaxInt32,
+	}
+	// 暴走した問い合わせから接続を守るため、1つの文がクライアントに返す結果を制限する
+	StatementRowLimit = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "statement_row_limit", Context: PGCUserset, Group: ClientConnStatement,
+			ShortDesc: "Sets the maximum number of rows any statement may return.",
+			LongDesc:  "-1 means no limit."},
+		BootVal: -1, Min: -1, Max: math.MaxInt32,
+	}
+	StatementResultSizeLimit = &ConfigInt{
+		ConfigGeneric: ConfigGeneric{Name: "statement_result_size_limit", Context: PGCUserset, Group: ClientConnStatement, Flags: GucUnitKB,
+			ShortDesc: "Sets the maximum total size of the rows any statement may return.",
+			LongDesc:  "-1 means no limit."},
+		BootVal: -1, Min: -1, Max: math.MaxInt32,
+	}
+	DefaultTransactionIsolation = &ConfigEnum{
+		ConfigGeneric: ConfigGeneric{Name: "default_transaction_isolation", Context: PGCUserset, Group: ClientConnStatement,
+			ShortDesc: "Sets the transaction isolation level of each new transaction."},
+		BootVal: "read committed", Options: isolationLevelOptions,
+	}
+	DefaultTransactionReadOnly = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "default_transaction_read_only", Context: PGCUserset, Group: ClientConnStatement, Flags: GucReport,
+			ShortDesc: "Sets the default read-only status of new transactions."},
+	}
+	DefaultTransactionDeferrable = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "default_transaction_deferrable", Context: PGCUserset, Group: ClientConnStatement,
+			ShortDesc: "Sets the default deferrable status of new transactions."},
+	}
+)
+
+// 実行中のトランザクションの特性。トランザクションの開始時に default_transaction_* の値にし、
+// SET TRANSACTION で変更する
+var (
+	TransactionIsolation = &ConfigEnum{
+		ConfigGeneric: ConfigGeneric{Name: "transaction_isolation", Context: PGCUserset, Group: ClientConnStatement,
+			Flags:     GucNoResetAll | GucNotInSample | GucDisallowInFile,
+			ShortDesc: "Sets the current transaction's isolation level."},
+		BootVal: "read committed", Options: isolationLevelOptions,
+	}
+	TransactionReadOnly = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "transaction_read_only", Context: PGCUserset, Group: ClientConnStatement,
+			Flags:     GucNoResetAll | GucNotInSample | GucDisallowInFile,
+			ShortDesc: "Sets the current transaction's read-only status."},
+	}
+	TransactionDeferrable = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "transaction_deferrable", Context: PGCUserset, Group: ClientConnStatement,
+			Flags:     GucNoResetAll | GucNotInSample | GucDisallowInFile,
+			ShortDesc: "Whether to defer a read-only serializable transaction until it can be executed with no possible serialization failures."},
+	}
+)
+
+// isolationLevelOptions はトランザクションの分離レベル (isolation_level_options 相当)
+var isolationLevelOptions = []string{"serializable", "repeatable read", "read committed", "read uncommitted"}
+
+// サーバーが決める値。バックエンドが接続の開始時に PGCInternal として設定する
+var (
+	DataChecksums = &ConfigEnum{
+		ConfigGeneric: ConfigGeneric{Name: "data_checksums", Context: PGCInternal, Group: PresetOptions,
+			Flags:     GucNotInSample | GucDisallowInFile,
+			ShortDesc: "Shows whether data checksums are turned on for this cluster."},
+		BootVal: "off",
+		Options: []string{"off", "inprogress-on", "on", "inprogress-off"},
+	}
+	IntegerDateTimes = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "integer_datetimes", Context: PGCInternal, Group: PresetOptions,
+			Flags:     GucReport | GucNotInSample | GucDisallowInFile,
+			ShortDesc: "Shows whether datetimes are integer based."},
+		BootVal: true,
+	}
+	IsSuperuser = &ConfigBool{
+		ConfigGeneric: ConfigGeneric{Name: "is_superuser", Context: PGCInternal, Group: Ungrouped,
+			Flags:     GucReport | GucNoShowAll | GucNoResetAll | GucNotInSample | GucDisallowInFile,
+			ShortDesc: "Shows whether the current user is a superuser."},
+	}
+	ServerEncoding = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "server_encoding", Context: PGCInternal, Group: PresetOptions,
+			Flags:     GucReport | GucNotInSample | GucDisallowInFile,
+			ShortDesc: "Shows the server (database) character set encoding."},
+		BootVal: "UTF8",
+	}
+	ServerVersion = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "server_version", Context: PGCInternal, Group: PresetOptions,
+			Flags:     GucReport | GucNotInSample | GucDisallowInFile,
+			ShortDesc: "Shows the server version."},
+		BootVal: pgconfig.PgVersion,
+	}
+	SessionAuthorization = &ConfigString{
+		ConfigGeneric: ConfigGeneric{Name: "session_authorization", Context: PGCInternal, Group: Ungrouped,
+			Flags:     GucReport | GucNoShowAll | GucNoResetAll | GucNotInSample | GucDisallowInFile,
+			ShortDesc: "Sets the session user name."},
+	}
+)
+
+// configureNames は全ての組み込みのパラメータ (ConfigureNamesBool などを合わせたもの)
+var configureNames = []configVar{
+	ListenAddresses, Port, UnixSocketDirectories, UnixSocketPermissions,
+	MaxConnections, SuperuserReservedConnections, ReservedConnections,
+	TCPKeepalivesIdle, TCPKeepalivesInterval, TCPKeepalivesCount,
+	AuthenticationTimeout, ConnectionAttemptLimit, ConnectionAttemptWindow,
+	PasswordEncryption, ScramIterations,
+	EnableSSL, SSLCertFile, SSLKeyFile, SSLCAFile,
+	DataDirectory, ConfigFile, HbaFile, IdentFile, ClusterKeyFile,
+	VacuumCostDelay, VacuumCostPageHit, VacuumCostPageMiss, VacuumCostPageDirty, VacuumCostLimit,
+	MaxWorkerProcesses, AutovacuumMaxWorkers, AutovacuumVacuumCostDelay, AutovacuumVacuumCostLimit, OldSnapshotThreshold, EffectiveIoConcurrency,
+	AutovacuumStartDaemon, AutovacuumNaptime, AutovacuumVacuumThreshold, AutovacuumVacuumInsertThreshold, AutovacuumAnalyzeThreshold,
+	AutovacuumVacuumScaleFactor, AutovacuumVacuumInsertScaleFactor, AutovacuumAnalyzeScaleFactor,
+	SharedBuffers, BgWriterDelay, BgWriterLRUMaxPages, BgWriterLRUMultiplier, TempFileLimit, MaxFilesPerProcess,
+	SynchronousCommit, WalWriterDelay, WalWriterFlushAfter,
+	CheckPointTimeout, CheckPointCompletionTarget, ArchiveMode, ArchiveCommand,
+	LogDestination, LoggingCollector, LogDirectory, LogFilename, LogFileMode, LogRotationAge, LogRotationSize, LogTruncateOnRotation,
+	LogMinMessages, LogMinErrorStatement, ClientMinMessages,
+	LogLinePrefix, LogCheckpoints, LogConnections, LogDisconnections, LogLockWaits, LogTempFiles, RestartAfterCrash, DeadlockTimeout,
+	ComputeQueryId, TrackActivities, TrackCounts, TrackFunctions, TrackIoTiming, TrackActivityQuerySize,
+	ApplicationName, ClientEncoding, DateStyle, ExtraFloatDigits, IntervalStyle, SearchPath,
+	StandardConformingStrings, SynchronizeSeqscans, TimeZone, StatementTimeout, LockTimeout, IdleInTransactionSessionTimeout,
+	StatementRowLimit, StatementResultSizeLimit,
+	DefaultTransactionIsolation, DefaultTransactionReadOnly, DefaultTransactionDeferrable,
+	TransactionIsolation, TransactionReadOnly, TransactionDeferrable,
+	DataChecksums, IntegerDateTimes, IsSuperuser, ServerEncoding, ServerVersion, SessionAuthorization,
+}
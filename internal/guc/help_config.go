@@ -0,0 +1,49 @@
+package guc
+
+import (
+	"fmt"
+	"io"
+)
+
+// ----------------------------------------------------------------
+// パラメータの一覧の出力 (utils/misc/help_config.c 相当)
+// ----------------------------------------------------------------
+// postgres describe-config で、全てのパラメータの定義をタブ区切りで1行ずつ出力する。
+// 列は名前、変更できる時期、分類、種類、既定値、最小値、最大値、短い説明、長い説明の順で、
+// 最小値と最大値は整数と実数のパラメータだけが持つ。
+
+// GucInfoMain はパラメータの一覧を w に出力する (GucInfoMain 相当)。SHOW ALL で表示しないもの、
+// postgresql.conf の見本に含めないもの、設定ファイルで設定できないものは出力しない。
+func GucInfoMain(w io.Writer) error {
+	for _, v := range configureNames {
+		g := v.generic()
+		if g.Flags&(GucNoShowAll|GucNotInSample|GucDisallowInFile) != 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			g.Name, g.Context, g.Group, describeValue(v), g.ShortDesc, g.LongDesc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describeValue は種類、既定値、最小値、最大値の列を返す (printMixedStruct 相当)
+func describeValue(v configVar) string {
+	switch c := v.(type) {
+	case *ConfigBool:
+		if c.BootVal {
+			return "BOOLEAN\tTRUE\t\t"
+		}
+		return "BOOLEAN\tFALSE\t\t"
+	case *ConfigInt:
+		return fmt.Sprintf("INTEGER\t%d\t%d\t%d", c.BootVal, c.Min, c.Max)
+	case *ConfigReal:
+		return fmt.Sprintf("REAL\t%.6g\t%.6g\t%.6g", c.BootVal, c.Min, c.Max)
+	case *ConfigString:
+		return fmt.Sprintf("STRING\t%s\t\t", c.BootVal)
+	case *ConfigEnum:
+		return fmt.Sprintf("ENUM\t%s\t\t", c.BootVal)
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package libpq
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/common/md5"
+	"github.com/Tsubasa-2005/go-postgres/internal/common/scram"
+)
+
+// ----------------------------------------------------------------
+// パスワードの暗号化 (interfaces/libpq/fe-auth.c の PQencryptPasswordConn 相当)
+// ----------------------------------------------------------------
+// \password のように、平文のパスワードをサーバーに送らずに変更するため、
+// サーバーに保存する形式 (MD5 のハッシュか SCRAM の秘密情報) をクライアントで作る。
+
+// EncryptPasswordConn は user のパスワード passwd から、サーバーに保存する値を作る
+// (PQencryptPasswordConn 相当)。algorithm が空の場合はサーバーの password_encryption に従う。
+func (c *Conn) EncryptPasswordConn(passwd, user, algorithm string) (string, error) {
+	if algorithm == "" {
+		res, err := c.Exec("show password_encryption")
+		if err != nil {
+			return "", err
+		}
+		if res.Status == FatalError {
+			return "", res.Err()
+		}
+		if res.Status != TuplesOK || res.NTuples() != 1 || res.NFields() != 1 {
+			return "", errors.New("unexpected shape of result set returned for SHOW")
+		}
+		algorithm = res.GetValue(0, 0)
+	}
+
+	// 以前の版の "on" と "off" は md5 と同じ意味
+	if algorithm == "on" || algorithm == "off" {
+		algorithm = "md5"
+	}
+	switch algorithm {
+	case "scram-sha-256":
+		salt := make([]byte, scram.DefaultSaltLen)
+		rand.Read(salt)
+		return scram.BuildSecret(passwd, salt, c.scramIterations()), nil
+	case "md5":
+		return md5.Encrypt(passwd, user), nil
+	}
+	return "", fmt.Errorf("unrecognized password encryption algorithm \"%s\"", algorithm)
+}
+
+// scramIterations はサーバーが通知した scram_iterations を返す。通知されていなければ既定値を返す
+func (c *Conn) scramIterations() int {
+	if n, err := strconv.Atoi(c.params["scram_iterations"]); err == nil && n > 0 {
+		return n
+	}
+	return scram.DefaultIterations
+}
+
+// ChangePassword は user のパスワードを passwd に変更する (PQchangePassword 相当)。
+// パスワードはクライアントで暗号化してから送る。
+func (c *Conn) ChangePassword(user, passwd string) (*Result, error) {
+	encrypted, err := c.EncryptPasswordConn(passwd, user, "")
+	if err != nil {
+		return nil, err
+	}
+	return c.Exec(fmt.Sprintf("ALTER USER %s PASSWORD %s", EscapeIdentifier(user), EscapeLiteral(encrypted)))
+}
@@ -0,0 +1,117 @@
+package libpq
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/common/scram"
+)
+
+// ----------------------------------------------------------------
+// クライアント側の SCRAM-SHA-256 (interfaces/libpq/fe-auth-scram.c 相当)
+// ----------------------------------------------------------------
+// チャネルバインディングにはまだ対応していないため、使わない (gs2-cbind-flag は "n")。
+
+// feScramState はクライアント側の交換の状態 (fe_scram_state 相当)
+type feScramState struct {
+	password string
+
+	clientNonce             string
+	clientFirstMessageBare  string
+	clientFinalWithoutProof string
+
+	serverFirstMessage string
+	saltedPassword     []byte
+	serverSignature    []byte
+}
+
+// newFeScramState は交換を始める (scram_init 相当)
+func newFeScramState(password string) *feScramState {
+	return &feScramState{password: scram.SASLprep(password)}
+}
+
+// clientFirstMessage は client-first-message を作る (build_client_first_message 相当)。
+// ユーザー名はスタートアップパケットで送るため、n= は空にする。
+func (st *feScramState) clientFirstMessage() string {
+	raw := make([]byte, scram.RawNonceLen)
+	rand.Read(raw)
+	st.clientNonce = base64.StdEncoding.EncodeToString(raw)
+	st.clientFirstMessageBare = "n=,r=" + st.clientNonce
+	return "n,," + st.clientFirstMessageBare
+}
+
+// readAttr は "attr=value" を読む (read_attr_value 相当)
+func readAttr(input string, attr byte) (value, rest string, err error) {
+	if len(input) < 2 || input[0] != attr || input[1] != '=' {
+		return "", "", fmt.Errorf("malformed SCRAM message (attribute \"%c\" expected)", attr)
+	}
+	value, rest, _ = strings.Cut(input[2:], ",")
+	return value, rest, nil
+}
+
+// clientFinalMessage は server-first-message を読んで client-final-message を作る
+// (read_server_first_message, build_client_final_message 相当)
+func (st *feScramState) clientFinalMessage(serverFirst string) (string, error) {
+	st.serverFirstMessage = serverFirst
+	nonce, rest, err := readAttr(serverFirst, 'r')
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(nonce, st.clientNonce) || len(nonce) == len(st.clientNonce) {
+		return "", errors.New("invalid SCRAM response (nonce mismatch)")
+	}
+	saltStr, rest, err := readAttr(rest, 's')
+	if err != nil {
+		return "", err
+	}
+	salt, err := base64.StdEncoding.DecodeString(saltStr)
+	if err != nil {
+		return "", errors.New("malformed SCRAM message (invalid salt)")
+	}
+	iterStr, _, err := readAttr(rest, 'i')
+	if err != nil {
+		return "", err
+	}
+	iterations, err := strconv.Atoi(iterStr)
+	if err != nil || iterations <= 0 {
+		return "", errors.New("malformed SCRAM message (invalid iteration count)")
+	}
+
+	st.saltedPassword = scram.SaltedPassword(st.password, salt, iterations)
+	st.clientFinalWithoutProof = "c=" + base64.StdEncoding.EncodeToString([]byte("n,,")) + ",r=" + nonce
+
+	authMessage := st.clientFirstMessageBare + "," + st.serverFirstMessage + "," + st.clientFinalWithoutProof
+	clientKey := scram.ClientKey(st.saltedPassword)
+	signature := scram.HMAC(scram.H(clientKey), authMessage)
+	proof := make([]byte, scram.KeyLen)
+	for i := range proof {
+		proof[i] = clientKey[i] ^ signature[i]
+	}
+	st.serverSignature = scram.HMAC(scram.ServerKey(st.saltedPassword), authMessage)
+	return st.clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// verifyServerFinal は server-final-message のサーバーの署名を検証する
+// (read_server_final_message, verify_server_signature 相当)
+func (st *feScramState) verifyServerFinal(serverFinal string) error {
+	if strings.HasPrefix(serverFinal, "e=") {
+		return fmt.Errorf("error received from server in SCRAM exchange: %s", serverFinal[2:])
+	}
+	sigStr, _, err := readAttr(serverFinal, 'v')
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigStr)
+	if err != nil || len(sig) != scram.KeyLen {
+		return errors.New("malformed SCRAM message (invalid server signature)")
+	}
+	if !hmac.Equal(sig, st.serverSignature) {
+		return errors.New("incorrect server signature")
+	}
+	return nil
+}
@@ -0,0 +1,479 @@
+package libpq
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/common/md5"
+	"github.com/Tsubasa-2005/go-postgres/internal/common/scram"
+	pqcomm "github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+)
+
+// ----------------------------------------------------------------
+// フロントエンド側の接続 (interfaces/libpq/fe-connect.c 相当)
+// ----------------------------------------------------------------
+// テスト用のツール (isolationtester, pg_regress など) がサーバーに接続するための最小限のクライアント。
+// C言語版の libpq と同じく、接続文字列 "keyword=value ..." で接続先を指定する。
+// internal/libpq はバックエンド側の通信処理で、プロトコルの定数はそちらのものを使う。
+
+// Conn はサーバーへの1つの接続 (PGconn 相当)
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+
+	network   string // "tcp" または "unix"
+	addr      string
+	user      string
+	params    map[string]string // ParameterStatus で通知された値
+	pid       int32
+	cancelKey int32
+	txStatus  byte
+	sslInUse  bool
+
+	noticeReceiver func(*Result)
+}
+
+// connOptions は接続文字列で指定できるキーワードとその既定値の環境変数 (PQconninfoOptions 相当)
+var connOptions = []struct {
+	keyword string
+	envvar  string
+}{
+	{"host", "PGHOST"},
+	{"port", "PGPORT"},
+	{"user", "PGUSER"},
+	{"password", "PGPASSWORD"},
+	{"dbname", "PGDATABASE"},
+	{"options", "PGOPTIONS"},
+	{"application_name", "PGAPPNAME"},
+	{"connect_timeout", "PGCONNECT_TIMEOUT"},
+	{"sslmode", "PGSSLMODE"},
+	{"sslcert", "PGSSLCERT"},
+	{"sslkey", "PGSSLKEY"},
+	{"sslrootcert", "PGSSLROOTCERT"},
+}
+
+// parseConnInfo は "keyword=value" の並びを解析する (conninfo_parse 相当)。
+// 値は単一引用符で囲むことができ、その中ではバックスラッシュでエスケープする。
+func parseConnInfo(conninfo string) (map[string]string, error) {
+	opts := make(map[string]string)
+	s := conninfo
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f\v")
+		if s == "" {
+			break
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("missing \"=\" after \"%s\" in connection info string", s)
+		}
+		keyword := strings.TrimRight(s[:eq], " \t")
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(s, "'") {
+			i := 1
+			for ; i < len(s) && s[i] != '\''; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New("unterminated quoted string in connection info string")
+			}
+			s = s[i+1:]
+		} else {
+			i := 0
+			for ; i < len(s) && !strings.ContainsRune(" \t\n\r\f\v", rune(s[i])); i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			s = s[i:]
+		}
+
+		known := false
+		for _, o := range connOptions {
+			if o.keyword == keyword {
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("invalid connection option \"%s\"", keyword)
+		}
+		opts[keyword] = value.String()
+	}
+
+	// 指定されていないものは環境変数から補う (conninfo_add_defaults 相当)
+	for _, o := range connOptions {
+		if _, ok := opts[o.keyword]; !ok {
+			if v := os.Getenv(o.envvar); v != "" {
+				opts[o.keyword] = v
+			}
+		}
+	}
+	if opts["host"] == "" {
+		// Unix ドメインソケットのない環境では TCP/IP で接続する (DefaultHost 相当)
+		if runtime.GOOS == "windows" {
+			opts["host"] = "localhost"
+		} else {
+			opts["host"] = pgconfig.DefaultPgSocketDir
+		}
+	}
+	if opts["port"] == "" {
+		opts["port"] = strconv.Itoa(pgconfig.DefPgPort)
+	}
+	if opts["user"] == "" {
+		// オペレーティングシステムのユーザー名を使う (pg_fe_getauthname 相当)
+		if u := os.Getenv("USER"); u != "" {
+			opts["user"] = u
+		} else if u, err := user.Current(); err == nil {
+			opts["user"] = u.Username
+		}
+	}
+	if opts["dbname"] == "" {
+		opts["dbname"] = opts["user"]
+	}
+	switch opts["sslmode"] {
+	case "":
+		opts["sslmode"] = defaultSSLMode
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		return nil, fmt.Errorf("invalid sslmode value: \"%s\"", opts["sslmode"])
+	}
+	return opts, nil
+}
+
+// Connect は接続文字列で指定したサーバーに接続し、クエリを送れる状態になるまで待つ (PQconnectdb 相当)
+func Connect(conninfo string) (*Conn, error) {
+	opts, err := parseConnInfo(conninfo)
+	if err != nil {
+		return nil, err
+	}
+
+	var timeout time.Duration
+	if v := opts["connect_timeout"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer value \"%s\" for connection option \"connect_timeout\"", v)
+		}
+		timeout = time.Duration(n) * time.Second
+	}
+
+	// "/" で始まるホスト名は Unix ドメインソケットのディレクトリとする
+	network, addr := "tcp", net.JoinHostPort(opts["host"], opts["port"])
+	where := fmt.Sprintf("server at \"%s\"", addr)
+	if strings.HasPrefix(opts["host"], "/") {
+		network, addr = "unix", filepath.Join(opts["host"], fmt.Sprintf(".s.PGSQL.%s", opts["port"]))
+		where = fmt.Sprintf("server on socket \"%s\"", addr)
+	}
+
+	// Unix ドメインソケットでは SSL を使わない。allow は平文で、prefer は SSL で失敗したら
+	// もう一方でやり直す
+	sslmode := opts["sslmode"]
+	trySSL := network == "tcp" && sslmode != "disable" && sslmode != "allow"
+	c, err := dialConn(network, addr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("connection to %s failed: %w", where, err)
+	}
+	err = c.connectDBComplete(opts, trySSL)
+	if err != nil && network == "tcp" && (sslmode == "allow" || sslmode == "prefer" && c.sslInUse) {
+		c.conn.Close()
+		if c, err = dialConn(network, addr, timeout); err != nil {
+			return nil, fmt.Errorf("connection to %s failed: %w", where, err)
+		}
+		err = c.connectDBComplete(opts, !trySSL)
+	}
+	if err != nil {
+		c.conn.Close()
+		return nil, fmt.Errorf("connection to %s failed: %w", where, err)
+	}
+	return c, nil
+}
+
+// dialConn はサーバーに接続する (connectDBStart 相当)
+func dialConn(network, addr string, timeout time.Duration) (*Conn, error) {
+	nc, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{
+		conn:     nc,
+		r:        bufio.NewReader(nc),
+		w:        bufio.NewWriter(nc),
+		network:  network,
+		addr:     addr,
+		params:   make(map[string]string),
+		txStatus: 'I',
+	}, nil
+}
+
+// connectDBComplete は useSSL なら接続を SSL に切り替えてから、スタートアップの交換を行う
+// (connectDBComplete 相当)
+func (c *Conn) connectDBComplete(opts map[string]string, useSSL bool) error {
+	c.user = opts["user"]
+	if useSSL {
+		if err := c.openSecure(opts); err != nil {
+			return err
+		}
+	}
+	return c.startup(opts)
+}
+
+// startup はスタートアップパケットを送り、ReadyForQuery を受け取るまでのメッセージを処理する
+// (PQconnectPoll 相当)
+func (c *Conn) startup(opts map[string]string) error {
+	var body []byte
+	body = binary.BigEndian.AppendUint32(body, uint32(pqcomm.PgProtocolLatest))
+	for _, kv := range [][2]string{
+		{"user", opts["user"]},
+		{"database", opts["dbname"]},
+		{"options", opts["options"]},
+		{"application_name", opts["application_name"]},
+	} {
+		if kv[1] == "" {
+			continue
+		}
+		body = append(body, kv[0]...)
+		body = append(body, 0)
+		body = append(body, kv[1]...)
+		body = append(body, 0)
+	}
+	body = append(body, 0)
+
+	pkt := binary.BigEndian.AppendUint32(nil, uint32(len(body)+4))
+	if _, err := c.w.Write(append(pkt, body...)); err != nil {
+		return err
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+
+	var sasl *feScramState
+	for {
+		typ, msg, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case pqcomm.PqMsgAuthenticationRequest:
+			if len(msg) < 4 {
+				return errProtocol
+			}
+			if err := c.handleAuthRequest(int32(binary.BigEndian.Uint32(msg)), msg[4:], opts, &sasl); err != nil {
+				return err
+			}
+		case pqcomm.PqMsgBackendKeyData:
+			if len(msg) < 8 {
+				return errProtocol
+			}
+			c.pid = int32(binary.BigEndian.Uint32(msg[0:4]))
+			c.cancelKey = int32(binary.BigEndian.Uint32(msg[4:8]))
+		case pqcomm.PqMsgErrorResponse:
+			return parseErrorFields(msg)
+		case pqcomm.PqMsgReadyForQuery:
+			c.handleReadyForQuery(msg)
+			return nil
+		default:
+			if err := c.handleAsync(typ, msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handleAuthRequest は認証要求に応える (pg_fe_sendauth 相当)。
+// sasl は SASL の交換の状態で、AuthenticationSASL で作り、以降のメッセージで使う。
+func (c *Conn) handleAuthRequest(areq int32, data []byte, opts map[string]string, sasl **feScramState) error {
+	password := opts["password"]
+	switch areq {
+	case pqcomm.AuthReqOk:
+		return nil
+	case pqcomm.AuthReqPassword:
+		if password == "" {
+			return errors.New("fe_sendauth: no password supplied")
+		}
+		return c.sendAuthMessage(pqcomm.PqMsgPasswordMessage, append([]byte(password), 0))
+	case pqcomm.AuthReqMD5:
+		if password == "" {
+			return errors.New("fe_sendauth: no password supplied")
+		}
+		if len(data) < 4 {
+			return errProtocol
+		}
+		// "md5" + md5(md5(パスワード + ユーザー名) + salt) を送る (pg_password_sendauth 相当)
+		crypted := md5.Encrypt(md5.Encrypt(password, opts["user"])[3:], string(data[:4]))
+		return c.sendAuthMessage(pqcomm.PqMsgPasswordMessage, append([]byte(crypted), 0))
+	case pqcomm.AuthReqSASL:
+		found := false
+		for _, mech := range strings.Split(string(data), "\x00") {
+			if mech == scram.MechanismSHA256 {
+				found = true
+			}
+		}
+		if !found {
+			return errors.New("none of the server's SASL authentication mechanisms are supported")
+		}
+		if password == "" {
+			return errors.New("fe_sendauth: no password supplied")
+		}
+		*sasl = newFeScramState(password)
+		first := (*sasl).clientFirstMessage()
+		body := append([]byte(scram.MechanismSHA256), 0)
+		body = binary.BigEndian.AppendUint32(body, uint32(len(first)))
+		body = append(body, first...)
+		return c.sendAuthMessage(pqcomm.PqMsgSASLInitialResponse, body)
+	case pqcomm.AuthReqSASLCont:
+		if *sasl == nil {
+			return errProtocol
+		}
+		final, err := (*sasl).clientFinalMessage(string(data))
+		if err != nil {
+			return err
+		}
+		return c.sendAuthMessage(pqcomm.PqMsgSASLResponse, []byte(final))
+	case pqcomm.AuthReqSASLFin:
+		if *sasl == nil {
+			return errProtocol
+		}
+		return (*sasl).verifyServerFinal(string(data))
+	}
+	return fmt.Errorf("authentication method %d not supported", areq)
+}
+
+// sendAuthMessage は認証のメッセージを送り、すぐに応答を待てるよう送信バッファを書き出す
+func (c *Conn) sendAuthMessage(typ byte, body []byte) error {
+	if err := c.sendMessage(typ, body); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// errProtocol はサーバーから想定外のメッセージを受け取ったことを表す。
+var errProtocol = errors.New("protocol error: unexpected message from server")
+
+// readMessage はメッセージを1つ読み取る (pqGetc, pqGetInt 相当)
+func (c *Conn) readMessage() (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, errors.New("server closed the connection unexpectedly")
+		}
+		return 0, nil, err
+	}
+	n := int(binary.BigEndian.Uint32(hdr[1:])) - 4
+	if n < 0 {
+		return 0, nil, errProtocol
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(c.r, msg); err != nil {
+		return 0, nil, errors.New("server closed the connection unexpectedly")
+	}
+	return hdr[0], msg, nil
+}
+
+// sendMessage はメッセージを送る。Flush するまでバッファに溜める。
+func (c *Conn) sendMessage(typ byte, body []byte) error {
+	var hdr [5]byte
+	hdr[0] = typ
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(body)+4))
+	if _, err := c.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := c.w.Write(body)
+	return err
+}
+
+// handleAsync は問い合わせとは無関係にいつでも届くメッセージを処理する (pqParseInput3 相当)
+func (c *Conn) handleAsync(typ byte, msg []byte) error {
+	switch typ {
+	case pqcomm.PqMsgParameterStatus:
+		fields := strings.Split(string(msg), "\x00")
+		if len(fields) < 2 {
+			return errProtocol
+		}
+		c.params[fields[0]] = fields[1]
+	case pqcomm.PqMsgNoticeResponse:
+		if c.noticeReceiver != nil {
+			c.noticeReceiver(&Result{Status: NonfatalError, err: parseErrorFields(msg)})
+		}
+	case pqcomm.PqMsgNotificationResponse, pqcomm.PqMsgNegotiateProtocolVersion:
+		// LISTEN/NOTIFY とプロトコル拡張はまだ扱わない
+	default:
+		return errProtocol
+	}
+	return nil
+}
+
+func (c *Conn) handleReadyForQuery(msg []byte) {
+	if len(msg) > 0 {
+		c.txStatus = msg[0]
+	}
+}
+
+// SetNoticeReceiver は NOTICE などを受け取ったときに呼ぶ関数を設定する (PQsetNoticeReceiver 相当)
+func (c *Conn) SetNoticeReceiver(fn func(*Result)) {
+	c.noticeReceiver = fn
+}
+
+// ParameterStatus はサーバーが通知した実行時パラメータの値を返す (PQparameterStatus 相当)
+func (c *Conn) ParameterStatus(name string) string {
+	return c.params[name]
+}
+
+// User は接続したユーザー名を返す (PQuser 相当)
+func (c *Conn) User() string {
+	return c.user
+}
+
+// BackendPID は接続先のバックエンドの PID を返す (PQbackendPID 相当)
+func (c *Conn) BackendPID() int32 {
+	return c.pid
+}
+
+// TransactionStatus は最後の ReadyForQuery で通知されたトランザクションの状態を返す
+// (PQtransactionStatus 相当)。'I', 'T', 'E' のいずれか。
+func (c *Conn) TransactionStatus() byte {
+	return c.txStatus
+}
+
+// Cancel は実行中の問い合わせの取り消しを別の接続で要求する (PQcancel 相当)
+func (c *Conn) Cancel() error {
+	nc, err := net.Dial(c.network, c.addr)
+	if err != nil {
+		return fmt.Errorf("could not send cancel request: %w", err)
+	}
+	defer nc.Close()
+	var pkt [16]byte
+	binary.BigEndian.PutUint32(pkt[0:], 16)
+	binary.BigEndian.PutUint32(pkt[4:], pqcomm.CancelRequestCode)
+	binary.BigEndian.PutUint32(pkt[8:], uint32(c.pid))
+	binary.BigEndian.PutUint32(pkt[12:], uint32(c.cancelKey))
+	if _, err := nc.Write(pkt[:]); err != nil {
+		return fmt.Errorf("could not send cancel request: %w", err)
+	}
+	// サーバーが接続を閉じるまで待つ
+	_, _ = nc.Read(pkt[:1])
+	return nil
+}
+
+// Close は Terminate を送って接続を閉じる (PQfinish 相当)
+func (c *Conn) Close() error {
+	_ = c.sendMessage(pqcomm.PqMsgTerminate, nil)
+	_ = c.w.Flush()
+	return c.conn.Close()
+}
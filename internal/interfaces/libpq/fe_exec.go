@@ -0,0 +1,317 @@
+package libpq
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	pqcomm "github.com/Tsubasa-2005/go-postgres/internal/libpq"
+)
+
+// ----------------------------------------------------------------
+// 問い合わせの送信と結果の受信 (interfaces/libpq/fe-exec.c, fe-protocol3.c 相当)
+// ----------------------------------------------------------------
+
+// ExecStatus は結果の種類 (ExecStatusType 相当)
+type ExecStatus int
+
+const (
+	EmptyQuery    ExecStatus = iota // PGRES_EMPTY_QUERY
+	CommandOK                       // PGRES_COMMAND_OK: 行を返さない文が成功した
+	TuplesOK                        // PGRES_TUPLES_OK: 行を返す文が成功した
+	NonfatalError                   // PGRES_NONFATAL_ERROR: NOTICE など
+	FatalError                      // PGRES_FATAL_ERROR
+)
+
+// FieldDesc は結果の列の記述 (PGresAttDesc 相当)
+type FieldDesc struct {
+	Name   string
+	TypeID catalog.Oid
+}
+
+// Result は1つの文の結果 (PGresult 相当)
+type Result struct {
+	Status ExecStatus
+	Fields []FieldDesc
+	// rows の各値は NULL の場合に nil
+	rows   [][]*string
+	cmdTag string
+	err    *Error
+}
+
+// NTuples は行数を返す (PQntuples 相当)
+func (r *Result) NTuples() int { return len(r.rows) }
+
+// NFields は列数を返す (PQnfields 相当)
+func (r *Result) NFields() int { return len(r.Fields) }
+
+// GetValue は値をテキスト形式で返す。NULL の場合は空文字列を返す (PQgetvalue 相当)
+func (r *Result) GetValue(row, col int) string {
+	if v := r.rows[row][col]; v != nil {
+		return *v
+	}
+	return ""
+}
+
+// GetIsNull は値が NULL かを返す (PQgetisnull 相当)
+func (r *Result) GetIsNull(row, col int) bool {
+	return r.rows[row][col] == nil
+}
+
+// CmdStatus はコマンドタグを返す (PQcmdStatus 相当)
+func (r *Result) CmdStatus() string { return r.cmdTag }
+
+// Err はエラーの結果 (FatalError, NonfatalError) の内容を返す。それ以外では nil。
+func (r *Result) Err() *Error { return r.err }
+
+// Error はサーバーから返された ErrorResponse / NoticeResponse の内容
+type Error struct {
+	Fields map[byte]string
+}
+
+func (e *Error) Error() string {
+	return e.Fields[pqcomm.PgDiagSeverity] + ":  " + e.Fields[pqcomm.PgDiagMessagePrimary]
+}
+
+// Field は識別子 (PgDiag*) に対応するフィールドの値を返す (PQresultErrorField 相当)
+func (e *Error) Field(code byte) string { return e.Fields[code] }
+
+// Severity, Code, Message はよく使うフィールドを返す
+func (e *Error) Severity() string { return e.Fields[pqcomm.PgDiagSeverity] }
+func (e *Error) Code() string     { return e.Fields[pqcomm.PgDiagSqlstate] }
+func (e *Error) Message() string  { return e.Fields[pqcomm.PgDiagMessagePrimary] }
+
+// parseErrorFields は ErrorResponse / NoticeResponse の本体を解析する (pqGetErrorNotice3 相当)
+func parseErrorFields(msg []byte) *Error {
+	e := &Error{Fields: make(map[byte]string)}
+	for len(msg) > 0 && msg[0] != 0 {
+		code := msg[0]
+		end := strings.IndexByte(string(msg[1:]), 0)
+		if end < 0 {
+			break
+		}
+		e.Fields[code] = string(msg[1 : 1+end])
+		msg = msg[2+end:]
+	}
+	return e
+}
+
+// SendQuery は単純問い合わせを送る。結果は GetResult で受け取る (PQsendQuery 相当)
+func (c *Conn) SendQuery(query string) error {
+	body := append([]byte(query), 0)
+	if err := c.sendMessage(pqcomm.PqMsgQuery, body); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// GetResult は次の文の結果を返す。全ての結果を返し終えたら nil を返す (PQgetResult 相当)。
+// 戻り値のエラーは通信の失敗を表す。サーバーが返したエラーは Status が FatalError の結果になる。
+func (c *Conn) GetResult() (*Result, error) {
+	var res *Result
+	for {
+		typ, msg, err := c.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case pqcomm.PqMsgRowDescription:
+			res = &Result{Status: TuplesOK}
+			if res.Fields, err = parseRowDescription(msg); err != nil {
+				return nil, err
+			}
+		case pqcomm.PqMsgDataRow:
+			if res == nil {
+				return nil, errProtocol
+			}
+			row, err := parseDataRow(msg, len(res.Fields))
+			if err != nil {
+				return nil, err
+			}
+			res.rows = append(res.rows, row)
+		case pqcomm.PqMsgCommandComplete:
+			if res == nil {
+				res = &Result{Status: CommandOK}
+			}
+			res.cmdTag = strings.TrimRight(string(msg), "\x00")
+			return res, nil
+		case pqcomm.PqMsgEmptyQueryResponse:
+			return &Result{Status: EmptyQuery}, nil
+		case pqcomm.PqMsgErrorResponse:
+			return &Result{Status: FatalError, err: parseErrorFields(msg)}, nil
+		case pqcomm.PqMsgReadyForQuery:
+			c.handleReadyForQuery(msg)
+			return nil, nil
+		default:
+			if err := c.handleAsync(typ, msg); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Exec は問い合わせを実行し、最後の文の結果を返す (PQexec 相当)。
+// 途中の文でエラーが起きた場合はそのエラーの結果を返す。
+func (c *Conn) Exec(query string) (*Result, error) {
+	results, err := c.ExecAll(query)
+	if err != nil {
+		return nil, err
+	}
+	var last *Result
+	for _, r := range results {
+		if last == nil || last.Status != FatalError {
+			last = r
+		}
+	}
+	if last == nil {
+		last = &Result{Status: EmptyQuery}
+	}
+	return last, nil
+}
+
+// ExecAll は問い合わせを実行し、全ての文の結果を順に返す。
+func (c *Conn) ExecAll(query string) ([]*Result, error) {
+	if err := c.SendQuery(query); err != nil {
+		return nil, err
+	}
+	var results []*Result
+	for {
+		r, err := c.GetResult()
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			return results, nil
+		}
+		results = append(results, r)
+	}
+}
+
+func parseRowDescription(msg []byte) ([]FieldDesc, error) {
+	if len(msg) < 2 {
+		return nil, errProtocol
+	}
+	n := int(binary.BigEndian.Uint16(msg))
+	msg = msg[2:]
+	fields := make([]FieldDesc, n)
+	for i := range fields {
+		end := strings.IndexByte(string(msg), 0)
+		// 名前の後にテーブル OID, 列番号, 型 OID, 型の長さ, 型修飾子, 書式コードが続く
+		if end < 0 || len(msg) < end+1+18 {
+			return nil, errProtocol
+		}
+		fields[i].Name = string(msg[:end])
+		msg = msg[end+1:]
+		fields[i].TypeID = catalog.Oid(binary.BigEndian.Uint32(msg[6:10]))
+		msg = msg[18:]
+	}
+	return fields, nil
+}
+
+func parseDataRow(msg []byte, nfields int) ([]*string, error) {
+	if len(msg) < 2 || int(binary.BigEndian.Uint16(msg)) != nfields {
+		return nil, errProtocol
+	}
+	msg = msg[2:]
+	row := make([]*string, nfields)
+	for i := range row {
+		if len(msg) < 4 {
+			return nil, errProtocol
+		}
+		n := int32(binary.BigEndian.Uint32(msg))
+		msg = msg[4:]
+		if n < 0 {
+			continue
+		}
+		if len(msg) < int(n) {
+			return nil, errProtocol
+		}
+		v := string(msg[:n])
+		row[i] = &v
+		msg = msg[n:]
+	}
+	return row, nil
+}
+
+// EscapeLiteral は文字列を SQL の文字列リテラルとして引用する (PQescapeLiteral 相当)。
+// バックスラッシュを含む場合は E'...' の形式にする。
+func EscapeLiteral(str string) string {
+	return escapeInternal(str, false)
+}
+
+// EscapeIdentifier は文字列を SQL の識別子として引用する (PQescapeIdentifier 相当)
+func EscapeIdentifier(str string) string {
+	return escapeInternal(str, true)
+}
+
+// escapeInternal は引用符を二重にして囲む (PQescapeInternal 相当)
+func escapeInternal(str string, asIdent bool) string {
+	quote := byte('\'')
+	if asIdent {
+		quote = '"'
+	}
+	var b strings.Builder
+	if !asIdent && strings.ContainsRune(str, '\\') {
+		// C言語版と同じく、前の語とつながらないよう空白を置く
+		b.WriteString(" E")
+	}
+	b.WriteByte(quote)
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if c == quote || !asIdent && c == '\\' {
+			b.WriteByte(c)
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte(quote)
+	return b.String()
+}
+
+// BuildMessage は psql が表示する形式のメッセージを組み立てる (pqBuildErrorMessage3 相当)。
+// query は位置 (PgDiagStatementPosition) の示す箇所を表示するための問い合わせ文字列。
+func (e *Error) BuildMessage(query string) string {
+	var b strings.Builder
+	b.WriteString(e.Severity() + ":  " + e.Message() + "\n")
+	if pos, err := strconv.Atoi(e.Field(pqcomm.PgDiagStatementPosition)); err == nil && query != "" {
+		reportErrorPosition(&b, query, pos)
+	}
+	for _, f := range []struct {
+		code  byte
+		label string
+	}{
+		{pqcomm.PgDiagMessageDetail, "DETAIL"},
+		{pqcomm.PgDiagMessageHint, "HINT"},
+		{pqcomm.PgDiagInternalQuery, "QUERY"},
+		{pqcomm.PgDiagContext, "CONTEXT"},
+	} {
+		if v := e.Field(f.code); v != "" {
+			b.WriteString(f.label + ":  " + v + "\n")
+		}
+	}
+	return b.String()
+}
+
+// reportErrorPosition は問い合わせの loc 文字目 (1始まり) を含む行を表示し、その下に "^" で印を付ける
+// (reportErrorPosition 相当)。C言語版と異なり、長い行を省略して表示することはしない。
+func reportErrorPosition(b *strings.Builder, query string, loc int) {
+	runes := []rune(query)
+	if loc < 1 || loc > len(runes)+1 {
+		return
+	}
+	lineno, start := 1, 0
+	for i := 0; i < loc-1 && i < len(runes); i++ {
+		if runes[i] == '\n' {
+			lineno++
+			start = i + 1
+		}
+	}
+	end := start
+	for end < len(runes) && runes[end] != '\n' && runes[end] != '\r' {
+		end++
+	}
+	prefix := fmt.Sprintf("LINE %d: ", lineno)
+	b.WriteString(prefix + string(runes[start:end]) + "\n")
+	b.WriteString(strings.Repeat(" ", len(prefix)+loc-1-start) + "^\n")
+}
@@ -0,0 +1,103 @@
+package libpq
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// 結果の表示 (interfaces/libpq/fe-print.c の PQprint 相当)
+// ----------------------------------------------------------------
+
+// PrintOpt は Print の表示方法 (PQprintOpt 相当)
+type PrintOpt struct {
+	// Header が true なら列名と区切り線を表示する
+	Header bool
+	// Align が true なら列の幅を揃える。数値だけの列は右寄せにする
+	Align bool
+	// FieldSep は列の区切り文字
+	FieldSep string
+}
+
+// Print は結果を表の形で書き出す (PQprint 相当)
+func Print(w io.Writer, res *Result, opt PrintOpt) {
+	nfields := res.NFields()
+	width := make([]int, nfields)
+	notNum := make([]bool, nfields)
+	for j, f := range res.Fields {
+		width[j] = len(f.Name)
+	}
+	for i := 0; i < res.NTuples(); i++ {
+		for j := 0; j < nfields; j++ {
+			v := res.GetValue(i, j)
+			width[j] = max(width[j], len(v))
+			if v != "" && !looksNumeric(v) {
+				notNum[j] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(values []string) {
+		for j, v := range values {
+			switch {
+			case !opt.Align:
+				b.WriteString(v)
+			case !notNum[j]:
+				fmt.Fprintf(&b, "%*s", width[j], v)
+			case j < nfields-1:
+				fmt.Fprintf(&b, "%-*s", width[j], v)
+			default:
+				// 最後の列は左寄せなら詰め物をしない
+				b.WriteString(v)
+			}
+			if j < nfields-1 {
+				b.WriteString(opt.FieldSep)
+			}
+		}
+		b.WriteByte('\n')
+	}
+
+	if opt.Header {
+		names := make([]string, nfields)
+		for j, f := range res.Fields {
+			names[j] = f.Name
+		}
+		writeRow(names)
+		if opt.Align {
+			// 区切り文字が "|" の場合は交点を "+" にする
+			cross := opt.FieldSep
+			if cross == "|" {
+				cross = "+"
+			}
+			for j := range width {
+				b.WriteString(strings.Repeat("-", width[j]))
+				if j < nfields-1 {
+					b.WriteString(cross)
+				}
+			}
+			b.WriteByte('\n')
+		}
+	}
+	values := make([]string, nfields)
+	for i := 0; i < res.NTuples(); i++ {
+		for j := range values {
+			values[j] = res.GetValue(i, j)
+		}
+		writeRow(values)
+	}
+	if opt.Header {
+		plural := "s"
+		if res.NTuples() == 1 {
+			plural = ""
+		}
+		fmt.Fprintf(&b, "(%d row%s)\n\n", res.NTuples(), plural)
+	}
+	io.WriteString(w, b.String())
+}
+
+// looksNumeric は値が数値の表記だけからなるかを返す
+func looksNumeric(s string) bool {
+	return strings.Trim(s, "0123456789+-.eE") == ""
+}
@@ -0,0 +1,229 @@
+package libpq
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pqcomm "github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+)
+
+// ----------------------------------------------------------------
+// SSL/TLS 接続 (fe-secure.c, fe-secure-openssl.c 相当)
+// ----------------------------------------------------------------
+// sslmode で SSL を使うかどうかとサーバー証明書の検証の程度を決める。
+//
+//	disable      SSL を使わない
+//	allow        平文で接続し、失敗したら SSL でやり直す
+//	prefer       SSL で接続し、サーバーが SSL に対応していなければ平文で続ける
+//	require      SSL を必須とする。ルート証明書があれば verify-ca と同じく検証する
+//	verify-ca    サーバー証明書が信頼する認証局から発行されたものかを検証する
+//	verify-full  verify-ca に加えて、証明書のホスト名が接続先と一致するかを検証する
+//
+// sslcert のクライアント証明書があれば、サーバーに提示する。サーバーの pg_hba.conf で
+// clientcert や cert 認証を指定した行には、証明書を提示しないと接続できない。
+
+// defaultSSLMode は sslmode の既定値 (DefaultSSLMode 相当)
+const defaultSSLMode = "prefer"
+
+// 既定のファイル名。ホームディレクトリの .postgresql からの相対パス
+const (
+	userCertFile = "postgresql.crt" // USER_CERT_FILE
+	userKeyFile  = "postgresql.key" // USER_KEY_FILE
+	rootCertFile = "root.crt"       // ROOT_CERT_FILE
+)
+
+// openSecure は SSLRequest を送り、サーバーが応じれば接続を TLS に切り替える
+// (pqsecure_open_client 相当)。サーバーが応じず、sslmode が SSL を必須としない場合は
+// 平文のまま続ける。
+func (c *Conn) openSecure(opts map[string]string) error {
+	cfg, err := initializeSSL(opts)
+	if err != nil {
+		return err
+	}
+
+	var pkt [8]byte
+	binary.BigEndian.PutUint32(pkt[0:], 8)
+	binary.BigEndian.PutUint32(pkt[4:], pqcomm.NegotiateSSLCode)
+	if _, err := c.conn.Write(pkt[:]); err != nil {
+		return fmt.Errorf("could not send SSL negotiation packet: %w", err)
+	}
+	resp, err := c.r.ReadByte()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("server closed the connection unexpectedly")
+		}
+		return err
+	}
+	switch resp {
+	case 'S':
+		// 応答の直後に平文のデータがあれば、中間者が挿入したものかもしれない
+		if c.r.Buffered() > 0 {
+			return errors.New("received unencrypted data after SSL response")
+		}
+	case 'N':
+		if opts["sslmode"] != "prefer" {
+			return errors.New("server does not support SSL, but SSL was required")
+		}
+		return nil
+	case 'E':
+		// SSL に対応していない古いサーバーはエラーを返す。読み直すよう1バイト戻す
+		if err := c.r.UnreadByte(); err != nil {
+			return err
+		}
+		typ, msg, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		if typ == pqcomm.PqMsgErrorResponse {
+			return parseErrorFields(msg)
+		}
+		return errProtocol
+	default:
+		return fmt.Errorf("received invalid response to SSL negotiation: %c", resp)
+	}
+
+	conn := tls.Client(c.conn, cfg)
+	if err := conn.Handshake(); err != nil {
+		return fmt.Errorf("SSL error: %w", err)
+	}
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	c.w = bufio.NewWriter(conn)
+	c.sslInUse = true
+	return nil
+}
+
+// initializeSSL はクライアント証明書とルート証明書を読み込み、TLS の設定を作る
+// (initialize_SSL 相当)
+func initializeSSL(opts map[string]string) (*tls.Config, error) {
+	sslmode := opts["sslmode"]
+	homedir, _ := os.UserHomeDir()
+	defaultFile := func(name string) string {
+		if homedir == "" {
+			return ""
+		}
+		return filepath.Join(homedir, ".postgresql", name)
+	}
+
+	// 証明書の検証は VerifyConnection で行う。verify-ca では
+	// ホスト名を確かめないため、crypto/tls の検証は使わない
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+	}
+
+	// ルート証明書があれば、require でもサーバー証明書を検証する
+	rootcert := opts["sslrootcert"]
+	if rootcert == "" {
+		rootcert = defaultFile(rootCertFile)
+	}
+	if rootcert != "" && fileExists(rootcert) {
+		pem, err := os.ReadFile(rootcert)
+		if err != nil {
+			return nil, fmt.Errorf("could not read root certificate file \"%s\": %s", rootcert, platform.OSErrorMessage(err))
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("could not read root certificate file \"%s\": no certificates found", rootcert)
+		}
+		host := ""
+		if sslmode == "verify-full" {
+			host = opts["host"]
+		}
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyServerCert(cs, pool, host)
+		}
+	} else if sslmode == "verify-ca" || sslmode == "verify-full" {
+		if rootcert == "" {
+			return nil, errors.New("could not get home directory to locate root certificate file\n" +
+				"Either provide the file or change sslmode to disable server certificate verification.")
+		}
+		return nil, fmt.Errorf("root certificate file \"%s\" does not exist\n"+
+			"Either provide the file or change sslmode to disable server certificate verification.", rootcert)
+	}
+
+	// クライアント証明書は、ファイルがあるときだけ提示する
+	sslcert := opts["sslcert"]
+	if sslcert == "" {
+		sslcert = defaultFile(userCertFile)
+	}
+	if sslcert != "" && fileExists(sslcert) {
+		certPEM, err := os.ReadFile(sslcert)
+		if err != nil {
+			return nil, fmt.Errorf("could not open certificate file \"%s\": %s", sslcert, platform.OSErrorMessage(err))
+		}
+		sslkey := opts["sslkey"]
+		if sslkey == "" {
+			sslkey = defaultFile(userKeyFile)
+		}
+		if sslkey == "" || !fileExists(sslkey) {
+			return nil, fmt.Errorf("certificate present, but not private key file \"%s\"", sslkey)
+		}
+		keyPEM, err := os.ReadFile(sslkey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load private key file \"%s\": %s", sslkey, platform.OSErrorMessage(err))
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("could not load private key file \"%s\": %w", sslkey, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// verifyServerCert はサーバー証明書が roots の認証局から発行されたものかを検証する。
+// host が空でなければ、証明書のホスト名が host と一致するかも確かめる
+// (verify_peer_name_matches_certificate 相当)。
+func verifyServerCert(cs tls.ConnectionState, roots *x509.CertPool, host string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server did not provide a certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	leaf := cs.PeerCertificates[0]
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+		return errors.New("certificate verify failed")
+	}
+	if host != "" && !certMatchesHost(leaf, host) {
+		return fmt.Errorf("server certificate for \"%s\" does not match host name \"%s\"", leaf.Subject.CommonName, host)
+	}
+	return nil
+}
+
+// certMatchesHost は証明書のホスト名が host と一致するかを返す。subjectAltName に
+// DNS 名も IP アドレスもない場合は、C言語版と同じく CN と比べる
+// (pq_verify_peer_name_matches_certificate 相当)。
+func certMatchesHost(cert *x509.Certificate, host string) bool {
+	if len(cert.DNSNames) > 0 || len(cert.IPAddresses) > 0 {
+		return cert.VerifyHostname(host) == nil
+	}
+	return wildcardCertificateMatch(cert.Subject.CommonName, host)
+}
+
+// wildcardCertificateMatch は証明書の名前 name が host と一致するかを返す。
+// 大文字と小文字は区別せず、先頭の "*." は1つのラベルに一致する (wildcard_certificate_match 相当)。
+func wildcardCertificateMatch(name, host string) bool {
+	name, host = strings.ToLower(name), strings.ToLower(host)
+	if suffix, ok := strings.CutPrefix(name, "*."); ok {
+		label, rest, found := strings.Cut(host, ".")
+		return found && label != "" && rest == suffix
+	}
+	return name != "" && name == host
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
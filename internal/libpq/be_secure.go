@@ -0,0 +1,185 @@
+package libpq
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// SSL/TLS 接続 (be-secure.c, be-secure-openssl.c 相当)
+// ----------------------------------------------------------------
+// C言語版では OpenSSL を使うが、Go言語版では crypto/tls を使う。
+// postmaster が起動時に証明書を読み込み (be_tls_init)、バックエンドは
+// SSLRequest を受け取ったら接続を TLS に切り替える (be_tls_open_server)。
+
+// sslContext は読み込み済みの TLS の設定 (SSL_context 相当)。SSL が無効な場合は nil。
+var sslContext *tls.Config
+
+// SecureInitialize はサーバー証明書と秘密鍵を読み込む (secure_initialize / be_tls_init 相当)。
+// caFile が空でなければ、その認証局が発行したクライアント証明書を検証する。
+// クライアント証明書の提示は任意で、必須にするかどうかは認証 (pg_hba.conf の clientcert) で決める。
+func SecureInitialize(certFile, keyFile, caFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("could not load server certificate file \"%s\": %w", certFile, err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		// ssl_min_protocol_version の既定値 (TLSv1.2) 相当
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.NoClientCert,
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("could not load root certificate file \"%s\": %w", caFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("could not load root certificate file \"%s\": no certificates found", caFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	sslContext = cfg
+	return nil
+}
+
+// SSLLoaded は SSL 接続を受け付けられるかを返す (LoadedSSL 相当)
+func SSLLoaded() bool {
+	return sslContext != nil
+}
+
+// ErrUnencryptedDataAfterSSLRequest は SSLRequest の直後に、TLS のハンドシェイクを
+// 待たずに平文のデータが送られてきたことを表す。中間者による挿入の恐れがあるため接続を切る。
+var ErrUnencryptedDataAfterSSLRequest = errors.New("received unencrypted data after SSL request")
+
+// SecureOpenServer は接続を TLS に切り替える (secure_open_server / be_tls_open_server 相当)。
+// SSLRequest に 'S' と応答した後に呼ぶ。
+func (p *Port) SecureOpenServer() error {
+	if p.r.Buffered() > 0 {
+		return ErrUnencryptedDataAfterSSLRequest
+	}
+
+	conn := tls.Server(p.conn, sslContext)
+	if err := conn.Handshake(); err != nil {
+		return fmt.Errorf("could not accept SSL connection: %w", err)
+	}
+	p.conn = conn
+	p.r = bufio.NewReaderSize(meteredConn{conn, p}, pqRecvBufferSize)
+	p.w = bufio.NewWriterSize(meteredConn{conn, p}, pqSendBufferSize)
+	p.SSLInUse = true
+
+	// 提示されたクライアント証明書は、ハンドシェイクの時点で認証局による検証が済んでいる
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) > 0 {
+		peer := state.PeerCertificates[0]
+		p.PeerCN = peer.Subject.CommonName
+		p.PeerDN = peer.Subject.String()
+		p.PeerCertValid = true
+	}
+	return nil
+}
+
+// SSLVersion は使用中の TLS のバージョン名を返す (be_tls_get_version 相当)。
+// SSL を使っていない場合は空文字列を返す。
+func (p *Port) SSLVersion() string {
+	conn, ok := p.conn.(*tls.Conn)
+	if !ok {
+		return ""
+	}
+	switch conn.ConnectionState().Version {
+	case tls.VersionTLS12:
+		return "TLSv1.2"
+	case tls.VersionTLS13:
+		return "TLSv1.3"
+	}
+	return "unknown"
+}
+
+// SSLCipher は使用中の暗号スイートの名前を返す (be_tls_get_cipher 相当)
+func (p *Port) SSLCipher() string {
+	conn, ok := p.conn.(*tls.Conn)
+	if !ok {
+		return ""
+	}
+	return tls.CipherSuiteName(conn.ConnectionState().CipherSuite)
+}
+
+// SSLBits は使用中の暗号の鍵長をビット数で返す (be_tls_get_cipher_bits 相当)
+func (p *Port) SSLBits() int {
+	switch name := p.SSLCipher(); {
+	case strings.Contains(name, "AES_128"):
+		return 128
+	case strings.Contains(name, "AES_256"), strings.Contains(name, "CHACHA20"):
+		return 256
+	case strings.Contains(name, "3DES"):
+		return 112
+	}
+	return 0
+}
+
+// peerCertificate はクライアント証明書を返す。提示されていなければ nil を返す。
+func (p *Port) peerCertificate() *x509.Certificate {
+	conn, ok := p.conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	if certs := conn.ConnectionState().PeerCertificates; len(certs) > 0 {
+		return certs[0]
+	}
+	return nil
+}
+
+// SSLPeerSerial はクライアント証明書のシリアル番号を10進数で返す (be_tls_get_peer_serial 相当)。
+// 証明書が提示されていなければ空文字列を返す。
+func (p *Port) SSLPeerSerial() string {
+	if cert := p.peerCertificate(); cert != nil {
+		return cert.SerialNumber.String()
+	}
+	return ""
+}
+
+// SSLIssuerDN はクライアント証明書の発行者の DN を返す (be_tls_get_peer_issuer_name 相当)。
+// 証明書が提示されていなければ空文字列を返す。
+func (p *Port) SSLIssuerDN() string {
+	if cert := p.peerCertificate(); cert != nil {
+		return cert.Issuer.String()
+	}
+	return ""
+}
+
+// CertificateHash はサーバー証明書のハッシュを返す (be_tls_get_certificate_hash 相当)。
+// SCRAM のチャネルバインディング (tls-server-end-point) に使う。ハッシュ関数は
+// 証明書の署名に使われたものと同じにするが、MD5 と SHA-1 の場合は SHA-256 を使う (RFC 5929)。
+func (p *Port) CertificateHash() ([]byte, error) {
+	if !p.SSLInUse {
+		return nil, errors.New("channel binding requires an SSL connection")
+	}
+	cert, err := x509.ParseCertificate(sslContext.Certificates[0].Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("could not parse server certificate: %w", err)
+	}
+	var h hash.Hash
+	switch cert.SignatureAlgorithm {
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384, x509.SHA384WithRSAPSS:
+		h = sha512.New384()
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512, x509.SHA512WithRSAPSS:
+		h = sha512.New()
+	case x509.PureEd25519:
+		return nil, errors.New("could not determine server certificate signature algorithm")
+	default:
+		h = sha256.New()
+	}
+	h.Write(cert.Raw)
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,389 @@
+package libpq
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// クライアント接続 (libpq-be.h の Port, pqcomm.c 相当)
+// ----------------------------------------------------------------
+// C言語版では送受信バッファ (PqSendBuffer / PqRecvBuffer) をプロセス全体の
+// 静的変数として持つが、Go言語版ではバックエンドがゴルーチンとして動くため、
+// バッファは接続ごとの Port に持たせる。
+
+// Port は1つのクライアント接続とその属性を表す。
+type Port struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+
+	// RemoteHost は接続元のアドレス (remote_host 相当)
+	RemoteHost string
+	// RemotePort は接続元のポート番号 (remote_port 相当)
+	RemotePort string
+
+	// 以下はスタートアップパケットから取り出した値
+	Proto          ProtocolVersion
+	DatabaseName   string
+	UserName       string
+	CmdlineOptions string
+	// GUCOptions はスタートアップパケットで指定された実行時パラメータ (guc_options 相当)
+	GUCOptions      [][2]string
+	ApplicationName string
+
+	// SSLInUse は接続が TLS で暗号化されていることを表す (ssl_in_use 相当)
+	SSLInUse bool
+	// PeerCN と PeerDN はクライアント証明書のサブジェクトの CN と DN (peer_cn, peer_dn 相当)
+	PeerCN string
+	PeerDN string
+	// PeerCertValid はクライアント証明書が提示され、検証に成功したことを表す (peer_cert_valid 相当)
+	PeerCertValid bool
+
+	// BytesSent と BytesReceived はこの接続で送受信したバイト数。TLS の場合は暗号化する前の
+	// バイト数で、ハンドシェイクは含まない
+	BytesSent     int64
+	BytesReceived int64
+
+	// WaitEvent はクライアントへの送信で待つ間を記録する先。nil なら記録しない
+	WaitEvent *waitevent.Slot
+}
+
+// meteredConn は送受信したバイト数を Port に加算する
+type meteredConn struct {
+	net.Conn
+	port *Port
+}
+
+func (c meteredConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.port.BytesReceived += int64(n)
+	return n, err
+}
+
+// Write はクライアントが読むまで待つ間を ClientWrite として記録する
+func (c meteredConn) Write(b []byte) (int, error) {
+	c.port.WaitEvent.Start(waitevent.ClientWrite)
+	n, err := c.Conn.Write(b)
+	c.port.WaitEvent.End()
+	c.port.BytesSent += int64(n)
+	return n, err
+}
+
+// pqSendBufferSize と pqRecvBufferSize は送受信バッファの大きさ (PQ_SEND_BUFFER_SIZE,
+// PQ_RECV_BUFFER_SIZE 相当)。送信バッファが一杯になると、クライアントが読むまで書き込みで待つ。
+// バッファより大きなメッセージは、バッファを介さずに直接送る。
+const (
+	pqSendBufferSize = 8192
+	pqRecvBufferSize = 8192
+)
+
+// ErrConnectionClosed は、クライアントがメッセージの途中で接続を閉じたことを表す。
+var ErrConnectionClosed = errors.New("unexpected EOF on client connection")
+
+// NewPort は受け付けた接続から Port を作成する (pq_init 相当)
+func NewPort(conn net.Conn) *Port {
+	port := &Port{conn: conn}
+	port.r = bufio.NewReaderSize(meteredConn{conn, port}, pqRecvBufferSize)
+	port.w = bufio.NewWriterSize(meteredConn{conn, port}, pqSendBufferSize)
+	if port.IsUnixSocket() {
+		// Unix ドメインソケットの接続元にはアドレスがない
+		port.RemoteHost = "[local]"
+	} else if host, p, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil {
+		port.RemoteHost, port.RemotePort = host, p
+	} else {
+		port.RemoteHost = conn.RemoteAddr().String()
+	}
+	// 接続が黙って切れた場合に気付けるよう、キープアライブを有効にする
+	if err := setKeepalives(conn); err != nil {
+		logf("could not set TCP keepalive options: %s", syscallErrorMessage(err))
+	}
+	return port
+}
+
+// Conn は下位の接続を返す。
+func (p *Port) Conn() net.Conn {
+	return p.conn
+}
+
+// IsUnixSocket は Unix ドメインソケットの接続かどうかを返す (IS_AF_UNIX(port->raddr) 相当)
+func (p *Port) IsUnixSocket() bool {
+	_, ok := p.conn.(*net.UnixConn)
+	return ok
+}
+
+// Close は接続を閉じる。
+func (p *Port) Close() error {
+	return p.conn.Close()
+}
+
+// GetByte は1バイト読み取る (pq_getbyte 相当)
+func (p *Port) GetByte() (byte, error) {
+	return p.r.ReadByte()
+}
+
+// readLength はメッセージ長 (自身の4バイトを含む) を読み取る。
+func (p *Port) readLength() (int, error) {
+	var lenbuf [4]byte
+	if _, err := io.ReadFull(p.r, lenbuf[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, ErrConnectionClosed
+		}
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint32(lenbuf[:])), nil
+}
+
+// GetMessage はメッセージ種別バイトに続くメッセージ本体を読み取る (pq_getmessage 相当)。
+// maxlen を超える長さのメッセージはプロトコル違反とみなす。
+func (p *Port) GetMessage(maxlen int) ([]byte, error) {
+	n, err := p.readLength()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrConnectionClosed
+		}
+		return nil, err
+	}
+	if n < 4 || n-4 > maxlen {
+		return nil, errors.New("invalid message length")
+	}
+	body := make([]byte, n-4)
+	if _, err := io.ReadFull(p.r, body); err != nil {
+		return nil, ErrConnectionClosed
+	}
+	return body, nil
+}
+
+// GetStartupPacket はスタートアップパケットを読み取る。
+// スタートアップパケットにはメッセージ種別バイトがない。
+// クライアントが何も送らずに切断した場合は io.EOF を返す。
+func (p *Port) GetStartupPacket() ([]byte, error) {
+	n, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+	if n < 8 || n > MaxStartupPacketLen {
+		return nil, fmt.Errorf("invalid length of startup packet")
+	}
+	body := make([]byte, n-4)
+	if _, err := io.ReadFull(p.r, body); err != nil {
+		return nil, fmt.Errorf("incomplete startup packet")
+	}
+	return body, nil
+}
+
+// PutMessage はメッセージを送信バッファに書き込む (pq_putmessage 相当)
+func (p *Port) PutMessage(msgtype byte, body []byte) error {
+	var hdr [5]byte
+	hdr[0] = msgtype
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(body)+4))
+	if _, err := p.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := p.w.Write(body)
+	return err
+}
+
+// PutRaw はメッセージ種別や長さを付けずにバイト列を書き込む。
+// SSLRequest への応答 ('S' / 'N') などの1バイト応答に使う。
+func (p *Port) PutRaw(b []byte) error {
+	_, err := p.w.Write(b)
+	return err
+}
+
+// Flush は送信バッファをクライアントへ送り出す (pq_flush 相当)
+func (p *Port) Flush() error {
+	return p.w.Flush()
+}
+
+// ----------------------------------------------------------------
+// 待ち受けソケットの作成 (StreamServerPort 相当)
+// ----------------------------------------------------------------
+
+// MaxListen は待ち受けソケットの数の上限 (MAXLISTEN 相当)
+const MaxListen = 64
+
+// StreamServerPort は hostName の全てのアドレスで port を待ち受けるソケットを作り、
+// listeners に追加して返す (StreamServerPort 相当)。hostName が "*" なら全ての
+// IPv4・IPv6 アドレスで待ち受ける。アドレスごとの失敗はログに記録して続け、
+// 1つも作れなかった場合にエラーを返す。
+func StreamServerPort(hostName string, port int, listeners []net.Listener) ([]net.Listener, error) {
+	type address struct {
+		network string
+		ip      string
+	}
+	var addrs []address
+	if hostName == "*" {
+		addrs = []address{{"tcp4", "0.0.0.0"}, {"tcp6", "::"}}
+	} else {
+		ips, err := net.LookupIP(hostName)
+		if err != nil {
+			logf("could not translate host name \"%s\", service \"%d\" to address: %s",
+				hostName, port, lookupErrorMessage(err))
+			return listeners, errors.New("could not translate host name")
+		}
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				addrs = append(addrs, address{"tcp4", ip.String()})
+			} else {
+				addrs = append(addrs, address{"tcp6", ip.String()})
+			}
+		}
+	}
+
+	// 受け付けた接続のキープアライブは NewPort で設定するため、Go の既定の設定 (15 秒) は使わない
+	lc := net.ListenConfig{KeepAlive: -1}
+	added := 0
+	for _, a := range addrs {
+		family := "IPv4"
+		if a.network == "tcp6" {
+			family = "IPv6"
+		}
+		if len(listeners) >= MaxListen {
+			logf("could not bind to all requested addresses: MAXLISTEN (%d) exceeded", MaxListen)
+			break
+		}
+		ln, err := lc.Listen(context.Background(), a.network, net.JoinHostPort(a.ip, strconv.Itoa(port)))
+		if err != nil {
+			logWithHint(addrInUseHint(err, port), "could not bind %s address \"%s\": %s", family, a.ip, syscallErrorMessage(err))
+			continue
+		}
+		logf("listening on %s address \"%s\", port %d", family, a.ip, port)
+		listeners = append(listeners, ln)
+		added++
+	}
+	if added == 0 {
+		return listeners, errors.New("could not create listen socket")
+	}
+	return listeners, nil
+}
+
+// UnixSockPathBufLen は Unix ドメインソケットのパスの長さの上限 (終端の NUL を含む。UNIXSOCK_PATH_BUFLEN 相当)
+const UnixSockPathBufLen = 108
+
+// UnixSocketPath は socketDir にある port 番のソケットのパスを返す (UNIXSOCK_PATH 相当)
+func UnixSocketPath(socketDir string, port int) string {
+	return filepath.Join(socketDir, fmt.Sprintf(".s.PGSQL.%d", port))
+}
+
+// StreamServerUnixPort は socketDir に port 番の Unix ドメインソケットを作り、listeners に追加して
+// 返す (StreamServerPort の AF_UNIX の場合相当)。ソケットのファイルの許可モードは perm にする。
+// 以前のサーバーが残したファイルは、接続できなければ削除して作り直す。
+func StreamServerUnixPort(socketDir string, port int, perm os.FileMode, listeners []net.Listener) ([]net.Listener, error) {
+	path := UnixSocketPath(socketDir, port)
+	if len(path) >= UnixSockPathBufLen {
+		logf("Unix-domain socket path \"%s\" is too long (maximum %d bytes)", path, UnixSockPathBufLen-1)
+		return listeners, errors.New("socket path is too long")
+	}
+	if len(listeners) >= MaxListen {
+		logf("could not bind to all requested addresses: MAXLISTEN (%d) exceeded", MaxListen)
+		return listeners, errors.New("MAXLISTEN exceeded")
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil && errors.Is(err, syscall.EADDRINUSE) {
+		// 接続できなければ、異常終了したサーバーが残したファイルとみなす
+		if c, derr := net.Dial("unix", path); derr == nil {
+			c.Close()
+		} else if rerr := os.Remove(path); rerr == nil {
+			ln, err = net.Listen("unix", path)
+		}
+	}
+	if err != nil {
+		logWithHint(addrInUseHint(err, port), "could not bind Unix address \"%s\": %s", path, syscallErrorMessage(err))
+		return listeners, err
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		logf("could not set permissions of file \"%s\": %s", path, platform.OSErrorMessage(err))
+		ln.Close()
+		return listeners, err
+	}
+	logf("listening on Unix socket \"%s\"", path)
+	return append(listeners, ln), nil
+}
+
+// addrInUseHint は err がアドレスの使用中を表すなら、別の postmaster を疑うヒントを返す
+func addrInUseHint(err error, port int) string {
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return fmt.Sprintf("Is another postmaster already running on port %d?", port)
+	}
+	return ""
+}
+
+// LogHook はメッセージをサーバーログに書く (ereport 相当)。errutil は libpq に依存するため、libpq から
+// errutil は呼べない。errutil が init で設定する。nil なら標準エラー出力に書く。
+var LogHook func(level, message, detail, hint string)
+
+// logf は LOG のメッセージをサーバーログに書く
+func logf(format string, args ...any) {
+	logWithHint("", format, args...)
+}
+
+// logWithHint は LOG のメッセージをヒントとともにサーバーログに書く。hint が空ならヒントは付けない。
+func logWithHint(hint, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if LogHook != nil {
+		LogHook("LOG", message, "", hint)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "LOG:  %s\n", message)
+	if hint != "" {
+		fmt.Fprintf(os.Stderr, "HINT:  %s\n", hint)
+	}
+}
+
+// syscallErrorMessage はソケットの操作のエラーから、システムコールのエラーの部分だけを取り出す。
+func syscallErrorMessage(err error) string {
+	var se *os.SyscallError
+	if errors.As(err, &se) {
+		return se.Err.Error()
+	}
+	return err.Error()
+}
+
+func lookupErrorMessage(err error) string {
+	var de *net.DNSError
+	if errors.As(err, &de) {
+		return de.Err
+	}
+	return err.Error()
+}
+
+// setKeepalives は TCP の接続に tcp_keepalives_idle, tcp_keepalives_interval,
+// tcp_keepalives_count のキープアライブを設定する
+// (pq_setkeepalivesidle, pq_setkeepalivesinterval, pq_setkeepalivescount 相当)。
+// 各オペレーティングシステムのソケットオプションへの対応付けは Go の net パッケージが行う。
+func setKeepalives(conn net.Conn) error {
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	// パラメータの 0 はオペレーティングシステムの既定値を使うことを表す。
+	// Go の KeepAliveConfig では負の値が「変更しない」、0 が Go の既定値を表すため読み替える
+	orDefault := func(v int) int {
+		if v == 0 {
+			return -1
+		}
+		return v
+	}
+	return tcp.SetKeepAliveConfig(net.KeepAliveConfig{
+		Enable:   true,
+		Idle:     time.Duration(orDefault(guc.TCPKeepalivesIdle.Get())) * time.Second,
+		Interval: time.Duration(orDefault(guc.TCPKeepalivesInterval.Get())) * time.Second,
+		Count:    orDefault(guc.TCPKeepalivesCount.Get()),
+	})
+}
@@ -0,0 +1,169 @@
+package libpq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// ----------------------------------------------------------------
+// メッセージの組み立てと解析 (pqformat.c 相当)
+// ----------------------------------------------------------------
+
+// ErrInvalidMessageFormat は受信メッセージの形式が不正であることを表す。
+var ErrInvalidMessageFormat = errors.New("invalid message format")
+
+// messageFormatError は ErrInvalidMessageFormat と同じ扱いで、別のメッセージを持つエラー。
+// 受信側は errors.Is(err, ErrInvalidMessageFormat) で形式の不正を判定する。
+type messageFormatError string
+
+func (e messageFormatError) Error() string        { return string(e) }
+func (e messageFormatError) Is(target error) bool { return target == ErrInvalidMessageFormat }
+
+// Buffer は送信するメッセージを組み立てるバッファ (StringInfo + pq_beginmessage 相当)
+type Buffer struct {
+	msgtype byte
+	data    []byte
+}
+
+// BeginMessage はメッセージの組み立てを開始する (pq_beginmessage 相当)
+func BeginMessage(msgtype byte) *Buffer {
+	return &Buffer{msgtype: msgtype}
+}
+
+// maxReusedBufferSize は Reset で使い回すバッファの大きさの上限。大きな行を1度送っただけで、
+// その大きさのバッファを持ち続けないようにする
+const maxReusedBufferSize = 64 * 1024
+
+// Reset は組み立てたメッセージを捨てて、同じバッファで次のメッセージの組み立てを開始する
+// (pq_beginmessage_reuse 相当)
+func (b *Buffer) Reset(msgtype byte) {
+	b.msgtype = msgtype
+	if cap(b.data) > maxReusedBufferSize {
+		b.data = nil
+	} else {
+		b.data = b.data[:0]
+	}
+}
+
+// SendByte は1バイト追加する (pq_sendbyte 相当)
+func (b *Buffer) SendByte(c byte) {
+	b.data = append(b.data, c)
+}
+
+// SendInt16 は16ビット整数をネットワークバイトオーダーで追加する (pq_sendint16 相当)
+func (b *Buffer) SendInt16(i int16) {
+	b.data = binary.BigEndian.AppendUint16(b.data, uint16(i))
+}
+
+// SendInt32 は32ビット整数をネットワークバイトオーダーで追加する (pq_sendint32 相当)
+func (b *Buffer) SendInt32(i int32) {
+	b.data = binary.BigEndian.AppendUint32(b.data, uint32(i))
+}
+
+// SendInt64 は64ビット整数をネットワークバイトオーダーで追加する (pq_sendint64 相当)
+func (b *Buffer) SendInt64(i int64) {
+	b.data = binary.BigEndian.AppendUint64(b.data, uint64(i))
+}
+
+// SendString は NUL 終端文字列を追加する (pq_sendstring 相当)
+func (b *Buffer) SendString(s string) {
+	b.data = append(b.data, s...)
+	b.data = append(b.data, 0)
+}
+
+// SendBytes はバイト列をそのまま追加する (pq_sendbytes 相当)
+func (b *Buffer) SendBytes(p []byte) {
+	b.data = append(b.data, p...)
+}
+
+// SendCountedText は長さ (int32) に続けてバイト列を追加する (pq_sendcountedtext 相当)
+func (b *Buffer) SendCountedText(p []byte) {
+	b.SendInt32(int32(len(p)))
+	b.data = append(b.data, p...)
+}
+
+// Bytes は組み立て中のメッセージ本体を返す。
+func (b *Buffer) Bytes() []byte {
+	return b.data
+}
+
+// EndMessage は組み立てたメッセージを送信バッファに書き込む (pq_endmessage 相当)
+func (b *Buffer) EndMessage(p *Port) error {
+	return p.PutMessage(b.msgtype, b.data)
+}
+
+// Message は受信したメッセージ本体を先頭から読み進めるためのカーソル (StringInfo + cursor 相当)
+type Message struct {
+	data   []byte
+	cursor int
+}
+
+// NewMessage は受信したメッセージ本体から Message を作る。
+func NewMessage(data []byte) *Message {
+	return &Message{data: data}
+}
+
+// Remaining は未読のバイト数を返す。
+func (m *Message) Remaining() int {
+	return len(m.data) - m.cursor
+}
+
+// GetMsgByte は1バイト読み取る (pq_getmsgbyte 相当)
+func (m *Message) GetMsgByte() (byte, error) {
+	if m.cursor >= len(m.data) {
+		return 0, messageFormatError("no data left in message")
+	}
+	c := m.data[m.cursor]
+	m.cursor++
+	return c, nil
+}
+
+// GetMsgInt16 は16ビット整数を読み取る (pq_getmsgint(msg, 2) 相当)
+func (m *Message) GetMsgInt16() (int16, error) {
+	if m.Remaining() < 2 {
+		return 0, ErrInvalidMessageFormat
+	}
+	v := binary.BigEndian.Uint16(m.data[m.cursor:])
+	m.cursor += 2
+	return int16(v), nil
+}
+
+// GetMsgInt32 は32ビット整数を読み取る (pq_getmsgint(msg, 4) 相当)
+func (m *Message) GetMsgInt32() (int32, error) {
+	if m.Remaining() < 4 {
+		return 0, ErrInvalidMessageFormat
+	}
+	v := binary.BigEndian.Uint32(m.data[m.cursor:])
+	m.cursor += 4
+	return int32(v), nil
+}
+
+// GetMsgBytes は n バイト読み取る (pq_getmsgbytes 相当)
+func (m *Message) GetMsgBytes(n int) ([]byte, error) {
+	if n < 0 || m.Remaining() < n {
+		return nil, messageFormatError("insufficient data left in message")
+	}
+	p := m.data[m.cursor : m.cursor+n]
+	m.cursor += n
+	return p, nil
+}
+
+// GetMsgString は NUL 終端文字列を読み取る (pq_getmsgstring 相当)
+func (m *Message) GetMsgString() (string, error) {
+	i := bytes.IndexByte(m.data[m.cursor:], 0)
+	if i < 0 {
+		return "", ErrInvalidMessageFormat
+	}
+	s := string(m.data[m.cursor : m.cursor+i])
+	m.cursor += i + 1
+	return s, nil
+}
+
+// GetMsgEnd はメッセージを最後まで読み取ったことを確認する (pq_getmsgend 相当)
+func (m *Message) GetMsgEnd() error {
+	if m.cursor != len(m.data) {
+		return ErrInvalidMessageFormat
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package libpq
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// FuzzMessage は Buffer で組み立てたメッセージ本体を Message で読み戻すと同じ値になることと、
+// 壊れた本体を読んでも panic せず ErrInvalidMessageFormat に当たるエラーを返すことを確かめる。
+func FuzzMessage(f *testing.F) {
+	f.Add(byte('S'), int16(0), int32(0), "", []byte{})
+	f.Add(byte(0), int16(-1), int32(-1), "postgres", []byte{0xff, 0xff})
+	f.Add(byte(0xff), int16(0x7fff), int32(0x7fffffff), "user\x01", []byte("\x00\x00\x00\x04"))
+
+	f.Fuzz(func(t *testing.T, c byte, i16 int16, i32 int32, s string, p []byte) {
+		// 送る側で NUL を含む文字列は組み立てられない
+		if strings.IndexByte(s, 0) >= 0 {
+			t.Skip()
+		}
+		buf := BeginMessage('X')
+		buf.SendByte(c)
+		buf.SendInt16(i16)
+		buf.SendInt32(i32)
+		buf.SendString(s)
+		buf.SendCountedText(p)
+		body := buf.Bytes()
+
+		msg := NewMessage(body)
+		if got, err := msg.GetMsgByte(); err != nil || got != c {
+			t.Fatalf("GetMsgByte = %d, %v; want %d", got, err, c)
+		}
+		if got, err := msg.GetMsgInt16(); err != nil || got != i16 {
+			t.Fatalf("GetMsgInt16 = %d, %v; want %d", got, err, i16)
+		}
+		if got, err := msg.GetMsgInt32(); err != nil || got != i32 {
+			t.Fatalf("GetMsgInt32 = %d, %v; want %d", got, err, i32)
+		}
+		if got, err := msg.GetMsgString(); err != nil || got != s {
+			t.Fatalf("GetMsgString = %q, %v; want %q", got, err, s)
+		}
+		n, err := msg.GetMsgInt32()
+		if err != nil || int(n) != len(p) {
+			t.Fatalf("counted text length = %d, %v; want %d", n, err, len(p))
+		}
+		if got, err := msg.GetMsgBytes(int(n)); err != nil || !bytes.Equal(got, p) {
+			t.Fatalf("GetMsgBytes = %x, %v; want %x", got, err, p)
+		}
+		if err := msg.GetMsgEnd(); err != nil {
+			t.Fatalf("GetMsgEnd: %v", err)
+		}
+
+		// 同じ本体を途中で切って読むと、どこかで形式の不正になる
+		if len(body) == 0 {
+			return
+		}
+		msg = NewMessage(body[:len(body)-1])
+		if err := readAll(msg); !errors.Is(err, ErrInvalidMessageFormat) {
+			t.Fatalf("truncated message: got %v, want invalid message format", err)
+		}
+	})
+}
+
+// readAll は FuzzMessage が組み立てる並びで msg を最後まで読む。
+func readAll(msg *Message) error {
+	if _, err := msg.GetMsgByte(); err != nil {
+		return err
+	}
+	if _, err := msg.GetMsgInt16(); err != nil {
+		return err
+	}
+	if _, err := msg.GetMsgInt32(); err != nil {
+		return err
+	}
+	if _, err := msg.GetMsgString(); err != nil {
+		return err
+	}
+	n, err := msg.GetMsgInt32()
+	if err != nil {
+		return err
+	}
+	if _, err := msg.GetMsgBytes(int(n)); err != nil {
+		return err
+	}
+	if msg.Remaining() < 0 {
+		return errors.New("cursor moved past the end of the message")
+	}
+	return msg.GetMsgEnd()
+}
@@ -0,0 +1,111 @@
+package libpq
+
+// ----------------------------------------------------------------
+// フロントエンド/バックエンド プロトコルの定数 (libpq/protocol.h, pqcomm.h 相当)
+// ----------------------------------------------------------------
+
+// ProtocolVersion はプロトコルのバージョン番号 (ProtocolVersion 相当)
+type ProtocolVersion uint32
+
+// PgProtocol はメジャー・マイナー番号からバージョン番号を作る (PG_PROTOCOL 相当)
+func PgProtocol(major, minor uint32) ProtocolVersion {
+	return ProtocolVersion(major<<16 | minor)
+}
+
+func (v ProtocolVersion) Major() uint32 { return uint32(v) >> 16 }
+func (v ProtocolVersion) Minor() uint32 { return uint32(v) & 0xffff }
+
+// スタートアップパケットの先頭に置かれる特殊なリクエストコード
+const (
+	CancelRequestCode   = 1234<<16 | 5678 // CANCEL_REQUEST_CODE
+	NegotiateSSLCode    = 1234<<16 | 5679 // NEGOTIATE_SSL_CODE
+	NegotiateGSSCode    = 1234<<16 | 5680 // NEGOTIATE_GSS_CODE
+	MaxStartupPacketLen = 10000           // MAX_STARTUP_PACKET_LENGTH
+	PqLargeMessageLimit = 0x3fffffff      // PQ_LARGE_MESSAGE_LIMIT (MaxAllocSize - 1)
+	PqSmallMessageLimit = 10000           // PQ_SMALL_MESSAGE_LIMIT
+)
+
+var (
+	// PgProtocolEarliest, PgProtocolLatest はサポートするプロトコルの範囲。
+	PgProtocolEarliest = PgProtocol(3, 0)
+	PgProtocolLatest   = PgProtocol(3, 0)
+)
+
+// フロントエンドが送信するメッセージ種別
+const (
+	PqMsgBind                = 'B'
+	PqMsgClose               = 'C'
+	PqMsgDescribe            = 'D'
+	PqMsgExecute             = 'E'
+	PqMsgFunctionCall        = 'F'
+	PqMsgFlush               = 'H'
+	PqMsgParse               = 'P'
+	PqMsgQuery               = 'Q'
+	PqMsgSync                = 'S'
+	PqMsgTerminate           = 'X'
+	PqMsgCopyFail            = 'f'
+	PqMsgGSSResponse         = 'p'
+	PqMsgPasswordMessage     = 'p'
+	PqMsgSASLInitialResponse = 'p'
+	PqMsgSASLResponse        = 'p'
+)
+
+// バックエンドが送信するメッセージ種別
+const (
+	PqMsgParseComplete            = '1'
+	PqMsgBindComplete             = '2'
+	PqMsgCloseComplete            = '3'
+	PqMsgNotificationResponse     = 'A'
+	PqMsgCommandComplete          = 'C'
+	PqMsgDataRow                  = 'D'
+	PqMsgErrorResponse            = 'E'
+	PqMsgCopyInResponse           = 'G'
+	PqMsgCopyOutResponse          = 'H'
+	PqMsgEmptyQueryResponse       = 'I'
+	PqMsgBackendKeyData           = 'K'
+	PqMsgNoticeResponse           = 'N'
+	PqMsgAuthenticationRequest    = 'R'
+	PqMsgParameterStatus          = 'S'
+	PqMsgRowDescription           = 'T'
+	PqMsgFunctionCallResponse     = 'V'
+	PqMsgNegotiateProtocolVersion = 'v'
+	PqMsgNoData                   = 'n'
+	PqMsgPortalSuspended          = 's'
+	PqMsgParameterDescription     = 't'
+	PqMsgReadyForQuery            = 'Z'
+)
+
+// 認証要求 (AuthenticationRequest) の種別
+const (
+	AuthReqOk       = 0
+	AuthReqPassword = 3
+	AuthReqMD5      = 5
+	AuthReqGSS      = 7
+	AuthReqGSSCont  = 8
+	AuthReqSSPI     = 9
+	AuthReqSASL     = 10
+	AuthReqSASLCont = 11
+	AuthReqSASLFin  = 12
+)
+
+// ErrorResponse / NoticeResponse のフィールド識別子 (postgres_ext.h の PG_DIAG_* 相当)
+const (
+	PgDiagSeverity             = 'S'
+	PgDiagSeverityNonlocalized = 'V'
+	PgDiagSqlstate             = 'C'
+	PgDiagMessagePrimary       = 'M'
+	PgDiagMessageDetail        = 'D'
+	PgDiagMessageHint          = 'H'
+	PgDiagStatementPosition    = 'P'
+	PgDiagInternalPosition     = 'p'
+	PgDiagInternalQuery        = 'q'
+	PgDiagContext              = 'W'
+	PgDiagSchemaName           = 's'
+	PgDiagTableName            = 't'
+	PgDiagColumnName           = 'c'
+	PgDiagDatatypeName         = 'd'
+	PgDiagConstraintName       = 'n'
+	PgDiagSourceFile           = 'F'
+	PgDiagSourceLine           = 'L'
+	PgDiagSourceFunction       = 'R'
+)
@@ -0,0 +1,44 @@
+package miscadmin
+
+import (
+	"fmt"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+)
+
+// ----------------------------------------------------------------
+// 接続数の上限 (globals.c, postinit.c の InitializeMaxBackends 相当)
+// ----------------------------------------------------------------
+// 接続数の上限と予約する枠の数は、設定パラメータ max_connections,
+// superuser_reserved_connections, reserved_connections で決まる。
+//
+// バックエンドの一覧 (C言語版の PGPROC の配列) には、クライアントの接続の枠とは別に、
+// autovacuum のランチャーとワーカー、バックグラウンドワーカーの枠を確保する。
+// クライアントの接続が max_connections に達しても、これらのプロセスは起動できる。
+
+// MaxBackendsLimit はバックエンドの一覧の大きさの上限 (MAX_BACKENDS 相当)
+const MaxBackendsLimit = 0x3FFFF
+
+// MaxBackends はバックエンドの一覧の大きさ (MaxBackends 相当)。max_connections に
+// autovacuum_max_workers、autovacuum のランチャーの1つ、max_worker_processes を加えた数。
+func MaxBackends() int {
+	return guc.MaxConnections.Get() + guc.AutovacuumMaxWorkers.Get() + 1 + guc.MaxWorkerProcesses.Get()
+}
+
+// CheckMaxBackends はバックエンドの一覧の大きさが上限を超えないかを確かめる
+// (InitializeMaxBackends 相当)。postmaster の起動時に呼ぶ。
+func CheckMaxBackends() error {
+	if MaxBackends() > MaxBackendsLimit {
+		return fmt.Errorf("too many server processes configured: \"max_connections\" (%d) plus \"autovacuum_max_workers\" (%d) plus \"max_worker_processes\" (%d) must be less than %d",
+			guc.MaxConnections.Get(), guc.AutovacuumMaxWorkers.Get(), guc.MaxWorkerProcesses.Get(), MaxBackendsLimit)
+	}
+	return nil
+}
+
+// MaxLivePostmasterChildren は postmaster が同時に起動しておく子 (ゴルーチン) の上限
+// (MaxLivePostmasterChildren 相当)。上限を超えた接続には、エラーを返すだけの
+// バックエンドすら起動せずに接続を閉じる。エラーを返すためのバックエンドの分も見込んで、
+// バックエンドの一覧の大きさの2倍とする。
+func MaxLivePostmasterChildren() int {
+	return 2 * MaxBackends()
+}
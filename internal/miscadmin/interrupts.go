@@ -0,0 +1,89 @@
+package miscadmin
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ----------------------------------------------------------------
+// 割り込みの受け付け (miscadmin.h の CHECK_FOR_INTERRUPTS, globals.c 相当)
+// ----------------------------------------------------------------
+// C言語版ではシグナルハンドラが大域変数 (InterruptPending, QueryCancelPending) を立て、
+// 処理の区切りごとに CHECK_FOR_INTERRUPTS() で確認する。Go言語版では
+// 1つのプロセスに複数のバックエンドが同居するため、フラグはバックエンドごとに持つ。
+// 他のゴルーチン (キャンセル要求を受け付けたゴルーチンなど) から立てられるよう、
+// フラグは atomic に操作する。
+
+// ErrQueryCanceled はクライアントの要求で文の実行を取り消したことを表す。
+var ErrQueryCanceled = errors.New("canceling statement due to user request")
+
+// ErrProcDie は管理者の要求でセッションを終了することを表す。
+var ErrProcDie = errors.New("terminating connection due to administrator command")
+
+// Interrupts は1つのバックエンドに届いた割り込み要求。
+type Interrupts struct {
+	queryCancelPending atomic.Bool
+	procDiePending     atomic.Bool
+	quickDiePending    atomic.Bool
+
+	// wakeup はコマンドを待っているバックエンドを起こす。C言語版ではシグナルの到着で
+	// 待ちが中断されるが、Go言語版では受信を中断する手段をバックエンドが登録しておく。
+	wakeup func()
+}
+
+// SetWakeup はコマンドを待っているバックエンドを起こす関数を登録する。
+// 他のゴルーチンから割り込みを要求できるようになる前に呼ぶ。
+func (in *Interrupts) SetWakeup(fn func()) {
+	in.wakeup = fn
+}
+
+// SetProcDiePending はセッションの終了を要求する (die 相当)。
+// コマンドを待っているバックエンドは、待ちを中断して終了する。
+func (in *Interrupts) SetProcDiePending() {
+	in.procDiePending.Store(true)
+	if in.wakeup != nil {
+		in.wakeup()
+	}
+}
+
+// SetQuickDiePending は他のバックエンドの異常終了を理由にセッションの終了を要求する
+// (quickdie 相当)。終了の要求として扱い、クライアントへの報告だけが異なる。
+func (in *Interrupts) SetQuickDiePending() {
+	in.quickDiePending.Store(true)
+	in.SetProcDiePending()
+}
+
+// QuickDiePending はセッションの終了が他のバックエンドの異常終了によるものかを返す
+func (in *Interrupts) QuickDiePending() bool {
+	return in != nil && in.quickDiePending.Load()
+}
+
+// ProcDiePending はセッションの終了が要求されているかを返す
+func (in *Interrupts) ProcDiePending() bool {
+	return in != nil && in.procDiePending.Load()
+}
+
+// SetQueryCancelPending は実行中の文の取り消しを要求する (StatementCancelHandler 相当)
+func (in *Interrupts) SetQueryCancelPending() {
+	in.queryCancelPending.Store(true)
+}
+
+// ClearQueryCancelPending は未処理の取り消し要求を捨てる。
+// コマンドを待っている間に届いた要求は、次の文には適用しない。
+func (in *Interrupts) ClearQueryCancelPending() {
+	in.queryCancelPending.Store(false)
+}
+
+// CheckForInterrupts は割り込み要求を確認する (CHECK_FOR_INTERRUPTS / ProcessInterrupts 相当)。
+// セッションの終了が要求されていれば ErrProcDie を返す。この要求は消費せず、呼び出し側が
+// セッションを終了する。取り消しが要求されていれば要求を消費して ErrQueryCanceled を返す。
+// in が nil の場合は割り込みを受け付けない。
+func (in *Interrupts) CheckForInterrupts() error {
+	if in.ProcDiePending() {
+		return ErrProcDie
+	}
+	if in != nil && in.queryCancelPending.Swap(false) {
+		return ErrQueryCanceled
+	}
+	return nil
+}
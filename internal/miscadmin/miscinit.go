@@ -0,0 +1,278 @@
+package miscadmin
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+)
+
+// ----------------------------------------------------------------
+// データディレクトリの確認 (utils/init/miscinit.c の checkDataDir, ValidatePgVersion 相当)
+// ----------------------------------------------------------------
+// データディレクトリは initdb が作る。サーバーはデータディレクトリを所有する利用者で起動し、
+// 他の利用者が書き込めてはならない。グループに読み取りだけを許す 0750 も認める。
+
+// PgDirModeOwner と PgDirModeGroup はデータディレクトリに認めるパーミッション
+// (PG_DIR_MODE_OWNER, PG_DIR_MODE_GROUP 相当)
+const (
+	PgDirModeOwner fs.FileMode = 0700
+	PgDirModeGroup fs.FileMode = 0750
+)
+
+// pgModeMaskGroup はデータディレクトリに許さないパーミッション (PG_MODE_MASK_GROUP 相当)
+const pgModeMaskGroup fs.FileMode = 0027
+
+// CheckDataDir はデータディレクトリが存在し、所有者とパーミッションが正しく、このサーバーの
+// バージョンで作られたものかを確かめる (checkDataDir 相当)
+func CheckDataDir(dataDir string) error {
+	fi, err := os.Stat(dataDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("data directory \"%s\" does not exist", dataDir)
+		}
+		return fmt.Errorf("could not read permissions of directory \"%s\": %w", dataDir, errors.Unwrap(err))
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("specified data directory \"%s\" is not a directory", dataDir)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Geteuid() {
+		return fmt.Errorf("data directory \"%s\" has wrong ownership\nHINT:  The server must be started by the user that owns the data directory.", dataDir)
+	}
+	if fi.Mode().Perm()&pgModeMaskGroup != 0 {
+		return fmt.Errorf("data directory \"%s\" has invalid permissions\nDETAIL:  Permissions should be u=rwx (0700) or u=rwx,g=rx (0750).", dataDir)
+	}
+	return ValidatePgVersion(dataDir)
+}
+
+// ValidatePgVersion はデータディレクトリの PG_VERSION がこのサーバーのメジャーバージョンと
+// 一致するかを確かめる (ValidatePgVersion 相当)
+func ValidatePgVersion(dataDir string) error {
+	path := filepath.Join(dataDir, "PG_VERSION")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("\"%s\" is not a valid data directory\nDETAIL:  File \"%s\" is missing.", dataDir, path)
+		}
+		return fmt.Errorf("could not open file \"%s\": %w", path, errors.Unwrap(err))
+	}
+	version, _, _ := strings.Cut(string(b), "\n")
+	if version != pgconfig.PgMajorVersion {
+		return fmt.Errorf("database files are incompatible with server\nDETAIL:  The data directory was initialized by PostgreSQL version %s, which is not compatible with this version %s.",
+			version, pgconfig.PgVersion)
+	}
+	return nil
+}
+
+// DataDirSubdirs はデータディレクトリの中に initdb が作るディレクトリ (initdb.c の subdirs 相当)。
+// まだ使わないものも、C言語版と同じ構成にするために作る。
+var DataDirSubdirs = []string{
+	"global",
+	"pg_wal/archive_status",
+	"pg_wal/summaries",
+	"pg_commit_ts",
+	"pg_dynshmem",
+	"pg_notify",
+	"pg_serial",
+	"pg_snapshots",
+	"pg_subtrans",
+	"pg_twophase",
+	"pg_multixact",
+	"pg_multixact/members",
+	"pg_multixact/offsets",
+	"base",
+	"base/" + strconv.Itoa(int(catalog.Template1DbOid)),
+	"pg_replslot",
+	"pg_tblspc",
+	"pg_stat",
+	"pg_stat_tmp",
+	"pg_xact",
+	"pg_logical",
+	"pg_logical/snapshots",
+	"pg_logical/mappings",
+}
+
+// ValidateDataDirStructure は initdb が作るディレクトリが全て揃っていて、他の利用者が
+// 書き込めないことを確かめる (ValidateXLOGDirectoryStructure をデータディレクトリ全体に広げたもの)
+func ValidateDataDirStructure(dataDir string) error {
+	for _, dir := range DataDirSubdirs {
+		path := filepath.Join(dataDir, dir)
+		fi, err := os.Stat(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("required directory \"%s\" does not exist", path)
+			}
+			return fmt.Errorf("could not stat directory \"%s\": %w", path, errors.Unwrap(err))
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("required directory \"%s\" is not a directory", path)
+		}
+		if fi.Mode().Perm()&pgModeMaskGroup != 0 {
+			return fmt.Errorf("directory \"%s\" has invalid permissions\nDETAIL:  Permissions should be u=rwx (0700) or u=rwx,g=rx (0750).", path)
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// データディレクトリのロックファイル (miscinit.c の CreateLockFile、AddToDataDirFile 相当)
+// ----------------------------------------------------------------
+// postmaster はデータディレクトリに postmaster.pid を置き、同じデータディレクトリで2つ目の
+// サーバーが起動しないようにする。単一ユーザーモードのバックエンドも、PID を負にして同じ
+// ファイルを置く。ファイルの各行の内容は LockFileLine* の通りで、pg_ctl などが読む。
+//
+// ファイルが残っていても、その PID のプロセスが動いていなければ、異常終了したサーバーが
+// 残したものとみなし、消して作り直す。ファイルは正常に終了するときに消す。
+
+// DirectoryLockFile はデータディレクトリのロックファイルの名前 (DIRECTORY_LOCK_FILE 相当)
+const DirectoryLockFile = "postmaster.pid"
+
+// ロックファイルの行の番号 (pidfile.h の LOCK_FILE_LINE_* 相当)。1から数える。
+const (
+	LockFileLinePid        = 1 // postmaster の PID (単一ユーザーモードでは負の値)
+	LockFileLineDataDir    = 2 // データディレクトリ
+	LockFileLineStartTime  = 3 // 起動した時刻 (Unix 時間の秒)
+	LockFileLinePort       = 4 // ポート番号
+	LockFileLineSocketDir  = 5 // 最初の Unix ドメインソケットのディレクトリ
+	LockFileLineListenAddr = 6 // 最初の待ち受けアドレス
+	LockFileLineShmemKey   = 7 // 共有メモリのキーと ID
+	LockFileLinePmStatus   = 8 // postmaster の状態 (PM_STATUS_* の値)
+)
+
+// postmaster の状態 (pidfile.h の PM_STATUS_* 相当)。pg_ctl が読み替えずに済むよう、
+// どれも同じ長さにしてある。
+const (
+	PmStatusStarting = "starting"
+	PmStatusStopping = "stopping"
+	PmStatusReady    = "ready   "
+	PmStatusStandby  = "standby "
+)
+
+// lockFileMode はロックファイルのパーミッション
+const lockFileMode fs.FileMode = 0600
+
+// CheckDataDirLockFile はデータディレクトリを他のサーバーが使っていないことを確かめる。
+// ロックファイルがないか、動いていないプロセスが残したものであれば nil を返す。
+func CheckDataDirLockFile(dataDir string) error {
+	_, err := checkLockFileOwner(filepath.Join(dataDir, DirectoryLockFile), dataDir)
+	return err
+}
+
+// checkLockFileOwner はロックファイルを読み、書いたプロセスがまだ動いていればエラーを返す。
+// ファイルがなければ false を、異常終了したサーバーが残したものであれば true を返す。
+func checkLockFileOwner(path, dataDir string) (bool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("could not open lock file \"%s\": %w", path, errors.Unwrap(err))
+	}
+	if len(b) == 0 {
+		return false, fmt.Errorf("lock file \"%s\" is empty\nHINT:  Either another server is starting, or the lock file is the remnant of a previous server startup crash.", path)
+	}
+	line, _, _ := strings.Cut(string(b), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || pid == 0 {
+		return false, fmt.Errorf("bogus data in lock file \"%s\": \"%s\"", path, line)
+	}
+	// 負の PID は単一ユーザーモードのバックエンドが書いたもの
+	standalone := pid < 0
+	if standalone {
+		pid = -pid
+	}
+	// 自分自身か親の PID であれば、前回の起動で同じ PID を割り当てられたプロセスが残したもの
+	if pid == os.Getpid() || pid == os.Getppid() {
+		return true, nil
+	}
+	// シグナル 0 はプロセスの存在だけを確かめる。EPERM は他の利用者のプロセスが動いていることを表す
+	if err := syscall.Kill(pid, 0); err == nil || errors.Is(err, syscall.EPERM) {
+		if standalone {
+			return false, fmt.Errorf("lock file \"%s\" already exists\nHINT:  Is another postgres (PID %d) running in data directory \"%s\"?", path, pid, dataDir)
+		}
+		return false, fmt.Errorf("lock file \"%s\" already exists\nHINT:  Is another postmaster (PID %d) running in data directory \"%s\"?", path, pid, dataDir)
+	}
+	return true, nil
+}
+
+// CreateDataDirLockFile はデータディレクトリのロックファイルを作る (CreateDataDirLockFile 相当)。
+// 最初の5行 (PID、データディレクトリ、起動した時刻、ポート番号、ソケットのディレクトリ) を書く。
+// ソケットのディレクトリは、ソケットを作った後に AddToDataDirFile で書くため空にしておく。
+// amPostmaster が false であれば単一ユーザーモードのバックエンドとして、PID を負にして書く。
+// 異常終了したサーバーが残したファイルは消して作り直す。
+func CreateDataDirLockFile(dataDir string, amPostmaster bool, startTime time.Time, port int) error {
+	path := filepath.Join(dataDir, DirectoryLockFile)
+	pid := os.Getpid()
+	if !amPostmaster {
+		pid = -pid
+	}
+	content := fmt.Sprintf("%d\n%s\n%d\n%d\n\n", pid, dataDir, startTime.Unix(), port)
+
+	// O_EXCL で作れなければ、残っているファイルを調べる。他のプロセスと同時に消したり作ったり
+	// しうるため、何度か繰り返す
+	for ntries := 0; ; ntries++ {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, lockFileMode)
+		if err == nil {
+			if _, err := f.WriteString(content); err != nil {
+				f.Close()
+				os.Remove(path)
+				return fmt.Errorf("could not write lock file \"%s\": %w", path, errors.Unwrap(err))
+			}
+			if err := f.Sync(); err != nil {
+				f.Close()
+				os.Remove(path)
+				return fmt.Errorf("could not write lock file \"%s\": %w", path, errors.Unwrap(err))
+			}
+			if err := f.Close(); err != nil {
+				os.Remove(path)
+				return fmt.Errorf("could not write lock file \"%s\": %w", path, errors.Unwrap(err))
+			}
+			return nil
+		}
+		if !errors.Is(err, fs.ErrExist) || ntries > 100 {
+			return fmt.Errorf("could not create lock file \"%s\": %w", path, errors.Unwrap(err))
+		}
+
+		if _, err := checkLockFileOwner(path, dataDir); err != nil {
+			return err
+		}
+		// 動いていないプロセスが残したファイル (か、確かめる間に消えたファイル) なので、消して作り直す
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("could not remove old lock file \"%s\": %w\nHINT:  The file seems accidentally left over, but it could not be removed. Please remove the file by hand and try again.", path, errors.Unwrap(err))
+		}
+	}
+}
+
+// AddToDataDirFile はロックファイルの target 行目を str に置き換える (AddToDataDirFile 相当)。
+// ファイルの行が足りなければ空の行を補う。ファイルを読めない場合は警告を出して続ける。
+func AddToDataDirFile(dataDir string, target int, str string) {
+	path := filepath.Join(dataDir, DirectoryLockFile)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		errutil.Report(nil, errutil.Elog(errutil.Log, "could not open file \"%s\": %v", path, errors.Unwrap(err)))
+		return
+	}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	for len(lines) < target {
+		lines = append(lines, "")
+	}
+	lines[target-1] = str
+	content := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), lockFileMode); err != nil {
+		errutil.Report(nil, errutil.Elog(errutil.Log, "could not write to file \"%s\": %v", path, errors.Unwrap(err)))
+	}
+}
+
+// RemoveDataDirLockFile はロックファイルを消す (UnlinkLockFiles 相当)。サーバーの終了時に呼ぶ。
+func RemoveDataDirLockFile(dataDir string) {
+	os.Remove(filepath.Join(dataDir, DirectoryLockFile))
+}
@@ -0,0 +1,137 @@
+package nodes
+
+import (
+	"slices"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+)
+
+// ----------------------------------------------------------------
+// タプルの位置の集合 (nodes/tidbitmap.c 相当)
+// ----------------------------------------------------------------
+// インデックスが返したタプルの位置 (TID) をページごとにまとめる。ページごとに、どのオフセット
+// 番号のタプルがあるかをビットマップで持つ。タプルではなくページ全体を加えたページは不正確
+// (lossy) な項目になり、ページの全てのタプルを読んで条件を確かめ直す必要があることを表す。
+//
+// ビットマップヒープスキャンは集合をブロック番号の順に辿るため、ヒープを先頭から順に一度だけ
+// 読めばよい。C言語版は、項目の数が work_mem を超えるとページの項目を1つのチャンクにまとめて
+// 不正確にするが、ここではまだ項目の数を制限しない。
+
+// wordsPerPage はページのビットマップの語の数 (WORDS_PER_PAGE 相当)
+const wordsPerPage = (int(storage.MaxOffsetNumber) + 63) / 64
+
+// pagetableEntry はページ1つ分の項目 (PagetableEntry 相当)
+type pagetableEntry struct {
+	// words はオフセット番号 n のタプルを n-1 番目のビットで表す
+	words [wordsPerPage]uint64
+	// lossy はページ全体を加えたことを表す。words は使わない (ischunk 相当)
+	lossy bool
+	// recheck はタプルを返したときに条件を確かめ直す必要があることを表す
+	recheck bool
+}
+
+// TIDBitmap はタプルの位置の集合 (TIDBitmap 相当)
+type TIDBitmap struct {
+	pages map[storage.BlockNumber]*pagetableEntry
+	// iterating はイテレーターを作った後であることを表す。以降は変更できない
+	iterating bool
+}
+
+// NewTIDBitmap は空の集合を作る (tbm_create 相当)
+func NewTIDBitmap() *TIDBitmap {
+	return &TIDBitmap{pages: make(map[storage.BlockNumber]*pagetableEntry)}
+}
+
+// page はブロック blk の項目を返す。なければ作る (tbm_get_pageentry 相当)
+func (tbm *TIDBitmap) page(blk storage.BlockNumber) *pagetableEntry {
+	if tbm.iterating {
+		panic("tidbitmap modified after iteration began")
+	}
+	e, ok := tbm.pages[blk]
+	if !ok {
+		e = &pagetableEntry{}
+		tbm.pages[blk] = e
+	}
+	return e
+}
+
+// AddTuples はタプルの位置を加える (tbm_add_tuples 相当)。recheck が真なら、それらのページの
+// タプルを返すときに条件を確かめ直させる。
+func (tbm *TIDBitmap) AddTuples(tids []storage.ItemPointer, recheck bool) {
+	for _, tid := range tids {
+		if !tid.Offset.IsValid() {
+			panic("tuple offset out of range")
+		}
+		e := tbm.page(tid.Block)
+		if !e.lossy {
+			bit := int(tid.Offset) - 1
+			e.words[bit/64] |= 1 << (bit % 64)
+		}
+		e.recheck = e.recheck || recheck
+	}
+}
+
+// AddPage はページの全てのタプルを加える (tbm_add_page 相当)。ページは不正確な項目になる。
+func (tbm *TIDBitmap) AddPage(blk storage.BlockNumber) {
+	e := tbm.page(blk)
+	*e = pagetableEntry{lossy: true}
+}
+
+// IsEmpty は集合が空かを返す (tbm_is_empty 相当)
+func (tbm *TIDBitmap) IsEmpty() bool {
+	return len(tbm.pages) == 0
+}
+
+// TBMIterateResult はイテレーターが返すページ1つ分 (TBMIterateResult 相当)
+type TBMIterateResult struct {
+	// Blockno はページのブロック番号
+	Blockno storage.BlockNumber
+	// Offsets はページのタプルのオフセット番号の昇順の並び。Lossy なら nil
+	Offsets []storage.OffsetNumber
+	// Lossy はページの全てのタプルを読む必要があることを表す (ntuples が -1 であること相当)
+	Lossy bool
+	// Recheck はタプルの条件を確かめ直す必要があることを表す。Lossy なら常に真
+	Recheck bool
+}
+
+// TBMIterator は集合をブロック番号の順に辿る (TBMIterator 相当)。1つの集合に複数の
+// イテレーターを作れ、それぞれ先頭から辿る。
+type TBMIterator struct {
+	tbm    *TIDBitmap
+	blocks []storage.BlockNumber
+	next   int
+}
+
+// BeginIterate はイテレーターを作る (tbm_begin_iterate 相当)。以降は集合を変更できない。
+func (tbm *TIDBitmap) BeginIterate() *TBMIterator {
+	tbm.iterating = true
+	blocks := make([]storage.BlockNumber, 0, len(tbm.pages))
+	for blk := range tbm.pages {
+		blocks = append(blocks, blk)
+	}
+	slices.Sort(blocks)
+	return &TBMIterator{tbm: tbm, blocks: blocks}
+}
+
+// Next は次のページを返す (tbm_iterate 相当)。全て返したら ok に false を返す。
+func (it *TBMIterator) Next() (res TBMIterateResult, ok bool) {
+	if it.next >= len(it.blocks) {
+		return TBMIterateResult{}, false
+	}
+	blk := it.blocks[it.next]
+	it.next++
+	e := it.tbm.pages[blk]
+	if e.lossy {
+		return TBMIterateResult{Blockno: blk, Lossy: true, Recheck: true}, true
+	}
+	res = TBMIterateResult{Blockno: blk, Recheck: e.recheck}
+	for w, word := range e.words {
+		for bit := 0; word != 0; bit++ {
+			if word&1 != 0 {
+				res.Offsets = append(res.Offsets, storage.OffsetNumber(w*64+bit+1))
+			}
+			word >>= 1
+		}
+	}
+	return res, true
+}
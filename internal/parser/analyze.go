@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 意味解析 (analyze.c, parse_target.c 相当)
+// ----------------------------------------------------------------
+// 構文木を、型の確定した Query に変換する。
+
+// ParseState は解析中の状態 (ParseState 相当)
+type ParseState struct {
+	// paramTypes は $n の型 (添字は n-1)。
+	paramTypes []catalog.Oid
+	// varParams が true の場合、型の指定されていないパラメータの型を
+	// 使われ方から推論する (parse_analyze_varparams 相当)
+	varParams bool
+	params    []*Param
+	// rtable は FROM 句のリレーション (p_rtable 相当)
+	rtable []*RangeTblEntry
+	// domainValueType はドメインの CHECK 制約の式を解析している間、VALUE の型 (ドメインの基の型) を
+	// 持つ (replace_domain_constraint_value を p_pre_columnref_hook に設定した状態相当)
+	domainValueType catalog.Oid
+}
+
+// ParseAnalyzeFixedparams は、パラメータの型が全て決まっている状態で文を解析する
+// (parse_analyze_fixedparams 相当)
+func ParseAnalyzeFixedparams(raw *RawStmt, paramTypes []catalog.Oid) (*Query, error) {
+	pstate := &ParseState{paramTypes: paramTypes}
+	return pstate.transformTopLevelStmt(raw)
+}
+
+// ParseAnalyzeVarparams は、型が未指定 (InvalidOid) のパラメータの型を推論しながら文を解析する
+// (parse_analyze_varparams 相当)。推論後のパラメータの型を返す。
+func ParseAnalyzeVarparams(raw *RawStmt, paramTypes []catalog.Oid) (*Query, []catalog.Oid, error) {
+	pstate := &ParseState{paramTypes: append([]catalog.Oid(nil), paramTypes...), varParams: true}
+	for i, t := range pstate.paramTypes {
+		if t == catalog.InvalidOid {
+			pstate.paramTypes[i] = catalog.UNKNOWNOID
+		}
+	}
+
+	query, err := pstate.transformTopLevelStmt(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 推論できなかったパラメータがないか確認し、推論結果を各 Param に反映する
+	// (check_variable_parameters 相当)
+	for i, t := range pstate.paramTypes {
+		if t == catalog.UNKNOWNOID {
+			return nil, nil, newError(errcodes.IndeterminateDatatype, "could not determine data type of parameter $%d", i+1)
+		}
+	}
+	for _, p := range pstate.params {
+		p.ParamType = pstate.paramTypes[p.ParamID-1]
+	}
+	return query, pstate.paramTypes, nil
+}
+
+// transformTopLevelStmt は1つの文を解析し、文の位置を記録する (transformTopLevelStmt 相当)
+func (ps *ParseState) transformTopLevelStmt(raw *RawStmt) (*Query, error) {
+	query, err := ps.transformStmt(raw)
+	if err != nil {
+		return nil, err
+	}
+	query.StmtLocation, query.StmtLen = raw.StmtLocation, raw.StmtLen
+	return query, nil
+}
+
+// transformStmt は1つの文を解析する (transformStmt 相当)
+func (ps *ParseState) transformStmt(raw *RawStmt) (*Query, error) {
+	switch n := raw.Stmt.(type) {
+	case *SelectStmt:
+		// SELECT INTO は CREATE TABLE AS として実行する (transformOptionalSelectInto 相当)
+		if n.IntoClause != nil {
+			query := *n
+			query.IntoClause = nil
+			ctas := &CreateTableAsStmt{Query: &query, Into: n.IntoClause, IsSelectInto: true}
+			return ps.transformCreateTableAsStmt(ctas)
+		}
+		return ps.transformSelectStmt(n)
+	case *CreateTableAsStmt:
+		return ps.transformCreateTableAsStmt(n)
+	default:
+		// ユーティリティ文は解析せず、構文木のまま実行する
+		return &Query{CommandType: CmdUtility, UtilityStmt: raw.Stmt}, nil
+	}
+}
+
+// transformCreateTableAsStmt は CREATE TABLE AS の問い合わせを解析する
+// (transformCreateTableAsStmt 相当)。文はユーティリティ文として実行し、解析した問い合わせを
+// 文の写しの Analyzed に持たせる。
+func (ps *ParseState) transformCreateTableAsStmt(stmt *CreateTableAsStmt) (*Query, error) {
+	query, err := ps.transformSelectStmt(stmt.Query)
+	if err != nil {
+		return nil, err
+	}
+	// 列名の指定は出力列より多くてはいけない (intorel_startup、create_ctas_nodata の検査相当)
+	if len(stmt.Into.ColNames) > len(query.TargetList) {
+		return nil, newError(errcodes.SyntaxError, "too many column names were specified")
+	}
+	ctas := *stmt
+	ctas.Analyzed = query
+	return &Query{CommandType: CmdUtility, UtilityStmt: &ctas}, nil
+}
+
+// transformSelectStmt は SELECT 文を解析する (transformSelectStmt 相当)
+func (ps *ParseState) transformSelectStmt(stmt *SelectStmt) (*Query, error) {
+	if stmt.IntoClause != nil {
+		return nil, newError(errcodes.SyntaxError, "SELECT ... INTO is not allowed here")
+	}
+	query := &Query{CommandType: CmdSelect}
+	if err := ps.transformFromClause(query, stmt.FromClause); err != nil {
+		return nil, err
+	}
+	for _, rt := range stmt.TargetList {
+		if cref, ok := rt.Val.(*ColumnRef); ok {
+			if _, star := cref.Fields[len(cref.Fields)-1].(*AStar); star {
+				tles, err := ps.expandColumnRefStar(cref)
+				if err != nil {
+					return nil, err
+				}
+				for _, te := range tles {
+					te.ResNo = len(query.TargetList) + 1
+					query.TargetList = append(query.TargetList, te)
+				}
+				continue
+			}
+		}
+		expr, err := ps.transformExpr(rt.Val)
+		if err != nil {
+			return nil, err
+		}
+		// 型が決まらないまま出力される定数は text とみなす (resolveTargetListUnknowns 相当)
+		if c, ok := expr.(*Const); ok && c.ConstType == catalog.UNKNOWNOID {
+			expr = &Const{ConstType: catalog.TEXTOID, ConstValue: c.ConstValue, Location: c.Location}
+		}
+		name := rt.Name
+		if name == "" {
+			name = FigureColname(rt.Val)
+		}
+		query.TargetList = append(query.TargetList, &TargetEntry{Expr: expr, ResNo: len(query.TargetList) + 1, ResName: name})
+	}
+	return query, nil
+}
+
+// FigureColname は別名のない出力列の名前を決める (FigureColname 相当)
+func FigureColname(node Node) string {
+	switch n := node.(type) {
+	case *TypeCast:
+		if name := FigureColname(n.Arg); name != "?column?" {
+			return name
+		}
+		return n.TypeName.Names[len(n.TypeName.Names)-1]
+	case *AConst:
+		if _, ok := n.Val.(*Boolean); ok {
+			return "bool"
+		}
+	case *FuncCall:
+		return n.Funcname[len(n.Funcname)-1]
+	case *ColumnRef:
+		if s, ok := n.Fields[len(n.Fields)-1].(*String); ok {
+			return s.Sval
+		}
+	}
+	return "?column?"
+}
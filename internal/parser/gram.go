@@ -0,0 +1,3115 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 構文解析 (gram.y / parser.c の raw_parser 相当)
+// ----------------------------------------------------------------
+// C言語版は bison で生成した LALR パーサを使うが、Go言語版では
+// 再帰下降パーサとして手書きで実装する。対応している構文は限定的で、
+// 未対応の構文は構文エラーとして報告する。
+
+// reservedKeywords は列の別名として AS なしでは使えない予約語 (kwlist.h の RESERVED_KEYWORD)
+var reservedKeywords = map[string]bool{
+	"all": true, "analyse": true, "analyze": true, "and": true, "any": true, "array": true,
+	"as": true, "asc": true, "asymmetric": true, "both": true, "case": true, "cast": true,
+	"check": true, "collate": true, "column": true, "constraint": true, "create": true,
+	"current_catalog": true, "current_date": true, "current_role": true, "current_time": true,
+	"current_timestamp": true, "current_user": true, "default": true, "deferrable": true,
+	"desc": true, "distinct": true, "do": true, "else": true, "end": true, "except": true,
+	"false": true, "fetch": true, "for": true, "foreign": true, "from": true, "grant": true,
+	"group": true, "having": true, "in": true, "initially": true, "intersect": true,
+	"into": true, "lateral": true, "leading": true, "limit": true, "localtime": true,
+	"localtimestamp": true, "not": true, "null": true, "offset": true, "on": true, "only": true,
+	"or": true, "order": true, "placing": true, "primary": true, "references": true,
+	"returning": true, "select": true, "session_user": true, "some": true, "symmetric": true,
+	"system_user": true, "table": true, "then": true, "to": true, "trailing": true, "true": true,
+	"union": true, "unique": true, "user": true, "using": true, "variadic": true, "when": true,
+	"where": true, "window": true, "with": true,
+}
+
+type parser struct {
+	lex  *lexer
+	tok  Token
+	peek *Token
+}
+
+// RawParser は問い合わせ文字列を文のリストに分解する (raw_parser 相当)。
+// 空の文 (連続するセミコロン等) は結果に含めない。
+func RawParser(query string) ([]*RawStmt, error) {
+	p := &parser{lex: &lexer{src: query}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var stmts []*RawStmt
+	for {
+		for p.tok.IsChar(';') {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if p.tok.Kind == EOF {
+			break
+		}
+
+		start := p.tok.Loc
+		stmt, err := p.parseStmt()
+		if err != nil {
+			return nil, err
+		}
+		end := p.tok.Loc
+		if p.tok.Kind != EOF && !p.tok.IsChar(';') {
+			return nil, p.syntaxError()
+		}
+		stmts = append(stmts, &RawStmt{Stmt: stmt, StmtLocation: start, StmtLen: end - start})
+	}
+	return stmts, nil
+}
+
+func (p *parser) advance() error {
+	if p.peek != nil {
+		p.tok = *p.peek
+		p.peek = nil
+		return nil
+	}
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// lookahead は現在のトークンの次のトークンを返す。
+func (p *parser) lookahead() (Token, error) {
+	if p.peek == nil {
+		tok, err := p.lex.next()
+		if err != nil {
+			return Token{}, err
+		}
+		p.peek = &tok
+	}
+	return *p.peek, nil
+}
+
+// syntaxError は現在のトークンの位置で構文エラーを作る (base_yyerror 相当)
+func (p *parser) syntaxError() error {
+	return p.syntaxErrorAt(p.tok)
+}
+
+// syntaxErrorAt は tok の位置の構文エラーを返す。先読みした後で前のトークンを報告するときに使う
+func (p *parser) syntaxErrorAt(tok Token) error {
+	if tok.Kind == EOF {
+		return &SyntaxError{Message: "syntax error at end of input", Position: tok.Loc}
+	}
+	text := p.lex.src[tok.Loc:tok.End]
+	return &SyntaxError{Message: fmt.Sprintf("syntax error at or near \"%s\"", text), Position: tok.Loc}
+}
+
+func (p *parser) expectChar(c byte) error {
+	if !p.tok.IsChar(c) {
+		return p.syntaxError()
+	}
+	return p.advance()
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.tok.IsKeyword(kw) {
+		return p.syntaxError()
+	}
+	return p.advance()
+}
+
+// acceptKeyword は現在のトークンが kw であれば読み進めて true を返す。
+func (p *parser) acceptKeyword(kw string) (bool, error) {
+	if !p.tok.IsKeyword(kw) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+// ----------------------------------------------------------------
+// 文
+// ----------------------------------------------------------------
+
+func (p *parser) parseStmt() (Node, error) {
+	switch {
+	case p.tok.IsKeyword("select"):
+		return p.parseSelectStmt()
+	case p.tok.IsKeyword("create"):
+		return p.parseCreateStmt()
+	case p.tok.IsKeyword("alter"):
+		return p.parseAlterStmt()
+	case p.tok.IsKeyword("drop"):
+		return p.parseDropStmt()
+	case p.tok.IsKeyword("set"):
+		return p.parseVariableSetStmt()
+	case p.tok.IsKeyword("reset"):
+		return p.parseVariableResetStmt()
+	case p.tok.IsKeyword("show"):
+		return p.parseVariableShowStmt()
+	case p.tok.IsKeyword("checkpoint"):
+		return &CheckPointStmt{}, p.advance()
+	case p.tok.IsKeyword("begin"), p.tok.IsKeyword("start"), p.tok.IsKeyword("commit"),
+		p.tok.IsKeyword("end"), p.tok.IsKeyword("rollback"), p.tok.IsKeyword("abort"),
+		p.tok.IsKeyword("savepoint"), p.tok.IsKeyword("release"), p.tok.IsKeyword("prepare"):
+		return p.parseTransactionStmt()
+	default:
+		return nil, p.syntaxError()
+	}
+}
+
+// parseSelectStmt は SELECT target_list [INTO into_clause] [FROM from_list] を解析する。
+func (p *parser) parseSelectStmt() (Node, error) {
+	if err := p.expectKeyword("select"); err != nil {
+		return nil, err
+	}
+	stmt := &SelectStmt{}
+	for {
+		rt, err := p.parseTargetEl()
+		if err != nil {
+			return nil, err
+		}
+		stmt.TargetList = append(stmt.TargetList, rt)
+		if !p.tok.IsChar(',') {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.tok.IsKeyword("into") {
+		into, err := p.parseIntoClause()
+		if err != nil {
+			return nil, err
+		}
+		stmt.IntoClause = into
+	}
+
+	if ok, err := p.acceptKeyword("from"); err != nil {
+		return nil, err
+	} else if !ok {
+		return stmt, nil
+	}
+	for {
+		rv, err := p.parseRelationExpr()
+		if err != nil {
+			return nil, err
+		}
+		if rv.Alias, err = p.parseOptAliasClause(); err != nil {
+			return nil, err
+		}
+		stmt.FromClause = append(stmt.FromClause, rv)
+		if !p.tok.IsChar(',') {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// parseIntoClause は INTO [TEMPORARY | TEMP] [TABLE] qualified_name を解析する (into_clause, OptTempTableName 相当)
+func (p *parser) parseIntoClause() (*IntoClause, error) {
+	if err := p.expectKeyword("into"); err != nil {
+		return nil, err
+	}
+	into := &IntoClause{}
+	temp, err := p.parseOptTemp()
+	if err != nil {
+		return nil, err
+	}
+	into.Temp = temp
+	if _, err := p.acceptKeyword("table"); err != nil {
+		return nil, err
+	}
+	if into.Rel, err = p.parseQualifiedName(); err != nil {
+		return nil, err
+	}
+	return into, nil
+}
+
+// parseOptTemp は TEMPORARY か TEMP があれば読み、一時テーブルかどうかを返す (OptTemp 相当)
+func (p *parser) parseOptTemp() (bool, error) {
+	if p.tok.IsKeyword("temporary") || p.tok.IsKeyword("temp") {
+		return true, p.advance()
+	}
+	return false, nil
+}
+
+// parseQualifiedName は [schema.]name の形のリレーション名を解析する (qualified_name 相当)
+func (p *parser) parseQualifiedName() (*RangeVar, error) {
+	rv := &RangeVar{Inh: true, Location: p.tok.Loc}
+	name, err := p.parseColId()
+	if err != nil {
+		return nil, err
+	}
+	rv.Relname = name
+	if p.tok.IsChar('.') {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if rv.Relname, err = p.parseColId(); err != nil {
+			return nil, err
+		}
+		rv.Schemaname = name
+	}
+	return rv, nil
+}
+
+// parseRelationExpr は FROM 句や ALTER TABLE のリレーションを解析する (relation_expr 相当)。
+// リレーションは qualified_name、qualified_name *、ONLY qualified_name、ONLY ( qualified_name ) の
+// いずれかで、ONLY の場合は継承した子のテーブルを含めない。
+func (p *parser) parseRelationExpr() (*RangeVar, error) {
+	only, err := p.acceptKeyword("only")
+	if err != nil {
+		return nil, err
+	}
+	paren := only && p.tok.IsChar('(')
+	if paren {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	rv, err := p.parseQualifiedName()
+	if err != nil {
+		return nil, err
+	}
+	rv.Inh = !only
+	switch {
+	case paren:
+		if err := p.expectChar(')'); err != nil {
+			return nil, err
+		}
+	case !only && p.tok.IsChar('*'):
+		// 子のテーブルを含めることを明示する。含めるのが既定のため、意味は変わらない
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return rv, nil
+}
+
+// parseOptAliasClause は [[AS] alias] を解析し、別名を返す (opt_alias_clause 相当)。書かれて
+// いなければ空を返す。
+func (p *parser) parseOptAliasClause() (string, error) {
+	if ok, err := p.acceptKeyword("as"); err != nil {
+		return "", err
+	} else if ok {
+		return p.parseColId()
+	}
+	if p.tok.Kind == IDENT && (p.tok.Quoted || !reservedKeywords[p.tok.Str]) {
+		alias := p.tok.Str
+		return alias, p.advance()
+	}
+	return "", nil
+}
+
+// parseColId は予約語でない識別子を解析する (ColId 相当)
+func (p *parser) parseColId() (string, error) {
+	if p.tok.Kind != IDENT || !p.tok.Quoted && reservedKeywords[p.tok.Str] {
+		return "", p.syntaxError()
+	}
+	name := p.tok.Str
+	return name, p.advance()
+}
+
+// parseCreateStmt は CREATE で始まる文を解析する。
+func (p *parser) parseCreateStmt() (Node, error) {
+	if err := p.expectKeyword("create"); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.tok.IsKeyword("role"), p.tok.IsKeyword("user"), p.tok.IsKeyword("group"):
+		return p.parseCreateRoleStmt()
+	case p.tok.IsKeyword("table"), p.tok.IsKeyword("temporary"), p.tok.IsKeyword("temp"):
+		return p.parseCreateTableStmt()
+	case p.tok.IsKeyword("domain"):
+		return p.parseCreateDomainStmt()
+	case p.tok.IsKeyword("cast"):
+		return p.parseCreateCastStmt()
+	case p.tok.IsKeyword("operator"):
+		return p.parseCreateOperatorStmt()
+	}
+	return nil, p.syntaxError()
+}
+
+// parseCreateTableStmt は CREATE [TEMPORARY | TEMP] TABLE [IF NOT EXISTS] qualified_name に続く
+// 文を解析する。'(' の中の列に型を書いた CREATE TABLE (CreateStmt 相当) と、列の名前だけを
+// 書いて AS SelectStmt が続く CREATE TABLE AS (CreateAsStmt 相当) のどちらかになる。
+//
+//	CREATE TABLE name ( [ColId Typename [, ...]] ) [INHERITS ( qualified_name [, ...] )]
+//	CREATE TABLE name [( ColId [, ...] )] AS SelectStmt [WITH [NO] DATA]
+func (p *parser) parseCreateTableStmt() (Node, error) {
+	temp, err := p.parseOptTemp()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("table"); err != nil {
+		return nil, err
+	}
+	ifNotExists := false
+	if p.tok.IsKeyword("if") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("not"); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("exists"); err != nil {
+			return nil, err
+		}
+		ifNotExists = true
+	}
+	rel, err := p.parseQualifiedName()
+	if err != nil {
+		return nil, err
+	}
+
+	// 列の並びは、AS が続けば CREATE TABLE AS の列名、続かなければ CREATE TABLE の列の定義になる。
+	// どちらか分かった後で型のない列を報告するため、その列の次のトークンを覚えておく
+	var elts []*ColumnDef
+	var paren, untyped *Token
+	typed := false
+	if p.tok.IsChar('(') {
+		tok := p.tok
+		paren = &tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if !p.tok.IsChar(')') {
+			for {
+				col := &ColumnDef{Location: p.tok.Loc}
+				if col.Colname, err = p.parseColId(); err != nil {
+					return nil, err
+				}
+				if tok := p.tok; tok.IsChar(',') || tok.IsChar(')') {
+					if untyped == nil {
+						untyped = &tok
+					}
+				} else {
+					typed = true
+					if col.TypeName, err = p.parseTypeName(); err != nil {
+						return nil, err
+					}
+				}
+				elts = append(elts, col)
+				if !p.tok.IsChar(',') {
+					break
+				}
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := p.expectChar(')'); err != nil {
+			return nil, err
+		}
+	}
+
+	if !p.tok.IsKeyword("as") {
+		if paren == nil {
+			return nil, p.syntaxError()
+		}
+		if untyped != nil {
+			return nil, p.syntaxErrorAt(*untyped)
+		}
+		stmt := &CreateStmt{Relation: rel, TableElts: elts, Temp: temp, IfNotExists: ifNotExists}
+		if ok, err := p.acceptKeyword("inherits"); err != nil {
+			return nil, err
+		} else if ok {
+			if stmt.InhRelations, err = p.parseQualifiedNameList(); err != nil {
+				return nil, err
+			}
+		}
+		return stmt, nil
+	}
+	switch {
+	case typed:
+		return nil, p.syntaxError()
+	case paren != nil && len(elts) == 0:
+		return nil, p.syntaxErrorAt(*paren)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	into := &IntoClause{Rel: rel, Temp: temp}
+	for _, col := range elts {
+		into.ColNames = append(into.ColNames, col.Colname)
+	}
+	stmt := &CreateTableAsStmt{Into: into, IfNotExists: ifNotExists}
+	query, err := p.parseSelectStmt()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Query = query.(*SelectStmt)
+
+	if p.tok.IsKeyword("with") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.acceptKeyword("no"); err != nil {
+			return nil, err
+		} else if ok {
+			into.SkipData = true
+		}
+		if err := p.expectKeyword("data"); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// parseQualifiedNameList は ( qualified_name [, ...] ) を解析する (OptInherit の qualified_name_list 相当)
+func (p *parser) parseQualifiedNameList() ([]*RangeVar, error) {
+	if err := p.expectChar('('); err != nil {
+		return nil, err
+	}
+	var rels []*RangeVar
+	for {
+		rv, err := p.parseQualifiedName()
+		if err != nil {
+			return nil, err
+		}
+		rels = append(rels, rv)
+		if !p.tok.IsChar(',') {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return rels, p.expectChar(')')
+}
+
+// parseCreateRoleStmt は CREATE ROLE|USER|GROUP RoleId [WITH] OptRoleList を解析する (CreateRoleStmt 相当)
+func (p *parser) parseCreateRoleStmt() (Node, error) {
+	stmt := &CreateRoleStmt{StmtType: RoleStmtRole}
+	switch {
+	case p.tok.IsKeyword("user"):
+		stmt.StmtType = RoleStmtUser
+	case p.tok.IsKeyword("group"):
+		stmt.StmtType = RoleStmtGroup
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.parseRoleId()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Role = name
+	if _, err := p.acceptKeyword("with"); err != nil {
+		return nil, err
+	}
+	for p.tok.Kind == IDENT && !p.tok.Quoted {
+		opt, err := p.parseOptRoleElem(true)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Options = append(stmt.Options, opt)
+	}
+	return stmt, nil
+}
+
+// parseDropStmt は DROP で始まる文を解析する。
+func (p *parser) parseDropStmt() (Node, error) {
+	if err := p.expectKeyword("drop"); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.tok.IsKeyword("role"), p.tok.IsKeyword("user"), p.tok.IsKeyword("group"):
+		return p.parseDropRoleStmt()
+	case p.tok.IsKeyword("table"), p.tok.IsKeyword("domain"):
+		return p.parseDropAnyNameStmt()
+	case p.tok.IsKeyword("cast"):
+		return p.parseDropCastStmt()
+	case p.tok.IsKeyword("operator"):
+		return p.parseDropOperatorStmt()
+	}
+	return nil, p.syntaxError()
+}
+
+// parseDropRoleStmt は DROP ROLE|USER|GROUP [IF EXISTS] role_list を解析する (DropRoleStmt 相当)
+func (p *parser) parseDropRoleStmt() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	stmt := &DropRoleStmt{}
+	if p.tok.IsKeyword("if") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("exists"); err != nil {
+			return nil, err
+		}
+		stmt.MissingOk = true
+	}
+	roles, err := p.parseRoleList()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Roles = roles
+	return stmt, nil
+}
+
+// parseAnyName は ColId [. attr_name ...] の形の名前を解析する (any_name 相当)
+func (p *parser) parseAnyName() ([]string, error) {
+	name, err := p.parseColId()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{name}
+	for p.tok.IsChar('.') {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		// attr_name は予約語も受け付ける (ColLabel)
+		if p.tok.Kind != IDENT {
+			return nil, p.syntaxError()
+		}
+		names = append(names, p.tok.Str)
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// parseOptDropBehavior は [CASCADE | RESTRICT] を解析する (opt_drop_behavior 相当)
+func (p *parser) parseOptDropBehavior() (DropBehavior, error) {
+	if ok, err := p.acceptKeyword("cascade"); err != nil || ok {
+		return DropCascade, err
+	}
+	_, err := p.acceptKeyword("restrict")
+	return DropRestrict, err
+}
+
+// parseOptIfExists は [IF EXISTS] を解析し、書かれていれば true を返す
+func (p *parser) parseOptIfExists() (bool, error) {
+	if !p.tok.IsKeyword("if") {
+		return false, nil
+	}
+	if err := p.advance(); err != nil {
+		return false, err
+	}
+	return true, p.expectKeyword("exists")
+}
+
+// parseCreateDomainStmt は CREATE DOMAIN any_name [AS] Typename ColQualList を解析する
+// (CreateDomainStmt 相当)
+func (p *parser) parseCreateDomainStmt() (Node, error) {
+	if err := p.expectKeyword("domain"); err != nil {
+		return nil, err
+	}
+	name, err := p.parseAnyName()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.acceptKeyword("as"); err != nil {
+		return nil, err
+	}
+	tn, err := p.parseTypeName()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &CreateDomainStmt{Domainname: name, TypeName: tn}
+	for p.tok.IsKeyword("constraint") || p.tok.IsKeyword("not") || p.tok.IsKeyword("null") ||
+		p.tok.IsKeyword("check") || p.tok.IsKeyword("default") {
+		con, err := p.parseColConstraint()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Constraints = append(stmt.Constraints, con)
+	}
+	return stmt, nil
+}
+
+// parseColConstraint は [CONSTRAINT name] に続く NOT NULL、NULL、CHECK (a_expr)、DEFAULT b_expr を
+// 解析する (ColConstraint, ColConstraintElem 相当)
+func (p *parser) parseColConstraint() (*Constraint, error) {
+	con := &Constraint{Location: p.tok.Loc}
+	if ok, err := p.acceptKeyword("constraint"); err != nil {
+		return nil, err
+	} else if ok {
+		if con.Conname, err = p.parseColId(); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case p.tok.IsKeyword("not"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		con.Contype = ConstrNotNull
+		return con, p.expectKeyword("null")
+	case p.tok.IsKeyword("null"):
+		con.Contype = ConstrNull
+		return con, p.advance()
+	case p.tok.IsKeyword("check"):
+		return con, p.parseCheckConstraintExpr(con)
+	case p.tok.IsKeyword("default"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		// b_expr には AND、OR、NOT を書けない
+		expr, err := p.parseAExpr(precCompare)
+		if err != nil {
+			return nil, err
+		}
+		con.Contype = ConstrDefault
+		con.RawExpr = expr
+		return con, nil
+	}
+	return nil, p.syntaxError()
+}
+
+// parseCheckConstraintExpr は CHECK ( a_expr ) を解析する
+func (p *parser) parseCheckConstraintExpr(con *Constraint) error {
+	if err := p.expectKeyword("check"); err != nil {
+		return err
+	}
+	if err := p.expectChar('('); err != nil {
+		return err
+	}
+	expr, err := p.parseAExpr(0)
+	if err != nil {
+		return err
+	}
+	con.Contype = ConstrCheck
+	con.RawExpr = expr
+	return p.expectChar(')')
+}
+
+// parseDomainConstraint は ALTER DOMAIN ADD の [CONSTRAINT name] CHECK (a_expr) | NOT NULL に続く
+// [NOT VALID] を解析する (DomainConstraint, DomainConstraintElem 相当)
+func (p *parser) parseDomainConstraint() (*Constraint, error) {
+	con := &Constraint{Location: p.tok.Loc}
+	if ok, err := p.acceptKeyword("constraint"); err != nil {
+		return nil, err
+	} else if ok {
+		if con.Conname, err = p.parseColId(); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case p.tok.IsKeyword("check"):
+		if err := p.parseCheckConstraintExpr(con); err != nil {
+			return nil, err
+		}
+	case p.tok.IsKeyword("not"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("null"); err != nil {
+			return nil, err
+		}
+		con.Contype = ConstrNotNull
+	default:
+		return nil, p.syntaxError()
+	}
+	if p.tok.IsKeyword("not") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("valid"); err != nil {
+			return nil, err
+		}
+		con.SkipValidation = true
+	}
+	return con, nil
+}
+
+// parseAlterDomainStmt は ALTER DOMAIN any_name に続く操作を解析する
+// (AlterDomainStmt、RenameStmt、AlterOwnerStmt の ALTER DOMAIN 相当)
+func (p *parser) parseAlterDomainStmt() (Node, error) {
+	if err := p.expectKeyword("domain"); err != nil {
+		return nil, err
+	}
+	name, err := p.parseAnyName()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &AlterDomainStmt{TypeName: name}
+	switch {
+	case p.tok.IsKeyword("set"), p.tok.IsKeyword("drop"):
+		set := p.tok.IsKeyword("set")
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		switch {
+		case p.tok.IsKeyword("default"):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			stmt.Subtype = AlterDomainDefault
+			if set {
+				if stmt.Def, err = p.parseAExpr(0); err != nil {
+					return nil, err
+				}
+			}
+		case p.tok.IsKeyword("not"):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			stmt.Subtype = AlterDomainDropNotNull
+			if set {
+				stmt.Subtype = AlterDomainSetNotNull
+			}
+			if err := p.expectKeyword("null"); err != nil {
+				return nil, err
+			}
+		case !set && p.tok.IsKeyword("constraint"):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			stmt.Subtype = AlterDomainDropConstraint
+			if stmt.MissingOk, err = p.parseOptIfExists(); err != nil {
+				return nil, err
+			}
+			if stmt.Name, err = p.parseColId(); err != nil {
+				return nil, err
+			}
+			if stmt.Behavior, err = p.parseOptDropBehavior(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, p.syntaxError()
+		}
+		return stmt, nil
+	case p.tok.IsKeyword("add"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		stmt.Subtype = AlterDomainAddConstraint
+		if stmt.Def, err = p.parseDomainConstraint(); err != nil {
+			return nil, err
+		}
+		return stmt, nil
+	case p.tok.IsKeyword("validate"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("constraint"); err != nil {
+			return nil, err
+		}
+		stmt.Subtype = AlterDomainValidateConstraint
+		if stmt.Name, err = p.parseColId(); err != nil {
+			return nil, err
+		}
+		return stmt, nil
+	case p.tok.IsKeyword("rename"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		rename := &RenameStmt{RenameType: ObjectDomain, Object: name}
+		if ok, err := p.acceptKeyword("constraint"); err != nil {
+			return nil, err
+		} else if ok {
+			rename.RenameType = ObjectDomconstraint
+			if rename.Subname, err = p.parseColId(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expectKeyword("to"); err != nil {
+			return nil, err
+		}
+		if rename.Newname, err = p.parseColId(); err != nil {
+			return nil, err
+		}
+		return rename, nil
+	case p.tok.IsKeyword("owner"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("to"); err != nil {
+			return nil, err
+		}
+		owner, err := p.parseRoleSpec()
+		if err != nil {
+			return nil, err
+		}
+		return &AlterOwnerStmt{ObjectType: ObjectDomain, Object: name, NewOwner: owner}, nil
+	}
+	return nil, p.syntaxError()
+}
+
+// parseDropAnyNameStmt は DROP TABLE|DOMAIN [IF EXISTS] any_name_list opt_drop_behavior を解析する
+// (DropStmt の object_type_any_name 相当)
+func (p *parser) parseDropAnyNameStmt() (Node, error) {
+	stmt := &DropStmt{RemoveType: ObjectDomain}
+	if p.tok.IsKeyword("table") {
+		stmt.RemoveType = ObjectTable
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if stmt.MissingOk, err = p.parseOptIfExists(); err != nil {
+		return nil, err
+	}
+	for {
+		name, err := p.parseAnyName()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Objects = append(stmt.Objects, name)
+		if !p.tok.IsChar(',') {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if stmt.Behavior, err = p.parseOptDropBehavior(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseCastTypes は CREATE CAST と DROP CAST の '(' Typename AS Typename ')' を解析する
+func (p *parser) parseCastTypes() (source, target *TypeName, err error) {
+	if err := p.expectChar('('); err != nil {
+		return nil, nil, err
+	}
+	if source, err = p.parseTypeName(); err != nil {
+		return nil, nil, err
+	}
+	if err := p.expectKeyword("as"); err != nil {
+		return nil, nil, err
+	}
+	if target, err = p.parseTypeName(); err != nil {
+		return nil, nil, err
+	}
+	return source, target, p.expectChar(')')
+}
+
+// parseCreateCastStmt は CREATE CAST '(' Typename AS Typename ')' に続く WITH FUNCTION
+// function_with_argtypes、WITHOUT FUNCTION、WITH INOUT のいずれかと cast_context を解析する
+// (CreateCastStmt 相当)
+func (p *parser) parseCreateCastStmt() (Node, error) {
+	if err := p.expectKeyword("cast"); err != nil {
+		return nil, err
+	}
+	source, target, err := p.parseCastTypes()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &CreateCastStmt{Sourcetype: source, Targettype: target}
+	switch {
+	case p.tok.IsKeyword("without"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("function"); err != nil {
+			return nil, err
+		}
+	case p.tok.IsKeyword("with"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.acceptKeyword("inout"); err != nil {
+			return nil, err
+		} else if ok {
+			stmt.Inout = true
+			break
+		}
+		if err := p.expectKeyword("function"); err != nil {
+			return nil, err
+		}
+		if stmt.Func, err = p.parseFunctionWithArgtypes(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.syntaxError()
+	}
+
+	// cast_context: AS IMPLICIT | AS ASSIGNMENT | 省略 (明示的な変換だけ)
+	stmt.Context = CoercionExplicit
+	if ok, err := p.acceptKeyword("as"); err != nil {
+		return nil, err
+	} else if ok {
+		switch {
+		case p.tok.IsKeyword("implicit"):
+			stmt.Context = CoercionImplicit
+		case p.tok.IsKeyword("assignment"):
+			stmt.Context = CoercionAssignment
+		default:
+			return nil, p.syntaxError()
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// parseFunctionWithArgtypes は func_name ['(' [Typename [, ...]] ')'] を解析する
+// (function_with_argtypes 相当)
+func (p *parser) parseFunctionWithArgtypes() (*ObjectWithArgs, error) {
+	name, err := p.parseAnyName()
+	if err != nil {
+		return nil, err
+	}
+	owa := &ObjectWithArgs{Objname: name}
+	if !p.tok.IsChar('(') {
+		owa.ArgsUnspecified = true
+		return owa, nil
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	for !p.tok.IsChar(')') {
+		if len(owa.Objargs) > 0 {
+			if err := p.expectChar(','); err != nil {
+				return nil, err
+			}
+		}
+		tn, err := p.parseTypeName()
+		if err != nil {
+			return nil, err
+		}
+		owa.Objargs = append(owa.Objargs, tn)
+	}
+	return owa, p.advance()
+}
+
+// parseDropCastStmt は DROP CAST [IF EXISTS] '(' Typename AS Typename ')' opt_drop_behavior を
+// 解析する (DropStmt の DROP CAST 相当)
+func (p *parser) parseDropCastStmt() (Node, error) {
+	if err := p.expectKeyword("cast"); err != nil {
+		return nil, err
+	}
+	stmt := &DropStmt{RemoveType: ObjectCast}
+	var err error
+	if stmt.MissingOk, err = p.parseOptIfExists(); err != nil {
+		return nil, err
+	}
+	source, target, err := p.parseCastTypes()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Objects = []Node{[]*TypeName{source, target}}
+	if stmt.Behavior, err = p.parseOptDropBehavior(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// isAllOp は現在のトークンが演算子 (all_Op) であるかを返す
+func (p *parser) isAllOp() bool {
+	switch p.tok.Kind {
+	case Op, LESS_EQUALS, GREATER_EQUALS, NOT_EQUALS:
+		return true
+	case CHAR:
+		// MathOp
+		return strings.Contains("+-*/%^<>=", p.tok.Str)
+	}
+	return false
+}
+
+// parseAllOp は演算子の名前 (all_Op) を解析する。!= は <> として扱う。
+func (p *parser) parseAllOp() (string, error) {
+	if !p.isAllOp() {
+		return "", p.syntaxError()
+	}
+	name := p.tok.Str
+	if name == "!=" {
+		name = "<>"
+	}
+	return name, p.advance()
+}
+
+// parseAnyOperator は [ColId '.' ...] all_Op を解析する (any_operator 相当)
+func (p *parser) parseAnyOperator() ([]string, error) {
+	var names []string
+	for p.tok.Kind == IDENT {
+		next, err := p.lookahead()
+		if err != nil {
+			return nil, err
+		}
+		if !next.IsChar('.') {
+			return nil, p.syntaxError()
+		}
+		name, err := p.parseColId()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	op, err := p.parseAllOp()
+	if err != nil {
+		return nil, err
+	}
+	return append(names, op), nil
+}
+
+// parseOperArgtypes は '(' {Typename | NONE} ',' {Typename | NONE} ')' を解析する (oper_argtypes 相当)。
+// NONE と書いた側は nil にする。
+func (p *parser) parseOperArgtypes() ([]*TypeName, error) {
+	if err := p.expectChar('('); err != nil {
+		return nil, err
+	}
+	args := make([]*TypeName, 2)
+	for i := range args {
+		if i > 0 {
+			if p.tok.IsChar(')') {
+				return nil, &SyntaxError{Message: "missing argument", Position: p.tok.Loc,
+					Hint: "Use NONE to denote the missing argument of a unary operator."}
+			}
+			if err := p.expectChar(','); err != nil {
+				return nil, err
+			}
+		}
+		if p.tok.IsKeyword("none") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		tn, err := p.parseTypeName()
+		if err != nil {
+			return nil, err
+		}
+		args[i] = tn
+	}
+	return args, p.expectChar(')')
+}
+
+// parseOperatorWithArgtypes は any_operator oper_argtypes を解析する (operator_with_argtypes 相当)
+func (p *parser) parseOperatorWithArgtypes() (*ObjectWithArgs, error) {
+	name, err := p.parseAnyOperator()
+	if err != nil {
+		return nil, err
+	}
+	args, err := p.parseOperArgtypes()
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectWithArgs{Objname: name, Objargs: args}, nil
+}
+
+// parseDefinition は '(' ColLabel ['=' def_arg] [, ...] ')' を解析する (definition 相当)。
+// alter が true の場合は ALTER OPERATOR の operator_def_list として、NONE の値を nil にする。
+func (p *parser) parseDefinition(alter bool) ([]*DefElem, error) {
+	if err := p.expectChar('('); err != nil {
+		return nil, err
+	}
+	var defs []*DefElem
+	for {
+		if p.tok.Kind != IDENT {
+			return nil, p.syntaxError()
+		}
+		def := &DefElem{Defname: p.tok.Str, Location: p.tok.Loc}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.IsChar('=') {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if alter && p.tok.IsKeyword("none") {
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			} else {
+				arg, err := p.parseDefArg()
+				if err != nil {
+					return nil, err
+				}
+				def.Arg = arg
+			}
+		}
+		defs = append(defs, def)
+		if !p.tok.IsChar(',') {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return defs, p.expectChar(')')
+}
+
+// parseDefArg はオプションの値を解析する (def_arg 相当)。値は型名か関数名 (TypeName)、
+// 予約語と NONE と文字列 (String)、演算子名 ([]string)、数値 (Integer か Float) のいずれか。
+func (p *parser) parseDefArg() (Node, error) {
+	t := p.tok
+	switch {
+	case t.IsKeyword("operator"):
+		// OPERATOR '(' any_operator ')'
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expectChar('('); err != nil {
+			return nil, err
+		}
+		name, err := p.parseAnyOperator()
+		if err != nil {
+			return nil, err
+		}
+		return name, p.expectChar(')')
+	case t.Kind == IDENT && !t.Quoted && (reservedKeywords[t.Str] || t.Str == "none"):
+		return &String{Sval: t.Str}, p.advance()
+	case t.Kind == IDENT:
+		next, err := p.lookahead()
+		if err != nil {
+			return nil, err
+		}
+		if next.IsChar('.') {
+			names, err := p.parseAnyName()
+			if err != nil {
+				return nil, err
+			}
+			return &TypeName{Names: names, Location: t.Loc}, nil
+		}
+		return p.parseTypeName()
+	case t.Kind == SCONST:
+		return &String{Sval: t.Str}, p.advance()
+	case t.Kind == ICONST, t.Kind == FCONST:
+		c, err := p.parseVarValue()
+		if err != nil {
+			return nil, err
+		}
+		return c.Val, nil
+	case t.IsChar('+'), t.IsChar('-'):
+		next, err := p.lookahead()
+		if err != nil {
+			return nil, err
+		}
+		if next.Kind == ICONST || next.Kind == FCONST {
+			c, err := p.parseVarValue()
+			if err != nil {
+				return nil, err
+			}
+			return c.Val, nil
+		}
+	}
+	if p.isAllOp() {
+		name, err := p.parseAllOp()
+		if err != nil {
+			return nil, err
+		}
+		return []string{name}, nil
+	}
+	return nil, p.syntaxError()
+}
+
+// parseUsingAmName は USING name を解析してアクセスメソッドの名前を返す
+func (p *parser) parseUsingAmName() (string, error) {
+	if err := p.expectKeyword("using"); err != nil {
+		return "", err
+	}
+	return p.parseColId()
+}
+
+// parseIconst は整数の定数を解析する (Iconst 相当)
+func (p *parser) parseIconst() (int, error) {
+	if p.tok.Kind != ICONST {
+		return 0, p.syntaxError()
+	}
+	n, err := strconv.Atoi(p.tok.Str)
+	if err != nil {
+		return 0, p.syntaxError()
+	}
+	return n, p.advance()
+}
+
+// parseTypeList は '(' Typename [, ...] ')' を解析する
+func (p *parser) parseTypeList() ([]*TypeName, error) {
+	if err := p.expectChar('('); err != nil {
+		return nil, err
+	}
+	var types []*TypeName
+	for {
+		tn, err := p.parseTypeName()
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, tn)
+		if !p.tok.IsChar(',') {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return types, p.expectChar(')')
+}
+
+// parseCreateOperatorStmt は CREATE OPERATOR で始まる文を解析する。CREATE OPERATOR any_operator
+// definition は DefineStmt にする。
+func (p *parser) parseCreateOperatorStmt() (Node, error) {
+	if err := p.expectKeyword("operator"); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.tok.IsKeyword("class"):
+		return p.parseCreateOpClassStmt()
+	case p.tok.IsKeyword("family"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.parseAnyName()
+		if err != nil {
+			return nil, err
+		}
+		amname, err := p.parseUsingAmName()
+		if err != nil {
+			return nil, err
+		}
+		return &CreateOpFamilyStmt{Opfamilyname: name, Amname: amname}, nil
+	}
+	name, err := p.parseAnyOperator()
+	if err != nil {
+		return nil, err
+	}
+	defs, err := p.parseDefinition(false)
+	if err != nil {
+		return nil, err
+	}
+	return &DefineStmt{Kind: ObjectOperator, Defnames: name, Definition: defs}, nil
+}
+
+// parseCreateOpClassStmt は CREATE OPERATOR CLASS any_name [DEFAULT] FOR TYPE Typename
+// USING name [FAMILY any_name] AS opclass_item [, ...] を解析する (CreateOpClassStmt 相当)
+func (p *parser) parseCreateOpClassStmt() (Node, error) {
+	if err := p.expectKeyword("class"); err != nil {
+		return nil, err
+	}
+	name, err := p.parseAnyName()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &CreateOpClassStmt{Opclassname: name}
+	if stmt.IsDefault, err = p.acceptKeyword("default"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("for"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("type"); err != nil {
+		return nil, err
+	}
+	if stmt.Datatype, err = p.parseTypeName(); err != nil {
+		return nil, err
+	}
+	if stmt.Amname, err = p.parseUsingAmName(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.acceptKeyword("family"); err != nil {
+		return nil, err
+	} else if ok {
+		if stmt.Opfamilyname, err = p.parseAnyName(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectKeyword("as"); err != nil {
+		return nil, err
+	}
+	if stmt.Items, err = p.parseOpclassItemList(false); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseOpclassItemList は opclass_item [, ...] を解析する (opclass_item_list 相当)。drop が true の
+// 場合は ALTER OPERATOR FAMILY ... DROP の opclass_drop [, ...] として解析する。
+func (p *parser) parseOpclassItemList(drop bool) ([]*CreateOpClassItem, error) {
+	var items []*CreateOpClassItem
+	for {
+		item, err := p.parseOpclassItem(drop)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if !p.tok.IsChar(',') {
+			return items, nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseOpclassItem は次のいずれかを解析する (opclass_item と opclass_drop 相当)。
+//
+//	OPERATOR Iconst any_operator [oper_argtypes] [FOR SEARCH | FOR ORDER BY any_name]
+//	FUNCTION Iconst ['(' type_list ')'] function_with_argtypes
+//	STORAGE Typename
+//	OPERATOR Iconst '(' type_list ')'   (drop)
+//	FUNCTION Iconst '(' type_list ')'   (drop)
+func (p *parser) parseOpclassItem(drop bool) (*CreateOpClassItem, error) {
+	item := &CreateOpClassItem{Location: p.tok.Loc}
+	var err error
+	switch {
+	case p.tok.IsKeyword("operator"), p.tok.IsKeyword("function"):
+		item.Itemtype = OpclassItemOperator
+		if p.tok.IsKeyword("function") {
+			item.Itemtype = OpclassItemFunction
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if item.Number, err = p.parseIconst(); err != nil {
+			return nil, err
+		}
+		if drop {
+			if item.ClassArgs, err = p.parseTypeList(); err != nil {
+				return nil, err
+			}
+			return item, nil
+		}
+		if item.Itemtype == OpclassItemFunction {
+			if p.tok.IsChar('(') {
+				if item.ClassArgs, err = p.parseTypeList(); err != nil {
+					return nil, err
+				}
+			}
+			if item.Name, err = p.parseFunctionWithArgtypes(); err != nil {
+				return nil, err
+			}
+			return item, nil
+		}
+		name, err := p.parseAnyOperator()
+		if err != nil {
+			return nil, err
+		}
+		item.Name = &ObjectWithArgs{Objname: name, ArgsUnspecified: true}
+		if p.tok.IsChar('(') {
+			if item.Name.Objargs, err = p.parseOperArgtypes(); err != nil {
+				return nil, err
+			}
+			item.Name.ArgsUnspecified = false
+		}
+		// opclass_purpose
+		if ok, err := p.acceptKeyword("for"); err != nil {
+			return nil, err
+		} else if ok {
+			if ok, err := p.acceptKeyword("search"); err != nil || ok {
+				return item, err
+			}
+			if err := p.expectKeyword("order"); err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("by"); err != nil {
+				return nil, err
+			}
+			if item.OrderFamily, err = p.parseAnyName(); err != nil {
+				return nil, err
+			}
+		}
+		return item, nil
+	case !drop && p.tok.IsKeyword("storage"):
+		item.Itemtype = OpclassItemStorageType
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if item.Storedtype, err = p.parseTypeName(); err != nil {
+			return nil, err
+		}
+		return item, nil
+	}
+	return nil, p.syntaxError()
+}
+
+// parseOpclassName は any_name USING name を解析し、DropStmt と RenameStmt の形
+// (アクセスメソッドの名前に続けた名前) で返す
+func (p *parser) parseOpclassName() ([]string, error) {
+	name, err := p.parseAnyName()
+	if err != nil {
+		return nil, err
+	}
+	amname, err := p.parseUsingAmName()
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{amname}, name...), nil
+}
+
+// parseAlterOperatorStmt は ALTER OPERATOR で始まる文を解析する。
+//
+//	ALTER OPERATOR operator_with_argtypes SET '(' operator_def_list ')'
+//	ALTER OPERATOR operator_with_argtypes OWNER TO RoleSpec
+//	ALTER OPERATOR {CLASS | FAMILY} any_name USING name {RENAME TO name | OWNER TO RoleSpec}
+//	ALTER OPERATOR FAMILY any_name USING name {ADD opclass_item_list | DROP opclass_drop_list}
+func (p *parser) parseAlterOperatorStmt() (Node, error) {
+	if err := p.expectKeyword("operator"); err != nil {
+		return nil, err
+	}
+	if p.tok.IsKeyword("class") || p.tok.IsKeyword("family") {
+		objtype := ObjectOpclass
+		if p.tok.IsKeyword("family") {
+			objtype = ObjectOpfamily
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		object, err := p.parseOpclassName()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case objtype == ObjectOpfamily && (p.tok.IsKeyword("add") || p.tok.IsKeyword("drop")):
+			stmt := &AlterOpFamilyStmt{Opfamilyname: object[1:], Amname: object[0], IsDrop: p.tok.IsKeyword("drop")}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if stmt.Items, err = p.parseOpclassItemList(stmt.IsDrop); err != nil {
+				return nil, err
+			}
+			return stmt, nil
+		case p.tok.IsKeyword("rename"):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("to"); err != nil {
+				return nil, err
+			}
+			newname, err := p.parseColId()
+			if err != nil {
+				return nil, err
+			}
+			return &RenameStmt{RenameType: objtype, Object: object, Newname: newname}, nil
+		case p.tok.IsKeyword("owner"):
+			owner, err := p.parseOwnerTo()
+			if err != nil {
+				return nil, err
+			}
+			return &AlterOwnerStmt{ObjectType: objtype, Object: object, NewOwner: owner}, nil
+		}
+		return nil, p.syntaxError()
+	}
+
+	oper, err := p.parseOperatorWithArgtypes()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.tok.IsKeyword("set"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		opts, err := p.parseDefinition(true)
+		if err != nil {
+			return nil, err
+		}
+		return &AlterOperatorStmt{Opername: oper, Options: opts}, nil
+	case p.tok.IsKeyword("owner"):
+		owner, err := p.parseOwnerTo()
+		if err != nil {
+			return nil, err
+		}
+		return &AlterOwnerStmt{ObjectType: ObjectOperator, Object: oper, NewOwner: owner}, nil
+	}
+	return nil, p.syntaxError()
+}
+
+// parseOwnerTo は OWNER TO RoleSpec を解析する
+func (p *parser) parseOwnerTo() (*RoleSpec, error) {
+	if err := p.expectKeyword("owner"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("to"); err != nil {
+		return nil, err
+	}
+	return p.parseRoleSpec()
+}
+
+// parseDropOperatorStmt は DROP OPERATOR [IF EXISTS] operator_with_argtypes [, ...] opt_drop_behavior と、
+// DROP OPERATOR {CLASS | FAMILY} [IF EXISTS] any_name USING name opt_drop_behavior を解析する
+// (DropStmt の DROP OPERATOR、DROP OPERATOR CLASS、DROP OPERATOR FAMILY 相当)
+func (p *parser) parseDropOperatorStmt() (Node, error) {
+	if err := p.expectKeyword("operator"); err != nil {
+		return nil, err
+	}
+	stmt := &DropStmt{RemoveType: ObjectOperator}
+	if p.tok.IsKeyword("class") || p.tok.IsKeyword("family") {
+		stmt.RemoveType = ObjectOpclass
+		if p.tok.IsKeyword("family") {
+			stmt.RemoveType = ObjectOpfamily
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if stmt.MissingOk, err = p.parseOptIfExists(); err != nil {
+		return nil, err
+	}
+	if stmt.RemoveType == ObjectOperator {
+		for {
+			oper, err := p.parseOperatorWithArgtypes()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Objects = append(stmt.Objects, oper)
+			if !p.tok.IsChar(',') {
+				break
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		object, err := p.parseOpclassName()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Objects = []Node{object}
+	}
+	if stmt.Behavior, err = p.parseOptDropBehavior(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseAlterStmt は ALTER で始まる文を解析する。
+func (p *parser) parseAlterStmt() (Node, error) {
+	if err := p.expectKeyword("alter"); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.tok.IsKeyword("role"), p.tok.IsKeyword("user"):
+		return p.parseAlterRoleStmt()
+	case p.tok.IsKeyword("system"):
+		return p.parseAlterSystemStmt()
+	case p.tok.IsKeyword("database"):
+		return p.parseAlterDatabaseSetStmt()
+	case p.tok.IsKeyword("table"):
+		return p.parseAlterTableStmt()
+	case p.tok.IsKeyword("domain"):
+		return p.parseAlterDomainStmt()
+	case p.tok.IsKeyword("operator"):
+		return p.parseAlterOperatorStmt()
+	case p.tok.IsKeyword("default"):
+		return p.parseAlterDefaultPrivilegesStmt()
+	case p.tok.IsKeyword("function"), p.tok.IsKeyword("procedure"), p.tok.IsKeyword("routine"):
+		return p.parseAlterFunctionStmt()
+	}
+	return nil, p.syntaxError()
+}
+
+// parseAlterTableStmt は ALTER TABLE [IF EXISTS] relation_expr alter_table_cmds を解析する
+// (AlterTableStmt 相当)。指定できる動作は INHERIT qualified_name と NO INHERIT qualified_name。
+func (p *parser) parseAlterTableStmt() (Node, error) {
+	if err := p.expectKeyword("table"); err != nil {
+		return nil, err
+	}
+	stmt := &AlterTableStmt{}
+	var err error
+	if stmt.MissingOk, err = p.parseOptIfExists(); err != nil {
+		return nil, err
+	}
+	if stmt.Relation, err = p.parseRelationExpr(); err != nil {
+		return nil, err
+	}
+	for {
+		cmd := &AlterTableCmd{Subtype: ATAddInherit}
+		if ok, err := p.acceptKeyword("no"); err != nil {
+			return nil, err
+		} else if ok {
+			cmd.Subtype = ATDropInherit
+		}
+		if err := p.expectKeyword("inherit"); err != nil {
+			return nil, err
+		}
+		if cmd.Parent, err = p.parseQualifiedName(); err != nil {
+			return nil, err
+		}
+		stmt.Cmds = append(stmt.Cmds, cmd)
+		if !p.tok.IsChar(',') {
+			return stmt, nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseAlterFunctionStmt は ALTER {FUNCTION | PROCEDURE | ROUTINE} function_with_argtypes
+// alterfunc_opt_list [RESTRICT] を解析する (AlterFunctionStmt 相当)。指定できる動作は次のとおり。
+//
+//	[EXTERNAL] SECURITY {DEFINER | INVOKER}
+//	SET var_name {TO | =} {var_list | DEFAULT}
+//	SET var_name FROM CURRENT
+//	RESET {var_name | ALL}
+func (p *parser) parseAlterFunctionStmt() (Node, error) {
+	stmt := &AlterFunctionStmt{Objtype: ObjectFunction}
+	switch {
+	case p.tok.IsKeyword("procedure"):
+		stmt.Objtype = ObjectProcedure
+	case p.tok.IsKeyword("routine"):
+		stmt.Objtype = ObjectRoutine
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	owa, err := p.parseFunctionWithArgtypes()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Func = owa
+	for {
+		loc := p.tok.Loc
+		switch {
+		case p.tok.IsKeyword("external"), p.tok.IsKeyword("security"):
+			if _, err := p.acceptKeyword("external"); err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("security"); err != nil {
+				return nil, err
+			}
+			var definer bool
+			switch {
+			case p.tok.IsKeyword("definer"):
+				definer = true
+			case !p.tok.IsKeyword("invoker"):
+				return nil, p.syntaxError()
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			stmt.Actions = append(stmt.Actions, &DefElem{Defname: "security", Arg: &Boolean{Boolval: definer}, Location: loc})
+		case p.tok.IsKeyword("set"):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.parseVarName()
+			if err != nil {
+				return nil, err
+			}
+			setstmt := &VariableSetStmt{Kind: VarSetValue, Name: name}
+			if ok, err := p.acceptKeyword("from"); err != nil {
+				return nil, err
+			} else if ok {
+				if err := p.expectKeyword("current"); err != nil {
+					return nil, err
+				}
+				setstmt.Kind = VarSetCurrent
+			} else if err := p.parseGenericSetValue(setstmt); err != nil {
+				return nil, err
+			}
+			stmt.Actions = append(stmt.Actions, &DefElem{Defname: "set", Arg: setstmt, Location: loc})
+		case p.tok.IsKeyword("reset"):
+			setstmt, err := p.parseVariableResetStmt()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Actions = append(stmt.Actions, &DefElem{Defname: "set", Arg: setstmt, Location: loc})
+		default:
+			if len(stmt.Actions) == 0 {
+				return nil, p.syntaxError()
+			}
+			if _, err := p.acceptKeyword("restrict"); err != nil {
+				return nil, err
+			}
+			return stmt, nil
+		}
+	}
+}
+
+// parseAlterDefaultPrivilegesStmt は ALTER DEFAULT PRIVILEGES DefACLOptionList DefACLAction を
+// 解析する (AlterDefaultPrivilegesStmt 相当)
+func (p *parser) parseAlterDefaultPrivilegesStmt() (Node, error) {
+	if err := p.expectKeyword("default"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("privileges"); err != nil {
+		return nil, err
+	}
+	stmt := &AlterDefaultPrivilegesStmt{}
+	for {
+		loc := p.tok.Loc
+		switch {
+		case p.tok.IsKeyword("in"):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("schema"); err != nil {
+				return nil, err
+			}
+			names, err := p.parseNameList()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Options = append(stmt.Options, &DefElem{Defname: "schemas", Arg: names, Location: loc})
+			continue
+		case p.tok.IsKeyword("for"):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if !p.tok.IsKeyword("role") && !p.tok.IsKeyword("user") {
+				return nil, p.syntaxError()
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			opt, err := p.parseRoleListOption("roles", loc)
+			if err != nil {
+				return nil, err
+			}
+			stmt.Options = append(stmt.Options, opt)
+			continue
+		}
+		break
+	}
+	action, err := p.parseDefACLAction()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Action = action
+	return stmt, nil
+}
+
+// parseDefACLAction は ALTER DEFAULT PRIVILEGES の GRANT と REVOKE を解析する (DefACLAction 相当)
+//
+//	GRANT privileges ON defacl_privilege_target TO grantee_list [WITH GRANT OPTION]
+//	REVOKE [GRANT OPTION FOR] privileges ON defacl_privilege_target FROM grantee_list [CASCADE | RESTRICT]
+func (p *parser) parseDefACLAction() (*GrantStmt, error) {
+	stmt := &GrantStmt{}
+	switch {
+	case p.tok.IsKeyword("grant"):
+		stmt.IsGrant = true
+	case !p.tok.IsKeyword("revoke"):
+		return nil, p.syntaxError()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if !stmt.IsGrant && p.tok.IsKeyword("grant") {
+		for _, kw := range []string{"grant", "option", "for"} {
+			if err := p.expectKeyword(kw); err != nil {
+				return nil, err
+			}
+		}
+		stmt.GrantOption = true
+	}
+	privs, err := p.parsePrivileges()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Privileges = privs
+	if err := p.expectKeyword("on"); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.tok.IsKeyword("tables"):
+		stmt.Objtype = GrantTables
+	case p.tok.IsKeyword("sequences"):
+		stmt.Objtype = GrantSequences
+	case p.tok.IsKeyword("functions"):
+		stmt.Objtype = GrantFunctions
+	case p.tok.IsKeyword("routines"):
+		stmt.Objtype = GrantRoutines
+	case p.tok.IsKeyword("types"):
+		stmt.Objtype = GrantTypes
+	case p.tok.IsKeyword("schemas"):
+		stmt.Objtype = GrantSchemas
+	default:
+		return nil, p.syntaxError()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	direction := "from"
+	if stmt.IsGrant {
+		direction = "to"
+	}
+	if err := p.expectKeyword(direction); err != nil {
+		return nil, err
+	}
+	for {
+		grantee, err := p.parseGrantee()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Grantees = append(stmt.Grantees, grantee)
+		if !p.tok.IsChar(',') {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if !stmt.IsGrant {
+		stmt.Behavior, err = p.parseOptDropBehavior()
+		return stmt, err
+	}
+	if p.tok.IsKeyword("with") {
+		for _, kw := range []string{"with", "grant", "option"} {
+			if err := p.expectKeyword(kw); err != nil {
+				return nil, err
+			}
+		}
+		stmt.GrantOption = true
+	}
+	return stmt, nil
+}
+
+// parsePrivileges は権限の並びを解析する (privileges 相当)。ALL [PRIVILEGES] なら nil を返し、
+// ALL に列の並びを付けた場合は名前のない権限1つを返す。
+func (p *parser) parsePrivileges() ([]*AccessPriv, error) {
+	if p.tok.IsKeyword("all") {
+		loc := p.tok.Loc
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if _, err := p.acceptKeyword("privileges"); err != nil {
+			return nil, err
+		}
+		if !p.tok.IsChar('(') {
+			return nil, nil
+		}
+		cols, err := p.parseColumnList()
+		if err != nil {
+			return nil, err
+		}
+		return []*AccessPriv{{Cols: cols, Location: loc}}, nil
+	}
+	var privs []*AccessPriv
+	for {
+		// SELECT と REFERENCES と CREATE は予約語だが権限の名前に使える (privilege 相当)
+		t := p.tok
+		if t.Kind != IDENT || !t.Quoted && reservedKeywords[t.Str] && t.Str != "select" && t.Str != "references" && t.Str != "create" {
+			return nil, p.syntaxError()
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		priv := &AccessPriv{PrivName: t.Str, Location: t.Loc}
+		if !t.Quoted && t.Str == "alter" {
+			if err := p.expectKeyword("system"); err != nil {
+				return nil, err
+			}
+			priv.PrivName = "alter system"
+		}
+		if p.tok.IsChar('(') {
+			cols, err := p.parseColumnList()
+			if err != nil {
+				return nil, err
+			}
+			priv.Cols = cols
+		}
+		privs = append(privs, priv)
+		if !p.tok.IsChar(',') {
+			return privs, nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseGrantee は権限を与えるロールを解析する (grantee 相当)。GROUP は読み飛ばし、PUBLIC は
+// RoleSpecPublic にする。
+func (p *parser) parseGrantee() (*RoleSpec, error) {
+	if _, err := p.acceptKeyword("group"); err != nil {
+		return nil, err
+	}
+	if p.tok.IsKeyword("public") {
+		spec := &RoleSpec{RoleType: RoleSpecPublic, Location: p.tok.Loc}
+		return spec, p.advance()
+	}
+	return p.parseRoleSpec()
+}
+
+// parseColumnList は '(' ColId [, ...] ')' を解析する (columnList 相当)
+func (p *parser) parseColumnList() ([]string, error) {
+	if err := p.expectChar('('); err != nil {
+		return nil, err
+	}
+	names, err := p.parseNameList()
+	if err != nil {
+		return nil, err
+	}
+	return names, p.expectChar(')')
+}
+
+// parseNameList は ColId をコンマでつないだものを解析する (name_list 相当)
+func (p *parser) parseNameList() ([]string, error) {
+	var names []string
+	for {
+		name, err := p.parseColId()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.tok.IsChar(',') {
+			return names, nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseAlterSystemStmt は ALTER SYSTEM SetResetClause を解析する (AlterSystemStmt 相当)
+func (p *parser) parseAlterSystemStmt() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	stmt, err := p.parseSetResetClause()
+	if err != nil {
+		return nil, err
+	}
+	return &AlterSystemStmt{Setstmt: stmt}, nil
+}
+
+// parseAlterDatabaseSetStmt は ALTER DATABASE name SetResetClause を解析する (AlterDatabaseSetStmt 相当)
+func (p *parser) parseAlterDatabaseSetStmt() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	dbname, err := p.parseColId()
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := p.parseSetResetClause()
+	if err != nil {
+		return nil, err
+	}
+	return &AlterDatabaseSetStmt{Dbname: dbname, Setstmt: stmt}, nil
+}
+
+// parseSetResetClause は SET generic_set | RESET {var_name | ALL} を解析する (SetResetClause 相当)
+func (p *parser) parseSetResetClause() (*VariableSetStmt, error) {
+	switch {
+	case p.tok.IsKeyword("set"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		stmt := &VariableSetStmt{Kind: VarSetValue}
+		if err := p.parseGenericSet(stmt); err != nil {
+			return nil, err
+		}
+		return stmt, nil
+	case p.tok.IsKeyword("reset"):
+		stmt, err := p.parseVariableResetStmt()
+		if err != nil {
+			return nil, err
+		}
+		return stmt.(*VariableSetStmt), nil
+	}
+	return nil, p.syntaxError()
+}
+
+// parseAlterRoleStmt は ALTER ROLE|USER RoleSpec [WITH] AlterOptRoleList (AlterRoleStmt 相当) と
+// ALTER ROLE|USER {RoleSpec | ALL} [IN DATABASE name] SetResetClause (AlterRoleSetStmt 相当) を解析する
+func (p *parser) parseAlterRoleStmt() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var role *RoleSpec
+	if ok, err := p.acceptKeyword("all"); err != nil {
+		return nil, err
+	} else if !ok {
+		if role, err = p.parseRoleSpec(); err != nil {
+			return nil, err
+		}
+	}
+	if set, err := p.parseAlterRoleSet(role); err != nil {
+		return nil, err
+	} else if set != nil {
+		return set, nil
+	}
+	if role == nil {
+		return nil, p.syntaxError()
+	}
+	stmt := &AlterRoleStmt{Role: role}
+	if _, err := p.acceptKeyword("with"); err != nil {
+		return nil, err
+	}
+	for p.tok.Kind == IDENT && !p.tok.Quoted {
+		opt, err := p.parseOptRoleElem(false)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Options = append(stmt.Options, opt)
+	}
+	return stmt, nil
+}
+
+// parseAlterRoleSet は ALTER ROLE の [IN DATABASE name] SetResetClause を解析する。
+// SET でも RESET でもなければ nil を返す。
+func (p *parser) parseAlterRoleSet(role *RoleSpec) (*AlterRoleSetStmt, error) {
+	stmt := &AlterRoleSetStmt{Role: role}
+	if ok, err := p.acceptKeyword("in"); err != nil {
+		return nil, err
+	} else if ok {
+		if err := p.expectKeyword("database"); err != nil {
+			return nil, err
+		}
+		if stmt.Database, err = p.parseColId(); err != nil {
+			return nil, err
+		}
+	} else if !p.tok.IsKeyword("set") && !p.tok.IsKeyword("reset") {
+		return nil, nil
+	}
+	set, err := p.parseSetResetClause()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Setstmt = set
+	return stmt, nil
+}
+
+// roleOptionKeywords は IDENT として書くロールのオプションと、その DefElem の名前と値
+// (AlterOptRoleElem の IDENT の規則相当)
+var roleOptionKeywords = map[string]struct {
+	defname string
+	value   bool
+}{
+	"superuser":     {"superuser", true},
+	"nosuperuser":   {"superuser", false},
+	"createrole":    {"createrole", true},
+	"nocreaterole":  {"createrole", false},
+	"replication":   {"isreplication", true},
+	"noreplication": {"isreplication", false},
+	"createdb":      {"createdb", true},
+	"nocreatedb":    {"createdb", false},
+	"login":         {"canlogin", true},
+	"nologin":       {"canlogin", false},
+	"bypassrls":     {"bypassrls", true},
+	"nobypassrls":   {"bypassrls", false},
+	"noinherit":     {"inherit", false},
+}
+
+// parseOptRoleElem はロールのオプションを1つ解析する (AlterOptRoleElem 相当)。
+// create が true の場合は CREATE ROLE だけに書けるオプションも受け付ける (CreateOptRoleElem 相当)。
+func (p *parser) parseOptRoleElem(create bool) (*DefElem, error) {
+	loc := p.tok.Loc
+	kw := p.tok.Str
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if create {
+		switch kw {
+		case "sysid":
+			if p.tok.Kind != ICONST {
+				return nil, p.syntaxError()
+			}
+			n, _ := strconv.Atoi(p.tok.Str)
+			return &DefElem{Defname: "sysid", Arg: &Integer{Ival: int32(n)}, Location: loc}, p.advance()
+		case "admin":
+			return p.parseRoleListOption("adminmembers", loc)
+		case "role", "user":
+			return p.parseRoleListOption("rolemembers", loc)
+		case "in":
+			if !p.tok.IsKeyword("role") && !p.tok.IsKeyword("group") {
+				return nil, p.syntaxError()
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			return p.parseRoleListOption("addroleto", loc)
+		}
+	}
+	switch kw {
+	case "connection":
+		if err := p.expectKeyword("limit"); err != nil {
+			return nil, err
+		}
+		neg := false
+		if p.tok.IsChar('-') || p.tok.IsChar('+') {
+			neg = p.tok.IsChar('-')
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if p.tok.Kind != ICONST {
+			return nil, p.syntaxError()
+		}
+		n, _ := strconv.Atoi(p.tok.Str)
+		if neg {
+			n = -n
+		}
+		return &DefElem{Defname: "connectionlimit", Arg: &Integer{Ival: int32(n)}, Location: loc}, p.advance()
+	case "password":
+		if ok, err := p.acceptKeyword("null"); err != nil {
+			return nil, err
+		} else if ok {
+			return &DefElem{Defname: "password", Location: loc}, nil
+		}
+		return p.parseSconstOption("password", loc)
+	case "encrypted":
+		if err := p.expectKeyword("password"); err != nil {
+			return nil, err
+		}
+		return p.parseSconstOption("password", loc)
+	case "unencrypted":
+		return nil, &SyntaxError{Message: "UNENCRYPTED PASSWORD is no longer supported", Position: loc, Code: errcodes.FeatureNotSupported,
+			Hint: "Remove UNENCRYPTED to store the password in encrypted form instead."}
+	case "inherit":
+		return &DefElem{Defname: "inherit", Arg: &Boolean{Boolval: true}, Location: loc}, nil
+	case "valid":
+		if err := p.expectKeyword("until"); err != nil {
+			return nil, err
+		}
+		return p.parseSconstOption("validUntil", loc)
+	}
+	if o, ok := roleOptionKeywords[kw]; ok {
+		return &DefElem{Defname: o.defname, Arg: &Boolean{Boolval: o.value}, Location: loc}, nil
+	}
+	return nil, &SyntaxError{Message: fmt.Sprintf("unrecognized role option \"%s\"", kw), Position: loc}
+}
+
+// parseRoleListOption はロールの並びを値とするオプションを解析する。Arg は []*RoleSpec になる。
+func (p *parser) parseRoleListOption(defname string, loc int) (*DefElem, error) {
+	roles, err := p.parseRoleList()
+	if err != nil {
+		return nil, err
+	}
+	return &DefElem{Defname: defname, Arg: roles, Location: loc}, nil
+}
+
+// parseRoleList はロールの指定をコンマでつないだものを解析する (role_list 相当)
+func (p *parser) parseRoleList() ([]*RoleSpec, error) {
+	var roles []*RoleSpec
+	for {
+		spec, err := p.parseRoleSpec()
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, spec)
+		if !p.tok.IsChar(',') {
+			return roles, nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseRoleId は新しいロールの名前を解析する (RoleId 相当)。CURRENT_USER などは書けない。
+func (p *parser) parseRoleId() (string, error) {
+	t := p.tok
+	spec, err := p.parseRoleSpec()
+	if err != nil {
+		return "", err
+	}
+	if spec.RoleType != RoleSpecCString {
+		return "", &SyntaxError{Message: fmt.Sprintf("%s cannot be used as a role name here", strings.ToUpper(t.Str)),
+			Position: t.Loc, Code: errcodes.ReservedName}
+	}
+	return spec.Rolename, nil
+}
+
+// parseSconstOption は文字列リテラルを値とするオプションを解析する
+func (p *parser) parseSconstOption(defname string, loc int) (*DefElem, error) {
+	if p.tok.Kind != SCONST {
+		return nil, p.syntaxError()
+	}
+	opt := &DefElem{Defname: defname, Arg: &String{Sval: p.tok.Str}, Location: loc}
+	return opt, p.advance()
+}
+
+// parseRoleSpec はロールの指定を解析する (RoleSpec 相当)
+func (p *parser) parseRoleSpec() (*RoleSpec, error) {
+	t := p.tok
+	if t.Kind != IDENT {
+		return nil, p.syntaxError()
+	}
+	spec := &RoleSpec{Location: t.Loc}
+	switch {
+	case t.IsKeyword("current_role"):
+		spec.RoleType = RoleSpecCurrentRole
+	case t.IsKeyword("current_user"):
+		spec.RoleType = RoleSpecCurrentUser
+	case t.IsKeyword("session_user"):
+		spec.RoleType = RoleSpecSessionUser
+	case t.IsKeyword("public"), t.IsKeyword("none"):
+		return nil, &SyntaxError{Message: fmt.Sprintf("role name \"%s\" is reserved", t.Str), Position: t.Loc, Code: errcodes.ReservedName}
+	case t.Quoted || !reservedKeywords[t.Str]:
+		spec.RoleType = RoleSpecCString
+		spec.Rolename = t.Str
+	default:
+		return nil, p.syntaxError()
+	}
+	return spec, p.advance()
+}
+
+// parseVariableShowStmt は SHOW var_name と SHOW ALL を解析する (VariableShowStmt 相当)。
+// SHOW ALL は名前を "all" とする。
+func (p *parser) parseVariableShowStmt() (Node, error) {
+	if err := p.expectKeyword("show"); err != nil {
+		return nil, err
+	}
+	if ok, err := p.acceptKeyword("all"); err != nil {
+		return nil, err
+	} else if ok {
+		return &VariableShowStmt{Name: "all"}, nil
+	}
+	// 複数語の名前は設定パラメータの名前に置き換える
+	multiword := []struct {
+		words []string
+		name  string
+	}{
+		{[]string{"time", "zone"}, "timezone"},
+		{[]string{"transaction", "isolation", "level"}, "transaction_isolation"},
+		{[]string{"session", "authorization"}, "session_authorization"},
+	}
+	for _, m := range multiword {
+		if !p.tok.IsKeyword(m.words[0]) {
+			continue
+		}
+		next, err := p.lookahead()
+		if err != nil {
+			return nil, err
+		}
+		if !next.IsKeyword(m.words[1]) {
+			continue
+		}
+		for _, w := range m.words {
+			if err := p.expectKeyword(w); err != nil {
+				return nil, err
+			}
+		}
+		return &VariableShowStmt{Name: m.name}, nil
+	}
+
+	name, err := p.parseVarName()
+	if err != nil {
+		return nil, err
+	}
+	return &VariableShowStmt{Name: name}, nil
+}
+
+// parseVariableSetStmt は SET [SESSION | LOCAL] var_name {TO | =} {var_list | DEFAULT} を解析する
+// (VariableSetStmt, set_rest 相当)
+func (p *parser) parseVariableSetStmt() (Node, error) {
+	if err := p.expectKeyword("set"); err != nil {
+		return nil, err
+	}
+	stmt := &VariableSetStmt{Kind: VarSetValue}
+	if p.tok.IsKeyword("local") || p.tok.IsKeyword("session") {
+		// SET SESSION AUTHORIZATION や SET SESSION CHARACTERISTICS とは区別する
+		next, err := p.lookahead()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.IsKeyword("session") && next.IsKeyword("characteristics") {
+			return p.parseSetSessionCharacteristics()
+		}
+		if next.Kind == IDENT {
+			stmt.IsLocal = p.tok.IsKeyword("local")
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.tok.IsKeyword("transaction") {
+		// SET TRANSACTION transaction_mode_list と、transaction という名前のパラメータの SET を区別する
+		next, err := p.lookahead()
+		if err != nil {
+			return nil, err
+		}
+		if next.Kind == IDENT && !next.IsKeyword("to") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			modes, err := p.parseTransactionModeList()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Kind, stmt.Name, stmt.Options = VarSetMulti, "TRANSACTION", modes
+			return stmt, nil
+		}
+	}
+	if err := p.parseGenericSet(stmt); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseSetSessionCharacteristics は SESSION CHARACTERISTICS AS TRANSACTION transaction_mode_list を解析する
+func (p *parser) parseSetSessionCharacteristics() (Node, error) {
+	for _, kw := range []string{"session", "characteristics", "as", "transaction"} {
+		if err := p.expectKeyword(kw); err != nil {
+			return nil, err
+		}
+	}
+	modes, err := p.parseTransactionModeList()
+	if err != nil {
+		return nil, err
+	}
+	return &VariableSetStmt{Kind: VarSetMulti, Name: "SESSION CHARACTERISTICS", Options: modes}, nil
+}
+
+// parseTransactionStmt は BEGIN、START TRANSACTION、COMMIT、END、ROLLBACK、ABORT、SAVEPOINT、
+// RELEASE、ROLLBACK TO、PREPARE TRANSACTION、COMMIT PREPARED、ROLLBACK PREPARED を解析する
+// (TransactionStmt 相当)
+func (p *parser) parseTransactionStmt() (Node, error) {
+	stmt := &TransactionStmt{}
+	switch {
+	case p.tok.IsKeyword("begin"):
+		stmt.Kind = TransStmtBegin
+	case p.tok.IsKeyword("start"):
+		stmt.Kind = TransStmtStart
+	case p.tok.IsKeyword("commit"), p.tok.IsKeyword("end"):
+		stmt.Kind = TransStmtCommit
+	case p.tok.IsKeyword("savepoint"):
+		stmt.Kind = TransStmtSavepoint
+	case p.tok.IsKeyword("release"):
+		stmt.Kind = TransStmtRelease
+	case p.tok.IsKeyword("prepare"):
+		stmt.Kind = TransStmtPrepare
+	default:
+		stmt.Kind = TransStmtRollback
+	}
+	commit, rollback := p.tok.IsKeyword("commit"), p.tok.IsKeyword("rollback")
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	switch stmt.Kind {
+	case TransStmtSavepoint:
+		return p.parseSavepointName(stmt)
+	case TransStmtRelease:
+		// RELEASE [SAVEPOINT] ColId
+		if _, err := p.acceptKeyword("savepoint"); err != nil {
+			return nil, err
+		}
+		return p.parseSavepointName(stmt)
+	case TransStmtPrepare:
+		// PREPARE TRANSACTION Sconst
+		if err := p.expectKeyword("transaction"); err != nil {
+			return nil, err
+		}
+		return p.parseGid(stmt)
+	}
+	// COMMIT PREPARED Sconst と ROLLBACK PREPARED Sconst
+	if (commit || rollback) && p.tok.IsKeyword("prepared") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		stmt.Kind = TransStmtCommitPrepared
+		if rollback {
+			stmt.Kind = TransStmtRollbackPrepared
+		}
+		return p.parseGid(stmt)
+	}
+
+	// opt_transaction。START の後は TRANSACTION が必須
+	if stmt.Kind == TransStmtStart {
+		if err := p.expectKeyword("transaction"); err != nil {
+			return nil, err
+		}
+	} else if p.tok.IsKeyword("work") || p.tok.IsKeyword("transaction") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	switch stmt.Kind {
+	case TransStmtBegin, TransStmtStart:
+		if p.tok.Kind == IDENT {
+			modes, err := p.parseTransactionModeList()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Options = modes
+		}
+	case TransStmtRollback:
+		// ROLLBACK [TRANSACTION] TO [SAVEPOINT] ColId。ABORT には TO を書けない
+		if rollback && p.tok.IsKeyword("to") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if _, err := p.acceptKeyword("savepoint"); err != nil {
+				return nil, err
+			}
+			stmt.Kind = TransStmtRollbackTo
+			return p.parseSavepointName(stmt)
+		}
+		fallthrough
+	case TransStmtCommit:
+		// opt_transaction_chain
+		if ok, err := p.acceptKeyword("and"); err != nil {
+			return nil, err
+		} else if ok {
+			no, err := p.acceptKeyword("no")
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("chain"); err != nil {
+				return nil, err
+			}
+			stmt.Chain = !no
+		}
+	}
+	return stmt, nil
+}
+
+// parseSavepointName はセーブポイントの名前 (ColId) を解析する
+func (p *parser) parseSavepointName(stmt *TransactionStmt) (Node, error) {
+	name, err := p.parseColId()
+	if err != nil {
+		return nil, err
+	}
+	stmt.SavepointName = name
+	return stmt, nil
+}
+
+// parseGid は2相コミットのトランザクションの識別子 (Sconst) を解析する
+func (p *parser) parseGid(stmt *TransactionStmt) (Node, error) {
+	if p.tok.Kind != SCONST {
+		return nil, p.syntaxError()
+	}
+	stmt.Gid = p.tok.Str
+	return stmt, p.advance()
+}
+
+// parseTransactionModeList は transaction_mode_item を空白かコンマで区切った並びを解析する
+// (transaction_mode_list 相当)
+func (p *parser) parseTransactionModeList() ([]*DefElem, error) {
+	var modes []*DefElem
+	for {
+		mode, err := p.parseTransactionModeItem()
+		if err != nil {
+			return nil, err
+		}
+		modes = append(modes, mode)
+		if p.tok.IsChar(',') {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.tok.Kind != IDENT {
+			return modes, nil
+		}
+	}
+}
+
+// parseTransactionModeItem は ISOLATION LEVEL iso_level、READ ONLY、READ WRITE、DEFERRABLE、
+// NOT DEFERRABLE のいずれかを解析する (transaction_mode_item 相当)
+func (p *parser) parseTransactionModeItem() (*DefElem, error) {
+	loc := p.tok.Loc
+	mode := func(name string, val Node, words ...string) (*DefElem, error) {
+		for _, kw := range words {
+			if err := p.expectKeyword(kw); err != nil {
+				return nil, err
+			}
+		}
+		return &DefElem{Defname: name, Arg: &AConst{Val: val, Location: loc}, Location: loc}, nil
+	}
+	switch {
+	case p.tok.IsKeyword("isolation"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("level"); err != nil {
+			return nil, err
+		}
+		next, err := p.lookahead()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case p.tok.IsKeyword("read") && next.IsKeyword("uncommitted"):
+			return mode("transaction_isolation", &String{Sval: "read uncommitted"}, "read", "uncommitted")
+		case p.tok.IsKeyword("read") && next.IsKeyword("committed"):
+			return mode("transaction_isolation", &String{Sval: "read committed"}, "read", "committed")
+		case p.tok.IsKeyword("repeatable"):
+			return mode("transaction_isolation", &String{Sval: "repeatable read"}, "repeatable", "read")
+		case p.tok.IsKeyword("serializable"):
+			return mode("transaction_isolation", &String{Sval: "serializable"}, "serializable")
+		}
+	case p.tok.IsKeyword("read"):
+		next, err := p.lookahead()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case next.IsKeyword("only"):
+			return mode("transaction_read_only", &Integer{Ival: 1}, "read", "only")
+		case next.IsKeyword("write"):
+			return mode("transaction_read_only", &Integer{Ival: 0}, "read", "write")
+		}
+		// READ の次のトークンの位置で構文エラーにする
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	case p.tok.IsKeyword("deferrable"):
+		return mode("transaction_deferrable", &Integer{Ival: 1}, "deferrable")
+	case p.tok.IsKeyword("not"):
+		return mode("transaction_deferrable", &Integer{Ival: 0}, "not", "deferrable")
+	}
+	return nil, p.syntaxError()
+}
+
+// parseGenericSet は var_name {TO | =} {var_list | DEFAULT} を解析して stmt に設定する (generic_set 相当)
+func (p *parser) parseGenericSet(stmt *VariableSetStmt) error {
+	name, err := p.parseVarName()
+	if err != nil {
+		return err
+	}
+	stmt.Name = name
+	return p.parseGenericSetValue(stmt)
+}
+
+// parseGenericSetValue は generic_set のうち var_name に続く {TO | =} {var_list | DEFAULT} を
+// 解析して stmt に設定する
+func (p *parser) parseGenericSetValue(stmt *VariableSetStmt) error {
+	if ok, err := p.acceptKeyword("to"); err != nil {
+		return err
+	} else if !ok {
+		if err := p.expectChar('='); err != nil {
+			return err
+		}
+	}
+	if ok, err := p.acceptKeyword("default"); err != nil {
+		return err
+	} else if ok {
+		stmt.Kind = VarSetDefault
+		return nil
+	}
+	for {
+		arg, err := p.parseVarValue()
+		if err != nil {
+			return err
+		}
+		stmt.Args = append(stmt.Args, arg)
+		if !p.tok.IsChar(',') {
+			return nil
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseVariableResetStmt は RESET {var_name | ALL} を解析する (VariableResetStmt 相当)
+func (p *parser) parseVariableResetStmt() (Node, error) {
+	if err := p.expectKeyword("reset"); err != nil {
+		return nil, err
+	}
+	if ok, err := p.acceptKeyword("all"); err != nil {
+		return nil, err
+	} else if ok {
+		return &VariableSetStmt{Kind: VarResetAll}, nil
+	}
+	name, err := p.parseVarName()
+	if err != nil {
+		return nil, err
+	}
+	return &VariableSetStmt{Kind: VarReset, Name: name}, nil
+}
+
+// parseVarName は ColId をドットでつないだ設定パラメータの名前を解析する (var_name 相当)
+func (p *parser) parseVarName() (string, error) {
+	var name strings.Builder
+	for {
+		id, err := p.parseColId()
+		if err != nil {
+			return "", err
+		}
+		name.WriteString(id)
+		if !p.tok.IsChar('.') {
+			break
+		}
+		name.WriteByte('.')
+		if err := p.advance(); err != nil {
+			return "", err
+		}
+	}
+	return name.String(), nil
+}
+
+// parseVarValue は設定する値を1つ解析する (var_value 相当)。
+// 値は文字列、数値 (符号付き)、ON、または予約語でないキーワードや識別子。
+func (p *parser) parseVarValue() (*AConst, error) {
+	t := p.tok
+	switch {
+	case t.Kind == SCONST:
+		return &AConst{Val: &String{Sval: t.Str}, Location: t.Loc}, p.advance()
+	case t.Kind == IDENT && (t.Quoted || !reservedKeywords[t.Str] || t.IsKeyword("on")):
+		return &AConst{Val: &String{Sval: t.Str}, Location: t.Loc}, p.advance()
+	case t.Kind == ICONST, t.Kind == FCONST, t.IsChar('+'), t.IsChar('-'):
+		sign := ""
+		if t.IsChar('+') || t.IsChar('-') {
+			if t.IsChar('-') {
+				sign = "-"
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if p.tok.Kind != ICONST && p.tok.Kind != FCONST {
+				return nil, p.syntaxError()
+			}
+		}
+		num := p.tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if num.Kind == ICONST {
+			n, _ := strconv.ParseInt(sign+num.Str, 10, 32)
+			return &AConst{Val: &Integer{Ival: int32(n)}, Location: t.Loc}, nil
+		}
+		return &AConst{Val: &Float{Fval: sign + num.Str}, Location: t.Loc}, nil
+	}
+	return nil, p.syntaxError()
+}
+
+// parseTargetEl は a_expr [AS ColLabel | BareColLabel] を解析する (target_el 相当)
+func (p *parser) parseTargetEl() (*ResTarget, error) {
+	loc := p.tok.Loc
+	if p.tok.IsChar('*') {
+		rt := &ResTarget{Val: &ColumnRef{Fields: []Node{&AStar{}}, Location: loc}, Location: loc}
+		return rt, p.advance()
+	}
+	val, err := p.parseAExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	rt := &ResTarget{Val: val, Location: loc}
+
+	if ok, err := p.acceptKeyword("as"); err != nil {
+		return nil, err
+	} else if ok {
+		if p.tok.Kind != IDENT {
+			return nil, p.syntaxError()
+		}
+		rt.Name = p.tok.Str
+		return rt, p.advance()
+	}
+	if p.tok.Kind == IDENT && (p.tok.Quoted || !reservedKeywords[p.tok.Str]) {
+		rt.Name = p.tok.Str
+		return rt, p.advance()
+	}
+	return rt, nil
+}
+
+// ----------------------------------------------------------------
+// 式 (a_expr 相当)
+// ----------------------------------------------------------------
+// 演算子の優先順位は gram.y の %left / %right 宣言に従う。
+
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precCompare
+	precOp
+	precAdd
+	precMul
+	precExp
+	precUnary
+)
+
+// binaryPrec は現在のトークンが二項演算子であればその優先順位と名前を返す。
+func (p *parser) binaryPrec() (int, string) {
+	t := p.tok
+	switch t.Kind {
+	case IDENT:
+		if t.Quoted {
+			return 0, ""
+		}
+		switch t.Str {
+		case "or":
+			return precOr, "or"
+		case "and":
+			return precAnd, "and"
+		}
+	case CHAR:
+		switch t.Str {
+		case "<", ">", "=":
+			return precCompare, t.Str
+		case "+", "-":
+			return precAdd, t.Str
+		case "*", "/", "%":
+			return precMul, t.Str
+		case "^":
+			return precExp, t.Str
+		}
+	case LESS_EQUALS, GREATER_EQUALS, NOT_EQUALS:
+		if t.Str == "!=" {
+			return precCompare, "<>"
+		}
+		return precCompare, t.Str
+	case Op:
+		return precOp, t.Str
+	}
+	return 0, ""
+}
+
+func (p *parser) parseAExpr(minPrec int) (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		prec, name := p.binaryPrec()
+		if prec == 0 || prec < minPrec {
+			return left, nil
+		}
+		loc := p.tok.Loc
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		// 二項演算子はいずれも左結合として扱う
+		next := prec + 1
+		right, err := p.parseAExpr(next)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "and":
+			left = &BoolExpr{Op: AndExpr, Args: []Node{left, right}, Location: loc}
+		case "or":
+			left = &BoolExpr{Op: OrExpr, Args: []Node{left, right}, Location: loc}
+		default:
+			left = &AExpr{Kind: AExprOp, Name: name, Lexpr: left, Rexpr: right, Location: loc}
+		}
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	loc := p.tok.Loc
+	switch {
+	case p.tok.IsKeyword("not"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		arg, err := p.parseAExpr(precNot)
+		if err != nil {
+			return nil, err
+		}
+		return &BoolExpr{Op: NotExpr, Args: []Node{arg}, Location: loc}, nil
+	case p.tok.IsChar('-'), p.tok.IsChar('+'):
+		op := p.tok.Str
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		arg, err := p.parseAExpr(precUnary)
+		if err != nil {
+			return nil, err
+		}
+		return doNegate(op, arg, loc), nil
+	case p.tok.Kind == Op:
+		// 前置演算子 (qual_Op a_expr %prec Op)
+		op := p.tok.Str
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		arg, err := p.parseAExpr(precOp + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &AExpr{Kind: AExprOp, Name: op, Rexpr: arg, Location: loc}, nil
+	}
+	return p.parsePostfix()
+}
+
+// doNegate は数値定数の符号反転を定数に畳み込む (doNegate 相当)
+func doNegate(op string, arg Node, loc int) Node {
+	if op == "-" {
+		if c, ok := arg.(*AConst); ok {
+			switch v := c.Val.(type) {
+			case *Integer:
+				return &AConst{Val: &Integer{Ival: -v.Ival}, Location: loc}
+			case *Float:
+				// -2147483648 は int4 に収まるが、字句解析の時点では FCONST になっている
+				if v.Fval == "2147483648" {
+					return &AConst{Val: &Integer{Ival: -2147483648}, Location: loc}
+				}
+				return &AConst{Val: &Float{Fval: "-" + v.Fval}, Location: loc}
+			}
+		}
+	}
+	return &AExpr{Kind: AExprOp, Name: op, Rexpr: arg, Location: loc}
+}
+
+// parsePostfix は c_expr に続く :: typename を解析する。
+func (p *parser) parsePostfix() (Node, error) {
+	expr, err := p.parseCExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.Kind == TYPECAST {
+		loc := p.tok.Loc
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		tn, err := p.parseTypeName()
+		if err != nil {
+			return nil, err
+		}
+		expr = &TypeCast{Arg: expr, TypeName: tn, Location: loc}
+	}
+	return expr, nil
+}
+
+// parseCExpr は定数、括弧式、CAST(...)、関数呼び出しを解析する (c_expr 相当)
+func (p *parser) parseCExpr() (Node, error) {
+	t := p.tok
+	switch {
+	case t.Kind == ICONST:
+		v, err := strconv.ParseInt(t.Str, 10, 32)
+		if err != nil {
+			return nil, p.syntaxError()
+		}
+		return &AConst{Val: &Integer{Ival: int32(v)}, Location: t.Loc}, p.advance()
+	case t.Kind == FCONST:
+		return &AConst{Val: &Float{Fval: t.Str}, Location: t.Loc}, p.advance()
+	case t.Kind == SCONST:
+		return &AConst{Val: &String{Sval: t.Str}, Location: t.Loc}, p.advance()
+	case t.IsKeyword("true"):
+		return &AConst{Val: &Boolean{Boolval: true}, Location: t.Loc}, p.advance()
+	case t.IsKeyword("false"):
+		return &AConst{Val: &Boolean{Boolval: false}, Location: t.Loc}, p.advance()
+	case t.IsKeyword("null"):
+		return &AConst{Location: t.Loc}, p.advance()
+	case t.Kind == PARAM:
+		n, err := strconv.Atoi(t.Str)
+		if err != nil || n <= 0 {
+			return nil, &SyntaxError{Message: fmt.Sprintf("there is no parameter $%s", t.Str), Position: t.Loc, Code: errcodes.UndefinedParameter}
+		}
+		return &ParamRef{Number: n, Location: t.Loc}, p.advance()
+	case t.IsChar('('):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseAExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expectChar(')')
+	case t.IsKeyword("cast"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expectChar('('); err != nil {
+			return nil, err
+		}
+		arg, err := p.parseAExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("as"); err != nil {
+			return nil, err
+		}
+		tn, err := p.parseTypeName()
+		if err != nil {
+			return nil, err
+		}
+		return &TypeCast{Arg: arg, TypeName: tn, Location: t.Loc}, p.expectChar(')')
+	case t.Kind == IDENT && !t.Quoted && sqlValueFunctions[t.Str] != "":
+		// CURRENT_SCHEMA は予約語ではなく、current_schema() とも書ける
+		if t.Str == "current_schema" {
+			next, err := p.lookahead()
+			if err != nil {
+				return nil, err
+			}
+			if next.IsChar('(') {
+				return p.parseFuncCall()
+			}
+		}
+		return &FuncCall{Funcname: []string{sqlValueFunctions[t.Str]}, Location: t.Loc}, p.advance()
+	case t.Kind == IDENT && (t.Quoted || !reservedKeywords[t.Str]):
+		next, err := p.lookahead()
+		if err != nil {
+			return nil, err
+		}
+		if next.IsChar('(') {
+			return p.parseFuncCall()
+		}
+		return p.parseColumnRef()
+	}
+	return nil, p.syntaxError()
+}
+
+// sqlValueFunctions は括弧なしで呼ぶ SQL 標準の関数と、その値を返す関数の名前
+// (func_expr_common_subexpr の SQLValueFunction 相当)。C言語版は SQLValueFunction の節を作るが、
+// ここでは同じ値を返す関数の呼び出しにする。
+var sqlValueFunctions = map[string]string{
+	"current_catalog": "current_database",
+	"current_role":    "current_user",
+	"current_schema":  "current_schema",
+	"current_user":    "current_user",
+	"session_user":    "session_user",
+	"user":            "current_user",
+}
+
+// parseColumnRef は ColId [. attr_name | . *] を解析する (columnref 相当)
+func (p *parser) parseColumnRef() (Node, error) {
+	cref := &ColumnRef{Fields: []Node{&String{Sval: p.tok.Str}}, Location: p.tok.Loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	for p.tok.IsChar('.') {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.IsChar('*') {
+			cref.Fields = append(cref.Fields, &AStar{})
+			return cref, p.advance()
+		}
+		// attr_name は予約語も受け付ける (ColLabel)
+		if p.tok.Kind != IDENT {
+			return nil, p.syntaxError()
+		}
+		cref.Fields = append(cref.Fields, &String{Sval: p.tok.Str})
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return cref, nil
+}
+
+// parseFuncCall は func_name '(' [func_arg_list] ')' を解析する (func_application 相当)
+func (p *parser) parseFuncCall() (Node, error) {
+	fc := &FuncCall{Funcname: []string{p.tok.Str}, Location: p.tok.Loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expectChar('('); err != nil {
+		return nil, err
+	}
+	if p.tok.IsChar(')') {
+		return fc, p.advance()
+	}
+	for {
+		arg, err := p.parseAExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		fc.Args = append(fc.Args, arg)
+		if !p.tok.IsChar(',') {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return fc, p.expectChar(')')
+}
+
+// parseTypeName は型名を解析する (Typename 相当)。
+// "double precision" や "character varying" のような複数語の型名も扱う。
+func (p *parser) parseTypeName() (*TypeName, error) {
+	if p.tok.Kind != IDENT {
+		return nil, p.syntaxError()
+	}
+	tn := &TypeName{Location: p.tok.Loc}
+	name, quoted := p.tok.Str, p.tok.Quoted
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	// SQL 標準の型名は gram.y と同じく内部名に置き換える (SystemTypeName 相当)。
+	// "char" のように二重引用符で囲んだ名前は置き換えない。
+	if !quoted {
+		switch name {
+		case "int", "integer":
+			name = "int4"
+		case "bigint":
+			name = "int8"
+		case "smallint":
+			name = "int2"
+		case "boolean":
+			name = "bool"
+		case "decimal", "dec":
+			name = "numeric"
+		case "real":
+			name = "float4"
+		case "float":
+			name = "float8"
+		case "double":
+			if err := p.expectKeyword("precision"); err != nil {
+				return nil, err
+			}
+			name = "float8"
+		case "character", "char":
+			if ok, err := p.acceptKeyword("varying"); err != nil {
+				return nil, err
+			} else if ok {
+				name = "varchar"
+			} else {
+				name = "bpchar"
+			}
+		case "timestamp", "time":
+			withTZ, err := p.parseOptTimezone()
+			if err != nil {
+				return nil, err
+			}
+			if withTZ {
+				name += "tz"
+			}
+		}
+	}
+	tn.Names = []string{name}
+
+	if p.tok.IsChar('(') {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for {
+			mod, err := p.parseCExpr()
+			if err != nil {
+				return nil, err
+			}
+			tn.Typmods = append(tn.Typmods, mod)
+			if !p.tok.IsChar(',') {
+				break
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expectChar(')'); err != nil {
+			return nil, err
+		}
+	}
+
+	// 配列型 "type[]" / "type[n]" (opt_array_bounds 相当)
+	for p.tok.IsChar('[') {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		bound := -1
+		if p.tok.Kind == ICONST {
+			n, err := strconv.Atoi(p.tok.Str)
+			if err != nil {
+				return nil, p.syntaxError()
+			}
+			bound = n
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expectChar(']'); err != nil {
+			return nil, err
+		}
+		tn.ArrayBounds = append(tn.ArrayBounds, bound)
+	}
+	return tn, nil
+}
+
+// parseOptTimezone は "with time zone" / "without time zone" を読み、
+// with であれば true を返す (opt_timezone 相当)。
+func (p *parser) parseOptTimezone() (bool, error) {
+	withTZ := p.tok.IsKeyword("with")
+	if !withTZ && !p.tok.IsKeyword("without") {
+		return false, nil
+	}
+	if err := p.advance(); err != nil {
+		return false, err
+	}
+	if err := p.expectKeyword("time"); err != nil {
+		return false, err
+	}
+	return withTZ, p.expectKeyword("zone")
+}
@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 型変換 (parse_coerce.c 相当)
+// ----------------------------------------------------------------
+// 型から型への変換の方法は pg_cast で決める。変換を行えるかどうかは場面 (CoercionContext) による。
+// 関数の引数と比較演算子の被演算子では暗黙の変換 (castcontext 'i') だけを、CAST と :: では
+// 全ての変換を行える。
+//
+// pg_cast にない組み合わせでも、C言語版と同じく文字列型への代入と明示的な変換は入出力関数で行う。
+// C言語版では明示的な入出力関数による変換は文字列型からの変換に限るが、組み込み型の間の変換関数
+// (int4 から boolean への int4bool など) がまだないため、ここでは全ての型の間で行う。
+//
+// ドメインは基の型として変換を探す。ドメインを変換元か変換先とする pg_cast の行は使わない。
+
+// coercionPathType は型変換の方法 (CoercionPathType 相当)
+type coercionPathType int
+
+const (
+	coercionPathNone        coercionPathType = iota // 変換できない
+	coercionPathFunc                                // 関数を呼ぶ
+	coercionPathRelabelType                         // 値をそのまま使う
+	coercionPathCoerceViaIO                         // 入出力関数で変換する
+)
+
+// stringTypes は文字列の分類 (TYPCATEGORY_STRING) の型
+var stringTypes = map[catalog.Oid]bool{
+	catalog.TEXTOID: true, catalog.VARCHAROID: true, catalog.BPCHAROID: true, catalog.NAMEOID: true,
+}
+
+// findCoercionPathway は source 型から target 型への変換の方法を返す (find_coercion_pathway 相当)。
+// 関数を呼ぶ場合は、その関数の OID も返す。
+func findCoercionPathway(target, source catalog.Oid, ccontext CoercionContext) (coercionPathType, catalog.Oid) {
+	if source == target {
+		return coercionPathRelabelType, catalog.InvalidOid
+	}
+	target = catalog.GetBaseType(target)
+	source = catalog.GetBaseType(source)
+	if source == target {
+		return coercionPathRelabelType, catalog.InvalidOid
+	}
+
+	if cast, ok := catalog.SearchCast(source, target); ok {
+		allowed := false
+		switch cast.Castcontext {
+		case catalog.CoercionCodeImplicit:
+			allowed = true
+		case catalog.CoercionCodeAssignment:
+			allowed = ccontext >= CoercionAssignment
+		case catalog.CoercionCodeExplicit:
+			allowed = ccontext >= CoercionExplicit
+		}
+		if !allowed {
+			return coercionPathNone, catalog.InvalidOid
+		}
+		switch cast.Castmethod {
+		case catalog.CoercionMethodFunction:
+			return coercionPathFunc, cast.Castfunc
+		case catalog.CoercionMethodBinary:
+			return coercionPathRelabelType, catalog.InvalidOid
+		}
+		return coercionPathCoerceViaIO, catalog.InvalidOid
+	}
+
+	// pg_cast にない組み合わせは入出力関数で変換する
+	if ccontext >= CoercionAssignment && stringTypes[target] || ccontext >= CoercionExplicit {
+		return coercionPathCoerceViaIO, catalog.InvalidOid
+	}
+	return coercionPathNone, catalog.InvalidOid
+}
+
+// canCoerceType は source 型の式を target 型に変換できるかを返す (can_coerce_type 相当)。
+// 型未定の定数とパラメータは、どの型にも変換できる。
+func canCoerceType(source, target catalog.Oid, ccontext CoercionContext) bool {
+	if source == target || source == catalog.UNKNOWNOID {
+		return true
+	}
+	path, _ := findCoercionPathway(target, source, ccontext)
+	return path != coercionPathNone
+}
+
+// IsBinaryCoercible は source 型の値を変換せずに target 型の値として使えるかを返す
+// (IsBinaryCoercible 相当)。ドメインの値は基の型の値として使える。
+func IsBinaryCoercible(source, target catalog.Oid) bool {
+	if source == target {
+		return true
+	}
+	if source = catalog.GetBaseType(source); source == target {
+		return true
+	}
+	cast, ok := catalog.SearchCast(source, target)
+	return ok && cast.Castcontext == catalog.CoercionCodeImplicit && cast.Castmethod == catalog.CoercionMethodBinary
+}
+
+// coerceToBoolean は式を boolean にする (coerce_to_boolean 相当)。constructName はエラーメッセージに
+// 出す構文の名前 (AND、CHECK など)。
+func (ps *ParseState) coerceToBoolean(expr Expr, constructName string, location int) (Expr, error) {
+	typid := expr.ExprType()
+	if typid == catalog.UNKNOWNOID {
+		return ps.coerceType(expr, catalog.BOOLOID, CoercionAssignment, location)
+	}
+	if catalog.GetBaseType(typid) != catalog.BOOLOID {
+		return nil, newError(errcodes.DatatypeMismatch, "argument of %s must be type %s, not type %s", constructName, "boolean", catalog.FormatType(typid))
+	}
+	return ps.coerceType(expr, catalog.BOOLOID, CoercionAssignment, location)
+}
+
+// coerceType は式を target 型に変換する (coerce_type 相当)。変換の方法は findCoercionPathway で
+// 決め、ccontext の場面で行えない変換はエラーにする。
+// 型未定の定数はこの時点で入力関数を呼んで target 型の定数にし、
+// 型未定のパラメータは target 型であると推論する。
+//
+// ドメインの値は基の型の値として変換する。target がドメインの場合は基の型に変換してから
+// CoerceToDomain で包み、実行時にドメインの制約を確かめる (coerce_to_domain 相当)。
+func (ps *ParseState) coerceType(expr Expr, target catalog.Oid, ccontext CoercionContext, location int) (Expr, error) {
+	source := expr.ExprType()
+	if source == target {
+		return expr, nil
+	}
+	if base := catalog.GetBaseType(target); base != target {
+		// 型未定のパラメータはドメインの型であると推論する。値は Bind で確かめる
+		if p, ok := expr.(*Param); ok && source == catalog.UNKNOWNOID && ps.varParams {
+			ps.paramTypes[p.ParamID-1] = target
+			p.ParamType = target
+			return p, nil
+		}
+		arg, err := ps.coerceType(expr, base, ccontext, location)
+		if err != nil {
+			return nil, err
+		}
+		return &CoerceToDomain{Arg: arg, ResultType: target, Location: location}, nil
+	}
+	if base := catalog.GetBaseType(source); base != source {
+		expr = &RelabelType{Arg: expr, ResultType: base}
+		if source = base; source == target {
+			return expr, nil
+		}
+	}
+
+	switch e := expr.(type) {
+	case *Const:
+		if source == catalog.UNKNOWNOID {
+			if e.ConstValue == nil {
+				return &Const{ConstType: target, Location: e.Location}, nil
+			}
+			val, err := adt.InputFunctionCall(target, e.ConstValue.(string))
+			if err != nil {
+				return nil, err
+			}
+			return &Const{ConstType: target, ConstValue: val, Location: e.Location}, nil
+		}
+	case *Param:
+		if source == catalog.UNKNOWNOID && ps.varParams {
+			ps.paramTypes[e.ParamID-1] = target
+			e.ParamType = target
+			return e, nil
+		}
+	}
+	if source == catalog.UNKNOWNOID {
+		return &CoerceViaIO{Arg: expr, ResultType: target, Location: location}, nil
+	}
+
+	path, funcID := findCoercionPathway(target, source, ccontext)
+	switch path {
+	case coercionPathFunc:
+		return buildCoercionExpression(expr, funcID, target, ccontext, location)
+	case coercionPathRelabelType:
+		return &RelabelType{Arg: expr, ResultType: target}, nil
+	case coercionPathCoerceViaIO:
+		return &CoerceViaIO{Arg: expr, ResultType: target, Location: location}, nil
+	}
+	return nil, newError(errcodes.CannotCoerce, "cannot cast type %s to %s", catalog.FormatType(source), catalog.FormatType(target))
+}
+
+// buildCoercionExpression は変換の関数の呼び出しを作る (build_coercion_expression 相当)。
+// 関数が2つ目の引数を取る場合は型修飾子 (-1 で指定なし) を、3つ目の引数を取る場合は
+// 明示的な変換であるかを渡す。
+func buildCoercionExpression(expr Expr, funcID, target catalog.Oid, ccontext CoercionContext, location int) (Expr, error) {
+	proc, ok := catalog.SearchProc(funcID)
+	if !ok {
+		return nil, fmt.Errorf("cache lookup failed for function %d", funcID)
+	}
+	args := []Expr{expr}
+	if len(proc.Proargtypes) >= 2 {
+		args = append(args, &Const{ConstType: catalog.INT4OID, ConstValue: int32(-1), Location: -1})
+	}
+	if len(proc.Proargtypes) >= 3 {
+		args = append(args, &Const{ConstType: catalog.BOOLOID, ConstValue: ccontext == CoercionExplicit, Location: -1})
+	}
+	return &FuncExpr{FuncID: funcID, Args: args, ResultType: target, Location: location}, nil
+}
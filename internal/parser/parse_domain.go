@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// ドメインの制約と既定値の式の解析 (commands/typecmds.c の domainAddCheckConstraint、
+// catalog/heap.c の cookDefault のうち式を解析する部分相当)
+// ----------------------------------------------------------------
+// CREATE DOMAIN と ALTER DOMAIN の DEFAULT と CHECK の式は、FROM 句のない式として解析する。
+// 列は参照できず、パラメータも使えない。
+
+// TransformDomainCheck はドメインの CHECK 制約の式を解析する。式の中の VALUE は確かめる値を表し、
+// 基の型 baseType の CoerceToDomainValue になる。式は boolean でなければならない。
+func TransformDomainCheck(expr Node, baseType catalog.Oid, location int) (Expr, error) {
+	ps := &ParseState{domainValueType: baseType}
+	e, err := ps.transformExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	return ps.coerceToBoolean(e, "CHECK", location)
+}
+
+// TransformDomainDefault はドメイン domainName の既定値の式を解析し、基の型 baseType に代入の
+// 変換で変換する (cookDefault 相当)
+func TransformDomainDefault(expr Node, domainName string, baseType catalog.Oid, location int) (Expr, error) {
+	ps := &ParseState{}
+	e, err := ps.transformExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	if !canCoerceType(e.ExprType(), baseType, CoercionAssignment) {
+		return nil, newError(errcodes.DatatypeMismatch, "column \"%s\" is of type %s but default expression is of type %s",
+			domainName, catalog.FormatType(baseType), catalog.FormatType(e.ExprType()))
+	}
+	return ps.coerceType(e, baseType, CoercionAssignment, location)
+}
@@ -0,0 +1,237 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 式の解析 (parse_expr.c, parse_node.c 相当)
+// ----------------------------------------------------------------
+
+// transformExpr は構文木の式を型の確定した式に変換する (transformExpr 相当)
+func (ps *ParseState) transformExpr(node Node) (Expr, error) {
+	switch n := node.(type) {
+	case *AConst:
+		return makeConst(n)
+	case *ParamRef:
+		return ps.transformParamRef(n)
+	case *ColumnRef:
+		return ps.transformColumnRef(n)
+	case *TypeCast:
+		arg, err := ps.transformExpr(n.Arg)
+		if err != nil {
+			return nil, err
+		}
+		target, err := TypenameTypeID(n.TypeName)
+		if err != nil {
+			return nil, err
+		}
+		return ps.coerceType(arg, target, CoercionExplicit, n.Location)
+	case *FuncCall:
+		return ps.transformFuncCall(n)
+	case *AExpr:
+		return ps.transformAExprOp(n)
+	case *BoolExpr:
+		return ps.transformBoolExpr(n)
+	}
+	return nil, fmt.Errorf("unrecognized node type: %T", node)
+}
+
+// TypenameTypeID は TypeName が表す型の OID を返す (typenameTypeId 相当)。
+// 配列の次元の数や大きさは型に影響しない。
+func TypenameTypeID(tn *TypeName) (catalog.Oid, error) {
+	name := tn.Names[len(tn.Names)-1]
+	typid, ok := catalog.TypenameTypeID(name)
+	if !ok {
+		return catalog.InvalidOid, newError(errcodes.UndefinedObject, "type \"%s\" does not exist", name)
+	}
+	if tn.ArrayBounds != nil {
+		array := catalog.GetArrayType(typid)
+		if array == catalog.InvalidOid {
+			return catalog.InvalidOid, newError(errcodes.UndefinedObject, "could not find array type for data type %s", catalog.FormatType(typid))
+		}
+		typid = array
+	}
+	return typid, nil
+}
+
+// makeConst は定数の値と型を決める (make_const 相当)
+func makeConst(c *AConst) (*Const, error) {
+	switch v := c.Val.(type) {
+	case nil:
+		return &Const{ConstType: catalog.UNKNOWNOID, Location: c.Location}, nil
+	case *Integer:
+		return &Const{ConstType: catalog.INT4OID, ConstValue: v.Ival, Location: c.Location}, nil
+	case *Float:
+		// int8 に収まる整数は int8、それ以外は numeric とする
+		if i, err := strconv.ParseInt(v.Fval, 10, 64); err == nil {
+			return &Const{ConstType: catalog.INT8OID, ConstValue: i, Location: c.Location}, nil
+		}
+		n, err := adt.NumericIn(v.Fval)
+		if err != nil {
+			return nil, err
+		}
+		return &Const{ConstType: catalog.NUMERICOID, ConstValue: n, Location: c.Location}, nil
+	case *String:
+		return &Const{ConstType: catalog.UNKNOWNOID, ConstValue: v.Sval, Location: c.Location}, nil
+	case *Boolean:
+		return &Const{ConstType: catalog.BOOLOID, ConstValue: v.Boolval, Location: c.Location}, nil
+	}
+	return nil, fmt.Errorf("unrecognized node type: %T", c.Val)
+}
+
+// transformParamRef は $n を Param に変換する (fixed_paramref_hook / variable_paramref_hook 相当)
+func (ps *ParseState) transformParamRef(ref *ParamRef) (Expr, error) {
+	n := ref.Number
+	if n > len(ps.paramTypes) {
+		if !ps.varParams {
+			return nil, newError(errcodes.UndefinedParameter, "there is no parameter $%d", n)
+		}
+		for len(ps.paramTypes) < n {
+			ps.paramTypes = append(ps.paramTypes, catalog.UNKNOWNOID)
+		}
+	}
+	p := &Param{ParamID: n, ParamType: ps.paramTypes[n-1], Location: ref.Location}
+	ps.params = append(ps.params, p)
+	return p, nil
+}
+
+// transformAExprOp は演算子式を解析する (transformAExprOp 相当)。CREATE OPERATOR で作った演算子を
+// 先に探し、合うものがなければ組み込みの演算子として解析する。
+func (ps *ParseState) transformAExprOp(a *AExpr) (Expr, error) {
+	var left Expr
+	if a.Lexpr != nil {
+		var err error
+		if left, err = ps.transformExpr(a.Lexpr); err != nil {
+			return nil, err
+		}
+	}
+	right, err := ps.transformExpr(a.Rexpr)
+	if err != nil {
+		return nil, err
+	}
+	ltype := catalog.InvalidOid
+	if left != nil {
+		ltype = left.ExprType()
+	}
+	op, found, err := operSelect(a.Name, ltype, right.ExprType())
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return ps.makeOp(op, left, right, a.Location)
+	}
+	if left == nil {
+		return transformPrefixOp(a, right)
+	}
+	return ps.transformComparisonOp(a, left, right)
+}
+
+// transformPrefixOp は組み込みの前置演算子を解析する。現時点では数値型の単項マイナスのみ対応する。
+func transformPrefixOp(a *AExpr, arg Expr) (Expr, error) {
+	typid := arg.ExprType()
+	switch typid {
+	case catalog.INT2OID, catalog.INT4OID, catalog.INT8OID, catalog.FLOAT8OID, catalog.NUMERICOID:
+		if a.Name == "+" {
+			return arg, nil
+		}
+		if a.Name == "-" {
+			return &OpExpr{Opname: "-", Args: []Expr{arg}, ResultType: typid, Location: a.Location}, nil
+		}
+	}
+	return nil, newError(errcodes.UndefinedFunction, "operator does not exist: %s %s", a.Name, catalog.FormatType(typid))
+}
+
+// comparisonTypes は比較演算子で比べられる型。演算子のカタログ (pg_operator) がまだないため、
+// 組み込みの型の比較演算子 (int4eq、texteq など) に当たるものだけを扱う
+var comparisonTypes = map[catalog.Oid]bool{
+	catalog.BOOLOID: true, catalog.CHAROID: true, catalog.NAMEOID: true,
+	catalog.INT2OID: true, catalog.INT4OID: true, catalog.INT8OID: true,
+	catalog.OIDOID: true, catalog.FLOAT4OID: true, catalog.FLOAT8OID: true, catalog.NUMERICOID: true,
+	catalog.TEXTOID: true, catalog.VARCHAROID: true, catalog.BPCHAROID: true, catalog.BYTEAOID: true,
+	catalog.UUIDOID: true, catalog.DATEOID: true, catalog.TIMEOID: true,
+	catalog.TIMESTAMPOID: true, catalog.TIMESTAMPTZOID: true,
+}
+
+// numericPromotion は型の異なる数値を比べるときに合わせる順位。順位の高い方の型に合わせる
+// (数値型の間の暗黙の型変換と、int48lt のような型をまたぐ演算子相当)
+var numericPromotion = map[catalog.Oid]int{
+	catalog.INT2OID: 1, catalog.INT4OID: 2, catalog.INT8OID: 3,
+	catalog.NUMERICOID: 4, catalog.FLOAT4OID: 5, catalog.FLOAT8OID: 6,
+}
+
+// transformComparisonOp は組み込みの比較演算子 (=、<>、<、>、<=、>=) を解析する (make_op 相当)。
+// 型未定の定数とパラメータは他方の型に合わせ、両方とも型未定なら text とする。
+// ドメインは基の型として比べる。
+func (ps *ParseState) transformComparisonOp(a *AExpr, left, right Expr) (Expr, error) {
+	switch a.Name {
+	case "=", "<>", "<", ">", "<=", ">=":
+	case "+", "-", "*", "/", "%", "^":
+		return nil, newError(errcodes.FeatureNotSupported, "operator is not supported yet: %s", a.Name)
+	default:
+		// 組み込みの演算子でなく、CREATE OPERATOR で作った演算子にも合うものがない (op_error 相当)
+		return nil, withOperHint(newError(errcodes.UndefinedFunction, "operator does not exist: %s",
+			opSignatureString(a.Name, catalog.OprkindBinary, left.ExprType(), right.ExprType())),
+			"No operator matches the given name and argument types. You might need to add explicit type casts.")
+	}
+	var err error
+	ltype := catalog.GetBaseType(left.ExprType())
+	rtype := catalog.GetBaseType(right.ExprType())
+	switch {
+	case ltype == catalog.UNKNOWNOID && rtype == catalog.UNKNOWNOID:
+		ltype, rtype = catalog.TEXTOID, catalog.TEXTOID
+	case ltype == catalog.UNKNOWNOID:
+		ltype = rtype
+	case rtype == catalog.UNKNOWNOID:
+		rtype = ltype
+	}
+	if ltype != rtype {
+		// 数値型は順位の高い方に、それ以外は暗黙に変換できる方に合わせる
+		lp, lok := numericPromotion[ltype]
+		rp, rok := numericPromotion[rtype]
+		switch {
+		case lok && rok && lp < rp:
+			ltype = rtype
+		case lok && rok:
+			rtype = ltype
+		case canCoerceType(rtype, ltype, CoercionImplicit):
+			rtype = ltype
+		case canCoerceType(ltype, rtype, CoercionImplicit):
+			ltype = rtype
+		default:
+			return nil, newError(errcodes.UndefinedFunction, "operator does not exist: %s %s %s", catalog.FormatType(ltype), a.Name, catalog.FormatType(rtype))
+		}
+	}
+	if !comparisonTypes[ltype] {
+		return nil, newError(errcodes.UndefinedFunction, "operator does not exist: %s %s %s", catalog.FormatType(ltype), a.Name, catalog.FormatType(rtype))
+	}
+	if left, err = ps.coerceType(left, ltype, CoercionImplicit, a.Location); err != nil {
+		return nil, err
+	}
+	if right, err = ps.coerceType(right, rtype, CoercionImplicit, a.Location); err != nil {
+		return nil, err
+	}
+	return &OpExpr{Opname: a.Name, Args: []Expr{left, right}, ResultType: catalog.BOOLOID, Location: a.Location}, nil
+}
+
+// transformBoolExpr は AND、OR、NOT を解析する (transformBoolExpr 相当)。引数は boolean でなければならない。
+func (ps *ParseState) transformBoolExpr(b *BoolExpr) (Expr, error) {
+	opname := map[BoolExprType]string{AndExpr: "AND", OrExpr: "OR", NotExpr: "NOT"}[b.Op]
+	args := make([]Expr, len(b.Args))
+	for i, a := range b.Args {
+		arg, err := ps.transformExpr(a)
+		if err != nil {
+			return nil, err
+		}
+		if arg, err = ps.coerceToBoolean(arg, opname, b.Location); err != nil {
+			return nil, err
+		}
+		args[i] = arg
+	}
+	return &BoolOpExpr{Op: b.Op, Args: args, Location: b.Location}, nil
+}
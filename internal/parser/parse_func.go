@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 関数呼び出しの解析 (parse_func.c 相当)
+// ----------------------------------------------------------------
+// 名前と引数の数が一致する関数のうち、引数の型が合うものを選ぶ。引数の型が全て一致する関数が
+// なければ、引数を暗黙に変換して呼べる関数を選ぶ。そのような関数が複数あればエラーにする。
+// 型未定の定数とパラメータは、選んだ関数の引数の型に合わせる。
+// 候補を型の分類や優先される型で絞り込む処理 (func_select_candidate) はまだ行わない。
+
+// transformFuncCall は関数呼び出しを FuncExpr に変換する (transformFuncCall, ParseFuncOrColumn 相当)
+func (ps *ParseState) transformFuncCall(fc *FuncCall) (Expr, error) {
+	args := make([]Expr, len(fc.Args))
+	for i, a := range fc.Args {
+		arg, err := ps.transformExpr(a)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = arg
+	}
+	name := fc.Funcname[len(fc.Funcname)-1]
+
+	var proc *catalog.FormPgProc
+	var coercible []*catalog.FormPgProc
+	candidates := catalog.FuncnameGetCandidates(name, len(args))
+	for _, cand := range candidates {
+		if argsMatch(args, cand.Proargtypes) {
+			proc = cand
+			break
+		}
+		if argsCoercible(args, cand.Proargtypes) {
+			coercible = append(coercible, cand)
+		}
+	}
+	if proc == nil {
+		switch len(coercible) {
+		case 0:
+			return nil, newError(errcodes.UndefinedFunction, "function %s(%s) does not exist", name, formatArgTypes(args))
+		case 1:
+			proc = coercible[0]
+		default:
+			return nil, newError(errcodes.AmbiguousFunction, "function %s(%s) is not unique", name, formatArgTypes(args))
+		}
+	}
+
+	for i, arg := range args {
+		coerced, err := ps.coerceType(arg, proc.Proargtypes[i], CoercionImplicit, fc.Location)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = coerced
+	}
+	return &FuncExpr{FuncID: proc.Oid, Args: args, ResultType: proc.Prorettype, Location: fc.Location}, nil
+}
+
+// argsMatch は引数の型が関数の引数の型と一致するか、型未定であるかを返す
+func argsMatch(args []Expr, argtypes []catalog.Oid) bool {
+	for i, arg := range args {
+		// ドメインの値は基の型の引数に渡せる
+		t := catalog.GetBaseType(arg.ExprType())
+		if t != argtypes[i] && t != catalog.UNKNOWNOID {
+			return false
+		}
+	}
+	return true
+}
+
+// argsCoercible は引数を関数の引数の型に暗黙に変換できるかを返す (func_match_argtypes 相当)
+func argsCoercible(args []Expr, argtypes []catalog.Oid) bool {
+	for i, arg := range args {
+		if !canCoerceType(arg.ExprType(), argtypes[i], CoercionImplicit) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatArgTypes はエラーメッセージ用に引数の型を並べる (funcname_signature_string 相当)
+func formatArgTypes(args []Expr) string {
+	names := make([]string, len(args))
+	for i, arg := range args {
+		names[i] = catalog.FormatType(arg.ExprType())
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 演算子の解析 (parse_oper.c 相当)
+// ----------------------------------------------------------------
+// CREATE OPERATOR で作った演算子から、名前と被演算子の型に合うものを選ぶ。被演算子の型が
+// 全て一致する演算子がなければ、被演算子を暗黙に変換して呼べる演算子を選び、そのような演算子が
+// 複数あればエラーにする。型未定の定数とパラメータは、二項演算子では他方の被演算子の型とみなす。
+//
+// 組み込みの演算子はまだ pg_operator の行を持たないため、ここで選べなければ
+// transformPrefixOp と transformComparisonOp が組み込みの演算子として解析する。
+
+// operSelect は名前と被演算子の型に合う演算子を選ぶ (oper, left_oper, oper_select_candidate 相当)。
+// 前置演算子は ltype に InvalidOid を渡す。合う演算子がなければ found に false を返す。
+func operSelect(name string, ltype, rtype catalog.Oid) (op catalog.FormPgOperator, found bool, err error) {
+	kind := catalog.OprkindBinary
+	if ltype == catalog.InvalidOid {
+		kind = catalog.OprkindPrefix
+	}
+	candidates := catalog.OpernameGetCandidates(name, kind)
+	if len(candidates) == 0 {
+		return catalog.FormPgOperator{}, false, nil
+	}
+
+	// 型未定の被演算子は他方の型とみなす (binary_oper_exact 相当)
+	l, r := ltype, rtype
+	if kind == catalog.OprkindBinary {
+		switch {
+		case l == catalog.UNKNOWNOID && r != catalog.UNKNOWNOID:
+			l = r
+		case r == catalog.UNKNOWNOID && l != catalog.UNKNOWNOID:
+			r = l
+		}
+	}
+	for _, cand := range candidates {
+		if cand.Oprleft == l && cand.Oprright == r {
+			return cand, true, nil
+		}
+	}
+	// ドメインの値は基の型の演算子で扱える
+	if bl, br := catalog.GetBaseType(l), catalog.GetBaseType(r); bl != l || br != r {
+		for _, cand := range candidates {
+			if cand.Oprleft == bl && cand.Oprright == br {
+				return cand, true, nil
+			}
+		}
+	}
+
+	var coercible []catalog.FormPgOperator
+	for _, cand := range candidates {
+		if (kind == catalog.OprkindPrefix || canCoerceType(ltype, cand.Oprleft, CoercionImplicit)) &&
+			canCoerceType(rtype, cand.Oprright, CoercionImplicit) {
+			coercible = append(coercible, cand)
+		}
+	}
+	switch len(coercible) {
+	case 0:
+		return catalog.FormPgOperator{}, false, nil
+	case 1:
+		return coercible[0], true, nil
+	}
+	// 型未定の被演算子は文字列型とみなせる演算子を優先する (func_select_candidate の一部相当)
+	var preferred []catalog.FormPgOperator
+	for _, cand := range coercible {
+		if (ltype != catalog.UNKNOWNOID || cand.Oprleft == catalog.TEXTOID) &&
+			(rtype != catalog.UNKNOWNOID || cand.Oprright == catalog.TEXTOID) {
+			preferred = append(preferred, cand)
+		}
+	}
+	if len(preferred) == 1 {
+		return preferred[0], true, nil
+	}
+	return catalog.FormPgOperator{}, false, withOperHint(newError(errcodes.AmbiguousFunction,
+		"operator is not unique: %s", opSignatureString(name, kind, ltype, rtype)),
+		"Could not choose a best candidate operator. You might need to add explicit type casts.")
+}
+
+// opSignatureString はエラーメッセージ用に演算子と被演算子の型を並べる (op_signature_string 相当)
+func opSignatureString(name string, kind byte, ltype, rtype catalog.Oid) string {
+	s := name + " " + catalog.FormatType(rtype)
+	if kind != catalog.OprkindPrefix {
+		s = catalog.FormatType(ltype) + " " + s
+	}
+	return s
+}
+
+// withOperHint は解析時のエラーにヒントを加える
+func withOperHint(err error, hint string) error {
+	err.(*SyntaxError).Hint = hint
+	return err
+}
+
+// makeOp は選んだ演算子の呼び出しを作る (make_op 相当)。被演算子は演算子の型に暗黙に変換する。
+// left は前置演算子では nil。
+func (ps *ParseState) makeOp(op catalog.FormPgOperator, left, right Expr, location int) (Expr, error) {
+	if op.Oprcode == catalog.InvalidOid {
+		ltype := catalog.InvalidOid
+		if left != nil {
+			ltype = left.ExprType()
+		}
+		return nil, newError(errcodes.UndefinedFunction, "operator is only a shell: %s",
+			opSignatureString(op.Oprname, op.Oprkind, ltype, right.ExprType()))
+	}
+	var args []Expr
+	if left != nil {
+		coerced, err := ps.coerceType(left, op.Oprleft, CoercionImplicit, location)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, coerced)
+	}
+	coerced, err := ps.coerceType(right, op.Oprright, CoercionImplicit, location)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, coerced)
+	return &OpExpr{Opno: op.Oid, Opfuncid: op.Oprcode, Opname: op.Oprname, Args: args,
+		ResultType: op.Oprresult, Location: location}, nil
+}
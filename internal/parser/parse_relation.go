@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// FROM 句と列の参照の解析 (parse_clause.c, parse_relation.c, parse_target.c 相当)
+// ----------------------------------------------------------------
+// FROM 句に書けるのはシステムビューと CREATE TABLE AS で作ったテーブルで、複数の
+// リレーションを書いた場合はそれらの直積になる。スキーマで修飾しない名前は、search_path と
+// 同じく pg_catalog のシステムビュー、public のテーブルの順に探す。
+
+// transformFromClause は FROM 句のリレーションを範囲テーブルに加える (transformFromClause 相当)
+func (ps *ParseState) transformFromClause(query *Query, from []*RangeVar) error {
+	for _, rv := range from {
+		rte := &RangeTblEntry{Inh: rv.Inh}
+		var ok bool
+		if rte.View, ok = catalog.RelnameGetSystemView(rv.Schemaname, rv.Relname); !ok {
+			if rte.Relation, ok = catalog.RelnameGetRelation(rv.Schemaname, rv.Relname); !ok {
+				if rv.Schemaname != "" {
+					return newError(errcodes.UndefinedTable, "relation \"%s.%s\" does not exist", rv.Schemaname, rv.Relname)
+				}
+				return newError(errcodes.UndefinedTable, "relation \"%s\" does not exist", rv.Relname)
+			}
+		}
+		rte.Eref = rv.Alias
+		if rte.Eref == "" {
+			rte.Eref = rv.Relname
+		}
+		// 同じ名前で参照するリレーションは1つまで (checkNameSpaceConflicts 相当)
+		for _, other := range query.RangeTable {
+			if other.Eref == rte.Eref {
+				return newError(errcodes.DuplicateAlias, "table name \"%s\" specified more than once", rte.Eref)
+			}
+		}
+		query.RangeTable = append(query.RangeTable, rte)
+	}
+	ps.rtable = query.RangeTable
+	return nil
+}
+
+// transformColumnRef は列の参照を解析する (transformColumnRef 相当)
+func (ps *ParseState) transformColumnRef(cref *ColumnRef) (Expr, error) {
+	var names []string
+	for _, f := range cref.Fields {
+		s, ok := f.(*String)
+		if !ok {
+			return nil, newError(errcodes.FeatureNotSupported, "row expansion via \"*\" is not supported here")
+		}
+		names = append(names, s.Sval)
+	}
+	if ps.domainValueType != catalog.InvalidOid && len(names) == 1 && names[0] == "value" {
+		return &CoerceToDomainValue{TypeID: ps.domainValueType, Location: cref.Location}, nil
+	}
+
+	switch len(names) {
+	case 1:
+		// 列名だけの場合は、全てのリレーションから探す (colNameToVar 相当)
+		var result *Var
+		for i, rte := range ps.rtable {
+			if v := scanRTEForColumn(rte, i+1, names[0], cref.Location); v != nil {
+				if result != nil {
+					return nil, newError(errcodes.AmbiguousColumn, "column reference \"%s\" is ambiguous", names[0])
+				}
+				result = v
+			}
+		}
+		if result == nil {
+			return nil, newError(errcodes.UndefinedColumn, "column \"%s\" does not exist", names[0])
+		}
+		return result, nil
+	case 2:
+		rtindex, rte := ps.refnameNamespaceItem(names[0])
+		if rte == nil {
+			return nil, newError(errcodes.UndefinedTable, "missing FROM-clause entry for table \"%s\"", names[0])
+		}
+		if v := scanRTEForColumn(rte, rtindex, names[1], cref.Location); v != nil {
+			return v, nil
+		}
+		return nil, newError(errcodes.UndefinedColumn, "column %s.%s does not exist", names[0], names[1])
+	}
+	return nil, newError(errcodes.SyntaxError, "improper qualified name (too many dotted names): %s", strings.Join(names, "."))
+}
+
+// refnameNamespaceItem は名前で参照するリレーションを探す (refnameNamespaceItem 相当)
+func (ps *ParseState) refnameNamespaceItem(refname string) (int, *RangeTblEntry) {
+	for i, rte := range ps.rtable {
+		if rte.Eref == refname {
+			return i + 1, rte
+		}
+	}
+	return 0, nil
+}
+
+// scanRTEForColumn はリレーションの列を名前で探す (scanNSItemForColumn 相当)。なければ nil を返す。
+func scanRTEForColumn(rte *RangeTblEntry, rtindex int, colname string, location int) *Var {
+	names, types := rte.columns()
+	for i, name := range names {
+		if name == colname {
+			return &Var{VarNo: rtindex, VarAttno: i + 1, VarType: types[i], Location: location}
+		}
+	}
+	return nil
+}
+
+// expandColumnRefStar は出力列の "*" と "名前.*" を、リレーションの全ての列に展開する
+// (ExpandColumnRefStar 相当)
+func (ps *ParseState) expandColumnRefStar(cref *ColumnRef) ([]*TargetEntry, error) {
+	var rtes []int
+	switch len(cref.Fields) {
+	case 1:
+		if len(ps.rtable) == 0 {
+			return nil, newError(errcodes.SyntaxError, "SELECT * with no tables specified is not valid")
+		}
+		for i := range ps.rtable {
+			rtes = append(rtes, i+1)
+		}
+	case 2:
+		refname := cref.Fields[0].(*String).Sval
+		rtindex, rte := ps.refnameNamespaceItem(refname)
+		if rte == nil {
+			return nil, newError(errcodes.UndefinedTable, "missing FROM-clause entry for table \"%s\"", refname)
+		}
+		rtes = append(rtes, rtindex)
+	default:
+		return nil, newError(errcodes.SyntaxError, "improper qualified name (too many dotted names)")
+	}
+
+	var tlist []*TargetEntry
+	for _, rtindex := range rtes {
+		names, types := ps.rtable[rtindex-1].columns()
+		for i, name := range names {
+			v := &Var{VarNo: rtindex, VarAttno: i + 1, VarType: types[i], Location: cref.Location}
+			tlist = append(tlist, &TargetEntry{Expr: v, ResName: name})
+		}
+	}
+	return tlist, nil
+}
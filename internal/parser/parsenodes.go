@@ -0,0 +1,606 @@
+package parser
+
+import "fmt"
+
+// ----------------------------------------------------------------
+// 構文木のノード (nodes/parsenodes.h, nodes/value.h 相当)
+// ----------------------------------------------------------------
+// C言語版では NodeTag によってノードの種類を区別するが、
+// Go言語版では各ノードを個別の構造体とし、型スイッチで区別する。
+
+// Node は構文木のノード。
+type Node any
+
+// RawStmt は1つの文と、その問い合わせ文字列中の位置 (RawStmt 相当)
+type RawStmt struct {
+	Stmt         Node
+	StmtLocation int
+	StmtLen      int
+}
+
+// ----------------------------------------------------------------
+// 値ノード (value.h 相当)
+// ----------------------------------------------------------------
+
+// Integer は int4 に収まる整数リテラル。
+type Integer struct {
+	Ival int32
+}
+
+// Float は小数リテラル、または int4 に収まらない整数リテラル。値は綴りのまま保持する。
+type Float struct {
+	Fval string
+}
+
+// String は文字列リテラル。
+type String struct {
+	Sval string
+}
+
+// Boolean は TRUE / FALSE リテラル。
+type Boolean struct {
+	Boolval bool
+}
+
+// ----------------------------------------------------------------
+// 式ノード
+// ----------------------------------------------------------------
+
+// AConst は定数 (A_Const 相当)。Val が nil の場合は NULL を表す。
+type AConst struct {
+	Val      Node
+	Location int
+}
+
+// AExprKind は演算子式の種類 (A_Expr_Kind 相当)
+type AExprKind int
+
+const (
+	AExprOp AExprKind = iota // 通常の演算子
+)
+
+// AExpr は演算子式 (A_Expr 相当)。前置演算子の場合 Lexpr は nil。
+type AExpr struct {
+	Kind     AExprKind
+	Name     string
+	Lexpr    Node
+	Rexpr    Node
+	Location int
+}
+
+// BoolExprType は論理演算の種類 (BoolExprType 相当)
+type BoolExprType int
+
+const (
+	AndExpr BoolExprType = iota
+	OrExpr
+	NotExpr
+)
+
+// BoolExpr は AND / OR / NOT (BoolExpr 相当)
+type BoolExpr struct {
+	Op       BoolExprType
+	Args     []Node
+	Location int
+}
+
+// TypeName は型名 (TypeName 相当)
+type TypeName struct {
+	Names   []string
+	Typmods []Node
+	// ArrayBounds は配列型の各次元の大きさ。大きさを書かない "[]" は -1。
+	ArrayBounds []int
+	Location    int
+}
+
+// TypeCast は CAST(x AS type) または x::type (TypeCast 相当)
+type TypeCast struct {
+	Arg      Node
+	TypeName *TypeName
+	Location int
+}
+
+// FuncCall は関数呼び出し (FuncCall 相当)
+type FuncCall struct {
+	Funcname []string
+	Args     []Node
+	Location int
+}
+
+// ColumnRef は列の参照 (ColumnRef 相当)。Fields の要素は String か、最後の要素に限り AStar。
+type ColumnRef struct {
+	Fields   []Node
+	Location int
+}
+
+// AStar は列の参照や出力列の "*" (A_Star 相当)
+type AStar struct{}
+
+// ParamRef は $n 形式のパラメータ参照 (ParamRef 相当)
+type ParamRef struct {
+	Number   int
+	Location int
+}
+
+// ResTarget は SELECT の出力列 (ResTarget 相当)
+type ResTarget struct {
+	Name     string
+	Val      Node
+	Location int
+}
+
+// ----------------------------------------------------------------
+// 文ノード
+// ----------------------------------------------------------------
+
+// RangeVar は FROM 句のリレーション名 (RangeVar 相当)。Alias が空の場合は別名を持たない。
+// Inh は継承した子のテーブルも対象にすることを表し、ONLY を書いた場合だけ false になる。
+type RangeVar struct {
+	Schemaname string
+	Relname    string
+	Inh        bool
+	Alias      string
+	Location   int
+}
+
+// SelectStmt は SELECT 文 (SelectStmt 相当)。IntoClause は SELECT INTO の INTO 句を持つ。
+type SelectStmt struct {
+	TargetList []*ResTarget
+	IntoClause *IntoClause
+	FromClause []*RangeVar
+}
+
+// IntoClause は CREATE TABLE AS と SELECT INTO で作るテーブル (IntoClause 相当)。
+// ColNames が空の場合は問い合わせの出力列の名前を使う。
+type IntoClause struct {
+	Rel      *RangeVar
+	ColNames []string
+	// Temp は一時テーブルを作ることを表す (relpersistence が RELPERSISTENCE_TEMP 相当)
+	Temp bool
+	// SkipData は WITH NO DATA で、行を入れずにテーブルだけを作ることを表す
+	SkipData bool
+}
+
+// CreateTableAsStmt は CREATE TABLE AS 文 (CreateTableAsStmt 相当)。SELECT INTO も
+// 意味解析でこの文に変換し、IsSelectInto で区別する。
+type CreateTableAsStmt struct {
+	Query        *SelectStmt
+	Into         *IntoClause
+	IsSelectInto bool
+	IfNotExists  bool
+	// Analyzed は意味解析した Query。C言語版は query を解析した Query に置き換えるが、構文木は
+	// 準備した文が持ち続けるため、transformCreateTableAsStmt が文の写しに設定する
+	Analyzed *Query
+}
+
+// ColumnDef は CREATE TABLE の列の定義 (ColumnDef 相当)
+type ColumnDef struct {
+	Colname  string
+	TypeName *TypeName
+	Location int
+}
+
+// CreateStmt は CREATE TABLE 文 (CreateStmt 相当)。InhRelations は INHERITS 句の親のテーブル。
+type CreateStmt struct {
+	Relation     *RangeVar
+	TableElts    []*ColumnDef
+	InhRelations []*RangeVar
+	// Temp は一時テーブルを作ることを表す (relpersistence が RELPERSISTENCE_TEMP 相当)
+	Temp        bool
+	IfNotExists bool
+}
+
+// AlterTableType は ALTER TABLE の動作の種類 (AlterTableType 相当)
+type AlterTableType int
+
+const (
+	// ATAddInherit は INHERIT parent
+	ATAddInherit AlterTableType = iota
+	// ATDropInherit は NO INHERIT parent
+	ATDropInherit
+)
+
+// AlterTableCmd は ALTER TABLE の動作1つ (AlterTableCmd 相当)。Parent は INHERIT と NO INHERIT の
+// 親のテーブル。
+type AlterTableCmd struct {
+	Subtype AlterTableType
+	Parent  *RangeVar
+}
+
+// AlterTableStmt は ALTER TABLE 文 (AlterTableStmt 相当)
+type AlterTableStmt struct {
+	Relation  *RangeVar
+	Cmds      []*AlterTableCmd
+	MissingOk bool
+}
+
+// ConstrType は制約の種類 (ConstrType 相当)
+type ConstrType int
+
+const (
+	ConstrNull    ConstrType = iota // NULL (NULL を許す)
+	ConstrNotNull                   // NOT NULL
+	ConstrDefault                   // DEFAULT
+	ConstrCheck                     // CHECK
+)
+
+// Constraint は CREATE DOMAIN と ALTER DOMAIN の制約 (Constraint 相当)。Conname は CONSTRAINT で
+// 付けた名前で、なければ空。RawExpr は DEFAULT と CHECK の式。
+type Constraint struct {
+	Contype ConstrType
+	Conname string
+	RawExpr Node
+	// SkipValidation は NOT VALID で、既存の値を確かめないことを表す
+	SkipValidation bool
+	Location       int
+}
+
+// CreateDomainStmt は CREATE DOMAIN 文 (CreateDomainStmt 相当)
+type CreateDomainStmt struct {
+	Domainname  []string
+	TypeName    *TypeName
+	Constraints []*Constraint
+}
+
+// AlterDomainType は ALTER DOMAIN の操作の種類 (AlterDomainStmt の subtype 相当)
+type AlterDomainType int
+
+const (
+	AlterDomainDefault            AlterDomainType = iota // SET DEFAULT, DROP DEFAULT
+	AlterDomainDropNotNull                               // DROP NOT NULL
+	AlterDomainSetNotNull                                // SET NOT NULL
+	AlterDomainAddConstraint                             // ADD CONSTRAINT
+	AlterDomainDropConstraint                            // DROP CONSTRAINT
+	AlterDomainValidateConstraint                        // VALIDATE CONSTRAINT
+)
+
+// DropBehavior は依存するオブジェクトがある場合の動作 (DropBehavior 相当)
+type DropBehavior int
+
+const (
+	DropRestrict DropBehavior = iota // 依存するオブジェクトがあればエラーにする
+	DropCascade                      // 依存するオブジェクトも削除する
+)
+
+// AlterDomainStmt は ALTER DOMAIN 文のうち、既定値と制約を変更するもの (AlterDomainStmt 相当)。
+// Name は制約の名前、Def は既定値の式 (DROP DEFAULT では nil) か、加える Constraint。
+type AlterDomainStmt struct {
+	Subtype   AlterDomainType
+	TypeName  []string
+	Name      string
+	Def       Node
+	Behavior  DropBehavior
+	MissingOk bool
+}
+
+// ObjectType は DROP などで対象にするオブジェクトの種類 (ObjectType 相当)
+type ObjectType int
+
+const (
+	ObjectCast ObjectType = iota
+	ObjectDomain
+	ObjectDomconstraint
+	ObjectFunction
+	ObjectOpclass
+	ObjectOperator
+	ObjectOpfamily
+	ObjectProcedure
+	ObjectRoutine
+	ObjectTable
+)
+
+// DropStmt は DROP 文 (DropStmt 相当)。Objects の要素は、テーブルとドメインでは名前 ([]string)、
+// キャストでは変換元と変換先の型 ([]*TypeName)、演算子では名前と被演算子の型 (*ObjectWithArgs)、
+// 演算子クラスと演算子族ではアクセスメソッドの名前に続けた名前 ([]string)。
+type DropStmt struct {
+	Objects    []Node
+	RemoveType ObjectType
+	Behavior   DropBehavior
+	MissingOk  bool
+}
+
+// RenameStmt は ALTER DOMAIN の RENAME TO と RENAME CONSTRAINT、ALTER OPERATOR CLASS と
+// ALTER OPERATOR FAMILY の RENAME TO (RenameStmt 相当)。Subname は名前を変える制約で、それ以外では空。
+// 演算子クラスと演算子族の Object は、DropStmt と同じくアクセスメソッドの名前に続けた名前。
+type RenameStmt struct {
+	RenameType ObjectType
+	Object     []string
+	Subname    string
+	Newname    string
+}
+
+// AlterOwnerStmt は ALTER DOMAIN、ALTER OPERATOR、ALTER OPERATOR CLASS、ALTER OPERATOR FAMILY の
+// OWNER TO (AlterOwnerStmt 相当)。Object は DropStmt の Objects の要素と同じ形で持つ。
+type AlterOwnerStmt struct {
+	ObjectType ObjectType
+	Object     Node
+	NewOwner   *RoleSpec
+}
+
+// ObjectWithArgs は関数か演算子の名前と引数の型 (ObjectWithArgs 相当)。ArgsUnspecified は引数の型の
+// 並びを書かなかったことを表し、その名前の関数が1つだけであればそれを指す。演算子の Objargs は
+// 左右の被演算子の型で、NONE と書いた側は nil。
+type ObjectWithArgs struct {
+	Objname         []string
+	Objargs         []*TypeName
+	ArgsUnspecified bool
+}
+
+// AlterFunctionStmt は ALTER FUNCTION、ALTER PROCEDURE、ALTER ROUTINE 文 (AlterFunctionStmt 相当)。
+// Objtype は ObjectFunction、ObjectProcedure、ObjectRoutine のいずれか。Actions は SECURITY DEFINER
+// と SECURITY INVOKER の "security" (Boolean) と、SET 句と RESET 句の "set" (*VariableSetStmt)。
+type AlterFunctionStmt struct {
+	Objtype ObjectType
+	Func    *ObjectWithArgs
+	Actions []*DefElem
+}
+
+// CreateCastStmt は CREATE CAST 文 (CreateCastStmt 相当)。Func は WITH FUNCTION の関数で、
+// WITHOUT FUNCTION と WITH INOUT では nil。
+type CreateCastStmt struct {
+	Sourcetype *TypeName
+	Targettype *TypeName
+	Func       *ObjectWithArgs
+	Context    CoercionContext
+	Inout      bool
+}
+
+// DefineStmt は CREATE OPERATOR 文 (DefineStmt 相当)。Definition の値は、型名と関数名では TypeName、
+// 演算子名では []string、NONE と文字列では String、数値では Integer か Float。
+type DefineStmt struct {
+	Kind       ObjectType
+	Defnames   []string
+	Definition []*DefElem
+}
+
+// AlterOperatorStmt は ALTER OPERATOR ... SET 文 (AlterOperatorStmt 相当)。Options の値は
+// DefineStmt と同じで、NONE と書いた場合は nil。
+type AlterOperatorStmt struct {
+	Opername *ObjectWithArgs
+	Options  []*DefElem
+}
+
+// OpclassItemType は演算子クラスの要素の種類 (OPCLASS_ITEM_* 相当)
+type OpclassItemType int
+
+const (
+	OpclassItemOperator    OpclassItemType = iota // OPERATOR strategy_number operator
+	OpclassItemFunction                           // FUNCTION support_number function
+	OpclassItemStorageType                        // STORAGE type
+)
+
+// CreateOpClassItem は CREATE OPERATOR CLASS と ALTER OPERATOR FAMILY の要素 (CreateOpClassItem 相当)。
+// Name は演算子か関数の名前と引数の型で、ALTER OPERATOR FAMILY ... DROP では nil。
+// ClassArgs は OPERATOR と FUNCTION の後に括弧で書いた左右の型で、書かなければ nil。
+type CreateOpClassItem struct {
+	Itemtype OpclassItemType
+	Name     *ObjectWithArgs
+	Number   int
+	// OrderFamily は FOR ORDER BY の演算子族の名前。FOR SEARCH か省略した場合は nil
+	OrderFamily []string
+	ClassArgs   []*TypeName
+	Storedtype  *TypeName
+	Location    int
+}
+
+// CreateOpClassStmt は CREATE OPERATOR CLASS 文 (CreateOpClassStmt 相当)。Opfamilyname は FAMILY で
+// 指定した演算子族で、書かなければ nil。
+type CreateOpClassStmt struct {
+	Opclassname  []string
+	Opfamilyname []string
+	Amname       string
+	Datatype     *TypeName
+	Items        []*CreateOpClassItem
+	IsDefault    bool
+}
+
+// CreateOpFamilyStmt は CREATE OPERATOR FAMILY 文 (CreateOpFamilyStmt 相当)
+type CreateOpFamilyStmt struct {
+	Opfamilyname []string
+	Amname       string
+}
+
+// AlterOpFamilyStmt は ALTER OPERATOR FAMILY ... ADD と DROP (AlterOpFamilyStmt 相当)
+type AlterOpFamilyStmt struct {
+	Opfamilyname []string
+	Amname       string
+	IsDrop       bool
+	Items        []*CreateOpClassItem
+}
+
+// DefElem は "名前 値" の形のオプション (DefElem 相当)。Arg が nil の場合は値を持たない。
+type DefElem struct {
+	Defname  string
+	Arg      Node
+	Location int
+}
+
+// RoleSpecType はロールの指定の種類 (RoleSpecType 相当)
+type RoleSpecType int
+
+const (
+	RoleSpecCString     RoleSpecType = iota // ロール名
+	RoleSpecCurrentRole                     // CURRENT_ROLE
+	RoleSpecCurrentUser                     // CURRENT_USER
+	RoleSpecSessionUser                     // SESSION_USER
+	RoleSpecPublic                          // PUBLIC
+)
+
+// RoleSpec はロールの指定 (RoleSpec 相当)。Rolename は RoleSpecCString の場合だけ使う。
+type RoleSpec struct {
+	RoleType RoleSpecType
+	Rolename string
+	Location int
+}
+
+// RoleStmtType は CREATE ROLE / USER / GROUP の区別 (RoleStmtType 相当)
+type RoleStmtType int
+
+const (
+	RoleStmtRole RoleStmtType = iota
+	RoleStmtUser
+	RoleStmtGroup
+)
+
+// CreateRoleStmt は CREATE ROLE / USER / GROUP 文 (CreateRoleStmt 相当)
+type CreateRoleStmt struct {
+	StmtType RoleStmtType
+	Role     string
+	Options  []*DefElem
+}
+
+// DropRoleStmt は DROP ROLE / USER / GROUP 文 (DropRoleStmt 相当)
+type DropRoleStmt struct {
+	Roles     []*RoleSpec
+	MissingOk bool
+}
+
+// AlterRoleStmt は ALTER ROLE / ALTER USER 文 (AlterRoleStmt 相当)
+type AlterRoleStmt struct {
+	Role    *RoleSpec
+	Options []*DefElem
+}
+
+// AlterRoleSetStmt は ALTER ROLE / ALTER USER の SET と RESET (AlterRoleSetStmt 相当)。
+// Role が nil の場合は ALL (全てのロール)、Database が空の場合は全てのデータベースへの設定。
+type AlterRoleSetStmt struct {
+	Role     *RoleSpec
+	Database string
+	Setstmt  *VariableSetStmt
+}
+
+// GrantObjectType は GRANT と REVOKE で対象にするオブジェクトの種類 (ObjectType のうち
+// defacl_privilege_target 相当)
+type GrantObjectType int
+
+const (
+	GrantTables GrantObjectType = iota
+	GrantSequences
+	GrantFunctions
+	GrantRoutines
+	GrantTypes
+	GrantSchemas
+)
+
+// AccessPriv は付与する権限1つ (AccessPriv 相当)。PrivName は小文字の権限の名前で、Cols は
+// 列の並び。
+type AccessPriv struct {
+	PrivName string
+	Cols     []string
+	Location int
+}
+
+// GrantStmt は GRANT と REVOKE (GrantStmt 相当)。ALTER DEFAULT PRIVILEGES の中でだけ使う。
+// Privileges が nil の場合は ALL PRIVILEGES。Grantees の PUBLIC は RoleSpecPublic で表す。
+// GrantOption は GRANT では WITH GRANT OPTION、REVOKE では GRANT OPTION FOR を表す。
+type GrantStmt struct {
+	IsGrant     bool
+	Objtype     GrantObjectType
+	Privileges  []*AccessPriv
+	Grantees    []*RoleSpec
+	GrantOption bool
+	Behavior    DropBehavior
+}
+
+// AlterDefaultPrivilegesStmt は ALTER DEFAULT PRIVILEGES 文 (AlterDefaultPrivilegesStmt 相当)。
+// Options は IN SCHEMA の "schemas" ([]string) と FOR ROLE の "roles" ([]*RoleSpec)。
+type AlterDefaultPrivilegesStmt struct {
+	Options []*DefElem
+	Action  *GrantStmt
+}
+
+// AlterDatabaseSetStmt は ALTER DATABASE の SET と RESET (AlterDatabaseSetStmt 相当)
+type AlterDatabaseSetStmt struct {
+	Dbname  string
+	Setstmt *VariableSetStmt
+}
+
+// VariableSetKind は SET / RESET の種類 (VariableSetKind 相当)
+type VariableSetKind int
+
+const (
+	VarSetValue   VariableSetKind = iota // SET var = value
+	VarSetDefault                        // SET var TO DEFAULT
+	VarReset                             // RESET var
+	VarResetAll                          // RESET ALL
+	VarSetMulti                          // SET TRANSACTION など、複数のパラメータをまとめて設定する特別な形
+	VarSetCurrent                        // SET var FROM CURRENT (関数の SET 句でだけ使う)
+)
+
+// VariableSetStmt は SET 文と RESET 文 (VariableSetStmt 相当)。Args の要素は AConst。
+// VarSetMulti の場合、Name は "TRANSACTION" か "SESSION CHARACTERISTICS" で、
+// Options にトランザクションの特性を持つ。
+type VariableSetStmt struct {
+	Kind    VariableSetKind
+	Name    string
+	Args    []*AConst
+	Options []*DefElem
+	IsLocal bool
+}
+
+// TransactionStmtKind はトランザクションを制御する文の種類 (TransactionStmtKind 相当)
+type TransactionStmtKind int
+
+const (
+	TransStmtBegin            TransactionStmtKind = iota // BEGIN
+	TransStmtStart                                       // START TRANSACTION
+	TransStmtCommit                                      // COMMIT, END
+	TransStmtRollback                                    // ROLLBACK, ABORT
+	TransStmtSavepoint                                   // SAVEPOINT
+	TransStmtRelease                                     // RELEASE
+	TransStmtRollbackTo                                  // ROLLBACK TO
+	TransStmtPrepare                                     // PREPARE TRANSACTION
+	TransStmtCommitPrepared                              // COMMIT PREPARED
+	TransStmtRollbackPrepared                            // ROLLBACK PREPARED
+)
+
+// TransactionStmt はトランザクションを制御する文 (TransactionStmt 相当)。Options の DefElem は
+// transaction_isolation, transaction_read_only, transaction_deferrable のいずれかで、値は AConst。
+type TransactionStmt struct {
+	Kind    TransactionStmtKind
+	Options []*DefElem
+	// SavepointName は SAVEPOINT、RELEASE、ROLLBACK TO のセーブポイントの名前
+	SavepointName string
+	// Gid は PREPARE TRANSACTION、COMMIT PREPARED、ROLLBACK PREPARED のトランザクションの識別子
+	Gid string
+	// Chain は COMMIT と ROLLBACK に AND CHAIN を書いたこと
+	Chain bool
+}
+
+// CheckPointStmt は CHECKPOINT 文 (CheckPointStmt 相当)
+type CheckPointStmt struct{}
+
+// AlterSystemStmt は ALTER SYSTEM 文 (AlterSystemStmt 相当)。Setstmt の IsLocal は常に false。
+type AlterSystemStmt struct {
+	Setstmt *VariableSetStmt
+}
+
+// VariableShowStmt は SHOW 文 (VariableShowStmt 相当)
+type VariableShowStmt struct {
+	Name string
+}
+
+// ----------------------------------------------------------------
+// 構文エラー
+// ----------------------------------------------------------------
+
+// SyntaxError は構文解析時のエラー。Position は問い合わせ文字列中のバイト位置 (不明な場合は -1)。
+// Code が空の場合の SQLSTATE は 42601 (syntax_error)。
+type SyntaxError struct {
+	Message  string
+	Position int
+	Code     string
+	Hint     string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Position < 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s at position %d", e.Message, e.Position)
+}
+
+// newError は位置のわからない、SQLSTATE が code の解析時のエラーを作る
+func newError(code string, format string, args ...any) error {
+	return &SyntaxError{Message: fmt.Sprintf(format, args...), Position: -1, Code: code}
+}
@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// 解析済みの式ノード (nodes/primnodes.h 相当)
+// ----------------------------------------------------------------
+// 構文木 (AConst, AExpr など) を解析 (parse analysis) した結果で、
+// 全てのノードに型が確定している。実行器はこちらを評価する。
+
+// Expr は型の確定した式。
+type Expr interface {
+	// ExprType は式の結果の型を返す (exprType 相当)
+	ExprType() catalog.Oid
+}
+
+// Const は定数 (Const 相当)。ConstValue が nil の場合は NULL を表す。
+type Const struct {
+	ConstType  catalog.Oid
+	ConstValue adt.Datum
+	Location   int
+}
+
+func (c *Const) ExprType() catalog.Oid { return c.ConstType }
+
+// Param は $n で参照される外部パラメータ (Param / PARAM_EXTERN 相当)。ParamID は1始まり。
+type Param struct {
+	ParamID   int
+	ParamType catalog.Oid
+	Location  int
+}
+
+func (p *Param) ExprType() catalog.Oid { return p.ParamType }
+
+// Var はリレーションの列の参照 (Var 相当)。VarNo は範囲テーブルでの位置、VarAttno は列の番号で、
+// いずれも1始まり。
+type Var struct {
+	VarNo    int
+	VarAttno int
+	VarType  catalog.Oid
+	Location int
+}
+
+func (v *Var) ExprType() catalog.Oid { return v.VarType }
+
+// CoercionContext は型変換を行う場面 (CoercionContext 相当)。値が大きいほど多くの変換を許す。
+type CoercionContext int
+
+const (
+	// CoercionImplicit は関数の引数や演算子の被演算子の暗黙の変換
+	CoercionImplicit CoercionContext = iota
+	// CoercionAssignment は列への代入の変換
+	CoercionAssignment
+	// CoercionExplicit は CAST と :: による明示的な変換
+	CoercionExplicit
+)
+
+// CoerceViaIO は変換元の出力関数と変換先の入力関数による型変換 (CoerceViaIO 相当)
+type CoerceViaIO struct {
+	Arg        Expr
+	ResultType catalog.Oid
+	Location   int
+}
+
+func (c *CoerceViaIO) ExprType() catalog.Oid { return c.ResultType }
+
+// FuncExpr は関数の呼び出し (FuncExpr 相当)
+type FuncExpr struct {
+	FuncID     catalog.Oid
+	Args       []Expr
+	ResultType catalog.Oid
+	Location   int
+}
+
+func (f *FuncExpr) ExprType() catalog.Oid { return f.ResultType }
+
+// OpExpr は演算子の呼び出し (OpExpr 相当)。Opno と Opfuncid は CREATE OPERATOR で作った演算子と
+// その関数で、組み込みの演算子は pg_operator の行をまだ持たないため InvalidOid になる。
+type OpExpr struct {
+	Opno       catalog.Oid
+	Opfuncid   catalog.Oid
+	Opname     string
+	Args       []Expr
+	ResultType catalog.Oid
+	Location   int
+}
+
+func (o *OpExpr) ExprType() catalog.Oid { return o.ResultType }
+
+// BoolOpExpr は AND / OR / NOT (primnodes.h の BoolExpr 相当)。構文木の BoolExpr と区別するため
+// 名前を変えている。
+type BoolOpExpr struct {
+	Op       BoolExprType
+	Args     []Expr
+	Location int
+}
+
+func (b *BoolOpExpr) ExprType() catalog.Oid { return catalog.BOOLOID }
+
+// RelabelType は値をそのままに型だけを変える変換 (RelabelType 相当)。ドメインの値を基の型として
+// 扱う場合と、内部表現が同じ型の間の変換 (castmethod 'b') に使う。
+type RelabelType struct {
+	Arg        Expr
+	ResultType catalog.Oid
+}
+
+func (r *RelabelType) ExprType() catalog.Oid { return r.ResultType }
+
+// CoerceToDomain は基の型の値をドメインの値にする (CoerceToDomain 相当)。Arg の型は
+// ドメインの基の型で、実行時にドメインの制約を確かめる。
+type CoerceToDomain struct {
+	Arg        Expr
+	ResultType catalog.Oid
+	Location   int
+}
+
+func (c *CoerceToDomain) ExprType() catalog.Oid { return c.ResultType }
+
+// CoerceToDomainValue はドメインの CHECK 制約の式で、確かめる値を表す VALUE (CoerceToDomainValue 相当)。
+// TypeID はドメインの基の型。
+type CoerceToDomainValue struct {
+	TypeID   catalog.Oid
+	Location int
+}
+
+func (c *CoerceToDomainValue) ExprType() catalog.Oid { return c.TypeID }
+
+// TargetEntry は出力列1つ分 (TargetEntry 相当)
+type TargetEntry struct {
+	Expr    Expr
+	ResNo   int
+	ResName string
+}
+
+// CmdType は文の種類 (CmdType 相当)
+type CmdType int
+
+const (
+	CmdSelect CmdType = iota
+	CmdUtility
+)
+
+// RangeTblEntry は FROM 句のリレーション1つ分 (RangeTblEntry 相当)。システムビューなら View に、
+// テーブルなら Relation にその定義を持ち、もう一方は nil になる。
+type RangeTblEntry struct {
+	// Eref はリレーションを参照する名前 (別名があれば別名)
+	Eref     string
+	View     *catalog.SystemView
+	Relation *catalog.FormPgClass
+	// Inh は継承した子のテーブルも走査することを表す (inh 相当)。子は実行器が走査の前に探す。
+	// システムビューには子がないため意味を持たない
+	Inh bool
+}
+
+// columns はリレーションの列の名前と型を返す (expandRTE 相当)
+func (rte *RangeTblEntry) columns() (names []string, types []catalog.Oid) {
+	if rte.Relation != nil {
+		for _, attr := range rte.Relation.Attrs {
+			names = append(names, attr.Attname)
+			types = append(types, attr.Atttypid)
+		}
+		return names, types
+	}
+	for _, attr := range rte.View.Attrs {
+		names = append(names, attr.Name)
+		types = append(types, attr.TypeID)
+	}
+	return names, types
+}
+
+// Query は解析済みの文 (Query 相当)
+type Query struct {
+	CommandType CmdType
+	// RangeTable は FROM 句のリレーション。Var の VarNo で参照する
+	RangeTable []*RangeTblEntry
+	TargetList []*TargetEntry
+	// UtilityStmt はユーティリティ文 (CmdUtility の場合) の構文木
+	UtilityStmt Node
+	// QueryId は問い合わせ ID (queryId 相当)。計算していなければ 0
+	QueryId int64
+	// StmtLocation と StmtLen は問い合わせの文字列の中の文の位置と長さ (stmt_location, stmt_len 相当)。
+	// StmtLen が 0 の場合は文字列の終わりまでを表す
+	StmtLocation int
+	StmtLen      int
+}
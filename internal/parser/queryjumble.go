@@ -0,0 +1,172 @@
+package parser
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+)
+
+// ----------------------------------------------------------------
+// 問い合わせ ID の計算 (nodes/queryjumblefuncs.c 相当)
+// ----------------------------------------------------------------
+// 解析済みの文の木から問い合わせ ID を計算する。定数は型と位置だけを ID に含め、値は含めない。
+// このため、定数の値だけが異なる文は同じ ID になる。列の別名 (ResName) も含めない。
+//
+// ユーティリティ文は構文木のまま実行するため、C言語版の PostgreSQL 15 までと同じく、
+// 文の文字列から ID を計算する。
+//
+// compute_query_id が auto の場合は、EnableQueryId を呼んだモジュールがある場合だけ計算する。
+
+// queryIDEnabled は compute_query_id が auto の場合に ID を計算するか (query_id_enabled 相当)
+var queryIDEnabled atomic.Bool
+
+// EnableQueryId は compute_query_id が auto の場合にも問い合わせ ID を計算させる (EnableQueryId 相当)。
+// 問い合わせ ID を使うモジュールが読み込まれたときに呼ぶ。
+func EnableQueryId() {
+	queryIDEnabled.Store(true)
+}
+
+// IsQueryIdEnabled は compute_query_id の値が computeQueryID の場合に問い合わせ ID を計算するかを返す
+// (IsQueryIdEnabled 相当)
+func IsQueryIdEnabled(computeQueryID string) bool {
+	switch computeQueryID {
+	case "on", "regress":
+		return true
+	case "auto":
+		return queryIDEnabled.Load()
+	}
+	return false
+}
+
+// jumbleState は問い合わせ ID の計算の途中の状態 (JumbleState 相当)。C言語版はバイト列を
+// 一定の長さまで溜めてからハッシュ値にまとめるが、Go言語版は直接ハッシュ関数に与える。
+type jumbleState struct {
+	h   hash.Hash64
+	buf [8]byte
+}
+
+// JumbleQuery は文の問い合わせ ID を計算して query.QueryId に設定する (JumbleQuery 相当)。
+// queryString は文を含む問い合わせの文字列で、ユーティリティ文の ID の計算に使う。
+func JumbleQuery(query *Query, queryString string) {
+	js := &jumbleState{h: fnv.New64a()}
+	if query.CommandType == CmdUtility {
+		// 文の前後の空白は ID に含めない (compute_utility_query_id 相当)
+		text := queryString
+		if query.StmtLen > 0 && query.StmtLocation+query.StmtLen <= len(text) {
+			text = text[query.StmtLocation : query.StmtLocation+query.StmtLen]
+		} else if query.StmtLocation <= len(text) {
+			text = text[query.StmtLocation:]
+		}
+		js.appendString(strings.TrimSpace(text))
+	} else {
+		js.jumbleQuery(query)
+	}
+	query.QueryId = int64(js.h.Sum64())
+	// 0 は ID がないことを表すため使わない
+	if query.QueryId == 0 {
+		query.QueryId = 1
+	}
+}
+
+func (js *jumbleState) appendInt(v int64) {
+	binary.LittleEndian.PutUint64(js.buf[:], uint64(v))
+	js.h.Write(js.buf[:])
+}
+
+func (js *jumbleState) appendOid(oid catalog.Oid) {
+	js.appendInt(int64(oid))
+}
+
+// appendString は長さと共に文字列を加え、続く値との境目が曖昧にならないようにする
+func (js *jumbleState) appendString(s string) {
+	js.appendInt(int64(len(s)))
+	js.h.Write([]byte(s))
+}
+
+// jumbleQuery は解析済みの文を加える (_jumbleQuery 相当)
+func (js *jumbleState) jumbleQuery(query *Query) {
+	js.appendInt(int64(query.CommandType))
+	js.appendInt(int64(len(query.RangeTable)))
+	for _, rte := range query.RangeTable {
+		if rte.Relation != nil {
+			js.appendInt(int64(rte.Relation.Oid))
+		} else {
+			// システムビューには OID がないため、名前を加える
+			js.appendString(rte.View.Nspname)
+			js.appendString(rte.View.Relname)
+		}
+		if rte.Inh {
+			js.appendInt(1)
+		} else {
+			js.appendInt(0)
+		}
+	}
+	js.appendInt(int64(len(query.TargetList)))
+	for _, te := range query.TargetList {
+		js.appendInt(int64(te.ResNo))
+		js.jumbleExpr(te.Expr)
+	}
+}
+
+// jumbleExprs は式の並びを加える
+func (js *jumbleState) jumbleExprs(exprs []Expr) {
+	js.appendInt(int64(len(exprs)))
+	for _, e := range exprs {
+		js.jumbleExpr(e)
+	}
+}
+
+// jumbleExpr は式を加える (_jumbleNode 相当)。ノードの種類を先に加え、異なる種類のノードが
+// 同じバイト列にならないようにする。
+func (js *jumbleState) jumbleExpr(expr Expr) {
+	switch e := expr.(type) {
+	case *Const:
+		// 定数の値は加えない (_jumbleConst の query_jumble_location 相当)
+		js.appendString("Const")
+		js.appendOid(e.ConstType)
+	case *Param:
+		js.appendString("Param")
+		js.appendInt(int64(e.ParamID))
+		js.appendOid(e.ParamType)
+	case *Var:
+		js.appendString("Var")
+		js.appendInt(int64(e.VarNo))
+		js.appendInt(int64(e.VarAttno))
+	case *FuncExpr:
+		js.appendString("FuncExpr")
+		js.appendOid(e.FuncID)
+		js.jumbleExprs(e.Args)
+	case *OpExpr:
+		// 組み込みの演算子は OID をまだ持たないため、名前と結果の型も加える
+		js.appendString("OpExpr")
+		js.appendOid(e.Opno)
+		js.appendString(e.Opname)
+		js.appendOid(e.ResultType)
+		js.jumbleExprs(e.Args)
+	case *BoolOpExpr:
+		js.appendString("BoolExpr")
+		js.appendInt(int64(e.Op))
+		js.jumbleExprs(e.Args)
+	case *CoerceViaIO:
+		js.appendString("CoerceViaIO")
+		js.appendOid(e.ResultType)
+		js.jumbleExpr(e.Arg)
+	case *RelabelType:
+		js.appendString("RelabelType")
+		js.appendOid(e.ResultType)
+		js.jumbleExpr(e.Arg)
+	case *CoerceToDomain:
+		js.appendString("CoerceToDomain")
+		js.appendOid(e.ResultType)
+		js.jumbleExpr(e.Arg)
+	case *CoerceToDomainValue:
+		js.appendString("CoerceToDomainValue")
+		js.appendOid(e.TypeID)
+	case nil:
+		js.appendString("")
+	}
+}
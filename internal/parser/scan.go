@@ -0,0 +1,364 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// 字句解析 (scan.l 相当)
+// ----------------------------------------------------------------
+// C言語版は flex で生成した字句解析器を使うが、Go言語版では手書きで実装する。
+// 識別子は小文字に変換し (downcase_truncate_identifier 相当)、二重引用符付きの
+// 識別子はそのまま扱う。standard_conforming_strings = on を前提とし、
+// 通常の文字列リテラル中のバックスラッシュは特別扱いしない。
+
+// TokenKind はトークンの種類。
+type TokenKind int
+
+const (
+	EOF            TokenKind = iota
+	IDENT                    // 識別子またはキーワード
+	SCONST                   // 文字列リテラル
+	ICONST                   // 整数リテラル
+	FCONST                   // 小数リテラル (または int に収まらない整数)
+	PARAM                    // $1 などのパラメータ記号
+	Op                       // 演算子
+	TYPECAST                 // ::
+	COLON_EQUALS             // :=
+	EQUALS_GREATER           // =>
+	LESS_EQUALS              // <=
+	GREATER_EQUALS           // >=
+	NOT_EQUALS               // <> または !=
+	CHAR                     // 1文字の記号 ( , ( ) ; = など)
+)
+
+// Token は1つのトークン。
+type Token struct {
+	Kind TokenKind
+	// Str は識別子 (小文字化済み)、文字列リテラルの値、数値リテラルや演算子の綴り。
+	Str string
+	// Quoted は二重引用符付きの識別子であることを表す。キーワードとしては扱わない。
+	Quoted bool
+	// Loc, End は問い合わせ文字列中のトークンの開始・終了バイト位置。
+	Loc int
+	End int
+}
+
+// IsKeyword はトークンが指定したキーワードかどうかを返す。
+func (t Token) IsKeyword(kw string) bool {
+	return t.Kind == IDENT && !t.Quoted && t.Str == kw
+}
+
+// IsChar はトークンが指定した1文字の記号かどうかを返す。
+func (t Token) IsChar(c byte) bool {
+	return t.Kind == CHAR && t.Str[0] == c
+}
+
+var twoCharTokens = map[string]TokenKind{
+	"::": TYPECAST, ":=": COLON_EQUALS, "=>": EQUALS_GREATER,
+	"<=": LESS_EQUALS, ">=": GREATER_EQUALS, "<>": NOT_EQUALS, "!=": NOT_EQUALS,
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentCont(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// 演算子を構成する文字 (scan.l の op_chars)
+func isOpChar(c byte) bool {
+	return strings.IndexByte("~!@#^&|`?+-*/%<>=", c) >= 0
+}
+
+// 1文字で完結する記号 (scan.l の self)
+func isSelfChar(c byte) bool {
+	return strings.IndexByte(",()[].;:+-*/%^<>=", c) >= 0
+}
+
+func (l *lexer) errorf(loc int, format string, args ...any) error {
+	return &SyntaxError{Message: fmt.Sprintf(format, args...), Position: loc}
+}
+
+// skipWhitespace は空白とコメントを読み飛ばす。
+func (l *lexer) skipWhitespace() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case isSpace(c):
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "--"):
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			start := l.pos
+			depth := 0
+			for {
+				if l.pos >= len(l.src) {
+					return l.errorf(start, "unterminated /* comment")
+				}
+				if strings.HasPrefix(l.src[l.pos:], "/*") {
+					depth++
+					l.pos += 2
+				} else if strings.HasPrefix(l.src[l.pos:], "*/") {
+					depth--
+					l.pos += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					l.pos++
+				}
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// next は次のトークンを返す。
+func (l *lexer) next() (Token, error) {
+	tok, err := l.scan()
+	tok.End = l.pos
+	return tok, err
+}
+
+func (l *lexer) scan() (Token, error) {
+	if err := l.skipWhitespace(); err != nil {
+		return Token{}, err
+	}
+	if l.pos >= len(l.src) {
+		return Token{Kind: EOF, Loc: l.pos}, nil
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+
+	switch {
+	case (c == 'e' || c == 'E') && l.pos+1 < len(l.src) && l.src[l.pos+1] == '\'':
+		l.pos++
+		s, err := l.scanString(true)
+		if err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: SCONST, Str: s, Loc: start}, nil
+
+	case isIdentStart(c):
+		for l.pos < len(l.src) && isIdentCont(l.src[l.pos]) {
+			l.pos++
+		}
+		ident := strings.ToLower(l.src[start:l.pos])
+		return Token{Kind: IDENT, Str: ident, Loc: start}, nil
+
+	case c == '"':
+		l.pos++
+		var sb strings.Builder
+		for {
+			if l.pos >= len(l.src) {
+				return Token{}, l.errorf(start, "unterminated quoted identifier")
+			}
+			if l.src[l.pos] == '"' {
+				if l.pos+1 < len(l.src) && l.src[l.pos+1] == '"' {
+					sb.WriteByte('"')
+					l.pos += 2
+					continue
+				}
+				l.pos++
+				break
+			}
+			sb.WriteByte(l.src[l.pos])
+			l.pos++
+		}
+		if sb.Len() == 0 {
+			return Token{}, l.errorf(start, "zero-length delimited identifier")
+		}
+		return Token{Kind: IDENT, Str: sb.String(), Quoted: true, Loc: start}, nil
+
+	case c == '\'':
+		s, err := l.scanString(false)
+		if err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: SCONST, Str: s, Loc: start}, nil
+
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1])):
+		return l.scanNumber()
+
+	case c == '$' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1]):
+		l.pos++
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+		return Token{Kind: PARAM, Str: l.src[start+1 : l.pos], Loc: start}, nil
+	}
+
+	// 2文字の特殊記号
+	if l.pos+1 < len(l.src) {
+		two := l.src[l.pos : l.pos+2]
+		if k, ok := twoCharTokens[two]; ok {
+			// "<=" などの後ろにさらに演算子文字が続く場合は一般の演算子として扱う
+			if k == TYPECAST || k == COLON_EQUALS || l.pos+2 >= len(l.src) || !isOpChar(l.src[l.pos+2]) {
+				l.pos += 2
+				return Token{Kind: k, Str: two, Loc: start}, nil
+			}
+		}
+	}
+
+	if isOpChar(c) {
+		return l.scanOperator(), nil
+	}
+	if isSelfChar(c) {
+		l.pos++
+		return Token{Kind: CHAR, Str: string(c), Loc: start}, nil
+	}
+
+	return Token{}, l.errorf(start, "syntax error at or near \"%c\"", c)
+}
+
+// scanString は単一引用符で囲まれた文字列を読み取る。
+// escape が true の場合は E'...' 形式としてバックスラッシュエスケープを解釈する。
+func (l *lexer) scanString(escape bool) (string, error) {
+	start := l.pos
+	l.pos++ // 開始の '
+	var sb strings.Builder
+	for {
+		if l.pos >= len(l.src) {
+			return "", l.errorf(start, "unterminated quoted string")
+		}
+		c := l.src[l.pos]
+		if c == '\'' {
+			if l.pos+1 < len(l.src) && l.src[l.pos+1] == '\'' {
+				sb.WriteByte('\'')
+				l.pos += 2
+				continue
+			}
+			l.pos++
+			return sb.String(), nil
+		}
+		if escape && c == '\\' && l.pos+1 < len(l.src) {
+			l.pos++
+			switch e := l.src[l.pos]; e {
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(e)
+			}
+			l.pos++
+			continue
+		}
+		sb.WriteByte(c)
+		l.pos++
+	}
+}
+
+// scanNumber は数値リテラルを読み取る。int32 に収まらない整数は FCONST とする (process_integer_literal 相当)
+func (l *lexer) scanNumber() (Token, error) {
+	start := l.pos
+	isFloat := false
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' && !strings.HasPrefix(l.src[l.pos:], "..") {
+		isFloat = true
+		l.pos++
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		p := l.pos + 1
+		if p < len(l.src) && (l.src[p] == '+' || l.src[p] == '-') {
+			p++
+		}
+		if p < len(l.src) && isDigit(l.src[p]) {
+			isFloat = true
+			l.pos = p
+			for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+				l.pos++
+			}
+		}
+	}
+	if l.pos < len(l.src) && isIdentStart(l.src[l.pos]) {
+		return Token{}, l.errorf(start, "trailing junk after numeric literal at or near \"%s\"", l.src[start:l.pos+1])
+	}
+
+	text := l.src[start:l.pos]
+	if isFloat {
+		return Token{Kind: FCONST, Str: text, Loc: start}, nil
+	}
+	if !fitsInt32(text) {
+		return Token{Kind: FCONST, Str: text, Loc: start}, nil
+	}
+	return Token{Kind: ICONST, Str: text, Loc: start}, nil
+}
+
+func fitsInt32(digits string) bool {
+	digits = strings.TrimLeft(digits, "0")
+	if len(digits) < 10 {
+		return true
+	}
+	return len(digits) == 10 && digits <= "2147483647"
+}
+
+// scanOperator は演算子を読み取る。"--" や "/*" が現れたらそこで区切り、
+// 末尾の + / - は ~!@#%^&|`? を含まない限り切り離す (scan.l の operator 規則)。
+func (l *lexer) scanOperator() Token {
+	start := l.pos
+	end := l.pos
+	for end < len(l.src) && isOpChar(l.src[end]) {
+		if end > start && (strings.HasPrefix(l.src[end:], "--") || strings.HasPrefix(l.src[end:], "/*")) {
+			break
+		}
+		end++
+	}
+
+	op := l.src[start:end]
+	if len(op) > 1 && (op[len(op)-1] == '+' || op[len(op)-1] == '-') {
+		if !strings.ContainsAny(op, "~!@#%^&|`?") {
+			for len(op) > 1 && (op[len(op)-1] == '+' || op[len(op)-1] == '-') {
+				op = op[:len(op)-1]
+			}
+		}
+	}
+	l.pos = start + len(op)
+
+	if len(op) == 1 && isSelfChar(op[0]) {
+		return Token{Kind: CHAR, Str: op, Loc: start}
+	}
+	switch op {
+	case "<=":
+		return Token{Kind: LESS_EQUALS, Str: op, Loc: start}
+	case ">=":
+		return Token{Kind: GREATER_EQUALS, Str: op, Loc: start}
+	case "<>", "!=":
+		return Token{Kind: NOT_EQUALS, Str: op, Loc: start}
+	case "=>":
+		return Token{Kind: EQUALS_GREATER, Str: op, Loc: start}
+	}
+	return Token{Kind: Op, Str: op, Loc: start}
+}
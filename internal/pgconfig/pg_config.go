@@ -0,0 +1,34 @@
+package pgconfig
+
+// ----------------------------------------------------------------
+// ビルド時定数 (pg_config.h / pg_config_manual.h 相当)
+// ----------------------------------------------------------------
+// C言語版では configure が生成するヘッダに定義されているが、
+// Go言語版では定数として直接定義する。
+
+const (
+	// PgVersion はクライアントに ParameterStatus (server_version) として通知するバージョン。
+	PgVersion = "17.0"
+
+	// PgMajorVersion はデータディレクトリの PG_VERSION に書き込むメジャーバージョン。
+	PgMajorVersion = "17"
+
+	// PgVersionNum は server_version_num として通知する数値表現。
+	PgVersionNum = 170000
+
+	// DefPgPort は既定の待ち受けポート番号 (DEF_PGPORT 相当)
+	DefPgPort = 5432
+
+	// DefaultPgSocketDir は Unix ドメインソケットを作る既定のディレクトリ (DEFAULT_PGSOCKET_DIR 相当)
+	DefaultPgSocketDir = "/tmp"
+
+	// BlckSz はリレーションのページの大きさ (BLCKSZ 相当)
+	BlckSz = 8192
+
+	// RelsegSize はリレーションのファイルの1つのセグメントのブロックの数 (RELSEG_SIZE 相当)。
+	// 既定の BLCKSZ では 1GB になる
+	RelsegSize = 131072
+
+	// XLogBlckSz は WAL のページの大きさ (XLOG_BLCKSZ 相当)
+	XLogBlckSz = 8192
+)
@@ -0,0 +1,6 @@
+package platform
+
+import "errors"
+
+// ErrDupNotSupported はファイル記述子を複製できないことを表す
+var ErrDupNotSupported = errors.New("duplicating file descriptors is not supported on this platform")
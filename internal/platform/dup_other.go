@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd
+
+package platform
+
+// Dup はファイル記述子 fd を複製する (dup 相当)。この環境では複製できない。
+func Dup(fd int) (int, error) {
+	return -1, ErrDupNotSupported
+}
+
+// Dup2 は newfd を oldfd の複製にする (dup2 相当)。この環境では複製できない。
+func Dup2(oldfd, newfd int) error {
+	return ErrDupNotSupported
+}
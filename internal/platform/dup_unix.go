@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+
+package platform
+
+import "golang.org/x/sys/unix"
+
+// Dup はファイル記述子 fd を複製する (dup 相当)
+func Dup(fd int) (int, error) {
+	return unix.Dup(fd)
+}
+
+// Dup2 は newfd を oldfd の複製にする (dup2 相当)。標準エラー出力をパイプに繋ぎ替えるのに使う。
+func Dup2(oldfd, newfd int) error {
+	return unix.Dup2(oldfd, newfd)
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+
+package platform
+
+// DisableEcho は端末 fd への入力を画面に表示しないようにし、元に戻す関数を返す。
+// この環境では端末の属性を変更できないため、入力はそのまま表示される。
+func DisableEcho(fd int) (restore func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd
+
+package platform
+
+import "golang.org/x/sys/unix"
+
+// DisableEcho は端末 fd への入力を画面に表示しないようにし、元に戻す関数を返す。
+// パスワードの入力に使う (simple_prompt の ECHO を外す処理相当)。
+func DisableEcho(fd int) (restore func(), err error) {
+	t, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil, err
+	}
+	saved := *t
+	t.Lflag &^= unix.ECHO
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, t); err != nil {
+		return nil, err
+	}
+	return func() { _ = unix.IoctlSetTermios(fd, ioctlWriteTermios, &saved) }, nil
+}
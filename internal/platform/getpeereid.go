@@ -0,0 +1,6 @@
+package platform
+
+import "errors"
+
+// ErrPeerCredNotSupported は接続相手の資格情報を取得できないことを表す (getpeereid の ENOSYS 相当)
+var ErrPeerCredNotSupported = errors.New("peer credentials are not supported on this platform")
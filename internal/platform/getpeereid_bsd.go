@@ -0,0 +1,38 @@
+//go:build darwin || freebsd
+
+package platform
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// GetPeerEid は Unix ドメインソケットの接続相手の実効ユーザー ID とグループ ID を返す
+// (getpeereid 相当)。macOS と FreeBSD では LOCAL_PEERCRED で取得する。
+func GetPeerEid(conn net.Conn) (uid, gid int, err error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0, 0, ErrPeerCredNotSupported
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, 0, err
+	}
+	var cred *unix.Xucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	}); err != nil {
+		return 0, 0, err
+	}
+	if credErr != nil {
+		return 0, 0, credErr
+	}
+	gid = -1
+	if cred.Ngroups > 0 {
+		gid = int(cred.Groups[0])
+	}
+	return int(cred.Uid), gid, nil
+}
@@ -0,0 +1,34 @@
+//go:build linux
+
+package platform
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// GetPeerEid は Unix ドメインソケットの接続相手の実効ユーザー ID とグループ ID を返す
+// (getpeereid 相当)。Linux では SO_PEERCRED で取得する。
+func GetPeerEid(conn net.Conn) (uid, gid int, err error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0, 0, ErrPeerCredNotSupported
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, 0, err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return 0, 0, err
+	}
+	if credErr != nil {
+		return 0, 0, credErr
+	}
+	return int(cred.Uid), int(cred.Gid), nil
+}
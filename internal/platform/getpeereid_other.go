@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd
+
+package platform
+
+import "net"
+
+// GetPeerEid は Unix ドメインソケットの接続相手の実効ユーザー ID とグループ ID を返す
+// (getpeereid 相当)。このプラットフォームでは取得できない。
+func GetPeerEid(conn net.Conn) (uid, gid int, err error) {
+	return 0, 0, ErrPeerCredNotSupported
+}
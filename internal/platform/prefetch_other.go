@@ -0,0 +1,12 @@
+//go:build !linux && !freebsd
+
+package platform
+
+// PrefetchSupported はファイルの先読みを OS に頼めるかどうか (USE_PREFETCH 相当)
+const PrefetchSupported = false
+
+// Prefetch は fd の offset から length バイトをまもなく読むことを OS に伝える
+// (posix_fadvise の POSIX_FADV_WILLNEED 相当)。この環境では何もしない。
+func Prefetch(fd int, offset, length int64) error {
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build linux || freebsd
+
+package platform
+
+import "golang.org/x/sys/unix"
+
+// PrefetchSupported はファイルの先読みを OS に頼めるかどうか (USE_PREFETCH 相当)
+const PrefetchSupported = true
+
+// Prefetch は fd の offset から length バイトをまもなく読むことを OS に伝え、読み込みを
+// 始めさせる (posix_fadvise の POSIX_FADV_WILLNEED 相当)。読み終えるのは待たない。
+func Prefetch(fd int, offset, length int64) error {
+	return unix.Fadvise(fd, offset, length, unix.FADV_WILLNEED)
+}
@@ -0,0 +1,20 @@
+package platform
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// OSErrorMessage は OS のエラーのメッセージを、パスを除いて返す (%m 相当)
+func OSErrorMessage(err error) string {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return pe.Err.Error()
+	}
+	var le *os.LinkError
+	if errors.As(err, &le) {
+		return le.Err.Error()
+	}
+	return err.Error()
+}
@@ -0,0 +1,11 @@
+//go:build darwin || freebsd
+
+package platform
+
+import "golang.org/x/sys/unix"
+
+// 端末の属性を読み書きする ioctl の要求 (tcgetattr, tcsetattr 相当)
+const (
+	ioctlReadTermios  = unix.TIOCGETA
+	ioctlWriteTermios = unix.TIOCSETA
+)
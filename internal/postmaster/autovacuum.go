@@ -0,0 +1,475 @@
+package postmaster
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/commands"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// ----------------------------------------------------------------
+// autovacuum のワーカーのコストの配分 (postmaster/autovacuum.c の一部相当)
+// ----------------------------------------------------------------
+// ワーカーの枠と、ワーカーの間での vacuum_cost_limit の配分を持つ。ワーカーは AutoVacuumWorkerStart で
+// 枠を得て、テーブルを処理する前に SetTableCostParams でテーブルのストレージパラメータを伝え、
+// 終わったら Exit で枠を返す。
+//
+// ストレージパラメータで遅延も上限も指定していないテーブルを処理しているワーカーが配分の対象で、
+// autovacuum_vacuum_cost_limit (-1 なら vacuum_cost_limit) をその数で割った値をそれぞれの上限とする。
+// ストレージパラメータを指定したテーブルは、その値で他のワーカーと関係なく処理する。
+
+// avCostParamUnset はテーブルのストレージパラメータで指定していないことを表す
+const avCostParamUnset = -1
+
+// autoVacuumShmem は動いている全てのワーカー (AutoVacuumShmem 相当)
+var autoVacuumShmem struct {
+	sync.Mutex
+	// runningWorkers は動いているワーカー (av_runningWorkers 相当)
+	runningWorkers []*AutoVacWorker
+	// nworkersForBalance は上限を分け合うワーカーの数 (av_nworkersForBalance 相当)
+	nworkersForBalance int
+	// launcherWakeup はワーカーが終わったことをランチャーに知らせる (ワーカーがランチャーに送る SIGUSR2 相当)
+	launcherWakeup chan struct{}
+}
+
+// AutoVacWorker は autovacuum のワーカー1つ (WorkerInfoData 相当)
+type AutoVacWorker struct {
+	// DbOid は処理しているデータベース
+	DbOid catalog.Oid
+
+	// 以下は autoVacuumShmem のロックで守る
+	// dobalance は上限を他のワーカーと分け合うかどうか (wi_dobalance 相当)
+	dobalance bool
+	// storageParamCostDelay と storageParamCostLimit は処理中のテーブルのストレージパラメータ
+	// (av_storage_param_cost_delay、av_storage_param_cost_limit 相当)
+	storageParamCostDelay float64
+	storageParamCostLimit int
+	// tableoid は処理中のテーブル。処理していなければ InvalidOid (wi_tableoid 相当)
+	tableoid catalog.Oid
+
+	cost       *commands.VacuumCost
+	interrupts *miscadmin.Interrupts
+	// logProc はサーバーログに出すワーカーの情報
+	logProc *errutil.ProcInfo
+}
+
+// AutoVacuumWorkerStart はワーカーの枠を得る (AutoVacWorkerMain の MyWorkerInfo の設定相当)。
+// autovacuum_max_workers のワーカーが動いていればエラーを返す。
+func AutoVacuumWorkerStart(dbOid catalog.Oid, interrupts *miscadmin.Interrupts) (*AutoVacWorker, error) {
+	autoVacuumShmem.Lock()
+	if len(autoVacuumShmem.runningWorkers) >= guc.AutovacuumMaxWorkers.Get() {
+		autoVacuumShmem.Unlock()
+		return nil, fmt.Errorf("no free autovacuum worker slots")
+	}
+	w := &AutoVacWorker{
+		DbOid:                 dbOid,
+		dobalance:             true,
+		storageParamCostDelay: avCostParamUnset,
+		storageParamCostLimit: avCostParamUnset,
+		interrupts:            interrupts,
+		logProc:               errutil.NewAuxProcInfo("autovacuum worker"),
+	}
+	autoVacuumShmem.runningWorkers = append(autoVacuumShmem.runningWorkers, w)
+	autovacRecalculateWorkersForBalance()
+	autoVacuumShmem.Unlock()
+
+	w.cost = commands.NewVacuumCost(nil, w, interrupts)
+	return w, nil
+}
+
+// Exit はワーカーの枠を返し、残ったワーカーで上限を分け合い直す (FreeWorkerInfo 相当)
+func (w *AutoVacWorker) Exit() {
+	autoVacuumShmem.Lock()
+	defer autoVacuumShmem.Unlock()
+	if i := slices.Index(autoVacuumShmem.runningWorkers, w); i >= 0 {
+		autoVacuumShmem.runningWorkers = slices.Delete(autoVacuumShmem.runningWorkers, i, i+1)
+	}
+	autovacRecalculateWorkersForBalance()
+	if autoVacuumShmem.launcherWakeup != nil {
+		select {
+		case autoVacuumShmem.launcherWakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Cost はワーカーのコストの状態を返す。VACUUM はこれでページのコストを数え、休む。
+func (w *AutoVacWorker) Cost() *commands.VacuumCost {
+	return w.cost
+}
+
+// SetTableCostParams は次に処理するテーブルのストレージパラメータ autovacuum_vacuum_cost_delay と
+// autovacuum_vacuum_cost_limit を設定する (do_autovacuum の処理相当)。指定していないものは -1 にする。
+func (w *AutoVacWorker) SetTableCostParams(costDelay float64, costLimit int) {
+	autoVacuumShmem.Lock()
+	w.storageParamCostDelay = costDelay
+	w.storageParamCostLimit = costLimit
+	w.dobalance = costDelay < 0 && costLimit <= 0
+	autovacRecalculateWorkersForBalance()
+	autoVacuumShmem.Unlock()
+
+	w.cost.UpdateCosts()
+	w.cost.Balance = 0
+}
+
+// CostDelay は vacuum_cost_delay として使う値を返す。テーブルのストレージパラメータ、
+// autovacuum_vacuum_cost_delay、vacuum_cost_delay の順に、指定されたものを使う。
+func (w *AutoVacWorker) CostDelay() float64 {
+	autoVacuumShmem.Lock()
+	delay := w.storageParamCostDelay
+	autoVacuumShmem.Unlock()
+	if delay >= 0 {
+		return delay
+	}
+	if d := guc.AutovacuumVacuumCostDelay.Get(); d >= 0 {
+		return d
+	}
+	return guc.VacuumCostDelay.Get()
+}
+
+// CostLimit は vacuum_cost_limit として使う値を返す (AutoVacuumUpdateCostLimit 相当)。
+// 上限を分け合うワーカーでは、それらのワーカーの数で割る。
+func (w *AutoVacWorker) CostLimit() int {
+	autoVacuumShmem.Lock()
+	defer autoVacuumShmem.Unlock()
+	if w.storageParamCostLimit > 0 {
+		return w.storageParamCostLimit
+	}
+	limit := guc.AutovacuumVacuumCostLimit.Get()
+	if limit <= 0 {
+		limit = guc.VacuumCostLimit.Get()
+	}
+	if !w.dobalance || autoVacuumShmem.nworkersForBalance <= 0 {
+		return limit
+	}
+	return max(limit/autoVacuumShmem.nworkersForBalance, 1)
+}
+
+// autovacRecalculateWorkersForBalance は上限を分け合うワーカーの数を数え直す
+// (autovac_recalculate_workers_for_balance 相当)。呼び出し側がロックを持つ。
+func autovacRecalculateWorkersForBalance() {
+	n := 0
+	for _, w := range autoVacuumShmem.runningWorkers {
+		if w.dobalance {
+			n++
+		}
+	}
+	autoVacuumShmem.nworkersForBalance = n
+}
+
+// ----------------------------------------------------------------
+// autovacuum のランチャーとワーカー (postmaster/autovacuum.c の AutoVacLauncherMain、do_autovacuum 相当)
+// ----------------------------------------------------------------
+// ランチャーは autovacuum_naptime の間に全てのデータベースを一度ずつ訪れるよう、naptime を
+// データベースの数で割った間隔で、順にデータベースを選んでワーカーを起動する。ワーカーの枠
+// (autovacuum_max_workers) が全て使われていれば、ワーカーが終わるのを待つ。autovacuum が off の
+// 間は、ワーカーを起動せずに naptime ごとに設定を確かめる。
+//
+// ワーカーはデータベースのテーブルの統計を調べ、次の数を超えたテーブルを処理して終わる。
+//
+//   - 不要になった行の数が、autovacuum_vacuum_threshold + autovacuum_vacuum_scale_factor * reltuples
+//     を超えれば VACUUM する
+//   - 前回の VACUUM から挿入した行の数が、autovacuum_vacuum_insert_threshold +
+//     autovacuum_vacuum_insert_scale_factor * reltuples を超えれば VACUUM する
+//   - 前回の ANALYZE から変更した行の数が、autovacuum_analyze_threshold +
+//     autovacuum_analyze_scale_factor * reltuples を超えれば ANALYZE する
+//
+// テーブルのストレージパラメータで指定した値があれば、設定の値の代わりに使う。同じデータベースの
+// 他のワーカーが処理しているテーブルは飛ばし、処理する直前に統計を読み直す。
+//
+// テーブルとその統計はまだないため、統計の参照と VACUUM の実行は AutoVacRelations を実装した側が
+// 行い、StartAutoVacLauncher に渡す。
+
+// minAutovacSleeptime はランチャーがワーカーを起動する間隔の最小値 (MIN_AUTOVAC_SLEEPTIME 相当)
+const minAutovacSleeptime = 100 * time.Millisecond
+
+// AutoVacOpts はテーブルの autovacuum のストレージパラメータ (AutoVacOpts 相当)。
+// 指定していない値は -1 にする。
+type AutoVacOpts struct {
+	// Enabled は autovacuum_enabled。偽なら autovacuum はテーブルを処理しない
+	Enabled          bool
+	VacuumThreshold  int
+	AnalyzeThreshold int
+	// VacuumInsThreshold は、-1 なら挿入による VACUUM を行わず、-2 なら指定していない
+	VacuumInsThreshold   int
+	VacuumCostDelay      float64
+	VacuumCostLimit      int
+	VacuumScaleFactor    float64
+	VacuumInsScaleFactor float64
+	AnalyzeScaleFactor   float64
+}
+
+// AutoVacTable は autovacuum が調べるテーブル1つとその統計 (pg_class の行と PgStat_StatTabEntry の一部相当)
+type AutoVacTable struct {
+	Relid catalog.Oid
+	// Relname はエラーの文脈に出すテーブルの名前
+	Relname string
+	// Reltuples はテーブルの行の数の見積もり。不明なら -1 (reltuples 相当)
+	Reltuples float64
+	// DeadTuples は不要になった行の数 (dead_tuples 相当)
+	DeadTuples int64
+	// InsSinceVacuum は前回の VACUUM から挿入した行の数 (ins_since_vacuum 相当)
+	InsSinceVacuum int64
+	// ModSinceAnalyze は前回の ANALYZE から挿入、更新、削除した行の数 (mod_since_analyze 相当)
+	ModSinceAnalyze int64
+	// Opts はテーブルのストレージパラメータ。指定していなければ nil
+	Opts *AutoVacOpts
+}
+
+// AutoVacRelations は autovacuum が使うテーブルの統計の参照と VACUUM の実行
+// (pgstat と commands/vacuum.c の一部相当)
+type AutoVacRelations interface {
+	// Databases は接続できるデータベースの OID を返す (get_database_list 相当)
+	Databases() []catalog.Oid
+	// Tables はデータベースのテーブルとその統計を返す (do_autovacuum の pg_class の走査相当)
+	Tables(dbOid catalog.Oid) []AutoVacTable
+	// RecheckTable はテーブルの最新の統計を返す。テーブルが削除されていれば ok に false を返す
+	// (table_recheck_autovac 相当)
+	RecheckTable(dbOid, relid catalog.Oid) (tab AutoVacTable, ok bool)
+	// Vacuum はテーブルを VACUUM、ANALYZE する (autovacuum_do_vac_analyze 相当)。w.Cost() で
+	// ページのコストを数えて休み、ワーカーの終了を要求されたら miscadmin.ErrProcDie を返す
+	Vacuum(w *AutoVacWorker, tab AutoVacTable, doVacuum, doAnalyze bool) error
+}
+
+// autoVacLauncher はランチャーの起動と停止の状態
+var autoVacLauncher struct {
+	sync.Mutex
+	running bool
+	// stop は停止の要求で閉じ、exited はランチャーと全てのワーカーが終わったときに閉じる
+	stop, exited chan struct{}
+}
+
+// StartAutoVacLauncher は autovacuum のランチャーを起動する (StartAutoVacLauncher 相当)。
+// 既に動いていれば何もしない。
+func StartAutoVacLauncher(rels AutoVacRelations) {
+	l := &autoVacLauncher
+	l.Lock()
+	defer l.Unlock()
+	if l.running {
+		return
+	}
+	l.running = true
+	l.stop = make(chan struct{})
+	l.exited = make(chan struct{})
+
+	autoVacuumShmem.Lock()
+	autoVacuumShmem.launcherWakeup = make(chan struct{}, 1)
+	wakeup := autoVacuumShmem.launcherWakeup
+	autoVacuumShmem.Unlock()
+	stop, exited := l.stop, l.exited
+	sim.Go(func() { autoVacLauncherMain(rels, wakeup, stop, exited) })
+}
+
+// StopAutoVacLauncher はランチャーと全てのワーカーを止め、終わるのを待つ (postmaster が
+// ランチャーとワーカーに SIGTERM を送る処理相当)。動いていなければ何もしない。
+func StopAutoVacLauncher() {
+	l := &autoVacLauncher
+	l.Lock()
+	if !l.running {
+		l.Unlock()
+		return
+	}
+	l.running = false
+	close(l.stop)
+	exited := l.exited
+	l.Unlock()
+	sim.Wait(-1, exited)
+}
+
+// autoVacLauncherMain はランチャーのメインループ (AutoVacLauncherMain 相当)
+func autoVacLauncherMain(rels AutoVacRelations, wakeup, stop <-chan struct{}, exited chan<- struct{}) {
+	// workers は起動したワーカーが終わると閉じるチャネル。シミュレーションでも待てるよう、
+	// sync.WaitGroup ではなくチャネルを sim.Wait で待つ
+	var workers []chan struct{}
+	defer func() {
+		// 処理中のワーカーに終了を要求し、終わるのを待つ
+		autoVacuumShmem.Lock()
+		for _, w := range autoVacuumShmem.runningWorkers {
+			w.interrupts.SetProcDiePending()
+		}
+		autoVacuumShmem.Unlock()
+		for _, done := range workers {
+			sim.Wait(-1, done)
+		}
+
+		autoVacuumShmem.Lock()
+		autoVacuumShmem.launcherWakeup = nil
+		autoVacuumShmem.Unlock()
+		close(exited)
+	}()
+
+	sleep := func(d time.Duration, wakeup <-chan struct{}) (stopped bool) {
+		return sim.Wait(d, stop, wakeup) == 0
+	}
+
+	// dbs は周回ごとに作り直すデータベースの一覧、next は次にワーカーを起動するデータベース
+	// (rebuild_database_list の DatabaseList 相当)
+	var dbs []catalog.Oid
+	next := 0
+	for {
+		naptime := time.Duration(guc.AutovacuumNaptime.Get()) * time.Second
+		if !guc.AutovacuumStartDaemon.Get() {
+			dbs, next = nil, 0
+			if sleep(naptime, nil) {
+				return
+			}
+			continue
+		}
+
+		if next >= len(dbs) {
+			dbs, next = rels.Databases(), 0
+		}
+		if next < len(dbs) {
+			interrupts := &miscadmin.Interrupts{}
+			w, err := AutoVacuumWorkerStart(dbs[next], interrupts)
+			if err != nil {
+				// 枠が空くまで待ってから、同じデータベースにワーカーを起動し直す
+				if sleep(naptime, wakeup) {
+					return
+				}
+				continue
+			}
+			next++
+			// 終わったワーカーのチャネルは捨てる
+			workers = slices.DeleteFunc(workers, func(done chan struct{}) bool { return sim.Wait(0, done) == 0 })
+			done := make(chan struct{})
+			workers = append(workers, done)
+			sim.Go(func() {
+				defer close(done)
+				defer w.Exit()
+				doAutovacuum(w, rels)
+			})
+		}
+
+		// naptime の間に全てのデータベースを訪れる
+		if sleep(max(naptime/time.Duration(max(len(dbs), 1)), minAutovacSleeptime), nil) {
+			return
+		}
+	}
+}
+
+// doAutovacuum はワーカーのデータベースで、閾値を超えたテーブルを VACUUM、ANALYZE する
+// (do_autovacuum 相当)。処理中のテーブルの VACUUM が失敗した場合は、エラーをログに出して
+// 次のテーブルに進む。
+func doAutovacuum(w *AutoVacWorker, rels AutoVacRelations) {
+	var tables []AutoVacTable
+	for _, tab := range rels.Tables(w.DbOid) {
+		if doVacuum, doAnalyze := relationNeedsVacAnalyze(&tab); doVacuum || doAnalyze {
+			tables = append(tables, tab)
+		}
+	}
+
+	for _, tab := range tables {
+		if w.interrupts.ProcDiePending() {
+			return
+		}
+		if !w.claimTable(tab.Relid) {
+			continue
+		}
+		err := w.vacuumTable(rels, tab.Relid)
+		w.claimTable(catalog.InvalidOid)
+		if errors.Is(err, miscadmin.ErrProcDie) {
+			return
+		}
+	}
+}
+
+// vacuumTable は統計を読み直し、まだ必要であればテーブルを処理する。一覧を作ってから
+// 他のワーカーが処理したかもしれないため
+func (w *AutoVacWorker) vacuumTable(rels AutoVacRelations, relid catalog.Oid) error {
+	tab, ok := rels.RecheckTable(w.DbOid, relid)
+	if !ok {
+		return nil
+	}
+	doVacuum, doAnalyze := relationNeedsVacAnalyze(&tab)
+	if !doVacuum && !doAnalyze {
+		return nil
+	}
+
+	costDelay, costLimit := float64(avCostParamUnset), avCostParamUnset
+	if tab.Opts != nil {
+		costDelay, costLimit = tab.Opts.VacuumCostDelay, tab.Opts.VacuumCostLimit
+	}
+	w.SetTableCostParams(costDelay, costLimit)
+
+	err := rels.Vacuum(w, tab, doVacuum, doAnalyze)
+	if err != nil && !errors.Is(err, miscadmin.ErrProcDie) {
+		what := "vacuum"
+		if !doVacuum {
+			what = "analyze"
+		}
+		edata := errutil.Elog(errutil.Error, "%v", err)
+		edata.Context = fmt.Sprintf("automatic %s of table \"%s\"", what, tab.Relname)
+		errutil.Report(w.logProc, edata)
+	}
+	return err
+}
+
+// claimTable はテーブルを処理中として記録する。同じデータベースの他のワーカーが処理していれば
+// 記録せずに false を返す。InvalidOid を渡すと記録を消す。
+func (w *AutoVacWorker) claimTable(relid catalog.Oid) bool {
+	autoVacuumShmem.Lock()
+	defer autoVacuumShmem.Unlock()
+	if relid != catalog.InvalidOid {
+		for _, other := range autoVacuumShmem.runningWorkers {
+			if other != w && other.DbOid == w.DbOid && other.tableoid == relid {
+				return false
+			}
+		}
+	}
+	w.tableoid = relid
+	return true
+}
+
+// relationNeedsVacAnalyze はテーブルを VACUUM するか、ANALYZE するかを決める
+// (relation_needs_vacanalyze 相当)。周回を防ぐための VACUUM はまだ行わない。
+func relationNeedsVacAnalyze(tab *AutoVacTable) (doVacuum, doAnalyze bool) {
+	enabled := true
+	vacBase, vacScale := guc.AutovacuumVacuumThreshold.Get(), guc.AutovacuumVacuumScaleFactor.Get()
+	insBase, insScale := guc.AutovacuumVacuumInsertThreshold.Get(), guc.AutovacuumVacuumInsertScaleFactor.Get()
+	anlBase, anlScale := guc.AutovacuumAnalyzeThreshold.Get(), guc.AutovacuumAnalyzeScaleFactor.Get()
+	if o := tab.Opts; o != nil {
+		enabled = o.Enabled
+		if o.VacuumThreshold >= 0 {
+			vacBase = o.VacuumThreshold
+		}
+		if o.VacuumScaleFactor >= 0 {
+			vacScale = o.VacuumScaleFactor
+		}
+		if o.VacuumInsThreshold >= -1 {
+			insBase = o.VacuumInsThreshold
+		}
+		if o.VacuumInsScaleFactor >= 0 {
+			insScale = o.VacuumInsScaleFactor
+		}
+		if o.AnalyzeThreshold >= 0 {
+			anlBase = o.AnalyzeThreshold
+		}
+		if o.AnalyzeScaleFactor >= 0 {
+			anlScale = o.AnalyzeScaleFactor
+		}
+	}
+	if !enabled {
+		return false, false
+	}
+
+	// 一度も VACUUM、ANALYZE していないテーブルの reltuples は -1 のため、0 として扱う
+	reltuples := max(tab.Reltuples, 0)
+	vacThresh := float64(vacBase) + vacScale*reltuples
+	anlThresh := float64(anlBase) + anlScale*reltuples
+	doVacuum = float64(tab.DeadTuples) > vacThresh
+	if insBase >= 0 {
+		insThresh := float64(insBase) + insScale*reltuples
+		doVacuum = doVacuum || float64(tab.InsSinceVacuum) > insThresh
+	}
+	doAnalyze = float64(tab.ModSinceAnalyze) > anlThresh
+	return doVacuum, doAnalyze
+}
@@ -0,0 +1,105 @@
+package postmaster
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// fakeRelations はどのデータベースにも VACUUM の必要なテーブルが1つあるとし、
+// VACUUM を始めた時刻とデータベースを記録する
+type fakeRelations struct {
+	dbs []catalog.Oid
+	// vacuumTime は VACUUM 1回にかける時間
+	vacuumTime time.Duration
+	start      time.Time
+	trace      []string
+}
+
+func (r *fakeRelations) Databases() []catalog.Oid { return r.dbs }
+
+func (r *fakeRelations) Tables(dbOid catalog.Oid) []AutoVacTable {
+	return []AutoVacTable{{Relid: 16384, Relname: "t", Reltuples: 100, DeadTuples: 1000}}
+}
+
+func (r *fakeRelations) RecheckTable(dbOid, relid catalog.Oid) (AutoVacTable, bool) {
+	return r.Tables(dbOid)[0], true
+}
+
+func (r *fakeRelations) Vacuum(w *AutoVacWorker, tab AutoVacTable, doVacuum, doAnalyze bool) error {
+	r.trace = append(r.trace, fmt.Sprintf("%v db %d", sim.Since(r.start).Truncate(time.Second), w.DbOid))
+	sim.Sleep(r.vacuumTime)
+	return nil
+}
+
+// setGUC は設定を変え、テストの後に元に戻す
+func setGUC(t *testing.T, name, value string) {
+	t.Helper()
+	prev, _ := guc.GetConfigOption(name)
+	if err := guc.SetConfigOption(name, value, guc.PGCPostmaster, guc.PGCSArgv); err != nil {
+		t.Fatalf("SetConfigOption(%s) = %v", name, err)
+	}
+	t.Cleanup(func() { guc.SetConfigOption(name, prev, guc.PGCPostmaster, guc.PGCSArgv) })
+}
+
+// runLauncher は seed のシミュレーションでランチャーを d の間動かし、VACUUM の記録を返す
+func runLauncher(seed uint64, rels *fakeRelations, d time.Duration) []string {
+	s := sim.NewSim(seed)
+	defer sim.Set(s)()
+	rels.start, rels.trace = sim.Now(), nil
+	StartAutoVacLauncher(rels)
+	s.RunFor(d)
+	sim.Go(StopAutoVacLauncher)
+	s.Run()
+	return rels.trace
+}
+
+func TestAutovacuumVisitsDatabasesWithinNaptime(t *testing.T) {
+	setGUC(t, "autovacuum", "on")
+	setGUC(t, "autovacuum_naptime", "60")
+	setGUC(t, "autovacuum_max_workers", "3")
+
+	// naptime をデータベースの数で割った 20 秒ごとに、順にワーカーを起動する
+	rels := &fakeRelations{dbs: []catalog.Oid{1, 2, 3}, vacuumTime: 5 * time.Second}
+	got := runLauncher(1, rels, 130*time.Second)
+	want := []string{
+		"0s db 1", "20s db 2", "40s db 3",
+		"1m0s db 1", "1m20s db 2", "1m40s db 3",
+		"2m0s db 1",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("vacuum schedule = %q, want %q", got, want)
+	}
+	for seed := uint64(2); seed < 5; seed++ {
+		if again := runLauncher(seed, rels, 130*time.Second); !slices.Equal(again, want) {
+			t.Errorf("seed %d: vacuum schedule = %q, want %q", seed, again, want)
+		}
+	}
+}
+
+func TestAutovacuumWaitsForFreeWorkerSlot(t *testing.T) {
+	setGUC(t, "autovacuum", "on")
+	setGUC(t, "autovacuum_naptime", "60")
+	setGUC(t, "autovacuum_max_workers", "1")
+
+	// 枠が1つしかないため、次のワーカーは前のワーカーが 50 秒の VACUUM を終えてから起動する
+	rels := &fakeRelations{dbs: []catalog.Oid{1, 2, 3}, vacuumTime: 50 * time.Second}
+	got := runLauncher(1, rels, 120*time.Second)
+	want := []string{"0s db 1", "50s db 2", "1m40s db 3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("vacuum schedule = %q, want %q", got, want)
+	}
+}
+
+func TestAutovacuumOffStartsNoWorkers(t *testing.T) {
+	setGUC(t, "autovacuum", "off")
+	rels := &fakeRelations{dbs: []catalog.Oid{1}, vacuumTime: time.Second}
+	if got := runLauncher(1, rels, 10*time.Minute); len(got) != 0 {
+		t.Errorf("vacuum schedule with autovacuum off = %q, want none", got)
+	}
+}
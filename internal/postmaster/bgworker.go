@@ -0,0 +1,83 @@
+package postmaster
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/backend"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/postmaster/bgworker"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// ----------------------------------------------------------------
+// バックグラウンドワーカーの起動 (postmaster.c の maybe_start_bgworkers, do_start_bgworker 相当)
+// ----------------------------------------------------------------
+// postmaster は起動の段階が進んだとき、ワーカーが登録されたときと、エラーで終わったワーカーを
+// 起動し直す時刻になったときに、起動できるワーカーを起動する。停止の処理の間と、異常終了の後に
+// 初期化し直すまでの間は起動しない。
+
+// bgworkerPhase は postmaster が達した起動の段階
+var bgworkerPhase atomic.Int32
+
+// liveBgWorkers は動いているワーカーの数。liveChildren には数えない
+var liveBgWorkers atomic.Int32
+
+// bgworkerWakeup は起動の段階が進んだことや、初期化し直したことを bgworkerLoop に知らせる
+var bgworkerWakeup = make(chan struct{}, 1)
+
+// advanceBgWorkerPhase は起動の段階を phase に進め、その段階で起動するワーカーを起動させる
+func advanceBgWorkerPhase(phase bgworker.StartTime) {
+	bgworkerPhase.Store(int32(phase))
+	wakeBgWorkerLoop()
+}
+
+// wakeBgWorkerLoop は bgworkerLoop に起動できるワーカーを見直させる
+func wakeBgWorkerLoop() {
+	select {
+	case bgworkerWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// bgworkerLoop はワーカーを起動できる機会を待ち、起動できるワーカーを起動し続ける
+// (ServerLoop の maybe_start_bgworkers の呼び出しと DetermineSleepTime 相当)
+func bgworkerLoop() {
+	for {
+		var next time.Duration
+		if shutdown.Load() == int32(noShutdown) && !fatalError.Load() {
+			next = bgworker.MaybeStartWorkers(bgworker.StartTime(bgworkerPhase.Load()), startBgWorker)
+		}
+		timeout := time.Duration(-1)
+		if next > 0 {
+			timeout = next
+		}
+		sim.Wait(timeout, bgworker.Changed(), bgworkerWakeup)
+	}
+}
+
+// startBgWorker はワーカーをゴルーチンとして起動する (do_start_bgworker 相当)。ワーカーが終わったら
+// 後始末をし、エラーで終わったワーカーは起動し直す時刻を記録させる (CleanupBackgroundWorker 相当)。
+func startBgWorker(rw *bgworker.RegisteredWorker) {
+	liveBgWorkers.Add(1)
+	go func() {
+		defer func() {
+			liveBgWorkers.Add(-1)
+			select {
+			case childExited <- struct{}{}:
+			default:
+			}
+		}()
+		crash, err := backend.BackgroundWorkerMain(rw)
+		if crash != nil {
+			// 異常終了したワーカーは、全てのプロセスが終わった後に初期化し直すときに起動し直す
+			handleChildCrash(crash, fmt.Sprintf("background worker \"%s\"", rw.Worker().Name))
+			return
+		}
+		if pid := rw.PID(); err != nil && pid > 0 {
+			errutil.Report(nil, errutil.Elog(errutil.Log, "background worker \"%s\" (PID %d) exited with exit code 1", rw.Worker().Name, pid))
+		}
+		bgworker.ReportWorkerExited(rw, err, shutdown.Load() != int32(noShutdown))
+	}()
+}
@@ -0,0 +1,527 @@
+package bgworker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// バックグラウンドワーカー (postmaster/bgworker.c 相当)
+// ----------------------------------------------------------------
+// 拡張機能やサーバー内部のモジュールが、postmaster に管理されたワーカーを動かすための仕組み。
+// ワーカーは RegisterBackgroundWorker で postmaster の起動前に登録するか、動作中のバックエンドから
+// RegisterDynamicBackgroundWorker で登録する。postmaster は StartTime の段階に達したワーカーを
+// 起動し、ワーカーがエラーで終われば RestartTime の後に起動し直す。
+//
+// C言語版のワーカーは postmaster が fork() するプロセスで、登録の内容を共有メモリの枠
+// (BackgroundWorkerSlot) に置く。Go言語版のワーカーはゴルーチンで、全てのメモリを共有する。
+// 枠は max_worker_processes の数だけ用意し、登録とバックエンドからの問い合わせはこのパッケージの
+// ロックで守る。
+//
+// ワーカーの本体 (Main) が nil を返すと終了コード 0 の終了とみなし、登録を取り消す。エラーを
+// 返すと終了コード 1 の終了とみなし、RestartTime の後に起動し直す。panic した場合は異常終了として、
+// バックエンドの異常終了と同じく全てのプロセスを終了させてから初期化し直す。
+
+// BGWMaxLen はワーカーの名前と種類の長さの上限 (BGW_MAXLEN 相当)
+const BGWMaxLen = 96
+
+// Flags はワーカーが使う機能 (bgw_flags 相当)
+type Flags int
+
+const (
+	// ShmemAccess は共有メモリを使うことを表す (BGWORKER_SHMEM_ACCESS 相当)。全てのワーカーに必要
+	ShmemAccess Flags = 1 << iota
+	// BackendDatabaseConnection はデータベースに接続することを表す
+	// (BGWORKER_BACKEND_DATABASE_CONNECTION 相当)
+	BackendDatabaseConnection
+)
+
+// StartTime はワーカーを起動する段階 (BgWorkerStartTime 相当)。WAL のリカバリがまだないため、
+// 一貫した状態とリカバリの終了は、postmaster が接続を受け付けられるようになると同時に迎える。
+type StartTime int
+
+const (
+	// StartAtPostmasterStart は postmaster の起動直後 (BgWorkerStart_PostmasterStart 相当)
+	StartAtPostmasterStart StartTime = iota
+	// StartAtConsistentState はリカバリ中でもデータが一貫した状態 (BgWorkerStart_ConsistentState 相当)
+	StartAtConsistentState
+	// StartAtRecoveryFinished はリカバリを終えた後 (BgWorkerStart_RecoveryFinished 相当)
+	StartAtRecoveryFinished
+)
+
+// NeverRestart は終わったワーカーを起動し直さないことを表す (BGW_NEVER_RESTART 相当)
+const NeverRestart time.Duration = -1
+
+// Worker はワーカーの登録の内容 (BackgroundWorker 相当)
+type Worker struct {
+	// Name はログに出すワーカーの名前 (bgw_name 相当)
+	Name string
+	// Type は pg_stat_activity の backend_type に出す種類 (bgw_type 相当)。空なら Name を使う
+	Type      string
+	Flags     Flags
+	StartTime StartTime
+	// RestartTime はエラーで終わってから起動し直すまでの時間 (bgw_restart_time 相当)。
+	// NeverRestart なら起動し直さない
+	RestartTime time.Duration
+	// Main はワーカーの本体 (bgw_library_name, bgw_function_name 相当)。ctx で割り込みを受け付け、
+	// 終了を要求されたら戻る
+	Main func(ctx Context) error
+	// MainArg は Main に渡す値 (bgw_main_arg 相当)
+	MainArg int64
+}
+
+// Context は動作中のワーカーが使う自分の情報と機能 (MyBgworkerEntry と MyProc 相当)。
+// バックエンドがワーカーを起動するときに実装を渡す。
+type Context interface {
+	// Worker は登録の内容を返す
+	Worker() *Worker
+	// PID はワーカーの PID を返す (MyProcPid 相当)
+	PID() int32
+	// Interrupts はワーカーへの割り込み要求を返す。Main は処理の区切りで確かめる
+	Interrupts() *miscadmin.Interrupts
+	// WaitEvent は待っているものを記録する先を返す
+	WaitEvent() *waitevent.Slot
+	// InitializeConnection は dbname のデータベースに username として接続する
+	// (BackgroundWorkerInitializeConnection 相当)。BackendDatabaseConnection を指定して
+	// 登録したワーカーだけが呼べる
+	InitializeConnection(dbname, username string) error
+}
+
+// Status はバックエンドから見たワーカーの状態 (BgwHandleStatus 相当)
+type Status int
+
+const (
+	// StatusStarted はワーカーが動いていることを表す (BGWH_STARTED 相当)
+	StatusStarted Status = iota
+	// StatusNotYetStarted はワーカーをまだ起動していないことを表す (BGWH_NOT_YET_STARTED 相当)
+	StatusNotYetStarted
+	// StatusStopped はワーカーが終わり、起動し直さないことを表す (BGWH_STOPPED 相当)
+	StatusStopped
+	// StatusPostmasterDied は postmaster が終わったことを表す (BGWH_POSTMASTER_DIED 相当)
+	StatusPostmasterDied
+)
+
+// RegisteredWorker は postmaster が管理するワーカー1つ (RegisteredBgWorker と BackgroundWorkerSlot 相当)
+type RegisteredWorker struct {
+	worker Worker
+	// slot は枠の番号、generation は枠を使い回すたびに増やす番号 (generation 相当)
+	slot       int
+	generation uint64
+	// dynamic はバックエンドが動作中に登録したことを表す
+	dynamic bool
+
+	// 以下は bgworkerShmem のロックで守る
+	// pid は動いているワーカーの PID。起動していなければ 0、起動を始めて PID が決まる前は -1
+	pid int32
+	// crashedAt はワーカーが最後にエラーで終わった時刻 (rw_crashed_at 相当)。動いているか、
+	// まだ一度も終わっていなければゼロ
+	crashedAt time.Time
+	// terminate は TerminateBackgroundWorker で終了を要求されたことを表す (terminate 相当)
+	terminate bool
+	// interrupts は動いているワーカーへの割り込み要求
+	interrupts *miscadmin.Interrupts
+	// changed は pid が変わるたびに閉じて作り直す。ワーカーの起動と終了を待つバックエンドが使う
+	changed chan struct{}
+}
+
+// Worker はワーカーの登録の内容を返す
+func (rw *RegisteredWorker) Worker() *Worker { return &rw.worker }
+
+// PID は動いているワーカーの PID を返す。動いていなければ 0 以下を返す
+func (rw *RegisteredWorker) PID() int32 {
+	bgworkerShmem.Lock()
+	defer bgworkerShmem.Unlock()
+	return rw.pid
+}
+
+// Handle はバックエンドが動作中に登録したワーカーを指す (BackgroundWorkerHandle 相当)
+type Handle struct {
+	slot       int
+	generation uint64
+}
+
+// bgworkerShmem は登録したワーカーと枠 (BackgroundWorkerData 相当)
+var bgworkerShmem = struct {
+	sync.Mutex
+	// static は postmaster の起動前に登録したワーカー。Init で枠に入れる (BackgroundWorkerList 相当)
+	static []Worker
+	// slots は max_worker_processes の数の枠。使っていない枠は nil
+	slots []*RegisteredWorker
+	// generations は枠ごとに最後に使った generation
+	generations []uint64
+	// initialized は Init を呼んだことを表す
+	initialized bool
+	// stopping は postmaster が停止の処理で全てのワーカーに終了を要求したことを表す
+	stopping bool
+	// postmasterDied は postmaster が終わったことを表す
+	postmasterDied bool
+}{}
+
+// changed はワーカーの登録と終了の要求を postmaster に知らせる
+// (PMSIGNAL_BACKGROUND_WORKER_CHANGE 相当)
+var changed = make(chan struct{}, 1)
+
+// notifyPostmaster は postmaster にワーカーの状態を見直させる (SendPostmasterSignal 相当)
+func notifyPostmaster() {
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
+
+// Changed はワーカーの登録や終了の要求があったときに受信できるチャネルを返す。postmaster が使う。
+func Changed() <-chan struct{} {
+	return changed
+}
+
+// sanityCheck は登録の内容を確かめ、種類を補う (SanityCheckBackgroundWorker 相当)
+func sanityCheck(w *Worker) error {
+	if w.Name == "" {
+		return fmt.Errorf("background worker name must not be empty")
+	}
+	if len(w.Name) >= BGWMaxLen {
+		w.Name = w.Name[:BGWMaxLen-1]
+	}
+	if w.Flags&ShmemAccess == 0 {
+		return fmt.Errorf("background worker \"%s\": background workers without shared memory access are not supported", w.Name)
+	}
+	if w.Flags&BackendDatabaseConnection != 0 && w.StartTime == StartAtPostmasterStart {
+		return fmt.Errorf("background worker \"%s\": cannot request database access if starting at postmaster start", w.Name)
+	}
+	if w.RestartTime < 0 && w.RestartTime != NeverRestart {
+		return fmt.Errorf("background worker \"%s\": invalid restart interval", w.Name)
+	}
+	if w.Main == nil {
+		return fmt.Errorf("background worker \"%s\": no main function", w.Name)
+	}
+	if w.Type == "" {
+		w.Type = w.Name
+	} else if len(w.Type) >= BGWMaxLen {
+		w.Type = w.Type[:BGWMaxLen-1]
+	}
+	return nil
+}
+
+// RegisterBackgroundWorker は postmaster が起動するワーカーを登録する (RegisterBackgroundWorker 相当)。
+// postmaster が起動する前に呼ぶ。C言語版で shared_preload_libraries の読み込み中に呼ぶことに当たる。
+func RegisterBackgroundWorker(w Worker) error {
+	if err := sanityCheck(&w); err != nil {
+		return err
+	}
+	sh := &bgworkerShmem
+	sh.Lock()
+	defer sh.Unlock()
+	if sh.initialized {
+		return fmt.Errorf("background worker \"%s\": must be registered before the postmaster starts", w.Name)
+	}
+	sh.static = append(sh.static, w)
+	return nil
+}
+
+// Init は max_worker_processes の数の枠を用意し、登録したワーカーを入れる (BackgroundWorkerShmemInit 相当)。
+// 枠に入り切らないワーカーは登録しない。postmaster が起動時に呼ぶ。
+func Init() {
+	sh := &bgworkerShmem
+	sh.Lock()
+	defer sh.Unlock()
+	if sh.initialized {
+		return
+	}
+	limit := guc.MaxWorkerProcesses.Get()
+	sh.slots = make([]*RegisteredWorker, limit)
+	sh.generations = make([]uint64, limit)
+	for i, w := range sh.static {
+		if i >= limit {
+			errutil.Report(nil, errutil.New(errutil.Log, errcodes.ConfigurationLimitExceeded, "too many background workers").
+				WithDetail("Up to %d background workers can be registered with the current settings.", limit).
+				WithHint("Consider increasing the configuration parameter \"max_worker_processes\"."))
+			break
+		}
+		sh.slots[i] = newRegisteredWorker(w, i, false)
+	}
+	sh.initialized = true
+}
+
+// newRegisteredWorker は slot の枠にワーカーを入れる。bgworkerShmem のロックを持って呼ぶ。
+func newRegisteredWorker(w Worker, slot int, dynamic bool) *RegisteredWorker {
+	sh := &bgworkerShmem
+	sh.generations[slot]++
+	return &RegisteredWorker{worker: w, slot: slot, generation: sh.generations[slot], dynamic: dynamic,
+		changed: make(chan struct{})}
+}
+
+// RegisterDynamicBackgroundWorker は動作中のバックエンドからワーカーを登録する
+// (RegisterDynamicBackgroundWorker 相当)。空いている枠がなければ ok に false を返す。
+func RegisterDynamicBackgroundWorker(w Worker) (h *Handle, ok bool, err error) {
+	if err := sanityCheck(&w); err != nil {
+		return nil, false, err
+	}
+	sh := &bgworkerShmem
+	sh.Lock()
+	defer sh.Unlock()
+	if !sh.initialized || sh.postmasterDied {
+		return nil, false, nil
+	}
+	for i, rw := range sh.slots {
+		if rw != nil {
+			continue
+		}
+		rw = newRegisteredWorker(w, i, true)
+		sh.slots[i] = rw
+		notifyPostmaster()
+		return &Handle{slot: i, generation: rw.generation}, true, nil
+	}
+	return nil, false, nil
+}
+
+// lookup は h の指すワーカーを返す。ワーカーが終わって枠が空いているか、別のワーカーに
+// 使われていれば nil を返す。bgworkerShmem のロックを持って呼ぶ。
+func (h *Handle) lookup() *RegisteredWorker {
+	rw := bgworkerShmem.slots[h.slot]
+	if rw == nil || rw.generation != h.generation {
+		return nil
+	}
+	return rw
+}
+
+// status は h の指すワーカーの PID と状態を返す。bgworkerShmem のロックを持って呼ぶ。
+func (h *Handle) status() (int32, Status, <-chan struct{}) {
+	if bgworkerShmem.postmasterDied {
+		return 0, StatusPostmasterDied, nil
+	}
+	rw := h.lookup()
+	switch {
+	case rw == nil:
+		return 0, StatusStopped, nil
+	case rw.pid > 0:
+		return rw.pid, StatusStarted, rw.changed
+	}
+	return 0, StatusNotYetStarted, rw.changed
+}
+
+// GetBackgroundWorkerPid はワーカーの PID と状態を返す (GetBackgroundWorkerPid 相当)。
+// PID はワーカーが動いているときだけ意味を持つ。
+func GetBackgroundWorkerPid(h *Handle) (int32, Status) {
+	sh := &bgworkerShmem
+	sh.Lock()
+	defer sh.Unlock()
+	pid, status, _ := h.status()
+	return pid, status
+}
+
+// WaitForBackgroundWorkerStartup はワーカーが起動するか、起動せずに終わるまで待つ
+// (WaitForBackgroundWorkerStartup 相当)。待つ間は interrupts で取り消しを受け付け、
+// waitEvent に記録する。
+func WaitForBackgroundWorkerStartup(h *Handle, interrupts *miscadmin.Interrupts, waitEvent *waitevent.Slot) (int32, Status, error) {
+	waitEvent.Start(waitevent.IPCBgWorkerStartup)
+	defer waitEvent.End()
+	for {
+		sh := &bgworkerShmem
+		sh.Lock()
+		pid, status, ch := h.status()
+		sh.Unlock()
+		if status != StatusNotYetStarted {
+			return pid, status, nil
+		}
+		if err := waitOrInterrupt(ch, interrupts); err != nil {
+			return 0, status, err
+		}
+	}
+}
+
+// WaitForBackgroundWorkerShutdown はワーカーが終わって起動し直さなくなるまで待つ
+// (WaitForBackgroundWorkerShutdown 相当)。待つ間は interrupts で取り消しを受け付け、
+// waitEvent に記録する。
+func WaitForBackgroundWorkerShutdown(h *Handle, interrupts *miscadmin.Interrupts, waitEvent *waitevent.Slot) (Status, error) {
+	waitEvent.Start(waitevent.IPCBgWorkerShutdown)
+	defer waitEvent.End()
+	for {
+		sh := &bgworkerShmem
+		sh.Lock()
+		_, status, ch := h.status()
+		sh.Unlock()
+		if status == StatusStopped || status == StatusPostmasterDied {
+			return status, nil
+		}
+		if err := waitOrInterrupt(ch, interrupts); err != nil {
+			return status, err
+		}
+	}
+}
+
+// TerminateBackgroundWorker はワーカーの終了を postmaster に要求する (TerminateBackgroundWorker 相当)。
+// 終わったワーカーは起動し直さない。既に終わっていれば何もしない。
+func TerminateBackgroundWorker(h *Handle) {
+	sh := &bgworkerShmem
+	sh.Lock()
+	defer sh.Unlock()
+	if rw := h.lookup(); rw != nil {
+		rw.terminate = true
+		notifyPostmaster()
+	}
+}
+
+// waitOrInterrupt は ch が閉じるまで待つ。待つ間も取り消しとセッションの終了の要求を確かめる
+func waitOrInterrupt(ch <-chan struct{}, interrupts *miscadmin.Interrupts) error {
+	for sim.Wait(100*time.Millisecond, ch) < 0 {
+		if err := interrupts.CheckForInterrupts(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// postmaster によるワーカーの起動と後始末 (postmaster.c の maybe_start_bgworkers,
+// CleanupBackgroundWorker 相当)
+// ----------------------------------------------------------------
+
+// MaybeStartWorkers は reached の段階に達していて起動を待っているワーカーを全て launch で起動する
+// (maybe_start_bgworkers 相当)。終了を要求されたワーカーは、動いていれば割り込みで終了を要求し、
+// 起動していなければ登録を取り消す。起動し直すまで待つワーカーがあれば、次に起動できるまでの
+// 時間を返す。なければ 0 を返す。
+func MaybeStartWorkers(reached StartTime, launch func(rw *RegisteredWorker)) time.Duration {
+	sh := &bgworkerShmem
+	sh.Lock()
+	var toStart []*RegisteredWorker
+	var next time.Duration
+	now := sim.Now()
+	for i, rw := range sh.slots {
+		if rw == nil {
+			continue
+		}
+		if rw.terminate {
+			if rw.pid > 0 {
+				rw.interrupts.SetProcDiePending()
+			} else if rw.pid == 0 {
+				forgetWorker(i)
+			}
+			continue
+		}
+		if rw.pid != 0 || rw.worker.StartTime > reached {
+			continue
+		}
+		if !rw.crashedAt.IsZero() {
+			if wait := rw.crashedAt.Add(rw.worker.RestartTime).Sub(now); wait > 0 {
+				if next == 0 || wait < next {
+					next = wait
+				}
+				continue
+			}
+		}
+		rw.pid = -1
+		rw.crashedAt = time.Time{}
+		toStart = append(toStart, rw)
+	}
+	sh.Unlock()
+	for _, rw := range toStart {
+		launch(rw)
+	}
+	return next
+}
+
+// ReportWorkerStarted はワーカーが PID を得て動き始めたことを記録する。ワーカーの起動を待つ
+// バックエンドを起こす。
+func ReportWorkerStarted(rw *RegisteredWorker, pid int32, interrupts *miscadmin.Interrupts) {
+	sh := &bgworkerShmem
+	sh.Lock()
+	defer sh.Unlock()
+	rw.pid = pid
+	rw.interrupts = interrupts
+	if rw.terminate || sh.stopping {
+		interrupts.SetProcDiePending()
+	}
+	rw.broadcast()
+}
+
+// ReportWorkerExited はワーカーが終わったことを記録する (CleanupBackgroundWorker 相当)。
+// exitErr はワーカーの本体が返したエラーで、nil なら終了コード 0 とみなす。終了コード 0 の場合、
+// 終了を要求された場合、起動し直さないワーカーと postmaster の停止中は登録を取り消す。
+// それ以外は RestartTime の後に起動し直す。
+func ReportWorkerExited(rw *RegisteredWorker, exitErr error, shuttingDown bool) {
+	sh := &bgworkerShmem
+	sh.Lock()
+	defer sh.Unlock()
+	rw.pid = 0
+	rw.interrupts = nil
+	if exitErr == nil || rw.terminate || rw.worker.RestartTime == NeverRestart || shuttingDown {
+		if sh.slots[rw.slot] == rw {
+			forgetWorker(rw.slot)
+		}
+	} else {
+		rw.crashedAt = sim.Now()
+	}
+	rw.broadcast()
+	notifyPostmaster()
+}
+
+// forgetWorker は slot の枠を空ける (ForgetBackgroundWorker 相当)。bgworkerShmem のロックを持って呼ぶ。
+func forgetWorker(slot int) {
+	sh := &bgworkerShmem
+	rw := sh.slots[slot]
+	sh.slots[slot] = nil
+	rw.broadcast()
+}
+
+// broadcast は状態の変化を待っているバックエンドを起こす。bgworkerShmem のロックを持って呼ぶ。
+func (rw *RegisteredWorker) broadcast() {
+	close(rw.changed)
+	rw.changed = make(chan struct{})
+}
+
+// TerminateAllWorkers は動いている全てのワーカーに終了を要求する。以降に動き始めたワーカーにも
+// すぐに終了を要求する。postmaster が停止の処理で、クライアントのバックエンドが全て終わった後に呼ぶ。
+func TerminateAllWorkers() {
+	sh := &bgworkerShmem
+	sh.Lock()
+	defer sh.Unlock()
+	sh.stopping = true
+	for _, rw := range sh.slots {
+		if rw != nil && rw.interrupts != nil {
+			rw.interrupts.SetProcDiePending()
+		}
+	}
+}
+
+// ResetAfterCrash は異常終了の後に、全てのプロセスが終わった時点で枠を初期化し直す
+// (ResetBackgroundWorkerCrashTimes 相当)。動作中に登録したワーカーと起動し直さないワーカーは
+// 登録を取り消し、他のワーカーはすぐに起動し直せるようにする。
+func ResetAfterCrash() {
+	sh := &bgworkerShmem
+	sh.Lock()
+	defer sh.Unlock()
+	for i, rw := range sh.slots {
+		if rw == nil {
+			continue
+		}
+		rw.pid = 0
+		rw.interrupts = nil
+		if rw.dynamic || rw.worker.RestartTime == NeverRestart {
+			forgetWorker(i)
+			continue
+		}
+		rw.crashedAt = time.Time{}
+	}
+	notifyPostmaster()
+}
+
+// PostmasterExited は postmaster が終わったことを記録する。ワーカーを待っているバックエンドを起こす。
+func PostmasterExited() {
+	sh := &bgworkerShmem
+	sh.Lock()
+	defer sh.Unlock()
+	sh.postmasterDied = true
+	for _, rw := range sh.slots {
+		if rw != nil {
+			rw.broadcast()
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package bgwriter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/pgstat"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// ----------------------------------------------------------------
+// バックグラウンドライター (postmaster/bgwriter.c、BgBufferSync 相当)
+// ----------------------------------------------------------------
+// 共有バッファの汚れたページを、バックエンドのクロックスイープが再利用しようとする前に
+// 書き出しておくバックグラウンドのゴルーチン。バックエンドが新しいページを読むために自分で
+// 汚れたページを書き出して待つことを減らす。
+//
+// bgwriter_delay ごとに、前回からのバッファの割り当ての数を平滑化して次の周期に必要な数を
+// 見積もり、クロックスイープの位置より先にある再利用できるバッファを、その数の
+// bgwriter_lru_multiplier 倍になるまで探して書き出す。1周期で書き出すのは bgwriter_lru_maxpages
+// までにする。割り当てがなく、先のバッファを全て調べ終えていれば、bgwriter_delay の
+// 50 倍まで休止し、バックエンドが次にバッファを割り当てたときに起こされる。
+//
+// 共有バッファは BufferPool を実装したバッファマネージャーが持ち、Start に渡す。書き出した
+// バッファの数などの統計は、周期ごとに pgstat に加える。
+
+// BufferPool はバックグラウンドライターが使う共有バッファの操作 (freelist.c と bufmgr.c の一部相当)
+type BufferPool interface {
+	// NBuffers は共有バッファの数 (NBuffers 相当)
+	NBuffers() int
+	// StrategySyncStart はクロックスイープが次に調べるバッファと、それまでにスイープが共有バッファを
+	// 一周した回数、前回の呼び出しからバッファを割り当てた数を返す (StrategySyncStart 相当)
+	StrategySyncStart() (nextVictim int, completePasses uint32, numBufferAllocs uint32)
+	// SyncOneBuffer は、buf が使われておらず最近も使われていなければ再利用できるとし、汚れていれば
+	// 書き出す (skip_recently_used を真にした SyncOneBuffer 相当)
+	SyncOneBuffer(buf int) (reusable, written bool)
+	// StrategyNotifyBgWriter は、次にバッファを割り当てたときに wake を呼ぶよう登録する。
+	// nil を渡すと登録を取り消す (StrategyNotifyBgWriter 相当)
+	StrategyNotifyBgWriter(wake func())
+}
+
+const (
+	// hibernateFactor は休止するときに bgwriter_delay の何倍まで休むか (HIBERNATE_FACTOR 相当)
+	hibernateFactor = 50
+	// smoothingSamples は割り当ての数と密度を平滑化する標本の数 (smoothing_samples 相当)
+	smoothingSamples = 16
+	// scanWholePool は割り当てがなくても、この時間で共有バッファ全体を一度は調べる
+	// (scan_whole_pool_milliseconds 相当)
+	scanWholePool = 120 * time.Second
+)
+
+// Proc はバックグラウンドライターのメッセージに付けるプロセスの情報
+var Proc = errutil.NewAuxProcInfo("background writer")
+
+// bgwriterShmem はバックグラウンドライターの起動と停止の状態
+var bgwriterShmem struct {
+	sync.Mutex
+	running bool
+	// stop は停止の要求で閉じ、exited はゴルーチンが終わったときに閉じる
+	stop, exited chan struct{}
+}
+
+// Start はバックグラウンドライターを起動する (StartBackgroundWriter 相当)。
+// 既に動いていれば何もしない。
+func Start(pool BufferPool) {
+	sh := &bgwriterShmem
+	sh.Lock()
+	defer sh.Unlock()
+	if sh.running {
+		return
+	}
+	sh.running = true
+	sh.stop = make(chan struct{})
+	sh.exited = make(chan struct{})
+	w := &bgWriter{pool: pool, smoothedDensity: 10.0}
+	stop, exited := sh.stop, sh.exited
+	sim.Go(func() { w.main(stop, exited) })
+}
+
+// Stop はバックグラウンドライターを止め、終わるのを待つ (postmaster が bgwriter に SIGTERM を
+// 送る処理相当)。動いていなければ何もしない。
+func Stop() {
+	sh := &bgwriterShmem
+	sh.Lock()
+	if !sh.running {
+		sh.Unlock()
+		return
+	}
+	sh.running = false
+	close(sh.stop)
+	exited := sh.exited
+	sh.Unlock()
+	<-exited
+}
+
+// bgWriter はバックグラウンドライターの状態。BgBufferSync の static 変数を持つ。
+type bgWriter struct {
+	pool BufferPool
+
+	// savedInfoValid は前回の周期の情報が有効であることを表す (saved_info_valid 相当)
+	savedInfoValid bool
+	// prevStrategyBuf と prevStrategyPasses は前回の周期のクロックスイープの位置
+	// (prev_strategy_buf_id、prev_strategy_passes 相当)
+	prevStrategyBuf    int
+	prevStrategyPasses uint32
+	// nextToClean と nextPasses は次に調べるバッファと、その位置までに一周した回数
+	// (next_to_clean、next_passes 相当)
+	nextToClean int
+	nextPasses  uint32
+	// smoothedAlloc は1周期あたりの割り当ての数の平滑値、smoothedDensity は再利用できる
+	// バッファを1つ見つけるまでにスイープが調べるバッファの数の平滑値
+	// (smoothed_alloc、smoothed_density 相当)
+	smoothedAlloc   float64
+	smoothedDensity float64
+
+	// pending は周期の間に数えた統計 (PendingBgWriterStats 相当)
+	pending pgstat.BgWriterStats
+}
+
+// main はバックグラウンドライターのメインループ (BackgroundWriterMain 相当)
+func (w *bgWriter) main(stop <-chan struct{}, exited chan<- struct{}) {
+	defer close(exited)
+	wakeup := make(chan struct{}, 1)
+	wake := func() {
+		select {
+		case wakeup <- struct{}{}:
+		default:
+		}
+	}
+	sleep := func(d time.Duration) (timedOut, stopped bool) {
+		switch sim.Wait(d, stop, wakeup) {
+		case 0:
+			return false, true
+		case 1:
+			return false, false
+		}
+		return true, false
+	}
+
+	prevHibernate := false
+	for {
+		canHibernate := w.bgBufferSync()
+		pgstat.ReportBgWriter(w.pending)
+		w.pending = pgstat.BgWriterStats{}
+
+		// 設定の読み直しに合わせるため、休む時間は毎回読む
+		delay := time.Duration(guc.BgWriterDelay.Get()) * time.Millisecond
+		timedOut, stopped := sleep(delay)
+		if stopped {
+			return
+		}
+
+		// 2周期続けて仕事がなければ、割り当てで起こされるまで長く休む。1周期だけで休止しないのは、
+		// 割り当てが一時的に途切れただけで休止と再開を繰り返さないため
+		if timedOut && canHibernate && prevHibernate {
+			w.pool.StrategyNotifyBgWriter(wake)
+			_, stopped := sleep(delay * hibernateFactor)
+			w.pool.StrategyNotifyBgWriter(nil)
+			if stopped {
+				return
+			}
+		}
+		prevHibernate = canHibernate
+	}
+}
+
+// bgBufferSync はクロックスイープの先にある汚れたバッファを書き出す (BgBufferSync 相当)。
+// 割り当てがなく、先のバッファを全て調べ終えていて、休止してよければ真を返す。
+func (w *bgWriter) bgBufferSync() bool {
+	pool := w.pool
+	nBuffers := pool.NBuffers()
+	strategyBuf, strategyPasses, recentAlloc := pool.StrategySyncStart()
+	w.pending.BuffersAlloc += int64(recentAlloc)
+
+	// bgwriter_lru_maxpages が 0 なら書き出さない。次に有効にしたときは最初からやり直す
+	maxPages := guc.BgWriterLRUMaxPages.Get()
+	if maxPages <= 0 {
+		w.savedInfoValid = false
+		return true
+	}
+
+	// 前回からスイープが進んだ数と、次に調べる位置がスイープより何バッファ先にあるかを求める
+	var strategyDelta, bufsToLap int
+	if w.savedInfoValid {
+		passesDelta := int(int32(strategyPasses - w.prevStrategyPasses))
+		strategyDelta = strategyBuf - w.prevStrategyBuf + passesDelta*nBuffers
+
+		switch {
+		case int32(w.nextPasses-strategyPasses) > 0:
+			// 前回の周期でスイープの一周先まで調べている
+			bufsToLap = strategyBuf - w.nextToClean
+		case w.nextPasses == strategyPasses && w.nextToClean >= strategyBuf:
+			bufsToLap = nBuffers - (w.nextToClean - strategyBuf)
+		default:
+			// スイープに追い越されたので、スイープの位置から調べ直す
+			w.nextToClean = strategyBuf
+			w.nextPasses = strategyPasses
+			bufsToLap = nBuffers
+		}
+	} else {
+		w.nextToClean = strategyBuf
+		w.nextPasses = strategyPasses
+		bufsToLap = nBuffers
+	}
+	w.prevStrategyBuf = strategyBuf
+	w.prevStrategyPasses = strategyPasses
+	w.savedInfoValid = true
+
+	// スイープが再利用できるバッファを1つ見つけるまでに調べた数を平滑化する
+	if strategyDelta > 0 && recentAlloc > 0 {
+		scansPerAlloc := float64(strategyDelta) / float64(recentAlloc)
+		w.smoothedDensity += (scansPerAlloc - w.smoothedDensity) / smoothingSamples
+	}
+	// 前回までに調べ終えた範囲に残っている、再利用できるバッファの数の見積もり
+	bufsAhead := nBuffers - bufsToLap
+	reusableEst := float64(bufsAhead) / w.smoothedDensity
+
+	// 割り当てが増えたときはすぐに追い、減ったときはゆっくり下げる
+	if w.smoothedAlloc <= float64(recentAlloc) {
+		w.smoothedAlloc = float64(recentAlloc)
+	} else {
+		w.smoothedAlloc += (float64(recentAlloc) - w.smoothedAlloc) / smoothingSamples
+	}
+	upcomingAllocEst := int(w.smoothedAlloc * guc.BgWriterLRUMultiplier.Get())
+	// 割り当てがない状態が続いて見積もりが 0 になったら、平滑値も 0 にして小さな値が残らないようにする
+	if upcomingAllocEst == 0 {
+		w.smoothedAlloc = 0
+	}
+
+	// 割り当てがなくても、scanWholePool の間に共有バッファ全体を調べるだけは進める
+	delay := time.Duration(guc.BgWriterDelay.Get()) * time.Millisecond
+	minScanBuffers := int(float64(nBuffers) / (float64(scanWholePool) / float64(delay)))
+	if float64(upcomingAllocEst) < float64(minScanBuffers)+reusableEst {
+		upcomingAllocEst = int(float64(minScanBuffers) + reusableEst)
+	}
+
+	// 見積もった数の再利用できるバッファが揃うか、スイープを一周先まで追い越すまで調べる
+	numToScan := bufsToLap
+	numWritten := 0
+	reusable := int(reusableEst)
+	for numToScan > 0 && reusable < upcomingAllocEst {
+		ok, written := pool.SyncOneBuffer(w.nextToClean)
+		w.nextToClean++
+		if w.nextToClean >= nBuffers {
+			w.nextToClean = 0
+			w.nextPasses++
+		}
+		numToScan--
+
+		if written {
+			reusable++
+			numWritten++
+			if numWritten >= maxPages {
+				w.pending.MaxwrittenClean++
+				break
+			}
+		} else if ok {
+			reusable++
+		}
+	}
+	w.pending.BuffersClean += int64(numWritten)
+
+	// 今回調べた範囲からも密度を見積もる。割り当てが少ないときに密度が古い値のままにならないようにする
+	newStrategyDelta := bufsToLap - numToScan
+	newRecentAlloc := reusable - int(reusableEst)
+	if newStrategyDelta > 0 && newRecentAlloc > 0 {
+		scansPerAlloc := float64(newStrategyDelta) / float64(newRecentAlloc)
+		w.smoothedDensity += (scansPerAlloc - w.smoothedDensity) / smoothingSamples
+	}
+
+	return bufsToLap == 0 && recentAlloc == 0
+}
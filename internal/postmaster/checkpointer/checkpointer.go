@@ -0,0 +1,316 @@
+package checkpointer
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/pgstat"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// checkpointer (postmaster/checkpointer.c 相当)
+// ----------------------------------------------------------------
+// データディレクトリを使う postmaster が起動するバックグラウンドのゴルーチンで、チェックポイントを
+// 行う。前回のチェックポイントから checkpoint_timeout が経つか、バックエンドが CHECKPOINT などで
+// RequestCheckpoint を呼ぶと、transam.CreateCheckPoint でチェックポイントを行う。
+//
+// 急がないチェックポイントでは、I/O が集中しないように、次のチェックポイントまでの時間の
+// checkpoint_completion_target の割合をかけて書き出す (CheckpointWriteDelay)。
+//
+// postmaster は全てのバックエンドが終わってから Shutdown を呼び、checkpointer は
+// シャットダウンのチェックポイントを行って終わる。
+//
+// 始めたチェックポイントの数は、checkpoint_timeout によるものと要求によるものに分けて pgstat に
+// 加える。書き出したバッファの数と時間は transam.CreateCheckPoint が加える。
+//
+// checkpointer が動いていない場合 (単一ユーザーモードなど) は、RequestCheckpoint を呼んだ
+// プロセスがその場でチェックポイントを行う。
+
+// ErrCheckpointFailed は要求したチェックポイントが失敗したことを表す。原因はサーバーログに出す。
+var ErrCheckpointFailed = errors.New("checkpoint request failed")
+
+// checkpointerShmem は checkpointer とバックエンドが共有する状態 (CheckpointerShmem 相当)
+var checkpointerShmem struct {
+	sync.Mutex
+	// running は checkpointer が動いていることを表す (checkpointer_pid 相当)
+	running bool
+	// ckptStarted と ckptDone は始めたチェックポイントと終えたチェックポイントの数、ckptFailed は
+	// 失敗したチェックポイントの数 (ckpt_started、ckpt_done、ckpt_failed 相当)
+	ckptStarted, ckptDone, ckptFailed int
+	// ckptFlags はまだ始めていない要求のフラグを合わせたもの (ckpt_flags 相当)
+	ckptFlags int
+	// started と done はチェックポイントを始めたとき、終えたときに閉じて作り直す
+	// (start_cv、done_cv 相当)
+	started, done chan struct{}
+
+	// wakeup は要求が来たことを checkpointer に知らせる (checkpointer の latch 相当)
+	wakeup chan struct{}
+	// shutdownRequested はシャットダウンのチェックポイントの要求 (ShutdownXLOGPending 相当)。
+	// stopping は要求を送ったことを表す
+	shutdownRequested chan struct{}
+	stopping          bool
+	// exited は checkpointer が終わったときに閉じ、exitErr にシャットダウンのチェックポイントの結果を持つ
+	exited  chan struct{}
+	exitErr error
+}
+
+// ckptStartTime と ckptFlags は実行中のチェックポイントを始めた時刻とフラグ (ckpt_start_time 相当)。
+// checkpointer のゴルーチンだけが使う。
+var (
+	ckptStartTime time.Time
+	ckptFlags     int
+)
+
+// Start は checkpointer を起動する (StartCheckpointer 相当)
+func Start() {
+	sh := &checkpointerShmem
+	sh.Lock()
+	defer sh.Unlock()
+	if sh.running {
+		return
+	}
+	sh.running = true
+	sh.stopping = false
+	sh.started = make(chan struct{})
+	sh.done = make(chan struct{})
+	sh.wakeup = make(chan struct{}, 1)
+	sh.shutdownRequested = make(chan struct{})
+	sh.exited = make(chan struct{})
+	sim.Go(checkpointerMain)
+}
+
+// Shutdown は checkpointer にシャットダウンのチェックポイントを要求し、終わるのを待つ
+// (postmaster が checkpointer に SIGUSR2 を送る処理相当)。チェックポイントが失敗すればエラーを返す。
+// checkpointer が動いていなければ何もしない。
+func Shutdown() error {
+	sh := &checkpointerShmem
+	sh.Lock()
+	if !sh.running {
+		sh.Unlock()
+		return nil
+	}
+	if !sh.stopping {
+		sh.stopping = true
+		close(sh.shutdownRequested)
+	}
+	exited := sh.exited
+	sh.Unlock()
+
+	sim.Wait(-1, exited)
+	sh.Lock()
+	defer sh.Unlock()
+	return sh.exitErr
+}
+
+// checkpointerMain は checkpointer のメインループ (CheckpointerMain 相当)
+func checkpointerMain() {
+	sh := &checkpointerShmem
+	lastCheckpointTime := sim.Now()
+
+	for {
+		// 前回のチェックポイントから checkpoint_timeout が経つか、要求が来るまで待つ。
+		// 設定の読み直しに合わせるため、待つ時間は毎回計算し直す
+		timeout := time.Duration(guc.CheckPointTimeout.Get()) * time.Second
+		if sim.Wait(max(timeout-sim.Since(lastCheckpointTime), 0), sh.shutdownRequested, sh.wakeup) == 0 {
+			shutdownCheckpoint()
+			return
+		}
+
+		sh.Lock()
+		flags := sh.ckptFlags
+		sh.ckptFlags = 0
+		sh.Unlock()
+		doCheckpoint := flags != 0
+		// 要求と checkpoint_timeout が重なれば、要求で始めたものとして数える
+		var pending pgstat.CheckpointerStats
+		if doCheckpoint {
+			pending.NumRequested++
+		}
+
+		now := sim.Now()
+		if now.Sub(lastCheckpointTime) >= timeout {
+			if !doCheckpoint {
+				pending.NumTimed++
+			}
+			doCheckpoint = true
+			flags |= transam.CheckpointCauseTime
+		}
+		if !doCheckpoint {
+			continue
+		}
+		pgstat.ReportCheckpointer(pending)
+
+		// 要求したバックエンドに、チェックポイントを始めたことを知らせる
+		sh.Lock()
+		sh.ckptStarted++
+		close(sh.started)
+		sh.started = make(chan struct{})
+		sh.Unlock()
+
+		ckptStartTime = sim.Now()
+		ckptFlags = flags
+		err := transam.CreateCheckPoint(flags, checkpointWriteDelay)
+		if err != nil {
+			errutil.Report(transam.CheckpointerProc, errutil.Elog(errutil.Error, "%v", err))
+		}
+
+		sh.Lock()
+		if err != nil {
+			sh.ckptFailed++
+		}
+		sh.ckptDone = sh.ckptStarted
+		close(sh.done)
+		sh.done = make(chan struct{})
+		sh.Unlock()
+
+		// 失敗した場合も、次のチェックポイントは checkpoint_timeout の後に行う
+		lastCheckpointTime = now
+	}
+}
+
+// shutdownCheckpoint はシャットダウンのチェックポイントを行い、checkpointer を終える (ShutdownXLOG 相当)
+func shutdownCheckpoint() {
+	sh := &checkpointerShmem
+	errutil.Report(transam.CheckpointerProc, errutil.Elog(errutil.Log, "shutting down"))
+	ckptStartTime = sim.Now()
+	ckptFlags = transam.CheckpointIsShutdown | transam.CheckpointImmediate
+	err := transam.CreateCheckPoint(ckptFlags, nil)
+	if err != nil {
+		errutil.Report(transam.CheckpointerProc, errutil.Elog(errutil.Error, "%v", err))
+	}
+
+	// 要求を待っているバックエンドがいれば起こし、失敗を返させる
+	sh.Lock()
+	defer sh.Unlock()
+	sh.running = false
+	sh.exitErr = err
+	close(sh.started)
+	close(sh.done)
+	close(sh.exited)
+}
+
+// RequestCheckpoint はチェックポイントを要求する (RequestCheckpoint 相当)。flags に
+// transam.CheckpointWait を含めると、要求の後に始まったチェックポイントが終わるまで待ち、
+// 失敗すれば ErrCheckpointFailed を返す。待つ間は interrupts で取り消しを受け付け、待っているものを
+// waitEvent に記録する。
+func RequestCheckpoint(flags int, interrupts *miscadmin.Interrupts, waitEvent *waitevent.Slot) error {
+	sh := &checkpointerShmem
+	sh.Lock()
+	if !sh.running {
+		sh.Unlock()
+		// checkpointer がなければ、その場で急いで行う
+		return transam.CreateCheckPoint(flags|transam.CheckpointImmediate, nil)
+	}
+	if sh.stopping {
+		sh.Unlock()
+		return ErrCheckpointFailed
+	}
+	oldStarted, oldFailed := sh.ckptStarted, sh.ckptFailed
+	sh.ckptFlags |= flags | transam.CheckpointRequested
+	sh.Unlock()
+
+	select {
+	case sh.wakeup <- struct{}{}:
+	default:
+	}
+	if flags&transam.CheckpointWait == 0 {
+		return nil
+	}
+
+	// 要求の後にチェックポイントが始まるのを待ち、それが終わるのを待つ
+	var newStarted int
+	for {
+		sh.Lock()
+		if !sh.running {
+			sh.Unlock()
+			return ErrCheckpointFailed
+		}
+		if sh.ckptStarted != oldStarted {
+			newStarted = sh.ckptStarted
+			sh.Unlock()
+			break
+		}
+		started := sh.started
+		sh.Unlock()
+		waitEvent.Start(waitevent.IPCCheckpointStart)
+		err := waitOrInterrupt(started, interrupts)
+		waitEvent.End()
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		sh.Lock()
+		if !sh.running {
+			sh.Unlock()
+			return ErrCheckpointFailed
+		}
+		if sh.ckptDone >= newStarted {
+			failed := sh.ckptFailed != oldFailed
+			sh.Unlock()
+			if failed {
+				return ErrCheckpointFailed
+			}
+			return nil
+		}
+		done := sh.done
+		sh.Unlock()
+		waitEvent.Start(waitevent.IPCCheckpointDone)
+		err := waitOrInterrupt(done, interrupts)
+		waitEvent.End()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// waitOrInterrupt は ch が閉じるまで待つ。待つ間も取り消しとセッションの終了の要求を確かめる
+// (ConditionVariableSleep 相当)。
+func waitOrInterrupt(ch <-chan struct{}, interrupts *miscadmin.Interrupts) error {
+	for sim.Wait(100*time.Millisecond, ch) < 0 {
+		if err := interrupts.CheckForInterrupts(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkpointWriteDelay はチェックポイントが予定より進んでいれば休む (CheckpointWriteDelay 相当)。
+// progress はチェックポイントの処理を終えた割合。急ぐチェックポイントや、急ぐチェックポイントか
+// シャットダウンが要求されている間は休まない。
+func checkpointWriteDelay(progress float64) {
+	for ckptFlags&transam.CheckpointImmediate == 0 && !immediateCheckpointRequested() && isCheckpointOnSchedule(progress) {
+		sim.Sleep(100 * time.Millisecond)
+	}
+}
+
+// immediateCheckpointRequested は急ぐチェックポイントかシャットダウンが要求されているかを返す
+// (ImmediateCheckpointRequested 相当)
+func immediateCheckpointRequested() bool {
+	sh := &checkpointerShmem
+	sh.Lock()
+	defer sh.Unlock()
+	select {
+	case <-sh.shutdownRequested:
+		return true
+	default:
+	}
+	return sh.ckptFlags&transam.CheckpointImmediate != 0
+}
+
+// isCheckpointOnSchedule はチェックポイントが予定より進んでいるかを返す (IsCheckpointOnSchedule 相当)。
+// 予定では、次のチェックポイントまでの時間の checkpoint_completion_target の割合で全てを終える。
+// WAL がないため、C言語版の WAL の量による予定は使わない。
+func isCheckpointOnSchedule(progress float64) bool {
+	progress *= guc.CheckPointCompletionTarget.Get()
+	timeout := time.Duration(guc.CheckPointTimeout.Get()) * time.Second
+	elapsed := float64(sim.Since(ckptStartTime)) / float64(timeout)
+	return progress >= elapsed
+}
@@ -0,0 +1,99 @@
+package checkpointer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/pgstat"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// setGUC は設定を変え、テストの後に元に戻す
+func setGUC(t *testing.T, name, value string) {
+	t.Helper()
+	prev, _ := guc.GetConfigOption(name)
+	if err := guc.SetConfigOption(name, value, guc.PGCSighup, guc.PGCSFile); err != nil {
+		t.Fatalf("SetConfigOption(%s) = %v", name, err)
+	}
+	t.Cleanup(func() { guc.SetConfigOption(name, prev, guc.PGCSighup, guc.PGCSFile) })
+}
+
+// startSim は seed のシミュレーションで checkpointer を起動する
+func startSim(t *testing.T, seed uint64) *sim.Sim {
+	t.Helper()
+	s := sim.NewSim(seed)
+	restore := sim.Set(s)
+	pgstat.ResetCheckpointer()
+	Start()
+	t.Cleanup(func() {
+		sim.Go(func() { Shutdown() })
+		s.Run()
+		restore()
+	})
+	return s
+}
+
+func TestTimedCheckpointsFollowCheckpointTimeout(t *testing.T) {
+	setGUC(t, "checkpoint_timeout", "30s")
+	s := startSim(t, 1)
+
+	// 30 秒、60 秒、90 秒にチェックポイントを始める
+	s.RunFor(95 * time.Second)
+	if got := pgstat.FetchStatCheckpointer().NumTimed; got != 3 {
+		t.Errorf("timed checkpoints after 95s = %d, want 3", got)
+	}
+
+	// 要求したチェックポイントの後は、そこから checkpoint_timeout を数え直す
+	var requestErr error
+	sim.Go(func() {
+		requestErr = RequestCheckpoint(transam.CheckpointImmediate|transam.CheckpointWait, nil, nil)
+	})
+	s.RunFor(time.Second)
+	if requestErr != nil {
+		t.Fatalf("RequestCheckpoint = %v", requestErr)
+	}
+	stats := pgstat.FetchStatCheckpointer()
+	if stats.NumRequested != 1 || stats.NumTimed != 3 {
+		t.Errorf("after request: requested = %d, timed = %d; want 1, 3", stats.NumRequested, stats.NumTimed)
+	}
+	s.RunFor(25 * time.Second) // 121 秒。90 秒から数えれば既に始めている
+	if got := pgstat.FetchStatCheckpointer().NumTimed; got != 3 {
+		t.Errorf("timed checkpoints 26s after the request = %d, want 3", got)
+	}
+	s.RunFor(5 * time.Second)
+	if got := pgstat.FetchStatCheckpointer().NumTimed; got != 4 {
+		t.Errorf("timed checkpoints 31s after the request = %d, want 4", got)
+	}
+}
+
+func TestCheckpointWriteDelaySpreadsWrites(t *testing.T) {
+	setGUC(t, "checkpoint_timeout", "100s")
+	setGUC(t, "checkpoint_completion_target", "0.5")
+	s := startSim(t, 1)
+
+	// 半分を書き終えた時点の予定は 100 秒 × 0.5 × 0.5 = 25 秒。それまで休む
+	finished := time.Duration(-1)
+	sim.Go(func() {
+		ckptStartTime, ckptFlags = sim.Now(), 0
+		checkpointWriteDelay(0.5)
+		finished = sim.Since(ckptStartTime)
+	})
+	s.RunFor(30 * time.Second)
+	if finished < 25*time.Second || finished > 25*time.Second+100*time.Millisecond {
+		t.Errorf("checkpointWriteDelay(0.5) returned after %v, want about 25s", finished)
+	}
+
+	// 急ぐチェックポイントでは休まない
+	finished = -1
+	sim.Go(func() {
+		ckptStartTime, ckptFlags = sim.Now(), transam.CheckpointImmediate
+		checkpointWriteDelay(0.5)
+		finished = sim.Since(ckptStartTime)
+	})
+	s.RunFor(time.Second)
+	if finished != 0 {
+		t.Errorf("immediate checkpointWriteDelay(0.5) returned after %v, want 0", finished)
+	}
+}
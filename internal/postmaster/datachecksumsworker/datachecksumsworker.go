@@ -0,0 +1,281 @@
+package datachecksumsworker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/postmaster/checkpointer"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/buffer"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/page"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/smgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/injection"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// ----------------------------------------------------------------
+// データチェックサムのオンライン有効化 (datachecksumsworker.c 相当)
+// ----------------------------------------------------------------
+// 稼働中のクラスタでデータチェックサムを有効にする。
+//
+//  1. 状態を inprogress-on にする。以降に書き出されるページにはチェックサムを付け、
+//     読み込み時の検証はまだ行わない。
+//  2. バックグラウンドワーカーが全てのリレーションの全ブロックを共有バッファに読み、
+//     汚れた印を付ける。チェックサムはバッファマネージャーが書き出すときに付ける。
+//     共有バッファを追い出さないよう VACUUM と同じリングバッファを使い、負荷を抑えるため、
+//     一定数のブロックごとに休む。
+//  3. 全て読み終えたらチェックポイントで汚れたページを全て書き出し、状態を on にして
+//     制御ファイルに記録する。以降は読み込み時に検証する。
+//
+// C言語版は各ページの全ページイメージを WAL に記録するが、リレーションのページの変更を
+// まだ WAL に記録しないため、on にする前のチェックポイントでディスクに届いたことを保証する。
+//
+// 無効化は逆に inprogress-off を経て off にする。こちらは既存のページを書き換える必要がない。
+
+// dataChecksums は動作中のワーカー
+var dataChecksums struct {
+	sync.Mutex
+	interrupts *miscadmin.Interrupts
+	done       chan struct{}
+}
+
+// EnableDataChecksums はデータチェックサムの有効化を始める (EnableDataChecksums 相当)。
+// costLimit ブロックを処理するごとに costDelay 休む。costDelay が 0 なら休まない。
+// 処理はバックグラウンドで続き、この関数はすぐに戻る。
+func EnableDataChecksums(costDelay time.Duration, costLimit int) error {
+	if costDelay < 0 {
+		return errutil.New(errutil.Error, errcodes.InvalidParameterValue, "cost delay cannot be a negative value")
+	}
+	if costLimit <= 0 {
+		return errutil.New(errutil.Error, errcodes.InvalidParameterValue, "cost limit must be greater than zero")
+	}
+	dataChecksums.Lock()
+	defer dataChecksums.Unlock()
+
+	switch page.GetDataChecksumState() {
+	case page.DataChecksumsOn, page.DataChecksumsInProgressOn:
+		// 既に有効、または有効化の途中
+		return nil
+	case page.DataChecksumsInProgressOff:
+		return errutil.New(errutil.Error, errcodes.ObjectNotInPrerequisiteState, "data checksums are being disabled")
+	}
+
+	if err := transam.SetDataChecksumState(page.DataChecksumsInProgressOn); err != nil {
+		return err
+	}
+	logProc := errutil.NewAuxProcInfo("datachecksums worker")
+	interrupts := &miscadmin.Interrupts{}
+	w := &checksumsWorker{
+		costDelay:  costDelay,
+		costLimit:  costLimit,
+		interrupts: interrupts,
+		buffers:    buffer.NewBackend(lmgr.NewProc(logProc.Pid, interrupts, nil, nil, logProc), nil, nil),
+		strategy:   buffer.GetAccessStrategy(buffer.BASVacuum),
+		logProc:    logProc,
+	}
+	dataChecksums.interrupts = w.interrupts
+	dataChecksums.done = make(chan struct{})
+	done := dataChecksums.done
+	sim.Go(func() { w.main(done) })
+	return nil
+}
+
+// DisableDataChecksums はデータチェックサムを無効にする (DisableDataChecksums 相当)。
+// 有効化の途中であれば、ワーカーを止めてから無効にする。
+func DisableDataChecksums() error {
+	if page.GetDataChecksumState() == page.DataChecksumsOff {
+		return nil
+	}
+	Stop()
+
+	dataChecksums.Lock()
+	defer dataChecksums.Unlock()
+	// 他のバックエンドがページの検証をやめてからチェックサムの付与をやめる
+	if err := transam.SetDataChecksumState(page.DataChecksumsInProgressOff); err != nil {
+		return err
+	}
+	return transam.SetDataChecksumState(page.DataChecksumsOff)
+}
+
+// Stop は動作中のワーカーを止め、終わるのを待つ。状態は変えない。シャットダウンの前と
+// DisableDataChecksums が呼ぶ。途中の状態は制御ファイルに記録しないため、再起動すると off に戻る。
+func Stop() {
+	dataChecksums.Lock()
+	interrupts, done := dataChecksums.interrupts, dataChecksums.done
+	dataChecksums.Unlock()
+	if interrupts != nil {
+		interrupts.SetQueryCancelPending()
+		<-done
+	}
+}
+
+// checksumsWorker は全てのブロックにチェックサムを設定するワーカー。
+type checksumsWorker struct {
+	costDelay  time.Duration
+	costLimit  int
+	costBlocks int
+	interrupts *miscadmin.Interrupts
+	buffers    *buffer.Backend
+	strategy   *buffer.BufferAccessStrategy
+	// logProc はサーバーログに出すワーカーの情報
+	logProc *errutil.ProcInfo
+}
+
+// main はワーカーの本体 (DataChecksumsWorkerMain 相当)
+func (w *checksumsWorker) main(done chan struct{}) {
+	defer close(done)
+	err := w.processAll()
+	w.buffers.ReleaseAll()
+	if err == nil {
+		// チェックサムを付けたページをディスクに書き出してから on にする
+		err = checkpointer.RequestCheckpoint(transam.CheckpointForce|transam.CheckpointWait|transam.CheckpointImmediate, w.interrupts, nil)
+	}
+
+	dataChecksums.Lock()
+	defer dataChecksums.Unlock()
+	dataChecksums.interrupts = nil
+
+	switch {
+	case errors.Is(err, miscadmin.ErrQueryCanceled):
+		// Stop による中断。状態は呼び出し側が変更する。
+		errutil.Report(w.logProc, errutil.Elog(errutil.Log, "data checksums worker was canceled"))
+	case err != nil:
+		// 途中までのページは検証されないよう off に戻す。もう一度有効化を要求すればやり直せる
+		errutil.Report(w.logProc, errutil.Elog(errutil.Error, "could not enable data checksums: %s", err.Error()))
+		if err := transam.SetDataChecksumState(page.DataChecksumsOff); err != nil {
+			errutil.Report(w.logProc, errutil.Elog(errutil.Error, "%s", err.Error()))
+		}
+	default:
+		if err := transam.SetDataChecksumState(page.DataChecksumsOn); err != nil {
+			errutil.Report(w.logProc, errutil.Elog(errutil.Error, "%s", err.Error()))
+			return
+		}
+		errutil.Report(w.logProc, errutil.Elog(errutil.Log, "data checksums are now enabled"))
+	}
+}
+
+// relationFork はチェックサムを設定するリレーションのフォーク1つ
+type relationFork struct {
+	rlocator storage.RelFileLocator
+	forkNum  storage.ForkNumber
+}
+
+// processAll は全てのリレーションを処理する (ProcessAllDatabases 相当)。
+func (w *checksumsWorker) processAll() error {
+	rels, err := listRelations(guc.DataDirectory.Get())
+	if err != nil {
+		return err
+	}
+	for _, rel := range rels {
+		if err := w.processRelation(rel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listRelations はデータディレクトリの base/<データベース>/ と global/ にあるリレーションの
+// フォークを返す。リレーションのカタログがまだないため、ファイルの名前から求める。
+// ブートストラップのカタログはページの形式でないテキストのファイルのため、
+// FirstNormalObjectID より小さいリレーション番号のファイルは除く。
+func listRelations(dataDir string) ([]relationFork, error) {
+	dirs := map[string]catalog.Oid{filepath.Join(dataDir, "global"): catalog.InvalidOid}
+	dbs, err := os.ReadDir(filepath.Join(dataDir, "base"))
+	if err != nil {
+		return nil, err
+	}
+	for _, db := range dbs {
+		if oid, err := strconv.ParseUint(db.Name(), 10, 32); err == nil && db.IsDir() {
+			dirs[filepath.Join(dataDir, "base", db.Name())] = catalog.Oid(oid)
+		}
+	}
+
+	var rels []relationFork
+	for dir, dbOid := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if rel, ok := parseRelationFileName(e.Name()); ok {
+				rel.rlocator.DbOid = dbOid
+				rels = append(rels, rel)
+			}
+		}
+	}
+	return rels, nil
+}
+
+// parseRelationFileName は "<リレーション番号>" か "<リレーション番号>_<フォーク>" の名前を
+// 解析する (parse_filename_for_nontemp_relation 相当)。2つ目以降のセグメント "<番号>.<n>" と
+// 一時リレーションは除く。
+func parseRelationFileName(name string) (relationFork, bool) {
+	num, fork, hasFork := strings.Cut(name, "_")
+	relNumber, err := strconv.ParseUint(num, 10, 32)
+	if err != nil || catalog.Oid(relNumber) < catalog.FirstNormalObjectID {
+		return relationFork{}, false
+	}
+	rel := relationFork{rlocator: storage.RelFileLocator{RelNumber: catalog.Oid(relNumber)}, forkNum: storage.MainForkNum}
+	if !hasFork {
+		return rel, true
+	}
+	for f := storage.MainForkNum; f <= storage.MaxForkNum; f++ {
+		if f.String() == fork {
+			rel.forkNum = f
+			return rel, true
+		}
+	}
+	return relationFork{}, false
+}
+
+// processRelation はフォーク1つの全ブロックを共有バッファに読み、汚れた印を付ける
+// (ProcessSingleRelationFork 相当)
+func (w *checksumsWorker) processRelation(rel relationFork) error {
+	nblocks, err := smgr.Nblocks(rel.rlocator, rel.forkNum)
+	if err != nil {
+		return err
+	}
+	for blkno := storage.BlockNumber(0); blkno < nblocks; blkno++ {
+		if err := w.interrupts.CheckForInterrupts(); err != nil {
+			return err
+		}
+		if err := injection.Run("datachecksums-process-block"); err != nil {
+			return err
+		}
+		buf, err := w.buffers.ReadBufferExtended(rel.rlocator, rel.forkNum, blkno, buffer.RBMNormal, w.strategy)
+		if err != nil {
+			return err
+		}
+		w.buffers.LockBuffer(buf, buffer.BufferLockExclusive)
+		// 未初期化のページにはチェックサムを付けないため、書き出す必要もない
+		if !page.IsNew(w.buffers.BufferGetPage(buf)) {
+			w.buffers.MarkBufferDirty(buf)
+		}
+		w.buffers.UnlockReleaseBuffer(buf)
+		w.delay()
+	}
+	return nil
+}
+
+// delay は costLimit ブロックごとに costDelay 休む (vacuum_delay_point 相当)
+func (w *checksumsWorker) delay() {
+	w.costBlocks++
+	if w.costBlocks >= w.costLimit {
+		w.costBlocks = 0
+		if w.costDelay > 0 {
+			sim.Sleep(w.costDelay)
+		}
+	}
+}
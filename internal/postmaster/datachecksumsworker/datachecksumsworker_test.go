@@ -0,0 +1,175 @@
+package datachecksumsworker
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/regress/cluster"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/page"
+)
+
+func TestParseRelationFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want relationFork
+		ok   bool
+	}{
+		{"16384", relationFork{rlocator: storage.RelFileLocator{RelNumber: 16384}, forkNum: storage.MainForkNum}, true},
+		{"16384_fsm", relationFork{rlocator: storage.RelFileLocator{RelNumber: 16384}, forkNum: storage.FsmForkNum}, true},
+		{"16384_vm", relationFork{rlocator: storage.RelFileLocator{RelNumber: 16384}, forkNum: storage.VisibilityMapForkNum}, true},
+		{"16384.1", relationFork{}, false},
+		{"16384_bogus", relationFork{}, false},
+		{"t3_16384", relationFork{}, false},
+		{"1247", relationFork{}, false},
+		{"PG_VERSION", relationFork{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRelationFileName(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRelationFileName(%q) = %v, %v; want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// requireBinDir はサーバーのプログラムのディレクトリを返す。PG_TEST_BINDIR がなければ
+// postgres と initdb をビルドする。initdb は root では動かないため、root ではテストを飛ばす。
+func requireBinDir(t *testing.T) string {
+	t.Helper()
+	if os.Geteuid() == 0 {
+		t.Skip("initdb cannot be run as root")
+	}
+	if dir := os.Getenv("PG_TEST_BINDIR"); dir != "" {
+		return dir
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"postgres", "initdb"} {
+		out, err := exec.Command("go", "build", "-o", filepath.Join(dir, name),
+			"github.com/Tsubasa-2005/go-postgres/cmd/"+name).CombinedOutput()
+		if err != nil {
+			t.Fatalf("could not build %s: %v\n%s", name, err, out)
+		}
+	}
+	return dir
+}
+
+func safePsql(t *testing.T, n *cluster.Node, sql string) string {
+	t.Helper()
+	out, err := n.SafePsql("postgres", sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// writeRelationFile はチェックサムのないページを2つ持つリレーションのファイルを作る。
+// SQL でまだリレーションにページを書けないため、停止中のクラスタに直接置く。
+func writeRelationFile(t *testing.T, path string) {
+	t.Helper()
+	data := make([]byte, 2*pgconfig.BlckSz)
+	for blkno := range 2 {
+		p := data[blkno*pgconfig.BlckSz : (blkno+1)*pgconfig.BlckSz]
+		page.Init(p, 0)
+		if _, err := page.AddItem(p, []byte("tuple"), storage.InvalidOffsetNumber, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnableAndDisableOnline(t *testing.T) {
+	bindir := requireBinDir(t)
+	n, err := cluster.New("main", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.BinDir = bindir
+	t.Cleanup(func() { n.Stop("immediate") })
+	if err := n.Init(); err != nil {
+		t.Fatal(err)
+	}
+	dbs, err := filepath.Glob(filepath.Join(n.DataDir(), "base", "*"))
+	if err != nil || len(dbs) == 0 {
+		t.Fatalf("no database directory: %v", err)
+	}
+	relPath := filepath.Join(dbs[0], "16384")
+	writeRelationFile(t, relPath)
+
+	if err := n.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if got := safePsql(t, n, "SHOW data_checksums"); got != "off" {
+		t.Fatalf("data_checksums = %q, want off", got)
+	}
+	safePsql(t, n, "SELECT pg_enable_data_checksums(0, 1)")
+	if !n.PollQueryUntil("postgres", "SHOW data_checksums", "on") {
+		t.Fatal("data checksums were not enabled")
+	}
+
+	// 書き戻したページにチェックサムが付き、再起動しても on のまま
+	if err := n.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if got := safePsql(t, n, "SHOW data_checksums"); got != "on" {
+		t.Errorf("data_checksums after restart = %q, want on", got)
+	}
+	data, err := os.ReadFile(relPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for blkno := range 2 {
+		if !page.VerifyChecksum(data[blkno*pgconfig.BlckSz:(blkno+1)*pgconfig.BlckSz], uint32(blkno)) {
+			t.Errorf("block %d has no valid checksum", blkno)
+		}
+	}
+
+	safePsql(t, n, "SELECT pg_disable_data_checksums()")
+	if got := safePsql(t, n, "SHOW data_checksums"); got != "off" {
+		t.Errorf("data_checksums after disable = %q, want off", got)
+	}
+	if err := n.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if got := safePsql(t, n, "SHOW data_checksums"); got != "off" {
+		t.Errorf("data_checksums after restart = %q, want off", got)
+	}
+}
+
+func TestEnableRequiresSuperuser(t *testing.T) {
+	bindir := requireBinDir(t)
+	n, err := cluster.New("main", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.BinDir = bindir
+	t.Cleanup(func() { n.Stop("immediate") })
+	if err := n.Init("-k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if got := safePsql(t, n, "SHOW data_checksums"); got != "on" {
+		t.Errorf("data_checksums with initdb -k = %q, want on", got)
+	}
+	safePsql(t, n, "CREATE ROLE bob LOGIN")
+	conn, err := libpq.Connect(n.Connstr("postgres") + " user=bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	res, err := conn.Exec("SELECT pg_disable_data_checksums()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := res.Err(); e == nil || !strings.Contains(e.Error(), "must be superuser") {
+		t.Errorf("pg_disable_data_checksums as non-superuser = %v, want must be superuser", e)
+	}
+}
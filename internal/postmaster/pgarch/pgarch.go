@@ -0,0 +1,311 @@
+package pgarch
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/pgstat"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// ----------------------------------------------------------------
+// アーカイバ (postmaster/pgarch.c、archive/shell_archive.c 相当)
+// ----------------------------------------------------------------
+// archive_mode が off でなければ postmaster が起動するバックグラウンドのゴルーチンで、書き終えた
+// WAL セグメントを archive_command でアーカイブする。アーカイブを待つセグメントは
+// pg_wal/archive_status に <セグメント>.ready のファイルがあることで表し、アーカイブできたら
+// .done に名前を変える。
+//
+// autowakeInterval ごとか、Wakeup で起こされるたびに .ready のファイルを探し、タイムラインの
+// 履歴ファイルを先に、他は名前の順にアーカイブする。コマンドが失敗したら1秒おいて
+// numArchiveRetries 回まで試し、それでも失敗すれば WARNING を出して次に起こされるまで
+// そのセグメントから先を後回しにする。成功と失敗は pg_stat_archiver に数える。
+//
+// postmaster はシャットダウンのチェックポイントの後に Stop を呼び、アーカイバは残っている
+// セグメントを一通りアーカイブしてから終わる。WAL がまだ作られないため、.ready のファイルは
+// WAL の管理が作るようになるまで手で置いたものだけである。
+
+const (
+	// autowakeInterval は起こされなくても .ready のファイルを探す間隔 (PGARCH_AUTOWAKE_INTERVAL 相当)
+	autowakeInterval = 60 * time.Second
+	// numArchiveRetries は1つのセグメントのアーカイブを続けて試す回数 (NUM_ARCHIVE_RETRIES 相当)
+	numArchiveRetries = 3
+	// numOrphanCleanupRetries は残った .ready のファイルの削除を続けて試す回数
+	// (NUM_ORPHAN_CLEANUP_RETRIES 相当)
+	numOrphanCleanupRetries = 3
+)
+
+// archiverProc はサーバーログに出すアーカイバの情報
+var archiverProc = errutil.NewAuxProcInfo("archiver")
+
+// pgarchShmem はアーカイバと他のプロセスが共有する状態 (PgArchData 相当)
+var pgarchShmem struct {
+	sync.Mutex
+	running bool
+	// wakeup はアーカイブするセグメントができたことをアーカイバに知らせる (pgarch の latch 相当)
+	wakeup chan struct{}
+	// stop は停止の要求で閉じ、exited はゴルーチンが終わったときに閉じる
+	stop, exited chan struct{}
+}
+
+// Start は dataDir の WAL をアーカイブするアーカイバを起動する (PgArchStartupAllowed と
+// StartArchiver 相当)。archive_mode が off か、既に動いていれば何もしない。
+func Start(dataDir string) {
+	if guc.ArchiveMode.Get() == "off" {
+		return
+	}
+	sh := &pgarchShmem
+	sh.Lock()
+	defer sh.Unlock()
+	if sh.running {
+		return
+	}
+	sh.running = true
+	sh.wakeup = make(chan struct{}, 1)
+	sh.stop = make(chan struct{})
+	sh.exited = make(chan struct{})
+	a := &archiver{dataDir: dataDir}
+	wakeup, stop, exited := sh.wakeup, sh.stop, sh.exited
+	sim.Go(func() { a.main(wakeup, stop, exited) })
+}
+
+// Stop はアーカイバに残りのセグメントをアーカイブさせてから止め、終わるのを待つ
+// (postmaster が pgarch に SIGUSR2 を送る処理相当)。動いていなければ何もしない。
+func Stop() {
+	sh := &pgarchShmem
+	sh.Lock()
+	if !sh.running {
+		sh.Unlock()
+		return
+	}
+	sh.running = false
+	close(sh.stop)
+	exited := sh.exited
+	sh.Unlock()
+	<-exited
+}
+
+// Wakeup はアーカイブするセグメントができたことをアーカイバに知らせる (PgArchWakeup 相当)。
+// WAL の管理がセグメントの .ready のファイルを作った後に呼ぶ。
+func Wakeup() {
+	sh := &pgarchShmem
+	sh.Lock()
+	defer sh.Unlock()
+	if !sh.running {
+		return
+	}
+	select {
+	case sh.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// archiver はアーカイバの状態
+type archiver struct {
+	dataDir string
+}
+
+// main はアーカイバのメインループ (PgArchiverMain と pgarch_MainLoop 相当)
+func (a *archiver) main(wakeup, stop <-chan struct{}, exited chan<- struct{}) {
+	defer close(exited)
+	for {
+		a.copyLoop()
+		if sim.Wait(autowakeInterval, stop, wakeup) == 0 {
+			// シャットダウンのチェックポイントまでに書き終えたセグメントをアーカイブしてから終わる
+			a.copyLoop()
+			return
+		}
+	}
+}
+
+// copyLoop は .ready のファイルがなくなるまでセグメントをアーカイブする (pgarch_ArchiverCopyLoop 相当)。
+// 1つのセグメントのアーカイブが続けて失敗したら、次に起こされるまで後回しにする。
+func (a *archiver) copyLoop() {
+	failedOrphan := 0
+	for {
+		xlog, ok := a.readyXlog()
+		if !ok {
+			return
+		}
+		if guc.ArchiveCommand.Get() == "" {
+			errutil.Report(archiverProc, errutil.Elog(errutil.Warning, "archive_mode enabled, yet archive_command is not set"))
+			return
+		}
+
+		// セグメントがなければ、アーカイブできないため .ready のファイルを削除する
+		if _, err := os.Stat(filepath.Join(a.dataDir, "pg_wal", xlog)); errors.Is(err, fs.ErrNotExist) {
+			ready := statusFilePath(xlog, ".ready")
+			err := os.Remove(filepath.Join(a.dataDir, ready))
+			if err == nil || errors.Is(err, fs.ErrNotExist) {
+				errutil.Report(archiverProc, errutil.Elog(errutil.Warning, "removed orphan archive status file \"%s\"", ready))
+				failedOrphan = 0
+				continue
+			}
+			errutil.Report(archiverProc, errutil.Elog(errutil.Warning, "could not remove file \"%s\": %s", ready, platform.OSErrorMessage(err)))
+			failedOrphan++
+			if failedOrphan >= numOrphanCleanupRetries {
+				errutil.Report(archiverProc, errutil.Elog(errutil.Warning,
+					"removal of orphan archive status file \"%s\" failed too many times, will try again later", ready))
+				return
+			}
+			continue
+		}
+
+		for failures := 0; ; {
+			if a.archiveXlog(xlog) {
+				a.archiveDone(xlog)
+				pgstat.ReportArchiver(xlog, false)
+				break
+			}
+			pgstat.ReportArchiver(xlog, true)
+			failures++
+			if failures >= numArchiveRetries {
+				errutil.Report(archiverProc, errutil.Elog(errutil.Warning,
+					"archiving write-ahead log file \"%s\" failed too many times, will try again later", xlog))
+				return
+			}
+			sim.Sleep(time.Second)
+		}
+	}
+}
+
+// statusFilePath はセグメントのアーカイブの状態のファイルの、データディレクトリからのパスを返す
+// (StatusFilePath 相当)
+func statusFilePath(xlog, suffix string) string {
+	return filepath.Join("pg_wal", "archive_status", xlog+suffix)
+}
+
+// readyXlog は次にアーカイブするセグメントを返す (pgarch_readyXlog 相当)。タイムラインの履歴
+// ファイルは、リカバリがタイムラインを選ぶのに使うため、他のセグメントより先にアーカイブする。
+func (a *archiver) readyXlog() (string, bool) {
+	ents, err := os.ReadDir(filepath.Join(a.dataDir, "pg_wal", "archive_status"))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			errutil.Report(archiverProc, errutil.Elog(errutil.Error, "could not open archive status directory \"%s\": %s",
+				filepath.Join("pg_wal", "archive_status"), platform.OSErrorMessage(err)))
+		}
+		return "", false
+	}
+	var ready []string
+	for _, e := range ents {
+		if name, ok := strings.CutSuffix(e.Name(), ".ready"); ok && validXlogName(name) {
+			ready = append(ready, name)
+		}
+	}
+	if len(ready) == 0 {
+		return "", false
+	}
+	sort.Slice(ready, func(i, j int) bool {
+		hi, hj := strings.HasSuffix(ready[i], ".history"), strings.HasSuffix(ready[j], ".history")
+		if hi != hj {
+			return hi
+		}
+		return ready[i] < ready[j]
+	})
+	return ready[0], true
+}
+
+// validXlogName は .ready のファイルの名前が WAL のファイルの名前になりうるかを返す
+// (pgarch_readyXlog の VALID_XFN_CHARS, MIN_XFN_CHARS, MAX_XFN_CHARS の判定相当)
+func validXlogName(name string) bool {
+	const validChars = "0123456789ABCDEF.history.backup.partial"
+	if len(name) < 16 || len(name) > 40 {
+		return false
+	}
+	for _, c := range name {
+		if !strings.ContainsRune(validChars, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// archiveXlog は archive_command でセグメントをアーカイブし、成功したかを返す
+// (pgarch_archiveXlog と shell_archive_file 相当)。失敗すれば LOG を出す。
+func (a *archiver) archiveXlog(xlog string) bool {
+	command := buildArchiveCommand(guc.ArchiveCommand.Get(), filepath.Join("pg_wal", xlog), xlog)
+	errutil.Report(archiverProc, errutil.Elog(errutil.Debug3, "executing archive command \"%s\"", command))
+
+	cmd := shellCommand(command)
+	// %p はデータディレクトリからのパスのため、データディレクトリで実行する
+	cmd.Dir = a.dataDir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err == nil {
+		errutil.Report(archiverProc, errutil.Elog(errutil.Debug1, "archived write-ahead log file \"%s\"", xlog))
+		return true
+	}
+
+	var edata *errutil.ErrorData
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			edata = errutil.Elog(errutil.Log, "archive command was terminated by signal %d: %s", int(ws.Signal()), ws.Signal())
+		} else {
+			edata = errutil.Elog(errutil.Log, "archive command failed with exit code %d", exitErr.ExitCode())
+		}
+	default:
+		edata = errutil.Elog(errutil.Log, "could not execute archive command: %v", err)
+	}
+	errutil.Report(archiverProc, edata.WithDetail("The failed archive command was: %s", command))
+	return false
+}
+
+// buildArchiveCommand は archive_command の %p をセグメントのパスに、%f をファイル名に、%% を % に
+// 置き換える (BuildRestoreCommand と同じ置き換え相当)
+func buildArchiveCommand(command, path, fileName string) string {
+	var b strings.Builder
+	for i := 0; i < len(command); i++ {
+		if command[i] != '%' || i+1 >= len(command) {
+			b.WriteByte(command[i])
+			continue
+		}
+		switch command[i+1] {
+		case 'p':
+			b.WriteString(path)
+			i++
+		case 'f':
+			b.WriteString(fileName)
+			i++
+		case '%':
+			b.WriteByte('%')
+			i++
+		default:
+			// 知らない指定はそのまま残す
+			b.WriteByte('%')
+		}
+	}
+	return b.String()
+}
+
+// shellCommand はシェルで command を実行するコマンドを作る (system() 相当)
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("/bin/sh", "-c", command)
+}
+
+// archiveDone はセグメントの .ready のファイルを .done に名前を変える (pgarch_archiveDone 相当)
+func (a *archiver) archiveDone(xlog string) {
+	ready := statusFilePath(xlog, ".ready")
+	done := statusFilePath(xlog, ".done")
+	if err := os.Rename(filepath.Join(a.dataDir, ready), filepath.Join(a.dataDir, done)); err != nil {
+		errutil.Report(archiverProc, errutil.Elog(errutil.Warning, "could not rename file \"%s\" to \"%s\": %s",
+			ready, done, platform.OSErrorMessage(err)))
+	}
+}
@@ -1,5 +1,455 @@
 package postmaster
 
-func PostmasterMain(config interface{}) error {
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"os/user"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/auth/hba"
+	"github.com/Tsubasa-2005/go-postgres/internal/backend"
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/crypto"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/postmaster/bgworker"
+	"github.com/Tsubasa-2005/go-postgres/internal/postmaster/bgwriter"
+	"github.com/Tsubasa-2005/go-postgres/internal/postmaster/checkpointer"
+	"github.com/Tsubasa-2005/go-postgres/internal/postmaster/datachecksumsworker"
+	"github.com/Tsubasa-2005/go-postgres/internal/postmaster/pgarch"
+	"github.com/Tsubasa-2005/go-postgres/internal/postmaster/syslogger"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/buffer"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/file"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/ipc"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/smgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/injection"
+)
+
+// PostmasterMain は postmaster のメイン処理 (PostmasterMain 相当)。
+// 待ち受けソケットを作成し、接続を受け付けるたびにバックエンドを起動する。
+// 設定パラメータは呼び出し側がコマンドラインから設定しておき、ここで設定ファイルを読む。
+// data_directory を指定しなければ、データディレクトリを使わずに起動する。
+func PostmasterMain() error {
+	// 設定ファイルの値はコマンドラインで指定した値より優先しない
+	if err := guc.SelectConfigFiles(); err != nil {
+		return err
+	}
+
+	// データディレクトリを指定した場合は、このサーバーで扱えるものかを確かめ、ロックファイルで
+	// 他のサーバーが同じデータディレクトリを使わないようにしてから、前回のチェックポイントの
+	// 状態を戻す (checkDataDir、CreateDataDirLockFile と、起動処理の ReadControlFile、StartupXLOG 相当)
+	if dataDir := guc.DataDirectory.Get(); dataDir != "" {
+		if err := miscadmin.CheckDataDir(dataDir); err != nil {
+			return err
+		}
+		if err := miscadmin.CreateDataDirLockFile(dataDir, true, time.Now(), guc.Port.Get()); err != nil {
+			return err
+		}
+		defer miscadmin.RemoveDataDirLockFile(dataDir)
+		if err := transam.ReadControlFile(dataDir); err != nil {
+			return err
+		}
+		// ファイル暗号化が有効なクラスタなら、リレーションのページを読み書きする前に鍵を読み込む
+		if err := crypto.InitializeFileEncryption(dataDir, guc.ClusterKeyFile.Get()); err != nil {
+			return err
+		}
+		if err := transam.StartupXLOG(dataDir); err != nil {
+			return err
+		}
+		// 前回のサーバーが異常終了して残した一時ファイルを削除する
+		file.RemovePgTempFiles()
+	}
+
+	// パラメータどうしの関係を確かめる (PostmasterMain の設定の検査相当)
+	if guc.SuperuserReservedConnections.Get()+guc.ReservedConnections.Get() >= guc.MaxConnections.Get() {
+		return fmt.Errorf("superuser_reserved_connections (%d) plus reserved_connections (%d) must be less than max_connections (%d)",
+			guc.SuperuserReservedConnections.Get(), guc.ReservedConnections.Get(), guc.MaxConnections.Get())
+	}
+	if err := miscadmin.CheckMaxBackends(); err != nil {
+		return err
+	}
+	// 仮想ファイル記述子が同時に開いておける数を決める
+	if err := file.SetMaxSafeFds(); err != nil {
+		return err
+	}
+	// 共有メモリのセグメントを作る (CreateSharedMemoryAndSemaphores 相当)
+	shmemKey := ipc.ShmemKey(guc.DataDirectory.Get(), guc.Port.Get())
+	if err := ipc.CreateSharedMemoryAndSemaphores(shmemKey, guc.DataDirectory.Get()); err != nil {
+		return err
+	}
+	defer ipc.DestroySharedMemory()
+	// 共有バッファがリレーションのファイルを読み書きできるようにする (BaseInit の smgrinit 相当)
+	smgr.Init()
+	defer smgr.CloseAll()
+	// logging_collector が on なら、これ以降の標準エラー出力をログファイルに書く
+	if err := syslogger.Start(); err != nil {
+		return err
+	}
+	defer syslogger.Stop()
+	// 登録されたワーカーを max_worker_processes の枠に入れ、起動直後に動くワーカーを起動する
+	bgworker.Init()
+	defer bgworker.PostmasterExited()
+	go bgworkerLoop()
+	advanceBgWorkerPhase(bgworker.StartAtPostmasterStart)
+
+	// ブートストラップスーパーユーザーの名前は initdb が pg_authid に書く。データディレクトリを
+	// 指定せずに起動した場合は、サーバーを起動した利用者をブートストラップスーパーユーザーとする
+	if dataDir := guc.DataDirectory.Get(); dataDir != "" {
+		if err := backend.LoadBootstrapSuperuser(dataDir); err != nil {
+			return err
+		}
+	} else if u, err := user.Current(); err == nil {
+		catalog.SetBootstrapSuperuser(u.Username)
+	}
+
+	if err := injection.LoadFromEnv(); err != nil {
+		return err
+	}
+
+	if guc.EnableSSL.Get() {
+		if err := libpq.SecureInitialize(guc.SSLCertFile.Get(), guc.SSLKeyFile.Get(), guc.SSLCAFile.Get()); err != nil {
+			return err
+		}
+	}
+
+	// SSL の設定を読み込んだ後に読む。hostssl の行が一致しうるかを確かめるため
+	if err := hba.Load(guc.HbaFile.Get()); err != nil {
+		return err
+	}
+	// pg_ident.conf を読めなくても起動は続ける。対応付けを使う認証方式では接続できなくなる
+	hba.LoadIdent(guc.IdentFile.Get())
+	go handleSighup()
+
+	listeners, err := createListenSockets()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}()
+	if guc.DataDirectory.Get() != "" {
+		checkpointer.Start()
+		bgwriter.Start(buffer.NewPool(bgwriter.Proc))
+		defer bgwriter.Stop()
+		pgarch.Start(guc.DataDirectory.Get())
+	}
+
+	// セグメントには ID がないため、ID は 0 にする (PGSharedMemoryCreate の AddToDataDirFile 相当)
+	addToDataDirFile(miscadmin.LockFileLineShmemKey, fmt.Sprintf("%9d %9d", shmemKey, 0))
+	addToDataDirFile(miscadmin.LockFileLinePmStatus, miscadmin.PmStatusReady)
+	errutil.Report(nil, errutil.Elog(errutil.Log, "database system is ready to accept connections"))
+	// リカバリがないため、接続を受け付けられるようになると同時にリカバリを終えた段階に達する
+	advanceBgWorkerPhase(bgworker.StartAtRecoveryFinished)
+
+	shutdownDone := make(chan error, 1)
+	go postmasterStateMachine(shutdownDone)
+	if err := serverLoop(listeners, shutdownDone); err != nil {
+		return err
+	}
+	errutil.Report(nil, errutil.Elog(errutil.Log, "database system is shut down"))
 	return nil
 }
+
+// shutdownMode は要求された停止の方法 (Shutdown 相当)。強い方法への切り替えだけを受け付ける。
+type shutdownMode int32
+
+const (
+	noShutdown        shutdownMode = iota
+	smartShutdown                  // SIGTERM: セッションが全て終わるのを待つ
+	fastShutdown                   // SIGINT: 全てのセッションを終了させる
+	immediateShutdown              // SIGQUIT: セッションを待たずに終了する
+)
+
+// shutdown は要求された停止の方法
+var shutdown atomic.Int32
+
+// childExited はバックエンドが終了したことを postmasterStateMachine に知らせる
+var childExited = make(chan struct{}, 1)
+
+// fatalError はバックエンドの異常終了を受けて、全てのバックエンドが終わるのを待っていることを表す
+// (FatalError 相当)。この間に届いた接続は dead-end バックエンドにする。
+var fatalError atomic.Bool
+
+// postmasterStateMachine は停止の要求を受け付け、バックエンドが終わるたびに次に進めるかを判定する
+// (handle_pm_shutdown_request_signal, process_pm_shutdown_request, PostmasterStateMachine 相当)。
+// smart と fast では全てのバックエンドが終わるのを待ち、続いてバックグラウンドワーカーを終了させ、
+// checkpointer がシャットダウンのチェックポイントを終えたら停止できる。停止できるようになったら、
+// postmaster の終了の仕方を done に送る。immediate ではチェックポイントを行わない。
+//
+// バックエンドの異常終了の後は、全てのバックエンドが終わるのを待って、共有する状態を
+// 初期化し直す。停止が要求されているか、restart_after_crash が無効であれば停止する。
+func postmasterStateMachine(done chan<- error) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	for {
+		select {
+		case sig := <-sigc:
+			mode := smartShutdown
+			switch sig {
+			case syscall.SIGINT:
+				mode = fastShutdown
+			case syscall.SIGQUIT:
+				mode = immediateShutdown
+			}
+			if mode <= shutdownMode(shutdown.Load()) {
+				continue
+			}
+			shutdown.Store(int32(mode))
+			addToDataDirFile(miscadmin.LockFileLinePmStatus, miscadmin.PmStatusStopping)
+			switch mode {
+			case smartShutdown:
+				errutil.Report(nil, errutil.Elog(errutil.Log, "received smart shutdown request"))
+			case fastShutdown:
+				errutil.Report(nil, errutil.Elog(errutil.Log, "received fast shutdown request"))
+				errutil.Report(nil, errutil.Elog(errutil.Log, "aborting any active transactions"))
+				backend.TerminateBackends()
+			case immediateShutdown:
+				// バックエンドの後始末は待たない。プロセスの終了で全ての接続が切れる
+				errutil.Report(nil, errutil.Elog(errutil.Log, "received immediate shutdown request"))
+				done <- nil
+				return
+			}
+		case <-childExited:
+		}
+		if liveChildren.Load() != 0 {
+			continue
+		}
+		// クライアントのバックエンドが全て終わってから、ワーカーに終了を要求する
+		if liveBgWorkers.Load() != 0 {
+			if shutdown.Load() != int32(noShutdown) {
+				bgworker.TerminateAllWorkers()
+			}
+			continue
+		}
+
+		switch {
+		case shutdown.Load() != int32(noShutdown) && fatalError.Load():
+			done <- errors.New("abnormal database system shutdown")
+			return
+		case shutdown.Load() != int32(noShutdown):
+			// バックエンドが全て終わったら、データチェックサムのワーカーとバックグラウンドライターを
+			// 止め、checkpointer にシャットダウンのチェックポイントを行わせる
+			datachecksumsworker.Stop()
+			bgwriter.Stop()
+			if err := checkpointer.Shutdown(); err != nil {
+				done <- errors.New("abnormal database system shutdown")
+				return
+			}
+			// チェックポイントまでに書き終えた WAL をアーカイブさせてから停止する
+			pgarch.Stop()
+			done <- nil
+			return
+		case fatalError.Load() && !guc.RestartAfterCrash.Get():
+			done <- errors.New("shutting down because restart_after_crash is off")
+			return
+		case fatalError.Load():
+			errutil.Report(nil, errutil.Elog(errutil.Log, "all server processes terminated; reinitializing"))
+			backend.ResetSharedState()
+			bgworker.ResetAfterCrash()
+			// 異常終了したバックエンドが壊したかもしれない共有メモリを作り直す。共有バッファを
+			// 使うバックグラウンドライターは作り直す間止めておく
+			bgwriter.Stop()
+			if err := ipc.CreateSharedMemoryAndSemaphores(ipc.ShmemKey(guc.DataDirectory.Get(), guc.Port.Get()), guc.DataDirectory.Get()); err != nil {
+				errutil.Report(nil, errutil.Elog(errutil.Log, "%s", err.Error()))
+				done <- errors.New("abnormal database system shutdown")
+				return
+			}
+			if guc.DataDirectory.Get() != "" {
+				bgwriter.Start(buffer.NewPool(bgwriter.Proc))
+			}
+			// 異常終了したバックエンドが後始末をせずに残した一時ファイルを削除する
+			if guc.DataDirectory.Get() != "" {
+				file.RemovePgTempFiles()
+			}
+			fatalError.Store(false)
+			errutil.Report(nil, errutil.Elog(errutil.Log, "database system is ready to accept connections"))
+			wakeBgWorkerLoop()
+		}
+	}
+}
+
+// handleChildCrash はバックエンドやバックグラウンドワーカーの異常終了を報告し、他の全てのバックエンドに
+// 終了を要求する (HandleChildCrash, LogChildExit 相当)。procName はログに出すプロセスの名前。
+// 異常終了したプロセスが共有する状態を壊しているかもしれないため、全てのバックエンドが終わった後に
+// postmasterStateMachine が初期化し直す。
+// C言語版では応答しない子プロセスを SIGKILL で止めるが、ゴルーチンは外から止められないため、
+// 各バックエンドが終了の要求を確かめるまで待つ。
+func handleChildCrash(crash *backend.ChildCrash, procName string) {
+	edata := errutil.Elog(errutil.Log, "%s (PID %d) was terminated by panic: %v", procName, crash.PID, crash.Reason)
+	if crash.Activity != "" {
+		edata.WithDetail("Failed process was running: %s", crash.Activity)
+	}
+	errutil.Report(nil, edata)
+	os.Stderr.Write(crash.Stack)
+
+	// 既に他のバックエンドに終了を要求している場合と、immediate シャットダウンの最中は何もしない
+	if shutdownMode(shutdown.Load()) == immediateShutdown || fatalError.Swap(true) {
+		return
+	}
+	errutil.Report(nil, errutil.Elog(errutil.Log, "terminating any other active server processes"))
+	backend.QuitBackends()
+}
+
+// handleSighup は SIGHUP を受け取るたびに設定ファイルを読み直す (process_pm_reload_request 相当)。
+// 誤りがあれば以前の設定を使い続ける。
+func handleSighup() {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGHUP)
+	for range sigc {
+		errutil.Report(nil, errutil.Elog(errutil.Log, "received SIGHUP, reloading configuration files"))
+		guc.ProcessConfigFile(guc.PGCSighup)
+		syslogger.Reload()
+		if err := hba.Load(guc.HbaFile.Get()); err != nil {
+			errutil.Report(nil, errutil.Elog(errutil.Log, "pg_hba.conf was not reloaded"))
+		}
+		if err := hba.LoadIdent(guc.IdentFile.Get()); err != nil {
+			errutil.Report(nil, errutil.Elog(errutil.Log, "pg_ident.conf was not reloaded"))
+		}
+	}
+}
+
+// addToDataDirFile はデータディレクトリを使っている場合に、ロックファイルの行を書き換える
+func addToDataDirFile(target int, str string) {
+	if dataDir := guc.DataDirectory.Get(); dataDir != "" {
+		miscadmin.AddToDataDirFile(dataDir, target, str)
+	}
+}
+
+// createListenSockets は listen_addresses と unix_socket_directories の各要素について待ち受けソケットを作る。
+// 一部の要素で失敗しても、1つでも作れれば起動を続ける。
+func createListenSockets() ([]net.Listener, error) {
+	port := guc.Port.Get()
+	elems, ok := adt.SplitGUCList(guc.ListenAddresses.Get(), ',')
+	if !ok {
+		return nil, errors.New("invalid list syntax in parameter \"listen_addresses\"")
+	}
+
+	var listeners []net.Listener
+	listenAddrSaved := false
+	for _, host := range elems {
+		var err error
+		if listeners, err = libpq.StreamServerPort(host, port, listeners); err != nil {
+			errutil.Report(nil, errutil.Elog(errutil.Warning, "could not create listen socket for \"%s\"", host))
+			continue
+		}
+		// 最初に待ち受けられたアドレスを pg_ctl のためにロックファイルに書く
+		if !listenAddrSaved {
+			addToDataDirFile(miscadmin.LockFileLineListenAddr, host)
+			listenAddrSaved = true
+		}
+	}
+	if len(elems) > 0 && len(listeners) == 0 {
+		return nil, errors.New("could not create any TCP/IP sockets")
+	}
+
+	dirs, ok := adt.SplitGUCList(guc.UnixSocketDirectories.Get(), ',')
+	if !ok {
+		return nil, errors.New("invalid list syntax in parameter \"unix_socket_directories\"")
+	}
+	nTCP := len(listeners)
+	for _, dir := range dirs {
+		var err error
+		if listeners, err = libpq.StreamServerUnixPort(dir, port, os.FileMode(guc.UnixSocketPermissions.Get()), listeners); err != nil {
+			errutil.Report(nil, errutil.Elog(errutil.Warning, "could not create Unix-domain socket in directory \"%s\"", dir))
+			continue
+		}
+		if len(listeners) == nTCP+1 {
+			addToDataDirFile(miscadmin.LockFileLineSocketDir, dir)
+		}
+	}
+	if len(dirs) > 0 && len(listeners) == nTCP {
+		return nil, errors.New("could not create any Unix-domain sockets")
+	}
+
+	if len(listeners) == 0 {
+		return nil, errors.New("no socket created for listening")
+	}
+	return listeners, nil
+}
+
+// serverLoop は全ての待ち受けソケットで接続を受け付け続ける (ServerLoop 相当)。
+// C言語版では select() で全てのソケットを待つが、Go言語版ではソケットごとにゴルーチンで
+// Accept を呼ぶ。いずれかのソケットで受け付けに失敗するか、停止の処理が終わったら戻る。
+func serverLoop(listeners []net.Listener, shutdownDone <-chan error) error {
+	errc := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					errc <- fmt.Errorf("could not accept new connection: %w", err)
+					return
+				}
+				backendStartup(conn)
+			}
+		}()
+	}
+	select {
+	case err := <-errc:
+		return err
+	case err := <-shutdownDone:
+		return err
+	}
+}
+
+// liveChildren は起動中のバックエンド (dead-end バックエンドを含む) の数
+var liveChildren atomic.Int32
+
+// backendStartup は新しい接続のバックエンドを起動する (BackendStartup 相当)。
+// C言語版の fork() の代わりにゴルーチンを起動する。
+func backendStartup(conn net.Conn) {
+	if !connThrottle.allow(conn) {
+		conn.Close()
+		return
+	}
+
+	n := int(liveChildren.Add(1))
+	if n > miscadmin.MaxLivePostmasterChildren() {
+		// エラーを返すバックエンドも起動できないほど接続が多い場合は、黙って閉じる
+		liveChildren.Add(-1)
+		conn.Close()
+		return
+	}
+
+	go func() {
+		defer func() {
+			liveChildren.Add(-1)
+			select {
+			case childExited <- struct{}{}:
+			default:
+			}
+		}()
+		if crash := backend.BackendMain(conn, canAcceptConnections(n)); crash != nil {
+			handleChildCrash(crash, "server process")
+		}
+	}()
+}
+
+// canAcceptConnections は新しい接続を受け付けられるかを判定する (canAcceptConnections 相当)。
+// n は新しい接続を含めた子の数。通常のバックエンドが max_connections に達しているかどうかは、
+// 最終的にバックエンドが自身を登録する際に確認する。ここでは postmaster が把握している
+// 子の数が、接続数の上限を明らかに超えている場合に dead-end バックエンドにする。
+// 停止の処理中と、異常終了の後に初期化し直すまでの間は、全ての新しい接続を dead-end バックエンドにする。
+func canAcceptConnections(n int) backend.CACState {
+	if shutdown.Load() != int32(noShutdown) {
+		return backend.CACShutdown
+	}
+	if fatalError.Load() {
+		return backend.CACRecovery
+	}
+	if n > guc.MaxConnections.Get() {
+		return backend.CACTooMany
+	}
+	return backend.CACOk
+}
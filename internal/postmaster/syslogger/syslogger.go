@@ -0,0 +1,594 @@
+package syslogger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// ----------------------------------------------------------------
+// ログの収集 (postmaster/syslogger.c 相当)
+// ----------------------------------------------------------------
+// logging_collector が on なら、サーバーの標準エラー出力 (fd 2) をパイプに繋ぎ替え、
+// 1つのゴルーチンがパイプから読んで log_directory/log_filename のファイルに書く。バックエンドや
+// バックグラウンドのゴルーチンはこれまでどおり標準エラー出力に書けばよく、runtime の panic の
+// 出力も同じファイルに入る。
+//
+// ファイルは log_rotation_age ごと (時刻を log_rotation_age の倍数に揃える) と、log_rotation_size を
+// 超えたときと、pg_rotate_logfile と、log_directory か log_filename を SIGHUP で変えたときに
+// 切り替える。ファイル名は log_filename を切り替えた時刻で strftime して決める。
+// log_truncate_on_rotation が on なら、時刻による切り替えで前と違う名前のファイルを開くときに
+// 既存の内容を捨てる。行の途中では切り替えない。
+//
+// log_destination に csvlog か jsonlog があれば、それぞれの形式の行を errutil からチャネルで
+// 受け取り、log_filename の末尾の .log を .csv か .json に換えた (.log で終わらなければ付け足した)
+// ファイルに書く。これらのファイルも stderr のファイルと一緒に切り替え、log_rotation_size による
+// 切り替えは超えたファイルだけを切り替える。log_destination から外したファイルは次の切り替えで閉じる。
+//
+// 書いているファイルはデータディレクトリの current_logfiles に、出力先ごとに1行ずつ記録する。
+
+// LogMetainfoDatafile は書いているログファイルを記録するファイル (LOG_METAINFO_DATAFILE 相当)
+const LogMetainfoDatafile = "current_logfiles"
+
+// logDests はログファイルに書く出力先。logger.files の添字と同じ順に並べる
+var logDests = []struct {
+	dest errutil.LogDest
+	// key は current_logfiles に書く出力先の名前
+	key string
+	// suffix は log_filename の .log と換える拡張子。stderr は log_filename のまま
+	suffix string
+}{
+	{errutil.LogDestStderr, "stderr", ""},
+	{errutil.LogDestCsvlog, "csvlog", ".csv"},
+	{errutil.LogDestJsonlog, "jsonlog", ".json"},
+}
+
+// allLogDests は全ての出力先
+const allLogDests = errutil.LogDestStderr | errutil.LogDestCsvlog | errutil.LogDestJsonlog
+
+// record は errutil から受け取った csvlog か jsonlog の1行
+type record struct {
+	dest errutil.LogDest
+	line []byte
+}
+
+// sysLogger はログを収集するゴルーチンと postmaster が共有する状態
+var sysLogger struct {
+	sync.Mutex
+	running bool
+	// origStderr は繋ぎ替える前の標準エラー出力。停止するときに fd 2 を戻す
+	origStderr *os.File
+	// pipeW はパイプの書き込み側。fd 2 もこれの複製になる
+	pipeW *os.File
+	// rotate は切り替えの要求、reload は設定の読み直しを知らせる
+	rotate, reload chan struct{}
+	// records は csvlog と jsonlog の行を受け取る
+	records chan record
+	exited  chan struct{}
+}
+
+// Start は logging_collector が on ならログの収集を始める (SysLogger_Start 相当)。最初の
+// ログファイルを開けなければエラーを返し、標準エラー出力はそのままにする。前回のサーバーが
+// 残した current_logfiles は、収集しない場合も削除する。
+func Start() error {
+	if dataDir := guc.DataDirectory.Get(); dataDir != "" {
+		os.Remove(filepath.Join(dataDir, LogMetainfoDatafile))
+	}
+	if !guc.LoggingCollector.Get() {
+		return nil
+	}
+	sh := &sysLogger
+	sh.Lock()
+	defer sh.Unlock()
+	if sh.running {
+		return nil
+	}
+
+	l := &logger{}
+	l.loadSettings()
+	if err := os.MkdirAll(l.directory, 0700); err != nil {
+		return fmt.Errorf("could not create log directory \"%s\": %w", l.directory, err)
+	}
+	now := sim.Now()
+	enabled := errutil.LogDestinations() | errutil.LogDestStderr
+	for i, d := range logDests {
+		if enabled&d.dest == 0 {
+			continue
+		}
+		f, name, err := l.openLogFile(&l.files[i], now, d.suffix, false)
+		if err != nil {
+			l.closeFiles()
+			return err
+		}
+		l.files[i].set(f, name)
+	}
+	l.updateMetainfoDatafile()
+	l.setNextRotationTime(now)
+
+	pipeR, pipeW, err := os.Pipe()
+	if err != nil {
+		l.closeFiles()
+		return fmt.Errorf("could not create pipe for syslog: %w", err)
+	}
+	origFd, err := platform.Dup(2)
+	if err != nil {
+		l.closeFiles()
+		pipeR.Close()
+		pipeW.Close()
+		return fmt.Errorf("could not redirect stderr: %w", err)
+	}
+	errutil.Report(nil, errutil.Elog(errutil.Log, "redirecting log output to logging collector process").
+		WithHint("Future log output will appear in directory \"%s\".", guc.LogDirectory.Get()))
+	if err := platform.Dup2(int(pipeW.Fd()), 2); err != nil {
+		l.closeFiles()
+		pipeR.Close()
+		pipeW.Close()
+		return fmt.Errorf("could not redirect stderr: %w", err)
+	}
+
+	sh.running = true
+	sh.origStderr = os.NewFile(uintptr(origFd), "stderr")
+	sh.pipeW = pipeW
+	sh.rotate = make(chan struct{}, 1)
+	sh.reload = make(chan struct{}, 1)
+	sh.records = make(chan record, 256)
+	sh.exited = make(chan struct{})
+	records := sh.records
+	errutil.SetCollector(func(dest errutil.LogDest, line []byte) {
+		records <- record{dest: dest, line: line}
+	})
+	go l.main(pipeR, sh.rotate, sh.reload, sh.records, sh.exited)
+	return nil
+}
+
+// Stop は標準エラー出力を元に戻し、パイプに残ったログを書き終えるまで待つ。ログを収集して
+// いなければ何もしない。
+func Stop() {
+	sh := &sysLogger
+	sh.Lock()
+	defer sh.Unlock()
+	if !sh.running {
+		return
+	}
+	// 以後の csvlog と jsonlog の行は渡さない。渡し終えた行は閉じたチャネルから読み切る
+	errutil.SetCollector(nil)
+	close(sh.records)
+	platform.Dup2(int(sh.origStderr.Fd()), 2)
+	sh.pipeW.Close()
+	<-sh.exited
+	sh.origStderr.Close()
+	sh.running = false
+}
+
+// Reload は設定ファイルを読み直したことを知らせる。log_directory か log_filename が
+// 変わっていればファイルを切り替える。
+func Reload() {
+	notify(func() chan struct{} { return sysLogger.reload })
+}
+
+// RequestRotation はログファイルの切り替えを要求する (pg_rotate_logfile の SIGUSR1 相当)。
+// ログを収集していなければ false を返す。
+func RequestRotation() bool {
+	return notify(func() chan struct{} { return sysLogger.rotate })
+}
+
+// notify はログを収集していれば ch に知らせる
+func notify(ch func() chan struct{}) bool {
+	sh := &sysLogger
+	sh.Lock()
+	defer sh.Unlock()
+	if !sh.running {
+		return false
+	}
+	select {
+	case ch() <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// ----------------------------------------------------------------
+// ログファイルへの書き込み
+// ----------------------------------------------------------------
+
+// logger はログを収集するゴルーチンの状態
+type logger struct {
+	// directory と filename は今のファイルを開いたときの log_directory と log_filename。
+	// directory はデータディレクトリからの相対パスを解決したもの
+	directory, filename string
+	// files は logDests の出力先ごとのファイル (syslogFile, csvlogFile, jsonlogFile 相当)
+	files [3]logFile
+	// nextRotation は次に時刻で切り替える時刻。ゼロ値なら時刻では切り替えない
+	nextRotation time.Time
+	// rotationDisabled は新しいファイルを開けなかったため、SIGHUP まで切り替えないことを表す
+	rotationDisabled bool
+	// pending はまだ改行が来ていない行の途中
+	pending []byte
+}
+
+// logFile は1つの出力先のログファイル。file が nil なら開いていない
+type logFile struct {
+	file *os.File
+	// name は今のファイルのパス (last_sys_file_name 相当)
+	name string
+	size int64
+}
+
+// set は開いたファイルを今のファイルにする
+func (lf *logFile) set(f *os.File, name string) {
+	lf.file = f
+	lf.name = name
+	lf.size = 0
+	if st, err := f.Stat(); err == nil {
+		lf.size = st.Size()
+	}
+}
+
+// close はファイルを閉じる
+func (lf *logFile) close() {
+	if lf.file != nil {
+		lf.file.Close()
+	}
+	*lf = logFile{}
+}
+
+// closeFiles は全ての出力先のファイルを閉じる
+func (l *logger) closeFiles() {
+	for i := range l.files {
+		l.files[i].close()
+	}
+}
+
+// loadSettings は log_directory と log_filename を読む
+func (l *logger) loadSettings() {
+	l.directory = guc.LogDirectory.Get()
+	if dataDir := guc.DataDirectory.Get(); dataDir != "" && !filepath.IsAbs(l.directory) {
+		l.directory = filepath.Join(dataDir, l.directory)
+	}
+	l.filename = guc.LogFilename.Get()
+}
+
+// main はパイプが閉じるまでログを読んでファイルに書く (SysLoggerMain 相当)
+func (l *logger) main(pipeR *os.File, rotate, reload <-chan struct{}, records <-chan record, exited chan<- struct{}) {
+	defer close(exited)
+	chunks := make(chan []byte)
+	go readPipe(pipeR, chunks)
+
+	// パイプと csvlog の行はチャネルで受け取るため、sim.Wait では待てない。切り替えの時刻は
+	// sim.Now で決め、それまでの時間だけタイマーで待つ
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		var timerC <-chan time.Time
+		if !l.nextRotation.IsZero() && !l.rotationDisabled {
+			timer.Reset(l.nextRotation.Sub(sim.Now()))
+			timerC = timer.C
+		}
+		select {
+		case data, ok := <-chunks:
+			if !ok {
+				// 書き込む側が全て閉じた。行の途中と残った csvlog と jsonlog の行も書いて終わる
+				l.write(0, l.pending)
+				if records != nil {
+					for r := range records {
+						l.writeRecord(r)
+					}
+				}
+				l.closeFiles()
+				return
+			}
+			l.processInput(data)
+		case r, ok := <-records:
+			if !ok {
+				records = nil
+				break
+			}
+			l.writeRecord(r)
+		case <-timerC:
+			l.logfileRotate(true, 0)
+		case <-rotate:
+			l.logfileRotate(false, allLogDests)
+		case <-reload:
+			l.reloadSettings()
+		}
+		timer.Stop()
+	}
+}
+
+// readPipe はパイプから読んだものを chunks に送り、パイプが閉じたら chunks を閉じる
+func readPipe(r io.ReadCloser, chunks chan<- []byte) {
+	defer close(chunks)
+	defer r.Close()
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			chunks <- append([]byte(nil), buf[:n]...)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// processInput はパイプから読んだものを行の区切りまで stderr のファイルに書く (process_pipe_input 相当)
+func (l *logger) processInput(data []byte) {
+	l.pending = append(l.pending, data...)
+	end := bytes.LastIndexByte(l.pending, '\n')
+	if end < 0 {
+		return
+	}
+	l.write(0, l.pending[:end+1])
+	l.pending = append(l.pending[:0], l.pending[end+1:]...)
+	l.checkSizeRotation()
+}
+
+// writeRecord は csvlog か jsonlog の1行をその出力先のファイルに書く。ファイルを開いていなければ
+// (log_destination に加えてからまだ切り替えていなければ) stderr のファイルに書く。
+func (l *logger) writeRecord(r record) {
+	i := destIndex(r.dest)
+	if l.files[i].file == nil {
+		i = 0
+	}
+	l.write(i, r.line)
+	l.checkSizeRotation()
+}
+
+// checkSizeRotation は log_rotation_size を超えたファイルを切り替える
+func (l *logger) checkSizeRotation() {
+	limit := guc.LogRotationSize.Get()
+	if limit <= 0 || l.rotationDisabled {
+		return
+	}
+	var sizeRotationFor errutil.LogDest
+	for i, d := range logDests {
+		if l.files[i].file != nil && l.files[i].size >= int64(limit)*1024 {
+			sizeRotationFor |= d.dest
+		}
+	}
+	if sizeRotationFor != 0 {
+		l.logfileRotate(false, sizeRotationFor)
+	}
+}
+
+// destIndex は出力先の logDests での添字を返す
+func destIndex(dest errutil.LogDest) int {
+	for i, d := range logDests {
+		if d.dest == dest {
+			return i
+		}
+	}
+	return 0
+}
+
+// write は i 番目の出力先のファイルに書く (write_syslogger_file 相当)。書けなければ元の標準エラー
+// 出力に報告する。
+func (l *logger) write(i int, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	lf := &l.files[i]
+	n, err := lf.file.Write(b)
+	lf.size += int64(n)
+	if err != nil {
+		fmt.Fprintf(sysLogger.origStderr, "could not write to log file: %v\n", err)
+	}
+}
+
+// reloadSettings は設定を読み直し、ログファイルの場所が変わっていれば切り替える。log_destination に
+// csvlog か jsonlog を加えたか外したときも、ファイルを開くか閉じるために切り替える。
+// 切り替えを止めていた場合は再開する。
+func (l *logger) reloadSettings() {
+	directory, filename := l.directory, l.filename
+	l.loadSettings()
+	l.rotationDisabled = false
+	if l.directory != directory {
+		// 新しいディレクトリがなければ作る。作れなければ次の切り替えで報告する
+		os.MkdirAll(l.directory, 0700)
+	}
+	if l.directory != directory || l.filename != filename || l.destinationsChanged() {
+		l.logfileRotate(false, allLogDests)
+		return
+	}
+	// 切り替えの間隔が変わっていても、すぐには切り替えない
+	l.setNextRotationTime(sim.Now())
+}
+
+// destinationsChanged は log_destination の csvlog と jsonlog が、開いているファイルと
+// 合わなくなったかを返す
+func (l *logger) destinationsChanged() bool {
+	enabled := errutil.LogDestinations()
+	for i, d := range logDests[1:] {
+		if (enabled&d.dest != 0) != (l.files[i+1].file != nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// logfileRotate はログファイルを切り替える (logfile_rotate 相当)。timeBased は時刻による
+// 切り替えであることを表し、そうでなければ sizeRotationFor の出力先のファイルだけを切り替える。
+func (l *logger) logfileRotate(timeBased bool, sizeRotationFor errutil.LogDest) {
+	now := sim.Now()
+	enabled := errutil.LogDestinations() | errutil.LogDestStderr
+	for i, d := range logDests {
+		if !l.logfileRotateDest(&l.files[i], d.dest, d.suffix, now, timeBased, sizeRotationFor, enabled) {
+			return
+		}
+	}
+	l.updateMetainfoDatafile()
+	l.setNextRotationTime(now)
+}
+
+// logfileRotateDest は1つの出力先のファイルを切り替える (logfile_rotate_dest 相当)。log_destination
+// から外した出力先のファイルは閉じる。新しいファイルを開けなければ、自動の切り替えを止めて false を返す。
+func (l *logger) logfileRotateDest(lf *logFile, dest errutil.LogDest, suffix string, now time.Time,
+	timeBased bool, sizeRotationFor, enabled errutil.LogDest) bool {
+	if enabled&dest == 0 {
+		lf.close()
+		return true
+	}
+	if !timeBased && sizeRotationFor&dest == 0 && lf.file != nil {
+		return true
+	}
+	truncate := timeBased && guc.LogTruncateOnRotation.Get()
+	f, name, err := l.openLogFile(lf, now, suffix, truncate)
+	if err != nil {
+		l.logMessage("%s", err.Error())
+		l.logMessage("disabling automatic rotation (use SIGHUP to re-enable)")
+		l.rotationDisabled = true
+		return false
+	}
+	lf.close()
+	lf.set(f, name)
+	return true
+}
+
+// logMessage は収集のゴルーチン自身のメッセージを stderr のファイルに書く。パイプを通すと
+// 自身が読むまで書き込みが終わらないことがあるため、直接書く。
+func (l *logger) logMessage(format string, args ...any) {
+	l.write(0, []byte("LOG:  "+fmt.Sprintf(format, args...)+"\n"))
+}
+
+// openLogFile は now の時刻で lf の出力先のログファイルを開く (logfile_open 相当)。suffix は
+// log_filename の .log と換える拡張子。truncate が true で、前のファイルと名前が違えば既存の内容を捨てる。
+func (l *logger) openLogFile(lf *logFile, now time.Time, suffix string, truncate bool) (*os.File, string, error) {
+	name := filepath.Join(l.directory, logfileGetname(l.filename, now, suffix))
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if truncate && name != lf.name {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(name, flags, os.FileMode(guc.LogFileMode.Get()))
+	if err != nil {
+		return nil, "", fmt.Errorf("could not open log file \"%s\": %s", name, platform.OSErrorMessage(err))
+	}
+	return f, name, nil
+}
+
+// logfileGetname は log_filename を now の時刻で展開したファイル名を返す (logfile_getname 相当)。
+// suffix があれば末尾の .log と換え、.log で終わらなければ付け足す。
+func logfileGetname(filename string, now time.Time, suffix string) string {
+	name := strftime(filename, now)
+	if suffix != "" {
+		name = strings.TrimSuffix(name, ".log") + suffix
+	}
+	return name
+}
+
+// setNextRotationTime は次に時刻で切り替える時刻を決める (set_next_rotation_time 相当)。
+// 地方時で log_rotation_age の倍数の時刻に揃える。
+func (l *logger) setNextRotationTime(now time.Time) {
+	age := int64(guc.LogRotationAge.Get()) * 60
+	if age <= 0 {
+		l.nextRotation = time.Time{}
+		return
+	}
+	_, offset := now.Zone()
+	t := now.Unix() + int64(offset)
+	t -= t % age
+	t += age
+	t -= int64(offset)
+	l.nextRotation = time.Unix(t, 0)
+}
+
+// updateMetainfoDatafile は開いているログファイルを出力先ごとに current_logfiles に書く
+// (update_metainfo_datafile 相当)。データディレクトリを使っていなければ何もしない。
+func (l *logger) updateMetainfoDatafile() {
+	dataDir := guc.DataDirectory.Get()
+	if dataDir == "" {
+		return
+	}
+	var b strings.Builder
+	for i, d := range logDests {
+		name := l.files[i].name
+		if l.files[i].file == nil {
+			continue
+		}
+		if rel, err := filepath.Rel(dataDir, name); err == nil && !filepath.IsAbs(guc.LogDirectory.Get()) {
+			name = rel
+		}
+		b.WriteString(d.key + " " + name + "\n")
+	}
+	path := filepath.Join(dataDir, LogMetainfoDatafile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0600); err != nil {
+		l.logMessage("could not write file \"%s\": %s", tmp, platform.OSErrorMessage(err))
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		l.logMessage("could not rename file \"%s\" to \"%s\": %s", tmp, path, platform.OSErrorMessage(err))
+	}
+}
+
+// strftime は log_filename の % 指定を t の時刻で置き換える (pg_strftime 相当)。知らない
+// 指定はそのまま残す。
+func strftime(format string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 >= len(format) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'e':
+			fmt.Fprintf(&b, "%2d", t.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'I':
+			h := t.Hour() % 12
+			if h == 0 {
+				h = 12
+			}
+			fmt.Fprintf(&b, "%02d", h)
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'Z':
+			name, _ := t.Zone()
+			b.WriteString(name)
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 's':
+			fmt.Fprintf(&b, "%d", t.Unix())
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package postmaster
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// ----------------------------------------------------------------
+// 接続元ごとの接続数の制限
+// ----------------------------------------------------------------
+// C言語版に相当する機能はない。パスワードの総当たりのように、同じ接続元から短い間に
+// 大量の接続を試みられた場合に、バックエンドを起動する前に接続を閉じる。
+//
+// 接続元の IP アドレスごとに、connection_attempt_window の間に受け付けた接続を数え、
+// connection_attempt_limit を超えたら閉じる。window が過ぎると数え直す。
+// Unix ドメインソケットの接続は数えない。
+
+var connThrottle connectionThrottle
+
+// connectionThrottle は接続元ごとの接続の数
+type connectionThrottle struct {
+	mu    sync.Mutex
+	hosts map[string]*attemptCount
+	// pruned は期限を過ぎた数を最後に捨てた時刻
+	pruned time.Time
+}
+
+// attemptCount は1つの接続元が今の window に試みた接続の数
+type attemptCount struct {
+	start    time.Time
+	attempts int
+	// logged は今の window で、制限を超えたことをログに出力したかどうか
+	logged bool
+}
+
+// allow は接続を受け付けてよいかを返す。制限を超えた場合は、window ごとに一度だけログに出力する。
+func (t *connectionThrottle) allow(conn net.Conn) bool {
+	limit := guc.ConnectionAttemptLimit.Get()
+	if limit == 0 {
+		return true
+	}
+	window := time.Duration(guc.ConnectionAttemptWindow.Get()) * time.Second
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return true
+	}
+	host := addr.IP.String()
+	now := sim.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hosts == nil {
+		t.hosts = make(map[string]*attemptCount)
+	}
+	if now.Sub(t.pruned) >= window {
+		for h, c := range t.hosts {
+			if now.Sub(c.start) >= window {
+				delete(t.hosts, h)
+			}
+		}
+		t.pruned = now
+	}
+
+	c, ok := t.hosts[host]
+	if !ok || now.Sub(c.start) >= window {
+		c = &attemptCount{start: now}
+		t.hosts[host] = c
+	}
+	c.attempts++
+	if c.attempts <= limit {
+		return true
+	}
+	if !c.logged {
+		c.logged = true
+		errutil.Report(nil, errutil.Elog(errutil.Log, "too many connection attempts from host \"%s\", closing connections for %s",
+			host, (window-now.Sub(c.start)).Round(time.Second)))
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package walwriter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// ----------------------------------------------------------------
+// WAL writer (postmaster/walwriter.c、XLogBackgroundFlush、XLogSetAsyncXactLSN 相当)
+// ----------------------------------------------------------------
+// WAL バッファを書き出して同期するバックグラウンドのゴルーチン。バックエンドが WAL を挿入する
+// ときに自分で書き出すことを減らし、非同期コミットの WAL を同期する。
+//
+// synchronous_commit が off のトランザクションは、コミットのレコードの同期を待たずにコミットを終え、
+// SetAsyncXactLSN でレコードの終わりを知らせる。WAL writer は wal_writer_delay ごとに、埋まった
+// WAL のページを書き出し、埋まったページがなければ非同期コミットのレコードまで書き出す。前回の
+// 同期から wal_writer_delay が経つか、wal_writer_flush_after 以上を書き出したら同期する。
+// このため、サーバーが異常終了したときに失われうる非同期コミットは、最後の wal_writer_delay の
+// 3倍の間にコミットしたものに限られる。
+//
+// 書き出すものがない周期が loopsUntilHibernate 回続けば、wal_writer_delay の hibernateFactor 倍まで
+// 休止する。休止している間は、非同期コミットがあればすぐに起こされる。
+//
+// WAL は XLog を実装した WAL の管理が持ち、Start に渡す。
+
+// XLog は WAL writer が使う WAL の操作 (xlog.c の一部相当)
+type XLog interface {
+	// WriteRequest はバックエンドが挿入を終えた WAL の終わり (LogwrtRqst.Write 相当) を返す
+	WriteRequest() transam.XLogRecPtr
+	// WriteResult は書き出し済みの位置と同期済みの位置 (LogwrtResult 相当) を返す
+	WriteResult() (write, flush transam.XLogRecPtr)
+	// Write は WAL バッファを write まで書き出し、flush まで同期する (XLogWrite 相当)。flush が
+	// InvalidXLogRecPtr なら同期しない。失敗した場合は WAL の管理がサーバーを止める (PANIC)
+	Write(write, flush transam.XLogRecPtr)
+}
+
+const (
+	// loopsUntilHibernate は書き出すものがない周期が何回続いたら休止するか (LOOPS_UNTIL_HIBERNATE 相当)
+	loopsUntilHibernate = 50
+	// hibernateFactor は休止するときに wal_writer_delay の何倍まで休むか (HIBERNATE_FACTOR 相当)
+	hibernateFactor = 25
+)
+
+// walwriterShmem は WAL writer とバックエンドが共有する状態
+var walwriterShmem struct {
+	sync.Mutex
+	running bool
+	xlog    XLog
+	// asyncXactLSN は最後に非同期コミットしたトランザクションのレコードの終わり (XLogCtl->asyncXactLSN 相当)
+	asyncXactLSN transam.XLogRecPtr
+	// sleeping は WAL writer が休止していることを表す (XLogCtl->WalWriterSleeping 相当)
+	sleeping bool
+	// wakeup は非同期コミットを WAL writer に知らせる (walwriterLatch 相当)
+	wakeup chan struct{}
+	// stop は停止の要求で閉じ、exited はゴルーチンが終わったときに閉じる
+	stop, exited chan struct{}
+}
+
+// Start は WAL writer を起動する (StartWalWriter 相当)。既に動いていれば何もしない。
+func Start(xlog XLog) {
+	sh := &walwriterShmem
+	sh.Lock()
+	defer sh.Unlock()
+	if sh.running {
+		return
+	}
+	sh.running = true
+	sh.xlog = xlog
+	sh.sleeping = false
+	sh.wakeup = make(chan struct{}, 1)
+	sh.stop = make(chan struct{})
+	sh.exited = make(chan struct{})
+	w := &walWriter{xlog: xlog}
+	wakeup, stop, exited := sh.wakeup, sh.stop, sh.exited
+	sim.Go(func() { w.main(wakeup, stop, exited) })
+}
+
+// Stop は WAL writer を止め、終わるのを待つ (postmaster が walwriter に SIGTERM を送る処理相当)。
+// 書き出していない WAL は、シャットダウンのチェックポイントが書き出す。動いていなければ何もしない。
+func Stop() {
+	sh := &walwriterShmem
+	sh.Lock()
+	if !sh.running {
+		sh.Unlock()
+		return
+	}
+	sh.running = false
+	sh.xlog = nil
+	close(sh.stop)
+	exited := sh.exited
+	sh.Unlock()
+	<-exited
+}
+
+// SetAsyncXactLSN は非同期コミットしたトランザクションのレコードの終わりを記録する
+// (XLogSetAsyncXactLSN 相当)。WAL writer が休止していれば起こす。休止していなければ、
+// レコードが同期済みでないページを埋めたときだけ起こし、埋まっていないページは次の周期に任せる。
+func SetAsyncXactLSN(asyncXactLSN transam.XLogRecPtr) {
+	sh := &walwriterShmem
+	sh.Lock()
+	defer sh.Unlock()
+	if sh.asyncXactLSN < asyncXactLSN {
+		sh.asyncXactLSN = asyncXactLSN
+	}
+	if !sh.running {
+		return
+	}
+	if !sh.sleeping {
+		rqst := asyncXactLSN - asyncXactLSN%pgconfig.XLogBlckSz
+		if _, flush := sh.xlog.WriteResult(); rqst <= flush {
+			return
+		}
+	}
+	select {
+	case sh.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// getAsyncXactLSN は最後に非同期コミットしたトランザクションのレコードの終わりを返す
+func getAsyncXactLSN() transam.XLogRecPtr {
+	sh := &walwriterShmem
+	sh.Lock()
+	defer sh.Unlock()
+	return sh.asyncXactLSN
+}
+
+// setWalWriterSleeping は WAL writer が休止しているかを記録する (SetWalWriterSleeping 相当)
+func setWalWriterSleeping(sleeping bool) {
+	sh := &walwriterShmem
+	sh.Lock()
+	defer sh.Unlock()
+	sh.sleeping = sleeping
+}
+
+// walWriter は WAL writer の状態
+type walWriter struct {
+	xlog XLog
+	// lastFlush は最後に同期した時刻 (XLogBackgroundFlush の lastflush 相当)
+	lastFlush time.Time
+}
+
+// main は WAL writer のメインループ (WalWriterMain 相当)
+func (w *walWriter) main(wakeup, stop <-chan struct{}, exited chan<- struct{}) {
+	defer close(exited)
+
+	leftTillHibernate := loopsUntilHibernate
+	hibernating := false
+	for {
+		// 休止する1周期前から休止していると知らせる。休止に入る前のコミットで起こされ損なわないため
+		if hibernating != (leftTillHibernate <= 1) {
+			hibernating = !hibernating
+			setWalWriterSleeping(hibernating)
+		}
+
+		if w.backgroundFlush() {
+			leftTillHibernate = loopsUntilHibernate
+		} else if leftTillHibernate > 0 {
+			leftTillHibernate--
+		}
+
+		// 設定の読み直しに合わせるため、休む時間は毎回読む
+		delay := time.Duration(guc.WalWriterDelay.Get()) * time.Millisecond
+		if leftTillHibernate == 0 {
+			delay *= hibernateFactor
+		}
+		if sim.Wait(delay, stop, wakeup) == 0 {
+			return
+		}
+	}
+}
+
+// backgroundFlush は WAL を書き出し、必要なら同期する (XLogBackgroundFlush 相当)。
+// 書き出すものがあれば真を返す。
+func (w *walWriter) backgroundFlush() bool {
+	write, flush := w.xlog.WriteResult()
+
+	// 埋まったページの終わりまでを書き出す。埋まったページが全て同期済みなら、
+	// 非同期コミットのレコードまでを書き出す
+	rqst := w.xlog.WriteRequest()
+	rqst -= rqst % pgconfig.XLogBlckSz
+	if rqst <= flush {
+		rqst = getAsyncXactLSN()
+	}
+	if rqst <= flush {
+		return false
+	}
+
+	// 前回の同期から wal_writer_delay が経つか、wal_writer_flush_after 以上を書き出したら同期する。
+	// それまでは書き出すだけにして、同期の回数を抑える
+	now := sim.Now()
+	delay := time.Duration(guc.WalWriterDelay.Get()) * time.Millisecond
+	flushAfter := guc.WalWriterFlushAfter.Get()
+	flushBlocks := int(rqst/pgconfig.XLogBlckSz - flush/pgconfig.XLogBlckSz)
+	rqstFlush := transam.InvalidXLogRecPtr
+	if flushAfter == 0 || w.lastFlush.IsZero() || now.Sub(w.lastFlush) >= delay || flushBlocks >= flushAfter {
+		rqstFlush = rqst
+		w.lastFlush = now
+	}
+
+	if rqst > write || rqstFlush > flush {
+		w.xlog.Write(rqst, rqstFlush)
+	}
+	return true
+}
@@ -0,0 +1,515 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+)
+
+// ----------------------------------------------------------------
+// テスト用のクラスタ (src/test/perl/PostgreSQL/Test/Cluster.pm 相当)
+// ----------------------------------------------------------------
+// エンドツーエンドのテストのために、使い捨てのサーバーを作成、起動、停止する。
+// 1つのテストで複数のノードを扱えるよう、ノードごとにポート番号とディレクトリを割り当てる。
+//
+// ノードのディレクトリの下に、initdb で作るデータディレクトリ (pgdata)、サーバーログ (log) と
+// バックアップ (backup) を置く。ポート番号などの設定は postgresql.conf に書き、サーバーは
+// -D でデータディレクトリを指定して起動する。
+//
+// pg_basebackup とストリーミングレプリケーションはまだないため、バックアップは止めたノードの
+// データディレクトリの複製 (backup_fs_cold 相当) だけを作れる。バックアップから作るノードは
+// プライマリに追従せず、バックアップを取った時点の内容から独立して動く。
+
+// defaultTimeout は待ち合わせの上限 (PG_TEST_TIMEOUT_DEFAULT 相当)
+func defaultTimeout() time.Duration {
+	if s := os.Getenv("PG_TEST_TIMEOUT_DEFAULT"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 180 * time.Second
+}
+
+// Node は1つのテスト用のサーバー
+type Node struct {
+	Name    string
+	Host    string
+	Port    int
+	BaseDir string
+	// BinDir はサーバーのプログラムがあるディレクトリ。空なら PATH から探す
+	BinDir string
+	// Env は起動するサーバーに加える環境変数 ("名前=値")
+	Env []string
+
+	logfile string
+
+	cmd    *exec.Cmd
+	exited chan error
+}
+
+// usedPorts はこのプロセスが割り当てたポート番号
+var usedPorts struct {
+	sync.Mutex
+	ports map[int]bool
+}
+
+// New はノードを作る (PostgreSQL::Test::Cluster->new 相当)。
+// ディレクトリは testDir の下に、ポート番号は空いているものを割り当てる。
+// testDir が空の場合は一時ディレクトリの下に作る。
+func New(name, testDir string) (*Node, error) {
+	if testDir == "" {
+		testDir = os.TempDir()
+	}
+	base, err := os.MkdirTemp(testDir, "t_"+name+"_")
+	if err != nil {
+		return nil, err
+	}
+	port, err := getFreePort()
+	if err != nil {
+		return nil, err
+	}
+	return &Node{
+		Name:    name,
+		Host:    "127.0.0.1",
+		Port:    port,
+		BaseDir: base,
+		BinDir:  os.Getenv("PG_TEST_BINDIR"),
+		logfile: filepath.Join(base, "log", name+".log"),
+	}, nil
+}
+
+// getFreePort はどのプロセスも使っておらず、このプロセスでもまだ割り当てていない
+// ポート番号を返す (get_free_port 相当)
+func getFreePort() (int, error) {
+	usedPorts.Lock()
+	defer usedPorts.Unlock()
+	if usedPorts.ports == nil {
+		usedPorts.ports = make(map[int]bool)
+	}
+	// 一時的なポートの範囲から選ぶ
+	for i := 0; i < 1000; i++ {
+		port := 49152 + rand.Intn(65536-49152)
+		if usedPorts.ports[port] {
+			continue
+		}
+		ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+		if err != nil {
+			continue
+		}
+		ln.Close()
+		usedPorts.ports[port] = true
+		return port, nil
+	}
+	return 0, errors.New("could not find a free port")
+}
+
+// DataDir はデータディレクトリのパスを返す (data_dir 相当)
+func (n *Node) DataDir() string {
+	return filepath.Join(n.BaseDir, "pgdata")
+}
+
+// BackupDir はバックアップを置くディレクトリのパスを返す (backup_dir 相当)
+func (n *Node) BackupDir() string {
+	return filepath.Join(n.BaseDir, "backup")
+}
+
+// HbaFile は pg_hba.conf のパスを返す
+func (n *Node) HbaFile() string {
+	return filepath.Join(n.DataDir(), "pg_hba.conf")
+}
+
+// IdentFile は pg_ident.conf のパスを返す
+func (n *Node) IdentFile() string {
+	return filepath.Join(n.DataDir(), "pg_ident.conf")
+}
+
+// LogFile はサーバーログのパスを返す
+func (n *Node) LogFile() string {
+	return n.logfile
+}
+
+// Init は initdb でデータディレクトリを作り、ノードで待ち受けるための設定を書く (init 相当)。
+// 認証は全て trust にする。extra は initdb にそのまま渡す引数。
+func (n *Node) Init(extra ...string) error {
+	if err := os.MkdirAll(filepath.Dir(n.logfile), 0755); err != nil {
+		return err
+	}
+	args := append([]string{"-D", n.DataDir(), "-A", "trust", "-N", "--no-instructions"}, extra...)
+	if out, err := exec.Command(n.program("initdb"), args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("initdb failed for node \"%s\": %w\n%s", n.Name, err, out)
+	}
+	return n.writeNodeConf()
+}
+
+// writeNodeConf はノードのアドレスとポート番号を postgresql.conf に書く。
+// Unix ドメインソケットはノードのディレクトリに置き、他のサーバーとぶつからないようにする。
+func (n *Node) writeNodeConf() error {
+	for _, kv := range [][2]string{
+		{"listen_addresses", n.Host},
+		{"port", strconv.Itoa(n.Port)},
+		{"unix_socket_directories", n.BaseDir},
+	} {
+		if err := n.AppendConf(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppendConf は postgresql.conf に設定パラメータを追加する (append_conf 相当)。
+// 次に起動したとき、または Reload したときから有効になる。
+func (n *Node) AppendConf(name, value string) error {
+	return appendLine(filepath.Join(n.DataDir(), "postgresql.conf"),
+		fmt.Sprintf("%s = '%s'", name, strings.ReplaceAll(value, "'", "''")))
+}
+
+// AppendHba は pg_hba.conf に行を追加する
+func (n *Node) AppendHba(line string) error {
+	return appendLine(n.HbaFile(), line)
+}
+
+// AppendIdent は pg_ident.conf に行を追加する
+func (n *Node) AppendIdent(line string) error {
+	return appendLine(n.IdentFile(), line)
+}
+
+func appendLine(filename, line string) error {
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, line)
+	return err
+}
+
+func (n *Node) program(name string) string {
+	if n.BinDir != "" {
+		return filepath.Join(n.BinDir, name)
+	}
+	return name
+}
+
+// Start はサーバーを起動し、接続を受け付けるようになるまで待つ (start 相当)
+func (n *Node) Start() error {
+	if n.cmd != nil {
+		return fmt.Errorf("node \"%s\" is already running", n.Name)
+	}
+	logf, err := os.OpenFile(n.logfile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer logf.Close()
+
+	cmd := exec.Command(n.program("postgres"), "-D", n.DataDir())
+	cmd.Stdout, cmd.Stderr = logf, logf
+	if len(n.Env) > 0 {
+		cmd.Env = append(os.Environ(), n.Env...)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start node \"%s\": %w", n.Name, err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	n.cmd, n.exited = cmd, exited
+
+	deadline := time.Now().Add(defaultTimeout())
+	for {
+		select {
+		case <-exited:
+			n.cmd = nil
+			return fmt.Errorf("node \"%s\" failed to start, examine \"%s\" for the reason", n.Name, n.logfile)
+		default:
+		}
+		if conn, err := n.Connect("postgres"); err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			n.Stop("immediate")
+			return fmt.Errorf("node \"%s\" did not start in time, examine \"%s\" for the reason", n.Name, n.logfile)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Stop はサーバーを止め、終了するまで待つ (stop 相当)。
+// mode は "smart"、"fast"、"immediate" のいずれかで、pg_ctl と同じシグナルを送る。
+func (n *Node) Stop(mode string) error {
+	if n.cmd == nil {
+		return nil
+	}
+	var sig os.Signal
+	switch mode {
+	case "smart":
+		sig = syscall.SIGTERM
+	case "fast", "":
+		sig = syscall.SIGINT
+	case "immediate":
+		sig = syscall.SIGQUIT
+	default:
+		return fmt.Errorf("unrecognized shutdown mode \"%s\"", mode)
+	}
+	if err := n.cmd.Process.Signal(sig); err != nil {
+		n.cmd.Process.Kill()
+	}
+	select {
+	case <-n.exited:
+	case <-time.After(defaultTimeout()):
+		n.cmd.Process.Kill()
+		<-n.exited
+	}
+	n.cmd = nil
+	return nil
+}
+
+// WaitForExit はサーバーが自ら終了するのを待ち、終了コードを返す。timeout までに終了しなければ
+// エラーを返し、サーバーは動かしたままにする。
+func (n *Node) WaitForExit(timeout time.Duration) (int, error) {
+	if n.cmd == nil {
+		return 0, fmt.Errorf("node \"%s\" is not running", n.Name)
+	}
+	select {
+	case err := <-n.exited:
+		n.cmd = nil
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 0, err
+	case <-time.After(timeout):
+		return 0, fmt.Errorf("node \"%s\" did not exit in time", n.Name)
+	}
+}
+
+// Restart はサーバーを止めてから起動し直す (restart 相当)
+func (n *Node) Restart() error {
+	if err := n.Stop("fast"); err != nil {
+		return err
+	}
+	return n.Start()
+}
+
+// Reload はサーバーに設定ファイルを読み直させる (reload 相当)
+func (n *Node) Reload() error {
+	if n.cmd == nil {
+		return fmt.Errorf("node \"%s\" is not running", n.Name)
+	}
+	return n.cmd.Process.Signal(syscall.SIGHUP)
+}
+
+// Teardown はサーバーを止め、ノードのディレクトリを削除する (teardown_node, clean_node 相当)
+func (n *Node) Teardown() error {
+	if err := n.Stop("immediate"); err != nil {
+		return err
+	}
+	return os.RemoveAll(n.BaseDir)
+}
+
+// ----------------------------------------------------------------
+// バックアップ
+// ----------------------------------------------------------------
+
+// BackupFSCold は止めたノードのデータディレクトリを、BackupDir の下の name に複製する
+// (backup_fs_cold 相当)。
+func (n *Node) BackupFSCold(name string) error {
+	if n.cmd != nil {
+		return fmt.Errorf("node \"%s\" must be stopped to take a cold backup", n.Name)
+	}
+	return copyDataDir(n.DataDir(), filepath.Join(n.BackupDir(), name))
+}
+
+// InitFromBackup は root のバックアップ name からこのノードのデータディレクトリを作る
+// (init_from_backup 相当)。ポート番号などの設定はこのノードのものにする。
+func (n *Node) InitFromBackup(root *Node, name string) error {
+	backup := filepath.Join(root.BackupDir(), name)
+	if _, err := os.Stat(backup); err != nil {
+		return fmt.Errorf("backup \"%s\" does not exist", backup)
+	}
+	if err := os.MkdirAll(filepath.Dir(n.logfile), 0755); err != nil {
+		return err
+	}
+	if err := copyDataDir(backup, n.DataDir()); err != nil {
+		return err
+	}
+	// 後に書いた値が優先されるため、root の設定の後ろに追加すればよい
+	return n.writeNodeConf()
+}
+
+// NewPrimaryStandby はプライマリのノードと、そのバックアップから作ったスタンバイのノードを
+// 作り、両方を起動して返す。プライマリは一度止めてバックアップを取ってから起動し直す。
+// レプリケーションがまだないため、スタンバイはプライマリに追従しない。
+func NewPrimaryStandby(testDir string, initdbArgs ...string) (primary, standby *Node, err error) {
+	primary, err = New("primary", testDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := primary.Init(initdbArgs...); err != nil {
+		return nil, nil, err
+	}
+	if err := primary.BackupFSCold("base"); err != nil {
+		return nil, nil, err
+	}
+	if err := primary.Start(); err != nil {
+		return nil, nil, err
+	}
+	standby, err = New("standby", testDir)
+	if err == nil {
+		err = standby.InitFromBackup(primary, "base")
+	}
+	if err == nil {
+		err = standby.Start()
+	}
+	if err != nil {
+		primary.Stop("immediate")
+		return nil, nil, err
+	}
+	return primary, standby, nil
+}
+
+// copyDataDir はデータディレクトリを複製する。サーバーが動いていたときに残るファイルは複製しない。
+func copyDataDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == "postmaster.pid" || rel == "postmaster.opts" {
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// Connstr はノードへの接続文字列を返す (connstr 相当)
+func (n *Node) Connstr(dbname string) string {
+	return fmt.Sprintf("host=%s port=%d dbname='%s'", n.Host, n.Port, dbname)
+}
+
+// Connect はノードに接続する
+func (n *Node) Connect(dbname string) (*libpq.Conn, error) {
+	return libpq.Connect(n.Connstr(dbname))
+}
+
+// Psql は問い合わせを実行し、結果を psql -XAt と同じ形式 (列は "|" 区切り、行は改行区切り) で返す
+// (psql 相当)。複数の文を含む場合は全ての結果を連結する。SQL のエラーは err として返す。
+func (n *Node) Psql(dbname, sql string) (string, error) {
+	conn, err := n.Connect(dbname)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	results, err := conn.ExecAll(sql)
+	if err != nil {
+		return "", err
+	}
+	var out []string
+	for _, res := range results {
+		if e := res.Err(); e != nil {
+			return strings.Join(out, "\n"), e
+		}
+		for row := 0; row < res.NTuples(); row++ {
+			vals := make([]string, res.NFields())
+			for col := range vals {
+				vals[col] = res.GetValue(row, col)
+			}
+			out = append(out, strings.Join(vals, "|"))
+		}
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// SafePsql は Psql と同じだが、エラーにノード名と問い合わせを含める (safe_psql 相当)
+func (n *Node) SafePsql(dbname, sql string) (string, error) {
+	out, err := n.Psql(dbname, sql)
+	if err != nil {
+		return out, fmt.Errorf("error running SQL on node \"%s\": %w\nwhile running: %s", n.Name, err, sql)
+	}
+	return out, nil
+}
+
+// PollQueryUntil は問い合わせの結果が expected になるまで繰り返し実行する (poll_query_until 相当)。
+// 上限の時間内に一致しなければ false を返す。
+func (n *Node) PollQueryUntil(dbname, query, expected string) bool {
+	deadline := time.Now().Add(defaultTimeout())
+	for {
+		if out, err := n.Psql(dbname, query); err == nil && out == expected {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// LogPosition はサーバーログの現在の大きさを返す。WaitForLog の開始位置に使う。
+func (n *Node) LogPosition() int64 {
+	fi, err := os.Stat(n.logfile)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// LogContains はサーバーログの offset バイト目以降に pattern に一致する部分があるかを返す (log_contains 相当)
+func (n *Node) LogContains(pattern string, offset int64) (bool, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(n.logfile)
+	if err != nil {
+		return false, err
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return re.Match(data[offset:]), nil
+}
+
+// WaitForLog はサーバーログの offset バイト目以降に pattern に一致する部分が現れるまで待つ (wait_for_log 相当)
+func (n *Node) WaitForLog(pattern string, offset int64) error {
+	deadline := time.Now().Add(defaultTimeout())
+	for {
+		found, err := n.LogContains(pattern, offset)
+		if err != nil {
+			return err
+		}
+		if found {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for match: %s", pattern)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,135 @@
+package cluster
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/injection"
+)
+
+var buildOnce struct {
+	sync.Once
+	dir string
+	err error
+}
+
+// requireBinDir はサーバーのプログラムのディレクトリを返す。PG_TEST_BINDIR がなければ
+// postgres と initdb をビルドする。initdb は root では動かないため、root ではテストを飛ばす。
+// テストをビルドタグ injection_points を付けてビルドした場合は、サーバーにも同じタグを付ける。
+func requireBinDir(t *testing.T) string {
+	t.Helper()
+	if os.Geteuid() == 0 {
+		t.Skip("initdb cannot be run as root")
+	}
+	if dir := os.Getenv("PG_TEST_BINDIR"); dir != "" {
+		return dir
+	}
+	buildOnce.Do(func() {
+		buildOnce.dir, buildOnce.err = os.MkdirTemp("", "cluster_bin_")
+		for _, name := range []string{"postgres", "initdb"} {
+			if buildOnce.err != nil {
+				return
+			}
+			args := []string{"build", "-o", filepath.Join(buildOnce.dir, name)}
+			if injection.Enabled {
+				args = append(args, "-tags", "injection_points")
+			}
+			out, err := exec.Command("go", append(args, "github.com/Tsubasa-2005/go-postgres/cmd/"+name)...).CombinedOutput()
+			if err != nil {
+				buildOnce.err = err
+				t.Log(string(out))
+			}
+		}
+	})
+	if buildOnce.err != nil {
+		t.Fatalf("could not build server programs: %v", buildOnce.err)
+	}
+	return buildOnce.dir
+}
+
+func newNode(t *testing.T, name string) *Node {
+	t.Helper()
+	bindir := requireBinDir(t)
+	n, err := New(name, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.BinDir = bindir
+	t.Cleanup(func() { n.Stop("immediate") })
+	return n
+}
+
+func safePsql(t *testing.T, n *Node, sql string) string {
+	t.Helper()
+	out, err := n.SafePsql("postgres", sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestInitFromColdBackup(t *testing.T) {
+	primary := newNode(t, "primary")
+	if err := primary.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.Start(); err != nil {
+		t.Fatal(err)
+	}
+	safePsql(t, primary, "ALTER SYSTEM SET statement_timeout = '42s'")
+	if err := primary.BackupFSCold("base"); err == nil {
+		t.Fatal("cold backup of a running node succeeded")
+	}
+	if err := primary.Stop("fast"); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.BackupFSCold("base"); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	standby := newNode(t, "standby")
+	if err := standby.InitFromBackup(primary, "base"); err != nil {
+		t.Fatal(err)
+	}
+	if err := standby.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// ALTER SYSTEM で書いた値はバックアップに含まれ、ポート番号はノードごとに異なる
+	if got := safePsql(t, standby, "SHOW statement_timeout"); got != "42s" {
+		t.Errorf("standby statement_timeout = %q, want 42s", got)
+	}
+	if got, want := safePsql(t, standby, "SHOW port"), safePsql(t, primary, "SHOW port"); got == want {
+		t.Errorf("standby uses the primary's port %s", got)
+	}
+}
+
+func TestPrimaryStandbyPair(t *testing.T) {
+	bindir := requireBinDir(t)
+	t.Setenv("PG_TEST_BINDIR", bindir)
+	primary, standby, err := NewPrimaryStandby(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		standby.Stop("immediate")
+		primary.Stop("immediate")
+	})
+	for _, n := range []*Node{primary, standby} {
+		if got := safePsql(t, n, "SELECT 1"); got != "1" {
+			t.Errorf("node %s returned %q, want 1", n.Name, got)
+		}
+	}
+	// 停止と再起動ができる
+	if err := standby.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if !standby.PollQueryUntil("postgres", "SELECT 1", "1") {
+		t.Error("standby did not come back after restart")
+	}
+}
@@ -0,0 +1,71 @@
+package cluster
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/injection"
+)
+
+// ----------------------------------------------------------------
+// 異常終了とリカバリの繰り返し
+// ----------------------------------------------------------------
+// インジェクションポイントでサーバーを異常終了させては起動し直し、そのたびにリカバリの後の
+// 不変条件を確かめる。サーバーはビルドタグ injection_points を付けてビルドしておく。
+//
+// 1回ごとに、PG_INJECTION_POINTS を設定してサーバーを起動し、サーバーが落ちるまで Workload を
+// 実行する。落ちたら設定を外して起動し直し、Check で不変条件を確かめてから止める。
+
+// CrashLoop は異常終了とリカバリを繰り返すテストの設定
+type CrashLoop struct {
+	Node *Node
+	// Points は回ごとに PG_INJECTION_POINTS に設定する値。要素の数だけ繰り返す
+	Points []string
+	// Workload はサーバーが落ちるまで実行する処理。サーバーが落ちると接続が切れるため、
+	// エラーを返しても失敗とはみなさない
+	Workload func(n *Node, round int) error
+	// Check は起動し直したサーバーで不変条件を確かめる。エラーを返すとテストは失敗する
+	Check func(n *Node, round int) error
+	// Timeout は Workload を終えてからサーバーが落ちるまで待つ上限。0 なら既定の上限
+	Timeout time.Duration
+}
+
+// Run は異常終了とリカバリを繰り返す。サーバーが落ちなかった回、起動し直せなかった回と、
+// 不変条件が成り立たなかった回があればエラーを返す。
+func (c *CrashLoop) Run() error {
+	n := c.Node
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout()
+	}
+	env := n.Env
+	defer func() { n.Env = env }()
+
+	for round, points := range c.Points {
+		n.Env = append(append([]string(nil), env...), injection.EnvVar+"="+points)
+		if err := n.Start(); err != nil {
+			return fmt.Errorf("round %d (%s): %w", round, points, err)
+		}
+		// エラーは接続が切れたことによるものとみなす
+		_ = c.Workload(n, round)
+		code, err := n.WaitForExit(timeout)
+		if err != nil {
+			n.Stop("immediate")
+			return fmt.Errorf("round %d (%s): injection point was not reached: %w", round, points, err)
+		}
+		if code != injection.CrashExitCode {
+			return fmt.Errorf("round %d (%s): server exited with code %d, want %d", round, points, code, injection.CrashExitCode)
+		}
+
+		n.Env = env
+		if err := n.Start(); err != nil {
+			return fmt.Errorf("round %d (%s): could not restart after crash: %w", round, points, err)
+		}
+		err = c.Check(n, round)
+		n.Stop("fast")
+		if err != nil {
+			return fmt.Errorf("round %d (%s): %w", round, points, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+//go:build injection_points
+
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/page"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/injection"
+)
+
+// PG_TEST_BINDIR を指定する場合は、サーバーをビルドタグ injection_points を付けてビルドしておく。
+
+func TestCrashDuringCheckpointKeepsCommittedXacts(t *testing.T) {
+	n := newNode(t, "crash")
+	if err := n.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// durable は成功したチェックポイントより前にコミットしたトランザクション、
+	// assigned はこれまでに割り当てられた最大のトランザクション ID
+	var durable []int64
+	var assigned int64
+	loop := &CrashLoop{
+		Node: n,
+		Points: []string{
+			"checkpoint-before-control-file=crash:1",
+			"mdsync=crash:2",
+			"fd-sync=crash:1",
+		},
+		Workload: func(n *Node, round int) error {
+			for range 20 {
+				out, err := n.Psql("postgres", "SELECT txid_current()")
+				if err != nil {
+					return err
+				}
+				xid, err := strconv.ParseInt(out, 10, 64)
+				if err != nil {
+					return err
+				}
+				assigned = max(assigned, xid)
+				if _, err := n.Psql("postgres", "CHECKPOINT"); err != nil {
+					return err
+				}
+				durable = append(durable, xid)
+			}
+			return nil
+		},
+		Check: func(n *Node, round int) error {
+			// 異常終了の前に割り当てた ID を割り当て直さない
+			out, err := n.SafePsql("postgres", "SELECT txid_current()")
+			if err != nil {
+				return err
+			}
+			if xid, _ := strconv.ParseInt(out, 10, 64); xid <= assigned {
+				return fmt.Errorf("txid_current() = %d after crash, but %d was already assigned", xid, assigned)
+			}
+			// チェックポイントで書き出したコミットの記録は失われない
+			for _, xid := range durable {
+				out, err := n.SafePsql("postgres", fmt.Sprintf("SELECT txid_status(%d)", xid))
+				if err != nil {
+					return err
+				}
+				if out != "committed" {
+					return fmt.Errorf("txid_status(%d) = %q after crash, want committed", xid, out)
+				}
+			}
+			return nil
+		},
+	}
+	if err := loop.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(durable) == 0 {
+		t.Error("no checkpoint succeeded before the crashes")
+	}
+}
+
+func TestFsyncFailureFailsCheckpoint(t *testing.T) {
+	n := newNode(t, "fsync")
+	if err := n.Init(); err != nil {
+		t.Fatal(err)
+	}
+	n.Env = []string{injection.EnvVar + "=mdsync=error"}
+	if err := n.Start(); err != nil {
+		t.Fatal(err)
+	}
+	safePsql(t, n, "SELECT txid_current()")
+	offset := n.LogPosition()
+	_, err := n.Psql("postgres", "CHECKPOINT")
+	if err == nil || !strings.Contains(err.Error(), "checkpoint request failed") {
+		t.Fatalf("CHECKPOINT with failing fsync = %v, want checkpoint request failed", err)
+	}
+	if err := n.WaitForLog(`could not fsync file ".*": injection point "mdsync": injected failure`, offset); err != nil {
+		t.Error(err)
+	}
+	// 同期できなくてもサーバーは動き続ける
+	if got := safePsql(t, n, "SELECT 1"); got != "1" {
+		t.Errorf("SELECT 1 after failed checkpoint = %q", got)
+	}
+	n.Stop("immediate")
+
+	// 同期できるようになれば、残した要求を同期できる
+	n.Env = nil
+	if err := n.Start(); err != nil {
+		t.Fatal(err)
+	}
+	safePsql(t, n, "SELECT txid_current()")
+	safePsql(t, n, "CHECKPOINT")
+}
+
+// writePages はチェックサムのないページを nblocks 個持つリレーションのファイルを作る。
+// SQL でまだリレーションにページを書けないため、停止中のクラスタに直接置く。
+func writePages(t *testing.T, path string, nblocks int) {
+	t.Helper()
+	data := make([]byte, nblocks*pgconfig.BlckSz)
+	for blkno := range nblocks {
+		p := data[blkno*pgconfig.BlckSz : (blkno+1)*pgconfig.BlckSz]
+		page.Init(p, 0)
+		if _, err := page.AddItem(p, []byte(fmt.Sprintf("tuple %d", blkno)), storage.InvalidOffsetNumber, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTornPageWhileEnablingChecksums(t *testing.T) {
+	n := newNode(t, "torn")
+	if err := n.Init(); err != nil {
+		t.Fatal(err)
+	}
+	dbs, err := filepath.Glob(filepath.Join(n.DataDir(), "base", "*"))
+	if err != nil || len(dbs) == 0 {
+		t.Fatalf("no database directory: %v", err)
+	}
+	relPath := filepath.Join(dbs[0], "16384")
+	const nblocks = 4
+	writePages(t, relPath, nblocks)
+
+	loop := &CrashLoop{
+		Node:   n,
+		Points: []string{"mdwrite=tear:512"},
+		Workload: func(n *Node, round int) error {
+			_, err := n.Psql("postgres", "SELECT pg_enable_data_checksums()")
+			return err
+		},
+		Check: func(n *Node, round int) error {
+			// 有効化の途中の状態は記録しないため、off に戻る
+			out, err := n.SafePsql("postgres", "SHOW data_checksums")
+			if err != nil {
+				return err
+			}
+			if out != "off" {
+				return fmt.Errorf("data_checksums after crash = %q, want off", out)
+			}
+			// もう一度有効にすれば、途中まで書いたページも書き直す
+			if _, err := n.SafePsql("postgres", "SELECT pg_enable_data_checksums()"); err != nil {
+				return err
+			}
+			if !n.PollQueryUntil("postgres", "SHOW data_checksums", "on") {
+				return fmt.Errorf("data checksums were not enabled after crash")
+			}
+			return nil
+		},
+	}
+	if err := loop.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(relPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for blkno := range nblocks {
+		p := data[blkno*pgconfig.BlckSz : (blkno+1)*pgconfig.BlckSz]
+		if !page.VerifyChecksum(p, uint32(blkno)) {
+			t.Errorf("block %d has no valid checksum", blkno)
+		}
+		if got, want := string(page.GetItem(p, page.GetItemID(p, 1))), fmt.Sprintf("tuple %d", blkno); got != want {
+			t.Errorf("block %d item = %q, want %q", blkno, got, want)
+		}
+	}
+}
@@ -0,0 +1,589 @@
+package regress
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/interfaces/libpq"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/spf13/cobra"
+)
+
+// ----------------------------------------------------------------
+// 回帰テストの実行と結果の比較 (test/regress/pg_regress.c 相当)
+// ----------------------------------------------------------------
+// スケジュールファイルまたはコマンドライン引数で指定したテストを実行し、
+// 出力 (results/テスト名.out) を期待される出力 (expected/テスト名.out) と比較する。
+// テストごとの起動の仕方は呼び出し側が StartTestFunc で与える。SQL のテスト (pg_regress) は
+// psql に SQL ファイルを流し込み、隔離性のテスト (pg_isolation_regress) は isolationtester を使う。
+//
+// 期待される出力は、環境による違いを許すために expected/テスト名_N.out (N は 0 から 9) という
+// 別の版を持てる。どれか1つと一致すれば成功とし、どれとも一致しなければ差分の最も小さいものとの
+// 差分を regression.diffs に書く。
+//
+// スケジュールファイルの各行は "test: テスト名 ..." の形で、1行に複数のテストを書くと
+// それらを並列に実行する。"#" で始まる行はコメント。
+
+// Options は回帰テストの設定
+type Options struct {
+	InputDir     string
+	OutputDir    string
+	ExpectDir    string
+	BinDir       string
+	Schedules    []string
+	DBName       string
+	Host         string
+	Port         int
+	User         string
+	TempInstance string
+	// MaxConcurrentTests は並列に実行するテストの数の上限。0 なら上限なし
+	MaxConcurrentTests int
+}
+
+// TestProcess は起動したテスト1つ分
+type TestProcess struct {
+	Cmd *exec.Cmd
+	// ResultFiles[i] を ExpectFiles[i] (既定の期待される出力) と比較する
+	ResultFiles []string
+	ExpectFiles []string
+}
+
+// StartTestFunc はテストを起動する。出力は ResultFiles に書かせる。
+type StartTestFunc func(opts *Options, testname string) (*TestProcess, error)
+
+// diff のオプション (basic_diff_opts, pretty_diff_opts 相当)
+const (
+	basicDiffOpts  = ""
+	prettyDiffOpts = "-U3"
+)
+
+// regression は実行中の回帰テストの状態
+type regression struct {
+	opts      *Options
+	startTest StartTestFunc
+
+	logfile      *os.File // regression.out
+	difffilename string
+	logfilename  string
+
+	testNumber int
+	failCount  int
+	postmaster *exec.Cmd
+	// postmasterExited には一時的なサーバーの cmd.Wait の結果が届く
+	postmasterExited chan error
+	// sockdir は一時的なサーバーの Unix ドメインソケットを置く一時ディレクトリ
+	sockdir string
+}
+
+// RegressionMain は回帰テストのドライバのメイン処理 (regression_main 相当)。
+// コマンドライン引数を解析してテストを実行し、終了コードを返す。
+func RegressionMain(progname string, startTest StartTestFunc) int {
+	opts := &Options{DBName: "regression"}
+	var dbnames string
+	var exitCode int
+	var rootCmd = &cobra.Command{
+		Use:   progname + " [OPTION]... [EXTRA-TEST]...",
+		Short: "PostgreSQL regression test driver",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dbnames != "" {
+				opts.DBName = strings.Split(dbnames, ",")[0]
+			}
+			if opts.ExpectDir == "" {
+				opts.ExpectDir = opts.InputDir
+			}
+			r := &regression{opts: opts, startTest: startTest}
+			code, err := r.run(args)
+			exitCode = code
+			return err
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	f := rootCmd.Flags()
+	// -h は接続先のホストに使うため、ヘルプは長い名前だけにする
+	f.Bool("help", false, "show this help, then exit")
+	f.StringVar(&opts.InputDir, "inputdir", ".", "take input files from DIR")
+	f.StringVar(&opts.OutputDir, "outputdir", ".", "place output files in DIR")
+	f.StringVar(&opts.ExpectDir, "expecteddir", "", "take expected files from DIR (default: inputdir)")
+	f.StringVar(&opts.BinDir, "bindir", "", "use programs in DIR (default: search PATH)")
+	f.StringArrayVar(&opts.Schedules, "schedule", nil, "use test ordering schedule FILE (may be used multiple times)")
+	f.StringVar(&dbnames, "dbname", "", "use database DB (default \"regression\")")
+	f.StringVar(&opts.Host, "host", "", "use postmaster running on HOST")
+	f.IntVar(&opts.Port, "port", 0, "start postmaster on PORT, or connect to it")
+	f.StringVar(&opts.User, "user", "", "connect as USER")
+	f.StringVar(&opts.TempInstance, "temp-instance", "", "start a temporary instance with its logs in DIR")
+	f.IntVar(&opts.MaxConcurrentTests, "max-concurrent-tests", 0, "maximum number of concurrent tests in parallel groups")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", progname, err.Error())
+		if exitCode == 0 {
+			exitCode = 2
+		}
+	}
+	return exitCode
+}
+
+// run はテストを実行する。戻り値は終了コードで、テストが失敗した場合は 1 を返す。
+func (r *regression) run(extraTests []string) (int, error) {
+	opts := r.opts
+	if err := os.MkdirAll(filepath.Join(opts.OutputDir, "results"), 0755); err != nil {
+		return 2, err
+	}
+	r.logfilename = filepath.Join(opts.OutputDir, "regression.out")
+	r.difffilename = filepath.Join(opts.OutputDir, "regression.diffs")
+	logfile, err := os.Create(r.logfilename)
+	if err != nil {
+		return 2, fmt.Errorf("could not open file \"%s\" for writing: %w", r.logfilename, err)
+	}
+	r.logfile = logfile
+	defer logfile.Close()
+	// 前回の差分は消しておく
+	os.Remove(r.difffilename)
+
+	if opts.TempInstance != "" {
+		if err := r.startTempInstance(); err != nil {
+			return 2, err
+		}
+		defer r.stopTempInstance()
+	} else {
+		host := opts.Host
+		if host == "" {
+			host = "localhost"
+		}
+		port := opts.Port
+		if port == 0 {
+			port = pgconfig.DefPgPort
+		}
+		r.note("using postmaster on %s, port %d", host, port)
+	}
+	r.setConnectionEnv()
+
+	// データベースの作成 (DROP DATABASE / CREATE DATABASE) は、CREATE DATABASE を
+	// 実装するまで行わない。サーバーは接続先のデータベース名を確かめないため、そのまま接続できる。
+
+	for _, schedule := range opts.Schedules {
+		if err := r.runSchedule(schedule); err != nil {
+			return 2, err
+		}
+	}
+	for _, test := range extraTests {
+		if err := r.runSingleTest(test); err != nil {
+			return 2, err
+		}
+	}
+
+	r.emit("1..%d\n", r.testNumber)
+	if r.failCount == 0 {
+		r.note("All %d tests passed.", r.testNumber)
+		return 0, nil
+	}
+	r.note("%d of %d tests failed.", r.failCount, r.testNumber)
+	r.note("The differences that caused some tests to fail can be viewed in the file \"%s\".", r.difffilename)
+	r.note("A copy of the test summary that you see above is saved in the file \"%s\".", r.logfilename)
+	return 1, nil
+}
+
+// emit は標準出力と regression.out の両方に書く。
+func (r *regression) emit(format string, args ...any) {
+	s := fmt.Sprintf(format, args...)
+	os.Stdout.WriteString(s)
+	r.logfile.WriteString(s)
+}
+
+// note は TAP のコメントとして1行書く (note 相当)
+func (r *regression) note(format string, args ...any) {
+	r.emit("# "+format+"\n", args...)
+}
+
+// setConnectionEnv はテストのプログラムが接続先を知るための環境変数を設定する。
+func (r *regression) setConnectionEnv() {
+	if r.opts.Host != "" {
+		os.Setenv("PGHOST", r.opts.Host)
+	}
+	if r.opts.Port != 0 {
+		os.Setenv("PGPORT", strconv.Itoa(r.opts.Port))
+	}
+	if r.opts.User != "" {
+		os.Setenv("PGUSER", r.opts.User)
+	}
+	os.Setenv("PGDATABASE", r.opts.DBName)
+}
+
+// FindProgram はテストで使うプログラムのパスを返す。--bindir が指定されていればその中を、
+// なければ自身と同じディレクトリを探し、見つからなければ PATH から探す。
+func FindProgram(opts *Options, name string) string {
+	if opts.BinDir != "" {
+		return filepath.Join(opts.BinDir, name)
+	}
+	if self, err := os.Executable(); err == nil {
+		path := filepath.Join(filepath.Dir(self), name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return name
+}
+
+// ----------------------------------------------------------------
+// 一時的なサーバー
+// ----------------------------------------------------------------
+
+// startTempInstance は temp-instance に initdb でデータディレクトリを作って一時的なサーバーを
+// 起動し、接続できるようになるまで待つ。サーバーは TCP で待ち受けず、一時ディレクトリの
+// Unix ドメインソケットだけで接続を受ける (make_temp_sockdir 相当)。
+func (r *regression) startTempInstance() error {
+	opts := r.opts
+	if _, err := os.Stat(opts.TempInstance); err == nil {
+		r.note("removing existing temp instance")
+		if err := os.RemoveAll(opts.TempInstance); err != nil {
+			return fmt.Errorf("could not remove temp instance \"%s\": %w", opts.TempInstance, err)
+		}
+	}
+	logdir := filepath.Join(opts.TempInstance, "log")
+	if err := os.MkdirAll(logdir, 0755); err != nil {
+		return err
+	}
+	datadir := filepath.Join(opts.TempInstance, "data")
+
+	r.note("initializing database system by running initdb")
+	initdbLog := filepath.Join(logdir, "initdb.log")
+	ilog, err := os.Create(initdbLog)
+	if err != nil {
+		return err
+	}
+	initdbArgs := []string{"-D", datadir, "--no-clean", "--no-sync", "--no-instructions"}
+	if opts.User != "" {
+		initdbArgs = append(initdbArgs, "-U", opts.User)
+	}
+	initdb := exec.Command(FindProgram(opts, "initdb"), initdbArgs...)
+	initdb.Stdout, initdb.Stderr = ilog, ilog
+	err = initdb.Run()
+	ilog.Close()
+	if err != nil {
+		return fmt.Errorf("initdb failed\nExamine \"%s\" for the reason.", initdbLog)
+	}
+
+	// 既定のポート番号は、稼働中の既定のサーバーとぶつからないようバージョンから決める
+	if opts.Port == 0 {
+		opts.Port = 0xC000 | (pgconfig.PgVersionNum & 0x3FFF)
+	}
+	sockdir, err := os.MkdirTemp("", "pg_regress-")
+	if err != nil {
+		return fmt.Errorf("could not create directory for Unix socket: %w", err)
+	}
+	r.sockdir = sockdir
+	if opts.Host == "" {
+		opts.Host = sockdir
+	}
+
+	logpath := filepath.Join(logdir, "postmaster.log")
+	logf, err := os.Create(logpath)
+	if err != nil {
+		return err
+	}
+	defer logf.Close()
+	cmd := exec.Command(FindProgram(opts, "postgres"), "-D", datadir, "-p", strconv.Itoa(opts.Port),
+		"-k", sockdir, "--listen-addresses=")
+	cmd.Stdout, cmd.Stderr = logf, logf
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not spawn postmaster: %w", err)
+	}
+	r.postmaster = cmd
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	r.postmasterExited = exited
+	conninfo := fmt.Sprintf("host=%s port=%d dbname=postgres", opts.Host, opts.Port)
+	if opts.User != "" {
+		conninfo += " user=" + opts.User
+	}
+	deadline := time.Now().Add(60 * time.Second)
+	for {
+		select {
+		case <-exited:
+			r.postmaster = nil
+			r.stopTempInstance()
+			return fmt.Errorf("postmaster failed, examine \"%s\" for the reason", logpath)
+		default:
+		}
+		if conn, err := libpq.Connect(conninfo); err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			r.stopTempInstance()
+			return fmt.Errorf("postmaster did not respond within 60 seconds, examine \"%s\" for the reason", logpath)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	r.note("using temp instance on port %d with PID %d", opts.Port, cmd.Process.Pid)
+	return nil
+}
+
+// stopTempInstance は一時的なサーバーを止める。
+func (r *regression) stopTempInstance() {
+	if r.sockdir != "" {
+		defer os.RemoveAll(r.sockdir)
+		r.sockdir = ""
+	}
+	if r.postmaster == nil {
+		return
+	}
+	if err := r.postmaster.Process.Signal(os.Interrupt); err != nil {
+		r.postmaster.Process.Kill()
+	}
+	select {
+	case <-r.postmasterExited:
+	case <-time.After(10 * time.Second):
+		r.postmaster.Process.Kill()
+		<-r.postmasterExited
+	}
+	r.postmaster = nil
+}
+
+// ----------------------------------------------------------------
+// テストの実行
+// ----------------------------------------------------------------
+
+// runSchedule はスケジュールファイルのテストを順に実行する (run_schedule 相当)
+func (r *regression) runSchedule(schedule string) error {
+	f, err := os.Open(schedule)
+	if err != nil {
+		return fmt.Errorf("could not open file \"%s\" for reading: %w", schedule, err)
+	}
+	defer f.Close()
+
+	lineno := 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lineno++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rest, ok := strings.CutPrefix(line, "test:")
+		if !ok {
+			return fmt.Errorf("syntax error in schedule file \"%s\" line %d: %s", schedule, lineno, line)
+		}
+		tests := strings.Fields(rest)
+		switch len(tests) {
+		case 0:
+			return fmt.Errorf("syntax error in schedule file \"%s\" line %d: %s", schedule, lineno, line)
+		case 1:
+			err = r.runSingleTest(tests[0])
+		default:
+			err = r.runParallelGroup(tests)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// runningTest は実行中のテスト
+type runningTest struct {
+	name    string
+	proc    *TestProcess
+	started time.Time
+	elapsed time.Duration
+	err     error
+}
+
+func (r *regression) start(name string) (*runningTest, error) {
+	t := &runningTest{name: name, started: time.Now()}
+	proc, err := r.startTest(r.opts, name)
+	if err != nil {
+		return nil, err
+	}
+	t.proc = proc
+	return t, nil
+}
+
+// wait はテストの終了を待つ。テストのプログラムが 0 以外で終了しても、
+// 出力の比較で失敗を判定するため、ここではエラーにしない。
+func (t *runningTest) wait() {
+	err := t.proc.Cmd.Wait()
+	t.elapsed = time.Since(t.started)
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.err = err
+	}
+}
+
+// runSingleTest はテストを1つ実行する (run_single_test 相当)
+func (r *regression) runSingleTest(name string) error {
+	t, err := r.start(name)
+	if err != nil {
+		return err
+	}
+	t.wait()
+	return r.report(t, false)
+}
+
+// runParallelGroup はテストを並列に実行する。終わった順にテスト名を書き、
+// 全て終わってからスケジュールの順に結果を書く。
+func (r *regression) runParallelGroup(names []string) error {
+	limit := r.opts.MaxConcurrentTests
+	if limit <= 0 || limit > len(names) {
+		limit = len(names)
+	}
+
+	tests := make([]*runningTest, len(names))
+	done := make(chan int, len(names))
+	var finished []string
+	next := 0
+	running := 0
+	for next < len(names) || running > 0 {
+		for running < limit && next < len(names) {
+			t, err := r.start(names[next])
+			if err != nil {
+				return err
+			}
+			tests[next] = t
+			go func(i int) {
+				tests[i].wait()
+				done <- i
+			}(next)
+			next++
+			running++
+		}
+		i := <-done
+		running--
+		finished = append(finished, names[i])
+	}
+
+	r.note("parallel group (%d tests):  %s", len(names), strings.Join(finished, " "))
+	for _, t := range tests {
+		if err := r.report(t, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// report はテストの出力を比較し、結果を1行書く (test_status_ok, test_status_failed 相当)
+func (r *regression) report(t *runningTest, parallel bool) error {
+	if t.err != nil {
+		return fmt.Errorf("could not run test \"%s\": %w", t.name, t.err)
+	}
+	differ := false
+	for i, result := range t.proc.ResultFiles {
+		d, err := r.resultsDiffer(t.name, result, t.proc.ExpectFiles[i])
+		if err != nil {
+			return err
+		}
+		differ = differ || d
+	}
+
+	r.testNumber++
+	status, numberWidth := "ok", 5
+	if differ {
+		status, numberWidth = "not ok", 1
+		r.failCount++
+	}
+	marker := "-"
+	if parallel {
+		marker = "+"
+	}
+	r.emit("%s %-*d %s %-36s %8d ms\n", status, numberWidth, r.testNumber, marker, t.name, t.elapsed.Milliseconds())
+	return nil
+}
+
+// resultsDiffer は出力を期待される出力 (とその別の版) と比較する (results_differ 相当)。
+// どれとも一致しなければ、差分の最も少ないものとの差分を regression.diffs に追記して true を返す。
+func (r *regression) resultsDiffer(testname, resultsFile, defaultExpectFile string) (bool, error) {
+	candidates := []string{defaultExpectFile}
+	ext := filepath.Ext(defaultExpectFile)
+	base := strings.TrimSuffix(defaultExpectFile, ext)
+	for i := 0; i <= 9; i++ {
+		alt := fmt.Sprintf("%s_%d%s", base, i, ext)
+		if _, err := os.Stat(alt); err == nil {
+			candidates = append(candidates, alt)
+		}
+	}
+
+	bestFile, bestLines := "", -1
+	for _, expect := range candidates {
+		if _, err := os.Stat(expect); err != nil {
+			continue
+		}
+		out, same, err := runDiff(basicDiffOpts, expect, resultsFile)
+		if err != nil {
+			return false, err
+		}
+		if same {
+			return false, nil
+		}
+		if n := bytes.Count(out, []byte("\n")); bestLines < 0 || n < bestLines {
+			bestFile, bestLines = expect, n
+		}
+	}
+	if bestFile == "" {
+		// 期待される出力がない場合は、空のファイルとの差分として全体を見せる
+		bestFile = os.DevNull
+	}
+
+	out, _, err := runDiff(prettyDiffOpts, bestFile, resultsFile)
+	if err != nil {
+		return false, err
+	}
+	difffile, err := os.OpenFile(r.difffilename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return false, fmt.Errorf("could not open file \"%s\" for writing: %w", r.difffilename, err)
+	}
+	defer difffile.Close()
+	fmt.Fprintf(difffile, "diff %s %s %s\n", prettyDiffOpts, bestFile, resultsFile)
+	difffile.Write(out)
+	return true, nil
+}
+
+// runDiff は diff を実行する。same は差分がなかったことを表す。
+func runDiff(opts, expect, result string) (out []byte, same bool, err error) {
+	args := append(strings.Fields(opts), expect, result)
+	cmd := exec.Command("diff", args...)
+	out, err = cmd.Output()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return out, true, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return out, false, nil
+	}
+	return nil, false, fmt.Errorf("diff command failed: %w", err)
+}
+
+// OpenResultFile はテストの出力を書くファイルを作る。
+func OpenResultFile(path string) (*os.File, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file \"%s\" for writing: %w", path, err)
+	}
+	return f, nil
+}
+
+// StartWithIO はテストのプログラムを、標準入力を infile、標準出力と標準エラー出力を
+// outfile にして起動する (spawn_process 相当)。
+func StartWithIO(cmd *exec.Cmd, infile, outfile string) error {
+	in, err := os.Open(infile)
+	if err != nil {
+		return fmt.Errorf("could not open file \"%s\" for reading: %w", infile, err)
+	}
+	defer in.Close()
+	out, err := OpenResultFile(outfile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	cmd.Stdin = in
+	cmd.Stdout, cmd.Stderr = out, out
+	return cmd.Start()
+}
@@ -0,0 +1,22 @@
+package storage
+
+import "github.com/Tsubasa-2005/go-postgres/internal/catalog"
+
+// ----------------------------------------------------------------
+// ブロック番号とリレーションのファイルの識別子 (storage/block.h, storage/relfilelocator.h 相当)
+// ----------------------------------------------------------------
+// リレーションのファイルはデータベースの OID と relfilenumber で識別し、ファイルの中のページは
+// 0 から数えたブロック番号で指す。テーブル空間はまだないため、識別子にテーブル空間の OID を含めない。
+
+// BlockNumber はリレーションのファイルの中のページの番号 (BlockNumber 相当)
+type BlockNumber uint32
+
+// InvalidBlockNumber はページを指さないことを表す (InvalidBlockNumber 相当)
+const InvalidBlockNumber BlockNumber = 0xFFFFFFFF
+
+// RelFileLocator はリレーションのファイルを識別する (RelFileLocator 相当)。共有カタログは
+// DbOid を InvalidOid にする。
+type RelFileLocator struct {
+	DbOid     catalog.Oid
+	RelNumber catalog.Oid
+}
@@ -0,0 +1,238 @@
+package buffer
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/ipc"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// 共有バッファの初期化 (storage/buffer/buf_init.c, storage/buf_internals.h 相当)
+// ----------------------------------------------------------------
+// 共有バッファは shared_buffers 個の BLCKSZ のページで、サーバーの起動時に共有メモリに確保する。
+// バッファごとに記述子を置き、どのページを持っているか (タグ) と、ピンの数、使用回数、汚れて
+// いるかなどのフラグを1つの語 (state) に詰めて持つ。state はヘッダーのスピンロック (BM_LOCKED)
+// を取るか、不可分の比較交換で書き換える。
+//
+// 記述子とページと置き換えの状態は共有メモリに置く。バッファの内容のロック、入出力の完了の待ち、
+// タグからバッファを引くマッピング表は Go のポインターを含むため Go のメモリに置き、共有メモリを
+// 作るたびに作り直す。そのため、共有バッファを使えるのは共有メモリを作ったプロセスの
+// ゴルーチンだけである。
+
+// バッファの状態の語のビット (BUF_* と BM_* 相当)。下位18ビットがピンの数、その上の4ビットが
+// 使用回数、残りがフラグ。
+const (
+	bufRefcountOne     uint32 = 1
+	bufRefcountMask    uint32 = (1 << 18) - 1
+	bufUsagecountShift        = 18
+	bufUsagecountMask  uint32 = 0x003C0000
+	bufUsagecountOne   uint32 = 1 << bufUsagecountShift
+	bufFlagMask        uint32 = 0xFFC00000
+
+	// bmLocked は記述子のヘッダーのスピンロック
+	bmLocked uint32 = 1 << 22
+	bmDirty  uint32 = 1 << 23
+	// bmValid はページの内容が正しいことを表す
+	bmValid uint32 = 1 << 24
+	// bmTagValid はタグが割り当て済みであることを表す
+	bmTagValid     uint32 = 1 << 25
+	bmIOInProgress uint32 = 1 << 26
+	// bmIOError は前回の入出力が失敗したことを表す
+	bmIOError uint32 = 1 << 27
+	// bmJustDirtied は書き出しを始めた後に汚れたことを表す
+	bmJustDirtied uint32 = 1 << 28
+	// bmCheckpointNeeded は進行中のチェックポイントで書き出す必要があることを表す
+	bmCheckpointNeeded uint32 = 1 << 30
+	// bmPermanent は永続的なリレーションのページであることを表す
+	bmPermanent uint32 = 1 << 31
+)
+
+// bmMaxUsageCount は使用回数の上限 (BM_MAX_USAGE_COUNT 相当)
+const bmMaxUsageCount = 5
+
+func bufStateGetRefcount(state uint32) uint32 { return state & bufRefcountMask }
+
+func bufStateGetUsagecount(state uint32) uint32 {
+	return (state & bufUsagecountMask) >> bufUsagecountShift
+}
+
+// 空きリストの印 (FREENEXT_* 相当)
+const (
+	freeNextEndOfList int32 = -1
+	freeNextNotInList int32 = -2
+)
+
+// numBufferPartitions はマッピング表の分割の数 (NUM_BUFFER_PARTITIONS 相当)
+const numBufferPartitions = 128
+
+// shmemAlignPadding は ShmemInitStruct が割り当てをキャッシュラインの境界に揃えるための余裕
+// (PG_CACHE_LINE_SIZE 相当)
+const shmemAlignPadding = 128
+
+// BufferTag はバッファが持っているページ (BufferTag 相当)
+type BufferTag struct {
+	RLocator storage.RelFileLocator
+	ForkNum  storage.ForkNumber
+	BlockNum storage.BlockNumber
+}
+
+// clearBufferTag はタグをどのページも指さないものにする (ClearBufferTag 相当)
+func clearBufferTag(tag *BufferTag) {
+	*tag = BufferTag{ForkNum: storage.InvalidForkNumber, BlockNum: storage.InvalidBlockNumber}
+}
+
+// bufferDesc はバッファの記述子 (BufferDesc 相当)。共有メモリに置く。
+type bufferDesc struct {
+	tag   BufferTag
+	bufID int32
+	state atomic.Uint32
+	// freeNext は空きリストの次のバッファ。空きリストのスピンロックで守る
+	freeNext int32
+}
+
+// bufferDescPadded は記述子どうしが同じキャッシュラインを共有しないよう 64 バイトに揃えた
+// 記述子 (BufferDescPadded 相当)
+type bufferDescPadded struct {
+	bufferDesc
+	_ [64 - unsafe.Sizeof(bufferDesc{})]byte
+}
+
+// lockBufHdr は記述子のヘッダーのスピンロックを取り、取った後の状態を返す (LockBufHdr 相当)
+func (d *bufferDesc) lockBufHdr() uint32 {
+	for {
+		old := d.state.Load()
+		if old&bmLocked == 0 && d.state.CompareAndSwap(old, old|bmLocked) {
+			return old | bmLocked
+		}
+		runtime.Gosched()
+	}
+}
+
+// unlockBufHdr は state を書き込んでヘッダーのスピンロックを放す (UnlockBufHdr 相当)
+func (d *bufferDesc) unlockBufHdr(state uint32) {
+	d.state.Store(state &^ bmLocked)
+}
+
+// waitBufHdrUnlocked はヘッダーのスピンロックが放されるまで待ち、状態を返す
+// (WaitBufHdrUnlocked 相当)
+func (d *bufferDesc) waitBufHdrUnlocked() uint32 {
+	for {
+		state := d.state.Load()
+		if state&bmLocked == 0 {
+			return state
+		}
+		runtime.Gosched()
+	}
+}
+
+// ioCondVar はバッファの入出力の完了の待ち (BufferDescriptorGetIOCV 相当)
+type ioCondVar struct {
+	mu   sync.Mutex
+	cond sync.Cond
+}
+
+// bufferPool は共有メモリに確保した共有バッファ
+type bufferPool struct {
+	nBuffers int
+	descs    []bufferDescPadded
+	// blocks はバッファのページを並べたもの (BufferBlocks 相当)
+	blocks   []byte
+	strategy *strategyControl
+	// contentLocks はバッファの内容のロック (BufferDescriptorGetContentLock 相当)
+	contentLocks []lmgr.LWLock
+	ioCVs        []ioCondVar
+	mapping      [numBufferPartitions]bufMappingPartition
+	// bgwNotify は次にバッファを割り当てたときに起こすバックグラウンドライター (bgwprocno 相当)
+	bgwNotify atomic.Pointer[func()]
+}
+
+// sharedPool は共有メモリを作ったときに確保した共有バッファ。まだ作っていなければ nil
+var sharedPool atomic.Pointer[bufferPool]
+
+func init() {
+	ipc.RegisterShmemModule(bufferShmemSize, initBufferPool)
+}
+
+// bufferShmemSize は共有バッファが使う共有メモリの大きさ (BufferShmemSize 相当)
+func bufferShmemSize() int {
+	n := guc.SharedBuffers.Get()
+	size := n*int(unsafe.Sizeof(bufferDescPadded{})) + shmemAlignPadding
+	size += n*pgconfig.BlckSz + shmemAlignPadding
+	size += int(unsafe.Sizeof(strategyControl{})) + shmemAlignPadding
+	return size
+}
+
+// initBufferPool は共有バッファを共有メモリに割り当て、初めて割り当てたなら全てのバッファを
+// 空きリストに入れる (BufferManagerShmemInit と StrategyInitialize 相当)
+func initBufferPool(seg *ipc.Segment) error {
+	n := guc.SharedBuffers.Get()
+	descBytes, foundDescs, err := seg.ShmemInitStruct("Buffer Descriptors", n*int(unsafe.Sizeof(bufferDescPadded{})))
+	if err != nil {
+		return err
+	}
+	blocks, foundBufs, err := seg.ShmemInitStruct("Buffer Blocks", n*pgconfig.BlckSz)
+	if err != nil {
+		return err
+	}
+	strategy, foundStrategy, err := ipc.ShmemInitStructOf[strategyControl](seg, "Buffer Strategy Status")
+	if err != nil {
+		return err
+	}
+	// 他のプロセスが作った共有メモリを割り当てただけなら、記述子は作ったプロセスが初期化している
+	if foundDescs || foundBufs || foundStrategy {
+		return nil
+	}
+
+	descs := unsafe.Slice((*bufferDescPadded)(unsafe.Pointer(unsafe.SliceData(descBytes))), n)
+	sharedPool.Store(newBufferPool(descs, blocks, strategy))
+	return nil
+}
+
+// newBufferPool は確保した記述子とページで共有バッファを作り、全てのバッファを空きリストに入れる
+func newBufferPool(descs []bufferDescPadded, blocks []byte, strategy *strategyControl) *bufferPool {
+	n := len(descs)
+	p := &bufferPool{
+		nBuffers:     n,
+		descs:        descs,
+		blocks:       blocks,
+		strategy:     strategy,
+		contentLocks: make([]lmgr.LWLock, n),
+		ioCVs:        make([]ioCondVar, n),
+	}
+	for i := range p.descs {
+		d := &p.descs[i].bufferDesc
+		clearBufferTag(&d.tag)
+		d.bufID = int32(i)
+		d.state.Store(0)
+		// 初めは全てのバッファを空きリストにつなぐ
+		d.freeNext = int32(i) + 1
+		lmgr.LWLockInitialize(&p.contentLocks[i], waitevent.LWLockBufferContent)
+		p.ioCVs[i].cond.L = &p.ioCVs[i].mu
+	}
+	p.descs[n-1].freeNext = freeNextEndOfList
+	for i := range p.mapping {
+		lmgr.LWLockInitialize(&p.mapping[i].lock, waitevent.LWLockBufferMapping)
+		p.mapping[i].table = make(map[BufferTag]int32, n/numBufferPartitions+1)
+	}
+	strategy.init(n)
+	return p
+}
+
+// desc は bufID のバッファの記述子を返す (GetBufferDescriptor 相当)
+func (p *bufferPool) desc(bufID int32) *bufferDesc {
+	return &p.descs[bufID].bufferDesc
+}
+
+// page は bufID のバッファのページを返す (BufHdrGetBlock 相当)
+func (p *bufferPool) page(bufID int32) []byte {
+	off := int(bufID) * pgconfig.BlckSz
+	return p.blocks[off : off+pgconfig.BlckSz : off+pgconfig.BlckSz]
+}
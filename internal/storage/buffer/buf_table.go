@@ -0,0 +1,57 @@
+package buffer
+
+import (
+	"hash/fnv"
+	"unsafe"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+)
+
+// ----------------------------------------------------------------
+// バッファのマッピング表 (storage/buffer/buf_table.c 相当)
+// ----------------------------------------------------------------
+// タグからそのページを持っているバッファを引く表。タグのハッシュ値で numBufferPartitions 個に
+// 分け、分割ごとの軽量ロック (BufferMappingLock 相当) で守る。引くときは共有、登録と削除は排他の
+// モードでロックを取る。
+
+// bufMappingPartition はマッピング表の分割1つ
+type bufMappingPartition struct {
+	lock  lmgr.LWLock
+	table map[BufferTag]int32
+}
+
+// bufTableHashCode はタグのハッシュ値 (BufTableHashCode 相当)
+func bufTableHashCode(tag BufferTag) uint32 {
+	h := fnv.New32a()
+	h.Write(unsafe.Slice((*byte)(unsafe.Pointer(&tag)), unsafe.Sizeof(tag)))
+	return h.Sum32()
+}
+
+// partition はハッシュ値の分割を返す (BufMappingPartitionLock 相当)
+func (p *bufferPool) partition(hashcode uint32) *bufMappingPartition {
+	return &p.mapping[hashcode%numBufferPartitions]
+}
+
+// lookup はタグのページを持つバッファを返す (BufTableLookup 相当)。分割のロックを取ってから呼ぶ。
+func (part *bufMappingPartition) lookup(tag BufferTag) (int32, bool) {
+	bufID, ok := part.table[tag]
+	return bufID, ok
+}
+
+// insert はタグを bufID に登録する (BufTableInsert 相当)。既に登録されていれば登録せず、
+// そのバッファを返す。分割のロックを排他のモードで取ってから呼ぶ。
+func (part *bufMappingPartition) insert(tag BufferTag, bufID int32) (existing int32, found bool) {
+	if existing, ok := part.table[tag]; ok {
+		return existing, true
+	}
+	part.table[tag] = bufID
+	return 0, false
+}
+
+// delete はタグの登録を消す (BufTableDelete 相当)。分割のロックを排他のモードで取ってから呼ぶ。
+func (part *bufMappingPartition) delete(tag BufferTag) {
+	if _, ok := part.table[tag]; !ok {
+		panic("shared buffer hash table corrupted")
+	}
+	delete(part.table, tag)
+}
@@ -0,0 +1,45 @@
+package buffer
+
+// ----------------------------------------------------------------
+// 共有バッファの内容の一覧 (contrib/pg_buffercache/pg_buffercache_pages.c 相当)
+// ----------------------------------------------------------------
+// pg_buffercache ビューのため、全てのバッファの記述子を読む。全体を止めないよう、バッファごとに
+// ヘッダーのスピンロックを取って写すため、一覧は一時点のものではない。
+
+// BufferCacheEntry はバッファ1つの状態 (BufferCachePagesRec 相当)
+type BufferCacheEntry struct {
+	Buffer Buffer
+	// Tag はバッファが持つページ。Valid が偽なら意味を持たない
+	Tag BufferTag
+	// Valid はページを読み終えていることを表す
+	Valid   bool
+	IsDirty bool
+	// UsageCount はクロックスイープが通り過ぎるまでに残る回数
+	UsageCount int
+	// PinningBackends はピンの数。同じバックエンドのピンは1つと数える
+	PinningBackends int
+}
+
+// GetBufferCacheEntries は全てのバッファの状態をバッファの番号の順に返す。共有メモリを
+// 作っていなければ nil を返す。
+func GetBufferCacheEntries() []BufferCacheEntry {
+	p := sharedPool.Load()
+	if p == nil {
+		return nil
+	}
+	entries := make([]BufferCacheEntry, p.nBuffers)
+	for i := range entries {
+		buf := p.desc(int32(i))
+		state := buf.lockBufHdr()
+		entries[i] = BufferCacheEntry{
+			Buffer:          Buffer(buf.bufID + 1),
+			Tag:             buf.tag,
+			Valid:           state&bmValid != 0 && state&bmTagValid != 0,
+			IsDirty:         state&bmDirty != 0,
+			UsageCount:      int(bufStateGetUsagecount(state)),
+			PinningBackends: int(bufStateGetRefcount(state)),
+		}
+		buf.unlockBufHdr(state)
+	}
+	return entries
+}
@@ -0,0 +1,825 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	pagepkg "github.com/Tsubasa-2005/go-postgres/internal/storage/page"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/pgstat"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// バッファマネージャー (storage/buffer/bufmgr.c 相当)
+// ----------------------------------------------------------------
+// リレーションのページを共有バッファに読み込み、バックエンドに貸す。ReadBuffer はページを持つ
+// バッファをマッピング表で探し、なければ置き換えるバッファを選んで Smgr から読み込む。返した
+// バッファにはピンが付いていて、ReleaseBuffer で外すまで他のページに置き換えられない。
+//
+// ページの内容を読むには LockBuffer で内容のロックを共有のモードで、書き換えるには排他のモードで
+// 取る。書き換えたら MarkBufferDirty で汚れた印を付け、置き換えるときかバックグラウンドライターが
+// Smgr に書き出す。1つのバッファの読み込みと書き出しは同時に1つだけで、他のバックエンドは
+// 入出力が終わるのを待つ。
+//
+// ピンの数は共有の記述子の数と、バックエンドごとの数 (Backend) の2段で数える。同じバッファに
+// 何度ピンを付けても、共有の数を変えるのは最初の1回と最後の1回だけにする。
+
+// Buffer は共有バッファの番号 (Buffer 相当)。1 から数え、0 はバッファを指さない。
+type Buffer int32
+
+// InvalidBuffer はバッファを指さないことを表す (InvalidBuffer 相当)
+const InvalidBuffer Buffer = 0
+
+// ReadBufferMode は ReadBufferExtended の読み方 (ReadBufferMode 相当)
+type ReadBufferMode int
+
+const (
+	// RBMNormal はページを読み込む
+	RBMNormal ReadBufferMode = iota
+	// RBMZeroAndLock はページを読み込まずに 0 で埋め、内容のロックを排他のモードで取って返す。
+	// 全体を書き換えるページに使う。既に共有バッファにあれば、内容はそのままにする
+	RBMZeroAndLock
+)
+
+// BufferLockMode は LockBuffer のモード (BUFFER_LOCK_* 相当)
+type BufferLockMode int
+
+const (
+	BufferLockUnlock BufferLockMode = iota
+	BufferLockShare
+	BufferLockExclusive
+)
+
+// Smgr はバッファマネージャーがページを読み書きする先 (smgr.c の smgrread、smgrwrite と
+// smgrprefetch 相当)。smgr パッケージがこのパッケージを使うため、smgr.Init が SetSmgr で実装を
+// 登録する。
+type Smgr interface {
+	// Read はリレーションのフォークのブロックを buf に読み込む
+	Read(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber, buf []byte) error
+	// Prefetch はリレーションのフォークのブロックの読み込みを OS に始めさせる
+	Prefetch(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber) error
+	// Write は buf をリレーションのフォークのブロックに書き出す
+	Write(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber, buf []byte) error
+}
+
+// smgr は SetSmgr で登録したストレージマネージャー
+var smgr atomic.Pointer[Smgr]
+
+// SetSmgr はページを読み書きするストレージマネージャーを登録する
+func SetSmgr(s Smgr) {
+	smgr.Store(&s)
+}
+
+func getSmgr() (Smgr, error) {
+	s := smgr.Load()
+	if s == nil {
+		return nil, errutil.Elog(errutil.Error, "storage manager is not initialized")
+	}
+	return *s, nil
+}
+
+// errBuffersNotInitialized は共有メモリを作る前に共有バッファを使おうとしたときのエラー
+func errBuffersNotInitialized() error {
+	return errutil.New(errutil.Error, errcodes.ObjectNotInPrerequisiteState, "shared buffers are not initialized")
+}
+
+// Backend はバックエンドが付けているバッファのピン (PrivateRefCount 相当)。バックエンドごとに
+// NewBackend で作り、そのバックエンドのゴルーチンだけが使う。
+type Backend struct {
+	proc      *lmgr.Proc
+	waitEvent *waitevent.Slot
+	// stats はブロックの読み書きにかけた時間を数える先
+	stats *pgstat.Pending
+	// refs はバッファごとにこのバックエンドが付けたピンの数
+	refs map[Buffer]int32
+}
+
+// NewBackend はバックエンドのバッファの管理を始める (InitBufferPoolAccess 相当)。内容のロックは
+// proc の軽量ロックとして取り、入出力の完了を待つ間は waitEvent に記録する。stats の
+// track_io_timing が on の間は、ブロックの読み書きにかけた時間を stats に数える。stats が nil
+// なら数えない。
+func NewBackend(proc *lmgr.Proc, waitEvent *waitevent.Slot, stats *pgstat.Pending) *Backend {
+	return &Backend{proc: proc, waitEvent: waitEvent, stats: stats, refs: make(map[Buffer]int32)}
+}
+
+// ReadBuffer はリレーションの本体のフォークのブロックを読み、ピンを付けたバッファを返す
+// (ReadBuffer 相当)
+func (b *Backend) ReadBuffer(rlocator storage.RelFileLocator, blockNum storage.BlockNumber) (Buffer, error) {
+	return b.ReadBufferExtended(rlocator, storage.MainForkNum, blockNum, RBMNormal, nil)
+}
+
+// ReadBufferExtended はリレーションのフォークのブロックを mode で読み、ピンを付けたバッファを
+// 返す (ReadBufferExtended と ReadBuffer_common 相当)。strategy が nil でなければ、新しいページは
+// そのリングのバッファに読み込む。
+func (b *Backend) ReadBufferExtended(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber, mode ReadBufferMode, strategy *BufferAccessStrategy) (Buffer, error) {
+	p := sharedPool.Load()
+	if p == nil {
+		return InvalidBuffer, errBuffersNotInitialized()
+	}
+	tag := BufferTag{RLocator: rlocator, ForkNum: forkNum, BlockNum: blockNum}
+	buf, found, err := b.bufferAlloc(p, tag, strategy)
+	if err != nil {
+		return InvalidBuffer, err
+	}
+	buffer := Buffer(buf.bufID + 1)
+	if found {
+		if mode == RBMZeroAndLock {
+			b.LockBuffer(buffer, BufferLockExclusive)
+		}
+		return buffer, nil
+	}
+
+	// 読み込みを始めたのはこのバックエンドのため、読み終えるまで他のバックエンドは待つ
+	page := p.page(buf.bufID)
+	if mode == RBMZeroAndLock {
+		clear(page)
+	} else if err := b.smgrRead(tag, page); err != nil {
+		p.terminateBufferIO(buf, false, bmIOError)
+		b.unpinBuffer(buf)
+		return InvalidBuffer, err
+	} else if !pagepkg.IsVerified(page, uint32(blockNum)) {
+		p.terminateBufferIO(buf, false, bmIOError)
+		b.unpinBuffer(buf)
+		return InvalidBuffer, errutil.New(errutil.Error, errcodes.DataCorrupted, "invalid page in block %d of relation %s",
+			blockNum, storage.RelPath(rlocator, forkNum))
+	}
+	// 他のバックエンドが 0 で埋めたページを見る前に内容のロックを取る
+	if mode == RBMZeroAndLock {
+		b.LockBuffer(buffer, BufferLockExclusive)
+	}
+	p.terminateBufferIO(buf, false, bmValid)
+	return buffer, nil
+}
+
+// PrefetchBufferResult は PrefetchBuffer の結果 (PrefetchBufferResult 相当)
+type PrefetchBufferResult struct {
+	// RecentBuffer はページを既に持っていたバッファ。なければ InvalidBuffer。ピンは付けないため、
+	// 読むまでに別のページに使われているかもしれない
+	RecentBuffer Buffer
+	// InitiatedIO は OS に読み込みを始めさせたかどうか
+	InitiatedIO bool
+}
+
+// PrefetchBuffer はリレーションのフォークのブロックをまもなく読むことを伝える (PrefetchBuffer と
+// PrefetchSharedBuffer 相当)。ページが共有バッファになければ、OS にファイルの読み込みを始め
+// させる。バッファは割り当てず、ピンも付けない。読むときは ReadBuffer を使う。
+func (b *Backend) PrefetchBuffer(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber) (PrefetchBufferResult, error) {
+	p := sharedPool.Load()
+	if p == nil {
+		return PrefetchBufferResult{}, errBuffersNotInitialized()
+	}
+	tag := BufferTag{RLocator: rlocator, ForkNum: forkNum, BlockNum: blockNum}
+	part := p.partition(bufTableHashCode(tag))
+	b.proc.LWLockAcquire(&part.lock, lmgr.LWShared)
+	bufID, found := part.lookup(tag)
+	b.proc.LWLockRelease(&part.lock)
+	if found {
+		return PrefetchBufferResult{RecentBuffer: Buffer(bufID + 1)}, nil
+	}
+	s, err := getSmgr()
+	if err != nil {
+		return PrefetchBufferResult{}, err
+	}
+	if err := s.Prefetch(rlocator, forkNum, blockNum); err != nil {
+		return PrefetchBufferResult{}, err
+	}
+	return PrefetchBufferResult{InitiatedIO: true}, nil
+}
+
+// bufferAlloc はタグのページを持つバッファを探し、なければ置き換えるバッファを選んでタグを
+// 割り当て、ピンを付けて返す (BufferAlloc 相当)。found が偽なら、呼び出し元が読み込みを始めて
+// いるため、ページを読み込んで terminateBufferIO を呼ぶ。
+func (b *Backend) bufferAlloc(p *bufferPool, tag BufferTag, strategy *BufferAccessStrategy) (buf *bufferDesc, found bool, err error) {
+	part := p.partition(bufTableHashCode(tag))
+	b.proc.LWLockAcquire(&part.lock, lmgr.LWShared)
+	if bufID, ok := part.lookup(tag); ok {
+		buf := p.desc(bufID)
+		valid := b.pinBuffer(buf, strategy)
+		b.proc.LWLockRelease(&part.lock)
+		return buf, b.waitValid(p, buf, valid), nil
+	}
+	b.proc.LWLockRelease(&part.lock)
+
+	victim, err := b.getVictimBuffer(p, strategy)
+	if err != nil {
+		return nil, false, err
+	}
+	b.proc.LWLockAcquire(&part.lock, lmgr.LWExclusive)
+	if existing, ok := part.insert(tag, victim.bufID); ok {
+		// 他のバックエンドが先に同じページのバッファを割り当てた。選んだバッファはすぐに
+		// 再利用できるよう空きリストに戻す
+		b.unpinBuffer(victim)
+		p.strategyFreeBuffer(victim)
+		buf := p.desc(existing)
+		valid := b.pinBuffer(buf, strategy)
+		b.proc.LWLockRelease(&part.lock)
+		return buf, b.waitValid(p, buf, valid), nil
+	}
+	state := victim.lockBufHdr()
+	victim.tag = tag
+	state |= bmTagValid | bmPermanent
+	state += bufUsagecountOne
+	victim.unlockBufHdr(state)
+	b.proc.LWLockRelease(&part.lock)
+	return victim, !p.startBufferIO(b.waitEvent, victim, true), nil
+}
+
+// waitValid は見つけたバッファのページが正しいかを返す。他のバックエンドが読み込み中なら
+// 終わるのを待ち、読み込みに失敗していれば、このバックエンドが読み込みを始めて偽を返す。
+func (b *Backend) waitValid(p *bufferPool, buf *bufferDesc, valid bool) bool {
+	if valid {
+		return true
+	}
+	return !p.startBufferIO(b.waitEvent, buf, true)
+}
+
+// getVictimBuffer は置き換えるバッファを選び、汚れていれば書き出し、マッピング表から外して
+// ピンを付けて返す (GetVictimBuffer 相当)
+func (b *Backend) getVictimBuffer(p *bufferPool, strategy *BufferAccessStrategy) (*bufferDesc, error) {
+	for {
+		buf, state, err := p.strategyGetBuffer(strategy)
+		if err != nil {
+			return nil, err
+		}
+		b.pinBufferLocked(buf, state)
+
+		if state&bmDirty != 0 {
+			// 他のバックエンドが内容を書き換えている最中なら、待たずに別のバッファを選ぶ
+			lock := &p.contentLocks[buf.bufID]
+			if !b.proc.LWLockConditionalAcquire(lock, lmgr.LWShared) {
+				b.unpinBuffer(buf)
+				continue
+			}
+			err := b.flushBuffer(p, buf)
+			b.proc.LWLockRelease(lock)
+			if err != nil {
+				b.unpinBuffer(buf)
+				return nil, err
+			}
+			// バックグラウンドライターが先に書き出せなかったバッファとして数える
+			pgstat.CountBackendWrite()
+		}
+		// 書き出す間に他のバックエンドがピンを付けたか汚したなら、別のバッファを選ぶ
+		if state&bmTagValid != 0 && !b.invalidateVictimBuffer(p, buf) {
+			b.unpinBuffer(buf)
+			continue
+		}
+		return buf, nil
+	}
+}
+
+// invalidateVictimBuffer は、このバックエンドだけがピンを付けていて汚れていなければ、バッファの
+// タグを外してマッピング表から消す (InvalidateVictimBuffer 相当)
+func (b *Backend) invalidateVictimBuffer(p *bufferPool, buf *bufferDesc) bool {
+	state := buf.lockBufHdr()
+	tag := buf.tag
+	buf.unlockBufHdr(state)
+
+	part := p.partition(bufTableHashCode(tag))
+	b.proc.LWLockAcquire(&part.lock, lmgr.LWExclusive)
+	state = buf.lockBufHdr()
+	if bufStateGetRefcount(state) != 1 || state&bmDirty != 0 {
+		buf.unlockBufHdr(state)
+		b.proc.LWLockRelease(&part.lock)
+		return false
+	}
+	clearBufferTag(&buf.tag)
+	state &^= bufFlagMask | bufUsagecountMask
+	buf.unlockBufHdr(state)
+	part.delete(tag)
+	b.proc.LWLockRelease(&part.lock)
+	return true
+}
+
+// pinBuffer はバッファにピンを付け、ページが正しいかを返す (PinBuffer 相当)。共有の数を
+// 増やすときは使用回数も1つ増やす。リングで使うバッファは、他で使われているバッファを
+// 追い出しやすくしないよう、使用回数が 0 のときだけ増やす。
+func (b *Backend) pinBuffer(buf *bufferDesc, strategy *BufferAccessStrategy) bool {
+	buffer := Buffer(buf.bufID + 1)
+	ref := b.refs[buffer]
+	b.refs[buffer] = ref + 1
+	if ref > 0 {
+		return buf.state.Load()&bmValid != 0
+	}
+	for {
+		old := buf.state.Load()
+		if old&bmLocked != 0 {
+			old = buf.waitBufHdrUnlocked()
+		}
+		state := old + bufRefcountOne
+		if strategy == nil {
+			if bufStateGetUsagecount(state) < bmMaxUsageCount {
+				state += bufUsagecountOne
+			}
+		} else if bufStateGetUsagecount(state) == 0 {
+			state += bufUsagecountOne
+		}
+		if buf.state.CompareAndSwap(old, state) {
+			return state&bmValid != 0
+		}
+	}
+}
+
+// pinBufferLocked はヘッダーのスピンロックを取ったバッファにピンを付け、スピンロックを放す
+// (PinBuffer_Locked 相当)。使用回数は変えない。
+func (b *Backend) pinBufferLocked(buf *bufferDesc, state uint32) {
+	buf.unlockBufHdr(state + bufRefcountOne)
+	b.refs[Buffer(buf.bufID+1)]++
+}
+
+// unpinBuffer はバッファのピンを1つ外す (UnpinBuffer 相当)
+func (b *Backend) unpinBuffer(buf *bufferDesc) {
+	buffer := Buffer(buf.bufID + 1)
+	ref := b.refs[buffer] - 1
+	if ref > 0 {
+		b.refs[buffer] = ref
+		return
+	}
+	delete(b.refs, buffer)
+	for {
+		old := buf.state.Load()
+		if old&bmLocked != 0 {
+			old = buf.waitBufHdrUnlocked()
+		}
+		if buf.state.CompareAndSwap(old, old-bufRefcountOne) {
+			return
+		}
+	}
+}
+
+// startBufferIO はバッファの読み込み (forInput) か書き出しを始める (StartBufferIO 相当)。
+// 他のバックエンドが入出力中なら終わるのを待つ。その間に読み込みか書き出しが済み、する必要が
+// なくなっていれば偽を返す。
+func (p *bufferPool) startBufferIO(waitEvent *waitevent.Slot, buf *bufferDesc, forInput bool) bool {
+	var state uint32
+	for {
+		state = buf.lockBufHdr()
+		if state&bmIOInProgress == 0 {
+			break
+		}
+		buf.unlockBufHdr(state)
+		p.waitIO(waitEvent, buf)
+	}
+	if forInput && state&bmValid != 0 || !forInput && state&bmDirty == 0 {
+		buf.unlockBufHdr(state)
+		return false
+	}
+	buf.unlockBufHdr(state | bmIOInProgress)
+	return true
+}
+
+// waitIO はバッファの入出力が終わるのを待つ (WaitIO 相当)
+func (p *bufferPool) waitIO(waitEvent *waitevent.Slot, buf *bufferDesc) {
+	cv := &p.ioCVs[buf.bufID]
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	if buf.state.Load()&bmIOInProgress == 0 {
+		return
+	}
+	waitEvent.Start(waitevent.IPCBufferIO)
+	for buf.state.Load()&bmIOInProgress != 0 {
+		cv.cond.Wait()
+	}
+	waitEvent.End()
+}
+
+// terminateBufferIO はバッファの入出力を終え、待っているバックエンドを起こす
+// (TerminateBufferIO 相当)。clearDirty が真なら、書き出しを始めた後に汚れていない限り汚れた
+// 印を外す。setFlagBits は入出力の結果のフラグ。
+func (p *bufferPool) terminateBufferIO(buf *bufferDesc, clearDirty bool, setFlagBits uint32) {
+	state := buf.lockBufHdr()
+	state &^= bmIOInProgress | bmIOError
+	if clearDirty && state&bmJustDirtied == 0 {
+		state &^= bmDirty | bmCheckpointNeeded
+	}
+	buf.unlockBufHdr(state | setFlagBits)
+
+	cv := &p.ioCVs[buf.bufID]
+	cv.mu.Lock()
+	cv.cond.Broadcast()
+	cv.mu.Unlock()
+}
+
+// flushBuffer は汚れたバッファのページを書き出す (FlushBuffer 相当)。バッファにピンを付け、
+// 内容のロックを取ってから呼ぶ。他のバックエンドが先に書き出していれば何もしない。
+func (b *Backend) flushBuffer(p *bufferPool, buf *bufferDesc) error {
+	if !p.startBufferIO(b.waitEvent, buf, false) {
+		return nil
+	}
+	// 書き出している間に汚れたら、書き出した後も汚れた印を残す
+	state := buf.lockBufHdr()
+	tag := buf.tag
+	buf.unlockBufHdr(state &^ bmJustDirtied)
+
+	// チェックサムはヒントビットの更新と競合しないよう複製に付ける
+	page := pagepkg.SetChecksumCopy(p.page(buf.bufID), uint32(tag.BlockNum))
+	if err := b.smgrWrite(tag, page); err != nil {
+		p.terminateBufferIO(buf, false, bmIOError)
+		return err
+	}
+	p.terminateBufferIO(buf, true, 0)
+	return nil
+}
+
+// smgrRead はブロックを page に読み込む。track_io_timing が on なら読み込みにかけた時間を数える。
+func (b *Backend) smgrRead(tag BufferTag, page []byte) error {
+	s, err := getSmgr()
+	if err != nil {
+		return err
+	}
+	if !b.stats.TrackIOTiming() {
+		return s.Read(tag.RLocator, tag.ForkNum, tag.BlockNum, page)
+	}
+	start := time.Now()
+	err = s.Read(tag.RLocator, tag.ForkNum, tag.BlockNum, page)
+	b.stats.CountBufferReadTime(time.Since(start))
+	return err
+}
+
+// smgrWrite はブロックを page の内容で書き出す。track_io_timing が on なら書き出しにかけた時間を
+// 数える。
+func (b *Backend) smgrWrite(tag BufferTag, page []byte) error {
+	s, err := getSmgr()
+	if err != nil {
+		return err
+	}
+	if !b.stats.TrackIOTiming() {
+		return s.Write(tag.RLocator, tag.ForkNum, tag.BlockNum, page)
+	}
+	start := time.Now()
+	err = s.Write(tag.RLocator, tag.ForkNum, tag.BlockNum, page)
+	b.stats.CountBufferWriteTime(time.Since(start))
+	return err
+}
+
+// pinnedDesc はこのバックエンドがピンを付けているバッファの記述子を返す。ピンを付けていない
+// バッファを渡すのは呼び出し元の誤りのため panic する。
+func (b *Backend) pinnedDesc(buffer Buffer) (*bufferPool, *bufferDesc) {
+	p := sharedPool.Load()
+	if p == nil || buffer <= InvalidBuffer || int(buffer) > p.nBuffers || b.refs[buffer] == 0 {
+		panic(fmt.Sprintf("bad buffer ID: %d", buffer))
+	}
+	return p, p.desc(int32(buffer) - 1)
+}
+
+// ReleaseBuffer はバッファのピンを1つ外す (ReleaseBuffer 相当)
+func (b *Backend) ReleaseBuffer(buffer Buffer) {
+	_, buf := b.pinnedDesc(buffer)
+	b.unpinBuffer(buf)
+}
+
+// UnlockReleaseBuffer は内容のロックを放してピンを外す (UnlockReleaseBuffer 相当)
+func (b *Backend) UnlockReleaseBuffer(buffer Buffer) {
+	b.LockBuffer(buffer, BufferLockUnlock)
+	b.ReleaseBuffer(buffer)
+}
+
+// IncrBufferRefCount は既にピンを付けているバッファにもう1つピンを付ける (IncrBufferRefCount 相当)
+func (b *Backend) IncrBufferRefCount(buffer Buffer) {
+	b.pinnedDesc(buffer)
+	b.refs[buffer]++
+}
+
+// MarkBufferDirty はバッファに汚れた印を付ける (MarkBufferDirty 相当)。ピンを付け、内容のロックを
+// 排他のモードで取ってから呼ぶ。
+func (b *Backend) MarkBufferDirty(buffer Buffer) {
+	p, buf := b.pinnedDesc(buffer)
+	if !b.proc.LWLockHeldByMeInMode(&p.contentLocks[buf.bufID], lmgr.LWExclusive) {
+		panic(fmt.Sprintf("content lock of buffer %d is not held exclusively", buffer))
+	}
+	for {
+		old := buf.state.Load()
+		if old&bmLocked != 0 {
+			old = buf.waitBufHdrUnlocked()
+		}
+		if buf.state.CompareAndSwap(old, old|bmDirty|bmJustDirtied) {
+			return
+		}
+	}
+}
+
+// MarkBufferDirtyHint はヒントビットのように、失っても正しさに関わらない変更をしたバッファに
+// 汚れた印を付ける (MarkBufferDirtyHint 相当)。ピンを付け、内容のロックを共有か排他のモードで
+// 取ってから呼ぶ。WAL とチェックサムの検証はまだないため、ページ全体の書き出しは記録しない。
+func (b *Backend) MarkBufferDirtyHint(buffer Buffer) {
+	p, buf := b.pinnedDesc(buffer)
+	if !b.proc.LWLockHeldByMe(&p.contentLocks[buf.bufID]) {
+		panic(fmt.Sprintf("content lock of buffer %d is not held", buffer))
+	}
+	// 既に印があれば、ヘッダのロックを取らずに済ませる
+	if buf.state.Load()&(bmDirty|bmJustDirtied) == bmDirty|bmJustDirtied {
+		return
+	}
+	for {
+		old := buf.state.Load()
+		if old&bmLocked != 0 {
+			old = buf.waitBufHdrUnlocked()
+		}
+		if buf.state.CompareAndSwap(old, old|bmDirty|bmJustDirtied) {
+			return
+		}
+	}
+}
+
+// LockBuffer はバッファの内容のロックを mode で取るか、BufferLockUnlock なら放す (LockBuffer 相当)
+func (b *Backend) LockBuffer(buffer Buffer, mode BufferLockMode) {
+	p, buf := b.pinnedDesc(buffer)
+	lock := &p.contentLocks[buf.bufID]
+	switch mode {
+	case BufferLockUnlock:
+		b.proc.LWLockRelease(lock)
+	case BufferLockShare:
+		b.proc.LWLockAcquire(lock, lmgr.LWShared)
+	case BufferLockExclusive:
+		b.proc.LWLockAcquire(lock, lmgr.LWExclusive)
+	default:
+		panic(fmt.Sprintf("unrecognized buffer lock mode: %d", mode))
+	}
+}
+
+// ConditionalLockBuffer は待たずに内容のロックを排他のモードで取れれば取り、取れたかを返す
+// (ConditionalLockBuffer 相当)
+func (b *Backend) ConditionalLockBuffer(buffer Buffer) bool {
+	p, buf := b.pinnedDesc(buffer)
+	return b.proc.LWLockConditionalAcquire(&p.contentLocks[buf.bufID], lmgr.LWExclusive)
+}
+
+// ReleaseAll はこのバックエンドが付けているピンを全て外す (中止したトランザクションの
+// ResourceOwnerRelease のバッファの処理と AtProcExit_Buffers 相当)。内容のロックは先に
+// LWLockReleaseAll で放しておく。
+func (b *Backend) ReleaseAll() {
+	if b == nil || len(b.refs) == 0 {
+		return
+	}
+	p := sharedPool.Load()
+	for buffer := range b.refs {
+		if p != nil && int(buffer) <= p.nBuffers {
+			b.refs[buffer] = 1
+			b.unpinBuffer(p.desc(int32(buffer) - 1))
+		}
+	}
+	clear(b.refs)
+}
+
+// BufferGetPage はピンを付けているバッファのページを返す (BufferGetPage 相当)。ページは
+// 共有メモリの中にあり、内容のロックを取っている間だけ読み書きできる。
+func (b *Backend) BufferGetPage(buffer Buffer) []byte {
+	p, buf := b.pinnedDesc(buffer)
+	return p.page(buf.bufID)
+}
+
+// BufferGetTag はピンを付けているバッファが持つページを返す (BufferGetTag 相当)
+func (b *Backend) BufferGetTag(buffer Buffer) BufferTag {
+	_, buf := b.pinnedDesc(buffer)
+	return buf.tag
+}
+
+// BufferGetBlockNumber はピンを付けているバッファのブロック番号を返す (BufferGetBlockNumber 相当)
+func (b *Backend) BufferGetBlockNumber(buffer Buffer) storage.BlockNumber {
+	return b.BufferGetTag(buffer).BlockNum
+}
+
+// ----------------------------------------------------------------
+// リレーション単位の操作
+// ----------------------------------------------------------------
+
+// FlushRelationBuffers はリレーションの汚れたページを全て書き出す (FlushRelationBuffers 相当)。
+// WAL に書かずに作ったリレーションのファイルを同期する前に呼ぶ。このバックエンドはリレーションの
+// バッファにピンを付けていてはいけない。
+func (b *Backend) FlushRelationBuffers(rlocator storage.RelFileLocator) error {
+	p := sharedPool.Load()
+	if p == nil {
+		return nil
+	}
+	for i := 0; i < p.nBuffers; i++ {
+		buf := p.desc(int32(i))
+		state := buf.lockBufHdr()
+		if state&(bmValid|bmDirty) != bmValid|bmDirty || buf.tag.RLocator != rlocator {
+			buf.unlockBufHdr(state)
+			continue
+		}
+		b.pinBufferLocked(buf, state)
+		lock := &p.contentLocks[buf.bufID]
+		b.proc.LWLockAcquire(lock, lmgr.LWShared)
+		err := b.flushBuffer(p, buf)
+		b.proc.LWLockRelease(lock)
+		b.unpinBuffer(buf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropRelationBuffers はリレーションの全てのフォークのページを、汚れていても書き出さずに共有
+// バッファから捨てる (DropRelationsAllBuffers と InvalidateBuffer 相当)。リレーションのファイルを
+// 消す前に呼ぶ。このバックエンドはリレーションのバッファにピンを付けていてはいけない。他の
+// バックエンドが付けているピンは、外されるまで待つ。
+func (b *Backend) DropRelationBuffers(rlocator storage.RelFileLocator) {
+	p := sharedPool.Load()
+	if p == nil {
+		return
+	}
+	for i := 0; i < p.nBuffers; i++ {
+		b.invalidateBuffer(p, p.desc(int32(i)), rlocator)
+	}
+}
+
+// invalidateBuffer はバッファが rlocator のページを持っていれば、タグを外してマッピング表から
+// 消し、空きリストに戻す (InvalidateBuffer 相当)
+func (b *Backend) invalidateBuffer(p *bufferPool, buf *bufferDesc, rlocator storage.RelFileLocator) {
+	for {
+		state := buf.lockBufHdr()
+		tag := buf.tag
+		buf.unlockBufHdr(state)
+		if state&bmTagValid == 0 || tag.RLocator != rlocator {
+			return
+		}
+
+		part := p.partition(bufTableHashCode(tag))
+		b.proc.LWLockAcquire(&part.lock, lmgr.LWExclusive)
+		state = buf.lockBufHdr()
+		if buf.tag != tag {
+			// マッピング表のロックを取る間に、他のページに置き換えられた
+			buf.unlockBufHdr(state)
+			b.proc.LWLockRelease(&part.lock)
+			return
+		}
+		if bufStateGetRefcount(state) != 0 {
+			buf.unlockBufHdr(state)
+			b.proc.LWLockRelease(&part.lock)
+			runtime.Gosched()
+			continue
+		}
+		clearBufferTag(&buf.tag)
+		state &^= bufFlagMask | bufUsagecountMask
+		buf.unlockBufHdr(state)
+		part.delete(tag)
+		b.proc.LWLockRelease(&part.lock)
+		p.strategyFreeBuffer(buf)
+		return
+	}
+}
+
+// ----------------------------------------------------------------
+// バックグラウンドライターの操作
+// ----------------------------------------------------------------
+
+// Pool は共有バッファをバックグラウンドライターに渡す。bgwriter.BufferPool を実装する。
+// 書き出しのためのピンと内容のロックは Pool の Backend で扱うため、1つのゴルーチンだけが使う。
+type Pool struct {
+	backend *Backend
+	logProc *errutil.ProcInfo
+}
+
+// NewPool はバックグラウンドライターが使う Pool を作る。logProc は書き出しのエラーを出力する
+// プロセス。
+func NewPool(logProc *errutil.ProcInfo) *Pool {
+	return &Pool{backend: newAuxBackend(logProc), logProc: logProc}
+}
+
+// newAuxBackend はバックグラウンドライターや checkpointer がバッファにピンを付けるための
+// Backend を作る。補助プロセスの書き出しはデータベースの統計に数えないため、stats は持たない。
+func newAuxBackend(logProc *errutil.ProcInfo) *Backend {
+	proc := lmgr.NewProc(logProc.Pid, nil, nil, nil, logProc)
+	return NewBackend(proc, nil, nil)
+}
+
+// NBuffers は共有バッファの数。共有メモリを作っていなければ 0 (NBuffers 相当)
+func (*Pool) NBuffers() int {
+	if p := sharedPool.Load(); p != nil {
+		return p.nBuffers
+	}
+	return 0
+}
+
+// StrategySyncStart はクロックスイープの位置と一周した回数と割り当ての数を返す
+// (StrategySyncStart 相当)
+func (*Pool) StrategySyncStart() (int, uint32, uint32) {
+	if p := sharedPool.Load(); p != nil {
+		return p.strategySyncStart()
+	}
+	return 0, 0, 0
+}
+
+// StrategyNotifyBgWriter は次にバッファを割り当てたときに wake を呼ぶよう登録する
+// (StrategyNotifyBgWriter 相当)
+func (*Pool) StrategyNotifyBgWriter(wake func()) {
+	if p := sharedPool.Load(); p != nil {
+		p.strategyNotifyBgWriter(wake)
+	}
+}
+
+// SyncOneBuffer は、bufID のバッファを使っておらず最近も使っていなければ再利用できるとし、
+// 汚れていれば書き出す (skip_recently_used を真にした SyncOneBuffer 相当)。書き出しに失敗すれば
+// エラーを出力して続ける。
+func (pl *Pool) SyncOneBuffer(bufID int) (reusable, written bool) {
+	p := sharedPool.Load()
+	if p == nil || bufID >= p.nBuffers {
+		return false, false
+	}
+	b := pl.backend
+	buf := p.desc(int32(bufID))
+	state := buf.lockBufHdr()
+	if bufStateGetRefcount(state) != 0 || bufStateGetUsagecount(state) != 0 {
+		buf.unlockBufHdr(state)
+		return false, false
+	}
+	if state&bmValid == 0 || state&bmDirty == 0 {
+		buf.unlockBufHdr(state)
+		return true, false
+	}
+	b.pinBufferLocked(buf, state)
+	lock := &p.contentLocks[buf.bufID]
+	b.proc.LWLockAcquire(lock, lmgr.LWShared)
+	err := b.flushBuffer(p, buf)
+	b.proc.LWLockRelease(lock)
+	b.unpinBuffer(buf)
+	if err != nil {
+		var edata *errutil.ErrorData
+		if !errors.As(err, &edata) {
+			edata = errutil.Elog(errutil.Error, "%s", err.Error())
+		}
+		errutil.Report(pl.logProc, edata)
+		return true, false
+	}
+	return true, true
+}
+
+// ----------------------------------------------------------------
+// チェックポイントの操作
+// ----------------------------------------------------------------
+
+// BufferSync は汚れた全てのバッファを書き出し、書き出した数を返す (BufferSync 相当)。始めた
+// ときに汚れていたバッファに bmCheckpointNeeded の印を付け、印が残っているものを順に書き出す。
+// 始めた後に汚れたバッファは次のチェックポイントに任せる。他のバックエンドが先に書き出した
+// バッファは印が消えているため飛ばす。
+//
+// logProc はピンを付けるプロセス。progress が nil でなければ、1つ書き出すたびに終えた割合
+// (0 から 1) を渡して呼ぶ。
+func BufferSync(logProc *errutil.ProcInfo, progress func(float64)) (int, error) {
+	p := sharedPool.Load()
+	if p == nil {
+		return 0, nil
+	}
+	var toWrite []int32
+	for i := 0; i < p.nBuffers; i++ {
+		buf := p.desc(int32(i))
+		state := buf.lockBufHdr()
+		if state&bmDirty != 0 {
+			state |= bmCheckpointNeeded
+			toWrite = append(toWrite, buf.bufID)
+		}
+		buf.unlockBufHdr(state)
+	}
+	if len(toWrite) == 0 {
+		return 0, nil
+	}
+
+	b := newAuxBackend(logProc)
+	written := 0
+	for i, bufID := range toWrite {
+		buf := p.desc(bufID)
+		state := buf.lockBufHdr()
+		if state&bmCheckpointNeeded == 0 {
+			buf.unlockBufHdr(state)
+			continue
+		}
+		b.pinBufferLocked(buf, state)
+		lock := &p.contentLocks[buf.bufID]
+		b.proc.LWLockAcquire(lock, lmgr.LWShared)
+		err := b.flushBuffer(p, buf)
+		b.proc.LWLockRelease(lock)
+		b.unpinBuffer(buf)
+		if err != nil {
+			return written, err
+		}
+		written++
+		if progress != nil {
+			progress(float64(i+1) / float64(len(toWrite)))
+		}
+	}
+	return written, nil
+}
+
+// DirtyBufferExists は汚れたバッファがあるかを返す。チェックポイントで書き出すものがあるか
+// どうかを確かめるために使う。
+func DirtyBufferExists() bool {
+	p := sharedPool.Load()
+	if p == nil {
+		return false
+	}
+	for i := 0; i < p.nBuffers; i++ {
+		if p.desc(int32(i)).state.Load()&bmDirty != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package buffer
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/lmgr"
+	pagepkg "github.com/Tsubasa-2005/go-postgres/internal/storage/page"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/pgstat"
+)
+
+// memSmgr はページをメモリに持つ Smgr。読み書きした回数を数える。delay を設定すれば、読み書きの
+// たびにその間だけ待つ。
+type memSmgr struct {
+	mu     sync.Mutex
+	pages  map[BufferTag][]byte
+	reads  int
+	writes int
+	delay  time.Duration
+}
+
+func (m *memSmgr) Read(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber, buf []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	time.Sleep(m.delay)
+	m.reads++
+	if p, ok := m.pages[BufferTag{RLocator: rlocator, ForkNum: forkNum, BlockNum: blockNum}]; ok {
+		copy(buf, p)
+	} else {
+		clear(buf)
+	}
+	return nil
+}
+
+func (m *memSmgr) Prefetch(storage.RelFileLocator, storage.ForkNumber, storage.BlockNumber) error {
+	return nil
+}
+
+func (m *memSmgr) Write(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber, buf []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	time.Sleep(m.delay)
+	m.writes++
+	m.pages[BufferTag{RLocator: rlocator, ForkNum: forkNum, BlockNum: blockNum}] = bytes.Clone(buf)
+	return nil
+}
+
+var testRel = storage.RelFileLocator{DbOid: 5, RelNumber: 16384}
+
+// setupPool は nBuffers 個のバッファの共有バッファとメモリ上の Smgr を用意する。
+func setupPool(t *testing.T, nBuffers int) (*Backend, *memSmgr) {
+	t.Helper()
+	m := &memSmgr{pages: make(map[BufferTag][]byte)}
+	p := newBufferPool(make([]bufferDescPadded, nBuffers), make([]byte, nBuffers*pgconfig.BlckSz), &strategyControl{})
+	prevPool, prevSmgr := sharedPool.Swap(p), smgr.Load()
+	SetSmgr(m)
+	t.Cleanup(func() {
+		sharedPool.Store(prevPool)
+		smgr.Store(prevSmgr)
+		pagepkg.SetDataChecksumState(pagepkg.DataChecksumsOff)
+	})
+	return NewBackend(lmgr.NewProc(1, nil, nil, nil, nil), nil, nil), m
+}
+
+func readBlock(t *testing.T, b *Backend, blkno storage.BlockNumber) Buffer {
+	t.Helper()
+	buffer, err := b.ReadBuffer(testRel, blkno)
+	if err != nil {
+		t.Fatalf("ReadBuffer(%d) = %v", blkno, err)
+	}
+	return buffer
+}
+
+// writeBlock はブロックを読み、内容を初期化して data を置き、汚れた印を付けて放す。
+func writeBlock(t *testing.T, b *Backend, blkno storage.BlockNumber, data string) {
+	t.Helper()
+	buffer := readBlock(t, b, blkno)
+	b.LockBuffer(buffer, BufferLockExclusive)
+	page := b.BufferGetPage(buffer)
+	pagepkg.Init(page, 0)
+	if _, err := pagepkg.AddItem(page, []byte(data), storage.InvalidOffsetNumber, 0); err != nil {
+		t.Fatalf("AddItem = %v", err)
+	}
+	b.MarkBufferDirty(buffer)
+	b.UnlockReleaseBuffer(buffer)
+}
+
+func TestClockSweepSkipsPinnedBuffers(t *testing.T) {
+	b, _ := setupPool(t, 4)
+	var pinned []Buffer
+	for blkno := range storage.BlockNumber(3) {
+		pinned = append(pinned, readBlock(t, b, blkno))
+	}
+	b.ReleaseBuffer(readBlock(t, b, 3))
+
+	// ピンのないバッファはブロック 3 のものだけのため、それが置き換えられる
+	victim := readBlock(t, b, 4)
+	for i, buffer := range pinned {
+		if got := b.BufferGetBlockNumber(buffer); got != storage.BlockNumber(i) {
+			t.Errorf("pinned buffer %d holds block %d, want %d", buffer, got, i)
+		}
+	}
+	if got := b.BufferGetBlockNumber(victim); got != 4 {
+		t.Errorf("victim holds block %d, want 4", got)
+	}
+
+	// 全てのバッファにピンが付いていれば置き換えられない
+	if _, err := b.ReadBuffer(testRel, 5); err == nil || !strings.Contains(err.Error(), "no unpinned buffers available") {
+		t.Errorf("ReadBuffer with all buffers pinned = %v, want no unpinned buffers available", err)
+	}
+	b.ReleaseAll()
+	b.ReleaseBuffer(readBlock(t, b, 5))
+}
+
+func TestDirtyBufferIsWrittenBackOnEviction(t *testing.T) {
+	b, m := setupPool(t, 1)
+	writeBlock(t, b, 0, "hello")
+	if m.writes != 0 {
+		t.Fatalf("writes before eviction = %d, want 0", m.writes)
+	}
+
+	// バッファが 1 つしかないため、次のブロックを読むとブロック 0 が書き出される
+	b.ReleaseBuffer(readBlock(t, b, 1))
+	if m.writes != 1 {
+		t.Fatalf("writes after eviction = %d, want 1", m.writes)
+	}
+	buffer := readBlock(t, b, 0)
+	defer b.ReleaseBuffer(buffer)
+	page := b.BufferGetPage(buffer)
+	if got := pagepkg.GetItem(page, pagepkg.GetItemID(page, 1)); string(got) != "hello" {
+		t.Errorf("item after re-read = %q, want %q", got, "hello")
+	}
+}
+
+func TestChecksumIsSetOnWriteAndVerifiedOnRead(t *testing.T) {
+	b, m := setupPool(t, 1)
+	pagepkg.SetDataChecksumState(pagepkg.DataChecksumsOn)
+	writeBlock(t, b, 7, "checksummed")
+	b.ReleaseBuffer(readBlock(t, b, 0))
+
+	tag := BufferTag{RLocator: testRel, ForkNum: storage.MainForkNum, BlockNum: 7}
+	if !pagepkg.VerifyChecksum(m.pages[tag], 7) {
+		t.Fatal("written page has no valid checksum")
+	}
+	b.ReleaseBuffer(readBlock(t, b, 7))
+
+	// 壊したページは読み込めない
+	b.ReleaseBuffer(readBlock(t, b, 0))
+	m.pages[tag][pgconfig.BlckSz-1] ^= 0xFF
+	_, err := b.ReadBuffer(testRel, 7)
+	if err == nil || !strings.Contains(err.Error(), "invalid page in block 7 of relation base/5/16384") {
+		t.Fatalf("ReadBuffer of corrupted page = %v, want invalid page error", err)
+	}
+
+	// チェックサムを検証しない状態ならヘッダだけを確かめる
+	pagepkg.SetDataChecksumState(pagepkg.DataChecksumsInProgressOn)
+	b.ReleaseBuffer(readBlock(t, b, 7))
+}
+
+func TestDropRelationBuffersDiscardsDirtyPages(t *testing.T) {
+	b, m := setupPool(t, 2)
+	writeBlock(t, b, 0, "dropped")
+	b.DropRelationBuffers(testRel)
+	if DirtyBufferExists() {
+		t.Fatal("dirty buffer remains after DropRelationBuffers")
+	}
+	// 捨てたページは書き出さず、読み直すと Smgr の内容になる
+	buffer := readBlock(t, b, 0)
+	defer b.ReleaseBuffer(buffer)
+	if m.writes != 0 || m.reads != 2 {
+		t.Errorf("writes, reads = %d, %d; want 0, 2", m.writes, m.reads)
+	}
+	if !pagepkg.IsNew(b.BufferGetPage(buffer)) {
+		t.Error("dropped page is still in the buffer")
+	}
+}
+
+func TestTrackIOTimingCountsReadAndWriteTime(t *testing.T) {
+	const dboid = catalog.Oid(90001)
+	_, m := setupPool(t, 1)
+	m.delay = time.Millisecond
+	trackIOTiming := false
+	stats := pgstat.NewPending(nil, nil, func() bool { return trackIOTiming })
+	stats.SetDatabase(dboid)
+	b := NewBackend(lmgr.NewProc(1, nil, nil, nil, nil), nil, stats)
+
+	// track_io_timing が off の間は時間を数えない
+	writeBlock(t, b, 0, "untimed")
+	b.ReleaseBuffer(readBlock(t, b, 1))
+	stats.ReportStat()
+	if st := pgstat.FetchStatDBEntry(dboid); st.BlkReadTime != 0 || st.BlkWriteTime != 0 {
+		t.Fatalf("BlkReadTime, BlkWriteTime with track_io_timing off = %v, %v; want 0, 0", st.BlkReadTime, st.BlkWriteTime)
+	}
+
+	// 共有バッファにあるブロック 1 を汚してからブロック 0 を読むと、ブロック 1 の書き出しと
+	// ブロック 0 の読み込みを数える
+	trackIOTiming = true
+	writeBlock(t, b, 1, "timed")
+	b.ReleaseBuffer(readBlock(t, b, 0))
+	stats.ReportStat()
+	st := pgstat.FetchStatDBEntry(dboid)
+	if st.BlkReadTime < m.delay || st.BlkWriteTime < m.delay {
+		t.Errorf("BlkReadTime, BlkWriteTime = %v, %v; want at least %v", st.BlkReadTime, st.BlkWriteTime, m.delay)
+	}
+}
@@ -0,0 +1,279 @@
+package buffer
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+)
+
+// ----------------------------------------------------------------
+// バッファの置き換え (storage/buffer/freelist.c 相当)
+// ----------------------------------------------------------------
+// 新しいページを読むバッファは、まず空きリストから取り、空きリストが空ならクロックスイープで
+// 選ぶ。クロックスイープは共有バッファを順に回る針 (nextVictimBuffer) を進め、ピンのない
+// バッファの使用回数を1つずつ減らし、使用回数が 0 のバッファを再利用する。よく使われるページほど
+// 使用回数が大きく、針が何度も通り過ぎるまで残る。
+//
+// 空きリストと針が一周した回数は strategyControl のスピンロックで守り、針そのものは不可分に
+// 進める。バックグラウンドライターは StrategySyncStart で針の位置と割り当ての数を読み、針の
+// 先のバッファを書き出しておく。
+//
+// 大きなテーブルの順次走査、大量の書き込み、VACUUM は、一度しか触らないページで共有バッファの
+// 全体を置き換えないよう、BufferAccessStrategy の小さなリングの中でバッファを使い回す。リングの
+// 次のバッファが他で使われていなければそれを再利用し、使われていれば通常どおりに選んだバッファを
+// リングに加える。C言語版の BAS_BULKREAD は、書き出す前に WAL の書き出しが要る汚れたバッファを
+// リングから外すが、WAL がまだないため外さない。
+
+// strategyControl は置き換えの状態 (BufferStrategyControl 相当)。共有メモリに置く。
+type strategyControl struct {
+	// lock は firstFreeBuffer, lastFreeBuffer, completePasses を守る (buffer_strategy_lock 相当)
+	lock uint32
+	// nextVictimBuffer はクロックスイープが次に調べるバッファ。nBuffers で割った余りを使う
+	nextVictimBuffer atomic.Uint32
+	firstFreeBuffer  int32
+	lastFreeBuffer   int32
+	// completePasses は針が共有バッファを一周した回数
+	completePasses uint32
+	// numBufferAllocs は前回の StrategySyncStart からバッファを割り当てた数
+	numBufferAllocs atomic.Uint32
+}
+
+// init は全てのバッファを空きリストに入れた状態にする (StrategyInitialize 相当)
+func (sc *strategyControl) init(nBuffers int) {
+	sc.lock = 0
+	sc.firstFreeBuffer = 0
+	sc.lastFreeBuffer = int32(nBuffers) - 1
+	sc.nextVictimBuffer.Store(0)
+	sc.completePasses = 0
+	sc.numBufferAllocs.Store(0)
+}
+
+func (sc *strategyControl) spinLockAcquire() {
+	for !atomic.CompareAndSwapUint32(&sc.lock, 0, 1) {
+		runtime.Gosched()
+	}
+}
+
+func (sc *strategyControl) spinLockRelease() {
+	atomic.StoreUint32(&sc.lock, 0)
+}
+
+// clockSweepTick は針を1つ進め、進める前に指していたバッファを返す (ClockSweepTick 相当)。
+// 針が nBuffers を超えたら、余りに戻したバックエンドが一周した回数を数える。
+func (p *bufferPool) clockSweepTick() int32 {
+	sc := p.strategy
+	n := uint32(p.nBuffers)
+	victim := sc.nextVictimBuffer.Add(1) - 1
+	if victim >= n {
+		originalVictim := victim
+		victim %= n
+		if victim == 0 {
+			expected := originalVictim + 1
+			for {
+				sc.spinLockAcquire()
+				wrapped := expected % n
+				ok := sc.nextVictimBuffer.CompareAndSwap(expected, wrapped)
+				if ok {
+					sc.completePasses++
+				} else {
+					expected = sc.nextVictimBuffer.Load()
+				}
+				sc.spinLockRelease()
+				if ok {
+					break
+				}
+			}
+		}
+	}
+	return int32(victim)
+}
+
+// strategyGetBuffer は新しいページを読むバッファを選ぶ (StrategyGetBuffer 相当)。選んだ
+// バッファはピンがなく、ヘッダーのスピンロックを取ったまま状態と共に返す。strategy があれば
+// まずリングから選び、リングから選べなければ選んだバッファをリングに加える。
+func (p *bufferPool) strategyGetBuffer(strategy *BufferAccessStrategy) (*bufferDesc, uint32, error) {
+	if strategy != nil {
+		if buf, state := p.getBufferFromRing(strategy); buf != nil {
+			return buf, state, nil
+		}
+	}
+
+	// バックグラウンドライターが休止していれば起こす
+	if p.bgwNotify.Load() != nil {
+		if wake := p.bgwNotify.Swap(nil); wake != nil {
+			(*wake)()
+		}
+	}
+	sc := p.strategy
+	sc.numBufferAllocs.Add(1)
+
+	// 空きリストのバッファは、取り出した後に他のバックエンドがピンを付けているかもしれない
+	for atomic.LoadInt32(&sc.firstFreeBuffer) >= 0 {
+		sc.spinLockAcquire()
+		if sc.firstFreeBuffer < 0 {
+			sc.spinLockRelease()
+			break
+		}
+		buf := p.desc(sc.firstFreeBuffer)
+		atomic.StoreInt32(&sc.firstFreeBuffer, buf.freeNext)
+		buf.freeNext = freeNextNotInList
+		sc.spinLockRelease()
+
+		state := buf.lockBufHdr()
+		if bufStateGetRefcount(state) == 0 && bufStateGetUsagecount(state) == 0 {
+			strategy.addBufferToRing(buf)
+			return buf, state, nil
+		}
+		buf.unlockBufHdr(state)
+	}
+
+	// クロックスイープで選ぶ。一周してもピンのないバッファがなければ諦める
+	trycounter := p.nBuffers
+	for {
+		buf := p.desc(p.clockSweepTick())
+		state := buf.lockBufHdr()
+		if bufStateGetRefcount(state) == 0 {
+			if bufStateGetUsagecount(state) != 0 {
+				state -= bufUsagecountOne
+				trycounter = p.nBuffers
+			} else {
+				strategy.addBufferToRing(buf)
+				return buf, state, nil
+			}
+		} else if trycounter--; trycounter == 0 {
+			buf.unlockBufHdr(state)
+			return nil, 0, errutil.Elog(errutil.Error, "no unpinned buffers available")
+		}
+		buf.unlockBufHdr(state)
+	}
+}
+
+// strategyFreeBuffer は使わなくなったバッファを空きリストの先頭に戻す (StrategyFreeBuffer 相当)
+func (p *bufferPool) strategyFreeBuffer(buf *bufferDesc) {
+	sc := p.strategy
+	sc.spinLockAcquire()
+	if buf.freeNext == freeNextNotInList {
+		buf.freeNext = sc.firstFreeBuffer
+		if buf.freeNext < 0 {
+			sc.lastFreeBuffer = buf.bufID
+		}
+		atomic.StoreInt32(&sc.firstFreeBuffer, buf.bufID)
+	}
+	sc.spinLockRelease()
+}
+
+// strategySyncStart はクロックスイープの針の位置と一周した回数を返し、割り当ての数を 0 に
+// 戻して返す (StrategySyncStart 相当)
+func (p *bufferPool) strategySyncStart() (int, uint32, uint32) {
+	sc := p.strategy
+	n := uint32(p.nBuffers)
+	sc.spinLockAcquire()
+	nextVictim := sc.nextVictimBuffer.Load()
+	completePasses := sc.completePasses + nextVictim/n
+	numBufferAllocs := sc.numBufferAllocs.Swap(0)
+	sc.spinLockRelease()
+	return int(nextVictim % n), completePasses, numBufferAllocs
+}
+
+// strategyNotifyBgWriter は次にバッファを割り当てたときに wake を呼ぶよう登録する
+// (StrategyNotifyBgWriter 相当)
+func (p *bufferPool) strategyNotifyBgWriter(wake func()) {
+	if wake == nil {
+		p.bgwNotify.Store(nil)
+		return
+	}
+	p.bgwNotify.Store(&wake)
+}
+
+// BufferAccessStrategyType はバッファの使い方の種類 (BufferAccessStrategyType 相当)
+type BufferAccessStrategyType int
+
+const (
+	// BASNormal は通常の使い方で、リングを使わない
+	BASNormal BufferAccessStrategyType = iota
+	// BASBulkRead は大きなテーブルの順次走査
+	BASBulkRead
+	// BASBulkWrite は COPY などの大量の書き込み
+	BASBulkWrite
+	// BASVacuum は VACUUM
+	BASVacuum
+)
+
+// BufferAccessStrategy はバッファを使い回すリング (BufferAccessStrategyData 相当)。作った
+// バックエンドだけが使う。
+type BufferAccessStrategy struct {
+	btype BufferAccessStrategyType
+	// current は最後に使ったリングの位置
+	current int
+	// buffers はリングのバッファ。InvalidBuffer はまだ埋めていない位置
+	buffers []Buffer
+}
+
+// GetAccessStrategy は btype の大きさのリングを作る (GetAccessStrategy 相当)。BASNormal なら
+// nil を返す。
+func GetAccessStrategy(btype BufferAccessStrategyType) *BufferAccessStrategy {
+	var ringSizeKB int
+	switch btype {
+	case BASNormal:
+		return nil
+	case BASBulkRead:
+		ringSizeKB = 256
+	case BASBulkWrite:
+		ringSizeKB = 16 * 1024
+	case BASVacuum:
+		ringSizeKB = 256
+	default:
+		panic(fmt.Sprintf("unrecognized buffer access strategy: %d", btype))
+	}
+	return GetAccessStrategyWithSize(btype, ringSizeKB)
+}
+
+// GetAccessStrategyWithSize は ringSizeKB の大きさのリングを作る (GetAccessStrategyWithSize
+// 相当)。1つのバッファにも満たなければ nil を返す。共有バッファの 1/8 より大きくはしない。
+func GetAccessStrategyWithSize(btype BufferAccessStrategyType, ringSizeKB int) *BufferAccessStrategy {
+	ringBuffers := ringSizeKB / (pgconfig.BlckSz / 1024)
+	if ringBuffers == 0 {
+		return nil
+	}
+	ringBuffers = min(guc.SharedBuffers.Get()/8, ringBuffers)
+	return &BufferAccessStrategy{btype: btype, buffers: make([]Buffer, ringBuffers)}
+}
+
+// GetAccessStrategyBufferCount はリングのバッファの数を返す (GetAccessStrategyBufferCount 相当)
+func GetAccessStrategyBufferCount(strategy *BufferAccessStrategy) int {
+	if strategy == nil {
+		return 0
+	}
+	return len(strategy.buffers)
+}
+
+// getBufferFromRing はリングの次のバッファが再利用できれば、ヘッダーのスピンロックを取って
+// 状態と共に返す (GetBufferFromRing 相当)。使用回数が 1 までなら、リングの中でだけ使われていた
+// とみなす。
+func (p *bufferPool) getBufferFromRing(strategy *BufferAccessStrategy) (*bufferDesc, uint32) {
+	if strategy.current++; strategy.current >= len(strategy.buffers) {
+		strategy.current = 0
+	}
+	buffer := strategy.buffers[strategy.current]
+	if buffer == InvalidBuffer || int(buffer) > p.nBuffers {
+		return nil, 0
+	}
+	buf := p.desc(int32(buffer) - 1)
+	state := buf.lockBufHdr()
+	if bufStateGetRefcount(state) == 0 && bufStateGetUsagecount(state) <= 1 {
+		return buf, state
+	}
+	buf.unlockBufHdr(state)
+	return nil, 0
+}
+
+// addBufferToRing はリングの今の位置に buf を入れる (AddBufferToRing 相当)
+func (strategy *BufferAccessStrategy) addBufferToRing(buf *bufferDesc) {
+	if strategy != nil {
+		strategy.buffers[strategy.current] = Buffer(buf.bufID + 1)
+	}
+}
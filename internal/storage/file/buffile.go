@@ -0,0 +1,248 @@
+package file
+
+import (
+	"io"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// バッファ付きの一時ファイル (storage/file/buffile.c 相当)
+// ----------------------------------------------------------------
+// 一時ファイルを BLCKSZ のバッファを通して読み書きする。論理的には1つの大きなファイルとして
+// 扱い、実際には maxPhysicalFileSize ごとの一時ファイルに分けて置く。書いた分はバッファが
+// いっぱいになるか、読み書きする位置を動かしたときにファイルに書き出す。
+//
+// 一時ファイルの大きさは TempFile が temp_file_limit と照らし合わせる。制限を超えた書き込みは
+// ConfigurationLimitExceeded のエラーになる。
+
+// maxPhysicalFileSize は1つの一時ファイルの大きさの上限 (MAX_PHYSICAL_FILESIZE 相当)
+const maxPhysicalFileSize = 1 << 30
+
+// BufFile はバッファ付きの一時ファイル (BufFile 相当)
+type BufFile struct {
+	temp      *TempFiles
+	interXact bool
+	waitEvent *waitevent.Slot
+	files     []*TempFile
+	// curFile と curOffset はバッファの先頭の位置
+	curFile   int
+	curOffset int64
+	// pos はバッファ内の読み書きする位置、nbytes はバッファ内の有効なバイト数
+	pos    int
+	nbytes int
+	dirty  bool
+	buffer [pgconfig.BlckSz]byte
+}
+
+// CreateTemp はバッファ付きの一時ファイルを作る (BufFileCreateTemp 相当)。interXact が false の
+// ファイルはトランザクションの終わりに削除する。waitEvent にはファイルの入出力を待つ間を記録する。
+func CreateTemp(t *TempFiles, interXact bool, waitEvent *waitevent.Slot) (*BufFile, error) {
+	f, err := t.OpenTemporaryFile(interXact)
+	if err != nil {
+		return nil, err
+	}
+	return &BufFile{temp: t, interXact: interXact, waitEvent: waitEvent, files: []*TempFile{f}}, nil
+}
+
+// Close はファイルを閉じて削除する (BufFileClose 相当)
+func (b *BufFile) Close() error {
+	var firstErr error
+	for _, f := range b.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	b.files = nil
+	return firstErr
+}
+
+// loadBuffer は現在の位置からバッファに読み込む (BufFileLoadBuffer 相当)
+func (b *BufFile) loadBuffer() error {
+	if b.curOffset >= maxPhysicalFileSize && b.curFile+1 < len(b.files) {
+		b.curFile++
+		b.curOffset = 0
+	}
+	b.waitEvent.Start(waitevent.IOBufFileRead)
+	n, err := b.files[b.curFile].ReadAt(b.buffer[:], b.curOffset)
+	b.waitEvent.End()
+	if err != nil {
+		b.nbytes = 0
+		return err
+	}
+	b.nbytes = n
+	return nil
+}
+
+// dumpBuffer はバッファの内容をファイルに書き出す (BufFileDumpBuffer 相当)。
+// 書き出す途中でファイルの区切りに達したら、次のファイルに続けて書く。
+func (b *BufFile) dumpBuffer() error {
+	logical := b.Tell()
+	wpos := 0
+	for wpos < b.nbytes {
+		if b.curOffset >= maxPhysicalFileSize {
+			for b.curFile+1 >= len(b.files) {
+				f, err := b.temp.OpenTemporaryFile(b.interXact)
+				if err != nil {
+					return err
+				}
+				b.files = append(b.files, f)
+			}
+			b.curFile++
+			b.curOffset = 0
+		}
+		chunk := b.nbytes - wpos
+		if rest := maxPhysicalFileSize - b.curOffset; int64(chunk) > rest {
+			chunk = int(rest)
+		}
+		b.waitEvent.Start(waitevent.IOBufFileWrite)
+		n, err := b.files[b.curFile].WriteAt(b.buffer[wpos:wpos+chunk], b.curOffset)
+		b.waitEvent.End()
+		if err != nil {
+			return err
+		}
+		b.curOffset += int64(n)
+		wpos += n
+	}
+	b.dirty = false
+	// バッファを空にし、書き出す前の読み書きする位置に戻す
+	b.setPosition(logical)
+	return nil
+}
+
+// setPosition はバッファを空にして論理的な位置 logical に移る。まだないファイルの先頭に
+// 当たる位置は、前のファイルの末尾として表す。
+func (b *BufFile) setPosition(logical int64) {
+	b.curFile = int(logical / maxPhysicalFileSize)
+	b.curOffset = logical % maxPhysicalFileSize
+	if b.curFile >= len(b.files) && b.curOffset == 0 && b.curFile > 0 {
+		b.curFile--
+		b.curOffset = maxPhysicalFileSize
+	}
+	b.pos = 0
+	b.nbytes = 0
+}
+
+// Read は現在の位置から p に読み込む (BufFileRead 相当)。ファイルの終わりでは io.EOF を返す。
+func (b *BufFile) Read(p []byte) (int, error) {
+	if b.dirty {
+		if err := b.flush(); err != nil {
+			return 0, err
+		}
+	}
+	total := 0
+	for len(p) > 0 {
+		if b.pos >= b.nbytes {
+			// バッファを読み終えたので次を読み込む
+			b.curOffset += int64(b.nbytes)
+			b.pos = 0
+			b.nbytes = 0
+			if err := b.loadBuffer(); err != nil {
+				return total, err
+			}
+			if b.nbytes == 0 {
+				break
+			}
+		}
+		n := copy(p, b.buffer[b.pos:b.nbytes])
+		b.pos += n
+		p = p[n:]
+		total += n
+	}
+	if total == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	return total, nil
+}
+
+// Write は p を現在の位置に書く (BufFileWrite 相当)
+func (b *BufFile) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		if b.pos >= len(b.buffer) {
+			// バッファがいっぱいなので書き出す
+			if b.dirty {
+				if err := b.dumpBuffer(); err != nil {
+					return total, err
+				}
+			} else {
+				// 読み込んだだけのバッファは捨てて次の位置に進む
+				b.curOffset += int64(b.pos)
+				b.pos = 0
+				b.nbytes = 0
+			}
+		}
+		n := copy(b.buffer[b.pos:], p)
+		b.dirty = true
+		b.pos += n
+		if b.nbytes < b.pos {
+			b.nbytes = b.pos
+		}
+		p = p[n:]
+		total += n
+	}
+	return total, nil
+}
+
+// flush はバッファに書いた内容があれば書き出す (BufFileFlush 相当)
+func (b *BufFile) flush() error {
+	if b.dirty {
+		return b.dumpBuffer()
+	}
+	return nil
+}
+
+// Seek は読み書きする位置を論理的な位置で動かす (BufFileSeek 相当)。io.SeekStart、
+// io.SeekCurrent、io.SeekEnd を受け付け、新しい位置を返す。
+func (b *BufFile) Seek(offset int64, whence int) (int64, error) {
+	var newPos int64
+	switch whence {
+	case io.SeekStart:
+		newPos = offset
+	case io.SeekCurrent:
+		newPos = b.Tell() + offset
+	case io.SeekEnd:
+		if err := b.flush(); err != nil {
+			return 0, err
+		}
+		newPos = b.Size() + offset
+	default:
+		return 0, newError(errcodes.InternalError, "invalid whence: %d", whence)
+	}
+	if newPos < 0 {
+		return 0, newError(errcodes.InternalError, "could not seek to block %d in temporary file", newPos/pgconfig.BlckSz)
+	}
+	// 今のバッファの中に収まる移動なら、バッファを捨てずに位置だけ変える
+	if start := b.Tell() - int64(b.pos); newPos >= start && newPos <= start+int64(b.nbytes) {
+		b.pos = int(newPos - start)
+		return newPos, nil
+	}
+	if err := b.flush(); err != nil {
+		return 0, err
+	}
+	if newPos > b.Size() {
+		return 0, newError(errcodes.InternalError, "could not seek to block %d in temporary file", newPos/pgconfig.BlckSz)
+	}
+	b.setPosition(newPos)
+	return newPos, nil
+}
+
+// Tell は現在の論理的な位置を返す (BufFileTell 相当)
+func (b *BufFile) Tell() int64 {
+	return int64(b.curFile)*maxPhysicalFileSize + b.curOffset + int64(b.pos)
+}
+
+// Size はファイル全体の論理的な大きさを返す (BufFileSize 相当)。書き出していないバッファの
+// 内容も含める。
+func (b *BufFile) Size() int64 {
+	last := len(b.files) - 1
+	size := int64(last)*maxPhysicalFileSize + b.files[last].Size()
+	if b.dirty {
+		if end := int64(b.curFile)*maxPhysicalFileSize + b.curOffset + int64(b.nbytes); end > size {
+			size = end
+		}
+	}
+	return size
+}
@@ -0,0 +1,527 @@
+package file
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/injection"
+)
+
+// ----------------------------------------------------------------
+// 仮想ファイル記述子と一時ファイル (storage/file/fd.c 相当)
+// ----------------------------------------------------------------
+// リレーションのセグメントや一時ファイルは、OS のファイル記述子を直接持たずに仮想ファイル記述子
+// (File) を通して開く。開いているファイルが max_files_per_process から決めた上限 (maxSafeFds) に
+// 達すると、最も長く使っていないファイルの記述子を閉じる。閉じたファイルは次に読み書きするときに
+// 開き直すため、呼び出し元は気付かない。開き直すときは、作成と切り詰め (O_CREATE、O_TRUNC、O_EXCL)
+// を外した同じフラグで開く。
+//
+// C言語版はバックエンドのプロセスごとに Vfd の表を持つ。バックエンドはゴルーチンで、ストレージ
+// マネージャーの開いたセグメントも全てのバックエンドで共有するため、ここでは表を1つにしてロックで
+// 守る。max_files_per_process はサーバーのプロセス全体で開いておくファイルの数を制限する。
+// 読み書きの途中のファイルは閉じない。
+//
+// 並べ替えやハッシュ表の溢れた分を書き出す一時ファイルを作り、セッションが使っている大きさの合計を
+// temp_file_limit に制限する。一時ファイルは base/pgsql_tmp の下に pgsql_tmp<PID>.<番号> の名前で作り、
+// 閉じると削除する。削除するときはファイルのパスと大きさを NewTempFiles に渡した関数に知らせ、
+// セッションがそれを統計に数え、log_temp_files に従ってログに出す。
+//
+// トランザクションをまたがない一時ファイルは、トランザクションの終わりに AtEOXact が閉じる
+// (C言語版の ResourceOwner による後始末相当)。セッションの終わりには CleanupAll が残りを全て閉じる。
+// サーバーが異常終了して残ったファイルは、次の起動時に RemovePgTempFiles が削除する。
+
+// PgTempFilesDir は一時ファイルを置くディレクトリの名前 (PG_TEMP_FILES_DIR 相当)
+const PgTempFilesDir = "pgsql_tmp"
+
+// PgTempFilePrefix は一時ファイルの名前の接頭辞 (PG_TEMP_FILE_PREFIX 相当)
+const PgTempFilePrefix = "pgsql_tmp"
+
+// Error は SQLSTATE 付きのファイル操作のエラー。バックエンドはクライアントにそのまま報告する。
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func newError(code, format string, args ...any) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ErrcodeForFileAccess は OS のエラーに合う SQLSTATE を返す (errcode_for_file_access 相当)
+func ErrcodeForFileAccess(err error) string {
+	var errno syscall.Errno
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return errcodes.InsufficientPrivilege
+	case errors.Is(err, fs.ErrNotExist):
+		return errcodes.UndefinedFile
+	case errors.Is(err, fs.ErrExist):
+		return errcodes.DuplicateFile
+	case errors.As(err, &errno) && errno == syscall.ENOSPC:
+		return errcodes.DiskFull
+	case errors.As(err, &errno) && errno == syscall.EIO:
+		return errcodes.IOError
+	}
+	return errcodes.InternalError
+}
+
+// fileAccessError は OS のエラーから SQLSTATE を決めてエラーを作る。format の後ろに OS の
+// エラーメッセージを加える。
+func fileAccessError(err error, format string, args ...any) error {
+	return newError(ErrcodeForFileAccess(err), "%s: %s", fmt.Sprintf(format, args...), platform.OSErrorMessage(err))
+}
+
+const (
+	// numReservedFDs は VFD の外で開く記述子 (ソケットやログファイルなど) のために残す数
+	// (NUM_RESERVED_FDS 相当)
+	numReservedFDs = 10
+	// fdMinFree はサーバーを起動するのに最低限要る VFD の数 (FD_MINFREE 相当)
+	fdMinFree = 48
+)
+
+// File は仮想ファイル記述子 (Vfd 相当)。PathNameOpenFile で開き、Close で閉じる。複数の
+// ゴルーチンから同時に読み書きしてよい。
+type File struct {
+	path string
+	// flags と perm は閉じた記述子を開き直すときのフラグ (fileFlags と fileMode 相当)
+	flags int
+	perm  fs.FileMode
+	// fd は OS のファイル。LRU で閉じていれば nil (VFD_CLOSED 相当)
+	fd *os.File
+	// elem は vfdCache.lru の中の位置。fd が nil なら nil
+	elem *list.Element
+	// inUse は読み書きの途中の数。0 でないファイルは LRU で閉じない
+	inUse int
+	// closed は Close を呼んだことを表す。読み書きの途中なら、最後の読み書きが記述子を閉じる
+	closed bool
+}
+
+// vfdCache は開いている仮想ファイル記述子の表 (VfdCache 相当)
+var vfdCache struct {
+	sync.Mutex
+	// lru は記述子を開いているファイルの並び。先頭ほど最近使った (lruMoreRecently の環相当)
+	lru list.List
+}
+
+// maxSafeFds は VFD が同時に開いておける記述子の数 (max_safe_fds 相当)。SetMaxSafeFds で決める
+// までは控えめな値を使う。
+var maxSafeFds = 32
+
+// SetMaxSafeFds は max_files_per_process と OS の制限から、VFD が同時に開いておける記述子の数を
+// 決める (set_max_safe_fds 相当)。サーバーの起動時に呼ぶ。C言語版は dup で開けるだけ開いて数えるが、
+// ここでは RLIMIT_NOFILE と既に開いている記述子の数から求める。
+func SetMaxSafeFds() error {
+	usableFds, alreadyOpen := countUsableFds(guc.MaxFilesPerProcess.Get())
+	safe := min(usableFds, guc.MaxFilesPerProcess.Get()-alreadyOpen) - numReservedFDs
+	if safe < fdMinFree {
+		return fmt.Errorf("insufficient file descriptors available to start server process\nDETAIL:  System allows %d, server needs at least %d.",
+			safe+numReservedFDs, fdMinFree+numReservedFDs)
+	}
+	vfdCache.Lock()
+	maxSafeFds = safe
+	vfdCache.Unlock()
+	errutil.Report(nil, errutil.Elog(errutil.Debug2, "max_safe_fds = %d, usable_fds = %d, already_open = %d",
+		safe, usableFds, alreadyOpen))
+	return nil
+}
+
+// countUsableFds は新しく開ける記述子の数 (maxToProbe まで) と、既に開いている記述子の数を返す
+// (count_usable_fds 相当)
+func countUsableFds(maxToProbe int) (usableFds, alreadyOpen int) {
+	// 一覧を読むために開いた記述子も1つ数える
+	if entries, err := os.ReadDir("/dev/fd"); err == nil {
+		alreadyOpen = max(len(entries)-1, 0)
+	}
+	usableFds = maxToProbe
+	var rlim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlim); err == nil && rlim.Cur < uint64(alreadyOpen+maxToProbe) {
+		usableFds = max(int(rlim.Cur)-alreadyOpen, 0)
+	}
+	return usableFds, alreadyOpen
+}
+
+// PathNameOpenFile はファイルを開いて仮想ファイル記述子を返す (PathNameOpenFilePerm 相当)。
+// OS のエラーはそのまま返す。
+func PathNameOpenFile(path string, flags int, perm fs.FileMode) (*File, error) {
+	vfdCache.Lock()
+	defer vfdCache.Unlock()
+	fd, err := basicOpenFile(path, flags, perm)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{path: path, flags: flags &^ (os.O_CREATE | os.O_TRUNC | os.O_EXCL), perm: perm, fd: fd}
+	f.elem = vfdCache.lru.PushFront(f)
+	return f, nil
+}
+
+// basicOpenFile は記述子を開く。開く前に上限を超えないよう LRU のファイルを閉じ、それでも記述子が
+// 足りなければさらに閉じてやり直す (BasicOpenFilePerm 相当)。vfdCache のロックを取ってから呼ぶ。
+func basicOpenFile(path string, flags int, perm fs.FileMode) (*os.File, error) {
+	releaseLruFiles()
+	for {
+		fd, err := os.OpenFile(path, flags, perm)
+		if !errors.Is(err, syscall.EMFILE) && !errors.Is(err, syscall.ENFILE) {
+			return fd, err
+		}
+		errutil.Report(nil, errutil.New(errutil.Log, errcodes.InsufficientResources,
+			"out of file descriptors: %s; release and retry", platform.OSErrorMessage(err)))
+		if !releaseLruFile() {
+			return nil, err
+		}
+	}
+}
+
+// releaseLruFiles は開いている記述子が maxSafeFds を下回るまで LRU のファイルを閉じる
+// (ReleaseLruFiles 相当)
+func releaseLruFiles() {
+	for vfdCache.lru.Len() >= maxSafeFds {
+		if !releaseLruFile() {
+			break
+		}
+	}
+}
+
+// releaseLruFile は読み書きの途中でないファイルのうち、最も長く使っていないものの記述子を閉じる
+// (ReleaseLruFile と LruDelete 相当)。閉じられるファイルがなければ false を返す。
+func releaseLruFile() bool {
+	for e := vfdCache.lru.Back(); e != nil; e = e.Prev() {
+		f := e.Value.(*File)
+		if f.inUse > 0 {
+			continue
+		}
+		if err := f.fd.Close(); err != nil {
+			errutil.Report(nil, errutil.New(errutil.Log, ErrcodeForFileAccess(err), "could not close file \"%s\": %s", f.path, platform.OSErrorMessage(err)))
+		}
+		vfdCache.lru.Remove(e)
+		f.fd, f.elem = nil, nil
+		return true
+	}
+	return false
+}
+
+// acquire は読み書きの前に記述子を開いておき、読み書きの途中として数える (FileAccess 相当)。
+// 閉じていれば開き直す。最近使ったファイルとして LRU の先頭に移す。
+func (f *File) acquire() (*os.File, error) {
+	vfdCache.Lock()
+	defer vfdCache.Unlock()
+	if f.closed {
+		return nil, os.ErrClosed
+	}
+	if f.fd == nil {
+		fd, err := basicOpenFile(f.path, f.flags, f.perm)
+		if err != nil {
+			return nil, err
+		}
+		f.fd = fd
+		f.elem = vfdCache.lru.PushFront(f)
+	} else {
+		vfdCache.lru.MoveToFront(f.elem)
+	}
+	f.inUse++
+	return f.fd, nil
+}
+
+// release は読み書きが終わったことを記録する。途中で Close が呼ばれていれば記述子を閉じる。
+func (f *File) release() {
+	vfdCache.Lock()
+	defer vfdCache.Unlock()
+	f.inUse--
+	if f.closed && f.inUse == 0 && f.fd != nil {
+		f.fd.Close()
+		f.fd = nil
+	}
+}
+
+// Name はファイルのパスを返す (FilePathName 相当)
+func (f *File) Name() string { return f.path }
+
+// ReadAt はファイルの offset から読む (FileRead 相当)
+func (f *File) ReadAt(b []byte, offset int64) (int, error) {
+	fd, err := f.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer f.release()
+	return fd.ReadAt(b, offset)
+}
+
+// WriteAt はファイルの offset に書く (FileWrite 相当)
+func (f *File) WriteAt(b []byte, offset int64) (int, error) {
+	fd, err := f.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer f.release()
+	return fd.WriteAt(b, offset)
+}
+
+// Prefetch はファイルの offset から amount バイトをまもなく読むことを OS に伝える
+// (FilePrefetch 相当)。先読みできない環境では何もしない。
+func (f *File) Prefetch(offset, amount int64) error {
+	if !platform.PrefetchSupported {
+		return nil
+	}
+	fd, err := f.acquire()
+	if err != nil {
+		return err
+	}
+	defer f.release()
+	return platform.Prefetch(int(fd.Fd()), offset, amount)
+}
+
+// Truncate はファイルを size バイトに切り詰める (FileTruncate 相当)
+func (f *File) Truncate(size int64) error {
+	fd, err := f.acquire()
+	if err != nil {
+		return err
+	}
+	defer f.release()
+	return fd.Truncate(size)
+}
+
+// Sync はファイルをディスクに同期する (FileSync 相当)。インジェクションポイント fd-sync で
+// 同期の失敗を起こせる。
+func (f *File) Sync() error {
+	if err := injection.Run("fd-sync"); err != nil {
+		return &fs.PathError{Op: "fsync", Path: f.path, Err: err}
+	}
+	fd, err := f.acquire()
+	if err != nil {
+		return err
+	}
+	defer f.release()
+	return fd.Sync()
+}
+
+// Size はファイルの大きさを返す (FileSize 相当)
+func (f *File) Size() (int64, error) {
+	fd, err := f.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer f.release()
+	fi, err := fd.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// Close は仮想ファイル記述子を閉じる (FileClose 相当)。読み書きの途中なら、最後の読み書きが
+// 終わったときに記述子を閉じる。
+func (f *File) Close() error {
+	vfdCache.Lock()
+	defer vfdCache.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	if f.elem != nil {
+		vfdCache.lru.Remove(f.elem)
+		f.elem = nil
+	}
+	if f.fd == nil || f.inUse > 0 {
+		return nil
+	}
+	err := f.fd.Close()
+	f.fd = nil
+	return err
+}
+
+// tempFilesDir は一時ファイルを置くディレクトリを返す。データディレクトリを使わずに起動した
+// 場合は、OS の一時ディレクトリの下に置く。
+func tempFilesDir() string {
+	if dataDir := guc.DataDirectory.Get(); dataDir != "" {
+		return filepath.Join(dataDir, "base", PgTempFilesDir)
+	}
+	return filepath.Join(os.TempDir(), PgTempFilesDir)
+}
+
+// TempFiles はセッションが開いている一時ファイルと、その大きさの合計
+// (fd.c の一時ファイルの Vfd と temporary_files_size 相当)。セッションごとに NewTempFiles で作り、
+// 1つのゴルーチンから使う。
+type TempFiles struct {
+	pid int32
+	// limit は temp_file_limit の値 (kB) を返す。-1 なら制限しない
+	limit func() int
+	// report は閉じたファイルのパスと大きさを受け取る (ReportTemporaryFileUsage 相当)
+	report func(path string, size int64)
+	// size は開いている一時ファイルの大きさの合計 (temporary_files_size 相当)
+	size int64
+	// counter はファイル名に付ける番号 (tempFileCounter 相当)
+	counter int
+	files   map[*TempFile]struct{}
+}
+
+// NewTempFiles はセッションの一時ファイルを管理する TempFiles を作る。pid はファイル名に使う
+// バックエンドの PID、limit は temp_file_limit の値を返す。report は一時ファイルを閉じるたびに
+// そのパスと大きさを受け取る。
+func NewTempFiles(pid int32, limit func() int, report func(path string, size int64)) *TempFiles {
+	return &TempFiles{pid: pid, limit: limit, report: report, files: make(map[*TempFile]struct{})}
+}
+
+// TempFile は開いている一時ファイル1つ
+type TempFile struct {
+	owner *TempFiles
+	f     *File
+	path  string
+	// size はファイルの大きさ (fileSize 相当)
+	size      int64
+	interXact bool
+}
+
+// OpenTemporaryFile は一時ファイルを作って開く (OpenTemporaryFile 相当)。interXact が false の
+// ファイルはトランザクションの終わりに閉じる。
+func (t *TempFiles) OpenTemporaryFile(interXact bool) (*TempFile, error) {
+	dir := tempFilesDir()
+	t.counter++
+	path := filepath.Join(dir, fmt.Sprintf("%s%d.%d", PgTempFilePrefix, t.pid, t.counter))
+	f, err := PathNameOpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if errors.Is(err, fs.ErrNotExist) {
+		// ディレクトリがなければ作ってからやり直す。他のバックエンドが同時に作っても構わない
+		if mkErr := os.MkdirAll(dir, 0700); mkErr != nil {
+			return nil, fileAccessError(mkErr, "could not create directory \"%s\"", dir)
+		}
+		f, err = PathNameOpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	}
+	if err != nil {
+		return nil, fileAccessError(err, "could not create temporary file \"%s\"", path)
+	}
+	file := &TempFile{owner: t, f: f, path: path, interXact: interXact}
+	t.files[file] = struct{}{}
+	return file, nil
+}
+
+// Path はファイルのパスを返す (FilePathName 相当)
+func (f *TempFile) Path() string { return f.path }
+
+// Size はファイルの大きさを返す (FileSize 相当)
+func (f *TempFile) Size() int64 { return f.size }
+
+// ReadAt はファイルの offset から読む (FileRead 相当)
+func (f *TempFile) ReadAt(b []byte, offset int64) (int, error) {
+	n, err := f.f.ReadAt(b, offset)
+	if err != nil && n == 0 && offset < f.size {
+		return n, fileAccessError(err, "could not read from temporary file \"%s\"", f.path)
+	}
+	return n, nil
+}
+
+// WriteAt はファイルの offset に書く (FileWrite 相当)。ファイルを大きくする書き込みは、セッションの
+// 一時ファイルの大きさの合計が temp_file_limit を超えるならエラーにする。
+func (f *TempFile) WriteAt(b []byte, offset int64) (int, error) {
+	t := f.owner
+	newPos := offset + int64(len(b))
+	if limit := t.limit(); limit >= 0 && newPos > f.size {
+		if t.size+(newPos-f.size) > int64(limit)*1024 {
+			return 0, newError(errcodes.ConfigurationLimitExceeded,
+				"temporary file size exceeds temp_file_limit (%dkB)", limit)
+		}
+	}
+	n, err := f.f.WriteAt(b, offset)
+	if err != nil {
+		return n, fileAccessError(err, "could not write to temporary file \"%s\"", f.path)
+	}
+	if end := offset + int64(n); end > f.size {
+		t.size += end - f.size
+		f.size = end
+	}
+	return n, nil
+}
+
+// Truncate はファイルを size の大きさに切り詰める (FileTruncate 相当)
+func (f *TempFile) Truncate(size int64) error {
+	if size >= f.size {
+		return nil
+	}
+	if err := f.f.Truncate(size); err != nil {
+		return fileAccessError(err, "could not truncate temporary file \"%s\"", f.path)
+	}
+	f.owner.size -= f.size - size
+	f.size = size
+	return nil
+}
+
+// Close はファイルを閉じて削除する (FileClose 相当)。削除に失敗しても、ファイルの大きさは知らせる。
+func (f *TempFile) Close() error {
+	t := f.owner
+	if _, ok := t.files[f]; !ok {
+		return nil
+	}
+	delete(t.files, f)
+	t.size -= f.size
+	err := f.f.Close()
+	if rmErr := os.Remove(f.path); rmErr != nil && err == nil {
+		err = rmErr
+	}
+	t.report(f.path, f.size)
+	if err != nil {
+		return fileAccessError(err, "could not remove temporary file \"%s\"", f.path)
+	}
+	return nil
+}
+
+// AtEOXact はトランザクションの終わりに、トランザクションをまたがない一時ファイルを閉じる
+// (AtEOXact_Files 相当)
+func (t *TempFiles) AtEOXact() {
+	if t == nil {
+		return
+	}
+	for f := range t.files {
+		if !f.interXact {
+			t.closeAndLog(f)
+		}
+	}
+}
+
+// CleanupAll はセッションの終わりに全ての一時ファイルを閉じる (AtProcExit_Files 相当)
+func (t *TempFiles) CleanupAll() {
+	if t == nil {
+		return
+	}
+	for f := range t.files {
+		t.closeAndLog(f)
+	}
+}
+
+// closeAndLog は後始末でファイルを閉じ、失敗すればログに出す
+func (t *TempFiles) closeAndLog(f *TempFile) {
+	if err := f.Close(); err != nil {
+		errutil.Report(nil, errutil.Elog(errutil.Log, "%s", err.Error()))
+	}
+}
+
+// RemovePgTempFiles は前回のサーバーが残した一時ファイルを削除する (RemovePgTempFiles 相当)。
+// postmaster が起動時に呼ぶ。削除に失敗したファイルはログに出して残す。
+func RemovePgTempFiles() {
+	dir := tempFilesDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			errutil.Report(nil, errutil.Elog(errutil.Log, "could not open directory \"%s\": %s", dir, platform.OSErrorMessage(err)))
+		}
+		return
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if !strings.HasPrefix(e.Name(), PgTempFilePrefix) {
+			errutil.Report(nil, errutil.Elog(errutil.Log, "unexpected file found in temporary-files directory: \"%s\"", path))
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			errutil.Report(nil, errutil.Elog(errutil.Log, "could not remove file \"%s\": %s", path, platform.OSErrorMessage(err)))
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setMaxSafeFds はテストの間だけ VFD が開いておける記述子の数を n にする。
+func setMaxSafeFds(t *testing.T, n int) {
+	t.Helper()
+	vfdCache.Lock()
+	prev := maxSafeFds
+	maxSafeFds = n
+	vfdCache.Unlock()
+	t.Cleanup(func() {
+		vfdCache.Lock()
+		maxSafeFds = prev
+		vfdCache.Unlock()
+	})
+}
+
+func TestVFDReopensFilesClosedByLRU(t *testing.T) {
+	const limit, nfiles = 3, 6
+	setMaxSafeFds(t, limit)
+	dir := t.TempDir()
+
+	files := make([]*File, nfiles)
+	for i := range files {
+		f, err := PathNameOpenFile(filepath.Join(dir, fmt.Sprintf("f%d", i)), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			t.Fatalf("PathNameOpenFile = %v", err)
+		}
+		defer f.Close()
+		if _, err := f.WriteAt([]byte(fmt.Sprintf("file %d", i)), 0); err != nil {
+			t.Fatalf("WriteAt = %v", err)
+		}
+		files[i] = f
+	}
+
+	vfdCache.Lock()
+	open := vfdCache.lru.Len()
+	closedFirst := files[0].fd == nil
+	vfdCache.Unlock()
+	if open > limit {
+		t.Errorf("open descriptors = %d, want at most %d", open, limit)
+	}
+	if !closedFirst {
+		t.Error("least recently used file was not closed")
+	}
+
+	// 閉じられたファイルは次の読み書きで開き直され、O_EXCL で失敗したり切り詰められたりしない
+	for i, f := range files {
+		buf := make([]byte, len(fmt.Sprintf("file %d", i)))
+		if _, err := f.ReadAt(buf, 0); err != nil {
+			t.Fatalf("ReadAt(%s) = %v", f.Name(), err)
+		}
+		if want := fmt.Sprintf("file %d", i); string(buf) != want {
+			t.Errorf("ReadAt(%s) = %q, want %q", f.Name(), buf, want)
+		}
+	}
+	vfdCache.Lock()
+	open = vfdCache.lru.Len()
+	vfdCache.Unlock()
+	if open > limit {
+		t.Errorf("open descriptors after reopening = %d, want at most %d", open, limit)
+	}
+}
+
+func TestVFDClosedFileFails(t *testing.T) {
+	f, err := PathNameOpenFile(filepath.Join(t.TempDir(), "f"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatalf("PathNameOpenFile = %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close = %v", err)
+	}
+	if _, err := f.ReadAt(make([]byte, 1), 0); err == nil {
+		t.Error("ReadAt after Close succeeded")
+	}
+}
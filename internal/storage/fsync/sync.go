@@ -0,0 +1,125 @@
+package fsync
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/file"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/injection"
+)
+
+// ----------------------------------------------------------------
+// ファイルの同期の要求 (storage/sync/sync.c 相当)
+// ----------------------------------------------------------------
+// ファイルに書いたプロセスはその場では fsync せず、RegisterSyncRequest で同期の要求を登録する。
+// 次のチェックポイントで ProcessSyncRequests が、それまでに登録された全てのファイルをまとめて
+// 同期する。同じファイルへの何度もの書き込みを1回の fsync で済ませ、書き込みのたびに待たずに済む。
+//
+// C言語版ではバックエンドが共有メモリのキューを通して checkpointer に要求を送り、checkpointer が
+// 自分のハッシュ表 (pendingOps) に移す。バックエンドはゴルーチンのため、ここでは1つの表を
+// ロックで守って全てのバックエンドから直接登録する。
+//
+// 削除したファイルは ForgetSyncRequest で要求を取り消す。取り消す前にチェックポイントが始まって
+// ファイルが見つからなかった場合も、消されたものとみなしてエラーにしない。
+
+// pendingOps は同期を待っているファイル (pendingOps 相当)
+var pendingOps struct {
+	sync.Mutex
+	files map[string]struct{}
+}
+
+// RegisterSyncRequest は次のチェックポイントで path を同期するよう登録する
+// (RegisterSyncRequest の SYNC_REQUEST 相当)
+func RegisterSyncRequest(path string) {
+	pendingOps.Lock()
+	defer pendingOps.Unlock()
+	if pendingOps.files == nil {
+		pendingOps.files = make(map[string]struct{})
+	}
+	pendingOps.files[path] = struct{}{}
+}
+
+// ForgetSyncRequest は削除するファイルの同期の要求を取り消す
+// (RegisterSyncRequest の SYNC_FORGET_REQUEST 相当)
+func ForgetSyncRequest(path string) {
+	pendingOps.Lock()
+	defer pendingOps.Unlock()
+	delete(pendingOps.files, path)
+}
+
+// PendingSyncRequests は同期を待っているファイルの数を返す。チェックポイントで同期するものが
+// あるかどうかを確かめるために使う。
+func PendingSyncRequests() int {
+	pendingOps.Lock()
+	defer pendingOps.Unlock()
+	return len(pendingOps.files)
+}
+
+// SyncStats は ProcessSyncRequests で同期したファイルの数と時間 (CheckpointStats の ckpt_sync_* 相当)
+type SyncStats struct {
+	Files   int
+	Longest time.Duration
+	Total   time.Duration
+}
+
+// ProcessSyncRequests は登録された全てのファイルを同期する (ProcessSyncRequests 相当)。
+// チェックポイントが始まった後に登録された要求は、次のチェックポイントで同期する。
+// progress が nil でなければ、ファイルを1つ同期するたびに、終えた割合 (0 から 1) を渡して呼ぶ。
+// checkpointer はこれでチェックポイントの進み具合を調整する。
+//
+// 同期に失敗したファイルの要求は残し、エラーを返す。
+func ProcessSyncRequests(progress func(float64)) (SyncStats, error) {
+	pendingOps.Lock()
+	paths := make([]string, 0, len(pendingOps.files))
+	for path := range pendingOps.files {
+		paths = append(paths, path)
+	}
+	pendingOps.files = nil
+	pendingOps.Unlock()
+	sort.Strings(paths)
+
+	var stats SyncStats
+	for i, path := range paths {
+		start := time.Now()
+		if err := fsyncFile(path); err != nil {
+			// 失敗したファイルと、まだ同期していないファイルは次のチェックポイントでやり直す
+			for _, p := range paths[i:] {
+				RegisterSyncRequest(p)
+			}
+			return stats, err
+		}
+		elapsed := time.Since(start)
+		stats.Files++
+		stats.Total += elapsed
+		stats.Longest = max(stats.Longest, elapsed)
+		if progress != nil {
+			progress(float64(i+1) / float64(len(paths)))
+		}
+	}
+	return stats, nil
+}
+
+// fsyncFile は path を同期する。ファイルがなければ、同期の要求を取り消す前に削除されたものとみなす
+func fsyncFile(path string) error {
+	f, err := file.PathNameOpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("could not open file \"%s\": %w", path, errors.Unwrap(err))
+	}
+	defer f.Close()
+	// インジェクションポイント mdsync で、チェックポイントがファイルを同期できない場合を起こせる
+	if err := injection.Run("mdsync"); err != nil {
+		return fmt.Errorf("could not fsync file \"%s\": %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("could not fsync file \"%s\": %w", path, errors.Unwrap(err))
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package ipc
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 共有メモリの作成 (storage/ipc/ipci.c, port/sysv_shmem.c, port/win32_shmem.c 相当)
+// ----------------------------------------------------------------
+// postmaster は起動時に CreateSharedMemoryAndSemaphores で1つのセグメントを作り、共有メモリを使う
+// モジュールの領域をそこに割り当てる。バックエンドの異常終了の後は、セグメントを作り直して
+// 初期化し直す。セグメントはデータディレクトリから決めたキーの名前で作り、Unix では /dev/shm
+// (なければ一時ディレクトリ) のファイルを mmap し、Windows では名前付きのファイルマッピングを使う。
+// 他のプロセスは AttachSharedMemory で同じセグメントを割り当てられる。
+//
+// 共有メモリを使うモジュールは RegisterShmemModule で必要な大きさと初期化の関数を登録する
+// (CalculateShmemSize と CreateOrAttachShmemStructs に並ぶ *ShmemSize と *ShmemInit 相当)。
+// 今のバックエンドはゴルーチンのため、ロック表などはまだ Go のメモリに置いている。
+
+// ModuleSize は登録したモジュールが使う共有メモリの大きさを返す関数 (*ShmemSize 相当)
+type ModuleSize func() int
+
+// ModuleInit は登録したモジュールの領域を ShmemInitStruct で割り当て、初めて割り当てたなら
+// 初期化する関数 (*ShmemInit 相当)
+type ModuleInit func(seg *Segment) error
+
+// shmemModules は共有メモリを使うモジュール
+var shmemModules struct {
+	sync.Mutex
+	modules []shmemModule
+}
+
+type shmemModule struct {
+	size ModuleSize
+	init ModuleInit
+}
+
+// RegisterShmemModule は共有メモリを使うモジュールを登録する (shmem_request_hook と
+// shmem_startup_hook 相当)。init でパッケージを初期化するときに呼ぶ。
+func RegisterShmemModule(size ModuleSize, init ModuleInit) {
+	shmemModules.Lock()
+	defer shmemModules.Unlock()
+	shmemModules.modules = append(shmemModules.modules, shmemModule{size: size, init: init})
+}
+
+// errSegmentInUse は同じキーのセグメントを他のサーバーが使っていることを表す
+var errSegmentInUse = errors.New("shared memory segment is still in use")
+
+// mainSegment は postmaster が作ったセグメント (ShmemSegHdr 相当)
+var mainSegment struct {
+	sync.Mutex
+	seg *Segment
+}
+
+// CalculateShmemSize は登録したモジュールが使う大きさの合計に、先頭の情報と ShmemIndex と
+// 余裕を加えた、セグメントの大きさを返す (CalculateShmemSize 相当)
+func CalculateShmemSize() int {
+	size := 100000
+	size += int(cacheLineAlign(int64(unsafe.Sizeof(shmemHeader{}))))
+	size += int(cacheLineAlign(int64(unsafe.Sizeof(shmemIndex{}))))
+	shmemModules.Lock()
+	for _, m := range shmemModules.modules {
+		size += int(cacheLineAlign(int64(m.size())))
+	}
+	shmemModules.Unlock()
+	// ページの大きさの倍数に切り上げる
+	pageSize := os.Getpagesize()
+	return (size + pageSize - 1) / pageSize * pageSize
+}
+
+// ShmemKey はデータディレクトリからセグメントのキーを決める。データディレクトリを使わなければ
+// C言語版の古い決め方と同じく port から決める。
+func ShmemKey(dataDir string, port int) uint32 {
+	if dataDir == "" {
+		return uint32(port) * 1000
+	}
+	if abs, err := filepath.Abs(dataDir); err == nil {
+		dataDir = abs
+	}
+	h := fnv.New32a()
+	h.Write([]byte(dataDir))
+	return h.Sum32()
+}
+
+// CreateSharedMemoryAndSemaphores はセグメントを作り、登録したモジュールの領域を初期化する
+// (CreateSharedMemoryAndSemaphores 相当)。既にセグメントがあれば削除して作り直す。
+// 起動時と、バックエンドの異常終了の後に postmaster が呼ぶ。
+func CreateSharedMemoryAndSemaphores(key uint32, dataDir string) error {
+	mainSegment.Lock()
+	defer mainSegment.Unlock()
+	if mainSegment.seg != nil {
+		mainSegment.seg.Detach()
+		mainSegment.seg = nil
+	}
+	size := CalculateShmemSize()
+	data, release, err := createSegment(key, size)
+	if errors.Is(err, errSegmentInUse) {
+		return fmt.Errorf("pre-existing shared memory block (key %d) is still in use\nHINT:  Terminate any old server processes associated with data directory \"%s\".", key, dataDir)
+	}
+	if err != nil {
+		return err
+	}
+	seg := newSegment(key, data, release)
+	seg.initShmemAllocation(int32(os.Getpid()))
+	if err := initModules(seg); err != nil {
+		return err
+	}
+	mainSegment.seg = seg
+	return nil
+}
+
+// AttachSharedMemory は key のセグメントを割り当て、登録したモジュールの領域を探す
+// (PGSharedMemoryReAttach と CreateOrAttachShmemStructs 相当)。別のプロセスになった
+// バックエンドが使う。
+func AttachSharedMemory(key uint32) (*Segment, error) {
+	data, release, err := attachSegment(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not reattach to shared memory (key=%d): %w", key, err)
+	}
+	if len(data) < int(unsafe.Sizeof(shmemHeader{})) {
+		release()
+		return nil, fmt.Errorf("could not reattach to shared memory (key=%d): segment is too small", key)
+	}
+	seg := newSegment(key, data, release)
+	if seg.hdr.magic != pgShmemMagic {
+		seg.Detach()
+		return nil, fmt.Errorf("shared memory segment (key=%d) is not a PostgreSQL segment", key)
+	}
+	if err := initModules(seg); err != nil {
+		return nil, err
+	}
+	return seg, nil
+}
+
+// initModules は登録したモジュールの領域を割り当てる (CreateOrAttachShmemStructs 相当)。
+// 失敗すればセグメントの割り当てを解く。
+func initModules(seg *Segment) error {
+	shmemModules.Lock()
+	modules := append([]shmemModule(nil), shmemModules.modules...)
+	shmemModules.Unlock()
+	for _, m := range modules {
+		if err := m.init(seg); err != nil {
+			seg.Detach()
+			return err
+		}
+	}
+	return nil
+}
+
+// DestroySharedMemory は postmaster が作ったセグメントを削除する (停止時の shmem_exit 相当)
+func DestroySharedMemory() {
+	mainSegment.Lock()
+	defer mainSegment.Unlock()
+	if mainSegment.seg != nil {
+		mainSegment.seg.Detach()
+		mainSegment.seg = nil
+	}
+}
+
+// GetShmemAllocations は postmaster が作ったセグメントの割り当ての一覧を返す
+// (pg_get_shmem_allocations 相当)。作っていなければ ERROR を返す。
+func GetShmemAllocations() ([]Allocation, error) {
+	mainSegment.Lock()
+	defer mainSegment.Unlock()
+	if mainSegment.seg == nil {
+		return nil, errutil.New(errutil.Error, errcodes.ObjectNotInPrerequisiteState, "shared memory is not initialized")
+	}
+	return mainSegment.seg.Allocations(), nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package ipc
+
+import "errors"
+
+// errShmemNotSupported はこの環境では他のプロセスとメモリを共有できないことを表す
+var errShmemNotSupported = errors.New("shared memory is not supported on this platform")
+
+// createSegment は size バイトのセグメントを作る。この環境では他のプロセスと共有できないため、
+// このプロセスのメモリに置く。
+func createSegment(key uint32, size int) ([]byte, func() error, error) {
+	return make([]byte, size), func() error { return nil }, nil
+}
+
+// attachSegment は作ってある key のセグメントを割り当てる。この環境では割り当てられない。
+func attachSegment(key uint32) ([]byte, func() error, error) {
+	return nil, nil, errShmemNotSupported
+}
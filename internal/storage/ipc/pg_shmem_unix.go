@@ -0,0 +1,98 @@
+//go:build linux || darwin || freebsd
+
+package ipc
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+)
+
+// segmentPath はキーのセグメントのファイル。POSIX の共有メモリと同じく /dev/shm に置き、
+// なければ一時ディレクトリに置く (dsm_impl_posix の名前相当)
+func segmentPath(key uint32) string {
+	dir := "/dev/shm"
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, fmt.Sprintf("PostgreSQL.%d", key))
+}
+
+// createSegment は key のセグメントを size バイトで作って割り当てる (PGSharedMemoryCreate 相当)。
+// 前に異常終了したサーバーが残したセグメントは削除して作り直す。
+func createSegment(key uint32, size int) ([]byte, func() error, error) {
+	path := segmentPath(key)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, fs.ErrExist) {
+		if inUse(path) {
+			return nil, nil, errSegmentInUse
+		}
+		os.Remove(path)
+		f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create shared memory segment \"%s\": %s", path, platform.OSErrorMessage(err))
+	}
+	defer f.Close()
+	if err := f.Truncate(int64(size)); err != nil {
+		os.Remove(path)
+		return nil, nil, fmt.Errorf("could not resize shared memory segment \"%s\" to %d bytes: %s", path, size, platform.OSErrorMessage(err))
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		os.Remove(path)
+		return nil, nil, fmt.Errorf("could not map shared memory segment \"%s\": %w", path, err)
+	}
+	return data, func() error {
+		err := unix.Munmap(data)
+		os.Remove(path)
+		return err
+	}, nil
+}
+
+// attachSegment は作ってある key のセグメントを割り当てる
+func attachSegment(key uint32) ([]byte, func() error, error) {
+	f, err := os.OpenFile(segmentPath(key), os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, errors.New(platform.OSErrorMessage(err))
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, errors.New(platform.OSErrorMessage(err))
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, int(fi.Size()), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return unix.Munmap(data) }, nil
+}
+
+// inUse は残っていたセグメントを作ったサーバーがまだ動いているかを返す (PGSharedMemoryAttach の
+// SHMSTATE_ATTACHED の判定相当)
+func inUse(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var hdr shmemHeader
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&hdr)), unsafe.Sizeof(hdr))
+	if _, err := f.ReadAt(buf, 0); err != nil || hdr.magic != pgShmemMagic {
+		return false
+	}
+	pid := int(hdr.creatorPID)
+	if pid == os.Getpid() {
+		return false
+	}
+	err = syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,85 @@
+//go:build windows
+
+package ipc
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modkernel32            = windows.NewLazySystemDLL("kernel32.dll")
+	procCreateFileMappingW = modkernel32.NewProc("CreateFileMappingW")
+	procOpenFileMappingW   = modkernel32.NewProc("OpenFileMappingW")
+)
+
+// segmentName はキーのセグメントのファイルマッピングの名前
+func segmentName(key uint32) string {
+	return fmt.Sprintf(`Local\PostgreSQL.%d`, key)
+}
+
+// createSegment は key のセグメントを size バイトのページングファイルのマッピングとして作って
+// 割り当てる (PGSharedMemoryCreate 相当)。マッピングは全てのハンドルを閉じると消えるため、
+// 同じ名前のものがあれば他のサーバーが使っている。
+func createSegment(key uint32, size int) ([]byte, func() error, error) {
+	name, err := windows.UTF16PtrFromString(segmentName(key))
+	if err != nil {
+		return nil, nil, err
+	}
+	h, _, errno := procCreateFileMappingW.Call(uintptr(windows.InvalidHandle), 0, windows.PAGE_READWRITE,
+		uintptr(uint64(size)>>32), uintptr(uint32(size)), uintptr(unsafe.Pointer(name)))
+	if h == 0 {
+		return nil, nil, fmt.Errorf("could not create shared memory segment: %w", errno)
+	}
+	if errno == windows.ERROR_ALREADY_EXISTS {
+		windows.CloseHandle(windows.Handle(h))
+		return nil, nil, errSegmentInUse
+	}
+	return mapView(windows.Handle(h), size)
+}
+
+// attachSegment は作ってある key のセグメントを割り当てる
+func attachSegment(key uint32) ([]byte, func() error, error) {
+	name, err := windows.UTF16PtrFromString(segmentName(key))
+	if err != nil {
+		return nil, nil, err
+	}
+	h, _, errno := procOpenFileMappingW.Call(windows.FILE_MAP_READ|windows.FILE_MAP_WRITE, 0, uintptr(unsafe.Pointer(name)))
+	if h == 0 {
+		return nil, nil, errno
+	}
+	return mapView(windows.Handle(h), 0)
+}
+
+// mapView はマッピングを割り当てる (MapViewOfFileEx 相当)。size が 0 ならマッピング全体を割り当て、
+// 大きさはセグメントの先頭の情報から読む。
+func mapView(h windows.Handle, size int) ([]byte, func() error, error) {
+	addr, err := windows.MapViewOfFile(h, windows.FILE_MAP_READ|windows.FILE_MAP_WRITE, 0, 0, uintptr(size))
+	if err != nil {
+		windows.CloseHandle(h)
+		return nil, nil, fmt.Errorf("could not create shared memory segment: %w", err)
+	}
+	if size == 0 {
+		hdr := viewSlice(addr, int(unsafe.Sizeof(shmemHeader{})))
+		size = int((*shmemHeader)(unsafe.Pointer(&hdr[0])).totalSize)
+	}
+	data := viewSlice(addr, size)
+	return data, func() error {
+		err := windows.UnmapViewOfFile(addr)
+		windows.CloseHandle(h)
+		return err
+	}, nil
+}
+
+// viewSlice は Go が管理しない addr からの size バイトをスライスにする
+func viewSlice(addr uintptr, size int) []byte {
+	var data []byte
+	h := (*struct {
+		data     uintptr
+		len, cap int
+	})(unsafe.Pointer(&data))
+	h.data, h.len, h.cap = addr, size, size
+	return data
+}
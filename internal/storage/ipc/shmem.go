@@ -0,0 +1,256 @@
+package ipc
+
+import (
+	"bytes"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 共有メモリの割り当て (storage/ipc/shmem.c 相当)
+// ----------------------------------------------------------------
+// 共有メモリのセグメントは先頭に shmemHeader を置き、その後ろを前から順に切り出して割り当てる。
+// 割り当てたものは解放しない。名前を付けた割り当ては ShmemIndex に記録し、同じ名前で
+// ShmemInitStruct を呼んだプロセスは同じ領域を受け取る。最初に作ったプロセスだけが found を
+// false として受け取り、中身を初期化する。
+//
+// ShmemIndex もセグメントの中に置き、位置は全てセグメントの先頭からのオフセットで持つ。
+// そのため、バックエンドが別のプロセスになり、別のアドレスにセグメントを割り当てても同じ
+// 割り当てを参照できる。共有メモリに置く構造体には Go のポインター (ポインター、スライス、
+// 文字列、マップ、チャネル、インターフェース) を含めてはならない。ガベージコレクターは共有
+// メモリの中を調べず、他のプロセスからは意味を持たないため。
+//
+// 割り当てと ShmemIndex は、セグメントの中のスピンロックで守る (ShmemLock と ShmemIndexLock 相当)。
+// 別のプロセスとも同じロックを使えるよう、sync.Mutex ではなく共有メモリの中の語を不可分に操作する。
+
+// pgShmemMagic はセグメントの先頭に書く値 (PGShmemMagic 相当)
+const pgShmemMagic = 679834894
+
+// cacheLineSize は割り当ての境界 (PG_CACHE_LINE_SIZE 相当)。別の割り当てが同じキャッシュラインを
+// 共有しないようにする。
+const cacheLineSize = 128
+
+const (
+	// shmemIndexKeysize は ShmemIndex の名前の長さの上限 (終端を含む) (SHMEM_INDEX_KEYSIZE 相当)
+	shmemIndexKeysize = 48
+	// shmemIndexSize は ShmemIndex に記録できる割り当ての数 (SHMEM_INDEX_SIZE 相当)
+	shmemIndexSize = 64
+)
+
+// shmemHeader はセグメントの先頭に置く情報 (PGShmemHeader 相当)
+type shmemHeader struct {
+	magic      uint32
+	creatorPID int32
+	totalSize  int64
+	// freeOffset はまだ割り当てていない部分の先頭 (freeoffset 相当)
+	freeOffset int64
+	// indexOffset は ShmemIndex の位置。0 ならまだ作っていない
+	indexOffset int64
+	// lock は freeOffset を守る (ShmemLock 相当)
+	lock uint32
+	// indexLock は ShmemIndex を守る (ShmemIndexLock 相当)
+	indexLock uint32
+}
+
+// shmemIndexEnt は ShmemIndex の1つの割り当て (ShmemIndexEnt 相当)
+type shmemIndexEnt struct {
+	key [shmemIndexKeysize]byte
+	// location は割り当ての位置 (セグメントの先頭からのオフセット)
+	location int64
+	// size は要求された大きさ、allocatedSize は境界に揃えて実際に割り当てた大きさ
+	size          int64
+	allocatedSize int64
+}
+
+// shmemIndex は ShmemIndex。C言語版はハッシュ表だが、数が少ないため固定の長さの配列にする
+type shmemIndex struct {
+	n       int64
+	entries [shmemIndexSize]shmemIndexEnt
+}
+
+// Segment は割り当てた共有メモリのセグメント
+type Segment struct {
+	key  uint32
+	data []byte
+	hdr  *shmemHeader
+	// release はセグメントの割り当てを解き、作ったプロセスならセグメントを削除する
+	release func() error
+}
+
+// newSegment は割り当てたセグメントの先頭の情報を読めるようにする (InitShmemAccess 相当)
+func newSegment(key uint32, data []byte, release func() error) *Segment {
+	return &Segment{key: key, data: data, hdr: (*shmemHeader)(unsafe.Pointer(&data[0])), release: release}
+}
+
+// initShmemAllocation は作ったばかりのセグメントの先頭の情報と ShmemIndex を初期化する
+// (InitShmemAllocation と InitShmemIndex 相当)
+func (s *Segment) initShmemAllocation(creatorPID int32) {
+	hdr := s.hdr
+	*hdr = shmemHeader{
+		creatorPID: creatorPID,
+		totalSize:  int64(len(s.data)),
+		freeOffset: cacheLineAlign(int64(unsafe.Sizeof(shmemHeader{}))),
+	}
+	// ShmemIndex も名前なしの割り当てとして切り出す
+	off, _, ok := s.allocRaw(int64(unsafe.Sizeof(shmemIndex{})))
+	if !ok {
+		panic("shared memory segment is too small for ShmemIndex")
+	}
+	clear(s.data[off : off+int64(unsafe.Sizeof(shmemIndex{}))])
+	hdr.indexOffset = off
+	// 初期化を終えてから、他のプロセスが使えるセグメントにする
+	atomic.StoreUint32(&hdr.magic, pgShmemMagic)
+}
+
+// Key はセグメントのキー
+func (s *Segment) Key() uint32 { return s.key }
+
+// Size はセグメントの大きさ
+func (s *Segment) Size() int64 { return s.hdr.totalSize }
+
+// Detach はセグメントの割り当てを解く (PGSharedMemoryDetach 相当)。作ったプロセスでは
+// セグメントも削除する。
+func (s *Segment) Detach() error {
+	if s.release == nil {
+		return nil
+	}
+	release := s.release
+	s.release = nil
+	s.hdr = nil
+	s.data = nil
+	return release()
+}
+
+// ShmemAlloc は名前を付けずに size バイトを割り当てる (ShmemAlloc 相当)。足りなければ ERROR を返す。
+func (s *Segment) ShmemAlloc(size int) ([]byte, error) {
+	off, _, ok := s.allocRaw(int64(size))
+	if !ok {
+		return nil, errutil.New(errutil.Error, errcodes.OutOfMemory, "out of shared memory (%d bytes requested)", size)
+	}
+	return s.data[off : off+int64(size) : off+int64(size)], nil
+}
+
+// allocRaw は size を境界に揃えて切り出し、位置と実際に割り当てた大きさを返す (ShmemAllocRaw 相当)
+func (s *Segment) allocRaw(size int64) (off, allocated int64, ok bool) {
+	allocated = cacheLineAlign(size)
+	spinLockAcquire(&s.hdr.lock)
+	defer spinLockRelease(&s.hdr.lock)
+	off = s.hdr.freeOffset
+	if off+allocated > s.hdr.totalSize {
+		return 0, 0, false
+	}
+	s.hdr.freeOffset = off + allocated
+	return off, allocated, true
+}
+
+// ShmemInitStruct は name の名前で size バイトの領域を探し、なければ割り当てる (ShmemInitStruct 相当)。
+// found は既に割り当ててあったかを表し、false なら呼び出し側が中身を初期化する。
+func (s *Segment) ShmemInitStruct(name string, size int) (b []byte, found bool, err error) {
+	spinLockAcquire(&s.hdr.indexLock)
+	defer spinLockRelease(&s.hdr.indexLock)
+	index := s.index()
+	key := indexKey(name)
+	for i := range index.n {
+		ent := &index.entries[i]
+		if ent.key != key {
+			continue
+		}
+		if ent.size != int64(size) {
+			return nil, false, errutil.New(errutil.Error, errcodes.InternalError,
+				"ShmemIndex entry size is wrong for data structure \"%s\": expected %d, actual %d", name, size, ent.size)
+		}
+		return s.data[ent.location : ent.location+ent.size : ent.location+ent.size], true, nil
+	}
+	if index.n >= shmemIndexSize {
+		return nil, false, errutil.New(errutil.Error, errcodes.OutOfMemory,
+			"could not create ShmemIndex entry for data structure \"%s\"", name)
+	}
+	off, allocated, ok := s.allocRaw(int64(size))
+	if !ok {
+		return nil, false, errutil.New(errutil.Error, errcodes.OutOfMemory,
+			"not enough shared memory for data structure \"%s\" (%d bytes requested)", name, size)
+	}
+	index.entries[index.n] = shmemIndexEnt{key: key, location: off, size: int64(size), allocatedSize: allocated}
+	index.n++
+	return s.data[off : off+int64(size) : off+int64(size)], false, nil
+}
+
+// index は ShmemIndex を返す
+func (s *Segment) index() *shmemIndex {
+	return (*shmemIndex)(unsafe.Pointer(&s.data[s.hdr.indexOffset]))
+}
+
+// ShmemInitStructOf は T の大きさで ShmemInitStruct を呼び、領域を *T として返す。T には Go の
+// ポインターを含めてはならない。
+func ShmemInitStructOf[T any](s *Segment, name string) (*T, bool, error) {
+	var zero T
+	b, found, err := s.ShmemInitStruct(name, int(unsafe.Sizeof(zero)))
+	if err != nil {
+		return nil, false, err
+	}
+	return (*T)(unsafe.Pointer(unsafe.SliceData(b))), found, nil
+}
+
+// Allocation は pg_shmem_allocations の1行。名前のない割り当ては Name を "<anonymous>" とし、
+// まだ割り当てていない部分は Free を真にする。
+type Allocation struct {
+	Name          string
+	Free          bool
+	Off           int64
+	Size          int64
+	AllocatedSize int64
+}
+
+// Allocations はセグメントの割り当ての一覧を返す (pg_get_shmem_allocations 相当)。名前を付けた
+// 割り当て、名前のない割り当ての合計、まだ割り当てていない部分の順に並べる。
+func (s *Segment) Allocations() []Allocation {
+	spinLockAcquire(&s.hdr.indexLock)
+	index := s.index()
+	rows := make([]Allocation, 0, index.n+2)
+	var named int64
+	for i := range index.n {
+		ent := &index.entries[i]
+		name, _, _ := bytes.Cut(ent.key[:], []byte{0})
+		rows = append(rows, Allocation{Name: string(name), Off: ent.location, Size: ent.size, AllocatedSize: ent.allocatedSize})
+		named += ent.allocatedSize
+	}
+	spinLockRelease(&s.hdr.indexLock)
+
+	spinLockAcquire(&s.hdr.lock)
+	freeOffset := s.hdr.freeOffset
+	spinLockRelease(&s.hdr.lock)
+	// 先頭の情報も名前のない割り当てに含める
+	anonymous := freeOffset - named
+	rows = append(rows, Allocation{Name: "<anonymous>", Off: -1, Size: anonymous, AllocatedSize: anonymous})
+	free := s.hdr.totalSize - freeOffset
+	rows = append(rows, Allocation{Free: true, Off: freeOffset, Size: free, AllocatedSize: free})
+	return rows
+}
+
+// indexKey は ShmemIndex に記録する名前。長すぎる名前は切り詰める (strlcpy 相当)
+func indexKey(name string) [shmemIndexKeysize]byte {
+	var key [shmemIndexKeysize]byte
+	copy(key[:shmemIndexKeysize-1], name)
+	return key
+}
+
+// cacheLineAlign は size を cacheLineSize の倍数に切り上げる (CACHELINEALIGN 相当)
+func cacheLineAlign(size int64) int64 {
+	return (size + cacheLineSize - 1) &^ (cacheLineSize - 1)
+}
+
+// spinLockAcquire は共有メモリの中のスピンロックを取る (SpinLockAcquire 相当)
+func spinLockAcquire(lock *uint32) {
+	for !atomic.CompareAndSwapUint32(lock, 0, 1) {
+		runtime.Gosched()
+	}
+}
+
+// spinLockRelease はスピンロックを放す (SpinLockRelease 相当)
+func spinLockRelease(lock *uint32) {
+	atomic.StoreUint32(lock, 0)
+}
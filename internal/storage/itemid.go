@@ -0,0 +1,82 @@
+package storage
+
+import "github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+
+// ----------------------------------------------------------------
+// ページの中の項目の位置 (storage/off.h, storage/itemid.h 相当)
+// ----------------------------------------------------------------
+// ページの項目は、ページの先頭のヘッダの後ろに並ぶ行ポインタ (ItemID) で指す。行ポインタは
+// 1 から数えたオフセット番号 (OffsetNumber) で引き、項目のページの中での位置と長さ、状態を持つ。
+// 項目を動かしても行ポインタの番号は変わらないため、外からは (ブロック番号, オフセット番号) で
+// 項目を指し続けられる。
+//
+// 行ポインタは 32 ビットで、下位から順に lp_off (15 ビット)、lp_flags (2 ビット)、lp_len
+// (15 ビット) を持つ。C言語版のビットフィールドのリトルエンディアンでの並びと同じにする。
+
+// OffsetNumber はページの中の行ポインタの番号 (OffsetNumber 相当)。1 から数える。
+type OffsetNumber uint16
+
+const (
+	// InvalidOffsetNumber は行ポインタを指さないことを表す (InvalidOffsetNumber 相当)
+	InvalidOffsetNumber OffsetNumber = 0
+	// FirstOffsetNumber は最初の行ポインタの番号 (FirstOffsetNumber 相当)
+	FirstOffsetNumber OffsetNumber = 1
+	// MaxOffsetNumber はページに置ける行ポインタの番号の上限 (MaxOffsetNumber 相当)
+	MaxOffsetNumber OffsetNumber = pgconfig.BlckSz / SizeOfItemID
+)
+
+// IsValid はオフセット番号が 1 から MaxOffsetNumber の間にあるかを返す (OffsetNumberIsValid 相当)
+func (off OffsetNumber) IsValid() bool {
+	return off != InvalidOffsetNumber && off <= MaxOffsetNumber
+}
+
+// SizeOfItemID は行ポインタの大きさ (sizeof(ItemIdData) 相当)
+const SizeOfItemID = 4
+
+// 行ポインタの状態 (LP_* 相当)
+const (
+	// LpUnused は使っていない行ポインタ。lp_len は常に 0
+	LpUnused = 0
+	// LpNormal は使っている行ポインタ。lp_len は常に正
+	LpNormal = 1
+	// LpRedirect は HOT の更新の鎖で別の行ポインタに転送する。lp_off は転送先のオフセット番号で、
+	// lp_len は 0
+	LpRedirect = 2
+	// LpDead は削除された項目。領域を持つ場合と持たない場合がある
+	LpDead = 3
+)
+
+// ItemID は行ポインタ1つ (ItemIdData 相当)
+type ItemID uint32
+
+// MakeItemID は状態 flags、ページの中の位置 off、長さ length の行ポインタを作る
+func MakeItemID(flags int, off, length int) ItemID {
+	return ItemID(uint32(off)&0x7FFF | uint32(flags)&0x3<<15 | uint32(length)&0x7FFF<<17)
+}
+
+// Off は項目のページの先頭からの位置を返す (ItemIdGetOffset 相当)
+func (id ItemID) Off() int { return int(id & 0x7FFF) }
+
+// Flags は行ポインタの状態を返す (ItemIdGetFlags 相当)
+func (id ItemID) Flags() int { return int(id >> 15 & 0x3) }
+
+// Len は項目の長さを返す (ItemIdGetLength 相当)
+func (id ItemID) Len() int { return int(id >> 17 & 0x7FFF) }
+
+// RedirectTo は転送先のオフセット番号を返す (ItemIdGetRedirect 相当)
+func (id ItemID) RedirectTo() OffsetNumber { return OffsetNumber(id.Off()) }
+
+// IsUsed は行ポインタが使われているかを返す (ItemIdIsUsed 相当)
+func (id ItemID) IsUsed() bool { return id.Flags() != LpUnused }
+
+// IsNormal は行ポインタが項目を指しているかを返す (ItemIdIsNormal 相当)
+func (id ItemID) IsNormal() bool { return id.Flags() == LpNormal }
+
+// IsRedirected は行ポインタが転送しているかを返す (ItemIdIsRedirected 相当)
+func (id ItemID) IsRedirected() bool { return id.Flags() == LpRedirect }
+
+// IsDead は行ポインタの項目が削除されたかを返す (ItemIdIsDead 相当)
+func (id ItemID) IsDead() bool { return id.Flags() == LpDead }
+
+// HasStorage は行ポインタがページの領域を指しているかを返す (ItemIdHasStorage 相当)
+func (id ItemID) HasStorage() bool { return id.Len() != 0 }
@@ -0,0 +1,46 @@
+package storage
+
+import "fmt"
+
+// ----------------------------------------------------------------
+// タプルの位置 (storage/itemptr.h 相当)
+// ----------------------------------------------------------------
+// タプルはブロック番号と行ポインタのオフセット番号の組 (TID) で指す。タプルヘッダの t_ctid には
+// 6 バイトで書き、ブロック番号は上位と下位の 16 ビットずつに分けて置く (BlockIdData 相当)。
+
+// ItemPointer はタプルの位置 (ItemPointerData 相当)
+type ItemPointer struct {
+	Block  BlockNumber
+	Offset OffsetNumber
+}
+
+// SizeOfItemPointer はタプルヘッダの中の ItemPointer の大きさ (sizeof(ItemPointerData) 相当)
+const SizeOfItemPointer = 6
+
+// InvalidItemPointer はタプルを指さないことを表す (ItemPointerSetInvalid 相当)
+var InvalidItemPointer = ItemPointer{Block: InvalidBlockNumber, Offset: InvalidOffsetNumber}
+
+// IsValid はタプルを指しているかを返す (ItemPointerIsValid 相当)
+func (p ItemPointer) IsValid() bool {
+	return p.Offset != InvalidOffsetNumber
+}
+
+// String は "(ブロック番号,オフセット番号)" の形式で返す。tid 型の出力と同じ形式
+func (p ItemPointer) String() string {
+	return fmt.Sprintf("(%d,%d)", p.Block, p.Offset)
+}
+
+// GetItemPointer は b の先頭の 6 バイトから ItemPointer を読む
+func GetItemPointer(b []byte) ItemPointer {
+	hi := uint32(b[0]) | uint32(b[1])<<8
+	lo := uint32(b[2]) | uint32(b[3])<<8
+	return ItemPointer{Block: BlockNumber(hi<<16 | lo), Offset: OffsetNumber(uint16(b[4]) | uint16(b[5])<<8)}
+}
+
+// PutItemPointer は p を b の先頭の 6 バイトに書く
+func PutItemPointer(b []byte, p ItemPointer) {
+	hi, lo := uint32(p.Block)>>16, uint32(p.Block)&0xFFFF
+	b[0], b[1] = byte(hi), byte(hi>>8)
+	b[2], b[3] = byte(lo), byte(lo>>8)
+	b[4], b[5] = byte(p.Offset), byte(p.Offset>>8)
+}
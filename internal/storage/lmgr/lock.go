@@ -0,0 +1,583 @@
+package lmgr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/miscadmin"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// 重いロック (storage/lmgr/lock.c, proc.c の ProcSleep 相当)
+// ----------------------------------------------------------------
+// ロックの対象を LockTag で表し、全てのバックエンドで共有するロック表で、対象ごとに
+// 取得しているロックのモードと、待っているバックエンドの列を管理する。取得できないロックは
+// 先に待ち始めたものから順に与える。ロック表は対象のハッシュ値で numLockPartitions 個に分け、
+// それぞれを LockManager の軽量ロックで守るため、別の区画の対象を扱うバックエンドは互いを待たない。
+//
+// 待っている間は pg_stat_activity に Lock の待機イベントとして表示し、取り消しとセッションの
+// 終了の要求を受け付ける。lock_timeout を超えて待ったらエラーにする。deadlock_timeout を
+// 超えて待ったら、log_lock_waits が on であれば、ロックを持っているバックエンドと待っている
+// バックエンドの PID をログに出す。ログの内容は区画の軽量ロックを共有で取って写し、ロック表を
+// 触らずにログを書く。デッドロックの検出はまだないため、デッドロックは lock_timeout か
+// 取り消しでしか解けない。
+
+// LockMode はロックのモード (LOCKMODE 相当)
+type LockMode int
+
+// ロックのモード (lockdefs.h 相当)
+const (
+	NoLock LockMode = iota
+	AccessShareLock
+	RowShareLock
+	RowExclusiveLock
+	ShareUpdateExclusiveLock
+	ShareLock
+	ShareRowExclusiveLock
+	ExclusiveLock
+	AccessExclusiveLock
+
+	numLockModes
+)
+
+var lockModeNames = [numLockModes]string{
+	"INVALID",
+	"AccessShareLock",
+	"RowShareLock",
+	"RowExclusiveLock",
+	"ShareUpdateExclusiveLock",
+	"ShareLock",
+	"ShareRowExclusiveLock",
+	"ExclusiveLock",
+	"AccessExclusiveLock",
+}
+
+func (m LockMode) String() string { return lockModeNames[m] }
+
+// lockBit はモードを conflictTab のビットにする (LOCKBIT_ON 相当)
+func lockBit(m LockMode) uint16 { return 1 << m }
+
+// conflictTab はモードごとに衝突するモードのビット (LockConflicts 相当)
+var conflictTab = [numLockModes]uint16{
+	NoLock:          0,
+	AccessShareLock: lockBit(AccessExclusiveLock),
+	RowShareLock:    lockBit(ExclusiveLock) | lockBit(AccessExclusiveLock),
+	RowExclusiveLock: lockBit(ShareLock) | lockBit(ShareRowExclusiveLock) |
+		lockBit(ExclusiveLock) | lockBit(AccessExclusiveLock),
+	ShareUpdateExclusiveLock: lockBit(ShareUpdateExclusiveLock) | lockBit(ShareLock) | lockBit(ShareRowExclusiveLock) |
+		lockBit(ExclusiveLock) | lockBit(AccessExclusiveLock),
+	ShareLock: lockBit(RowExclusiveLock) | lockBit(ShareUpdateExclusiveLock) |
+		lockBit(ShareRowExclusiveLock) | lockBit(ExclusiveLock) | lockBit(AccessExclusiveLock),
+	ShareRowExclusiveLock: lockBit(RowExclusiveLock) | lockBit(ShareUpdateExclusiveLock) |
+		lockBit(ShareLock) | lockBit(ShareRowExclusiveLock) | lockBit(ExclusiveLock) | lockBit(AccessExclusiveLock),
+	ExclusiveLock: lockBit(RowShareLock) | lockBit(RowExclusiveLock) | lockBit(ShareUpdateExclusiveLock) |
+		lockBit(ShareLock) | lockBit(ShareRowExclusiveLock) | lockBit(ExclusiveLock) | lockBit(AccessExclusiveLock),
+	AccessExclusiveLock: lockBit(AccessShareLock) | lockBit(RowShareLock) | lockBit(RowExclusiveLock) |
+		lockBit(ShareUpdateExclusiveLock) | lockBit(ShareLock) | lockBit(ShareRowExclusiveLock) |
+		lockBit(ExclusiveLock) | lockBit(AccessExclusiveLock),
+}
+
+// LockTagType はロックの対象の種類 (LockTagType 相当)。並びは waitevent の Lock の待機イベントと
+// 同じにする。
+type LockTagType uint8
+
+const (
+	LockTagRelation LockTagType = iota
+	LockTagRelationExtend
+	LockTagDatabaseFrozenIds
+	LockTagPage
+	LockTagTuple
+	LockTagTransaction
+	LockTagVirtualTransaction
+	LockTagSpeculativeToken
+	LockTagObject
+	LockTagUserlock
+	LockTagAdvisory
+	LockTagApplyTransaction
+)
+
+// LockTag はロックの対象 (LOCKTAG 相当)。フィールドの意味は種類ごとに異なる。
+type LockTag struct {
+	Field1 uint32
+	Field2 uint32
+	Field3 uint32
+	Field4 uint16
+	Type   LockTagType
+}
+
+// waitEventInfo は対象のロックを待つ間の待機イベントを返す (PG_WAIT_LOCK | locktag_type 相当)
+func (t LockTag) waitEventInfo() waitevent.Info {
+	return waitevent.LockRelation + waitevent.Info(t.Type)
+}
+
+// String はログに出すための対象の説明を返す (DescribeLockTag 相当)
+func (t LockTag) String() string {
+	switch t.Type {
+	case LockTagRelation:
+		return fmt.Sprintf("relation %d of database %d", t.Field2, t.Field1)
+	case LockTagRelationExtend:
+		return fmt.Sprintf("extension of relation %d of database %d", t.Field2, t.Field1)
+	case LockTagDatabaseFrozenIds:
+		return fmt.Sprintf("pg_database.datfrozenxid of database %d", t.Field1)
+	case LockTagPage:
+		return fmt.Sprintf("page %d of relation %d of database %d", t.Field3, t.Field2, t.Field1)
+	case LockTagTuple:
+		return fmt.Sprintf("tuple (%d,%d) of relation %d of database %d", t.Field3, t.Field4, t.Field2, t.Field1)
+	case LockTagTransaction:
+		return fmt.Sprintf("transaction %d", t.Field1)
+	case LockTagVirtualTransaction:
+		return fmt.Sprintf("virtual transaction %d/%d", t.Field1, t.Field2)
+	case LockTagSpeculativeToken:
+		return fmt.Sprintf("speculative token %d of transaction %d", t.Field2, t.Field1)
+	case LockTagObject:
+		return fmt.Sprintf("object %d of class %d of database %d", t.Field3, t.Field2, t.Field1)
+	case LockTagUserlock:
+		return fmt.Sprintf("user lock [%d,%d,%d]", t.Field1, t.Field2, t.Field3)
+	case LockTagAdvisory:
+		return fmt.Sprintf("advisory lock [%d,%d,%d,%d]", t.Field1, t.Field2, t.Field3, t.Field4)
+	case LockTagApplyTransaction:
+		return fmt.Sprintf("remote transaction %d of subscription %d of database %d", t.Field3, t.Field2, t.Field1)
+	}
+	return fmt.Sprintf("unrecognized locktag type %d", t.Type)
+}
+
+// Error は SQLSTATE 付きのロックのエラー。バックエンドはクライアントにそのまま報告する。
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// errLockTimeout は lock_timeout を超えて待ったことを表す
+var errLockTimeout = &Error{Code: errcodes.LockNotAvailable, Message: "canceling statement due to lock timeout"}
+
+// Proc はロックを取るバックエンド1つ (PGPROC のロックの部分相当)。バックエンドごとに NewProc で作り、
+// そのバックエンドのゴルーチンだけが使う。
+type Proc struct {
+	pid        int32
+	interrupts *miscadmin.Interrupts
+	waitEvent  *waitevent.Slot
+	gucs       *guc.Session
+	// logProc はロックを待つログに付けるバックエンドの情報
+	logProc *errutil.ProcInfo
+	// held はこのバックエンドが取ったロック (LOCALLOCK 相当)。同じロックを何度取ったかを数える
+	held map[localLockKey]*localLock
+	// heldLWLocks はこのバックエンドが取った軽量ロック (held_lwlocks 相当)。取った順に並べる
+	heldLWLocks []heldLWLock
+	// lwWait は軽量ロックを待つ状態
+	lwWait lwWaiter
+}
+
+// localLockKey は取ったロックを引くための鍵 (LOCALLOCKTAG 相当)
+type localLockKey struct {
+	tag  LockTag
+	mode LockMode
+}
+
+// localLock はこのバックエンドが取ったロック1つ分の回数 (LOCALLOCK 相当)。トランザクションの
+// ロックとセッションのロックは別に数え、両方が 0 になったらロック表のロックを放す。
+type localLock struct {
+	xactCount    int
+	sessionCount int
+}
+
+// NewProc はバックエンドのロックの状態を作る。待つ間は waitEvent に記録し、interrupts の要求で
+// 待ちを中断する。lock_timeout などはセッションの値を gucs から読み、gucs が nil なら
+// サーバー全体の値を使う。ロックを待つログは logProc のバックエンドのメッセージとして出す。
+func NewProc(pid int32, interrupts *miscadmin.Interrupts, waitEvent *waitevent.Slot, gucs *guc.Session, logProc *errutil.ProcInfo) *Proc {
+	return &Proc{pid: pid, interrupts: interrupts, waitEvent: waitEvent, gucs: gucs, logProc: logProc,
+		held: make(map[localLockKey]*localLock), lwWait: lwWaiter{sem: make(chan struct{}, 1)}}
+}
+
+func (p *Proc) getInt(c *guc.ConfigInt) int {
+	if p.gucs == nil {
+		return c.Get()
+	}
+	return p.gucs.GetInt(c)
+}
+
+func (p *Proc) getBool(c *guc.ConfigBool) bool {
+	if p.gucs == nil {
+		return c.Get()
+	}
+	return p.gucs.GetBool(c)
+}
+
+// lock はロック表の対象1つ (LOCK と PROCLOCK 相当)
+type lock struct {
+	// granted はバックエンドごとに与えたモードの数
+	granted map[*Proc]*[numLockModes]int
+	// grantMask は誰かに与えているモードのビット
+	grantMask uint16
+	// waiters はロックを待っているバックエンドの列 (waitProcs 相当)
+	waiters []*waiter
+}
+
+// waiter はロックを待っているバックエンド1つ
+type waiter struct {
+	proc  *Proc
+	mode  LockMode
+	ready chan struct{}
+}
+
+// numLockPartitions はロック表の区画の数 (NUM_LOCK_PARTITIONS 相当)
+const numLockPartitions = 16
+
+// lockPartition はロック表の区画1つ。locks は lock を取って触る
+type lockPartition struct {
+	lock  LWLock
+	locks map[LockTag]*lock
+}
+
+// lockTable は全てのバックエンドで共有するロック表 (LockMethodLockHash 相当)
+var lockTable [numLockPartitions]lockPartition
+
+func init() {
+	for i := range lockTable {
+		LWLockInitialize(&lockTable[i].lock, waitevent.LWLockLockManager)
+		lockTable[i].locks = make(map[LockTag]*lock)
+	}
+}
+
+// hashCode は対象のハッシュ値を返す (LockTagHashCode 相当)
+func (t LockTag) hashCode() uint32 {
+	h := uint32(2166136261)
+	for _, v := range [...]uint32{t.Field1, t.Field2, t.Field3, uint32(t.Field4), uint32(t.Type)} {
+		h = (h ^ v) * 16777619
+	}
+	return h
+}
+
+// lockHashPartition は対象を管理する区画を返す (LockHashPartition 相当)
+func lockHashPartition(tag LockTag) *lockPartition {
+	return &lockTable[tag.hashCode()%numLockPartitions]
+}
+
+// conflictsWithOthers は proc が mode を取ると、他のバックエンドに与えたロックと衝突するかを返す
+// (LockCheckConflicts 相当)
+func (l *lock) conflictsWithOthers(proc *Proc, mode LockMode) bool {
+	conflict := conflictTab[mode]
+	if l.grantMask&conflict == 0 {
+		return false
+	}
+	for p, counts := range l.granted {
+		if p == proc {
+			continue
+		}
+		for m := LockMode(1); m < numLockModes; m++ {
+			if counts[m] > 0 && conflict&lockBit(m) != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// conflictsWithWaiters は先に待ち始めたバックエンドの最初の n 人の要求と衝突するかを返す
+func (l *lock) conflictsWithWaiters(proc *Proc, mode LockMode, n int) bool {
+	for _, w := range l.waiters[:n] {
+		if w.proc != proc && conflictTab[mode]&lockBit(w.mode) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// grant は proc に mode を与える (GrantLock 相当)
+func (l *lock) grant(proc *Proc, mode LockMode) {
+	counts := l.granted[proc]
+	if counts == nil {
+		counts = new([numLockModes]int)
+		l.granted[proc] = counts
+	}
+	counts[mode]++
+	l.grantMask |= lockBit(mode)
+}
+
+// ungrant は proc に与えた mode を1つ戻す (UnGrantLock 相当)
+func (l *lock) ungrant(proc *Proc, mode LockMode) {
+	counts := l.granted[proc]
+	counts[mode]--
+	empty := true
+	for m := LockMode(1); m < numLockModes; m++ {
+		if counts[m] > 0 {
+			empty = false
+		}
+	}
+	if empty {
+		delete(l.granted, proc)
+	}
+	l.grantMask = 0
+	for _, c := range l.granted {
+		for m := LockMode(1); m < numLockModes; m++ {
+			if c[m] > 0 {
+				l.grantMask |= lockBit(m)
+			}
+		}
+	}
+}
+
+// wakeWaiters は与えられるようになった待ちを先頭から順に起こす (ProcLockWakeup 相当)。
+// 衝突する待ちより後ろの待ちも、衝突しなければ起こす。
+func (l *lock) wakeWaiters() {
+	for i := 0; i < len(l.waiters); {
+		w := l.waiters[i]
+		if l.conflictsWithOthers(w.proc, w.mode) || l.conflictsWithWaiters(w.proc, w.mode, i) {
+			i++
+			continue
+		}
+		l.grant(w.proc, w.mode)
+		l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+		close(w.ready)
+	}
+}
+
+// removeWaiter は w を待ちの列から除く。与えた後であれば false を返す
+func (l *lock) removeWaiter(w *waiter) bool {
+	for i, x := range l.waiters {
+		if x == w {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// LockAcquire は tag のロックを mode で取る (LockAcquire 相当)。sessionLock が true なら
+// トランザクションの終わりに放さないセッションのロックにする。dontWait が true なら、すぐに
+// 取れない場合に待たずに false を返す。待つ間に取り消しや lock_timeout でエラーになったら
+// ロックは取らない。
+func (p *Proc) LockAcquire(tag LockTag, mode LockMode, sessionLock, dontWait bool) (bool, error) {
+	key := localLockKey{tag: tag, mode: mode}
+	if ll := p.held[key]; ll != nil {
+		// 既に取っているロックは回数を数えるだけでよい
+		ll.count(sessionLock, 1)
+		return true, nil
+	}
+
+	part := lockHashPartition(tag)
+	p.LWLockAcquire(&part.lock, LWExclusive)
+	l := part.locks[tag]
+	if l == nil {
+		l = &lock{granted: make(map[*Proc]*[numLockModes]int)}
+		part.locks[tag] = l
+	}
+	if !l.conflictsWithOthers(p, mode) && !l.conflictsWithWaiters(p, mode, len(l.waiters)) {
+		l.grant(p, mode)
+		p.LWLockRelease(&part.lock)
+		p.remember(key, sessionLock)
+		return true, nil
+	}
+	if dontWait {
+		part.forgetIfUnused(tag, l)
+		p.LWLockRelease(&part.lock)
+		return false, nil
+	}
+	w := &waiter{proc: p, mode: mode, ready: make(chan struct{})}
+	l.waiters = append(l.waiters, w)
+	p.LWLockRelease(&part.lock)
+
+	if err := p.sleep(tag, part, l, w); err != nil {
+		return false, err
+	}
+	p.remember(key, sessionLock)
+	return true, nil
+}
+
+// forgetIfUnused は誰も使っていない対象を区画から除く。区画の軽量ロックを排他で取って呼ぶ
+func (part *lockPartition) forgetIfUnused(tag LockTag, l *lock) {
+	if len(l.granted) == 0 && len(l.waiters) == 0 {
+		delete(part.locks, tag)
+	}
+}
+
+// remember は取ったロックを記録する
+func (p *Proc) remember(key localLockKey, sessionLock bool) {
+	ll := &localLock{}
+	ll.count(sessionLock, 1)
+	p.held[key] = ll
+}
+
+func (ll *localLock) count(sessionLock bool, n int) {
+	if sessionLock {
+		ll.sessionCount += n
+	} else {
+		ll.xactCount += n
+	}
+}
+
+// sleep はロックが与えられるまで待つ (ProcSleep 相当)。待ちの列に w を加えてから呼ぶ。
+func (p *Proc) sleep(tag LockTag, part *lockPartition, l *lock, w *waiter) error {
+	start := time.Now()
+	p.waitEvent.Start(tag.waitEventInfo())
+	defer p.waitEvent.End()
+
+	var deadlockTimer, lockTimer <-chan time.Time
+	deadlockTimeout := time.Duration(p.getInt(guc.DeadlockTimeout)) * time.Millisecond
+	t := time.NewTimer(deadlockTimeout)
+	defer t.Stop()
+	deadlockTimer = t.C
+	if ms := p.getInt(guc.LockTimeout); ms > 0 {
+		lt := time.NewTimer(time.Duration(ms) * time.Millisecond)
+		defer lt.Stop()
+		lockTimer = lt.C
+	}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	logged := false
+	for {
+		var err error
+		select {
+		case <-w.ready:
+			if logged {
+				p.log(errutil.Elog(errutil.Log, "process %d acquired %s on %s after %s ms",
+					p.pid, w.mode, tag, elapsedMs(start)))
+			}
+			return nil
+		case <-deadlockTimer:
+			deadlockTimer = nil
+			if p.getBool(guc.LogLockWaits) {
+				p.logLockWait(tag, part, l, w, start)
+				logged = true
+			}
+			continue
+		case <-lockTimer:
+			err = errLockTimeout
+		case <-ticker.C:
+			err = p.interrupts.CheckForInterrupts()
+		}
+		if err == nil {
+			continue
+		}
+		p.LWLockAcquire(&part.lock, LWExclusive)
+		if !l.removeWaiter(w) {
+			// エラーにすると決める前に与えられていたら、放してから戻る
+			l.ungrant(p, w.mode)
+		}
+		l.wakeWaiters()
+		part.forgetIfUnused(tag, l)
+		p.LWLockRelease(&part.lock)
+		return err
+	}
+}
+
+// logLockWait は deadlock_timeout を超えて待っているロックをログに出す (ProcSleep の
+// log_lock_waits の処理相当)
+func (p *Proc) logLockWait(tag LockTag, part *lockPartition, l *lock, w *waiter, start time.Time) {
+	p.LWLockAcquire(&part.lock, LWShared)
+	var holders, queue []int32
+	for proc, counts := range l.granted {
+		if proc == p {
+			continue
+		}
+		for m := LockMode(1); m < numLockModes; m++ {
+			if counts[m] > 0 && conflictTab[w.mode]&lockBit(m) != 0 {
+				holders = append(holders, proc.pid)
+				break
+			}
+		}
+	}
+	for _, x := range l.waiters {
+		queue = append(queue, x.proc.pid)
+	}
+	p.LWLockRelease(&part.lock)
+
+	sort.Slice(holders, func(i, j int) bool { return holders[i] < holders[j] })
+	holderLabel := "Process holding the lock"
+	if len(holders) != 1 {
+		holderLabel = "Processes holding the lock"
+	}
+	p.log(errutil.Elog(errutil.Log, "process %d still waiting for %s on %s after %s ms",
+		p.pid, w.mode, tag, elapsedMs(start)).
+		WithDetailLog("%s: %s. Wait queue: %s.", holderLabel, joinPids(holders), joinPids(queue)))
+}
+
+// log はセッションの log_min_messages に従ってサーバーログに出す
+func (p *Proc) log(edata *errutil.ErrorData) {
+	_ = errutil.EmitErrorReport(p.logProc, p.gucs, nil, edata, "")
+}
+
+// elapsedMs は start からの経過時間をミリ秒で小数第3位まで表す
+func elapsedMs(start time.Time) string {
+	us := time.Since(start).Microseconds()
+	return fmt.Sprintf("%d.%03d", us/1000, us%1000)
+}
+
+func joinPids(pids []int32) string {
+	s := make([]string, len(pids))
+	for i, pid := range pids {
+		s[i] = fmt.Sprint(pid)
+	}
+	return strings.Join(s, ", ")
+}
+
+// LockRelease は tag の mode のロックを1つ放す (LockRelease 相当)。取っていなければ
+// false を返す。
+func (p *Proc) LockRelease(tag LockTag, mode LockMode, sessionLock bool) bool {
+	key := localLockKey{tag: tag, mode: mode}
+	ll := p.held[key]
+	if ll == nil || (sessionLock && ll.sessionCount == 0) || (!sessionLock && ll.xactCount == 0) {
+		return false
+	}
+	ll.count(sessionLock, -1)
+	if ll.xactCount+ll.sessionCount > 0 {
+		return true
+	}
+	delete(p.held, key)
+	p.releaseShared(tag, mode)
+	return true
+}
+
+// releaseShared はロック表から proc に与えた mode を1つ戻し、待っているバックエンドを起こす
+func (p *Proc) releaseShared(tag LockTag, mode LockMode) {
+	part := lockHashPartition(tag)
+	p.LWLockAcquire(&part.lock, LWExclusive)
+	defer p.LWLockRelease(&part.lock)
+	l := part.locks[tag]
+	l.ungrant(p, mode)
+	l.wakeWaiters()
+	part.forgetIfUnused(tag, l)
+}
+
+// LockReleaseAll はトランザクションのロックを全て放す (LockReleaseAll 相当)。allLocks が true なら
+// セッションのロックも放す。
+func (p *Proc) LockReleaseAll(allLocks bool) {
+	if p == nil {
+		return
+	}
+	for key, ll := range p.held {
+		ll.xactCount = 0
+		if allLocks {
+			ll.sessionCount = 0
+		}
+		if ll.sessionCount > 0 {
+			continue
+		}
+		delete(p.held, key)
+		p.releaseShared(key.tag, key.mode)
+	}
+}
+
+// ReleaseSessionLocks はセッションのロックのうち tagType の種類のものを全て放す
+// (LockReleaseSession 相当)。トランザクションのロックとしても取っている分は残す。
+func (p *Proc) ReleaseSessionLocks(tagType LockTagType) {
+	for key, ll := range p.held {
+		if key.tag.Type != tagType || ll.sessionCount == 0 {
+			continue
+		}
+		ll.sessionCount = 0
+		if ll.xactCount > 0 {
+			continue
+		}
+		delete(p.held, key)
+		p.releaseShared(key.tag, key.mode)
+	}
+}
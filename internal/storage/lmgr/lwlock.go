@@ -0,0 +1,402 @@
+package lmgr
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// 軽量ロック (storage/lmgr/lwlock.c 相当)
+// ----------------------------------------------------------------
+// 共有する構造体を短い間だけ守るロック。共有 (LWShared) と排他 (LWExclusive) のモードがあり、
+// ロックの状態は1つの語を不可分に操作して取るため、競合しなければ待ちの列に触れない。取れなければ
+// 待ちの列に加わり、ロックを放したバックエンドに起こされてから取り直す。起こすのは列の先頭から、
+// 共有の待ちは最初の排他の待ちまでをまとめて、排他の待ちは1つだけにする。
+//
+// 重いロックと違い、デッドロックの検出も取り消しもない。取る順番を決めて使い、持っている間は
+// 待ちを伴う処理をしない。待つ間は、ロックのトランシェを LWLock の待機イベントとして表示する。
+//
+// 取ったロックはバックエンドの Proc に記録し、エラーでトランザクションを中止したときと
+// セッションの終わりに LWLockReleaseAll が残りを全て放す。
+
+// LWLockMode は軽量ロックのモード (LWLockMode 相当)
+type LWLockMode int
+
+const (
+	LWExclusive LWLockMode = iota
+	LWShared
+	// LWWaitUntilFree はロックが空くまで待つだけで、ロックは取らない。LWLockAcquireOrWait と
+	// LWLockWaitForVar が内部で使う
+	LWWaitUntilFree
+)
+
+// ロックの状態の語のビット (LW_FLAG_* と LW_VAL_* 相当)
+const (
+	lwFlagHasWaiters uint32 = 1 << 30
+	lwFlagReleaseOK  uint32 = 1 << 29
+	lwValExclusive   uint32 = 1 << 24
+	lwValShared      uint32 = 1
+	lwLockMask       uint32 = lwValExclusive | (lwValExclusive - 1)
+)
+
+// maxSimulLWLocks は1つのバックエンドが同時に持てる軽量ロックの数 (MAX_SIMUL_LWLOCKS 相当)
+const maxSimulLWLocks = 200
+
+// LWLock は軽量ロック1つ (LWLock 相当)。LWLockInitialize で初期化してから使う。
+type LWLock struct {
+	tranche waitevent.Info
+	state   atomic.Uint32
+	// mu は waiters を守る (LW_FLAG_LOCKED 相当)
+	mu      sync.Mutex
+	waiters []*Proc
+}
+
+// lwWaiter はバックエンドが軽量ロックを待つ状態 (PGPROC の lwWaiting, lwWaitMode, sem 相当)
+type lwWaiter struct {
+	mode LWLockMode
+	// waiting は待ちの列にいることを表す。起こす側が列から除いて false にする
+	waiting bool
+	sem     chan struct{}
+}
+
+// heldLWLock はバックエンドが持っている軽量ロック1つ (LWLockHandle 相当)
+type heldLWLock struct {
+	lock *LWLock
+	mode LWLockMode
+}
+
+// nextTranche は次に LWLockNewTrancheId で割り当てるトランシェ
+var nextTranche atomic.Uint32
+
+func init() {
+	nextTranche.Store(uint32(waitevent.LWLockFirstUserDefined))
+}
+
+// LWLockNewTrancheId は新しいトランシェを割り当てる (LWLockNewTrancheId 相当)。待機イベントの
+// 名前は LWLockRegisterTranche で付ける。
+func LWLockNewTrancheId() waitevent.Info {
+	return waitevent.Info(nextTranche.Add(1) - 1)
+}
+
+// LWLockRegisterTranche はトランシェに待機イベントの名前を付ける (LWLockRegisterTranche 相当)
+func LWLockRegisterTranche(tranche waitevent.Info, name string) {
+	waitevent.RegisterLWLockTranche(tranche, name)
+}
+
+// LWLockInitialize はロックを tranche のロックとして初期化する (LWLockInitialize 相当)
+func LWLockInitialize(lock *LWLock, tranche waitevent.Info) {
+	lock.tranche = tranche
+	lock.state.Store(lwFlagReleaseOK)
+	lock.waiters = nil
+}
+
+// String はロックのトランシェの名前を返す (T_NAME 相当)
+func (lock *LWLock) String() string {
+	return waitevent.GetWaitEventIdentifier(lock.tranche)
+}
+
+// attemptLock はロックを一度だけ取ろうとし、取れなければ true を返す (LWLockAttemptLock 相当)
+func (lock *LWLock) attemptLock(mode LWLockMode) (mustWait bool) {
+	old := lock.state.Load()
+	for {
+		var desired uint32
+		if mode == LWExclusive {
+			if old&lwLockMask != 0 {
+				return true
+			}
+			desired = old + lwValExclusive
+		} else {
+			if old&lwValExclusive != 0 {
+				return true
+			}
+			desired = old + lwValShared
+		}
+		if lock.state.CompareAndSwap(old, desired) {
+			return false
+		}
+		old = lock.state.Load()
+	}
+}
+
+// queueSelf はバックエンドを待ちの列に加える (LWLockQueueSelf 相当)。LWWaitUntilFree は
+// ロックを取らないため、列の先頭に加える。
+func (lock *LWLock) queueSelf(p *Proc, mode LWLockMode) {
+	lock.mu.Lock()
+	if p.lwWait.waiting {
+		panic("queueing for lock while waiting on another one")
+	}
+	lock.state.Or(lwFlagHasWaiters)
+	p.lwWait.waiting = true
+	p.lwWait.mode = mode
+	if mode == LWWaitUntilFree {
+		lock.waiters = append([]*Proc{p}, lock.waiters...)
+	} else {
+		lock.waiters = append(lock.waiters, p)
+	}
+	lock.mu.Unlock()
+}
+
+// dequeueSelf は列に加えた後にロックを取れたバックエンドを列から除く (LWLockDequeueSelf 相当)。
+// 既に他のバックエンドが列から除いていれば、送られてくる起こしを受け取ってから戻る。
+func (lock *LWLock) dequeueSelf(p *Proc) {
+	lock.mu.Lock()
+	onList := p.lwWait.waiting
+	if onList {
+		lock.removeWaiter(p)
+		p.lwWait.waiting = false
+	}
+	if len(lock.waiters) == 0 {
+		lock.state.And(^lwFlagHasWaiters)
+	}
+	lock.mu.Unlock()
+	if !onList {
+		// 起こした側は RELEASE_OK を落としているため、戻しておく
+		lock.state.Or(lwFlagReleaseOK)
+		<-p.lwWait.sem
+	}
+}
+
+// removeWaiter は p を待ちの列から除く。mu を取って呼ぶ
+func (lock *LWLock) removeWaiter(p *Proc) {
+	for i, w := range lock.waiters {
+		if w == p {
+			lock.waiters = append(lock.waiters[:i], lock.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// lwSleep は他のバックエンドに起こされるまで待つ (PGSemaphoreLock の繰り返し相当)
+func (p *Proc) lwSleep(lock *LWLock) {
+	p.waitEvent.Start(lock.tranche)
+	<-p.lwWait.sem
+	p.waitEvent.End()
+}
+
+// LWLockAcquire は mode でロックを取る (LWLockAcquire 相当)。待たずに取れたら true を返す。
+func (p *Proc) LWLockAcquire(lock *LWLock, mode LWLockMode) bool {
+	p.checkLWLockLimit()
+	result := true
+	for {
+		if !lock.attemptLock(mode) {
+			break
+		}
+		// 列に加えてからもう一度試す。加える間にロックが放されると、誰にも起こされないため
+		lock.queueSelf(p, mode)
+		if !lock.attemptLock(mode) {
+			lock.dequeueSelf(p)
+			break
+		}
+		p.lwSleep(lock)
+		// 起こされたら、次に放すバックエンドがまた起こせるようにしてから取り直す
+		lock.state.Or(lwFlagReleaseOK)
+		result = false
+	}
+	p.heldLWLocks = append(p.heldLWLocks, heldLWLock{lock: lock, mode: mode})
+	return result
+}
+
+// LWLockConditionalAcquire は待たずに取れる場合だけロックを取り、取れたかを返す
+// (LWLockConditionalAcquire 相当)
+func (p *Proc) LWLockConditionalAcquire(lock *LWLock, mode LWLockMode) bool {
+	p.checkLWLockLimit()
+	if lock.attemptLock(mode) {
+		return false
+	}
+	p.heldLWLocks = append(p.heldLWLocks, heldLWLock{lock: lock, mode: mode})
+	return true
+}
+
+// LWLockAcquireOrWait はすぐに取れればロックを取って true を返し、取れなければロックが空くまで
+// 待って、取らずに false を返す (LWLockAcquireOrWait 相当)。他のバックエンドが済ませた処理を
+// 繰り返さないために使う。
+func (p *Proc) LWLockAcquireOrWait(lock *LWLock, mode LWLockMode) bool {
+	p.checkLWLockLimit()
+	if lock.attemptLock(mode) {
+		lock.queueSelf(p, LWWaitUntilFree)
+		if lock.attemptLock(mode) {
+			p.lwSleep(lock)
+			return false
+		}
+		lock.dequeueSelf(p)
+	}
+	p.heldLWLocks = append(p.heldLWLocks, heldLWLock{lock: lock, mode: mode})
+	return true
+}
+
+// checkLWLockLimit は同時に持てる数を超えないかを確かめる
+func (p *Proc) checkLWLockLimit() {
+	if len(p.heldLWLocks) >= maxSimulLWLocks {
+		panic("too many LWLocks taken")
+	}
+}
+
+// LWLockRelease はロックを放す (LWLockRelease 相当)。持っていないロックを放すのは誤りとする。
+func (p *Proc) LWLockRelease(lock *LWLock) {
+	i := len(p.heldLWLocks) - 1
+	for ; i >= 0; i-- {
+		if p.heldLWLocks[i].lock == lock {
+			break
+		}
+	}
+	if i < 0 {
+		panic(fmt.Sprintf("lock %s is not held", lock))
+	}
+	mode := p.heldLWLocks[i].mode
+	p.heldLWLocks = append(p.heldLWLocks[:i], p.heldLWLocks[i+1:]...)
+
+	held := lwValShared
+	if mode == LWExclusive {
+		held = lwValExclusive
+	}
+	state := lock.state.Add(^(held - 1))
+	if state&(lwFlagHasWaiters|lwFlagReleaseOK) == lwFlagHasWaiters|lwFlagReleaseOK && state&lwLockMask == 0 {
+		lock.wakeup()
+	}
+}
+
+// wakeup は待ちの列の先頭から起こせるバックエンドを起こす (LWLockWakeup 相当)。排他の待ちを
+// 起こしたら他は起こさず、共有の待ちは次の排他の待ちまでをまとめて起こす。起こしたバックエンドが
+// ロックを取り直すまでは、次に放すバックエンドに起こさせない (RELEASE_OK を落とす)。
+func (lock *LWLock) wakeup() {
+	lock.mu.Lock()
+	releaseOK := true
+	wokeSomebody := false
+	var wake []*Proc
+	remaining := lock.waiters[:0:0]
+	for i, w := range lock.waiters {
+		if wokeSomebody && w.lwWait.mode == LWExclusive {
+			remaining = append(remaining, w)
+			continue
+		}
+		wake = append(wake, w)
+		if w.lwWait.mode != LWWaitUntilFree {
+			releaseOK = false
+			wokeSomebody = true
+		}
+		if w.lwWait.mode == LWExclusive {
+			remaining = append(remaining, lock.waiters[i+1:]...)
+			break
+		}
+	}
+	lock.waiters = remaining
+	if releaseOK {
+		lock.state.Or(lwFlagReleaseOK)
+	} else {
+		lock.state.And(^lwFlagReleaseOK)
+	}
+	if len(lock.waiters) == 0 {
+		lock.state.And(^lwFlagHasWaiters)
+	}
+	for _, w := range wake {
+		w.lwWait.waiting = false
+	}
+	lock.mu.Unlock()
+	for _, w := range wake {
+		w.lwWait.sem <- struct{}{}
+	}
+}
+
+// LWLockReleaseAll は持っている軽量ロックを全て放す (LWLockReleaseAll 相当)。エラーで処理を
+// 中止したときに使う。
+func (p *Proc) LWLockReleaseAll() {
+	if p == nil {
+		return
+	}
+	for len(p.heldLWLocks) > 0 {
+		p.LWLockRelease(p.heldLWLocks[len(p.heldLWLocks)-1].lock)
+	}
+}
+
+// LWLockHeldByMe はこのバックエンドがロックを持っているかを返す (LWLockHeldByMe 相当)
+func (p *Proc) LWLockHeldByMe(lock *LWLock) bool {
+	for _, h := range p.heldLWLocks {
+		if h.lock == lock {
+			return true
+		}
+	}
+	return false
+}
+
+// LWLockHeldByMeInMode はこのバックエンドがロックを mode で持っているかを返す
+// (LWLockHeldByMeInMode 相当)
+func (p *Proc) LWLockHeldByMeInMode(lock *LWLock, mode LWLockMode) bool {
+	for _, h := range p.heldLWLocks {
+		if h.lock == lock && h.mode == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// ----------------------------------------------------------------
+// ロックに付けた値を待つ (WAL の挿入のロック用)
+// ----------------------------------------------------------------
+// WAL の挿入のロックは、排他で持つバックエンドが挿入を終えた位置を値として公開する。他の
+// バックエンドはロックが空くか値が変わるまで待ち、ロックを取らずにどこまで書き出せるかを知る。
+
+// conflictsWithVar はロックが空いているか値が oldval から変わっていれば待たずに済むとする
+// (LWLockConflictsWithVar 相当)。free はロックが空いていたかを表す。
+func (lock *LWLock) conflictsWithVar(valptr *atomic.Uint64, oldval uint64) (mustWait bool, newval uint64, free bool) {
+	if lock.state.Load()&lwValExclusive == 0 {
+		return false, 0, true
+	}
+	if v := valptr.Load(); v != oldval {
+		return false, v, false
+	}
+	return true, 0, false
+}
+
+// LWLockWaitForVar は排他で持たれているロックが空くか、valptr の値が oldval から変わるまで待つ
+// (LWLockWaitForVar 相当)。ロックが空いたら free を真にし、値が変わったらその値を返す。
+func (p *Proc) LWLockWaitForVar(lock *LWLock, valptr *atomic.Uint64, oldval uint64) (newval uint64, free bool) {
+	for {
+		mustWait, newval, free := lock.conflictsWithVar(valptr, oldval)
+		if !mustWait {
+			return newval, free
+		}
+		lock.queueSelf(p, LWWaitUntilFree)
+		// 値を変えたバックエンドが起こせるようにする
+		lock.state.Or(lwFlagReleaseOK)
+		mustWait, newval, free = lock.conflictsWithVar(valptr, oldval)
+		if !mustWait {
+			lock.dequeueSelf(p)
+			return newval, free
+		}
+		p.lwSleep(lock)
+	}
+}
+
+// LWLockUpdateVar は排他で持っているロックの値を val にし、値を待っているバックエンドを起こす
+// (LWLockUpdateVar 相当)
+func (p *Proc) LWLockUpdateVar(lock *LWLock, valptr *atomic.Uint64, val uint64) {
+	valptr.Store(val)
+	lock.mu.Lock()
+	var wake []*Proc
+	remaining := lock.waiters[:0:0]
+	for _, w := range lock.waiters {
+		if w.lwWait.mode != LWWaitUntilFree {
+			remaining = append(remaining, w)
+			continue
+		}
+		w.lwWait.waiting = false
+		wake = append(wake, w)
+	}
+	lock.waiters = remaining
+	if len(lock.waiters) == 0 {
+		lock.state.And(^lwFlagHasWaiters)
+	}
+	lock.mu.Unlock()
+	for _, w := range wake {
+		w.lwWait.sem <- struct{}{}
+	}
+}
+
+// LWLockReleaseClearVar は値を val に戻してからロックを放す (LWLockReleaseClearVar 相当)
+func (p *Proc) LWLockReleaseClearVar(lock *LWLock, valptr *atomic.Uint64, val uint64) {
+	valptr.Store(val)
+	p.LWLockRelease(lock)
+}
@@ -0,0 +1,416 @@
+package page
+
+import (
+	"encoding/binary"
+	"slices"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// ページの形式 (storage/bufpage.h, storage/page/bufpage.c 相当)
+// ----------------------------------------------------------------
+// ヒープとインデックスのページは共通の形式を持つ。先頭にページヘッダ、その後ろに行ポインタの
+// 並びを置き、項目 (タプル) はページの後ろから前に向かって詰める。行ポインタの終わりが pd_lower、
+// 項目の始まりが pd_upper で、その間が空き領域になる。ページの末尾の pd_special から後ろは
+// アクセスメソッドが自由に使う特別な領域 (インデックスの兄弟ページへのリンクなど) である。
+//
+//	+----------------+---------------------------------+
+//	| PageHeaderData | linp1 linp2 linp3 ...           |
+//	+-----------+----+---------------------------------+
+//	| ... linpN |                                      |
+//	+-----------+--------------------------------------+
+//	|           ^ pd_lower                             |
+//	|                                                  |
+//	|             v pd_upper                           |
+//	+-------------+------------------------------------+
+//	|             | tupleN ...                         |
+//	+-------------+------------------+-----------------+
+//	|       ... tuple3 tuple2 tuple1 | "special space" |
+//	+--------------------------------+-----------------+
+//	                                 ^ pd_special
+//
+// ページは共有バッファのページ (pgconfig.BlckSz バイトの []byte) をそのまま読み書きする。
+// ヘッダの各欄はチェックサムと同じくリトルエンディアンで持つ。項目の位置と長さは MaxAlign で
+// 8 バイトに揃える。
+//
+// ファイル暗号化を有効にしたクラスタでは、特別な領域のさらに後ろの ReservedPageSize バイトを
+// 暗号化の nonce のために空けておく (reserved_page_size 相当)。この領域はアクセスメソッドからは
+// 見えない。
+
+// ページヘッダの各欄の位置 (PageHeaderData 相当)
+const (
+	pdLSNOffset             = 0
+	pdFlagsOffset           = 10
+	pdLowerOffset           = 12
+	pdUpperOffset           = 14
+	pdSpecialOffset         = 16
+	pdPagesizeVersionOffset = 18
+	pdPruneXidOffset        = 20
+
+	// SizeOfPageHeaderData はページヘッダの大きさ。最初の行ポインタはここから始まる
+	// (SizeOfPageHeaderData 相当)
+	SizeOfPageHeaderData = 24
+)
+
+// pd_flags のビット (PD_* 相当)
+const (
+	// PdHasFreeLines は使っていない行ポインタがあるかもしれないことを表すヒント
+	PdHasFreeLines = 0x0001
+	// PdPageFull は更新のための空き領域がなかったことを表すヒント
+	PdPageFull = 0x0002
+	// PdAllVisible は全てのタプルが全てのトランザクションから見えることを表す
+	PdAllVisible = 0x0004
+
+	pdValidFlagBits = 0x0007
+)
+
+// PgPageLayoutVersion はページの形式の版 (PG_PAGE_LAYOUT_VERSION 相当)
+const PgPageLayoutVersion = 4
+
+// MaximumAlignof は項目を揃える境界 (MAXIMUM_ALIGNOF 相当)
+const MaximumAlignof = 8
+
+// MaxAlign は n を MaximumAlignof の倍数に切り上げる (MAXALIGN 相当)
+func MaxAlign(n int) int {
+	return (n + MaximumAlignof - 1) &^ (MaximumAlignof - 1)
+}
+
+// ReservedPageSize はページの末尾に空けておく領域の大きさ。ファイル暗号化が有効なら
+// crypto.InitializeFileEncryption が設定する。ページを初期化する前に決め、その後は変えない。
+var ReservedPageSize int
+
+// MaxHeapTuplesPerPage はヒープのページに置けるタプルの数の上限。ヘッダだけのタプル
+// (SizeofHeapTupleHeader の 23 バイトを揃えた大きさ) と行ポインタで埋めた場合の数
+// (MaxHeapTuplesPerPage 相当)
+const MaxHeapTuplesPerPage = (pgconfig.BlckSz - SizeOfPageHeaderData) / (24 + storage.SizeOfItemID)
+
+// Init はページを空のページに初期化する (PageInit 相当)。末尾に specialSize バイトの特別な
+// 領域と、その後ろに ReservedPageSize バイトの領域を取る。
+func Init(page []byte, specialSize int) {
+	specialSize = MaxAlign(specialSize) + ReservedPageSize
+	clear(page)
+	setUint16(page, pdLowerOffset, SizeOfPageHeaderData)
+	setUint16(page, pdUpperOffset, len(page)-specialSize)
+	setUint16(page, pdSpecialOffset, len(page)-specialSize)
+	// ページの大きさは 256 の倍数のため、下位の 8 ビットに版を入れる (PageSetPageSizeAndVersion 相当)
+	setUint16(page, pdPagesizeVersionOffset, len(page)|PgPageLayoutVersion)
+}
+
+// IsNew はページが初期化されていない (全て 0 の) ページかを返す (PageIsNew 相当)。
+// pd_upper が 0 のページは未初期化とみなす。
+func IsNew(page []byte) bool {
+	return Upper(page) == 0
+}
+
+// IsEmpty はページに行ポインタがないかを返す (PageIsEmpty 相当)
+func IsEmpty(page []byte) bool {
+	return Lower(page) <= SizeOfPageHeaderData
+}
+
+// LSN はページを最後に変更した WAL の位置を返す (PageGetLSN 相当)
+func LSN(page []byte) uint64 { return binary.LittleEndian.Uint64(page[pdLSNOffset:]) }
+
+// SetLSN はページを最後に変更した WAL の位置を設定する (PageSetLSN 相当)
+func SetLSN(page []byte, lsn uint64) { binary.LittleEndian.PutUint64(page[pdLSNOffset:], lsn) }
+
+// Flags は pd_flags を返す
+func Flags(page []byte) int { return getUint16(page, pdFlagsOffset) }
+
+// SetFlags は pd_flags に flags のビットを立てる (PageSetHasFreeLinePointers などに相当)
+func SetFlags(page []byte, flags int) { setUint16(page, pdFlagsOffset, Flags(page)|flags) }
+
+// ClearFlags は pd_flags の flags のビットを下ろす (PageClearHasFreeLinePointers などに相当)
+func ClearFlags(page []byte, flags int) { setUint16(page, pdFlagsOffset, Flags(page)&^flags) }
+
+// Lower は空き領域の始まり (pd_lower) を返す
+func Lower(page []byte) int { return getUint16(page, pdLowerOffset) }
+
+// Upper は空き領域の終わり (pd_upper) を返す
+func Upper(page []byte) int { return getUint16(page, pdUpperOffset) }
+
+// Special は特別な領域の始まり (pd_special) を返す
+func Special(page []byte) int { return getUint16(page, pdSpecialOffset) }
+
+// SpecialPointer は特別な領域を返す (PageGetSpecialPointer 相当)
+func SpecialPointer(page []byte) []byte { return page[Special(page) : len(page)-ReservedPageSize] }
+
+// PageSize はヘッダに記録したページの大きさを返す (PageGetPageSize 相当)
+func PageSize(page []byte) int { return getUint16(page, pdPagesizeVersionOffset) &^ 0xFF }
+
+// LayoutVersion はヘッダに記録したページの形式の版を返す (PageGetPageLayoutVersion 相当)
+func LayoutVersion(page []byte) int { return getUint16(page, pdPagesizeVersionOffset) & 0xFF }
+
+// PruneXid は刈り込めるかもしれない最も古いトランザクションを返す (pd_prune_xid 相当)
+func PruneXid(page []byte) uint32 { return binary.LittleEndian.Uint32(page[pdPruneXidOffset:]) }
+
+// SetPruneXid は pd_prune_xid を設定する (PageSetPrunable の代入相当)
+func SetPruneXid(page []byte, xid uint32) {
+	binary.LittleEndian.PutUint32(page[pdPruneXidOffset:], xid)
+}
+
+// MaxOffsetNumber はページの最後の行ポインタの番号を返す。行ポインタがなければ 0 を返す
+// (PageGetMaxOffsetNumber 相当)
+func MaxOffsetNumber(page []byte) storage.OffsetNumber {
+	lower := Lower(page)
+	if lower <= SizeOfPageHeaderData {
+		return 0
+	}
+	return storage.OffsetNumber((lower - SizeOfPageHeaderData) / storage.SizeOfItemID)
+}
+
+// itemIDOffset は行ポインタ offnum のページの中の位置を返す
+func itemIDOffset(offnum storage.OffsetNumber) int {
+	return SizeOfPageHeaderData + int(offnum-1)*storage.SizeOfItemID
+}
+
+// GetItemID は行ポインタ offnum を返す (PageGetItemId 相当)
+func GetItemID(page []byte, offnum storage.OffsetNumber) storage.ItemID {
+	return storage.ItemID(binary.LittleEndian.Uint32(page[itemIDOffset(offnum):]))
+}
+
+// SetItemID は行ポインタ offnum を書き換える (ItemIdSetNormal、ItemIdSetUnused などの代入相当)
+func SetItemID(page []byte, offnum storage.OffsetNumber, id storage.ItemID) {
+	binary.LittleEndian.PutUint32(page[itemIDOffset(offnum):], uint32(id))
+}
+
+// GetItem は行ポインタ id が指す項目を返す (PageGetItem 相当)。返した領域はページと共有する。
+// 領域を持たない行ポインタには使えない。
+func GetItem(page []byte, id storage.ItemID) []byte {
+	return page[id.Off() : id.Off()+id.Len()]
+}
+
+// AddItem のフラグ (PAI_* 相当)
+const (
+	// AddItemOverwrite は offsetNumber の使っていない行ポインタをそのまま使う。指定しなければ、
+	// offsetNumber から後ろの行ポインタを1つずつずらして空ける
+	AddItemOverwrite = 1 << iota
+	// AddItemIsHeap はヒープのページに加え、MaxHeapTuplesPerPage を超えないようにする
+	AddItemIsHeap
+)
+
+// AddItem は item をページに加え、指す行ポインタの番号を返す (PageAddItemExtended 相当)。
+// offsetNumber が InvalidOffsetNumber なら、使っていない行ポインタか新しい行ポインタを使う。
+// 空き領域が足りなければ InvalidOffsetNumber を返す。
+//
+// 行ポインタの指定が正しくない場合は、C言語版と同じく WARNING をサーバーログに出して
+// InvalidOffsetNumber を返す。ページヘッダが壊れていればサーバーを異常終了させる (PANIC)。
+func AddItem(page []byte, item []byte, offsetNumber storage.OffsetNumber, flags int) (storage.OffsetNumber, error) {
+	lower, upper, special := Lower(page), Upper(page), Special(page)
+	if lower < SizeOfPageHeaderData || lower > upper || upper > special || special > pgconfig.BlckSz {
+		return storage.InvalidOffsetNumber, errutil.New(errutil.Panic, errcodes.DataCorrupted,
+			"corrupted page pointers: lower = %d, upper = %d, special = %d", lower, upper, special)
+	}
+
+	// 新しい行ポインタを足すならこの番号になる
+	limit := MaxOffsetNumber(page) + 1
+	needshuffle := false
+	if offsetNumber.IsValid() {
+		if flags&AddItemOverwrite != 0 {
+			if offsetNumber < limit {
+				if id := GetItemID(page, offsetNumber); id.IsUsed() || id.HasStorage() {
+					errutil.Report(nil, errutil.Elog(errutil.Warning, "will not overwrite a used ItemId"))
+					return storage.InvalidOffsetNumber, nil
+				}
+			}
+		} else if offsetNumber < limit {
+			needshuffle = true
+		}
+	} else {
+		offsetNumber = limit
+		if Flags(page)&PdHasFreeLines != 0 {
+			// 使っていない行ポインタを探す。なければヒントを下ろす
+			for off := storage.FirstOffsetNumber; off < limit; off++ {
+				if id := GetItemID(page, off); !id.IsUsed() && !id.HasStorage() {
+					offsetNumber = off
+					break
+				}
+			}
+			if offsetNumber == limit {
+				ClearFlags(page, PdHasFreeLines)
+			}
+		}
+	}
+	if offsetNumber > limit {
+		errutil.Report(nil, errutil.Elog(errutil.Warning, "specified item offset is too large"))
+		return storage.InvalidOffsetNumber, nil
+	}
+	if flags&AddItemIsHeap != 0 && offsetNumber > MaxHeapTuplesPerPage {
+		errutil.Report(nil, errutil.Elog(errutil.Warning, "can't put more than MaxHeapTuplesPerPage items in a heap page"))
+		return storage.InvalidOffsetNumber, nil
+	}
+
+	// 新しい行ポインタを足す場合とずらす場合は、行ポインタの並びが1つ伸びる
+	newLower := lower
+	if offsetNumber == limit || needshuffle {
+		newLower += storage.SizeOfItemID
+	}
+	newUpper := upper - MaxAlign(len(item))
+	if newLower > newUpper {
+		return storage.InvalidOffsetNumber, nil
+	}
+
+	if needshuffle {
+		from := itemIDOffset(offsetNumber)
+		copy(page[from+storage.SizeOfItemID:], page[from:itemIDOffset(limit)])
+	}
+	SetItemID(page, offsetNumber, storage.MakeItemID(storage.LpNormal, newUpper, len(item)))
+	copy(page[newUpper:], item)
+	setUint16(page, pdLowerOffset, newLower)
+	setUint16(page, pdUpperOffset, newUpper)
+	return offsetNumber, nil
+}
+
+// GetFreeSpace は項目を1つ加えるのに使える空き領域の大きさを返す。新しい行ポインタの分を
+// 差し引く (PageGetFreeSpace 相当)。
+func GetFreeSpace(page []byte) int {
+	space := Upper(page) - Lower(page)
+	if space < storage.SizeOfItemID {
+		return 0
+	}
+	return space - storage.SizeOfItemID
+}
+
+// GetExactFreeSpace は pd_lower と pd_upper の間の大きさをそのまま返す (PageGetExactFreeSpace 相当)
+func GetExactFreeSpace(page []byte) int {
+	return max(Upper(page)-Lower(page), 0)
+}
+
+// GetHeapFreeSpace はヒープのページにタプルを1つ加えるのに使える空き領域の大きさを返す
+// (PageGetHeapFreeSpace 相当)。行ポインタが MaxHeapTuplesPerPage に達していて、使っていない
+// 行ポインタもなければ 0 を返す。
+func GetHeapFreeSpace(page []byte) int {
+	space := GetFreeSpace(page)
+	if space == 0 {
+		return 0
+	}
+	nline := MaxOffsetNumber(page)
+	if nline < MaxHeapTuplesPerPage {
+		return space
+	}
+	if Flags(page)&PdHasFreeLines != 0 {
+		for off := storage.FirstOffsetNumber; off <= nline; off++ {
+			if id := GetItemID(page, off); !id.IsUsed() {
+				return space
+			}
+		}
+	}
+	return 0
+}
+
+// itemIDSort は RepairFragmentation が詰め直す項目1つ (itemIdCompactData 相当)
+type itemIDSort struct {
+	offnum     storage.OffsetNumber
+	itemoff    int
+	alignedlen int
+}
+
+// RepairFragmentation は項目の間の隙間をなくし、空き領域を1つにまとめる
+// (PageRepairFragmentation と compactify_tuples 相当)。行ポインタの番号は変えない。領域を持たない
+// 行ポインタのうち使っていないものは、末尾の分を切り詰め、残りがあれば PdHasFreeLines を立てる。
+func RepairFragmentation(page []byte) error {
+	lower, upper, special := Lower(page), Upper(page), Special(page)
+	if lower < SizeOfPageHeaderData || lower > upper || upper > special || special > pgconfig.BlckSz ||
+		special != MaxAlign(special) {
+		return errutil.New(errutil.Error, errcodes.DataCorrupted,
+			"corrupted page pointers: lower = %d, upper = %d, special = %d", lower, upper, special)
+	}
+
+	nline := MaxOffsetNumber(page)
+	var items []itemIDSort
+	var totallen, nunused int
+	finalusedlp := storage.InvalidOffsetNumber
+	for off := storage.FirstOffsetNumber; off <= nline; off++ {
+		id := GetItemID(page, off)
+		if !id.IsUsed() {
+			// 使っていない行ポインタは長さを持たないはずだが、念のため消す
+			SetItemID(page, off, storage.MakeItemID(storage.LpUnused, 0, 0))
+			nunused++
+			continue
+		}
+		if id.HasStorage() {
+			if id.Off() < upper || id.Off() >= special {
+				return errutil.New(errutil.Error, errcodes.DataCorrupted, "corrupted line pointer: %d", id.Off())
+			}
+			item := itemIDSort{offnum: off, itemoff: id.Off(), alignedlen: MaxAlign(id.Len())}
+			items = append(items, item)
+			totallen += item.alignedlen
+		}
+		finalusedlp = off
+	}
+
+	if len(items) == 0 {
+		setUint16(page, pdUpperOffset, special)
+	} else {
+		if totallen > special-lower {
+			return errutil.New(errutil.Error, errcodes.DataCorrupted,
+				"corrupted item lengths: total %d, available space %d", totallen, special-lower)
+		}
+		// 元の位置の後ろの項目から順に、特別な領域の手前に詰めていく
+		slices.SortFunc(items, func(a, b itemIDSort) int { return b.itemoff - a.itemoff })
+		scratch := slices.Clone(page[upper:special])
+		newUpper := special
+		for _, item := range items {
+			newUpper -= item.alignedlen
+			copy(page[newUpper:newUpper+item.alignedlen], scratch[item.itemoff-upper:])
+			id := GetItemID(page, item.offnum)
+			SetItemID(page, item.offnum, storage.MakeItemID(id.Flags(), newUpper, id.Len()))
+		}
+		setUint16(page, pdUpperOffset, newUpper)
+	}
+
+	// 末尾の使っていない行ポインタは切り詰める
+	if finalusedlp != nline {
+		nunusedend := int(nline - finalusedlp)
+		nunused -= nunusedend
+		setUint16(page, pdLowerOffset, lower-nunusedend*storage.SizeOfItemID)
+	}
+	// AddItem が使っていない行ポインタを探すためのヒント
+	if nunused > 0 {
+		SetFlags(page, PdHasFreeLines)
+	} else {
+		ClearFlags(page, PdHasFreeLines)
+	}
+	return nil
+}
+
+// HeaderIsValid はページヘッダが正しい形をしているかを返す (PageIsVerifiedExtended のヘッダの
+// 検査相当)
+func HeaderIsValid(page []byte) bool {
+	lower, upper, special := Lower(page), Upper(page), Special(page)
+	return Flags(page)&^pdValidFlagBits == 0 &&
+		lower >= SizeOfPageHeaderData && lower <= upper && upper <= special &&
+		special <= pgconfig.BlckSz-ReservedPageSize && special == MaxAlign(special)
+}
+
+// IsVerified は読み込んだページが正しいかを返す (PageIsVerifiedExtended 相当)。初期化済みの
+// ページはヘッダの形と、データチェックサムを検証する状態であればチェックサムを確かめる。
+// 未初期化のページは全て 0 であれば正しいとする (md の ZeroExtend が書くページ)。
+// チェックサムが合わなければ警告を出す。
+func IsVerified(page []byte, blkno uint32) bool {
+	checksumFailure := false
+	if !IsNew(page) {
+		if DataChecksumsNeedVerify() {
+			if checksum := ChecksumPage(page, blkno); checksum != binary.LittleEndian.Uint16(page[PdChecksumOffset:]) {
+				checksumFailure = true
+				errutil.Report(nil, errutil.New(errutil.Warning, errcodes.DataCorrupted,
+					"page verification failed, calculated checksum %d but expected %d",
+					checksum, binary.LittleEndian.Uint16(page[PdChecksumOffset:])))
+			}
+		}
+		return HeaderIsValid(page) && !checksumFailure
+	}
+	for _, b := range page {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func getUint16(page []byte, off int) int { return int(binary.LittleEndian.Uint16(page[off:])) }
+
+func setUint16(page []byte, off, v int) { binary.LittleEndian.PutUint16(page[off:], uint16(v)) }
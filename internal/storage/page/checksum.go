@@ -0,0 +1,160 @@
+package page
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+)
+
+// ----------------------------------------------------------------
+// データページのチェックサム (storage/checksum_impl.h, storage/page/checksum.c 相当)
+// ----------------------------------------------------------------
+// ページを 32 個の uint32 の列として並行に FNV-1a を変形したハッシュで畳み込み、
+// 最後に XOR でまとめる。32 列を独立に計算するため、C言語版ではコンパイラが
+// SIMD 命令に展開できる。結果にブロック番号を混ぜ、ページの取り違えも検出する。
+//
+// C言語版はページをそのままのバイト順 (マシンのエンディアン) で読むため、
+// Go言語版ではリトルエンディアンとして読む (x86-64 / arm64 の C言語版と同じ値になる)。
+
+// nSums は並行に計算する部分チェックサムの数 (N_SUMS 相当)
+const nSums = 32
+
+// fnvPrime は FNV-1a の素数 (FNV_PRIME 相当)
+const fnvPrime = 16777619
+
+// PdChecksumOffset はページヘッダ内の pd_checksum の位置。
+// pd_lsn (8 バイト) の直後にある。
+const PdChecksumOffset = 8
+
+// checksumBaseOffsets は部分チェックサムの初期値。列ごとに異なる値にして、
+// 全て 0 のページなどでの偏りを避ける。
+var checksumBaseOffsets = [nSums]uint32{
+	0x5B1F36E9, 0xB8525960, 0x02AB50AA, 0x1DE66D2A,
+	0x79FF467A, 0x9BB9F8A3, 0x217E7CD2, 0x83E13D2C,
+	0xF8D4474F, 0xE39EB970, 0x42C6AE16, 0x993216FA,
+	0x7B093B5D, 0x98DAFF3C, 0xF718902A, 0x0B1C9CDB,
+	0xE58F764B, 0x187636BC, 0x5D7B3BB1, 0xE73DE7DE,
+	0x92BEC979, 0xCCA6C0B2, 0x304A0979, 0x85AA43D4,
+	0x783125BB, 0x6CA8EAA2, 0xE407EAC6, 0x4B5CFC3E,
+	0x9FBF8C76, 0x15CA20BE, 0xF2CA9FFF, 0x3E5DBFB6,
+}
+
+// checksumComp は部分チェックサムに値を1つ畳み込む (CHECKSUM_COMP 相当)
+func checksumComp(checksum, value uint32) uint32 {
+	tmp := checksum ^ value
+	return tmp*fnvPrime ^ (tmp >> 17)
+}
+
+// checksumBlock はページ全体のチェックサムを計算する (pg_checksum_block 相当)
+func checksumBlock(page []byte) uint32 {
+	sums := checksumBaseOffsets
+	for i := 0; i < pgconfig.BlckSz/(4*nSums); i++ {
+		row := page[i*4*nSums:]
+		for j := 0; j < nSums; j++ {
+			sums[j] = checksumComp(sums[j], binary.LittleEndian.Uint32(row[j*4:]))
+		}
+	}
+	// 0 を2周分畳み込んで、最後の数語の影響を全体に行き渡らせる
+	for i := 0; i < 2; i++ {
+		for j := 0; j < nSums; j++ {
+			sums[j] = checksumComp(sums[j], 0)
+		}
+	}
+	var result uint32
+	for _, s := range sums {
+		result ^= s
+	}
+	return result
+}
+
+// ChecksumPage はページのチェックサムを計算する (pg_checksum_page 相当)。
+// pd_checksum 自身は 0 とみなして計算する。結果は 0 にならない (0 は「未設定」と区別できるようにする)。
+func ChecksumPage(page []byte, blkno uint32) uint16 {
+	save := binary.LittleEndian.Uint16(page[PdChecksumOffset:])
+	binary.LittleEndian.PutUint16(page[PdChecksumOffset:], 0)
+	checksum := checksumBlock(page)
+	binary.LittleEndian.PutUint16(page[PdChecksumOffset:], save)
+
+	checksum ^= blkno
+	return uint16(checksum%65535 + 1)
+}
+
+// SetChecksum はページの pd_checksum を設定する (PageSetChecksumInplace 相当)
+func SetChecksum(page []byte, blkno uint32) {
+	binary.LittleEndian.PutUint16(page[PdChecksumOffset:], ChecksumPage(page, blkno))
+}
+
+// VerifyChecksum はページの pd_checksum が正しいかを返す。
+func VerifyChecksum(page []byte, blkno uint32) bool {
+	return binary.LittleEndian.Uint16(page[PdChecksumOffset:]) == ChecksumPage(page, blkno)
+}
+
+// ----------------------------------------------------------------
+// データチェックサムの状態 (xlog.c の DataChecksumsNeedWrite、DataChecksumsNeedVerify 相当)
+// ----------------------------------------------------------------
+// C言語版は状態を XLogCtl に持つが、transam はバッファマネージャーを使うため、バッファマネージャーが
+// 参照できるこのパッケージに持つ。起動時に制御ファイルの data_checksum_version から決め、
+// オンラインでの有効化と無効化 (datachecksumsworker) が変える。
+
+// DataChecksumState はクラスタのデータチェックサムの状態 (data_checksums 相当)
+type DataChecksumState int32
+
+const (
+	DataChecksumsOff DataChecksumState = iota
+	DataChecksumsInProgressOn
+	DataChecksumsOn
+	DataChecksumsInProgressOff
+)
+
+func (s DataChecksumState) String() string {
+	switch s {
+	case DataChecksumsOff:
+		return "off"
+	case DataChecksumsInProgressOn:
+		return "inprogress-on"
+	case DataChecksumsOn:
+		return "on"
+	case DataChecksumsInProgressOff:
+		return "inprogress-off"
+	}
+	return "unknown"
+}
+
+var dataChecksumState atomic.Int32
+
+// GetDataChecksumState は現在の状態を返す
+func GetDataChecksumState() DataChecksumState {
+	return DataChecksumState(dataChecksumState.Load())
+}
+
+// SetDataChecksumState は状態を変える。制御ファイルへの記録は呼び出し側が行う。
+func SetDataChecksumState(state DataChecksumState) {
+	dataChecksumState.Store(int32(state))
+}
+
+// DataChecksumsNeedWrite は、書き出すページにチェックサムを付ける必要があるかを返す
+// (DataChecksumsNeedWrite 相当)。無効化の途中でも、まだ検証しているバックエンドのために付ける。
+func DataChecksumsNeedWrite() bool {
+	s := GetDataChecksumState()
+	return s == DataChecksumsOn || s == DataChecksumsInProgressOn || s == DataChecksumsInProgressOff
+}
+
+// DataChecksumsNeedVerify は、読み込んだページのチェックサムを検証すべきかを返す
+// (DataChecksumsNeedVerify 相当)。設定途中のページが残っている間は検証しない。
+func DataChecksumsNeedVerify() bool {
+	return GetDataChecksumState() == DataChecksumsOn
+}
+
+// SetChecksumCopy はページの複製にチェックサムを設定して返す (PageSetChecksumCopy 相当)。
+// 共有バッファのページは内容のロックを共有のモードで取ったまま書き出すため、ヒントビットの
+// 更新と競合しないよう複製に設定する。チェックサムが不要か、未初期化のページはそのまま返す。
+func SetChecksumCopy(page []byte, blkno uint32) []byte {
+	if IsNew(page) || !DataChecksumsNeedWrite() {
+		return page
+	}
+	buf := make([]byte, len(page))
+	copy(buf, page)
+	SetChecksum(buf, blkno)
+	return buf
+}
@@ -0,0 +1,142 @@
+package procarray
+
+import (
+	"sync"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/snapmgr"
+)
+
+// ----------------------------------------------------------------
+// 実行中のトランザクションの一覧 (storage/ipc/procarray.c 相当)
+// ----------------------------------------------------------------
+// バックエンドごとに、割り当てたトランザクション ID と、取ったスナップショットの xmin を持つ。
+// バックエンドは PID で表す。スナップショットは一覧のロックを持って実行中のトランザクションを
+// 集めて作る。
+//
+// 他のバックエンドが実行中でもコミット済みでもない状態を見ないよう、トランザクション ID は
+// 一覧のロックを持ったまま割り当て、トランザクションの終わりにはコミットかアボートかを記録して
+// から一覧から外す。そのため、スナップショットに実行中として入っていない xmax より前の
+// トランザクションは、必ず状態を記録している。
+//
+// サブトランザクションとスタンバイはまだないため、トップレベルのトランザクションだけを扱う。
+
+// procEntry はバックエンド1つ分のトランザクションの状態 (PGPROC の xid と xmin 相当)
+type procEntry struct {
+	// xid は実行中のトランザクションに割り当てたトランザクション ID。割り当てていなければ
+	// InvalidTransactionId
+	xid adt.TransactionId
+	// xmin はトランザクションで最初に取ったスナップショットの xmin。取っていなければ
+	// InvalidTransactionId。これより後のトランザクションが消した行はまだ消せない
+	xmin adt.TransactionId
+}
+
+// procArray は全てのバックエンドの一覧 (ProcArrayStruct 相当)。ロックは ProcArrayLock に当たる
+var procArray = struct {
+	sync.Mutex
+	procs map[int32]*procEntry
+}{procs: make(map[int32]*procEntry)}
+
+// entry は pid のバックエンドの状態を返す。なければ作る。procArray のロックを持って呼ぶ。
+func entry(pid int32) *procEntry {
+	e, ok := procArray.procs[pid]
+	if !ok {
+		e = &procEntry{}
+		procArray.procs[pid] = e
+	}
+	return e
+}
+
+// AssignTransactionId は新しいトランザクション ID を割り当て、pid のバックエンドの実行中の
+// トランザクションとして登録する (AssignTransactionId と GetNewTransactionId の MyProc->xid の
+// 設定相当)。割り当てられなければエラーを返す。
+func AssignTransactionId(pid int32) (adt.FullTransactionId, error) {
+	procArray.Lock()
+	defer procArray.Unlock()
+	fxid, err := transam.GetNewTransactionId()
+	if err != nil {
+		return 0, err
+	}
+	entry(pid).xid = transam.XidFromFullTransactionId(fxid)
+	return fxid, nil
+}
+
+// EndTransaction は pid のバックエンドのトランザクションが終わったことを登録する
+// (ProcArrayEndTransaction 相当)。トランザクション ID を割り当てていれば、状態を記録した後に
+// 呼ぶ。スナップショットの xmin も外す。
+func EndTransaction(pid int32) {
+	procArray.Lock()
+	defer procArray.Unlock()
+	if e, ok := procArray.procs[pid]; ok {
+		e.xid = transam.InvalidTransactionId
+		e.xmin = transam.InvalidTransactionId
+	}
+}
+
+// Remove は終了したバックエンドを一覧から外す (ProcArrayRemove 相当)
+func Remove(pid int32) {
+	procArray.Lock()
+	defer procArray.Unlock()
+	delete(procArray.procs, pid)
+}
+
+// TransactionIdIsInProgress は xid のトランザクションを実行中のバックエンドがあるかを返す
+// (TransactionIdIsInProgress 相当)
+func TransactionIdIsInProgress(xid adt.TransactionId) bool {
+	if !transam.TransactionIdIsNormal(xid) {
+		return false
+	}
+	procArray.Lock()
+	defer procArray.Unlock()
+	for _, e := range procArray.procs {
+		if e.xid == xid {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTransactionIds は pid のバックエンドのトランザクション ID と xmin を返す
+// (ProcNumberGetTransactionIds 相当)。pg_stat_activity の backend_xid と backend_xmin に使う。
+func GetTransactionIds(pid int32) (xid, xmin adt.TransactionId) {
+	procArray.Lock()
+	defer procArray.Unlock()
+	if e, ok := procArray.procs[pid]; ok {
+		return e.xid, e.xmin
+	}
+	return transam.InvalidTransactionId, transam.InvalidTransactionId
+}
+
+// GetSnapshotData は pid のバックエンドのコマンド curcid が使うスナップショットを取る
+// (GetSnapshotData 相当)。バックエンドがトランザクションでまだスナップショットを取って
+// いなければ、その xmin をバックエンドの xmin にする。自分のトランザクションは実行中の一覧に
+// 入れないため、呼び出し側が別に確かめる。
+func GetSnapshotData(pid int32, curcid adt.CommandId) *snapmgr.Snapshot {
+	procArray.Lock()
+	xmax := transam.ReadNextTransactionId()
+	xmin := xmax
+	var xip []adt.TransactionId
+	for p, e := range procArray.procs {
+		if !transam.TransactionIdIsNormal(e.xid) {
+			continue
+		}
+		// 自分のトランザクションも xmin には含める
+		if transam.TransactionIdPrecedes(e.xid, xmin) {
+			xmin = e.xid
+		}
+		if p != pid {
+			xip = append(xip, e.xid)
+		}
+	}
+	if e := entry(pid); !transam.TransactionIdIsValid(e.xmin) {
+		e.xmin = xmin
+	}
+	procArray.Unlock()
+
+	snapshot := snapmgr.NewSnapshot(xmin, 0)
+	snapshot.Xmax = xmax
+	snapshot.Xip = xip
+	snapshot.Curcid = curcid
+	return snapshot
+}
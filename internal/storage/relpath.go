@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+)
+
+// ----------------------------------------------------------------
+// フォークとリレーションのファイルのパス (common/relpath.c, common/relpath.h 相当)
+// ----------------------------------------------------------------
+// リレーションは本体のほかに、空き領域マップなどを別のファイル (フォーク) に持つ。フォークの
+// ファイルは本体のファイルの名前にフォークの名前を付けた名前にする。パスはデータディレクトリ
+// からの相対パスで、共有カタログは global/、それ以外は base/<データベースの OID>/ に置く。
+
+// ForkNumber はリレーションのフォーク (ForkNumber 相当)
+type ForkNumber int32
+
+const (
+	// InvalidForkNumber はフォークを指さないことを表す
+	InvalidForkNumber ForkNumber = -1
+	MainForkNum       ForkNumber = 0
+	// FsmForkNum は空き領域マップ
+	FsmForkNum ForkNumber = 1
+	// VisibilityMapForkNum は可視性マップ
+	VisibilityMapForkNum ForkNumber = 2
+	// InitForkNum はログを取らないリレーションの初期化用のフォーク
+	InitForkNum ForkNumber = 3
+
+	// MaxForkNum は最後のフォーク (MAX_FORKNUM 相当)
+	MaxForkNum = InitForkNum
+)
+
+// forkNames はフォークの名前 (forkNames 相当)
+var forkNames = [...]string{
+	MainForkNum:          "main",
+	FsmForkNum:           "fsm",
+	VisibilityMapForkNum: "vm",
+	InitForkNum:          "init",
+}
+
+// String はフォークの名前を返す
+func (f ForkNumber) String() string {
+	if f < 0 || f > MaxForkNum {
+		return fmt.Sprintf("fork %d", int32(f))
+	}
+	return forkNames[f]
+}
+
+// RelPath はリレーションのフォークのファイルのパスを返す (relpathperm 相当)。本体のフォークは
+// フォークの名前を付けない。
+func RelPath(rlocator RelFileLocator, forkNum ForkNumber) string {
+	var path string
+	if rlocator.DbOid == catalog.InvalidOid {
+		path = fmt.Sprintf("global/%d", rlocator.RelNumber)
+	} else {
+		path = fmt.Sprintf("base/%d/%d", rlocator.DbOid, rlocator.RelNumber)
+	}
+	if forkNum != MainForkNum {
+		path += "_" + forkNum.String()
+	}
+	return path
+}
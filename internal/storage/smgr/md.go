@@ -0,0 +1,463 @@
+package smgr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/crypto"
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/platform"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/file"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/fsync"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/injection"
+)
+
+// ----------------------------------------------------------------
+// 磁気ディスクのストレージマネージャー (storage/smgr/md.c 相当)
+// ----------------------------------------------------------------
+// リレーションのフォークは、データディレクトリの下の storage.RelPath のファイルに置く。ファイルは
+// RelsegSize ブロック (1GB) ごとのセグメントに分け、2番目からはパスに ".1"、".2" と番号を付ける。
+// 最後のセグメント以外は常にちょうど RelsegSize ブロックを持つ。RelsegSize より短いセグメントが
+// あれば、その後ろのセグメントは数えない。切り詰めで使わなくなったセグメントは、C言語版と同じく
+// 削除せずに空にする。
+//
+// セグメントは仮想ファイル記述子 (file.File) で開く。開いているファイルが多すぎれば、長く使って
+// いないセグメントの記述子は閉じられ、次に読み書きするときに開き直される。
+//
+// ファイル暗号化が有効なクラスタでは、ブロックを書く前に複製を crypto.EncryptPage で暗号化し、
+// 読んだ直後に crypto.DecryptPage で復号する。共有バッファのページは常に平文のまま持つ。
+//
+// 書き込んだセグメントはその場では同期せず、fsync.RegisterSyncRequest で次のチェックポイントに
+// 同期を任せる (register_dirty_segment 相当)。削除するファイルの要求は取り消す。
+//
+// C言語版の mdunlink は、relfilenumber が再利用されないよう最初のセグメントを次のチェック
+// ポイントまで空のまま残す。WAL がまだなく再生で取り違えることがないため、全てのセグメントを
+// すぐに削除する。
+
+// mdfdVec は開いたセグメント1つ (_MdfdVec 相当)
+type mdfdVec struct {
+	file  *file.File
+	segno storage.BlockNumber
+}
+
+// extensionBehavior は求めたセグメントがないときの振る舞い (EXTENSION_* 相当)
+type extensionBehavior int
+
+const (
+	// extensionFail はエラーにする
+	extensionFail extensionBehavior = iota
+	// extensionReturnNull は nil を返す
+	extensionReturnNull
+	// extensionCreate は足りないセグメントを作る。前のセグメントが埋まっていなければエラーにする
+	extensionCreate
+)
+
+// segPath はフォークの segno 番目のセグメントのパスを返す
+func segPath(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, segno storage.BlockNumber) string {
+	path := filepath.Join(guc.DataDirectory.Get(), storage.RelPath(rlocator, forkNum))
+	if segno > 0 {
+		path = fmt.Sprintf("%s.%d", path, segno)
+	}
+	return path
+}
+
+// fileAccessError は OS のエラーから SQLSTATE を決め、format の後ろに OS のエラーメッセージを
+// 加えたエラーを作る (errcode_for_file_access と %m 相当)
+func fileAccessError(err error, format string, args ...any) *errutil.ErrorData {
+	return errutil.New(errutil.Error, file.ErrcodeForFileAccess(err), "%s: %s", fmt.Sprintf(format, args...), platform.OSErrorMessage(err))
+}
+
+// encryptBlock はファイル暗号化が有効なら、buf のブロックを暗号化した複製を返す。無効なら
+// buf をそのまま返す。
+func encryptBlock(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber, buf []byte) []byte {
+	if !crypto.FileEncryptionEnabled() {
+		return buf
+	}
+	enc := make([]byte, pgconfig.BlckSz)
+	copy(enc, buf)
+	crypto.EncryptPage(enc, crypto.PageID{RLocator: rlocator, ForkNum: forkNum, BlockNum: blockNum})
+	return enc
+}
+
+// writeBlock は buf のブロックをセグメントの seekpos に書く。インジェクションポイント point を
+// 通過するため、テストでは書き込みの失敗や途中までの書き込み (ページの破損) を起こせる。
+func writeBlock(point string, v *mdfdVec, buf []byte, seekpos int64) error {
+	return injection.Write(point, buf[:pgconfig.BlckSz], func(b []byte) error {
+		_, err := v.file.WriteAt(b, seekpos)
+		return err
+	})
+}
+
+// registerDirtySegment は書き込んだセグメントを次のチェックポイントで同期するよう登録する
+// (register_dirty_segment 相当)
+func registerDirtySegment(v *mdfdVec) {
+	fsync.RegisterSyncRequest(v.file.Name())
+}
+
+// mdClose はフォークの開いているセグメントを閉じる (mdclose 相当)
+func mdClose(reln *smgrRelation, forkNum storage.ForkNumber) {
+	for _, v := range reln.mdSegs[forkNum] {
+		v.file.Close()
+	}
+	reln.mdSegs[forkNum] = nil
+}
+
+// mdOpenFork はフォークの最初のセグメントを開く (mdopenfork 相当)。既に開いていればそれを返す。
+// ファイルがなければ、behavior が extensionReturnNull なら nil を返し、それ以外はエラーにする。
+func mdOpenFork(reln *smgrRelation, forkNum storage.ForkNumber, behavior extensionBehavior) (*mdfdVec, error) {
+	if segs := reln.mdSegs[forkNum]; len(segs) > 0 {
+		return segs[0], nil
+	}
+	path := segPath(reln.rlocator, forkNum, 0)
+	f, err := file.PathNameOpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		if behavior == extensionReturnNull && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fileAccessError(err, "could not open file \"%s\"", path)
+	}
+	v := &mdfdVec{file: f, segno: 0}
+	reln.mdSegs[forkNum] = []*mdfdVec{v}
+	return v, nil
+}
+
+// segNblocks はセグメントのブロックの数を返す (_mdnblocks 相当)
+func segNblocks(v *mdfdVec) (storage.BlockNumber, error) {
+	size, err := v.file.Size()
+	if err != nil {
+		return 0, fileAccessError(err, "could not seek to end of file \"%s\"", v.file.Name())
+	}
+	return storage.BlockNumber(size / pgconfig.BlckSz), nil
+}
+
+// getSeg はブロック blkno を含むセグメントを返す (_mdfd_getseg 相当)。それより前のセグメントも
+// 順に開く。relations のロックを取ってから呼ぶ。
+func getSeg(reln *smgrRelation, forkNum storage.ForkNumber, blkno storage.BlockNumber, behavior extensionBehavior) (*mdfdVec, error) {
+	targetseg := blkno / pgconfig.RelsegSize
+	if int(targetseg) < len(reln.mdSegs[forkNum]) {
+		return reln.mdSegs[forkNum][targetseg], nil
+	}
+	v, err := mdOpenFork(reln, forkNum, behavior)
+	if v == nil {
+		return nil, err
+	}
+	for nextsegno := storage.BlockNumber(len(reln.mdSegs[forkNum])); nextsegno <= targetseg; nextsegno++ {
+		nblocks, err := segNblocks(reln.mdSegs[forkNum][nextsegno-1])
+		if err != nil {
+			return nil, err
+		}
+		if nblocks > pgconfig.RelsegSize {
+			return nil, errutil.Elog(errutil.Error, "segment too big")
+		}
+		path := segPath(reln.rlocator, forkNum, nextsegno)
+		if nblocks < pgconfig.RelsegSize {
+			if behavior == extensionReturnNull {
+				return nil, nil
+			}
+			return nil, errutil.New(errutil.Error, errcodes.UndefinedFile,
+				"could not open file \"%s\" (target block %d): previous segment is only %d blocks", path, blkno, nblocks)
+		}
+		flags := os.O_RDWR
+		if behavior == extensionCreate {
+			flags |= os.O_CREATE
+		}
+		f, err := file.PathNameOpenFile(path, flags, 0600)
+		if err != nil {
+			if behavior == extensionReturnNull && errors.Is(err, fs.ErrNotExist) {
+				return nil, nil
+			}
+			return nil, fileAccessError(err, "could not open file \"%s\" (target block %d)", path, blkno)
+		}
+		reln.mdSegs[forkNum] = append(reln.mdSegs[forkNum], &mdfdVec{file: f, segno: nextsegno})
+	}
+	return reln.mdSegs[forkNum][targetseg], nil
+}
+
+// lookupSeg はリレーションを開き、ブロック blkno を含むセグメントと、セグメントの中での位置を返す
+func lookupSeg(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blkno storage.BlockNumber, behavior extensionBehavior) (*mdfdVec, int64, error) {
+	relations.Lock()
+	defer relations.Unlock()
+	v, err := getSeg(open(rlocator), forkNum, blkno, behavior)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, int64(blkno%pgconfig.RelsegSize) * pgconfig.BlckSz, nil
+}
+
+// Exists はリレーションのフォークのファイルがあるかを返す (smgrexists と mdexists 相当)
+func Exists(rlocator storage.RelFileLocator, forkNum storage.ForkNumber) bool {
+	relations.Lock()
+	defer relations.Unlock()
+	reln := open(rlocator)
+	// 他で削除されていても気付けるよう、開き直して確かめる
+	mdClose(reln, forkNum)
+	v, _ := mdOpenFork(reln, forkNum, extensionReturnNull)
+	return v != nil
+}
+
+// Create はリレーションのフォークの空のファイルを作る (smgrcreate と mdcreate 相当)。isRedo が
+// 真なら、既にあるファイルをそのまま使う。
+func Create(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, isRedo bool) error {
+	relations.Lock()
+	defer relations.Unlock()
+	reln := open(rlocator)
+	if isRedo && len(reln.mdSegs[forkNum]) > 0 {
+		return nil
+	}
+	path := segPath(rlocator, forkNum, 0)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fileAccessError(err, "could not create directory \"%s\"", filepath.Dir(path))
+	}
+	f, err := file.PathNameOpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil && isRedo {
+		f, err = file.PathNameOpenFile(path, os.O_RDWR, 0)
+	}
+	if err != nil {
+		return fileAccessError(err, "could not create file \"%s\"", path)
+	}
+	mdClose(reln, forkNum)
+	reln.mdSegs[forkNum] = []*mdfdVec{{file: f, segno: 0}}
+	return nil
+}
+
+// Unlink はリレーションのフォークの全てのセグメントを削除する (smgrdounlinkall と mdunlink 相当)。
+// forkNum が InvalidForkNumber なら全てのフォークを削除する。ないファイルは無視する。
+func Unlink(rlocator storage.RelFileLocator, forkNum storage.ForkNumber) error {
+	relations.Lock()
+	defer relations.Unlock()
+	if reln, ok := relations.byLocator[rlocator]; ok {
+		for f := range reln.mdSegs {
+			mdClose(reln, storage.ForkNumber(f))
+		}
+		delete(relations.byLocator, rlocator)
+	}
+	if forkNum != storage.InvalidForkNumber {
+		return mdUnlinkFork(rlocator, forkNum)
+	}
+	var firstErr error
+	for f := storage.MainForkNum; f <= storage.MaxForkNum; f++ {
+		if err := mdUnlinkFork(rlocator, f); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// mdUnlinkFork はフォークのセグメントを、ないセグメントに当たるまで順に削除する (mdunlinkfork 相当)
+func mdUnlinkFork(rlocator storage.RelFileLocator, forkNum storage.ForkNumber) error {
+	for segno := storage.BlockNumber(0); ; segno++ {
+		path := segPath(rlocator, forkNum, segno)
+		fsync.ForgetSyncRequest(path)
+		if err := os.Remove(path); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return fileAccessError(err, "could not remove file \"%s\"", path)
+		}
+	}
+}
+
+// Extend はフォークの終わりにブロック blockNum を加え、buf を書く (smgrextend と mdextend 相当)。
+// blockNum はフォークのブロックの数でなければならない。
+func Extend(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber, buf []byte) error {
+	if blockNum == storage.InvalidBlockNumber {
+		return errutil.New(errutil.Error, errcodes.ProgramLimitExceeded,
+			"cannot extend file \"%s\" beyond %d blocks", segPath(rlocator, forkNum, 0), storage.InvalidBlockNumber)
+	}
+	v, seekpos, err := lookupSeg(rlocator, forkNum, blockNum, extensionCreate)
+	if err != nil {
+		return err
+	}
+	buf = encryptBlock(rlocator, forkNum, blockNum, buf)
+	if err := writeBlock("mdextend", v, buf, seekpos); err != nil {
+		return fileAccessError(err, "could not extend file \"%s\"", v.file.Name()).WithHint("Check free disk space.")
+	}
+	registerDirtySegment(v)
+	return nil
+}
+
+// zeroChunkBlocks は ZeroExtend が1回に書く 0 のブロックの数
+const zeroChunkBlocks = 64
+
+// ZeroExtend はフォークの終わりの blockNum から nblocks 個の 0 で埋めたブロックを加える
+// (smgrzeroextend と mdzeroextend 相当)。セグメントの境界をまたいでもよい。
+func ZeroExtend(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber, nblocks int) error {
+	if uint64(blockNum)+uint64(nblocks) >= uint64(storage.InvalidBlockNumber) {
+		return errutil.New(errutil.Error, errcodes.ProgramLimitExceeded,
+			"cannot extend file \"%s\" beyond %d blocks", segPath(rlocator, forkNum, 0), storage.InvalidBlockNumber)
+	}
+	zero := make([]byte, zeroChunkBlocks*pgconfig.BlckSz)
+	for remblocks := storage.BlockNumber(nblocks); remblocks > 0; {
+		v, seekpos, err := lookupSeg(rlocator, forkNum, blockNum, extensionCreate)
+		if err != nil {
+			return err
+		}
+		// 1つのセグメントに収まる分だけ書き、残りは次のセグメントに書く
+		numblocks := min(remblocks, pgconfig.RelsegSize-blockNum%pgconfig.RelsegSize, zeroChunkBlocks)
+		if _, err := v.file.WriteAt(zero[:int(numblocks)*pgconfig.BlckSz], seekpos); err != nil {
+			return fileAccessError(err, "could not extend file \"%s\"", v.file.Name()).WithHint("Check free disk space.")
+		}
+		registerDirtySegment(v)
+		blockNum += numblocks
+		remblocks -= numblocks
+	}
+	return nil
+}
+
+// Prefetch はフォークのブロック blockNum の読み込みを OS に始めさせる (smgrprefetch と
+// mdprefetch 相当)。読み終えるのは待たない。ブロックを含むセグメントがなければ何もしない。
+func Prefetch(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber) error {
+	v, seekpos, err := lookupSeg(rlocator, forkNum, blockNum, extensionReturnNull)
+	if err != nil || v == nil {
+		return err
+	}
+	if err := v.file.Prefetch(seekpos, pgconfig.BlckSz); err != nil {
+		return fileAccessError(err, "could not prefetch block %d in file \"%s\"", blockNum, v.file.Name())
+	}
+	return nil
+}
+
+// Read はフォークのブロック blockNum を buf に読み込む (smgrread と mdreadv 相当)
+func Read(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber, buf []byte) error {
+	v, seekpos, err := lookupSeg(rlocator, forkNum, blockNum, extensionFail)
+	if err != nil {
+		return err
+	}
+	n, err := v.file.ReadAt(buf[:pgconfig.BlckSz], seekpos)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fileAccessError(err, "could not read block %d in file \"%s\"", blockNum, v.file.Name())
+	}
+	if n < pgconfig.BlckSz {
+		return errutil.New(errutil.Error, errcodes.DataCorrupted,
+			"could not read block %d in file \"%s\": read only %d of %d bytes", blockNum, v.file.Name(), n, pgconfig.BlckSz)
+	}
+	crypto.DecryptPage(buf[:pgconfig.BlckSz], crypto.PageID{RLocator: rlocator, ForkNum: forkNum, BlockNum: blockNum})
+	return nil
+}
+
+// Write は buf をフォークの既にあるブロック blockNum に書く (smgrwrite と mdwritev 相当)。
+// フォークを伸ばすには Extend を使う。
+func Write(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber, buf []byte) error {
+	v, seekpos, err := lookupSeg(rlocator, forkNum, blockNum, extensionFail)
+	if err != nil {
+		return err
+	}
+	buf = encryptBlock(rlocator, forkNum, blockNum, buf)
+	if err := writeBlock("mdwrite", v, buf, seekpos); err != nil {
+		edata := fileAccessError(err, "could not write block %d in file \"%s\"", blockNum, v.file.Name())
+		if edata.Code == errcodes.DiskFull {
+			edata.WithHint("Check free disk space.")
+		}
+		return edata
+	}
+	registerDirtySegment(v)
+	return nil
+}
+
+// Nblocks はフォークのブロックの数を返す (smgrnblocks と mdnblocks 相当)
+func Nblocks(rlocator storage.RelFileLocator, forkNum storage.ForkNumber) (storage.BlockNumber, error) {
+	relations.Lock()
+	defer relations.Unlock()
+	return mdNblocks(open(rlocator), forkNum)
+}
+
+// mdNblocks はフォークのブロックの数を数え、その間に全てのセグメントを開く。relations のロックを
+// 取ってから呼ぶ。
+func mdNblocks(reln *smgrRelation, forkNum storage.ForkNumber) (storage.BlockNumber, error) {
+	if _, err := mdOpenFork(reln, forkNum, extensionFail); err != nil {
+		return 0, err
+	}
+	// 開いている最後のセグメントより前は埋まっている
+	segno := storage.BlockNumber(len(reln.mdSegs[forkNum]) - 1)
+	v := reln.mdSegs[forkNum][segno]
+	for {
+		nblocks, err := segNblocks(v)
+		if err != nil {
+			return 0, err
+		}
+		if nblocks > pgconfig.RelsegSize {
+			return 0, errutil.Elog(errutil.Error, "segment too big")
+		}
+		if nblocks < pgconfig.RelsegSize {
+			return segno*pgconfig.RelsegSize + nblocks, nil
+		}
+		segno++
+		path := segPath(reln.rlocator, forkNum, segno)
+		f, err := file.PathNameOpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return segno * pgconfig.RelsegSize, nil
+			}
+			return 0, fileAccessError(err, "could not open file \"%s\"", path)
+		}
+		v = &mdfdVec{file: f, segno: segno}
+		reln.mdSegs[forkNum] = append(reln.mdSegs[forkNum], v)
+	}
+}
+
+// Truncate はフォークを nblocks 個のブロックに切り詰める (smgrtruncate と mdtruncate 相当)。
+// 切り詰めたブロックのページが共有バッファに残っていないことは呼び出し元が保証する。
+func Truncate(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, nblocks storage.BlockNumber) error {
+	relations.Lock()
+	defer relations.Unlock()
+	reln := open(rlocator)
+	curnblk, err := mdNblocks(reln, forkNum)
+	if err != nil {
+		return err
+	}
+	if nblocks > curnblk {
+		return errutil.Elog(errutil.Error, "could not truncate file \"%s\" to %d blocks: it's only %d blocks now",
+			segPath(rlocator, forkNum, 0), nblocks, curnblk)
+	}
+	if nblocks == curnblk {
+		return nil
+	}
+	// 後ろのセグメントから切り詰める。丸ごと要らないセグメントは空にして閉じる
+	for curopensegs := len(reln.mdSegs[forkNum]); curopensegs > 0; curopensegs-- {
+		priorblocks := storage.BlockNumber(curopensegs-1) * pgconfig.RelsegSize
+		v := reln.mdSegs[forkNum][curopensegs-1]
+		if priorblocks > nblocks {
+			if err := v.file.Truncate(0); err != nil {
+				return fileAccessError(err, "could not truncate file \"%s\"", v.file.Name())
+			}
+			registerDirtySegment(v)
+			v.file.Close()
+			reln.mdSegs[forkNum] = reln.mdSegs[forkNum][:curopensegs-1]
+		} else if priorblocks+pgconfig.RelsegSize > nblocks {
+			lastsegblocks := nblocks - priorblocks
+			if err := v.file.Truncate(int64(lastsegblocks) * pgconfig.BlckSz); err != nil {
+				return fileAccessError(err, "could not truncate file \"%s\" to %d blocks", v.file.Name(), nblocks)
+			}
+			registerDirtySegment(v)
+		} else {
+			// これより前のセグメントは埋まったまま残る
+			break
+		}
+	}
+	return nil
+}
+
+// Immedsync はフォークの全てのセグメントをすぐに同期する (smgrimmedsync と mdimmedsync 相当)。
+// チェックポイントを待たずにファイルを永続化する必要がある場合に使う。
+func Immedsync(rlocator storage.RelFileLocator, forkNum storage.ForkNumber) error {
+	relations.Lock()
+	defer relations.Unlock()
+	reln := open(rlocator)
+	if _, err := mdNblocks(reln, forkNum); err != nil {
+		return err
+	}
+	for _, v := range reln.mdSegs[forkNum] {
+		if err := v.file.Sync(); err != nil {
+			return fileAccessError(err, "could not fsync file \"%s\"", v.file.Name())
+		}
+	}
+	return nil
+}
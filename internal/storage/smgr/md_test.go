@@ -0,0 +1,92 @@
+package smgr
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/pgconfig"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+)
+
+var testRel = storage.RelFileLocator{DbOid: 5, RelNumber: 16384}
+
+// setupDataDir は一時ディレクトリをデータディレクトリにする。
+func setupDataDir(t *testing.T) {
+	t.Helper()
+	prev := guc.DataDirectory.Get()
+	if err := guc.SetConfigOption("data_directory", t.TempDir(), guc.PGCPostmaster, guc.PGCSArgv); err != nil {
+		t.Fatalf("SetConfigOption = %v", err)
+	}
+	t.Cleanup(func() {
+		CloseAll()
+		guc.SetConfigOption("data_directory", prev, guc.PGCPostmaster, guc.PGCSArgv)
+	})
+}
+
+func blockOf(b byte) []byte {
+	return bytes.Repeat([]byte{b}, pgconfig.BlckSz)
+}
+
+func TestSegmentBoundaryReadWrite(t *testing.T) {
+	setupDataDir(t)
+	if err := Create(testRel, storage.MainForkNum, false); err != nil {
+		t.Fatalf("Create = %v", err)
+	}
+	// 最初のセグメントを埋めたものとする (疎なファイルのためディスクは使わない)
+	if err := os.Truncate(segPath(testRel, storage.MainForkNum, 0), int64(pgconfig.RelsegSize)*pgconfig.BlckSz); err != nil {
+		t.Fatal(err)
+	}
+	last := storage.BlockNumber(pgconfig.RelsegSize - 1)
+	if err := Write(testRel, storage.MainForkNum, last, blockOf('a')); err != nil {
+		t.Fatalf("Write(%d) = %v", last, err)
+	}
+	// 次のブロックは2番目のセグメントの先頭で、まだないため Extend で加える
+	if err := Write(testRel, storage.MainForkNum, last+1, blockOf('b')); err == nil {
+		t.Fatalf("Write beyond the end of the fork succeeded")
+	}
+	if err := Extend(testRel, storage.MainForkNum, last+1, blockOf('b')); err != nil {
+		t.Fatalf("Extend(%d) = %v", last+1, err)
+	}
+	if _, err := os.Stat(segPath(testRel, storage.MainForkNum, 1)); err != nil {
+		t.Fatalf("second segment was not created: %v", err)
+	}
+
+	// 開き直してもセグメントをまたいで読める
+	Close(testRel)
+	if n, err := Nblocks(testRel, storage.MainForkNum); err != nil || n != last+2 {
+		t.Fatalf("Nblocks = %d, %v, want %d", n, err, last+2)
+	}
+	buf := make([]byte, pgconfig.BlckSz)
+	for blkno, want := range map[storage.BlockNumber]byte{last: 'a', last + 1: 'b'} {
+		if err := Read(testRel, storage.MainForkNum, blkno, buf); err != nil {
+			t.Fatalf("Read(%d) = %v", blkno, err)
+		}
+		if !bytes.Equal(buf, blockOf(want)) {
+			t.Errorf("Read(%d) returned wrong contents", blkno)
+		}
+	}
+	err := Read(testRel, storage.MainForkNum, last+2, buf)
+	if err == nil || !strings.Contains(err.Error(), "read only 0 of 8192 bytes") {
+		t.Errorf("Read past the end = %v, want a short read error", err)
+	}
+
+	// 最初のセグメントの途中まで切り詰めると、2番目のセグメントは空になる
+	if err := Truncate(testRel, storage.MainForkNum, last); err != nil {
+		t.Fatalf("Truncate = %v", err)
+	}
+	if n, err := Nblocks(testRel, storage.MainForkNum); err != nil || n != last {
+		t.Errorf("Nblocks after truncate = %d, %v, want %d", n, err, last)
+	}
+	if info, err := os.Stat(segPath(testRel, storage.MainForkNum, 1)); err != nil || info.Size() != 0 {
+		t.Errorf("second segment after truncate: %v, %v", info, err)
+	}
+	if err := Unlink(testRel, storage.InvalidForkNumber); err != nil {
+		t.Fatalf("Unlink = %v", err)
+	}
+	if Exists(testRel, storage.MainForkNum) {
+		t.Error("fork still exists after Unlink")
+	}
+}
@@ -0,0 +1,92 @@
+package smgr
+
+import (
+	"sync"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/storage"
+	"github.com/Tsubasa-2005/go-postgres/internal/storage/buffer"
+)
+
+// ----------------------------------------------------------------
+// ストレージマネージャー (storage/smgr/smgr.c 相当)
+// ----------------------------------------------------------------
+// リレーションのフォークをブロック単位で読み書きする。C言語版は smgrsw の表で実装を選ぶが、
+// 実装は磁気ディスク (md.c) の1つしかないため、md の関数を直接呼ぶ。
+//
+// C言語版はバックエンドごとに SMgrRelation のハッシュ表を持ち、開いたセグメントのファイルを
+// 覚える。バックエンドはゴルーチンのため、ここでは1つの表を全てのバックエンドで共有し、ロックで
+// 守る。ファイルの読み書きそのものはロックの外で行う。同じリレーションの切り詰めや削除と
+// 読み書きが重ならないことは、呼び出し元がリレーションのロックで保証する。
+//
+// Init でバッファマネージャーに登録し、共有バッファのページを読み書きする先にする。
+
+// smgrRelation は開いたリレーション (SMgrRelationData 相当)
+type smgrRelation struct {
+	rlocator storage.RelFileLocator
+	// mdSegs はフォークごとに開いたセグメント。先頭から順に開き、途中を飛ばさない (md_seg_fds 相当)
+	mdSegs [storage.MaxForkNum + 1][]*mdfdVec
+}
+
+// relations は開いたリレーションの表 (SMgrRelationHash 相当)
+var relations struct {
+	sync.Mutex
+	byLocator map[storage.RelFileLocator]*smgrRelation
+}
+
+// Init はストレージマネージャーをバッファマネージャーに登録する (smgrinit 相当)。リレーションの
+// ファイルはデータディレクトリの下に置くため、データディレクトリを決めてから呼ぶ。
+func Init() {
+	buffer.SetSmgr(bufferSmgr{})
+}
+
+// open はリレーションを表に加えて返す (smgropen 相当)。relations のロックを取ってから呼ぶ。
+func open(rlocator storage.RelFileLocator) *smgrRelation {
+	if relations.byLocator == nil {
+		relations.byLocator = make(map[storage.RelFileLocator]*smgrRelation)
+	}
+	reln, ok := relations.byLocator[rlocator]
+	if !ok {
+		reln = &smgrRelation{rlocator: rlocator}
+		relations.byLocator[rlocator] = reln
+	}
+	return reln
+}
+
+// Close はリレーションの開いている全てのセグメントを閉じ、表から除く (smgrclose 相当)
+func Close(rlocator storage.RelFileLocator) {
+	relations.Lock()
+	defer relations.Unlock()
+	if reln, ok := relations.byLocator[rlocator]; ok {
+		for forkNum := range reln.mdSegs {
+			mdClose(reln, storage.ForkNumber(forkNum))
+		}
+		delete(relations.byLocator, rlocator)
+	}
+}
+
+// CloseAll は全てのリレーションを閉じる (smgrcloseall 相当)
+func CloseAll() {
+	relations.Lock()
+	defer relations.Unlock()
+	for rlocator, reln := range relations.byLocator {
+		for forkNum := range reln.mdSegs {
+			mdClose(reln, storage.ForkNumber(forkNum))
+		}
+		delete(relations.byLocator, rlocator)
+	}
+}
+
+// bufferSmgr はバッファマネージャーに登録する buffer.Smgr の実装
+type bufferSmgr struct{}
+
+func (bufferSmgr) Read(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber, buf []byte) error {
+	return Read(rlocator, forkNum, blockNum, buf)
+}
+
+func (bufferSmgr) Prefetch(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber) error {
+	return Prefetch(rlocator, forkNum, blockNum)
+}
+
+func (bufferSmgr) Write(rlocator storage.RelFileLocator, forkNum storage.ForkNumber, blockNum storage.BlockNumber, buf []byte) error {
+	return Write(rlocator, forkNum, blockNum, buf)
+}
@@ -0,0 +1,438 @@
+package adt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 配列型 (utils/adt/arrayfuncs.c 相当)
+// ----------------------------------------------------------------
+// 多次元の配列を、次元ごとの要素数と下限、行優先に並べた要素で表す。
+// 要素が1つもない配列は次元を持たない。
+//
+// テキスト表現は "{1,2,NULL}" や "{{1,2},{3,4}}" のように波括弧で入れ子にし、
+// 下限が 1 でない場合は "[0:1]={1,2}" のように次元を前に付ける。
+// バイナリ形式は次元数、NULL を含むかどうか、要素の型の OID、次元ごとの要素数と下限に続いて、
+// 要素ごとに長さ (NULL は -1) とバイナリ表現を並べる。
+
+// MaxDim は配列の次元数の上限 (MAXDIM 相当)
+const MaxDim = 6
+
+// Array は配列の値 (ArrayType 相当)
+type Array struct {
+	ElemType catalog.Oid
+	Dims     []int
+	LBounds  []int
+	// Elems は行優先に並べた要素。NULL は nil で表す。
+	Elems []Datum
+}
+
+// TextArray は文字列の並びを1次元の text[] の値にする。空の並びは空の配列にする。
+func TextArray(elems []string) *Array {
+	arr := &Array{ElemType: catalog.TEXTOID}
+	if len(elems) == 0 {
+		return arr
+	}
+	arr.Dims = []int{len(elems)}
+	arr.LBounds = []int{1}
+	for _, e := range elems {
+		arr.Elems = append(arr.Elems, e)
+	}
+	return arr
+}
+
+// ArrayIn は配列のテキスト表現を解析する (array_in 相当)
+func ArrayIn(s string, elemType catalog.Oid) (*Array, error) {
+	p := &arrayParser{input: s, str: s, delim: catalog.TypeDelim(elemType)}
+	arr := &Array{ElemType: elemType}
+
+	// 次元の指定 "[lb:ub]...="
+	var explicit []int
+	p.skipSpace()
+	for strings.HasPrefix(p.str, "[") {
+		end := strings.IndexByte(p.str, ']')
+		if end < 0 {
+			return nil, p.malformed("Missing \"]\" after array dimensions.")
+		}
+		lbs, ubs, hasLB := strings.Cut(p.str[1:end], ":")
+		if !hasLB {
+			lbs, ubs = "1", lbs
+		}
+		lb, err1 := strconv.Atoi(strings.TrimSpace(lbs))
+		ub, err2 := strconv.Atoi(strings.TrimSpace(ubs))
+		if err1 != nil || err2 != nil {
+			return nil, p.malformed("\"[\" must introduce explicitly-specified array dimensions.")
+		}
+		if ub < lb {
+			return nil, newError(errcodes.ArraySubscriptError, "upper bound cannot be less than lower bound")
+		}
+		if len(explicit)/2 >= MaxDim {
+			return nil, newError(errcodes.ProgramLimitExceeded, "number of array dimensions exceeds the maximum allowed (%d)", MaxDim)
+		}
+		explicit = append(explicit, lb, ub)
+		p.str = p.str[end+1:]
+		p.skipSpace()
+	}
+	if explicit != nil {
+		if !strings.HasPrefix(p.str, "=") {
+			return nil, p.malformed("Missing \"=\" after array dimensions.")
+		}
+		p.str = p.str[1:]
+		p.skipSpace()
+	}
+	if !strings.HasPrefix(p.str, "{") {
+		return nil, p.malformed("Array value must start with \"{\" or dimension information.")
+	}
+
+	var raw []*string
+	if err := p.parseLevel(0, &raw); err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.str != "" {
+		return nil, p.malformed("Junk after closing right brace.")
+	}
+
+	if len(raw) > 0 {
+		arr.Dims = append([]int(nil), p.dims[:p.ndim]...)
+		arr.LBounds = make([]int, len(p.dims))
+		for i := range arr.LBounds {
+			arr.LBounds[i] = 1
+		}
+	}
+	if explicit != nil {
+		if len(explicit)/2 != len(arr.Dims) {
+			return nil, p.malformed("Specified array dimensions do not match array contents.")
+		}
+		for i := range arr.Dims {
+			if explicit[2*i+1]-explicit[2*i]+1 != arr.Dims[i] {
+				return nil, p.malformed("Specified array dimensions do not match array contents.")
+			}
+			arr.LBounds[i] = explicit[2*i]
+		}
+	}
+
+	arr.Elems = make([]Datum, len(raw))
+	for i, r := range raw {
+		if r == nil {
+			continue
+		}
+		d, err := InputFunctionCall(elemType, *r)
+		if err != nil {
+			return nil, err
+		}
+		arr.Elems[i] = d
+	}
+	return arr, nil
+}
+
+// arrayParser は配列のテキスト表現の解析の状態 (ReadArrayStr 相当)
+type arrayParser struct {
+	input string
+	str   string
+	delim byte
+	// ndim は要素が現れた深さから決まる次元数。0 はまだ決まっていないことを表す。
+	ndim int
+	// dims は次元ごとの要素数。最初に閉じた部分配列で決まり、以降の部分配列と照合する。
+	dims [MaxDim]int
+}
+
+func (p *arrayParser) skipSpace() {
+	p.str = strings.TrimLeft(p.str, " \t\n\r\v\f")
+}
+
+func (p *arrayParser) malformed(detail string) error {
+	return newError(errcodes.InvalidTextRepresentation, "malformed array literal: \"%s\": %s", p.input, detail)
+}
+
+// parseLevel は depth 段目の "{...}" を読み、要素を raw に追加する。NULL は nil で表す。
+func (p *arrayParser) parseLevel(depth int, raw *[]*string) error {
+	if depth >= MaxDim {
+		return newError(errcodes.ProgramLimitExceeded, "number of array dimensions exceeds the maximum allowed (%d)", MaxDim)
+	}
+	p.str = p.str[1:] // "{"
+	p.skipSpace()
+	if strings.HasPrefix(p.str, "}") {
+		p.str = p.str[1:]
+		// 空の配列 "{}" は最上位でだけ許す
+		if depth > 0 {
+			return p.malformed("Multidimensional arrays must have sub-arrays with matching dimensions.")
+		}
+		return nil
+	}
+	n := 0
+	for {
+		p.skipSpace()
+		if strings.HasPrefix(p.str, "{") {
+			if p.ndim != 0 && depth+1 >= p.ndim {
+				return p.malformed("Unexpected \"{\" character.")
+			}
+			if err := p.parseLevel(depth+1, raw); err != nil {
+				return err
+			}
+		} else {
+			if p.ndim == 0 {
+				p.ndim = depth + 1
+			} else if p.ndim != depth+1 {
+				return p.malformed("Unexpected array element.")
+			}
+			elem, err := p.parseElement()
+			if err != nil {
+				return err
+			}
+			*raw = append(*raw, elem)
+		}
+		n++
+		p.skipSpace()
+		switch {
+		case strings.HasPrefix(p.str, "}"):
+			p.str = p.str[1:]
+			if p.dims[depth] == 0 {
+				p.dims[depth] = n
+			} else if p.dims[depth] != n {
+				return p.malformed("Multidimensional arrays must have sub-arrays with matching dimensions.")
+			}
+			return nil
+		case p.str != "" && p.str[0] == p.delim:
+			p.str = p.str[1:]
+		case p.str == "":
+			return p.malformed("Unexpected end of input.")
+		default:
+			return p.malformed(fmt.Sprintf("Unexpected \"%c\" character.", p.str[0]))
+		}
+	}
+}
+
+// parseElement は1つの要素を読む。引用符で囲んでいない NULL は nil を返す。
+func (p *arrayParser) parseElement() (*string, error) {
+	var sb strings.Builder
+	quoted, anyQuoted := false, false
+	// trailing は引用符の外の末尾の空白を除くため、最後に意味のある文字を書いた位置
+	trailing := 0
+	for {
+		if p.str == "" {
+			return nil, p.malformed("Unexpected end of input.")
+		}
+		c := p.str[0]
+		switch {
+		case c == '\\':
+			if len(p.str) < 2 {
+				return nil, p.malformed("Unexpected end of input.")
+			}
+			sb.WriteByte(p.str[1])
+			p.str = p.str[2:]
+			trailing = sb.Len()
+			continue
+		case c == '"':
+			if !quoted && anyQuoted || !quoted && sb.Len() > 0 {
+				return nil, p.malformed("Unexpected array element.")
+			}
+			quoted = !quoted
+			anyQuoted = true
+			p.str = p.str[1:]
+			trailing = sb.Len()
+			continue
+		case quoted:
+		case c == p.delim || c == '}':
+			if !anyQuoted && sb.Len() == 0 {
+				return nil, p.malformed("Unexpected \"" + string(c) + "\" character.")
+			}
+			elem := sb.String()[:trailing]
+			if !anyQuoted && strings.EqualFold(elem, "NULL") {
+				return nil, nil
+			}
+			return &elem, nil
+		case c == '{':
+			return nil, p.malformed("Unexpected \"{\" character.")
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f':
+			sb.WriteByte(c)
+			p.str = p.str[1:]
+			continue
+		case anyQuoted:
+			return nil, p.malformed("Unexpected array element.")
+		}
+		sb.WriteByte(c)
+		p.str = p.str[1:]
+		trailing = sb.Len()
+	}
+}
+
+// ArrayOut は配列のテキスト表現を返す (array_out 相当)
+func ArrayOut(arr *Array) string {
+	if len(arr.Dims) == 0 {
+		return "{}"
+	}
+	var sb strings.Builder
+	for _, lb := range arr.LBounds {
+		if lb != 1 {
+			for j := range arr.Dims {
+				fmt.Fprintf(&sb, "[%d:%d]", arr.LBounds[j], arr.LBounds[j]+arr.Dims[j]-1)
+			}
+			sb.WriteByte('=')
+			break
+		}
+	}
+	delim := catalog.TypeDelim(arr.ElemType)
+	pos := 0
+	var write func(depth int)
+	write = func(depth int) {
+		sb.WriteByte('{')
+		for i := 0; i < arr.Dims[depth]; i++ {
+			if i > 0 {
+				sb.WriteByte(delim)
+			}
+			if depth+1 < len(arr.Dims) {
+				write(depth + 1)
+				continue
+			}
+			elem := arr.Elems[pos]
+			pos++
+			if elem == nil {
+				sb.WriteString("NULL")
+				continue
+			}
+			writeArrayElement(&sb, OutputFunctionCall(arr.ElemType, elem), delim)
+		}
+		sb.WriteByte('}')
+	}
+	write(0)
+	return sb.String()
+}
+
+// writeArrayElement は要素のテキスト表現を書き出す。空文字列、NULL と紛らわしい値、
+// 区切り文字や空白、引用符を含む値は二重引用符で囲み、引用符とバックスラッシュをエスケープする。
+func writeArrayElement(sb *strings.Builder, s string, delim byte) {
+	needQuote := s == "" || strings.EqualFold(s, "NULL")
+	for i := 0; i < len(s) && !needQuote; i++ {
+		switch c := s[i]; c {
+		case '{', '}', '"', '\\', ' ', '\t', '\n', '\r', '\v', '\f', delim:
+			needQuote = true
+		}
+	}
+	if !needQuote {
+		sb.WriteString(s)
+		return
+	}
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+	sb.WriteByte('"')
+}
+
+// ArraySend は配列のバイナリ表現を返す (array_send 相当)
+func ArraySend(arr *Array) ([]byte, error) {
+	hasNull := int32(0)
+	for _, e := range arr.Elems {
+		if e == nil {
+			hasNull = 1
+			break
+		}
+	}
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(arr.Dims)))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(hasNull))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(arr.ElemType))
+	for i, d := range arr.Dims {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(int32(d)))
+		buf = binary.BigEndian.AppendUint32(buf, uint32(int32(arr.LBounds[i])))
+	}
+	for _, e := range arr.Elems {
+		if e == nil {
+			buf = binary.BigEndian.AppendUint32(buf, 0xFFFFFFFF)
+			continue
+		}
+		b, err := SendFunctionCall(arr.ElemType, e)
+		if err != nil {
+			return nil, err
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(b)))
+		buf = append(buf, b...)
+	}
+	return buf, nil
+}
+
+// ArrayRecv はバイナリ形式の配列を受け取る (array_recv 相当)。
+// 要素の型は elemType と一致しなければならない。
+func ArrayRecv(buf []byte, elemType catalog.Oid) (*Array, error) {
+	if len(buf) < 12 {
+		return nil, errInsufficientData
+	}
+	ndim := int32(binary.BigEndian.Uint32(buf))
+	flags := int32(binary.BigEndian.Uint32(buf[4:]))
+	elem := catalog.Oid(binary.BigEndian.Uint32(buf[8:]))
+	buf = buf[12:]
+	if ndim < 0 {
+		return nil, newError(errcodes.InvalidBinaryRepresentation, "invalid number of dimensions: %d", ndim)
+	}
+	if ndim > MaxDim {
+		return nil, newError(errcodes.ProgramLimitExceeded, "number of array dimensions (%d) exceeds the maximum allowed (%d)", ndim, MaxDim)
+	}
+	if flags != 0 && flags != 1 {
+		return nil, newError(errcodes.InvalidBinaryRepresentation, "invalid array flags")
+	}
+	if elem != elemType {
+		return nil, newError(errcodes.DatatypeMismatch, "binary data has array element type %d (%s) instead of expected %d (%s)",
+			elem, catalog.FormatType(elem), elemType, catalog.FormatType(elemType))
+	}
+
+	arr := &Array{ElemType: elemType}
+	nitems := 1
+	for i := int32(0); i < ndim; i++ {
+		if len(buf) < 8 {
+			return nil, errInsufficientData
+		}
+		d := int(int32(binary.BigEndian.Uint32(buf)))
+		lb := int(int32(binary.BigEndian.Uint32(buf[4:])))
+		buf = buf[8:]
+		if d < 0 {
+			return nil, newError(errcodes.ProgramLimitExceeded, "array size exceeds the maximum allowed")
+		}
+		arr.Dims = append(arr.Dims, d)
+		arr.LBounds = append(arr.LBounds, lb)
+		nitems *= d
+	}
+	if ndim > 0 && nitems == 0 {
+		// 要素数が 0 の次元を持つ配列は、要素のない配列として扱う
+		arr.Dims, arr.LBounds = nil, nil
+	}
+	if nitems > len(buf)/4 {
+		return nil, errInsufficientData
+	}
+	if arr.Dims == nil {
+		nitems = 0
+	}
+
+	arr.Elems = make([]Datum, nitems)
+	for i := range arr.Elems {
+		if len(buf) < 4 {
+			return nil, errInsufficientData
+		}
+		n := int32(binary.BigEndian.Uint32(buf))
+		buf = buf[4:]
+		if n == -1 {
+			continue
+		}
+		if n < 0 || int(n) > len(buf) {
+			return nil, errInsufficientData
+		}
+		d, err := ReceiveFunctionCall(elemType, buf[:n])
+		if err != nil {
+			return nil, newError(errcodes.InvalidBinaryRepresentation, "improper binary format in array element %d", i+1)
+		}
+		arr.Elems[i] = d
+		buf = buf[n:]
+	}
+	if len(buf) != 0 {
+		return nil, errInsufficientData
+	}
+	return arr, nil
+}
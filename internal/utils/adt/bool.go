@@ -0,0 +1,47 @@
+package adt
+
+import (
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// bool 型 (bool.c 相当)
+// ----------------------------------------------------------------
+
+// ParseBool は真偽値の文字列表現を解析する (parse_bool 相当)。
+// "true", "yes", "on", "1" およびその一意な接頭辞を受け付ける。
+func ParseBool(s string) (bool, bool) {
+	str := strings.ToLower(strings.TrimSpace(s))
+	if str == "" {
+		return false, false
+	}
+	switch {
+	case strings.HasPrefix("true", str), strings.HasPrefix("yes", str):
+		return true, true
+	case strings.HasPrefix("false", str), strings.HasPrefix("no", str):
+		return false, true
+	case str == "on", str == "1":
+		return true, true
+	case len(str) >= 2 && strings.HasPrefix("off", str), str == "0":
+		return false, true
+	}
+	return false, false
+}
+
+// BoolIn は boolin 相当。
+func BoolIn(s string) (bool, error) {
+	if b, ok := ParseBool(s); ok {
+		return b, nil
+	}
+	return false, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type boolean: \"%s\"", s)
+}
+
+// BoolOut は boolout 相当。
+func BoolOut(b bool) string {
+	if b {
+		return "t"
+	}
+	return "f"
+}
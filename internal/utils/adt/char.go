@@ -0,0 +1,31 @@
+package adt
+
+import "fmt"
+
+// ----------------------------------------------------------------
+// "char" 型 (char.c 相当)
+// ----------------------------------------------------------------
+// 1バイトの値。テキスト表現では 0 を空文字列で、最上位ビットが立ったバイトを
+// "\ooo" (8進数) で表す。バイナリ形式はそのバイト。
+
+// CharIn は "char" のテキスト表現を解析する (charin 相当)。2バイト目以降は捨てる。
+func CharIn(s string) byte {
+	if len(s) == 4 && s[0] == '\\' && s[1] >= '0' && s[1] <= '3' && isOctal(s[2]) && isOctal(s[3]) {
+		return (s[1]-'0')<<6 | (s[2]-'0')<<3 | (s[3] - '0')
+	}
+	if s == "" {
+		return 0
+	}
+	return s[0]
+}
+
+// CharOut は "char" のテキスト表現を返す (charout 相当)
+func CharOut(c byte) string {
+	switch {
+	case c == 0:
+		return ""
+	case c >= 0x80:
+		return fmt.Sprintf("\\%03o", c)
+	}
+	return string(rune(c))
+}
@@ -0,0 +1,131 @@
+package adt
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// ----------------------------------------------------------------
+// date と time (utils/adt/date.c 相当)
+// ----------------------------------------------------------------
+// date は 2000-01-01 からの日数で表し、最小値と最大値はそれぞれ -infinity と infinity を表す。
+// time は 00:00:00 からのマイクロ秒で表す。バイナリ形式はそれぞれ int32 と int64。
+// 入出力の形式は timestamp と同じく ISO 8601 形式だけを扱う。
+
+// DateADT は date の値 (DateADT 相当)
+type DateADT int32
+
+// TimeADT は time without time zone の値 (TimeADT 相当)
+type TimeADT int64
+
+const (
+	// DateNoBegin は date の -infinity (DATEVAL_NOBEGIN 相当)
+	DateNoBegin DateADT = math.MinInt32
+	// DateNoEnd は date の infinity (DATEVAL_NOEND 相当)
+	DateNoEnd DateADT = math.MaxInt32
+)
+
+// usecsPerDay は1日のマイクロ秒 (USECS_PER_DAY 相当)
+const usecsPerDay = 86400 * 1000000
+
+// DateIn は date のテキスト表現を解析する (date_in 相当)。時刻が続く場合は無視する。
+func DateIn(s string) (DateADT, error) {
+	str := strings.TrimSpace(s)
+	switch strings.ToLower(str) {
+	case "infinity", "+infinity":
+		return DateNoEnd, nil
+	case "-infinity":
+		return DateNoBegin, nil
+	case "epoch":
+		return dateFromTime(time.Unix(0, 0).UTC()), nil
+	case "today", "now":
+		return dateFromTime(sim.Now().UTC()), nil
+	}
+
+	m := timestampPattern.FindStringSubmatch(str)
+	if m == nil {
+		return 0, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type date: \"%s\"", s)
+	}
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	if strings.EqualFold(m[9], "bc") {
+		year = 1 - year
+	}
+	if month < 1 || month > 12 || day < 1 || day > daysIn(year, month) {
+		return 0, newError(errcodes.DatetimeFieldOverflow, "date/time field value out of range: \"%s\"", s)
+	}
+	return dateFromTime(time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)), nil
+}
+
+// dateFromTime は t の日付を 2000-01-01 からの日数にする
+func dateFromTime(t time.Time) DateADT {
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return DateADT((d.Unix() - postgresEpoch.Unix()) / 86400)
+}
+
+// DateOut は date のテキスト表現を返す (date_out 相当)
+func DateOut(d DateADT) string {
+	switch d {
+	case DateNoBegin:
+		return "-infinity"
+	case DateNoEnd:
+		return "infinity"
+	}
+	t := time.Unix(postgresEpoch.Unix()+int64(d)*86400, 0).UTC()
+	out := encodeDate(t.Year(), t.Month(), t.Day())
+	if t.Year() <= 0 {
+		out += " BC"
+	}
+	return out
+}
+
+var timePattern = regexp.MustCompile(`(?i)^(\d{1,2}):(\d{2})(?::(\d{2})(\.\d+)?)?` +
+	`\s*(?:z|utc|gmt|[+-]\d{1,2}(?::?\d{2})?)?$`)
+
+// TimeIn は time のテキスト表現を解析する (time_in 相当)。時間帯は無視する。
+func TimeIn(s string) (TimeADT, error) {
+	str := strings.TrimSpace(s)
+	m := timePattern.FindStringSubmatch(str)
+	if m == nil {
+		return 0, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type time: \"%s\"", s)
+	}
+	hour, _ := strconv.Atoi(m[1])
+	min, _ := strconv.Atoi(m[2])
+	sec, _ := strconv.Atoi(m[3])
+	usec := parseFraction(m[4])
+	if min > 59 || sec > 60 {
+		return 0, newError(errcodes.DatetimeFieldOverflow, "date/time field value out of range: \"%s\"", s)
+	}
+	t := ((int64(hour)*60+int64(min))*60+int64(sec))*1000000 + int64(usec)
+	// 24:00:00 までを受け付ける
+	if t > usecsPerDay {
+		return 0, newError(errcodes.DatetimeFieldOverflow, "date/time field value out of range: \"%s\"", s)
+	}
+	return TimeADT(t), nil
+}
+
+// TimeOut は time のテキスト表現を返す (time_out 相当)
+func TimeOut(t TimeADT) string {
+	usec := int64(t)
+	out := fmt.Sprintf("%02d:%02d:%02d", usec/3600000000, usec/60000000%60, usec/1000000%60)
+	if frac := usec % 1000000; frac != 0 {
+		out += strings.TrimRight(fmt.Sprintf(".%06d", frac), "0")
+	}
+	return out
+}
+
+// TimeRecv はバイナリ形式の time を受け取る (time_recv 相当)
+func TimeRecv(usec int64) (TimeADT, error) {
+	if usec < 0 || usec > usecsPerDay {
+		return 0, newError(errcodes.DatetimeValueOutOfRange, "time out of range")
+	}
+	return TimeADT(usec), nil
+}
@@ -0,0 +1,87 @@
+package adt
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 浮動小数点型 (float.c 相当)
+// ----------------------------------------------------------------
+
+// Float4In は float4in 相当。
+func Float4In(s string) (float32, error) {
+	str := strings.TrimSpace(s)
+	switch strings.ToLower(str) {
+	case "nan":
+		return float32(math.NaN()), nil
+	case "infinity", "+infinity", "inf", "+inf":
+		return float32(math.Inf(1)), nil
+	case "-infinity", "-inf":
+		return float32(math.Inf(-1)), nil
+	}
+	v, err := strconv.ParseFloat(str, 32)
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return 0, newError(errcodes.NumericValueOutOfRange, "\"%s\" is out of range for type real", s)
+		}
+		return 0, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type real: \"%s\"", s)
+	}
+	return float32(v), nil
+}
+
+// Float4Out は float4out 相当。Float8Out と同じく最短の表現を出力する
+// (float_to_shortest_decimal 相当)。指数が -4 未満または 6 以上の場合は指数表記にする。
+func Float4Out(f float32) string {
+	return formatShortest(float64(f), 32, 6)
+}
+
+// Float8In は float8in 相当。
+func Float8In(s string) (float64, error) {
+	str := strings.TrimSpace(s)
+	switch strings.ToLower(str) {
+	case "nan":
+		return math.NaN(), nil
+	case "infinity", "+infinity", "inf", "+inf":
+		return math.Inf(1), nil
+	case "-infinity", "-inf":
+		return math.Inf(-1), nil
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return 0, newError(errcodes.NumericValueOutOfRange, "\"%s\" is out of range for type double precision", s)
+		}
+		return 0, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type double precision: \"%s\"", s)
+	}
+	return v, nil
+}
+
+// Float8Out は float8out 相当。extra_float_digits = 1 (既定値) と同じく、
+// 値を一意に復元できる最短の表現を出力する (double_to_shortest_decimal 相当)。
+// 指数が -4 未満または 15 以上の場合は指数表記にする。
+func Float8Out(f float64) string {
+	return formatShortest(f, 64, 15)
+}
+
+// formatShortest は bitSize ビットの浮動小数点数を、値を一意に復元できる最短の表現にする。
+// 指数が -4 未満または maxExp 以上の場合は指数表記にする。
+func formatShortest(f float64, bitSize, maxExp int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	e := strconv.FormatFloat(f, 'e', -1, bitSize)
+	exp, _ := strconv.Atoi(e[strings.IndexByte(e, 'e')+1:])
+	if exp < -4 || exp >= maxExp {
+		return e
+	}
+	return strconv.FormatFloat(f, 'f', -1, bitSize)
+}
@@ -0,0 +1,56 @@
+package adt
+
+import "math"
+
+// ----------------------------------------------------------------
+// 幾何データ型の定義 (utils/geo_decls.h 相当)
+// ----------------------------------------------------------------
+// 座標の比較には誤差 EPSILON を許す「あいまいな」比較を使う。
+// 等しいかどうかの判定 (~=) や位置関係の演算子は全てこの比較に基づく。
+
+// EPSILON は座標の比較で同じとみなす誤差。
+const EPSILON = 1.0e-06
+
+// FPzero は a が 0 とみなせるかを返す
+func FPzero(a float64) bool { return math.Abs(a) <= EPSILON }
+
+// FPeq は a と b が等しいとみなせるかを返す。同符号の無限大どうしは等しい。
+func FPeq(a, b float64) bool { return a == b || math.Abs(a-b) <= EPSILON }
+
+func FPne(a, b float64) bool { return a != b && math.Abs(a-b) > EPSILON }
+func FPlt(a, b float64) bool { return b-a > EPSILON }
+func FPle(a, b float64) bool { return a-b <= EPSILON }
+func FPgt(a, b float64) bool { return a-b > EPSILON }
+func FPge(a, b float64) bool { return b-a <= EPSILON }
+
+// Point は点 (Point 相当)
+type Point struct {
+	X, Y float64
+}
+
+// LSeg は線分 (LSEG 相当)
+type LSeg struct {
+	P [2]Point
+}
+
+// Line は直線 Ax + By + C = 0 (LINE 相当)
+type Line struct {
+	A, B, C float64
+}
+
+// Box は矩形 (BOX 相当)。High は右上、Low は左下の頂点。
+type Box struct {
+	High, Low Point
+}
+
+// Polygon は多角形 (POLYGON 相当)。BoundBox は全頂点を囲む矩形。
+type Polygon struct {
+	BoundBox Box
+	P        []Point
+}
+
+// Circle は円 (CIRCLE 相当)
+type Circle struct {
+	Center Point
+	Radius float64
+}
@@ -0,0 +1,1052 @@
+package adt
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 幾何データ型 (geo_ops.c 相当)
+// ----------------------------------------------------------------
+// point, lseg, line, box, polygon, circle の入出力関数と演算子を提供する。
+// 演算子は SQL から呼べるようになるまでの間、Go の関数として公開する。
+// 関数名の後の括弧内は、対応する SQL の演算子を表す。
+
+// ----------------------------------------------------------------
+// 入力文字列の解析 (pair_decode, path_decode 相当)
+// ----------------------------------------------------------------
+
+// geoDecoder は幾何型の入力文字列を先頭から読み進める。
+type geoDecoder struct {
+	s    string
+	pos  int
+	typ  string
+	orig string
+}
+
+func newGeoDecoder(s, typ string) *geoDecoder {
+	return &geoDecoder{s: s, typ: typ, orig: s}
+}
+
+func (d *geoDecoder) invalid() error {
+	return newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type %s: \"%s\"", d.typ, d.orig)
+}
+
+func (d *geoDecoder) skipSpace() {
+	for d.pos < len(d.s) && isSpace(d.s[d.pos]) {
+		d.pos++
+	}
+}
+
+func (d *geoDecoder) peek() byte {
+	d.skipSpace()
+	if d.pos >= len(d.s) {
+		return 0
+	}
+	return d.s[d.pos]
+}
+
+// accept は次の文字が c であれば読み進めて true を返す。
+func (d *geoDecoder) accept(c byte) bool {
+	if d.peek() == c {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+func (d *geoDecoder) expect(c byte) error {
+	if !d.accept(c) {
+		return d.invalid()
+	}
+	return nil
+}
+
+// end は入力を全て読み終えたことを確認する。
+func (d *geoDecoder) end() error {
+	if d.peek() != 0 {
+		return d.invalid()
+	}
+	return nil
+}
+
+// single は浮動小数点数を1つ読む (single_decode 相当)。
+// strtod と同じく、数値として読める最長の部分を読む。
+func (d *geoDecoder) single() (float64, error) {
+	d.skipSpace()
+	start := d.pos
+	if d.pos < len(d.s) && (d.s[d.pos] == '+' || d.s[d.pos] == '-') {
+		d.pos++
+	}
+	if d.pos < len(d.s) && isAlpha(d.s[d.pos]) {
+		// "Infinity", "NaN" など
+		for d.pos < len(d.s) && isAlpha(d.s[d.pos]) {
+			d.pos++
+		}
+	} else {
+		for d.pos < len(d.s) && (isDigit(d.s[d.pos]) || d.s[d.pos] == '.') {
+			d.pos++
+		}
+		if d.pos < len(d.s) && (d.s[d.pos] == 'e' || d.s[d.pos] == 'E') {
+			d.pos++
+			if d.pos < len(d.s) && (d.s[d.pos] == '+' || d.s[d.pos] == '-') {
+				d.pos++
+			}
+			for d.pos < len(d.s) && isDigit(d.s[d.pos]) {
+				d.pos++
+			}
+		}
+	}
+	if d.pos == start {
+		return 0, d.invalid()
+	}
+	v, err := Float8In(d.s[start:d.pos])
+	if err != nil {
+		return 0, d.invalid()
+	}
+	return v, nil
+}
+
+// pair は "(x, y)" または "x, y" の形の点を読む (pair_decode 相当)
+func (d *geoDecoder) pair() (Point, error) {
+	delim := d.accept('(')
+	x, err := d.single()
+	if err != nil {
+		return Point{}, err
+	}
+	if err := d.expect(','); err != nil {
+		return Point{}, err
+	}
+	y, err := d.single()
+	if err != nil {
+		return Point{}, err
+	}
+	if delim {
+		if err := d.expect(')'); err != nil {
+			return Point{}, err
+		}
+	}
+	return Point{X: x, Y: y}, nil
+}
+
+// path は npts 個の点の並びを読む (path_decode 相当)。点の並び全体を
+// 括弧で囲んでもよい。allowOpen が true の場合は [ ] で囲むこともでき、
+// その場合は isOpen が true になる。
+func (d *geoDecoder) path(npts int, allowOpen bool) (pts []Point, isOpen bool, err error) {
+	depth := 0
+	switch d.peek() {
+	case '[':
+		if allowOpen {
+			isOpen = true
+			depth++
+			d.pos++
+		}
+	case '(':
+		// "((x1,y1),...)" のように点の外側にも括弧があるか、
+		// "(x1,y1,...)" のように括弧が1組しかない場合は、外側の括弧とみなす
+		cp := d.pos + 1
+		for cp < len(d.s) && isSpace(d.s[cp]) {
+			cp++
+		}
+		if (cp < len(d.s) && d.s[cp] == '(') || strings.LastIndexByte(d.s, '(') == d.pos {
+			depth++
+			d.pos = cp
+		}
+	}
+
+	pts = make([]Point, npts)
+	for i := range pts {
+		if pts[i], err = d.pair(); err != nil {
+			return nil, false, err
+		}
+		if i < npts-1 {
+			if err := d.expect(','); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	for ; depth > 0; depth-- {
+		if !d.accept(')') && !(isOpen && depth == 1 && d.accept(']')) {
+			return nil, false, d.invalid()
+		}
+	}
+	return pts, isOpen, nil
+}
+
+// pairCount は入力文字列に含まれる点の数を数える (pair_count 相当)。
+// 座標は "," で区切られるため、"," の数は点の数の2倍より1少ない。
+func pairCount(s string) int {
+	ndelim := strings.Count(s, ",")
+	if ndelim%2 == 0 {
+		return -1
+	}
+	return (ndelim + 1) / 2
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isAlpha(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+// ----------------------------------------------------------------
+// 出力文字列の組み立て (pair_encode, path_encode 相当)
+// ----------------------------------------------------------------
+
+func pairEncode(sb *strings.Builder, p Point) {
+	sb.WriteByte('(')
+	sb.WriteString(Float8Out(p.X))
+	sb.WriteByte(',')
+	sb.WriteString(Float8Out(p.Y))
+	sb.WriteByte(')')
+}
+
+// pathEncode は点の並びを open, close で囲んで出力する。
+// open が 0 の場合は囲まない。
+func pathEncode(open, close byte, pts []Point) string {
+	var sb strings.Builder
+	if open != 0 {
+		sb.WriteByte(open)
+	}
+	for i, p := range pts {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		pairEncode(&sb, p)
+	}
+	if close != 0 {
+		sb.WriteByte(close)
+	}
+	return sb.String()
+}
+
+// ----------------------------------------------------------------
+// バイナリ形式 (point_send, point_recv など相当)
+// ----------------------------------------------------------------
+// 座標は float8 のバイナリ形式で順に並べる。
+
+func appendFloat8s(buf []byte, fs ...float64) []byte {
+	for _, f := range fs {
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(f))
+	}
+	return buf
+}
+
+// readFloat8s は buf から n 個の float8 を読む。buf の長さがちょうどでなければエラー。
+func readFloat8s(buf []byte, n int) ([]float64, error) {
+	if len(buf) != n*8 {
+		return nil, errInsufficientData
+	}
+	fs := make([]float64, n)
+	for i := range fs {
+		fs[i] = math.Float64frombits(binary.BigEndian.Uint64(buf[i*8:]))
+	}
+	return fs, nil
+}
+
+// ----------------------------------------------------------------
+// point
+// ----------------------------------------------------------------
+
+// PointIn は point_in 相当。
+func PointIn(s string) (*Point, error) {
+	d := newGeoDecoder(s, "point")
+	p, err := d.pair()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.end(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// PointOut は point_out 相当。
+func PointOut(p *Point) string {
+	return pathEncode(0, 0, []Point{*p})
+}
+
+// PointRecv は point_recv 相当。
+func PointRecv(buf []byte) (*Point, error) {
+	fs, err := readFloat8s(buf, 2)
+	if err != nil {
+		return nil, err
+	}
+	return &Point{X: fs[0], Y: fs[1]}, nil
+}
+
+// PointSend は point_send 相当。
+func PointSend(p *Point) []byte {
+	return appendFloat8s(nil, p.X, p.Y)
+}
+
+// PointDistance は2点間の距離を返す (point_distance, <-> 相当)
+func PointDistance(p1, p2 *Point) float64 {
+	return math.Hypot(p1.X-p2.X, p1.Y-p2.Y)
+}
+
+// PointEq (~=)
+func PointEq(p1, p2 *Point) bool { return FPeq(p1.X, p2.X) && FPeq(p1.Y, p2.Y) }
+
+// PointLeft (<<)
+func PointLeft(p1, p2 *Point) bool { return FPlt(p1.X, p2.X) }
+
+// PointRight (>>)
+func PointRight(p1, p2 *Point) bool { return FPgt(p1.X, p2.X) }
+
+// PointAbove (|>>)
+func PointAbove(p1, p2 *Point) bool { return FPgt(p1.Y, p2.Y) }
+
+// PointBelow (<<|)
+func PointBelow(p1, p2 *Point) bool { return FPlt(p1.Y, p2.Y) }
+
+// PointVert は2点が垂直に並んでいるかを返す (?|)
+func PointVert(p1, p2 *Point) bool { return FPeq(p1.X, p2.X) }
+
+// PointHoriz は2点が水平に並んでいるかを返す (?-)
+func PointHoriz(p1, p2 *Point) bool { return FPeq(p1.Y, p2.Y) }
+
+// ----------------------------------------------------------------
+// lseg
+// ----------------------------------------------------------------
+
+// LsegIn は lseg_in 相当。"[(x1,y1),(x2,y2)]" などの形式を受け付ける。
+func LsegIn(s string) (*LSeg, error) {
+	d := newGeoDecoder(s, "lseg")
+	pts, _, err := d.path(2, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.end(); err != nil {
+		return nil, err
+	}
+	return &LSeg{P: [2]Point{pts[0], pts[1]}}, nil
+}
+
+// LsegOut は lseg_out 相当。
+func LsegOut(l *LSeg) string {
+	return pathEncode('[', ']', l.P[:])
+}
+
+// LsegRecv は lseg_recv 相当。
+func LsegRecv(buf []byte) (*LSeg, error) {
+	fs, err := readFloat8s(buf, 4)
+	if err != nil {
+		return nil, err
+	}
+	return &LSeg{P: [2]Point{{X: fs[0], Y: fs[1]}, {X: fs[2], Y: fs[3]}}}, nil
+}
+
+// LsegSend は lseg_send 相当。
+func LsegSend(l *LSeg) []byte {
+	return appendFloat8s(nil, l.P[0].X, l.P[0].Y, l.P[1].X, l.P[1].Y)
+}
+
+// LsegLength は線分の長さを返す (lseg_length, @-@ 相当)
+func LsegLength(l *LSeg) float64 {
+	return PointDistance(&l.P[0], &l.P[1])
+}
+
+// LsegCenter は線分の中点を返す (lseg_center, @@ 相当)
+func LsegCenter(l *LSeg) *Point {
+	return &Point{X: (l.P[0].X + l.P[1].X) / 2, Y: (l.P[0].Y + l.P[1].Y) / 2}
+}
+
+// lsegContainPoint は点が線分上にあるかを返す (lseg_contain_point 相当)
+func lsegContainPoint(l *LSeg, p *Point) bool {
+	return FPeq(PointDistance(p, &l.P[0])+PointDistance(p, &l.P[1]), LsegLength(l))
+}
+
+// lsegInterptLseg は2つの線分の交点を返す (lseg_interpt_lseg 相当)。
+// 交わらない場合、または2つの線分が平行な場合は false を返す。
+func lsegInterptLseg(l1, l2 *LSeg) (Point, bool) {
+	line1 := lineConstructPts(&l1.P[0], &l1.P[1])
+	line2 := lineConstructPts(&l2.P[0], &l2.P[1])
+	p, ok := lineInterptLine(&line1, &line2)
+	if !ok || !lsegContainPoint(l1, &p) || !lsegContainPoint(l2, &p) {
+		return Point{}, false
+	}
+	return p, true
+}
+
+// LsegIntersect は2つの線分が交わるかを返す (lseg_intersect, ?# 相当)
+func LsegIntersect(l1, l2 *LSeg) bool {
+	_, ok := lsegInterptLseg(l1, l2)
+	return ok
+}
+
+// lsegClosept は線分上で点 p に最も近い点を返す (lseg_closept_point 相当)
+func lsegClosept(l *LSeg, p *Point) Point {
+	dx, dy := l.P[1].X-l.P[0].X, l.P[1].Y-l.P[0].Y
+	norm := dx*dx + dy*dy
+	if norm == 0 {
+		return l.P[0]
+	}
+	t := ((p.X-l.P[0].X)*dx + (p.Y-l.P[0].Y)*dy) / norm
+	t = math.Max(0, math.Min(1, t))
+	return Point{X: l.P[0].X + t*dx, Y: l.P[0].Y + t*dy}
+}
+
+// DistPs は点と線分の距離を返す (dist_ps, <-> 相当)
+func DistPs(p *Point, l *LSeg) float64 {
+	c := lsegClosept(l, p)
+	return PointDistance(p, &c)
+}
+
+// ----------------------------------------------------------------
+// line
+// ----------------------------------------------------------------
+
+// LineIn は line_in 相当。"{A,B,C}" の形式、または直線上の2点で指定する。
+func LineIn(s string) (*Line, error) {
+	d := newGeoDecoder(s, "line")
+	if d.accept('{') {
+		var fs [3]float64
+		for i := range fs {
+			if i > 0 {
+				if err := d.expect(','); err != nil {
+					return nil, err
+				}
+			}
+			f, err := d.single()
+			if err != nil {
+				return nil, err
+			}
+			fs[i] = f
+		}
+		if err := d.expect('}'); err != nil {
+			return nil, err
+		}
+		if err := d.end(); err != nil {
+			return nil, err
+		}
+		if FPzero(fs[0]) && FPzero(fs[1]) {
+			return nil, newError(errcodes.InvalidParameterValue, "invalid line specification: A and B cannot both be zero")
+		}
+		return &Line{A: fs[0], B: fs[1], C: fs[2]}, nil
+	}
+
+	pts, _, err := d.path(2, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.end(); err != nil {
+		return nil, err
+	}
+	if PointEq(&pts[0], &pts[1]) {
+		return nil, newError(errcodes.InvalidParameterValue, "invalid line specification: must be two distinct points")
+	}
+	l := lineConstructPts(&pts[0], &pts[1])
+	return &l, nil
+}
+
+// LineOut は line_out 相当。
+func LineOut(l *Line) string {
+	return "{" + Float8Out(l.A) + "," + Float8Out(l.B) + "," + Float8Out(l.C) + "}"
+}
+
+// LineRecv は line_recv 相当。
+func LineRecv(buf []byte) (*Line, error) {
+	fs, err := readFloat8s(buf, 3)
+	if err != nil {
+		return nil, err
+	}
+	if FPzero(fs[0]) && FPzero(fs[1]) {
+		return nil, newError(errcodes.InvalidParameterValue, "invalid line specification: A and B cannot both be zero")
+	}
+	return &Line{A: fs[0], B: fs[1], C: fs[2]}, nil
+}
+
+// LineSend は line_send 相当。
+func LineSend(l *Line) []byte {
+	return appendFloat8s(nil, l.A, l.B, l.C)
+}
+
+// lineConstructPts は2点を通る直線を返す (line_construct_pts 相当)
+func lineConstructPts(p1, p2 *Point) Line {
+	switch {
+	case FPeq(p1.X, p2.X):
+		// 垂直な直線 x = p1.X
+		return Line{A: -1, B: 0, C: p1.X}
+	case FPeq(p1.Y, p2.Y):
+		// 水平な直線 y = p1.Y
+		return Line{A: 0, B: -1, C: p1.Y}
+	}
+	m := (p2.Y - p1.Y) / (p2.X - p1.X)
+	return Line{A: m, B: -1, C: p1.Y - m*p1.X}
+}
+
+// lineInterptLine は2つの直線の交点を返す (line_interpt_line 相当)。平行な場合は false。
+func lineInterptLine(l1, l2 *Line) (Point, bool) {
+	var x, y float64
+	switch {
+	case !FPzero(l1.B):
+		if FPeq(l2.A, l1.A*(l2.B/l1.B)) {
+			return Point{}, false
+		}
+		x = (l1.B*l2.C - l2.B*l1.C) / (l1.A*l2.B - l2.A*l1.B)
+		y = (l1.A*x + l1.C) / -l1.B
+	case !FPzero(l2.B):
+		if FPeq(l1.A, l2.A*(l1.B/l2.B)) {
+			return Point{}, false
+		}
+		x = (l2.B*l1.C - l1.B*l2.C) / (l2.A*l1.B - l1.A*l2.B)
+		y = (l2.A*x + l2.C) / -l2.B
+	default:
+		return Point{}, false
+	}
+	return Point{X: x, Y: y}, true
+}
+
+// LineParallel は2つの直線が平行かを返す (line_parallel, ?|| 相当)
+func LineParallel(l1, l2 *Line) bool {
+	_, ok := lineInterptLine(l1, l2)
+	return !ok
+}
+
+// LineDistance は2つの直線の距離を返す (line_distance, <-> 相当)。交わる場合は 0。
+func LineDistance(l1, l2 *Line) float64 {
+	if _, ok := lineInterptLine(l1, l2); ok {
+		return 0
+	}
+	ratio := 1.0
+	switch {
+	case !FPzero(l1.A) && !math.IsNaN(l1.A) && !FPzero(l2.A) && !math.IsNaN(l2.A):
+		ratio = l1.A / l2.A
+	case !FPzero(l1.B) && !math.IsNaN(l1.B) && !FPzero(l2.B) && !math.IsNaN(l2.B):
+		ratio = l1.B / l2.B
+	}
+	return math.Abs(l1.C-ratio*l2.C) / math.Hypot(l1.A, l1.B)
+}
+
+// DistPl は点と直線の距離を返す (dist_pl, <-> 相当)
+func DistPl(p *Point, l *Line) float64 {
+	return math.Abs(l.A*p.X+l.B*p.Y+l.C) / math.Hypot(l.A, l.B)
+}
+
+// ----------------------------------------------------------------
+// box
+// ----------------------------------------------------------------
+
+// BoxConstruct は2つの頂点から矩形を作る (box_construct 相当)。
+// 頂点はどの2隅でもよく、右上と左下に並べ替える。
+func BoxConstruct(p1, p2 *Point) *Box {
+	return &Box{
+		High: Point{X: math.Max(p1.X, p2.X), Y: math.Max(p1.Y, p2.Y)},
+		Low:  Point{X: math.Min(p1.X, p2.X), Y: math.Min(p1.Y, p2.Y)},
+	}
+}
+
+// BoxIn は box_in 相当。"(x1,y1),(x2,y2)" などの形式を受け付ける。
+func BoxIn(s string) (*Box, error) {
+	d := newGeoDecoder(s, "box")
+	pts, _, err := d.path(2, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.end(); err != nil {
+		return nil, err
+	}
+	return BoxConstruct(&pts[0], &pts[1]), nil
+}
+
+// BoxOut は box_out 相当。
+func BoxOut(b *Box) string {
+	return pathEncode(0, 0, []Point{b.High, b.Low})
+}
+
+// BoxRecv は box_recv 相当。
+func BoxRecv(buf []byte) (*Box, error) {
+	fs, err := readFloat8s(buf, 4)
+	if err != nil {
+		return nil, err
+	}
+	return BoxConstruct(&Point{X: fs[0], Y: fs[1]}, &Point{X: fs[2], Y: fs[3]}), nil
+}
+
+// BoxSend は box_send 相当。
+func BoxSend(b *Box) []byte {
+	return appendFloat8s(nil, b.High.X, b.High.Y, b.Low.X, b.Low.Y)
+}
+
+// BoxSame (~=)
+func BoxSame(b1, b2 *Box) bool { return PointEq(&b1.High, &b2.High) && PointEq(&b1.Low, &b2.Low) }
+
+// BoxOverlap (&&)
+func BoxOverlap(b1, b2 *Box) bool {
+	return FPle(b1.Low.X, b2.High.X) && FPle(b2.Low.X, b1.High.X) &&
+		FPle(b1.Low.Y, b2.High.Y) && FPle(b2.Low.Y, b1.High.Y)
+}
+
+// BoxLeft (<<)
+func BoxLeft(b1, b2 *Box) bool { return FPlt(b1.High.X, b2.Low.X) }
+
+// BoxOverLeft (&<)
+func BoxOverLeft(b1, b2 *Box) bool { return FPle(b1.High.X, b2.High.X) }
+
+// BoxRight (>>)
+func BoxRight(b1, b2 *Box) bool { return FPgt(b1.Low.X, b2.High.X) }
+
+// BoxOverRight (&>)
+func BoxOverRight(b1, b2 *Box) bool { return FPge(b1.Low.X, b2.Low.X) }
+
+// BoxBelow (<<|)
+func BoxBelow(b1, b2 *Box) bool { return FPlt(b1.High.Y, b2.Low.Y) }
+
+// BoxOverBelow (&<|)
+func BoxOverBelow(b1, b2 *Box) bool { return FPle(b1.High.Y, b2.High.Y) }
+
+// BoxAbove (|>>)
+func BoxAbove(b1, b2 *Box) bool { return FPgt(b1.Low.Y, b2.High.Y) }
+
+// BoxOverAbove (|&>)
+func BoxOverAbove(b1, b2 *Box) bool { return FPge(b1.Low.Y, b2.Low.Y) }
+
+// BoxContain は b1 が b2 を含むかを返す (box_contain, @> 相当)
+func BoxContain(b1, b2 *Box) bool {
+	return FPge(b1.High.X, b2.High.X) && FPle(b1.Low.X, b2.Low.X) &&
+		FPge(b1.High.Y, b2.High.Y) && FPle(b1.Low.Y, b2.Low.Y)
+}
+
+// BoxContainPt は矩形が点を含むかを返す (box_contain_pt, @> 相当)
+func BoxContainPt(b *Box, p *Point) bool {
+	return b.High.X >= p.X && b.Low.X <= p.X && b.High.Y >= p.Y && b.Low.Y <= p.Y
+}
+
+// BoxArea は矩形の面積を返す (box_area, area() 相当)
+func BoxArea(b *Box) float64 {
+	return (b.High.X - b.Low.X) * (b.High.Y - b.Low.Y)
+}
+
+// BoxCenter は矩形の中心を返す (box_center, @@ 相当)
+func BoxCenter(b *Box) *Point {
+	return &Point{X: (b.High.X + b.Low.X) / 2, Y: (b.High.Y + b.Low.Y) / 2}
+}
+
+// BoxDistance は2つの矩形の中心間の距離を返す (box_distance, <-> 相当)
+func BoxDistance(b1, b2 *Box) float64 {
+	return PointDistance(BoxCenter(b1), BoxCenter(b2))
+}
+
+// BoxIntersect は2つの矩形の共通部分を返す (box_intersect, # 相当)。重ならない場合は nil。
+func BoxIntersect(b1, b2 *Box) *Box {
+	if !BoxOverlap(b1, b2) {
+		return nil
+	}
+	return &Box{
+		High: Point{X: math.Min(b1.High.X, b2.High.X), Y: math.Min(b1.High.Y, b2.High.Y)},
+		Low:  Point{X: math.Max(b1.Low.X, b2.Low.X), Y: math.Max(b1.Low.Y, b2.Low.Y)},
+	}
+}
+
+// DistPb は点と矩形の距離を返す (dist_pb, <-> 相当)。点が矩形の内側にあれば 0。
+func DistPb(p *Point, b *Box) float64 {
+	if BoxContainPt(b, p) {
+		return 0
+	}
+	closest := Point{
+		X: math.Max(b.Low.X, math.Min(b.High.X, p.X)),
+		Y: math.Max(b.Low.Y, math.Min(b.High.Y, p.Y)),
+	}
+	return PointDistance(p, &closest)
+}
+
+// ----------------------------------------------------------------
+// polygon
+// ----------------------------------------------------------------
+
+// makeBoundBox は多角形の全頂点を囲む矩形を設定する (make_bound_box 相当)
+func (poly *Polygon) makeBoundBox() {
+	b := Box{High: poly.P[0], Low: poly.P[0]}
+	for _, p := range poly.P[1:] {
+		b.High.X = math.Max(b.High.X, p.X)
+		b.High.Y = math.Max(b.High.Y, p.Y)
+		b.Low.X = math.Min(b.Low.X, p.X)
+		b.Low.Y = math.Min(b.Low.Y, p.Y)
+	}
+	poly.BoundBox = b
+}
+
+// PolyIn は poly_in 相当。"((x1,y1),...,(xn,yn))" などの形式を受け付ける。
+func PolyIn(s string) (*Polygon, error) {
+	d := newGeoDecoder(s, "polygon")
+	npts := pairCount(s)
+	if npts <= 0 {
+		return nil, d.invalid()
+	}
+	pts, _, err := d.path(npts, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.end(); err != nil {
+		return nil, err
+	}
+	poly := &Polygon{P: pts}
+	poly.makeBoundBox()
+	return poly, nil
+}
+
+// PolyOut は poly_out 相当。
+func PolyOut(poly *Polygon) string {
+	return pathEncode('(', ')', poly.P)
+}
+
+// PolyRecv は poly_recv 相当。点の数 (int32) に続いて各点の座標が並ぶ。
+func PolyRecv(buf []byte) (*Polygon, error) {
+	if len(buf) < 4 {
+		return nil, errInsufficientData
+	}
+	npts := int(int32(binary.BigEndian.Uint32(buf)))
+	if npts <= 0 || npts > (len(buf)-4)/16 {
+		return nil, newError(errcodes.InvalidBinaryRepresentation, "invalid number of points in external \"polygon\" value")
+	}
+	fs, err := readFloat8s(buf[4:], npts*2)
+	if err != nil {
+		return nil, err
+	}
+	poly := &Polygon{P: make([]Point, npts)}
+	for i := range poly.P {
+		poly.P[i] = Point{X: fs[i*2], Y: fs[i*2+1]}
+	}
+	poly.makeBoundBox()
+	return poly, nil
+}
+
+// PolySend は poly_send 相当。
+func PolySend(poly *Polygon) []byte {
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(poly.P)))
+	for _, p := range poly.P {
+		buf = appendFloat8s(buf, p.X, p.Y)
+	}
+	return buf
+}
+
+// PolyNpoints は頂点の数を返す (poly_npoints, # 相当)
+func PolyNpoints(poly *Polygon) int {
+	return len(poly.P)
+}
+
+// PolyCenter は頂点の重心を返す (poly_center, @@ 相当)
+func PolyCenter(poly *Polygon) *Point {
+	var c Point
+	for _, p := range poly.P {
+		c.X += p.X
+		c.Y += p.Y
+	}
+	n := float64(len(poly.P))
+	return &Point{X: c.X / n, Y: c.Y / n}
+}
+
+// edge は多角形の i 番目の辺を返す。最後の辺は最後の頂点と最初の頂点を結ぶ。
+func (poly *Polygon) edge(i int) LSeg {
+	return LSeg{P: [2]Point{poly.P[i], poly.P[(i+1)%len(poly.P)]}}
+}
+
+// pointOnPolygon は lsegCrossing が返す「点が多角形の辺上にある」ことを表す値。
+const pointOnPolygon = math.MaxInt32
+
+// pointInside は点が多角形の内側にあるかを判定する (point_inside 相当)。
+// 外側なら 0、内側なら 1、辺上なら 2 を返す。
+// 点を原点とし、各辺が正の X 軸と交わる向きを数える (交差数アルゴリズム)。
+func pointInside(p *Point, plist []Point) int {
+	x0, y0 := plist[0].X-p.X, plist[0].Y-p.Y
+	prevX, prevY := x0, y0
+	totalCross := 0
+	for _, q := range plist[1:] {
+		x, y := q.X-p.X, q.Y-p.Y
+		cross := lsegCrossing(x, y, prevX, prevY)
+		if cross == pointOnPolygon {
+			return 2
+		}
+		totalCross += cross
+		prevX, prevY = x, y
+	}
+	// 最後の頂点から最初の頂点への辺
+	cross := lsegCrossing(x0, y0, prevX, prevY)
+	if cross == pointOnPolygon {
+		return 2
+	}
+	totalCross += cross
+	if totalCross != 0 {
+		return 1
+	}
+	return 0
+}
+
+// lsegCrossing は (prevX, prevY) から (x, y) への線分が正の X 軸と交わる向きを返す (lseg_crossing 相当)。
+// 交わらなければ 0、下から上へ交われば +2、上から下へ交われば -2、端点が X 軸上にある場合は ±1。
+// 線分が原点を通る場合は pointOnPolygon を返す。
+func lsegCrossing(x, y, prevX, prevY float64) int {
+	if FPzero(y) {
+		// (x, y) は X 軸上
+		switch {
+		case FPzero(x):
+			return pointOnPolygon
+		case FPgt(x, 0):
+			if FPzero(prevY) {
+				if FPgt(prevX, 0) {
+					return 0
+				}
+				return pointOnPolygon
+			}
+			if FPlt(prevY, 0) {
+				return 1
+			}
+			return -1
+		default:
+			if FPzero(prevY) {
+				if FPlt(prevX, 0) {
+					return 0
+				}
+				return pointOnPolygon
+			}
+			return 0
+		}
+	}
+
+	ySign := -1
+	if FPgt(y, 0) {
+		ySign = 1
+	}
+	switch {
+	case FPzero(prevY):
+		// 前の点は X 軸上
+		if FPlt(prevX, 0) {
+			return 0
+		}
+		return ySign
+	case ySign < 0 && FPlt(prevY, 0), ySign > 0 && FPgt(prevY, 0):
+		// 2点とも X 軸の同じ側にある
+		return 0
+	case FPge(x, 0) && FPgt(prevX, 0):
+		// 2点とも x >= 0 にあるので、正の X 軸と交わる
+		return 2 * ySign
+	case FPlt(x, 0) && FPle(prevX, 0):
+		// 2点とも x <= 0 にあるので、正の X 軸とは交わらない
+		return 0
+	}
+	// X 軸との交点の x 座標の符号を外積で求める
+	z := (x-prevX)*y - (y-prevY)*x
+	if FPzero(z) {
+		return pointOnPolygon
+	}
+	if (ySign < 0 && FPlt(z, 0)) || (ySign > 0 && FPgt(z, 0)) {
+		return 0
+	}
+	return 2 * ySign
+}
+
+// PolyContainPt は多角形が点を含むかを返す (poly_contain_pt, @> 相当)。辺上の点も含む。
+func PolyContainPt(poly *Polygon, p *Point) bool {
+	return pointInside(p, poly.P) != 0
+}
+
+// PolyContain は poly1 が poly2 を含むかを返す (poly_contain, @> 相当)。
+// poly2 の全ての頂点と各辺の中点が poly1 の内側 (辺上を含む) にあり、
+// poly2 の辺が poly1 の辺と端点以外で交わらない場合に含むとみなす。
+func PolyContain(poly1, poly2 *Polygon) bool {
+	if !BoxContain(&poly1.BoundBox, &poly2.BoundBox) {
+		return false
+	}
+	for i := range poly2.P {
+		s := poly2.edge(i)
+		if !PolyContainPt(poly1, &s.P[0]) || !PolyContainPt(poly1, LsegCenter(&s)) {
+			return false
+		}
+		for j := range poly1.P {
+			t := poly1.edge(j)
+			ip, ok := lsegInterptLseg(&s, &t)
+			if ok && !PointEq(&ip, &s.P[0]) && !PointEq(&ip, &s.P[1]) &&
+				!PointEq(&ip, &t.P[0]) && !PointEq(&ip, &t.P[1]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// PolyOverlap は2つの多角形が重なるかを返す (poly_overlap, && 相当)
+func PolyOverlap(poly1, poly2 *Polygon) bool {
+	if !BoxOverlap(&poly1.BoundBox, &poly2.BoundBox) {
+		return false
+	}
+	// いずれかの辺どうしが交われば重なる
+	for i := range poly1.P {
+		s := poly1.edge(i)
+		for j := range poly2.P {
+			t := poly2.edge(j)
+			if LsegIntersect(&s, &t) {
+				return true
+			}
+		}
+	}
+	// 辺が交わらない場合は、一方が他方の内側にあるときのみ重なる
+	return PolyContainPt(poly1, &poly2.P[0]) || PolyContainPt(poly2, &poly1.P[0])
+}
+
+// PolySame は2つの多角形が同じかを返す (poly_same, ~= 相当)。
+// 頂点の並びの開始位置と向きは問わない (plist_same 相当)。
+func PolySame(poly1, poly2 *Polygon) bool {
+	n := len(poly1.P)
+	if n != len(poly2.P) {
+		return false
+	}
+	for start := range poly2.P {
+		if !PointEq(&poly1.P[0], &poly2.P[start]) {
+			continue
+		}
+		forward, backward := true, true
+		for i := 1; i < n; i++ {
+			forward = forward && PointEq(&poly1.P[i], &poly2.P[(start+i)%n])
+			backward = backward && PointEq(&poly1.P[i], &poly2.P[(start-i+n)%n])
+		}
+		if forward || backward {
+			return true
+		}
+	}
+	return false
+}
+
+// DistPpoly は点と多角形の距離を返す (dist_ppoly, <-> 相当)。点が内側にあれば 0。
+func DistPpoly(p *Point, poly *Polygon) float64 {
+	if PolyContainPt(poly, p) {
+		return 0
+	}
+	result := math.Inf(1)
+	for i := range poly.P {
+		s := poly.edge(i)
+		result = math.Min(result, DistPs(p, &s))
+	}
+	return result
+}
+
+// ----------------------------------------------------------------
+// circle
+// ----------------------------------------------------------------
+
+// CircleIn は circle_in 相当。"<(x,y),r>", "((x,y),r)", "(x,y),r", "x,y,r" の形式を受け付ける。
+func CircleIn(s string) (*Circle, error) {
+	d := newGeoDecoder(s, "circle")
+	var closers []byte
+	switch d.peek() {
+	case '<':
+		d.pos++
+		closers = append(closers, '>')
+	case '(':
+		cp := d.pos + 1
+		for cp < len(d.s) && isSpace(d.s[cp]) {
+			cp++
+		}
+		if cp < len(d.s) && d.s[cp] == '(' {
+			d.pos = cp
+			closers = append(closers, ')')
+		}
+	}
+
+	center, err := d.pair()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.expect(','); err != nil {
+		return nil, err
+	}
+	radius, err := d.single()
+	if err != nil {
+		return nil, err
+	}
+	// NaN は許す
+	if radius < 0 {
+		return nil, d.invalid()
+	}
+	for _, c := range closers {
+		if err := d.expect(c); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.end(); err != nil {
+		return nil, err
+	}
+	return &Circle{Center: center, Radius: radius}, nil
+}
+
+// CircleOut は circle_out 相当。
+func CircleOut(c *Circle) string {
+	var sb strings.Builder
+	sb.WriteByte('<')
+	pairEncode(&sb, c.Center)
+	sb.WriteByte(',')
+	sb.WriteString(Float8Out(c.Radius))
+	sb.WriteByte('>')
+	return sb.String()
+}
+
+// CircleRecv は circle_recv 相当。
+func CircleRecv(buf []byte) (*Circle, error) {
+	fs, err := readFloat8s(buf, 3)
+	if err != nil {
+		return nil, err
+	}
+	if fs[2] < 0 {
+		return nil, newError(errcodes.InvalidBinaryRepresentation, "invalid radius in external \"circle\" value")
+	}
+	return &Circle{Center: Point{X: fs[0], Y: fs[1]}, Radius: fs[2]}, nil
+}
+
+// CircleSend は circle_send 相当。
+func CircleSend(c *Circle) []byte {
+	return appendFloat8s(nil, c.Center.X, c.Center.Y, c.Radius)
+}
+
+// CircleSame (~=)
+func CircleSame(c1, c2 *Circle) bool {
+	return FPeq(c1.Radius, c2.Radius) && PointEq(&c1.Center, &c2.Center)
+}
+
+// CircleOverlap (&&)
+func CircleOverlap(c1, c2 *Circle) bool {
+	return FPle(PointDistance(&c1.Center, &c2.Center), c1.Radius+c2.Radius)
+}
+
+// CircleContain は c1 が c2 を含むかを返す (circle_contain, @> 相当)
+func CircleContain(c1, c2 *Circle) bool {
+	return FPle(PointDistance(&c1.Center, &c2.Center), c1.Radius-c2.Radius)
+}
+
+// CircleContainPt は円が点を含むかを返す (circle_contain_pt, @> 相当)
+func CircleContainPt(c *Circle, p *Point) bool {
+	return PointDistance(&c.Center, p) <= c.Radius
+}
+
+// CircleArea は円の面積を返す (circle_area, area() 相当)
+func CircleArea(c *Circle) float64 {
+	return c.Radius * c.Radius * math.Pi
+}
+
+// CircleCenter は円の中心を返す (circle_center, @@ 相当)
+func CircleCenter(c *Circle) *Point {
+	return &Point{X: c.Center.X, Y: c.Center.Y}
+}
+
+// CircleDistance は2つの円の距離を返す (circle_distance, <-> 相当)。重なる場合は 0。
+func CircleDistance(c1, c2 *Circle) float64 {
+	return math.Max(0, PointDistance(&c1.Center, &c2.Center)-(c1.Radius+c2.Radius))
+}
+
+// DistPc は点と円の距離を返す (dist_pc, <-> 相当)。点が内側にあれば 0。
+func DistPc(p *Point, c *Circle) float64 {
+	return math.Max(0, PointDistance(p, &c.Center)-c.Radius)
+}
+
+// CircleBox は円に外接する矩形を返す。GiST 索引のキーに使う。
+func CircleBox(c *Circle) *Box {
+	return &Box{
+		High: Point{X: c.Center.X + c.Radius, Y: c.Center.Y + c.Radius},
+		Low:  Point{X: c.Center.X - c.Radius, Y: c.Center.Y - c.Radius},
+	}
+}
@@ -0,0 +1,92 @@
+package adt
+
+import (
+	"encoding/binary"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/contrib/hstore"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// hstore の入出力 (contrib/hstore の hstore_io.c の hstore_in, hstore_out, hstore_recv, hstore_send 相当)
+// ----------------------------------------------------------------
+// 値は *hstore.Hstore で表す。解析と演算子は contrib/hstore パッケージにあり、ここでは
+// InputFunctionCall などから呼べるよう、エラーに SQLSTATE を付ける。
+//
+// バイナリ表現は組の数 (int4) に続けて、組ごとにキーの長さ (int4)、キー、値の長さ (int4、NULL は -1)、
+// 値を並べる。
+
+// HstoreIn は hstore のテキスト表現を読む (hstore_in 相当)
+func HstoreIn(s string) (*hstore.Hstore, error) {
+	h, err := hstore.Parse(s)
+	if err != nil {
+		return nil, newError(errcodes.SyntaxError, "%s", err.Error())
+	}
+	return h, nil
+}
+
+// HstoreOut は hstore のテキスト表現を返す (hstore_out 相当)
+func HstoreOut(h *hstore.Hstore) string {
+	return h.String()
+}
+
+// HstoreRecv は hstore のバイナリ表現を読む (hstore_recv 相当)。同じキーの組は最初のものを残す。
+func HstoreRecv(buf []byte) (*hstore.Hstore, error) {
+	if len(buf) < 4 {
+		return nil, errInsufficientData
+	}
+	count := int32(binary.BigEndian.Uint32(buf))
+	buf = buf[4:]
+	// 組は少なくとも8バイトを使う
+	if count < 0 || int(count) > len(buf)/8 {
+		return nil, errInsufficientData
+	}
+	// readString は長さ (int4) に続く文字列を読む。長さが -1 なら null に true を返す。
+	readString := func() (s string, null bool, err error) {
+		if len(buf) < 4 {
+			return "", false, errInsufficientData
+		}
+		n := int32(binary.BigEndian.Uint32(buf))
+		buf = buf[4:]
+		if n == -1 {
+			return "", true, nil
+		}
+		if n < 0 || int(n) > len(buf) {
+			return "", false, errInsufficientData
+		}
+		s, buf = string(buf[:n]), buf[n:]
+		return s, false, nil
+	}
+	pairs := make([]hstore.Pair, 0, count)
+	for range count {
+		key, null, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		if null {
+			return nil, newError(errcodes.NullValueNotAllowed, "null value not allowed for hstore key")
+		}
+		val, null, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, hstore.Pair{Key: key, Val: val, Null: null})
+	}
+	return hstore.New(pairs), nil
+}
+
+// HstoreSend は hstore のバイナリ表現を返す (hstore_send 相当)
+func HstoreSend(h *hstore.Hstore) []byte {
+	buf := binary.BigEndian.AppendUint32(nil, uint32(h.Len()))
+	for _, p := range h.Pairs() {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(p.Key)))
+		buf = append(buf, p.Key...)
+		if p.Null {
+			buf = binary.BigEndian.AppendUint32(buf, ^uint32(0))
+			continue
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(p.Val)))
+		buf = append(buf, p.Val...)
+	}
+	return buf
+}
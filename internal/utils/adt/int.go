@@ -0,0 +1,42 @@
+package adt
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 整数型 (int.c, int8.c 相当)
+// ----------------------------------------------------------------
+
+// parseInt は int2 / int4 / int8 の入力文字列を解析する (pg_strtoint16/32/64 相当)
+func parseInt(s string, bits int, typname string) (int64, error) {
+	str := strings.TrimSpace(s)
+	v, err := strconv.ParseInt(str, 10, bits)
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return 0, newError(errcodes.NumericValueOutOfRange, "value \"%s\" is out of range for type %s", s, typname)
+		}
+		return 0, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type %s: \"%s\"", typname, s)
+	}
+	return v, nil
+}
+
+// Int2In は int2in 相当。
+func Int2In(s string) (int16, error) {
+	v, err := parseInt(s, 16, "smallint")
+	return int16(v), err
+}
+
+// Int4In は int4in 相当。
+func Int4In(s string) (int32, error) {
+	v, err := parseInt(s, 32, "integer")
+	return int32(v), err
+}
+
+// Int8In は int8in 相当。
+func Int8In(s string) (int64, error) {
+	return parseInt(s, 64, "bigint")
+}
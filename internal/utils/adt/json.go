@@ -0,0 +1,160 @@
+package adt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// json 型と jsonb 型 (json.c, jsonb.c 相当)
+// ----------------------------------------------------------------
+// json は入力をそのまま保持し、jsonb は解析した結果を正規化した文字列として保持する。
+// jsonb の正規化では、空白を取り除き、オブジェクトのキーを長さ、バイト列の順に並べ、
+// 重複したキーは最後の値だけを残す。数値は numeric として正規化する。
+//
+// json のバイナリ形式はテキスト表現と同じ。jsonb のバイナリ形式は版番号 1 の1バイトに
+// テキスト表現が続く。
+
+// jsonbVersion は jsonb のバイナリ形式の版番号
+const jsonbVersion = 1
+
+// JSONIn は json のテキスト表現を検証する (json_in 相当)
+func JSONIn(s string) (string, error) {
+	if !json.Valid([]byte(s)) {
+		return "", newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type json")
+	}
+	return s, nil
+}
+
+// JSONBIn は jsonb のテキスト表現を解析し、正規化する (jsonb_in 相当)
+func JSONBIn(s string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type json")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return "", newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type json")
+	}
+	var sb strings.Builder
+	if err := writeJSONB(&sb, v); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// writeJSONB は解析した値を jsonb の出力形式で書き出す (JsonbToCString 相当)
+func writeJSONB(sb *strings.Builder, v any) error {
+	switch v := v.(type) {
+	case nil:
+		sb.WriteString("null")
+	case bool:
+		if v {
+			sb.WriteString("true")
+		} else {
+			sb.WriteString("false")
+		}
+	case json.Number:
+		n, err := NumericIn(v.String())
+		if err != nil {
+			return err
+		}
+		sb.WriteString(n)
+	case string:
+		escapeJSON(sb, v)
+	case []any:
+		sb.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			if err := writeJSONB(sb, elem); err != nil {
+				return err
+			}
+		}
+		sb.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// jsonb のキーは短い順、同じ長さならバイト列の順 (lengthCompareJsonbString 相当)
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		sb.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			escapeJSON(sb, k)
+			sb.WriteString(": ")
+			if err := writeJSONB(sb, v[k]); err != nil {
+				return err
+			}
+		}
+		sb.WriteByte('}')
+	default:
+		return fmt.Errorf("unexpected json value %T", v)
+	}
+	return nil
+}
+
+// escapeJSON は文字列を JSON の文字列リテラルとして書き出す (escape_json 相当)
+func escapeJSON(sb *strings.Builder, s string) {
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(sb, `\u%04x`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+}
+
+// JSONBSend は jsonb のバイナリ表現を返す (jsonb_send 相当)
+func JSONBSend(s string) []byte {
+	return append([]byte{jsonbVersion}, s...)
+}
+
+// JSONBRecv はバイナリ形式の jsonb を解析する (jsonb_recv 相当)
+func JSONBRecv(buf []byte) (string, error) {
+	if len(buf) < 1 {
+		return "", errInsufficientData
+	}
+	if buf[0] != jsonbVersion {
+		return "", fmt.Errorf("unsupported jsonb version number %d", buf[0])
+	}
+	s, err := TextRecv(buf[1:])
+	if err != nil {
+		return "", err
+	}
+	return JSONBIn(s)
+}
@@ -0,0 +1,36 @@
+package adt
+
+import (
+	"unicode/utf8"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// name 型 (name.c 相当)
+// ----------------------------------------------------------------
+// システムカタログの識別子に使う、NAMEDATALEN - 1 バイトまでの文字列。
+
+// NameDataLen は name 型の領域の大きさ (NAMEDATALEN 相当)
+const NameDataLen = 64
+
+// NameIn は name のテキスト表現を受け取る (namein 相当)。長すぎる値は
+// 文字の境界で NAMEDATALEN - 1 バイトに切り詰める。
+func NameIn(s string) string {
+	if len(s) < NameDataLen {
+		return s
+	}
+	n := NameDataLen - 1
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// NameRecv はバイナリ形式の name を受け取る (namerecv 相当)
+func NameRecv(buf []byte) (string, error) {
+	if len(buf) >= NameDataLen {
+		return "", newError(errcodes.NameTooLong, "identifier too long")
+	}
+	return TextRecv(buf)
+}
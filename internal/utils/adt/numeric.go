@@ -0,0 +1,262 @@
+package adt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// numeric 型 (numeric.c 相当)
+// ----------------------------------------------------------------
+// 任意精度の演算はまだ実装していないため、numeric の値は正規化した
+// 10進数表記の文字列として保持する。
+
+// NumericIn は numeric の入力文字列を検証し、出力形式に正規化する (numeric_in 相当)。
+// 指数表記は展開し、表示桁数 (dscale) は「小数部の桁数 - 指数」とする。
+func NumericIn(s string) (string, error) {
+	str := strings.TrimSpace(s)
+	invalid := newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type numeric: \"%s\"", s)
+
+	switch strings.ToLower(str) {
+	case "nan":
+		return "NaN", nil
+	case "infinity", "+infinity", "inf", "+inf":
+		return "Infinity", nil
+	case "-infinity", "-inf":
+		return "-Infinity", nil
+	}
+
+	neg := false
+	if str != "" && (str[0] == '+' || str[0] == '-') {
+		neg = str[0] == '-'
+		str = str[1:]
+	}
+
+	mantissa, expPart, hasExp := strings.Cut(strings.ToLower(str), "e")
+	exp := 0
+	if hasExp {
+		e, err := strconv.Atoi(expPart)
+		if err != nil {
+			return "", invalid
+		}
+		exp = e
+	}
+
+	intPart, fracPart, _ := strings.Cut(mantissa, ".")
+	if intPart == "" && fracPart == "" {
+		return "", invalid
+	}
+	for _, part := range []string{intPart, fracPart} {
+		for i := 0; i < len(part); i++ {
+			if part[i] < '0' || part[i] > '9' {
+				return "", invalid
+			}
+		}
+	}
+
+	// 全桁を並べ、小数点の位置を指数分だけずらす
+	digits := intPart + fracPart
+	point := len(intPart) + exp
+	dscale := len(fracPart) - exp
+	if dscale < 0 {
+		dscale = 0
+	}
+
+	for point <= 0 {
+		digits = "0" + digits
+		point++
+	}
+	for len(digits) < point+dscale {
+		digits += "0"
+	}
+
+	ip := strings.TrimLeft(digits[:point], "0")
+	if ip == "" {
+		ip = "0"
+	}
+	fp := digits[point : point+dscale]
+
+	out := ip
+	if dscale > 0 {
+		out += "." + fp
+	}
+	if neg && strings.Trim(out, "0.") != "" {
+		out = "-" + out
+	}
+	return out, nil
+}
+
+// NumericCmp は正規化済みの numeric の値を比べ、a が小さければ負、等しければ 0、大きければ正を返す
+// (cmp_numerics 相当)。NaN は全ての値より大きく、NaN どうしは等しいとみなす。
+func NumericCmp(a, b string) int {
+	rank := func(s string) int {
+		switch s {
+		case "-Infinity":
+			return -1
+		case "Infinity":
+			return 1
+		case "NaN":
+			return 2
+		}
+		return 0
+	}
+	ra, rb := rank(a), rank(b)
+	if ra != 0 || rb != 0 {
+		return ra - rb
+	}
+	x, _ := new(big.Rat).SetString(a)
+	y, _ := new(big.Rat).SetString(b)
+	return x.Cmp(y)
+}
+
+// ----------------------------------------------------------------
+// numeric のバイナリ形式 (numeric_send / numeric_recv 相当)
+// ----------------------------------------------------------------
+// ndigits (int16), weight (int16), sign (uint16), dscale (int16) に続いて、
+// 基数 10000 の桁 (int16) が ndigits 個並ぶ。値は Σ digit[i] * 10000^(weight-i)。
+
+const (
+	numericPos  = 0x0000
+	numericNeg  = 0x4000
+	numericNaN  = 0xC000
+	numericPinf = 0xD000
+	numericNinf = 0xF000
+	nbase       = 10000
+	decDigits   = 4
+)
+
+// NumericSend は正規化済みの numeric の文字列をバイナリ形式に変換する。
+func NumericSend(s string) []byte {
+	var sign uint16 = numericPos
+	switch s {
+	case "NaN":
+		sign = numericNaN
+	case "Infinity":
+		sign = numericPinf
+	case "-Infinity":
+		sign = numericNinf
+	}
+	buf := make([]byte, 0, 16)
+	if sign != numericPos {
+		buf = binary.BigEndian.AppendUint16(buf, 0)
+		buf = binary.BigEndian.AppendUint16(buf, 0)
+		buf = binary.BigEndian.AppendUint16(buf, sign)
+		return binary.BigEndian.AppendUint16(buf, 0)
+	}
+
+	if strings.HasPrefix(s, "-") {
+		sign = numericNeg
+		s = s[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	dscale := len(fracPart)
+
+	// 整数部は左に、小数部は右に 0 を補って4桁ごとに区切る
+	for len(intPart)%decDigits != 0 {
+		intPart = "0" + intPart
+	}
+	for len(fracPart)%decDigits != 0 {
+		fracPart += "0"
+	}
+	all := intPart + fracPart
+	digits := make([]int16, 0, len(all)/decDigits)
+	for i := 0; i < len(all); i += decDigits {
+		d, _ := strconv.Atoi(all[i : i+decDigits])
+		digits = append(digits, int16(d))
+	}
+	weight := len(intPart)/decDigits - 1
+
+	// 先頭と末尾の 0 の桁を取り除く
+	for len(digits) > 0 && digits[0] == 0 {
+		digits = digits[1:]
+		weight--
+	}
+	for len(digits) > 0 && digits[len(digits)-1] == 0 {
+		digits = digits[:len(digits)-1]
+	}
+	if len(digits) == 0 {
+		weight = 0
+		sign = numericPos
+	}
+
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(digits)))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(int16(weight)))
+	buf = binary.BigEndian.AppendUint16(buf, sign)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(dscale))
+	for _, d := range digits {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(d))
+	}
+	return buf
+}
+
+// NumericRecv はバイナリ形式の numeric を正規化済みの文字列に変換する。
+func NumericRecv(buf []byte) (string, error) {
+	if len(buf) < 8 {
+		return "", errInsufficientData
+	}
+	ndigits := int(int16(binary.BigEndian.Uint16(buf[0:])))
+	weight := int(int16(binary.BigEndian.Uint16(buf[2:])))
+	sign := binary.BigEndian.Uint16(buf[4:])
+	dscale := int(int16(binary.BigEndian.Uint16(buf[6:])))
+
+	switch sign {
+	case numericNaN:
+		return "NaN", nil
+	case numericPinf:
+		return "Infinity", nil
+	case numericNinf:
+		return "-Infinity", nil
+	case numericPos, numericNeg:
+	default:
+		return "", newError(errcodes.InvalidBinaryRepresentation, "invalid sign in external \"numeric\" value")
+	}
+	if ndigits < 0 || len(buf) != 8+ndigits*2 {
+		return "", errInsufficientData
+	}
+	if dscale < 0 {
+		return "", newError(errcodes.InvalidBinaryRepresentation, "invalid scale in external \"numeric\" value")
+	}
+
+	digits := make([]int, ndigits)
+	for i := range digits {
+		digits[i] = int(binary.BigEndian.Uint16(buf[8+i*2:]))
+		if digits[i] >= nbase {
+			return "", newError(errcodes.InvalidBinaryRepresentation, "invalid digit in external \"numeric\" value")
+		}
+	}
+	digitAt := func(w int) int {
+		// w は 10000 の何乗の桁かを表す
+		i := weight - w
+		if i < 0 || i >= ndigits {
+			return 0
+		}
+		return digits[i]
+	}
+
+	var sb strings.Builder
+	if sign == numericNeg && ndigits > 0 {
+		sb.WriteByte('-')
+	}
+	if weight < 0 {
+		sb.WriteByte('0')
+	} else {
+		fmt.Fprintf(&sb, "%d", digitAt(weight))
+		for w := weight - 1; w >= 0; w-- {
+			fmt.Fprintf(&sb, "%04d", digitAt(w))
+		}
+	}
+	if dscale > 0 {
+		var frac strings.Builder
+		for w := -1; frac.Len() < dscale; w-- {
+			fmt.Fprintf(&frac, "%04d", digitAt(w))
+		}
+		sb.WriteByte('.')
+		sb.WriteString(frac.String()[:dscale])
+	}
+	return sb.String(), nil
+}
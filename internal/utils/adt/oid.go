@@ -0,0 +1,51 @@
+package adt
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// oid 型 (oid.c 相当)
+// ----------------------------------------------------------------
+
+// OidIn は oid のテキスト表現を解析する (oidin 相当)
+func OidIn(s string) (catalog.Oid, error) {
+	v, err := uint32InSubr(s, "oid")
+	return catalog.Oid(v), err
+}
+
+// uint32InSubr は符号なし32ビット整数のテキスト表現を解析する (uint32in_subr 相当)。
+// 以前の版との互換のため、負の値は符号なしに読み替えて受け付ける。typname はエラーの
+// メッセージに使う型名。
+func uint32InSubr(s, typname string) (uint32, error) {
+	str := strings.TrimSpace(s)
+	v, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return 0, newError(errcodes.NumericValueOutOfRange, "value \"%s\" is out of range for type %s", s, typname)
+		}
+		return 0, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type %s: \"%s\"", typname, s)
+	}
+	if v < -(1<<31) || v > 1<<32-1 {
+		return 0, newError(errcodes.NumericValueOutOfRange, "value \"%s\" is out of range for type %s", s, typname)
+	}
+	return uint32(v), nil
+}
+
+// uint64InSubr は符号なし64ビット整数のテキスト表現を解析する (uint64in_subr 相当)。
+// uint32InSubr と異なり、負の値は受け付けない。
+func uint64InSubr(s, typname string) (uint64, error) {
+	str := strings.TrimSpace(s)
+	v, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return 0, newError(errcodes.NumericValueOutOfRange, "value \"%s\" is out of range for type %s", s, typname)
+		}
+		return 0, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type %s: \"%s\"", typname, s)
+	}
+	return v, nil
+}
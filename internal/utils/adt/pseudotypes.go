@@ -0,0 +1,26 @@
+package adt
+
+import "github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+
+// ----------------------------------------------------------------
+// 疑似型 (utils/adt/pseudotypes.c 相当)
+// ----------------------------------------------------------------
+// void は値を返さない関数の戻り値の型。C言語版と同じく、出力は空文字列、入力はどんな文字列も
+// 受け付けて値を1つ作る。バイナリ表現は空である。
+//
+// internal は GiST のサポート関数などが受け取る、SQL からは作れない内部のデータ構造の型。
+// 入力関数は常にエラーを返すため、SQL から internal の引数を持つ関数は呼べない。
+
+// Void は void 型の値
+type Void struct{}
+
+// VoidIn は void のテキスト表現を読む (void_in 相当)。内容は見ない。
+func VoidIn(string) Void { return Void{} }
+
+// VoidOut は void のテキスト表現を返す (void_out 相当)
+func VoidOut(Void) string { return "" }
+
+// InternalIn は internal のテキスト表現を読む (internal_in 相当)。常にエラーを返す。
+func InternalIn(string) (Datum, error) {
+	return nil, newError(errcodes.FeatureNotSupported, "cannot accept a value of type %s", "internal")
+}
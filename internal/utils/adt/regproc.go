@@ -0,0 +1,54 @@
+package adt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// regtype 型 (utils/adt/regproc.c 相当)
+// ----------------------------------------------------------------
+// 値は型の OID だが、テキスト表現には型名を使う。システムビューで型の一覧を
+// 読みやすく表示するために使う。
+
+// RegType は型の OID (regtype 相当)
+type RegType catalog.Oid
+
+// RegtypeIn は regtype のテキスト表現を解析する (regtypein 相当)。
+// 数字だけの場合は OID として、それ以外は型名として読む。"-" は InvalidOid を表す。
+// 型名の末尾の "[]" は配列型を表す。
+func RegtypeIn(s string) (RegType, error) {
+	str := strings.TrimSpace(s)
+	if str == "-" {
+		return RegType(catalog.InvalidOid), nil
+	}
+	if str != "" && strings.Trim(str, "0123456789") == "" {
+		oid, err := OidIn(str)
+		return RegType(oid), err
+	}
+	name, isArray := strings.CutSuffix(str, "[]")
+	typid, ok := catalog.TypenameTypeID(name)
+	if ok && isArray {
+		typid = catalog.GetArrayType(typid)
+	}
+	if !ok || typid == catalog.InvalidOid {
+		return 0, newError(errcodes.UndefinedObject, "type \"%s\" does not exist", str)
+	}
+	return RegType(typid), nil
+}
+
+// RegtypeOut は regtype のテキスト表現を返す (regtypeout 相当)。
+// 存在しない型は OID の数字で表す。
+func RegtypeOut(typid RegType) string {
+	oid := catalog.Oid(typid)
+	if oid == catalog.InvalidOid {
+		return "-"
+	}
+	if _, ok := catalog.SearchType(oid); !ok {
+		return fmt.Sprint(uint32(oid))
+	}
+	return catalog.FormatType(oid)
+}
@@ -0,0 +1,414 @@
+package adt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// ----------------------------------------------------------------
+// timestamp と timestamp with time zone (utils/adt/timestamp.c 相当)
+// ----------------------------------------------------------------
+// 値は 2000-01-01 00:00:00 (timestamp with time zone では UTC) からのマイクロ秒で表す。
+// 最小値と最大値はそれぞれ -infinity と infinity を表す。バイナリ形式はこのマイクロ秒の int64。
+//
+// 入力は ISO 8601 形式 ("2024-01-31 12:34:56.789+09" など) と、infinity、-infinity、
+// epoch、now だけを受け付ける。時間帯を省略した場合は UTC とみなす (TimeZone の既定値)。
+// timestamp では時間帯を無視する。
+// 出力は DateStyle = ISO の形式で、timestamp with time zone は常に UTC で表す。
+
+// TimestampTz は timestamp with time zone の値 (TimestampTz 相当)
+type TimestampTz int64
+
+// Timestamp は timestamp の値 (Timestamp 相当)
+type Timestamp int64
+
+const (
+	// DtNoBegin は -infinity (DT_NOBEGIN 相当)
+	DtNoBegin = math.MinInt64
+	// DtNoEnd は infinity (DT_NOEND 相当)
+	DtNoEnd = math.MaxInt64
+)
+
+// postgresEpoch は値の起点 (POSTGRES_EPOCH_JDATE 相当)
+var postgresEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// TimestamptzFromTime は time.Time を TimestampTz に変換する。
+// time.Duration は約 292 年までしか表せないため、秒とマイクロ秒に分けて計算する。
+func TimestamptzFromTime(t time.Time) TimestampTz {
+	return TimestampTz((t.Unix()-postgresEpoch.Unix())*1000000 + int64(t.Nanosecond()/1000))
+}
+
+// timestamptzToTime は 2000-01-01 からのマイクロ秒を UTC の time.Time に変換する
+func timestamptzToTime(ts int64) time.Time {
+	sec, usec := ts/1000000, ts%1000000
+	if usec < 0 {
+		sec, usec = sec-1, usec+1000000
+	}
+	return time.Unix(postgresEpoch.Unix()+sec, usec*1000).UTC()
+}
+
+// GetCurrentTimestamp は現在時刻を返す (GetCurrentTimestamp 相当)
+func GetCurrentTimestamp() TimestampTz {
+	return TimestamptzFromTime(sim.Now())
+}
+
+var timestampPattern = regexp.MustCompile(`(?i)^(\d{4,})-(\d{1,2})-(\d{1,2})` +
+	`(?:[ T](\d{1,2}):(\d{2})(?::(\d{2})(\.\d+)?)?)?` +
+	`\s*(z|utc|gmt|[+-]\d{1,2}(?::?\d{2})?)?(?:\s+(bc|ad))?$`)
+
+// TimestamptzIn は timestamp with time zone のテキスト表現を解析する (timestamptz_in 相当)
+func TimestamptzIn(s string) (TimestampTz, error) {
+	ts, err := parseTimestamp(s, "timestamp with time zone", false)
+	return TimestampTz(ts), err
+}
+
+// TimestampIn は timestamp のテキスト表現を解析する (timestamp_in 相当)
+func TimestampIn(s string) (Timestamp, error) {
+	ts, err := parseTimestamp(s, "timestamp", true)
+	return Timestamp(ts), err
+}
+
+// parseTimestamp は日時のテキスト表現を 2000-01-01 からのマイクロ秒にする
+// (ParseDateTime, DecodeDateTime 相当)。ignoreZone が true なら時間帯を無視する。
+func parseTimestamp(s, typname string, ignoreZone bool) (int64, error) {
+	str := strings.TrimSpace(s)
+	switch strings.ToLower(str) {
+	case "infinity", "+infinity":
+		return DtNoEnd, nil
+	case "-infinity":
+		return DtNoBegin, nil
+	case "epoch":
+		return int64(TimestamptzFromTime(time.Unix(0, 0))), nil
+	case "now":
+		return int64(GetCurrentTimestamp()), nil
+	}
+
+	m := timestampPattern.FindStringSubmatch(str)
+	if m == nil {
+		return 0, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type %s: \"%s\"", typname, s)
+	}
+	field := func(i int) int {
+		n, _ := strconv.Atoi(m[i])
+		return n
+	}
+	year, month, day := field(1), field(2), field(3)
+	if strings.EqualFold(m[9], "bc") {
+		year = 1 - year
+	}
+	hour, min, sec := field(4), field(5), field(6)
+	usec := parseFraction(m[7])
+
+	// 24:00:00 は翌日の 00:00:00 として受け付ける
+	if month < 1 || month > 12 || day < 1 || day > daysIn(year, month) ||
+		hour > 24 || min > 59 || sec > 60 || hour == 24 && (min > 0 || sec > 0 || usec > 0) {
+		return 0, newError(errcodes.DatetimeFieldOverflow, "date/time field value out of range: \"%s\"", s)
+	}
+
+	offset := 0
+	if zone := strings.ToLower(m[8]); zone != "" && zone != "z" && zone != "utc" && zone != "gmt" && !ignoreZone {
+		sign := 1
+		if zone[0] == '-' {
+			sign = -1
+		}
+		digits := strings.ReplaceAll(zone[1:], ":", "")
+		var h, mi int
+		if len(digits) <= 2 {
+			h, _ = strconv.Atoi(digits)
+		} else {
+			h, _ = strconv.Atoi(digits[:len(digits)-2])
+			mi, _ = strconv.Atoi(digits[len(digits)-2:])
+		}
+		if h > 15 || mi > 59 {
+			return 0, newError(errcodes.InvalidTimeZoneDisplacementValue, "time zone displacement out of range: \"%s\"", s)
+		}
+		offset = sign * (h*3600 + mi*60)
+	}
+
+	t := time.Date(year, time.Month(month), day, hour, min, sec, usec*1000, time.FixedZone("", offset))
+	return int64(TimestamptzFromTime(t)), nil
+}
+
+// parseFraction は "." で始まる秒の小数部をマイクロ秒に丸める。空なら 0 を返す。
+func parseFraction(frac string) int {
+	if frac == "" {
+		return 0
+	}
+	f, _ := strconv.ParseFloat("0"+frac, 64)
+	return int(math.Round(f * 1e6))
+}
+
+// daysIn は year 年 month 月の日数を返す
+func daysIn(year, month int) int {
+	return time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// TimestamptzOut は timestamp with time zone のテキスト表現を返す (timestamptz_out 相当)
+func TimestamptzOut(ts TimestampTz) string {
+	switch ts {
+	case DtNoBegin:
+		return "-infinity"
+	case DtNoEnd:
+		return "infinity"
+	}
+	return encodeDateTime(timestamptzToTime(int64(ts)), "+00")
+}
+
+// TimestampOut は timestamp のテキスト表現を返す (timestamp_out 相当)
+func TimestampOut(ts Timestamp) string {
+	switch ts {
+	case DtNoBegin:
+		return "-infinity"
+	case DtNoEnd:
+		return "infinity"
+	}
+	return encodeDateTime(timestamptzToTime(int64(ts)), "")
+}
+
+// encodeDateTime は日時を ISO 形式で表す (EncodeDateTime 相当)。秒の小数部は末尾の 0 を除き、
+// zone を付けた後、紀元前であれば " BC" を付ける。
+func encodeDateTime(t time.Time, zone string) string {
+	out := encodeDate(t.Year(), t.Month(), t.Day()) + t.Format(" 15:04:05")
+	if usec := t.Nanosecond() / 1000; usec != 0 {
+		out += strings.TrimRight(fmt.Sprintf(".%06d", usec), "0")
+	}
+	out += zone
+	if t.Year() <= 0 {
+		out += " BC"
+	}
+	return out
+}
+
+// encodeDate は日付を ISO 形式で表す (EncodeDateOnly 相当)。紀元前の年は 1 - year で表すが、
+// " BC" は呼び出し側が付ける。
+func encodeDate(year int, month time.Month, day int) string {
+	if year <= 0 {
+		year = 1 - year
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+}
+
+// ----------------------------------------------------------------
+// interval (utils/adt/timestamp.c の interval_in, interval_out 相当)
+// ----------------------------------------------------------------
+// 値は月、日、マイクロ秒を別々に持つ。月の日数と日の長さは一定でないため、互いに繰り上げない。
+// バイナリ形式はマイクロ秒 (int64)、日 (int32)、月 (int32) の順に並べる。
+//
+// 入力は "1 year 2 mons 3 days 04:05:06" のような単位付きの数と時刻の並びを受け付ける。
+// 先頭の "@" と末尾の "ago" (符号の反転) も受け付ける。単位のない数は秒とみなす。
+// 出力は IntervalStyle = postgres の形式にする。
+
+// Interval は interval の値 (Interval 相当)
+type Interval struct {
+	Time  int64 // マイクロ秒
+	Day   int32
+	Month int32
+}
+
+// daysPerMonth は月の端数を日に換算する際の1か月の日数 (DAYS_PER_MONTH 相当)
+const daysPerMonth = 30
+
+// intervalUnit は interval の入力の単位
+type intervalUnit struct {
+	months int   // 1単位の月数。0 なら日またはマイクロ秒の単位
+	days   int   // 1単位の日数。0 ならマイクロ秒の単位
+	usecs  int64 // 1単位のマイクロ秒
+}
+
+// intervalUnits は interval の入力で使える単位 (deltatktbl 相当)
+var intervalUnits = map[string]intervalUnit{}
+
+func init() {
+	for _, u := range []struct {
+		names []string
+		unit  intervalUnit
+	}{
+		{[]string{"millennium", "millennia", "millenniums", "mil", "mils"}, intervalUnit{months: 12000}},
+		{[]string{"century", "centuries", "c", "cent"}, intervalUnit{months: 1200}},
+		{[]string{"decade", "decades", "dec", "decs"}, intervalUnit{months: 120}},
+		{[]string{"year", "years", "y", "yr", "yrs"}, intervalUnit{months: 12}},
+		{[]string{"month", "months", "mon", "mons"}, intervalUnit{months: 1}},
+		{[]string{"week", "weeks", "w"}, intervalUnit{days: 7}},
+		{[]string{"day", "days", "d"}, intervalUnit{days: 1}},
+		{[]string{"hour", "hours", "h", "hr", "hrs"}, intervalUnit{usecs: 3600000000}},
+		{[]string{"minute", "minutes", "m", "min", "mins"}, intervalUnit{usecs: 60000000}},
+		{[]string{"second", "seconds", "s", "sec", "secs"}, intervalUnit{usecs: 1000000}},
+		{[]string{"millisecond", "milliseconds", "ms", "msec", "msecs"}, intervalUnit{usecs: 1000}},
+		{[]string{"microsecond", "microseconds", "us", "usec", "usecs"}, intervalUnit{usecs: 1}},
+	} {
+		for _, name := range u.names {
+			intervalUnits[name] = u.unit
+		}
+	}
+}
+
+var (
+	intervalNumberPattern = regexp.MustCompile(`^([+-]?(?:\d+\.?\d*|\.\d+))([a-z]*)$`)
+	intervalTimePattern   = regexp.MustCompile(`^([+-]?)(\d+):(\d{1,2})(?::(\d{1,2})(\.\d+)?)?$`)
+)
+
+// IntervalIn は interval のテキスト表現を解析する (interval_in, DecodeInterval 相当)
+func IntervalIn(s string) (*Interval, error) {
+	invalid := newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type interval: \"%s\"", s)
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) > 0 && fields[0] == "@" {
+		fields = fields[1:]
+	} else if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		fields[0] = fields[0][1:]
+	}
+	ago := false
+	if len(fields) > 0 && fields[len(fields)-1] == "ago" {
+		ago = true
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) == 0 {
+		return nil, invalid
+	}
+
+	var months, days, usecs float64
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if m := intervalTimePattern.FindStringSubmatch(f); m != nil {
+			h, _ := strconv.ParseFloat(m[2], 64)
+			mi, _ := strconv.ParseFloat(m[3], 64)
+			sec, _ := strconv.ParseFloat(m[4], 64)
+			if mi > 59 || sec > 60 {
+				return nil, newError(errcodes.IntervalFieldOverflow, "interval field value out of range: \"%s\"", s)
+			}
+			t := (h*3600+mi*60+sec)*1e6 + float64(parseFraction(m[5]))
+			if m[1] == "-" {
+				t = -t
+			}
+			usecs += t
+			continue
+		}
+		m := intervalNumberPattern.FindStringSubmatch(f)
+		if m == nil {
+			return nil, invalid
+		}
+		v, _ := strconv.ParseFloat(m[1], 64)
+		unitName := m[2]
+		if unitName == "" && i+1 < len(fields) {
+			if _, ok := intervalUnits[fields[i+1]]; ok {
+				i++
+				unitName = fields[i]
+			}
+		}
+		unit := intervalUnit{usecs: 1000000} // 単位のない数は秒
+		if unitName != "" {
+			var ok bool
+			if unit, ok = intervalUnits[unitName]; !ok {
+				return nil, invalid
+			}
+		}
+
+		// 端数は下の単位に繰り下げる (AdjustFractYears, AdjustFractDays 相当)。
+		// 年の単位の端数は月に丸め、月と週の端数は日に、日の端数はマイクロ秒に換算する。
+		whole, frac := math.Modf(v)
+		switch {
+		case unit.months >= 12:
+			months += whole*float64(unit.months) + math.Round(frac*float64(unit.months))
+		case unit.months == 1:
+			months += whole
+			d, fd := math.Modf(frac * daysPerMonth)
+			days += d
+			usecs += fd * usecsPerDay
+		case unit.days > 0:
+			d, fd := math.Modf(v * float64(unit.days))
+			days += d
+			usecs += fd * usecsPerDay
+		default:
+			usecs += v * float64(unit.usecs)
+		}
+	}
+	if ago {
+		months, days, usecs = -months, -days, -usecs
+	}
+	if math.Abs(months) > math.MaxInt32 || math.Abs(days) > math.MaxInt32 || math.Abs(usecs) >= math.MaxInt64 {
+		return nil, newError(errcodes.DatetimeValueOutOfRange, "interval out of range")
+	}
+	return &Interval{Time: int64(math.Round(usecs)), Day: int32(days), Month: int32(months)}, nil
+}
+
+// IntervalOut は interval のテキスト表現を返す (interval_out, EncodeInterval の INTSTYLE_POSTGRES 相当)
+func IntervalOut(iv *Interval) string {
+	year, mon, mday := iv.Month/12, iv.Month%12, iv.Day
+	t := iv.Time
+	hour := t / 3600000000
+	t -= hour * 3600000000
+	min := t / 60000000
+	t -= min * 60000000
+	sec, fsec := t/1000000, t%1000000
+
+	var sb strings.Builder
+	isZero, isBefore := true, false
+	// addPart は0でない年、月、日を出力する。前の値が負なら、正の値に "+" を付ける
+	addPart := func(value int32, units string) {
+		if value == 0 {
+			return
+		}
+		if !isZero {
+			sb.WriteByte(' ')
+		}
+		if isBefore && value > 0 {
+			sb.WriteByte('+')
+		}
+		fmt.Fprintf(&sb, "%d %s", value, units)
+		if value != 1 {
+			sb.WriteByte('s')
+		}
+		isBefore, isZero = value < 0, false
+	}
+	addPart(year, "year")
+	addPart(mon, "mon")
+	addPart(mday, "day")
+	if isZero || hour != 0 || min != 0 || sec != 0 || fsec != 0 {
+		if !isZero {
+			sb.WriteByte(' ')
+		}
+		switch {
+		case hour < 0 || min < 0 || sec < 0 || fsec < 0:
+			sb.WriteByte('-')
+		case isBefore:
+			sb.WriteByte('+')
+		}
+		fmt.Fprintf(&sb, "%02d:%02d:%02d", abs64(hour), abs64(min), abs64(sec))
+		if fsec != 0 {
+			sb.WriteString(strings.TrimRight(fmt.Sprintf(".%06d", abs64(fsec)), "0"))
+		}
+	}
+	return sb.String()
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// IntervalSend は interval のバイナリ表現を返す (interval_send 相当)
+func IntervalSend(iv *Interval) []byte {
+	buf := binary.BigEndian.AppendUint64(nil, uint64(iv.Time))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(iv.Day))
+	return binary.BigEndian.AppendUint32(buf, uint32(iv.Month))
+}
+
+// IntervalRecv はバイナリ形式の interval を受け取る (interval_recv 相当)
+func IntervalRecv(buf []byte) (*Interval, error) {
+	if len(buf) != 16 {
+		return nil, errInsufficientData
+	}
+	return &Interval{
+		Time:  int64(binary.BigEndian.Uint64(buf)),
+		Day:   int32(binary.BigEndian.Uint32(buf[8:])),
+		Month: int32(binary.BigEndian.Uint32(buf[12:])),
+	}, nil
+}
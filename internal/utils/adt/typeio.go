@@ -0,0 +1,392 @@
+package adt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/contrib/hstore"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 型の入出力関数の呼び出し (fmgr の InputFunctionCall / OutputFunctionCall 相当)
+// ----------------------------------------------------------------
+// テキスト形式の入出力 (typinput / typoutput) と、拡張問い合わせプロトコルで使う
+// バイナリ形式の入出力 (typreceive / typsend) を提供する。
+// C言語版では pg_type に登録された関数を fmgr 経由で呼ぶが、
+// Go言語版では型の OID で分岐して直接呼び出す。
+//
+// 値 (Datum) は Go の値で表す:
+//   bool → bool, int2 → int16, int4 → int32, int8 → int64, oid → catalog.Oid,
+//   xid → TransactionId, xid8 → FullTransactionId, regtype → RegType,
+//   float4 → float32, float8 → float64, numeric → 正規化済みの string,
+//   text / varchar / bpchar / name / unknown → string, "char" → byte, bytea → []byte,
+//   json → string, jsonb → 正規化済みの string, uuid → UUID,
+//   date → DateADT, time → TimeADT, timestamp → Timestamp, timestamptz → TimestampTz,
+//   interval → *Interval,
+//   point → *Point, lseg → *LSeg, box → *Box, polygon → *Polygon,
+//   line → *Line, circle → *Circle, 配列 → *Array, void → Void, hstore → *hstore.Hstore
+
+// Datum は1つの値を表す。NULL は nil で表す。
+type Datum = any
+
+// Error は SQLSTATE 付きの値の入出力のエラー。バックエンドはクライアントにそのまま報告する。
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// newError は SQLSTATE が code のエラーを作る
+func newError(code string, format string, args ...any) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+var errInsufficientData = newError(errcodes.ProtocolViolation, "insufficient data left in message")
+
+// InputFunctionCall はテキスト表現から値を作る。
+func InputFunctionCall(typid catalog.Oid, s string) (Datum, error) {
+	switch typid {
+	case catalog.BOOLOID:
+		return BoolIn(s)
+	case catalog.INT2OID:
+		return Int2In(s)
+	case catalog.INT4OID:
+		return Int4In(s)
+	case catalog.INT8OID:
+		return Int8In(s)
+	case catalog.OIDOID:
+		return OidIn(s)
+	case catalog.XIDOID:
+		return XidIn(s)
+	case catalog.XID8OID:
+		return Xid8In(s)
+	case catalog.REGTYPEOID:
+		return RegtypeIn(s)
+	case catalog.FLOAT4OID:
+		return Float4In(s)
+	case catalog.FLOAT8OID:
+		return Float8In(s)
+	case catalog.NUMERICOID:
+		return NumericIn(s)
+	case catalog.TEXTOID, catalog.VARCHAROID, catalog.BPCHAROID, catalog.UNKNOWNOID:
+		return s, nil
+	case catalog.NAMEOID:
+		return NameIn(s), nil
+	case catalog.CHAROID:
+		return CharIn(s), nil
+	case catalog.BYTEAOID:
+		return ByteaIn(s)
+	case catalog.JSONOID:
+		return JSONIn(s)
+	case catalog.JSONBOID:
+		return JSONBIn(s)
+	case catalog.UUIDOID:
+		return UUIDIn(s)
+	case catalog.DATEOID:
+		return DateIn(s)
+	case catalog.TIMEOID:
+		return TimeIn(s)
+	case catalog.TIMESTAMPOID:
+		return TimestampIn(s)
+	case catalog.TIMESTAMPTZOID:
+		return TimestamptzIn(s)
+	case catalog.INTERVALOID:
+		return IntervalIn(s)
+	case catalog.POINTOID:
+		return PointIn(s)
+	case catalog.LSEGOID:
+		return LsegIn(s)
+	case catalog.BOXOID:
+		return BoxIn(s)
+	case catalog.POLYGONOID:
+		return PolyIn(s)
+	case catalog.LINEOID:
+		return LineIn(s)
+	case catalog.CIRCLEOID:
+		return CircleIn(s)
+	case catalog.VOIDOID:
+		return VoidIn(s), nil
+	case catalog.INTERNALOID:
+		return InternalIn(s)
+	case catalog.HSTOREOID:
+		return HstoreIn(s)
+	}
+	// ドメインは基の型の入力関数で読む。ドメインの制約は呼び出し側が確かめる (domain_in 相当)
+	if base := catalog.GetBaseType(typid); base != typid {
+		return InputFunctionCall(base, s)
+	}
+	if elem := catalog.GetElementType(typid); elem != catalog.InvalidOid {
+		return ArrayIn(s, elem)
+	}
+	return nil, newError(errcodes.UndefinedFunction, "no input function available for type %s", catalog.FormatType(typid))
+}
+
+// OutputFunctionCall は値のテキスト表現を返す。
+func OutputFunctionCall(typid catalog.Oid, d Datum) string {
+	switch v := d.(type) {
+	case bool:
+		return BoolOut(v)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case catalog.Oid:
+		return strconv.FormatUint(uint64(v), 10)
+	case TransactionId:
+		return XidOut(v)
+	case FullTransactionId:
+		return Xid8Out(v)
+	case RegType:
+		return RegtypeOut(v)
+	case float32:
+		return Float4Out(v)
+	case float64:
+		return Float8Out(v)
+	case string:
+		return v
+	case byte:
+		return CharOut(v)
+	case []byte:
+		return ByteaOut(v)
+	case UUID:
+		return UUIDOut(v)
+	case DateADT:
+		return DateOut(v)
+	case TimeADT:
+		return TimeOut(v)
+	case Timestamp:
+		return TimestampOut(v)
+	case TimestampTz:
+		return TimestamptzOut(v)
+	case *Interval:
+		return IntervalOut(v)
+	case *Point:
+		return PointOut(v)
+	case *LSeg:
+		return LsegOut(v)
+	case *Box:
+		return BoxOut(v)
+	case *Polygon:
+		return PolyOut(v)
+	case *Line:
+		return LineOut(v)
+	case *Circle:
+		return CircleOut(v)
+	case *Array:
+		return ArrayOut(v)
+	case Void:
+		return VoidOut(v)
+	case *hstore.Hstore:
+		return HstoreOut(v)
+	}
+	return fmt.Sprint(d)
+}
+
+// ReceiveFunctionCall はバイナリ表現から値を作る (ReceiveFunctionCall 相当)
+func ReceiveFunctionCall(typid catalog.Oid, buf []byte) (Datum, error) {
+	switch typid {
+	case catalog.BOOLOID:
+		if len(buf) != 1 {
+			return nil, errInsufficientData
+		}
+		return buf[0] != 0, nil
+	case catalog.INT2OID:
+		if len(buf) != 2 {
+			return nil, errInsufficientData
+		}
+		return int16(binary.BigEndian.Uint16(buf)), nil
+	case catalog.INT4OID:
+		if len(buf) != 4 {
+			return nil, errInsufficientData
+		}
+		return int32(binary.BigEndian.Uint32(buf)), nil
+	case catalog.INT8OID:
+		if len(buf) != 8 {
+			return nil, errInsufficientData
+		}
+		return int64(binary.BigEndian.Uint64(buf)), nil
+	case catalog.OIDOID:
+		if len(buf) != 4 {
+			return nil, errInsufficientData
+		}
+		return catalog.Oid(binary.BigEndian.Uint32(buf)), nil
+	case catalog.XIDOID:
+		if len(buf) != 4 {
+			return nil, errInsufficientData
+		}
+		return TransactionId(binary.BigEndian.Uint32(buf)), nil
+	case catalog.XID8OID:
+		if len(buf) != 8 {
+			return nil, errInsufficientData
+		}
+		return FullTransactionId(binary.BigEndian.Uint64(buf)), nil
+	case catalog.REGTYPEOID:
+		if len(buf) != 4 {
+			return nil, errInsufficientData
+		}
+		return RegType(binary.BigEndian.Uint32(buf)), nil
+	case catalog.FLOAT4OID:
+		if len(buf) != 4 {
+			return nil, errInsufficientData
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(buf)), nil
+	case catalog.FLOAT8OID:
+		if len(buf) != 8 {
+			return nil, errInsufficientData
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(buf)), nil
+	case catalog.NUMERICOID:
+		return NumericRecv(buf)
+	case catalog.TEXTOID, catalog.VARCHAROID, catalog.BPCHAROID, catalog.UNKNOWNOID:
+		return TextRecv(buf)
+	case catalog.NAMEOID:
+		return NameRecv(buf)
+	case catalog.CHAROID:
+		if len(buf) != 1 {
+			return nil, errInsufficientData
+		}
+		return buf[0], nil
+	case catalog.BYTEAOID:
+		return append([]byte(nil), buf...), nil
+	case catalog.JSONOID:
+		// json のバイナリ形式はテキスト表現そのもの
+		s, err := TextRecv(buf)
+		if err != nil {
+			return nil, err
+		}
+		return JSONIn(s)
+	case catalog.JSONBOID:
+		return JSONBRecv(buf)
+	case catalog.UUIDOID:
+		if len(buf) != 16 {
+			return nil, errInsufficientData
+		}
+		return UUID(buf), nil
+	case catalog.DATEOID:
+		if len(buf) != 4 {
+			return nil, errInsufficientData
+		}
+		return DateADT(binary.BigEndian.Uint32(buf)), nil
+	case catalog.TIMEOID:
+		if len(buf) != 8 {
+			return nil, errInsufficientData
+		}
+		return TimeRecv(int64(binary.BigEndian.Uint64(buf)))
+	case catalog.TIMESTAMPOID:
+		if len(buf) != 8 {
+			return nil, errInsufficientData
+		}
+		return Timestamp(binary.BigEndian.Uint64(buf)), nil
+	case catalog.TIMESTAMPTZOID:
+		if len(buf) != 8 {
+			return nil, errInsufficientData
+		}
+		return TimestampTz(binary.BigEndian.Uint64(buf)), nil
+	case catalog.INTERVALOID:
+		return IntervalRecv(buf)
+	case catalog.POINTOID:
+		return PointRecv(buf)
+	case catalog.LSEGOID:
+		return LsegRecv(buf)
+	case catalog.BOXOID:
+		return BoxRecv(buf)
+	case catalog.POLYGONOID:
+		return PolyRecv(buf)
+	case catalog.LINEOID:
+		return LineRecv(buf)
+	case catalog.CIRCLEOID:
+		return CircleRecv(buf)
+	case catalog.VOIDOID:
+		// void のバイナリ表現は空 (void_recv 相当)
+		return Void{}, nil
+	case catalog.HSTOREOID:
+		return HstoreRecv(buf)
+	}
+	// ドメインは基の型の受信関数で読む (domain_recv 相当)
+	if base := catalog.GetBaseType(typid); base != typid {
+		return ReceiveFunctionCall(base, buf)
+	}
+	if elem := catalog.GetElementType(typid); elem != catalog.InvalidOid {
+		return ArrayRecv(buf, elem)
+	}
+	return nil, newError(errcodes.UndefinedFunction, "no binary input function available for type %s", catalog.FormatType(typid))
+}
+
+// SendFunctionCall は値のバイナリ表現を返す (SendFunctionCall 相当)
+func SendFunctionCall(typid catalog.Oid, d Datum) ([]byte, error) {
+	switch v := d.(type) {
+	case bool:
+		if v {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case int16:
+		return binary.BigEndian.AppendUint16(nil, uint16(v)), nil
+	case int32:
+		return binary.BigEndian.AppendUint32(nil, uint32(v)), nil
+	case int64:
+		return binary.BigEndian.AppendUint64(nil, uint64(v)), nil
+	case catalog.Oid:
+		return binary.BigEndian.AppendUint32(nil, uint32(v)), nil
+	case TransactionId:
+		return binary.BigEndian.AppendUint32(nil, uint32(v)), nil
+	case FullTransactionId:
+		return binary.BigEndian.AppendUint64(nil, uint64(v)), nil
+	case RegType:
+		return binary.BigEndian.AppendUint32(nil, uint32(v)), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(nil, math.Float32bits(v)), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(nil, math.Float64bits(v)), nil
+	case string:
+		switch typid {
+		case catalog.NUMERICOID:
+			return NumericSend(v), nil
+		case catalog.JSONBOID:
+			return JSONBSend(v), nil
+		}
+		return []byte(v), nil
+	case byte:
+		return []byte{v}, nil
+	case []byte:
+		return v, nil
+	case UUID:
+		return v[:], nil
+	case DateADT:
+		return binary.BigEndian.AppendUint32(nil, uint32(v)), nil
+	case TimeADT:
+		return binary.BigEndian.AppendUint64(nil, uint64(v)), nil
+	case Timestamp:
+		return binary.BigEndian.AppendUint64(nil, uint64(v)), nil
+	case TimestampTz:
+		return binary.BigEndian.AppendUint64(nil, uint64(v)), nil
+	case *Interval:
+		return IntervalSend(v), nil
+	case *Point:
+		return PointSend(v), nil
+	case *LSeg:
+		return LsegSend(v), nil
+	case *Box:
+		return BoxSend(v), nil
+	case *Polygon:
+		return PolySend(v), nil
+	case *Line:
+		return LineSend(v), nil
+	case *Circle:
+		return CircleSend(v), nil
+	case *Array:
+		return ArraySend(v)
+	case Void:
+		return []byte{}, nil
+	case *hstore.Hstore:
+		return HstoreSend(v), nil
+	}
+	return nil, newError(errcodes.UndefinedFunction, "no binary output function available for type %s", catalog.FormatType(typid))
+}
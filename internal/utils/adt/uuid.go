@@ -0,0 +1,56 @@
+package adt
+
+import (
+	"encoding/hex"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// uuid 型 (uuid.c 相当)
+// ----------------------------------------------------------------
+// 値は16バイトで、バイナリ形式もその16バイト。
+
+// UUID は uuid の値 (pg_uuid_t 相当)
+type UUID [16]byte
+
+// UUIDIn は uuid のテキスト表現を解析する (string_to_uuid 相当)。
+// 全体を波括弧で囲んでもよく、4桁ごとの区切りにハイフンを置いてもよい。
+func UUIDIn(s string) (UUID, error) {
+	var u UUID
+	str := s
+	if len(str) >= 2 && str[0] == '{' && str[len(str)-1] == '}' {
+		str = str[1 : len(str)-1]
+	}
+	n := 0
+	for i := 0; i < len(str); {
+		if n == len(u) {
+			return u, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type uuid: \"%s\"", s)
+		}
+		hi, ok1 := hexValue(str[i])
+		var lo byte
+		ok2 := false
+		if i+1 < len(str) {
+			lo, ok2 = hexValue(str[i+1])
+		}
+		if !ok1 || !ok2 {
+			return u, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type uuid: \"%s\"", s)
+		}
+		u[n] = hi<<4 | lo
+		n++
+		i += 2
+		if i < len(str) && str[i] == '-' && n%2 == 0 && n < len(u) {
+			i++
+		}
+	}
+	if n != len(u) {
+		return u, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type uuid: \"%s\"", s)
+	}
+	return u, nil
+}
+
+// UUIDOut は uuid のテキスト表現を返す (uuid_out 相当)
+func UUIDOut(u UUID) string {
+	h := hex.EncodeToString(u[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
@@ -0,0 +1,167 @@
+package adt
+
+import (
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/errcodes"
+)
+
+// ----------------------------------------------------------------
+// 文字列のリストの分割 (varlena.c 相当)
+// ----------------------------------------------------------------
+
+// SplitGUCList は設定パラメータの値をカンマなどの separator で区切ったリストとして分割する
+// (SplitGUCList 相当)。各要素の前後の空白は除き、二重引用符で囲んだ要素はその中の
+// 区切り文字や空白をそのまま残す (引用符の中の "" は " 1文字を表す)。
+// SplitIdentifierString と異なり、大文字小文字は変換しない。
+// 空の要素や閉じていない引用符があれば ok に false を返す。
+func SplitGUCList(rawstring string, separator byte) (list []string, ok bool) {
+	s := strings.TrimLeft(rawstring, " \t\n\r\f\v")
+	if s == "" {
+		return nil, true
+	}
+	for {
+		var elem string
+		if s[0] == '"' {
+			var b strings.Builder
+			i := 1
+			for {
+				end := strings.IndexByte(s[i:], '"')
+				if end < 0 {
+					return nil, false
+				}
+				b.WriteString(s[i : i+end])
+				i += end + 1
+				if i < len(s) && s[i] == '"' {
+					b.WriteByte('"')
+					i++
+					continue
+				}
+				break
+			}
+			elem = b.String()
+			if elem == "" {
+				return nil, false
+			}
+			s = strings.TrimLeft(s[i:], " \t\n\r\f\v")
+		} else {
+			end := strings.IndexByte(s, separator)
+			if end < 0 {
+				end = len(s)
+			}
+			elem = strings.TrimRight(s[:end], " \t\n\r\f\v")
+			if elem == "" {
+				return nil, false
+			}
+			s = s[end:]
+		}
+		list = append(list, elem)
+
+		if s == "" {
+			return list, true
+		}
+		if s[0] != separator {
+			return nil, false
+		}
+		s = strings.TrimLeft(s[1:], " \t\n\r\f\v")
+		if s == "" {
+			return nil, false
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// 文字列型のバイナリ形式 (textrecv, textsend 相当)
+// ----------------------------------------------------------------
+// text, varchar, bpchar, name のバイナリ形式はサーバーの符号化方式 (UTF8) のバイト列そのもの。
+
+// TextRecv はバイナリ形式の文字列を受け取る。不正な UTF-8 のバイト列は受け付けない
+// (pg_verify_mbstr 相当)。
+func TextRecv(buf []byte) (string, error) {
+	if !utf8.Valid(buf) {
+		return "", newError(errcodes.CharacterNotInRepertoire, "invalid byte sequence for encoding \"UTF8\"")
+	}
+	return string(buf), nil
+}
+
+// ----------------------------------------------------------------
+// bytea 型 (byteain, byteaout 相当)
+// ----------------------------------------------------------------
+// 入力は "\x" で始まる16進数形式と、"\ooo" (8進数) と "\\" を使うエスケープ形式を受け付ける。
+// 出力は bytea_output = hex と同じく16進数形式にする。バイナリ形式はバイト列そのもの。
+
+// ByteaIn は bytea のテキスト表現を解析する (byteain 相当)
+func ByteaIn(s string) ([]byte, error) {
+	if strings.HasPrefix(s, "\\x") {
+		return hexDecode(s[2:])
+	}
+
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); {
+		if s[i] != '\\' {
+			out = append(out, s[i])
+			i++
+			continue
+		}
+		switch {
+		case i+1 < len(s) && s[i+1] == '\\':
+			out = append(out, '\\')
+			i += 2
+		case i+3 < len(s) && isOctal(s[i+1]) && s[i+1] <= '3' && isOctal(s[i+2]) && isOctal(s[i+3]):
+			out = append(out, (s[i+1]-'0')<<6|(s[i+2]-'0')<<3|(s[i+3]-'0'))
+			i += 4
+		default:
+			return nil, newError(errcodes.InvalidTextRepresentation, "invalid input syntax for type bytea")
+		}
+	}
+	return out, nil
+}
+
+// hexDecode は16進数の並びを復号する (hex_decode 相当)。2桁の組の間の空白は読み飛ばす。
+func hexDecode(s string) ([]byte, error) {
+	out := make([]byte, 0, len(s)/2)
+	for i := 0; i < len(s); {
+		if c := s[i]; c == ' ' || c == '\n' || c == '\t' || c == '\r' {
+			i++
+			continue
+		}
+		hi, ok := hexValue(s[i])
+		if !ok {
+			return nil, newError(errcodes.InvalidParameterValue, "invalid hexadecimal digit: \"%c\"", s[i])
+		}
+		if i+1 >= len(s) {
+			return nil, newError(errcodes.InvalidParameterValue, "invalid hexadecimal data: odd number of digits")
+		}
+		lo, ok := hexValue(s[i+1])
+		if !ok {
+			return nil, newError(errcodes.InvalidParameterValue, "invalid hexadecimal digit: \"%c\"", s[i+1])
+		}
+		out = append(out, hi<<4|lo)
+		i += 2
+	}
+	return out, nil
+}
+
+// hexValue は16進数の1桁の値を返す
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+// ByteaOut は bytea のテキスト表現を返す (byteaout 相当)
+func ByteaOut(b []byte) string {
+	return "\\x" + hex.EncodeToString(b)
+}
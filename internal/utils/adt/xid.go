@@ -0,0 +1,52 @@
+package adt
+
+import (
+	"strconv"
+)
+
+// ----------------------------------------------------------------
+// xid 型と xid8 型 (utils/adt/xid.c 相当)
+// ----------------------------------------------------------------
+// xid はトランザクション ID を表す符号なし32ビット整数で、周回する。xid8 は上位32ビットに
+// 周回した回数 (epoch) を持つ64ビットのトランザクション ID で、周回しない。
+//
+// トランザクションの中のコマンドの番号 (CommandId) もここに置く。
+
+// TransactionId はトランザクション ID (TransactionId 相当)
+type TransactionId uint32
+
+// XidIn は xid のテキスト表現を解析する (xidin 相当)
+func XidIn(s string) (TransactionId, error) {
+	v, err := uint32InSubr(s, "xid")
+	return TransactionId(v), err
+}
+
+// XidOut は xid のテキスト表現を返す (xidout 相当)
+func XidOut(xid TransactionId) string {
+	return strconv.FormatUint(uint64(xid), 10)
+}
+
+// CommandId はトランザクションの中のコマンドの番号 (CommandId 相当)。同じトランザクションが
+// 前のコマンドで変更した行と、実行中のコマンドで変更した行を見分けるのに使う。
+type CommandId uint32
+
+const (
+	// FirstCommandId はトランザクションの最初のコマンドの番号 (FirstCommandId 相当)
+	FirstCommandId CommandId = 0
+	// InvalidCommandId はコマンドを指さないことを表す (InvalidCommandId 相当)
+	InvalidCommandId CommandId = ^CommandId(0)
+)
+
+// FullTransactionId は epoch を含む64ビットのトランザクション ID (FullTransactionId 相当)
+type FullTransactionId uint64
+
+// Xid8In は xid8 のテキスト表現を解析する (xid8in 相当)
+func Xid8In(s string) (FullTransactionId, error) {
+	v, err := uint64InSubr(s, "xid8")
+	return FullTransactionId(v), err
+}
+
+// Xid8Out は xid8 のテキスト表現を返す (xid8out 相当)
+func Xid8Out(fxid FullTransactionId) string {
+	return strconv.FormatUint(uint64(fxid), 10)
+}
@@ -0,0 +1,10 @@
+package errcodes
+
+// ----------------------------------------------------------------
+// SQLSTATE のエラーコード (utils/errcodes.h 相当)
+// ----------------------------------------------------------------
+// クライアントに報告するエラーの SQLSTATE。定数は errcodes.txt から generrcodes で
+// errcodes_d.go に生成する。ドライバや ORM は SQLSTATE でエラーの種類を判別するため、
+// エラーを報告する箇所ではコードを文字列で書かずにここの定数を使う。
+
+//go:generate go run ./generrcodes
@@ -0,0 +1,100 @@
+// generrcodes は errcodes.txt から、SQLSTATE の定数を定義する Go のソースを生成する
+// (backend/utils/generate-errcodes.pl 相当)。
+//
+// internal/utils/errcodes で go generate を実行すると、errcodes.txt から errcodes_d.go を生成する。
+// 定数の名前は C言語版のマクロ名から ERRCODE_ を除き、単語ごとに先頭を大文字にしたものとする
+// (ERRCODE_UNDEFINED_TABLE → UndefinedTable)。SQL などの略語は全て大文字にする。
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// acronyms は定数の名前で全て大文字にする略語
+var acronyms = map[string]bool{
+	"SQL": true, "XML": true, "JSON": true, "FDW": true, "SRF": true, "IO": true,
+}
+
+// lineRE は errcode の行 (sqlstate E/W/S errcode_macro_name [spec_name])
+var lineRE = regexp.MustCompile(`^([0-9A-Z]{5})\s+([EWS])\s+(ERRCODE_\S+)(?:\s+(\S+))?\s*$`)
+
+func main() {
+	if err := run("errcodes.txt", "errcodes_d.go"); err != nil {
+		fmt.Fprintf(os.Stderr, "generrcodes: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(input, output string) error {
+	f, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by generrcodes from errcodes.txt; DO NOT EDIT.\n\npackage errcodes\n")
+	seen := make(map[string]string)
+	inSection := false
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if section, ok := strings.CutPrefix(line, "Section:"); ok {
+			if inSection {
+				b.WriteString(")\n")
+			}
+			fmt.Fprintf(&b, "\n// %s\nconst (\n", strings.TrimSpace(section))
+			inSection = true
+			continue
+		}
+		m := lineRE.FindStringSubmatch(line)
+		if m == nil {
+			return fmt.Errorf("%s:%d: syntax error", input, lineno)
+		}
+		if !inSection {
+			return fmt.Errorf("%s:%d: error code outside of a section", input, lineno)
+		}
+		sqlstate, macro := m[1], m[3]
+		name := constName(macro)
+		if prev, dup := seen[name]; dup {
+			return fmt.Errorf("%s:%d: %s and %s map to the same name %s", input, lineno, prev, macro, name)
+		}
+		seen[name] = macro
+		fmt.Fprintf(&b, "\t%s = %q\n", name, sqlstate)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if inSection {
+		b.WriteString(")\n")
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, src, 0644)
+}
+
+// constName は C言語版のマクロ名を Go の定数の名前にする
+func constName(macro string) string {
+	var b strings.Builder
+	for _, word := range strings.Split(strings.TrimPrefix(macro, "ERRCODE_"), "_") {
+		if acronyms[word] {
+			b.WriteString(word)
+			continue
+		}
+		b.WriteString(word[:1])
+		b.WriteString(strings.ToLower(word[1:]))
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package fmgr
+
+import (
+	"sync"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// 関数の呼び出し (utils/fmgr/fmgr.c, fmgrtab.c 相当)
+// ----------------------------------------------------------------
+// C言語版では組み込み関数の一覧 (fmgr_builtins) をビルド時に生成し、prosrc の名前で引く。
+// Go言語版では実装するパッケージが init で Register し、実行器が prosrc の名前で引く。
+// バックエンドの状態に触れる関数 (pg_cancel_backend など) はバックエンドのパッケージに置き、
+// 呼び出し元のセッションの情報は CallContext で受け取る。
+
+// CallContext は関数を呼び出したセッションの情報
+type CallContext interface {
+	// UserName は現在のユーザー名を返す (GetUserId 相当)
+	UserName() string
+	// BackendPid はバックエンドの PID を返す (MyProcPid 相当)
+	BackendPid() int32
+	// Warning は WARNING をクライアントとサーバーログに報告する (ereport(WARNING) 相当)
+	Warning(msg string)
+	// EnterFunction は SECURITY DEFINER の関数か SET 句を持つ関数を呼ぶ前に、owner が空でなければ
+	// 現在のユーザーを owner に切り替え、config の設定を適用する (fmgr_security_definer 相当)。
+	// 関数を抜けるときに、エラーで抜ける場合も exit を呼んで元に戻す。
+	EnterFunction(owner string, config []string) (exit func(), err error)
+}
+
+// FunctionCallInfo は関数の引数と呼び出し元 (FunctionCallInfoBaseData 相当)
+type FunctionCallInfo struct {
+	Args    []adt.Datum
+	Context CallContext
+}
+
+// PGFunction は組み込み関数の実装 (PGFunction 相当)
+type PGFunction func(fcinfo *FunctionCallInfo) (adt.Datum, error)
+
+var builtins struct {
+	sync.RWMutex
+	m map[string]PGFunction
+}
+
+// Register は prosrc の名前で組み込み関数の実装を登録する
+func Register(prosrc string, fn PGFunction) {
+	builtins.Lock()
+	defer builtins.Unlock()
+	if builtins.m == nil {
+		builtins.m = make(map[string]PGFunction)
+	}
+	builtins.m[prosrc] = fn
+}
+
+// Lookup は prosrc の名前から組み込み関数の実装を返す (fmgr_lookupByName 相当)
+func Lookup(prosrc string) (PGFunction, bool) {
+	builtins.RLock()
+	defer builtins.RUnlock()
+	fn, ok := builtins.m[prosrc]
+	return fn, ok
+}
+
+// SetReturningFunction は集合を返す組み込み関数の実装。C言語版の materialize モード
+// (SFRM_Materialize) と同じく、全ての行をまとめて返す。
+type SetReturningFunction func(fcinfo *FunctionCallInfo) ([][]adt.Datum, error)
+
+var setReturningBuiltins struct {
+	sync.RWMutex
+	m map[string]SetReturningFunction
+}
+
+// RegisterSetReturning は prosrc の名前で集合を返す組み込み関数の実装を登録する
+func RegisterSetReturning(prosrc string, fn SetReturningFunction) {
+	setReturningBuiltins.Lock()
+	defer setReturningBuiltins.Unlock()
+	if setReturningBuiltins.m == nil {
+		setReturningBuiltins.m = make(map[string]SetReturningFunction)
+	}
+	setReturningBuiltins.m[prosrc] = fn
+}
+
+// LookupSetReturning は prosrc の名前から集合を返す組み込み関数の実装を返す
+func LookupSetReturning(prosrc string) (SetReturningFunction, bool) {
+	setReturningBuiltins.RLock()
+	defer setReturningBuiltins.RUnlock()
+	fn, ok := setReturningBuiltins.m[prosrc]
+	return fn, ok
+}
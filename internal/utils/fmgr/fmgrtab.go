@@ -0,0 +1,44 @@
+package fmgr
+
+import (
+	"cmp"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// 型の比較関数 (utils/adt/int.c, varlena.c, nbtree/nbtcompare.c の一部相当)
+// ----------------------------------------------------------------
+// int4 と text の比較関数。CREATE OPERATOR で演算子の関数に、CREATE OPERATOR CLASS で btree の
+// サポート関数に使える。adt パッケージは fmgr を参照できないため、ここで登録する。
+//
+// 組み込みの比較演算子 (= や <) はまだこれらの関数を呼ばず、実行器が直接比べる。
+// text はバイト順で比べる (照合順序 "C" 相当)。
+
+func init() {
+	registerComparison[int32]([...]string{"int4eq", "int4ne", "int4lt", "int4le", "int4gt", "int4ge"}, "btint4cmp")
+	registerComparison[string]([...]string{"texteq", "textne", "text_lt", "text_le", "text_gt", "text_ge"}, "bttextcmp")
+}
+
+// comparisonTests は =、<>、<、<=、>、>= の比較関数が、比較の結果に対して真を返す条件
+var comparisonTests = [...]func(c int) bool{
+	func(c int) bool { return c == 0 },
+	func(c int) bool { return c != 0 },
+	func(c int) bool { return c < 0 },
+	func(c int) bool { return c <= 0 },
+	func(c int) bool { return c > 0 },
+	func(c int) bool { return c >= 0 },
+}
+
+// registerComparison は names の6つの比較関数 (comparisonTests の順) と btree の比較関数 btcmp を登録する
+func registerComparison[T cmp.Ordered](names [len(comparisonTests)]string, btcmp string) {
+	for i, name := range names {
+		test := comparisonTests[i]
+		Register(name, func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+			return test(cmp.Compare(fcinfo.Args[0].(T), fcinfo.Args[1].(T))), nil
+		})
+	}
+	Register(btcmp, func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return int32(cmp.Compare(fcinfo.Args[0].(T), fcinfo.Args[1].(T))), nil
+	})
+}
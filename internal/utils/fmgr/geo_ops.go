@@ -0,0 +1,110 @@
+package fmgr
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/access/gist"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// 幾何型の演算子と GiST のサポート関数 (utils/adt/geo_ops.c, access/gist/gistproc.c の一部相当)
+// ----------------------------------------------------------------
+// point の演算子 (<->, <<, ~= など) の関数と、gist の point_ops のサポート関数。
+// fmgrtab.go の比較関数と同じく、adt と gist は fmgr を参照しないため、ここで登録する。
+//
+// サポート関数の internal の引数は、C言語版のポインタの代わりに次の Go の値で受け取る。
+//
+//	GISTENTRY *         → *gist.Entry
+//	GistEntryVector *   → []*gist.Entry
+//	GIST_SPLITVEC *     → *gist.Split
+//	float *, bool *     → *float64, *bool (結果を書き込む)
+
+func init() {
+	registerPointOp("point_above", adt.PointAbove)
+	registerPointOp("point_left", adt.PointLeft)
+	registerPointOp("point_right", adt.PointRight)
+	registerPointOp("point_below", adt.PointBelow)
+	registerPointOp("point_eq", adt.PointEq)
+	Register("on_pb", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return adt.BoxContainPt(fcinfo.Args[1].(*adt.Box), fcinfo.Args[0].(*adt.Point)), nil
+	})
+	Register("point_distance", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return adt.PointDistance(fcinfo.Args[0].(*adt.Point), fcinfo.Args[1].(*adt.Point)), nil
+	})
+
+	Register("gist_point_consistent", gistPointConsistent)
+	Register("gist_point_distance", gistPointDistance)
+	Register("gist_point_compress", gistPointCompress)
+	Register("gist_box_union", gistBoxUnion)
+	Register("gist_box_penalty", gistBoxPenalty)
+	Register("gist_box_picksplit", gistBoxPicksplit)
+	Register("gist_box_same", gistBoxSame)
+}
+
+// registerPointOp は2つの点を比べる演算子の関数を登録する
+func registerPointOp(name string, op func(p1, p2 *adt.Point) bool) {
+	Register(name, func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return op(fcinfo.Args[0].(*adt.Point), fcinfo.Args[1].(*adt.Point)), nil
+	})
+}
+
+// entryKeys は索引キーの並びから矩形を取り出す
+func entryKeys(entryvec []*gist.Entry) []*adt.Box {
+	keys := make([]*adt.Box, len(entryvec))
+	for i, e := range entryvec {
+		keys[i] = e.Key.(*adt.Box)
+	}
+	return keys
+}
+
+// gistPointConsistent は gist_point_consistent(entry, query, strategy, subtype, recheck) 相当
+func gistPointConsistent(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	entry := fcinfo.Args[0].(*gist.Entry)
+	strategy := gist.StrategyNumber(fcinfo.Args[2].(int16))
+	match, recheck := gist.PointConsistent(entry.Key.(*adt.Box), fcinfo.Args[1], strategy, entry.Leaf)
+	*fcinfo.Args[4].(*bool) = recheck
+	return match, nil
+}
+
+// gistPointDistance は gist_point_distance(entry, query, strategy, subtype, recheck) 相当。
+// 点どうしの距離は索引キーから正確に求まるため、再評価は要らない。
+func gistPointDistance(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	entry := fcinfo.Args[0].(*gist.Entry)
+	*fcinfo.Args[4].(*bool) = false
+	return gist.PointDistance(entry.Key.(*adt.Box), fcinfo.Args[1].(*adt.Point), entry.Leaf), nil
+}
+
+// gistPointCompress は gist_point_compress(entry) 相当。葉の点を幅のない矩形にする。
+func gistPointCompress(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	entry := fcinfo.Args[0].(*gist.Entry)
+	if !entry.Leaf {
+		return entry, nil
+	}
+	return &gist.Entry{Key: gist.PointCompress(entry.Key.(*adt.Point)), Leaf: true}, nil
+}
+
+// gistBoxUnion は gist_box_union(entryvec, sizep) 相当。Go の値には大きさがないため、sizep は使わない。
+func gistBoxUnion(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	return gist.BoxUnion(entryKeys(fcinfo.Args[0].([]*gist.Entry))), nil
+}
+
+// gistBoxPenalty は gist_box_penalty(origentry, newentry, penalty) 相当
+func gistBoxPenalty(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	orig, add := fcinfo.Args[0].(*gist.Entry), fcinfo.Args[1].(*gist.Entry)
+	penalty := fcinfo.Args[2].(*float64)
+	*penalty = gist.BoxPenalty(orig.Key.(*adt.Box), add.Key.(*adt.Box))
+	return penalty, nil
+}
+
+// gistBoxPicksplit は gist_box_picksplit(entryvec, splitvec) 相当
+func gistBoxPicksplit(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	v := fcinfo.Args[1].(*gist.Split)
+	*v = *gist.BoxPicksplit(entryKeys(fcinfo.Args[0].([]*gist.Entry)))
+	return v, nil
+}
+
+// gistBoxSame は gist_box_same(b1, b2, result) 相当
+func gistBoxSame(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	result := fcinfo.Args[2].(*bool)
+	*result = gist.BoxSame(fcinfo.Args[0].(*adt.Box), fcinfo.Args[1].(*adt.Box))
+	return result, nil
+}
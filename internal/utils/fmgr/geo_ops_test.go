@@ -0,0 +1,91 @@
+package fmgr
+
+import (
+	"testing"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/gist"
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// supportProc は gist の point の既定の演算子クラスから、サポート関数 procnum の実装を引く
+func supportProc(t *testing.T, procnum int16) PGFunction {
+	t.Helper()
+	opclass, ok := catalog.GetDefaultOpclass(catalog.GistAmOid, catalog.POINTOID)
+	if !ok {
+		t.Fatal("no default gist operator class for point")
+	}
+	amproc, ok := catalog.SearchAmproc(opclass.Opcfamily, catalog.POINTOID, catalog.POINTOID, procnum)
+	if !ok {
+		t.Fatalf("no support function %d in %s", procnum, opclass.Opcname)
+	}
+	proc, ok := catalog.SearchProc(amproc.Amproc)
+	if !ok {
+		t.Fatalf("no pg_proc row %d", amproc.Amproc)
+	}
+	fn, ok := Lookup(proc.Prosrc)
+	if !ok {
+		t.Fatalf("%s is not registered", proc.Prosrc)
+	}
+	return fn
+}
+
+// strategyOf は gist の point_ops で演算子 oprname(point,righttype) のストラテジ番号を返す
+func strategyOf(t *testing.T, oprname string, righttype catalog.Oid) int16 {
+	t.Helper()
+	op, ok := catalog.OperatorLookup(oprname, catalog.POINTOID, righttype)
+	if !ok {
+		t.Fatalf("operator %s does not exist", oprname)
+	}
+	for _, amop := range catalog.AmopsUsingOperator(op.Oid) {
+		if amop.Amopmethod == catalog.GistAmOid {
+			return amop.Amopstrategy
+		}
+	}
+	t.Fatalf("operator %s is not in a gist operator family", oprname)
+	return 0
+}
+
+func TestGistPointOpsConsistent(t *testing.T) {
+	consistent, compress := supportProc(t, 1), supportProc(t, 3)
+	compressed, err := compress(&FunctionCallInfo{Args: []adt.Datum{&gist.Entry{Key: &adt.Point{X: 1, Y: 2}, Leaf: true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		oprname   string
+		righttype catalog.Oid
+		query     adt.Datum
+		want      bool
+	}{
+		{"<<", catalog.POINTOID, &adt.Point{X: 2, Y: 2}, true},
+		{">>", catalog.POINTOID, &adt.Point{X: 2, Y: 2}, false},
+		{"~=", catalog.POINTOID, &adt.Point{X: 1, Y: 2}, true},
+		{"<@", catalog.BOXOID, &adt.Box{High: adt.Point{X: 5, Y: 5}}, true},
+	}
+	for _, tt := range tests {
+		recheck := true
+		strategy := strategyOf(t, tt.oprname, tt.righttype)
+		got, err := consistent(&FunctionCallInfo{Args: []adt.Datum{compressed, tt.query, strategy, tt.righttype, &recheck}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want || recheck {
+			t.Errorf("consistent for %s = %v (recheck %t), want %t", tt.oprname, got, recheck, tt.want)
+		}
+	}
+}
+
+func TestGistPointOpsDistance(t *testing.T) {
+	distance := supportProc(t, 8)
+	strategy := strategyOf(t, "<->", catalog.POINTOID)
+	recheck := true
+	key := &gist.Entry{Key: &adt.Box{Low: adt.Point{X: 3, Y: 4}, High: adt.Point{X: 6, Y: 8}}}
+	got, err := distance(&FunctionCallInfo{Args: []adt.Datum{key, &adt.Point{}, strategy, catalog.POINTOID, &recheck}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 5.0 || recheck {
+		t.Errorf("distance = %v (recheck %t), want 5", got, recheck)
+	}
+}
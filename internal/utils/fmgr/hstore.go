@@ -0,0 +1,129 @@
+package fmgr
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/contrib/hstore"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// hstore の関数と演算子クラスのサポート関数 (contrib/hstore の hstore_op.c, hstore_gin.c 相当)
+// ----------------------------------------------------------------
+// ->、?、@> などの演算子の関数、akeys() などの関数と、gin_hstore_ops のサポート関数。
+// hstore は fmgr を参照しないため、ここで登録する。hstore の値の入出力は adt の typeio.go が扱う。
+//
+// GIN のサポート関数の internal の引数は trgm.go と同じく、*int32 (キーの数) と []bool (check) で
+// 受け取る。GIN のキーは hstore.GinExtractValue の返す、接頭辞を付けたキーと値 (string)。
+
+func init() {
+	Register("hstore_fetchval", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		if val, ok := fcinfo.Args[0].(*hstore.Hstore).FetchVal(fcinfo.Args[1].(string)); ok {
+			return val, nil
+		}
+		return nil, nil
+	})
+	Register("hstore_exists", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return fcinfo.Args[0].(*hstore.Hstore).Exists(fcinfo.Args[1].(string)), nil
+	})
+	Register("hstore_exists_any", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return fcinfo.Args[0].(*hstore.Hstore).ExistsAny(arrayKeys(fcinfo.Args[1].(*adt.Array))), nil
+	})
+	Register("hstore_exists_all", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return fcinfo.Args[0].(*hstore.Hstore).ExistsAll(arrayKeys(fcinfo.Args[1].(*adt.Array))), nil
+	})
+	Register("hstore_contains", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return fcinfo.Args[0].(*hstore.Hstore).Contains(fcinfo.Args[1].(*hstore.Hstore)), nil
+	})
+	Register("hstore_contained", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return fcinfo.Args[0].(*hstore.Hstore).ContainedBy(fcinfo.Args[1].(*hstore.Hstore)), nil
+	})
+	Register("hstore_concat", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return fcinfo.Args[0].(*hstore.Hstore).Concat(fcinfo.Args[1].(*hstore.Hstore)), nil
+	})
+	Register("hstore_delete", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return fcinfo.Args[0].(*hstore.Hstore).Delete(fcinfo.Args[1].(string)), nil
+	})
+	Register("hstore_akeys", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return adt.TextArray(fcinfo.Args[0].(*hstore.Hstore).Akeys()), nil
+	})
+	Register("hstore_avals", hstoreAvals)
+	Register("hstore_slice_to_hstore", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return fcinfo.Args[0].(*hstore.Hstore).Slice(arrayKeys(fcinfo.Args[1].(*adt.Array))), nil
+	})
+	Register("hstore_to_json", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return fcinfo.Args[0].(*hstore.Hstore).ToJSON(), nil
+	})
+	Register("hstore_to_jsonb", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return fcinfo.Args[0].(*hstore.Hstore).ToJSON(), nil
+	})
+
+	Register("gin_extract_hstore", ginExtractHstore)
+	Register("gin_extract_hstore_query", ginExtractHstoreQuery)
+	Register("gin_consistent_hstore", ginConsistentHstore)
+}
+
+// arrayKeys は text[] の要素のうち NULL でないものを返す。C言語版と同じく、NULL のキーは無視する。
+func arrayKeys(arr *adt.Array) []string {
+	keys := make([]string, 0, len(arr.Elems))
+	for _, e := range arr.Elems {
+		if e != nil {
+			keys = append(keys, e.(string))
+		}
+	}
+	return keys
+}
+
+// hstoreAvals は avals(hstore) 相当。NULL の値は配列の NULL の要素にする。
+func hstoreAvals(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	vals := fcinfo.Args[0].(*hstore.Hstore).Avals()
+	arr := &adt.Array{ElemType: catalog.TEXTOID}
+	if len(vals) == 0 {
+		return arr, nil
+	}
+	arr.Dims, arr.LBounds = []int{len(vals)}, []int{1}
+	for _, v := range vals {
+		if v == nil {
+			arr.Elems = append(arr.Elems, nil)
+		} else {
+			arr.Elems = append(arr.Elems, *v)
+		}
+	}
+	return arr, nil
+}
+
+// hstoreGinKeys は GIN のキーを Datum の並びにして、キーの数を nentries に書き込む
+func hstoreGinKeys(entries []string, nentries *int32) []adt.Datum {
+	keys := make([]adt.Datum, len(entries))
+	for i, e := range entries {
+		keys[i] = e
+	}
+	*nentries = int32(len(keys))
+	return keys
+}
+
+// ginExtractHstore は gin_extract_hstore(hs, nentries) 相当
+func ginExtractHstore(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	return hstoreGinKeys(hstore.GinExtractValue(fcinfo.Args[0].(*hstore.Hstore)), fcinfo.Args[1].(*int32)), nil
+}
+
+// ginExtractHstoreQuery は gin_extract_hstore_query(query, nentries, strategy, ...) 相当。
+// 問い合わせの値の型はストラテジで決まり、@> は hstore、? は text、?| と ?& は text[]。
+func ginExtractHstoreQuery(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	var entries []string
+	switch q := fcinfo.Args[0].(type) {
+	case *hstore.Hstore:
+		entries = hstore.GinExtractQueryContains(q)
+	case string:
+		entries = hstore.GinExtractQueryExists([]string{q})
+	case *adt.Array:
+		entries = hstore.GinExtractQueryExists(arrayKeys(q))
+	}
+	return hstoreGinKeys(entries, fcinfo.Args[1].(*int32)), nil
+}
+
+// ginConsistentHstore は gin_consistent_hstore(check, strategy, query, nkeys, extra_data, recheck) 相当
+func ginConsistentHstore(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	match, recheck := hstore.GinConsistent(fcinfo.Args[0].([]bool), hstore.Strategy(fcinfo.Args[1].(int16)))
+	*fcinfo.Args[5].(*bool) = recheck
+	return match, nil
+}
@@ -0,0 +1,146 @@
+package fmgr
+
+import (
+	"testing"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/contrib/hstore"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// operatorFunc は演算子 oprname(hstore,righttype) の関数を引く
+func operatorFunc(t *testing.T, oprname string, righttype catalog.Oid) PGFunction {
+	t.Helper()
+	op, ok := catalog.OperatorLookup(oprname, catalog.HSTOREOID, righttype)
+	if !ok {
+		t.Fatalf("operator %s does not exist", oprname)
+	}
+	proc, ok := catalog.SearchProc(op.Oprcode)
+	if !ok {
+		t.Fatalf("no pg_proc row %d", op.Oprcode)
+	}
+	fn, ok := Lookup(proc.Prosrc)
+	if !ok {
+		t.Fatalf("%s is not registered", proc.Prosrc)
+	}
+	return fn
+}
+
+func mustHstore(t *testing.T, s string) *hstore.Hstore {
+	t.Helper()
+	h, err := adt.HstoreIn(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestHstoreOperators(t *testing.T) {
+	h := mustHstore(t, `a=>1, b=>NULL`)
+	tests := []struct {
+		oprname   string
+		righttype catalog.Oid
+		arg       adt.Datum
+		want      string
+	}{
+		{"->", catalog.TEXTOID, "a", "1"},
+		{"->", catalog.TEXTOID, "b", "<nil>"},
+		{"?", catalog.TEXTOID, "b", "t"},
+		{"?|", catalog.TEXTARRAYOID, adt.TextArray([]string{"x", "a"}), "t"},
+		{"?&", catalog.TEXTARRAYOID, adt.TextArray([]string{"x", "a"}), "f"},
+		{"@>", catalog.HSTOREOID, mustHstore(t, `a=>1`), "t"},
+		{"<@", catalog.HSTOREOID, mustHstore(t, `a=>1`), "f"},
+		{"||", catalog.HSTOREOID, mustHstore(t, `c=>3`), `"a"=>"1", "b"=>NULL, "c"=>"3"`},
+		{"-", catalog.TEXTOID, "a", `"b"=>NULL`},
+	}
+	for _, tt := range tests {
+		got, err := operatorFunc(t, tt.oprname, tt.righttype)(&FunctionCallInfo{Args: []adt.Datum{h, tt.arg}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := "<nil>"
+		if got != nil {
+			s = adt.OutputFunctionCall(catalog.InvalidOid, got)
+		}
+		if s != tt.want {
+			t.Errorf("hstore %s %v = %s, want %s", tt.oprname, tt.arg, s, tt.want)
+		}
+	}
+}
+
+func TestHstoreSendRecv(t *testing.T) {
+	h := mustHstore(t, `a=>1, "long key"=>NULL`)
+	buf, err := adt.SendFunctionCall(catalog.HSTOREOID, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := adt.ReceiveFunctionCall(catalog.HSTOREOID, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.(*hstore.Hstore).String() != h.String() {
+		t.Errorf("recv(send(%s)) = %s", h, got)
+	}
+	if _, err := adt.ReceiveFunctionCall(catalog.HSTOREOID, buf[:len(buf)-1]); err == nil {
+		t.Error("truncated hstore was accepted")
+	}
+}
+
+func TestGinHstoreOps(t *testing.T) {
+	opclass, ok := catalog.GetDefaultOpclass(catalog.GinAmOid, catalog.HSTOREOID)
+	if !ok {
+		t.Fatal("no default gin operator class for hstore")
+	}
+	support := func(procnum int16) PGFunction {
+		amproc, ok := catalog.SearchAmproc(opclass.Opcfamily, catalog.HSTOREOID, catalog.HSTOREOID, procnum)
+		if !ok {
+			t.Fatalf("no support function %d in %s", procnum, opclass.Opcname)
+		}
+		proc, _ := catalog.SearchProc(amproc.Amproc)
+		fn, ok := Lookup(proc.Prosrc)
+		if !ok {
+			t.Fatalf("%s is not registered", proc.Prosrc)
+		}
+		return fn
+	}
+	extractValue, extractQuery, consistent := support(2), support(3), support(4)
+
+	var nvalue, nquery int32
+	value, err := extractValue(&FunctionCallInfo{Args: []adt.Datum{mustHstore(t, `a=>1, b=>2`), &nvalue}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	has := make(map[adt.Datum]bool)
+	for _, k := range value.([]adt.Datum) {
+		has[k] = true
+	}
+	tests := []struct {
+		query    adt.Datum
+		strategy hstore.Strategy
+		want     bool
+	}{
+		{mustHstore(t, `a=>1`), hstore.ContainsStrategyNumber, true},
+		{mustHstore(t, `a=>3`), hstore.ContainsStrategyNumber, false},
+		{"b", hstore.ExistsStrategyNumber, true},
+		{adt.TextArray([]string{"x", "y"}), hstore.ExistsAnyStrategyNumber, false},
+		{adt.TextArray([]string{"a", "b"}), hstore.ExistsAllStrategyNumber, true},
+	}
+	for _, tt := range tests {
+		keys, err := extractQuery(&FunctionCallInfo{Args: []adt.Datum{tt.query, &nquery, int16(tt.strategy), nil, nil}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var check []bool
+		for _, k := range keys.([]adt.Datum) {
+			check = append(check, has[k])
+		}
+		var recheck bool
+		got, err := consistent(&FunctionCallInfo{Args: []adt.Datum{check, int16(tt.strategy), tt.query, nquery, nil, &recheck}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("strategy %d for %v = %v, want %t", tt.strategy, tt.query, got, tt.want)
+		}
+	}
+}
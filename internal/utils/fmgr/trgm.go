@@ -0,0 +1,170 @@
+package fmgr
+
+import (
+	"github.com/Tsubasa-2005/go-postgres/internal/access/gist"
+	"github.com/Tsubasa-2005/go-postgres/internal/contrib/pgtrgm"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// ----------------------------------------------------------------
+// pg_trgm の関数と演算子クラスのサポート関数 (contrib/pg_trgm の trgm_op.c, trgm_gin.c, trgm_gist.c 相当)
+// ----------------------------------------------------------------
+// similarity() などの関数、% や <-> などの演算子の関数と、gin_trgm_ops と gist_trgm_ops の
+// サポート関数。pgtrgm は fmgr を参照しないため、ここで登録する。
+//
+// 類似度のしきい値は pgtrgm.SimilarityThreshold などの既定値を使う。
+// pg_trgm.similarity_threshold などの設定パラメータはまだない。
+//
+// サポート関数の internal の引数は、C言語版のポインタの代わりに次の Go の値で受け取る。
+// geo_ops.go と異なり、GiST のページ分割の結果は pgtrgm.GistSplit で返す。
+//
+//	int32 *nentries      → *int32 (キーの数を書き込む)
+//	bool check[]         → []bool
+//	GISTENTRY *          → *gist.Entry (Key は葉の値の string か、圧縮した []pgtrgm.Trigram)
+//	GistEntryVector *    → []*gist.Entry
+//	GIST_SPLITVEC *      → *pgtrgm.GistSplit
+//	float *, bool *      → *float64, *bool (結果を書き込む)
+//
+// GIN のキーはトライグラムそのものを text の値 (string) として返す。
+
+func init() {
+	Register("show_trgm", func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return adt.TextArray(pgtrgm.ShowTrgm(fcinfo.Args[0].(string))), nil
+	})
+	registerTrgmFunc("similarity", pgtrgm.Similarity)
+	registerTrgmFunc("word_similarity", pgtrgm.WordSimilarity)
+	registerTrgmFunc("strict_word_similarity", pgtrgm.StrictWordSimilarity)
+	registerTrgmFunc("similarity_dist", pgtrgm.SimilarityDist)
+	registerTrgmFunc("word_similarity_dist_op", pgtrgm.WordSimilarityDist)
+	registerTrgmFunc("word_similarity_dist_commutator_op", commute(pgtrgm.WordSimilarityDist))
+	registerTrgmFunc("strict_word_similarity_dist_op", pgtrgm.StrictWordSimilarityDist)
+	registerTrgmFunc("strict_word_similarity_dist_commutator_op", commute(pgtrgm.StrictWordSimilarityDist))
+	registerTrgmOp("similarity_op", pgtrgm.SimilarityOp)
+	registerTrgmOp("word_similarity_op", pgtrgm.WordSimilarityOp)
+	registerTrgmOp("word_similarity_commutator_op", commute(pgtrgm.WordSimilarityOp))
+	registerTrgmOp("strict_word_similarity_op", pgtrgm.StrictWordSimilarityOp)
+	registerTrgmOp("strict_word_similarity_commutator_op", commute(pgtrgm.StrictWordSimilarityOp))
+
+	Register("gin_extract_value_trgm", ginExtractValueTrgm)
+	Register("gin_extract_query_trgm", ginExtractQueryTrgm)
+	Register("gin_trgm_consistent", ginTrgmConsistent)
+
+	Register("gtrgm_consistent", gtrgmConsistent)
+	Register("gtrgm_distance", gtrgmDistance)
+	Register("gtrgm_compress", gtrgmCompress)
+	Register("gtrgm_union", gtrgmUnion)
+	Register("gtrgm_penalty", gtrgmPenalty)
+	Register("gtrgm_picksplit", gtrgmPicksplit)
+	Register("gtrgm_same", gtrgmSame)
+}
+
+// registerTrgmFunc は2つの text から float4 を返す関数を登録する
+func registerTrgmFunc(name string, fn func(a, b string) float64) {
+	Register(name, func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return float32(fn(fcinfo.Args[0].(string), fcinfo.Args[1].(string))), nil
+	})
+}
+
+// registerTrgmOp は2つの text を比べる演算子の関数を登録する
+func registerTrgmOp(name string, op func(a, b string) bool) {
+	Register(name, func(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+		return op(fcinfo.Args[0].(string), fcinfo.Args[1].(string)), nil
+	})
+}
+
+// commute は引数を入れ替えた関数を返す。%> などの交代演算子は、<% などの関数を逆の順に呼ぶ。
+func commute[T any](fn func(a, b string) T) func(a, b string) T {
+	return func(a, b string) T { return fn(b, a) }
+}
+
+// trgmKeys はトライグラムを GIN のキーの並びにして、キーの数を nentries に書き込む
+func trgmKeys(trgs []pgtrgm.Trigram, nentries *int32) []adt.Datum {
+	keys := make([]adt.Datum, len(trgs))
+	for i, t := range trgs {
+		keys[i] = string(t)
+	}
+	*nentries = int32(len(keys))
+	return keys
+}
+
+// ginExtractValueTrgm は gin_extract_value_trgm(value, nentries) 相当
+func ginExtractValueTrgm(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	return trgmKeys(pgtrgm.GinExtractValue(fcinfo.Args[0].(string)), fcinfo.Args[1].(*int32)), nil
+}
+
+// ginExtractQueryTrgm は gin_extract_query_trgm(query, nentries, strategy, ...) 相当。
+// 部分一致と extra_data は使わない。
+func ginExtractQueryTrgm(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	strategy := pgtrgm.Strategy(fcinfo.Args[2].(int16))
+	return trgmKeys(pgtrgm.GinExtractQuery(fcinfo.Args[0].(string), strategy), fcinfo.Args[1].(*int32)), nil
+}
+
+// ginTrgmConsistent は gin_trgm_consistent(check, strategy, query, nkeys, extra_data, recheck, ...) 相当
+func ginTrgmConsistent(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	match, recheck := pgtrgm.GinConsistent(fcinfo.Args[0].([]bool), pgtrgm.Strategy(fcinfo.Args[1].(int16)))
+	*fcinfo.Args[5].(*bool) = recheck
+	return match, nil
+}
+
+// trgmEntryKeys は索引キーの並びからトライグラムの集合を取り出す
+func trgmEntryKeys(entryvec []*gist.Entry) [][]pgtrgm.Trigram {
+	keys := make([][]pgtrgm.Trigram, len(entryvec))
+	for i, e := range entryvec {
+		keys[i] = e.Key.([]pgtrgm.Trigram)
+	}
+	return keys
+}
+
+// gtrgmConsistent は gtrgm_consistent(entry, query, strategy, subtype, recheck) 相当
+func gtrgmConsistent(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	entry := fcinfo.Args[0].(*gist.Entry)
+	strategy := pgtrgm.Strategy(fcinfo.Args[2].(int16))
+	match, recheck := pgtrgm.GistConsistent(entry.Key.([]pgtrgm.Trigram), fcinfo.Args[1].(string), strategy, entry.Leaf)
+	*fcinfo.Args[4].(*bool) = recheck
+	return match, nil
+}
+
+// gtrgmDistance は gtrgm_distance(entry, query, strategy, subtype, recheck) 相当
+func gtrgmDistance(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	entry := fcinfo.Args[0].(*gist.Entry)
+	strategy := pgtrgm.Strategy(fcinfo.Args[2].(int16))
+	dist, recheck := pgtrgm.GistDistance(entry.Key.([]pgtrgm.Trigram), fcinfo.Args[1].(string), strategy, entry.Leaf)
+	*fcinfo.Args[4].(*bool) = recheck
+	return dist, nil
+}
+
+// gtrgmCompress は gtrgm_compress(entry) 相当。葉の値をトライグラムの集合にする。
+func gtrgmCompress(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	entry := fcinfo.Args[0].(*gist.Entry)
+	if !entry.Leaf {
+		return entry, nil
+	}
+	return &gist.Entry{Key: pgtrgm.GistCompress(entry.Key.(string)), Leaf: true}, nil
+}
+
+// gtrgmUnion は gtrgm_union(entryvec, sizep) 相当。Go の値には大きさがないため、sizep は使わない。
+func gtrgmUnion(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	return pgtrgm.GistUnion(trgmEntryKeys(fcinfo.Args[0].([]*gist.Entry))), nil
+}
+
+// gtrgmPenalty は gtrgm_penalty(origentry, newentry, penalty) 相当
+func gtrgmPenalty(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	orig, add := fcinfo.Args[0].(*gist.Entry), fcinfo.Args[1].(*gist.Entry)
+	penalty := fcinfo.Args[2].(*float64)
+	*penalty = pgtrgm.GistPenalty(orig.Key.([]pgtrgm.Trigram), add.Key.([]pgtrgm.Trigram))
+	return penalty, nil
+}
+
+// gtrgmPicksplit は gtrgm_picksplit(entryvec, splitvec) 相当
+func gtrgmPicksplit(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	v := fcinfo.Args[1].(*pgtrgm.GistSplit)
+	*v = *pgtrgm.GistPicksplit(trgmEntryKeys(fcinfo.Args[0].([]*gist.Entry)))
+	return v, nil
+}
+
+// gtrgmSame は gtrgm_same(a, b, result) 相当
+func gtrgmSame(fcinfo *FunctionCallInfo) (adt.Datum, error) {
+	result := fcinfo.Args[2].(*bool)
+	*result = pgtrgm.GistSame(fcinfo.Args[0].([]pgtrgm.Trigram), fcinfo.Args[1].([]pgtrgm.Trigram))
+	return result, nil
+}
@@ -0,0 +1,140 @@
+package fmgr
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/gist"
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/contrib/pgtrgm"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+)
+
+// trgmSupportProc は演算子クラス opcname (text) から、サポート関数 procnum の実装を引く
+func trgmSupportProc(t *testing.T, amoid catalog.Oid, opcname string, procnum int16) PGFunction {
+	t.Helper()
+	opclass, ok := catalog.SearchOpclass(amoid, opcname)
+	if !ok {
+		t.Fatalf("operator class %s does not exist", opcname)
+	}
+	amproc, ok := catalog.SearchAmproc(opclass.Opcfamily, catalog.TEXTOID, catalog.TEXTOID, procnum)
+	if !ok {
+		t.Fatalf("no support function %d in %s", procnum, opcname)
+	}
+	proc, ok := catalog.SearchProc(amproc.Amproc)
+	if !ok {
+		t.Fatalf("no pg_proc row %d", amproc.Amproc)
+	}
+	fn, ok := Lookup(proc.Prosrc)
+	if !ok {
+		t.Fatalf("%s is not registered", proc.Prosrc)
+	}
+	return fn
+}
+
+// callOperator は演算子 oprname(text,text) の関数を呼ぶ
+func callOperator(t *testing.T, oprname string, a, b string) adt.Datum {
+	t.Helper()
+	op, ok := catalog.OperatorLookup(oprname, catalog.TEXTOID, catalog.TEXTOID)
+	if !ok {
+		t.Fatalf("operator %s does not exist", oprname)
+	}
+	proc, ok := catalog.SearchProc(op.Oprcode)
+	if !ok {
+		t.Fatalf("no pg_proc row %d", op.Oprcode)
+	}
+	fn, ok := Lookup(proc.Prosrc)
+	if !ok {
+		t.Fatalf("%s is not registered", proc.Prosrc)
+	}
+	got, err := fn(&FunctionCallInfo{Args: []adt.Datum{a, b}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestTrgmOperators(t *testing.T) {
+	tests := []struct {
+		oprname string
+		a, b    string
+		want    adt.Datum
+	}{
+		{"%", "word", "words", true},
+		{"%", "word", "ward", false},
+		// % 以外の word_similarity の演算子は、単語の側を < の側に書く
+		{"<%", "word", "two words", true},
+		{"%>", "two words", "word", true},
+		{"%>", "word", "two words", false},
+		{"<->", "word", "two words", float32(1 - 4.0/11)},
+		{"<<->", "word", "two words", float32(1 - 0.8)},
+		{"<->>", "two words", "word", float32(1 - 0.8)},
+	}
+	for _, tt := range tests {
+		if got := callOperator(t, tt.oprname, tt.a, tt.b); got != tt.want {
+			t.Errorf("%q %s %q = %v, want %v", tt.a, tt.oprname, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestGinTrgmOps(t *testing.T) {
+	extractValue := trgmSupportProc(t, catalog.GinAmOid, "gin_trgm_ops", 2)
+	extractQuery := trgmSupportProc(t, catalog.GinAmOid, "gin_trgm_ops", 3)
+	consistent := trgmSupportProc(t, catalog.GinAmOid, "gin_trgm_ops", 4)
+
+	var nvalue, nquery int32
+	value, err := extractValue(&FunctionCallInfo{Args: []adt.Datum{"word", &nvalue}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, err := extractQuery(&FunctionCallInfo{Args: []adt.Datum{"words", &nquery, int16(pgtrgm.SimilarityStrategyNumber), nil, nil, nil, nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nvalue != 5 || nquery != 6 {
+		t.Fatalf("nentries = %d, %d; want 5, 6", nvalue, nquery)
+	}
+	// 索引値のキーにある問い合わせのキーを check に立てる
+	has := make(map[adt.Datum]bool)
+	for _, k := range value.([]adt.Datum) {
+		has[k] = true
+	}
+	var check []bool
+	for _, k := range query.([]adt.Datum) {
+		check = append(check, has[k])
+	}
+	recheck := false
+	got, err := consistent(&FunctionCallInfo{Args: []adt.Datum{check, int16(pgtrgm.SimilarityStrategyNumber), "words", nquery, nil, &recheck, nil, nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != true || !recheck {
+		t.Errorf("consistent = %v (recheck %t), want true with recheck", got, recheck)
+	}
+}
+
+func TestGistTrgmOps(t *testing.T) {
+	consistent := trgmSupportProc(t, catalog.GistAmOid, "gist_trgm_ops", 1)
+	compress := trgmSupportProc(t, catalog.GistAmOid, "gist_trgm_ops", 3)
+	distance := trgmSupportProc(t, catalog.GistAmOid, "gist_trgm_ops", 8)
+
+	compressed, err := compress(&FunctionCallInfo{Args: []adt.Datum{&gist.Entry{Key: "word", Leaf: true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recheck := true
+	got, err := consistent(&FunctionCallInfo{Args: []adt.Datum{compressed, "words", int16(pgtrgm.SimilarityStrategyNumber), catalog.TEXTOID, &recheck}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != true || recheck {
+		t.Errorf("consistent = %v (recheck %t), want true without recheck", got, recheck)
+	}
+	got, err = distance(&FunctionCallInfo{Args: []adt.Datum{compressed, "words", int16(pgtrgm.DistanceStrategyNumber), catalog.TEXTOID, &recheck}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(got.(float64)-3.0/7) > 1e-12 || recheck {
+		t.Errorf("distance = %v (recheck %t), want %v", got, recheck, 3.0/7)
+	}
+}
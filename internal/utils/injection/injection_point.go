@@ -0,0 +1,115 @@
+package injection
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// インジェクションポイント (utils/misc/injection_point.c 相当)
+// ----------------------------------------------------------------
+// ストレージや WAL の処理の途中に名前付きの地点 (インジェクションポイント) を置き、
+// そこで障害を起こせるようにする。クラッシュリカバリの検証に使う。
+//
+// C言語版では --enable-injection-points を付けてビルドした場合にだけ有効になる。
+// Go言語版ではビルドタグ injection_points を付けた場合にだけ有効になり、
+// 付けない場合の Run と Write は何もしない (インライン化されて消える)。
+//
+// ポイントに結び付ける動作は次の3つ。
+//
+//   - error: その地点の処理を失敗させる (fsync の失敗など)
+//   - crash: その場でプロセスを終了させる。後片付けは一切行わない。
+//   - tear:  書き込みを途中までで止めてからプロセスを終了させる (ページの破損)
+//
+// 動作は環境変数 PG_INJECTION_POINTS で postmaster の起動時に設定できる。
+// 書式は "名前=動作[:引数]" をカンマで区切ったもの。引数は error と crash では
+// 発動するまでに素通りさせる回数、tear では実際に書き込むバイト数を表す。
+//
+//	PG_INJECTION_POINTS="mdsync=error,checkpoint-before-control-file=crash:3,mdwrite=tear:512"
+//
+// 置いてあるポイントは次のとおり。WAL をまだ書かないため、WAL のレコードごとのポイントはない。
+//
+//   - mdwrite, mdextend: リレーションのブロックを書く (Write, Write)
+//   - fd-sync: 仮想ファイル記述子のファイルを同期する (Run)
+//   - mdsync: チェックポイントが同期の要求のファイルを1つ同期する (Run)
+//   - checkpoint-before-control-file: チェックポイントがページを書き終え、制御ファイルを書き換える前 (Run)
+//   - datachecksums-process-block: データチェックサムのワーカーがブロックを1つ処理する前 (Run)
+
+// EnvVar はインジェクションポイントを設定する環境変数の名前
+const EnvVar = "PG_INJECTION_POINTS"
+
+// Action はインジェクションポイントで起こす障害の種類
+type Action int
+
+const (
+	ActionError Action = iota
+	ActionCrash
+	ActionTear
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionError:
+		return "error"
+	case ActionCrash:
+		return "crash"
+	case ActionTear:
+		return "tear"
+	}
+	return "unknown"
+}
+
+// Point はインジェクションポイントに結び付けた動作
+type Point struct {
+	Action Action
+	// Skip は発動するまでに素通りさせる回数 (error, crash)
+	Skip int
+	// TearBytes は tear で実際に書き込むバイト数
+	TearBytes int
+}
+
+// ErrInjected は error を結び付けたポイントが返すエラー
+var ErrInjected = errors.New("injected failure")
+
+// CrashExitCode は crash と tear でプロセスを終了させる際の終了コード。
+// SIGKILL で終了した場合と区別できるよう、通常の終了コードと重ならない値にする。
+const CrashExitCode = 99
+
+// ParsePoints は PG_INJECTION_POINTS の書式の文字列を解析する。
+func ParsePoints(s string) (map[string]Point, error) {
+	points := make(map[string]Point)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid injection point specification \"%s\"", item)
+		}
+		action, arg, hasArg := strings.Cut(spec, ":")
+		n := 0
+		if hasArg {
+			var err error
+			if n, err = strconv.Atoi(arg); err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid argument \"%s\" for injection point \"%s\"", arg, name)
+			}
+		}
+
+		var p Point
+		switch action {
+		case "error":
+			p = Point{Action: ActionError, Skip: n}
+		case "crash":
+			p = Point{Action: ActionCrash, Skip: n}
+		case "tear":
+			p = Point{Action: ActionTear, TearBytes: n}
+		default:
+			return nil, fmt.Errorf("unrecognized injection point action \"%s\"", action)
+		}
+		points[name] = p
+	}
+	return points, nil
+}
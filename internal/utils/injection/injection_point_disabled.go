@@ -0,0 +1,34 @@
+//go:build !injection_points
+
+package injection
+
+import (
+	"errors"
+	"os"
+)
+
+// Enabled はインジェクションポイントが有効なビルドかを表す
+const Enabled = false
+
+// Attach はインジェクションポイントが無効なビルドでは常にエラーを返す
+func Attach(name string, p Point) error {
+	return errors.New("injection points are not supported by this build")
+}
+
+// Detach は何もしない
+func Detach(name string) {}
+
+// LoadFromEnv は環境変数が設定されていればエラーを返す。
+// 設定したのに黙って無視されると、障害が起きないことを正常と取り違えるため。
+func LoadFromEnv() error {
+	if os.Getenv(EnvVar) != "" {
+		return errors.New("injection points are not supported by this build")
+	}
+	return nil
+}
+
+// Run は何もしない
+func Run(name string) error { return nil }
+
+// Write は write(buf) を呼ぶだけ
+func Write(name string, buf []byte, write func([]byte) error) error { return write(buf) }
@@ -0,0 +1,121 @@
+//go:build injection_points
+
+package injection
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/errutil"
+)
+
+// Enabled はインジェクションポイントが有効なビルドかを表す
+const Enabled = true
+
+// injectionPoints は結び付けた動作と、各ポイントを通過した回数 (InjectionPointCache 相当)
+var injectionPoints struct {
+	sync.Mutex
+	points map[string]*attached
+}
+
+type attached struct {
+	Point
+	hits int
+}
+
+// Attach はポイントに動作を結び付ける (InjectionPointAttach 相当)。
+// 既に結び付けてある場合は置き換える。
+func Attach(name string, p Point) error {
+	injectionPoints.Lock()
+	defer injectionPoints.Unlock()
+	if injectionPoints.points == nil {
+		injectionPoints.points = make(map[string]*attached)
+	}
+	injectionPoints.points[name] = &attached{Point: p}
+	return nil
+}
+
+// Detach はポイントから動作を外す (InjectionPointDetach 相当)
+func Detach(name string) {
+	injectionPoints.Lock()
+	defer injectionPoints.Unlock()
+	delete(injectionPoints.points, name)
+}
+
+// LoadFromEnv は環境変数 PG_INJECTION_POINTS の設定を結び付ける。
+func LoadFromEnv() error {
+	s := os.Getenv(EnvVar)
+	if s == "" {
+		return nil
+	}
+	points, err := ParsePoints(s)
+	if err != nil {
+		return err
+	}
+	for name, p := range points {
+		if err := Attach(name, p); err != nil {
+			return err
+		}
+		errutil.Report(nil, errutil.Elog(errutil.Log, "injection point \"%s\" attached (%s)", name, p.Action))
+	}
+	return nil
+}
+
+// lookup はポイントを通過したことを記録し、発動する場合にその動作を返す。
+func lookup(name string) (Point, bool) {
+	injectionPoints.Lock()
+	defer injectionPoints.Unlock()
+	a, ok := injectionPoints.points[name]
+	if !ok {
+		return Point{}, false
+	}
+	a.hits++
+	if a.hits <= a.Skip {
+		return Point{}, false
+	}
+	return a.Point, true
+}
+
+// crash はプロセスを直ちに終了させる。defer も実行しない。
+func crash(name string) {
+	errutil.Report(nil, errutil.Elog(errutil.Log, "crashing at injection point \"%s\"", name))
+	os.Exit(CrashExitCode)
+}
+
+// Run はインジェクションポイントを通過する (INJECTION_POINT 相当)。
+// error が結び付けてあれば ErrInjected を返し、crash と tear ならプロセスを終了させる。
+func Run(name string) error {
+	p, ok := lookup(name)
+	if !ok {
+		return nil
+	}
+	switch p.Action {
+	case ActionError:
+		return fmt.Errorf("injection point \"%s\": %w", name, ErrInjected)
+	default:
+		crash(name)
+	}
+	return nil
+}
+
+// Write はインジェクションポイントを通過しながら write(buf) を呼ぶ。
+// tear が結び付けてあれば、先頭の TearBytes バイトだけを書き込んでからプロセスを終了させる。
+// error なら書き込まずに ErrInjected を返し、crash なら書き込む前に終了させる。
+func Write(name string, buf []byte, write func([]byte) error) error {
+	p, ok := lookup(name)
+	if !ok {
+		return write(buf)
+	}
+	switch p.Action {
+	case ActionError:
+		return fmt.Errorf("injection point \"%s\": %w", name, ErrInjected)
+	case ActionTear:
+		n := min(p.TearBytes, len(buf))
+		if err := write(buf[:n]); err != nil {
+			return err
+		}
+	}
+	crash(name)
+	return nil
+}
@@ -0,0 +1,116 @@
+package pgstat
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/waitevent"
+)
+
+// ----------------------------------------------------------------
+// 累積統計 (utils/activity/pgstat.c, pgstat_shmem.c 相当)
+// ----------------------------------------------------------------
+// テーブルやインデックスの操作の回数と関数の呼び出しの回数、データベースごとのトランザクションと
+// 一時ファイルの数を、全てのバックエンドで共有する統計に積み上げる。
+//
+// バックエンドは操作の回数をまず自分の Pending に数え、アイドルになったときとセッションの終了時に
+// ReportStat で共有の統計に加える。操作のたびに共有の統計のロックを取らないためである。
+// 挿入、更新、削除した行の数はトランザクションの中で別に数え、トランザクションが終わったときに
+// コミットかアボートかに応じて生きている行と不要な行の数に振り分ける。
+//
+// C言語版は統計をサーバーの停止時にファイル (pg_stat/pgstat.stat) に書き、起動時に読む。
+// Go言語版はまだ書かないため、サーバーを起動し直すと統計は空になる。
+
+// Key は統計の対象 (PgStat_HashKey 相当)。共有のカタログは Dboid を InvalidOid にする。
+type Key struct {
+	Dboid catalog.Oid
+	Objid catalog.Oid
+}
+
+// shared は全てのバックエンドが共有する統計 (PgStat_ShmemControl 相当)
+var shared = struct {
+	sync.Mutex
+	relations map[Key]*StatTabEntry
+	functions map[Key]*StatFuncEntry
+	databases map[catalog.Oid]*StatDBEntry
+}{
+	relations: make(map[Key]*StatTabEntry),
+	functions: make(map[Key]*StatFuncEntry),
+	databases: make(map[catalog.Oid]*StatDBEntry),
+}
+
+// Pending はバックエンドがまだ共有の統計に加えていない回数 (pgStatPending 相当)。
+// セッションごとに NewPending で作り、1つのゴルーチンから使う。
+type Pending struct {
+	relations map[Key]*TableStatus
+	functions map[Key]*FunctionCounts
+	// database は接続先のデータベースについて数えた回数
+	database DatabaseCounts
+	// dboid は接続先のデータベース (MyDatabaseId 相当)。関数の統計はデータベースごとに数える
+	dboid catalog.Oid
+	// totalFuncTime はバックエンドが関数にかけた時間の合計 (total_func_time 相当)
+	totalFuncTime time.Duration
+	// trackFunctions はセッションの track_functions の値を返す
+	trackFunctions func() TrackFunc
+	// trackIOTiming はセッションの track_io_timing の値を返す
+	trackIOTiming func() bool
+	// xact は実行中のトランザクションで数えた回数を持つリレーション (pgStatXactStack 相当)
+	xact []*TableStatus
+	// waitEvent は共有の統計のロックを待つ間を記録する先
+	waitEvent *waitevent.Slot
+}
+
+// NewPending はバックエンドの統計を作る。waitEvent は共有の統計のロックを待つ間を記録する先で、
+// nil なら記録しない。trackFunctions と trackIOTiming はセッションの track_functions と
+// track_io_timing の値を返す。
+func NewPending(waitEvent *waitevent.Slot, trackFunctions func() TrackFunc, trackIOTiming func() bool) *Pending {
+	return &Pending{
+		relations:      make(map[Key]*TableStatus),
+		functions:      make(map[Key]*FunctionCounts),
+		trackFunctions: trackFunctions,
+		trackIOTiming:  trackIOTiming,
+		waitEvent:      waitEvent,
+	}
+}
+
+// SetDatabase は接続先のデータベースを設定する
+func (p *Pending) SetDatabase(dboid catalog.Oid) { p.dboid = dboid }
+
+// ReportStat はバックエンドが数えた回数を共有の統計に加える (pgstat_report_stat 相当)。
+// 実行中のトランザクションの挿入、更新、削除の回数は、トランザクションが終わるまで加えない。
+// C言語版は共有メモリのロックの競合を避けるため PGSTAT_MIN_INTERVAL より頻繁には加えないが、
+// Go言語版は1つのロックで加えるだけなので、アイドルになるたびに加える。
+func (p *Pending) ReportStat() {
+	if len(p.relations) == 0 && len(p.functions) == 0 && p.database == (DatabaseCounts{}) {
+		return
+	}
+	now := time.Now()
+	p.waitEvent.LockMutex(&shared.Mutex, waitevent.LWLockPgStatsData)
+	defer shared.Unlock()
+	for key, ts := range p.relations {
+		if ts.trans != nil {
+			// トランザクションの中の回数が残っているため、次の機会に加える
+			continue
+		}
+		flushRelation(ts, now)
+		delete(p.relations, key)
+	}
+	for key, fs := range p.functions {
+		flushFunction(key, fs)
+		delete(p.functions, key)
+	}
+	flushDatabase(p.dboid, &p.database)
+}
+
+// AtEOXact はトランザクションの終わりに、トランザクションの中で数えた挿入、更新、削除の回数を
+// バックエンドの回数に移す (AtEOXact_PgStat 相当)。コミットした場合は挿入した行が生きている行に、
+// 削除した行と更新前の行が不要な行になる。アボートした場合は挿入した行と更新後の行が不要な行になる。
+// コミットとアボートしたトランザクションの数もここで数える。
+func (p *Pending) AtEOXact(isCommit bool) {
+	p.atEOXactDatabase(isCommit)
+	for _, ts := range p.xact {
+		ts.atEOXact(isCommit)
+	}
+	p.xact = nil
+}
@@ -0,0 +1,65 @@
+package pgstat
+
+import (
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------
+// アーカイバの統計 (utils/activity/pgstat_archiver.c 相当)
+// ----------------------------------------------------------------
+// アーカイバは WAL ファイルを1つアーカイブするたびに ReportArchiver で成功か失敗かを報告し、
+// 成功と失敗の回数と、最後にアーカイブしたファイルと失敗したファイルとその時刻を積み上げる。
+// pg_stat_archiver はこれを1行として返す。
+
+// ArchiverStats はアーカイバの統計 (PgStat_ArchiverStats 相当)
+type ArchiverStats struct {
+	ArchivedCount int64
+	// LastArchivedWal と LastArchivedTimestamp は最後にアーカイブした WAL ファイルとその時刻
+	LastArchivedWal       string
+	LastArchivedTimestamp time.Time
+	FailedCount           int64
+	// LastFailedWal と LastFailedTimestamp は最後にアーカイブに失敗した WAL ファイルとその時刻
+	LastFailedWal       string
+	LastFailedTimestamp time.Time
+	// StatResetTimestamp は統計を 0 にした時刻
+	StatResetTimestamp time.Time
+}
+
+// archiverStats は共有の統計 (PgStatShared_Archiver 相当)。統計を書き出さないため、サーバーの
+// 起動時に 0 から数え始める。
+var archiverStats = struct {
+	sync.Mutex
+	stats ArchiverStats
+}{stats: ArchiverStats{StatResetTimestamp: time.Now()}}
+
+// ReportArchiver は WAL ファイル xlog をアーカイブした結果を加える (pgstat_report_archiver 相当)
+func ReportArchiver(xlog string, failed bool) {
+	now := time.Now()
+	archiverStats.Lock()
+	defer archiverStats.Unlock()
+	s := &archiverStats.stats
+	if failed {
+		s.FailedCount++
+		s.LastFailedWal = xlog
+		s.LastFailedTimestamp = now
+	} else {
+		s.ArchivedCount++
+		s.LastArchivedWal = xlog
+		s.LastArchivedTimestamp = now
+	}
+}
+
+// FetchStatArchiver はアーカイバの統計を返す (pgstat_fetch_stat_archiver 相当)
+func FetchStatArchiver() ArchiverStats {
+	archiverStats.Lock()
+	defer archiverStats.Unlock()
+	return archiverStats.stats
+}
+
+// ResetArchiver はアーカイバの統計を 0 にする (pgstat_archiver_reset_all_cb 相当)
+func ResetArchiver() {
+	archiverStats.Lock()
+	defer archiverStats.Unlock()
+	archiverStats.stats = ArchiverStats{StatResetTimestamp: time.Now()}
+}
@@ -0,0 +1,72 @@
+package pgstat
+
+import (
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------
+// バックグラウンドライターの統計 (utils/activity/pgstat_bgwriter.c 相当)
+// ----------------------------------------------------------------
+// バックグラウンドライターは周期ごとに、書き出したバッファの数と、bgwriter_lru_maxpages に
+// 達して書き出しを止めたかと、前回からバッファを割り当てた数を ReportBgWriter で加える。
+// pg_stat_bgwriter はこれを1行として返す。
+//
+// バックエンドが置き換えるバッファを自分で書き出した数も、ここで数える。バックグラウンドライターが
+// 先回りして書き出せているかは、この数とバックグラウンドライターが書き出した数を比べて確かめる。
+// C言語版では PostgreSQL 16 までの buffers_backend 相当で、17 からは pg_stat_io に移っている。
+
+// BgWriterStats はバックグラウンドライターの統計 (PgStat_BgWriterStats 相当)
+type BgWriterStats struct {
+	// BuffersClean はバックグラウンドライターが書き出したバッファの数 (buf_written_clean 相当)
+	BuffersClean int64
+	// MaxwrittenClean は bgwriter_lru_maxpages に達して書き出しを止めた回数 (maxwritten_clean 相当)
+	MaxwrittenClean int64
+	// BuffersAlloc は割り当てたバッファの数 (buf_alloc 相当)
+	BuffersAlloc int64
+	// BuffersBackend はバックエンドが自分で書き出したバッファの数 (buf_written_backend 相当)
+	BuffersBackend int64
+	// StatResetTimestamp は統計を 0 にした時刻
+	StatResetTimestamp time.Time
+}
+
+// bgwriterStats は共有の統計 (PgStatShared_BgWriter 相当)。統計を書き出さないため、サーバーの
+// 起動時に 0 から数え始める。
+var bgwriterStats = struct {
+	sync.Mutex
+	stats BgWriterStats
+}{stats: BgWriterStats{StatResetTimestamp: time.Now()}}
+
+// ReportBgWriter はバックグラウンドライターが1周期で数えた回数を加える (pgstat_report_bgwriter 相当)。
+// pending の StatResetTimestamp は見ない。
+func ReportBgWriter(pending BgWriterStats) {
+	bgwriterStats.Lock()
+	defer bgwriterStats.Unlock()
+	s := &bgwriterStats.stats
+	s.BuffersClean += pending.BuffersClean
+	s.MaxwrittenClean += pending.MaxwrittenClean
+	s.BuffersAlloc += pending.BuffersAlloc
+	s.BuffersBackend += pending.BuffersBackend
+}
+
+// CountBackendWrite はバックエンドがバッファを1つ書き出したことを加える
+// (pgstat_count_io_op の IOOBJECT_RELATION、IOOP_WRITE 相当)
+func CountBackendWrite() {
+	bgwriterStats.Lock()
+	defer bgwriterStats.Unlock()
+	bgwriterStats.stats.BuffersBackend++
+}
+
+// FetchStatBgWriter はバックグラウンドライターの統計を返す (pgstat_fetch_stat_bgwriter 相当)
+func FetchStatBgWriter() BgWriterStats {
+	bgwriterStats.Lock()
+	defer bgwriterStats.Unlock()
+	return bgwriterStats.stats
+}
+
+// ResetBgWriter はバックグラウンドライターの統計を 0 にする (pgstat_bgwriter_reset_all_cb 相当)
+func ResetBgWriter() {
+	bgwriterStats.Lock()
+	defer bgwriterStats.Unlock()
+	bgwriterStats.stats = BgWriterStats{StatResetTimestamp: time.Now()}
+}
@@ -0,0 +1,74 @@
+package pgstat
+
+import (
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------
+// checkpointer の統計 (utils/activity/pgstat_checkpointer.c 相当)
+// ----------------------------------------------------------------
+// checkpointer はチェックポイントを始めるたびに、checkpoint_timeout が経ったためか、要求が
+// 来たためかを数える。チェックポイントの処理は、書き出しと同期にかけた時間と書き出した
+// バッファの数を ReportCheckpointer で加える。シャットダウンのチェックポイントは始めた回数には
+// 数えず、書き出しだけを数える。pg_stat_checkpointer はこれを1行として返す。
+//
+// リカバリーはまだないため、リスタートポイントの回数は常に 0 になる。
+
+// CheckpointerStats は checkpointer の統計 (PgStat_CheckpointerStats 相当)
+type CheckpointerStats struct {
+	// NumTimed は checkpoint_timeout が経ったために始めたチェックポイントの数 (num_timed 相当)
+	NumTimed int64
+	// NumRequested は要求が来たために始めたチェックポイントの数 (num_requested 相当)
+	NumRequested int64
+	// RestartpointsTimed、RestartpointsRequested、RestartpointsPerformed はリスタートポイントの数
+	// (restartpoints_timed、restartpoints_requested、restartpoints_performed 相当)
+	RestartpointsTimed     int64
+	RestartpointsRequested int64
+	RestartpointsPerformed int64
+	// WriteTime と SyncTime はチェックポイントでバッファを書き出した時間とファイルを同期した時間
+	// (write_time、sync_time 相当)
+	WriteTime time.Duration
+	SyncTime  time.Duration
+	// BuffersWritten はチェックポイントで書き出したバッファの数 (buffers_written 相当)
+	BuffersWritten int64
+	// StatResetTimestamp は統計を 0 にした時刻
+	StatResetTimestamp time.Time
+}
+
+// checkpointerStats は共有の統計 (PgStatShared_Checkpointer 相当)。統計を書き出さないため、
+// サーバーの起動時に 0 から数え始める。
+var checkpointerStats = struct {
+	sync.Mutex
+	stats CheckpointerStats
+}{stats: CheckpointerStats{StatResetTimestamp: time.Now()}}
+
+// ReportCheckpointer は数えた回数と時間を加える (pgstat_report_checkpointer 相当)。
+// pending の StatResetTimestamp は見ない。
+func ReportCheckpointer(pending CheckpointerStats) {
+	checkpointerStats.Lock()
+	defer checkpointerStats.Unlock()
+	s := &checkpointerStats.stats
+	s.NumTimed += pending.NumTimed
+	s.NumRequested += pending.NumRequested
+	s.RestartpointsTimed += pending.RestartpointsTimed
+	s.RestartpointsRequested += pending.RestartpointsRequested
+	s.RestartpointsPerformed += pending.RestartpointsPerformed
+	s.WriteTime += pending.WriteTime
+	s.SyncTime += pending.SyncTime
+	s.BuffersWritten += pending.BuffersWritten
+}
+
+// FetchStatCheckpointer は checkpointer の統計を返す (pgstat_fetch_stat_checkpointer 相当)
+func FetchStatCheckpointer() CheckpointerStats {
+	checkpointerStats.Lock()
+	defer checkpointerStats.Unlock()
+	return checkpointerStats.stats
+}
+
+// ResetCheckpointer は checkpointer の統計を 0 にする (pgstat_checkpointer_reset_all_cb 相当)
+func ResetCheckpointer() {
+	checkpointerStats.Lock()
+	defer checkpointerStats.Unlock()
+	checkpointerStats.stats = CheckpointerStats{StatResetTimestamp: time.Now()}
+}
@@ -0,0 +1,120 @@
+package pgstat
+
+import (
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+)
+
+// ----------------------------------------------------------------
+// データベースの統計 (utils/activity/pgstat_database.c 相当)
+// ----------------------------------------------------------------
+// バックエンドは接続先のデータベースについて、コミットとアボートしたトランザクションの数と、
+// 作った一時ファイルの数と大きさ、track_io_timing が on の間にデータファイルの読み書きにかけた
+// 時間を Pending に数え、ReportStat でデータベースの共有の統計に加える。一時ファイルは閉じるときに
+// ReportTempFile で、読み書きの時間はバッファマネージャーが CountBufferReadTime と
+// CountBufferWriteTime で数える。pg_stat_database はこれをデータベースごとに1行として返す。
+//
+// データベースに接続しないバックエンドの回数は、datid が 0 の行に数える。
+
+// DatabaseCounts はバックエンドがまだ共有の統計に加えていないデータベースの回数
+// (PgStat_StatDBEntry のうちバックエンドが数える部分相当)
+type DatabaseCounts struct {
+	// XactCommit と XactRollback はコミットとアボートしたトランザクションの数
+	// (pgStatXactCommit、pgStatXactRollback 相当)
+	XactCommit   int64
+	XactRollback int64
+	// TempFiles と TempBytes は作った一時ファイルの数と大きさの合計 (temp_files、temp_bytes 相当)
+	TempFiles int64
+	TempBytes int64
+	// BlkReadTime と BlkWriteTime はデータファイルのブロックの読み込みと書き出しにかけた時間
+	// (pgStatBlockReadTime、pgStatBlockWriteTime 相当)
+	BlkReadTime  time.Duration
+	BlkWriteTime time.Duration
+}
+
+// StatDBEntry は共有の統計の1つのデータベース分 (PgStat_StatDBEntry 相当)
+type StatDBEntry struct {
+	DatabaseCounts
+	// StatResetTimestamp は pg_stat_reset で統計を 0 にした時刻。0 にしていなければゼロの時刻
+	StatResetTimestamp time.Time
+}
+
+// ReportTempFile は閉じた一時ファイルの大きさ filesize を数える (pgstat_report_tempfile 相当)。
+// 呼び出し側は track_counts が on であることを確かめておく。
+func (p *Pending) ReportTempFile(filesize int64) {
+	p.database.TempBytes += filesize
+	p.database.TempFiles++
+}
+
+// TrackIOTiming はセッションの track_io_timing が on かを返す。p が nil なら偽を返す。
+func (p *Pending) TrackIOTiming() bool {
+	return p != nil && p.trackIOTiming != nil && p.trackIOTiming()
+}
+
+// CountBufferReadTime はブロックの読み込みにかけた時間を数える (pgstat_count_buffer_read_time 相当)
+func (p *Pending) CountBufferReadTime(d time.Duration) { p.database.BlkReadTime += d }
+
+// CountBufferWriteTime はブロックの書き出しにかけた時間を数える (pgstat_count_buffer_write_time 相当)
+func (p *Pending) CountBufferWriteTime(d time.Duration) { p.database.BlkWriteTime += d }
+
+// atEOXactDatabase はトランザクションの終わりをコミットかアボートかに応じて数える
+// (AtEOXact_PgStat_Database 相当)
+func (p *Pending) atEOXactDatabase(isCommit bool) {
+	if isCommit {
+		p.database.XactCommit++
+	} else {
+		p.database.XactRollback++
+	}
+}
+
+// flushDatabase はバックエンドのデータベースの回数を共有の統計に加える (pgstat_database_flush_cb 相当)。
+// shared のロックを持って呼ぶ。
+func flushDatabase(dboid catalog.Oid, c *DatabaseCounts) {
+	if *c == (DatabaseCounts{}) {
+		return
+	}
+	entry, ok := shared.databases[dboid]
+	if !ok {
+		entry = &StatDBEntry{}
+		shared.databases[dboid] = entry
+	}
+	entry.XactCommit += c.XactCommit
+	entry.XactRollback += c.XactRollback
+	entry.TempFiles += c.TempFiles
+	entry.TempBytes += c.TempBytes
+	entry.BlkReadTime += c.BlkReadTime
+	entry.BlkWriteTime += c.BlkWriteTime
+	*c = DatabaseCounts{}
+}
+
+// FetchStatDBEntry は dboid のデータベースの統計の写しを返す (pgstat_fetch_stat_dbentry 相当)。
+// まだ何も数えていなければ全て 0 の統計を返す。
+func FetchStatDBEntry(dboid catalog.Oid) StatDBEntry {
+	shared.Lock()
+	defer shared.Unlock()
+	if entry, ok := shared.databases[dboid]; ok {
+		return *entry
+	}
+	return StatDBEntry{}
+}
+
+// ResetCounters は dboid のデータベースの統計と、そのデータベースのリレーションと関数の統計を
+// 0 にする (pgstat_reset_counters 相当)。共有のカタログの統計は、dboid が InvalidOid でも残す。
+func ResetCounters(dboid catalog.Oid) {
+	shared.Lock()
+	defer shared.Unlock()
+	if dboid != catalog.InvalidOid {
+		for key := range shared.relations {
+			if key.Dboid == dboid {
+				delete(shared.relations, key)
+			}
+		}
+		for key := range shared.functions {
+			if key.Dboid == dboid {
+				delete(shared.functions, key)
+			}
+		}
+	}
+	shared.databases[dboid] = &StatDBEntry{StatResetTimestamp: time.Now()}
+}
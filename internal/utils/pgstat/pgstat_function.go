@@ -0,0 +1,134 @@
+package pgstat
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+)
+
+// ----------------------------------------------------------------
+// 関数の統計 (utils/activity/pgstat_function.c 相当)
+// ----------------------------------------------------------------
+// track_functions が none 以外なら、実行器が関数を呼ぶたびに InitFunctionUsage と
+// FunctionCallUsage.End で挟み、呼び出しの回数と、かかった時間の合計 (total_time) と、
+// 呼び出した関数の中で他の関数にかかった時間を除いた時間 (self_time) を数える。
+// 再帰的な呼び出しでは、total_time には一番外側の呼び出しの時間だけを数える。
+//
+// 数えるかは関数の言語で決まる。track_functions が pl なら手続き言語の関数を、all なら
+// C言語と SQL の関数も数える。組み込み関数 (internal) はいつも数えない。関数を作る文
+// (CREATE FUNCTION) がまだなく、今ある関数は全て組み込み関数のため、数える関数はまだない。
+
+// TrackFunc は track_functions の値 (TrackFunctionsLevel 相当)
+type TrackFunc int
+
+const (
+	TrackFuncOff TrackFunc = iota
+	TrackFuncPL
+	TrackFuncAll
+)
+
+// trackFuncLevels は track_functions の選択肢に対応する値
+var trackFuncLevels = map[string]TrackFunc{"none": TrackFuncOff, "pl": TrackFuncPL, "all": TrackFuncAll}
+
+// TrackFunctionsLevel は track_functions の値の名前から TrackFunc を返す
+func TrackFunctionsLevel(name string) TrackFunc { return trackFuncLevels[name] }
+
+// FunctionCounts はバックエンドがまだ共有の統計に加えていない関数の回数 (PgStat_FunctionCounts 相当)
+type FunctionCounts struct {
+	NumCalls  int64
+	TotalTime time.Duration
+	SelfTime  time.Duration
+}
+
+// StatFuncEntry は共有の統計の1つの関数分 (PgStat_StatFuncEntry 相当)
+type StatFuncEntry struct {
+	Funcid    catalog.Oid
+	NumCalls  int64
+	TotalTime time.Duration
+	SelfTime  time.Duration
+}
+
+// FunctionCallUsage は1回の関数の呼び出しの時間を計る状態 (PgStat_FunctionCallUsage 相当)
+type FunctionCallUsage struct {
+	pending *Pending
+	fs      *FunctionCounts
+	// saveFTotalTime は呼び出す前の関数の total_time。再帰的な呼び出しの時間を重ねて数えないため
+	saveFTotalTime time.Duration
+	// saveTotal は呼び出す前のバックエンド全体の関数の時間
+	saveTotal time.Duration
+	start     time.Time
+}
+
+// InitFunctionUsage は funcid の関数を呼ぶ前に時間を計り始める (pgstat_init_function_usage 相当)。
+// fnStats は関数の言語で決まり、track_functions がそれより大きくなければ数えずに nil を返す。
+// 組み込み関数は TrackFuncAll を渡していつも数えない。p が nil の場合も nil を返す。
+func (p *Pending) InitFunctionUsage(funcid catalog.Oid, fnStats TrackFunc) *FunctionCallUsage {
+	if p == nil || p.trackFunctions == nil || p.trackFunctions() <= fnStats {
+		return nil
+	}
+	key := Key{Dboid: p.dboid, Objid: funcid}
+	fs, ok := p.functions[key]
+	if !ok {
+		fs = &FunctionCounts{}
+		p.functions[key] = fs
+	}
+	return &FunctionCallUsage{pending: p, fs: fs, saveFTotalTime: fs.TotalTime, saveTotal: p.totalFuncTime, start: time.Now()}
+}
+
+// End は関数の呼び出しを終えて時間を数える (pgstat_end_function_usage 相当)。finalize は
+// 呼び出しが終わったことを表し、false なら (集合を返す関数が続きを返すときなど) 時間だけを数える。
+func (fcu *FunctionCallUsage) End(finalize bool) {
+	if fcu == nil {
+		return
+	}
+	p := fcu.pending
+	fTotal := time.Since(fcu.start)
+	// 自分の時間は、かかった時間から中で呼んだ関数の時間を除いたもの
+	fSelf := fTotal - (p.totalFuncTime - fcu.saveTotal)
+	p.totalFuncTime += fSelf
+	// 再帰的な呼び出しが数えた時間は捨て、呼び出す前の値にかかった時間を足す
+	fcu.fs.TotalTime = fcu.saveFTotalTime + fTotal
+	fcu.fs.SelfTime += fSelf
+	if finalize {
+		fcu.fs.NumCalls++
+	}
+}
+
+// flushFunction はバックエンドの関数の回数を共有の統計に加える (pgstat_function_flush_cb 相当)。
+// shared のロックを持って呼ぶ。
+func flushFunction(key Key, fs *FunctionCounts) {
+	if *fs == (FunctionCounts{}) {
+		return
+	}
+	entry, ok := shared.functions[key]
+	if !ok {
+		entry = &StatFuncEntry{Funcid: key.Objid}
+		shared.functions[key] = entry
+	}
+	entry.NumCalls += fs.NumCalls
+	entry.TotalTime += fs.TotalTime
+	entry.SelfTime += fs.SelfTime
+}
+
+// DropFunction は削除した関数の統計を捨てる (pgstat_drop_function 相当)
+func DropFunction(key Key) {
+	shared.Lock()
+	defer shared.Unlock()
+	delete(shared.functions, key)
+}
+
+// FetchStatFuncEntries は dboid のデータベースの関数の統計の写しを、OID の順に返す
+// (pgstat_fetch_stat_funcentry 相当)
+func FetchStatFuncEntries(dboid catalog.Oid) []StatFuncEntry {
+	shared.Lock()
+	entries := make([]StatFuncEntry, 0, len(shared.functions))
+	for key, entry := range shared.functions {
+		if key.Dboid == dboid {
+			entries = append(entries, *entry)
+		}
+	}
+	shared.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Funcid < entries[j].Funcid })
+	return entries
+}
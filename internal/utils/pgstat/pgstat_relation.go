@@ -0,0 +1,310 @@
+package pgstat
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/catalog"
+)
+
+// ----------------------------------------------------------------
+// テーブルとインデックスの統計 (utils/activity/pgstat_relation.c 相当)
+// ----------------------------------------------------------------
+// テーブルのアクセスメソッドとインデックスのアクセスメソッドは、リレーションを開いたときに
+// InitRelation で TableStatus を受け取り、走査した回数や読んだ行の数をそれに数える。
+// track_counts が off の場合は nil を受け取り、nil の TableStatus に数えても何もしない。
+//
+// インデックスも同じ形の統計を持つ。インデックスの NumScans はインデックスを走査した回数、
+// TuplesReturned はインデックスから読んだ項目の数、TuplesFetched はそれを使って
+// テーブルから読んだ行の数である。
+//
+// リレーションのカタログ (pg_class) とテーブルのアクセスメソッドはまだないため、
+// 統計を数える側はまだない。
+
+// Relation は統計を数えるリレーション (RelationData のうち統計に使う部分相当)
+type Relation struct {
+	Dboid catalog.Oid
+	Relid catalog.Oid
+	// Nspname と Relname はビューに表示するスキーマ名とリレーション名
+	Nspname string
+	Relname string
+	// Indrelid はインデックスのテーブルの OID (pg_index.indrelid 相当)。テーブルでは InvalidOid
+	Indrelid catalog.Oid
+}
+
+func (r *Relation) key() Key { return Key{r.Dboid, r.Relid} }
+
+// TableCounts はバックエンドがまだ共有の統計に加えていない回数 (PgStat_TableCounts 相当)
+type TableCounts struct {
+	NumScans       int64
+	TuplesReturned int64
+	TuplesFetched  int64
+
+	TuplesInserted       int64
+	TuplesUpdated        int64
+	TuplesDeleted        int64
+	TuplesHotUpdated     int64
+	TuplesNewpageUpdated int64
+
+	DeltaLiveTuples int64
+	DeltaDeadTuples int64
+	// ChangedTuples は最後の ANALYZE から変更した行の数に加える数
+	ChangedTuples int64
+
+	BlocksFetched int64
+	BlocksHit     int64
+}
+
+// tableXactStatus は実行中のトランザクションで挿入、更新、削除した行の数 (PgStat_TableXactStatus 相当)。
+// サブトランザクションはまだないため、トランザクションごとに1つだけ持つ。
+type tableXactStatus struct {
+	tuplesInserted int64
+	tuplesUpdated  int64
+	tuplesDeleted  int64
+}
+
+// TableStatus はバックエンドが1つのリレーションについて数えている回数 (PgStat_TableStatus 相当)
+type TableStatus struct {
+	rel     Relation
+	counts  TableCounts
+	trans   *tableXactStatus
+	pending *Pending
+}
+
+// StatTabEntry は共有の統計の1つのリレーション分 (PgStat_StatTabEntry 相当)
+type StatTabEntry struct {
+	Relation
+
+	NumScans       int64
+	LastScan       time.Time
+	TuplesReturned int64
+	TuplesFetched  int64
+
+	TuplesInserted       int64
+	TuplesUpdated        int64
+	TuplesDeleted        int64
+	TuplesHotUpdated     int64
+	TuplesNewpageUpdated int64
+
+	LiveTuples      int64
+	DeadTuples      int64
+	ModSinceAnalyze int64
+	InsSinceVacuum  int64
+
+	BlocksFetched int64
+	BlocksHit     int64
+
+	LastVacuumTime      time.Time
+	VacuumCount         int64
+	LastAutovacuumTime  time.Time
+	AutovacuumCount     int64
+	LastAnalyzeTime     time.Time
+	AnalyzeCount        int64
+	LastAutoanalyzeTime time.Time
+	AutoanalyzeCount    int64
+}
+
+// InitRelation はリレーションの回数を数える TableStatus を返す (pgstat_init_relation と
+// pgstat_prep_relation_pending 相当)。trackCounts はセッションの track_counts の値で、
+// false なら nil を返す。
+func (p *Pending) InitRelation(rel Relation, trackCounts bool) *TableStatus {
+	if !trackCounts {
+		return nil
+	}
+	if ts, ok := p.relations[rel.key()]; ok {
+		return ts
+	}
+	ts := &TableStatus{rel: rel, pending: p}
+	p.relations[rel.key()] = ts
+	return ts
+}
+
+// CountScan はリレーションを走査したことを数える (pgstat_count_heap_scan, pgstat_count_index_scan 相当)
+func (ts *TableStatus) CountScan() {
+	if ts != nil {
+		ts.counts.NumScans++
+	}
+}
+
+// CountTuplesReturned は走査で返した行の数を数える (pgstat_count_heap_getnext, pgstat_count_index_tuples 相当)
+func (ts *TableStatus) CountTuplesReturned(n int64) {
+	if ts != nil {
+		ts.counts.TuplesReturned += n
+	}
+}
+
+// CountTuplesFetched はインデックスの項目から読んだ行の数を数える (pgstat_count_heap_fetch 相当)
+func (ts *TableStatus) CountTuplesFetched(n int64) {
+	if ts != nil {
+		ts.counts.TuplesFetched += n
+	}
+}
+
+// CountBufferRead はページを読もうとしたことを数える (pgstat_count_buffer_read 相当)
+func (ts *TableStatus) CountBufferRead() {
+	if ts != nil {
+		ts.counts.BlocksFetched++
+	}
+}
+
+// CountBufferHit は読もうとしたページが共有バッファにあったことを数える (pgstat_count_buffer_hit 相当)
+func (ts *TableStatus) CountBufferHit() {
+	if ts != nil {
+		ts.counts.BlocksHit++
+	}
+}
+
+// CountHeapInsert は n 行を挿入したことを数える (pgstat_count_heap_insert 相当)
+func (ts *TableStatus) CountHeapInsert(n int64) {
+	if ts != nil {
+		ts.xactStatus().tuplesInserted += n
+	}
+}
+
+// CountHeapUpdate は1行を更新したことを数える (pgstat_count_heap_update 相当)。hot は
+// インデックスを更新しなかったこと、newpage は新しい版を別のページに置いたことを表す。
+func (ts *TableStatus) CountHeapUpdate(hot, newpage bool) {
+	if ts == nil {
+		return
+	}
+	ts.xactStatus().tuplesUpdated++
+	// HOT の更新の回数はトランザクションがアボートしても取り消さない
+	if hot {
+		ts.counts.TuplesHotUpdated++
+	} else if newpage {
+		ts.counts.TuplesNewpageUpdated++
+	}
+}
+
+// CountHeapDelete は1行を削除したことを数える (pgstat_count_heap_delete 相当)
+func (ts *TableStatus) CountHeapDelete() {
+	if ts != nil {
+		ts.xactStatus().tuplesDeleted++
+	}
+}
+
+// xactStatus は実行中のトランザクションの回数を返す。初めて数えるときにバックエンドの
+// トランザクションの一覧に加える (add_tabstat_xact_level 相当)。
+func (ts *TableStatus) xactStatus() *tableXactStatus {
+	if ts.trans == nil {
+		ts.trans = &tableXactStatus{}
+		ts.pending.xact = append(ts.pending.xact, ts)
+	}
+	return ts.trans
+}
+
+// atEOXact はトランザクションの回数をバックエンドの回数に移す (AtEOXact_PgStat_Relations 相当)
+func (ts *TableStatus) atEOXact(isCommit bool) {
+	trans := ts.trans
+	ts.trans = nil
+	// 挿入、更新、削除した回数はアボートしても数える
+	ts.counts.TuplesInserted += trans.tuplesInserted
+	ts.counts.TuplesUpdated += trans.tuplesUpdated
+	ts.counts.TuplesDeleted += trans.tuplesDeleted
+	if isCommit {
+		ts.counts.DeltaLiveTuples += trans.tuplesInserted - trans.tuplesDeleted
+		ts.counts.DeltaDeadTuples += trans.tuplesUpdated + trans.tuplesDeleted
+		ts.counts.ChangedTuples += trans.tuplesInserted + trans.tuplesUpdated + trans.tuplesDeleted
+	} else {
+		// アボートしたトランザクションが挿入した行と更新後の行は不要な行になる
+		ts.counts.DeltaDeadTuples += trans.tuplesInserted + trans.tuplesUpdated
+	}
+}
+
+// flushRelation はバックエンドの回数を共有の統計に加える (pgstat_relation_flush_cb 相当)。
+// shared のロックを持って呼ぶ。
+func flushRelation(ts *TableStatus, now time.Time) {
+	if ts.counts == (TableCounts{}) {
+		return
+	}
+	entry := sharedRelation(ts.rel)
+	c := &ts.counts
+	entry.NumScans += c.NumScans
+	if c.NumScans > 0 {
+		entry.LastScan = now
+	}
+	entry.TuplesReturned += c.TuplesReturned
+	entry.TuplesFetched += c.TuplesFetched
+	entry.TuplesInserted += c.TuplesInserted
+	entry.TuplesUpdated += c.TuplesUpdated
+	entry.TuplesDeleted += c.TuplesDeleted
+	entry.TuplesHotUpdated += c.TuplesHotUpdated
+	entry.TuplesNewpageUpdated += c.TuplesNewpageUpdated
+	entry.LiveTuples = max(entry.LiveTuples+c.DeltaLiveTuples, 0)
+	entry.DeadTuples = max(entry.DeadTuples+c.DeltaDeadTuples, 0)
+	entry.ModSinceAnalyze += c.ChangedTuples
+	entry.InsSinceVacuum += c.TuplesInserted
+	entry.BlocksFetched += c.BlocksFetched
+	entry.BlocksHit += c.BlocksHit
+}
+
+// sharedRelation はリレーションの共有の統計を返す。なければ作る (pgstat_get_entry_ref 相当)。
+// shared のロックを持って呼ぶ。
+func sharedRelation(rel Relation) *StatTabEntry {
+	entry, ok := shared.relations[rel.key()]
+	if !ok {
+		entry = &StatTabEntry{Relation: rel}
+		shared.relations[rel.key()] = entry
+	}
+	return entry
+}
+
+// ReportVacuum は VACUUM を終えたリレーションの行の数を記録する (pgstat_report_vacuum 相当)。
+// autovacuum は autovacuum のワーカーが実行したことを表す。
+func ReportVacuum(rel Relation, autovacuum bool, liveTuples, deadTuples int64) {
+	now := time.Now()
+	shared.Lock()
+	defer shared.Unlock()
+	entry := sharedRelation(rel)
+	entry.LiveTuples = liveTuples
+	entry.DeadTuples = deadTuples
+	// VACUUM は挿入された行を全て見たため、挿入による autovacuum の閾値を数え直す
+	entry.InsSinceVacuum = 0
+	if autovacuum {
+		entry.LastAutovacuumTime = now
+		entry.AutovacuumCount++
+	} else {
+		entry.LastVacuumTime = now
+		entry.VacuumCount++
+	}
+}
+
+// ReportAnalyze は ANALYZE を終えたリレーションの行の数を記録する (pgstat_report_analyze 相当)
+func ReportAnalyze(rel Relation, autovacuum bool, liveTuples, deadTuples int64) {
+	now := time.Now()
+	shared.Lock()
+	defer shared.Unlock()
+	entry := sharedRelation(rel)
+	entry.LiveTuples = liveTuples
+	entry.DeadTuples = deadTuples
+	entry.ModSinceAnalyze = 0
+	if autovacuum {
+		entry.LastAutoanalyzeTime = now
+		entry.AutoanalyzeCount++
+	} else {
+		entry.LastAnalyzeTime = now
+		entry.AnalyzeCount++
+	}
+}
+
+// DropRelation は削除したリレーションの統計を捨てる (pgstat_drop_relation 相当)
+func DropRelation(key Key) {
+	shared.Lock()
+	defer shared.Unlock()
+	delete(shared.relations, key)
+}
+
+// FetchStatTabEntries は dboid のデータベースと共有のカタログのリレーションの統計の写しを、
+// OID の順に返す (pgstat_fetch_stat_tabentry 相当)
+func FetchStatTabEntries(dboid catalog.Oid) []StatTabEntry {
+	shared.Lock()
+	entries := make([]StatTabEntry, 0, len(shared.relations))
+	for key, entry := range shared.relations {
+		if key.Dboid == dboid || key.Dboid == catalog.InvalidOid {
+			entries = append(entries, *entry)
+		}
+	}
+	shared.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Relid < entries[j].Relid })
+	return entries
+}
@@ -0,0 +1,291 @@
+package sim
+
+import (
+	"container/heap"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------
+// 決定的シミュレーション
+// ----------------------------------------------------------------
+// 時刻、乱数、I/O の完了の順序を抽象化し、postmaster やバックグラウンドプロセスの
+// スケジューリングを決定的に再現できるようにする。通常は実時間の環境 (realEnv) を使い、
+// 検証のときだけ Set でシミュレーションの環境 (Sim) に差し替える。
+//
+// シミュレーションでは、Go で起動したタスクを一度に1つずつ実行する。タスクが Sleep や IO で
+// 待つと制御がスケジューラに戻り、スケジューラは仮想時刻の最も早いタスクを再開する。
+// 同じ時刻のタスクは登録された順に再開し、乱数は種から決まるため、同じ種であれば
+// 実行の順序は毎回同じになる。待ち時間は仮想的なもので、実際には待たない。
+//
+// タスクがこのパッケージ以外の方法 (チャネル、ロック、ネットワーク) で待つと、
+// スケジューラも止まってしまう。シミュレーションの対象とする処理は、待つときには
+// 必ず Sleep か IO を使う。起こされるのを期限付きで待つ場合は、チャネルを select で待つ代わりに
+// Wait を使う。シミュレーションの Wait は仮想時刻で PollInterval ごとにチャネルを調べる。
+
+// Env は時刻、乱数、タスクの起動を提供する環境
+type Env interface {
+	// Now は現在時刻を返す
+	Now() time.Time
+	// Sleep は d の間待つ
+	Sleep(d time.Duration)
+	// Go は fn を新しいタスクとして起動する
+	Go(fn func())
+	// Int64N は [0, n) の乱数を返す
+	Int64N(n int64) int64
+	// IO は I/O の操作 fn を実行する。シミュレーションでは完了までの遅延を乱数で決める
+	IO(fn func() error) error
+	// Wait は chans のどれかから受信するか、timeout が経つまで待つ
+	Wait(timeout time.Duration, chans ...<-chan struct{}) int
+}
+
+var (
+	envMu sync.RWMutex
+	env   Env = realEnv{}
+)
+
+// Set は環境を差し替え、元に戻す関数を返す
+func Set(e Env) (restore func()) {
+	envMu.Lock()
+	defer envMu.Unlock()
+	prev := env
+	env = e
+	return func() {
+		envMu.Lock()
+		defer envMu.Unlock()
+		env = prev
+	}
+}
+
+func current() Env {
+	envMu.RLock()
+	defer envMu.RUnlock()
+	return env
+}
+
+// Now は現在の環境の時刻を返す
+func Now() time.Time { return current().Now() }
+
+// Since は t からの経過時間を返す
+func Since(t time.Time) time.Duration { return Now().Sub(t) }
+
+// Sleep は現在の環境で d の間待つ
+func Sleep(d time.Duration) { current().Sleep(d) }
+
+// Go は現在の環境で fn を新しいタスクとして起動する
+func Go(fn func()) { current().Go(fn) }
+
+// Int64N は現在の環境の乱数で [0, n) の値を返す
+func Int64N(n int64) int64 { return current().Int64N(n) }
+
+// IO は現在の環境で I/O の操作を実行する
+func IO(fn func() error) error { return current().IO(fn) }
+
+// Wait は現在の環境で、chans のどれかから受信するか timeout が経つまで待つ (WaitLatch 相当)。
+// 受信したチャネルの添字を返し、timeout が経った場合は -1 を返す。timeout が負なら期限なく待つ。
+// 閉じたチャネルと nil のチャネルは select と同じく扱う。
+func Wait(timeout time.Duration, chans ...<-chan struct{}) int {
+	return current().Wait(timeout, chans...)
+}
+
+// ----------------------------------------------------------------
+// 実時間の環境
+// ----------------------------------------------------------------
+
+type realEnv struct{}
+
+func (realEnv) Now() time.Time           { return time.Now() }
+func (realEnv) Sleep(d time.Duration)    { time.Sleep(d) }
+func (realEnv) Go(fn func())             { go fn() }
+func (realEnv) Int64N(n int64) int64     { return rand.Int64N(n) }
+func (realEnv) IO(fn func() error) error { return fn() }
+
+func (realEnv) Wait(timeout time.Duration, chans ...<-chan struct{}) int {
+	cases := make([]reflect.SelectCase, len(chans), len(chans)+1)
+	for i, ch := range chans {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
+	}
+	if timeout >= 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)})
+	}
+	i, _, _ := reflect.Select(cases)
+	if i == len(chans) {
+		return -1
+	}
+	return i
+}
+
+// ----------------------------------------------------------------
+// シミュレーションの環境
+// ----------------------------------------------------------------
+
+// Sim は仮想時刻で動くシミュレーションの環境
+type Sim struct {
+	// MaxIOLatency は IO の遅延の上限。0 なら遅延なしで完了する
+	MaxIOLatency time.Duration
+	// PollInterval は Wait がチャネルを調べる間隔。0 なら defaultPollInterval を使う
+	PollInterval time.Duration
+
+	now   time.Time
+	rng   *rand.Rand
+	queue eventQueue
+	seq   uint64
+
+	// yield はタスクが待ちに入るか終了したことをスケジューラに知らせる
+	yield   chan struct{}
+	running bool
+}
+
+// event は指定した時刻に再開するタスク
+type event struct {
+	at   time.Time
+	seq  uint64
+	wake chan struct{}
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x any)   { *q = append(*q, x.(*event)) }
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// simEpoch は仮想時刻の起点。実行のたびに同じ時刻から始める
+var simEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// defaultPollInterval は PollInterval を指定しない場合の Wait がチャネルを調べる間隔
+const defaultPollInterval = 10 * time.Millisecond
+
+// NewSim は seed を種とするシミュレーションの環境を作る
+func NewSim(seed uint64) *Sim {
+	return &Sim{
+		now:   simEpoch,
+		rng:   rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+		yield: make(chan struct{}),
+	}
+}
+
+// schedule は時刻 at に再開するタスクを登録し、再開の合図を待つチャネルを返す
+func (s *Sim) schedule(at time.Time) chan struct{} {
+	s.seq++
+	e := &event{at: at, seq: s.seq, wake: make(chan struct{})}
+	heap.Push(&s.queue, e)
+	return e.wake
+}
+
+// Now は仮想時刻を返す
+func (s *Sim) Now() time.Time { return s.now }
+
+// Sleep は仮想時刻で d の間待つ。タスクの中から呼ぶ。
+func (s *Sim) Sleep(d time.Duration) {
+	if !s.running {
+		panic("sim: Sleep called outside a simulated task")
+	}
+	if d < 0 {
+		d = 0
+	}
+	wake := s.schedule(s.now.Add(d))
+	s.yield <- struct{}{}
+	<-wake
+}
+
+// Go はタスクを登録する。タスクは現在の仮想時刻で、既に登録されたタスクの後に実行する。
+func (s *Sim) Go(fn func()) {
+	wake := s.schedule(s.now)
+	go func() {
+		<-wake
+		defer func() { s.yield <- struct{}{} }()
+		fn()
+	}()
+}
+
+// Int64N は種から決まる乱数を返す
+func (s *Sim) Int64N(n int64) int64 { return s.rng.Int64N(n) }
+
+// IO は乱数で決めた遅延の後に fn を実行する。複数のタスクの I/O の完了の順序は種によって変わる。
+func (s *Sim) IO(fn func() error) error {
+	if s.MaxIOLatency > 0 {
+		s.Sleep(time.Duration(s.rng.Int64N(int64(s.MaxIOLatency) + 1)))
+	}
+	return fn()
+}
+
+// Wait は仮想時刻で PollInterval ごとに chans を先頭から調べ、受信できたチャネルの添字を返す。
+// 他のタスクは調べる間にしか動かないため、起こされる時刻も種によって決まる。タスクの中から呼ぶ。
+func (s *Sim) Wait(timeout time.Duration, chans ...<-chan struct{}) int {
+	deadline := s.now.Add(timeout)
+	for {
+		for i, ch := range chans {
+			select {
+			case <-ch:
+				return i
+			default:
+			}
+		}
+		if timeout >= 0 && !s.now.Before(deadline) {
+			return -1
+		}
+		d := s.PollInterval
+		if d <= 0 {
+			d = defaultPollInterval
+		}
+		if timeout >= 0 {
+			d = min(d, deadline.Sub(s.now))
+		}
+		s.Sleep(d)
+	}
+}
+
+// Run は全てのタスクが終了するまで実行する
+func (s *Sim) Run() {
+	s.RunUntil(time.Time{})
+}
+
+// RunFor は仮想時刻が d 進むまで、または全てのタスクが終了するまで実行する
+func (s *Sim) RunFor(d time.Duration) {
+	s.RunUntil(s.now.Add(d))
+}
+
+// RunUntil は仮想時刻が until を過ぎるまで実行する。until がゼロ値の場合は
+// 全てのタスクが終了するまで実行する。タスクの外 (テストの本体) から呼ぶ。
+func (s *Sim) RunUntil(until time.Time) {
+	s.running = true
+	defer func() { s.running = false }()
+	for s.queue.Len() > 0 {
+		next := s.queue[0]
+		if !until.IsZero() && next.at.After(until) {
+			s.now = until
+			return
+		}
+		heap.Pop(&s.queue)
+		if next.at.After(s.now) {
+			s.now = next.at
+		}
+		// タスクを1つ再開し、待ちに入るか終了するまで待つ
+		close(next.wake)
+		<-s.yield
+	}
+	if !until.IsZero() && until.After(s.now) {
+		s.now = until
+	}
+}
+
+// Pending はまだ終了していないタスクの数を返す
+func (s *Sim) Pending() int {
+	return s.queue.Len()
+}
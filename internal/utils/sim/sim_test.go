@@ -0,0 +1,97 @@
+package sim
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// interleaving は seed のシミュレーションで、I/O を繰り返す3つのタスクが進んだ順序を返す
+func interleaving(seed uint64) []string {
+	s := NewSim(seed)
+	s.MaxIOLatency = 10 * time.Millisecond
+	restore := Set(s)
+	defer restore()
+
+	var trace []string
+	for task := range 3 {
+		Go(func() {
+			for step := range 5 {
+				_ = IO(func() error {
+					trace = append(trace, fmt.Sprintf("task %d step %d at %v", task, step, Since(simEpoch)))
+					return nil
+				})
+			}
+		})
+	}
+	s.Run()
+	return trace
+}
+
+func TestSameSeedSameInterleaving(t *testing.T) {
+	for seed := range uint64(8) {
+		first := interleaving(seed)
+		if len(first) != 15 {
+			t.Fatalf("seed %d: got %d steps, want 15", seed, len(first))
+		}
+		for range 3 {
+			if again := interleaving(seed); !slices.Equal(first, again) {
+				t.Fatalf("seed %d: interleaving changed between runs:\n%q\n%q", seed, first, again)
+			}
+		}
+	}
+}
+
+func TestSeedChangesInterleaving(t *testing.T) {
+	first := interleaving(0)
+	for seed := uint64(1); seed < 8; seed++ {
+		if !slices.Equal(first, interleaving(seed)) {
+			return
+		}
+	}
+	t.Fatal("seeds 0 to 7 all produced the same interleaving")
+}
+
+func TestSimWait(t *testing.T) {
+	s := NewSim(1)
+	restore := Set(s)
+	defer restore()
+
+	wakeup := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	var got []string
+	Go(func() {
+		// 起こされるまで待ち、次は期限まで待ち、最後は閉じたチャネルで終わる
+		for {
+			i := Wait(time.Second, stop, wakeup)
+			got = append(got, fmt.Sprintf("%d at %v", i, Since(simEpoch)))
+			if i == 0 {
+				return
+			}
+		}
+	})
+	Go(func() {
+		Sleep(300 * time.Millisecond)
+		wakeup <- struct{}{}
+		Sleep(2 * time.Second)
+		close(stop)
+	})
+	s.Run()
+
+	want := []string{"1 at 300ms", "-1 at 1.3s", "0 at 2.3s"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestRealWait(t *testing.T) {
+	closed := make(chan struct{})
+	close(closed)
+	if i := Wait(time.Hour, nil, closed); i != 1 {
+		t.Fatalf("Wait on a closed channel = %d, want 1", i)
+	}
+	if i := Wait(time.Millisecond, make(chan struct{})); i != -1 {
+		t.Fatalf("Wait with nothing to receive = %d, want -1", i)
+	}
+}
@@ -0,0 +1,318 @@
+package snapmgr
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/access/transam"
+	"github.com/Tsubasa-2005/go-postgres/internal/guc"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/adt"
+	"github.com/Tsubasa-2005/go-postgres/internal/utils/sim"
+)
+
+// ----------------------------------------------------------------
+// 古いスナップショットの制限 (utils/time/snapmgr.c の old_snapshot_threshold の処理相当)
+// ----------------------------------------------------------------
+// old_snapshot_threshold を設定すると、それより前に取ったスナップショットからまだ見える不要な行も
+// VACUUM が削除できるようになる。削除した後に、そのスナップショットで、スナップショットより
+// 後に変更されたページを読むと "snapshot too old" のエラーにする。長く続く読み取りの
+// トランザクションによる肥大化を、そのトランザクションのエラーと引き換えに抑える。
+//
+//  1. スナップショットを取るたびに、その xmin を1分ごとの枠に記録する (MaintainOldSnapshotTimeMapping)
+//  2. VACUUM は old_snapshot_threshold 分前の枠の xmin を、行を削除する境界にできる
+//     (TransactionIdLimitedForOldSnapshots)。境界を使ったら、その時刻を記録する
+//  3. 記録した時刻より前に取ったスナップショットで、スナップショットより新しい LSN のページを
+//     読むとエラーにする (TestForOldSnapshot)
+//
+// スナップショットは procarray.GetSnapshotData で取る。WAL の挿入位置はまだないため、LSN は
+// 呼び出し側が渡す。old_snapshot_threshold が 0 の場合は試験用で、分ごとの記録をせず、
+// 5秒前より前に取ったスナップショットを古いとみなす。
+
+// ErrSnapshotTooOld は古いスナップショットから、削除されたかもしれない行を読もうとしたことを表す
+var ErrSnapshotTooOld = errors.New("snapshot too old")
+
+// oldSnapshotPaddingEntries は old_snapshot_threshold の分に加えて記録する枠の数
+// (OLD_SNAPSHOT_PADDING_ENTRIES 相当)
+const oldSnapshotPaddingEntries = 10
+
+// Snapshot は MVCC のスナップショット (SnapshotData のうち MVCC のスナップショットが使う部分相当)。
+// スナップショットを取った時点でコミットしていたトランザクションの変更だけが見える。
+type Snapshot struct {
+	// Xmin はスナップショットを取ったときに実行中だった最も古いトランザクション。これより前の
+	// トランザクションは全て終わっている
+	Xmin adt.TransactionId
+	// Xmax はスナップショットを取ったときに次に割り当てるトランザクション ID。これ以降の
+	// トランザクションは全て実行中として扱う
+	Xmax adt.TransactionId
+	// Xip はスナップショットを取ったときに実行中だった、Xmin から Xmax の間のトランザクション
+	Xip []adt.TransactionId
+	// Curcid はスナップショットを使うコマンド。同じトランザクションのこれより前のコマンドの
+	// 変更だけが見える
+	Curcid adt.CommandId
+	// LSN はスナップショットを取ったときの WAL の挿入位置 (lsn 相当)
+	LSN uint64
+	// WhenTaken はスナップショットを取った時刻 (whenTaken 相当)
+	WhenTaken time.Time
+}
+
+// oldSnapshotControl は全てのバックエンドで共有する状態 (OldSnapshotControlData 相当)
+var oldSnapshotControl struct {
+	// latestMu は latestXmin と nextMapUpdate を守る (mutex_latest_xmin 相当)
+	latestMu sync.Mutex
+	// latestXmin はいずれかのバックエンドが取った最も新しいスナップショットの xmin
+	latestXmin adt.TransactionId
+	// nextMapUpdate は次に xidByMinute を更新する分
+	nextMapUpdate time.Time
+
+	// currentMu は currentTimestamp を守る (mutex_current 相当)
+	currentMu        sync.Mutex
+	currentTimestamp time.Time
+
+	// thresholdMu は thresholdTimestamp と thresholdXid を守る (mutex_threshold 相当)
+	thresholdMu sync.Mutex
+	// thresholdTimestamp と thresholdXid は VACUUM が最後に使った境界
+	thresholdTimestamp time.Time
+	thresholdXid       adt.TransactionId
+
+	// mapMu は分ごとの xmin の記録を守る (OldSnapshotTimeMapLock 相当)。xidByMinute は
+	// headOffset から countUsed 個の枠を使う環状の配列で、headOffset の枠が headTimestamp の分を表す
+	mapMu         sync.RWMutex
+	headOffset    int
+	headTimestamp time.Time
+	countUsed     int
+	xidByMinute   []adt.TransactionId
+}
+
+// OldSnapshotThresholdActive は old_snapshot_threshold が有効かどうかを返す
+// (OldSnapshotThresholdActive 相当)
+func OldSnapshotThresholdActive() bool {
+	return guc.OldSnapshotThreshold.Get() >= 0
+}
+
+// timeMapEntries は分ごとの記録の枠の数 (OLD_SNAPSHOT_TIME_MAP_ENTRIES 相当)
+func timeMapEntries() int {
+	return guc.OldSnapshotThreshold.Get() + oldSnapshotPaddingEntries
+}
+
+// NewSnapshot は xmin と WAL の挿入位置 lsn のスナップショットを作る (GetSnapshotData の
+// old_snapshot_threshold の処理相当)。old_snapshot_threshold が有効なら、取った時刻を記録する。
+// Xmax と Xip は呼び出し側が書く。
+func NewSnapshot(xmin adt.TransactionId, lsn uint64) *Snapshot {
+	s := &Snapshot{Xmin: xmin, Xmax: xmin}
+	if OldSnapshotThresholdActive() {
+		s.LSN = lsn
+		s.WhenTaken = GetSnapshotCurrentTimestamp()
+		MaintainOldSnapshotTimeMapping(s.WhenTaken, xmin)
+	}
+	return s
+}
+
+// XidInMVCCSnapshot は xid のトランザクションが snapshot から実行中に見えるかを返す
+// (XidInMVCCSnapshot 相当)。実行中に見えるトランザクションの変更は見えない。スナップショットを
+// 取った後に終わったトランザクションも実行中に見えるため、コミットしたかを確かめる前に呼ぶ。
+// サブトランザクションはまだないため、トップレベルのトランザクションだけを比べる。
+func XidInMVCCSnapshot(xid adt.TransactionId, snapshot *Snapshot) bool {
+	if transam.TransactionIdPrecedes(xid, snapshot.Xmin) {
+		return false
+	}
+	if transam.TransactionIdFollowsOrEquals(xid, snapshot.Xmax) {
+		return true
+	}
+	return slices.Contains(snapshot.Xip, xid)
+}
+
+// GetSnapshotCurrentTimestamp は現在時刻を返す (GetSnapshotCurrentTimestamp 相当)。
+// 時刻が戻っても、前に返した時刻より前の時刻は返さない。
+func GetSnapshotCurrentTimestamp() time.Time {
+	now := sim.Now()
+	oldSnapshotControl.currentMu.Lock()
+	defer oldSnapshotControl.currentMu.Unlock()
+	if now.After(oldSnapshotControl.currentTimestamp) {
+		oldSnapshotControl.currentTimestamp = now
+	}
+	return oldSnapshotControl.currentTimestamp
+}
+
+// GetOldSnapshotThresholdTimestamp は VACUUM が最後に使った境界の時刻を返す
+// (GetOldSnapshotThresholdTimestamp 相当)
+func GetOldSnapshotThresholdTimestamp() time.Time {
+	oldSnapshotControl.thresholdMu.Lock()
+	defer oldSnapshotControl.thresholdMu.Unlock()
+	return oldSnapshotControl.thresholdTimestamp
+}
+
+// SetOldSnapshotThresholdTimestamp は VACUUM が使った境界を記録する
+// (SetOldSnapshotThresholdTimestamp 相当)。境界の時刻は戻らない。
+func SetOldSnapshotThresholdTimestamp(ts time.Time, xlimit adt.TransactionId) {
+	oldSnapshotControl.thresholdMu.Lock()
+	defer oldSnapshotControl.thresholdMu.Unlock()
+	if ts.Before(oldSnapshotControl.thresholdTimestamp) {
+		return
+	}
+	oldSnapshotControl.thresholdTimestamp = ts
+	oldSnapshotControl.thresholdXid = xlimit
+}
+
+// TestForOldSnapshot は、snapshot で LSN が pageLSN のページを読んでよいかを確かめる
+// (TestForOldSnapshot、TestForOldSnapshot_impl 相当)。ページがスナップショットより後に変更され、
+// スナップショットが VACUUM の使った境界より前に取ったものであれば ErrSnapshotTooOld を返す。
+// allowsEarlyPruning はリレーションの行を早く削除してよいかどうかで、システムカタログや
+// WAL を書かないリレーションでは false にする (RelationAllowsEarlyPruning 相当)。
+func TestForOldSnapshot(snapshot *Snapshot, allowsEarlyPruning bool, pageLSN uint64) error {
+	if !OldSnapshotThresholdActive() || snapshot == nil || snapshot.LSN == 0 || pageLSN <= snapshot.LSN {
+		return nil
+	}
+	if allowsEarlyPruning && snapshot.WhenTaken.Before(GetOldSnapshotThresholdTimestamp()) {
+		return ErrSnapshotTooOld
+	}
+	return nil
+}
+
+// MaintainOldSnapshotTimeMapping は分ごとの xmin の記録を更新する (MaintainOldSnapshotTimeMapping 相当)
+func MaintainOldSnapshotTimeMapping(whenTaken time.Time, xmin adt.TransactionId) {
+	ts := whenTaken.Truncate(time.Minute)
+
+	c := &oldSnapshotControl
+	c.latestMu.Lock()
+	mapUpdateRequired := false
+	if ts.After(c.nextMapUpdate) {
+		c.nextMapUpdate = ts
+		mapUpdateRequired = true
+	}
+	if transam.TransactionIdFollows(xmin, c.latestXmin) {
+		c.latestXmin = xmin
+	}
+	c.latestMu.Unlock()
+
+	// 0 は試験用で、最も新しい xmin だけを使う
+	if !mapUpdateRequired || guc.OldSnapshotThreshold.Get() == 0 || !transam.TransactionIdIsNormal(xmin) {
+		return
+	}
+
+	c.mapMu.Lock()
+	defer c.mapMu.Unlock()
+	entries := timeMapEntries()
+	if c.xidByMinute == nil {
+		c.xidByMinute = make([]adt.TransactionId, entries)
+	}
+
+	switch {
+	case c.countUsed == 0:
+		// 最初の枠
+		c.headOffset = 0
+		c.headTimestamp = ts
+		c.countUsed = 1
+		c.xidByMinute[0] = xmin
+	case ts.Before(c.headTimestamp):
+		// 記録している範囲より古い時刻は無視する
+	case !ts.After(c.headTimestamp.Add(time.Duration(c.countUsed-1) * time.Minute)):
+		// 既にある枠の xmin を進める
+		bucket := (c.headOffset + int(ts.Sub(c.headTimestamp)/time.Minute)) % entries
+		if transam.TransactionIdPrecedes(c.xidByMinute[bucket], xmin) {
+			c.xidByMinute[bucket] = xmin
+		}
+	default:
+		// 新しい枠が必要になる。最も新しい枠が ts を表すまで進める
+		advance := int(ts.Sub(c.headTimestamp)/time.Minute) - (c.countUsed - 1)
+		if advance >= entries {
+			// 全ての枠が古くなったため、記録し直す
+			c.headOffset = 0
+			c.countUsed = 1
+			c.xidByMinute[0] = xmin
+			c.headTimestamp = ts
+			return
+		}
+		for range advance {
+			if c.countUsed == entries {
+				// 枠が埋まっているため、最も古い枠を使い回す
+				oldHead := c.headOffset
+				c.headOffset = (oldHead + 1) % entries
+				c.xidByMinute[oldHead] = xmin
+				c.headTimestamp = c.headTimestamp.Add(time.Minute)
+			} else {
+				newTail := (c.headOffset + c.countUsed) % entries
+				c.countUsed++
+				c.xidByMinute[newTail] = xmin
+			}
+		}
+	}
+}
+
+// getOldSnapshotFromTimeMapping は ts の分の枠の xmin を返す (GetOldSnapshotFromTimeMapping 相当)
+func getOldSnapshotFromTimeMapping(ts time.Time) (adt.TransactionId, bool) {
+	c := &oldSnapshotControl
+	c.mapMu.RLock()
+	defer c.mapMu.RUnlock()
+	if c.countUsed == 0 || ts.Before(c.headTimestamp) {
+		return transam.InvalidTransactionId, false
+	}
+	offset := min(int(ts.Sub(c.headTimestamp)/time.Minute), c.countUsed-1)
+	return c.xidByMinute[(c.headOffset+offset)%len(c.xidByMinute)], true
+}
+
+// TransactionIdLimitedForOldSnapshots は、old_snapshot_threshold より古いスナップショットを
+// 無視した場合の、行を削除する境界を返す (TransactionIdLimitedForOldSnapshots 相当)。
+// recentXmin は全てのスナップショットを考慮した境界、myXmin は呼び出したバックエンドの
+// スナップショットの xmin。recentXmin より進んだ境界が得られた場合に ok に true を返し、
+// 呼び出し側は境界を使うなら SetOldSnapshotThresholdTimestamp で記録する。
+func TransactionIdLimitedForOldSnapshots(recentXmin, myXmin adt.TransactionId, allowsEarlyPruning bool) (limitXid adt.TransactionId, limitTs time.Time, ok bool) {
+	if !OldSnapshotThresholdActive() || !allowsEarlyPruning {
+		return transam.InvalidTransactionId, time.Time{}, false
+	}
+	ts := GetSnapshotCurrentTimestamp()
+	xlimit := recentXmin
+
+	c := &oldSnapshotControl
+	c.latestMu.Lock()
+	latestXmin, nextMapUpdate := c.latestXmin, c.nextMapUpdate
+	c.latestMu.Unlock()
+
+	if threshold := guc.OldSnapshotThreshold.Get(); threshold == 0 {
+		// 試験用。自分のスナップショットを古いとみなさないよう、5秒の猶予を置く
+		if transam.TransactionIdPrecedes(latestXmin, myXmin) && transam.TransactionIdFollows(latestXmin, xlimit) {
+			xlimit = latestXmin
+		}
+		ts = ts.Add(-5 * time.Second)
+	} else {
+		ts = ts.Truncate(time.Minute).Add(-time.Duration(threshold) * time.Minute)
+
+		c.thresholdMu.Lock()
+		thresholdTimestamp, thresholdXid := c.thresholdTimestamp, c.thresholdXid
+		c.thresholdMu.Unlock()
+
+		switch {
+		case ts.Equal(thresholdTimestamp):
+			// 前回と同じ分であれば、前回の境界を使う
+			xlimit = thresholdXid
+		case ts.Equal(nextMapUpdate):
+			xlimit = latestXmin
+		default:
+			if xid, found := getOldSnapshotFromTimeMapping(ts); found {
+				xlimit = xid
+			}
+		}
+
+		// 動いているトランザクションの行を削除しないよう、最も新しい xmin を超えないようにする
+		if transam.TransactionIdIsNormal(latestXmin) && transam.TransactionIdPrecedes(latestXmin, xlimit) {
+			xlimit = latestXmin
+		}
+	}
+
+	if transam.TransactionIdIsValid(xlimit) && transam.TransactionIdFollowsOrEquals(xlimit, recentXmin) {
+		return xlimit, ts, true
+	}
+	return transam.InvalidTransactionId, time.Time{}, false
+}
+
+// VacuumOldestXmin は VACUUM が行を削除する境界を返す (vacuum_get_cutoffs の
+// old_snapshot_threshold の処理相当)。old_snapshot_threshold により境界を進めた場合は、
+// それを記録して、古いスナップショットでの読み取りをエラーにする。
+func VacuumOldestXmin(oldestXmin, myXmin adt.TransactionId, allowsEarlyPruning bool) adt.TransactionId {
+	if limitXmin, limitTs, ok := TransactionIdLimitedForOldSnapshots(oldestXmin, myXmin, allowsEarlyPruning); ok {
+		SetOldSnapshotThresholdTimestamp(limitTs, limitXmin)
+		return limitXmin
+	}
+	return oldestXmin
+}
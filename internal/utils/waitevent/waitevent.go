@@ -0,0 +1,343 @@
+package waitevent
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ----------------------------------------------------------------
+// 待機イベント (utils/activity/wait_event.c, wait_event_names.txt 相当)
+// ----------------------------------------------------------------
+// バックエンドが処理を止めて何かを待つ間、何を待っているかを Slot に記録する。
+// pg_stat_activity は他のバックエンドの Slot を読み、wait_event_type と wait_event に表示する。
+//
+// 待っているものは Info の32ビットの値で表す。上位8ビットが種類 (LWLock, Lock, IO など)、
+// 下位16ビットが種類の中のイベントである。C言語版と同じく、記録と読み出しはロックを取らずに
+// 1つの値を読み書きするだけにし、待ち始めと待ち終わりの負担を小さくする。
+
+// Info は待っているもの (wait_event_info 相当)。0 は待っていないことを表す。
+type Info uint32
+
+// 待機イベントの種類 (PG_WAIT_* 相当)
+const (
+	ClassLWLock    Info = 0x01000000
+	ClassLock      Info = 0x03000000
+	ClassBufferPin Info = 0x04000000
+	ClassActivity  Info = 0x05000000
+	ClassClient    Info = 0x06000000
+	ClassExtension Info = 0x07000000
+	ClassIPC       Info = 0x08000000
+	ClassTimeout   Info = 0x09000000
+	ClassIO        Info = 0x0A000000
+
+	classMask Info = 0xFF000000
+	eventMask Info = 0x0000FFFF
+)
+
+// 軽量ロックの待ち (LWLock の組み込みのロックとトランシェ相当)。イベントは lmgr.LWLock の
+// トランシェでもある。
+const (
+	LWLockAutoFile Info = ClassLWLock + iota
+	LWLockControlFile
+	LWLockCheckpoint
+	LWLockPgStatsData
+	LWLockLockManager
+	LWLockBufferMapping
+	LWLockBufferContent
+
+	// LWLockFirstUserDefined は LWLockNewTrancheId で割り当てるトランシェの最初の値
+	// (LWTRANCHE_FIRST_USER_DEFINED 相当)
+	LWLockFirstUserDefined
+)
+
+var lwlockNames = []string{
+	"AutoFile",
+	"ControlFile",
+	"Checkpoint",
+	"PgStatsData",
+	"LockManager",
+	"BufferMapping",
+	"BufferContent",
+}
+
+// lwlockTranches は RegisterLWLockTranche で名前を登録したトランシェ (LWLockTrancheNames 相当)
+var lwlockTranches struct {
+	sync.RWMutex
+	names map[Info]string
+}
+
+// RegisterLWLockTranche は LWLockFirstUserDefined 以降のトランシェに待機イベントの名前を付ける
+// (LWLockRegisterTranche 相当)。名前のないトランシェは extension と表示する。
+func RegisterLWLockTranche(tranche Info, name string) {
+	lwlockTranches.Lock()
+	defer lwlockTranches.Unlock()
+	if lwlockTranches.names == nil {
+		lwlockTranches.names = make(map[Info]string)
+	}
+	lwlockTranches.names[tranche] = name
+}
+
+// 重いロックの待ち (LockTagType 相当)。イベントはロックの対象の種類を表す。
+const (
+	LockRelation Info = ClassLock + iota
+	LockExtend
+	LockFrozenID
+	LockPage
+	LockTuple
+	LockTransactionID
+	LockVirtualXID
+	LockSpeculativeToken
+	LockObject
+	LockUserLock
+	LockAdvisory
+	LockApplyTransaction
+)
+
+var lockNames = []string{
+	"relation",
+	"extend",
+	"frozenid",
+	"page",
+	"tuple",
+	"transactionid",
+	"virtualxid",
+	"spectoken",
+	"object",
+	"userlock",
+	"advisory",
+	"applytransaction",
+}
+
+// バッファのピンの待ち (PG_WAIT_BUFFERPIN 相当)
+const (
+	BufferPin Info = ClassBufferPin + iota
+)
+
+var bufferPinNames = []string{
+	"BufferPin",
+}
+
+// バックグラウンドプロセスが仕事を待つ間 (WaitEventActivity 相当)
+const (
+	ActivityArchiverMain Info = ClassActivity + iota
+	ActivityAutovacuumMain
+	ActivityBgWriterHibernate
+	ActivityBgWriterMain
+	ActivityCheckpointerMain
+	ActivityLogicalLauncherMain
+	ActivitySysLoggerMain
+	ActivityWalReceiverMain
+	ActivityWalSenderMain
+	ActivityWalWriterMain
+)
+
+var activityNames = []string{
+	"ArchiverMain",
+	"AutovacuumMain",
+	"BgWriterHibernate",
+	"BgWriterMain",
+	"CheckpointerMain",
+	"LogicalLauncherMain",
+	"SysLoggerMain",
+	"WalReceiverMain",
+	"WalSenderMain",
+	"WalWriterMain",
+}
+
+// クライアントとの通信の待ち (WaitEventClient 相当)
+const (
+	ClientRead Info = ClassClient + iota
+	ClientWrite
+	ClientSSLOpenServer
+	ClientWalSenderWaitForWAL
+	ClientWalSenderWriteData
+)
+
+var clientNames = []string{
+	"ClientRead",
+	"ClientWrite",
+	"SSLOpenServer",
+	"WalSenderWaitForWal",
+	"WalSenderWriteData",
+}
+
+// 拡張機能の待ち (PG_WAIT_EXTENSION 相当)
+const (
+	Extension Info = ClassExtension + iota
+)
+
+var extensionNames = []string{
+	"Extension",
+}
+
+// 他のプロセスの処理の待ち (WaitEventIPC 相当)
+const (
+	IPCBgWorkerShutdown Info = ClassIPC + iota
+	IPCBgWorkerStartup
+	IPCBufferIO
+	IPCCheckpointDone
+	IPCCheckpointStart
+	IPCRecoveryPause
+)
+
+var ipcNames = []string{
+	"BgWorkerShutdown",
+	"BgWorkerStartup",
+	"BufferIO",
+	"CheckpointDone",
+	"CheckpointStart",
+	"RecoveryPause",
+}
+
+// 時間の経過の待ち (WaitEventTimeout 相当)
+const (
+	TimeoutBaseBackupThrottle Info = ClassTimeout + iota
+	TimeoutCheckpointWriteDelay
+	TimeoutPgSleep
+	TimeoutRecoveryApplyDelay
+	TimeoutVacuumDelay
+)
+
+var timeoutNames = []string{
+	"BaseBackupThrottle",
+	"CheckpointWriteDelay",
+	"PgSleep",
+	"RecoveryApplyDelay",
+	"VacuumDelay",
+}
+
+// ファイルの入出力の待ち (WaitEventIO 相当)
+const (
+	IOBufFileRead Info = ClassIO + iota
+	IOBufFileTruncate
+	IOBufFileWrite
+	IOControlFileRead
+	IOControlFileSync
+	IOControlFileSyncUpdate
+	IOControlFileWrite
+	IOControlFileWriteUpdate
+	IODataFileExtend
+	IODataFileFlush
+	IODataFileImmediateSync
+	IODataFilePrefetch
+	IODataFileRead
+	IODataFileSync
+	IODataFileTruncate
+	IODataFileWrite
+)
+
+var ioNames = []string{
+	"BufFileRead",
+	"BufFileTruncate",
+	"BufFileWrite",
+	"ControlFileRead",
+	"ControlFileSync",
+	"ControlFileSyncUpdate",
+	"ControlFileWrite",
+	"ControlFileWriteUpdate",
+	"DataFileExtend",
+	"DataFileFlush",
+	"DataFileImmediateSync",
+	"DataFilePrefetch",
+	"DataFileRead",
+	"DataFileSync",
+	"DataFileTruncate",
+	"DataFileWrite",
+}
+
+// classes は種類ごとの表示名とイベントの名前
+var classes = map[Info]struct {
+	name   string
+	events []string
+}{
+	ClassLWLock:    {"LWLock", lwlockNames},
+	ClassLock:      {"Lock", lockNames},
+	ClassBufferPin: {"BufferPin", bufferPinNames},
+	ClassActivity:  {"Activity", activityNames},
+	ClassClient:    {"Client", clientNames},
+	ClassExtension: {"Extension", extensionNames},
+	ClassIPC:       {"IPC", ipcNames},
+	ClassTimeout:   {"Timeout", timeoutNames},
+	ClassIO:        {"IO", ioNames},
+}
+
+// GetWaitEventType は待機イベントの種類の名前を返す (pgstat_get_wait_event_type 相当)。
+// 待っていなければ空文字列を返す。
+func GetWaitEventType(info Info) string {
+	if info == 0 {
+		return ""
+	}
+	if c, ok := classes[info&classMask]; ok {
+		return c.name
+	}
+	return "???"
+}
+
+// GetWaitEventIdentifier は待機イベントの名前を返す (pgstat_get_wait_event 相当)。
+// 待っていなければ空文字列を返す。
+func GetWaitEventIdentifier(info Info) string {
+	if info == 0 {
+		return ""
+	}
+	c, ok := classes[info&classMask]
+	if !ok {
+		return "unknown wait event"
+	}
+	if ev := int(info & eventMask); ev < len(c.events) {
+		return c.events[ev]
+	}
+	if info&classMask == ClassLWLock {
+		lwlockTranches.RLock()
+		defer lwlockTranches.RUnlock()
+		if name, ok := lwlockTranches.names[info]; ok {
+			return name
+		}
+		return "extension"
+	}
+	return "unknown wait event"
+}
+
+// ----------------------------------------------------------------
+// 待機イベントの記録
+// ----------------------------------------------------------------
+
+// Slot はプロセス1つ分の待機イベントの記録 (PGPROC の wait_event_info 相当)。
+// 記録するプロセスが Start と End を呼び、他のプロセスは Load で読む。
+// nil の Slot には何も記録しないため、記録する先のない呼び出し元は nil を渡せばよい。
+type Slot struct {
+	info atomic.Uint32
+}
+
+// Start は info を待ち始めたことを記録する (pgstat_report_wait_start 相当)。
+// 待ちを入れ子にはできず、前の記録を上書きする。
+func (s *Slot) Start(info Info) {
+	if s != nil {
+		s.info.Store(uint32(info))
+	}
+}
+
+// End は待ち終えたことを記録する (pgstat_report_wait_end 相当)
+func (s *Slot) End() {
+	if s != nil {
+		s.info.Store(0)
+	}
+}
+
+// Load は記録された待機イベントを返す。待っていなければ 0 を返す。
+func (s *Slot) Load() Info {
+	if s == nil {
+		return 0
+	}
+	return Info(s.info.Load())
+}
+
+// LockMutex は mu のロックを取る。ロックが他で取られていて待つ必要がある間だけ、info を
+// 待っていることを記録する (LWLockAcquire の LWLockReportWaitStart 相当)。
+func (s *Slot) LockMutex(mu *sync.Mutex, info Info) {
+	if mu.TryLock() {
+		return
+	}
+	s.Start(info)
+	mu.Lock()
+	s.End()
+}
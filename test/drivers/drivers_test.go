@@ -0,0 +1,86 @@
+package drivers
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Tsubasa-2005/go-postgres/internal/regress/cluster"
+)
+
+// ----------------------------------------------------------------
+// クライアントドライバーの適合性のテスト
+// ----------------------------------------------------------------
+// よく使われる Go言語のドライバー (lib/pq と pgx) でサーバーに接続し、ドライバーが使うプロトコルの
+// 流れをそのまま通す。単純問い合わせと拡張問い合わせ、名前付きの文、テキストとバイナリの形式の
+// パラメータと結果、パイプライン、トランザクション、エラーと NOTICE の項目、ParameterStatus、
+// CancelRequest を確かめる。
+//
+// テスト用のサーバーは cluster で1つ作り、パッケージの全てのテストで共有する。initdb は root では
+// 動かないため、root ではテストを飛ばす。
+//
+// JDBC の TCK は Java の実行環境が要るため、まだ含めない。
+
+// server はパッケージの全てのテストで共有するテスト用のサーバー
+var server struct {
+	sync.Once
+	node *cluster.Node
+	err  error
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if server.node != nil {
+		server.node.Teardown()
+	}
+	os.Exit(code)
+}
+
+// connstr はテスト用のサーバーの postgres データベースへの接続文字列を返す。サーバーは最初に
+// 呼んだときに起動する。
+func connstr(t *testing.T) string {
+	t.Helper()
+	if os.Geteuid() == 0 {
+		t.Skip("initdb cannot be run as root")
+	}
+	server.Do(func() {
+		server.node, server.err = startServer()
+	})
+	if server.err != nil {
+		t.Fatal(server.err)
+	}
+	// テスト用のサーバーは SSL を受け付けない
+	return server.node.Connstr("postgres") + " sslmode=disable"
+}
+
+// startServer はテスト用のサーバーを作って起動する。PG_TEST_BINDIR がなければ postgres と
+// initdb をビルドする。
+func startServer() (*cluster.Node, error) {
+	n, err := cluster.New("drivers", "")
+	if err != nil {
+		return nil, err
+	}
+	if n.BinDir == "" {
+		n.BinDir = filepath.Join(n.BaseDir, "bin")
+		for _, name := range []string{"postgres", "initdb"} {
+			out, err := exec.Command("go", "build", "-o", filepath.Join(n.BinDir, name),
+				"github.com/Tsubasa-2005/go-postgres/cmd/"+name).CombinedOutput()
+			if err != nil {
+				n.Teardown()
+				return nil, fmt.Errorf("could not build %s: %w\n%s", name, err, out)
+			}
+		}
+	}
+	if err := n.Init(); err != nil {
+		n.Teardown()
+		return nil, err
+	}
+	if err := n.Start(); err != nil {
+		n.Teardown()
+		return nil, err
+	}
+	return n, nil
+}
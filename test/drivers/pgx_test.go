@@ -0,0 +1,346 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ----------------------------------------------------------------
+// pgx
+// ----------------------------------------------------------------
+// pgx は既定では文を名前付きの文として Parse と Describe で準備してキャッシュし、型を知っている
+// パラメータと結果をバイナリの形式でやり取りする。QueryExecMode で、文を準備せずに Describe する
+// もの、型を問い合わせずにテキストの形式で送るもの、単純問い合わせに埋め込むものに切り替えられる。
+
+func connectPgx(t *testing.T) *pgx.Conn {
+	t.Helper()
+	return connectPgxConfig(t, func(*pgx.ConnConfig) {})
+}
+
+func connectPgxConfig(t *testing.T, configure func(*pgx.ConnConfig)) *pgx.Conn {
+	t.Helper()
+	cfg, err := pgx.ParseConfig(connstr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configure(cfg)
+	ctx := context.Background()
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close(ctx) })
+	return conn
+}
+
+// pgError は err が *pgconn.PgError ならそれを返す
+func pgError(t *testing.T, err error) *pgconn.PgError {
+	t.Helper()
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		t.Fatalf("error = %v, want *pgconn.PgError", err)
+	}
+	return pgErr
+}
+
+func TestPgxBinaryResultFormats(t *testing.T) {
+	conn := connectPgx(t)
+	ctx := context.Background()
+	tests := []struct {
+		query string
+		dest  any
+		want  any
+	}{
+		{"SELECT 12::int2", new(int16), int16(12)},
+		{"SELECT -34::int4", new(int32), int32(-34)},
+		{"SELECT 5000000000::int8", new(int64), int64(5000000000)},
+		{"SELECT 1.5::float4", new(float32), float32(1.5)},
+		{"SELECT 0.25::float8", new(float64), 0.25},
+		{"SELECT false", new(bool), false},
+		{"SELECT 'abc'::text", new(string), "abc"},
+		{"SELECT 'x'::varchar", new(string), "x"},
+		{"SELECT 'pg_class'::name", new(string), "pg_class"},
+		{"SELECT 1259::oid", new(uint32), uint32(1259)},
+		{"SELECT '\\x00ff'::bytea", new([]byte), []byte{0, 0xFF}},
+		{"SELECT '2024-02-29'::date", new(time.Time), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"SELECT '2024-02-29 12:34:56.789'::timestamp", new(time.Time), time.Date(2024, 2, 29, 12, 34, 56, 789000000, time.UTC)},
+		{"SELECT '2024-02-29 12:00:00+09'::timestamptz", new(time.Time), time.Date(2024, 2, 29, 3, 0, 0, 0, time.UTC)},
+		{"SELECT '1 mon 2 days 00:00:03'::interval", new(pgtype.Interval), pgtype.Interval{Months: 1, Days: 2, Microseconds: 3000000, Valid: true}},
+		{"SELECT '12:00'::time", new(pgtype.Time), pgtype.Time{Microseconds: 12 * 3600 * 1000000, Valid: true}},
+		{"SELECT '00000000-0000-0000-0000-000000000001'::uuid", new([16]byte), [16]byte{15: 1}},
+		{`SELECT '{"a": [1, 2]}'::jsonb`, new(map[string]any), map[string]any{"a": []any{1.0, 2.0}}},
+		{`SELECT '{"b": null}'::json`, new(map[string]any), map[string]any{"b": nil}},
+		{"SELECT '{1,NULL,3}'::int4[]", new([]*int32), []*int32{ptr(int32(1)), nil, ptr(int32(3))}},
+		{"SELECT NULL::int4", new(*int32), (*int32)(nil)},
+	}
+	for _, tt := range tests {
+		if err := conn.QueryRow(ctx, tt.query).Scan(tt.dest); err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		got := reflect.ValueOf(tt.dest).Elem().Interface()
+		if tm, ok := got.(time.Time); ok {
+			got = tm.UTC()
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %#v, want %#v", tt.query, got, tt.want)
+		}
+	}
+
+	var num pgtype.Numeric
+	if err := conn.QueryRow(ctx, "SELECT 123.450::numeric").Scan(&num); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := num.Float64Value(); err != nil || f.Float64 != 123.45 {
+		t.Errorf("numeric = %v, %v; want 123.45", f, err)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestPgxQueryExecModes(t *testing.T) {
+	conn := connectPgx(t)
+	ctx := context.Background()
+	modes := []pgx.QueryExecMode{
+		pgx.QueryExecModeCacheStatement,
+		pgx.QueryExecModeCacheDescribe,
+		pgx.QueryExecModeDescribeExec,
+		pgx.QueryExecModeExec,
+		pgx.QueryExecModeSimpleProtocol,
+	}
+	for _, mode := range modes {
+		// 同じ文を2回実行し、キャッシュした文も使う
+		for range 2 {
+			var (
+				i int64
+				s string
+				n *float64
+				x []byte
+				d time.Time
+			)
+			err := conn.QueryRow(ctx, "SELECT $1::int8, $2::text, $3::float8, $4::bytea, $5::date", mode,
+				int64(-9), "a'b", nil, []byte("\x00z"), time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)).Scan(&i, &s, &n, &x, &d)
+			if err != nil {
+				t.Errorf("%v: %v", mode, err)
+				break
+			}
+			if i != -9 || s != "a'b" || n != nil || string(x) != "\x00z" || !d.Equal(time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("%v: row = %v, %q, %v, %q, %v", mode, i, s, n, x, d)
+			}
+		}
+	}
+}
+
+func TestPgxBatch(t *testing.T) {
+	conn := connectPgx(t)
+	ctx := context.Background()
+
+	// 文を1回の Sync にまとめてパイプラインで送る
+	batch := &pgx.Batch{}
+	batch.Queue("SELECT 1::int4")
+	batch.Queue("SELECT $1::text", "two")
+	batch.Queue("SET application_name = 'pgx_batch'")
+	results := conn.SendBatch(ctx, batch)
+	var one int32
+	var two string
+	if err := results.QueryRow().Scan(&one); err != nil || one != 1 {
+		t.Errorf("first result = %v, %v; want 1", one, err)
+	}
+	if err := results.QueryRow().Scan(&two); err != nil || two != "two" {
+		t.Errorf("second result = %q, %v; want \"two\"", two, err)
+	}
+	if tag, err := results.Exec(); err != nil || tag.String() != "SET" {
+		t.Errorf("third result = %v, %v; want SET", tag, err)
+	}
+	if err := results.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := conn.PgConn().ParameterStatus("application_name"); got != "pgx_batch" {
+		t.Errorf("application_name parameter status = %q, want \"pgx_batch\"", got)
+	}
+
+	// 既定のモードでは全ての文を先に準備するため、準備に失敗すればどの文も実行しない
+	batch = &pgx.Batch{}
+	batch.Queue("SELECT 1::int4")
+	batch.Queue("SELECT nosuchcolumn")
+	results = conn.SendBatch(ctx, batch)
+	if err := results.QueryRow().Scan(&one); pgError(t, err).Code != "42703" {
+		t.Errorf("batch with a failing statement = %v, want SQLSTATE 42703", err)
+	}
+	results.Close()
+
+	// 準備せずに送る文がエラーになった後は、Sync までのメッセージを読み飛ばす
+	pb := &pgconn.Batch{}
+	pb.ExecParams("SELECT 1::int4", nil, nil, nil, nil)
+	pb.ExecParams("SELECT nosuchcolumn", nil, nil, nil, nil)
+	pb.ExecParams("SELECT 3::int4", nil, nil, nil, nil)
+	rs, err := conn.PgConn().ExecBatch(ctx, pb).ReadAll()
+	if pgError(t, err).Code != "42703" {
+		t.Errorf("pipeline with a failing statement = %v, want SQLSTATE 42703", err)
+	}
+	if len(rs) != 1 || len(rs[0].Rows) != 1 || string(rs[0].Rows[0][0]) != "1" {
+		t.Errorf("pipeline results = %+v, want only the first statement's row", rs)
+	}
+	if err := conn.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgxMultipleStatements(t *testing.T) {
+	conn := connectPgx(t)
+	results, err := conn.PgConn().Exec(context.Background(), "SELECT 1; SHOW server_version; SELECT 'x' AS a, 'y' AS b").ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	last := results[2]
+	if len(last.FieldDescriptions) != 2 || last.FieldDescriptions[1].Name != "b" ||
+		len(last.Rows) != 1 || string(last.Rows[0][1]) != "y" || last.CommandTag.String() != "SELECT 1" {
+		t.Errorf("last result = %v, %q, %v", last.FieldDescriptions, last.Rows, last.CommandTag)
+	}
+}
+
+func TestPgxParameterStatus(t *testing.T) {
+	conn := connectPgx(t)
+	pg := conn.PgConn()
+	for name, want := range map[string]string{
+		"client_encoding":             "UTF8",
+		"standard_conforming_strings": "on",
+		"integer_datetimes":           "on",
+		"DateStyle":                   "ISO, MDY",
+	} {
+		if got := pg.ParameterStatus(name); got != want {
+			t.Errorf("parameter status %s = %q, want %q", name, got, want)
+		}
+	}
+	if pg.ParameterStatus("server_version") == "" {
+		t.Error("server_version is not reported")
+	}
+	if pg.PID() == 0 || pg.SecretKey() == nil {
+		t.Error("BackendKeyData is not received")
+	}
+}
+
+func TestPgxErrorFields(t *testing.T) {
+	conn := connectPgx(t)
+	ctx := context.Background()
+	_, err := conn.Exec(ctx, "SELECT 1 FROM")
+	pgErr := pgError(t, err)
+	if pgErr.Severity != "ERROR" || pgErr.SeverityUnlocalized != "ERROR" || pgErr.Code != "42601" || pgErr.Position != 14 {
+		t.Errorf("severity, code, position = %s, %s, %d; want ERROR, 42601, 14", pgErr.Severity, pgErr.Code, pgErr.Position)
+	}
+
+	// 準備に失敗した文はキャッシュに残らず、次も同じエラーになる
+	for range 2 {
+		_, err = conn.Exec(ctx, "SELECT * FROM regress_nosuch_table")
+		if pgErr := pgError(t, err); pgErr.Code != "42P01" {
+			t.Errorf("SQLSTATE = %s, want 42P01", pgErr.Code)
+		}
+	}
+	if err := conn.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgxNotice(t *testing.T) {
+	var notices []*pgconn.Notice
+	conn := connectPgxConfig(t, func(cfg *pgx.ConnConfig) {
+		cfg.OnNotice = func(_ *pgconn.PgConn, n *pgconn.Notice) { notices = append(notices, n) }
+	})
+	if _, err := conn.Exec(context.Background(), "DROP TABLE IF EXISTS regress_nosuch_table"); err != nil {
+		t.Fatal(err)
+	}
+	if len(notices) != 1 || notices[0].Severity != "NOTICE" ||
+		notices[0].Message != `table "regress_nosuch_table" does not exist, skipping` {
+		t.Errorf("notices = %v, want one NOTICE about the missing table", notices)
+	}
+}
+
+func TestPgxTransactions(t *testing.T) {
+	conn := connectPgx(t)
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.NotDeferrable})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var isolation string
+	if err := tx.QueryRow(ctx, "SHOW transaction_isolation").Scan(&isolation); err != nil {
+		t.Fatal(err)
+	}
+	if isolation != "repeatable read" {
+		t.Errorf("transaction_isolation = %q, want \"repeatable read\"", isolation)
+	}
+	if status := conn.PgConn().TxStatus(); status != 'T' {
+		t.Errorf("transaction status = %c, want T", status)
+	}
+	if _, err := tx.Exec(ctx, "SELECT nosuchcolumn"); err == nil {
+		t.Fatal("query with an unknown column succeeded")
+	}
+	if status := conn.PgConn().TxStatus(); status != 'E' {
+		t.Errorf("transaction status after error = %c, want E", status)
+	}
+	if err := tx.Commit(ctx); !errors.Is(err, pgx.ErrTxCommitRollback) {
+		t.Errorf("commit of failed transaction = %v, want ErrTxCommitRollback", err)
+	}
+	if status := conn.PgConn().TxStatus(); status != 'I' {
+		t.Errorf("transaction status after commit = %c, want I", status)
+	}
+}
+
+func TestPgxCancelRequest(t *testing.T) {
+	conn := connectPgx(t)
+	ctx := context.Background()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := conn.Exec(ctx, "SELECT pg_sleep(10)")
+		errc <- err
+	}()
+	// 文が始まるまで待ってから取り消す
+	waitForActive(t, connectPgx(t), conn.PgConn().PID())
+	if err := conn.PgConn().CancelRequest(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; pgError(t, err).Code != "57014" {
+		t.Errorf("canceled query = %v, want SQLSTATE 57014", err)
+	}
+	if err := conn.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitForActive は pid のバックエンドが文を実行し始めるまで、pg_stat_activity を conn で見て待つ
+func waitForActive(t *testing.T, conn *pgx.Conn, pid uint32) {
+	t.Helper()
+	ctx := context.Background()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		rows, err := conn.Query(ctx, "SELECT pid, state FROM pg_stat_activity")
+		if err != nil {
+			t.Fatal(err)
+		}
+		active := false
+		for rows.Next() {
+			var p int32
+			var state *string
+			if err := rows.Scan(&p, &state); err != nil {
+				t.Fatal(err)
+			}
+			active = active || uint32(p) == pid && state != nil && *state == "active"
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if active {
+			return
+		}
+	}
+	t.Fatalf("backend %d did not start the query", pid)
+}
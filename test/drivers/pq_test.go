@@ -0,0 +1,229 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------
+// lib/pq
+// ----------------------------------------------------------------
+// lib/pq は引数のない問い合わせを単純問い合わせで、引数のある問い合わせを名前のない文の拡張
+// 問い合わせで送る。パラメータは []byte だけをバイナリの形式で、他はテキストの形式で送り、結果は
+// 全てテキストの形式で受け取る。
+
+func openPQ(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("postgres", connstr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// pqError は err が *pq.Error ならそれを返す
+func pqError(t *testing.T, err error) *pq.Error {
+	t.Helper()
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		t.Fatalf("error = %v, want *pq.Error", err)
+	}
+	return pqErr
+}
+
+func TestPQSimpleQuery(t *testing.T) {
+	db := openPQ(t)
+	var (
+		i int64
+		s string
+		b bool
+		f float64
+		n sql.NullString
+	)
+	err := db.QueryRow("SELECT 42::int8, 'hello'::text, true, 2.5::float8, NULL::text").Scan(&i, &s, &b, &f, &n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != 42 || s != "hello" || !b || f != 2.5 || n.Valid {
+		t.Errorf("row = %v, %q, %v, %v, %v; want 42, \"hello\", true, 2.5, NULL", i, s, b, f, n)
+	}
+
+	// 複数の行
+	rows, err := db.Query("SELECT name FROM pg_settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if count == 0 {
+		t.Error("pg_settings returned no rows")
+	}
+}
+
+func TestPQExtendedQueryParameters(t *testing.T) {
+	db := openPQ(t)
+	var (
+		i int64
+		s string
+		b bool
+		f float64
+		n sql.NullInt64
+		x []byte
+	)
+	err := db.QueryRow("SELECT $1::int8, $2::text, $3::bool, $4::float8, $5::int8, $6::bytea",
+		int64(-7), "it's", true, 0.125, nil, []byte{0, 1, 0xFF}).Scan(&i, &s, &b, &f, &n, &x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != -7 || s != "it's" || !b || f != 0.125 || n.Valid || !bytes.Equal(x, []byte{0, 1, 0xFF}) {
+		t.Errorf("row = %v, %q, %v, %v, %v, %v; want -7, \"it's\", true, 0.125, NULL, [0 1 255]", i, s, b, f, n, x)
+	}
+
+	// 型を決められないパラメータ
+	_, err = db.Exec("SELECT $1", "x")
+	if pqErr := pqError(t, err); pqErr.Code != "42P18" {
+		t.Errorf("SQLSTATE = %s, want 42P18", pqErr.Code)
+	}
+}
+
+func TestPQPreparedStatement(t *testing.T) {
+	db := openPQ(t)
+	stmt, err := db.Prepare("SELECT $1::int4 AS a, $2::text AS b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for k := int32(0); k < 3; k++ {
+		var a int32
+		var b string
+		if err := stmt.QueryRow(k, "v").Scan(&a, &b); err != nil {
+			t.Fatal(err)
+		}
+		if a != k || b != "v" {
+			t.Errorf("execution %d = %v, %q; want %v, \"v\"", k, a, b, k)
+		}
+	}
+
+	rows, err := stmt.Query(int32(1), "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 2 || types[0].Name() != "a" || types[0].DatabaseTypeName() != "INT4" ||
+		types[1].Name() != "b" || types[1].DatabaseTypeName() != "TEXT" {
+		t.Errorf("column types = %v, %v; want a INT4, b TEXT", types[0], types[1])
+	}
+}
+
+func TestPQTransactions(t *testing.T) {
+	db := openPQ(t)
+	ctx := context.Background()
+
+	// BEGIN READ ONLY ISOLATION LEVEL SERIALIZABLE
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var isolation, readOnly string
+	if err := tx.QueryRow("SHOW transaction_isolation").Scan(&isolation); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.QueryRow("SHOW transaction_read_only").Scan(&readOnly); err != nil {
+		t.Fatal(err)
+	}
+	if isolation != "serializable" || readOnly != "on" {
+		t.Errorf("transaction_isolation, transaction_read_only = %q, %q; want \"serializable\", \"on\"", isolation, readOnly)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// エラーの後は ROLLBACK するまで文を受け付けない
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("SELECT nosuchcolumn"); err == nil {
+		t.Fatal("query with an unknown column succeeded")
+	}
+	_, err = tx.Exec("SELECT 1")
+	if pqErr := pqError(t, err); pqErr.Code != "25P02" {
+		t.Errorf("SQLSTATE after error = %s, want 25P02", pqErr.Code)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	var one int
+	if err := db.QueryRow("SELECT 1").Scan(&one); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPQErrorFields(t *testing.T) {
+	db := openPQ(t)
+	_, err := db.Exec("SELECT 1 FROM")
+	pqErr := pqError(t, err)
+	if pqErr.Severity != "ERROR" || pqErr.Code != "42601" || pqErr.Position != "14" {
+		t.Errorf("severity, code, position = %s, %s, %s; want ERROR, 42601, 14", pqErr.Severity, pqErr.Code, pqErr.Position)
+	}
+
+	_, err = db.Exec("SELECT * FROM regress_nosuch_table")
+	if pqErr := pqError(t, err); pqErr.Code != "42P01" || pqErr.Message != `relation "regress_nosuch_table" does not exist` {
+		t.Errorf("code, message = %s, %q; want 42P01, relation does not exist", pqErr.Code, pqErr.Message)
+	}
+}
+
+func TestPQNotice(t *testing.T) {
+	base, err := pq.NewConnector(connstr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notices []*pq.Error
+	db := sql.OpenDB(pq.ConnectorWithNoticeHandler(base, func(n *pq.Error) {
+		notices = append(notices, n)
+	}))
+	defer db.Close()
+	if _, err := db.Exec("DROP TABLE IF EXISTS regress_nosuch_table"); err != nil {
+		t.Fatal(err)
+	}
+	if len(notices) != 1 || notices[0].Severity != "NOTICE" || notices[0].Code != "00000" ||
+		notices[0].Message != `table "regress_nosuch_table" does not exist, skipping` {
+		t.Errorf("notices = %v, want one NOTICE about the missing table", notices)
+	}
+}
+
+func TestPQCancel(t *testing.T) {
+	db := openPQ(t)
+	db.SetMaxOpenConns(1)
+
+	// 文脈を取り消すと lib/pq は CancelRequest を送る
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := db.ExecContext(ctx, "SELECT pg_sleep(10)")
+	if pqErr := pqError(t, err); pqErr.Code != "57014" {
+		t.Errorf("SQLSTATE = %s, want 57014", pqErr.Code)
+	}
+
+	// 取り消した後も同じ接続を使える
+	var one int
+	if err := db.QueryRow("SELECT 1").Scan(&one); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,28 @@
+# BEGIN と ROLLBACK の可視性
+#
+# s1 がトランザクションブロックを始めると、他のセッションからは idle in transaction に見え、
+# そのトランザクション ID は実行中になる。s1 がロールバックすると、s2 のスナップショットの
+# xmin に残った s1 のトランザクション ID はアボート済みになる。ブロックの中でエラーになると、
+# トランザクションは ROLLBACK を待たずにその場でアボートし、s1 は ROLLBACK するまで
+# idle in transaction (aborted) に見える。
+
+session s1
+step s1b { BEGIN; }
+step s1x { SELECT txid_current() > 0 AS xid_assigned; }
+step s1err { SELECT nonexistent_column; }
+step s1r { ROLLBACK; }
+
+session s2
+step s2b { BEGIN ISOLATION LEVEL REPEATABLE READ; }
+step s2snap { SELECT 1 AS snapshot_taken; }
+step s2show
+{
+  SELECT application_name, state,
+         txid_status(backend_xid::text::bigint) AS xid_status,
+         txid_status(backend_xmin::text::bigint) AS xmin_status
+    FROM pg_stat_activity;
+}
+step s2c { COMMIT; }
+
+permutation s1b s1x s2b s2snap s2show s1r s2show s2c
+permutation s1b s1x s2b s2snap s1err s2show s1r s2show s2c
@@ -0,0 +1,27 @@
+# 実行中とコミット済みの xmin
+#
+# s2 の REPEATABLE READ のスナップショットは、取った時点で実行中の s1 のトランザクションを
+# xmin に持つ。s1 がコミットすると、同じ xmin は実行中からコミット済みに変わるが、s2 は
+# スナップショットを取り直さないため xmin は変わらない。
+#
+# テーブルはまだないため、行の代わりに pg_stat_activity の backend_xid と backend_xmin の
+# トランザクションの状態を読む。
+
+session s1
+step s1b { BEGIN; }
+step s1x { SELECT txid_current() > 0 AS xid_assigned; }
+step s1c { COMMIT; }
+
+session s2
+step s2b { BEGIN ISOLATION LEVEL REPEATABLE READ; }
+step s2snap { SELECT 1 AS snapshot_taken; }
+step s2show
+{
+  SELECT application_name, state,
+         txid_status(backend_xid::text::bigint) AS xid_status,
+         txid_status(backend_xmin::text::bigint) AS xmin_status
+    FROM pg_stat_activity;
+}
+step s2c { COMMIT; }
+
+permutation s1b s1x s2b s2snap s2show s1c s2show s2c
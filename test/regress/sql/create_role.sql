@@ -0,0 +1,33 @@
+--
+-- CREATE ROLE
+--
+
+CREATE ROLE regress_role_plain;
+CREATE ROLE regress_role_plain;
+CREATE USER regress_role_user PASSWORD 'secret';
+CREATE GROUP regress_role_group;
+CREATE ROLE regress_role_admin WITH LOGIN SUPERUSER CREATEDB CREATEROLE CONNECTION LIMIT 5 VALID UNTIL 'infinity';
+CREATE ROLE regress_role_noopts NOSUPERUSER NOLOGIN INHERIT NOCREATEDB NOCREATEROLE NOREPLICATION NOBYPASSRLS;
+
+-- メンバーシップ
+CREATE ROLE regress_role_member IN ROLE regress_role_group;
+CREATE ROLE regress_role_parent ROLE regress_role_plain;
+CREATE ROLE regress_role_adminof ADMIN regress_role_plain;
+
+-- 不正なオプション
+CREATE ROLE regress_role_badlimit CONNECTION LIMIT -2;
+
+-- 予約されている名前
+CREATE ROLE public;
+CREATE ROLE none;
+CREATE ROLE pg_regress_role;
+CREATE ROLE "";
+
+-- ALTER ROLE
+ALTER ROLE regress_role_plain LOGIN CONNECTION LIMIT 3;
+
+-- DROP ROLE
+DROP ROLE regress_role_member, regress_role_parent, regress_role_adminof;
+DROP ROLE regress_role_plain, regress_role_user, regress_role_group, regress_role_admin, regress_role_noopts;
+DROP ROLE regress_role_plain;
+DROP ROLE IF EXISTS regress_role_plain;
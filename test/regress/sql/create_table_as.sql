@@ -0,0 +1,69 @@
+--
+-- CREATE TABLE AS と SELECT INTO
+--
+
+-- 定数とシステムビューから作る
+CREATE TABLE regress_ctas1 AS SELECT 1 AS a, 'one'::text AS b, NULL::integer AS c;
+SELECT * FROM regress_ctas1;
+SELECT b, a FROM regress_ctas1;
+CREATE TABLE regress_ctas2 AS SELECT archived_count, failed_count FROM pg_stat_archiver;
+SELECT * FROM regress_ctas2;
+
+-- 型の指定のない文字列は text の列になる
+CREATE TABLE regress_ctas3 AS SELECT 'abc' AS s, 2.5 AS n, true AS t;
+SELECT * FROM regress_ctas3;
+
+-- SELECT INTO
+SELECT b, a AS x INTO regress_ctas4 FROM regress_ctas1, regress_ctas3;
+SELECT * FROM regress_ctas4;
+SELECT t.x, t.b FROM regress_ctas4 t;
+
+-- 列名の指定
+CREATE TABLE regress_ctas5 (x, y) AS SELECT 1, 2, 3;
+SELECT * FROM regress_ctas5;
+CREATE TABLE regress_ctas6 (x, x) AS SELECT 1, 2;
+CREATE TABLE regress_ctas6 AS SELECT 1 AS a, 2 AS a;
+
+-- WITH NO DATA は行を書かない
+CREATE TABLE regress_ctas6 AS SELECT 1 AS a WITH NO DATA;
+SELECT * FROM regress_ctas6;
+
+-- 既にあるテーブル
+CREATE TABLE regress_ctas1 AS SELECT 1;
+CREATE TABLE IF NOT EXISTS regress_ctas1 AS SELECT 1;
+SELECT * INTO regress_ctas1 FROM regress_ctas5;
+
+-- 作れないテーブル
+CREATE TEMP TABLE regress_ctas7 AS SELECT 1;
+CREATE TABLE regress_noschema.regress_ctas7 AS SELECT 1;
+CREATE TABLE pg_catalog.regress_ctas7 AS SELECT 1;
+SELECT * FROM regress_ctas7;
+
+-- アボートしたら作ったテーブルは消える
+BEGIN;
+CREATE TABLE regress_ctas7 AS SELECT 7 AS a;
+SELECT * FROM regress_ctas7;
+ROLLBACK;
+SELECT * FROM regress_ctas7;
+
+-- アボートしたら削除したテーブルは戻る
+BEGIN;
+DROP TABLE regress_ctas1;
+SELECT * FROM regress_ctas1;
+ROLLBACK;
+SELECT * FROM regress_ctas1;
+
+-- 同じトランザクションで作って削除する
+BEGIN;
+CREATE TABLE regress_ctas7 AS SELECT 7 AS a;
+DROP TABLE regress_ctas7;
+COMMIT;
+SELECT * FROM regress_ctas7;
+
+-- DROP TABLE
+DROP TABLE regress_ctas7;
+DROP TABLE IF EXISTS regress_ctas7;
+DROP TABLE regress_noschema.regress_ctas7;
+DROP TABLE pg_settings;
+DROP TABLE regress_ctas1, regress_ctas2, regress_ctas3, regress_ctas4, regress_ctas5, regress_ctas6;
+SELECT * FROM regress_ctas1;
@@ -0,0 +1,34 @@
+--
+-- ドメイン
+--
+
+-- CHECK 制約
+CREATE DOMAIN regress_posint AS integer CHECK (VALUE > 0);
+SELECT 5::regress_posint;
+SELECT (-5)::regress_posint;
+SELECT 0::regress_posint AS zero;
+
+-- NOT NULL と DEFAULT
+CREATE DOMAIN regress_nnint AS int NOT NULL;
+SELECT 3::regress_nnint;
+SELECT NULL::regress_nnint;
+CREATE DOMAIN regress_dtext AS text DEFAULT 'x';
+SELECT 'abc'::regress_dtext;
+
+-- ドメインの上のドメインは、基のドメインの制約も確かめる
+CREATE DOMAIN regress_smallposint AS regress_posint CHECK (VALUE < 100);
+SELECT 50::regress_smallposint;
+SELECT 500::regress_smallposint;
+SELECT (-1)::regress_smallposint;
+
+-- 作れないドメイン
+CREATE DOMAIN regress_posint AS integer;
+CREATE DOMAIN regress_baddomain AS nonexistent_type;
+
+-- DROP DOMAIN
+DROP DOMAIN regress_smallposint;
+DROP DOMAIN regress_posint;
+DROP DOMAIN IF EXISTS regress_nosuchdomain;
+DROP DOMAIN regress_nosuchdomain;
+DROP DOMAIN regress_nnint, regress_dtext;
+SELECT 1::regress_nnint;
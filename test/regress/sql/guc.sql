@@ -0,0 +1,66 @@
+--
+-- SET, SHOW, RESET
+--
+
+-- 単位付きの値と単位のない値
+SHOW lock_timeout;
+SET lock_timeout = '10s';
+SHOW lock_timeout;
+SET lock_timeout TO 2000;
+SHOW lock_timeout;
+RESET lock_timeout;
+SHOW lock_timeout;
+
+-- 範囲外や不正な値は受け付けず、値は変わらない
+SET extra_float_digits = 3;
+SET extra_float_digits = 4;
+SHOW extra_float_digits;
+SET lock_timeout = 'abc';
+SET lock_timeout = -1;
+SET client_min_messages = nonsense;
+RESET extra_float_digits;
+
+-- 存在しないパラメータ
+SET nonexistent_param = 1;
+SHOW nonexistent_param;
+
+-- 大文字小文字を区別しない名前と DEFAULT
+SET DateStyle = 'Postgres, DMY';
+SHOW datestyle;
+SET datestyle TO DEFAULT;
+SHOW DateStyle;
+
+-- リストの値
+SET search_path = foo, bar;
+SHOW search_path;
+RESET search_path;
+
+-- 変更できないパラメータ
+SET server_version = '1';
+SET port = 1;
+
+-- SET LOCAL はトランザクションブロックの終わりで元に戻る
+SET LOCAL lock_timeout = '1s';
+SHOW lock_timeout;
+BEGIN;
+SET LOCAL lock_timeout = '2s';
+SHOW lock_timeout;
+COMMIT;
+SHOW lock_timeout;
+
+-- SET はコミットすれば残り、ロールバックすれば元に戻る
+BEGIN;
+SET lock_timeout = '3s';
+SHOW lock_timeout;
+ROLLBACK;
+SHOW lock_timeout;
+BEGIN;
+SET lock_timeout = '4s';
+COMMIT;
+SHOW lock_timeout;
+
+-- RESET ALL
+SET DateStyle = 'SQL, DMY';
+RESET ALL;
+SHOW lock_timeout;
+SHOW DateStyle;
@@ -0,0 +1,34 @@
+--
+-- hstore の型、演算子と演算子クラス
+--
+
+-- 入出力。キーは長さ、バイト列の順に並び、重複したキーは最初のものを残す
+SELECT 'a=>1, b=>2'::hstore;
+SELECT 'bb=>x, a=>y, a=>z'::hstore;
+SELECT '"k y"=>"v\"q", n=>NULL, s=>"NULL"'::hstore;
+SELECT ''::hstore;
+SELECT 'a=>'::hstore;
+SELECT 'a=1'::hstore;
+
+-- 値の取り出しとキーの有無
+SELECT 'a=>1, b=>NULL'::hstore -> 'a' AS a, 'a=>1, b=>NULL'::hstore -> 'b' AS b,
+  'a=>1, b=>NULL'::hstore -> 'c' AS c;
+SELECT 'a=>1, b=>NULL'::hstore ? 'b' AS has_b, 'a=>1'::hstore ? 'c' AS has_c;
+SELECT 'a=>1, b=>2'::hstore ?| '{c,b}' AS any_key, 'a=>1, b=>2'::hstore ?& '{c,b}' AS all_keys;
+
+-- 包含
+SELECT 'a=>1, b=>2'::hstore @> 'a=>1' AS contains, 'a=>1, b=>2'::hstore @> 'a=>2' AS not_contains;
+SELECT 'a=>1'::hstore <@ 'a=>1, b=>2' AS contained;
+
+-- 連結と削除
+SELECT 'a=>1, b=>2'::hstore || 'b=>3, c=>4' AS concat;
+SELECT 'a=>1, b=>2'::hstore - 'a' AS deleted;
+
+-- 関数
+SELECT akeys('a=>1, b=>NULL'), avals('a=>1, b=>NULL');
+SELECT slice('a=>1, b=>2, c=>3', '{c,a,x}');
+SELECT hstore_to_json('a=>1, n=>NULL'), hstore_to_jsonb('a=>1, n=>NULL');
+
+-- gin の既定の演算子クラス。組み込みのため削除できない
+DROP OPERATOR CLASS gin_hstore_ops USING gin;
+DROP OPERATOR -> (hstore, text);
@@ -0,0 +1,73 @@
+--
+-- テーブルの継承
+--
+
+-- INHERIT で既にあるテーブルを子にする。子の列は親の列の順に並べ直して読む
+CREATE TABLE regress_inh_p AS SELECT 1 AS a, 'p'::text AS b;
+CREATE TABLE regress_inh_c1 AS SELECT 'c1'::text AS b, 2 AS a, true AS extra;
+ALTER TABLE regress_inh_c1 INHERIT regress_inh_p;
+SELECT * FROM regress_inh_p;
+SELECT * FROM regress_inh_p*;
+SELECT * FROM ONLY regress_inh_p;
+SELECT b FROM ONLY (regress_inh_p);
+SELECT * FROM regress_inh_c1;
+
+-- 孫のテーブルも読む
+CREATE TABLE regress_inh_g AS SELECT 3 AS a, 'g'::text AS b, false AS extra, 'grandchild'::text AS note;
+ALTER TABLE regress_inh_g INHERIT regress_inh_c1;
+SELECT * FROM regress_inh_p;
+SELECT * FROM regress_inh_c1;
+SELECT p.a, c.a FROM ONLY regress_inh_p p, regress_inh_c1 c;
+
+-- CREATE TABLE ... INHERITS は親の列を受け継ぐ
+CREATE TABLE regress_inh_c2 (x integer) INHERITS (regress_inh_p);
+CREATE TABLE regress_inh_c3 (a integer, y text) INHERITS (regress_inh_c1, regress_inh_p);
+SELECT * FROM regress_inh_c3;
+SELECT * FROM regress_inh_p;
+
+-- 作れない子のテーブル
+CREATE TABLE regress_inh_bad (a text) INHERITS (regress_inh_p);
+CREATE TABLE regress_inh_bad (x integer, x text);
+CREATE TABLE regress_inh_bad () INHERITS (regress_inh_p, regress_inh_p);
+CREATE TABLE regress_inh_bad () INHERITS (pg_settings);
+CREATE TABLE regress_inh_bad () INHERITS (regress_inh_nope);
+CREATE TABLE regress_inh_bad (a);
+CREATE TABLE regress_inh_bad (a integer) AS SELECT 1;
+CREATE TABLE regress_inh_c2 () INHERITS (regress_inh_p);
+CREATE TABLE IF NOT EXISTS regress_inh_c2 () INHERITS (regress_inh_p);
+
+-- INHERIT と NO INHERIT の誤り
+CREATE TABLE regress_inh_e (z text);
+ALTER TABLE regress_inh_p INHERIT regress_inh_g;
+ALTER TABLE regress_inh_p INHERIT regress_inh_p;
+ALTER TABLE regress_inh_c1 INHERIT regress_inh_p;
+ALTER TABLE regress_inh_e INHERIT regress_inh_p;
+CREATE TABLE regress_inh_e2 AS SELECT 'x'::text AS a, 'y'::text AS b;
+ALTER TABLE regress_inh_e2 INHERIT regress_inh_p;
+ALTER TABLE regress_inh_c1 NO INHERIT regress_inh_e;
+ALTER TABLE pg_settings INHERIT regress_inh_p;
+ALTER TABLE regress_inh_nope INHERIT regress_inh_p;
+ALTER TABLE IF EXISTS regress_inh_nope INHERIT regress_inh_p;
+
+-- アボートしたら継承の変更は戻る
+BEGIN;
+ALTER TABLE regress_inh_c1 NO INHERIT regress_inh_p;
+SELECT * FROM regress_inh_p;
+ROLLBACK;
+SELECT * FROM regress_inh_p;
+
+-- NO INHERIT をコミットすると、子は受け継いだ列を持ったまま親から外れる
+ALTER TABLE regress_inh_g NO INHERIT regress_inh_c1;
+SELECT * FROM regress_inh_p;
+SELECT * FROM regress_inh_g;
+
+-- 子のある親は CASCADE でなければ削除できない
+DROP TABLE regress_inh_p;
+BEGIN;
+DROP TABLE regress_inh_p CASCADE;
+SELECT * FROM regress_inh_c3;
+ROLLBACK;
+SELECT * FROM regress_inh_p;
+DROP TABLE regress_inh_c2;
+DROP TABLE regress_inh_p CASCADE;
+DROP TABLE regress_inh_g, regress_inh_e, regress_inh_e2;
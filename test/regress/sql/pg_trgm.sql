@@ -0,0 +1,29 @@
+--
+-- pg_trgm の関数、演算子と演算子クラス
+--
+
+-- トライグラムと類似度
+SELECT show_trgm('Cat');
+SELECT show_trgm('a-b');
+SELECT similarity('word', 'two words'), word_similarity('word', 'two words'),
+  strict_word_similarity('word', 'two words');
+SELECT similarity('abc', 'xyz');
+
+-- 類似度の演算子。しきい値は既定値 (0.3, 0.6, 0.5) を使う
+SELECT 'word' % 'words' AS similar, 'word' % 'ward' AS not_similar;
+SELECT 'word' <% 'two words' AS word_similar, 'two words' %> 'word' AS commuted;
+SELECT 'word' <<% 'two words' AS strict_similar, 'two words' %>> 'word' AS commuted;
+SELECT 'word' <-> 'two words' AS dist, 'word' <<-> 'two words' AS word_dist,
+  'two words' <->> 'word' AS commuted;
+SELECT 'word' <<<-> 'two words' AS strict_dist, 'two words' <->>> 'word' AS commuted;
+
+-- 演算子クラスと演算子は組み込みのため、作り直したり削除したりできない
+CREATE OPERATOR CLASS gin_trgm_ops FOR TYPE text USING gin AS OPERATOR 1 %;
+DROP OPERATOR CLASS gin_trgm_ops USING gin;
+DROP OPERATOR CLASS gist_trgm_ops USING gist;
+DROP OPERATOR FAMILY gist_trgm_ops USING gist;
+DROP OPERATOR % (text, text);
+
+-- gtrgm は索引キーの型で、SQL から値を作れない。サポート関数も SQL から呼べない
+SELECT 'abc'::gtrgm;
+SELECT gtrgm_compress('x');
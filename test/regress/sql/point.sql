@@ -0,0 +1,33 @@
+--
+-- point の演算子と gist の point_ops
+--
+
+-- 距離と位置の比較
+SELECT '(0,0)'::point <-> '(3,4)'::point AS dist;
+SELECT '(3,4)' <-> '(0,0)'::point AS dist_unknown;
+SELECT '(1,1)'::point << '(2,2)' AS left_of, '(1,1)'::point >> '(2,2)' AS right_of;
+SELECT '(1,2)'::point >^ '(0,0)' AS above, '(1,2)'::point <^ '(0,0)' AS below;
+SELECT '(1,2)'::point ~= '(1,2)' AS same, '(1,2)'::point ~= '(1,2.5)' AS not_same;
+SELECT '(1,1)'::point <@ '(0,0),(2,2)'::box AS inside, '(3,3)'::point <@ '(0,0),(2,2)'::box AS outside;
+SELECT point_distance('(0,0)', '(1,0)');
+
+-- 組み込みの演算子クラスと演算子族
+CREATE OPERATOR CLASS point_ops FOR TYPE point USING gist AS OPERATOR 1 <<;
+CREATE OPERATOR <-> (leftarg = point, rightarg = point, function = point_distance);
+
+-- 組み込みのオブジェクトは削除できない
+DROP OPERATOR <-> (point, point);
+DROP OPERATOR CLASS point_ops USING gist;
+DROP OPERATOR FAMILY point_ops USING gist;
+ALTER OPERATOR FAMILY point_ops USING gist DROP OPERATOR 15 (point, point);
+ALTER OPERATOR FAMILY point_ops USING gist DROP FUNCTION 1 (point, point);
+
+-- 組み込みの演算子と btree の float_ops は、利用者の演算子族でも使える
+CREATE OPERATOR FAMILY regress_point_fam USING gist;
+ALTER OPERATOR FAMILY regress_point_fam USING gist
+  ADD OPERATOR 15 <-> (point, point) FOR ORDER BY float_ops;
+ALTER OPERATOR FAMILY regress_point_fam USING gist DROP OPERATOR 15 (point, point);
+DROP OPERATOR FAMILY regress_point_fam USING gist;
+
+-- internal の引数を持つサポート関数は SQL から呼べない
+SELECT gist_box_same('(1,1),(0,0)', '(1,1),(0,0)', 'x');
@@ -0,0 +1,80 @@
+--
+-- トランザクションブロック
+--
+
+-- 入れ子の BEGIN と、ブロックの外の COMMIT / ROLLBACK は警告になる
+BEGIN;
+BEGIN;
+COMMIT;
+COMMIT;
+ROLLBACK;
+
+-- 分離レベルと読み取り専用
+SHOW transaction_isolation;
+START TRANSACTION ISOLATION LEVEL REPEATABLE READ;
+SHOW transaction_isolation;
+END;
+SHOW transaction_isolation;
+BEGIN;
+SET TRANSACTION ISOLATION LEVEL SERIALIZABLE;
+SHOW transaction_isolation;
+COMMIT;
+SET TRANSACTION READ ONLY;
+BEGIN ISOLATION LEVEL SERIALIZABLE, READ ONLY;
+SHOW transaction_isolation;
+SHOW transaction_read_only;
+CREATE DOMAIN xact_ro_domain AS integer;
+ABORT;
+START TRANSACTION READ WRITE;
+SHOW transaction_read_only;
+ROLLBACK;
+
+-- エラーになったブロックでは、ブロックを終える文のほかは実行しない
+BEGIN;
+SELECT 1 AS one;
+SELECT nonexistent_column;
+SELECT 1 AS one;
+SHOW transaction_isolation;
+COMMIT;
+SELECT 1 AS one;
+
+BEGIN;
+SELECT nonexistent_column;
+ROLLBACK;
+SELECT 1 AS one;
+
+-- ブロックの中で作ったものはコミット後も見える
+BEGIN;
+CREATE DOMAIN xact_domain AS integer;
+SELECT 1::xact_domain;
+COMMIT;
+SELECT 2::xact_domain;
+DROP DOMAIN xact_domain;
+
+-- AND NO CHAIN は書かない場合と同じ
+BEGIN;
+COMMIT AND NO CHAIN;
+BEGIN;
+ROLLBACK WORK AND NO CHAIN;
+
+-- セーブポイント、AND CHAIN と2相コミットはまだない
+SAVEPOINT sp;
+BEGIN;
+SAVEPOINT sp;
+ROLLBACK;
+BEGIN;
+RELEASE SAVEPOINT sp;
+SELECT 1 AS one;
+ROLLBACK TO SAVEPOINT sp;
+SELECT 1 AS one;
+ROLLBACK;
+BEGIN;
+COMMIT AND CHAIN;
+ROLLBACK AND CHAIN;
+ROLLBACK;
+BEGIN;
+PREPARE TRANSACTION 'regress_xact';
+ROLLBACK;
+COMMIT PREPARED 'regress_xact';
+ROLLBACK PREPARED 'regress_xact';
+SHOW transaction_isolation;
@@ -0,0 +1,21 @@
+--
+-- トランザクション ID
+--
+
+-- 1つのトランザクションの中では同じ ID を返す
+BEGIN;
+SELECT txid_current() = txid_current() AS same_xid;
+SELECT txid_status(txid_current());
+COMMIT;
+SELECT txid_current() = txid_current() AS same_xid;
+
+-- 書き込むまで ID を割り当てない
+SELECT txid_current_if_assigned();
+
+-- 特別なトランザクション ID と、まだ割り当てていない ID
+SELECT txid_status(1);
+SELECT txid_status(100000000);
+
+-- xid8 の入力
+SELECT '10'::xid8;
+SELECT 'abc'::xid8;